@@ -0,0 +1,209 @@
+//go:build mage || tools
+// +build mage tools
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Counters holds hardware counters and energy captured for one benchmark
+// process. They cover the whole run (fixture loading, warmup and both timed
+// loops), so compare them between runs of the same suite rather than reading
+// them as per-operation costs. Fields are zero when the platform does not
+// expose them.
+type Counters struct {
+	Cycles       uint64 `json:"cycles,omitempty"`
+	Instructions uint64 `json:"instructions,omitempty"`
+	CacheMisses  uint64 `json:"cache_misses,omitempty"`
+	EnergyUJ     uint64 `json:"energy_uj,omitempty"`
+}
+
+// perfEvents are the events requested from perf stat, in output order.
+var perfEvents = []string{"cycles", "instructions", "cache-misses"}
+
+var (
+	perfOnce      sync.Once
+	perfAvailable bool
+)
+
+// countersEnabled reports whether counter collection should be attempted.
+// Collection is Linux-only and can be disabled with BENCH_COUNTERS=0.
+func countersEnabled() bool {
+	return runtime.GOOS == "linux" && os.Getenv("BENCH_COUNTERS") != "0"
+}
+
+// havePerf probes once whether perf stat is installed and allowed to open
+// counters for unprivileged processes (see perf_event_paranoid).
+func havePerf() bool {
+	perfOnce.Do(func() {
+		if _, err := exec.LookPath("perf"); err != nil {
+			return
+		}
+		probe := exec.Command("perf", "stat", "-x,", "-e", "instructions", "--", "true")
+		out, err := probe.CombinedOutput()
+		perfAvailable = err == nil && !bytes.Contains(out, []byte("<not supported>"))
+	})
+	return perfAvailable
+}
+
+// runMeasured runs cmd like cmd.Output, additionally capturing perf counters
+// and RAPL energy for the process when available. The returned Counters is
+// nil when nothing could be measured.
+func runMeasured(cmd *exec.Cmd) ([]byte, *Counters, error) {
+	if !countersEnabled() {
+		output, err := cmd.Output()
+		return output, nil, err
+	}
+
+	var statFile string
+	if havePerf() {
+		f, err := os.CreateTemp("", "ffire-perf-*.csv")
+		if err == nil {
+			statFile = f.Name()
+			f.Close()
+			defer os.Remove(statFile)
+
+			args := []string{"stat", "-x,", "-o", statFile, "-e", strings.Join(perfEvents, ","), "--", cmd.Path}
+			wrapped := exec.Command("perf", append(args, cmd.Args[1:]...)...)
+			wrapped.Dir = cmd.Dir
+			wrapped.Env = cmd.Env
+			cmd = wrapped
+		}
+	}
+
+	before := readRAPL()
+	output, err := cmd.Output()
+	after := readRAPL()
+	if err != nil {
+		return output, nil, err
+	}
+
+	var c Counters
+	if statFile != "" {
+		if data, err := os.ReadFile(statFile); err == nil {
+			parsePerfStat(data, &c)
+		}
+	}
+	c.EnergyUJ = energyDelta(before, after)
+
+	if c == (Counters{}) {
+		return output, nil, nil
+	}
+	return output, &c, nil
+}
+
+// parsePerfStat reads perf stat CSV output (-x,) into c. Events that were
+// not counted or are unsupported are left at zero.
+func parsePerfStat(data []byte, c *Counters) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) < 3 {
+			continue
+		}
+		value, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		// Events may carry modifiers such as "cycles:u".
+		event, _, _ := strings.Cut(fields[2], ":")
+		switch event {
+		case "cycles":
+			c.Cycles = value
+		case "instructions":
+			c.Instructions = value
+		case "cache-misses":
+			c.CacheMisses = value
+		}
+	}
+}
+
+// raplZone is a snapshot of one top-level powercap energy counter.
+type raplZone struct {
+	energy   uint64
+	maxRange uint64
+}
+
+// readRAPL snapshots the energy counters of every RAPL package domain. It
+// returns nil when the powercap interface is missing or unreadable, which is
+// the default for unprivileged users on recent kernels.
+func readRAPL() map[string]raplZone {
+	zones, _ := filepath.Glob("/sys/class/powercap/intel-rapl:[0-9]")
+	if len(zones) == 0 {
+		return nil
+	}
+	snapshot := make(map[string]raplZone, len(zones))
+	for _, zone := range zones {
+		energy, err := readUint(filepath.Join(zone, "energy_uj"))
+		if err != nil {
+			return nil
+		}
+		maxRange, _ := readUint(filepath.Join(zone, "max_energy_range_uj"))
+		snapshot[zone] = raplZone{energy: energy, maxRange: maxRange}
+	}
+	return snapshot
+}
+
+// energyDelta sums the energy consumed across all zones between two
+// snapshots, accounting for counter wraparound.
+func energyDelta(before, after map[string]raplZone) uint64 {
+	var total uint64
+	for zone, b := range before {
+		a, ok := after[zone]
+		if !ok {
+			continue
+		}
+		if a.energy >= b.energy {
+			total += a.energy - b.energy
+		} else if b.maxRange > 0 {
+			total += b.maxRange - b.energy + a.energy
+		}
+	}
+	return total
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// printCounters prints the captured counters for a result, if any.
+func printCounters(r BenchResult) {
+	c := r.Counters
+	if c == nil {
+		return
+	}
+	if c.Cycles > 0 {
+		fmt.Printf("  ✓ Cycles: %d (%d instructions, %d cache misses)\n", c.Cycles, c.Instructions, c.CacheMisses)
+	}
+	if c.EnergyUJ > 0 {
+		fmt.Printf("  ✓ Energy: %.3f J\n", float64(c.EnergyUJ)/1e6)
+	}
+}
+
+// hasCounters reports whether any result carries captured counters.
+func hasCounters(results []BenchResult) bool {
+	for _, r := range results {
+		if r.Counters != nil {
+			return true
+		}
+	}
+	return false
+}
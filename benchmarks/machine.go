@@ -0,0 +1,73 @@
+//go:build mage || tools
+// +build mage tools
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// resultsVersion is the format of the files in results/. It must match
+// results.Version in the ffire module (pkg/results), which reads them for
+// ffire graph and ffire bench aggregate.
+const resultsVersion = 1
+
+// ResultsFile is a results file: the results of one suite run and the
+// machine it ran on, so runs from different hosts can be compared.
+type ResultsFile struct {
+	Version int           `json:"version"`
+	Machine *Machine      `json:"machine"`
+	Results []BenchResult `json:"results"`
+}
+
+// Machine describes the benchmark host, as in pkg/results.
+type Machine struct {
+	Host   string `json:"host"`
+	OS     string `json:"os,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	CPU    string `json:"cpu,omitempty"`
+	Cores  int    `json:"cores,omitempty"`
+	Memory int64  `json:"memory,omitempty"`
+}
+
+func currentMachine() *Machine {
+	m := &Machine{OS: runtime.GOOS, Arch: runtime.GOARCH, Cores: runtime.NumCPU()}
+	m.Host, _ = os.Hostname()
+	switch runtime.GOOS {
+	case "linux":
+		m.CPU = procField("/proc/cpuinfo", "model name")
+		if kb, err := strconv.ParseInt(strings.TrimSuffix(procField("/proc/meminfo", "MemTotal"), " kB"), 10, 64); err == nil {
+			m.Memory = kb * 1024
+		}
+	case "darwin":
+		if out, err := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output(); err == nil {
+			m.CPU = strings.TrimSpace(string(out))
+		}
+		if out, err := exec.Command("sysctl", "-n", "hw.memsize").Output(); err == nil {
+			m.Memory, _ = strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		}
+	}
+	return m
+}
+
+// procField returns the value of the first "key: value" line of a /proc file.
+func procField(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
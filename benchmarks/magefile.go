@@ -6,17 +6,24 @@
 // Usage:
 //
 //	mage gen {target}    - Generate benchmarks for specific language or 'all'
-//	                       Targets: all, go, cpp, java, csharp, dart, swift, zig, rust, proto
+//	                       Targets: all, go, cpp, java, csharp, dart, swift, zig, rust, proto,
+//	                       json, msgpack, cbor, baselines (json + msgpack + cbor),
+//	                       validated (Go decoding with full validation)
 //	                       Example: mage gen java
 //
 //	mage run {target}    - Run benchmarks for specific language or 'all'
-//	                       Targets: all, go, cpp, java, csharp, dart, swift, zig, rust, proto
+//	                       Targets: all, go, cpp, java, csharp, dart, swift, zig, rust, proto,
+//	                       json, msgpack, cbor, baselines, validated
 //	                       Example: mage run go
 //
 //	mage clean {target}  - Clean generated files for specific language or 'all'
-//	                       Targets: all, go, cpp, java, csharp, dart, swift, zig, rust, proto
+//	                       Targets: all, go, cpp, java, csharp, dart, swift, zig, rust, proto,
+//	                       json, msgpack, cbor, baselines, validated
 //	                       Example: mage clean cpp
 //
+//	The json, msgpack and cbor baselines are optional: 'all' and 'mage bench'
+//	include them only when BENCH_BASELINES=1 is set.
+//
 //	mage compare         - Generate comparison table from all benchmark results
 //	                       Shows performance metrics across all languages and formats
 //
@@ -45,16 +52,23 @@ import (
 const (
 	schemaDir  = "../testdata/schema"
 	jsonDir    = "../testdata/json"
-	protoDir   = "../testdata/proto"
 	genDir     = "generated"
 	resultsDir = "results"
 )
 
+// baselineFormats are the optional general-purpose formats benchmarked in Go
+// next to ffire and protobuf
+var baselineFormats = []string{"json", "msgpack", "cbor"}
+
+// baselinesEnabled reports whether 'all' targets include the baseline formats
+func baselinesEnabled() bool {
+	return os.Getenv("BENCH_BASELINES") == "1"
+}
+
 type BenchmarkSuite struct {
 	Name       string
 	SchemaFile string
 	JSONFile   string
-	ProtoFile  string
 }
 
 func discoverBenchmarks() ([]BenchmarkSuite, error) {
@@ -70,7 +84,6 @@ func discoverBenchmarks() ([]BenchmarkSuite, error) {
 		name := strings.TrimSuffix(base, ".ffi")
 
 		jsonFile := filepath.Join(jsonDir, name+".json")
-		protoFile := filepath.Join(protoDir, name+".proto")
 
 		// Check if JSON file exists (required)
 		if _, err := os.Stat(jsonFile); err != nil {
@@ -82,7 +95,6 @@ func discoverBenchmarks() ([]BenchmarkSuite, error) {
 			Name:       name,
 			SchemaFile: schemaFile,
 			JSONFile:   jsonFile,
-			ProtoFile:  protoFile,
 		})
 	}
 
@@ -101,6 +113,10 @@ type BenchResult struct {
 	WireSize    int    `json:"wire_size"`
 	FixtureSize int    `json:"fixture_size"`
 	Timestamp   string `json:"timestamp"`
+
+	// Counters is filled in by the runner on platforms that expose perf
+	// counters or RAPL energy; it is omitted elsewhere.
+	Counters *Counters `json:"counters,omitempty"`
 }
 
 // cleanAll removes all generated files AND results (private helper)
@@ -154,6 +170,14 @@ func genAll() error {
 		}
 	}
 
+	// Generate Go benchmarks that also decode with full validation
+	for _, suite := range suites {
+		fmt.Printf("🛡️  Generating validated Go benchmark: %s\n", suite.Name)
+		if err := genValidated(suite); err != nil {
+			fmt.Printf("  ⚠️  Skipping %s: %v\n", suite.Name, err)
+		}
+	}
+
 	// Generate ffire C++ benchmarks
 	for _, suite := range suites {
 		fmt.Printf("🔨 Generating ffire C++ benchmark: %s\n", suite.Name)
@@ -169,6 +193,13 @@ func genAll() error {
 		}
 	}
 
+	// Generate mixed-schema benchmarks (all suites interleaved in one loop)
+	for _, lang := range []string{"go", "cpp"} {
+		if err := genMixed(lang, suites); err != nil {
+			fmt.Printf("  ⚠️  Skipping mixed %s benchmark: %v\n", lang, err)
+		}
+	}
+
 	// NOTE: Python and JavaScript excluded from 'all' - use explicit targets
 	// Generate them with: mage gen python, mage gen javascript
 
@@ -232,12 +263,22 @@ func genAll() error {
 		}
 	}
 
-	// Generate proto benchmarks (only for those with .proto files)
+	// Generate proto benchmarks
 	for _, suite := range suites {
-		if _, err := os.Stat(suite.ProtoFile); err == nil {
-			fmt.Printf("📦 Generating proto benchmark: %s\n", suite.Name)
-			if err := genProto(suite.Name, suite.ProtoFile, suite.JSONFile); err != nil {
-				return fmt.Errorf("failed to generate proto benchmark for %s: %w", suite.Name, err)
+		fmt.Printf("📦 Generating proto benchmark: %s\n", suite.Name)
+		if err := genProto(suite); err != nil {
+			return fmt.Errorf("failed to generate proto benchmark for %s: %w", suite.Name, err)
+		}
+	}
+
+	// Generate the optional json/msgpack/cbor baselines
+	if baselinesEnabled() {
+		for _, format := range baselineFormats {
+			for _, suite := range suites {
+				fmt.Printf("📦 Generating %s baseline: %s\n", format, suite.Name)
+				if err := genBaseline(format, suite); err != nil {
+					fmt.Printf("  ⚠️  Skipping %s: %v\n", suite.Name, err)
+				}
 			}
 		}
 	}
@@ -246,26 +287,92 @@ func genAll() error {
 	return nil
 }
 
-// genProto generates proto benchmark
-func genProto(name, protoFile, jsonFile string) error {
-	outDir := filepath.Join(genDir, "proto_"+name)
-	if err := os.MkdirAll(outDir, 0755); err != nil {
+// genMixed generates a benchmark interleaving all suites' message types, with
+// results reported under the message name "mixed"
+func genMixed(lang string, suites []BenchmarkSuite) error {
+	var pairs []string
+	for _, suite := range suites {
+		pairs = append(pairs, suite.SchemaFile+"="+suite.JSONFile)
+	}
+
+	dir := "ffire_mixed"
+	if lang != "go" {
+		dir = "ffire_" + lang + "_mixed"
+	}
+
+	fmt.Printf("🔀 Generating mixed %s benchmark (%d message types)\n", lang, len(suites))
+	return sh.Run("ffire", "bench",
+		"--lang", lang,
+		"--mixed", strings.Join(pairs, ","),
+		"--output", filepath.Join(genDir, dir),
+		"--iterations", "100000",
+	)
+}
+
+// genProto generates the proto benchmark: ffire derives the .proto file and
+// the driver from the schema, protoc generates the Go code
+func genProto(suite BenchmarkSuite) error {
+	outDir := filepath.Join(genDir, "proto_"+suite.Name)
+	if err := sh.Run("ffire", "bench",
+		"--lang", "proto",
+		"--schema", suite.SchemaFile,
+		"--json", suite.JSONFile,
+		"--output", outDir,
+		"--iterations", "100000",
+	); err != nil {
 		return err
 	}
 
-	// Generate Go code from proto
 	fmt.Println("  Running protoc...")
-	if err := sh.Run("protoc",
-		"--go_out="+outDir,
-		"--proto_path="+protoDir,
-		protoFile,
+	cmd := exec.Command("protoc", "--go_out=.", "--go_opt=module=protobench", "bench.proto")
+	cmd.Dir = outDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("protoc failed: %w\n%s", err, out)
+	}
+
+	// Run go mod tidy to generate go.sum
+	cmd = exec.Command("go", "mod", "tidy")
+	cmd.Dir = outDir
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to run go mod tidy: %w", err)
+	}
+	return nil
+}
+
+// genValidated generates a Go benchmark whose decode time includes a full
+// validation pass, reported as format ffire-validated
+func genValidated(suite BenchmarkSuite) error {
+	return sh.Run("ffire", "bench",
+		"--lang", "go",
+		"--validate",
+		"--schema", suite.SchemaFile,
+		"--json", suite.JSONFile,
+		"--output", filepath.Join(genDir, "validated_"+suite.Name),
+		"--iterations", "100000",
+	)
+}
+
+// genBaseline generates a Go benchmark of a general-purpose format (json,
+// msgpack or cbor) on the Go types ffire generates for the schema
+func genBaseline(format string, suite BenchmarkSuite) error {
+	outDir := filepath.Join(genDir, format+"_"+suite.Name)
+	if err := sh.Run("ffire", "bench",
+		"--lang", format,
+		"--schema", suite.SchemaFile,
+		"--json", suite.JSONFile,
+		"--output", outDir,
+		"--iterations", "100000",
 	); err != nil {
-		return fmt.Errorf("protoc failed: %w", err)
+		return err
 	}
 
-	// Generate benchmark driver
-	fmt.Println("  Generating benchmark driver...")
-	return generateProtoBenchmark(name, outDir, jsonFile)
+	// Run go mod tidy to fetch the library and generate go.sum
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = outDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to run go mod tidy: %w\n%s", err, out)
+	}
+	return nil
 }
 
 // Gen generates benchmarks for the specified target
@@ -275,7 +382,9 @@ func genProto(name, protoFile, jsonFile string) error {
 //	mage gen go       - Generate only Go benchmarks
 //	mage gen java     - Generate only Java benchmarks
 //	mage gen cpp      - Generate only C++ benchmarks
-//	(supports: go, cpp, java, csharp, dart, swift, zig, rust, proto)
+//	mage gen baselines - Generate the json, msgpack and cbor baselines
+//	mage gen validated - Generate Go benchmarks that decode with full validation
+//	(supports: go, cpp, java, csharp, dart, swift, zig, rust, proto, json, msgpack, cbor, baselines, validated)
 func Gen(target string) error {
 	target = strings.ToLower(target)
 
@@ -289,10 +398,11 @@ func Gen(target string) error {
 		"go": true, "cpp": true, "java": true, "csharp": true,
 		"dart": true, "swift": true, "proto": true, "zig": true, "rust": true,
 		"js": true, "javascript": true, "python": true, "py": true,
+		"json": true, "msgpack": true, "cbor": true, "baselines": true, "validated": true,
 	}
 
 	if !validTargets[target] {
-		return fmt.Errorf("unknown target: %s\nValid targets: all, go, cpp, java, csharp, dart, swift, proto, zig, rust, js, python", target)
+		return fmt.Errorf("unknown target: %s\nValid targets: all, go, cpp, java, csharp, dart, swift, proto, zig, rust, js, python, json, msgpack, cbor, baselines, validated", target)
 	}
 
 	// Create output directories
@@ -325,7 +435,9 @@ func Gen(target string) error {
 //	mage run all      - Run all language benchmarks
 //	mage run go       - Run only Go benchmarks
 //	mage run java     - Run only Java benchmarks
-//	(supports: go, cpp, java, python, dart, swift, javascript/js, proto)
+//	mage run baselines - Run the json, msgpack and cbor baselines
+//	mage run validated - Run the Go benchmarks that decode with full validation
+//	(supports: go, cpp, java, python, dart, swift, javascript/js, proto, json, msgpack, cbor, baselines, validated)
 func Run(target string) error {
 	target = strings.ToLower(target)
 
@@ -355,6 +467,14 @@ func Run(target string) error {
 		if err := runProto(); err != nil {
 			fmt.Printf("⚠️  Proto benchmarks failed: %v\n", err)
 		}
+		if err := runValidated(); err != nil {
+			fmt.Printf("⚠️  Validated benchmarks failed: %v\n", err)
+		}
+		if baselinesEnabled() {
+			if err := runBaselines(); err != nil {
+				fmt.Printf("⚠️  Baseline benchmarks failed: %v\n", err)
+			}
+		}
 
 		return nil
 	}
@@ -383,8 +503,14 @@ func Run(target string) error {
 		return runJavaScript()
 	case "proto":
 		return runProto()
+	case "json", "msgpack", "cbor":
+		return runBaseline(target)
+	case "baselines":
+		return runBaselines()
+	case "validated":
+		return runValidated()
 	default:
-		return fmt.Errorf("unknown target: %s\nValid targets: all, go, cpp, java, csharp, dart, swift, zig, rust, python, js, proto", target)
+		return fmt.Errorf("unknown target: %s\nValid targets: all, go, cpp, java, csharp, dart, swift, zig, rust, python, js, proto, json, msgpack, cbor, baselines, validated", target)
 	}
 }
 
@@ -407,10 +533,11 @@ func Clean(target string) error {
 	validTargets := map[string]bool{
 		"go": true, "cpp": true, "java": true, "csharp": true,
 		"dart": true, "swift": true, "proto": true, "zig": true, "rust": true,
+		"json": true, "msgpack": true, "cbor": true, "baselines": true, "validated": true,
 	}
 
 	if !validTargets[target] {
-		return fmt.Errorf("unknown target: %s\nValid targets: all, go, cpp, java, csharp, dart, swift, proto, zig, rust", target)
+		return fmt.Errorf("unknown target: %s\nValid targets: all, go, cpp, java, csharp, dart, swift, proto, zig, rust, json, msgpack, cbor, baselines, validated", target)
 	}
 
 	// Remove language-specific generated files
@@ -420,8 +547,12 @@ func Clean(target string) error {
 	if target == "go" {
 		// Go benchmarks don't have language prefix
 		patterns = []string{filepath.Join(genDir, "ffire_*")}
-	} else if target == "proto" {
-		patterns = []string{filepath.Join(genDir, "proto_*")}
+	} else if target == "proto" || target == "json" || target == "msgpack" || target == "cbor" || target == "validated" {
+		patterns = []string{filepath.Join(genDir, target+"_*")}
+	} else if target == "baselines" {
+		for _, format := range baselineFormats {
+			patterns = append(patterns, filepath.Join(genDir, format+"_*"))
+		}
 	} else {
 		patterns = []string{filepath.Join(genDir, fmt.Sprintf("ffire_%s_*", target))}
 	}
@@ -512,6 +643,7 @@ func runGo() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -552,6 +684,7 @@ func runProto() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -560,6 +693,66 @@ func runProto() error {
 	return saveResults(allResults, "proto_go")
 }
 
+// runBaselines runs the json, msgpack and cbor baselines
+func runBaselines() error {
+	for _, format := range baselineFormats {
+		if err := runBaseline(format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBaseline runs the Go benchmarks of one baseline format
+func runBaseline(format string) error {
+	fmt.Printf("\n🏃 Running %s baseline benchmarks...\n", format)
+	return runGoPrefixed(format, format+"_go")
+}
+
+// runValidated runs the Go benchmarks that decode with full validation
+func runValidated() error {
+	fmt.Println("\n🏃 Running validated Go benchmarks...")
+	return runGoPrefixed("validated", "validated_go")
+}
+
+// runGoPrefixed runs the Go benchmarks in generated/<prefix>_* and saves
+// their results as results/<resultsName>.json
+func runGoPrefixed(prefix, resultsName string) error {
+	pattern := filepath.Join(genDir, prefix+"_*")
+	dirs, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		fmt.Printf("  ⚠️  No %s benchmarks found (skipping)\n", prefix)
+		return nil
+	}
+
+	var allResults []BenchResult
+	for _, dir := range dirs {
+		name := strings.TrimPrefix(filepath.Base(dir), prefix+"_")
+		fmt.Printf("\n  Testing: %s\n", name)
+
+		result, err := runGoBench(dir)
+		if err != nil {
+			fmt.Printf("  ❌ Failed: %v\n", err)
+			continue
+		}
+
+		// Print result
+		fmt.Printf("  ✓ Encode: %d ns/op\n", result.EncodeNs)
+		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
+		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
+		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
+
+		allResults = append(allResults, result)
+	}
+
+	return saveResults(allResults, resultsName)
+}
+
 // runCpp runs the C++ benchmarks
 func runCpp() error {
 	fmt.Println("\n🏃 Running ffire C++ benchmarks...")
@@ -592,6 +785,7 @@ func runCpp() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -649,6 +843,7 @@ func runPython() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -698,6 +893,7 @@ func runDart() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -747,6 +943,7 @@ func runSwift() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -796,6 +993,7 @@ func runZig() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -845,6 +1043,7 @@ func runRust() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -920,9 +1119,12 @@ func runJSBench(jsDir string) (BenchResult, error) {
 	cmd.Dir = jsDir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.CombinedOutput()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
-		return BenchResult{}, fmt.Errorf("benchmark failed: %w\nOutput: %s", err, string(output))
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return BenchResult{}, fmt.Errorf("benchmark failed: %w\nStderr: %s", err, exitErr.Stderr)
+		}
+		return BenchResult{}, fmt.Errorf("benchmark failed: %w", err)
 	}
 
 	// Parse JSON result
@@ -931,6 +1133,7 @@ func runJSBench(jsDir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse result: %w\nOutput: %s", err, string(output))
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -979,6 +1182,7 @@ func runJava() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -1027,6 +1231,7 @@ func runCSharp() error {
 		fmt.Printf("  ✓ Decode: %d ns/op\n", result.DecodeNs)
 		fmt.Printf("  ✓ Total:  %d ns/op\n", result.TotalNs)
 		fmt.Printf("  ✓ Size:   %d bytes\n", result.WireSize)
+		printCounters(result)
 
 		allResults = append(allResults, result)
 	}
@@ -1058,15 +1263,10 @@ func Compare() error {
 			continue
 		}
 
-		data, err := os.ReadFile(file)
+		results, err := loadResults(file)
 		if err != nil {
 			continue
 		}
-
-		var results []BenchResult
-		if err := json.Unmarshal(data, &results); err != nil {
-			continue
-		}
 		allResults = append(allResults, results...)
 	}
 
@@ -1132,10 +1332,21 @@ func Bench() error {
 		return err
 	}
 
+	if err := runValidated(); err != nil {
+		return err
+	}
+
+	if baselinesEnabled() {
+		if err := runBaselines(); err != nil {
+			return err
+		}
+	}
+
 	return Compare()
 }
 
 // Graph displays terminal graphs for encode/decode/total times from benchmark results
+// The charts are drawn by 'ffire graph', which also works on results outside this directory.
 // Usage:
 //
 //	mage graph all         - Show average across all schemas (encode, decode, total)
@@ -1144,226 +1355,56 @@ func Bench() error {
 func Graph(target string) error {
 	target = strings.ToLower(target)
 
-	var targetSchema string
-	if target != "all" {
-		targetSchema = target
-	}
-
-	if targetSchema != "" {
-		fmt.Printf("\n📈 Generating performance graphs for '%s' (stable languages)...\n", targetSchema)
-	} else {
-		fmt.Println("\n📈 Generating performance graphs (average across all schemas, stable languages)...")
-	}
-
-	// Load all result files
+	// Load all result files, skipping python and javascript results
 	files, err := filepath.Glob(filepath.Join(resultsDir, "*.json"))
 	if err != nil {
 		return err
 	}
-
-	var allResults []BenchResult
+	var stable []string
 	for _, file := range files {
-		// Skip python and javascript results
 		base := filepath.Base(file)
 		if strings.Contains(base, "python") || strings.Contains(base, "javascript") {
 			continue
 		}
-
-		data, err := os.ReadFile(file)
-		if err != nil {
-			continue
-		}
-
-		var results []BenchResult
-		if err := json.Unmarshal(data, &results); err != nil {
-			continue
-		}
-		allResults = append(allResults, results...)
+		stable = append(stable, file)
 	}
-
-	if len(allResults) == 0 {
+	if len(stable) == 0 {
 		return fmt.Errorf("no results found - run 'mage bench' or 'mage run all' first")
 	}
 
-	// Filter by schema if specified
-	if targetSchema != "" {
-		var filtered []BenchResult
-		for _, r := range allResults {
-			if r.Message == targetSchema {
-				filtered = append(filtered, r)
-			}
-		}
-		if len(filtered) == 0 {
-			return fmt.Errorf("no results found for schema '%s'\nAvailable schemas: run 'mage compare' to see all", targetSchema)
-		}
-		allResults = filtered
-	}
-
-	// Group results by language+format combination
-	type LangFormat struct {
-		language string
-		format   string
-	}
-
-	type LangFormatData struct {
-		encodeNs []float64
-		decodeNs []float64
-		totalNs  []float64
-	}
-
-	langFormatData := make(map[LangFormat]*LangFormatData)
-	messageNames := make(map[string]bool)
-
-	for _, r := range allResults {
-		messageNames[r.Message] = true
-		key := LangFormat{language: r.Language, format: r.Format}
-		if langFormatData[key] == nil {
-			langFormatData[key] = &LangFormatData{}
-		}
-		langFormatData[key].encodeNs = append(langFormatData[key].encodeNs, float64(r.EncodeNs))
-		langFormatData[key].decodeNs = append(langFormatData[key].decodeNs, float64(r.DecodeNs))
-		langFormatData[key].totalNs = append(langFormatData[key].totalNs, float64(r.TotalNs))
-	}
-
-	// Calculate averages for each language+format
-	type LangFormatAvg struct {
-		language string
-		format   string
-		label    string
-		encodeNs float64
-		decodeNs float64
-		totalNs  float64
-	}
-
-	var langFormatAvgs []LangFormatAvg
-	for key, data := range langFormatData {
-		encodeSum := 0.0
-		for _, v := range data.encodeNs {
-			encodeSum += v
-		}
-		decodeSum := 0.0
-		for _, v := range data.decodeNs {
-			decodeSum += v
-		}
-		totalSum := 0.0
-		for _, v := range data.totalNs {
-			totalSum += v
-		}
-
-		count := float64(len(data.encodeNs))
-		label := fmt.Sprintf("%s %s", key.format, key.language)
-		langFormatAvgs = append(langFormatAvgs, LangFormatAvg{
-			language: key.language,
-			format:   key.format,
-			label:    label,
-			encodeNs: encodeSum / count,
-			decodeNs: decodeSum / count,
-			totalNs:  totalSum / count,
-		})
-	}
-
-	// Helper function to display a chart
-	displayChart := func(title string, getValue func(LangFormatAvg) float64) {
-		// Sort by the metric
-		sorted := make([]LangFormatAvg, len(langFormatAvgs))
-		copy(sorted, langFormatAvgs)
-		sort.Slice(sorted, func(i, j int) bool {
-			return getValue(sorted[i]) < getValue(sorted[j])
-		})
-
-		// Print header
-		fmt.Println("\n╔══════════════════════════════════════════════════════════════════╗")
-		centerText := func(text string, width int) string {
-			padding := width - len(text)
-			if padding <= 0 {
-				return text
-			}
-			leftPad := padding / 2
-			rightPad := padding - leftPad
-			return strings.Repeat(" ", leftPad) + text + strings.Repeat(" ", rightPad)
-		}
-		fmt.Printf("║%s║\n", centerText(title, 66))
-		fmt.Println("╚══════════════════════════════════════════════════════════════════╝")
-		fmt.Println()
-
-		// Find max for scaling
-		maxVal := getValue(sorted[len(sorted)-1])
-		barWidth := 50
-
-		// Draw bars
-		for i, lf := range sorted {
-			val := getValue(lf)
-			usVal := val / 1000.0 // Convert to microseconds
-			barLen := int((val / maxVal) * float64(barWidth))
-			if barLen < 1 {
-				barLen = 1
-			}
-
-			bar := strings.Repeat("█", barLen)
-
-			// Color coding
-			var color string
-			if i == 0 {
-				color = "\033[32m" // Green
-			} else if float64(i) < float64(len(sorted))/2 {
-				color = "\033[33m" // Yellow
-			} else {
-				color = "\033[31m" // Red
-			}
-			resetColor := "\033[0m"
-
-			fmt.Printf("  %-15s %s%s%s %.2f μs", lf.label, color, bar, resetColor, usVal)
-			if i > 0 {
-				ratio := val / getValue(sorted[0])
-				fmt.Printf("  (%.2fx)", ratio)
-			}
-			fmt.Println()
-		}
-
+	if target != "all" {
+		fmt.Printf("\n📈 Generating performance graphs for '%s' (stable languages)...\n\n", target)
+	} else {
+		fmt.Println("\n📈 Generating performance graphs (average across all schemas, stable languages)...")
 		fmt.Println()
-
-		// Show comparison only for same language (e.g., ffire Go vs proto Go)
-		// Find ffire and proto implementations of the same language
-		for _, ffireLf := range sorted {
-			if ffireLf.format == "ffire" {
-				// Look for proto version of the same language
-				for _, protoLf := range sorted {
-					if protoLf.format == "proto" && protoLf.language == ffireLf.language {
-						ratio := getValue(protoLf) / getValue(ffireLf)
-						ffireVal := getValue(ffireLf) / 1000.0
-						protoVal := getValue(protoLf) / 1000.0
-						fmt.Printf("  → %s (%.2f μs) is %.2fx faster than %s (%.2f μs)\n",
-							ffireLf.label, ffireVal, ratio, protoLf.label, protoVal)
-					}
-				}
-			}
-		}
 	}
 
-	// Display schema context
-	if targetSchema != "" {
-		fmt.Printf("\nPerformance Metrics for '%s':\n", targetSchema)
-	} else {
-		fmt.Printf("\nPerformance Metrics - Average across %d schemas:\n", len(messageNames))
+	if err := sh.RunV("sh", "-c", "cd .. && go install ./cmd/ffire"); err != nil {
+		return fmt.Errorf("failed to build ffire: %w", err)
 	}
-
-	// Display three charts
-	displayChart("Encode Time (μs) - Lower is Better", func(lf LangFormatAvg) float64 { return lf.encodeNs })
-	displayChart("Decode Time (μs) - Lower is Better", func(lf LangFormatAvg) float64 { return lf.decodeNs })
-	displayChart("Total Time (μs) - Lower is Better", func(lf LangFormatAvg) float64 { return lf.totalNs })
-
-	return nil
+	args := append([]string{"graph"}, stable...)
+	if target != "all" {
+		args = append(args, "-message", target)
+	}
+	return sh.RunV("ffire", args...)
 }
 
 // Helper functions
 
 func runGoBench(dir string) (BenchResult, error) {
+	// Build first so compilation is not part of the measured run
+	buildCmd := exec.Command("go", "build", "-o", "bench", ".")
+	buildCmd.Dir = dir
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		return BenchResult{}, fmt.Errorf("go build failed: %w\nOutput: %s", err, output)
+	}
+
 	// Run benchmark with JSON output
-	cmd := exec.Command("go", "run", ".")
+	cmd := exec.Command("./bench")
 	cmd.Dir = dir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		return BenchResult{}, fmt.Errorf("benchmark failed: %w", err)
 	}
@@ -1373,6 +1414,7 @@ func runGoBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1388,7 +1430,7 @@ func runCppBench(dir string) (BenchResult, error) {
 	cmd := exec.Command(benchPath)
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		return BenchResult{}, fmt.Errorf("benchmark failed: %w", err)
 	}
@@ -1398,6 +1440,7 @@ func runCppBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1422,7 +1465,7 @@ func runPythonBench(dir string) (BenchResult, error) {
 	cmd.Dir = dir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		return BenchResult{}, fmt.Errorf("benchmark failed: %w", err)
 	}
@@ -1432,6 +1475,7 @@ func runPythonBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1455,7 +1499,7 @@ func runDartBench(dir string) (BenchResult, error) {
 	cmd.Dir = dartDir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		return BenchResult{}, fmt.Errorf("benchmark failed: %w", err)
 	}
@@ -1465,6 +1509,7 @@ func runDartBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1493,15 +1538,22 @@ func runSwiftBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to get absolute lib path: %w", err)
 	}
 
-	fmt.Printf("    Building and running Swift benchmark...\n")
-	cmd := exec.Command("swift", "run", "-c", "release", "bench")
+	fmt.Printf("    Building Swift benchmark...\n")
+	buildCmd := exec.Command("swift", "build", "-c", "release", "--product", "bench")
+	buildCmd.Dir = swiftDir
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		return BenchResult{}, fmt.Errorf("swift build failed: %w\nOutput: %s", err, output)
+	}
+
+	fmt.Printf("    Running Swift benchmark...\n")
+	cmd := exec.Command("swift", "run", "-c", "release", "--skip-build", "bench")
 	cmd.Dir = swiftDir
 	cmd.Env = append(os.Environ(),
 		"BENCH_JSON=1",
 		"DYLD_LIBRARY_PATH="+absLibDir,
 	)
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return BenchResult{}, fmt.Errorf("benchmark failed: %w\nStderr: %s", err, exitErr.Stderr)
@@ -1530,6 +1582,7 @@ func runSwiftBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w\nJSON: %s", err, jsonLine)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1572,7 +1625,7 @@ func runZigBench(dir string) (BenchResult, error) {
 		"LD_LIBRARY_PATH="+absLibDir,
 	)
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return BenchResult{}, fmt.Errorf("benchmark failed: %w\nStderr: %s", err, exitErr.Stderr)
@@ -1600,6 +1653,7 @@ func runZigBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w\nJSON: %s", err, jsonLine)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1619,7 +1673,7 @@ func runRustBench(dir string) (BenchResult, error) {
 	cmd.Dir = rustDir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return BenchResult{}, fmt.Errorf("benchmark failed: %w\nStderr: %s", err, exitErr.Stderr)
@@ -1647,6 +1701,7 @@ func runRustBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w\nJSON: %s", err, jsonLine)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1681,7 +1736,7 @@ func runJavaBench(dir string) (BenchResult, error) {
 	cmd.Dir = javaDir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return BenchResult{}, fmt.Errorf("benchmark failed: %w\nStderr: %s", err, exitErr.Stderr)
@@ -1694,6 +1749,7 @@ func runJavaBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w\nOutput: %s", err, output)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
@@ -1724,7 +1780,7 @@ func runCSharpBench(dir string) (BenchResult, error) {
 	cmd.Dir = csharpDir
 	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
 
-	output, err := cmd.Output()
+	output, counters, err := runMeasured(cmd)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return BenchResult{}, fmt.Errorf("benchmark failed: %w\nStderr: %s", err, exitErr.Stderr)
@@ -1737,11 +1793,13 @@ func runCSharpBench(dir string) (BenchResult, error) {
 		return BenchResult{}, fmt.Errorf("failed to parse JSON: %w\nOutput: %s", err, output)
 	}
 
+	result.Counters = counters
 	return result, nil
 }
 
 func saveResults(results []BenchResult, name string) error {
-	data, err := json.MarshalIndent(results, "", "  ")
+	file := ResultsFile{Version: resultsVersion, Machine: currentMachine(), Results: results}
+	data, err := json.MarshalIndent(file, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -1750,6 +1808,25 @@ func saveResults(results []BenchResult, name string) error {
 	return os.WriteFile(filename, data, 0644)
 }
 
+// loadResults reads a results file, or a bare array of results as written
+// before results files were versioned.
+func loadResults(path string) ([]BenchResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file ResultsFile
+	if err := json.Unmarshal(data, &file); err == nil {
+		if file.Version > resultsVersion {
+			return nil, fmt.Errorf("%s: results format version %d is newer than this suite supports", path, file.Version)
+		}
+		return file.Results, nil
+	}
+	var results []BenchResult
+	err = json.Unmarshal(data, &results)
+	return results, err
+}
+
 func printComparisonTable(results []BenchResult) {
 	fmt.Println("\n" + strings.Repeat("=", 95))
 	fmt.Println("BENCHMARK COMPARISON")
@@ -1797,6 +1874,23 @@ func saveMarkdownTable(results []BenchResult) error {
 			r.WireSize))
 	}
 
+	if hasCounters(results) {
+		buf.WriteString("\n## Hardware Counters\n\n")
+		buf.WriteString("Whole-process totals per benchmark run (Linux only; perf counters and RAPL energy where available).\n\n")
+		buf.WriteString("| Language | Format | Message | Cycles | Instructions | Cache Misses | Energy (mJ) |\n")
+		buf.WriteString("|----------|--------|---------|--------|--------------|--------------|-------------|\n")
+		for _, r := range results {
+			if r.Counters == nil {
+				continue
+			}
+			c := r.Counters
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s | %d | %d | %d | %.1f |\n",
+				r.Language, r.Format, r.Message,
+				c.Cycles, c.Instructions, c.CacheMisses,
+				float64(c.EnergyUJ)/1e3))
+		}
+	}
+
 	buf.WriteString("\n## Notes\n\n")
 	buf.WriteString("- All benchmarks use the same test fixture\n")
 	buf.WriteString("- Measurements exclude warmup and fixture loading\n")
@@ -1809,208 +1903,6 @@ func saveMarkdownTable(results []BenchResult) error {
 	)
 }
 
-func inferMessageType(name string, jsonData []byte) string {
-	// Map schema names to proto message type names (as defined in .proto files)
-	// Note: proto uses the exact message names from .proto files, no suffix added
-	typeMap := map[string]string{
-		"complex":      "PluginList",
-		"array_float":  "FloatList",
-		"array_int":    "IntList",
-		"array_string": "StringList",
-		"array_struct": "DeviceList",
-		"empty":        "EmptyTest",
-		"nested":       "Level1",
-		"optional":     "RecordList",
-		"struct":       "Config",
-		"tags":         "User",
-	}
-
-	if typeName, ok := typeMap[name]; ok {
-		return typeName
-	}
-
-	// Fallback: capitalize first letter
-	if len(name) > 0 {
-		return strings.ToUpper(name[:1]) + name[1:]
-	}
-	return "Message"
-}
-
-func getFieldName(name string) string {
-	// Map schema names to their proto field names for wrapping raw arrays
-	// Empty string means the JSON is already an object and doesn't need wrapping
-	fieldMap := map[string]string{
-		"complex":      "plugins",
-		"array_float":  "values",
-		"array_int":    "values",
-		"array_string": "values",
-		"array_struct": "devices",
-		"empty":        "",
-		"nested":       "", // already an object
-		"optional":     "records",
-		"struct":       "",
-		"tags":         "",
-	}
-
-	if fieldName, ok := fieldMap[name]; ok {
-		return fieldName
-	}
-	return "items"
-}
-
-func generateProtoBenchmark(name, outDir, jsonFile string) error {
-	// Read JSON fixture to determine message type
-	jsonData, err := os.ReadFile(jsonFile)
-	if err != nil {
-		return fmt.Errorf("failed to read JSON: %w", err)
-	}
-
-	// Infer message type from JSON structure
-	msgTypeName := inferMessageType(name, jsonData)
-	fieldName := getFieldName(name)
-
-	// Generate JSON wrapping code if needed (for raw arrays)
-	wrapCode := ""
-	if fieldName != "" {
-		wrapCode = `
-	// Wrap raw array JSON in object with field name for protojson
-	wrappedJSON := []byte(fmt.Sprintf("{%q: %s}", "` + fieldName + `", string(fixtureJSON)))
-	jsonToUnmarshal := wrappedJSON`
-	} else {
-		wrapCode = `
-	jsonToUnmarshal := fixtureJSON`
-	}
-
-	// Create benchmark driver using protojson for generic unmarshalling
-	benchCode := `package main
-
-import (
-	_ "embed"
-	"encoding/json"
-	"fmt"
-	"os"
-	"time"
-
-	testproto "protobench/github.com/shaban/ffire/testdata/proto"
-	"google.golang.org/protobuf/encoding/protojson"
-	"google.golang.org/protobuf/proto"
-)
-
-//go:embed fixture.json
-var fixtureJSON []byte
-
-type BenchResult struct {
-	Language    string ` + "`json:\"language\"`" + `
-	Format      string ` + "`json:\"format\"`" + `
-	Message     string ` + "`json:\"message\"`" + `
-	Iterations  int    ` + "`json:\"iterations\"`" + `
-	EncodeNs    int64  ` + "`json:\"encode_ns\"`" + `
-	DecodeNs    int64  ` + "`json:\"decode_ns\"`" + `
-	TotalNs     int64  ` + "`json:\"total_ns\"`" + `
-	WireSize    int    ` + "`json:\"wire_size\"`" + `
-	FixtureSize int    ` + "`json:\"fixture_size\"`" + `
-	Timestamp   string ` + "`json:\"timestamp\"`" + `
-}
-
-func main() {
-	iterations := 10000
-	jsonOutput := os.Getenv("BENCH_JSON") == "1"
-` + wrapCode + `
-
-	// Parse JSON into protobuf message using protojson
-	msg := &testproto.` + msgTypeName + `{}
-	if err := protojson.Unmarshal(jsonToUnmarshal, msg); err != nil {
-		panic(fmt.Sprintf("failed to parse fixture: %v", err))
-	}
-
-	// Warmup
-	for i := 0; i < 1000; i++ {
-		encoded, _ := proto.Marshal(msg)
-		decoded := &testproto.` + msgTypeName + `{}
-		proto.Unmarshal(encoded, decoded)
-	}
-
-	// Benchmark encode
-	start := time.Now()
-	var encoded []byte
-	for i := 0; i < iterations; i++ {
-		encoded, _ = proto.Marshal(msg)
-	}
-	encodeTime := time.Since(start)
-
-	// Benchmark decode
-	start = time.Now()
-	for i := 0; i < iterations; i++ {
-		decoded := &testproto.` + msgTypeName + `{}
-		proto.Unmarshal(encoded, decoded)
-	}
-	decodeTime := time.Since(start)
-
-	// Calculate metrics
-	encodeNs := encodeTime.Nanoseconds() / int64(iterations)
-	decodeNs := decodeTime.Nanoseconds() / int64(iterations)
-	totalNs := encodeNs + decodeNs
-
-	if jsonOutput {
-		result := BenchResult{
-			Language:    "Go",
-			Format:      "proto",
-			Message:     "` + name + `",
-			Iterations:  iterations,
-			EncodeNs:    encodeNs,
-			DecodeNs:    decodeNs,
-			TotalNs:     totalNs,
-			WireSize:    len(encoded),
-			FixtureSize: len(fixtureJSON),
-			Timestamp:   time.Now().Format(time.RFC3339),
-		}
-		json.NewEncoder(os.Stdout).Encode(result)
-	} else {
-		fmt.Printf("proto benchmark: ` + name + `\n")
-		fmt.Printf("Iterations:  %d\n", iterations)
-		fmt.Printf("Encode:      %d ns/op\n", encodeNs)
-		fmt.Printf("Decode:      %d ns/op\n", decodeNs)
-		fmt.Printf("Total:       %d ns/op\n", totalNs)
-		fmt.Printf("Wire size:   %d bytes\n", len(encoded))
-		fmt.Printf("Fixture:     %d bytes\n", len(fixtureJSON))
-		fmt.Printf("Total time:  %.2fs\n", (encodeTime + decodeTime).Seconds())
-	}
-}
-`
-
-	benchFile := filepath.Join(outDir, "bench.go")
-	if err := os.WriteFile(benchFile, []byte(benchCode), 0644); err != nil {
-		return fmt.Errorf("failed to write bench.go: %w", err)
-	}
-
-	// Copy JSON fixture
-	fixtureFile := filepath.Join(outDir, "fixture.json")
-	if err := os.WriteFile(fixtureFile, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write fixture: %w", err)
-	}
-
-	// Create go.mod
-	goMod := `module protobench
-
-go 1.21
-
-require google.golang.org/protobuf v1.31.0
-`
-	modFile := filepath.Join(outDir, "go.mod")
-	if err := os.WriteFile(modFile, []byte(goMod), 0644); err != nil {
-		return fmt.Errorf("failed to write go.mod: %w", err)
-	}
-
-	// Run go mod tidy to generate go.sum
-	cmd := exec.Command("go", "mod", "tidy")
-	cmd.Dir = outDir
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run go mod tidy: %w", err)
-	}
-
-	return nil
-}
-
 // Helper function for language generation
 func genLanguage(lang string, suites []BenchmarkSuite) error {
 	switch lang {
@@ -2027,6 +1919,9 @@ func genLanguage(lang string, suites []BenchmarkSuite) error {
 				return err
 			}
 		}
+		if err := genMixed("go", suites); err != nil {
+			return err
+		}
 	case "cpp":
 		for _, suite := range suites {
 			fmt.Printf("🔨 Generating C++ benchmark: %s\n", suite.Name)
@@ -2040,6 +1935,9 @@ func genLanguage(lang string, suites []BenchmarkSuite) error {
 				return err
 			}
 		}
+		if err := genMixed("cpp", suites); err != nil {
+			return err
+		}
 	case "java":
 		for _, suite := range suites {
 			fmt.Printf("☕ Generating Java benchmark: %s\n", suite.Name)
@@ -2095,16 +1993,27 @@ func genLanguage(lang string, suites []BenchmarkSuite) error {
 	case "proto":
 		for _, suite := range suites {
 			fmt.Printf("📦 Generating proto benchmark: %s\n", suite.Name)
-			if _, err := os.Stat(suite.ProtoFile); os.IsNotExist(err) {
-				fmt.Printf("  ⚠️  Skipping %s: no proto file\n", suite.Name)
-				continue
+			if err := genProto(suite); err != nil {
+				return err
+			}
+		}
+	case "json", "msgpack", "cbor":
+		for _, suite := range suites {
+			fmt.Printf("📦 Generating %s baseline: %s\n", lang, suite.Name)
+			if err := genBaseline(lang, suite); err != nil {
+				return err
 			}
-			outDir := filepath.Join(genDir, "proto_"+suite.Name)
-			if err := genProto(suite.Name, suite.ProtoFile, suite.JSONFile); err != nil {
+		}
+	case "baselines":
+		for _, format := range baselineFormats {
+			if err := genLanguage(format, suites); err != nil {
 				return err
 			}
-			fmt.Printf("  Generating benchmark driver...\n")
-			if err := generateProtoBenchmark(suite.Name, outDir, suite.JSONFile); err != nil {
+		}
+	case "validated":
+		for _, suite := range suites {
+			fmt.Printf("🛡️  Generating validated Go benchmark: %s\n", suite.Name)
+			if err := genValidated(suite); err != nil {
 				return err
 			}
 		}
@@ -2190,6 +2099,12 @@ func runLanguage(lang string) error {
 		return runJavaScript()
 	case "proto":
 		return runProto()
+	case "json", "msgpack", "cbor":
+		return runBaseline(lang)
+	case "baselines":
+		return runBaselines()
+	case "validated":
+		return runValidated()
 	default:
 		return fmt.Errorf("unknown language: %s", lang)
 	}
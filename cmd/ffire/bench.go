@@ -1,31 +1,91 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/shaban/ffire/pkg/benchmark"
+	"github.com/shaban/ffire/pkg/generator"
 	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
 	"github.com/shaban/ffire/pkg/validator"
 )
 
 func runBench(args []string) {
+	if len(args) > 0 && args[0] == "aggregate" {
+		runBenchAggregate(args[1:])
+		return
+	}
+
 	fs := flag.NewFlagSet("bench", flag.ExitOnError)
 	schemaFile := fs.String("schema", "", "Path to .ffi schema file (required)")
 	jsonFile := fs.String("json", "", "Path to JSON fixture file (required)")
 	outputDir := fs.String("output", "", "Output directory (required)")
-	lang := fs.String("lang", "go", "Target language: go, cpp, swift, dart, java, csharp, rust, zig (default: go)")
+	lang := fs.String("lang", "go", "Target language: go, cpp, swift, dart, java, csharp, rust, zig, or a Go baseline format: proto, json, msgpack, cbor (default: go)")
 	messageName := fs.String("message", "Message", "Message type name to encode (default: Message)")
 	iterations := fs.Int("iterations", 100000, "Number of benchmark iterations (default: 100000)")
+	replayDir := fs.String("replay", "", "Replay captured .bin payloads from this directory at a fixed rate instead of a tight loop (go, cpp)")
+	rate := fs.Int("rate", 10000, "Replay rate in messages per second")
+	duration := fs.Duration("duration", 10*time.Second, "Replay duration")
+	quick := fs.Bool("quick", false, "Build and run a short benchmark for every installed toolchain and print a summary table (go, cpp, rust, java, csharp)")
+	mixed := fs.String("mixed", "", "Interleave several message types in one benchmark: comma-separated schema.ffi=fixture.json pairs (go, cpp)")
+	validate := fs.Bool("validate", false, "Also time decoding with full validation (bounds, bools and optional flags, UTF-8, counts) against the fast path (go only)")
+	sanitize := fs.String("sanitize", "", "Build the benchmark with sanitizers: address, undefined, thread, leak (comma-separated; cpp only)")
+	cppSIMD := fs.Bool("cpp-simd", false, "Generate the C++ decoder with SIMD UTF-8 and bool validation (cpp only)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: ffire bench [options]
+       ffire bench aggregate [options] <results>...
 
 Generate benchmark executables with embedded fixtures.
 
+With --quick, the benchmark is generated, built and run for every language
+whose toolchain is installed, using 1000 iterations unless --iterations is
+given, and a compact comparison table is printed. --lang may restrict the
+languages (comma-separated); --output keeps the generated benchmarks.
+
+With --lang proto, the benchmark is the protobuf baseline ffire is compared
+against: a .proto file derived from the schema and a Go driver timing
+proto.Marshal and proto.Unmarshal on the same fixture. Building it needs
+protoc and protoc-gen-go.
+
+With --lang json, msgpack or cbor, the benchmark is a baseline for a
+general-purpose format: a Go driver timing encoding/json, vmihailenco/msgpack
+or fxamacker/cbor on the Go types ffire generates for the schema, with the
+same fixture values. Run go mod tidy first for msgpack and cbor.
+
+With --validate, the Go benchmark also times decoding with full validation:
+a pass specialized to the schema checks every bound, that bools and optional
+flags are 0x00 or 0x01, that strings are valid UTF-8 and that element counts
+fit the payload, then the fast decoder runs. It prints both decode times and
+the overhead, and reports the validated time as format ffire-validated with
+BENCH_JSON=1.
+
+With --mixed, one benchmark interleaves several message types in a
+pseudo-random order, dispatching on the type of each message.
+
+With --sanitize, the C++ benchmark is built with the given sanitizers so that
+running it reports memory errors and undefined behavior in the generated
+code. Its timings are not representative.
+
+With --cpp-simd, the C++ benchmark uses a decoder that also rejects invalid
+UTF-8 and bools other than 0x00/0x01, checking 16 bytes at a time with SSE2 or
+NEON, and reports format ffire-simd. make scalar (or the CMake bench_scalar
+target) builds the same checks without SIMD, to measure what SIMD gains.
+
+With --replay, the benchmark instead replays a directory of captured payloads
+at a fixed message rate, decoding and re-encoding each one, and reports tail
+latencies (p50/p90/p99/p99.9/max) measured from each message's scheduled time.
+
+ffire bench aggregate merges results from several machines into one
+comparison; see ffire bench aggregate -h.
+
 Options:
 `)
 		fs.PrintDefaults()
@@ -34,6 +94,16 @@ Examples:
   ffire bench --schema schema.ffi --json data.json --output bench/
   ffire bench --lang cpp --schema schema.ffi --json data.json --output bench_cpp/
   ffire bench --schema schema.ffi --json data.json --output bench/ --iterations 10000000
+  ffire bench --validate --schema schema.ffi --json data.json --output bench_validated/
+  ffire bench --lang proto --schema schema.ffi --json data.json --output bench_proto/
+  ffire bench --lang msgpack --schema schema.ffi --json data.json --output bench_msgpack/
+  ffire bench --quick --schema schema.ffi --json data.json
+  ffire bench --quick --lang go,cpp --schema schema.ffi --json data.json
+  ffire bench --lang cpp --mixed a.ffi=a.json,b.ffi=b.json --output mixed/
+  ffire bench --lang cpp --cpp-simd --schema schema.ffi --json data.json --output bench_simd/
+  ffire bench --lang cpp --sanitize address,undefined --schema schema.ffi --json data.json --output bench_asan/
+  ffire bench --schema schema.ffi --replay captures/ --rate 50000 --duration 30s --output replay/
+  ffire bench aggregate results-linux/ results-mac/
 `)
 	}
 
@@ -41,7 +111,32 @@ Examples:
 		os.Exit(1)
 	}
 
-	if *schemaFile == "" || *jsonFile == "" || *outputDir == "" {
+	sanitizers, err := generator.ParseSanitizers(*sanitize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --sanitize: %v\n", err)
+		os.Exit(1)
+	}
+	if len(sanitizers) > 0 && (*lang != "cpp" || *quick || *mixed != "" || *replayDir != "") {
+		fmt.Fprintln(os.Stderr, "Error: --sanitize is supported for plain --lang cpp benchmarks only")
+		os.Exit(1)
+	}
+
+	if *cppSIMD && (*lang != "cpp" || *quick || *mixed != "" || *replayDir != "") {
+		fmt.Fprintln(os.Stderr, "Error: --cpp-simd is supported for plain --lang cpp benchmarks only")
+		os.Exit(1)
+	}
+
+	if *validate && (*lang != "go" || *quick || *mixed != "" || *replayDir != "") {
+		fmt.Fprintln(os.Stderr, "Error: --validate is supported for plain --lang go benchmarks only")
+		os.Exit(1)
+	}
+
+	if *mixed != "" && *outputDir != "" {
+		runMixedBench(*lang, *mixed, *outputDir, *iterations)
+		return
+	}
+
+	if *schemaFile == "" || (*jsonFile == "" && *replayDir == "") || (*outputDir == "" && !*quick) {
 		fs.Usage()
 		os.Exit(1)
 	}
@@ -59,13 +154,6 @@ Examples:
 		os.Exit(1)
 	}
 
-	// Read JSON file
-	jsonData, err := os.ReadFile(*jsonFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading JSON file: %v\n", err)
-		os.Exit(1)
-	}
-
 	// Auto-detect message name if not specified or if default "Message" doesn't exist
 	actualMessageName := *messageName
 	if len(schema.Messages) == 0 {
@@ -88,20 +176,57 @@ Examples:
 		}
 	}
 
+	// Extract schema name from file path
+	schemaName := filepath.Base(*schemaFile)
+	schemaName = strings.TrimSuffix(schemaName, filepath.Ext(schemaName))
+
+	if *replayDir != "" {
+		runReplayBench(schema, schemaName, actualMessageName, *lang, *replayDir, *outputDir, benchmark.ReplayConfig{Rate: *rate, Duration: *duration})
+		return
+	}
+
+	// Read JSON file
+	jsonData, err := os.ReadFile(*jsonFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading JSON file: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Validate JSON against schema
 	if err := validator.ValidateJSON(schema, actualMessageName, jsonData); err != nil {
 		fmt.Fprintf(os.Stderr, "Error validating JSON: %s\n", formatError(err))
 		os.Exit(1)
 	}
 
-	// Extract schema name from file path
-	schemaName := filepath.Base(*schemaFile)
-	schemaName = strings.TrimSuffix(schemaName, filepath.Ext(schemaName))
+	if *quick {
+		explicit := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+		var langs []string
+		if explicit["lang"] {
+			for _, l := range strings.Split(*lang, ",") {
+				langs = append(langs, strings.TrimSpace(l))
+			}
+		}
+		n := quickIterations
+		if explicit["iterations"] {
+			n = *iterations
+		}
+		// Ctrl-C stops the running toolchain and still removes the temp directory
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		runQuickBench(ctx, schema, schemaName, actualMessageName, jsonData, langs, *outputDir, n)
+		return
+	}
 
 	// Generate benchmark based on language
 	switch *lang {
 	case "go":
-		if err := benchmark.GenerateGo(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
+		generate := benchmark.GenerateGo
+		if *validate {
+			generate = benchmark.GenerateGoValidated
+		}
+		if err := generate(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
 			os.Exit(1)
 		}
@@ -109,11 +234,18 @@ Examples:
 		fmt.Printf("  Run with: cd %s && go run .\n", *outputDir)
 
 	case "cpp":
-		if err := benchmark.GenerateCpp(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
+		opts := benchmark.CppOptions{Sanitize: sanitizers, SIMD: *cppSIMD}
+		if err := benchmark.GenerateCppWithOptions(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Printf("✓ Generated C++ benchmark in %s\n", *outputDir)
+		if len(sanitizers) > 0 {
+			fmt.Printf("  Builds with -fsanitize=%s; timings are not representative\n", strings.Join(sanitizers, ","))
+		}
+		if *cppSIMD {
+			fmt.Printf("  Decoder validates UTF-8 and bools with SIMD; make scalar builds bench_scalar without it\n")
+		}
 		fmt.Printf("\n  Build with CMake:\n")
 		fmt.Printf("    cd %s && cmake -B build && cmake --build build && ./build/bench\n", *outputDir)
 		fmt.Printf("\n  Or build with Make (fallback):\n")
@@ -150,6 +282,7 @@ Examples:
 		}
 		fmt.Printf("✓ Generated C# benchmark in %s\n", *outputDir)
 		fmt.Printf("  Run with: cd %s/csharp && dotnet run -c Release\n", *outputDir)
+		fmt.Printf("  BenchmarkDotNet: cd %s/csharp/BenchmarkDotNet && dotnet run -c Release\n", *outputDir)
 
 	case "zig":
 		if err := benchmark.GenerateZig(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
@@ -167,6 +300,22 @@ Examples:
 		fmt.Printf("✓ Generated Rust benchmark in %s\n", *outputDir)
 		fmt.Printf("  Run with: cd %s/rust && cargo build --release --bin bench && ./target/release/bench\n", *outputDir)
 
+	case "proto":
+		if err := benchmark.GenerateProto(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Generated protobuf benchmark in %s\n", *outputDir)
+		fmt.Printf("  Run with: cd %s && protoc --go_out=. --go_opt=module=protobench bench.proto && go mod tidy && go run .\n", *outputDir)
+
+	case "json", "msgpack", "cbor":
+		if err := benchmark.GenerateBaseline(*lang, schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Generated %s baseline benchmark in %s\n", *lang, *outputDir)
+		fmt.Printf("  Run with: cd %s && go mod tidy && go run .\n", *outputDir)
+
 	case "js", "javascript", "igniffi-js":
 		if err := benchmark.GenerateIgniffiJS(schema, schemaName, actualMessageName, jsonData, *outputDir, *iterations); err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
@@ -184,7 +333,104 @@ Examples:
 		fmt.Printf("  Run with: cd %s/python && pip install . && python bench.py\n", *outputDir)
 
 	default:
-		fmt.Fprintf(os.Stderr, "Error: unsupported language '%s' (supported: go, cpp, js, python, swift, dart, java, csharp, zig, rust)\n", *lang)
+		fmt.Fprintf(os.Stderr, "Error: unsupported language '%s' (supported: go, cpp, js, python, swift, dart, java, csharp, zig, rust, proto, json, msgpack, cbor)\n", *lang)
+		os.Exit(1)
+	}
+}
+
+func runReplayBench(s *schema.Schema, schemaName, messageName, lang, replayDir, outputDir string, cfg benchmark.ReplayConfig) {
+	// Captured payloads come from generated code, which uses canonical field order
+	s.Canonicalize()
+
+	payloads, err := benchmark.LoadReplayDir(s, messageName, replayDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading replay payloads: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch lang {
+	case "go":
+		if err := benchmark.GenerateGoReplay(s, schemaName, messageName, payloads, outputDir, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Generated Go replay benchmark in %s (%d payloads)\n", outputDir, len(payloads))
+		fmt.Printf("  Run with: cd %s && go run .\n", outputDir)
+
+	case "cpp":
+		if err := benchmark.GenerateCppReplay(s, schemaName, messageName, payloads, outputDir, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Generated C++ replay benchmark in %s (%d payloads)\n", outputDir, len(payloads))
+		fmt.Printf("  Run with: cd %s && make && ./bench\n", outputDir)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Error: replay benchmarks support go and cpp, not '%s'\n", lang)
+		os.Exit(1)
+	}
+}
+
+func runMixedBench(lang, pairs, outputDir string, iterations int) {
+	var suites []benchmark.MixedSuite
+	for _, pair := range strings.Split(pairs, ",") {
+		schemaFile, jsonFile, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Error: invalid --mixed entry %q (expected schema.ffi=fixture.json)\n", pair)
+			os.Exit(1)
+		}
+
+		s, err := parser.Parse(schemaFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing schema %s: %s\n", schemaFile, formatError(err))
+			os.Exit(1)
+		}
+		if err := validator.ValidateSchema(s); err != nil {
+			fmt.Fprintf(os.Stderr, "Error validating schema %s: %s\n", schemaFile, formatError(err))
+			os.Exit(1)
+		}
+		if len(s.Messages) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: %s has %d root types, mixed benchmarks need exactly one per schema\n", schemaFile, len(s.Messages))
+			os.Exit(1)
+		}
+
+		jsonData, err := os.ReadFile(jsonFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading JSON file: %v\n", err)
+			os.Exit(1)
+		}
+		if err := validator.ValidateJSON(s, s.Messages[0].Name, jsonData); err != nil {
+			fmt.Fprintf(os.Stderr, "Error validating %s: %s\n", jsonFile, formatError(err))
+			os.Exit(1)
+		}
+
+		suites = append(suites, benchmark.MixedSuite{
+			Name:        strings.TrimSuffix(filepath.Base(schemaFile), filepath.Ext(schemaFile)),
+			Schema:      s,
+			MessageName: s.Messages[0].Name,
+			JSONData:    jsonData,
+		})
+	}
+
+	switch lang {
+	case "go":
+		if err := benchmark.GenerateGoMixed(suites, outputDir, iterations); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Generated Go mixed benchmark in %s (%d message types)\n", outputDir, len(suites))
+		fmt.Printf("  Run with: cd %s && go run .\n", outputDir)
+
+	case "cpp":
+		if err := benchmark.GenerateCppMixed(suites, outputDir, iterations); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating benchmark: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Generated C++ mixed benchmark in %s (%d message types)\n", outputDir, len(suites))
+		fmt.Printf("  Run with: cd %s && make && ./bench\n", outputDir)
+
+	default:
+		fmt.Fprintf(os.Stderr, "Error: mixed benchmarks support go and cpp, not '%s'\n", lang)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/results"
+)
+
+// runBenchAggregate merges results from several machines into one
+// comparison.
+func runBenchAggregate(args []string) {
+	fs := flag.NewFlagSet("bench aggregate", flag.ExitOnError)
+	message := fs.String("message", "", "Only compare this message (default: a table per message)")
+	metric := fs.String("metric", "total", "Timing to compare: encode, decode or total")
+	out := fs.String("o", "", "Also write the merged results, with each result's machine, to this file")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire bench aggregate [options] <results>...
+
+Merge benchmark results from several machines into one comparison: a table
+per message with a row per implementation and a column per machine.
+
+Each <results> is a results file or a directory of them, such as the
+results/ directory of the benchmark suite copied from each host. Results
+record the machine they ran on; results from before that was recorded are
+labelled with the path they were loaded from.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire bench aggregate results-linux/ results-mac/ results-graviton/
+  ffire bench aggregate results-*/ -message complex -metric decode
+  ffire bench aggregate results-*/ -o merged.json
+  ffire graph merged.json -message complex
+`)
+	}
+
+	// Allow flags before, between and after the result paths
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(1)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := results.CompareOptions{Message: *message}
+	for i, m := range results.Metrics {
+		if strings.EqualFold(m.Name, *metric) {
+			opts.Metric = &results.Metrics[i]
+		}
+	}
+	if opts.Metric == nil {
+		fmt.Fprintf(os.Stderr, "Error: unknown -metric %q (want encode, decode or total)\n", *metric)
+		os.Exit(1)
+	}
+
+	var all []results.Result
+	for _, path := range paths {
+		rs, err := results.Load(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading results: %v\n", err)
+			os.Exit(1)
+		}
+		for i := range rs {
+			if rs[i].Machine == nil {
+				rs[i].Machine = &results.Machine{Host: path}
+			}
+		}
+		all = append(all, rs...)
+	}
+	if len(all) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no results in %s\n", strings.Join(paths, ", "))
+		os.Exit(1)
+	}
+
+	if err := results.CompareMachines(os.Stdout, all, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing merged results: %v\n", err)
+			os.Exit(1)
+		}
+		err = results.Write(f, all)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing merged results: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\n✓ Wrote %d results from %d machines to %s\n", len(all), len(results.Machines(all)), *out)
+	}
+}
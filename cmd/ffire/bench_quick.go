@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shaban/ffire/pkg/benchmark"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// quickIterations is the iteration count used by --quick unless
+// --iterations is given explicitly.
+const quickIterations = 1000
+
+// quickTimeout bounds generating, building and running one language.
+const quickTimeout = 2 * time.Minute
+
+// quickTarget describes how to build and run one language in quick mode.
+type quickTarget struct {
+	lang string
+	// tools lists required executables; "a|b" accepts either.
+	tools    []string
+	generate func(s *schema.Schema, schemaName, messageName string, jsonData []byte, outputDir string, iterations int) error
+	// subdir is the directory, relative to the output directory, that
+	// build and run commands execute in.
+	subdir string
+	build  [][]string
+	run    []string
+}
+
+// quickTargets are the languages whose benchmarks build without package
+// installs or native library setup. The mage suite covers the rest.
+var quickTargets = []quickTarget{
+	{
+		lang:     "go",
+		tools:    []string{"go"},
+		generate: benchmark.GenerateGo,
+		build:    [][]string{{"go", "build", "-o", "bench", "."}},
+		run:      []string{"./bench"},
+	},
+	{
+		lang:     "cpp",
+		tools:    []string{"make", "clang++|g++"},
+		generate: benchmark.GenerateCpp,
+		build:    [][]string{{"make"}},
+		run:      []string{"./bench"},
+	},
+	{
+		lang:     "rust",
+		tools:    []string{"cargo"},
+		generate: benchmark.GenerateRust,
+		subdir:   "rust",
+		build:    [][]string{{"cargo", "build", "--release", "--quiet", "--bin", "bench"}},
+		run:      []string{"./target/release/bench"},
+	},
+	{
+		lang:     "java",
+		tools:    []string{"javac", "java"},
+		generate: benchmark.GenerateJava,
+		subdir:   "java",
+		build:    [][]string{{"sh", "-c", "javac -d . *.java"}},
+		run:      []string{"java", "Bench"},
+	},
+	{
+		lang:     "csharp",
+		tools:    []string{"dotnet"},
+		generate: benchmark.GenerateCSharp,
+		subdir:   "csharp",
+		build:    [][]string{{"dotnet", "build", "-c", "Release", "--nologo", "-v", "q"}},
+		run:      []string{"dotnet", "run", "-c", "Release", "--no-build", "--nologo"},
+	},
+}
+
+// quickResult is the subset of a benchmark's JSON output shown in the table.
+type quickResult struct {
+	EncodeNs int64 `json:"encode_ns"`
+	DecodeNs int64 `json:"decode_ns"`
+	TotalNs  int64 `json:"total_ns"`
+	WireSize int   `json:"wire_size"`
+}
+
+// missingTool returns the first required tool that is not installed, or "".
+func (t quickTarget) missingTool() string {
+	for _, tool := range t.tools {
+		found := false
+		for _, alt := range strings.Split(tool, "|") {
+			if _, err := exec.LookPath(alt); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return tool
+		}
+	}
+	return ""
+}
+
+// runQuickBench generates, builds and runs a short benchmark for every
+// language with an installed toolchain and prints a compact table.
+// Canceling ctx stops the running toolchain and skips the remaining ones.
+func runQuickBench(ctx context.Context, s *schema.Schema, schemaName, messageName string, jsonData []byte, langs []string, outputDir string, iterations int) {
+	keep := outputDir != ""
+	if !keep {
+		dir, err := os.MkdirTemp("", "ffire-quick-*")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating temp directory: %v\n", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+		outputDir = dir
+	}
+
+	targets := quickTargets
+	if len(langs) > 0 {
+		targets = nil
+		for _, lang := range langs {
+			found := false
+			for _, t := range quickTargets {
+				if t.lang == lang {
+					targets = append(targets, t)
+					found = true
+				}
+			}
+			if !found {
+				fmt.Fprintf(os.Stderr, "Error: --quick does not support '%s' (supported: %s)\n", lang, quickLangs())
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Probe toolchains up front so the table shows what was skipped and why
+	fmt.Printf("Probing toolchains...\n")
+	var available []quickTarget
+	skipped := make(map[string]string)
+	for _, t := range targets {
+		if tool := t.missingTool(); tool != "" {
+			skipped[t.lang] = fmt.Sprintf("skipped (%s not found)", strings.ReplaceAll(tool, "|", "/"))
+			fmt.Printf("  ✗ %-7s %s not found\n", t.lang, strings.ReplaceAll(tool, "|", "/"))
+			continue
+		}
+		fmt.Printf("  ✓ %s\n", t.lang)
+		available = append(available, t)
+	}
+
+	start := time.Now()
+	results := make(map[string]quickResult)
+	failures := make(map[string]string)
+	for _, t := range available {
+		if err := ctx.Err(); err != nil {
+			failures[t.lang] = err.Error()
+			continue
+		}
+		fmt.Printf("Running %s...\n", t.lang)
+		dir := filepath.Join(outputDir, t.lang)
+		result, err := t.execute(ctx, s, schemaName, messageName, jsonData, dir, iterations)
+		if err != nil {
+			failures[t.lang] = err.Error()
+			continue
+		}
+		results[t.lang] = result
+	}
+
+	fmt.Printf("\n%s (%s, %d iterations)\n", schemaName, messageName, iterations)
+	fmt.Printf("%-8s %12s %12s %12s %10s\n", "Language", "Encode", "Decode", "Total", "Size")
+	fmt.Println(strings.Repeat("-", 58))
+	for _, t := range targets {
+		if r, ok := results[t.lang]; ok {
+			fmt.Printf("%-8s %9d ns %9d ns %9d ns %8d B\n", t.lang, r.EncodeNs, r.DecodeNs, r.TotalNs, r.WireSize)
+		} else if reason, ok := skipped[t.lang]; ok {
+			fmt.Printf("%-8s %s\n", t.lang, reason)
+		} else {
+			fmt.Printf("%-8s failed\n", t.lang)
+		}
+	}
+	fmt.Printf("\nCompleted in %s\n", time.Since(start).Round(100*time.Millisecond))
+	if len(skipped) > 0 || len(failures) > 0 {
+		fmt.Printf("Run 'ffire doctor' to check toolchain versions and see installation steps\n")
+	}
+	if keep {
+		fmt.Printf("Benchmarks kept in %s\n", outputDir)
+	}
+
+	if len(failures) > 0 {
+		fmt.Fprintln(os.Stderr)
+		for _, t := range targets {
+			if msg, ok := failures[t.lang]; ok {
+				fmt.Fprintf(os.Stderr, "✗ %s: %s\n", t.lang, msg)
+			}
+		}
+		if !keep {
+			os.RemoveAll(outputDir) // os.Exit skips the deferred cleanup
+		}
+		os.Exit(1)
+	}
+}
+
+// execute generates the benchmark into dir, builds it and parses the JSON
+// result of a single run, giving up after quickTimeout or when parent is
+// canceled.
+func (t quickTarget) execute(parent context.Context, s *schema.Schema, schemaName, messageName string, jsonData []byte, dir string, iterations int) (quickResult, error) {
+	ctx, cancel := context.WithTimeout(parent, quickTimeout)
+	defer cancel()
+
+	if err := t.generate(s, schemaName, messageName, jsonData, dir, iterations); err != nil {
+		return quickResult{}, fmt.Errorf("generate: %w", err)
+	}
+	workDir := filepath.Join(dir, t.subdir)
+
+	for _, args := range t.build {
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Dir = workDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if parent.Err() != nil {
+				return quickResult{}, parent.Err()
+			}
+			return quickResult{}, fmt.Errorf("build: %w\n%s", err, bytes.TrimSpace(output))
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, t.run[0], t.run[1:]...)
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), "BENCH_JSON=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if parent.Err() != nil {
+			return quickResult{}, parent.Err()
+		}
+		return quickResult{}, fmt.Errorf("run: %w\n%s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	// Some toolchains print build chatter around the JSON object
+	start := bytes.IndexByte(output, '{')
+	end := bytes.LastIndexByte(output, '}')
+	if start < 0 || end < start {
+		return quickResult{}, fmt.Errorf("no JSON result in output:\n%s", bytes.TrimSpace(output))
+	}
+	var result quickResult
+	if err := json.Unmarshal(output[start:end+1], &result); err != nil {
+		return quickResult{}, fmt.Errorf("parse result: %w", err)
+	}
+	return result, nil
+}
+
+func quickLangs() string {
+	var langs []string
+	for _, t := range quickTargets {
+		langs = append(langs, t.lang)
+	}
+	return strings.Join(langs, ", ")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/bindiff"
+)
+
+func runBindiff(args []string) {
+	fs := flag.NewFlagSet("bindiff", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	messageName := fs.String("message", "", "Message type name (auto-detected if only one root type)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire bindiff <a.bin> <b.bin> --schema <file> [options]
+
+Decode two payloads and report the fields whose values differ, e.g. when two
+languages disagree on the encoding of a fixture. Exits 0 if the payloads are
+equal, 1 if they differ and 2 if either cannot be decoded.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire bindiff go.bin swift.bin --schema schema.ffi
+  ffire bindiff expected.bin actual.bin --schema schema.ffi --message DeviceList
+`)
+	}
+
+	// Allow flags before, between and after the two file arguments
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(2)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if *schemaFile == "" || len(files) != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	schema, err := loadCanonicalSchema(*schemaFile, *checksum, *features)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(2)
+	}
+
+	// Auto-detect message name if not specified
+	if *messageName == "" {
+		if len(schema.Messages) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
+			for _, msg := range schema.Messages {
+				fmt.Fprintf(os.Stderr, "  - %s\n", msg.Name)
+			}
+			os.Exit(2)
+		}
+		*messageName = schema.Messages[0].Name
+	}
+
+	a, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading payload: %v\n", err)
+		os.Exit(2)
+	}
+	b, err := os.ReadFile(files[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading payload: %v\n", err)
+		os.Exit(2)
+	}
+
+	diffs, err := bindiff.Diff(schema, *messageName, a, b)
+	if err != nil {
+		if payloadErr, ok := err.(*bindiff.PayloadError); ok {
+			file := files[0]
+			if payloadErr.Payload == "b" {
+				file = files[1]
+			}
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", file, payloadErr.Err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(2)
+	}
+
+	if len(diffs) == 0 {
+		fmt.Printf("✓ %s and %s are equal (%s)\n", files[0], files[1], *messageName)
+		return
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", files[0], files[1])
+	for _, d := range diffs {
+		fmt.Printf("  %s\n", d)
+	}
+	fmt.Printf("✗ %d difference(s)\n", len(diffs))
+	os.Exit(1)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/canonical"
+)
+
+func runCanonicalize(args []string) {
+	fs := flag.NewFlagSet("canonicalize", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	input := fs.String("in", "", "Input payload (required)")
+	output := fs.String("out", "", "Output file (default: stdout)")
+	messageName := fs.String("message", "", "Message type name (auto-detected if only one root type)")
+	check := fs.Bool("check", false, "Only report whether the payload is canonical (exit 1 if not)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire canonicalize [options]
+
+Rewrite a payload into canonical form so it can be hashed or signed: -0.0
+becomes 0.0 and every NaN becomes the default quiet NaN. Everything else in
+the wire format is already deterministic.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire canonicalize --schema schema.ffi --in payload.bin | sha256sum
+  ffire canonicalize --schema schema.ffi --in payload.bin --out payload.canonical.bin
+  ffire canonicalize --schema schema.ffi --in payload.bin --check
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *schemaFile == "" || *input == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	schema, err := loadCanonicalSchema(*schemaFile, *checksum, *features)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	// Auto-detect message name if not specified
+	if *messageName == "" {
+		if len(schema.Messages) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
+			for _, msg := range schema.Messages {
+				fmt.Fprintf(os.Stderr, "  - %s\n", msg.Name)
+			}
+			os.Exit(1)
+		}
+		*messageName = schema.Messages[0].Name
+	}
+
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *check {
+		err := canonical.Check(schema, *messageName, data)
+		var canonErr *canonical.Error
+		switch {
+		case err == nil:
+			fmt.Printf("✓ %s is canonical\n", *input)
+		case errors.As(err, &canonErr):
+			fmt.Printf("✗ %s is not canonical: %v\n", *input, err)
+			os.Exit(1)
+		default:
+			fmt.Fprintf(os.Stderr, "Error decoding payload: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	out, err := canonical.Payload(schema, *messageName, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding payload: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*output, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote canonical payload to %s\n", *output)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/convert"
+)
+
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path to .ffi schema file (required)")
+	input := fs.String("in", "", "Input payload file (required)")
+	output := fs.String("out", "", "Output file (required)")
+	from := fs.String("from", convert.FormatFFire, "Input format: "+strings.Join(convert.Formats, ", "))
+	to := fs.String("to", "", "Output format: "+strings.Join(convert.Formats, ", ")+" (required)")
+	messageName := fs.String("message", "", "Message type name (auto-detected if only one root type)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	avroSchema := fs.String("avro-schema", "", "Also write the Avro schema (.avsc) to this path")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire convert [options]
+
+Convert payloads between the ffire wire format and other formats.
+
+Formats:
+  ffire     ffire binary wire format
+  json      JSON fixture (keyed by JSON field names)
+  msgpack   MessagePack (structs as maps keyed by JSON field names)
+  avro      Avro object container file with the schema embedded
+  arrow     Arrow IPC file / Feather v2 (array-of-struct messages only)
+  arrow-stream  Arrow IPC stream (array-of-struct messages only)
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire convert --schema schema.ffi --in data.bin --out data.avro --to avro
+  ffire convert --schema schema.ffi --in data.msgpack --out data.bin --from msgpack --to ffire
+  ffire convert --schema schema.ffi --in data.json --out data.msgpack --from json --to msgpack
+  ffire convert --schema schema.ffi --in devices.bin --out devices.arrow --to arrow
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *schemaFile == "" || *input == "" || *output == "" || *to == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Parse schema
+	schema, err := loadCanonicalSchema(*schemaFile, "", *features)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	// Auto-detect message name if not specified
+	if *messageName == "" {
+		if len(schema.Messages) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
+			for _, msg := range schema.Messages {
+				fmt.Fprintf(os.Stderr, "  - %s\n", msg.Name)
+			}
+			os.Exit(1)
+		}
+		*messageName = schema.Messages[0].Name
+	}
+
+	data, err := os.ReadFile(*input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err := convert.Convert(schema, *messageName, *from, *to, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(*output, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *avroSchema != "" {
+		avsc, err := convert.AvroSchema(schema, *messageName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := os.WriteFile(*avroSchema, append(avsc, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing Avro schema: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("✓ Converted %s (%s, %d bytes) to %s (%s, %d bytes)\n", *input, *from, len(data), *output, *to, len(out))
+}
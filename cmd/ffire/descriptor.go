@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/descriptor"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+func runDescriptor(args []string) {
+	fs := flag.NewFlagSet("descriptor", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	output := fs.String("out", "", "Output file (default: stdout)")
+	verify := fs.String("verify", "", "Check a peer's descriptor against the schema instead (exit 1 if the wire layouts differ)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire descriptor [options]
+
+Write the descriptor of a schema: the schema encoded as an ffire message,
+which processes exchange at runtime to check that they share a wire layout
+or to decode each other's messages without generated code. Packages
+generated with -descriptor embed it.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire descriptor --schema audio.ffi --out audio.ffd
+  ffire descriptor --schema audio.ffi --verify peer.ffd
+  ffire fixture --decode --descriptor peer.ffd --input message.bin
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *schemaFile == "" || (*verify != "" && *output != "") {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	schema, err := parseSchema(context.Background(), *schemaFile, *checksum)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+	applyFeatures(schema, *features)
+	if err := validator.ValidateSchema(schema); err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+
+	if *verify != "" {
+		peer, err := os.ReadFile(*verify)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading descriptor: %v\n", err)
+			os.Exit(1)
+		}
+		if err := descriptor.Verify(schema, peer); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", *verify, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ %s has the wire layout of %s\n", *verify, *schemaFile)
+		return
+	}
+
+	data, err := descriptor.Encode(schema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding descriptor: %v\n", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote descriptor of %s to %s (%d bytes)\n", *schemaFile, *output, len(data))
+}
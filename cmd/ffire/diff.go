@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/compat"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/semver"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	current := fs.String("current", "", "Version the old schema was released as (default: its @version)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	failOn := fs.String("fail-on", "major", "Exit with status 2 if changes need this release or a bigger one: major, minor, patch, or none")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire diff <old.ffi> <new.ffi> [options]
+
+Compare two versions of a schema and report each change with the semantic
+version bump it requires of generated packages:
+
+  major  breaks the wire format or generated APIs (fields added, removed or
+         retyped in an existing struct; types or message types removed)
+  minor  adds types or message types
+  patch  changes nothing generated code depends on (e.g. struct tags)
+
+The wire format has no field numbers, so even adding a field is a major
+change. The next version is suggested from the old schema's @version, and
+checked against the new schema's @version when it has one.
+
+Exit status is 0 when the schemas are compatible, 1 on errors, and 2 when
+the changes need a --fail-on release (default: major) or the new schema's
+@version is lower than the changes need, so the command can gate CI.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire diff v1/audio.ffi audio.ffi
+  ffire diff audio-1.4.ffi audio.ffi --current 1.4.2
+
+  # Fail CI on any change that needs a release, including additions
+  ffire diff main/audio.ffi audio.ffi --fail-on minor
+`)
+	}
+
+	// Allow flags before, between and after the two schema arguments
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(1)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	gate, err := parseFailOn(*failOn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	load := func(path string) *schema.Schema {
+		s, err := parseSchema(context.Background(), path, "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %s\n", path, formatError(err))
+			os.Exit(1)
+		}
+		applyFeatures(s, *features)
+		if err := validator.ValidateSchema(s); err != nil {
+			fmt.Fprintf(os.Stderr, "Error validating %s: %s\n", path, formatError(err))
+			os.Exit(1)
+		}
+		return s
+	}
+	oldSchema, newSchema := load(files[0]), load(files[1])
+
+	changes := compat.Diff(oldSchema, newSchema)
+	printDiff(files, changes)
+	if len(changes) == 0 {
+		return
+	}
+
+	level := compat.Required(changes)
+	fmt.Printf("\nRequired release: %s\n", level)
+
+	versionOK := checkDiffVersion(files, oldSchema, newSchema, changes, *current)
+	if gate != nil && level >= *gate {
+		fmt.Printf("✗ Changes need a %s release (--fail-on %s)\n", level, gate)
+		os.Exit(2)
+	}
+	if !versionOK {
+		os.Exit(2)
+	}
+}
+
+// parseFailOn returns the release level at which diff fails, or nil for
+// "none".
+func parseFailOn(value string) (*semver.Level, error) {
+	for _, level := range []semver.Level{semver.Patch, semver.Minor, semver.Major} {
+		if value == level.String() {
+			return &level, nil
+		}
+	}
+	if value == "none" {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("--fail-on %q: must be major, minor, patch or none", value)
+}
+
+// checkDiffVersion prints the version to release after the old schema's and
+// reports false if the new schema declares a lower @version.
+func checkDiffVersion(files []string, oldSchema, newSchema *schema.Schema, changes []compat.Change, current string) bool {
+	from := current
+	if from == "" {
+		from = oldSchema.Version
+	}
+	if from == "" {
+		fmt.Printf("  %s has no @version; pass --current to get a version suggestion\n", files[0])
+		return true
+	}
+	suggested, err := compat.Suggest(from, changes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Suggested version: %s → %s\n", from, suggested)
+
+	if newSchema.Version == "" {
+		fmt.Printf("  Add // @version(%q) above the package clause of %s\n", suggested, files[1])
+		return true
+	}
+	declared, _ := semver.Parse(newSchema.Version)
+	want, _ := semver.Parse(suggested)
+	if semver.Compare(declared, want) < 0 {
+		fmt.Printf("⚠ %s declares @version(%q), but these changes need %s or later\n", files[1], newSchema.Version, suggested)
+		return false
+	}
+	fmt.Printf("✓ %s declares @version(%q)\n", files[1], newSchema.Version)
+	return true
+}
+
+func printDiff(files []string, changes []compat.Change) {
+	fmt.Printf("Comparing %s → %s\n\n", files[0], files[1])
+	if len(changes) == 0 {
+		fmt.Println("  No changes to types or message types")
+		return
+	}
+	for _, c := range changes {
+		mark := "·"
+		switch c.Level {
+		case semver.Major:
+			mark = "✗"
+		case semver.Minor:
+			mark = "+"
+		}
+		fmt.Printf("  %s %-5s  %s\n", mark, c.Level, c)
+	}
+}
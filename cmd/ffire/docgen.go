@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/shaban/ffire/pkg/docgen"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+func runDocgen(args []string) {
+	fs := flag.NewFlagSet("docgen", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	output := fs.String("output", "", "Output directory (required)")
+	format := fs.String("format", "all", "Output format: html, markdown, all")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire docgen [options]
+
+Render documentation of a schema for teams consuming the format: messages
+and types with their fields in wire order, wire sizes and an example
+payload of each message. Writes index.html and README.md to the output
+directory.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire docgen --schema audio.ffi --output docs/
+  ffire docgen --schema audio.ffi --output docs/ --format markdown
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *schemaFile == "" || *output == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	type page struct {
+		file   string
+		render func(*schema.Schema) ([]byte, error)
+	}
+	var pages []page
+	switch *format {
+	case "html":
+		pages = []page{{"index.html", docgen.HTML}}
+	case "markdown":
+		pages = []page{{"README.md", docgen.Markdown}}
+	case "all":
+		pages = []page{{"index.html", docgen.HTML}, {"README.md", docgen.Markdown}}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q (supported: html, markdown, all)\n", *format)
+		os.Exit(1)
+	}
+
+	s, err := parseSchema(context.Background(), *schemaFile, *checksum)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+	applyFeatures(s, *features)
+	if err := validator.ValidateSchema(s); err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+	// List fields in wire order
+	s.Canonicalize()
+
+	if err := os.MkdirAll(*output, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
+		os.Exit(1)
+	}
+	for _, p := range pages {
+		out, err := p.render(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*output, p.file)
+		if err := os.WriteFile(path, out, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Documented %s in %s\n", *schemaFile, path)
+	}
+}
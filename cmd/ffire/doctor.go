@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/toolchain"
+)
+
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	langs := fs.String("lang", "", "Comma-separated target languages to check (default: all)")
+	jsonOut := fs.Bool("json", false, "Print results as JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire doctor [options]
+
+Check the compilers and runtimes used to build generated packages and
+benchmarks: whether they are installed, their versions, and known
+incompatibilities with the generated code.
+
+With -lang, exits with status 1 if a tool needed by those languages is
+missing or too old, so the check can gate CI jobs.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  # Check everything
+  ffire doctor
+
+  # Check what Swift and C# packages need
+  ffire doctor -lang swift,csharp
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	tools := toolchain.ForPlatform(toolchain.Tools, runtime.GOOS)
+	strict := *langs != ""
+	if strict {
+		var selected []string
+		for _, l := range strings.Split(*langs, ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				selected = append(selected, l)
+			}
+		}
+		tools = toolchain.ForLangs(tools, selected)
+		if len(tools) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no tools known for -lang %s\n", *langs)
+			os.Exit(1)
+		}
+	}
+
+	results := toolchain.Check(context.Background(), tools)
+
+	if *jsonOut {
+		printDoctorJSON(results)
+	} else {
+		printDoctor(results)
+	}
+
+	if strict {
+		for _, r := range results {
+			if r.Status != toolchain.OK {
+				os.Exit(1)
+			}
+		}
+	}
+}
+
+func printDoctor(results []toolchain.Result) {
+	fmt.Printf("Toolchain check (%s/%s)\n\n", runtime.GOOS, runtime.GOARCH)
+
+	problems := 0
+	for _, r := range results {
+		version := r.Version
+		if version == "" {
+			version = "-"
+		}
+
+		mark := "✓"
+		switch {
+		case r.Status == toolchain.OK:
+		case r.Tool.Optional:
+			mark = "-"
+		case r.Status == toolchain.Missing:
+			mark = "✗"
+			problems++
+		default:
+			mark = "⚠"
+			problems++
+		}
+
+		switch r.Status {
+		case toolchain.Missing:
+			fmt.Printf("  %s %-24s %-10s not found (%s)\n", mark, r.Tool.Name, "", r.Tool.Purpose)
+		default:
+			fmt.Printf("  %s %-24s %-10s %s\n", mark, r.Tool.Name, version, r.Path)
+		}
+		if r.Problem != "" {
+			fmt.Printf("      %s\n", r.Problem)
+		}
+		if r.Status != toolchain.OK {
+			if hint := r.InstallHint(); hint != "" {
+				fmt.Printf("      Install: %s\n", hint)
+			}
+		}
+	}
+
+	fmt.Println()
+	if problems == 0 {
+		fmt.Println("✓ All required toolchains are available")
+		return
+	}
+	fmt.Printf("⚠ %d toolchain problem(s). Languages that need them cannot be built or benchmarked.\n", problems)
+}
+
+// doctorJSON is the -json form of a toolchain.Result.
+type doctorJSON struct {
+	Tool       string   `json:"tool"`
+	Status     string   `json:"status"`
+	Version    string   `json:"version,omitempty"`
+	MinVersion string   `json:"min_version,omitempty"`
+	Path       string   `json:"path,omitempty"`
+	Problem    string   `json:"problem,omitempty"`
+	Install    string   `json:"install,omitempty"`
+	Langs      []string `json:"langs,omitempty"`
+	Optional   bool     `json:"optional,omitempty"`
+}
+
+func printDoctorJSON(results []toolchain.Result) {
+	out := make([]doctorJSON, len(results))
+	for i, r := range results {
+		out[i] = doctorJSON{
+			Tool:       r.Tool.Name,
+			Status:     r.Status.String(),
+			Version:    r.Version,
+			MinVersion: r.Tool.MinVersion,
+			Path:       r.Path,
+			Problem:    r.Problem,
+			Langs:      r.Tool.Langs,
+			Optional:   r.Tool.Optional,
+		}
+		if r.Status != toolchain.OK {
+			out[i].Install = r.InstallHint()
+		}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/editor"
+)
+
+func runSymbols(args []string) {
+	fs := flag.NewFlagSet("symbols", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path of .ffi schema file, or - to read stdin (required)")
+	jsonOut := fs.Bool("json", false, "Print the symbols as JSON, shaped like LSP document symbols")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire symbols [options]
+
+List the declarations of a schema in source order: types with their fields
+or variants, enums with their constants. Editors use -json for the outline
+and go-to-symbol; positions are 0-based lines and UTF-16 characters, as in
+LSP. The schema only has to parse, so unfinished schemas have an outline.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire symbols --schema audio.ffi
+  ffire symbols --schema - --json < audio.ffi
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *schemaFile == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var src []byte
+	var err error
+	if *schemaFile == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(*schemaFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading schema: %v\n", err)
+		os.Exit(1)
+	}
+
+	outline, err := editor.Symbols(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing schema: %v\n", err)
+		os.Exit(1)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(outline); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Printf("package %s\n", outline.Package)
+	printSymbols(outline.Symbols, 1)
+}
+
+// printSymbols prints an indented outline, with 1-based line numbers as in
+// error messages.
+func printSymbols(symbols []editor.Symbol, depth int) {
+	for _, sym := range symbols {
+		line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", depth), sym.Kind, sym.Name)
+		if sym.Detail != "" {
+			line += " " + sym.Detail
+		}
+		if sym.Message {
+			line += " (message)"
+		}
+		fmt.Printf("%s (line %d)\n", line, sym.Selection.Start.Line+1)
+		printSymbols(sym.Children, depth+1)
+	}
+}
+
+func runGrammar(args []string) {
+	fs := flag.NewFlagSet("grammar", flag.ExitOnError)
+	output := fs.String("out", "", "Output file (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire grammar [options]
+
+Write a TextMate grammar of the schema language (scope %s) as JSON, for
+editors to highlight .ffi files. VS Code extensions list it under
+contributes.grammars in package.json.
+
+Options:
+`, editor.ScopeName)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire grammar --out syntaxes/ffi.tmLanguage.json
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	data := editor.Grammar()
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing grammar: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote %s\n", *output)
+}
+
+// validateRequest is a line of validate -stdin input: the text of a
+// schema, with fields echoed back so the editor can match the reply to
+// the document and version it sent.
+type validateRequest struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	URI     string          `json:"uri,omitempty"`
+	Version *int            `json:"version,omitempty"`
+	Text    string          `json:"text"`
+}
+
+// validateReply is a line of validate -stdin output.
+type validateReply struct {
+	ID          json.RawMessage     `json:"id,omitempty"`
+	URI         string              `json:"uri,omitempty"`
+	Version     *int                `json:"version,omitempty"`
+	Diagnostics []editor.Diagnostic `json:"diagnostics"`
+	Error       string              `json:"error,omitempty"` // The request line was not valid JSON
+}
+
+// validateStdin answers validate requests read from r, one JSON object per
+// line, with one JSON line each on w, until r ends. The editor keeps one
+// process running and sends the text of a schema on every change; text a
+// URI already had is answered from the last result.
+func validateStdin(r io.Reader, w io.Writer) error {
+	type result struct {
+		text  string
+		diags []editor.Diagnostic
+	}
+	last := make(map[string]result) // By URI
+
+	in := bufio.NewScanner(r)
+	in.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	out := json.NewEncoder(w)
+	for in.Scan() {
+		line := in.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var req validateRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := out.Encode(validateReply{Diagnostics: []editor.Diagnostic{}, Error: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		prev, ok := last[req.URI]
+		diags := prev.diags
+		if !ok || prev.text != req.Text {
+			diags = editor.Diagnose([]byte(req.Text))
+			if diags == nil {
+				diags = []editor.Diagnostic{}
+			}
+			last[req.URI] = result{req.Text, diags}
+		}
+		reply := validateReply{ID: req.ID, URI: req.URI, Version: req.Version, Diagnostics: diags}
+		if err := out.Encode(reply); err != nil {
+			return err
+		}
+	}
+	return in.Err()
+}
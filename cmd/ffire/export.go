@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/convert"
+	"github.com/shaban/ffire/pkg/export"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	format := fs.String("format", "", "Output format: sql, graphql, openapi, proto, csv (required)")
+	output := fs.String("out", "", "Output file (default: stdout)")
+	dialect := fs.String("dialect", "postgres", "SQL dialect: postgres, mysql, sqlite")
+	typeMap := fs.String("type-map", "", "Type mapping overrides, e.g. string=VARCHAR(255),int64=NUMERIC(20) (sql) or int64=String (graphql)")
+	goPackage := fs.String("go-package", "", "go_package option of proto output")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	input := fs.String("in", "", "Payload file to export (csv)")
+	from := fs.String("from", convert.FormatFFire, "Payload format: "+strings.Join(convert.Formats, ", ")+" (csv)")
+	messageName := fs.String("message", "", "Message type name (csv; auto-detected if only one root type)")
+	flatten := fs.Int("flatten", -1, "Levels of nested structs and fixed-size arrays spread over columns, -1 for all (csv)")
+	separator := fs.String("flatten-sep", ".", "Separator joining flattened column names (csv)")
+	bom := fs.Bool("bom", false, "Start with a UTF-8 byte order mark, for Excel (csv)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire export [options]
+
+Render a schema in another schema language.
+
+Formats:
+  sql       CREATE TABLE statements, one table per struct type
+            (type-map keys: primitive names, json for array columns, id for surrogate keys)
+  graphql   GraphQL SDL object types, one per struct type
+            (type-map values are scalar names; int64 defaults to a custom Int64 scalar)
+  openapi   OpenAPI 3.0 components: JSON schemas per struct, plus request bodies
+            and responses per message offering application/x-ffire and JSON
+  proto     A proto3 file, one message per struct type plus a wrapper message
+            for each message type whose root is an array or primitive
+            (type-map values are proto scalar types, e.g. int64=sfixed64)
+  csv       The rows of a payload (--in) of an array-of-struct message, or the
+            single row of a struct message; nested structs are flattened into
+            "field.child" columns, slices, maps and unions are JSON cells
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire export --schema audio.ffi --format sql
+  ffire export --schema audio.ffi --format sql --dialect mysql --type-map string=VARCHAR(255) --out schema.sql
+  ffire export --schema audio.ffi --format graphql --type-map int64=String --out schema.graphql
+  ffire export --schema audio.ffi --format openapi --out components.json
+  ffire export --schema audio.ffi --format proto --go-package example.com/audio/pb --out audio.proto
+  ffire export --schema audio.ffi --format csv --in devices.bin --out devices.csv --bom
+  ffire export --schema audio.ffi --format csv --in devices.json --from json --flatten 1 --flatten-sep _
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *schemaFile == "" || *format == "" || (*format == "csv" && *input == "") {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	// Parse schema
+	schema, err := parseSchema(context.Background(), *schemaFile, *checksum)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+
+	// Strip fields guarded by feature flags that are not enabled
+	applyFeatures(schema, *features)
+
+	// Validate schema
+	if err := validator.ValidateSchema(schema); err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+
+	mapping, err := export.ParseTypeMap(*typeMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	opts := export.Options{
+		TypeMap: mapping, Dialect: *dialect, GoPackage: *goPackage,
+		Flatten: *flatten, Separator: *separator, BOM: *bom,
+	}
+
+	var out []byte
+	switch *format {
+	case "sql":
+		out, err = export.SQL(schema, opts)
+	case "graphql":
+		out, err = export.GraphQL(schema, opts)
+	case "openapi":
+		out, err = export.OpenAPI(schema, opts)
+	case "proto":
+		out, err = export.Proto(schema, opts)
+	case "csv":
+		out, err = exportCSV(schema, *input, *from, *messageName, opts)
+	default:
+		err = fmt.Errorf("unknown format %q (supported: sql, graphql, openapi, proto, csv)", *format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(*output, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+	if *format == "csv" {
+		fmt.Printf("✓ Exported %s to %s\n", *input, *output)
+		return
+	}
+	fmt.Printf("✓ Exported %s schema to %s\n", *format, *output)
+}
+
+// exportCSV decodes a payload and renders its rows as CSV.
+func exportCSV(s *schema.Schema, input, from, messageName string, opts export.Options) ([]byte, error) {
+	if messageName == "" {
+		if len(s.Messages) != 1 {
+			names := make([]string, len(s.Messages))
+			for i, msg := range s.Messages {
+				names[i] = msg.Name
+			}
+			return nil, fmt.Errorf("multiple root types found, please specify --message: %s", strings.Join(names, ", "))
+		}
+		messageName = s.Messages[0].Name
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+
+	// Payloads use canonical field order; columns keep declaration order
+	restore := s.SaveFieldOrder()
+	s.Canonicalize()
+	value, err := convert.Decode(s, messageName, from, data)
+	restore()
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", from, err)
+	}
+	return export.CSV(s, messageName, value, opts)
+}
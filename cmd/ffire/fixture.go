@@ -1,26 +1,36 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 
+	"github.com/shaban/ffire/pkg/descriptor"
+	"github.com/shaban/ffire/pkg/dynamic"
 	"github.com/shaban/ffire/pkg/fixture"
 	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
 	"github.com/shaban/ffire/pkg/validator"
 )
 
 func runFixture(args []string) {
 	fs := flag.NewFlagSet("fixture", flag.ExitOnError)
 	schemaFile := fs.String("schema", "", "Path to .ffi schema file (required)")
-	jsonFile := fs.String("json", "", "Path to JSON fixture file (required)")
-	outputFile := fs.String("output", "", "Path to output binary file (required)")
+	jsonFile := fs.String("json", "", "Path to JSON fixture file (required unless --decode)")
+	outputFile := fs.String("output", "", "Path to output binary file (required); with --decode, the JSON file (default: stdout)")
 	messageName := fs.String("message", "", "Message type name to encode (auto-detected if only one root type)")
+	decode := fs.Bool("decode", false, "Convert a binary payload (--input) back to pretty-printed JSON")
+	input := fs.String("input", "", "Path to binary wire file to decode (with --decode)")
+	path := fs.String("path", "", "With --decode, print only the value at this field path, e.g. Devices[2].Name")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	descriptorFile := fs.String("descriptor", "", "Path to a schema descriptor, used instead of --schema (see ffire descriptor)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: ffire fixture [options]
 
-Convert JSON fixture to binary wire format.
+Convert JSON fixture to binary wire format, or with --decode a binary
+payload back to JSON.
 
 Options:
 `)
@@ -29,6 +39,10 @@ Options:
 Examples:
   ffire fixture --schema schema.ffi --json data.json --output data.bin
   ffire fixture --schema schema.ffi --json data.json --output data.bin --message DeviceList
+  ffire fixture --decode --schema schema.ffi --input data.bin
+  ffire fixture --decode --schema schema.ffi --input data.bin --output data.json --message DeviceList
+  ffire fixture --decode --schema schema.ffi --input data.bin --path '[0].Name'
+  ffire fixture --decode --descriptor peer.ffd --input data.bin
 `)
 	}
 
@@ -37,21 +51,23 @@ Examples:
 	}
 
 	// Validate required flags
-	if *schemaFile == "" || *jsonFile == "" || *outputFile == "" {
+	if (*schemaFile == "") == (*descriptorFile == "") || (*descriptorFile != "" && *features != "") {
 		fs.Usage()
 		os.Exit(1)
 	}
-
-	// Parse schema
-	schema, err := parser.Parse(*schemaFile)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing schema: %s\n", formatError(err))
+	if *decode {
+		if *input == "" || *jsonFile != "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+	} else if *jsonFile == "" || *outputFile == "" || *input != "" || *path != "" {
+		fs.Usage()
 		os.Exit(1)
 	}
 
-	// Validate schema
-	if err := validator.ValidateSchema(schema); err != nil {
-		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
+	schema, err := loadFixtureSchema(*schemaFile, *descriptorFile, *features)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
 
@@ -63,7 +79,12 @@ Examples:
 		}
 		if len(schema.Messages) == 1 {
 			*messageName = schema.Messages[0].Name
-			fmt.Printf("Auto-detected root type: %s\n", *messageName)
+			// Keep stdout clean when it carries the decoded JSON
+			note := os.Stdout
+			if *decode {
+				note = os.Stderr
+			}
+			fmt.Fprintf(note, "Auto-detected root type: %s\n", *messageName)
 		} else {
 			fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
 			for _, msg := range schema.Messages {
@@ -73,6 +94,11 @@ Examples:
 		}
 	}
 
+	if *decode {
+		decodeFixture(schema, *messageName, *input, *outputFile, *path)
+		return
+	}
+
 	// Read JSON file
 	jsonData, err := os.ReadFile(*jsonFile)
 	if err != nil {
@@ -101,3 +127,82 @@ Examples:
 
 	fmt.Printf("✓ Converted %s to %s (%d bytes)\n", *jsonFile, *outputFile, len(binary))
 }
+
+// loadFixtureSchema parses, validates and canonicalizes schemaFile, with
+// the fields of features compiled in, or decodes descriptorFile, which is
+// validated, canonical and has the enabled features recorded.
+func loadFixtureSchema(schemaFile, descriptorFile, features string) (*schema.Schema, error) {
+	if descriptorFile != "" {
+		data, err := os.ReadFile(descriptorFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading descriptor: %w", err)
+		}
+		return descriptor.Decode(data)
+	}
+
+	s, err := parser.Parse(schemaFile)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema: %s", formatError(err))
+	}
+
+	// Strip fields guarded by feature flags that are not enabled
+	applyFeatures(s, features)
+
+	if err := validator.ValidateSchema(s); err != nil {
+		return nil, fmt.Errorf("validating schema: %s", formatError(err))
+	}
+
+	// Payloads go to and come from generated code, which uses canonical
+	// field order
+	s.Canonicalize()
+	return s, nil
+}
+
+// decodePayload decodes a payload of messageName, or the part of it at
+// path if path is set. s is canonical, as loadFixtureSchema returns it.
+func decodePayload(s *schema.Schema, messageName string, data []byte, path string) (interface{}, error) {
+	msg, err := dynamic.Lookup(s, messageName)
+	if err != nil {
+		return nil, err
+	}
+	value, err := msg.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return msg.Get(value, path)
+}
+
+// decodeFixture converts the binary payload in inputFile, or the part of
+// it at path if path is set, to pretty-printed JSON, written to outputFile
+// or to stdout if outputFile is empty.
+func decodeFixture(s *schema.Schema, messageName, inputFile, outputFile, path string) {
+	data, err := os.ReadFile(inputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading binary file: %v\n", err)
+		os.Exit(1)
+	}
+
+	value, err := decodePayload(s, messageName, data, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding %s: %v\n", inputFile, err)
+		os.Exit(1)
+	}
+
+	out, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+		os.Exit(1)
+	}
+	out = append(out, '\n')
+
+	if outputFile == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(outputFile, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Decoded %s to %s (%d bytes)\n", inputFile, outputFile, len(data))
+}
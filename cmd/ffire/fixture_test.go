@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// fixtureDecodeSchema declares fields out of canonical order, so a payload
+// only decodes when the schema is canonicalized like the generated code.
+const fixtureDecodeSchema = `package studio
+
+type Device struct {
+	Name  string
+	Tags  []string
+	Gain  *float32
+	ID    int64
+	Level int16
+}
+`
+
+// TestDecodePayloadGeneratedGo decodes a payload written by the generated
+// Go encoder, as fixture --decode does.
+func TestDecodePayloadGeneratedGo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	s, err := parser.ParseBytes([]byte(fixtureDecodeSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := generator.GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":           "module decode\n\ngo 1.21\n",
+		"studio/studio.go": string(code),
+		"main.go": `package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"decode/studio"
+)
+
+func main() {
+	gain := float32(0.5)
+	data := studio.DeviceMessage{Name: "mic", Tags: []string{"a", "b"}, Gain: &gain, ID: 42, Level: -3}.Encode()
+	fmt.Print(hex.EncodeToString(data))
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	data, err := hex.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("bad encoder output %q: %v", out, err)
+	}
+
+	// The schema as fixture --decode loads it
+	schemaFile := filepath.Join(tmpDir, "studio.ffi")
+	if err := os.WriteFile(schemaFile, []byte(fixtureDecodeSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err = loadFixtureSchema(schemaFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := decodePayload(s, "Device", data, "")
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	got, _ := json.Marshal(value)
+	want := `{"Gain":0.5,"ID":42,"Level":-3,"Name":"mic","Tags":["a","b"]}`
+	if string(got) != want {
+		t.Errorf("decoded %s, want %s", got, want)
+	}
+
+	name, err := decodePayload(s, "Device", data, "Name")
+	if err != nil || name != "mic" {
+		t.Errorf("Name = %v, %v", name, err)
+	}
+}
+
+// TestFixtureRoundtrip encodes a JSON fixture with ffire fixture and
+// decodes the payload back with ffire fixture --decode.
+func TestFixtureRoundtrip(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"studio.ffi":  fixtureDecodeSchema,
+		"device.json": `{"Name": "mic", "Tags": ["a", "b"], "Gain": 0.5, "ID": 42, "Level": -3}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schemaFile := filepath.Join(dir, "studio.ffi")
+	runFixture([]string{"-schema", schemaFile, "-json", filepath.Join(dir, "device.json"), "-output", filepath.Join(dir, "device.bin")})
+	runFixture([]string{"-decode", "-schema", schemaFile, "-input", filepath.Join(dir, "device.bin"), "-output", filepath.Join(dir, "decoded.json")})
+
+	out, err := os.ReadFile(filepath.Join(dir, "decoded.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("decoded %s: %v", out, err)
+	}
+	data, _ := json.Marshal(got)
+	if want := `{"Gain":0.5,"ID":42,"Level":-3,"Name":"mic","Tags":["a","b"]}`; string(data) != want {
+		t.Errorf("decoded %s, want %s", data, want)
+	}
+}
@@ -1,26 +1,77 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/shaban/ffire/pkg/config"
 	"github.com/shaban/ffire/pkg/generator"
-	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/remote"
+	"github.com/shaban/ffire/pkg/schema"
 	"github.com/shaban/ffire/pkg/validator"
 )
 
 func runGenerate(args []string) {
 	fs := flag.NewFlagSet("generate", flag.ExitOnError)
-	schemaFile := fs.String("schema", "", "Path to .ffi schema file (required)")
-	lang := fs.String("lang", "", "Target language: go, cpp, js, python, swift, dart, java, csharp (required)")
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	lang := fs.String("lang", "", "Target language: go, cpp, js, python, swift, dart, java, kotlin, csharp, rust, zig, godot, matlab, or all (required)")
 	output := fs.String("out", "./dist", "Output directory for generated package")
 	optimize := fs.Int("O", 2, "Optimization level (0-3)")
 	platform := fs.String("platform", "current", "Target platform: darwin, linux, windows, all")
 	arch := fs.String("arch", "current", "Target architecture: arm64, x86_64, all")
 	namespace := fs.String("ns", "", "Namespace/package name (defaults to schema name)")
+	packageVersion := fs.String("package-version", "", "Version written to package manifests (default: the schema's @version, then 1.0.0)")
+	goModule := fs.String("go-module", "", "For Go: also write go.mod with this module path and a doc.go, e.g. example.com/acme/audio")
+	verifyLint := fs.Bool("verify-lint", false, "For Go: run go vet, and staticcheck and gofumpt when installed, on the output and fail on findings")
 	noCompile := fs.Bool("no-compile", false, "Skip dylib compilation (for testing)")
+	cc := fs.String("cc", "", "C compiler for igniffi libraries (default: $CC, then gcc)")
+	cxx := fs.String("cxx", "", "C++ compiler for native libraries (default: $CXX, then clang++/g++ for the platform)")
+	cflags := fs.String("cflags", "", "Extra C compiler flags (default: $CFLAGS)")
+	cxxflags := fs.String("cxxflags", "", "Extra C++ compiler flags, e.g. \"--sysroot=/opt/sysroot\" (default: $CXXFLAGS)")
+	ldflags := fs.String("ldflags", "", "Extra linker flags (default: $LDFLAGS)")
+	sanitize := fs.String("sanitize", "", "Build the native library with sanitizers: address, undefined, thread, leak (comma-separated); with -with-tests, C++ tests run under them")
+	cppSIMD := fs.Bool("cpp-simd", false, "For packages built on the C++ core (cpp, swift, dart, csharp, zig, godot): reject invalid UTF-8 and bools when decoding, using SSE2/NEON where available")
+	cppEmbedded := fs.Bool("cpp-embedded", false, "For cpp: generate a header for microcontrollers with no heap, exceptions or RTTI, sized by the schema's @max limits")
+	cppArduino := fs.Bool("cpp-arduino", false, "For cpp: package the -cpp-embedded header as an Arduino library, which PlatformIO also installs")
+	layout := fs.String("layout", generator.LayoutDefault, "Output layout: default, or monorepo (<out>/<lang>/<schema>/ plus <out>/ffire-manifest.json)")
+	buildRules := fs.String("build-rules", "", "Also emit build rules declaring the package as a library: bazel (go, cpp, swift, java) or buck (go, cpp, java)")
+	noFormat := fs.Bool("no-format", false, "Skip gofmt and native formatters (clang-format, swift-format, rustfmt, ...) on the output")
 	verbose := fs.Bool("v", false, "Verbose output")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	only := fs.String("only", "", "Comma-separated message types to generate codecs for (default: all)")
+	sharedRuntime := fs.String("shared-runtime", "", "For Go: import path of a runtime written by ffire runtime, imported instead of defining the runtime helpers in the package")
+	borrowedDecode := fs.Bool("borrowed-decode", false, "For Go: also generate DecodeBorrowed methods, whose strings and byte slices point into the input instead of copying it")
+	kotlinOkio := fs.Bool("kotlin-okio", false, "For Kotlin: also generate okio extensions that encode to sinks and decode from sources and ByteStrings, and depend on okio")
+	swiftCodable := fs.Bool("swift-codable", false, "For Swift: also make the generated types Codable, reading and writing the JSON of ffire fixtures")
+	javaFFM := fs.Bool("java-ffm", false, "For Java: also generate encode and decode methods for MemorySegments of the Foreign Function & Memory API, building for Java 22")
+	csharpUnity := fs.Bool("csharp-unity", false, "For C#: generate code Unity 2021.3+ compiles under Mono and IL2CPP, as a Unity package and a .unitypackage")
+	pythonPure := fs.Bool("python-pure", false, "For Python: generate dataclasses encoded with the struct module and a .pyi stub, instead of CFFI bindings that need a compiler")
+	keepAll := fs.Bool("keep-all", false, "Generate every declared type, including types no message reaches")
+	profile := fs.String("profile", "", "Write a CPU profile of the generation step to this file and print per-phase timings")
+	configFile := fs.String("config", "", "Path to ffire.yaml (default: ./ffire.yaml, then ffire.yaml next to the schema)")
+	noHooks := fs.Bool("no-hooks", false, "Do not run pre/post generation hooks from ffire.yaml")
+	withTests := fs.Bool("with-tests", false, "Also emit roundtrip unit tests in the language's test framework (go, cpp, swift, java, python)")
+	fixtureFile := fs.String("fixture", "", "JSON fixture to add as a roundtrip test case (with -with-tests)")
+	handshake := fs.Bool("handshake", false, "Also emit a handshake message and negotiation helpers so hosts can reject plugins built from a different schema (go, cpp, swift)")
+	descriptor := fs.Bool("descriptor", false, "Also embed the schema as a descriptor that peers decode at runtime to check or read this package's messages (go, cpp, swift)")
+	messageName := fs.String("message", "", "Message type of the -fixture data (defaults to the only message type)")
+	license := fs.String("license", "", "License of the package: MIT, Apache-2.0 or proprietary. Writes LICENSE and fills the license field of the manifest")
+	copyright := fs.String("copyright", "", "Copyright holder named in LICENSE (default: The <namespace> Authors)")
+	sbom := fs.Bool("sbom", false, "Write a CycloneDX SBOM (bom.cdx.json) listing the package's runtime dependencies and native libraries")
+	provenance := fs.Bool("provenance", false, "Write an SLSA provenance statement (provenance.intoto.json) with the digest of every file in the package")
+	sign := fs.String("sign", "", "Sign native libraries, archives and the provenance statement: cosign or minisign")
+	signKey := fs.String("sign-key", "", "Private key for -sign (default: keyless for cosign, ~/.minisign/minisign.key for minisign)")
+	plugin := fs.String("plugin", "", "Generator plugin to run instead of a built-in generator; it reads the schema as JSON on stdin and writes the package files as JSON to stdout (-lang defaults to its name after ffire-gen-)")
+	timeout := fs.Duration("timeout", 0, "Cancel generation after this long, e.g. 10m, stopping any compiler or hook it runs (default: no limit)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: ffire generate [options]
@@ -35,6 +86,13 @@ Examples:
   # Generate Python package
   ffire generate -lang python -schema audio.ffi -out ./dist
   
+  # Generate the targets listed under generate in ffire.yaml
+  ffire generate
+  ffire generate -lang go
+
+  # Generate every language into generated/go, generated/swift, ...
+  ffire generate -lang all -schema audio.ffi -out generated -no-compile
+
   # Generate pure JavaScript package (no native bindings)
   ffire generate -lang js -schema audio.ffi -out ./dist
   
@@ -43,6 +101,107 @@ Examples:
   
   # Multi-platform build
   ffire generate -lang python -schema audio.ffi -platform all
+
+  # Release a package version other than the schema's @version
+  ffire generate -lang js -schema audio.ffi -package-version 2.1.0-rc.1
+
+  # Generate a standalone Go module, ready to push and tag
+  ffire generate -lang go -schema audio.ffi -out ./audio -go-module example.com/acme/audio
+
+  # Fail CI if the generated code has go vet, staticcheck or gofumpt findings
+  ffire generate -lang go -schema audio.ffi -with-tests -verify-lint
+
+  # Compile in fields marked @feature("v2")
+  ffire generate -lang go -schema audio.ffi -features v2
+
+  # Generate codecs only for the messages this app uses
+  ffire generate -lang cpp -schema shared.ffi -only PluginList,Device
+
+  # Also emit the types those messages do not use
+  ffire generate -lang cpp -schema shared.ffi -only PluginList -keep-all
+
+  # Share one runtime between the packages of several schemas
+  ffire runtime -lang go -out gen/ffirert
+  ffire generate -lang go -schema audio.ffi -out gen/audio -shared-runtime example.com/app/gen/ffirert
+
+  # Add zero-copy DecodeBorrowed methods next to the copying Decode
+  ffire generate -lang go -schema audio.ffi -borrowed-decode
+
+  # Kotlin Multiplatform package for Android and iOS, with okio sinks and sources
+  ffire generate -lang kotlin -schema audio.ffi -kotlin-okio
+
+  # Swift types that are also Codable, for JSON and property lists
+  ffire generate -lang swift -schema audio.ffi -swift-codable
+
+  # Java that encodes to and decodes from native memory with java.lang.foreign
+  ffire generate -lang java -schema audio.ffi -java-ffm
+
+  # C# for Unity game clients, as a Unity package and a .unitypackage
+  ffire generate -lang csharp -schema audio.ffi -csharp-unity
+
+  # Python package that pip installs without a compiler
+  ffire generate -lang python -schema audio.ffi -python-pure
+
+  # MATLAB and Octave functions that decode captures into matrices
+  ffire generate -lang matlab -schema audio.ffi
+
+  # Profile generation of a large schema
+  ffire generate -lang go -schema big.ffi -profile cpu.pprof
+  go tool pprof -top cpu.pprof
+
+  # Keep generated sources exactly as emitted (faster for huge schemas)
+  ffire generate -lang go -schema big.ffi -no-format
+
+  # Build the native library with a musl cross toolchain
+  ffire generate -lang dart -schema audio.ffi -cxx x86_64-linux-musl-g++ -ldflags "-static-libstdc++"
+
+  # Validate UTF-8 strings and bools when decoding, vectorized with SSE2/NEON
+  ffire generate -lang cpp -schema audio.ffi -cpp-simd
+
+  # Header for ESP32/STM32 firmware: no heap, exceptions or RTTI
+  ffire generate -lang cpp -schema telemetry.ffi -cpp-embedded
+
+  # The same header as an Arduino library with an example sketch
+  ffire generate -lang cpp -schema telemetry.ffi -cpp-arduino
+
+  # Build an ASan/UBSan library and run the C++ roundtrip tests under it
+  ffire generate -lang cpp -schema audio.ffi -sanitize address,undefined -with-tests
+
+  # Emit go test roundtrip tests, including a case from a JSON fixture
+  ffire generate -lang go -schema audio.ffi -with-tests -fixture plugins.json
+  go test ./dist
+
+  # Let a host reject plugins built from a different schema or feature set
+  ffire generate -lang cpp -schema audio.ffi -features v2 -handshake
+
+  # Generate several packages into one monorepo tree (gen/go/audio, gen/js/audio, ...)
+  ffire generate -lang go -schema audio.ffi -out gen -layout monorepo
+  ffire generate -lang js -schema audio.ffi -out gen -layout monorepo
+
+  # Emit a BUILD.bazel with a go_library target next to the code
+  ffire generate -lang go -schema audio.ffi -out gen -layout monorepo -build-rules bazel
+
+  # Run hooks from a specific config file (e.g. gofmt the output)
+  ffire generate -lang go -schema audio.ffi -config ci/ffire.yaml
+
+  # Give up if native compilation and hooks take longer than 10 minutes
+  ffire generate -lang python -schema audio.ffi -timeout 10m
+
+  # Generate from a schema registry, pinned to a checksum
+  ffire generate -lang go -schema https://registry.example.com/audio/v3 -checksum sha256:9f86d0...
+
+  # Release the package under the Apache License with a LICENSE and NOTICE
+  ffire generate -lang rust -schema audio.ffi -license Apache-2.0 -copyright "Acme Corp"
+
+  # List the package's third-party runtime dependencies for security review
+  ffire generate -lang js -schema audio.ffi -sbom
+
+  # Attest the package and sign its native library and the attestation
+  ffire generate -lang python -schema audio.ffi -provenance -sign cosign -sign-key cosign.key
+
+  # Generate Lua with a plugin, given by path or found on the PATH as ffire-gen-lua
+  ffire generate -plugin ./bin/ffire-gen-lua -schema audio.ffi
+  ffire generate -lang lua -schema audio.ffi
 `)
 	}
 
@@ -50,39 +209,417 @@ Examples:
 		os.Exit(1)
 	}
 
+	// Without -schema, run the targets of ffire.yaml
+	if *schemaFile == "" {
+		runs, err := configTargets(fs, *configFile, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+		if len(runs) > 0 {
+			for _, run := range runs {
+				runGenerate(run)
+			}
+			return
+		}
+	}
+
+	if *lang == "" && *plugin != "" {
+		name := filepath.Base(*plugin)
+		*lang = strings.TrimPrefix(strings.TrimSuffix(name, filepath.Ext(name)), generator.PluginPrefix)
+	}
 	if *schemaFile == "" || *lang == "" {
 		fs.Usage()
 		os.Exit(1)
 	}
+	if *lang == generator.LanguageAll && *plugin != "" {
+		fmt.Fprintln(os.Stderr, "Error: -plugin generates one language and cannot be combined with -lang all")
+		os.Exit(1)
+	}
+	start := time.Now()
+	if *fixtureFile != "" && !*withTests {
+		fmt.Fprintln(os.Stderr, "Error: -fixture requires -with-tests")
+		os.Exit(1)
+	}
+	sanitizers, err := generator.ParseSanitizers(*sanitize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -sanitize: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(*configFile, *schemaFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	var hooks generator.Hooks
+	var coordinates generator.Coordinates
+	if cfg != nil {
+		if !*noHooks {
+			hooks = cfg.Hooks
+		}
+		coordinates = cfg.Packages
+	}
+
+	var prof *generateProfile
+	if *profile != "" {
+		var err error
+		if prof, err = startGenerateProfile(*profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error starting profile: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Ctrl-C and -timeout stop child processes instead of orphaning them
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, *timeout, fmt.Errorf("timed out after %s", *timeout))
+		defer cancel()
+	}
 
 	// Parse schema
-	schema, err := parser.Parse(*schemaFile)
+	schema, err := parseSchema(ctx, *schemaFile, *checksum)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing schema: %v\n", formatError(err))
 		os.Exit(1)
 	}
 
+	prof.phase("parse")
+
+	// Strip fields guarded by feature flags that are not enabled
+	applyFeatures(schema, *features)
+
+	// Drop message types the consumer does not need
+	if err := selectMessages(schema, *only); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -only: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Validate schema
 	if err := validator.ValidateSchema(schema); err != nil {
 		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
 		os.Exit(1)
 	}
 
+	fixtures, err := loadTestFixtures(schema, *fixtureFile, *messageName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fixture: %s\n", formatError(err))
+		os.Exit(1)
+	}
+	var digest string
+	if *provenance {
+		if digest, err = schemaDigest(ctx, *schemaFile, *checksum); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading schema for provenance: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	prof.phase("validate")
+
+	level := generator.LevelInfo
+	if *verbose {
+		level = generator.LevelDebug
+	}
+
 	// Generate package
 	config := &generator.PackageConfig{
-		Schema:    schema,
-		Language:  *lang,
-		OutputDir: *output,
-		Optimize:  *optimize,
-		Platform:  *platform,
-		Arch:      *arch,
-		Namespace: *namespace,
-		NoCompile: *noCompile,
-		Verbose:   *verbose,
-	}
-
-	if err := generator.GeneratePackage(config); err != nil {
+		Schema:         schema,
+		Language:       *lang,
+		OutputDir:      *output,
+		Optimize:       *optimize,
+		Platform:       *platform,
+		Arch:           *arch,
+		Namespace:      *namespace,
+		Version:        *packageVersion,
+		GoModule:       *goModule,
+		NoCompile:      *noCompile,
+		CC:             *cc,
+		CXX:            *cxx,
+		CFlags:         *cflags,
+		CXXFlags:       *cxxflags,
+		LDFlags:        *ldflags,
+		Sanitize:       sanitizers,
+		CppSIMD:        *cppSIMD,
+		CppEmbedded:    *cppEmbedded,
+		CppArduino:     *cppArduino,
+		NoFormat:       *noFormat,
+		Hooks:          hooks,
+		Layout:         *layout,
+		KeepAllTypes:   *keepAll,
+		SharedRuntime:  *sharedRuntime,
+		BorrowedDecode: *borrowedDecode,
+		KotlinOkio:     *kotlinOkio,
+		SwiftCodable:   *swiftCodable,
+		JavaFFM:        *javaFFM,
+		CSharpUnity:    *csharpUnity,
+		PythonPure:     *pythonPure,
+		BuildRules:     *buildRules,
+		VerifyLint:     *verifyLint,
+		Coordinates:    coordinates,
+		WithTests:      *withTests,
+		Fixtures:       fixtures,
+		Handshake:      *handshake,
+		Descriptor:     *descriptor,
+		Plugin:         *plugin,
+		License:        *license,
+		Copyright:      *copyright,
+		SBOM:           *sbom,
+		Provenance:     *provenance,
+		Sign:           *sign,
+		SignKey:        *signKey,
+		SchemaSource:   *schemaFile,
+		SchemaDigest:   digest,
+		Logger:         generator.NewLogger(os.Stdout, os.Stderr, level),
+	}
+
+	if *lang == generator.LanguageAll {
+		results := generator.GenerateAll(ctx, config)
+		failed := printSummary(results, len(generator.AllLanguages))
+		recordUsage(*schemaFile, config, start, failed)
+		if err := prof.stop(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing profile: %v\n", err)
+			os.Exit(1)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = generator.GeneratePackageContext(ctx, config)
+	recordUsage(*schemaFile, config, start, err != nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = context.Cause(ctx)
+		}
 		fmt.Fprintf(os.Stderr, "Error generating package: %s\n", formatError(err))
 		os.Exit(1)
 	}
+	prof.phase("generate")
+
+	if err := prof.stop(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing profile: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printSummary prints the outcome of each language of -lang all and
+// reports whether any failed, or was not reached before a timeout or
+// interrupt.
+func printSummary(results []generator.LanguageResult, total int) bool {
+	failures := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failures++
+		}
+	}
+	fmt.Printf("\nGenerated %d of %d languages:\n", len(results)-failures, total)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("  ✗ %-7s %s\n", r.Language, formatError(r.Err))
+			continue
+		}
+		fmt.Printf("  ✓ %-7s %-28s %s\n", r.Language, r.Dir, r.Duration.Round(time.Millisecond))
+	}
+	for _, lang := range generator.AllLanguages[len(results):] {
+		fmt.Printf("  - %-7s not generated\n", lang)
+	}
+	return failures > 0 || len(results) < total
+}
+
+// configTargets returns the flags of each run the generate targets of the
+// config file describe, one per schema and language, or nil if there are
+// none. The command line's args come after each target's flags, so they
+// override the target's settings; a -lang among them picks the targets
+// listing that language instead, whose options may be specific to it.
+func configTargets(fs *flag.FlagSet, path string, args []string) ([][]string, error) {
+	cfg, err := loadConfig(path, "")
+	if err != nil || cfg == nil || len(cfg.Generate) == 0 {
+		return nil, err
+	}
+	only := ""
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "lang" {
+			only = f.Value.String()
+		}
+	})
+
+	var runs [][]string
+	for i, t := range cfg.Generate {
+		run := []string{"-config", cfg.Path, "-schema", t.Schema}
+		if t.Out != "" {
+			run = append(run, "-out", t.Out)
+		}
+		if t.Namespace != "" {
+			run = append(run, "-ns", t.Namespace)
+		}
+		names := make([]string, 0, len(t.Options))
+		for name := range t.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fs.Lookup(name) == nil {
+				return nil, fmt.Errorf("%s: generate[%d].options: unknown option %q", cfg.Path, i, name)
+			}
+			value, err := optionValue(t.Options[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: generate[%d].options.%s: %w", cfg.Path, i, name, err)
+			}
+			run = append(run, "-"+name+"="+value)
+		}
+
+		for _, lang := range t.Lang {
+			if lang != "" && (only == "" || lang == only) {
+				targetRun := append(append([]string(nil), run...), "-lang", lang)
+				runs = append(runs, append(targetRun, args...))
+			}
+		}
+	}
+	if len(runs) == 0 {
+		if only == "" {
+			return nil, fmt.Errorf("%s: no generate targets declare a lang", cfg.Path)
+		}
+		return nil, fmt.Errorf("%s: no generate target has lang %s", cfg.Path, only)
+	}
+	return runs, nil
+}
+
+// optionValue renders a YAML option as a flag value; lists become
+// comma-separated, as -features and -sanitize take them.
+func optionValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := optionValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}, nil:
+		return "", fmt.Errorf("expected a value or list, got %v", v)
+	}
+	return fmt.Sprint(v), nil
+}
+
+// loadConfig loads the config file given with -config or, failing that,
+// the ffire.yaml in the current directory or next to a local schema. It
+// returns nil if there is no config file.
+func loadConfig(path, schemaFile string) (*config.Config, error) {
+	if path == "" {
+		dirs := []string{"."}
+		if !remote.IsURL(schemaFile) {
+			dirs = append(dirs, filepath.Dir(schemaFile))
+		}
+		found, ok := config.Find(dirs...)
+		if !ok {
+			return nil, nil
+		}
+		path = found
+	}
+	return config.Load(path)
+}
+
+// loadTestFixtures reads and validates the -fixture file for the roundtrip
+// tests. The message type may be omitted when the schema has only one.
+func loadTestFixtures(s *schema.Schema, path, messageName string) (map[string][]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if messageName == "" {
+		if len(s.Messages) != 1 {
+			return nil, fmt.Errorf("schema has %d message types, use -message to pick the fixture's type", len(s.Messages))
+		}
+		messageName = s.Messages[0].Name
+	}
+
+	jsonData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateJSON(s, messageName, jsonData); err != nil {
+		return nil, err
+	}
+	return map[string][]byte{messageName: jsonData}, nil
+}
+
+// generateProfile records a CPU profile and wall-clock time per phase of
+// ffire generate. A nil *generateProfile is valid and records nothing.
+type generateProfile struct {
+	file   *os.File
+	start  time.Time
+	last   time.Time
+	phases []generatePhase
+}
+
+type generatePhase struct {
+	name     string
+	duration time.Duration
+}
+
+func startGenerateProfile(path string) (*generateProfile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	now := time.Now()
+	return &generateProfile{file: f, start: now, last: now}, nil
+}
+
+// phase ends the current phase under the given name.
+func (p *generateProfile) phase(name string) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	p.phases = append(p.phases, generatePhase{name: name, duration: now.Sub(p.last)})
+	p.last = now
+}
+
+// stop finishes the CPU profile and prints the phase timings to stderr.
+func (p *generateProfile) stop() error {
+	if p == nil {
+		return nil
+	}
+	pprof.StopCPUProfile()
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+
+	total := time.Since(p.start)
+	fmt.Fprintf(os.Stderr, "\nGeneration profile (%s):\n", p.file.Name())
+	for _, ph := range p.phases {
+		fmt.Fprintf(os.Stderr, "  %-10s %10s %5.1f%%\n", ph.name, ph.duration.Round(time.Microsecond), 100*float64(ph.duration)/float64(total))
+	}
+	fmt.Fprintf(os.Stderr, "  %-10s %10s\n", "total", total.Round(time.Microsecond))
+	return nil
+}
+
+// schemaDigest returns the remote.Checksum of the schema at location, for
+// the provenance statement. A pinned remote schema is not fetched again.
+func schemaDigest(ctx context.Context, location, checksum string) (string, error) {
+	if !remote.IsURL(location) {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return "", err
+		}
+		return remote.Checksum(data), nil
+	}
+	if checksum != "" {
+		// Already verified by parseSchema; pins may omit the prefix
+		return "sha256:" + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(checksum)), "sha256:"), nil
+	}
+	data, err := remote.NewFetcher().FetchContext(ctx, location, "")
+	if err != nil {
+		return "", err
+	}
+	return remote.Checksum(data), nil
 }
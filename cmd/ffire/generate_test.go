@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const generateTargetsConfig = `generate:
+  - schema: audio.ffi
+    lang: [go, swift]
+    out: gen
+    options:
+      features: [v2]
+  - schema: audio.ffi
+    lang: python
+    out: gen
+`
+
+// generateFlags declares the generate flags the targets above use.
+func generateFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	for _, name := range []string{"config", "schema", "lang", "out", "ns", "features"} {
+		fs.String(name, "", "")
+	}
+	return fs
+}
+
+// TestConfigTargetsCommandLineOverrides checks that flags on the command
+// line override the settings and options of every generate target.
+func TestConfigTargetsCommandLineOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ffire.yaml")
+	if err := os.WriteFile(path, []byte(generateTargetsConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	targets := func(args ...string) ([][]string, error) {
+		fs := generateFlags()
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return configTargets(fs, path, args)
+	}
+
+	runs, err := targets("-out", "/tmp/override", "-features", "v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var langs []string
+	for _, run := range runs {
+		fs := generateFlags()
+		if err := fs.Parse(run); err != nil {
+			t.Fatalf("run %q: %v", run, err)
+		}
+		if out := fs.Lookup("out").Value.String(); out != "/tmp/override" {
+			t.Errorf("run %q: out = %s", run, out)
+		}
+		if features := fs.Lookup("features").Value.String(); features != "v3" {
+			t.Errorf("run %q: features = %s", run, features)
+		}
+		langs = append(langs, fs.Lookup("lang").Value.String())
+	}
+	if got := strings.Join(langs, " "); got != "go swift python" {
+		t.Errorf("langs = %s", got)
+	}
+
+	runs, err = targets("-lang", "swift")
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("-lang swift: %q, %v", runs, err)
+	}
+	if _, err := targets("-lang", "rust"); err == nil || !strings.Contains(err.Error(), "no generate target has lang rust") {
+		t.Errorf("-lang rust: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("generate:\n  - schema: audio.ffi\n    lang: \"\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := targets(); err == nil || !strings.Contains(err.Error(), "no generate targets declare a lang") {
+		t.Errorf("empty lang: %v", err)
+	}
+}
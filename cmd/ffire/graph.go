@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/results"
+)
+
+func runGraph(args []string) {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	message := fs.String("message", "", "Only chart this message (default: average across all messages)")
+	metrics := fs.String("metric", "encode,decode,total", "Comma-separated charts to draw: encode, decode, total")
+	width := fs.Int("width", 50, "Length of the longest bar")
+	color := fs.String("color", "auto", "Color the bars: auto (when writing to a terminal), always or never")
+	machine := fs.String("machine", "", "Only chart results from this host, for results merged from several machines")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire graph [options] <results>...
+
+Draw bar charts of encode, decode and total time from benchmark results.
+Each <results> is a results file or a directory of them, such as the
+results/ directory of the benchmark suite. The JSON printed by a single
+benchmark run with BENCH_JSON=1 works too.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  # Average across every message in the suite's results
+  ffire graph benchmarks/results
+
+  # One message, decode time only
+  ffire graph benchmarks/results -message complex -metric decode
+
+  # Your own runs
+  BENCH_JSON=1 ./bench > go.json
+  ffire graph go.json cpp.json -color never
+`)
+	}
+
+	// Allow flags before, between and after the result paths
+	var paths []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			os.Exit(1)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := results.GraphOptions{Message: *message, Width: *width}
+	for _, name := range strings.Split(*metrics, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, m := range results.Metrics {
+			if strings.EqualFold(m.Name, name) {
+				opts.Metrics = append(opts.Metrics, m)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "Error: unknown -metric %q (want encode, decode or total)\n", name)
+			os.Exit(1)
+		}
+	}
+	switch *color {
+	case "always":
+		opts.Color = true
+	case "auto":
+		info, err := os.Stdout.Stat()
+		opts.Color = err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+	case "never":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown -color %q (want auto, always or never)\n", *color)
+		os.Exit(1)
+	}
+
+	rs, err := results.Load(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading results: %v\n", err)
+		os.Exit(1)
+	}
+	if rs, err = oneMachine(rs, *machine); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(rs) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no results in %s\n", strings.Join(paths, ", "))
+		os.Exit(1)
+	}
+	if err := results.Graph(os.Stdout, rs, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// oneMachine returns the results of the host named machine, or all of rs
+// if they come from a single machine. Averaging timings from different
+// hardware means nothing, so results from several machines are an error
+// unless one is picked.
+func oneMachine(rs []results.Result, machine string) ([]results.Result, error) {
+	if machine != "" {
+		var filtered []results.Result
+		for _, r := range rs {
+			if r.Machine != nil && r.Machine.Host == machine {
+				filtered = append(filtered, r)
+			}
+		}
+		if len(filtered) == 0 {
+			return nil, fmt.Errorf("no results from machine %q", machine)
+		}
+		return filtered, nil
+	}
+
+	var hosts []string
+	for _, m := range results.Machines(rs) {
+		if m != nil {
+			hosts = append(hosts, m.Host)
+		}
+	}
+	if len(hosts) > 1 {
+		return nil, fmt.Errorf("results are from %d machines (%s); pick one with -machine, or compare them with ffire bench aggregate",
+			len(hosts), strings.Join(hosts, ", "))
+	}
+	return rs, nil
+}
@@ -25,6 +25,7 @@ Examples:
   ffire inspect --schema audio.ffi --binary output.bin
   ffire inspect --schema audio.ffi --binary output.bin --message AudioData
   ffire inspect --schema audio.ffi --binary output.bin --hex
+  ffire inspect --schema audio.ffi --binary output.bin --diag > report.diag
 `)
 	}
 
@@ -33,6 +34,8 @@ Examples:
 	messageName := fs.String("message", "Message", "Message type name")
 	showHex := fs.Bool("hex", false, "Show hex dump")
 	compact := fs.Bool("compact", false, "Compact output (no field annotations)")
+	diag := fs.Bool("diag", false, "Print the decoded message in CBOR diagnostic notation (exact float widths, NaN payloads, raw bytes)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
 
 	if err := fs.Parse(args); err != nil {
 		os.Exit(1)
@@ -51,6 +54,9 @@ Examples:
 		os.Exit(1)
 	}
 
+	// Strip fields guarded by feature flags that are not enabled
+	applyFeatures(schema, *features)
+
 	// Validate schema
 	if err := validator.ValidateSchema(schema); err != nil {
 		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
@@ -64,6 +70,19 @@ Examples:
 		os.Exit(1)
 	}
 
+	if *diag {
+		// Bug reports carry payloads from generated code, which uses the
+		// canonical field order
+		schema.Canonicalize()
+		output, err := inspector.Diagnostic(schema, *messageName, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error decoding binary: %s\n", formatError(err))
+			os.Exit(1)
+		}
+		fmt.Print(output)
+		return
+	}
+
 	// Inspect binary data
 	config := &inspector.Config{
 		Schema:      schema,
@@ -2,11 +2,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime/debug"
+	"strings"
 
 	"github.com/shaban/ffire/pkg/errors"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/remote"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/validator"
 )
 
 func main() {
@@ -45,6 +51,42 @@ func main() {
 		runBench(os.Args[2:])
 	case "inspect":
 		runInspect(os.Args[2:])
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	case "convert":
+		runConvert(os.Args[2:])
+	case "verify-corpus":
+		runVerifyCorpus(os.Args[2:])
+	case "bindiff":
+		runBindiff(os.Args[2:])
+	case "canonicalize":
+		runCanonicalize(os.Args[2:])
+	case "store":
+		runStore(os.Args[2:])
+	case "synth":
+		runSynth(os.Args[2:])
+	case "doctor":
+		runDoctor(os.Args[2:])
+	case "diff":
+		runDiff(os.Args[2:])
+	case "ui":
+		runUI(os.Args[2:])
+	case "graph":
+		runGraph(os.Args[2:])
+	case "stats":
+		runStats(os.Args[2:])
+	case "runtime":
+		runRuntime(os.Args[2:])
+	case "descriptor":
+		runDescriptor(os.Args[2:])
+	case "docgen":
+		runDocgen(os.Args[2:])
+	case "symbols":
+		runSymbols(os.Args[2:])
+	case "grammar":
+		runGrammar(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -68,6 +110,78 @@ func formatError(err error) string {
 	return err.Error()
 }
 
+// parseSchema parses a schema from a local path or an https:// URL. Remote
+// schemas are verified against checksum ("sha256:<hex>") when one is given.
+func parseSchema(ctx context.Context, location, checksum string) (*schema.Schema, error) {
+	if !remote.IsURL(location) {
+		if checksum != "" {
+			fmt.Fprintf(os.Stderr, "Warning: -checksum only applies to remote schemas, ignoring\n")
+		}
+		return parser.Parse(location)
+	}
+
+	src, err := remote.NewFetcher().FetchContext(ctx, location, checksum)
+	if err != nil {
+		return nil, err
+	}
+	if checksum == "" {
+		fmt.Fprintf(os.Stderr, "Warning: remote schema is not pinned, add -checksum %s\n", remote.Checksum(src))
+	}
+	return parser.ParseBytes(src)
+}
+
+// loadCanonicalSchema parses the schema at location like parseSchema,
+// strips the fields of features that are not enabled, validates it and
+// canonicalizes it. Payloads come from generated code, which uses
+// canonical field order, so commands reading payloads load schemas with it.
+func loadCanonicalSchema(location, checksum, features string) (*schema.Schema, error) {
+	s, err := parseSchema(context.Background(), location, checksum)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %s", location, formatError(err))
+	}
+	applyFeatures(s, features)
+	if err := validator.ValidateSchema(s); err != nil {
+		return nil, fmt.Errorf("validating schema %s: %s", location, formatError(err))
+	}
+	s.Canonicalize()
+	return s, nil
+}
+
+// applyFeatures strips fields guarded by feature flags not listed in the
+// comma-separated features value (e.g. "v2,beta").
+func applyFeatures(s *schema.Schema, features string) {
+	var enabled []string
+	for _, f := range strings.Split(features, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			enabled = append(enabled, f)
+		}
+	}
+
+	known := make(map[string]bool)
+	for _, f := range s.Features() {
+		known[f] = true
+	}
+	for _, f := range enabled {
+		if !known[f] {
+			fmt.Fprintf(os.Stderr, "Warning: feature %q is not used by schema %s\n", f, s.Package)
+		}
+	}
+
+	s.ApplyFeatures(enabled)
+}
+
+// selectMessages drops message types not listed in the comma-separated only
+// value (e.g. "PluginList,Device"). An empty value keeps every message.
+func selectMessages(s *schema.Schema, only string) error {
+	var names []string
+	for _, name := range strings.Split(only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return s.SelectMessages(names)
+}
+
 func printUsage() {
 	fmt.Println(`ffire - FFI Encoding code generator and tooling
 
@@ -75,11 +189,29 @@ Usage:
   ffire <command> [options]
 
 Commands:
-  fixture     Convert JSON fixture to binary wire format
+  fixture     Convert JSON fixture to binary wire format, or back with --decode
   validate    Validate schema and fixture files
   generate    Generate encoder/decoder code (Go, C++, Swift)
   bench       Generate benchmark executables
   inspect     Inspect and visualize binary wire format
+  migrate     Convert binary payloads between schema versions
+  export      Export schema as SQL DDL, GraphQL SDL or OpenAPI, or payloads as CSV
+  convert     Convert payloads between ffire, JSON, MessagePack, Avro and Arrow
+  verify-corpus  Decode every payload in a directory and report failures
+  bindiff     Compare two binary payloads field by field
+  canonicalize  Normalize floats in a payload for hashing or signing
+  store       Content-addressable payload store (put/get by BLAKE3 hash)
+  synth       Generate a synthetic schema for stress testing the generator
+  doctor      Check installed compilers and runtimes for each target language
+  diff        Compare two schema versions and suggest the next package version
+  ui          Browse schema types, fixtures and benchmark results in the terminal
+  graph       Draw bar charts of benchmark results
+  stats       Summarize the local, opt-in history of generate runs
+  runtime     Write the runtime shared by packages generated with -shared-runtime
+  descriptor  Write a schema as a descriptor for runtime exchange, or check a peer's
+  docgen      Render HTML and Markdown documentation of a schema
+  symbols     List the declarations of a schema, as JSON for editors
+  grammar     Write a TextMate grammar for highlighting .ffi files
 
 Examples:
   ffire fixture --schema testdata/schema/complex.ffi --json testdata/json/complex.json --output out.bin
@@ -87,6 +219,24 @@ Examples:
   ffire generate --schema testdata/schema/complex.ffi --lang go --output generated/
   ffire bench --schema testdata/schema/complex.ffi --output bench/
   ffire inspect --schema testdata/schema/complex.ffi --binary out.bin
+  ffire migrate --from v1.ffi --to v2.ffi --mapping map.json --in archive/ --out archive-v2/
+  ffire export --schema testdata/schema/complex.ffi --format sql --dialect sqlite
+  ffire convert --schema testdata/schema/complex.ffi --in out.bin --out out.avro --to avro
+  ffire verify-corpus --schema testdata/schema/complex.ffi --dir payloads/
+  ffire bindiff go.bin swift.bin --schema testdata/schema/complex.ffi
+  ffire canonicalize --schema testdata/schema/complex.ffi --in payload.bin --check
+  ffire store put --dir snapshots/ --schema testdata/schema/complex.ffi payload.bin
+  ffire synth --types 500 --out big.ffi
+  ffire doctor --lang swift,csharp
+  ffire diff v1/audio.ffi audio.ffi
+  ffire ui --schema testdata/schema/complex.ffi --results benchmarks/results
+  ffire graph benchmarks/results --message complex
+  ffire stats --usage
+  ffire runtime --lang go --out gen/ffirert
+  ffire descriptor --schema testdata/schema/complex.ffi --out complex.ffd
+  ffire docgen --schema testdata/schema/complex.ffi --output docs/
+  ffire symbols --schema testdata/schema/complex.ffi --json
+  ffire grammar --out ffi.tmLanguage.json
 
 Use "ffire <command> --help" for more information about a command.`)
 }
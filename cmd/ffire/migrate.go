@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/migrate"
+)
+
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fromFile := fs.String("from", "", "Path to old .ffi schema file (required)")
+	toFile := fs.String("to", "", "Path to new .ffi schema file (required)")
+	mappingFile := fs.String("mapping", "", "Path to JSON mapping file (renamed fields, defaults for new fields)")
+	input := fs.String("in", "", "Input .bin file or directory of .bin files (required)")
+	output := fs.String("out", "", "Output file or directory (required)")
+	messageName := fs.String("message", "", "Root type name in the old schema (auto-detected if only one root type)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire migrate [options]
+
+Convert stored binary payloads from an old schema version to a new one.
+Payloads are expected in the canonical wire layout produced by generated code.
+
+Mapping file format (JSON, all keys optional):
+  {
+    "message":  "Config",
+    "renames":  { "Device": { "OldField": "NewField" } },
+    "defaults": { "Device": { "AddedField": "value" } }
+  }
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire migrate --from v1.ffi --to v2.ffi --in payload.bin --out payload.v2.bin
+  ffire migrate --from v1.ffi --to v2.ffi --mapping v1-to-v2.json --in archive/ --out archive-v2/
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *fromFile == "" || *toFile == "" || *input == "" || *output == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	oldSchema, err := loadCanonicalSchema(*fromFile, "", "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+	newSchema, err := loadCanonicalSchema(*toFile, "", "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	// Auto-detect message name if not specified
+	if *messageName == "" {
+		if len(oldSchema.Messages) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
+			for _, msg := range oldSchema.Messages {
+				fmt.Fprintf(os.Stderr, "  - %s\n", msg.Name)
+			}
+			os.Exit(1)
+		}
+		*messageName = oldSchema.Messages[0].Name
+	}
+
+	var mapping *migrate.Mapping
+	if *mappingFile != "" {
+		mapping, err = migrate.LoadMapping(*mappingFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading mapping: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	migrator, err := migrate.New(oldSchema, newSchema, *messageName, mapping)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	info, err := os.Stat(*input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Single file
+	if !info.IsDir() {
+		if err := migrator.MigrateFile(*input, *output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error migrating %s: %v\n", *input, err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Migrated %s to %s\n", *input, *output)
+		return
+	}
+
+	// Directory batch
+	results, err := migrator.MigrateDir(*input, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", r.Path, r.Err)
+		}
+	}
+
+	fmt.Printf("✓ Migrated %d of %d payloads from %s to %s\n", len(results)-failed, len(results), *input, *output)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
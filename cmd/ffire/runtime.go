@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/generator"
+)
+
+func runRuntime(args []string) {
+	fs := flag.NewFlagSet("runtime", flag.ExitOnError)
+	lang := fs.String("lang", "", "Target language: go (required)")
+	output := fs.String("out", "", "Output directory for the runtime package (required)")
+	pkg := fs.String("package", generator.DefaultRuntimePackage, "Package name of the runtime")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire runtime [options]
+
+Write the runtime shared by packages generated with -shared-runtime. When
+several schemas are generated into one program, their packages then import
+one copy of the runtime helpers instead of each defining its own.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire runtime -lang go -out gen/ffirert
+  ffire generate -lang go -schema audio.ffi -out gen/audio -shared-runtime example.com/app/gen/ffirert
+  ffire generate -lang go -schema video.ffi -out gen/video -shared-runtime example.com/app/gen/ffirert
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *lang == "" || *output == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := generator.GenerateSharedRuntime(*lang, *output, *pkg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote %s runtime to %s\n", *lang, *output)
+}
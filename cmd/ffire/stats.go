@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/remote"
+	"github.com/shaban/ffire/pkg/usage"
+)
+
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	usageReport := fs.Bool("usage", false, "Summarize the local history of ffire generate runs")
+	file := fs.String("file", "", "History file to read (default: usage.jsonl in the user cache directory)")
+	jsonOut := fs.Bool("json", false, "Print the report as JSON")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire stats -usage [options]
+
+Report how ffire is used on this machine: generate runs per language and
+schema, how long they took, and schema and output sizes.
+
+Runs are only recorded when %s=1 is set, and the history never
+leaves the machine. It is a JSON Lines file that can be copied from CI
+runners and read with -file.
+
+Options:
+`, usage.EnvVar)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  # Opt in to recording
+  export %s=1
+
+  # Summarize the runs recorded so far
+  ffire stats -usage
+
+  # Summarize a history collected from a CI runner, as JSON
+  ffire stats -usage -file ci-usage.jsonl -json
+`, usage.EnvVar)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if !*usageReport {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	path := *file
+	if path == "" {
+		var err error
+		if path, err = usage.DefaultPath(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	events, skipped, err := usage.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %d malformed lines in %s\n", skipped, path)
+	}
+	if len(events) == 0 && *file == "" && !usage.Enabled() {
+		fmt.Fprintf(os.Stderr, "Recording is off; set %s=1 to record generate runs\n", usage.EnvVar)
+	}
+
+	report := usage.Summarize(events)
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	report.Write(os.Stdout)
+}
+
+// recordUsage appends a generate run to the usage history if the user
+// opted in. Failing to record never fails the run.
+func recordUsage(schemaFile string, config *generator.PackageConfig, start time.Time, failed bool) {
+	if !usage.Enabled() {
+		return
+	}
+	path, err := usage.DefaultPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: usage stats: %v\n", err)
+		return
+	}
+	e := usage.Event{
+		Time:       start,
+		Schema:     config.Schema.Package,
+		Language:   config.Language,
+		Types:      len(config.Schema.Types),
+		Messages:   len(config.Schema.Messages),
+		DurationMs: time.Since(start).Milliseconds(),
+		Failed:     failed,
+	}
+	if !failed {
+		e.OutputBytes = dirSize(config.OutputDir)
+	}
+	if !remote.IsURL(schemaFile) {
+		if info, err := os.Stat(schemaFile); err == nil {
+			e.SchemaBytes = info.Size()
+		}
+	}
+	if err := usage.Record(path, e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: usage stats: %v\n", err)
+	}
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/store"
+)
+
+func runStore(args []string) {
+	if len(args) == 0 {
+		printStoreUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "put":
+		runStorePut(args[1:])
+	case "get":
+		runStoreGet(args[1:])
+	case "help", "-h", "--help":
+		printStoreUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown store command: %s\n\n", args[0])
+		printStoreUsage()
+		os.Exit(1)
+	}
+}
+
+func printStoreUsage() {
+	fmt.Fprintf(os.Stderr, `Usage: ffire store <put|get> [options]
+
+Content-addressable message store. Payloads are keyed by their BLAKE3 hash
+and stored once, with the schema fingerprint they were written with.
+
+Commands:
+  put    Validate payloads and add them to the store, printing their hashes
+  get    Read a payload back by hash
+
+Examples:
+  ffire store put --dir snapshots/ --schema schema.ffi state.bin
+  ffire store get --dir snapshots/ --schema schema.ffi --out state.bin <hash>
+`)
+}
+
+func runStorePut(args []string) {
+	fs := flag.NewFlagSet("store put", flag.ExitOnError)
+	dir := fs.String("dir", "", "Store directory (required)")
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	messageName := fs.String("message", "", "Message type name (auto-detected if only one root type)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire store put [options] <payload>...
+
+Options:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *dir == "" || *schemaFile == "" || fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	schema := loadStoreSchema(*schemaFile, *checksum, *features)
+	*messageName = storeMessage(schema, *messageName)
+
+	st, err := store.Open(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, file := range fs.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", file, err)
+			failed = true
+			continue
+		}
+		h, err := st.Put(schema, *messageName, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", file, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s  %s\n", h, file)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runStoreGet(args []string) {
+	fs := flag.NewFlagSet("store get", flag.ExitOnError)
+	dir := fs.String("dir", "", "Store directory (required)")
+	output := fs.String("out", "", "Output file (default: stdout)")
+	schemaFile := fs.String("schema", "", "Schema to check the object's fingerprint against (optional)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire store get [options] <hash>
+
+Options:
+`)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *dir == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	h, err := store.ParseHash(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	st, err := store.Open(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, meta, err := st.Get(h)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Refuse to hand out payloads the caller's decoder would misread
+	if *schemaFile != "" {
+		schema := loadStoreSchema(*schemaFile, *checksum, *features)
+		fingerprint, err := store.Fingerprint(schema, meta.Message)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: object is a %s message: %v\n", meta.Message, err)
+			os.Exit(1)
+		}
+		if fingerprint != meta.Fingerprint {
+			fmt.Fprintf(os.Stderr, "Error: object was written with a different %s layout (fingerprint %s, schema has %s)\n",
+				meta.Message, meta.Fingerprint, fingerprint)
+			os.Exit(1)
+		}
+	}
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote %s (%s.%s, %d bytes) to %s\n", h, meta.Package, meta.Message, meta.Size, *output)
+}
+
+func loadStoreSchema(location, checksum, features string) *schema.Schema {
+	s, err := loadCanonicalSchema(location, checksum, features)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+	return s
+}
+
+// storeMessage returns name, or the only root type if name is empty.
+func storeMessage(s *schema.Schema, name string) string {
+	if name != "" {
+		return name
+	}
+	if len(s.Messages) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
+		for _, msg := range s.Messages {
+			fmt.Fprintf(os.Stderr, "  - %s\n", msg.Name)
+		}
+		os.Exit(1)
+	}
+	return s.Messages[0].Name
+}
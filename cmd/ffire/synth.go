@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/synth"
+)
+
+func runSynth(args []string) {
+	fs := flag.NewFlagSet("synth", flag.ExitOnError)
+	types := fs.Int("types", 100, "Number of struct types")
+	fields := fs.Int("fields", 10, "Primitive fields per struct")
+	seed := fs.Int64("seed", 1, "Seed for field type selection")
+	pkg := fs.String("package", "synth", "Schema package name")
+	output := fs.String("out", "", "Output file (default: stdout)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire synth [options]
+
+Generate a synthetic schema for stress testing and profiling the generator.
+Types form a tree with a single root type (Struct0), and the same options
+always produce the same schema.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire synth --types 500 --out big.ffi
+  ffire generate --lang go --schema big.ffi --profile cpu.pprof
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *types <= 0 || *fields <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: --types and --fields must be positive\n")
+		os.Exit(1)
+	}
+
+	src := synth.Schema(synth.Options{Package: *pkg, Types: *types, Fields: *fields, Seed: *seed})
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Wrote %d types to %s\n", *types, *output)
+}
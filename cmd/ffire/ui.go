@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/results"
+	"github.com/shaban/ffire/pkg/tui"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+func runUI(args []string) {
+	fs := flag.NewFlagSet("ui", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path or https:// URL of .ffi schema file (required)")
+	checksum := fs.String("checksum", "", "Expected checksum of a remote schema (sha256:<hex>)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	fixtureFile := fs.String("fixture", "", "JSON fixture to preview as JSON and as annotated wire bytes")
+	messageName := fs.String("message", "", "Message type of the -fixture data (defaults to the only message type)")
+	resultsPaths := fs.String("results", "", "Comma-separated benchmark result files or directories, e.g. benchmarks/results")
+	machine := fs.String("machine", "", "Only show results from this host, for results merged from several machines")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire ui [options]
+
+Browse a schema interactively in the terminal. Four views:
+
+  1 Types    messages and structs, with fields in wire order and sizes
+  2 Fixture  a JSON fixture, and the annotated wire bytes it encodes to
+  3 Results  benchmark results as a comparison table, per message
+  4 Graph    the same results as encode, decode and total time bar charts
+
+Keys: ↑/↓ or j/k select, ←/→ or tab switch view, 1-4 jump to a view,
+PgUp/PgDn or space scroll, q or Esc quit.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  # Explore a schema's types
+  ffire ui -schema audio.ffi
+
+  # Check how a fixture encodes
+  ffire ui -schema audio.ffi -fixture plugins.json
+
+  # Compare benchmark runs
+  ffire ui -schema testdata/schema/complex.ffi -results benchmarks/results
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *schemaFile == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	schema, err := parseSchema(context.Background(), *schemaFile, *checksum)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing schema: %v\n", formatError(err))
+		os.Exit(1)
+	}
+	applyFeatures(schema, *features)
+	if err := validator.ValidateSchema(schema); err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
+		os.Exit(1)
+	}
+
+	cfg := tui.Config{Schema: schema, FixtureName: *fixtureFile}
+	fixtures, err := loadTestFixtures(schema, *fixtureFile, *messageName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading fixture: %s\n", formatError(err))
+		os.Exit(1)
+	}
+	for name, data := range fixtures {
+		cfg.Message, cfg.Fixture = name, data
+	}
+
+	if *resultsPaths != "" {
+		var paths []string
+		for _, p := range strings.Split(*resultsPaths, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		rs, err := results.Load(paths...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading results: %v\n", err)
+			os.Exit(1)
+		}
+		if cfg.Results, err = oneMachine(rs, *machine); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Show fields in the order the generated code puts them on the wire
+	schema.Canonicalize()
+
+	if err := tui.Run(tui.New(cfg), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
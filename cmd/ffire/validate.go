@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/shaban/ffire/pkg/analyzer"
 	"github.com/shaban/ffire/pkg/parser"
+	ffschema "github.com/shaban/ffire/pkg/schema"
 	"github.com/shaban/ffire/pkg/validator"
 )
 
@@ -14,6 +16,8 @@ func runValidate(args []string) {
 	schemaFile := fs.String("schema", "", "Path to .ffi schema file (required)")
 	jsonFile := fs.String("json", "", "Path to JSON fixture file (optional)")
 	messageName := fs.String("message", "Message", "Message type name (default: Message)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	stdin := fs.Bool("stdin", false, "Validate schema texts read from stdin, one JSON request per line, and answer each with a JSON line of diagnostics (for editors)")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage: ffire validate [options]
@@ -28,6 +32,13 @@ Examples:
   ffire validate --schema schema.ffi
   ffire validate --schema schema.ffi --json data.json
   ffire validate --schema schema.ffi --json data.json --message DeviceList
+  ffire validate --stdin
+
+With --stdin, each input line is {"uri": ..., "version": ..., "text": ...}
+(id, uri and version are optional and echoed back) and each output line is
+{"uri": ..., "version": ..., "diagnostics": [...]}. Diagnostics have an LSP
+range (0-based lines, UTF-16 characters), severity, code, message and hint;
+all feature flags are compiled in. The process runs until stdin closes.
 `)
 	}
 
@@ -35,6 +46,14 @@ Examples:
 		os.Exit(1)
 	}
 
+	if *stdin {
+		if err := validateStdin(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Validate required flags
 	if *schemaFile == "" {
 		fs.Usage()
@@ -48,6 +67,9 @@ Examples:
 		os.Exit(1)
 	}
 
+	// Strip fields guarded by feature flags that are not enabled
+	applyFeatures(schema, *features)
+
 	// Validate schema
 	if err := validator.ValidateSchema(schema); err != nil {
 		fmt.Fprintf(os.Stderr, "Error validating schema: %s\n", formatError(err))
@@ -55,6 +77,7 @@ Examples:
 	}
 
 	fmt.Printf("✓ Schema %s is valid\n", *schemaFile)
+	printBitmapHints(schema)
 
 	// If JSON file is provided, validate it too
 	if *jsonFile != "" {
@@ -72,3 +95,17 @@ Examples:
 		fmt.Printf("✓ JSON %s is valid\n", *jsonFile)
 	}
 }
+
+// printBitmapHints points out structs whose optional fields would take
+// fewer bytes as a @bitmap presence bitmap.
+func printBitmapHints(s *ffschema.Schema) {
+	for _, t := range s.Types {
+		st, ok := t.(*ffschema.StructType)
+		if !ok || st.Bitmap {
+			continue
+		}
+		if hint := analyzer.BitmapHint(st.Name, ffschema.OptionalFields(st)); hint != "" {
+			fmt.Printf("  hint: %s\n", hint)
+		}
+	}
+}
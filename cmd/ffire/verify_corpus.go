@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shaban/ffire/pkg/corpus"
+)
+
+func runVerifyCorpus(args []string) {
+	fs := flag.NewFlagSet("verify-corpus", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "Path to .ffi schema file (required)")
+	dir := fs.String("dir", "", "Directory of binary payloads, searched recursively (required)")
+	messageName := fs.String("message", "", "Message type name to decode (auto-detected if only one root type)")
+	ext := fs.String("ext", ".bin", "Only verify files with this extension (empty = all files)")
+	workers := fs.Int("workers", 0, "Number of parallel decoders (default: number of CPUs)")
+	features := fs.String("features", "", "Comma-separated feature flags to compile in (fields marked @feature)")
+	quiet := fs.Bool("quiet", false, "Only print failures and the summary")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: ffire verify-corpus [options]
+
+Decode every payload in a directory tree against a schema and report
+failures with the byte offset where decoding stopped. Run this against an
+archive before rolling out decoder or schema changes.
+
+Options:
+`)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, `
+Examples:
+  ffire verify-corpus --schema schema.ffi --dir payloads/
+  ffire verify-corpus --schema schema.ffi --dir archive/ --message DeviceList --workers 16
+`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *schemaFile == "" || *dir == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	schema, err := loadCanonicalSchema(*schemaFile, "", *features)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	// Auto-detect message name if not specified
+	if *messageName == "" {
+		if len(schema.Messages) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: Multiple root types found, please specify --message:\n")
+			for _, msg := range schema.Messages {
+				fmt.Fprintf(os.Stderr, "  - %s\n", msg.Name)
+			}
+			os.Exit(1)
+		}
+		*messageName = schema.Messages[0].Name
+	}
+
+	results, err := corpus.Verify(schema, *dir, corpus.Options{
+		Message: *messageName,
+		Ext:     *ext,
+		Workers: *workers,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	var totalBytes int64
+	for _, r := range results {
+		totalBytes += int64(r.Size)
+		if r.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "✗ %s: %v\n", r.Path, r.Err)
+		} else if !*quiet {
+			fmt.Printf("✓ %s (%d bytes)\n", r.Path, r.Size)
+		}
+	}
+
+	fmt.Printf("\nVerified %d payloads (%d bytes) as %s: %d passed, %d failed\n",
+		len(results), totalBytes, *messageName, len(results)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,34 @@
+// Command protoc-gen-ffire is a protoc plugin that emits ffire schemas (and
+// optionally generated ffire code) from .proto files.
+//
+//	go install github.com/shaban/ffire/cmd/protoc-gen-ffire@latest
+//	protoc --ffire_out=lang=go:gen/ api.proto
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/shaban/ffire/pkg/protoc"
+)
+
+func main() {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-ffire: reading request: %v\n", err)
+		os.Exit(1)
+	}
+
+	req, err := protoc.ParseRequest(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-ffire: %v\n", err)
+		os.Exit(1)
+	}
+
+	resp := protoc.Generate(req)
+	if _, err := os.Stdout.Write(resp.Marshal()); err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-ffire: writing response: %v\n", err)
+		os.Exit(1)
+	}
+}
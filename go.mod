@@ -2,4 +2,7 @@ module github.com/shaban/ffire
 
 go 1.25.3
 
-require github.com/magefile/mage v1.15.0
+require (
+	github.com/magefile/mage v1.15.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,32 @@
+package com.ffire.gradle;
+
+import org.gradle.api.file.ConfigurableFileCollection;
+import org.gradle.api.file.DirectoryProperty;
+import org.gradle.api.provider.ListProperty;
+import org.gradle.api.provider.Property;
+
+/**
+ * The {@code ffire { ... }} block of a build script.
+ */
+public abstract class FfireExtension {
+    /** Schema files to generate from. Default: {@code src/main/ffire/**}{@code /*.ffi}. */
+    public abstract ConfigurableFileCollection getSchemas();
+
+    /** The ffire CLI to run. Default: {@code ffire} on the PATH. */
+    public abstract Property<String> getExecutable();
+
+    /** Target language passed to {@code ffire generate -lang}. Default: {@code java}. */
+    public abstract Property<String> getLanguage();
+
+    /** Namespace override ({@code -ns}). */
+    public abstract Property<String> getNamespace();
+
+    /** Package version override ({@code -package-version}). */
+    public abstract Property<String> getPackageVersion();
+
+    /** Feature flags to compile in ({@code -features}). */
+    public abstract ListProperty<String> getFeatures();
+
+    /** Directory the sources are generated into. Default: {@code build/generated/sources/ffire}. */
+    public abstract DirectoryProperty getOutputDirectory();
+}
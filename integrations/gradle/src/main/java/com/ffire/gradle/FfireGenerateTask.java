@@ -0,0 +1,131 @@
+package com.ffire.gradle;
+
+import java.io.File;
+import java.util.ArrayList;
+import java.util.HashMap;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import javax.inject.Inject;
+import org.gradle.api.DefaultTask;
+import org.gradle.api.GradleException;
+import org.gradle.api.file.ConfigurableFileCollection;
+import org.gradle.api.file.DirectoryProperty;
+import org.gradle.api.file.FileSystemOperations;
+import org.gradle.api.provider.ListProperty;
+import org.gradle.api.provider.Property;
+import org.gradle.api.tasks.CacheableTask;
+import org.gradle.api.tasks.Input;
+import org.gradle.api.tasks.InputFiles;
+import org.gradle.api.tasks.Internal;
+import org.gradle.api.tasks.Optional;
+import org.gradle.api.tasks.OutputDirectory;
+import org.gradle.api.tasks.PathSensitive;
+import org.gradle.api.tasks.PathSensitivity;
+import org.gradle.api.tasks.SkipWhenEmpty;
+import org.gradle.api.tasks.TaskAction;
+import org.gradle.process.ExecOperations;
+
+/**
+ * Runs {@code ffire generate} for each schema. Each schema is generated
+ * into {@code <outputDirectory>/<schema file name>/}, with sources under
+ * its {@code src/} directory.
+ *
+ * <p>Gradle skips the task while the schemas, options and output are
+ * unchanged. It does not see a new ffire release: run with
+ * {@code --rerun-tasks} after upgrading the CLI.
+ */
+@CacheableTask
+public abstract class FfireGenerateTask extends DefaultTask {
+    @InputFiles
+    @PathSensitive(PathSensitivity.RELATIVE)
+    @SkipWhenEmpty
+    public abstract ConfigurableFileCollection getSchemas();
+
+    @Input
+    public abstract Property<String> getExecutable();
+
+    @Input
+    public abstract Property<String> getLanguage();
+
+    @Input
+    @Optional
+    public abstract Property<String> getNamespace();
+
+    @Input
+    @Optional
+    public abstract Property<String> getPackageVersion();
+
+    @Input
+    public abstract ListProperty<String> getFeatures();
+
+    @OutputDirectory
+    public abstract DirectoryProperty getOutputDirectory();
+
+    @Inject
+    protected abstract ExecOperations getExecOperations();
+
+    @Inject
+    protected abstract FileSystemOperations getFileSystemOperations();
+
+    /** Source directories of the generated packages, one per schema. */
+    @Internal
+    public List<File> getSourceRoots() {
+        List<File> roots = new ArrayList<>();
+        for (File dir : packageDirectories().values()) {
+            roots.add(new File(dir, "src"));
+        }
+        return roots;
+    }
+
+    @TaskAction
+    public void generate() {
+        Map<File, File> packages = packageDirectories();
+        // Start clean so sources of renamed or removed types do not linger
+        getFileSystemOperations().delete(spec -> spec.delete(getOutputDirectory().get().getAsFile()));
+
+        for (Map.Entry<File, File> entry : packages.entrySet()) {
+            List<String> args = new ArrayList<>();
+            args.add("generate");
+            args.add("-schema");
+            args.add(entry.getKey().getAbsolutePath());
+            args.add("-lang");
+            args.add(getLanguage().get());
+            args.add("-out");
+            args.add(entry.getValue().getAbsolutePath());
+            if (getNamespace().isPresent()) {
+                args.add("-ns");
+                args.add(getNamespace().get());
+            }
+            if (getPackageVersion().isPresent()) {
+                args.add("-package-version");
+                args.add(getPackageVersion().get());
+            }
+            if (!getFeatures().get().isEmpty()) {
+                args.add("-features");
+                args.add(String.join(",", getFeatures().get()));
+            }
+            getExecOperations().exec(spec -> {
+                spec.setExecutable(getExecutable().get());
+                spec.args(args);
+            });
+        }
+    }
+
+    /** Maps each schema to its package directory. */
+    private Map<File, File> packageDirectories() {
+        File out = getOutputDirectory().get().getAsFile();
+        Map<File, File> packages = new LinkedHashMap<>();
+        Map<String, File> byName = new HashMap<>();
+        for (File schema : getSchemas().getFiles()) {
+            String name = schema.getName().replaceFirst("\\.ffi$", "");
+            File other = byName.put(name, schema);
+            if (other != null) {
+                throw new GradleException("ffire schemas " + other + " and " + schema
+                        + " would be generated into the same directory; rename one of them");
+            }
+            packages.put(schema, new File(out, name));
+        }
+        return packages;
+    }
+}
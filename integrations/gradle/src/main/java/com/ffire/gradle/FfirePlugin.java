@@ -0,0 +1,41 @@
+package com.ffire.gradle;
+
+import org.gradle.api.Plugin;
+import org.gradle.api.Project;
+import org.gradle.api.plugins.JavaPlugin;
+import org.gradle.api.tasks.SourceSetContainer;
+import org.gradle.api.tasks.TaskProvider;
+
+/**
+ * Registers the {@code generateFfire} task and, with the Java plugin, adds
+ * its output to the main source set so {@code compileJava} regenerates
+ * sources first.
+ */
+public class FfirePlugin implements Plugin<Project> {
+    @Override
+    public void apply(Project project) {
+        FfireExtension ext = project.getExtensions().create("ffire", FfireExtension.class);
+        ext.getSchemas().from(project.fileTree("src/main/ffire", tree -> tree.include("**/*.ffi")));
+        ext.getExecutable().convention("ffire");
+        ext.getLanguage().convention("java");
+        ext.getOutputDirectory().convention(project.getLayout().getBuildDirectory().dir("generated/sources/ffire"));
+
+        TaskProvider<FfireGenerateTask> generate = project.getTasks().register("generateFfire", FfireGenerateTask.class, task -> {
+            task.setGroup("build");
+            task.setDescription("Generates sources from ffire schemas.");
+            task.getSchemas().from(ext.getSchemas());
+            task.getExecutable().set(ext.getExecutable());
+            task.getLanguage().set(ext.getLanguage());
+            task.getNamespace().set(ext.getNamespace());
+            task.getPackageVersion().set(ext.getPackageVersion());
+            task.getFeatures().set(ext.getFeatures());
+            task.getOutputDirectory().set(ext.getOutputDirectory());
+        });
+
+        project.getPlugins().withType(JavaPlugin.class, java -> {
+            SourceSetContainer sourceSets = project.getExtensions().getByType(SourceSetContainer.class);
+            // The mapped provider carries the task dependency
+            sourceSets.getByName("main").getJava().srcDir(generate.map(FfireGenerateTask::getSourceRoots));
+        });
+    }
+}
@@ -0,0 +1,71 @@
+"""Load ffire payloads into pandas DataFrames.
+
+The payload is decoded by the ffire CLI (``ffire convert --to json``), so
+any schema works without generating a package first::
+
+    import ffire
+    df = ffire.load("telemetry.ffi", "capture.bin")
+
+``ffire`` must be on PATH, or passed as ``executable``.
+"""
+
+import json
+import os
+import subprocess
+import tempfile
+
+__all__ = ["FfireError", "decode", "load"]
+
+
+class FfireError(RuntimeError):
+    """The ffire CLI failed; the message holds its error output."""
+
+
+def decode(schema, path, message=None, format="ffire", executable="ffire"):
+    """Decode the payload at ``path`` into plain Python values.
+
+    Structs become dicts keyed by JSON field name, arrays lists, enums the
+    names of their constants, bytes base64 strings and absent optional
+    fields None, as in ``ffire convert --to json``.
+
+    ``message`` names the root type and may be omitted when the schema has
+    only one. ``format`` is the payload's format: ffire, json, msgpack,
+    avro, arrow or arrow-stream.
+    """
+    with tempfile.TemporaryDirectory() as tmp:
+        out = os.path.join(tmp, "payload.json")
+        args = [executable, "convert", "--schema", os.fspath(schema), "--in", os.fspath(path),
+                "--out", out, "--from", format, "--to", "json"]
+        if message:
+            args += ["--message", message]
+        try:
+            proc = subprocess.run(args, capture_output=True, text=True)
+        except FileNotFoundError:
+            raise FfireError(f"{executable} not found; install the ffire CLI or pass executable=") from None
+        if proc.returncode != 0:
+            raise FfireError((proc.stderr or proc.stdout).strip())
+        with open(out, encoding="utf-8") as f:
+            return json.load(f)
+
+
+def load(schema, path, message=None, format="ffire", max_level=None, sep=".", executable="ffire"):
+    """Decode the payload at ``path`` into a pandas DataFrame.
+
+    An array of structs gives one row per element, and a struct message a
+    single row. Nested structs are flattened into ``field.child`` columns,
+    down to ``max_level`` levels (all by default); arrays, maps and unions
+    stay Python objects in their cells. An array of primitives gives a
+    single ``value`` column.
+
+    Columns are sorted by name, as the CLI writes JSON objects.
+    """
+    import pandas as pd
+
+    value = decode(schema, path, message=message, format=format, executable=executable)
+    if isinstance(value, dict):
+        value = [value]
+    if not isinstance(value, list):
+        raise TypeError(f"cannot make a DataFrame of a {type(value).__name__} message; use ffire.decode")
+    if value and not all(isinstance(row, dict) for row in value):
+        return pd.DataFrame({"value": value})
+    return pd.json_normalize(value, sep=sep, max_level=max_level)
@@ -0,0 +1,237 @@
+package com.ffire.maven;
+
+import java.io.File;
+import java.io.IOException;
+import java.nio.charset.StandardCharsets;
+import java.nio.file.Files;
+import java.nio.file.Path;
+import java.security.MessageDigest;
+import java.security.NoSuchAlgorithmException;
+import java.util.ArrayList;
+import java.util.Comparator;
+import java.util.HashMap;
+import java.util.LinkedHashMap;
+import java.util.List;
+import java.util.Map;
+import java.util.stream.Collectors;
+import java.util.stream.Stream;
+import org.apache.maven.plugin.AbstractMojo;
+import org.apache.maven.plugin.MojoExecutionException;
+import org.apache.maven.plugins.annotations.LifecyclePhase;
+import org.apache.maven.plugins.annotations.Mojo;
+import org.apache.maven.plugins.annotations.Parameter;
+import org.apache.maven.project.MavenProject;
+
+/**
+ * Runs {@code ffire generate} for each schema under {@code schemaDirectory}
+ * and adds the generated sources to the compile source roots. Each schema
+ * is generated into {@code <outputDirectory>/<schema file name>/}, with
+ * sources under its {@code src/} directory.
+ *
+ * <p>Generation is skipped while the schemas and options are unchanged: a
+ * stamp file in the output directory records a hash of both. A new ffire
+ * release is not detected; run {@code mvn clean} after upgrading the CLI.
+ */
+@Mojo(name = "generate", defaultPhase = LifecyclePhase.GENERATE_SOURCES, threadSafe = true)
+public class GenerateMojo extends AbstractMojo {
+    /** Name of the stamp file in the output directory. */
+    static final String STAMP_FILE = ".ffire-stamp";
+
+    @Parameter(defaultValue = "${project}", readonly = true, required = true)
+    private MavenProject project;
+
+    /** Directory searched recursively for .ffi schemas. */
+    @Parameter(defaultValue = "${project.basedir}/src/main/ffire")
+    private File schemaDirectory;
+
+    /** Directory the sources are generated into. */
+    @Parameter(defaultValue = "${project.build.directory}/generated-sources/ffire")
+    private File outputDirectory;
+
+    /** The ffire CLI to run. */
+    @Parameter(property = "ffire.executable", defaultValue = "ffire")
+    private String executable;
+
+    /** Target language passed to {@code ffire generate -lang}. */
+    @Parameter(defaultValue = "java")
+    private String language;
+
+    /** Namespace override ({@code -ns}). */
+    @Parameter
+    private String namespace;
+
+    /** Package version override ({@code -package-version}). */
+    @Parameter
+    private String packageVersion;
+
+    /** Feature flags to compile in ({@code -features}). */
+    @Parameter
+    private List<String> features = new ArrayList<>();
+
+    /** Skips generation, e.g. with {@code -Dffire.skip}. */
+    @Parameter(property = "ffire.skip", defaultValue = "false")
+    private boolean skip;
+
+    @Override
+    public void execute() throws MojoExecutionException {
+        if (skip) {
+            getLog().info("Skipping ffire generation");
+            return;
+        }
+        Map<Path, Path> packages = packageDirectories(findSchemas());
+        if (packages.isEmpty()) {
+            getLog().info("No ffire schemas in " + schemaDirectory);
+            return;
+        }
+
+        String stamp = stamp(packages);
+        Path stampFile = outputDirectory.toPath().resolve(STAMP_FILE);
+        boolean upToDate = stamp.equals(readStamp(stampFile))
+                && packages.values().stream().allMatch(Files::isDirectory);
+        if (!upToDate) {
+            // Start clean so sources of renamed or removed types do not linger
+            deleteRecursively(outputDirectory.toPath());
+            for (Map.Entry<Path, Path> entry : packages.entrySet()) {
+                generate(entry.getKey(), entry.getValue());
+            }
+            writeStamp(stampFile, stamp);
+        } else {
+            getLog().info("ffire sources are up to date");
+        }
+
+        for (Path dir : packages.values()) {
+            project.addCompileSourceRoot(dir.resolve("src").toString());
+        }
+    }
+
+    private List<Path> findSchemas() throws MojoExecutionException {
+        if (!schemaDirectory.isDirectory()) {
+            return new ArrayList<>();
+        }
+        try (Stream<Path> files = Files.walk(schemaDirectory.toPath())) {
+            return files.filter(p -> p.toString().endsWith(".ffi") && Files.isRegularFile(p))
+                    .sorted()
+                    .collect(Collectors.toList());
+        } catch (IOException e) {
+            throw new MojoExecutionException("Failed to list schemas in " + schemaDirectory, e);
+        }
+    }
+
+    /** Maps each schema to its package directory. */
+    private Map<Path, Path> packageDirectories(List<Path> schemas) throws MojoExecutionException {
+        Map<Path, Path> packages = new LinkedHashMap<>();
+        Map<String, Path> byName = new HashMap<>();
+        for (Path schema : schemas) {
+            String name = schema.getFileName().toString().replaceFirst("\\.ffi$", "");
+            Path other = byName.put(name, schema);
+            if (other != null) {
+                throw new MojoExecutionException("ffire schemas " + other + " and " + schema
+                        + " would be generated into the same directory; rename one of them");
+            }
+            packages.put(schema, outputDirectory.toPath().resolve(name));
+        }
+        return packages;
+    }
+
+    private void generate(Path schema, Path out) throws MojoExecutionException {
+        List<String> command = new ArrayList<>();
+        command.add(executable);
+        command.add("generate");
+        command.add("-schema");
+        command.add(schema.toAbsolutePath().toString());
+        command.add("-lang");
+        command.add(language);
+        command.add("-out");
+        command.add(out.toAbsolutePath().toString());
+        if (namespace != null && !namespace.isEmpty()) {
+            command.add("-ns");
+            command.add(namespace);
+        }
+        if (packageVersion != null && !packageVersion.isEmpty()) {
+            command.add("-package-version");
+            command.add(packageVersion);
+        }
+        if (!features.isEmpty()) {
+            command.add("-features");
+            command.add(String.join(",", features));
+        }
+
+        getLog().debug("Running " + String.join(" ", command));
+        ProcessBuilder pb = new ProcessBuilder(command)
+                .directory(project.getBasedir())
+                .redirectErrorStream(true);
+        try {
+            Process process = pb.start();
+            String output = new String(process.getInputStream().readAllBytes(), StandardCharsets.UTF_8);
+            int exit = process.waitFor();
+            if (exit != 0) {
+                throw new MojoExecutionException("ffire generate failed for " + schema + " (exit " + exit + "):\n" + output);
+            }
+            getLog().debug(output);
+        } catch (IOException e) {
+            throw new MojoExecutionException("Failed to run " + executable + "; is the ffire CLI installed?", e);
+        } catch (InterruptedException e) {
+            Thread.currentThread().interrupt();
+            throw new MojoExecutionException("Interrupted while running ffire", e);
+        }
+        getLog().info("Generated " + out + " from " + schema.getFileName());
+    }
+
+    /** Returns a hash of the options and of each schema's path and content. */
+    private String stamp(Map<Path, Path> packages) throws MojoExecutionException {
+        try {
+            MessageDigest digest = MessageDigest.getInstance("SHA-256");
+            List<String> options = List.of(executable, language, String.valueOf(namespace),
+                    String.valueOf(packageVersion), String.join(",", features));
+            for (String option : options) {
+                digest.update(option.getBytes(StandardCharsets.UTF_8));
+                digest.update((byte) 0);
+            }
+            for (Path schema : packages.keySet()) {
+                digest.update(schemaDirectory.toPath().relativize(schema).toString().getBytes(StandardCharsets.UTF_8));
+                digest.update((byte) 0);
+                digest.update(Files.readAllBytes(schema));
+                digest.update((byte) 0);
+            }
+            StringBuilder hex = new StringBuilder();
+            for (byte b : digest.digest()) {
+                hex.append(String.format("%02x", b));
+            }
+            return hex.toString();
+        } catch (IOException e) {
+            throw new MojoExecutionException("Failed to read schemas", e);
+        } catch (NoSuchAlgorithmException e) {
+            throw new MojoExecutionException("SHA-256 is not available", e);
+        }
+    }
+
+    private static String readStamp(Path stampFile) {
+        try {
+            return Files.readString(stampFile).trim();
+        } catch (IOException e) {
+            return "";
+        }
+    }
+
+    private static void writeStamp(Path stampFile, String stamp) throws MojoExecutionException {
+        try {
+            Files.createDirectories(stampFile.getParent());
+            Files.writeString(stampFile, stamp + "\n");
+        } catch (IOException e) {
+            throw new MojoExecutionException("Failed to write " + stampFile, e);
+        }
+    }
+
+    private static void deleteRecursively(Path dir) throws MojoExecutionException {
+        if (!Files.exists(dir)) {
+            return;
+        }
+        try (Stream<Path> files = Files.walk(dir)) {
+            for (Path p : files.sorted(Comparator.reverseOrder()).collect(Collectors.toList())) {
+                Files.delete(p);
+            }
+        } catch (IOException e) {
+            throw new MojoExecutionException("Failed to clean " + dir, e);
+        }
+    }
+}
@@ -0,0 +1,233 @@
+// Package blake3 implements the BLAKE3 hash function (default hash mode,
+// 32-byte output). It is a straightforward port of the reference
+// implementation, without SIMD or multithreading.
+package blake3
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Size is the length of a BLAKE3 digest in bytes.
+const Size = 32
+
+const (
+	blockLen = 64
+	chunkLen = 1024
+
+	chunkStart = 1 << 0
+	chunkEnd   = 1 << 1
+	parent     = 1 << 2
+	root       = 1 << 3
+)
+
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var msgPermutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func g(state *[16]uint32, a, b, c, d int, mx, my uint32) {
+	state[a] = state[a] + state[b] + mx
+	state[d] = bits.RotateLeft32(state[d]^state[a], -16)
+	state[c] = state[c] + state[d]
+	state[b] = bits.RotateLeft32(state[b]^state[c], -12)
+	state[a] = state[a] + state[b] + my
+	state[d] = bits.RotateLeft32(state[d]^state[a], -8)
+	state[c] = state[c] + state[d]
+	state[b] = bits.RotateLeft32(state[b]^state[c], -7)
+}
+
+func round(state *[16]uint32, m *[16]uint32) {
+	// Mix the columns
+	g(state, 0, 4, 8, 12, m[0], m[1])
+	g(state, 1, 5, 9, 13, m[2], m[3])
+	g(state, 2, 6, 10, 14, m[4], m[5])
+	g(state, 3, 7, 11, 15, m[6], m[7])
+	// Mix the diagonals
+	g(state, 0, 5, 10, 15, m[8], m[9])
+	g(state, 1, 6, 11, 12, m[10], m[11])
+	g(state, 2, 7, 8, 13, m[12], m[13])
+	g(state, 3, 4, 9, 14, m[14], m[15])
+}
+
+func permute(m *[16]uint32) {
+	var permuted [16]uint32
+	for i := range permuted {
+		permuted[i] = m[msgPermutation[i]]
+	}
+	*m = permuted
+}
+
+// compress returns the first 8 words of the compression function output,
+// which is all the default 32-byte hash mode needs.
+func compress(cv *[8]uint32, block *[16]uint32, counter uint64, blockLen uint32, flags uint32) [8]uint32 {
+	state := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		iv[0], iv[1], iv[2], iv[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := *block
+	for r := 0; r < 7; r++ {
+		round(&state, &m)
+		if r < 6 {
+			permute(&m)
+		}
+	}
+
+	var out [8]uint32
+	for i := range out {
+		out[i] = state[i] ^ state[i+8]
+	}
+	return out
+}
+
+func wordsFromBlock(b []byte) [16]uint32 {
+	var padded [blockLen]byte
+	copy(padded[:], b)
+	var words [16]uint32
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(padded[i*4:])
+	}
+	return words
+}
+
+// output holds the inputs of a compression that may become the root.
+type output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *output) chainingValue() [8]uint32 {
+	return compress(&o.cv, &o.block, o.counter, o.blockLen, o.flags)
+}
+
+func (o *output) rootHash() [Size]byte {
+	words := compress(&o.cv, &o.block, 0, o.blockLen, o.flags|root)
+	var out [Size]byte
+	for i, w := range words {
+		binary.LittleEndian.PutUint32(out[i*4:], w)
+	}
+	return out
+}
+
+type chunkState struct {
+	cv               [8]uint32
+	counter          uint64
+	block            [blockLen]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+func newChunkState(counter uint64) chunkState {
+	return chunkState{cv: iv, counter: counter}
+}
+
+func (c *chunkState) len() int {
+	return c.blocksCompressed*blockLen + c.blockLen
+}
+
+func (c *chunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return chunkStart
+	}
+	return 0
+}
+
+func (c *chunkState) update(input []byte) {
+	for len(input) > 0 {
+		// Only compress a full block once more input arrives, since the last
+		// block of the chunk needs the chunkEnd flag
+		if c.blockLen == blockLen {
+			words := wordsFromBlock(c.block[:])
+			c.cv = compress(&c.cv, &words, c.counter, blockLen, c.startFlag())
+			c.blocksCompressed++
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], input)
+		c.blockLen += n
+		input = input[n:]
+	}
+}
+
+func (c *chunkState) output() output {
+	return output{
+		cv:       c.cv,
+		block:    wordsFromBlock(c.block[:c.blockLen]),
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | chunkEnd,
+	}
+}
+
+func parentOutput(left, right [8]uint32) output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return output{cv: iv, block: block, blockLen: blockLen, flags: parent}
+}
+
+// Hasher computes a BLAKE3 digest incrementally.
+type Hasher struct {
+	chunk   chunkState
+	cvStack [][8]uint32
+}
+
+// New returns a Hasher for the default hash mode.
+func New() *Hasher {
+	return &Hasher{chunk: newChunkState(0)}
+}
+
+// Write adds data to the hash. It never returns an error.
+func (h *Hasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// A full chunk is only finalized once more input arrives, since the
+		// last chunk may be the root
+		if h.chunk.len() == chunkLen {
+			out := h.chunk.output()
+			h.addChunkCV(out.chainingValue(), h.chunk.counter+1)
+			h.chunk = newChunkState(h.chunk.counter + 1)
+		}
+		take := chunkLen - h.chunk.len()
+		if take > len(p) {
+			take = len(p)
+		}
+		h.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// addChunkCV merges completed subtrees: one merge per trailing zero bit of
+// the total number of chunks.
+func (h *Hasher) addChunkCV(cv [8]uint32, totalChunks uint64) {
+	for totalChunks&1 == 0 {
+		left := h.cvStack[len(h.cvStack)-1]
+		h.cvStack = h.cvStack[:len(h.cvStack)-1]
+		out := parentOutput(left, cv)
+		cv = out.chainingValue()
+		totalChunks >>= 1
+	}
+	h.cvStack = append(h.cvStack, cv)
+}
+
+// Sum returns the digest of the data written so far.
+func (h *Hasher) Sum() [Size]byte {
+	out := h.chunk.output()
+	for i := len(h.cvStack) - 1; i >= 0; i-- {
+		out = parentOutput(h.cvStack[i], out.chainingValue())
+	}
+	return out.rootHash()
+}
+
+// Sum256 returns the BLAKE3 digest of data.
+func Sum256(data []byte) [Size]byte {
+	h := New()
+	h.Write(data)
+	return h.Sum()
+}
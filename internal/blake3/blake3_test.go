@@ -0,0 +1,54 @@
+package blake3
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Inputs follow the official test vectors: byte i is i % 251.
+func testInput(n int) []byte {
+	in := make([]byte, n)
+	for i := range in {
+		in[i] = byte(i % 251)
+	}
+	return in
+}
+
+func TestSum256(t *testing.T) {
+	vectors := []struct {
+		len  int
+		hash string
+	}{
+		{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+		{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+		{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+		{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+	}
+
+	for _, v := range vectors {
+		sum := Sum256(testInput(v.len))
+		if got := hex.EncodeToString(sum[:]); got != v.hash {
+			t.Errorf("len %d: got %s, want %s", v.len, got, v.hash)
+		}
+	}
+}
+
+func TestHasherIncremental(t *testing.T) {
+	in := testInput(5000)
+	want := Sum256(in)
+
+	for _, step := range []int{1, 63, 64, 65, 1023, 1024, 1025} {
+		h := New()
+		for i := 0; i < len(in); i += step {
+			end := i + step
+			if end > len(in) {
+				end = len(in)
+			}
+			h.Write(in[i:end])
+		}
+		if got := h.Sum(); got != want {
+			t.Errorf("step %d: got %x, want %x", step, got, want)
+		}
+	}
+}
@@ -122,6 +122,21 @@ func DecodeString(r io.Reader) (string, error) {
 	return string(buf), nil
 }
 
+// DecodeBytes decodes a byte string from [uint16_le: length][bytes...].
+func DecodeBytes(r io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("decode bytes length: %w", err)
+	}
+
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("decode bytes data: %w", err)
+	}
+
+	return buf, nil
+}
+
 // DecodeArrayHeader decodes an array length from uint16_le.
 // No bounds checking needed - uint16 physically limits to 65,535 elements.
 func DecodeArrayHeader(r io.Reader) (uint16, error) {
@@ -135,3 +150,49 @@ func DecodeArrayHeader(r io.Reader) (uint16, error) {
 	}
 	return count, nil
 }
+
+// DecodeLargeString decodes a @large string from [uint32_le: byte_length][utf8_bytes...].
+// The data is read as it arrives, so a corrupt length fails at the end of
+// the input rather than allocating up to 4 GiB.
+func DecodeLargeString(r io.Reader) (string, error) {
+	b, err := decodeLarge(r)
+	if err != nil {
+		return "", fmt.Errorf("decode string: %w", err)
+	}
+	return string(b), nil
+}
+
+// DecodeLargeBytes decodes a @large byte string from [uint32_le: length][bytes...].
+func DecodeLargeBytes(r io.Reader) ([]byte, error) {
+	b, err := decodeLarge(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode bytes: %w", err)
+	}
+	return b, nil
+}
+
+// DecodeLargeArrayHeader decodes the length of a @large array or map from
+// uint32_le.
+func DecodeLargeArrayHeader(r io.Reader) (uint32, error) {
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return 0, fmt.Errorf("decode array header: %w", err)
+	}
+	return count, nil
+}
+
+// decodeLarge reads a uint32 length and that many bytes.
+func decodeLarge(r io.Reader) ([]byte, error) {
+	var length uint32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("length: %w", err)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return nil, fmt.Errorf("data: %w", err)
+	}
+	if len(b) != int(length) {
+		return nil, fmt.Errorf("data: %w", io.ErrUnexpectedEOF)
+	}
+	return b, nil
+}
@@ -9,7 +9,13 @@ import (
 )
 
 // Wire format version: Uses uint16 for string/array lengths (max 65,535)
-// This provides safety by design - physically impossible to overflow
+// This provides safety by design - physically impossible to overflow.
+// Values marked @large in the schema use uint32 lengths instead.
+
+// Version is the wire format version, sent in handshakes. It changes only
+// when the encoding of existing schemas changes; opt-in features such as
+// @large show up in the schema fingerprint instead.
+const Version = 1
 
 // EncodeBool encodes a boolean value.
 func EncodeBool(buf *bytes.Buffer, v bool) {
@@ -58,9 +64,35 @@ func EncodeString(buf *bytes.Buffer, s string) {
 	buf.WriteString(s)
 }
 
+// EncodeBytes encodes a byte string as [uint16_le: length][bytes...], the
+// same layout as a string without the UTF-8 requirement.
+// Max length: 65,535 bytes (enforced by validator).
+func EncodeBytes(buf *bytes.Buffer, b []byte) {
+	binary.Write(buf, binary.LittleEndian, uint16(len(b)))
+	buf.Write(b)
+}
+
 // EncodeArrayHeader encodes an array length as uint16_le.
 // This should be called before encoding array elements.
 // Max count: 65,535 elements (enforced by validator).
 func EncodeArrayHeader(buf *bytes.Buffer, count uint16) {
 	binary.Write(buf, binary.LittleEndian, count)
 }
+
+// EncodeLargeString encodes a @large string as [uint32_le: byte_length][utf8_bytes...].
+func EncodeLargeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(s)))
+	buf.WriteString(s)
+}
+
+// EncodeLargeBytes encodes a @large byte string as [uint32_le: length][bytes...].
+func EncodeLargeBytes(buf *bytes.Buffer, b []byte) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
+// EncodeLargeArrayHeader encodes the length of a @large array or map as
+// uint32_le.
+func EncodeLargeArrayHeader(buf *bytes.Buffer, count uint32) {
+	binary.Write(buf, binary.LittleEndian, count)
+}
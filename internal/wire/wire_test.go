@@ -2,6 +2,7 @@ package wire
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -138,6 +139,26 @@ func TestRoundTripString(t *testing.T) {
 	}
 }
 
+func TestRoundTripBytes(t *testing.T) {
+	tests := [][]byte{
+		{},
+		{0x00},
+		{0xff, 0xfe, 0x00, 0x80}, // not valid UTF-8
+	}
+	for _, want := range tests {
+		buf := &bytes.Buffer{}
+		EncodeBytes(buf, want)
+
+		got, err := DecodeBytes(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("DecodeBytes failed for %x: %v", want, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("bytes round-trip: got %x, want %x", got, want)
+		}
+	}
+}
+
 func TestRoundTripArrayHeader(t *testing.T) {
 	tests := []uint16{0, 1, 100, 5000, 65535} // Max changed from 4294967295 to 65535 (uint16)
 	for _, want := range tests {
@@ -154,6 +175,33 @@ func TestRoundTripArrayHeader(t *testing.T) {
 	}
 }
 
+func TestRoundTripLarge(t *testing.T) {
+	long := strings.Repeat("x", 70000) // Past the uint16 limit
+	buf := &bytes.Buffer{}
+	EncodeLargeString(buf, long)
+	EncodeLargeBytes(buf, []byte{0xff, 0x00})
+	EncodeLargeArrayHeader(buf, 100000)
+
+	if !bytes.Equal(buf.Bytes()[:4], []byte{0x70, 0x11, 0x01, 0x00}) {
+		t.Errorf("large string length = %x, want 70110100", buf.Bytes()[:4])
+	}
+	r := bytes.NewReader(buf.Bytes())
+	if got, err := DecodeLargeString(r); err != nil || got != long {
+		t.Fatalf("DecodeLargeString = %d bytes, %v; want %d bytes", len(got), err, len(long))
+	}
+	if got, err := DecodeLargeBytes(r); err != nil || !bytes.Equal(got, []byte{0xff, 0x00}) {
+		t.Fatalf("DecodeLargeBytes = %x, %v", got, err)
+	}
+	if got, err := DecodeLargeArrayHeader(r); err != nil || got != 100000 {
+		t.Fatalf("DecodeLargeArrayHeader = %d, %v", got, err)
+	}
+
+	// A length past the end of the data fails without allocating it
+	if _, err := DecodeLargeString(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 'x'})); err == nil {
+		t.Error("DecodeLargeString accepted a truncated string")
+	}
+}
+
 // Test wire format according to spec
 func TestWireFormatSpec(t *testing.T) {
 	// Example from wire-format.md:
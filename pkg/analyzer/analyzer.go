@@ -1,7 +1,11 @@
 // Package analyzer analyzes schemas to enable code generation optimizations.
+// Analyze only reads the schema, so build tools can call it on schemas from
+// parser.ParseReader before choosing how to generate.
 package analyzer
 
 import (
+	"fmt"
+
 	"github.com/shaban/ffire/pkg/schema"
 )
 
@@ -12,7 +16,34 @@ type TypeInfo struct {
 	MaxSize     int  // Maximum possible size with all optionals present
 	HasStrings  bool // Contains any string fields?
 	HasArrays   bool // Contains any array fields?
+	HasMaps     bool // Contains any map fields?
+	HasUnions   bool // Contains any union fields?
 	NestDepth   int  // Maximum nesting depth
+
+	// Presence flags of a struct's own optional fields
+	OptionalFields int // Number of optional fields
+	PresenceBytes  int // Bytes their flags take: one each, or the bitmap if @bitmap
+	BitmapSavings  int // Bytes per value @bitmap saves over presence bytes
+}
+
+// BitmapSavings returns the bytes per value a presence bitmap saves over
+// presence bytes for a struct with n optional fields: n flags shrink to
+// n bits, rounded up to whole bytes.
+func BitmapSavings(n int) int {
+	return n - (n+7)/8
+}
+
+// BitmapHint returns the hint that struct name, with n optional fields,
+// would be smaller as a @bitmap struct, or "" if a bitmap saves nothing.
+func BitmapHint(name string, n int) string {
+	saved := BitmapSavings(n)
+	switch saved {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("%s has %d optional fields; @bitmap would save 1 byte per value", name, n)
+	}
+	return fmt.Sprintf("%s has %d optional fields; @bitmap would save %d bytes per value", name, n, saved)
 }
 
 // Analyze analyzes all types in a schema and returns type information map.
@@ -34,6 +65,19 @@ func Analyze(s *schema.Schema) map[string]*TypeInfo {
 	return a.typeInfo
 }
 
+// AnalyzeType analyzes a single type of s, such as the target of a
+// message that is not a struct. With @max limits on its strings, bytes,
+// slices and maps, MaxSize is the size of a buffer that holds any value
+// of the type.
+func AnalyzeType(s *schema.Schema, t schema.Type) *TypeInfo {
+	a := &analyzer{
+		schema:   s,
+		typeInfo: make(map[string]*TypeInfo),
+		visiting: make(map[string]bool),
+	}
+	return a.computeTypeInfo(t)
+}
+
 type analyzer struct {
 	schema   *schema.Schema
 	typeInfo map[string]*TypeInfo
@@ -72,6 +116,12 @@ func (a *analyzer) computeTypeInfo(typ schema.Type) *TypeInfo {
 		return a.analyzeStruct(t)
 	case *schema.ArrayType:
 		return a.analyzeArray(t)
+	case *schema.MapType:
+		return a.analyzeMap(t)
+	case *schema.EnumType:
+		return a.analyzePrimitive(t.BaseType()) // Encoded as its base integer
+	case *schema.UnionType:
+		return a.analyzeUnion(t)
 	default:
 		return &TypeInfo{}
 	}
@@ -89,7 +139,10 @@ func (a *analyzer) analyzePrimitive(typ *schema.PrimitiveType) *TypeInfo {
 		// Strings are not fixed size
 		info.IsFixedSize = false
 		info.HasStrings = true
-		info.MaxSize = 2 + 65535 // uint16 length + max string
+		info.MaxSize = schema.LengthPrefixSize(typ) + int(schema.MaxLengthOf(typ)) // length prefix + max string
+	} else if typ.Name == "bytes" {
+		info.IsFixedSize = false
+		info.MaxSize = schema.LengthPrefixSize(typ) + int(schema.MaxLengthOf(typ))
 	} else {
 		// Fixed-size primitive
 		info.IsFixedSize = true
@@ -133,6 +186,12 @@ func (a *analyzer) analyzeStruct(typ *schema.StructType) *TypeInfo {
 		if fieldInfo.HasArrays {
 			info.HasArrays = true
 		}
+		if fieldInfo.HasMaps {
+			info.HasMaps = true
+		}
+		if fieldInfo.HasUnions {
+			info.HasUnions = true
+		}
 
 		// Update sizes
 		if info.IsFixedSize {
@@ -146,6 +205,10 @@ func (a *analyzer) analyzeStruct(typ *schema.StructType) *TypeInfo {
 			fieldDepth++ // Nested struct adds a level
 		} else if _, isArray := field.Type.(*schema.ArrayType); isArray {
 			fieldDepth++ // Nested array adds a level
+		} else if _, isMap := field.Type.(*schema.MapType); isMap {
+			fieldDepth++ // Nested map adds a level
+		} else if _, isUnion := field.Type.(*schema.UnionType); isUnion {
+			fieldDepth++ // Nested union adds a level
 		}
 
 		if fieldDepth > maxFieldDepth {
@@ -155,6 +218,22 @@ func (a *analyzer) analyzeStruct(typ *schema.StructType) *TypeInfo {
 
 	info.NestDepth = maxFieldDepth
 
+	if frame := schema.FramePrefixSize(typ); frame > 0 {
+		if info.IsFixedSize {
+			info.FixedSize += frame
+		}
+		info.MaxSize += frame
+	}
+
+	info.OptionalFields = schema.OptionalFields(typ)
+	info.BitmapSavings = BitmapSavings(info.OptionalFields)
+	info.PresenceBytes = info.OptionalFields
+	if typ.Bitmap {
+		// Every optional field's MaxSize counted its presence byte
+		info.PresenceBytes = schema.BitmapSize(typ)
+		info.MaxSize += info.PresenceBytes - info.OptionalFields
+	}
+
 	return info
 }
 
@@ -164,13 +243,79 @@ func (a *analyzer) analyzeArray(typ *schema.ArrayType) *TypeInfo {
 	info := &TypeInfo{
 		IsFixedSize: false, // Arrays are never fixed size (length varies)
 		HasArrays:   true,
-		MaxSize:     2 + (65535 * elemInfo.MaxSize), // uint16 length + max elements
+		MaxSize:     schema.LengthPrefixSize(typ) + (int(schema.MaxLengthOf(typ)) * elemInfo.MaxSize), // length prefix + max elements
 		NestDepth:   elemInfo.NestDepth + 1,
 	}
 
 	if elemInfo.HasStrings {
 		info.HasStrings = true
 	}
+	if elemInfo.HasMaps {
+		info.HasMaps = true
+	}
+	if elemInfo.HasUnions {
+		info.HasUnions = true
+	}
+
+	// Fixed-size arrays have no length prefix and a known element count
+	if typ.Length > 0 {
+		info.MaxSize = typ.Length * elemInfo.MaxSize
+		if elemInfo.IsFixedSize {
+			info.IsFixedSize = true
+			info.FixedSize = typ.Length * elemInfo.FixedSize
+		}
+	}
+
+	if typ.Optional {
+		info.IsFixedSize = false
+		info.FixedSize = 0
+		info.MaxSize += 1 // Optional flag
+	}
+
+	return info
+}
+
+func (a *analyzer) analyzeMap(typ *schema.MapType) *TypeInfo {
+	keyInfo := a.computeTypeInfo(typ.KeyType)
+	valueInfo := a.computeTypeInfo(typ.ValueType)
+
+	info := &TypeInfo{
+		IsFixedSize: false, // Entry count varies
+		HasStrings:  keyInfo.HasStrings || valueInfo.HasStrings,
+		HasArrays:   valueInfo.HasArrays,
+		HasMaps:     true,
+		HasUnions:   valueInfo.HasUnions,
+		MaxSize:     schema.LengthPrefixSize(typ) + (int(schema.MaxLengthOf(typ)) * (keyInfo.MaxSize + valueInfo.MaxSize)), // entry count + max entries
+		NestDepth:   valueInfo.NestDepth + 1,
+	}
+
+	if typ.Optional {
+		info.MaxSize += 1 // Optional flag
+	}
+
+	return info
+}
+
+func (a *analyzer) analyzeUnion(typ *schema.UnionType) *TypeInfo {
+	info := &TypeInfo{
+		IsFixedSize: false, // Size depends on the variant
+		HasUnions:   true,
+	}
+
+	maxVariant := 0
+	for _, variant := range typ.Variants {
+		variantInfo := a.computeTypeInfo(variant)
+		info.HasStrings = info.HasStrings || variantInfo.HasStrings
+		info.HasArrays = info.HasArrays || variantInfo.HasArrays
+		info.HasMaps = info.HasMaps || variantInfo.HasMaps
+		if variantInfo.MaxSize > maxVariant {
+			maxVariant = variantInfo.MaxSize
+		}
+		if variantInfo.NestDepth+1 > info.NestDepth {
+			info.NestDepth = variantInfo.NestDepth + 1
+		}
+	}
+	info.MaxSize = 1 + maxVariant // uint8 tag + largest variant
 
 	if typ.Optional {
 		info.MaxSize += 1 // Optional flag
@@ -189,7 +334,7 @@ func (a *analyzer) primitiveSize(name string) int {
 		return 4
 	case "int64", "float64":
 		return 8
-	case "string":
+	case "string", "bytes":
 		return 2 + 65535 // uint16 length + max data
 	default:
 		return 0
@@ -288,3 +288,143 @@ func TestAnalyzeOptionalStruct(t *testing.T) {
 		t.Errorf("MaxSize = %d, want %d", info.MaxSize, expectedMax)
 	}
 }
+
+func TestAnalyzeBitmap(t *testing.T) {
+	fields := []schema.Field{{Name: "ID", Type: &schema.PrimitiveType{Name: "int32"}}}
+	for _, name := range []string{"A", "B", "C", "D", "E", "F", "G", "H", "I"} {
+		fields = append(fields, schema.Field{Name: name, Type: &schema.PrimitiveType{Name: "int8", Optional: true}})
+	}
+	s := &schema.Schema{
+		Package: "test",
+		Types: []schema.Type{
+			&schema.StructType{Name: "Plain", Fields: fields},
+			&schema.StructType{Name: "Packed", Fields: fields, Bitmap: true},
+		},
+	}
+
+	result := Analyze(s)
+	plain, packed := result["Plain"], result["Packed"]
+
+	// Nine flags take nine bytes, or two as a bitmap
+	if plain.OptionalFields != 9 || plain.PresenceBytes != 9 || plain.BitmapSavings != 7 {
+		t.Errorf("Plain = %d fields, %d bytes, saves %d; want 9, 9, 7", plain.OptionalFields, plain.PresenceBytes, plain.BitmapSavings)
+	}
+	if packed.PresenceBytes != 2 || packed.BitmapSavings != 7 {
+		t.Errorf("Packed = %d bytes, saves %d; want 2, 7", packed.PresenceBytes, packed.BitmapSavings)
+	}
+	if plain.MaxSize != 4+9*2 || packed.MaxSize != 4+2+9 {
+		t.Errorf("MaxSize = %d and %d, want %d and %d", plain.MaxSize, packed.MaxSize, 4+9*2, 4+2+9)
+	}
+
+	for n, want := range map[int]int{0: 0, 1: 0, 2: 1, 8: 7, 9: 7, 16: 14} {
+		if got := BitmapSavings(n); got != want {
+			t.Errorf("BitmapSavings(%d) = %d, want %d", n, got, want)
+		}
+	}
+
+	for n, want := range map[int]string{
+		1: "",
+		2: "Plain has 2 optional fields; @bitmap would save 1 byte per value",
+		9: "Plain has 9 optional fields; @bitmap would save 7 bytes per value",
+	} {
+		if got := BitmapHint("Plain", n); got != want {
+			t.Errorf("BitmapHint(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestAnalyzeFramed(t *testing.T) {
+	point := &schema.StructType{
+		Name:   "Point",
+		Framed: true,
+		Fields: []schema.Field{
+			{Name: "X", Type: &schema.PrimitiveType{Name: "int32"}},
+			{Name: "Y", Type: &schema.PrimitiveType{Name: "int32"}},
+		},
+	}
+	label := &schema.StructType{
+		Name:   "Label",
+		Framed: true,
+		Fields: []schema.Field{{Name: "Text", Type: &schema.PrimitiveType{Name: "string", Optional: true}}},
+	}
+	s := &schema.Schema{Package: "test", Types: []schema.Type{point, label}}
+
+	result := Analyze(s)
+	// The frame adds 4 bytes to fixed and variable structs alike
+	if p := result["Point"]; !p.IsFixedSize || p.FixedSize != 4+8 || p.MaxSize != 4+8 {
+		t.Errorf("Point = fixed %v, %d B, at most %d B; want fixed, 12 B, 12 B", p.IsFixedSize, p.FixedSize, p.MaxSize)
+	}
+	if l := result["Label"]; l.IsFixedSize || l.MaxSize != 4+1+2+65535 {
+		t.Errorf("Label = fixed %v, at most %d B; want variable, at most %d B", l.IsFixedSize, l.MaxSize, 4+1+2+65535)
+	}
+}
+
+func TestAnalyzeUnion(t *testing.T) {
+	// Struct with a union of a fixed struct and an int64
+	point := &schema.StructType{
+		Name: "Point",
+		Fields: []schema.Field{
+			{Name: "X", Type: &schema.PrimitiveType{Name: "int32"}},
+			{Name: "Y", Type: &schema.PrimitiveType{Name: "int32"}},
+		},
+	}
+	s := &schema.Schema{
+		Package: "test",
+		Types: []schema.Type{
+			point,
+			&schema.StructType{
+				Name: "Marker",
+				Fields: []schema.Field{
+					{Name: "At", Type: &schema.UnionType{Name: "Location", Variants: []schema.Type{
+						point, &schema.PrimitiveType{Name: "int64"},
+					}}},
+				},
+			},
+		},
+	}
+
+	info := Analyze(s)["Marker"]
+	if info == nil {
+		t.Fatal("Marker not in result")
+	}
+
+	if info.IsFixedSize {
+		t.Errorf("IsFixedSize = true, want false")
+	}
+	if !info.HasUnions {
+		t.Errorf("HasUnions = false, want true")
+	}
+	if info.MaxSize != 9 { // 1 (tag) + 8 (largest variant)
+		t.Errorf("MaxSize = %d, want 9", info.MaxSize)
+	}
+	if info.NestDepth != 2 { // union + Point
+		t.Errorf("NestDepth = %d, want 2", info.NestDepth)
+	}
+}
+
+func TestAnalyzeTypeMax(t *testing.T) {
+	device := &schema.StructType{
+		Name: "Device",
+		Fields: []schema.Field{
+			{Name: "Name", Type: &schema.PrimitiveType{Name: "string", Max: 16}},
+			{Name: "Gain", Type: &schema.PrimitiveType{Name: "float32", Optional: true}},
+		},
+	}
+	list := &schema.ArrayType{ElementType: device, Max: 4}
+	s := &schema.Schema{Package: "test", Types: []schema.Type{device}}
+
+	// 2 (count) + 4 * (2 + 16 name bytes + 1 flag + 4 gain bytes)
+	if got := AnalyzeType(s, list).MaxSize; got != 94 {
+		t.Errorf("MaxSize = %d, want 94", got)
+	}
+	tags := &schema.MapType{
+		KeyType:   &schema.PrimitiveType{Name: "string", Max: 8},
+		ValueType: &schema.PrimitiveType{Name: "int32"},
+		Max:       3,
+		Large:     true,
+	}
+	// 4 (count) + 3 * (2 + 8 key bytes + 4)
+	if got := AnalyzeType(s, tags).MaxSize; got != 46 {
+		t.Errorf("MaxSize = %d, want 46", got)
+	}
+}
@@ -0,0 +1,404 @@
+// Package ast is the syntax tree of .ffi schema files. It keeps every
+// comment and source position so that formatting, linting, documentation
+// and editor tooling can share one front-end; pkg/parser lowers it to the
+// semantic schema.Schema used by the generators.
+//
+// The syntax is the subset of Go type and constant declarations that
+// schemas use:
+//
+//	package audio
+//
+//	// Device is a doc comment, attached to the declaration below.
+//	type Device struct {
+//	    ID   int32   `json:"id"`
+//	    Name *string // line comment
+//	    Tags []string
+//	    Gain map[string]float32
+//	    Mode Mode
+//	}
+//
+//	type Mode int8
+//
+//	const (
+//	    Mono Mode = iota + 1
+//	    Stereo
+//	)
+//
+//	type Page[T any] struct { Items []T }
+//	type DevicePage Page[Device]
+package ast
+
+import "strings"
+
+// Node is implemented by all syntax tree nodes.
+type Node interface {
+	Pos() Pos // position of the first character of the node
+	End() Pos // position just after the node
+}
+
+// Expr is a type expression.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Comment is a single // or /* */ comment, including its delimiters.
+type Comment struct {
+	Slash Pos
+	Text  string
+}
+
+func (c *Comment) Pos() Pos { return c.Slash }
+func (c *Comment) End() Pos { return advance(c.Slash, c.Text) }
+
+// CommentGroup is a sequence of comments with no blank line or other token
+// between them.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) Pos() Pos { return g.List[0].Pos() }
+func (g *CommentGroup) End() Pos { return g.List[len(g.List)-1].End() }
+
+// Text returns the comment text without delimiters, with leading and
+// trailing blank lines removed. A nil group has no text.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		text := c.Text
+		if strings.HasPrefix(text, "//") {
+			text = strings.TrimPrefix(text[2:], " ")
+			lines = append(lines, strings.TrimRight(text, " \t"))
+			continue
+		}
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRight(line, " \t\r"))
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// File is a parsed schema file.
+type File struct {
+	Doc      *CommentGroup // comment above the package clause, or nil
+	Package  Pos           // position of the "package" keyword
+	Name     *Ident        // package name
+	Decls    []Decl
+	Comments []*CommentGroup // every comment in the file, in source order
+}
+
+func (f *File) Pos() Pos { return f.Package }
+func (f *File) End() Pos {
+	if n := len(f.Decls); n > 0 {
+		return f.Decls[n-1].End()
+	}
+	return f.Name.End()
+}
+
+// Decl is a top-level declaration: *ImportDecl, *TypeDecl or *ConstDecl.
+type Decl interface {
+	Node
+	declNode()
+}
+
+// ImportDecl is an import declaration, either a single spec (import "x")
+// or a parenthesized group. Schemas cannot use other packages, so imports
+// are parsed and printed but otherwise ignored.
+type ImportDecl struct {
+	Doc    *CommentGroup // comment above the "import" keyword, or nil
+	Import Pos           // position of the "import" keyword
+	Lparen Pos           // position of "(", invalid for a single spec
+	Specs  []*ImportSpec
+	Rparen Pos // position of ")", invalid for a single spec
+}
+
+func (d *ImportDecl) Pos() Pos { return d.Import }
+func (d *ImportDecl) End() Pos {
+	if d.Rparen.IsValid() {
+		return advance(d.Rparen, ")")
+	}
+	return d.Specs[0].End()
+}
+
+// Grouped reports whether the declaration uses the parenthesized form.
+func (d *ImportDecl) Grouped() bool { return d.Lparen.IsValid() }
+
+// ImportSpec imports a single package.
+type ImportSpec struct {
+	Doc     *CommentGroup // comment above the spec, or nil
+	Name    *Ident        // local name, ".", "_" or nil
+	Path    *BasicLit     // import path
+	Comment *CommentGroup // comment after the spec on the same line, or nil
+}
+
+func (s *ImportSpec) Pos() Pos {
+	if s.Name != nil {
+		return s.Name.Pos()
+	}
+	return s.Path.Pos()
+}
+func (s *ImportSpec) End() Pos { return s.Path.End() }
+
+// TypeDecl is a type declaration, either a single spec (type A B) or a
+// parenthesized group (type ( A B; C D )).
+type TypeDecl struct {
+	Doc    *CommentGroup // comment above the "type" keyword, or nil
+	Type   Pos           // position of the "type" keyword
+	Lparen Pos           // position of "(", invalid for a single spec
+	Specs  []*TypeSpec
+	Rparen Pos // position of ")", invalid for a single spec
+}
+
+func (d *TypeDecl) Pos() Pos { return d.Type }
+func (d *TypeDecl) End() Pos {
+	if d.Rparen.IsValid() {
+		return advance(d.Rparen, ")")
+	}
+	return d.Specs[0].End()
+}
+
+// Grouped reports whether the declaration uses the parenthesized form.
+func (d *TypeDecl) Grouped() bool { return d.Lparen.IsValid() }
+
+// TypeSpec declares a single named type.
+type TypeSpec struct {
+	Doc        *CommentGroup // comment above the spec, or nil
+	Name       *Ident
+	TypeParams *TypeParamList // generic parameters, or nil
+	Assign     Pos            // position of "=" for an alias, invalid otherwise
+	Type       Expr
+	Comment    *CommentGroup // comment after the spec on the same line, or nil
+}
+
+func (s *TypeSpec) Pos() Pos { return s.Name.Pos() }
+func (s *TypeSpec) End() Pos { return s.Type.End() }
+
+// ConstDecl is a constant declaration, either a single spec (const A T = 1)
+// or a parenthesized group. Schemas use constants to list enum values.
+type ConstDecl struct {
+	Doc    *CommentGroup // comment above the "const" keyword, or nil
+	Const  Pos           // position of the "const" keyword
+	Lparen Pos           // position of "(", invalid for a single spec
+	Specs  []*ConstSpec
+	Rparen Pos // position of ")", invalid for a single spec
+}
+
+func (d *ConstDecl) Pos() Pos { return d.Const }
+func (d *ConstDecl) End() Pos {
+	if d.Rparen.IsValid() {
+		return advance(d.Rparen, ")")
+	}
+	return d.Specs[0].End()
+}
+
+// Grouped reports whether the declaration uses the parenthesized form.
+func (d *ConstDecl) Grouped() bool { return d.Lparen.IsValid() }
+
+// ConstSpec declares a single constant. As in Go, a spec in a group may
+// omit its type and value to repeat those of the spec before it.
+type ConstSpec struct {
+	Doc     *CommentGroup // comment above the spec, or nil
+	Name    *Ident
+	Type    Expr          // nil if omitted
+	Assign  Pos           // position of "=", invalid if the value is omitted
+	Value   Expr          // nil if omitted
+	Comment *CommentGroup // comment after the spec on the same line, or nil
+}
+
+func (s *ConstSpec) Pos() Pos { return s.Name.Pos() }
+func (s *ConstSpec) End() Pos {
+	switch {
+	case s.Value != nil:
+		return s.Value.End()
+	case s.Type != nil:
+		return s.Type.End()
+	}
+	return s.Name.End()
+}
+
+// TypeParamList is the bracketed parameter list of a generic type.
+type TypeParamList struct {
+	Lbrack Pos
+	List   []*TypeParam
+	Rbrack Pos
+}
+
+func (l *TypeParamList) Pos() Pos { return l.Lbrack }
+func (l *TypeParamList) End() Pos { return advance(l.Rbrack, "]") }
+
+// TypeParam is a group of type parameters sharing a constraint, such as
+// "K, V any".
+type TypeParam struct {
+	Names      []*Ident
+	Constraint Expr
+}
+
+func (p *TypeParam) Pos() Pos { return p.Names[0].Pos() }
+func (p *TypeParam) End() Pos { return p.Constraint.End() }
+
+// Ident is a type or field name.
+type Ident struct {
+	NamePos Pos
+	Name    string
+}
+
+func (x *Ident) Pos() Pos { return x.NamePos }
+func (x *Ident) End() Pos { return advance(x.NamePos, x.Name) }
+
+// BasicLit is an integer, float or string literal. Value is the literal
+// as written in the source, including quotes.
+type BasicLit struct {
+	ValuePos Pos
+	Kind     Token // INT, FLOAT or STRING
+	Value    string
+}
+
+func (x *BasicLit) Pos() Pos { return x.ValuePos }
+func (x *BasicLit) End() Pos { return advance(x.ValuePos, x.Value) }
+
+// UnaryExpr is a signed constant value: -1 or +1.
+type UnaryExpr struct {
+	OpPos Pos
+	Op    Token // ADD or SUB
+	X     Expr
+}
+
+func (x *UnaryExpr) Pos() Pos { return x.OpPos }
+func (x *UnaryExpr) End() Pos { return x.X.End() }
+
+// BinaryExpr is a constant sum or difference: iota + 1.
+type BinaryExpr struct {
+	X     Expr
+	OpPos Pos
+	Op    Token // ADD or SUB
+	Y     Expr
+}
+
+func (x *BinaryExpr) Pos() Pos { return x.X.Pos() }
+func (x *BinaryExpr) End() Pos { return x.Y.End() }
+
+// StarExpr is an optional type: *T.
+type StarExpr struct {
+	Star Pos
+	X    Expr
+}
+
+func (x *StarExpr) Pos() Pos { return x.Star }
+func (x *StarExpr) End() Pos { return x.X.End() }
+
+// ArrayType is a slice []T, or an array [Len]T when Len is set.
+type ArrayType struct {
+	Lbrack Pos
+	Len    Expr // nil for slices
+	Elt    Expr
+}
+
+func (x *ArrayType) Pos() Pos { return x.Lbrack }
+func (x *ArrayType) End() Pos { return x.Elt.End() }
+
+// MapType is a map type: map[Key]Value.
+type MapType struct {
+	Map   Pos // position of the "map" keyword
+	Key   Expr
+	Value Expr
+}
+
+func (x *MapType) Pos() Pos { return x.Map }
+func (x *MapType) End() Pos { return x.Value.End() }
+
+// IndexExpr is a generic instantiation: Page[Device] or Pair[K, V].
+type IndexExpr struct {
+	X       Expr
+	Lbrack  Pos
+	Indices []Expr
+	Rbrack  Pos
+}
+
+func (x *IndexExpr) Pos() Pos { return x.X.Pos() }
+func (x *IndexExpr) End() Pos { return advance(x.Rbrack, "]") }
+
+// SelectorExpr is a qualified name such as pkg.Type. Imports are
+// ignored, so it only appears to be reported as an error.
+type SelectorExpr struct {
+	X   Expr
+	Sel *Ident
+}
+
+func (x *SelectorExpr) Pos() Pos { return x.X.Pos() }
+func (x *SelectorExpr) End() Pos { return x.Sel.End() }
+
+// StructType is a struct type literal.
+type StructType struct {
+	Struct Pos
+	Lbrace Pos
+	Fields []*Field
+	Rbrace Pos
+}
+
+func (x *StructType) Pos() Pos { return x.Struct }
+func (x *StructType) End() Pos { return advance(x.Rbrace, "}") }
+
+// InterfaceType is a type-set interface: interface { A | B | C }. Schemas
+// use it to declare a tagged union whose variants are the listed types.
+type InterfaceType struct {
+	Interface Pos
+	Lbrace    Pos
+	Terms     []Expr // the union terms, in declaration order
+	Rbrace    Pos
+}
+
+func (x *InterfaceType) Pos() Pos { return x.Interface }
+func (x *InterfaceType) End() Pos { return advance(x.Rbrace, "}") }
+
+// Field is a struct field declaration. Names is empty for an embedded
+// field.
+type Field struct {
+	Doc     *CommentGroup // comment above the field, or nil
+	Names   []*Ident
+	Type    Expr
+	Tag     *BasicLit     // struct tag, or nil
+	Comment *CommentGroup // comment after the field on the same line, or nil
+}
+
+func (f *Field) Pos() Pos {
+	if len(f.Names) > 0 {
+		return f.Names[0].Pos()
+	}
+	return f.Type.Pos()
+}
+
+func (f *Field) End() Pos {
+	if f.Tag != nil {
+		return f.Tag.End()
+	}
+	return f.Type.End()
+}
+
+func (*Ident) exprNode()         {}
+func (*BasicLit) exprNode()      {}
+func (*UnaryExpr) exprNode()     {}
+func (*BinaryExpr) exprNode()    {}
+func (*StarExpr) exprNode()      {}
+func (*ArrayType) exprNode()     {}
+func (*MapType) exprNode()       {}
+func (*IndexExpr) exprNode()     {}
+func (*SelectorExpr) exprNode()  {}
+func (*StructType) exprNode()    {}
+func (*InterfaceType) exprNode() {}
+
+func (*ImportDecl) declNode() {}
+func (*TypeDecl) declNode()   {}
+func (*ConstDecl) declNode()  {}
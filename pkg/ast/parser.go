@@ -0,0 +1,542 @@
+package ast
+
+import "fmt"
+
+// Parse parses a schema file. The returned error is an *Error carrying the
+// position of the first syntax error.
+func Parse(src []byte) (*File, error) {
+	p := &parser{sc: NewScanner(src)}
+	file, err := p.parseFile()
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// bailout is panicked with to abort parsing at the first error.
+type bailout struct{ err *Error }
+
+type parser struct {
+	sc       *Scanner
+	comments []*CommentGroup
+
+	// Current token
+	pos     Pos
+	tok     Token
+	lit     string
+	endLine int // line on which the current token ends
+
+	leadComment *CommentGroup // comment group ending on the line before the current token
+	lineComment *CommentGroup // comment group on the line of the previous token
+}
+
+func (p *parser) error(pos Pos, format string, args ...any) {
+	panic(bailout{&Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}})
+}
+
+// next0 advances to the next token, including comments.
+func (p *parser) next0() {
+	p.pos, p.tok, p.lit = p.sc.Scan()
+	if err := p.sc.err; err != nil {
+		panic(bailout{err})
+	}
+	p.endLine = p.sc.Pos().Line
+	if p.tok == SEMICOLON && p.lit == "\n" {
+		p.endLine = p.pos.Line
+	}
+}
+
+// consumeCommentGroup collects comments separated by at most n line breaks.
+func (p *parser) consumeCommentGroup(n int) (*CommentGroup, int) {
+	var list []*Comment
+	endLine := p.pos.Line
+	for p.tok == COMMENT && p.pos.Line <= endLine+n {
+		list = append(list, &Comment{Slash: p.pos, Text: p.lit})
+		endLine = p.endLine
+		p.next0()
+	}
+	group := &CommentGroup{List: list}
+	p.comments = append(p.comments, group)
+	return group, endLine
+}
+
+// next advances to the next non-comment token, recording the comment groups
+// it passes as line or lead comments, following the same rules as go/parser.
+func (p *parser) next() {
+	p.leadComment = nil
+	p.lineComment = nil
+	prevLine := p.endLine
+	p.next0()
+
+	if p.tok != COMMENT {
+		return
+	}
+
+	var comment *CommentGroup
+	var endLine int
+
+	if p.pos.Line == prevLine && prevLine > 0 {
+		// The comment is on the same line as the previous token; it is a
+		// line comment unless another token follows on that line
+		comment, endLine = p.consumeCommentGroup(0)
+		if p.pos.Line != endLine || p.tok == EOF {
+			p.lineComment = comment
+		}
+	}
+
+	endLine = -1
+	for p.tok == COMMENT {
+		comment, endLine = p.consumeCommentGroup(1)
+	}
+	if endLine+1 == p.pos.Line {
+		// The last group ends right above the next token
+		p.leadComment = comment
+	}
+}
+
+func (p *parser) describe() string {
+	switch {
+	case p.tok == SEMICOLON && p.lit == "\n":
+		return "newline"
+	case p.tok == EOF:
+		return "EOF"
+	case p.tok == IDENT || p.tok == INT || p.tok == FLOAT || p.tok == STRING:
+		return p.lit
+	}
+	return "'" + p.tok.String() + "'"
+}
+
+func (p *parser) expect(tok Token) Pos {
+	pos := p.pos
+	if p.tok != tok {
+		p.error(pos, "expected '%s', found %s", tok, p.describe())
+	}
+	p.next()
+	return pos
+}
+
+// expectSemi consumes a statement terminator; it may be omitted before a
+// closing ")" or "}".
+func (p *parser) expectSemi() {
+	switch p.tok {
+	case RPAREN, RBRACE:
+	case SEMICOLON:
+		p.next()
+	case EOF:
+	default:
+		p.error(p.pos, "expected ';' or newline, found %s", p.describe())
+	}
+}
+
+func (p *parser) parseIdent() *Ident {
+	pos, name := p.pos, p.lit
+	if p.tok != IDENT {
+		p.error(pos, "expected identifier, found %s", p.describe())
+	}
+	p.next()
+	return &Ident{NamePos: pos, Name: name}
+}
+
+func (p *parser) parseFile() (file *File, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, ok := r.(bailout)
+			if !ok {
+				panic(r)
+			}
+			file, err = nil, b.err
+		}
+	}()
+
+	p.next()
+	file = &File{Doc: p.leadComment}
+	if p.tok != PACKAGE {
+		p.error(p.pos, "expected 'package', found %s", p.describe())
+	}
+	file.Package = p.pos
+	p.next()
+	file.Name = p.parseIdent()
+	p.expectSemi()
+
+	for p.tok == IMPORT {
+		file.Decls = append(file.Decls, p.parseImportDecl())
+	}
+	for p.tok != EOF {
+		switch p.tok {
+		case TYPE:
+			file.Decls = append(file.Decls, p.parseTypeDecl())
+		case CONST:
+			file.Decls = append(file.Decls, p.parseConstDecl())
+		case IMPORT:
+			p.error(p.pos, "imports must appear before other declarations")
+		default:
+			p.error(p.pos, "expected type or const declaration, found %s", p.describe())
+		}
+	}
+
+	file.Comments = p.comments
+	return file, nil
+}
+
+func (p *parser) parseImportDecl() *ImportDecl {
+	decl := &ImportDecl{Doc: p.leadComment}
+	decl.Import = p.expect(IMPORT)
+
+	if p.tok != LPAREN {
+		decl.Specs = []*ImportSpec{p.parseImportSpec(nil)}
+		return decl
+	}
+
+	decl.Lparen = p.pos
+	p.next()
+	for p.tok != RPAREN && p.tok != EOF {
+		decl.Specs = append(decl.Specs, p.parseImportSpec(p.leadComment))
+	}
+	decl.Rparen = p.expect(RPAREN)
+	p.expectSemi()
+	return decl
+}
+
+func (p *parser) parseImportSpec(doc *CommentGroup) *ImportSpec {
+	spec := &ImportSpec{Doc: doc}
+	switch p.tok {
+	case IDENT:
+		spec.Name = p.parseIdent()
+	case PERIOD:
+		spec.Name = &Ident{NamePos: p.pos, Name: "."}
+		p.next()
+	}
+	if p.tok != STRING {
+		p.error(p.pos, "expected import path, found %s", p.describe())
+	}
+	spec.Path = &BasicLit{ValuePos: p.pos, Kind: STRING, Value: p.lit}
+	p.next()
+
+	p.expectSemi()
+	spec.Comment = p.lineComment
+	return spec
+}
+
+func (p *parser) parseTypeDecl() *TypeDecl {
+	decl := &TypeDecl{Doc: p.leadComment}
+	decl.Type = p.expect(TYPE)
+
+	if p.tok != LPAREN {
+		decl.Specs = []*TypeSpec{p.parseTypeSpec(nil)}
+		return decl
+	}
+
+	decl.Lparen = p.pos
+	p.next()
+	for p.tok != RPAREN && p.tok != EOF {
+		decl.Specs = append(decl.Specs, p.parseTypeSpec(p.leadComment))
+	}
+	decl.Rparen = p.expect(RPAREN)
+	p.expectSemi()
+	return decl
+}
+
+func (p *parser) parseTypeSpec(doc *CommentGroup) *TypeSpec {
+	spec := &TypeSpec{Doc: doc}
+	spec.Name = p.parseIdent()
+
+	if p.tok == LBRACK && p.isTypeParamList() {
+		spec.TypeParams = p.parseTypeParams()
+	}
+	if p.tok == ASSIGN {
+		spec.Assign = p.pos
+		p.next()
+	}
+	spec.Type = p.parseType()
+
+	p.expectSemi()
+	spec.Comment = p.lineComment
+	return spec
+}
+
+func (p *parser) parseConstDecl() *ConstDecl {
+	decl := &ConstDecl{Doc: p.leadComment}
+	decl.Const = p.expect(CONST)
+
+	if p.tok != LPAREN {
+		decl.Specs = []*ConstSpec{p.parseConstSpec(nil)}
+		return decl
+	}
+
+	decl.Lparen = p.pos
+	p.next()
+	for p.tok != RPAREN && p.tok != EOF {
+		decl.Specs = append(decl.Specs, p.parseConstSpec(p.leadComment))
+	}
+	decl.Rparen = p.expect(RPAREN)
+	p.expectSemi()
+	return decl
+}
+
+func (p *parser) parseConstSpec(doc *CommentGroup) *ConstSpec {
+	spec := &ConstSpec{Doc: doc}
+	spec.Name = p.parseIdent()
+	if p.tok == COMMA {
+		p.error(p.pos, "declare one constant per line")
+	}
+
+	if p.tok != ASSIGN && p.tok != SEMICOLON && p.tok != RPAREN && p.tok != EOF {
+		spec.Type = p.parseType()
+	}
+	if p.tok == ASSIGN {
+		spec.Assign = p.pos
+		p.next()
+		spec.Value = p.parseConstExpr()
+	}
+
+	p.expectSemi()
+	spec.Comment = p.lineComment
+	return spec
+}
+
+// parseConstExpr parses a constant value: integers and iota, combined with
+// + and -.
+func (p *parser) parseConstExpr() Expr {
+	x := p.parseConstOperand()
+	for p.tok == ADD || p.tok == SUB {
+		bin := &BinaryExpr{X: x, OpPos: p.pos, Op: p.tok}
+		p.next()
+		bin.Y = p.parseConstOperand()
+		x = bin
+	}
+	return x
+}
+
+func (p *parser) parseConstOperand() Expr {
+	switch p.tok {
+	case ADD, SUB:
+		un := &UnaryExpr{OpPos: p.pos, Op: p.tok}
+		p.next()
+		un.X = p.parseConstOperand()
+		return un
+	case INT:
+		lit := &BasicLit{ValuePos: p.pos, Kind: INT, Value: p.lit}
+		p.next()
+		return lit
+	case IDENT:
+		return p.parseIdent()
+	}
+	p.error(p.pos, "expected constant value, found %s", p.describe())
+	return nil
+}
+
+// isTypeParamList reports whether the "[" at the current position opens a
+// type parameter list ("type Page[T any]") rather than an array type
+// ("type Row [N]int32" or "type Row []int32").
+func (p *parser) isTypeParamList() bool {
+	sc := *p.sc
+	first := p.scanAhead(&sc)
+	if first != IDENT {
+		return false
+	}
+	return p.scanAhead(&sc) != RBRACK
+}
+
+// scanAhead returns the next non-comment token from a copy of the scanner.
+func (p *parser) scanAhead(sc *Scanner) Token {
+	for {
+		_, tok, _ := sc.Scan()
+		if tok != COMMENT {
+			return tok
+		}
+	}
+}
+
+func (p *parser) parseTypeParams() *TypeParamList {
+	list := &TypeParamList{Lbrack: p.expect(LBRACK)}
+	for {
+		param := &TypeParam{Names: []*Ident{p.parseIdent()}}
+		for p.tok == COMMA {
+			p.next()
+			param.Names = append(param.Names, p.parseIdent())
+		}
+		param.Constraint = p.parseType()
+		if p.tok == TILDE || p.tok == PIPE {
+			p.error(p.pos, "unsupported type constraint: only 'any' is allowed")
+		}
+		list.List = append(list.List, param)
+
+		if p.tok != COMMA {
+			break
+		}
+		p.next()
+		if p.tok == RBRACK {
+			break
+		}
+	}
+	list.Rbrack = p.expect(RBRACK)
+	return list
+}
+
+func (p *parser) parseType() Expr {
+	switch p.tok {
+	case IDENT:
+		var x Expr = p.parseIdent()
+		if p.tok == PERIOD {
+			p.next()
+			x = &SelectorExpr{X: x, Sel: p.parseIdent()}
+		}
+		if p.tok == LBRACK {
+			return p.parseIndex(x)
+		}
+		return x
+
+	case STAR:
+		star := p.pos
+		p.next()
+		return &StarExpr{Star: star, X: p.parseType()}
+
+	case LBRACK:
+		arr := &ArrayType{Lbrack: p.pos}
+		p.next()
+		if p.tok != RBRACK {
+			switch p.tok {
+			case INT:
+				arr.Len = &BasicLit{ValuePos: p.pos, Kind: INT, Value: p.lit}
+				p.next()
+			case IDENT:
+				arr.Len = p.parseIdent()
+			default:
+				p.error(p.pos, "expected array length, found %s", p.describe())
+			}
+		}
+		p.expect(RBRACK)
+		arr.Elt = p.parseType()
+		return arr
+
+	case STRUCT:
+		return p.parseStructType()
+
+	case MAP:
+		m := &MapType{Map: p.pos}
+		p.next()
+		p.expect(LBRACK)
+		m.Key = p.parseType()
+		p.expect(RBRACK)
+		m.Value = p.parseType()
+		return m
+
+	case INTERFACE:
+		return p.parseInterfaceType()
+
+	case CHAN, FUNC:
+		p.error(p.pos, "unsupported type: %s", p.tok)
+	}
+
+	p.error(p.pos, "expected type, found %s", p.describe())
+	return nil
+}
+
+func (p *parser) parseIndex(x Expr) Expr {
+	index := &IndexExpr{X: x, Lbrack: p.expect(LBRACK)}
+	for {
+		index.Indices = append(index.Indices, p.parseType())
+		if p.tok != COMMA {
+			break
+		}
+		p.next()
+		if p.tok == RBRACK {
+			break
+		}
+	}
+	index.Rbrack = p.expect(RBRACK)
+	return index
+}
+
+func (p *parser) parseStructType() *StructType {
+	st := &StructType{Struct: p.expect(STRUCT)}
+	st.Lbrace = p.pos
+	if p.tok != LBRACE {
+		p.error(p.pos, "expected '{', found %s", p.describe())
+	}
+	p.next()
+	for p.tok != RBRACE && p.tok != EOF {
+		st.Fields = append(st.Fields, p.parseField())
+	}
+	st.Rbrace = p.pos
+	if p.tok != RBRACE {
+		p.error(p.pos, "expected '}', found %s", p.describe())
+	}
+	p.next()
+	return st
+}
+
+// parseInterfaceType parses a type-set interface. Only a single union of
+// types is accepted: methods, approximation terms (~T) and intersections of
+// several lines have no wire representation.
+func (p *parser) parseInterfaceType() *InterfaceType {
+	it := &InterfaceType{Interface: p.expect(INTERFACE)}
+	it.Lbrace = p.pos
+	if p.tok != LBRACE {
+		p.error(p.pos, "expected '{', found %s", p.describe())
+	}
+	p.next()
+	if p.tok != RBRACE && p.tok != EOF {
+		for {
+			if p.tok == TILDE {
+				p.error(p.pos, "unsupported union term: approximation (~T) is not allowed")
+			}
+			it.Terms = append(it.Terms, p.parseType())
+			if p.tok == LPAREN {
+				p.error(p.pos, "unsupported interface: methods are not allowed")
+			}
+			if p.tok != PIPE {
+				break
+			}
+			p.next()
+		}
+		p.expectSemi()
+		if p.tok != RBRACE && p.tok != EOF {
+			p.error(p.pos, "interface must contain a single union; join the types with '|'")
+		}
+	}
+	it.Rbrace = p.pos
+	if p.tok != RBRACE {
+		p.error(p.pos, "expected '}', found %s", p.describe())
+	}
+	p.next()
+	return it
+}
+
+func (p *parser) parseField() *Field {
+	field := &Field{Doc: p.leadComment}
+
+	switch p.tok {
+	case IDENT:
+		name := p.parseIdent()
+		switch p.tok {
+		case STRING, SEMICOLON, RBRACE:
+			// Embedded type: "Base" or "Base `tag`"
+			field.Type = name
+		case PERIOD:
+			p.next()
+			field.Type = &SelectorExpr{X: name, Sel: p.parseIdent()}
+		default:
+			field.Names = []*Ident{name}
+			for p.tok == COMMA {
+				p.next()
+				field.Names = append(field.Names, p.parseIdent())
+			}
+			field.Type = p.parseType()
+		}
+	case STAR:
+		// Embedded pointer: "*Base"
+		field.Type = p.parseType()
+	default:
+		p.error(p.pos, "expected field name, found %s", p.describe())
+	}
+
+	if p.tok == STRING {
+		field.Tag = &BasicLit{ValuePos: p.pos, Kind: STRING, Value: p.lit}
+		p.next()
+	}
+
+	p.expectSemi()
+	field.Comment = p.lineComment
+	return field
+}
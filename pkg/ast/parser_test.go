@@ -0,0 +1,356 @@
+package ast
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustParse(t *testing.T, src string) *File {
+	t.Helper()
+	file, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return file
+}
+
+func TestParseComments(t *testing.T) {
+	file := mustParse(t, `// Package audio is a doc comment.
+package audio
+
+// Device describes an
+// audio device.
+type Device struct {
+	// ID is unique.
+	ID   int32  `+"`json:\"id\"`"+` // line comment
+	Name string
+}
+
+/* floating */
+
+type Level int32 // level
+`)
+
+	if got := file.Doc.Text(); got != "Package audio is a doc comment.\n" {
+		t.Errorf("File.Doc = %q", got)
+	}
+	if len(file.Decls) != 2 {
+		t.Fatalf("len(Decls) = %d, want 2", len(file.Decls))
+	}
+
+	device := file.Decls[0].(*TypeDecl)
+	if got := device.Doc.Text(); got != "Device describes an\naudio device.\n" {
+		t.Errorf("Device doc = %q", got)
+	}
+	st, ok := device.Specs[0].Type.(*StructType)
+	if !ok {
+		t.Fatalf("Device type = %T, want *StructType", device.Specs[0].Type)
+	}
+	id := st.Fields[0]
+	if got := id.Doc.Text(); got != "ID is unique.\n" {
+		t.Errorf("ID doc = %q", got)
+	}
+	if got := id.Comment.Text(); got != "line comment\n" {
+		t.Errorf("ID comment = %q", got)
+	}
+	if id.Tag == nil || id.Tag.Value != "`json:\"id\"`" {
+		t.Errorf("ID tag = %+v", id.Tag)
+	}
+	if st.Fields[1].Doc != nil || st.Fields[1].Comment != nil {
+		t.Errorf("Name has unexpected comments")
+	}
+
+	level := file.Decls[1].(*TypeDecl)
+	if level.Doc != nil {
+		t.Errorf("Level doc = %q, want none (separated by a blank line)", level.Doc.Text())
+	}
+	if got := level.Specs[0].Comment.Text(); got != "level\n" {
+		t.Errorf("Level comment = %q", got)
+	}
+
+	// Every comment is kept, including the floating one
+	if len(file.Comments) != 6 {
+		t.Errorf("len(Comments) = %d, want 6", len(file.Comments))
+	}
+}
+
+func TestParseGroupedDecl(t *testing.T) {
+	file := mustParse(t, `package p
+
+type (
+	// A doc
+	A int32
+	B = A // alias
+)
+`)
+	decl := file.Decls[0].(*TypeDecl)
+	if !decl.Grouped() {
+		t.Fatal("expected grouped declaration")
+	}
+	if len(decl.Specs) != 2 {
+		t.Fatalf("len(Specs) = %d, want 2", len(decl.Specs))
+	}
+	if got := decl.Specs[0].Doc.Text(); got != "A doc\n" {
+		t.Errorf("A doc = %q", got)
+	}
+	b := decl.Specs[1]
+	if !b.Assign.IsValid() {
+		t.Error("B should be an alias")
+	}
+	if got := b.Comment.Text(); got != "alias\n" {
+		t.Errorf("B comment = %q", got)
+	}
+}
+
+func TestParseTypeParamsAndArrays(t *testing.T) {
+	file := mustParse(t, `package p
+
+type Page[T any] struct {
+	Items []T
+}
+type Pair[K, V any] struct {
+	Key K
+	Val V
+}
+type Row [4]int32
+type List []int32
+type DevicePage Page[Device]
+type Both Pair[int32, []string]
+type Gains map[string]*float32
+`)
+	specs := make(map[string]*TypeSpec)
+	for _, d := range file.Decls {
+		spec := d.(*TypeDecl).Specs[0]
+		specs[spec.Name.Name] = spec
+	}
+
+	if tp := specs["Page"].TypeParams; tp == nil || tp.List[0].Names[0].Name != "T" {
+		t.Errorf("Page type params = %+v", tp)
+	}
+	if tp := specs["Pair"].TypeParams; tp == nil || len(tp.List[0].Names) != 2 {
+		t.Errorf("Pair type params = %+v", tp)
+	}
+
+	row, ok := specs["Row"].Type.(*ArrayType)
+	if specs["Row"].TypeParams != nil || !ok {
+		t.Fatalf("Row should be an array type, got %T", specs["Row"].Type)
+	}
+	if lit, ok := row.Len.(*BasicLit); !ok || lit.Value != "4" {
+		t.Errorf("Row length = %+v", row.Len)
+	}
+	if list, ok := specs["List"].Type.(*ArrayType); !ok || list.Len != nil {
+		t.Errorf("List should be a slice, got %+v", specs["List"].Type)
+	}
+
+	idx, ok := specs["Both"].Type.(*IndexExpr)
+	if !ok || len(idx.Indices) != 2 {
+		t.Fatalf("Both type = %+v, want IndexExpr with 2 indices", specs["Both"].Type)
+	}
+	if _, ok := idx.Indices[1].(*ArrayType); !ok {
+		t.Errorf("second index = %T, want *ArrayType", idx.Indices[1])
+	}
+
+	m, ok := specs["Gains"].Type.(*MapType)
+	if !ok {
+		t.Fatalf("Gains type = %T, want *MapType", specs["Gains"].Type)
+	}
+	if key, ok := m.Key.(*Ident); !ok || key.Name != "string" {
+		t.Errorf("Gains key = %+v", m.Key)
+	}
+	if _, ok := m.Value.(*StarExpr); !ok {
+		t.Errorf("Gains value = %T, want *StarExpr", m.Value)
+	}
+}
+
+func TestParseConstDecl(t *testing.T) {
+	file := mustParse(t, `package p
+
+type Mode int8
+
+// Modes
+const (
+	Off Mode = -1 // off
+	On  Mode = iota + 1
+	Auto
+)
+const Max Mode = 0x10
+`)
+	if len(file.Decls) != 3 {
+		t.Fatalf("len(Decls) = %d, want 3", len(file.Decls))
+	}
+	group, ok := file.Decls[1].(*ConstDecl)
+	if !ok || !group.Grouped() || len(group.Specs) != 3 {
+		t.Fatalf("Decls[1] = %+v, want a group of 3 constants", file.Decls[1])
+	}
+	if got := group.Doc.Text(); got != "Modes\n" {
+		t.Errorf("group doc = %q", got)
+	}
+
+	off := group.Specs[0]
+	if un, ok := off.Value.(*UnaryExpr); !ok || un.Op != SUB {
+		t.Errorf("Off value = %+v, want -1", off.Value)
+	}
+	if got := off.Comment.Text(); got != "off\n" {
+		t.Errorf("Off comment = %q", got)
+	}
+	on := group.Specs[1]
+	bin, ok := on.Value.(*BinaryExpr)
+	if !ok || bin.Op != ADD || bin.X.(*Ident).Name != "iota" {
+		t.Errorf("On value = %+v, want iota + 1", on.Value)
+	}
+	if auto := group.Specs[2]; auto.Type != nil || auto.Value != nil || auto.Assign.IsValid() {
+		t.Errorf("Auto = %+v, want type and value omitted", auto)
+	}
+
+	single := file.Decls[2].(*ConstDecl)
+	if single.Grouped() || single.Specs[0].Type.(*Ident).Name != "Mode" {
+		t.Errorf("Max = %+v", single.Specs[0])
+	}
+}
+
+func TestParseImportDecl(t *testing.T) {
+	file := mustParse(t, `package p
+
+import "time" // t
+
+// Deps
+import (
+	str "strings"
+	. "os"
+)
+
+type A int32
+`)
+	if len(file.Decls) != 3 {
+		t.Fatalf("len(Decls) = %d, want 3", len(file.Decls))
+	}
+	single := file.Decls[0].(*ImportDecl)
+	if single.Grouped() || single.Specs[0].Name != nil || single.Specs[0].Path.Value != `"time"` {
+		t.Errorf("single import = %+v", single.Specs[0])
+	}
+	if got := single.Specs[0].Comment.Text(); got != "t\n" {
+		t.Errorf("single import comment = %q", got)
+	}
+
+	group, ok := file.Decls[1].(*ImportDecl)
+	if !ok || !group.Grouped() || len(group.Specs) != 2 {
+		t.Fatalf("Decls[1] = %+v, want a group of 2 imports", file.Decls[1])
+	}
+	if got := group.Doc.Text(); got != "Deps\n" {
+		t.Errorf("group doc = %q", got)
+	}
+	if s := group.Specs[0]; s.Name.Name != "str" || s.Path.Value != `"strings"` {
+		t.Errorf("Specs[0] = %+v", s)
+	}
+	if s := group.Specs[1]; s.Name.Name != "." || s.Pos().Column != 2 {
+		t.Errorf("Specs[1] = %+v", s)
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	src := "package p\n\ntype Device struct {\n\tName *string\n}\n"
+	file := mustParse(t, src)
+	spec := file.Decls[0].(*TypeDecl).Specs[0]
+
+	if got := spec.Name.Pos().String(); got != "3:6" {
+		t.Errorf("Name pos = %s, want 3:6", got)
+	}
+	field := spec.Type.(*StructType).Fields[0]
+	if got := field.Type.Pos().String(); got != "4:7" {
+		t.Errorf("field type pos = %s, want 4:7", got)
+	}
+	end := spec.Type.End()
+	if got := end.String(); got != "5:2" {
+		t.Errorf("struct end = %s, want 5:2", got)
+	}
+	if src[spec.Pos().Offset:end.Offset] != "Device struct {\n\tName *string\n}" {
+		t.Errorf("spec source = %q", src[spec.Pos().Offset:end.Offset])
+	}
+}
+
+func TestParseEmbeddedField(t *testing.T) {
+	file := mustParse(t, "package p\ntype A struct {\n\tBase\n\t*Other\n}\n")
+	fields := file.Decls[0].(*TypeDecl).Specs[0].Type.(*StructType).Fields
+	if len(fields) != 2 || len(fields[0].Names) != 0 || len(fields[1].Names) != 0 {
+		t.Fatalf("expected two embedded fields, got %+v", fields)
+	}
+}
+
+func TestParseInterfaceType(t *testing.T) {
+	file := mustParse(t, "package p\ntype Shape interface {\n\tCircle |\n\t\tSquare | string\n}\ntype Empty interface{}\n")
+	it, ok := file.Decls[0].(*TypeDecl).Specs[0].Type.(*InterfaceType)
+	if !ok {
+		t.Fatalf("Shape type = %T, want *InterfaceType", file.Decls[0].(*TypeDecl).Specs[0].Type)
+	}
+	var names []string
+	for _, x := range it.Terms {
+		names = append(names, x.(*Ident).Name)
+	}
+	if got := strings.Join(names, " "); got != "Circle Square string" {
+		t.Errorf("Shape terms = %q", got)
+	}
+	if it.Pos().Line != 2 || it.End().Line != 5 {
+		t.Errorf("Shape spans lines %d-%d, want 2-5", it.Pos().Line, it.End().Line)
+	}
+	if empty := file.Decls[1].(*TypeDecl).Specs[0].Type.(*InterfaceType); len(empty.Terms) != 0 {
+		t.Errorf("Empty terms = %d, want 0", len(empty.Terms))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"type A int32", "1:1: expected 'package', found 'type'"},
+		{"package p\ntype A int32\nimport \"fmt\"", "3:1: imports must appear before other declarations"},
+		{"package p\nimport fmt", "2:11: expected import path, found newline"},
+		{"package p\n;", "2:1: expected type or const declaration, found ';'"},
+		{"package p\nconst A, B T = 1", "2:8: declare one constant per line"},
+		{"package p\nconst A T = *", "2:13: expected constant value, found '*'"},
+		{"package p\ntype A chan int32", "2:8: unsupported type: chan"},
+		{"package p\ntype A map[string", "2:18: expected ']', found newline"},
+		{"package p\ntype A struct {\n\tX\tint32", "3:9: expected '}', found EOF"},
+		{"package p\ntype A[T comparable | any] struct{}", "2:21: unsupported type constraint: only 'any' is allowed"},
+		{"package p\ntype A struct { X int32 Y int32 }", "2:25: expected ';' or newline, found Y"},
+		{"package p\ntype A interface { ~int32 | string }", "2:20: unsupported union term: approximation (~T) is not allowed"},
+		{"package p\ntype A interface { String() string }", "2:26: unsupported interface: methods are not allowed"},
+		{"package p\ntype A interface {\n\tB | C\n\tD\n}", "4:2: interface must contain a single union; join the types with '|'"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.src))
+		if err == nil {
+			t.Errorf("Parse(%q): expected error %q", tt.src, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestParseTestdataSchemas(t *testing.T) {
+	paths, err := filepath.Glob("../../testdata/schema/*.ffi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Skip("no testdata schemas found")
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := Parse(src)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.Base(path), err)
+			continue
+		}
+		if !strings.HasPrefix(string(src[file.Package.Offset:]), "package") {
+			t.Errorf("%s: package position %s is wrong", filepath.Base(path), file.Package)
+		}
+	}
+}
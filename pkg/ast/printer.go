@@ -0,0 +1,733 @@
+package ast
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+)
+
+// Fprint writes file to w in canonical form. For parsed files the output
+// matches gofmt: fields, specs and trailing comments are aligned in
+// columns, and up to one blank line between declarations or fields is kept.
+// Nodes built or moved by a codemod may have invalid positions; they are
+// laid out with the default spacing instead.
+//
+// Comments attached to nodes (Doc and Comment fields) move with their node.
+// Other comments in File.Comments are placed by source position, between
+// the declarations, specs or fields they were written between; a comment
+// inside a type expression is moved after it.
+func Fprint(w io.Writer, file *File) error {
+	p := newPrinter(file)
+	p.file(file)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', tabwriter.DiscardEmptyColumns|tabwriter.StripEscape)
+	if _, err := tw.Write(p.buf.Bytes()); err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+// Format parses src and returns it in canonical form.
+func Format(src []byte) ([]byte, error) {
+	file, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maxOneLineStruct is the longest field that keeps a single-field struct
+// such as struct{ X int32 } on one line (the same limit gofmt uses).
+const maxOneLineStruct = 30
+
+// printer renders a file into tabwriter input: '\v' separates aligned
+// cells, '\f' ends a line and an alignment section, and text that must not
+// be split into cells (indentation, comments, tags) is escaped.
+type printer struct {
+	buf       bytes.Buffer
+	indent    int
+	lineStart bool // indentation is pending for the current line
+	lines     int  // number of line breaks written
+	lastLine  int  // source line of the last printed element, 0 if unknown
+	floating  []*CommentGroup
+}
+
+func newPrinter(file *File) *printer {
+	attached := make(map[*CommentGroup]bool)
+	Inspect(file, func(n Node) bool {
+		if g, ok := n.(*CommentGroup); ok {
+			attached[g] = true
+			return false
+		}
+		return true
+	})
+	p := &printer{}
+	for _, g := range file.Comments {
+		if !attached[g] && len(g.List) > 0 {
+			p.floating = append(p.floating, g)
+		}
+	}
+	return p
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart {
+		p.lineStart = false
+		if p.indent > 0 {
+			p.escaped(strings.Repeat("\t", p.indent))
+		}
+	}
+	p.buf.WriteString(s)
+}
+
+func (p *printer) escaped(s string) {
+	p.buf.WriteByte(tabwriter.Escape)
+	p.buf.WriteString(s)
+	p.buf.WriteByte(tabwriter.Escape)
+}
+
+// text writes s as a single cell fragment, protecting any tabs in it.
+func (p *printer) text(s string) {
+	if strings.ContainsAny(s, "\t\v") {
+		p.write("")
+		p.escaped(s)
+		return
+	}
+	p.write(s)
+}
+
+// sep ends the current cell. A vertical tab, unlike '\t', lets the
+// tabwriter discard a column whose cells are all empty.
+func (p *printer) sep() { p.buf.WriteByte('\v') }
+
+// newline ends the current line; a formfeed also ends the alignment section.
+func (p *printer) newline(formfeed bool) {
+	if formfeed {
+		p.buf.WriteByte('\f')
+	} else {
+		p.buf.WriteByte('\n')
+	}
+	p.lineStart = true
+	p.lines++
+}
+
+// linebreak starts the element at source line line, keeping one blank
+// line if the source had any and at least min-1 blank lines otherwise.
+func (p *printer) linebreak(line, min int, formfeed bool) {
+	if p.buf.Len() == 0 {
+		return
+	}
+	n := min
+	if line > 0 && p.lastLine > 0 && line-p.lastLine > n {
+		n = line - p.lastLine
+	}
+	if n > 2 {
+		n = 2
+	}
+	for i := 0; i < n; i++ {
+		p.newline(formfeed || i > 0)
+	}
+}
+
+// setLine records the source line of the element just printed.
+func (p *printer) setLine(pos Pos) {
+	if pos.IsValid() {
+		p.lastLine = pos.Line
+	}
+}
+
+// startLine returns the line on which a node with the given doc comment
+// starts, or 0 if it has no position.
+func startLine(doc *CommentGroup, n Node) int {
+	if doc != nil && len(doc.List) > 0 && doc.Pos().IsValid() {
+		return doc.Pos().Line
+	}
+	return n.Pos().Line
+}
+
+// flushComments prints the floating comments that start before pos. An
+// invalid pos prints them all.
+func (p *printer) flushComments(pos Pos) {
+	for len(p.floating) > 0 {
+		g := p.floating[0]
+		if pos.IsValid() && g.Pos().Offset >= pos.Offset {
+			return
+		}
+		p.floating = p.floating[1:]
+		if p.buf.Len() > 0 && g.Pos().Line == p.lastLine {
+			p.sep()
+		} else {
+			p.linebreak(g.Pos().Line, 1, false)
+		}
+		p.commentGroup(g)
+		p.setLine(g.End())
+	}
+}
+
+// commentGroup prints the comments of g, one per line.
+func (p *printer) commentGroup(g *CommentGroup) {
+	for i, c := range g.List {
+		if i > 0 {
+			p.newline(false)
+		}
+		p.comment(c)
+	}
+}
+
+// lineComment prints a trailing comment group on the current line.
+func (p *printer) lineComment(g *CommentGroup) {
+	for i, c := range g.List {
+		if i > 0 {
+			p.write(" ")
+		}
+		p.comment(c)
+	}
+	p.setLine(g.End())
+}
+
+func (p *printer) comment(c *Comment) {
+	lines := strings.Split(c.Text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if i > 0 {
+			// Continuation lines of a /* */ comment keep their own indentation
+			p.newline(false)
+			p.lineStart = false
+		}
+		p.write("")
+		p.escaped(line)
+	}
+}
+
+func (p *printer) file(f *File) {
+	p.flushComments(f.Package)
+	if f.Doc != nil {
+		p.linebreak(startLine(f.Doc, f), 1, false)
+		p.commentGroup(f.Doc)
+		p.newline(false)
+	} else {
+		p.linebreak(f.Package.Line, 1, false)
+	}
+	p.write("package ")
+	p.write(f.Name.Name)
+	p.setLine(f.Name.Pos())
+
+	for i, d := range f.Decls {
+		doc := declDoc(d)
+		p.flushComments(declStart(d))
+		line := startLine(doc, d)
+		min := 1
+		if i == 0 || doc != nil || line == 0 || !sameKind(d, f.Decls[i-1]) {
+			min = 2
+		}
+		p.linebreak(line, min, false)
+		switch d := d.(type) {
+		case *ImportDecl:
+			p.importDecl(d)
+		case *TypeDecl:
+			p.typeDecl(d)
+		case *ConstDecl:
+			p.constDecl(d)
+		}
+	}
+	p.flushComments(Pos{})
+	p.newline(false)
+}
+
+// sameKind reports whether a and b are both import, type or const
+// declarations; gofmt separates declarations of different kinds.
+func sameKind(a, b Decl) bool {
+	switch a.(type) {
+	case *ImportDecl:
+		_, ok := b.(*ImportDecl)
+		return ok
+	case *TypeDecl:
+		_, ok := b.(*TypeDecl)
+		return ok
+	}
+	_, ok := b.(*ConstDecl)
+	return ok
+}
+
+func declDoc(d Decl) *CommentGroup {
+	switch d := d.(type) {
+	case *ImportDecl:
+		return d.Doc
+	case *TypeDecl:
+		return d.Doc
+	case *ConstDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+func declStart(d Decl) Pos {
+	if doc := declDoc(d); doc != nil && len(doc.List) > 0 {
+		return doc.Pos()
+	}
+	return d.Pos()
+}
+
+func (p *printer) importDecl(d *ImportDecl) {
+	if d.Doc != nil {
+		p.commentGroup(d.Doc)
+		p.newline(false)
+	}
+	p.write("import ")
+	p.setLine(d.Import)
+
+	if !d.Grouped() {
+		if len(d.Specs) > 0 {
+			p.importSpec(d.Specs[0], false)
+		}
+		return
+	}
+
+	p.write("(")
+	p.setLine(d.Lparen)
+	if len(d.Specs) == 0 && !p.hasFloating(d.Rparen) {
+		p.write(")")
+		p.setLine(d.Rparen)
+		return
+	}
+
+	p.indent++
+	for _, s := range d.Specs {
+		start := s.Pos()
+		if s.Doc != nil && len(s.Doc.List) > 0 {
+			start = s.Doc.Pos()
+		}
+		p.flushComments(start)
+		p.linebreak(startLine(s.Doc, s), 1, false)
+		if s.Doc != nil {
+			p.commentGroup(s.Doc)
+			p.newline(false)
+		}
+		p.importSpec(s, true)
+	}
+	p.flushComments(d.Rparen)
+	p.indent--
+	p.newline(true)
+	p.write(")")
+	p.setLine(d.Rparen)
+}
+
+// importSpec prints an import. gofmt does not align import paths, only the
+// comments after them.
+func (p *printer) importSpec(s *ImportSpec, grouped bool) {
+	if s.Name != nil {
+		p.write(s.Name.Name + " ")
+	}
+	p.write(s.Path.Value)
+	p.setLine(s.Path.End())
+	if s.Comment != nil {
+		if grouped {
+			p.sep()
+		} else {
+			p.write(" ")
+		}
+		p.lineComment(s.Comment)
+	}
+}
+
+func (p *printer) typeDecl(d *TypeDecl) {
+	if d.Doc != nil {
+		p.commentGroup(d.Doc)
+		p.newline(false)
+	}
+	p.write("type ")
+	p.setLine(d.Type)
+
+	if !d.Grouped() {
+		if len(d.Specs) > 0 {
+			p.typeSpec(d.Specs[0], false)
+		}
+		return
+	}
+
+	p.write("(")
+	p.setLine(d.Lparen)
+	if len(d.Specs) == 0 && !p.hasFloating(d.Rparen) {
+		p.write(")")
+		p.setLine(d.Rparen)
+		return
+	}
+
+	p.indent++
+	multiline := true // the first spec always starts a new section
+	for _, s := range d.Specs {
+		start := s.Pos()
+		if s.Doc != nil && len(s.Doc.List) > 0 {
+			start = s.Doc.Pos()
+		}
+		p.flushComments(start)
+		p.linebreak(startLine(s.Doc, s), 1, multiline)
+		if s.Doc != nil {
+			p.commentGroup(s.Doc)
+			p.newline(false)
+		}
+		before := p.lines
+		p.typeSpec(s, true)
+		multiline = p.lines > before
+	}
+	p.flushComments(d.Rparen)
+	p.indent--
+	p.newline(true)
+	p.write(")")
+	p.setLine(d.Rparen)
+}
+
+func (p *printer) constDecl(d *ConstDecl) {
+	if d.Doc != nil {
+		p.commentGroup(d.Doc)
+		p.newline(false)
+	}
+	p.write("const ")
+	p.setLine(d.Const)
+
+	if !d.Grouped() {
+		if len(d.Specs) > 0 {
+			p.constSpec(d.Specs[0], false)
+		}
+		return
+	}
+
+	p.write("(")
+	p.setLine(d.Lparen)
+	if len(d.Specs) == 0 && !p.hasFloating(d.Rparen) {
+		p.write(")")
+		p.setLine(d.Rparen)
+		return
+	}
+
+	p.indent++
+	for _, s := range d.Specs {
+		start := s.Pos()
+		if s.Doc != nil && len(s.Doc.List) > 0 {
+			start = s.Doc.Pos()
+		}
+		p.flushComments(start)
+		p.linebreak(startLine(s.Doc, s), 1, false)
+		if s.Doc != nil {
+			p.commentGroup(s.Doc)
+			p.newline(false)
+		}
+		p.constSpec(s, true)
+	}
+	p.flushComments(d.Rparen)
+	p.indent--
+	p.newline(true)
+	p.write(")")
+	p.setLine(d.Rparen)
+}
+
+// constSpec prints a constant; in a group its type, value and comment are
+// aligned in columns, as gofmt does.
+func (p *printer) constSpec(s *ConstSpec, grouped bool) {
+	p.write(s.Name.Name)
+	p.setLine(s.Name.Pos())
+	cells := 0 // cells ended so far, to keep the comment column aligned
+	if s.Type != nil {
+		if grouped {
+			p.sep()
+		} else {
+			p.write(" ")
+		}
+		cells++
+		p.expr(s.Type)
+		p.setLine(s.Type.End())
+	}
+	if s.Value != nil {
+		if grouped {
+			for ; cells < 2; cells++ {
+				p.sep()
+			}
+		} else {
+			p.write(" ")
+		}
+		p.write("= ")
+		p.expr(s.Value)
+		p.setLine(s.Value.End())
+	}
+	if s.Comment != nil {
+		if grouped {
+			for ; cells < 3; cells++ {
+				p.sep()
+			}
+		} else {
+			p.write(" ")
+		}
+		p.lineComment(s.Comment)
+	}
+}
+
+// hasFloating reports whether a floating comment starts before pos.
+func (p *printer) hasFloating(pos Pos) bool {
+	return len(p.floating) > 0 && pos.IsValid() && p.floating[0].Pos().Offset < pos.Offset
+}
+
+func (p *printer) typeSpec(s *TypeSpec, grouped bool) {
+	p.write(s.Name.Name)
+	if s.TypeParams != nil {
+		p.typeParams(s.TypeParams)
+	}
+	if grouped {
+		p.sep()
+	} else {
+		p.write(" ")
+	}
+	if s.Assign.IsValid() {
+		p.write("= ")
+	}
+	p.expr(s.Type)
+	p.setLine(s.Type.End())
+	if s.Comment != nil {
+		p.sep()
+		p.lineComment(s.Comment)
+	}
+}
+
+func (p *printer) typeParams(l *TypeParamList) {
+	p.write("[")
+	for i, param := range l.List {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.identList(param.Names)
+		p.write(" ")
+		p.expr(param.Constraint)
+	}
+	p.write("]")
+}
+
+func (p *printer) identList(names []*Ident) {
+	for i, name := range names {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(name.Name)
+	}
+}
+
+func (p *printer) expr(x Expr) {
+	switch x := x.(type) {
+	case *Ident:
+		p.write(x.Name)
+	case *BasicLit:
+		p.text(x.Value)
+	case *UnaryExpr:
+		p.write(x.Op.String())
+		p.expr(x.X)
+	case *BinaryExpr:
+		p.expr(x.X)
+		p.write(" " + x.Op.String() + " ")
+		p.expr(x.Y)
+	case *StarExpr:
+		p.write("*")
+		p.expr(x.X)
+	case *ArrayType:
+		p.write("[")
+		if x.Len != nil {
+			p.expr(x.Len)
+		}
+		p.write("]")
+		p.expr(x.Elt)
+	case *MapType:
+		p.write("map[")
+		p.expr(x.Key)
+		p.write("]")
+		p.expr(x.Value)
+	case *IndexExpr:
+		p.expr(x.X)
+		p.write("[")
+		for i, index := range x.Indices {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expr(index)
+		}
+		p.write("]")
+	case *SelectorExpr:
+		p.expr(x.X)
+		p.write(".")
+		p.write(x.Sel.Name)
+	case *StructType:
+		p.structType(x)
+	case *InterfaceType:
+		p.interfaceType(x)
+	}
+}
+
+// interfaceType prints a type-set interface on one line when it was written
+// on one line, and otherwise with the union on its own lines, breaking
+// after a "|" wherever the source did.
+func (p *printer) interfaceType(it *InterfaceType) {
+	oneLine := it.Lbrace.IsValid() && it.Rbrace.IsValid() && it.Lbrace.Line == it.Rbrace.Line
+	if len(it.Terms) == 0 {
+		oneLine = true
+	}
+	if oneLine {
+		if len(it.Terms) == 0 {
+			p.write("interface{}")
+			return
+		}
+		p.write("interface{ ")
+		p.terms(it.Terms)
+		p.write(" }")
+		return
+	}
+
+	p.write("interface {")
+	p.indent++
+	p.newline(false)
+	p.terms(it.Terms)
+	p.indent--
+	p.newline(true)
+	p.write("}")
+	p.setLine(it.Rbrace)
+}
+
+func (p *printer) terms(terms []Expr) {
+	indent := p.indent
+	for i, x := range terms {
+		if i > 0 {
+			if prev, pos := terms[i-1].End(), x.Pos(); prev.IsValid() && pos.IsValid() && pos.Line > prev.Line {
+				p.write(" |")
+				if p.indent == indent {
+					p.indent++
+				}
+				p.newline(false)
+			} else {
+				p.write(" | ")
+			}
+		}
+		p.expr(x)
+	}
+	p.indent = indent
+}
+
+func (p *printer) structType(st *StructType) {
+	if p.oneLineStruct(st) {
+		if len(st.Fields) == 0 {
+			p.write("struct{}")
+			return
+		}
+		f := st.Fields[0]
+		p.write("struct{ ")
+		if len(f.Names) > 0 {
+			p.identList(f.Names)
+			p.write(" ")
+		}
+		p.expr(f.Type)
+		p.write(" }")
+		return
+	}
+
+	p.write("struct {")
+	p.setLine(st.Lbrace)
+	p.indent++
+	multiline := true // the first field always starts a new section
+	for i, f := range st.Fields {
+		start := f.Pos()
+		if f.Doc != nil && len(f.Doc.List) > 0 {
+			start = f.Doc.Pos()
+		}
+		p.flushComments(start)
+		line := startLine(f.Doc, f)
+		if i == 0 {
+			line = 0 // gofmt drops blank lines after "{"
+		}
+		p.linebreak(line, 1, multiline)
+		if f.Doc != nil {
+			p.commentGroup(f.Doc)
+			p.newline(false)
+		}
+		before := p.lines
+		p.field(f)
+		multiline = p.lines > before
+	}
+	p.flushComments(st.Rbrace)
+	p.indent--
+	p.newline(true)
+	p.write("}")
+	p.setLine(st.Rbrace)
+}
+
+func (p *printer) field(f *Field) {
+	extraTabs := 1
+	if len(f.Names) > 0 {
+		p.identList(f.Names)
+		p.sep()
+		p.expr(f.Type)
+	} else {
+		p.expr(f.Type)
+		extraTabs = 2
+	}
+	p.setLine(f.Type.End())
+	if f.Tag != nil {
+		p.sep()
+		p.text(f.Tag.Value)
+		p.setLine(f.Tag.Pos())
+		extraTabs = 1
+	}
+	if f.Comment != nil {
+		for ; extraTabs > 0; extraTabs-- {
+			p.sep()
+		}
+		p.lineComment(f.Comment)
+	}
+}
+
+// oneLineStruct reports whether st is printed on a single line: it has at
+// most one small untagged field, no comments, and was written on one line.
+func (p *printer) oneLineStruct(st *StructType) bool {
+	for _, g := range p.floating {
+		if !st.Rbrace.IsValid() || g.Pos().Offset >= st.Rbrace.Offset {
+			break
+		}
+		if g.Pos().Offset > st.Lbrace.Offset {
+			return false
+		}
+	}
+	if st.Lbrace.IsValid() && st.Rbrace.IsValid() {
+		if st.Lbrace.Line != st.Rbrace.Line {
+			return false
+		}
+	} else if len(st.Fields) > 0 {
+		return false
+	}
+	switch len(st.Fields) {
+	case 0:
+		return true
+	case 1:
+		f := st.Fields[0]
+		if f.Doc != nil || f.Tag != nil || f.Comment != nil {
+			return false
+		}
+		size := 0
+		if len(f.Names) > 0 {
+			size = 1 // blank between names and type, as gofmt counts it
+		}
+		typ, ok := oneLineExpr(f.Type)
+		return ok && size+utf8.RuneCountInString(typ) <= maxOneLineStruct
+	}
+	return false
+}
+
+// oneLineExpr renders x without alignment, reporting whether it fits on a
+// single line.
+func oneLineExpr(x Expr) (string, bool) {
+	q := &printer{}
+	q.expr(x)
+	if q.lines > 0 {
+		return "", false
+	}
+	s := strings.ReplaceAll(q.buf.String(), string(tabwriter.Escape), "")
+	return s, true
+}
@@ -0,0 +1,151 @@
+package ast
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// formatSample covers alignment, comments and one-line structs; gofmt is
+// the reference for the expected output.
+const formatSample = `// Package p is a sample.
+package p
+import "fmt" // f
+import (
+	// doc
+	"bytes" // b
+	str "strings" // s
+
+	. "os"
+	_ "unsafe"
+)
+type A int32 // a
+type LongName string // b
+type S struct {
+	X int ` + "`a`" + `
+	// doc
+	LongName string ` + "`json:\"b\"`" + `
+	C *int // c
+	D int
+	LongerName []string // d
+	M map[string]*[]int
+
+
+
+	E int
+	F struct {
+		G int
+	}
+	H int
+	Embedded // e
+	*Emb2 ` + "`tag`" + `
+	I bool
+} // trailing
+type (
+	B int32 // b
+	// doc
+	LongerName = []string // d
+	C struct {
+		X int
+	}
+	D[T any] struct{ X T }
+	E struct{}
+	F = int
+)
+type G struct{ X, Y int; Z string }
+type H struct {}
+type F struct { // c
+}
+type P[K, V any] struct{
+ Key K
+ Vals []V
+}
+type Q P[int, []string]
+
+// floating
+
+/* block */
+type R [4]int // r
+type U interface { A | B | string }
+type V interface {
+	A |
+	B | []int
+}
+type W struct {
+	X interface{ A | B }
+}
+
+type Mode int8
+const (
+	Off Mode = -1 // off
+	// On is on.
+	On Mode = iota+1
+	Auto
+	LongName // long
+
+
+	Last = 10 // untyped
+)
+const Max Mode = 0x10 // max
+`
+
+func TestFprintMatchesGofmt(t *testing.T) {
+	sources := map[string][]byte{"sample": []byte(formatSample)}
+	paths, _ := filepath.Glob("../../testdata/schema/*.ffi")
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(path)] = src
+	}
+
+	for name, src := range sources {
+		want, err := format.Source(src)
+		if err != nil {
+			t.Fatalf("%s: gofmt: %v", name, err)
+		}
+		got, err := Format(src)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: Format differs from gofmt\n--- got ---\n%s\n--- want ---\n%s", name, got, want)
+		}
+	}
+}
+
+func TestFprintCodemod(t *testing.T) {
+	file := mustParse(t, "package p\n\n// Device doc\ntype Device struct {\n\tID int32 `json:\"id\"`\n}\n")
+
+	// Add a field and a new declaration; neither has source positions
+	st := file.Decls[0].(*TypeDecl).Specs[0].Type.(*StructType)
+	st.Fields = append(st.Fields, &Field{
+		Doc:   &CommentGroup{List: []*Comment{{Text: "// Serial is optional."}}},
+		Names: []*Ident{{Name: "Serial"}},
+		Type:  &StarExpr{X: &Ident{Name: "string"}},
+		Tag:   &BasicLit{Kind: STRING, Value: "`json:\"serial\"`"},
+	})
+	file.Decls = append(file.Decls, &TypeDecl{Specs: []*TypeSpec{{
+		Name: &Ident{Name: "DeviceList"},
+		Type: &ArrayType{Elt: &Ident{Name: "Device"}},
+	}}})
+
+	var buf bytes.Buffer
+	if err := Fprint(&buf, file); err != nil {
+		t.Fatal(err)
+	}
+	want := "package p\n\n// Device doc\ntype Device struct {\n\tID int32 `json:\"id\"`\n" +
+		"\t// Serial is optional.\n\tSerial *string `json:\"serial\"`\n}\n\ntype DeviceList []Device\n"
+	if buf.String() != want {
+		t.Errorf("Fprint:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// The output parses back to an equivalent file
+	if _, err := Format(buf.Bytes()); err != nil {
+		t.Errorf("reparse: %v", err)
+	}
+}
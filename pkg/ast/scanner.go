@@ -0,0 +1,342 @@
+package ast
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scanner tokenizes schema source. Like Go, it inserts a SEMICOLON token
+// (with literal "\n") at the end of a line whose last token can end a
+// declaration, so schemas need no explicit semicolons.
+type Scanner struct {
+	src        []byte
+	pos        Pos // position of the next unread byte
+	insertSemi bool
+	err        *Error
+}
+
+// NewScanner returns a scanner over src.
+func NewScanner(src []byte) *Scanner {
+	return &Scanner{src: src, pos: Pos{Line: 1, Column: 1}}
+}
+
+// Err returns the first error encountered, if any.
+func (s *Scanner) Err() error {
+	if s.err == nil {
+		return nil
+	}
+	return s.err
+}
+
+// Pos returns the position just after the most recently scanned token.
+func (s *Scanner) Pos() Pos { return s.pos }
+
+func (s *Scanner) error(pos Pos, format string, args ...any) {
+	if s.err == nil {
+		s.err = &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (s *Scanner) peek(n int) byte {
+	if s.pos.Offset+n < len(s.src) {
+		return s.src[s.pos.Offset+n]
+	}
+	return 0
+}
+
+func (s *Scanner) atEOF() bool { return s.pos.Offset >= len(s.src) }
+
+// skip advances past n bytes.
+func (s *Scanner) skip(n int) {
+	for _, c := range s.src[s.pos.Offset : s.pos.Offset+n] {
+		if c == '\n' {
+			s.pos.Line++
+			s.pos.Column = 1
+		} else {
+			s.pos.Column++
+		}
+	}
+	s.pos.Offset += n
+}
+
+// Scan returns the next token, its start position and its literal text.
+// Comments are returned as COMMENT tokens including their delimiters.
+func (s *Scanner) Scan() (pos Pos, tok Token, lit string) {
+	for !s.atEOF() {
+		c := s.src[s.pos.Offset]
+		if c == '\n' && s.insertSemi {
+			break
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			break
+		}
+		s.skip(1)
+	}
+
+	pos = s.pos
+	if s.atEOF() {
+		if s.insertSemi {
+			s.insertSemi = false
+			return pos, SEMICOLON, "\n"
+		}
+		return pos, EOF, ""
+	}
+
+	insertSemi := false
+	defer func() { s.insertSemi = insertSemi }()
+
+	c := s.src[s.pos.Offset]
+	switch {
+	case c == '\n':
+		s.skip(1)
+		return pos, SEMICOLON, "\n"
+
+	case isLetter(c) || c >= utf8.RuneSelf:
+		lit = s.scanIdent()
+		if lit == "" {
+			r, size := utf8.DecodeRune(s.src[s.pos.Offset:])
+			s.skip(size)
+			s.error(pos, "invalid character %q", r)
+			return pos, ILLEGAL, string(r)
+		}
+		if kw, ok := keywords[lit]; ok {
+			return pos, kw, lit
+		}
+		insertSemi = true
+		return pos, IDENT, lit
+
+	case isDigit(c) || (c == '.' && isDigit(s.peek(1))):
+		tok, lit = s.scanNumber()
+		insertSemi = true
+		return pos, tok, lit
+
+	case c == '"' || c == '`':
+		lit = s.scanString(c)
+		insertSemi = true
+		return pos, STRING, lit
+
+	case c == '/' && (s.peek(1) == '/' || s.peek(1) == '*'):
+		if s.insertSemi && s.commentEndsLine() {
+			// Emit the implicit semicolon first; the comment follows
+			insertSemi = false
+			return pos, SEMICOLON, "\n"
+		}
+		insertSemi = s.insertSemi
+		return pos, COMMENT, s.scanComment()
+	}
+
+	s.skip(1)
+	switch c {
+	case '[':
+		tok = LBRACK
+	case ']':
+		tok, insertSemi = RBRACK, true
+	case '{':
+		tok = LBRACE
+	case '}':
+		tok, insertSemi = RBRACE, true
+	case '(':
+		tok = LPAREN
+	case ')':
+		tok, insertSemi = RPAREN, true
+	case '*':
+		tok = STAR
+	case ',':
+		tok = COMMA
+	case '.':
+		tok = PERIOD
+	case ';':
+		tok = SEMICOLON
+	case '=':
+		tok = ASSIGN
+	case '~':
+		tok = TILDE
+	case '|':
+		tok = PIPE
+	case '+':
+		tok = ADD
+	case '-':
+		tok = SUB
+	default:
+		s.error(pos, "invalid character %q", c)
+		return pos, ILLEGAL, string(c)
+	}
+	return pos, tok, tok.String()
+}
+
+func (s *Scanner) scanIdent() string {
+	start := s.pos.Offset
+	end := start
+	for end < len(s.src) {
+		r, size := rune(s.src[end]), 1
+		if r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRune(s.src[end:])
+		}
+		if !unicode.IsLetter(r) && r != '_' && (end == start || !unicode.IsDigit(r)) {
+			break
+		}
+		end += size
+	}
+	s.skip(end - start)
+	return string(s.src[start:end])
+}
+
+func (s *Scanner) scanNumber() (Token, string) {
+	start := s.pos.Offset
+	tok := INT
+	if s.peek(0) == '0' && (s.peek(1) == 'x' || s.peek(1) == 'X') {
+		s.skip(2)
+		for isHex(s.peek(0)) || s.peek(0) == '_' {
+			s.skip(1)
+		}
+		return tok, string(s.src[start:s.pos.Offset])
+	}
+	for isDigit(s.peek(0)) || s.peek(0) == '_' {
+		s.skip(1)
+	}
+	if s.peek(0) == '.' {
+		tok = FLOAT
+		s.skip(1)
+		for isDigit(s.peek(0)) || s.peek(0) == '_' {
+			s.skip(1)
+		}
+	}
+	if c := s.peek(0); c == 'e' || c == 'E' {
+		tok = FLOAT
+		s.skip(1)
+		if c := s.peek(0); c == '+' || c == '-' {
+			s.skip(1)
+		}
+		if !isDigit(s.peek(0)) {
+			s.error(s.pos, "exponent has no digits")
+		}
+		for isDigit(s.peek(0)) {
+			s.skip(1)
+		}
+	}
+	return tok, string(s.src[start:s.pos.Offset])
+}
+
+func (s *Scanner) scanString(quote byte) string {
+	start := s.pos
+	s.skip(1)
+	for {
+		if s.atEOF() {
+			s.error(start, "string literal not terminated")
+			break
+		}
+		c := s.peek(0)
+		if c == quote {
+			s.skip(1)
+			break
+		}
+		if quote == '"' {
+			if c == '\n' {
+				s.error(start, "string literal not terminated")
+				break
+			}
+			if c == '\\' {
+				s.scanEscape()
+				continue
+			}
+		}
+		s.skip(1)
+	}
+	return string(s.src[start.Offset:s.pos.Offset])
+}
+
+// scanEscape consumes an escape sequence inside an interpreted string,
+// starting at the backslash.
+func (s *Scanner) scanEscape() {
+	start := s.pos
+	s.skip(1)
+	var n, base int
+	switch c := s.peek(0); c {
+	case 'a', 'b', 'f', 'n', 'r', 't', 'v', '\\', '"':
+		s.skip(1)
+		return
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n, base = 3, 8
+	case 'x':
+		s.skip(1)
+		n, base = 2, 16
+	case 'u':
+		s.skip(1)
+		n, base = 4, 16
+	case 'U':
+		s.skip(1)
+		n, base = 8, 16
+	default:
+		s.error(start, "unknown escape sequence")
+		return
+	}
+	for ; n > 0; n-- {
+		c := s.peek(0)
+		if (base == 8 && (c < '0' || c > '7')) || (base == 16 && !isHex(c)) {
+			s.error(start, "illegal character in escape sequence")
+			return
+		}
+		s.skip(1)
+	}
+}
+
+func (s *Scanner) scanComment() string {
+	start := s.pos
+	if s.peek(1) == '/' {
+		end := start.Offset
+		for end < len(s.src) && s.src[end] != '\n' {
+			end++
+		}
+		s.skip(end - start.Offset)
+		text := s.src[start.Offset:end]
+		if n := len(text); n > 0 && text[n-1] == '\r' {
+			text = text[:n-1]
+		}
+		return string(text)
+	}
+
+	s.skip(2)
+	for {
+		if s.atEOF() {
+			s.error(start, "comment not terminated")
+			break
+		}
+		if s.peek(0) == '*' && s.peek(1) == '/' {
+			s.skip(2)
+			break
+		}
+		s.skip(1)
+	}
+	return string(s.src[start.Offset:s.pos.Offset])
+}
+
+// commentEndsLine reports whether the comment starting at the current
+// position reaches or spans a line end, with only whitespace before it.
+func (s *Scanner) commentEndsLine() bool {
+	i := s.pos.Offset
+	for i < len(s.src) && s.src[i] == '/' && i+1 < len(s.src) && (s.src[i+1] == '/' || s.src[i+1] == '*') {
+		if s.src[i+1] == '/' {
+			return true
+		}
+		i += 2
+		for i < len(s.src) && !(s.src[i] == '*' && i+1 < len(s.src) && s.src[i+1] == '/') {
+			if s.src[i] == '\n' {
+				return true
+			}
+			i++
+		}
+		i += 2
+		for i < len(s.src) && (s.src[i] == ' ' || s.src[i] == '\t' || s.src[i] == '\r') {
+			i++
+		}
+		if i >= len(s.src) || s.src[i] == '\n' {
+			return true
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool { return c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isHex(c byte) bool    { return isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'f') }
@@ -0,0 +1,135 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+type scanned struct {
+	tok Token
+	lit string
+}
+
+func scanAll(t *testing.T, src string) []scanned {
+	t.Helper()
+	sc := NewScanner([]byte(src))
+	var out []scanned
+	for {
+		_, tok, lit := sc.Scan()
+		if tok == EOF {
+			break
+		}
+		out = append(out, scanned{tok, lit})
+		if len(out) > 1000 {
+			t.Fatal("scanner did not terminate")
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("Scan(%q): %v", src, err)
+	}
+	return out
+}
+
+func TestScanTokens(t *testing.T) {
+	src := "package audio\ntype Device struct { ID *int32 `json:\"id\"`; Tags []string }\n"
+	want := []scanned{
+		{PACKAGE, "package"}, {IDENT, "audio"}, {SEMICOLON, "\n"},
+		{TYPE, "type"}, {IDENT, "Device"}, {STRUCT, "struct"}, {LBRACE, "{"},
+		{IDENT, "ID"}, {STAR, "*"}, {IDENT, "int32"}, {STRING, "`json:\"id\"`"}, {SEMICOLON, ";"},
+		{IDENT, "Tags"}, {LBRACK, "["}, {RBRACK, "]"}, {IDENT, "string"}, {RBRACE, "}"},
+		{SEMICOLON, "\n"},
+	}
+	got := scanAll(t, src)
+	if len(got) != len(want) {
+		t.Fatalf("got %d tokens %v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestScanSemicolonBeforeComment(t *testing.T) {
+	got := scanAll(t, "type A int32 // trailing\ntype B /* inline */ int32\n")
+	var toks []string
+	for _, s := range got {
+		toks = append(toks, s.tok.String())
+	}
+	want := "type IDENT IDENT ; COMMENT type IDENT COMMENT IDENT ;"
+	if strings.Join(toks, " ") != want {
+		t.Errorf("tokens = %s, want %s", strings.Join(toks, " "), want)
+	}
+}
+
+func TestScanNumbers(t *testing.T) {
+	tests := []struct {
+		src string
+		tok Token
+	}{
+		{"42", INT},
+		{"0x1F", INT},
+		{"1_000", INT},
+		{"1.5", FLOAT},
+		{".5", FLOAT},
+		{"1e9", FLOAT},
+		{"2.5E-3", FLOAT},
+	}
+	for _, tt := range tests {
+		got := scanAll(t, tt.src)
+		if got[0].tok != tt.tok || got[0].lit != tt.src {
+			t.Errorf("Scan(%q) = %v, want %v %q", tt.src, got[0], tt.tok, tt.src)
+		}
+	}
+}
+
+func TestScanPositions(t *testing.T) {
+	sc := NewScanner([]byte("package a\n\ntype  B int32"))
+	var positions []string
+	for {
+		pos, tok, _ := sc.Scan()
+		if tok == EOF {
+			break
+		}
+		positions = append(positions, pos.String())
+	}
+	want := "1:1 1:9 1:10 3:1 3:7 3:9 3:14"
+	if got := strings.Join(positions, " "); got != want {
+		t.Errorf("positions = %s, want %s", got, want)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`"abc`, "1:1: string literal not terminated"},
+		{"\"ab\nc\"", "1:1: string literal not terminated"},
+		{`"a\qb"`, "1:3: unknown escape sequence"},
+		{`"\x4"`, "1:2: illegal character in escape sequence"},
+		{"/* open", "1:1: comment not terminated"},
+		{"a # b", "1:3: invalid character '#'"},
+		{"1e", "1:3: exponent has no digits"},
+	}
+	for _, tt := range tests {
+		sc := NewScanner([]byte(tt.src))
+		for {
+			if _, tok, _ := sc.Scan(); tok == EOF {
+				break
+			}
+		}
+		err := sc.Err()
+		if err == nil {
+			t.Errorf("Scan(%q): expected error %q", tt.src, tt.want)
+			continue
+		}
+		if err.Error() != tt.want {
+			t.Errorf("Scan(%q) error = %q, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestScanValidEscapes(t *testing.T) {
+	scanAll(t, `"\a\b\f\n\r\t\v\\\" \101 \x41 é \U0001F600"`)
+}
@@ -0,0 +1,149 @@
+package ast
+
+import "fmt"
+
+// Token is the kind of a lexical token.
+type Token int
+
+const (
+	ILLEGAL Token = iota
+	EOF
+	COMMENT
+
+	// Literals
+	IDENT  // Device
+	INT    // 42
+	FLOAT  // 1.5
+	STRING // "abc" or `abc`
+
+	// Punctuation
+	LBRACK    // [
+	RBRACK    // ]
+	LBRACE    // {
+	RBRACE    // }
+	LPAREN    // (
+	RPAREN    // )
+	STAR      // *
+	COMMA     // ,
+	PERIOD    // .
+	SEMICOLON // ; or an automatically inserted newline
+	ASSIGN    // =
+	TILDE     // ~
+	PIPE      // |
+	ADD       // +
+	SUB       // -
+
+	// Keywords
+	PACKAGE
+	IMPORT
+	TYPE
+	CONST
+	STRUCT
+	MAP
+	CHAN
+	FUNC
+	INTERFACE
+)
+
+var tokens = [...]string{
+	ILLEGAL:   "ILLEGAL",
+	EOF:       "EOF",
+	COMMENT:   "COMMENT",
+	IDENT:     "IDENT",
+	INT:       "INT",
+	FLOAT:     "FLOAT",
+	STRING:    "STRING",
+	LBRACK:    "[",
+	RBRACK:    "]",
+	LBRACE:    "{",
+	RBRACE:    "}",
+	LPAREN:    "(",
+	RPAREN:    ")",
+	STAR:      "*",
+	COMMA:     ",",
+	PERIOD:    ".",
+	SEMICOLON: ";",
+	ASSIGN:    "=",
+	TILDE:     "~",
+	PIPE:      "|",
+	ADD:       "+",
+	SUB:       "-",
+	PACKAGE:   "package",
+	IMPORT:    "import",
+	TYPE:      "type",
+	CONST:     "const",
+	STRUCT:    "struct",
+	MAP:       "map",
+	CHAN:      "chan",
+	FUNC:      "func",
+	INTERFACE: "interface",
+}
+
+func (t Token) String() string {
+	if t >= 0 && int(t) < len(tokens) {
+		return tokens[t]
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+// keywords maps reserved words to their tokens. Go keywords with no meaning
+// in schemas (map, chan, func, interface) are still reserved so they produce
+// a clear error instead of being read as type names.
+var keywords = map[string]Token{
+	"package":   PACKAGE,
+	"import":    IMPORT,
+	"type":      TYPE,
+	"const":     CONST,
+	"struct":    STRUCT,
+	"map":       MAP,
+	"chan":      CHAN,
+	"func":      FUNC,
+	"interface": INTERFACE,
+}
+
+// IsKeyword reports whether t is a reserved word.
+func (t Token) IsKeyword() bool {
+	return t >= PACKAGE && t <= INTERFACE
+}
+
+// Pos is a position in a source file. Lines and columns start at 1; columns
+// count bytes. The zero Pos is invalid.
+type Pos struct {
+	Offset int // byte offset, starting at 0
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is set.
+func (p Pos) IsValid() bool { return p.Line > 0 }
+
+func (p Pos) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// advance returns the position just after text, which starts at p.
+func advance(p Pos, text string) Pos {
+	p.Offset += len(text)
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// Error is a syntax error at a source position.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
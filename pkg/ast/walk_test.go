@@ -0,0 +1,94 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInspectOrder(t *testing.T) {
+	file := mustParse(t, `package p
+
+// Doc
+type Page[T any] struct {
+	Items []T `+"`json:\"items\"`"+` // items
+	Next  *Page[T]
+}
+`)
+	var got []string
+	Inspect(file, func(n Node) bool {
+		switch n := n.(type) {
+		case *Ident:
+			got = append(got, n.Name)
+		case *Comment:
+			got = append(got, n.Text)
+		case *BasicLit:
+			got = append(got, n.Value)
+		case nil:
+		default:
+			got = append(got, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+	want := "File p TypeDecl CommentGroup // Doc TypeSpec Page TypeParamList TypeParam T any StructType " +
+		"Field Items ArrayType T `json:\"items\"` CommentGroup // items " +
+		"Field Next StarExpr IndexExpr Page T"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("Inspect order:\n got %s\nwant %s", s, want)
+	}
+}
+
+func TestInspectConsts(t *testing.T) {
+	file := mustParse(t, "package p\nconst (\n\tA T = iota + 1 // a\n\tB\n)\n")
+	var got []string
+	Inspect(file, func(n Node) bool {
+		switch n := n.(type) {
+		case *Ident:
+			got = append(got, n.Name)
+		case *BasicLit:
+			got = append(got, n.Value)
+		case *ConstSpec, *BinaryExpr:
+			got = append(got, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+	want := "p ConstSpec A T BinaryExpr iota 1 ConstSpec B"
+	if s := strings.Join(got, " "); s != want {
+		t.Errorf("Inspect order:\n got %s\nwant %s", s, want)
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	file := mustParse(t, "package p\ntype A struct {\n\tB struct{ C int32 }\n}\n")
+	var names []string
+	Inspect(file, func(n Node) bool {
+		if f, ok := n.(*Field); ok {
+			names = append(names, f.Names[0].Name)
+			return false // do not descend into nested structs
+		}
+		return true
+	})
+	if len(names) != 1 || names[0] != "B" {
+		t.Errorf("visited fields %v, want [B]", names)
+	}
+}
+
+type countVisitor struct{ enter, leave *int }
+
+func (v countVisitor) Visit(n Node) Visitor {
+	if n == nil {
+		*v.leave++
+	} else {
+		*v.enter++
+	}
+	return v
+}
+
+func TestWalkBalanced(t *testing.T) {
+	file := mustParse(t, "package p\ntype (\n\tA [4]int32\n\tB = A\n)\n")
+	var enter, leave int
+	Walk(countVisitor{&enter, &leave}, file)
+	if enter == 0 || enter != leave {
+		t.Errorf("Walk entered %d nodes but left %d", enter, leave)
+	}
+}
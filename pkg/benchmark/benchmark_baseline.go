@@ -0,0 +1,221 @@
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// baselineFormat describes a general-purpose Go serialization library that
+// ffire is compared against.
+type baselineFormat struct {
+	Import    string // Import path of the library
+	Require   string // go.mod require line, empty for the standard library
+	Marshal   string
+	Unmarshal string
+}
+
+var baselineFormats = map[string]baselineFormat{
+	"json": {
+		Import:  "encoding/json",
+		Marshal: "json.Marshal", Unmarshal: "json.Unmarshal",
+	},
+	"msgpack": {
+		Import:  "github.com/vmihailenco/msgpack/v5",
+		Require: "github.com/vmihailenco/msgpack/v5 v5.4.1",
+		Marshal: "msgpack.Marshal", Unmarshal: "msgpack.Unmarshal",
+	},
+	"cbor": {
+		Import:  "github.com/fxamacker/cbor/v2",
+		Require: "github.com/fxamacker/cbor/v2 v2.7.0",
+		Marshal: "cbor.Marshal", Unmarshal: "cbor.Unmarshal",
+	},
+}
+
+// BaselineFormats lists the formats GenerateBaseline accepts.
+var BaselineFormats = []string{"json", "msgpack", "cbor"}
+
+// GenerateBaseline creates a Go benchmark of a general-purpose format for
+// comparison with ffire: json (encoding/json), msgpack
+// (github.com/vmihailenco/msgpack/v5) or cbor (github.com/fxamacker/cbor/v2).
+//
+// The driver uses the Go types ffire generates for the schema, so the
+// baseline marshals the same values as the ffire Go benchmark, with field
+// names taken from the schema's struct tags as each library reads them.
+// Libraries outside the standard library are fetched with go mod tidy.
+func GenerateBaseline(format string, s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int) error {
+	bf, ok := baselineFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown baseline format %q (supported: json, msgpack, cbor)", format)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+	if messageType == nil {
+		return fmt.Errorf("message type %s not found", messageName)
+	}
+
+	// The generated decoder turns the ffire fixture into the Go value to marshal
+	if err := writeGoMain(s, outputDir); err != nil {
+		return err
+	}
+	binaryData, err := fixture.Convert(s, messageName, jsonData)
+	if err != nil {
+		return fmt.Errorf("failed to convert fixture: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "fixture.bin"), binaryData, 0644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	data := struct {
+		BenchmarkData
+		Format string
+		baselineFormat
+	}{
+		BenchmarkData: BenchmarkData{
+			Package:      s.Package,
+			SchemaName:   schemaName,
+			MessageName:  messageName,
+			TypeName:     getRootTypeName(messageType.TargetType),
+			Iterations:   iterations,
+			FixtureBytes: len(binaryData),
+		},
+		Format:         format,
+		baselineFormat: bf,
+	}
+	var buf bytes.Buffer
+	if err := baselineBenchTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to generate benchmark: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.go"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark main: %w", err)
+	}
+
+	goMod := "module bench\n\ngo 1.21\n"
+	if bf.Require != "" {
+		goMod += "\nrequire " + bf.Require + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "go.mod"), []byte(goMod), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+
+	return nil
+}
+
+var baselineBenchTemplate = template.Must(template.New("bench").Parse(`package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+{{if ne .Import "encoding/json"}}
+	"{{.Import}}"
+{{end}})
+
+//go:embed fixture.bin
+var fixtureData []byte
+
+type BenchResult struct {
+	Language    string ` + "`json:\"language\"`" + `
+	Format      string ` + "`json:\"format\"`" + `
+	Message     string ` + "`json:\"message\"`" + `
+	Iterations  int    ` + "`json:\"iterations\"`" + `
+	EncodeNs    int64  ` + "`json:\"encode_ns\"`" + `
+	DecodeNs    int64  ` + "`json:\"decode_ns\"`" + `
+	TotalNs     int64  ` + "`json:\"total_ns\"`" + `
+	WireSize    int    ` + "`json:\"wire_size\"`" + `
+	FixtureSize int    ` + "`json:\"fixture_size\"`" + `
+	Timestamp   string ` + "`json:\"timestamp\"`" + `
+}
+
+// decodeAs unmarshals data into a fresh value of the same type as like
+func decodeAs[T any](like T, data []byte) error {
+	var v T
+	return {{.Unmarshal}}(data, &v)
+}
+
+func main() {
+	iterations := {{.Iterations}}
+	jsonOutput := os.Getenv("BENCH_JSON") == "1"
+
+	// Decode the ffire fixture to get the value to marshal
+	original, err := Decode{{.TypeName}}Message(fixtureData)
+	if err != nil {
+		panic(fmt.Sprintf("failed to decode fixture: %v", err))
+	}
+
+	// Warmup
+	for i := 0; i < 1000; i++ {
+		encoded, err := {{.Marshal}}(original)
+		if err != nil {
+			panic(fmt.Sprintf("{{.Format}} marshal failed: %v", err))
+		}
+		if err := decodeAs(original, encoded); err != nil {
+			panic(fmt.Sprintf("{{.Format}} unmarshal failed: %v", err))
+		}
+	}
+
+	// Benchmark encode
+	start := time.Now()
+	var encoded []byte
+	for i := 0; i < iterations; i++ {
+		encoded, _ = {{.Marshal}}(original)
+	}
+	encodeTime := time.Since(start)
+
+	// Benchmark decode
+	start = time.Now()
+	for i := 0; i < iterations; i++ {
+		_ = decodeAs(original, encoded)
+	}
+	decodeTime := time.Since(start)
+
+	// Calculate metrics
+	encodeNs := encodeTime.Nanoseconds() / int64(iterations)
+	decodeNs := decodeTime.Nanoseconds() / int64(iterations)
+	totalNs := encodeNs + decodeNs
+
+	if jsonOutput {
+		// Output JSON for automation
+		result := BenchResult{
+			Language:    "Go",
+			Format:      "{{.Format}}",
+			Message:     "{{.SchemaName}}",
+			Iterations:  iterations,
+			EncodeNs:    encodeNs,
+			DecodeNs:    decodeNs,
+			TotalNs:     totalNs,
+			WireSize:    len(encoded),
+			FixtureSize: len(fixtureData),
+			Timestamp:   time.Now().Format(time.RFC3339),
+		}
+		json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		// Print human-readable results
+		fmt.Printf("{{.Format}} benchmark: {{.SchemaName}}\n")
+		fmt.Printf("Iterations:  %d\n", iterations)
+		fmt.Printf("Encode:      %d ns/op\n", encodeNs)
+		fmt.Printf("Decode:      %d ns/op\n", decodeNs)
+		fmt.Printf("Total:       %d ns/op\n", totalNs)
+		fmt.Printf("Wire size:   %d bytes\n", len(encoded))
+		fmt.Printf("Fixture:     %d bytes\n", len(fixtureData))
+		fmt.Printf("Total time:  %.2fs\n", (encodeTime + decodeTime).Seconds())
+	}
+}
+`))
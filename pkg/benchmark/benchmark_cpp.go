@@ -15,13 +15,33 @@ import (
 
 // GenerateCpp creates a complete C++ benchmark executable in the output directory.
 func GenerateCpp(s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int) error {
+	return GenerateCppWithOptions(s, schemaName, messageName, jsonData, outputDir, iterations, CppOptions{})
+}
+
+// CppOptions are build options for the C++ benchmark.
+type CppOptions struct {
+	// Sanitize builds the benchmark with these sanitizers (see
+	// generator.ParseSanitizers), so a run reports memory errors and
+	// undefined behavior in the generated code. Timings are not
+	// representative.
+	Sanitize []string
+
+	// SIMD generates the decoder with the --cpp-simd checks (see
+	// generator.CppOptions). The benchmark reports format ffire-simd and
+	// the instruction set in use; make scalar builds bench_scalar with
+	// FFIRE_NO_SIMD to time the same checks without SIMD.
+	SIMD bool
+}
+
+// GenerateCppWithOptions is GenerateCpp with build options.
+func GenerateCppWithOptions(s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int, opts CppOptions) error {
 	// Create output directory
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Generate the encoder/decoder code
-	generatedCode, err := generator.GenerateCpp(s)
+	generatedCode, err := generator.GenerateCppWithOptions(s, generator.CppOptions{SIMD: opts.SIMD})
 	if err != nil {
 		return fmt.Errorf("failed to generate code: %w", err)
 	}
@@ -68,6 +88,8 @@ func GenerateCpp(s *schema.Schema, schemaName string, messageName string, jsonDa
 		TypeName:     rootTypeName,
 		Iterations:   iterations,
 		FixtureBytes: len(binaryData),
+		Sanitize:     strings.Join(generator.SanitizerFlags(opts.Sanitize), " "),
+		SIMD:         opts.SIMD,
 	}
 
 	var buf bytes.Buffer
@@ -114,6 +136,8 @@ type CppBenchmarkData struct {
 	TypeName     string
 	Iterations   int
 	FixtureBytes int
+	Sanitize     string // sanitizer compile and link flags, if any
+	SIMD         bool   // decoder generated with --cpp-simd
 }
 
 // generateCppFixture converts binary data to a C++ byte array
@@ -124,7 +148,16 @@ func generateCppFixture(data []byte) string {
 	buf.WriteString("#include <cstdint>\n")
 	buf.WriteString("#include <cstddef>\n\n")
 	buf.WriteString("const uint8_t FIXTURE_DATA[] = {\n")
+	buf.WriteString(cppByteList(data))
+	buf.WriteString("\n};\n\n")
+	fmt.Fprintf(&buf, "const size_t FIXTURE_SIZE = %d;\n\n", len(data))
+	buf.WriteString("#endif // FIXTURE_HPP\n")
+	return buf.String()
+}
 
+// cppByteList formats data as the body of a C++ byte array initializer
+func cppByteList(data []byte) string {
+	var buf bytes.Buffer
 	for i, b := range data {
 		if i > 0 {
 			buf.WriteString(", ")
@@ -134,10 +167,6 @@ func generateCppFixture(data []byte) string {
 		}
 		fmt.Fprintf(&buf, "0x%02x", b)
 	}
-
-	buf.WriteString("\n};\n\n")
-	fmt.Fprintf(&buf, "const size_t FIXTURE_SIZE = %d;\n\n", len(data))
-	buf.WriteString("#endif // FIXTURE_HPP\n")
 	return buf.String()
 }
 
@@ -205,7 +234,7 @@ int main(int argc, char** argv) {
             // Output JSON for automation
             std::cout << "{"
                       << "\"language\":\"C++\","
-                      << "\"format\":\"ffire\","
+                      << "\"format\":\"{{if .SIMD}}ffire-simd{{else}}ffire{{end}}\","
                       << "\"message\":\"{{.SchemaName}}\","
                       << "\"iterations\":" << iterations << ","
                       << "\"encode_ns\":" << encode_ns << ","
@@ -217,6 +246,9 @@ int main(int argc, char** argv) {
         } else {
             // Print human-readable results
             std::cout << "ffire benchmark: {{.SchemaName}}\n";
+{{- if .SIMD}}
+            std::cout << "SIMD:        " << {{.Namespace}}::simd::isa() << "\n";
+{{- end}}
             std::cout << "Iterations:  " << iterations << "\n";
             std::cout << "Encode:      " << encode_ns << " ns/op\n";
             std::cout << "Decode:      " << decode_ns << " ns/op\n";
@@ -244,8 +276,18 @@ set(CMAKE_CXX_STANDARD_REQUIRED ON)
 # Enable optimizations
 set(CMAKE_CXX_FLAGS_RELEASE "-O3 -march=native")
 set(CMAKE_BUILD_TYPE Release)
-
+{{if .Sanitize}}
+# Sanitizer build: reports memory errors and undefined behavior
+set(CMAKE_CXX_FLAGS "${CMAKE_CXX_FLAGS} {{.Sanitize}}")
+set(CMAKE_EXE_LINKER_FLAGS "${CMAKE_EXE_LINKER_FLAGS} {{.Sanitize}}")
+{{end}}
 add_executable(bench bench.cpp)
+{{- if .SIMD}}
+
+# The same checks without SIMD, for comparison
+add_executable(bench_scalar bench.cpp)
+target_compile_definitions(bench_scalar PRIVATE FFIRE_NO_SIMD)
+{{- end}}
 `))
 
 var makefileTemplate = template.Must(template.New("makefile").Parse(`# Makefile for ffire benchmark
@@ -253,20 +295,31 @@ var makefileTemplate = template.Must(template.New("makefile").Parse(`# Makefile
 
 CXX := $(shell command -v clang++ 2>/dev/null || echo g++)
 CXXFLAGS := -std=c++17 -O3 -march=native -Wall
+{{- if .Sanitize}}
+# Sanitizer build: reports memory errors and undefined behavior
+CXXFLAGS += {{.Sanitize}}
+{{- end}}
 TARGET := bench
 SOURCES := bench.cpp
 HEADERS := generated.hpp fixture.hpp
 
-.PHONY: all clean
+.PHONY: all clean{{if .SIMD}} scalar{{end}}
 
 all: $(TARGET)
 
 $(TARGET): $(SOURCES) $(HEADERS)
 	$(CXX) $(CXXFLAGS) -o $(TARGET) $(SOURCES)
 	@echo "Built $(TARGET) successfully"
+{{- if .SIMD}}
+
+# The same checks without SIMD, for comparison
+scalar: $(SOURCES) $(HEADERS)
+	$(CXX) $(CXXFLAGS) -DFFIRE_NO_SIMD -o $(TARGET)_scalar $(SOURCES)
+	@echo "Built $(TARGET)_scalar successfully"
+{{- end}}
 
 clean:
-	rm -f $(TARGET)
+	rm -f $(TARGET){{if .SIMD}} $(TARGET)_scalar{{end}}
 
 run: $(TARGET)
 	./$(TARGET)
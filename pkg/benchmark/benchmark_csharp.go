@@ -62,6 +62,23 @@ func GenerateCSharp(schema *schema.Schema, schemaName, messageName string, jsonD
 		return fmt.Errorf("failed to write project file: %w", err)
 	}
 
+	// Step 5: Generate the BenchmarkDotNet project, which compares Decode
+	// with TryDecode and the zero-copy view
+	bdnDir := filepath.Join(csharpDir, "BenchmarkDotNet")
+	if err := os.MkdirAll(bdnDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	withView := generator.CSharpHasView(schema, strings.TrimSuffix(messageName, "Message"))
+	bdnFiles := map[string]string{
+		"DecodeBenchmarks.cs":     generateCSharpBenchmarkDotNetCode(namespace, messageName, withView),
+		"DecodeBenchmarks.csproj": generateCSharpBenchmarkDotNetProjectFile(),
+	}
+	for name, content := range bdnFiles {
+		if err := os.WriteFile(filepath.Join(bdnDir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -163,6 +180,82 @@ func generateCSharpProjectFile() string {
     <Nullable>enable</Nullable>
     <ImplicitUsings>enable</ImplicitUsings>
   </PropertyGroup>
+  <ItemGroup>
+    <Compile Remove="BenchmarkDotNet/**" />
+  </ItemGroup>
+</Project>
+`
+}
+
+// generateCSharpBenchmarkDotNetCode generates BenchmarkDotNet benchmarks
+// of decoding the fixture with Decode, TryDecode and, if the message has
+// one, its zero-copy view. The fixture is embedded, since BenchmarkDotNet
+// runs the benchmarks from a directory of its own.
+func generateCSharpBenchmarkDotNetCode(namespace, messageName string, withView bool) string {
+	csMessageName := messageName
+	if !strings.HasSuffix(messageName, "Message") {
+		csMessageName = messageName + "Message"
+	}
+	msg := namespace + "." + csMessageName
+
+	var view string
+	if withView {
+		view = fmt.Sprintf(`
+        [Benchmark]
+        public bool TryDecodeView() => %sView.TryDecode(fixture, out _);
+`, msg)
+	}
+
+	return fmt.Sprintf(`using System.IO;
+using BenchmarkDotNet.Attributes;
+using BenchmarkDotNet.Running;
+
+namespace FFire.Benchmark
+{
+    [MemoryDiagnoser]
+    public class DecodeBenchmarks
+    {
+        private byte[] fixture = Array.Empty<byte>();
+
+        [GlobalSetup]
+        public void Setup()
+        {
+            using var stream = typeof(DecodeBenchmarks).Assembly.GetManifestResourceStream("fixture.bin")!;
+            using var memory = new MemoryStream();
+            stream.CopyTo(memory);
+            fixture = memory.ToArray();
+        }
+
+        [Benchmark(Baseline = true)]
+        public %s Decode() => %s.Decode(fixture);
+
+        [Benchmark]
+        public bool TryDecode() => %s.TryDecode(fixture, out _);
+%s
+        public static void Main(string[] args) => BenchmarkSwitcher.FromAssembly(typeof(DecodeBenchmarks).Assembly).Run(args);
+    }
+}
+`, msg, msg, msg, view)
+}
+
+// generateCSharpBenchmarkDotNetProjectFile generates the .csproj of the
+// BenchmarkDotNet project, which compiles the generated code one
+// directory up.
+func generateCSharpBenchmarkDotNetProjectFile() string {
+	return `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <OutputType>Exe</OutputType>
+    <TargetFramework>net9.0</TargetFramework>
+    <AllowUnsafeBlocks>true</AllowUnsafeBlocks>
+    <Nullable>enable</Nullable>
+    <ImplicitUsings>enable</ImplicitUsings>
+    <Optimize>true</Optimize>
+  </PropertyGroup>
+  <ItemGroup>
+    <Compile Include="../Generated.cs" />
+    <EmbeddedResource Include="../fixture.bin" LogicalName="fixture.bin" />
+    <PackageReference Include="BenchmarkDotNet" Version="0.14.0" />
+  </ItemGroup>
 </Project>
 `
 }
@@ -16,32 +16,27 @@ import (
 
 // GenerateGo creates a complete Go benchmark executable in the output directory.
 func GenerateGo(s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int) error {
+	return generateGo(s, schemaName, messageName, jsonData, outputDir, iterations, false)
+}
+
+// GenerateGoValidated creates a Go benchmark that also times decoding with
+// full validation: a generated pass checking bounds, bool and optional flag
+// values, UTF-8 and element counts runs before the fast decoder. It reports
+// the validated decode time under the format "ffire-validated" and prints
+// the overhead over the fast path.
+func GenerateGoValidated(s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int) error {
+	return generateGo(s, schemaName, messageName, jsonData, outputDir, iterations, true)
+}
+
+func generateGo(s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int, validate bool) error {
 	// Create output directory
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	// Generate the encoder/decoder code as package main
-	generatedCode, err := generator.GenerateGo(s)
-	if err != nil {
-		return fmt.Errorf("failed to generate code: %w", err)
-	}
-
-	// Rewrite package declaration to "main" for single-directory benchmark
-	generatedCodeStr := string(generatedCode)
-	// Find the package line (after the generated comment)
-	packageLine := "package " + s.Package + "\n"
-	packageIdx := strings.Index(generatedCodeStr, packageLine)
-	if packageIdx == -1 {
-		return fmt.Errorf("could not find package declaration in generated code")
-	}
-	// Keep everything before the package line, replace it with "package main", then add the rest
-	generatedCodeStr = generatedCodeStr[:packageIdx] + "package main\n" + generatedCodeStr[packageIdx+len(packageLine):]
-
-	// Write generated code
-	generatedFile := filepath.Join(outputDir, "generated.go")
-	if err := os.WriteFile(generatedFile, []byte(generatedCodeStr), 0644); err != nil {
-		return fmt.Errorf("failed to write generated code: %w", err)
+	if err := writeGoMain(s, outputDir); err != nil {
+		return err
 	}
 
 	// Convert JSON to binary fixture
@@ -71,6 +66,12 @@ func GenerateGo(s *schema.Schema, schemaName string, messageName string, jsonDat
 	// Determine root type name for function naming
 	rootTypeName := getRootTypeName(messageType.TargetType)
 
+	if validate {
+		if err := writeGoValidator(messageType, rootTypeName, outputDir); err != nil {
+			return err
+		}
+	}
+
 	// Generate benchmark main
 	benchData := BenchmarkData{
 		Package:      s.Package,
@@ -79,6 +80,7 @@ func GenerateGo(s *schema.Schema, schemaName string, messageName string, jsonDat
 		TypeName:     rootTypeName,
 		Iterations:   iterations,
 		FixtureBytes: len(binaryData),
+		Validate:     validate,
 	}
 
 	var buf bytes.Buffer
@@ -102,6 +104,32 @@ func GenerateGo(s *schema.Schema, schemaName string, messageName string, jsonDat
 	return nil
 }
 
+// writeGoMain writes the generated Go code for s to generated.go in dir,
+// as package main for a single-directory benchmark.
+func writeGoMain(s *schema.Schema, dir string) error {
+	generatedCode, err := generator.GenerateGo(s)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	// Rewrite package declaration to "main"
+	generatedCodeStr := string(generatedCode)
+	// Find the package line (after the generated comment)
+	packageLine := "package " + s.Package + "\n"
+	packageIdx := strings.Index(generatedCodeStr, packageLine)
+	if packageIdx == -1 {
+		return fmt.Errorf("could not find package declaration in generated code")
+	}
+	// Keep everything before the package line, replace it with "package main", then add the rest
+	generatedCodeStr = generatedCodeStr[:packageIdx] + "package main\n" + generatedCodeStr[packageIdx+len(packageLine):]
+
+	generatedFile := filepath.Join(dir, "generated.go")
+	if err := os.WriteFile(generatedFile, []byte(generatedCodeStr), 0644); err != nil {
+		return fmt.Errorf("failed to write generated code: %w", err)
+	}
+	return nil
+}
+
 // BenchmarkData holds template data for benchmark generation.
 type BenchmarkData struct {
 	Package      string
@@ -110,6 +138,7 @@ type BenchmarkData struct {
 	TypeName     string
 	Iterations   int
 	FixtureBytes int
+	Validate     bool // Also time decoding with full validation (Go only)
 }
 
 // getRootTypeName extracts the type name for function naming.
@@ -193,12 +222,30 @@ func main() {
 	encodeNs := encodeTime.Nanoseconds() / int64(iterations)
 	decodeNs := decodeTime.Nanoseconds() / int64(iterations)
 	totalNs := encodeNs + decodeNs
-	
+	format := "ffire"
+{{if .Validate}}
+	// Benchmark validated decode: the full validation pass, then the fast decoder
+	if err := validate{{.TypeName}}Message(encoded); err != nil {
+		panic(fmt.Sprintf("encoded message failed validation: %v", err))
+	}
+	start = time.Now()
+	for i := 0; i < iterations; i++ {
+		if validate{{.TypeName}}Message(encoded) == nil {
+			_, _ = Decode{{.TypeName}}Message(encoded)
+		}
+	}
+	validatedTime := time.Since(start)
+	fastDecodeNs := decodeNs
+	decodeNs = validatedTime.Nanoseconds() / int64(iterations)
+	totalNs = encodeNs + decodeNs
+	format = "ffire-validated"
+	decodeTime = validatedTime
+{{end}}
 	if jsonOutput {
 		// Output JSON for automation
 		result := BenchResult{
 			Language:    "Go",
-			Format:      "ffire",
+			Format:      format,
 			Message:     "{{.SchemaName}}",
 			Iterations:  iterations,
 			EncodeNs:    encodeNs,
@@ -214,7 +261,12 @@ func main() {
 		fmt.Printf("ffire benchmark: {{.SchemaName}}\n")
 		fmt.Printf("Iterations:  %d\n", iterations)
 		fmt.Printf("Encode:      %d ns/op\n", encodeNs)
+{{- if .Validate}}
+		fmt.Printf("Decode:      %d ns/op (fast path)\n", fastDecodeNs)
+		fmt.Printf("Validated:   %d ns/op (%+.1f%%)\n", decodeNs, float64(decodeNs-fastDecodeNs)*100/float64(max(fastDecodeNs, 1)))
+{{- else}}
 		fmt.Printf("Decode:      %d ns/op\n", decodeNs)
+{{- end}}
 		fmt.Printf("Total:       %d ns/op\n", totalNs)
 		fmt.Printf("Wire size:   %d bytes\n", len(encoded))
 		fmt.Printf("Fixture:     %d bytes\n", len(fixtureData))
@@ -27,7 +27,6 @@ func GenerateIgniffiJS(schema *schema.Schema, schemaName, messageName string, js
 		Platform:  "current",
 		Arch:      "current",
 		NoCompile: false,
-		Verbose:   false,
 	}
 
 	if err := generator.GenerateIgniffiJSPackage(config); err != nil {
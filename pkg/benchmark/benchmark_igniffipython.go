@@ -27,7 +27,6 @@ func GenerateIgniffiPython(schema *schema.Schema, schemaName, messageName string
 		Platform:  "current",
 		Arch:      "current",
 		NoCompile: true, // Don't compile yet - user needs to pip install
-		Verbose:   false,
 	}
 
 	if err := generator.GenerateIgniffiPythonPackage(config); err != nil {
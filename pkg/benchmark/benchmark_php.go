@@ -28,7 +28,6 @@ func GeneratePHP(schema *schema.Schema, schemaName, messageName string, jsonData
 		Platform:  "current",
 		Arch:      "current",
 		NoCompile: false,
-		Verbose:   false,
 	}
 
 	if err := generator.GeneratePackage(config); err != nil {
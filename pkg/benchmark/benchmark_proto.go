@@ -0,0 +1,177 @@
+package benchmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/shaban/ffire/pkg/export"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// GenerateProto creates a Go protobuf benchmark for comparison with ffire:
+// bench.proto derived from the schema (see export.Proto), a driver that
+// parses the JSON fixture with protojson and times proto.Marshal and
+// proto.Unmarshal, and a go.mod. The Go code for bench.proto is left to
+// protoc:
+//
+//	protoc --go_out=. --go_opt=module=protobench bench.proto
+func GenerateProto(s *schema.Schema, schemaName string, messageName string, jsonData []byte, outputDir string, iterations int) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+	if messageType == nil {
+		return fmt.Errorf("message type %s not found", messageName)
+	}
+
+	protoFile, err := export.Proto(s, export.Options{GoPackage: "protobench/pb"})
+	if err != nil {
+		return fmt.Errorf("failed to generate proto file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.proto"), protoFile, 0644); err != nil {
+		return fmt.Errorf("failed to write bench.proto: %w", err)
+	}
+
+	// Messages whose root is not a struct are wrapped, so their JSON is too
+	fixtureJSON := jsonData
+	if field := export.ProtoWrapper(*messageType); field != "" {
+		wrapped, err := json.Marshal(map[string]json.RawMessage{field: jsonData})
+		if err != nil {
+			return fmt.Errorf("failed to wrap fixture: %w", err)
+		}
+		fixtureJSON = wrapped
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "fixture.json"), fixtureJSON, 0644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	benchData := BenchmarkData{
+		Package:      s.Package,
+		SchemaName:   schemaName,
+		MessageName:  messageName,
+		TypeName:     messageName,
+		Iterations:   iterations,
+		FixtureBytes: len(jsonData),
+	}
+	var buf bytes.Buffer
+	if err := protoBenchTemplate.Execute(&buf, benchData); err != nil {
+		return fmt.Errorf("failed to generate benchmark: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.go"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark main: %w", err)
+	}
+
+	goMod := "module protobench\n\ngo 1.21\n\nrequire google.golang.org/protobuf v1.31.0\n"
+	if err := os.WriteFile(filepath.Join(outputDir, "go.mod"), []byte(goMod), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+
+	return nil
+}
+
+var protoBenchTemplate = template.Must(template.New("bench").Parse(`package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"protobench/pb"
+)
+
+//go:embed fixture.json
+var fixtureJSON []byte
+
+type BenchResult struct {
+	Language    string ` + "`json:\"language\"`" + `
+	Format      string ` + "`json:\"format\"`" + `
+	Message     string ` + "`json:\"message\"`" + `
+	Iterations  int    ` + "`json:\"iterations\"`" + `
+	EncodeNs    int64  ` + "`json:\"encode_ns\"`" + `
+	DecodeNs    int64  ` + "`json:\"decode_ns\"`" + `
+	TotalNs     int64  ` + "`json:\"total_ns\"`" + `
+	WireSize    int    ` + "`json:\"wire_size\"`" + `
+	FixtureSize int    ` + "`json:\"fixture_size\"`" + `
+	Timestamp   string ` + "`json:\"timestamp\"`" + `
+}
+
+func main() {
+	iterations := {{.Iterations}}
+	jsonOutput := os.Getenv("BENCH_JSON") == "1"
+
+	// Parse the fixture with protojson, which reads the ffire JSON names
+	msg := &pb.{{.TypeName}}{}
+	if err := protojson.Unmarshal(fixtureJSON, msg); err != nil {
+		panic(fmt.Sprintf("failed to parse fixture: %v", err))
+	}
+
+	// Warmup
+	for i := 0; i < 1000; i++ {
+		encoded, _ := proto.Marshal(msg)
+		_ = proto.Unmarshal(encoded, &pb.{{.TypeName}}{})
+	}
+
+	// Benchmark encode
+	start := time.Now()
+	var encoded []byte
+	for i := 0; i < iterations; i++ {
+		encoded, _ = proto.Marshal(msg)
+	}
+	encodeTime := time.Since(start)
+
+	// Benchmark decode
+	start = time.Now()
+	for i := 0; i < iterations; i++ {
+		_ = proto.Unmarshal(encoded, &pb.{{.TypeName}}{})
+	}
+	decodeTime := time.Since(start)
+
+	// Calculate metrics
+	encodeNs := encodeTime.Nanoseconds() / int64(iterations)
+	decodeNs := decodeTime.Nanoseconds() / int64(iterations)
+	totalNs := encodeNs + decodeNs
+
+	if jsonOutput {
+		// Output JSON for automation
+		result := BenchResult{
+			Language:    "Go",
+			Format:      "proto",
+			Message:     "{{.SchemaName}}",
+			Iterations:  iterations,
+			EncodeNs:    encodeNs,
+			DecodeNs:    decodeNs,
+			TotalNs:     totalNs,
+			WireSize:    len(encoded),
+			FixtureSize: {{.FixtureBytes}},
+			Timestamp:   time.Now().Format(time.RFC3339),
+		}
+		json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		// Print human-readable results
+		fmt.Printf("proto benchmark: {{.SchemaName}}\n")
+		fmt.Printf("Iterations:  %d\n", iterations)
+		fmt.Printf("Encode:      %d ns/op\n", encodeNs)
+		fmt.Printf("Decode:      %d ns/op\n", decodeNs)
+		fmt.Printf("Total:       %d ns/op\n", totalNs)
+		fmt.Printf("Wire size:   %d bytes\n", len(encoded))
+		fmt.Printf("Fixture:     %d bytes\n", {{.FixtureBytes}})
+		fmt.Printf("Total time:  %.2fs\n", (encodeTime + decodeTime).Seconds())
+	}
+}
+`))
@@ -28,7 +28,6 @@ func GenerateRust(schema *schema.Schema, schemaName, messageName string, jsonDat
 		Platform:  "current",
 		Arch:      "current",
 		NoCompile: false,
-		Verbose:   false,
 	}
 
 	if err := generator.GeneratePackage(config); err != nil {
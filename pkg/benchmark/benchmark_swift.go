@@ -34,7 +34,6 @@ func GenerateSwift(schema *schema.Schema, schemaName, messageName string, jsonDa
 		Platform:  "current",
 		Arch:      "current",
 		NoCompile: false,
-		Verbose:   false,
 	}
 
 	if err := generator.GeneratePackage(config); err != nil {
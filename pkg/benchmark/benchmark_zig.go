@@ -27,7 +27,6 @@ func GenerateZig(schema *schema.Schema, schemaName, messageName string, jsonData
 		Platform:  "current",
 		Arch:      "current",
 		NoCompile: false,
-		Verbose:   false,
 	}
 
 	if err := generator.GeneratePackage(config); err != nil {
@@ -0,0 +1,440 @@
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Mixed benchmarks interleave several message types in one loop, each
+// iteration dispatching on a type tag as a service receiving heterogeneous
+// traffic would. The tag sequence is pseudo-random (fixed seed), so branch
+// prediction cannot learn it the way it learns a single-schema loop.
+
+// MixedSuite is one message type taking part in a mixed benchmark.
+type MixedSuite struct {
+	Name        string // Identifier, e.g. the schema file name; must be unique
+	Schema      *schema.Schema
+	MessageName string
+	JSONData    []byte
+}
+
+// MixedSequenceLength is the number of tags in the interleaving sequence.
+const MixedSequenceLength = 4096
+
+// MixedData holds template data for mixed benchmark generation.
+type MixedData struct {
+	Suites     []MixedSuiteData
+	Sequence   string // Comma-separated suite indices
+	Iterations int
+	AvgSize    int // Mean payload size over the sequence
+}
+
+// MixedSuiteData holds per-suite template data.
+type MixedSuiteData struct {
+	Index     int
+	Name      string
+	Namespace string
+	TypeName  string
+	Fixture   []byte
+}
+
+// mixedSequence returns a deterministic pseudo-random sequence of suite
+// indices (xorshift32, fixed seed) and the mean payload size over it.
+func mixedSequence(suites []MixedSuiteData) (string, int) {
+	var sb strings.Builder
+	state := uint32(2463534242)
+	total := 0
+	for i := 0; i < MixedSequenceLength; i++ {
+		state ^= state << 13
+		state ^= state >> 17
+		state ^= state << 5
+		idx := int(state % uint32(len(suites)))
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if i%32 == 0 {
+			sb.WriteString("\n\t")
+		}
+		fmt.Fprintf(&sb, "%d", idx)
+		total += len(suites[idx].Fixture)
+	}
+	return sb.String(), total / MixedSequenceLength
+}
+
+// prepareMixed canonicalizes each schema, renames its package to a unique
+// namespace (testdata schemas all share one package name) and encodes the
+// fixtures.
+func prepareMixed(suites []MixedSuite, iterations int) (MixedData, error) {
+	if len(suites) < 2 {
+		return MixedData{}, fmt.Errorf("a mixed benchmark needs at least two message types, got %d", len(suites))
+	}
+	if len(suites) > 256 {
+		return MixedData{}, fmt.Errorf("a mixed benchmark supports at most 256 message types, got %d", len(suites))
+	}
+
+	seen := make(map[string]bool)
+	data := MixedData{Iterations: iterations}
+	for i, suite := range suites {
+		namespace := "mixed_" + identifier(suite.Name)
+		if seen[namespace] {
+			return MixedData{}, fmt.Errorf("duplicate suite name %q", suite.Name)
+		}
+		seen[namespace] = true
+
+		var messageType *schema.MessageType
+		for j := range suite.Schema.Messages {
+			if suite.Schema.Messages[j].Name == suite.MessageName {
+				messageType = &suite.Schema.Messages[j]
+				break
+			}
+		}
+		if messageType == nil {
+			return MixedData{}, fmt.Errorf("%s: message type %s not found", suite.Name, suite.MessageName)
+		}
+
+		suite.Schema.Canonicalize()
+		suite.Schema.Package = namespace
+
+		binaryData, err := fixture.Convert(suite.Schema, suite.MessageName, suite.JSONData)
+		if err != nil {
+			return MixedData{}, fmt.Errorf("%s: failed to convert fixture: %w", suite.Name, err)
+		}
+
+		data.Suites = append(data.Suites, MixedSuiteData{
+			Index:     i,
+			Name:      suite.Name,
+			Namespace: suite.Schema.Package,
+			TypeName:  getRootTypeName(messageType.TargetType),
+			Fixture:   binaryData,
+		})
+	}
+	data.Sequence, data.AvgSize = mixedSequence(data.Suites)
+	return data, nil
+}
+
+// identifier lowercases name and replaces anything but letters and digits
+// with underscores, for use in package and namespace names.
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// GenerateGoMixed creates a Go benchmark interleaving all suites. Each
+// suite's code is generated into its own package. The suites' schemas are
+// modified (canonicalized and renamed).
+func GenerateGoMixed(suites []MixedSuite, outputDir string, iterations int) error {
+	data, err := prepareMixed(suites, iterations)
+	if err != nil {
+		return err
+	}
+
+	for i, suite := range suites {
+		pkgDir := filepath.Join(outputDir, data.Suites[i].Namespace)
+		if err := os.MkdirAll(pkgDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		code, err := generator.GenerateGo(suite.Schema)
+		if err != nil {
+			return fmt.Errorf("%s: failed to generate code: %w", suite.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(pkgDir, "generated.go"), code, 0644); err != nil {
+			return fmt.Errorf("failed to write generated code: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, fmt.Sprintf("fixture_%d.bin", i)), data.Suites[i].Fixture, 0644); err != nil {
+			return fmt.Errorf("failed to write fixture: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := goMixedTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to generate benchmark: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.go"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark main: %w", err)
+	}
+
+	goMod := "module bench\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(outputDir, "go.mod"), []byte(goMod), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+	return nil
+}
+
+// GenerateCppMixed creates a C++ benchmark interleaving all suites, one
+// header and namespace per suite. The suites' schemas are modified
+// (canonicalized and renamed).
+func GenerateCppMixed(suites []MixedSuite, outputDir string, iterations int) error {
+	data, err := prepareMixed(suites, iterations)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var fixtures bytes.Buffer
+	fixtures.WriteString("#ifndef FIXTURE_HPP\n#define FIXTURE_HPP\n\n#include <cstdint>\n#include <cstddef>\n")
+	for i, suite := range suites {
+		code, err := generator.GenerateCpp(suite.Schema)
+		if err != nil {
+			return fmt.Errorf("%s: failed to generate code: %w", suite.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, data.Suites[i].Namespace+".hpp"), code, 0644); err != nil {
+			return fmt.Errorf("failed to write generated code: %w", err)
+		}
+
+		fmt.Fprintf(&fixtures, "\nconst uint8_t FIXTURE_%d_DATA[] = {\n%s\n};\n", i, cppByteList(data.Suites[i].Fixture))
+		fmt.Fprintf(&fixtures, "const size_t FIXTURE_%d_SIZE = %d;\n", i, len(data.Suites[i].Fixture))
+	}
+	fixtures.WriteString("\n#endif // FIXTURE_HPP\n")
+	if err := os.WriteFile(filepath.Join(outputDir, "fixture.hpp"), fixtures.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cppMixedTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to generate benchmark: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.cpp"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark main: %w", err)
+	}
+
+	var cmakeBuf bytes.Buffer
+	if err := cmakeTemplate.Execute(&cmakeBuf, data); err != nil {
+		return fmt.Errorf("failed to generate CMakeLists.txt: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "CMakeLists.txt"), cmakeBuf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write CMakeLists.txt: %w", err)
+	}
+
+	var makeBuf bytes.Buffer
+	if err := makefileTemplate.Execute(&makeBuf, data); err != nil {
+		return fmt.Errorf("failed to generate Makefile: %w", err)
+	}
+	makefile := strings.Replace(makeBuf.String(), "generated.hpp fixture.hpp", "$(wildcard *.hpp)", 1)
+	if err := os.WriteFile(filepath.Join(outputDir, "Makefile"), []byte(makefile), 0644); err != nil {
+		return fmt.Errorf("failed to write Makefile: %w", err)
+	}
+	return nil
+}
+
+var goMixedTemplate = template.Must(template.New("gomixed").Parse(`package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+{{range .Suites}}
+	{{.Namespace}} "bench/{{.Namespace}}"{{end}}
+)
+{{range .Suites}}
+//go:embed fixture_{{.Index}}.bin
+var fixture{{.Index}} []byte
+{{end}}
+// Suite index per message, pseudo-random with a fixed seed
+var sequence = [...]uint8{ {{- .Sequence}},
+}
+
+type BenchResult struct {
+	Language    string ` + "`json:\"language\"`" + `
+	Format      string ` + "`json:\"format\"`" + `
+	Message     string ` + "`json:\"message\"`" + `
+	Iterations  int    ` + "`json:\"iterations\"`" + `
+	EncodeNs    int64  ` + "`json:\"encode_ns\"`" + `
+	DecodeNs    int64  ` + "`json:\"decode_ns\"`" + `
+	TotalNs     int64  ` + "`json:\"total_ns\"`" + `
+	WireSize    int    ` + "`json:\"wire_size\"`" + `
+	FixtureSize int    ` + "`json:\"fixture_size\"`" + `
+	Timestamp   string ` + "`json:\"timestamp\"`" + `
+}
+
+func main() {
+	iterations := {{.Iterations}}
+	jsonOutput := os.Getenv("BENCH_JSON") == "1"
+{{range .Suites}}
+	original{{.Index}}, err := {{.Namespace}}.Decode{{.TypeName}}Message(fixture{{.Index}})
+	if err != nil {
+		panic(fmt.Sprintf("failed to decode {{.Name}} fixture: %v", err))
+	}
+	var encoded{{.Index}} []byte
+{{end}}
+	encodeAll := func(n int) {
+		for i := 0; i < n; i++ {
+			switch sequence[i%len(sequence)] {
+{{- range .Suites}}
+			case {{.Index}}:
+				encoded{{.Index}} = {{.Namespace}}.Encode{{.TypeName}}Message(original{{.Index}})
+{{- end}}
+			}
+		}
+	}
+	decodeAll := func(n int) {
+		for i := 0; i < n; i++ {
+			switch sequence[i%len(sequence)] {
+{{- range .Suites}}
+			case {{.Index}}:
+				_, _ = {{.Namespace}}.Decode{{.TypeName}}Message(encoded{{.Index}})
+{{- end}}
+			}
+		}
+	}
+
+	// Warmup (also fills every encoded buffer)
+	encodeAll(len(sequence))
+	decodeAll(len(sequence))
+
+	start := time.Now()
+	encodeAll(iterations)
+	encodeTime := time.Since(start)
+
+	start = time.Now()
+	decodeAll(iterations)
+	decodeTime := time.Since(start)
+
+	encodeNs := encodeTime.Nanoseconds() / int64(iterations)
+	decodeNs := decodeTime.Nanoseconds() / int64(iterations)
+	totalNs := encodeNs + decodeNs
+
+	if jsonOutput {
+		result := BenchResult{
+			Language:    "Go",
+			Format:      "ffire",
+			Message:     "mixed",
+			Iterations:  iterations,
+			EncodeNs:    encodeNs,
+			DecodeNs:    decodeNs,
+			TotalNs:     totalNs,
+			WireSize:    {{.AvgSize}},
+			FixtureSize: {{.AvgSize}},
+			Timestamp:   time.Now().Format(time.RFC3339),
+		}
+		json.NewEncoder(os.Stdout).Encode(result)
+	} else {
+		fmt.Printf("ffire mixed benchmark: {{len .Suites}} message types\n")
+		fmt.Printf("Iterations:  %d\n", iterations)
+		fmt.Printf("Encode:      %d ns/op\n", encodeNs)
+		fmt.Printf("Decode:      %d ns/op\n", decodeNs)
+		fmt.Printf("Total:       %d ns/op\n", totalNs)
+		fmt.Printf("Avg size:    %d bytes\n", {{.AvgSize}})
+		fmt.Printf("Total time:  %.2fs\n", (encodeTime + decodeTime).Seconds())
+	}
+}
+`))
+
+var cppMixedTemplate = template.Must(template.New("cppmixed").Funcs(template.FuncMap{
+	"ToLower": strings.ToLower,
+}).Parse(`#include <chrono>
+#include <cstdint>
+#include <cstdlib>
+#include <iomanip>
+#include <iostream>
+#include <vector>
+{{- range .Suites}}
+#include "{{.Namespace}}.hpp"
+{{- end}}
+#include "fixture.hpp"
+
+using namespace std::chrono;
+
+// Suite index per message, pseudo-random with a fixed seed
+static const uint8_t SEQUENCE[] = { {{- .Sequence}},
+};
+static const size_t SEQUENCE_SIZE = sizeof(SEQUENCE);
+
+int main(int argc, char** argv) {
+    const int iterations = {{.Iterations}};
+    const bool json_output = std::getenv("BENCH_JSON") != nullptr;
+
+    try {
+{{- range .Suites}}
+        auto original{{.Index}} = {{.Namespace}}::decode_{{.TypeName | ToLower}}_message(FIXTURE_{{.Index}}_DATA, FIXTURE_{{.Index}}_SIZE);
+        std::vector<uint8_t> encoded{{.Index}};
+{{- end}}
+
+        auto encode_all = [&](int n) {
+            for (int i = 0; i < n; ++i) {
+                switch (SEQUENCE[i % SEQUENCE_SIZE]) {
+{{- range .Suites}}
+                case {{.Index}}:
+                    encoded{{.Index}} = {{.Namespace}}::encode_{{.TypeName | ToLower}}_message(original{{.Index}});
+                    break;
+{{- end}}
+                }
+            }
+        };
+        auto decode_all = [&](int n) {
+            for (int i = 0; i < n; ++i) {
+                switch (SEQUENCE[i % SEQUENCE_SIZE]) {
+{{- range .Suites}}
+                case {{.Index}}: {
+                    auto decoded = {{.Namespace}}::decode_{{.TypeName | ToLower}}_message(encoded{{.Index}});
+                    break;
+                }
+{{- end}}
+                }
+            }
+        };
+
+        // Warmup (also fills every encoded buffer)
+        encode_all(SEQUENCE_SIZE);
+        decode_all(SEQUENCE_SIZE);
+
+        auto encode_start = high_resolution_clock::now();
+        encode_all(iterations);
+        auto encode_time = duration_cast<nanoseconds>(high_resolution_clock::now() - encode_start).count();
+
+        auto decode_start = high_resolution_clock::now();
+        decode_all(iterations);
+        auto decode_time = duration_cast<nanoseconds>(high_resolution_clock::now() - decode_start).count();
+
+        int64_t encode_ns = encode_time / iterations;
+        int64_t decode_ns = decode_time / iterations;
+        int64_t total_ns = encode_ns + decode_ns;
+
+        if (json_output) {
+            std::cout << "{"
+                      << "\"language\":\"C++\","
+                      << "\"format\":\"ffire\","
+                      << "\"message\":\"mixed\","
+                      << "\"iterations\":" << iterations << ","
+                      << "\"encode_ns\":" << encode_ns << ","
+                      << "\"decode_ns\":" << decode_ns << ","
+                      << "\"total_ns\":" << total_ns << ","
+                      << "\"wire_size\":{{.AvgSize}},"
+                      << "\"fixture_size\":{{.AvgSize}}"
+                      << "}\n";
+        } else {
+            std::cout << "ffire mixed benchmark: {{len .Suites}} message types\n";
+            std::cout << "Iterations:  " << iterations << "\n";
+            std::cout << "Encode:      " << encode_ns << " ns/op\n";
+            std::cout << "Decode:      " << decode_ns << " ns/op\n";
+            std::cout << "Total:       " << total_ns << " ns/op\n";
+            std::cout << "Avg size:    {{.AvgSize}} bytes\n";
+            std::cout << "Total time:  " << std::fixed << std::setprecision(2)
+                      << (encode_time + decode_time) / 1e9 << "s\n";
+        }
+        return 0;
+    } catch (const std::exception& e) {
+        std::cerr << "Error: " << e.what() << "\n";
+        return 1;
+    }
+}
+`))
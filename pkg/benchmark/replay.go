@@ -0,0 +1,449 @@
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Replay benchmarks complement the tight-loop benchmarks: captured payloads
+// are replayed at a fixed message rate, and each message is decoded and
+// re-encoded as a service handling it would. Latency is measured from the
+// time a message was scheduled, not from when it was picked up, so a slow
+// message also delays the ones queued behind it (no coordinated omission).
+// Results are reported as percentiles.
+
+// ReplayConfig controls a replay benchmark.
+type ReplayConfig struct {
+	Rate     int           // Messages per second
+	Duration time.Duration // How long to replay; payloads are cycled
+}
+
+// ReplayData holds template data for replay benchmark generation.
+type ReplayData struct {
+	Namespace  string
+	SchemaName string
+	TypeName   string
+	Rate       int
+	Messages   int
+	Payloads   int
+}
+
+// LoadReplayDir reads every .bin file in dir (sorted by name) and checks
+// that each decodes as messageName. s must use canonical field order.
+func LoadReplayDir(s *schema.Schema, messageName, dir string) ([][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replay directory: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".bin") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no .bin payloads in %s", dir)
+	}
+
+	payloads := make([][]byte, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fixture.Decode(s, messageName, data); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		payloads = append(payloads, data)
+	}
+	return payloads, nil
+}
+
+func newReplayData(s *schema.Schema, schemaName, messageName string, payloads [][]byte, cfg ReplayConfig) (ReplayData, error) {
+	if cfg.Rate <= 0 {
+		return ReplayData{}, fmt.Errorf("replay rate must be positive")
+	}
+	messages := int(cfg.Duration.Seconds() * float64(cfg.Rate))
+	if messages <= 0 {
+		return ReplayData{}, fmt.Errorf("replay duration %s is too short for %d msg/s", cfg.Duration, cfg.Rate)
+	}
+
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			return ReplayData{
+				Namespace:  s.Package,
+				SchemaName: schemaName,
+				TypeName:   getRootTypeName(s.Messages[i].TargetType),
+				Rate:       cfg.Rate,
+				Messages:   messages,
+				Payloads:   len(payloads),
+			}, nil
+		}
+	}
+	return ReplayData{}, fmt.Errorf("message type %s not found", messageName)
+}
+
+func writePayloads(dir string, payloads [][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create payload directory: %w", err)
+	}
+	for i, data := range payloads {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%06d.bin", i)), data, 0644); err != nil {
+			return fmt.Errorf("failed to write payload: %w", err)
+		}
+	}
+	return nil
+}
+
+// GenerateGoReplay creates a Go replay benchmark in the output directory.
+// Payloads are embedded in the executable.
+func GenerateGoReplay(s *schema.Schema, schemaName, messageName string, payloads [][]byte, outputDir string, cfg ReplayConfig) error {
+	data, err := newReplayData(s, schemaName, messageName, payloads, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	generatedCode, err := generator.GenerateGo(s)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	// Rewrite package declaration to "main" for single-directory benchmark
+	packageLine := "package " + s.Package + "\n"
+	code := strings.Replace(string(generatedCode), packageLine, "package main\n", 1)
+	if err := os.WriteFile(filepath.Join(outputDir, "generated.go"), []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write generated code: %w", err)
+	}
+
+	if err := writePayloads(filepath.Join(outputDir, "replay"), payloads); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := goReplayTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to generate benchmark: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.go"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark main: %w", err)
+	}
+
+	goMod := "module bench\n\ngo 1.21\n"
+	if err := os.WriteFile(filepath.Join(outputDir, "go.mod"), []byte(goMod), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+	return nil
+}
+
+// GenerateCppReplay creates a C++ replay benchmark in the output directory.
+// Payloads are written to replay/ and loaded at startup, so large captures
+// don't have to be compiled in.
+func GenerateCppReplay(s *schema.Schema, schemaName, messageName string, payloads [][]byte, outputDir string, cfg ReplayConfig) error {
+	data, err := newReplayData(s, schemaName, messageName, payloads, cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	generatedCode, err := generator.GenerateCpp(s)
+	if err != nil {
+		return fmt.Errorf("failed to generate code: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "generated.hpp"), generatedCode, 0644); err != nil {
+		return fmt.Errorf("failed to write generated code: %w", err)
+	}
+
+	if err := writePayloads(filepath.Join(outputDir, "replay"), payloads); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := cppReplayTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to generate benchmark: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "bench.cpp"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write benchmark main: %w", err)
+	}
+
+	var cmakeBuf bytes.Buffer
+	if err := cmakeTemplate.Execute(&cmakeBuf, data); err != nil {
+		return fmt.Errorf("failed to generate CMakeLists.txt: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "CMakeLists.txt"), cmakeBuf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write CMakeLists.txt: %w", err)
+	}
+
+	var makeBuf bytes.Buffer
+	if err := makefileTemplate.Execute(&makeBuf, data); err != nil {
+		return fmt.Errorf("failed to generate Makefile: %w", err)
+	}
+	// No fixture.hpp: payloads are loaded at runtime
+	makefile := strings.Replace(makeBuf.String(), "generated.hpp fixture.hpp", "generated.hpp", 1)
+	if err := os.WriteFile(filepath.Join(outputDir, "Makefile"), []byte(makefile), 0644); err != nil {
+		return fmt.Errorf("failed to write Makefile: %w", err)
+	}
+	return nil
+}
+
+var goReplayTemplate = template.Must(template.New("goreplay").Parse(`package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+//go:embed replay
+var replayFS embed.FS
+
+type ReplayResult struct {
+	Language      string  ` + "`json:\"language\"`" + `
+	Format        string  ` + "`json:\"format\"`" + `
+	Mode          string  ` + "`json:\"mode\"`" + `
+	Message       string  ` + "`json:\"message\"`" + `
+	Rate          int     ` + "`json:\"rate\"`" + `
+	Messages      int     ` + "`json:\"messages\"`" + `
+	Payloads      int     ` + "`json:\"payloads\"`" + `
+	AchievedRate  float64 ` + "`json:\"achieved_rate\"`" + `
+	P50Ns         int64   ` + "`json:\"p50_ns\"`" + `
+	P90Ns         int64   ` + "`json:\"p90_ns\"`" + `
+	P99Ns         int64   ` + "`json:\"p99_ns\"`" + `
+	P999Ns        int64   ` + "`json:\"p999_ns\"`" + `
+	MaxNs         int64   ` + "`json:\"max_ns\"`" + `
+	ServiceMeanNs int64   ` + "`json:\"service_mean_ns\"`" + `
+	Timestamp     string  ` + "`json:\"timestamp\"`" + `
+}
+
+func percentile(sorted []int64, p float64) int64 {
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+func main() {
+	rate := {{.Rate}}
+	messages := {{.Messages}}
+	jsonOutput := os.Getenv("BENCH_JSON") == "1"
+
+	entries, err := replayFS.ReadDir("replay")
+	if err != nil {
+		panic(err)
+	}
+	var payloads [][]byte
+	for _, e := range entries {
+		data, err := replayFS.ReadFile("replay/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		payloads = append(payloads, data)
+	}
+
+	// Warmup
+	for i := 0; i < 1000; i++ {
+		decoded, err := Decode{{.TypeName}}Message(payloads[i%len(payloads)])
+		if err != nil {
+			panic(fmt.Sprintf("failed to decode payload %d: %v", i%len(payloads), err))
+		}
+		_ = Encode{{.TypeName}}Message(decoded)
+	}
+
+	interval := time.Second / time.Duration(rate)
+	latencies := make([]int64, messages)
+	var serviceTotal time.Duration
+
+	start := time.Now()
+	for i := 0; i < messages; i++ {
+		scheduled := start.Add(time.Duration(i) * interval)
+
+		// Sleep until close to the scheduled time, then spin for precision
+		if wait := time.Until(scheduled); wait > 200*time.Microsecond {
+			time.Sleep(wait - 100*time.Microsecond)
+		}
+		for time.Now().Before(scheduled) {
+		}
+
+		begin := time.Now()
+		decoded, _ := Decode{{.TypeName}}Message(payloads[i%len(payloads)])
+		_ = Encode{{.TypeName}}Message(decoded)
+		end := time.Now()
+
+		serviceTotal += end.Sub(begin)
+		latencies[i] = end.Sub(scheduled).Nanoseconds()
+	}
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+	result := ReplayResult{
+		Language:      "Go",
+		Format:        "ffire",
+		Mode:          "replay",
+		Message:       "{{.SchemaName}}",
+		Rate:          rate,
+		Messages:      messages,
+		Payloads:      len(payloads),
+		AchievedRate:  float64(messages) / elapsed.Seconds(),
+		P50Ns:         percentile(latencies, 0.50),
+		P90Ns:         percentile(latencies, 0.90),
+		P99Ns:         percentile(latencies, 0.99),
+		P999Ns:        percentile(latencies, 0.999),
+		MaxNs:         latencies[len(latencies)-1],
+		ServiceMeanNs: serviceTotal.Nanoseconds() / int64(messages),
+		Timestamp:     time.Now().Format(time.RFC3339),
+	}
+
+	if jsonOutput {
+		json.NewEncoder(os.Stdout).Encode(result)
+		return
+	}
+
+	fmt.Printf("ffire replay benchmark: {{.SchemaName}}\n")
+	fmt.Printf("Payloads:    %d\n", result.Payloads)
+	fmt.Printf("Messages:    %d at %d msg/s (achieved %.0f msg/s)\n", messages, rate, result.AchievedRate)
+	fmt.Printf("Service:     %d ns mean (decode + encode)\n", result.ServiceMeanNs)
+	fmt.Printf("Latency p50:   %d ns\n", result.P50Ns)
+	fmt.Printf("Latency p90:   %d ns\n", result.P90Ns)
+	fmt.Printf("Latency p99:   %d ns\n", result.P99Ns)
+	fmt.Printf("Latency p99.9: %d ns\n", result.P999Ns)
+	fmt.Printf("Latency max:   %d ns\n", result.MaxNs)
+}
+`))
+
+var cppReplayTemplate = template.Must(template.New("cppreplay").Funcs(template.FuncMap{
+	"ToLower": strings.ToLower,
+}).Parse(`#include <algorithm>
+#include <chrono>
+#include <cstdlib>
+#include <filesystem>
+#include <fstream>
+#include <iostream>
+#include <iterator>
+#include <string>
+#include <thread>
+#include <vector>
+#include "generated.hpp"
+
+using namespace std::chrono;
+
+static int64_t percentile(const std::vector<int64_t>& sorted, double p) {
+    return sorted[static_cast<size_t>(p * (sorted.size() - 1))];
+}
+
+int main(int argc, char** argv) {
+    const int rate = {{.Rate}};
+    const int messages = {{.Messages}};
+    const bool json_output = std::getenv("BENCH_JSON") != nullptr;
+    const std::string dir = argc > 1 ? argv[1] : "replay";
+
+    try {
+        std::vector<std::string> files;
+        for (const auto& entry : std::filesystem::directory_iterator(dir)) {
+            if (entry.path().extension() == ".bin") {
+                files.push_back(entry.path().string());
+            }
+        }
+        std::sort(files.begin(), files.end());
+        if (files.empty()) {
+            std::cerr << "Error: no .bin payloads in " << dir << "\n";
+            return 1;
+        }
+
+        std::vector<std::vector<uint8_t>> payloads;
+        for (const auto& file : files) {
+            std::ifstream in(file, std::ios::binary);
+            payloads.emplace_back(std::istreambuf_iterator<char>(in), std::istreambuf_iterator<char>());
+        }
+
+        // Warmup
+        for (int i = 0; i < 1000; ++i) {
+            const auto& payload = payloads[i % payloads.size()];
+            auto decoded = {{.Namespace}}::decode_{{.TypeName | ToLower}}_message(payload.data(), payload.size());
+            auto encoded = {{.Namespace}}::encode_{{.TypeName | ToLower}}_message(decoded);
+        }
+
+        const auto interval = nanoseconds(1000000000LL / rate);
+        std::vector<int64_t> latencies(messages);
+        int64_t service_total = 0;
+
+        const auto start = steady_clock::now();
+        for (int i = 0; i < messages; ++i) {
+            const auto scheduled = start + interval * i;
+
+            // Sleep until close to the scheduled time, then spin for precision
+            auto wait = scheduled - steady_clock::now();
+            if (wait > microseconds(200)) {
+                std::this_thread::sleep_for(wait - microseconds(100));
+            }
+            while (steady_clock::now() < scheduled) {
+            }
+
+            const auto begin = steady_clock::now();
+            const auto& payload = payloads[i % payloads.size()];
+            auto decoded = {{.Namespace}}::decode_{{.TypeName | ToLower}}_message(payload.data(), payload.size());
+            auto encoded = {{.Namespace}}::encode_{{.TypeName | ToLower}}_message(decoded);
+            const auto end = steady_clock::now();
+
+            service_total += duration_cast<nanoseconds>(end - begin).count();
+            latencies[i] = duration_cast<nanoseconds>(end - scheduled).count();
+        }
+        const double elapsed = duration<double>(steady_clock::now() - start).count();
+
+        std::sort(latencies.begin(), latencies.end());
+        const double achieved = messages / elapsed;
+        const int64_t service_mean = service_total / messages;
+
+        if (json_output) {
+            std::cout << "{"
+                      << "\"language\":\"C++\","
+                      << "\"format\":\"ffire\","
+                      << "\"mode\":\"replay\","
+                      << "\"message\":\"{{.SchemaName}}\","
+                      << "\"rate\":" << rate << ","
+                      << "\"messages\":" << messages << ","
+                      << "\"payloads\":" << payloads.size() << ","
+                      << "\"achieved_rate\":" << achieved << ","
+                      << "\"p50_ns\":" << percentile(latencies, 0.50) << ","
+                      << "\"p90_ns\":" << percentile(latencies, 0.90) << ","
+                      << "\"p99_ns\":" << percentile(latencies, 0.99) << ","
+                      << "\"p999_ns\":" << percentile(latencies, 0.999) << ","
+                      << "\"max_ns\":" << latencies.back() << ","
+                      << "\"service_mean_ns\":" << service_mean
+                      << "}\n";
+        } else {
+            std::cout << "ffire replay benchmark: {{.SchemaName}}\n";
+            std::cout << "Payloads:    " << payloads.size() << "\n";
+            std::cout << "Messages:    " << messages << " at " << rate << " msg/s (achieved "
+                      << static_cast<int64_t>(achieved) << " msg/s)\n";
+            std::cout << "Service:     " << service_mean << " ns mean (decode + encode)\n";
+            std::cout << "Latency p50:   " << percentile(latencies, 0.50) << " ns\n";
+            std::cout << "Latency p90:   " << percentile(latencies, 0.90) << " ns\n";
+            std::cout << "Latency p99:   " << percentile(latencies, 0.99) << " ns\n";
+            std::cout << "Latency p99.9: " << percentile(latencies, 0.999) << " ns\n";
+            std::cout << "Latency max:   " << latencies.back() << " ns\n";
+        }
+        return 0;
+    } catch (const std::exception& e) {
+        std::cerr << "Error: " << e.what() << "\n";
+        return 1;
+    }
+}
+`))
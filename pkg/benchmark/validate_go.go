@@ -0,0 +1,416 @@
+package benchmark
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// writeGoValidator writes validate.go: a validation pass specialized to the
+// message type that checks a payload the way a defensive decoder would.
+// Every read is bounds-checked, bools and optional flags must be 0x00 or
+// 0x01, strings must be valid UTF-8, enums must hold a declared value,
+// element counts must fit in the
+// remaining bytes, @framed structs must fill their frame exactly and no
+// bytes may trail the message. The generated Go
+// decoders skip all of this; the validated benchmark runs both to measure
+// what the checks cost.
+func writeGoValidator(messageType *schema.MessageType, typeName string, dir string) error {
+	g := &goValidatorGen{methods: make(map[string]bool)}
+
+	var root bytes.Buffer
+	g.value(&root, messageType.TargetType, "\t", 0)
+
+	var buf bytes.Buffer
+	buf.WriteString(goValidatorRuntime)
+	fmt.Fprintf(&buf, "\n// validate%sMessage reports the first problem in data, or nil if the\n", typeName)
+	fmt.Fprintf(&buf, "// payload is a well-formed %s message.\n", messageType.Name)
+	fmt.Fprintf(&buf, "func validate%sMessage(data []byte) error {\n", typeName)
+	buf.WriteString("\tv := &wireValidator{data: data}\n")
+	buf.Write(root.Bytes())
+	buf.WriteString("\tif v.err == nil && v.pos != len(data) {\n")
+	buf.WriteString("\t\tv.fail(\"%d trailing bytes after message\", len(data)-v.pos)\n")
+	buf.WriteString("\t}\n")
+	buf.WriteString("\treturn v.err\n")
+	buf.WriteString("}\n")
+
+	// Struct methods, generated on first use so recursive types terminate
+	for len(g.pending) > 0 {
+		st := g.pending[0]
+		g.pending = g.pending[1:]
+		fmt.Fprintf(&buf, "\nfunc (v *wireValidator) struct%s() {\n", st.Name)
+		if st.Framed {
+			buf.WriteString("\touter, end := v.frame()\n")
+			fmt.Fprintf(&buf, "\tdefer v.endFrame(outer, end, %q)\n", st.Name)
+		}
+		if schema.BitmapSize(st) > 0 {
+			fmt.Fprintf(&buf, "\tbits := v.bitmap(%d, %d)\n", schema.BitmapSize(st), schema.OptionalFields(st))
+		}
+		run := 0 // Bytes of consecutive fixed-size fields, checked at once
+		bit := 0
+		for _, field := range st.Fields {
+			if size := skippableSize(field.Type); size > 0 {
+				run += size
+				continue
+			}
+			if run > 0 {
+				fmt.Fprintf(&buf, "\tv.skip(%d)\n", run)
+				run = 0
+			}
+			if st.Bitmap && field.Type.IsOptional() {
+				// The bitmap holds the presence flag
+				fmt.Fprintf(&buf, "\tif bits != nil && bits[%d]&0x%02x != 0 {\n", bit/8, 1<<(bit%8))
+				g.required(&buf, field.Type, "\t\t", 0)
+				buf.WriteString("\t}\n")
+				bit++
+				continue
+			}
+			g.value(&buf, field.Type, "\t", 0)
+		}
+		if run > 0 {
+			fmt.Fprintf(&buf, "\tv.skip(%d)\n", run)
+		}
+		buf.WriteString("}\n")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "validate.go"), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write validator: %w", err)
+	}
+	return nil
+}
+
+type goValidatorGen struct {
+	methods map[string]bool      // Struct methods already queued
+	pending []*schema.StructType // Struct methods still to generate
+}
+
+// value emits the checks for one value of typ, including its optional flag.
+// depth names loop variables so nested loops do not shadow each other.
+func (g *goValidatorGen) value(buf *bytes.Buffer, typ schema.Type, indent string, depth int) {
+	if typ.IsOptional() {
+		fmt.Fprintf(buf, "%sif v.flag(\"optional flag\") {\n", indent)
+		g.required(buf, typ, indent+"\t", depth)
+		fmt.Fprintf(buf, "%s}\n", indent)
+		return
+	}
+	g.required(buf, typ, indent, depth)
+}
+
+func (g *goValidatorGen) required(buf *bytes.Buffer, typ schema.Type, indent string, depth int) {
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			fmt.Fprintf(buf, "%sv.flag(\"bool\")\n", indent)
+		case "string":
+			fmt.Fprintf(buf, "%sv.str(%d)\n", indent, schema.LengthPrefixSize(t))
+		case "bytes":
+			fmt.Fprintf(buf, "%sv.blob(%d)\n", indent, schema.LengthPrefixSize(t))
+		default:
+			fmt.Fprintf(buf, "%sv.skip(%d)\n", indent, schema.PrimitiveSize(t.Name))
+		}
+
+	case *schema.EnumType:
+		values := make([]string, len(t.Values))
+		for i, v := range t.Values {
+			values[i] = fmt.Sprint(v.Value)
+		}
+		fmt.Fprintf(buf, "%sv.enum(%q, %d, %s)\n", indent, t.Name, schema.PrimitiveSize(t.Base), strings.Join(values, ", "))
+
+	case *schema.UnionType:
+		fmt.Fprintf(buf, "%sswitch v.tag(%q, %d) {\n", indent, t.Name, len(t.Variants))
+		for i, variant := range t.Variants {
+			fmt.Fprintf(buf, "%scase %d:\n", indent, i+1)
+			g.required(buf, variant, indent+"\t", depth)
+		}
+		fmt.Fprintf(buf, "%s}\n", indent)
+
+	case *schema.StructType:
+		if !g.methods[t.Name] {
+			g.methods[t.Name] = true
+			g.pending = append(g.pending, t)
+		}
+		fmt.Fprintf(buf, "%sv.struct%s()\n", indent, t.Name)
+
+	case *schema.ArrayType:
+		i, n := fmt.Sprintf("i%d", depth), fmt.Sprintf("n%d", depth)
+		if t.Length > 0 {
+			// Fixed-size arrays have no length prefix
+			if size := skippableSize(t.ElementType); size > 0 {
+				fmt.Fprintf(buf, "%sv.skip(%d)\n", indent, t.Length*size)
+				return
+			}
+			fmt.Fprintf(buf, "%sfor %s := 0; %s < %d && v.err == nil; %s++ {\n", indent, i, i, t.Length, i)
+			g.value(buf, t.ElementType, indent+"\t", depth+1)
+			fmt.Fprintf(buf, "%s}\n", indent)
+			return
+		}
+		if size := skippableSize(t.ElementType); size > 0 {
+			fmt.Fprintf(buf, "%sv.skip(v.count(%d, %d) * %d)\n", indent, schema.LengthPrefixSize(t), size, size)
+			return
+		}
+		fmt.Fprintf(buf, "%sfor %s, %s := 0, v.count(%d, %d); %s < %s && v.err == nil; %s++ {\n",
+			indent, i, n, schema.LengthPrefixSize(t), minWireSize(t.ElementType, nil), i, n, i)
+		g.value(buf, t.ElementType, indent+"\t", depth+1)
+		fmt.Fprintf(buf, "%s}\n", indent)
+
+	case *schema.MapType:
+		i, n := fmt.Sprintf("i%d", depth), fmt.Sprintf("n%d", depth)
+		entrySize := minWireSize(t.KeyType, nil) + minWireSize(t.ValueType, nil)
+		fmt.Fprintf(buf, "%sfor %s, %s := 0, v.count(%d, %d); %s < %s && v.err == nil; %s++ {\n",
+			indent, i, n, schema.LengthPrefixSize(t), entrySize, i, n, i)
+		g.value(buf, t.KeyType, indent+"\t", depth+1)
+		g.value(buf, t.ValueType, indent+"\t", depth+1)
+		fmt.Fprintf(buf, "%s}\n", indent)
+	}
+}
+
+// skippableSize returns the size of a non-optional number, which any bytes
+// encode validly, or 0 for types whose contents need checking.
+func skippableSize(typ schema.Type) int {
+	prim, ok := typ.(*schema.PrimitiveType)
+	if !ok || prim.Optional || prim.Name == "bool" {
+		return 0
+	}
+	return schema.PrimitiveSize(prim.Name)
+}
+
+// minWireSize returns the fewest bytes a value of typ can occupy, used to
+// reject element counts that cannot fit in the remaining data.
+func minWireSize(typ schema.Type, visiting map[*schema.StructType]bool) int {
+	if typ.IsOptional() {
+		return 1
+	}
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		if t.Name == "string" || t.Name == "bytes" {
+			return schema.LengthPrefixSize(t)
+		}
+		return schema.PrimitiveSize(t.Name)
+	case *schema.EnumType:
+		return schema.PrimitiveSize(t.Base)
+	case *schema.ArrayType:
+		if t.Length > 0 {
+			return t.Length * minWireSize(t.ElementType, visiting)
+		}
+		return schema.LengthPrefixSize(t)
+	case *schema.MapType:
+		return schema.LengthPrefixSize(t)
+	case *schema.UnionType:
+		return 1
+	case *schema.StructType:
+		if visiting == nil {
+			visiting = make(map[*schema.StructType]bool)
+		}
+		if visiting[t] {
+			return 0 // Only reachable through an optional, array or map
+		}
+		visiting[t] = true
+		size := schema.FramePrefixSize(t) + schema.BitmapSize(t)
+		for _, field := range t.Fields {
+			if t.Bitmap && field.Type.IsOptional() {
+				continue // Absent fields take no bytes
+			}
+			size += minWireSize(field.Type, visiting)
+		}
+		delete(visiting, t)
+		return size
+	}
+	return 0
+}
+
+var goValidatorRuntime = strings.TrimLeft(`
+// Code generated by ffire bench --validate. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+// wireValidator walks a payload, recording the first problem in err. Once
+// err is set every check is a no-op.
+type wireValidator struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (v *wireValidator) fail(format string, args ...interface{}) {
+	if v.err == nil {
+		v.err = fmt.Errorf("offset %d: %s", v.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+// need reports whether n more bytes are available.
+func (v *wireValidator) need(n int) bool {
+	if v.err != nil {
+		return false
+	}
+	if len(v.data)-v.pos < n {
+		v.fail("unexpected end of data (need %d bytes, have %d)", n, len(v.data)-v.pos)
+		return false
+	}
+	return true
+}
+
+func (v *wireValidator) skip(n int) {
+	if v.need(n) {
+		v.pos += n
+	}
+}
+
+// flag reads a bool or optional presence flag, which must be 0x00 or 0x01.
+func (v *wireValidator) flag(what string) bool {
+	if !v.need(1) {
+		return false
+	}
+	b := v.data[v.pos]
+	if b > 0x01 {
+		v.fail("invalid %s 0x%02x", what, b)
+		return false
+	}
+	v.pos++
+	return b == 0x01
+}
+
+// bitmap reads the n-byte presence bitmap of a struct with the given
+// number of optional fields. Unused bits must be zero. It returns nil on
+// failure.
+func (v *wireValidator) bitmap(n, optional int) []byte {
+	if !v.need(n) {
+		return nil
+	}
+	bits := v.data[v.pos : v.pos+n]
+	if unused := n*8 - optional; unused > 0 && bits[n-1]>>(8-unused) != 0 {
+		v.fail("invalid presence bitmap 0x%02x", bits[n-1])
+		return nil
+	}
+	v.pos += n
+	return bits
+}
+
+// frame reads the length prefix of a @framed struct and limits the data to
+// the struct's bytes. It returns the data and frame end that endFrame
+// needs.
+func (v *wireValidator) frame() ([]byte, int) {
+	outer := v.data
+	if !v.need(4) {
+		return outer, v.pos
+	}
+	n := int(binary.LittleEndian.Uint32(v.data[v.pos:]))
+	if n > len(v.data)-v.pos-4 {
+		v.fail("frame of %d bytes exceeds remaining %d", n, len(v.data)-v.pos-4)
+		return outer, v.pos
+	}
+	v.pos += 4
+	v.data = v.data[:v.pos+n]
+	return outer, v.pos + n
+}
+
+// endFrame checks that the fields of a @framed struct filled its frame and
+// restores the data.
+func (v *wireValidator) endFrame(outer []byte, end int, name string) {
+	if v.err == nil && v.pos != end {
+		v.fail("%d bytes left in the frame of %s", end-v.pos, name)
+	}
+	v.data = outer
+}
+
+// enum reads a size-byte integer, which must be one of values.
+func (v *wireValidator) enum(name string, size int, values ...int64) {
+	if !v.need(size) {
+		return
+	}
+	var n int64
+	switch size {
+	case 1:
+		n = int64(int8(v.data[v.pos]))
+	case 2:
+		n = int64(int16(binary.LittleEndian.Uint16(v.data[v.pos:])))
+	case 4:
+		n = int64(int32(binary.LittleEndian.Uint32(v.data[v.pos:])))
+	default:
+		n = int64(binary.LittleEndian.Uint64(v.data[v.pos:]))
+	}
+	for _, value := range values {
+		if n == value {
+			v.pos += size
+			return
+		}
+	}
+	v.fail("invalid %s value %d", name, n)
+}
+
+// tag reads a union tag, which must select one of n variants. It returns 0,
+// matching no variant, on failure.
+func (v *wireValidator) tag(name string, n int) int {
+	if !v.need(1) {
+		return 0
+	}
+	t := int(v.data[v.pos])
+	if t < 1 || t > n {
+		v.fail("invalid %s tag %d", name, t)
+		return 0
+	}
+	v.pos++
+	return t
+}
+
+// length reads a length prefix of prefix bytes: 2, or 4 for @large
+// values. It returns -1 on failure.
+func (v *wireValidator) length(prefix int) int {
+	if !v.need(prefix) {
+		return -1
+	}
+	var n int
+	if prefix == 4 {
+		n = int(binary.LittleEndian.Uint32(v.data[v.pos:]))
+	} else {
+		n = int(binary.LittleEndian.Uint16(v.data[v.pos:]))
+	}
+	v.pos += prefix
+	return n
+}
+
+func (v *wireValidator) str(prefix int) {
+	n := v.length(prefix)
+	if n < 0 || !v.need(n) {
+		return
+	}
+	if !utf8.Valid(v.data[v.pos : v.pos+n]) {
+		v.fail("invalid UTF-8 in string")
+		return
+	}
+	v.pos += n
+}
+
+// blob skips a length-prefixed byte string, which unlike str is not
+// checked for UTF-8.
+func (v *wireValidator) blob(prefix int) {
+	n := v.length(prefix)
+	if n >= 0 && v.need(n) {
+		v.pos += n
+	}
+}
+
+// count reads an array or map length whose elements take at least minSize
+// bytes each, rejecting counts the remaining data cannot hold.
+func (v *wireValidator) count(prefix, minSize int) int {
+	n := v.length(prefix)
+	if n < 0 {
+		return 0
+	}
+	if n*minSize > len(v.data)-v.pos {
+		v.fail("count %d exceeds remaining data", n)
+		return 0
+	}
+	return n
+}
+`, "\n")
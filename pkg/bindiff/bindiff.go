@@ -0,0 +1,216 @@
+// Package bindiff compares two binary payloads field by field.
+package bindiff
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Difference is a single field whose value differs between payloads.
+type Difference struct {
+	Path string // Field path (e.g., "Devices[2].Name"), empty for the root
+	A    string // Value in the first payload, formatted for display
+	B    string // Value in the second payload
+}
+
+func (d Difference) String() string {
+	if d.Path == "" {
+		return d.A + " → " + d.B
+	}
+	return d.Path + ": " + d.A + " → " + d.B
+}
+
+// PayloadError reports which payload failed to decode.
+type PayloadError struct {
+	Payload string // "a" or "b"
+	Err     error  // Usually a *fixture.DecodeError
+}
+
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("payload %s: %v", e.Payload, e.Err)
+}
+
+func (e *PayloadError) Unwrap() error {
+	return e.Err
+}
+
+// Diff decodes both payloads as messageName and returns their differences
+// in wire order. Floats are compared bit for bit, so 0.0 and -0.0 differ
+// and NaNs are equal to themselves. Arrays of different length are compared
+// element by element, with missing elements reported as "(missing)".
+func Diff(s *schema.Schema, messageName string, a, b []byte) ([]Difference, error) {
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+	if messageType == nil {
+		return nil, fmt.Errorf("message type %s not found in schema", messageName)
+	}
+
+	va, err := fixture.Decode(s, messageName, a)
+	if err != nil {
+		return nil, &PayloadError{Payload: "a", Err: err}
+	}
+	vb, err := fixture.Decode(s, messageName, b)
+	if err != nil {
+		return nil, &PayloadError{Payload: "b", Err: err}
+	}
+
+	var diffs []Difference
+	compare(messageType.TargetType, "", va, vb, &diffs)
+	return diffs, nil
+}
+
+func compare(typ schema.Type, path string, a, b interface{}, diffs *[]Difference) {
+	if a == nil || b == nil {
+		if a != nil || b != nil {
+			*diffs = append(*diffs, Difference{Path: path, A: format(typ, a), B: format(typ, b)})
+		}
+		return
+	}
+
+	switch t := typ.(type) {
+	case *schema.StructType:
+		objA := a.(map[string]interface{})
+		objB := b.(map[string]interface{})
+		for _, field := range t.Fields {
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			compare(field.Type, fieldPath, objA[field.JSONName()], objB[field.JSONName()], diffs)
+		}
+
+	case *schema.ArrayType:
+		arrA := a.([]interface{})
+		arrB := b.([]interface{})
+		if len(arrA) != len(arrB) {
+			*diffs = append(*diffs, Difference{
+				Path: path,
+				A:    fmt.Sprintf("length %d", len(arrA)),
+				B:    fmt.Sprintf("length %d", len(arrB)),
+			})
+		}
+		for i := 0; i < len(arrA) || i < len(arrB); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(arrA):
+				*diffs = append(*diffs, Difference{Path: elemPath, A: "(missing)", B: format(t.ElementType, arrB[i])})
+			case i >= len(arrB):
+				*diffs = append(*diffs, Difference{Path: elemPath, A: format(t.ElementType, arrA[i]), B: "(missing)"})
+			default:
+				compare(t.ElementType, elemPath, arrA[i], arrB[i], diffs)
+			}
+		}
+
+	case *schema.MapType:
+		objA := a.(map[string]interface{})
+		objB := b.(map[string]interface{})
+		union := make(map[string]interface{}, len(objA)+len(objB))
+		for k := range objA {
+			union[k] = nil
+		}
+		for k := range objB {
+			union[k] = nil
+		}
+		key := t.KeyType.(*schema.PrimitiveType)
+		keys, err := fixture.SortMapKeys(key, union)
+		if err != nil {
+			return // Decoded keys always parse
+		}
+		for _, k := range keys {
+			entryPath := fmt.Sprintf("%s[%s]", path, formatKey(key, k))
+			va, okA := objA[k]
+			vb, okB := objB[k]
+			switch {
+			case !okA:
+				*diffs = append(*diffs, Difference{Path: entryPath, A: "(missing)", B: format(t.ValueType, vb)})
+			case !okB:
+				*diffs = append(*diffs, Difference{Path: entryPath, A: format(t.ValueType, va), B: "(missing)"})
+			default:
+				compare(t.ValueType, entryPath, va, vb, diffs)
+			}
+		}
+
+	case *schema.EnumType:
+		if a != b {
+			*diffs = append(*diffs, Difference{Path: path, A: format(typ, a), B: format(typ, b)})
+		}
+
+	case *schema.UnionType:
+		tagA, variant, va, _ := fixture.UnionValue(t, a)
+		tagB, _, vb, _ := fixture.UnionValue(t, b)
+		if tagA != tagB {
+			*diffs = append(*diffs, Difference{Path: path, A: format(typ, a), B: format(typ, b)})
+			return
+		}
+		compare(variant, path+"."+variant.TypeName(), va, vb, diffs)
+
+	case *schema.PrimitiveType:
+		equal := a == b
+		if fa, ok := a.(float64); ok {
+			equal = math.Float64bits(fa) == math.Float64bits(b.(float64))
+		}
+		if !equal {
+			*diffs = append(*diffs, Difference{Path: path, A: format(typ, a), B: format(typ, b)})
+		}
+	}
+}
+
+// format renders a decoded value for display. Nested values are summarized;
+// their contents are reported as separate differences when both sides exist.
+func format(typ schema.Type, v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+
+	switch t := typ.(type) {
+	case *schema.StructType:
+		return t.Name + "{...}"
+	case *schema.ArrayType:
+		return fmt.Sprintf("[%d items]", len(v.([]interface{})))
+	case *schema.MapType:
+		return fmt.Sprintf("{%d entries}", len(v.(map[string]interface{})))
+	case *schema.EnumType:
+		return fmt.Sprint(v) // Constant name
+	case *schema.UnionType:
+		_, variant, inner, err := fixture.UnionValue(t, v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		if _, ok := variant.(*schema.StructType); ok {
+			return format(variant, inner)
+		}
+		return variant.TypeName() + "(" + format(variant, inner) + ")"
+	case *schema.PrimitiveType:
+		switch val := v.(type) {
+		case string:
+			return strconv.Quote(val)
+		case float64:
+			size := 64
+			if t.Name == "float32" {
+				size = 32
+			}
+			return strconv.FormatFloat(val, 'g', -1, size)
+		default:
+			return fmt.Sprint(val)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// formatKey renders a map key inside a path. String keys are quoted so that
+// keys containing brackets or dots stay unambiguous.
+func formatKey(key *schema.PrimitiveType, k string) string {
+	if key.Name == "string" {
+		return strconv.Quote(k)
+	}
+	return k
+}
@@ -0,0 +1,192 @@
+package bindiff
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const testSchema = `package test
+
+type Library struct {
+	Name    string
+	Devices []Device
+	Owner   *User
+}
+
+type Device struct {
+	ID   int32
+	Gain float32
+}
+
+type User struct {
+	Email string
+}
+`
+
+func encode(t *testing.T, s *schema.Schema, value map[string]interface{}) []byte {
+	t.Helper()
+	data, err := fixture.Encode(s, "Library", value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return data
+}
+
+func TestDiff(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	a := encode(t, s, map[string]interface{}{
+		"Name": "main",
+		"Devices": []interface{}{
+			map[string]interface{}{"ID": 1, "Gain": 0.5},
+			map[string]interface{}{"ID": 2, "Gain": 0.0},
+		},
+		"Owner": nil,
+	})
+	b := encode(t, s, map[string]interface{}{
+		"Name": "main",
+		"Devices": []interface{}{
+			map[string]interface{}{"ID": 1, "Gain": 0.25},
+		},
+		"Owner": map[string]interface{}{"Email": "x@example.com"},
+	})
+
+	diffs, err := Diff(s, "Library", a, b)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	want := []string{
+		"Devices: length 2 → length 1",
+		"Devices[0].Gain: 0.5 → 0.25",
+		"Devices[1]: Device{...} → (missing)",
+		"Owner: null → User{...}",
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d differences %v, want %d", len(diffs), diffs, len(want))
+	}
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("difference %d = %q, want %q", i, d.String(), want[i])
+		}
+	}
+
+	if diffs, _ := Diff(s, "Library", a, a); len(diffs) != 0 {
+		t.Errorf("identical payloads reported differences: %v", diffs)
+	}
+}
+
+func TestDiffNegativeZero(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Sample struct {\n\tValue float64\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	a := []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	b := []byte{0, 0, 0, 0, 0, 0, 0, 0x80}
+	diffs, err := Diff(s, "Sample", a, b)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].String() != "Value: 0 → -0" {
+		t.Errorf("got %v, want one difference for -0.0", diffs)
+	}
+}
+
+func TestDiffDecodeError(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	_, err = Diff(s, "Library", []byte{0, 0, 0, 0, 0}, []byte{1})
+	var payloadErr *PayloadError
+	if !errors.As(err, &payloadErr) || payloadErr.Payload != "b" {
+		t.Fatalf("expected error for payload b, got %v", err)
+	}
+	var decodeErr *fixture.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("expected wrapped DecodeError, got %T", payloadErr.Err)
+	}
+}
+
+func TestDiffMaps(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Config struct {\n\tLimits map[string]int32\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	encodeConfig := func(limits map[string]interface{}) []byte {
+		data, err := fixture.Encode(s, "Config", map[string]interface{}{"Limits": limits})
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		return data
+	}
+	a := encodeConfig(map[string]interface{}{"cpu": 2, "mem": 512})
+	b := encodeConfig(map[string]interface{}{"cpu": 4, "disk": 10})
+
+	diffs, err := Diff(s, "Config", a, b)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	want := []string{
+		`Limits["cpu"]: 2 → 4`,
+		`Limits["disk"]: (missing) → 10`,
+		`Limits["mem"]: 512 → (missing)`,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d differences %v, want %d", len(diffs), diffs, len(want))
+	}
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("difference %d = %q, want %q", i, d.String(), want[i])
+		}
+	}
+}
+
+func TestDiffUnions(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Log struct {\n\tEntries []Entry\n}\n\ntype Entry interface {\n\tNote | string\n}\n\ntype Note struct {\n\tLevel int32\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	encodeLog := func(entries ...interface{}) []byte {
+		data, err := fixture.Encode(s, "Log", map[string]interface{}{"Entries": entries})
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		return data
+	}
+	note := func(level int) interface{} {
+		return map[string]interface{}{"Note": map[string]interface{}{"Level": level}}
+	}
+	a := encodeLog(note(1), map[string]interface{}{"string": "x"})
+	b := encodeLog(note(2), note(3))
+
+	diffs, err := Diff(s, "Log", a, b)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+
+	want := []string{
+		`Entries[0].Note.Level: 1 → 2`,
+		`Entries[1]: string("x") → Note{...}`,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("got %d differences %v, want %d", len(diffs), diffs, len(want))
+	}
+	for i, d := range diffs {
+		if d.String() != want[i] {
+			t.Errorf("difference %d = %q, want %q", i, d.String(), want[i])
+		}
+	}
+}
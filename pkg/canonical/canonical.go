@@ -0,0 +1,344 @@
+// Package canonical rewrites payloads into a single canonical encoding so
+// they can be hashed or signed.
+//
+// The wire format is already deterministic: bools and optional flags must be
+// 0x00 or 0x01, lengths are exact and there is no padding, so equal values
+// encode to equal bytes in every generated language. The exceptions are
+// floats, whose IEEE 754 bits distinguish values that compare equal (0.0 and
+// -0.0) or that should be treated as equal (NaNs with different payloads).
+// In canonical form -0.0 is written as 0.0 and every NaN as the default
+// quiet NaN (0x7fc00000 for float32, 0x7ff8000000000000 for float64).
+//
+// Maps are the other exception: decoders accept entries in any order and let
+// the last duplicate key win. In canonical form entries are sorted by key the
+// way encoders write them and duplicates are dropped, so Payload can return
+// fewer bytes than it was given.
+package canonical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const (
+	nan32 = 0x7fc00000
+	nan64 = 0x7ff8000000000000
+)
+
+// Error reports the first value that is not in canonical form.
+type Error struct {
+	Offset int    // Byte offset of the value
+	Path   string // Field path (e.g., "Devices[2].Gain"), empty for the root
+	Reason string // e.g. "negative zero"
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("offset %d: %s", e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("offset %d: %s: %s", e.Offset, e.Path, e.Reason)
+}
+
+// Payload returns data rewritten into canonical form. The input is validated
+// first; decode failures are returned as *fixture.DecodeError. The input
+// slice is not modified.
+func Payload(s *schema.Schema, messageName string, data []byte) ([]byte, error) {
+	out := append([]byte(nil), data...)
+	w, err := newWalker(s, messageName, out, true)
+	if err != nil {
+		return nil, err
+	}
+	w.value(w.root)
+	return w.data, nil
+}
+
+// Check reports whether data is already canonical, returning an *Error for
+// the first non-canonical value.
+func Check(s *schema.Schema, messageName string, data []byte) error {
+	w, err := newWalker(s, messageName, data, false)
+	if err != nil {
+		return err
+	}
+	w.value(w.root)
+	if w.first != nil {
+		return w.first
+	}
+	return nil
+}
+
+type walker struct {
+	root  schema.Type
+	data  []byte
+	pos   int
+	path  string
+	fix   bool   // Rewrite values in place
+	first *Error // First non-canonical value (check mode)
+}
+
+func newWalker(s *schema.Schema, messageName string, data []byte, fix bool) (*walker, error) {
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+	if messageType == nil {
+		return nil, fmt.Errorf("message type %s not found in schema", messageName)
+	}
+
+	// Validate up front so the walk can read without bounds checks
+	if _, err := fixture.Decode(s, messageName, data); err != nil {
+		return nil, err
+	}
+
+	return &walker{root: messageType.TargetType, data: data, fix: fix}, nil
+}
+
+func (w *walker) value(typ schema.Type) {
+	if typ.IsOptional() {
+		present := w.data[w.pos]
+		w.pos++
+		if present == 0x00 {
+			return
+		}
+	}
+	w.content(typ)
+}
+
+// content walks a value whose presence, if it is optional, has been read.
+func (w *walker) content(typ schema.Type) {
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		if t.Large {
+			w.pos += w.length(t) // A string or bytes has no floats to fix
+			break
+		}
+		w.primitive(t.Name)
+
+	case *schema.EnumType:
+		w.primitive(t.Base)
+
+	case *schema.UnionType:
+		variant := t.Variants[w.data[w.pos]-1]
+		w.pos++
+		parent := w.path
+		if parent == "" {
+			w.path = variant.TypeName()
+		} else {
+			w.path = parent + "." + variant.TypeName()
+		}
+		w.value(variant)
+		w.path = parent
+
+	case *schema.StructType:
+		parent := w.path
+		// Sorting map entries keeps the struct's length, so its frame
+		// stays valid
+		w.pos += schema.FramePrefixSize(t)
+		// A presence bitmap precedes the fields; splicing maps only moves
+		// data after it
+		bitmap := w.data[w.pos : w.pos+schema.BitmapSize(t)]
+		w.pos += len(bitmap)
+		bit := 0
+		for _, field := range t.Fields {
+			if parent == "" {
+				w.path = field.Name
+			} else {
+				w.path = parent + "." + field.Name
+			}
+			switch {
+			case !t.Bitmap || !field.Type.IsOptional():
+				w.value(field.Type)
+			case bitmap[bit/8]&(1<<(bit%8)) != 0:
+				w.content(field.Type)
+			}
+			if field.Type.IsOptional() {
+				bit++
+			}
+		}
+		w.path = parent
+
+	case *schema.ArrayType:
+		length := t.Length
+		if length == 0 {
+			length = w.length(t)
+		}
+		parent := w.path
+		for i := 0; i < length; i++ {
+			w.path = fmt.Sprintf("%s[%d]", parent, i)
+			w.value(t.ElementType)
+		}
+		w.path = parent
+
+	case *schema.MapType:
+		w.mapEntries(t)
+	}
+}
+
+// length reads the length prefix of typ: a uint16, or a uint32 if typ is
+// @large.
+func (w *walker) length(typ schema.Type) int {
+	if schema.IsLarge(typ) {
+		n := int(binary.LittleEndian.Uint32(w.data[w.pos:]))
+		w.pos += 4
+		return n
+	}
+	n := int(binary.LittleEndian.Uint16(w.data[w.pos:]))
+	w.pos += 2
+	return n
+}
+
+// mapEntry is the byte range of one encoded key/value pair.
+type mapEntry struct {
+	start, keyEnd, end int
+}
+
+// mapEntries walks the entries of a map, then sorts them into wire order and
+// drops duplicate keys (keeping the last, as decoders do).
+func (w *walker) mapEntries(t *schema.MapType) {
+	countPos := w.pos
+	length := w.length(t)
+	key := t.KeyType.(*schema.PrimitiveType)
+	parent := w.path
+	entries := make([]mapEntry, length)
+	for i := 0; i < length; i++ {
+		entries[i].start = w.pos
+		w.primitive(key.Name)
+		entries[i].keyEnd = w.pos
+		w.path = fmt.Sprintf("%s[%d]", parent, i)
+		w.value(t.ValueType)
+		entries[i].end = w.pos
+	}
+	w.path = parent
+
+	less := func(a, b mapEntry) bool {
+		return compareKeys(key.Name, w.data[a.start:a.keyEnd], w.data[b.start:b.keyEnd]) < 0
+	}
+	equal := func(a, b mapEntry) bool {
+		return compareKeys(key.Name, w.data[a.start:a.keyEnd], w.data[b.start:b.keyEnd]) == 0
+	}
+
+	if !w.fix {
+		for i := 1; i < length; i++ {
+			if w.first != nil {
+				break
+			}
+			switch {
+			case equal(entries[i-1], entries[i]):
+				w.first = &Error{Offset: entries[i].start, Path: fmt.Sprintf("%s[%d]", parent, i), Reason: "duplicate map key"}
+			case less(entries[i], entries[i-1]):
+				w.first = &Error{Offset: entries[i].start, Path: fmt.Sprintf("%s[%d]", parent, i), Reason: "map keys out of order"}
+			}
+		}
+		return
+	}
+
+	sorted := append([]mapEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	var region []byte
+	count := 0
+	for i, e := range sorted {
+		if i+1 < len(sorted) && equal(e, sorted[i+1]) {
+			continue // A later duplicate wins
+		}
+		region = append(region, w.data[e.start:e.end]...)
+		count++
+	}
+	if t.Large {
+		binary.LittleEndian.PutUint32(w.data[countPos:], uint32(count))
+	} else {
+		binary.LittleEndian.PutUint16(w.data[countPos:], uint16(count))
+	}
+
+	// Splice the rewritten entries in; nothing after w.pos has been visited yet
+	start := countPos + schema.LengthPrefixSize(t)
+	rest := append(region, w.data[w.pos:]...)
+	w.data = append(w.data[:start], rest...)
+	w.pos = start + len(region)
+}
+
+// compareKeys orders two encoded map keys: strings by their UTF-8 bytes,
+// integers numerically and false before true.
+func compareKeys(name string, a, b []byte) int {
+	switch name {
+	case "string":
+		return bytes.Compare(a[2:], b[2:])
+	case "int8":
+		return compareInt(int64(int8(a[0])), int64(int8(b[0])))
+	case "int16":
+		return compareInt(int64(int16(binary.LittleEndian.Uint16(a))), int64(int16(binary.LittleEndian.Uint16(b))))
+	case "int32":
+		return compareInt(int64(int32(binary.LittleEndian.Uint32(a))), int64(int32(binary.LittleEndian.Uint32(b))))
+	case "int64":
+		return compareInt(int64(binary.LittleEndian.Uint64(a)), int64(binary.LittleEndian.Uint64(b)))
+	}
+	return bytes.Compare(a, b) // bool
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func (w *walker) primitive(name string) {
+	switch name {
+	case "bool", "int8":
+		w.pos++
+	case "int16":
+		w.pos += 2
+	case "int32":
+		w.pos += 4
+	case "int64":
+		w.pos += 8
+	case "string", "bytes":
+		w.pos += 2 + int(binary.LittleEndian.Uint16(w.data[w.pos:]))
+
+	case "float32":
+		bits := binary.LittleEndian.Uint32(w.data[w.pos:])
+		switch {
+		case math.IsNaN(float64(math.Float32frombits(bits))) && bits != nan32:
+			w.replace(4, nan32, "non-default NaN")
+		case bits == 0x80000000:
+			w.replace(4, 0, "negative zero")
+		}
+		w.pos += 4
+
+	case "float64":
+		bits := binary.LittleEndian.Uint64(w.data[w.pos:])
+		switch {
+		case math.IsNaN(math.Float64frombits(bits)) && bits != nan64:
+			w.replace(8, nan64, "non-default NaN")
+		case bits == 0x8000000000000000:
+			w.replace(8, 0, "negative zero")
+		}
+		w.pos += 8
+	}
+}
+
+// replace rewrites the float at the current position, or records it as the
+// first non-canonical value when checking.
+func (w *walker) replace(size int, bits uint64, reason string) {
+	if !w.fix {
+		if w.first == nil {
+			w.first = &Error{Offset: w.pos, Path: w.path, Reason: reason}
+		}
+		return
+	}
+	if size == 4 {
+		binary.LittleEndian.PutUint32(w.data[w.pos:], uint32(bits))
+	} else {
+		binary.LittleEndian.PutUint64(w.data[w.pos:], bits)
+	}
+}
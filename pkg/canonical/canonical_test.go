@@ -0,0 +1,148 @@
+package canonical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const testSchema = `package test
+
+type Sample struct {
+	Name    string
+	Gain    *float32
+	Samples []float64
+}
+`
+
+func parseSample(t *testing.T) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return s
+}
+
+func sample(gain uint32, samples ...uint64) []byte {
+	data := []byte{1, 0, 'x', 1}
+	data = binary.LittleEndian.AppendUint32(data, gain)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(samples)))
+	for _, bits := range samples {
+		data = binary.LittleEndian.AppendUint64(data, bits)
+	}
+	return data
+}
+
+func TestPayload(t *testing.T) {
+	s := parseSample(t)
+
+	input := sample(0x7fc00123, math.Float64bits(1.5), 0x8000000000000000, 0xfff8000000000001)
+	want := sample(nan32, math.Float64bits(1.5), 0, nan64)
+
+	got, err := Payload(s, "Sample", input)
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+	if bytes.Equal(input, want) {
+		t.Error("input was modified")
+	}
+
+	if err := Check(s, "Sample", got); err != nil {
+		t.Errorf("canonical payload failed check: %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := parseSample(t)
+
+	err := Check(s, "Sample", sample(0, math.Float64bits(2), 0x8000000000000000))
+	var canonErr *Error
+	if !errors.As(err, &canonErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if canonErr.Path != "Samples[1]" || canonErr.Offset != 18 || canonErr.Reason != "negative zero" {
+		t.Errorf("got %+v", canonErr)
+	}
+
+	// Decode failures are reported as such
+	err = Check(s, "Sample", []byte{1, 0, 'x', 2})
+	var decodeErr *fixture.DecodeError
+	if !errors.As(err, &decodeErr) {
+		t.Errorf("expected DecodeError, got %v", err)
+	}
+}
+
+func TestPayloadMaps(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Config struct {\n\tLimits map[int16]float32\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	entry := func(data []byte, key int16, bits uint32) []byte {
+		data = binary.LittleEndian.AppendUint16(data, uint16(key))
+		return binary.LittleEndian.AppendUint32(data, bits)
+	}
+	// Entries 3, -1, 3 with a negative zero in the first: the duplicate 3
+	// wins and the result is sorted numerically
+	input := entry(entry(entry([]byte{3, 0}, 3, 0x80000000), -1, 0), 3, math.Float32bits(2))
+	want := entry(entry([]byte{2, 0}, -1, 0), 3, math.Float32bits(2))
+
+	got, err := Payload(s, "Config", input)
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+	if err := Check(s, "Config", got); err != nil {
+		t.Errorf("canonical payload failed check: %v", err)
+	}
+
+	err = Check(s, "Config", entry(entry([]byte{2, 0}, 3, 0), -1, 0))
+	var canonErr *Error
+	if !errors.As(err, &canonErr) || canonErr.Reason != "map keys out of order" || canonErr.Offset != 8 {
+		t.Errorf("got %v, want keys out of order at offset 8", err)
+	}
+}
+
+func TestCheckUnion(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package test
+
+type Reading struct {
+	Value Value
+}
+
+type Value interface {
+	string | float64
+}
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Tag 2 selects the float64 variant, which holds -0.0
+	input := binary.LittleEndian.AppendUint64([]byte{2}, 0x8000000000000000)
+	err = Check(s, "Reading", input)
+	var canonErr *Error
+	if !errors.As(err, &canonErr) || canonErr.Path != "Value.float64" || canonErr.Offset != 1 {
+		t.Fatalf("Check = %v, want negative zero at Value.float64", err)
+	}
+
+	got, err := Payload(s, "Reading", input)
+	if err != nil {
+		t.Fatalf("Payload failed: %v", err)
+	}
+	if want := binary.LittleEndian.AppendUint64([]byte{2}, 0); !bytes.Equal(got, want) {
+		t.Errorf("got  % x\nwant % x", got, want)
+	}
+}
@@ -0,0 +1,310 @@
+// Package compat compares two versions of a schema and classifies each
+// change by the semantic version bump it requires of generated packages.
+//
+// The wire format has no field numbers: a struct is its fields in
+// canonical order. Adding, removing or retyping a field of an existing
+// struct therefore breaks decoding in both directions and requires a major
+// release, as does anything that breaks generated APIs. New types and
+// message types only add API and require a minor release.
+package compat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/semver"
+)
+
+// Change is one difference between two schemas.
+type Change struct {
+	Level   semver.Level // Release level the change requires
+	Path    string       // Type, message or Type.Field the change is in
+	Message string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Path, c.Message)
+}
+
+// Diff returns the changes from old to new, message types first, then
+// structs, then enums, then unions, each sorted by name.
+func Diff(old, new *schema.Schema) []Change {
+	var changes []Change
+	add := func(level semver.Level, path, format string, args ...interface{}) {
+		changes = append(changes, Change{Level: level, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	oldMsgs, newMsgs := messages(old), messages(new)
+	for _, name := range unionKeys(oldMsgs, newMsgs) {
+		o, inOld := oldMsgs[name]
+		n, inNew := newMsgs[name]
+		switch {
+		case !inNew:
+			add(semver.Major, name, "message type removed")
+		case !inOld:
+			add(semver.Minor, name, "message type added")
+		case typeString(o) != typeString(n):
+			add(semver.Major, name, "message type changed from %s to %s", typeString(o), typeString(n))
+		}
+	}
+
+	oldStructs, newStructs := structs(old), structs(new)
+	for _, name := range unionKeys(oldStructs, newStructs) {
+		o, inOld := oldStructs[name]
+		n, inNew := newStructs[name]
+		switch {
+		case !inNew:
+			if _, isMsg := oldMsgs[name]; !isMsg { // reported as a message type
+				add(semver.Major, name, "type removed")
+			}
+		case !inOld:
+			if _, isMsg := newMsgs[name]; !isMsg {
+				add(semver.Minor, name, "type added")
+			}
+		default:
+			diffFields(o, n, add)
+		}
+	}
+
+	oldEnums, newEnums := enums(old), enums(new)
+	for _, name := range unionKeys(oldEnums, newEnums) {
+		o, inOld := oldEnums[name]
+		n, inNew := newEnums[name]
+		switch {
+		case !inNew:
+			add(semver.Major, name, "type removed")
+		case !inOld:
+			add(semver.Minor, name, "type added")
+		default:
+			diffEnumValues(o, n, add)
+		}
+	}
+
+	oldUnions, newUnions := unionTypes(old), unionTypes(new)
+	for _, name := range unionKeys(oldUnions, newUnions) {
+		o, inOld := oldUnions[name]
+		n, inNew := newUnions[name]
+		switch {
+		case !inNew:
+			add(semver.Major, name, "type removed")
+		case !inOld:
+			add(semver.Minor, name, "type added")
+		default:
+			diffUnionVariants(o, n, add)
+		}
+	}
+	return changes
+}
+
+// diffUnionVariants compares the variants of a union. A variant's tag is
+// its position, so only appending one is a minor change; removing or
+// reordering variants renumbers the tags of existing payloads.
+func diffUnionVariants(o, n *schema.UnionType, add func(semver.Level, string, string, ...interface{})) {
+	for i, v := range n.Variants {
+		path := n.Name + "." + v.TypeName()
+		_, oldTag, ok := o.Variant(v.TypeName())
+		switch {
+		case ok && oldTag != i+1:
+			add(semver.Major, path, "tag changed from %d to %d", oldTag, i+1)
+		case !ok:
+			add(semver.Minor, path, "variant added; decoders built from the old schema reject it")
+		}
+	}
+	for _, v := range o.Variants {
+		if _, _, ok := n.Variant(v.TypeName()); !ok {
+			add(semver.Major, o.Name+"."+v.TypeName(), "variant removed")
+		}
+	}
+}
+
+// diffEnumValues compares the constants of an enum. Adding one is a minor
+// change, although decoders built from the old schema reject the new value;
+// removing, renaming or renumbering one breaks existing payloads or code.
+func diffEnumValues(o, n *schema.EnumType, add func(semver.Level, string, string, ...interface{})) {
+	if o.Base != n.Base {
+		add(semver.Major, n.Name, "base type changed from %s to %s", o.Base, n.Base)
+	}
+	for _, v := range n.Values {
+		path := n.Name + "." + v.Name
+		old, ok := o.ValueOf(v.Name)
+		switch {
+		case ok && old != v.Value:
+			add(semver.Major, path, "value changed from %d to %d", old, v.Value)
+		case !ok:
+			if name, taken := o.Lookup(v.Value); taken {
+				add(semver.Major, path, "constant %s renamed to %s", name, v.Name)
+			} else {
+				add(semver.Minor, path, "constant added; decoders built from the old schema reject it")
+			}
+		}
+	}
+	for _, v := range o.Values {
+		if _, ok := n.ValueOf(v.Name); ok {
+			continue
+		}
+		if _, renamed := n.Lookup(v.Value); !renamed {
+			add(semver.Major, o.Name+"."+v.Name, "constant removed")
+		}
+	}
+}
+
+func diffFields(o, n *schema.StructType, add func(semver.Level, string, string, ...interface{})) {
+	switch {
+	case !o.Bitmap && n.Bitmap:
+		add(semver.Major, n.Name, "@bitmap added; it changes the wire layout of %s", n.Name)
+	case o.Bitmap && !n.Bitmap:
+		add(semver.Major, n.Name, "@bitmap removed; it changes the wire layout of %s", n.Name)
+	}
+	switch {
+	case !o.Framed && n.Framed:
+		add(semver.Major, n.Name, "@framed added; it changes the wire layout of %s", n.Name)
+	case o.Framed && !n.Framed:
+		add(semver.Major, n.Name, "@framed removed; it changes the wire layout of %s", n.Name)
+	}
+	oldFields := make(map[string]schema.Field, len(o.Fields))
+	for _, f := range o.Fields {
+		oldFields[f.Name] = f
+	}
+	newFields := make(map[string]bool, len(n.Fields))
+	for _, f := range n.Fields {
+		newFields[f.Name] = true
+		path := n.Name + "." + f.Name
+		of, ok := oldFields[f.Name]
+		switch {
+		case !ok:
+			add(semver.Major, path, "field added; it changes the wire layout of %s", n.Name)
+		case typeString(of.Type) != typeString(f.Type):
+			add(semver.Major, path, "type changed from %s to %s", typeString(of.Type), typeString(f.Type))
+		case of.Tag != f.Tag:
+			add(semver.Patch, path, "struct tag changed from %s to %s", quoteTag(of.Tag), quoteTag(f.Tag))
+		}
+	}
+	for _, f := range o.Fields {
+		if !newFields[f.Name] {
+			add(semver.Major, o.Name+"."+f.Name, "field removed")
+		}
+	}
+}
+
+// Required returns the release level that changes need together: the
+// highest level among them, or Patch if there are none.
+func Required(changes []Change) semver.Level {
+	level := semver.Patch
+	for _, c := range changes {
+		if c.Level > level {
+			level = c.Level
+		}
+	}
+	return level
+}
+
+// Suggest returns the version to release after current for changes.
+func Suggest(current string, changes []Change) (string, error) {
+	v, err := semver.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	return v.Bump(Required(changes)).String(), nil
+}
+
+// typeString spells a type as in schema source, e.g. "*[]Device", with
+// "@large " in front of types with a uint32 length prefix.
+func typeString(t schema.Type) string {
+	s := t.TypeName()
+	switch t := t.(type) {
+	case *schema.ArrayType:
+		s = "[]" + typeString(t.ElementType)
+		if t.Length > 0 {
+			s = "[" + strconv.Itoa(t.Length) + "]" + typeString(t.ElementType)
+		}
+	case *schema.MapType:
+		s = "map[" + typeString(t.KeyType) + "]" + typeString(t.ValueType)
+	}
+	if t.IsOptional() {
+		s = "*" + s
+	}
+	if schema.IsLarge(t) {
+		s = "@large " + s // The length prefix widens to a uint32
+	}
+	return s
+}
+
+func quoteTag(tag string) string {
+	if tag == "" {
+		return "none"
+	}
+	return tag
+}
+
+func messages(s *schema.Schema) map[string]schema.Type {
+	m := make(map[string]schema.Type, len(s.Messages))
+	for _, msg := range s.Messages {
+		m[msg.Name] = msg.TargetType
+	}
+	return m
+}
+
+// structs returns every named struct type reachable from the schema.
+func structs(s *schema.Schema) map[string]*schema.StructType {
+	m := make(map[string]*schema.StructType)
+	var walk func(t schema.Type)
+	walk = func(t schema.Type) {
+		switch t := t.(type) {
+		case *schema.StructType:
+			if _, seen := m[t.Name]; seen {
+				return
+			}
+			m[t.Name] = t
+			for _, f := range t.Fields {
+				walk(f.Type)
+			}
+		case *schema.ArrayType:
+			walk(t.ElementType)
+		case *schema.MapType:
+			walk(t.ValueType)
+		case *schema.UnionType:
+			for _, v := range t.Variants {
+				walk(v)
+			}
+		}
+	}
+	for _, t := range s.Types {
+		walk(t)
+	}
+	for _, msg := range s.Messages {
+		walk(msg.TargetType)
+	}
+	return m
+}
+
+func enums(s *schema.Schema) map[string]*schema.EnumType {
+	m := make(map[string]*schema.EnumType)
+	for _, e := range s.Enums() {
+		m[e.Name] = e
+	}
+	return m
+}
+
+func unionTypes(s *schema.Schema) map[string]*schema.UnionType {
+	m := make(map[string]*schema.UnionType)
+	for _, u := range s.Unions() {
+		m[u.Name] = u
+	}
+	return m
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,328 @@
+package compat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/semver"
+)
+
+func mustParse(t *testing.T, src string) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return s
+}
+
+const baseSchema = `package audio
+
+type Device struct {
+	ID    int32
+	Name  string ` + "`json:\"name\"`" + `
+	Gains []float32
+}
+
+type DeviceList []Device
+`
+
+func TestDiff(t *testing.T) {
+	old := mustParse(t, baseSchema)
+
+	tests := []struct {
+		name  string
+		src   string
+		level semver.Level
+		want  []string
+	}{
+		{
+			name:  "unchanged",
+			src:   baseSchema,
+			level: semver.Patch,
+		},
+		{
+			name: "reordered fields",
+			src: `package audio
+type Device struct {
+	Gains []float32
+	Name  string ` + "`json:\"name\"`" + `
+	ID    int32
+}
+type DeviceList []Device
+`,
+			level: semver.Patch,
+		},
+		{
+			name: "tag changed",
+			src: `package audio
+type Device struct {
+	ID    int32
+	Name  string ` + "`json:\"label\"`" + `
+	Gains []float32
+}
+type DeviceList []Device
+`,
+			level: semver.Patch,
+			want:  []string{"Device.Name: struct tag changed"},
+		},
+		{
+			name: "message added",
+			src: baseSchema + `
+type Config struct {
+	Host string
+}
+`,
+			level: semver.Minor,
+			want:  []string{"Config: message type added"},
+		},
+		{
+			name: "field added, removed and retyped",
+			src: `package audio
+type Device struct {
+	ID     int64
+	Gains  []float32
+	Vendor *string
+}
+type DeviceList []Device
+`,
+			level: semver.Major,
+			want: []string{
+				"Device.ID: type changed from int32 to int64",
+				"Device.Vendor: field added",
+				"Device.Name: field removed",
+			},
+		},
+		{
+			name: "large prefix",
+			src: `package audio
+type Device struct {
+	ID    int32
+	Name  string ` + "`json:\"name\"`" + `
+	// @large
+	Gains []float32
+}
+type DeviceList []Device
+`,
+			level: semver.Major,
+			want:  []string{"Device.Gains: type changed from []float32 to @large []float32"},
+		},
+		{
+			name: "message removed",
+			src: `package audio
+type Device struct {
+	ID    int32
+	Name  string ` + "`json:\"name\"`" + `
+	Gains []float32
+}
+type Config struct {
+	Devices []Device
+}
+`,
+			level: semver.Major,
+			want:  []string{"Config: message type added", "DeviceList: message type removed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := Diff(old, mustParse(t, tt.src))
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			joined := strings.Join(got, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("changes lack %q:\n%s", want, joined)
+				}
+			}
+			if len(changes) != len(tt.want) {
+				t.Errorf("unexpected changes:\n%s", joined)
+			}
+			if level := Required(changes); level != tt.level {
+				t.Errorf("Required = %s, want %s", level, tt.level)
+			}
+		})
+	}
+}
+
+func TestDiffEnums(t *testing.T) {
+	const enumSchema = `package audio
+
+type Device struct {
+	Mode Mode
+}
+
+type Mode int8
+
+const (
+	Mono Mode = iota + 1
+	Stereo
+)
+`
+	old := mustParse(t, enumSchema)
+
+	tests := []struct {
+		name   string
+		consts string
+		level  semver.Level
+		want   []string
+	}{
+		{"unchanged", "Mono Mode = iota + 1\n\tStereo", semver.Patch, nil},
+		{"added", "Mono Mode = iota + 1\n\tStereo\n\tSurround", semver.Minor, []string{"Mode.Surround: constant added"}},
+		{"removed", "Mono Mode = iota + 1", semver.Major, []string{"Mode.Stereo: constant removed"}},
+		{"renamed", "Mono Mode = iota + 1\n\tDual", semver.Major, []string{"Mode.Dual: constant Stereo renamed to Dual"}},
+		{"renumbered", "Mono Mode = iota\n\tStereo", semver.Major, []string{"Mode.Mono: value changed from 1 to 0", "Mode.Stereo: value changed from 2 to 1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := strings.Replace(enumSchema, "Mono Mode = iota + 1\n\tStereo", tt.consts, 1)
+			changes := Diff(old, mustParse(t, src))
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			joined := strings.Join(got, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("changes lack %q:\n%s", want, joined)
+				}
+			}
+			if len(changes) != len(tt.want) {
+				t.Errorf("unexpected changes:\n%s", joined)
+			}
+			if level := Required(changes); level != tt.level {
+				t.Errorf("Required = %s, want %s", level, tt.level)
+			}
+		})
+	}
+}
+
+func TestDiffUnions(t *testing.T) {
+	const unionSchema = `package audio
+
+type Device struct {
+	Value Value
+}
+
+type Value interface {
+	string | int32
+}
+`
+	old := mustParse(t, unionSchema)
+
+	tests := []struct {
+		name     string
+		variants string
+		level    semver.Level
+		want     []string
+	}{
+		{"unchanged", "string | int32", semver.Patch, nil},
+		{"appended", "string | int32 | bool", semver.Minor, []string{"Value.bool: variant added"}},
+		{"removed", "string", semver.Major, []string{"Value.int32: variant removed"}},
+		{"reordered", "int32 | string", semver.Major, []string{"Value.int32: tag changed from 2 to 1", "Value.string: tag changed from 1 to 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := strings.Replace(unionSchema, "string | int32", tt.variants, 1)
+			changes := Diff(old, mustParse(t, src))
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.String())
+			}
+			joined := strings.Join(got, "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(joined, want) {
+					t.Errorf("changes lack %q:\n%s", want, joined)
+				}
+			}
+			if len(changes) != len(tt.want) {
+				t.Errorf("unexpected changes:\n%s", joined)
+			}
+			if level := Required(changes); level != tt.level {
+				t.Errorf("Required = %s, want %s", level, tt.level)
+			}
+		})
+	}
+}
+
+func TestDiffBitmap(t *testing.T) {
+	const src = `package audio
+
+type Device struct {
+	Name  string
+	Gain  *float32
+	Label *string
+}
+
+type DeviceList []Device
+`
+	plain := mustParse(t, src)
+	packed := mustParse(t, strings.Replace(src, "type Device struct", "// @bitmap\ntype Device struct", 1))
+
+	for _, tt := range []struct {
+		old, new *schema.Schema
+		want     string
+	}{
+		{plain, packed, "Device: @bitmap added; it changes the wire layout of Device"},
+		{packed, plain, "Device: @bitmap removed; it changes the wire layout of Device"},
+	} {
+		changes := Diff(tt.old, tt.new)
+		if len(changes) != 1 || changes[0].String() != tt.want {
+			t.Errorf("changes = %v, want [%s]", changes, tt.want)
+		}
+		if level := Required(changes); level != semver.Major {
+			t.Errorf("Required = %s, want major", level)
+		}
+	}
+}
+
+func TestDiffFramed(t *testing.T) {
+	const src = `package audio
+
+type Device struct {
+	Name string
+}
+
+type DeviceList []Device
+`
+	plain := mustParse(t, src)
+	framed := mustParse(t, strings.Replace(src, "type Device struct", "// @framed\ntype Device struct", 1))
+
+	for _, tt := range []struct {
+		old, new *schema.Schema
+		want     string
+	}{
+		{plain, framed, "Device: @framed added; it changes the wire layout of Device"},
+		{framed, plain, "Device: @framed removed; it changes the wire layout of Device"},
+	} {
+		changes := Diff(tt.old, tt.new)
+		if len(changes) != 1 || changes[0].String() != tt.want {
+			t.Errorf("changes = %v, want [%s]", changes, tt.want)
+		}
+		if level := Required(changes); level != semver.Major {
+			t.Errorf("Required = %s, want major", level)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	old := mustParse(t, baseSchema)
+	changes := Diff(old, mustParse(t, baseSchema+"\ntype Config struct {\n\tHost string\n}\n"))
+
+	got, err := Suggest("1.4.2", changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "1.5.0" {
+		t.Errorf("Suggest = %s, want 1.5.0", got)
+	}
+	if _, err := Suggest("next", changes); err == nil {
+		t.Error("Suggest should reject an invalid version")
+	}
+}
@@ -0,0 +1,166 @@
+// Package config loads ffire.yaml, the optional per-project configuration
+// file read by the CLI.
+//
+//	generate:
+//	  - schema: schemas/audio.ffi
+//	    lang: [go, swift]
+//	    out: gen
+//	    options:
+//	      features: [v2]
+//	      no-compile: true
+//	packages:
+//	  maven:
+//	    group_id: com.acme.audio
+//	  npm:
+//	    scope: "@acme"
+//	hooks:
+//	  post:
+//	    - name: gofmt
+//	      run: gofmt -w .
+//	      lang: [go]
+//	    - name: clang-format
+//	      run: clang-format -i include/*.h src/*.cpp
+//	      lang: [cpp]
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/remote"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the configuration file looked up by Find.
+const FileName = "ffire.yaml"
+
+// Config is the contents of ffire.yaml.
+type Config struct {
+	Generate []Target              `yaml:"generate"` // Packages a plain `ffire generate` writes
+	Hooks    generator.Hooks       `yaml:"hooks"`
+	Packages generator.Coordinates `yaml:"packages"` // Published package names per language
+
+	// Path is the file the configuration was loaded from
+	Path string `yaml:"-"`
+}
+
+// Target is a schema generated for one or more languages by a plain
+// `ffire generate`. Flags on the command line override its settings.
+type Target struct {
+	Schema    string    `yaml:"schema"` // Path, relative to the config file, or https:// URL
+	Lang      Languages `yaml:"lang"`
+	Out       string    `yaml:"out"` // Relative to the config file; default ./dist
+	Namespace string    `yaml:"namespace"`
+
+	// Options holds other ffire generate flags by name, without the
+	// leading dash: `no-compile: true`, `features: [v2]`.
+	Options map[string]interface{} `yaml:"options"`
+}
+
+// Languages is a list of target languages, written as a list or a single
+// name.
+type Languages []string
+
+func (l *Languages) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = Languages{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// Load reads and validates the configuration file at path. Unknown keys
+// are errors, so a misspelled hook setting is not silently ignored.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	cfg := &Config{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve config path: %w", err)
+	}
+	cfg.Path = abs
+	cfg.Hooks.ConfigDir = filepath.Dir(abs)
+	for i := range cfg.Generate {
+		t := &cfg.Generate[i]
+		if !remote.IsURL(t.Schema) {
+			t.Schema = resolve(cfg.Hooks.ConfigDir, t.Schema)
+		}
+		if t.Out != "" {
+			t.Out = resolve(cfg.Hooks.ConfigDir, t.Out)
+		}
+	}
+	return cfg, nil
+}
+
+// resolve returns path relative to dir, unless it is absolute.
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func (c *Config) validate() error {
+	for i, t := range c.Generate {
+		switch {
+		case t.Schema == "":
+			return fmt.Errorf("generate[%d]: missing schema", i)
+		case len(t.Lang) == 0:
+			return fmt.Errorf("generate[%d]: missing lang", i)
+		}
+		for _, key := range [][2]string{{"schema", "schema"}, {"lang", "lang"}, {"out", "out"}, {"ns", "namespace"}} {
+			if _, ok := t.Options[key[0]]; ok {
+				return fmt.Errorf("generate[%d].options.%s: use the target's %s key", i, key[0], key[1])
+			}
+		}
+		if _, ok := t.Options["config"]; ok {
+			return fmt.Errorf("generate[%d].options.config: a target cannot name another config file", i)
+		}
+	}
+	phases := []struct {
+		name  string
+		hooks []generator.Hook
+	}{{"pre", c.Hooks.Pre}, {"post", c.Hooks.Post}}
+	for _, phase := range phases {
+		for i, h := range phase.hooks {
+			if h.Run == "" {
+				return fmt.Errorf("hooks.%s[%d]: missing run command", phase.name, i)
+			}
+		}
+	}
+	return c.Packages.Validate()
+}
+
+// Find returns the path of the first ffire.yaml found in dirs, in order.
+func Find(dirs ...string) (string, bool) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, FileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
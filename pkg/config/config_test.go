@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadHooks(t *testing.T) {
+	path := writeConfig(t, `hooks:
+  pre:
+    - run: ./check.sh
+  post:
+    - name: gofmt
+      run: gofmt -w .
+      lang: [go]
+    - name: clang-format
+      run: clang-format -i src/*.cpp
+      lang: [cpp]
+      dir: cpp
+      allow_failure: true
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Hooks.Pre) != 1 || cfg.Hooks.Pre[0].Run != "./check.sh" {
+		t.Errorf("Pre = %+v", cfg.Hooks.Pre)
+	}
+	if len(cfg.Hooks.Post) != 2 {
+		t.Fatalf("len(Post) = %d, want 2", len(cfg.Hooks.Post))
+	}
+	cf := cfg.Hooks.Post[1]
+	if cf.Name != "clang-format" || cf.Dir != "cpp" || !cf.AllowFailure || len(cf.Lang) != 1 || cf.Lang[0] != "cpp" {
+		t.Errorf("Post[1] = %+v", cf)
+	}
+	if cfg.Hooks.ConfigDir != filepath.Dir(path) {
+		t.Errorf("ConfigDir = %q, want %q", cfg.Hooks.ConfigDir, filepath.Dir(path))
+	}
+}
+
+func TestLoadPackages(t *testing.T) {
+	path := writeConfig(t, `packages:
+  go: example.com/acme/audio
+  maven:
+    group_id: com.acme.audio
+    artifact_id: audio-codec
+  npm:
+    scope: "@acme"
+    name: audio
+  pypi: acme-audio
+  nuget: Acme.Audio
+  swiftpm: AcmeAudio
+  pub: acme_audio
+  crate: acme-audio
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	p := cfg.Packages
+	if p.Maven.GroupID != "com.acme.audio" || p.Maven.ArtifactID != "audio-codec" {
+		t.Errorf("Maven = %+v", p.Maven)
+	}
+	if p.NPM.Scope != "@acme" || p.NPM.Name != "audio" {
+		t.Errorf("NPM = %+v", p.NPM)
+	}
+	if p.Go != "example.com/acme/audio" || p.PyPI != "acme-audio" || p.NuGet != "Acme.Audio" || p.SwiftPM != "AcmeAudio" || p.Pub != "acme_audio" || p.Crate != "acme-audio" {
+		t.Errorf("Packages = %+v", p)
+	}
+}
+
+func TestLoadGenerate(t *testing.T) {
+	path := writeConfig(t, `generate:
+  - schema: schemas/audio.ffi
+    lang: [go, swift]
+    out: gen
+    namespace: audio
+    options:
+      no-compile: true
+      features: [v2, v3]
+  - schema: https://registry.example.com/audio/v3
+    lang: python
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Generate) != 2 {
+		t.Fatalf("len(Generate) = %d, want 2", len(cfg.Generate))
+	}
+	dir := filepath.Dir(path)
+	local, remote := cfg.Generate[0], cfg.Generate[1]
+	if local.Schema != filepath.Join(dir, "schemas", "audio.ffi") || local.Out != filepath.Join(dir, "gen") {
+		t.Errorf("paths not resolved against the config file: %+v", local)
+	}
+	if strings.Join(local.Lang, ",") != "go,swift" || local.Namespace != "audio" {
+		t.Errorf("Generate[0] = %+v", local)
+	}
+	if local.Options["no-compile"] != true || len(local.Options["features"].([]interface{})) != 2 {
+		t.Errorf("Options = %v", local.Options)
+	}
+	if remote.Schema != "https://registry.example.com/audio/v3" || len(remote.Lang) != 1 || remote.Lang[0] != "python" || remote.Out != "" {
+		t.Errorf("Generate[1] = %+v", remote)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	cfg, err := Load(writeConfig(t, "# nothing configured yet\n"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.Hooks.Pre)+len(cfg.Hooks.Post) != 0 {
+		t.Errorf("expected no hooks, got %+v", cfg.Hooks)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", "hooks:\n  post:\n    - run: gofmt -w .\n      langs: [go]\n", "field langs not found"},
+		{"missing run", "hooks:\n  post:\n    - name: gofmt\n", "hooks.post[0]: missing run command"},
+		{"bad yaml", "hooks: [\n", "parse"},
+		{"npm scope without @", "packages:\n  npm:\n    scope: acme\n", "packages.npm.scope: invalid name"},
+		{"maven group", "packages:\n  maven:\n    group_id: com.acme-audio\n", "packages.maven.group_id"},
+		{"unknown language", "packages:\n  gem: acme\n", "field gem not found"},
+		{"target without schema", "generate:\n  - lang: go\n", "generate[0]: missing schema"},
+		{"target without lang", "generate:\n  - schema: a.ffi\n", "generate[0]: missing lang"},
+		{"target option with a key", "generate:\n  - schema: a.ffi\n    lang: go\n    options:\n      ns: audio\n", "use the target's namespace key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	path := writeConfig(t, "")
+	empty := t.TempDir()
+
+	if got, ok := Find(empty, filepath.Dir(path)); !ok || got != path {
+		t.Errorf("Find = %q, %v; want %q", got, ok, path)
+	}
+	if _, ok := Find(empty); ok {
+		t.Error("Find found a config in an empty directory")
+	}
+}
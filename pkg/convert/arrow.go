@@ -0,0 +1,755 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Arrow IPC support for array-of-struct messages: each array element is a
+// row and each struct field a column. Nested structs map to Struct columns,
+// arrays to List columns, optional fields to nullable columns, enums to
+// Utf8 columns of constant names and bytes to Binary columns. Column names
+// are the JSON field names.
+//
+// Both the IPC file format (Feather v2, FormatArrow) and the IPC stream
+// format (FormatArrowStream) are supported; readers accept either.
+
+// Arrow metadata constants (Schema.fbs, Message.fbs).
+const (
+	arrowMetadataV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeBinary        = 4
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeList          = 12
+	arrowTypeStruct        = 13
+
+	arrowPrecisionSingle = 1
+	arrowPrecisionDouble = 2
+)
+
+var arrowFileMagic = []byte("ARROW1")
+
+// arrowRowType returns the struct type of the rows of an array message.
+func arrowRowType(typ schema.Type) (*schema.StructType, error) {
+	arr, ok := typ.(*schema.ArrayType)
+	if !ok || arr.IsOptional() {
+		return nil, fmt.Errorf("arrow conversion requires an array-of-struct message, got %s", typ.TypeName())
+	}
+	row, ok := arr.ElementType.(*schema.StructType)
+	if !ok || row.IsOptional() {
+		return nil, fmt.Errorf("arrow conversion requires an array-of-struct message, got array of %s", arr.ElementType.TypeName())
+	}
+	for _, field := range row.Fields {
+		if arrowHasMap(field.Type) {
+			return nil, fmt.Errorf("arrow conversion does not support maps (field %s)", field.Name)
+		}
+		if arrowHasUnion(field.Type) {
+			return nil, fmt.Errorf("arrow conversion does not support unions (field %s)", field.Name)
+		}
+	}
+	return arrowEnumsAsStrings(row, make(map[*schema.StructType]*schema.StructType)).(*schema.StructType), nil
+}
+
+// arrowEnumsAsStrings returns a copy of typ with every enum replaced by a
+// string, so enum columns hold the constant names as in JSON.
+func arrowEnumsAsStrings(typ schema.Type, copies map[*schema.StructType]*schema.StructType) schema.Type {
+	switch t := typ.(type) {
+	case *schema.EnumType:
+		return &schema.PrimitiveType{Name: "string", Optional: t.Optional}
+	case *schema.ArrayType:
+		return &schema.ArrayType{ElementType: arrowEnumsAsStrings(t.ElementType, copies), Length: t.Length, Optional: t.Optional, Large: t.Large}
+	case *schema.StructType:
+		if c, ok := copies[t]; ok {
+			return c
+		}
+		c := &schema.StructType{Name: t.Name, Optional: t.Optional}
+		copies[t] = c
+		for _, field := range t.Fields {
+			field.Type = arrowEnumsAsStrings(field.Type, copies)
+			c.Fields = append(c.Fields, field)
+		}
+		return c
+	}
+	return typ
+}
+
+func arrowHasMap(typ schema.Type) bool {
+	switch t := typ.(type) {
+	case *schema.MapType:
+		return true
+	case *schema.ArrayType:
+		return arrowHasMap(t.ElementType)
+	case *schema.StructType:
+		for _, field := range t.Fields {
+			if arrowHasMap(field.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func arrowHasUnion(typ schema.Type) bool {
+	switch t := typ.(type) {
+	case *schema.UnionType:
+		return true
+	case *schema.ArrayType:
+		return arrowHasUnion(t.ElementType)
+	case *schema.StructType:
+		for _, field := range t.Fields {
+			if arrowHasUnion(field.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// arrowField builds the Arrow Field table for a column of type typ.
+func arrowField(name string, typ schema.Type) fbTable {
+	var typeID uint8
+	var typeTable fbTable
+	var children fbTableVector
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			typeID, typeTable = arrowTypeBool, fbTable{}
+		case "int8", "int16", "int32", "int64":
+			typeID = arrowTypeInt
+			typeTable = fbTable{fbInt32(int32(primitiveWidth(t.Name) * 8)), fbBool(true)}
+		case "float32":
+			typeID, typeTable = arrowTypeFloatingPoint, fbTable{fbInt16(arrowPrecisionSingle)}
+		case "float64":
+			typeID, typeTable = arrowTypeFloatingPoint, fbTable{fbInt16(arrowPrecisionDouble)}
+		case "bytes":
+			typeID, typeTable = arrowTypeBinary, fbTable{}
+		default:
+			typeID, typeTable = arrowTypeUtf8, fbTable{}
+		}
+	case *schema.ArrayType:
+		typeID, typeTable = arrowTypeList, fbTable{}
+		children = fbTableVector{arrowField("item", t.ElementType)}
+	case *schema.StructType:
+		typeID, typeTable = arrowTypeStruct, fbTable{}
+		for _, field := range t.Fields {
+			children = append(children, arrowField(field.JSONName(), field.Type))
+		}
+	}
+
+	if children == nil {
+		children = fbTableVector{}
+	}
+	return fbTable{
+		fbRef(fbString(name)),
+		fbBool(typ.IsOptional()),
+		fbUint8(typeID),
+		fbRef(typeTable),
+		nil, // dictionary
+		fbRef(children),
+	}
+}
+
+func arrowSchema(row *schema.StructType) fbTable {
+	fields := fbTableVector{}
+	for _, field := range row.Fields {
+		fields = append(fields, arrowField(field.JSONName(), field.Type))
+	}
+	return fbTable{
+		fbInt16(0), // Little endian
+		fbRef(fields),
+	}
+}
+
+func primitiveWidth(name string) int {
+	switch name {
+	case "int8", "bool":
+		return 1
+	case "int16":
+		return 2
+	case "int32", "float32":
+		return 4
+	default:
+		return 8
+	}
+}
+
+// arrowColumn accumulates the buffers of one column.
+type arrowColumn struct {
+	typ       schema.Type
+	length    int
+	nullCount int
+	validity  []bool
+	values    []byte  // Fixed-width values, bit-packed for bool
+	offsets   []int32 // Utf8, Binary and List
+	data      []byte  // Utf8 and Binary bytes
+	children  []*arrowColumn
+}
+
+func newArrowColumn(typ schema.Type) *arrowColumn {
+	c := &arrowColumn{typ: typ}
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		if t.Name == "string" || t.Name == "bytes" {
+			c.offsets = []int32{0}
+		}
+	case *schema.ArrayType:
+		c.offsets = []int32{0}
+		c.children = []*arrowColumn{newArrowColumn(t.ElementType)}
+	case *schema.StructType:
+		for _, field := range t.Fields {
+			c.children = append(c.children, newArrowColumn(field.Type))
+		}
+	}
+	return c
+}
+
+// append adds one value. nil is a null slot for nullable columns; for
+// non-nullable columns (children of a null struct) it writes a zero value.
+func (c *arrowColumn) append(v interface{}) {
+	index := c.length
+	c.length++
+	valid := v != nil || !c.typ.IsOptional()
+	c.validity = append(c.validity, valid)
+	if !valid {
+		c.nullCount++
+	}
+
+	switch t := c.typ.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			if index%8 == 0 {
+				c.values = append(c.values, 0)
+			}
+			if b, _ := v.(bool); b {
+				c.values[index/8] |= 1 << (index % 8)
+			}
+		case "string":
+			s, _ := v.(string)
+			c.data = append(c.data, s...)
+			c.offsets = append(c.offsets, int32(len(c.data)))
+		case "bytes":
+			s, _ := v.(string)
+			b, _ := base64.StdEncoding.DecodeString(s) // Normalized, so valid
+			c.data = append(c.data, b...)
+			c.offsets = append(c.offsets, int32(len(c.data)))
+		case "float32":
+			f, _ := v.(float64)
+			c.values = binary.LittleEndian.AppendUint32(c.values, math.Float32bits(float32(f)))
+		case "float64":
+			f, _ := v.(float64)
+			c.values = binary.LittleEndian.AppendUint64(c.values, math.Float64bits(f))
+		default:
+			n, _ := v.(int64)
+			var tmp [8]byte
+			binary.LittleEndian.PutUint64(tmp[:], uint64(n))
+			c.values = append(c.values, tmp[:primitiveWidth(t.Name)]...)
+		}
+
+	case *schema.ArrayType:
+		arr, _ := v.([]interface{})
+		for _, elem := range arr {
+			c.children[0].append(elem)
+		}
+		c.offsets = append(c.offsets, int32(c.children[0].length))
+
+	case *schema.StructType:
+		obj, _ := v.(map[string]interface{})
+		for i, field := range t.Fields {
+			c.children[i].append(obj[field.JSONName()])
+		}
+	}
+}
+
+// arrowBody collects field nodes and buffers of a record batch.
+type arrowBody struct {
+	nodes   []byte // FieldNode structs
+	buffers []byte // Buffer structs
+	body    []byte
+}
+
+func (b *arrowBody) addBuffer(data []byte) {
+	b.buffers = binary.LittleEndian.AppendUint64(b.buffers, uint64(len(b.body)))
+	b.buffers = binary.LittleEndian.AppendUint64(b.buffers, uint64(len(data)))
+	b.body = append(b.body, data...)
+	for len(b.body)%8 != 0 {
+		b.body = append(b.body, 0)
+	}
+}
+
+// flatten appends the column's node and buffers in depth-first order.
+func (b *arrowBody) flatten(c *arrowColumn) {
+	b.nodes = binary.LittleEndian.AppendUint64(b.nodes, uint64(c.length))
+	b.nodes = binary.LittleEndian.AppendUint64(b.nodes, uint64(c.nullCount))
+
+	// Validity bitmap is omitted when there are no nulls
+	var validity []byte
+	if c.nullCount > 0 {
+		validity = make([]byte, (c.length+7)/8)
+		for i, valid := range c.validity {
+			if valid {
+				validity[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+	b.addBuffer(validity)
+
+	switch t := c.typ.(type) {
+	case *schema.PrimitiveType:
+		if t.Name == "string" || t.Name == "bytes" {
+			b.addBuffer(int32Bytes(c.offsets))
+			b.addBuffer(c.data)
+		} else {
+			b.addBuffer(c.values)
+		}
+	case *schema.ArrayType:
+		b.addBuffer(int32Bytes(c.offsets))
+		b.flatten(c.children[0])
+	case *schema.StructType:
+		for _, child := range c.children {
+			b.flatten(child)
+		}
+	}
+}
+
+func int32Bytes(values []int32) []byte {
+	out := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint32(out, uint32(v))
+	}
+	return out
+}
+
+// arrowMessage frames a Message flatbuffer with the IPC continuation marker,
+// padding the metadata so the body starts 8-byte aligned.
+func arrowMessage(headerType uint8, header fbTable, body []byte) []byte {
+	meta := fbFinish(fbTable{
+		fbInt16(arrowMetadataV5),
+		fbUint8(headerType),
+		fbRef(header),
+		fbInt64(int64(len(body))),
+	})
+	for len(meta)%8 != 0 {
+		meta = append(meta, 0)
+	}
+
+	out := binary.LittleEndian.AppendUint32(nil, 0xFFFFFFFF)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(meta)))
+	out = append(out, meta...)
+	return append(out, body...)
+}
+
+func encodeArrow(typ schema.Type, value interface{}, fileFormat bool) ([]byte, error) {
+	row, err := arrowRowType(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := value.([]interface{})
+	columns := make([]*arrowColumn, len(row.Fields))
+	for i, field := range row.Fields {
+		columns[i] = newArrowColumn(field.Type)
+	}
+	for _, r := range rows {
+		obj := r.(map[string]interface{})
+		for i, field := range row.Fields {
+			columns[i].append(obj[field.JSONName()])
+		}
+	}
+
+	body := &arrowBody{}
+	for _, c := range columns {
+		body.flatten(c)
+	}
+
+	schemaMsg := arrowMessage(arrowHeaderSchema, arrowSchema(row), nil)
+	batchMsg := arrowMessage(arrowHeaderRecordBatch, fbTable{
+		fbInt64(int64(len(rows))),
+		fbRef(&fbStructVector{count: len(body.nodes) / 16, data: body.nodes}),
+		fbRef(&fbStructVector{count: len(body.buffers) / 16, data: body.buffers}),
+	}, body.body)
+	eos := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}
+
+	var out []byte
+	if !fileFormat {
+		out = append(out, schemaMsg...)
+		out = append(out, batchMsg...)
+		return append(out, eos...), nil
+	}
+
+	// File format: magic, stream, footer with the schema and batch location
+	out = append(out, arrowFileMagic...)
+	out = append(out, 0, 0)
+	out = append(out, schemaMsg...)
+	batchOffset := len(out)
+	out = append(out, batchMsg...)
+	out = append(out, eos...)
+
+	block := binary.LittleEndian.AppendUint64(nil, uint64(batchOffset))
+	block = binary.LittleEndian.AppendUint32(block, uint32(len(batchMsg)-len(body.body)))
+	block = append(block, 0, 0, 0, 0)
+	block = binary.LittleEndian.AppendUint64(block, uint64(len(body.body)))
+
+	footer := fbFinish(fbTable{
+		fbInt16(arrowMetadataV5),
+		fbRef(arrowSchema(row)),
+		fbRef(&fbStructVector{}),
+		fbRef(&fbStructVector{count: 1, data: block}),
+	})
+	out = append(out, footer...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(footer)))
+	return append(out, arrowFileMagic...), nil
+}
+
+func decodeArrow(typ schema.Type, data []byte) (value interface{}, err error) {
+	defer fbRecover(&err)
+
+	row, err := arrowRowType(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := 0
+	if bytes.HasPrefix(data, arrowFileMagic) {
+		pos = 8
+	}
+
+	rows := []interface{}{}
+	sawSchema := false
+	for {
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("offset %d: unexpected end of Arrow stream", pos)
+		}
+		length := binary.LittleEndian.Uint32(data[pos:])
+		pos += 4
+		if length == 0xFFFFFFFF {
+			if pos+4 > len(data) {
+				return nil, fmt.Errorf("offset %d: unexpected end of Arrow stream", pos)
+			}
+			length = binary.LittleEndian.Uint32(data[pos:])
+			pos += 4
+		}
+		if length == 0 {
+			break // End of stream
+		}
+		if uint64(pos)+uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("offset %d: metadata length %d exceeds data", pos, length)
+		}
+
+		msg := fbRootTable(data[pos : pos+int(length)])
+		pos += int(length)
+		bodyLength := msg.int64(3, 0)
+		if bodyLength < 0 || int64(pos)+bodyLength > int64(len(data)) {
+			return nil, fmt.Errorf("offset %d: body length %d exceeds data", pos, bodyLength)
+		}
+		body := data[pos : pos+int(bodyLength)]
+		pos += int(bodyLength)
+
+		header, ok := msg.table(2)
+		if !ok {
+			return nil, fmt.Errorf("Arrow message without header")
+		}
+
+		switch msg.uint8(1, 0) {
+		case arrowHeaderSchema:
+			if err := checkArrowSchema(row, header); err != nil {
+				return nil, err
+			}
+			sawSchema = true
+		case arrowHeaderRecordBatch:
+			if !sawSchema {
+				return nil, fmt.Errorf("record batch before schema")
+			}
+			batch, err := decodeArrowBatch(row, header, body)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, batch...)
+		default:
+			return nil, fmt.Errorf("unsupported Arrow message type %d (dictionaries are not supported)", msg.uint8(1, 0))
+		}
+	}
+
+	if !sawSchema {
+		return nil, fmt.Errorf("Arrow stream has no schema")
+	}
+	return rows, nil
+}
+
+// describeArrowField renders a field tree from metadata for comparison.
+func describeArrowField(f fbReader) string {
+	var sb strings.Builder
+	sb.WriteString(f.string(0))
+	if f.uint8(1, 0) != 0 {
+		sb.WriteString("?")
+	}
+	sb.WriteString(":")
+	typeTable, _ := f.table(3)
+	switch id := f.uint8(2, 0); id {
+	case arrowTypeInt:
+		signed := "u"
+		if typeTable.uint8(1, 0) != 0 {
+			signed = ""
+		}
+		fmt.Fprintf(&sb, "%sint%d", signed, typeTable.int32(0, 0))
+	case arrowTypeFloatingPoint:
+		fmt.Fprintf(&sb, "float(%d)", typeTable.int16(0, 0))
+	case arrowTypeUtf8:
+		sb.WriteString("utf8")
+	case arrowTypeBinary:
+		sb.WriteString("binary")
+	case arrowTypeBool:
+		sb.WriteString("bool")
+	case arrowTypeList:
+		sb.WriteString("list")
+	case arrowTypeStruct:
+		sb.WriteString("struct")
+	default:
+		fmt.Fprintf(&sb, "type%d", id)
+	}
+	if children := f.tables(5); len(children) > 0 {
+		parts := make([]string, len(children))
+		for i, child := range children {
+			parts[i] = describeArrowField(child)
+		}
+		sb.WriteString("<" + strings.Join(parts, ", ") + ">")
+	}
+	return sb.String()
+}
+
+func checkArrowSchema(row *schema.StructType, header fbReader) error {
+	want := fbFinish(arrowSchema(row))
+	expected := fbRootTable(want).tables(1)
+	got := header.tables(1)
+
+	if len(got) != len(expected) {
+		return fmt.Errorf("Arrow schema has %d columns, %s has %d fields", len(got), row.Name, len(expected))
+	}
+	for i := range got {
+		if g, e := describeArrowField(got[i]), describeArrowField(expected[i]); g != e {
+			return fmt.Errorf("Arrow column %d is %s, expected %s", i, g, e)
+		}
+	}
+	return nil
+}
+
+// arrowBatchReader walks the nodes and buffers of a record batch in order.
+type arrowBatchReader struct {
+	nodes   [][]byte
+	buffers [][]byte
+	body    []byte
+}
+
+func (r *arrowBatchReader) node() (length, nullCount int, err error) {
+	if len(r.nodes) == 0 {
+		return 0, 0, fmt.Errorf("record batch has too few field nodes")
+	}
+	n := r.nodes[0]
+	r.nodes = r.nodes[1:]
+	return int(binary.LittleEndian.Uint64(n)), int(binary.LittleEndian.Uint64(n[8:])), nil
+}
+
+func (r *arrowBatchReader) buffer() ([]byte, error) {
+	if len(r.buffers) == 0 {
+		return nil, fmt.Errorf("record batch has too few buffers")
+	}
+	b := r.buffers[0]
+	r.buffers = r.buffers[1:]
+	offset, length := binary.LittleEndian.Uint64(b), binary.LittleEndian.Uint64(b[8:])
+	if offset > uint64(len(r.body)) || length > uint64(len(r.body))-offset {
+		return nil, fmt.Errorf("buffer [%d, +%d) exceeds record batch body", offset, length)
+	}
+	return r.body[offset : offset+length], nil
+}
+
+func decodeArrowBatch(row *schema.StructType, header fbReader, body []byte) ([]interface{}, error) {
+	if header.field(3) >= 0 {
+		return nil, fmt.Errorf("compressed record batches are not supported")
+	}
+
+	r := &arrowBatchReader{
+		nodes:   header.structs(1, 16),
+		buffers: header.structs(2, 16),
+		body:    body,
+	}
+	length := int(header.int64(0, 0))
+
+	rows := make([]interface{}, length)
+	for i := range rows {
+		rows[i] = make(map[string]interface{}, len(row.Fields))
+	}
+	for _, field := range row.Fields {
+		values, err := r.column(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", field.JSONName(), err)
+		}
+		if len(values) != length {
+			return nil, fmt.Errorf("column %s has %d values, batch has %d rows", field.JSONName(), len(values), length)
+		}
+		for i, v := range values {
+			rows[i].(map[string]interface{})[field.JSONName()] = v
+		}
+	}
+	return rows, nil
+}
+
+// column decodes one column (and its children) into values.
+func (r *arrowBatchReader) column(typ schema.Type) ([]interface{}, error) {
+	length, nullCount, err := r.node()
+	if err != nil {
+		return nil, err
+	}
+	validity, err := r.buffer()
+	if err != nil {
+		return nil, err
+	}
+	if nullCount > 0 && len(validity) < (length+7)/8 {
+		return nil, fmt.Errorf("validity bitmap too short")
+	}
+	isNull := func(i int) bool {
+		return nullCount > 0 && validity[i/8]&(1<<(i%8)) == 0
+	}
+
+	values := make([]interface{}, length)
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		if t.Name == "string" || t.Name == "bytes" {
+			offsets, data, err := r.offsetsAndData(length)
+			if err != nil {
+				return nil, err
+			}
+			for i := range values {
+				if offsets[i] > offsets[i+1] || int(offsets[i+1]) > len(data) {
+					return nil, fmt.Errorf("invalid %s offsets at row %d", t.Name, i)
+				}
+				raw := data[offsets[i]:offsets[i+1]]
+				if t.Name == "bytes" {
+					values[i] = base64.StdEncoding.EncodeToString(raw)
+				} else {
+					values[i] = string(raw)
+				}
+			}
+			break
+		}
+
+		buf, err := r.buffer()
+		if err != nil {
+			return nil, err
+		}
+		width := primitiveWidth(t.Name)
+		if t.Name == "bool" {
+			if len(buf) < (length+7)/8 {
+				return nil, fmt.Errorf("bool buffer too short")
+			}
+		} else if len(buf) < width*length {
+			return nil, fmt.Errorf("%s buffer too short", t.Name)
+		}
+		for i := range values {
+			switch t.Name {
+			case "bool":
+				values[i] = buf[i/8]&(1<<(i%8)) != 0
+			case "int8":
+				values[i] = int64(int8(buf[i]))
+			case "int16":
+				values[i] = int64(int16(binary.LittleEndian.Uint16(buf[2*i:])))
+			case "int32":
+				values[i] = int64(int32(binary.LittleEndian.Uint32(buf[4*i:])))
+			case "int64":
+				values[i] = int64(binary.LittleEndian.Uint64(buf[8*i:]))
+			case "float32":
+				values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+			case "float64":
+				values[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*i:]))
+			}
+		}
+
+	case *schema.ArrayType:
+		buf, err := r.buffer()
+		if err != nil {
+			return nil, err
+		}
+		offsets, err := readOffsets(buf, length)
+		if err != nil {
+			return nil, err
+		}
+		elems, err := r.column(t.ElementType)
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if offsets[i] > offsets[i+1] || int(offsets[i+1]) > len(elems) {
+				return nil, fmt.Errorf("invalid list offsets at row %d", i)
+			}
+			values[i] = append([]interface{}{}, elems[offsets[i]:offsets[i+1]]...)
+		}
+
+	case *schema.StructType:
+		for i := range values {
+			values[i] = make(map[string]interface{}, len(t.Fields))
+		}
+		for _, field := range t.Fields {
+			child, err := r.column(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.JSONName(), err)
+			}
+			if len(child) < length {
+				return nil, fmt.Errorf("%s: child column too short", field.JSONName())
+			}
+			for i := range values {
+				values[i].(map[string]interface{})[field.JSONName()] = child[i]
+			}
+		}
+	}
+
+	for i := range values {
+		if isNull(i) {
+			values[i] = nil
+		}
+	}
+	return values, nil
+}
+
+func (r *arrowBatchReader) offsetsAndData(length int) ([]int32, []byte, error) {
+	buf, err := r.buffer()
+	if err != nil {
+		return nil, nil, err
+	}
+	offsets, err := readOffsets(buf, length)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := r.buffer()
+	if err != nil {
+		return nil, nil, err
+	}
+	return offsets, data, nil
+}
+
+func readOffsets(buf []byte, length int) ([]int32, error) {
+	if len(buf) < 4*(length+1) {
+		return nil, fmt.Errorf("offsets buffer too short")
+	}
+	offsets := make([]int32, length+1)
+	for i := range offsets {
+		offsets[i] = int32(binary.LittleEndian.Uint32(buf[4*i:]))
+		if offsets[i] < 0 {
+			return nil, fmt.Errorf("negative offset")
+		}
+	}
+	return offsets, nil
+}
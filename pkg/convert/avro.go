@@ -0,0 +1,611 @@
+package convert
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Avro mapping:
+//
+//	bool -> boolean, int8/int16/int32 -> int, int64 -> long,
+//	float32 -> float, float64 -> double, string -> string,
+//	[]T -> array, map[K]V -> map, struct -> record, *T -> ["null", T],
+//	enum -> enum with the constant names as symbols,
+//	union -> union of the variants in tag order, *union -> ["null", variants...]
+//
+// Avro unions may not hold two unnamed types of the same kind, so unions
+// whose variants share an Avro type (int8 and int16, say) are rejected.
+// Avro map keys are strings, so integer and bool keys take their JSON
+// spelling; entries are written in ffire wire order.
+// Payloads are written as Avro object container files holding one record,
+// with the schema embedded, which is what data lake tooling ingests.
+
+var avroMagic = []byte("Obj\x01")
+
+var avroNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name string      `json:"name"`
+	Type interface{} `json:"type"`
+}
+
+type avroArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+type avroEnum struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Symbols   []string `json:"symbols"`
+}
+
+type avroMap struct {
+	Type   string      `json:"type"`
+	Values interface{} `json:"values"`
+}
+
+// AvroSchema returns the Avro schema (JSON) for messageName.
+func AvroSchema(s *schema.Schema, messageName string) ([]byte, error) {
+	typ, err := messageType(s, messageName)
+	if err != nil {
+		return nil, err
+	}
+	b := &avroSchemaBuilder{namespace: s.Package, defined: make(map[string]bool)}
+	result := b.build(typ)
+	if b.err != nil {
+		return nil, b.err
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+type avroSchemaBuilder struct {
+	namespace string
+	defined   map[string]bool // Avro named types may only be defined once
+	err       error           // First unrepresentable type
+}
+
+func (b *avroSchemaBuilder) build(typ schema.Type) interface{} {
+	var result interface{}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		result = avroPrimitive(t.Name)
+	case *schema.ArrayType:
+		result = avroArray{Type: "array", Items: b.build(t.ElementType)}
+	case *schema.MapType:
+		result = avroMap{Type: "map", Values: b.build(t.ValueType)}
+	case *schema.EnumType:
+		if b.defined[t.Name] {
+			result = t.Name
+			break
+		}
+		b.defined[t.Name] = true
+		enum := avroEnum{Type: "enum", Name: t.Name, Namespace: b.namespace}
+		for _, v := range t.Values {
+			enum.Symbols = append(enum.Symbols, v.Name)
+		}
+		result = enum
+	case *schema.StructType:
+		if b.defined[t.Name] {
+			result = t.Name
+			break
+		}
+		b.defined[t.Name] = true
+		rec := avroRecord{Type: "record", Name: t.Name, Namespace: b.namespace, Fields: []avroField{}}
+		for _, field := range t.Fields {
+			rec.Fields = append(rec.Fields, avroField{Name: avroFieldName(field), Type: b.build(field.Type)})
+		}
+		result = rec
+	case *schema.UnionType:
+		// Avro unions cannot nest, so an optional union gets null as an
+		// extra first branch rather than being wrapped
+		var branches []interface{}
+		if t.Optional {
+			branches = append(branches, "null")
+		}
+		kinds := make(map[string]string)
+		for _, v := range t.Variants {
+			if p, ok := v.(*schema.PrimitiveType); ok {
+				kind := avroPrimitive(p.Name)
+				if other, dup := kinds[kind]; dup && b.err == nil {
+					b.err = fmt.Errorf("%s: variants %s and %s both map to Avro %s", t.Name, other, p.Name, kind)
+				}
+				kinds[kind] = p.Name
+			}
+			branches = append(branches, b.build(v))
+		}
+		return branches
+	}
+
+	if typ.IsOptional() {
+		return []interface{}{"null", result}
+	}
+	return result
+}
+
+func avroPrimitive(name string) string {
+	switch name {
+	case "bool":
+		return "boolean"
+	case "int8", "int16", "int32":
+		return "int"
+	case "int64":
+		return "long"
+	case "float32":
+		return "float"
+	case "float64":
+		return "double"
+	case "bytes":
+		return "bytes"
+	default:
+		return "string"
+	}
+}
+
+// avroFieldName uses the JSON name where it is a valid Avro name, so Avro
+// consumers see the same field names as JSON consumers.
+func avroFieldName(field schema.Field) string {
+	if name := field.JSONName(); avroNameRE.MatchString(name) {
+		return name
+	}
+	return field.Name
+}
+
+func encodeAvroContainer(s *schema.Schema, messageName string, typ schema.Type, value interface{}) ([]byte, error) {
+	schemaJSON, err := AvroSchema(s, messageName)
+	if err != nil {
+		return nil, err
+	}
+
+	datum := &bytes.Buffer{}
+	if err := encodeAvro(datum, typ, value); err != nil {
+		return nil, err
+	}
+
+	// The sync marker only has to be unlikely to occur in the data; deriving
+	// it from the schema keeps output reproducible.
+	sum := sha256.Sum256(schemaJSON)
+	sync := sum[:16]
+
+	buf := &bytes.Buffer{}
+	buf.Write(avroMagic)
+	writeAvroLong(buf, 2) // Metadata map block with two entries
+	writeAvroBytes(buf, []byte("avro.schema"))
+	writeAvroBytes(buf, schemaJSON)
+	writeAvroBytes(buf, []byte("avro.codec"))
+	writeAvroBytes(buf, []byte("null"))
+	writeAvroLong(buf, 0)
+	buf.Write(sync)
+
+	writeAvroLong(buf, 1) // One object in the block
+	writeAvroLong(buf, int64(datum.Len()))
+	buf.Write(datum.Bytes())
+	buf.Write(sync)
+
+	return buf.Bytes(), nil
+}
+
+func encodeAvro(buf *bytes.Buffer, typ schema.Type, value interface{}) error {
+	if t, ok := typ.(*schema.UnionType); ok {
+		return encodeAvroUnion(buf, t, value)
+	}
+	if typ.IsOptional() {
+		if value == nil {
+			writeAvroLong(buf, 0)
+			return nil
+		}
+		writeAvroLong(buf, 1)
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			if value.(bool) {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		case "float32":
+			binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(value.(float64))))
+		case "float64":
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(value.(float64)))
+		case "string":
+			writeAvroBytes(buf, []byte(value.(string)))
+		case "bytes":
+			b, err := base64.StdEncoding.DecodeString(value.(string))
+			if err != nil {
+				return err
+			}
+			writeAvroBytes(buf, b)
+		default:
+			writeAvroLong(buf, value.(int64))
+		}
+
+	case *schema.EnumType:
+		// Enums are written as the index of the symbol
+		for i, v := range t.Values {
+			if v.Name == value.(string) {
+				writeAvroLong(buf, int64(i))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s value %q", t.Name, value)
+
+	case *schema.StructType:
+		obj := value.(map[string]interface{})
+		for _, field := range t.Fields {
+			if err := encodeAvro(buf, field.Type, obj[field.JSONName()]); err != nil {
+				return err
+			}
+		}
+
+	case *schema.ArrayType:
+		arr := value.([]interface{})
+		if len(arr) > 0 {
+			writeAvroLong(buf, int64(len(arr)))
+			for _, elem := range arr {
+				if err := encodeAvro(buf, t.ElementType, elem); err != nil {
+					return err
+				}
+			}
+		}
+		writeAvroLong(buf, 0)
+
+	case *schema.MapType:
+		obj := value.(map[string]interface{})
+		keys, err := fixture.SortMapKeys(t.KeyType.(*schema.PrimitiveType), obj)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			writeAvroLong(buf, int64(len(keys)))
+			for _, k := range keys {
+				writeAvroBytes(buf, []byte(k))
+				if err := encodeAvro(buf, t.ValueType, obj[k]); err != nil {
+					return err
+				}
+			}
+		}
+		writeAvroLong(buf, 0)
+
+	default:
+		return fmt.Errorf("unknown type: %T", typ)
+	}
+
+	return nil
+}
+
+// encodeAvroUnion writes the branch index and the variant. Branches follow
+// the tag order, after the null branch of an optional union.
+func encodeAvroUnion(buf *bytes.Buffer, t *schema.UnionType, value interface{}) error {
+	if t.Optional && value == nil {
+		writeAvroLong(buf, 0)
+		return nil
+	}
+	tag, variant, inner, err := fixture.UnionValue(t, value)
+	if err != nil {
+		return err
+	}
+	branch := tag - 1
+	if t.Optional {
+		branch = tag
+	}
+	writeAvroLong(buf, int64(branch))
+	return encodeAvro(buf, variant, inner)
+}
+
+func writeAvroLong(buf *bytes.Buffer, v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v) // zig-zag, as Avro specifies
+	buf.Write(tmp[:n])
+}
+
+func writeAvroBytes(buf *bytes.Buffer, b []byte) {
+	writeAvroLong(buf, int64(len(b)))
+	buf.Write(b)
+}
+
+func decodeAvroContainer(s *schema.Schema, messageName string, typ schema.Type, data []byte) (interface{}, error) {
+	r := &avroReader{data: data}
+	magic, err := r.take(len(avroMagic))
+	if err != nil || !bytes.Equal(magic, avroMagic) {
+		return nil, fmt.Errorf("not an Avro object container file")
+	}
+
+	meta, err := r.metadata()
+	if err != nil {
+		return nil, err
+	}
+	if codec := string(meta["avro.codec"]); codec != "" && codec != "null" {
+		return nil, fmt.Errorf("unsupported Avro codec %q (only null is supported)", codec)
+	}
+
+	expected, err := AvroSchema(s, messageName)
+	if err != nil {
+		return nil, err
+	}
+	if !sameJSON(meta["avro.schema"], expected) {
+		return nil, fmt.Errorf("writer schema in file does not match the Avro schema of %s", messageName)
+	}
+
+	sync, err := r.take(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	for r.pos < len(r.data) {
+		count, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.long(); err != nil { // Block size in bytes
+			return nil, err
+		}
+		for i := int64(0); i < count; i++ {
+			v, err := r.value(typ)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		marker, err := r.take(16)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(marker, sync) {
+			return nil, fmt.Errorf("offset %d: sync marker mismatch", r.pos-16)
+		}
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf("expected exactly one record, file contains %d", len(values))
+	}
+	return values[0], nil
+}
+
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+type avroReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *avroReader) take(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("offset %d: unexpected end of data (need %d bytes)", r.pos, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *avroReader) long() (int64, error) {
+	v, n := binary.Varint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("offset %d: invalid varint", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *avroReader) bytes() ([]byte, error) {
+	n, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	return r.take(int(n))
+}
+
+// blockCount reads an Avro block count. Negative counts are followed by the
+// block size in bytes, which is skipped.
+func (r *avroReader) blockCount() (int64, error) {
+	count, err := r.long()
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		count = -count
+		if _, err := r.long(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (r *avroReader) metadata() (map[string][]byte, error) {
+	meta := make(map[string][]byte)
+	for {
+		count, err := r.blockCount()
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return meta, nil
+		}
+		for i := int64(0); i < count; i++ {
+			key, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			value, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			meta[string(key)] = value
+		}
+	}
+}
+
+func (r *avroReader) value(typ schema.Type) (interface{}, error) {
+	if t, ok := typ.(*schema.UnionType); ok {
+		return r.union(t)
+	}
+	if typ.IsOptional() {
+		branch, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		switch branch {
+		case 0:
+			return nil, nil
+		case 1:
+		default:
+			return nil, fmt.Errorf("offset %d: invalid union branch %d", r.pos, branch)
+		}
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			b, err := r.take(1)
+			if err != nil {
+				return nil, err
+			}
+			return b[0] != 0, nil
+		case "float32":
+			b, err := r.take(4)
+			if err != nil {
+				return nil, err
+			}
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), nil
+		case "float64":
+			b, err := r.take(8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+		case "string":
+			b, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			return string(b), nil
+		case "bytes":
+			b, err := r.bytes()
+			if err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.EncodeToString(b), nil
+		default:
+			return r.long()
+		}
+
+	case *schema.EnumType:
+		start := r.pos
+		i, err := r.long()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(t.Values)) {
+			return nil, fmt.Errorf("offset %d: invalid %s symbol index %d", start, t.Name, i)
+		}
+		return t.Values[i].Name, nil
+
+	case *schema.StructType:
+		obj := make(map[string]interface{}, len(t.Fields))
+		for _, field := range t.Fields {
+			v, err := r.value(field.Type)
+			if err != nil {
+				return nil, err
+			}
+			obj[field.JSONName()] = v
+		}
+		return obj, nil
+
+	case *schema.ArrayType:
+		arr := []interface{}{}
+		for {
+			count, err := r.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return arr, nil
+			}
+			for i := int64(0); i < count; i++ {
+				v, err := r.value(t.ElementType)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+		}
+
+	case *schema.MapType:
+		obj := map[string]interface{}{}
+		for {
+			count, err := r.blockCount()
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return obj, nil
+			}
+			for i := int64(0); i < count; i++ {
+				k, err := r.value(&schema.PrimitiveType{Name: "string"})
+				if err != nil {
+					return nil, err
+				}
+				v, err := r.value(t.ValueType)
+				if err != nil {
+					return nil, err
+				}
+				obj[k.(string)] = v
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("unknown type: %T", typ)
+}
+
+func (r *avroReader) union(t *schema.UnionType) (interface{}, error) {
+	start := r.pos
+	branch, err := r.long()
+	if err != nil {
+		return nil, err
+	}
+	tag := branch + 1
+	if t.Optional {
+		if branch == 0 {
+			return nil, nil
+		}
+		tag = branch
+	}
+	if tag < 1 || tag > int64(len(t.Variants)) {
+		return nil, fmt.Errorf("offset %d: invalid union branch %d", start, branch)
+	}
+	variant := t.Variants[tag-1]
+	v, err := r.value(variant)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{variant.TypeName(): v}, nil
+}
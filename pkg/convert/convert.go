@@ -0,0 +1,139 @@
+// Package convert translates ffire payloads to and from other serialization
+// formats (JSON, MessagePack, Avro, Arrow IPC), so ffire data can be exchanged with
+// systems that do not speak the ffire wire format.
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Supported formats.
+const (
+	FormatFFire   = "ffire"   // ffire binary wire format
+	FormatJSON    = "json"    // JSON fixture, keyed by JSON field names
+	FormatMsgpack = "msgpack" // MessagePack, structs as maps keyed by JSON field names
+	FormatAvro    = "avro"    // Avro object container file with one record
+
+	FormatArrow       = "arrow"        // Arrow IPC file (Feather v2), array-of-struct messages only
+	FormatArrowStream = "arrow-stream" // Arrow IPC stream, array-of-struct messages only
+)
+
+// Formats lists all supported format names.
+var Formats = []string{FormatFFire, FormatJSON, FormatMsgpack, FormatAvro, FormatArrow, FormatArrowStream}
+
+// Convert transcodes a payload of messageName from one format to another.
+// The value is always validated against the schema on the way through.
+func Convert(s *schema.Schema, messageName, from, to string, data []byte) ([]byte, error) {
+	value, err := Decode(s, messageName, from, data)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", from, err)
+	}
+	out, err := Encode(s, messageName, to, value)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", to, err)
+	}
+	return out, nil
+}
+
+// Decode reads a payload in the given format and returns the normalized value
+// tree produced by fixture.Decode (int64 integers, float64 floats).
+func Decode(s *schema.Schema, messageName, format string, data []byte) (interface{}, error) {
+	typ, err := messageType(s, messageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch format {
+	case FormatFFire:
+		return fixture.Decode(s, messageName, data)
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	case FormatMsgpack:
+		value, err = decodeMsgpack(data)
+	case FormatAvro:
+		value, err = decodeAvroContainer(s, messageName, typ, data)
+	case FormatArrow, FormatArrowStream:
+		value, err = decodeArrow(typ, data)
+	default:
+		return nil, unknownFormat(format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return normalize(s, messageName, value)
+}
+
+// Encode writes a value tree in the given format.
+func Encode(s *schema.Schema, messageName, format string, value interface{}) ([]byte, error) {
+	typ, err := messageType(s, messageName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatFFire:
+		return fixture.Encode(s, messageName, value)
+	case FormatJSON, FormatMsgpack, FormatAvro, FormatArrow, FormatArrowStream:
+	default:
+		return nil, unknownFormat(format)
+	}
+
+	if value, err = normalize(s, messageName, value); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSON:
+		out, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	case FormatMsgpack:
+		buf := &bytes.Buffer{}
+		if err := encodeMsgpack(buf, typ, value); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case FormatAvro:
+		return encodeAvroContainer(s, messageName, typ, value)
+	default:
+		return encodeArrow(typ, value, format == FormatArrow)
+	}
+}
+
+// normalize validates value against the schema and converts it to the
+// representation produced by fixture.Decode, so encoders only handle one
+// set of Go types.
+func normalize(s *schema.Schema, messageName string, value interface{}) (interface{}, error) {
+	data, err := fixture.Encode(s, messageName, value)
+	if err != nil {
+		return nil, err
+	}
+	return fixture.Decode(s, messageName, data)
+}
+
+func messageType(s *schema.Schema, messageName string) (schema.Type, error) {
+	for _, msg := range s.Messages {
+		if msg.Name == messageName {
+			return msg.TargetType, nil
+		}
+	}
+	return nil, fmt.Errorf("message type %s not found in schema", messageName)
+}
+
+func unknownFormat(format string) error {
+	return fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(Formats, ", "))
+}
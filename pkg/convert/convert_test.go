@@ -0,0 +1,434 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func testSchema() *schema.Schema {
+	point := &schema.StructType{
+		Name: "Point",
+		Fields: []schema.Field{
+			{Name: "X", Type: &schema.PrimitiveType{Name: "float32"}},
+			{Name: "Y", Type: &schema.PrimitiveType{Name: "float32"}},
+		},
+	}
+	optPoint := *point
+	optPoint.Optional = true
+
+	shape := &schema.StructType{
+		Name: "Shape",
+		Fields: []schema.Field{
+			{Name: "ID", Type: &schema.PrimitiveType{Name: "int64"}},
+			{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+			{Name: "Visible", Type: &schema.PrimitiveType{Name: "bool"}},
+			{Name: "Layer", Type: &schema.PrimitiveType{Name: "int8"}},
+			{Name: "Origin", Type: &optPoint},
+			{Name: "Points", Type: &schema.ArrayType{ElementType: point}},
+			{Name: "Label", Type: &schema.PrimitiveType{Name: "string", Optional: true}},
+		},
+	}
+	shape.Fields[1].SetJSONTag("name")
+
+	return &schema.Schema{
+		Package:  "geo",
+		Messages: []schema.MessageType{{Name: "Shape", TargetType: shape}},
+		Types:    []schema.Type{point, shape},
+	}
+}
+
+const testJSON = `{
+	"ID": 9007199254740993,
+	"name": "triangle",
+	"Visible": true,
+	"Layer": -3,
+	"Origin": {"X": 1.5, "Y": -2},
+	"Points": [{"X": 0, "Y": 0}, {"X": 1, "Y": 0}, {"X": 0.5, "Y": 1}]
+}`
+
+func TestConvertRoundtrip(t *testing.T) {
+	s := testSchema()
+
+	binary, err := Convert(s, "Shape", FormatJSON, FormatFFire, []byte(testJSON))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatJSON, FormatMsgpack, FormatAvro} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "Shape", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			back, err := Convert(s, "Shape", format, FormatFFire, encoded)
+			if err != nil {
+				t.Fatalf("%s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("roundtrip through %s changed payload:\n got %x\nwant %x", format, back, binary)
+			}
+		})
+	}
+}
+
+func TestMsgpackEncoding(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{{
+			Name: "Pair",
+			TargetType: &schema.StructType{Name: "Pair", Fields: []schema.Field{
+				{Name: "A", Type: &schema.PrimitiveType{Name: "int32"}},
+				{Name: "B", Type: &schema.PrimitiveType{Name: "int32", Optional: true}},
+			}},
+		}},
+	}
+
+	out, err := Encode(s, "Pair", FormatMsgpack, map[string]interface{}{"A": int64(-200)})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// fixmap(2) "A" int16(-200) "B" nil
+	want := []byte{0x82, 0xa1, 'A', 0xd1, 0xff, 0x38, 0xa1, 'B', 0xc0}
+	if !bytes.Equal(out, want) {
+		t.Errorf("got %x, want %x", out, want)
+	}
+
+	if _, err := Decode(s, "Pair", FormatMsgpack, []byte{0x81, 0xc0, 0x01}); err == nil || !strings.Contains(err.Error(), "map keys") {
+		t.Errorf("expected error for nil map key, got %v", err)
+	}
+	if _, err := Decode(s, "Pair", FormatMsgpack, append(want, 0x00)); err == nil {
+		t.Error("expected error for trailing bytes")
+	}
+}
+
+func TestAvroSchema(t *testing.T) {
+	out, err := AvroSchema(testSchema(), "Shape")
+	if err != nil {
+		t.Fatalf("AvroSchema failed: %v", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if parsed["type"] != "record" || parsed["name"] != "Shape" || parsed["namespace"] != "geo" {
+		t.Errorf("unexpected record header: %s", out)
+	}
+
+	src := string(out)
+	for _, want := range []string{`"name": "name"`, `"long"`, `"null"`, `"items": "Point"`} {
+		if !strings.Contains(src, want) {
+			t.Errorf("Avro schema missing %s:\n%s", want, src)
+		}
+	}
+	// Point is defined once, then referenced by name
+	if strings.Count(src, `"name": "Point"`) != 1 {
+		t.Errorf("Point should be defined exactly once:\n%s", src)
+	}
+}
+
+func TestAvroSchemaMismatch(t *testing.T) {
+	s := testSchema()
+	binary, err := fixture.Convert(s, "Shape", []byte(testJSON))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	avro, err := Convert(s, "Shape", FormatFFire, FormatAvro, binary)
+	if err != nil {
+		t.Fatalf("ffire -> avro failed: %v", err)
+	}
+
+	other := testSchema()
+	other.Package = "other"
+	if _, err := Decode(other, "Shape", FormatAvro, avro); err == nil || !strings.Contains(err.Error(), "writer schema") {
+		t.Errorf("Decode error = %v, want writer schema mismatch", err)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := Convert(testSchema(), "Shape", FormatJSON, "parquet", []byte(testJSON)); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func shapeListSchema() *schema.Schema {
+	s := testSchema()
+	shape := s.Messages[0].TargetType
+	s.Messages = []schema.MessageType{{Name: "ShapeList", TargetType: &schema.ArrayType{ElementType: shape}}}
+	return s
+}
+
+const testListJSON = `[
+	{"ID": 1, "name": "a", "Visible": true, "Layer": 1, "Points": [], "Label": "first"},
+	{"ID": 2, "name": "b", "Visible": false, "Layer": -1, "Origin": {"X": 1, "Y": 2},
+	 "Points": [{"X": 0, "Y": 0}, {"X": 3, "Y": 4}]},
+	{"ID": 3, "name": "", "Visible": true, "Layer": 0, "Points": [{"X": 5, "Y": 6}]}
+]`
+
+func TestArrowRoundtrip(t *testing.T) {
+	s := shapeListSchema()
+
+	binary, err := Convert(s, "ShapeList", FormatJSON, FormatFFire, []byte(testListJSON))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatArrow, FormatArrowStream} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "ShapeList", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			if format == FormatArrow && (!bytes.HasPrefix(encoded, []byte("ARROW1")) || !bytes.HasSuffix(encoded, []byte("ARROW1"))) {
+				t.Errorf("Arrow file missing magic")
+			}
+			back, err := Convert(s, "ShapeList", format, FormatFFire, encoded)
+			if err != nil {
+				t.Fatalf("%s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("roundtrip through %s changed payload:\n got %x\nwant %x", format, back, binary)
+			}
+		})
+	}
+}
+
+func TestArrowSchemaMismatch(t *testing.T) {
+	s := shapeListSchema()
+	arrow, err := Convert(s, "ShapeList", FormatJSON, FormatArrowStream, []byte(testListJSON))
+	if err != nil {
+		t.Fatalf("JSON -> arrow failed: %v", err)
+	}
+
+	other := shapeListSchema()
+	row := other.Messages[0].TargetType.(*schema.ArrayType).ElementType.(*schema.StructType)
+	row.Fields[0].Type = &schema.PrimitiveType{Name: "int32"}
+
+	if _, err := Decode(other, "ShapeList", FormatArrowStream, arrow); err == nil || !strings.Contains(err.Error(), "column 0") {
+		t.Errorf("Decode error = %v, want column type mismatch", err)
+	}
+
+	// Truncated input must fail cleanly, not panic
+	for _, n := range []int{0, 6, 40, len(arrow) / 2, len(arrow) - 8} {
+		if _, err := Decode(s, "ShapeList", FormatArrowStream, arrow[:n]); err == nil {
+			t.Errorf("expected error decoding %d of %d bytes", n, len(arrow))
+		}
+	}
+}
+
+func TestArrowRequiresArrayOfStruct(t *testing.T) {
+	if _, err := Convert(testSchema(), "Shape", FormatJSON, FormatArrow, []byte(testJSON)); err == nil {
+		t.Error("expected error for non-array message")
+	}
+}
+
+func TestConvertMapRoundtrip(t *testing.T) {
+	table := &schema.StructType{
+		Name: "Table",
+		Fields: []schema.Field{
+			{Name: "Counts", Type: &schema.MapType{
+				KeyType:   &schema.PrimitiveType{Name: "int16"},
+				ValueType: &schema.PrimitiveType{Name: "string", Optional: true},
+			}},
+			{Name: "Tags", Type: &schema.MapType{
+				KeyType:   &schema.PrimitiveType{Name: "string"},
+				ValueType: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "bool"}},
+			}},
+		},
+	}
+	s := &schema.Schema{
+		Package:  "test",
+		Messages: []schema.MessageType{{Name: "Table", TargetType: table}},
+		Types:    []schema.Type{table},
+	}
+
+	binary, err := Convert(s, "Table", FormatJSON, FormatFFire,
+		[]byte(`{"Counts": {"10": "ten", "-1": null, "2": "two"}, "Tags": {"b": [true], "a": []}}`))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatJSON, FormatMsgpack, FormatAvro} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "Table", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			back, err := Convert(s, "Table", format, FormatFFire, encoded)
+			if err != nil {
+				t.Fatalf("%s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("roundtrip through %s changed payload:\n got %x\nwant %x", format, back, binary)
+			}
+		})
+	}
+
+	// MessagePack keeps integer keys as integers, in wire order
+	packed, err := Convert(s, "Table", FormatFFire, FormatMsgpack, binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(packed, []byte{0x83, 0xff, 0xc0, 0x02, 0xa3, 't', 'w', 'o', 0x0a}) {
+		t.Errorf("unexpected MessagePack map encoding: %x", packed)
+	}
+}
+
+func TestConvertEnumRoundtrip(t *testing.T) {
+	mode := &schema.EnumType{Name: "Mode", Base: "int8", Values: []schema.EnumValue{{Name: "Mono", Value: 1}, {Name: "Stereo", Value: 2}}}
+	optMode := *mode
+	optMode.Optional = true
+	track := &schema.StructType{
+		Name: "Track",
+		Fields: []schema.Field{
+			{Name: "Mode", Type: mode},
+			{Name: "Backup", Type: &optMode},
+			{Name: "History", Type: &schema.ArrayType{ElementType: mode}},
+		},
+	}
+	s := &schema.Schema{
+		Package:  "test",
+		Messages: []schema.MessageType{{Name: "Tracks", TargetType: &schema.ArrayType{ElementType: track}}},
+		Types:    []schema.Type{mode, track},
+	}
+
+	binary, err := Convert(s, "Tracks", FormatJSON, FormatFFire,
+		[]byte(`[{"Mode": "Stereo", "Backup": 1, "History": ["Mono", "Stereo"]}, {"Mode": "Mono", "History": []}]`))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatJSON, FormatMsgpack, FormatAvro, FormatArrow} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "Tracks", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			back, err := Convert(s, "Tracks", format, FormatFFire, encoded)
+			if err != nil {
+				t.Fatalf("%s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("roundtrip through %s changed payload:\n got %x\nwant %x", format, back, binary)
+			}
+		})
+	}
+
+	avro, err := AvroSchema(s, "Tracks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(avro), `"symbols": [`) {
+		t.Errorf("Avro schema has no enum:\n%s", avro)
+	}
+}
+
+func TestConvertUnionRoundtrip(t *testing.T) {
+	circle := &schema.StructType{Name: "Circle", Fields: []schema.Field{{Name: "R", Type: &schema.PrimitiveType{Name: "float32"}}}}
+	shape := &schema.UnionType{Name: "Shape", Variants: []schema.Type{circle, &schema.PrimitiveType{Name: "string"}}}
+	optShape := *shape
+	optShape.Optional = true
+	drawing := &schema.StructType{
+		Name: "Drawing",
+		Fields: []schema.Field{
+			{Name: "Shapes", Type: &schema.ArrayType{ElementType: shape}},
+			{Name: "Hint", Type: &optShape},
+		},
+	}
+	s := &schema.Schema{
+		Package:  "test",
+		Messages: []schema.MessageType{{Name: "Drawing", TargetType: drawing}},
+		Types:    []schema.Type{shape, drawing, circle},
+	}
+
+	binary, err := Convert(s, "Drawing", FormatJSON, FormatFFire,
+		[]byte(`{"Shapes": [{"Circle": {"R": 1.5}}, {"string": "dot"}], "Hint": {"string": "x"}}`))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatJSON, FormatMsgpack, FormatAvro} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "Drawing", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			back, err := Convert(s, "Drawing", format, FormatFFire, encoded)
+			if err != nil {
+				t.Fatalf("%s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("roundtrip through %s changed payload:\n got %x\nwant %x", format, back, binary)
+			}
+		})
+	}
+
+	avro, err := AvroSchema(s, "Drawing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, avro); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(compact.String(), `"type":["null","Circle","string"]`) {
+		t.Errorf("Avro schema has no flattened optional union:\n%s", avro)
+	}
+
+	shape.Variants = append(shape.Variants, &schema.PrimitiveType{Name: "int8"}, &schema.PrimitiveType{Name: "int16"})
+	if _, err := AvroSchema(s, "Drawing"); err == nil || !strings.Contains(err.Error(), "both map to Avro int") {
+		t.Errorf("expected Avro branch clash, got %v", err)
+	}
+}
+
+func TestConvertBytesRoundtrip(t *testing.T) {
+	blob := &schema.StructType{
+		Name: "Blob",
+		Fields: []schema.Field{
+			{Name: "Data", Type: &schema.PrimitiveType{Name: "bytes"}},
+			{Name: "Thumb", Type: &schema.PrimitiveType{Name: "bytes", Optional: true}},
+			{Name: "Chunks", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "bytes"}}},
+		},
+	}
+	s := &schema.Schema{
+		Package:  "test",
+		Messages: []schema.MessageType{{Name: "Blobs", TargetType: &schema.ArrayType{ElementType: blob}}},
+		Types:    []schema.Type{blob},
+	}
+
+	binary, err := Convert(s, "Blobs", FormatJSON, FormatFFire,
+		[]byte(`[{"Data": "3q2+7w==", "Thumb": "AA==", "Chunks": ["", "AQI="]}, {"Data": "", "Chunks": []}]`))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatJSON, FormatMsgpack, FormatAvro, FormatArrow} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "Blobs", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			back, err := Convert(s, "Blobs", format, FormatFFire, encoded)
+			if err != nil {
+				t.Fatalf("%s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("roundtrip through %s changed payload:\n got %x\nwant %x", format, back, binary)
+			}
+		})
+	}
+
+	msgpack, err := Convert(s, "Blobs", FormatFFire, FormatMsgpack, binary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(msgpack, []byte{0xc4, 0x04, 0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("MessagePack payload does not carry Data as bin 8: %x", msgpack)
+	}
+}
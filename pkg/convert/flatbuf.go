@@ -0,0 +1,295 @@
+package convert
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// A minimal FlatBuffers builder and reader, sufficient for Arrow IPC
+// metadata. The builder writes front to back: every object is written before
+// the objects it references, so all uoffsets point forward as required.
+
+// fbObject is anything that can be referenced by an offset.
+type fbObject interface {
+	write(b *fbBuilder) int // Returns the absolute position of the object
+}
+
+// fbField is one table slot: either an inline scalar or an offset.
+type fbField struct {
+	scalar []byte // Little-endian inline value
+	ref    fbObject
+}
+
+func fbUint8(v uint8) *fbField { return &fbField{scalar: []byte{v}} }
+func fbBool(v bool) *fbField {
+	if v {
+		return fbUint8(1)
+	}
+	return fbUint8(0)
+}
+func fbInt16(v int16) *fbField {
+	return &fbField{scalar: binary.LittleEndian.AppendUint16(nil, uint16(v))}
+}
+func fbInt32(v int32) *fbField {
+	return &fbField{scalar: binary.LittleEndian.AppendUint32(nil, uint32(v))}
+}
+func fbInt64(v int64) *fbField {
+	return &fbField{scalar: binary.LittleEndian.AppendUint64(nil, uint64(v))}
+}
+func fbRef(obj fbObject) *fbField { return &fbField{ref: obj} }
+
+// fbTable is a table; fields are indexed by field id, nil means absent.
+type fbTable []*fbField
+
+type fbString string
+
+type fbTableVector []fbTable
+
+// fbStructVector is a vector of fixed-size structs with 8-byte alignment.
+type fbStructVector struct {
+	count int
+	data  []byte
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) pad(align, phase int) {
+	for len(b.buf)%align != phase {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) patchOffset(at, target int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(target-at))
+}
+
+// finish serializes root and returns the buffer. The buffer must be placed
+// at an 8-byte aligned position by the caller.
+func fbFinish(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	b.patchOffset(0, root.write(b))
+	return b.buf
+}
+
+func (t fbTable) write(b *fbBuilder) int {
+	// vtable: [vtable size][table size][field offsets...]
+	b.pad(2, 0)
+	vtablePos := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 4+2*len(t))...)
+
+	maxAlign := 4
+	for _, f := range t {
+		if f != nil && len(f.scalar) > maxAlign {
+			maxAlign = len(f.scalar)
+		}
+	}
+	b.pad(maxAlign, 0)
+	tablePos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(int32(tablePos-vtablePos)))
+
+	type pendingRef struct {
+		at  int
+		obj fbObject
+	}
+	var refs []pendingRef
+	for i, f := range t {
+		if f == nil {
+			continue
+		}
+		size := 4
+		if f.ref == nil {
+			size = len(f.scalar)
+		}
+		b.pad(size, 0)
+		binary.LittleEndian.PutUint16(b.buf[vtablePos+4+2*i:], uint16(len(b.buf)-tablePos))
+		if f.ref == nil {
+			b.buf = append(b.buf, f.scalar...)
+		} else {
+			refs = append(refs, pendingRef{at: len(b.buf), obj: f.ref})
+			b.buf = append(b.buf, 0, 0, 0, 0)
+		}
+	}
+
+	binary.LittleEndian.PutUint16(b.buf[vtablePos:], uint16(4+2*len(t)))
+	binary.LittleEndian.PutUint16(b.buf[vtablePos+2:], uint16(len(b.buf)-tablePos))
+
+	// Referenced objects follow the table, in field order
+	for _, ref := range refs {
+		b.patchOffset(ref.at, ref.obj.write(b))
+	}
+
+	return tablePos
+}
+
+func (s fbString) write(b *fbBuilder) int {
+	b.pad(4, 0)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+func (v fbTableVector) write(b *fbBuilder) int {
+	b.pad(4, 0)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	slots := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, t := range v {
+		b.patchOffset(slots+4*i, t.write(b))
+	}
+	return pos
+}
+
+func (v *fbStructVector) write(b *fbBuilder) int {
+	b.pad(8, 4) // Elements start 8-byte aligned after the 4-byte length
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.count))
+	b.buf = append(b.buf, v.data...)
+	return pos
+}
+
+// fbError is raised (via panic) by the reader on out-of-bounds access and
+// converted to an error by fbRecover.
+type fbError struct{ msg string }
+
+func fbRecover(err *error) {
+	if r := recover(); r != nil {
+		fe, ok := r.(fbError)
+		if !ok {
+			panic(r)
+		}
+		*err = fmt.Errorf("malformed Arrow metadata: %s", fe.msg)
+	}
+}
+
+// fbReader reads tables from a FlatBuffers buffer.
+type fbReader struct {
+	buf []byte
+	pos int // Table position
+}
+
+func fbRootTable(buf []byte) fbReader {
+	r := fbReader{buf: buf}
+	return fbReader{buf: buf, pos: r.uoffset(0)}
+}
+
+func (r fbReader) check(pos, n int) {
+	if pos < 0 || n < 0 || pos+n > len(r.buf) {
+		panic(fbError{fmt.Sprintf("offset %d out of bounds", pos)})
+	}
+}
+
+func (r fbReader) uoffset(at int) int {
+	r.check(at, 4)
+	return at + int(binary.LittleEndian.Uint32(r.buf[at:]))
+}
+
+// field returns the absolute position of field id, or -1 if absent.
+func (r fbReader) field(id int) int {
+	r.check(r.pos, 4)
+	vtable := r.pos - int(int32(binary.LittleEndian.Uint32(r.buf[r.pos:])))
+	r.check(vtable, 4)
+	vtableSize := int(binary.LittleEndian.Uint16(r.buf[vtable:]))
+	if 4+2*id+2 > vtableSize {
+		return -1
+	}
+	r.check(vtable+4+2*id, 2)
+	off := int(binary.LittleEndian.Uint16(r.buf[vtable+4+2*id:]))
+	if off == 0 {
+		return -1
+	}
+	return r.pos + off
+}
+
+func (r fbReader) uint8(id int, def uint8) uint8 {
+	pos := r.field(id)
+	if pos < 0 {
+		return def
+	}
+	r.check(pos, 1)
+	return r.buf[pos]
+}
+
+func (r fbReader) int16(id int, def int16) int16 {
+	pos := r.field(id)
+	if pos < 0 {
+		return def
+	}
+	r.check(pos, 2)
+	return int16(binary.LittleEndian.Uint16(r.buf[pos:]))
+}
+
+func (r fbReader) int32(id int, def int32) int32 {
+	pos := r.field(id)
+	if pos < 0 {
+		return def
+	}
+	r.check(pos, 4)
+	return int32(binary.LittleEndian.Uint32(r.buf[pos:]))
+}
+
+func (r fbReader) int64(id int, def int64) int64 {
+	pos := r.field(id)
+	if pos < 0 {
+		return def
+	}
+	r.check(pos, 8)
+	return int64(binary.LittleEndian.Uint64(r.buf[pos:]))
+}
+
+func (r fbReader) table(id int) (fbReader, bool) {
+	pos := r.field(id)
+	if pos < 0 {
+		return fbReader{}, false
+	}
+	return fbReader{buf: r.buf, pos: r.uoffset(pos)}, true
+}
+
+func (r fbReader) string(id int) string {
+	pos := r.field(id)
+	if pos < 0 {
+		return ""
+	}
+	start := r.uoffset(pos)
+	r.check(start, 4)
+	n := int(binary.LittleEndian.Uint32(r.buf[start:]))
+	r.check(start+4, n)
+	return string(r.buf[start+4 : start+4+n])
+}
+
+// vector returns the position of the first element and the element count.
+func (r fbReader) vector(id int) (int, int) {
+	pos := r.field(id)
+	if pos < 0 {
+		return 0, 0
+	}
+	start := r.uoffset(pos)
+	r.check(start, 4)
+	return start + 4, int(binary.LittleEndian.Uint32(r.buf[start:]))
+}
+
+// tables returns the tables of a vector-of-tables field.
+func (r fbReader) tables(id int) []fbReader {
+	start, n := r.vector(id)
+	r.check(start, 4*n)
+	result := make([]fbReader, n)
+	for i := range result {
+		result[i] = fbReader{buf: r.buf, pos: r.uoffset(start + 4*i)}
+	}
+	return result
+}
+
+// structs returns the raw bytes of a vector-of-structs field.
+func (r fbReader) structs(id int, size int) [][]byte {
+	start, n := r.vector(id)
+	r.check(start, size*n)
+	result := make([][]byte, n)
+	for i := range result {
+		result[i] = r.buf[start+size*i : start+size*(i+1)]
+	}
+	return result
+}
@@ -0,0 +1,156 @@
+package convert
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// The interop tests check the Arrow and Avro output against the reference
+// Python implementations. They skip unless python3 can import pyarrow or
+// fastavro.
+
+// pythonWith returns the path of python3, skipping the test unless it can
+// import module.
+func pythonWith(t *testing.T, module string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping interop test in short mode")
+	}
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not installed")
+	}
+	if err := exec.Command(python, "-c", "import "+module).Run(); err != nil {
+		t.Skipf("python3 cannot import %s", module)
+	}
+	return python
+}
+
+// runPython runs script with args and returns what it writes to stdout.
+func runPython(t *testing.T, python, script string, args ...string) []byte {
+	t.Helper()
+	var stderr bytes.Buffer
+	cmd := exec.Command(python, append([]string{"-c", script}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("python: %v\n%s", err, stderr.Bytes())
+	}
+	return out
+}
+
+// Reads an Arrow file or stream and prints its rows as JSON, or with a
+// third argument writes the table back out in the same format there.
+const pyarrowScript = `
+import json, sys
+import pyarrow as pa
+
+path, fmt = sys.argv[1], sys.argv[2]
+with open(path, "rb") as f:
+    data = f.read()
+if fmt == "arrow":
+    table = pa.ipc.open_file(pa.BufferReader(data)).read_all()
+else:
+    table = pa.ipc.open_stream(pa.BufferReader(data)).read_all()
+
+if len(sys.argv) < 4:
+    json.dump(table.to_pylist(), sys.stdout)
+    sys.exit()
+
+sink = pa.BufferOutputStream()
+new = pa.ipc.new_file if fmt == "arrow" else pa.ipc.new_stream
+with new(sink, table.schema) as writer:
+    writer.write_table(table)
+with open(sys.argv[3], "wb") as f:
+    f.write(sink.getvalue().to_pybytes())
+`
+
+func TestArrowInteropPyarrow(t *testing.T) {
+	python := pythonWith(t, "pyarrow")
+	s := shapeListSchema()
+
+	binary, err := Convert(s, "ShapeList", FormatJSON, FormatFFire, []byte(testListJSON))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+
+	for _, format := range []string{FormatArrow, FormatArrowStream} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := Convert(s, "ShapeList", FormatFFire, format, binary)
+			if err != nil {
+				t.Fatalf("ffire -> %s failed: %v", format, err)
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "shapes."+format)
+			if err := os.WriteFile(path, encoded, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// pyarrow reads the values that were written
+			rows := runPython(t, python, pyarrowScript, path, format)
+			back, err := Convert(s, "ShapeList", FormatJSON, FormatFFire, rows)
+			if err != nil {
+				t.Fatalf("pyarrow rows -> ffire failed: %v\n%s", err, rows)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("pyarrow read different values:\n%s", rows)
+			}
+
+			// and what pyarrow writes reads back to the same payload
+			rewritten := filepath.Join(dir, "pyarrow."+format)
+			runPython(t, python, pyarrowScript, path, format, rewritten)
+			data, err := os.ReadFile(rewritten)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err = Convert(s, "ShapeList", format, FormatFFire, data)
+			if err != nil {
+				t.Fatalf("pyarrow %s -> ffire failed: %v", format, err)
+			}
+			if !bytes.Equal(back, binary) {
+				t.Errorf("pyarrow output changed payload:\n got %x\nwant %x", back, binary)
+			}
+		})
+	}
+}
+
+// Reads an Avro object container file and prints its single record as JSON.
+const fastavroScript = `
+import json, sys
+import fastavro
+
+with open(sys.argv[1], "rb") as f:
+    records = list(fastavro.reader(f))
+assert len(records) == 1, len(records)
+json.dump(records[0], sys.stdout)
+`
+
+func TestAvroInteropFastavro(t *testing.T) {
+	python := pythonWith(t, "fastavro")
+	s := testSchema()
+
+	binary, err := Convert(s, "Shape", FormatJSON, FormatFFire, []byte(testJSON))
+	if err != nil {
+		t.Fatalf("JSON -> ffire failed: %v", err)
+	}
+	encoded, err := Convert(s, "Shape", FormatFFire, FormatAvro, binary)
+	if err != nil {
+		t.Fatalf("ffire -> avro failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "shape.avro")
+	if err := os.WriteFile(path, encoded, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	record := runPython(t, python, fastavroScript, path)
+	back, err := Convert(s, "Shape", FormatJSON, FormatFFire, record)
+	if err != nil {
+		t.Fatalf("fastavro record -> ffire failed: %v\n%s", err, record)
+	}
+	if !bytes.Equal(back, binary) {
+		t.Errorf("fastavro read different values:\n%s", record)
+	}
+}
@@ -0,0 +1,365 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// MessagePack support is hand-written to avoid a runtime dependency. The
+// encoder is schema-guided (struct fields are written in schema order, float32
+// fields as float 32, enums by constant name, unions as a one-entry map keyed
+// by the variant name, map entries in wire order with native integer and bool
+// keys, bytes as bin); the decoder is generic and relies on normalize for
+// validation, reading bin as base64.
+
+func encodeMsgpack(buf *bytes.Buffer, typ schema.Type, value interface{}) error {
+	if value == nil {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			if value.(bool) {
+				buf.WriteByte(0xc3)
+			} else {
+				buf.WriteByte(0xc2)
+			}
+		case "float32":
+			buf.WriteByte(0xca)
+			binary.Write(buf, binary.BigEndian, math.Float32bits(float32(value.(float64))))
+		case "float64":
+			buf.WriteByte(0xcb)
+			binary.Write(buf, binary.BigEndian, math.Float64bits(value.(float64)))
+		case "string":
+			writeMsgpackString(buf, value.(string))
+		case "bytes":
+			b, err := base64.StdEncoding.DecodeString(value.(string))
+			if err != nil {
+				return err
+			}
+			writeMsgpackBin(buf, b)
+		default:
+			writeMsgpackInt(buf, value.(int64))
+		}
+
+	case *schema.EnumType:
+		writeMsgpackString(buf, value.(string))
+
+	case *schema.UnionType:
+		_, variant, inner, err := fixture.UnionValue(t, value)
+		if err != nil {
+			return err
+		}
+		writeMsgpackHeader(buf, 1, 0x80, 0xde, 0xdf)
+		writeMsgpackString(buf, variant.TypeName())
+		if err := encodeMsgpack(buf, variant, inner); err != nil {
+			return err
+		}
+
+	case *schema.StructType:
+		obj := value.(map[string]interface{})
+		writeMsgpackHeader(buf, len(t.Fields), 0x80, 0xde, 0xdf)
+		for _, field := range t.Fields {
+			writeMsgpackString(buf, field.JSONName())
+			if err := encodeMsgpack(buf, field.Type, obj[field.JSONName()]); err != nil {
+				return err
+			}
+		}
+
+	case *schema.ArrayType:
+		arr := value.([]interface{})
+		writeMsgpackHeader(buf, len(arr), 0x90, 0xdc, 0xdd)
+		for _, elem := range arr {
+			if err := encodeMsgpack(buf, t.ElementType, elem); err != nil {
+				return err
+			}
+		}
+
+	case *schema.MapType:
+		obj := value.(map[string]interface{})
+		key := t.KeyType.(*schema.PrimitiveType)
+		keys, err := fixture.SortMapKeys(key, obj)
+		if err != nil {
+			return err
+		}
+		writeMsgpackHeader(buf, len(keys), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			parsed, err := fixture.ParseMapKey(key, k)
+			if err != nil {
+				return err
+			}
+			if err := encodeMsgpack(buf, key, parsed); err != nil {
+				return err
+			}
+			if err := encodeMsgpack(buf, t.ValueType, obj[k]); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown type: %T", typ)
+	}
+
+	return nil
+}
+
+// writeMsgpackHeader writes a map or array header: fix form for n < 16,
+// otherwise the 16 or 32 bit form.
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix, code16, code32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+// writeMsgpackBin writes b in the bin family, which holds the bytes
+// primitive.
+func writeMsgpackBin(buf *bytes.Buffer, b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.Write(b)
+}
+
+// writeMsgpackInt writes v in the smallest signed representation.
+func writeMsgpackInt(buf *bytes.Buffer, v int64) {
+	switch {
+	case v >= 0 && v <= 127:
+		buf.WriteByte(byte(v))
+	case v < 0 && v >= -32:
+		buf.WriteByte(byte(int8(v)))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(v)))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(v))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, v)
+	}
+}
+
+// decodeMsgpack decodes a MessagePack document into a generic value tree.
+func decodeMsgpack(data []byte) (interface{}, error) {
+	r := &msgpackReader{data: data}
+	value, err := r.value()
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("offset %d: %d trailing bytes after document", r.pos, len(data)-r.pos)
+	}
+	return value, nil
+}
+
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *msgpackReader) take(n int) ([]byte, error) {
+	if r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("offset %d: unexpected end of data (need %d bytes)", r.pos, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads an n-byte big-endian unsigned integer.
+func (r *msgpackReader) uint(n int) (uint64, error) {
+	b, err := r.take(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (r *msgpackReader) value() (interface{}, error) {
+	start := r.pos
+	b, err := r.take(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return r.mapValue(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return r.arrayValue(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return r.stringValue(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		v, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := r.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := r.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("offset %d: unsigned value %d does not fit int64", start, v)
+		}
+		return int64(v), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		v, err := r.uint(n)
+		if err != nil {
+			return nil, err
+		}
+		// Sign-extend from n bytes
+		shift := 64 - 8*n
+		return int64(v<<shift) >> shift, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		// Binary values become base64, as in JSON fixtures
+		b, err := r.take(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.stringValue(int(n))
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.arrayValue(int(n))
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.mapValue(int(n))
+	}
+
+	return nil, fmt.Errorf("offset %d: unsupported MessagePack type 0x%02x (ext is not supported)", start, c)
+}
+
+func (r *msgpackReader) stringValue(n int) (interface{}, error) {
+	b, err := r.take(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *msgpackReader) arrayValue(n int) (interface{}, error) {
+	if n > len(r.data)-r.pos {
+		return nil, fmt.Errorf("offset %d: array length %d exceeds remaining data", r.pos, n)
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (r *msgpackReader) mapValue(n int) (interface{}, error) {
+	if n > len(r.data)-r.pos {
+		return nil, fmt.Errorf("offset %d: map length %d exceeds remaining data", r.pos, n)
+	}
+	obj := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		keyPos := r.pos
+		k, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		// Integer and bool keys (ffire maps) take their JSON spelling
+		var key string
+		switch k := k.(type) {
+		case string:
+			key = k
+		case int64, bool:
+			key = fixture.FormatMapKey(k)
+		default:
+			return nil, fmt.Errorf("offset %d: map keys must be strings, integers or bools, got %T", keyPos, k)
+		}
+		v, err := r.value()
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = v
+	}
+	return obj, nil
+}
@@ -0,0 +1,114 @@
+// Package corpus verifies archives of binary payloads against a schema.
+package corpus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Options controls a corpus verification run.
+type Options struct {
+	Message string // Root type to decode each payload as
+	Ext     string // Only files with this extension are checked ("" = all files)
+	Workers int    // Parallel decoders (0 = runtime.NumCPU())
+}
+
+// Result records the outcome of decoding one payload file.
+type Result struct {
+	Path   string // Path relative to the corpus directory
+	Size   int    // Payload size in bytes
+	Offset int    // Byte offset of the failure, -1 if unknown or on success
+	Err    error  // nil on success
+}
+
+// Verify decodes every matching file under dir in parallel and returns one
+// Result per file, sorted by path. Decode failures are reported per file;
+// the returned error is only set if the directory cannot be walked.
+func Verify(s *schema.Schema, dir string, opts Options) ([]Result, error) {
+	found := false
+	for _, msg := range s.Messages {
+		if msg.Name == opts.Message {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("message type %s not found in schema", opts.Message)
+	}
+
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (opts.Ext != "" && !strings.HasSuffix(d.Name(), opts.Ext)) {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %s: %w", dir, err)
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]Result, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = verifyFile(s, opts.Message, dir, paths[i])
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Path < results[j].Path })
+	return results, nil
+}
+
+func verifyFile(s *schema.Schema, messageName, dir, path string) Result {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+	result := Result{Path: rel, Offset: -1}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = fmt.Errorf("read: %w", err)
+		return result
+	}
+	result.Size = len(data)
+
+	if _, err := fixture.Decode(s, messageName, data); err != nil {
+		var decErr *fixture.DecodeError
+		if errors.As(err, &decErr) {
+			result.Offset = decErr.Offset
+		}
+		result.Err = err
+	}
+	return result
+}
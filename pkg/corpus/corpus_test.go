@@ -0,0 +1,80 @@
+package corpus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func TestVerify(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Device",
+				TargetType: &schema.StructType{
+					Name: "Device",
+					Fields: []schema.Field{
+						{Name: "ID", Type: &schema.PrimitiveType{Name: "int32"}},
+						{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+					},
+				},
+			},
+		},
+	}
+
+	good, err := fixture.Convert(s, "Device", []byte(`{"ID": 7, "Name": "Speaker"}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.bin":          good,
+		"nested/b.bin":   good[:len(good)-2],
+		"nested/c.bin":   append(append([]byte{}, good...), 0x00),
+		"nested/notes.t": []byte("ignored"),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := Verify(s, dir, Options{Message: "Device", Ext: ".bin", Workers: 2})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	if results[0].Path != "a.bin" || results[0].Err != nil {
+		t.Errorf("a.bin: got %+v, want success", results[0])
+	}
+
+	// String data truncated: failure detected after the int32 and length prefix
+	if results[1].Path != filepath.Join("nested", "b.bin") || results[1].Err == nil || results[1].Offset != 6 {
+		t.Errorf("b.bin: got %+v, want failure at offset 6", results[1])
+	}
+
+	// Trailing byte reported at the end of the valid message
+	if results[2].Err == nil || results[2].Offset != len(good) {
+		t.Errorf("c.bin: got %+v, want failure at offset %d", results[2], len(good))
+	}
+}
+
+func TestVerifyUnknownMessage(t *testing.T) {
+	s := &schema.Schema{Package: "test"}
+	if _, err := Verify(s, t.TempDir(), Options{Message: "Missing"}); err == nil {
+		t.Error("expected error for unknown message type")
+	}
+}
@@ -0,0 +1,398 @@
+// Package descriptor encodes a schema as an ffire message, so processes
+// can exchange the schemas they were built from at runtime: to check that
+// a peer shares their wire layout, or to decode its messages with
+// fixture.Decode when they have no generated code for them.
+//
+// A descriptor is a Descriptor message of descriptor.ffi, which Source
+// holds. Generate code from it to read descriptors in other languages.
+// Descriptors carry the wire layout and the names and tags of a schema,
+// not its doc comments or feature annotations; fields are listed in
+// canonical order.
+package descriptor
+
+import (
+	_ "embed"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/compat"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/store"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+// Source is the schema of descriptors.
+//
+//go:embed descriptor.ffi
+var Source []byte
+
+// MessageName is the message type of a descriptor in Source.
+const MessageName = "Descriptor"
+
+// Schema returns Source parsed, in canonical order. Each call returns a
+// new schema, which the caller may modify.
+func Schema() *schema.Schema {
+	s, err := parser.ParseBytes(Source)
+	if err != nil {
+		panic("descriptor: invalid descriptor.ffi: " + err.Error())
+	}
+	s.Canonicalize()
+	return s
+}
+
+// Type kinds, as the Kind enum of Source names them.
+const (
+	kindPrimitive = "KindPrimitive"
+	kindStruct    = "KindStruct"
+	kindEnum      = "KindEnum"
+	kindUnion     = "KindUnion"
+	kindArray     = "KindArray"
+	kindMap       = "KindMap"
+)
+
+type object = map[string]interface{}
+
+// Encode returns the descriptor of s. Features lists s.EnabledFeatures.
+func Encode(s *schema.Schema) ([]byte, error) {
+	// Structs, then enums, then unions, each in declaration order, which
+	// is the order Decode returns them in
+	e := &encoder{index: make(map[string]int), defined: make(map[string]bool)}
+	for _, t := range s.Types {
+		if _, ok := t.(*schema.StructType); ok {
+			e.define(t)
+		}
+	}
+	for _, t := range s.Types {
+		if _, ok := t.(*schema.EnumType); ok {
+			e.define(t)
+		}
+	}
+	for _, t := range s.Types {
+		if _, ok := t.(*schema.UnionType); ok {
+			e.define(t)
+		}
+	}
+	messages := []interface{}{}
+	for _, msg := range s.Messages {
+		messages = append(messages, object{"Name": msg.Name, "Type": e.ref(msg.TargetType)})
+	}
+	features := []interface{}{}
+	for _, f := range s.EnabledFeatures {
+		features = append(features, f)
+	}
+	return fixture.Encode(Schema(), MessageName, object{
+		"Package":  s.Package,
+		"Version":  s.Version,
+		"Features": features,
+		"Messages": messages,
+		"Types":    e.types,
+		"Structs":  e.structs,
+		"Enums":    e.enums,
+		"Unions":   e.unions,
+	})
+}
+
+// encoder builds the value tree of a descriptor. Type uses are interned:
+// equal uses share one TypeDesc.
+type encoder struct {
+	types   []interface{}
+	index   map[string]int // TypeDesc index by its fields, as a string
+	structs []interface{}
+	enums   []interface{}
+	unions  []interface{}
+	defined map[string]bool // Named types written so far
+}
+
+// ref returns the index of the TypeDesc of t, adding it and the definition
+// of the type it names if needed.
+func (e *encoder) ref(t schema.Type) int {
+	desc := object{"Kind": kindPrimitive, "Name": "", "Optional": t.IsOptional(), "Large": schema.IsLarge(t), "Length": 0, "Elem": 0, "Key": 0}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		desc["Name"] = typ.Name
+	case *schema.StructType:
+		desc["Kind"], desc["Name"] = kindStruct, typ.Name
+		e.define(typ)
+	case *schema.EnumType:
+		desc["Kind"], desc["Name"] = kindEnum, typ.Name
+		e.define(typ)
+	case *schema.UnionType:
+		desc["Kind"], desc["Name"] = kindUnion, typ.Name
+		e.define(typ)
+	case *schema.ArrayType:
+		desc["Kind"], desc["Length"], desc["Elem"] = kindArray, typ.Length, e.ref(typ.ElementType)
+	case *schema.MapType:
+		desc["Kind"], desc["Key"], desc["Elem"] = kindMap, e.ref(typ.KeyType), e.ref(typ.ValueType)
+	}
+
+	key := fmt.Sprint(desc["Kind"], desc["Name"], desc["Optional"], desc["Large"], desc["Length"], desc["Elem"], desc["Key"])
+	if i, ok := e.index[key]; ok {
+		return i
+	}
+	e.index[key] = len(e.types)
+	e.types = append(e.types, desc)
+	return len(e.types) - 1
+}
+
+// define adds the definition of a struct, enum or union, once per name.
+// Definitions are added before the types they use.
+func (e *encoder) define(t schema.Type) {
+	name := t.TypeName()
+	if e.defined[name] {
+		return
+	}
+	e.defined[name] = true
+
+	switch typ := t.(type) {
+	case *schema.StructType:
+		def := object{"Name": name, "Bitmap": typ.Bitmap, "Framed": typ.Framed}
+		e.structs = append(e.structs, def)
+		fields := []interface{}{}
+		for _, field := range schema.SortFieldsCanonical(typ.Fields) {
+			fields = append(fields, object{"Name": field.Name, "Tag": field.Tag, "Type": e.ref(field.Type)})
+		}
+		def["Fields"] = fields
+	case *schema.EnumType:
+		values := []interface{}{}
+		for _, v := range typ.Values {
+			values = append(values, object{"Name": v.Name, "Value": v.Value})
+		}
+		e.enums = append(e.enums, object{"Name": name, "Base": typ.Base, "Values": values})
+	case *schema.UnionType:
+		def := object{"Name": name}
+		e.unions = append(e.unions, def)
+		variants := []interface{}{}
+		for _, v := range typ.Variants {
+			variants = append(variants, e.ref(v))
+		}
+		def["Variants"] = variants
+	}
+}
+
+// Decode returns the schema a descriptor describes. The schema is
+// validated and in canonical order, ready for fixture.Decode.
+func Decode(data []byte) (*schema.Schema, error) {
+	tree, err := fixture.Decode(Schema(), MessageName, data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor: %w", err)
+	}
+	root := tree.(object)
+	d := &decoder{
+		types:    list(root["Types"]),
+		structs:  byName(root["Structs"]),
+		enums:    byName(root["Enums"]),
+		unions:   byName(root["Unions"]),
+		resolved: make(map[string]schema.Type),
+		pending:  make(map[string]bool),
+	}
+
+	s := &schema.Schema{Package: root["Package"].(string), Version: root["Version"].(string)}
+	for _, f := range list(root["Features"]) {
+		s.EnabledFeatures = append(s.EnabledFeatures, f.(string))
+	}
+	// Structs, then enums, then unions, each in declaration order
+	for _, kind := range []struct {
+		name string
+		defs []interface{}
+	}{{kindStruct, list(root["Structs"])}, {kindEnum, list(root["Enums"])}, {kindUnion, list(root["Unions"])}} {
+		for _, def := range kind.defs {
+			t, err := d.named(kind.name, def.(object)["Name"].(string))
+			if err != nil {
+				return nil, err
+			}
+			s.Types = append(s.Types, t)
+		}
+	}
+	for _, m := range list(root["Messages"]) {
+		msg := m.(object)
+		t, err := d.ref(msg["Type"])
+		if err != nil {
+			return nil, fmt.Errorf("message %s: %w", msg["Name"], err)
+		}
+		switch t.(type) {
+		case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		default:
+			s.Types = append(s.Types, t)
+		}
+		s.Messages = append(s.Messages, schema.MessageType{Name: msg["Name"].(string), TargetType: t})
+	}
+
+	if err := validator.ValidateSchema(s); err != nil {
+		return nil, fmt.Errorf("invalid descriptor: %w", err)
+	}
+	return s, nil
+}
+
+type decoder struct {
+	types    []interface{}
+	structs  map[string]object
+	enums    map[string]object
+	unions   map[string]object
+	resolved map[string]schema.Type // Named types by kind and name
+	pending  map[string]bool        // Named types being resolved
+}
+
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func byName(v interface{}) map[string]object {
+	defs := make(map[string]object)
+	for _, def := range list(v) {
+		defs[def.(object)["Name"].(string)] = def.(object)
+	}
+	return defs
+}
+
+// ref resolves an index into the descriptor's Types.
+func (d *decoder) ref(v interface{}) (schema.Type, error) {
+	i := int(v.(int64))
+	if i < 0 || i >= len(d.types) {
+		return nil, fmt.Errorf("invalid descriptor: type index %d out of range", i)
+	}
+	desc := d.types[i].(object)
+	kind, name := desc["Kind"].(string), desc["Name"].(string)
+	optional, large := desc["Optional"].(bool), desc["Large"].(bool)
+
+	switch kind {
+	case kindPrimitive:
+		if !schema.IsPrimitive(name) {
+			return nil, fmt.Errorf("invalid descriptor: unknown primitive type %q", name)
+		}
+		return &schema.PrimitiveType{Name: name, Optional: optional, Large: large}, nil
+	case kindArray:
+		elem, err := d.ref(desc["Elem"])
+		if err != nil {
+			return nil, err
+		}
+		return &schema.ArrayType{ElementType: elem, Length: int(desc["Length"].(int64)), Optional: optional, Large: large}, nil
+	case kindMap:
+		key, err := d.ref(desc["Key"])
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.ref(desc["Elem"])
+		if err != nil {
+			return nil, err
+		}
+		return &schema.MapType{KeyType: key, ValueType: value, Optional: optional, Large: large}, nil
+	}
+
+	t, err := d.named(kind, name)
+	if err != nil || !optional {
+		return t, err
+	}
+	// Optional uses are copies of the definition, as the parser makes them
+	switch typ := t.(type) {
+	case *schema.StructType:
+		cp := *typ
+		cp.Optional = true
+		return &cp, nil
+	case *schema.EnumType:
+		cp := *typ
+		cp.Optional = true
+		return &cp, nil
+	case *schema.UnionType:
+		cp := *typ
+		cp.Optional = true
+		return &cp, nil
+	}
+	return t, nil
+}
+
+// named resolves the definition of a struct, enum or union.
+func (d *decoder) named(kind, name string) (schema.Type, error) {
+	key := kind + " " + name
+	if t, ok := d.resolved[key]; ok {
+		return t, nil
+	}
+	if d.pending[key] {
+		return nil, fmt.Errorf("invalid descriptor: circular reference: %s", name)
+	}
+	d.pending[key] = true
+	defer delete(d.pending, key)
+
+	var t schema.Type
+	switch kind {
+	case kindStruct:
+		def, ok := d.structs[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid descriptor: struct %s is not defined", name)
+		}
+		st := &schema.StructType{Name: name, Bitmap: def["Bitmap"].(bool), Framed: def["Framed"].(bool)}
+		for _, f := range list(def["Fields"]) {
+			fd := f.(object)
+			typ, err := d.ref(fd["Type"])
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, fd["Name"], err)
+			}
+			field := schema.Field{Name: fd["Name"].(string), Type: typ, Tag: fd["Tag"].(string)}
+			field.SetJSONTag(jsonName(field.Tag))
+			st.Fields = append(st.Fields, field)
+		}
+		st.Fields = schema.SortFieldsCanonical(st.Fields)
+		t = st
+	case kindEnum:
+		def, ok := d.enums[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid descriptor: enum %s is not defined", name)
+		}
+		et := &schema.EnumType{Name: name, Base: def["Base"].(string)}
+		for _, v := range list(def["Values"]) {
+			ev := v.(object)
+			et.Values = append(et.Values, schema.EnumValue{Name: ev["Name"].(string), Value: ev["Value"].(int64)})
+		}
+		t = et
+	case kindUnion:
+		def, ok := d.unions[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid descriptor: union %s is not defined", name)
+		}
+		ut := &schema.UnionType{Name: name}
+		for _, v := range list(def["Variants"]) {
+			variant, err := d.ref(v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			ut.Variants = append(ut.Variants, variant)
+		}
+		t = ut
+	default:
+		return nil, fmt.Errorf("invalid descriptor: unknown type kind %s", kind)
+	}
+	d.resolved[key] = t
+	return t, nil
+}
+
+// jsonName returns the name in the json key of a struct tag, as the parser
+// reads it.
+func jsonName(tag string) string {
+	name := reflect.StructTag(strings.Trim(tag, "`")).Get("json")
+	name, _, _ = strings.Cut(name, ",")
+	return name
+}
+
+// Verify checks that peer, a descriptor received from another process,
+// describes messages with the same wire layout as s, so the two exchange
+// them with the same code. On a mismatch the error lists what differs.
+func Verify(s *schema.Schema, peer []byte) error {
+	ps, err := Decode(peer)
+	if err != nil {
+		return err
+	}
+	if store.SchemaFingerprint(s) == store.SchemaFingerprint(ps) {
+		return nil
+	}
+	var diffs []string
+	for _, c := range compat.Diff(s, ps) {
+		diffs = append(diffs, c.String())
+	}
+	if len(diffs) == 0 {
+		return fmt.Errorf("peer schema %s has a different wire layout", ps.Package)
+	}
+	return fmt.Errorf("peer schema %s has a different wire layout: %s", ps.Package, strings.Join(diffs, "; "))
+}
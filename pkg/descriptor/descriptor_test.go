@@ -0,0 +1,257 @@
+package descriptor
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/compat"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/store"
+)
+
+const richSchema = `package rich
+
+// @version 1.2.0
+
+type Mode int16
+
+const (
+	Off  Mode = -1
+	Mono Mode = 1
+)
+
+// @framed
+type Circle struct {
+	R float32
+}
+
+type Shape interface {
+	Circle | string
+}
+
+type Meta struct {
+	Note *string
+}
+
+// @bitmap
+type Record struct {
+	ID     int64  ` + "`json:\"id\" db:\"record_id\"`" + `
+	Name   string ` + "`json:\"name,omitempty\"`" + `
+	Blob   bytes  // @large
+	Mode   Mode
+	Prev   *Mode
+	Shape  Shape
+	Alt    *Shape
+	Grid   [4]float64
+	Rows   [][]int32
+	Index  map[string]int32
+	Tags   map[int64]*Meta
+	Meta   *Meta
+	Notes  []Meta // @large
+	Before *Circle
+	After  Circle
+}
+`
+
+const richValue = `{
+	"id": 7, "name": "r", "Blob": "aGk=", "Mode": "Off", "Prev": "Mono",
+	"Shape": {"Circle": {"R": 1.5}}, "Alt": {"string": "sq"},
+	"Grid": [1, 2, 3, 4], "Rows": [[1], [], [2, 3]],
+	"Index": {"a": 1, "b": 2}, "Tags": {"5": {"Note": "n"}, "-1": null},
+	"Meta": {}, "Notes": [{"Note": "x"}], "After": {"R": 2}
+}`
+
+func mustParse(t *testing.T, src string) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return s
+}
+
+// roundtrip encodes s as a descriptor, decodes it and checks that the
+// result describes the same schema.
+func roundtrip(t *testing.T, s *schema.Schema) *schema.Schema {
+	t.Helper()
+	data, err := Encode(s)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if a, b := store.SchemaFingerprint(s), store.SchemaFingerprint(got); a != b {
+		t.Errorf("fingerprint changed from %s to %s", a, b)
+	}
+	if changes := compat.Diff(s, got); len(changes) != 0 {
+		t.Errorf("decoded schema differs: %v", changes)
+	}
+	if got.Package != s.Package || got.Version != s.Version {
+		t.Errorf("package %s %s, want %s %s", got.Package, got.Version, s.Package, s.Version)
+	}
+	again, err := Encode(got)
+	if err != nil {
+		t.Fatalf("Encode of decoded schema failed: %v", err)
+	}
+	if string(again) != string(data) {
+		t.Error("re-encoding the decoded schema changes the descriptor")
+	}
+	return got
+}
+
+func TestRoundtrip(t *testing.T) {
+	s := mustParse(t, richSchema)
+	s.Canonicalize()
+	got := roundtrip(t, s)
+
+	// A payload decodes the same with the schema and its descriptor
+	data, err := fixture.Convert(s, "Record", []byte(richValue))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want, err := fixture.Decode(s, "Record", data)
+	if err != nil {
+		t.Fatalf("Decode with schema failed: %v", err)
+	}
+	value, err := fixture.Decode(got, "Record", data)
+	if err != nil {
+		t.Fatalf("Decode with descriptor failed: %v", err)
+	}
+	if !reflect.DeepEqual(value, want) {
+		t.Errorf("decoded with descriptor:\n%v\nwant:\n%v", value, want)
+	}
+
+	record := got.Messages[0].TargetType.(*schema.StructType)
+	if record != got.Types[2] || !record.Bitmap {
+		t.Errorf("Record = %+v, want the @bitmap struct in Types", record)
+	}
+	if circle, ok := got.FindType("Circle").(*schema.StructType); !ok || !circle.Framed {
+		t.Errorf("Circle = %+v, want the @framed struct", circle)
+	}
+	for _, field := range record.Fields {
+		if field.Name == "ID" && (field.JSONName() != "id" || field.Tag != "`json:\"id\" db:\"record_id\"`") {
+			t.Errorf("ID = %+v, want its tag", field)
+		}
+	}
+}
+
+func TestRoundtripTestdata(t *testing.T) {
+	schemas, _ := filepath.Glob("../../testdata/schema/*.ffi")
+	if len(schemas) == 0 {
+		t.Fatal("no testdata schemas found")
+	}
+	for _, path := range append(schemas, "descriptor.ffi") {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			s, err := parser.Parse(path)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			roundtrip(t, s)
+		})
+	}
+}
+
+func TestEncodeFeatures(t *testing.T) {
+	s := mustParse(t, "package f\n\ntype M struct {\n\tA int32\n\tB int32 // @feature(\"v2\")\n}\n")
+	s.ApplyFeatures([]string{"v2"})
+	got := roundtrip(t, s)
+	if !reflect.DeepEqual(got.EnabledFeatures, []string{"v2"}) {
+		t.Errorf("EnabledFeatures = %v, want [v2]", got.EnabledFeatures)
+	}
+}
+
+func TestDescriptorSchema(t *testing.T) {
+	data, err := os.ReadFile("descriptor.ffi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(Source) {
+		t.Error("Source differs from descriptor.ffi")
+	}
+	s := Schema()
+	if len(s.Messages) != 1 || s.Messages[0].Name != MessageName {
+		t.Errorf("Messages = %v, want only %s", s.Messages, MessageName)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid, err := Encode(mustParse(t, richSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(valid[:len(valid)-1]); err == nil {
+		t.Error("truncated descriptor decoded")
+	}
+
+	desc := Schema()
+	encode := func(value string) []byte {
+		data, err := fixture.Convert(desc, MessageName, []byte(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	prim := `{"Kind": "KindPrimitive", "Name": "int32", "Optional": false, "Large": false, "Length": 0, "Elem": 0, "Key": 0}`
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"index out of range", `{"Package": "p", "Version": "", "Features": [], "Messages": [{"Name": "M", "Type": 3}],
+			"Types": [], "Structs": [], "Enums": [], "Unions": []}`, "type index 3 out of range"},
+		{"undefined struct", `{"Package": "p", "Version": "", "Features": [], "Messages": [{"Name": "M", "Type": 0}],
+			"Types": [{"Kind": "KindStruct", "Name": "M", "Optional": false, "Large": false, "Length": 0, "Elem": 0, "Key": 0}],
+			"Structs": [], "Enums": [], "Unions": []}`, "struct M is not defined"},
+		{"unknown primitive", `{"Package": "p", "Version": "", "Features": [], "Messages": [{"Name": "M", "Type": 0}],
+			"Types": [{"Kind": "KindArray", "Name": "", "Optional": false, "Large": false, "Length": 0, "Elem": 1, "Key": 0},
+				{"Kind": "KindPrimitive", "Name": "uint8", "Optional": false, "Large": false, "Length": 0, "Elem": 0, "Key": 0}],
+			"Structs": [], "Enums": [], "Unions": []}`, `unknown primitive type "uint8"`},
+		{"circular", `{"Package": "p", "Version": "", "Features": [], "Messages": [{"Name": "M", "Type": 0}],
+			"Types": [{"Kind": "KindStruct", "Name": "M", "Optional": false, "Large": false, "Length": 0, "Elem": 0, "Key": 0}],
+			"Structs": [{"Name": "M", "Bitmap": false, "Framed": false, "Fields": [{"Name": "Self", "Tag": "", "Type": 0}]}],
+			"Enums": [], "Unions": []}`, "circular reference"},
+		{"map root", `{"Package": "p", "Version": "", "Features": [], "Messages": [{"Name": "M", "Type": 0}],
+			"Types": [{"Kind": "KindMap", "Name": "", "Optional": false, "Large": false, "Length": 0, "Elem": 1, "Key": 1}, ` + prim + `],
+			"Structs": [], "Enums": [], "Unions": []}`, "root type cannot be a map"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(encode(tt.value))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode() = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	s := mustParse(t, richSchema)
+	peer, err := Encode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(mustParse(t, richSchema), peer); err != nil {
+		t.Errorf("Verify(same schema) = %v", err)
+	}
+
+	// Tags and field order are not part of the wire layout
+	same := strings.Replace(richSchema, "`json:\"id\" db:\"record_id\"`", "", 1)
+	same = strings.Replace(same, "\tAfter  Circle\n", "", 1)
+	same = strings.Replace(same, "\tID     int64", "\tAfter  Circle\n\tID     int64", 1)
+	if err := Verify(mustParse(t, same), peer); err != nil {
+		t.Errorf("Verify(reordered schema) = %v", err)
+	}
+
+	other := strings.Replace(richSchema, "R float32", "R float64", 1)
+	err = Verify(mustParse(t, other), peer)
+	if err == nil || !strings.Contains(err.Error(), "Circle.R") {
+		t.Errorf("Verify(changed schema) = %v, want an error naming Circle.R", err)
+	}
+}
@@ -0,0 +1,312 @@
+// Package docgen renders browsable documentation of an ffire schema for
+// teams that consume the format: every message and named type with its
+// fields in wire order, wire sizes from the analyzer and an example
+// payload of each message, as Markdown or as a self-contained HTML page.
+//
+// The schema should be canonicalized first, so fields are listed in the
+// order they appear on the wire.
+package docgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/analyzer"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// exampleBytes caps the hex dump of an example payload; the rest is only
+// counted.
+const exampleBytes = 512
+
+// page is the documentation of a schema, shared by both renderers.
+type page struct {
+	Package  string
+	Version  string
+	Messages []message
+	Types    []typeDoc
+}
+
+type message struct {
+	Name    string
+	Anchor  string
+	Doc     string
+	Type    string // Root type, as written in the schema
+	Link    string // Anchor of the named type the root refers to, or ""
+	Size    string
+	JSON    string // Example payload as indented JSON
+	Hex     string // Hex dump of the example payload, with offsets
+	Bytes   int    // Size of the example payload
+	Shown   int    // Bytes of the payload in Hex
+	Omitted int    // Bytes of the payload left out of Hex
+}
+
+type typeDoc struct {
+	Kind     string // "struct", "enum" or "union"
+	Name     string
+	Anchor   string
+	Doc      string
+	Size     string
+	Frame    string // Length prefix of a @framed struct, or ""
+	Presence string // Presence flags of a struct's optional fields, or ""
+	Fields   []field
+	Values   []schema.EnumValue
+	Variants []variant
+}
+
+type field struct {
+	Name string
+	JSON string
+	Type string
+	Link string
+	Size string
+	Doc  string
+}
+
+type variant struct {
+	Tag  int
+	Type string
+	Link string
+}
+
+// builder collects the documentation of a schema.
+type builder struct {
+	s     *schema.Schema
+	infos map[string]*analyzer.TypeInfo
+}
+
+// build collects the documentation of s.
+func build(s *schema.Schema) (*page, error) {
+	b := &builder{s: s, infos: analyzer.Analyze(s)}
+	p := &page{Package: s.Package, Version: s.Version}
+
+	for _, msg := range s.Messages {
+		m := message{
+			Name:   msg.Name,
+			Anchor: "message-" + anchorName(msg.Name),
+			Doc:    msg.Doc,
+			Type:   typeString(msg.TargetType),
+			Link:   link(msg.TargetType),
+			Size:   b.rootSize(msg.TargetType),
+		}
+		if err := b.example(&m); err != nil {
+			return nil, err
+		}
+		p.Messages = append(p.Messages, m)
+	}
+
+	for _, typ := range s.Types {
+		switch t := typ.(type) {
+		case *schema.StructType:
+			p.Types = append(p.Types, b.structDoc(t))
+		case *schema.EnumType:
+			p.Types = append(p.Types, typeDoc{
+				Kind:   "enum",
+				Name:   t.Name,
+				Anchor: link(t),
+				Doc:    t.Doc,
+				Size:   fmt.Sprintf("fixed, %d B (%s)", schema.PrimitiveSize(t.Base), t.Base),
+				Values: t.Values,
+			})
+		case *schema.UnionType:
+			d := typeDoc{
+				Kind:   "union",
+				Name:   t.Name,
+				Anchor: link(t),
+				Doc:    t.Doc,
+				Size:   "variable, 1 B tag + variant",
+			}
+			for i, v := range t.Variants {
+				d.Variants = append(d.Variants, variant{Tag: i + 1, Type: typeString(v), Link: link(v)})
+			}
+			p.Types = append(p.Types, d)
+		}
+	}
+	return p, nil
+}
+
+func (b *builder) structDoc(st *schema.StructType) typeDoc {
+	d := typeDoc{
+		Kind:   "struct",
+		Name:   st.Name,
+		Anchor: link(st),
+		Doc:    st.Doc,
+	}
+	if ti := b.infos[st.Name]; ti != nil {
+		d.Size = sizeString(ti)
+		d.Presence = presenceString(st, ti)
+	}
+	if st.Framed {
+		d.Frame = fmt.Sprintf("%d B length of the rest of the struct, before its fields", schema.FrameSize)
+	}
+	for _, f := range st.Fields {
+		size := b.wireSize(f.Type)
+		if st.Bitmap && f.Type.IsOptional() {
+			size = "bitmap bit + " + b.contentSize(f.Type)
+		}
+		d.Fields = append(d.Fields, field{
+			Name: f.Name,
+			JSON: f.JSONName(),
+			Type: typeString(f.Type),
+			Link: link(f.Type),
+			Size: size,
+			Doc:  f.Doc,
+		})
+	}
+	return d
+}
+
+// example fills in the example payload of m: a sample value with every
+// field populated, as JSON and as encoded bytes.
+func (b *builder) example(m *message) error {
+	value, err := fixture.Sample(b.s, m.Name)
+	if err != nil {
+		return err
+	}
+	js, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: example: %w", m.Name, err)
+	}
+	data, err := fixture.Encode(b.s, m.Name, value)
+	if err != nil {
+		return fmt.Errorf("%s: example: %w", m.Name, err)
+	}
+	m.JSON = string(js)
+	m.Bytes = len(data)
+	if len(data) > exampleBytes {
+		m.Omitted = len(data) - exampleBytes
+		data = data[:exampleBytes]
+	}
+	m.Shown = len(data)
+	m.Hex = hexDump(data)
+	return nil
+}
+
+// hexDump formats data as rows of 16 bytes, each led by its offset.
+func hexDump(data []byte) string {
+	var b strings.Builder
+	for off := 0; off < len(data); off += 16 {
+		if off > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%04x ", off)
+		for _, c := range data[off:min(off+16, len(data))] {
+			fmt.Fprintf(&b, " %02x", c)
+		}
+	}
+	return b.String()
+}
+
+func typeString(t schema.Type) string {
+	prefix := ""
+	if t.IsOptional() {
+		prefix = "*"
+	}
+	switch tt := t.(type) {
+	case *schema.ArrayType:
+		if tt.Length > 0 {
+			return prefix + fmt.Sprintf("[%d]", tt.Length) + typeString(tt.ElementType)
+		}
+		return prefix + "[]" + typeString(tt.ElementType)
+	case *schema.MapType:
+		return prefix + "map[" + typeString(tt.KeyType) + "]" + typeString(tt.ValueType)
+	}
+	return prefix + t.TypeName()
+}
+
+// link returns the anchor of the named type t refers to, through arrays
+// and map values, or "" if it refers to none.
+func link(t schema.Type) string {
+	switch tt := t.(type) {
+	case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		return "type-" + anchorName(tt.TypeName())
+	case *schema.ArrayType:
+		return link(tt.ElementType)
+	case *schema.MapType:
+		return link(tt.ValueType)
+	}
+	return ""
+}
+
+func anchorName(name string) string {
+	return strings.ToLower(name)
+}
+
+func sizeString(ti *analyzer.TypeInfo) string {
+	switch {
+	case ti.IsFixedSize:
+		return fmt.Sprintf("fixed, %d B", ti.FixedSize)
+	case ti.MaxSize < 0:
+		return "variable, unbounded (recursive)"
+	default:
+		return fmt.Sprintf("variable, at most %d B", ti.MaxSize)
+	}
+}
+
+// presenceString describes the presence flags of the optional fields of
+// st, or returns "" if it has none.
+func presenceString(st *schema.StructType, ti *analyzer.TypeInfo) string {
+	fields := fmt.Sprintf("%d optional fields", ti.OptionalFields)
+	if ti.OptionalFields == 1 {
+		fields = "1 optional field"
+	}
+	switch {
+	case ti.OptionalFields == 0:
+		return ""
+	case st.Bitmap:
+		return fmt.Sprintf("%s, %d B presence bitmap", fields, ti.PresenceBytes)
+	}
+	return fields + ", one presence byte each"
+}
+
+// rootSize describes the wire size of a message with root type t.
+func (b *builder) rootSize(t schema.Type) string {
+	if st, ok := t.(*schema.StructType); ok && !st.Optional {
+		if ti := b.infos[st.Name]; ti != nil {
+			return sizeString(ti)
+		}
+	}
+	return b.wireSize(t)
+}
+
+// wireSize describes how a value of type t is laid out on the wire.
+func (b *builder) wireSize(t schema.Type) string {
+	if t.IsOptional() {
+		return "1 B presence + " + b.contentSize(t)
+	}
+	return b.contentSize(t)
+}
+
+// contentSize describes the layout of a value of type t after its
+// presence byte, if any.
+func (b *builder) contentSize(t schema.Type) string {
+	switch tt := t.(type) {
+	case *schema.PrimitiveType:
+		switch tt.Name {
+		case "string":
+			return fmt.Sprintf("%d B length + UTF-8", schema.LengthPrefixSize(tt))
+		case "bytes":
+			return fmt.Sprintf("%d B length + data", schema.LengthPrefixSize(tt))
+		}
+		return fmt.Sprintf("%d B", schema.PrimitiveSize(tt.Name))
+	case *schema.EnumType:
+		return fmt.Sprintf("%d B", schema.PrimitiveSize(tt.Base))
+	case *schema.StructType:
+		if ti := b.infos[tt.Name]; ti != nil && ti.IsFixedSize {
+			return fmt.Sprintf("%d B", ti.FixedSize)
+		}
+		return "variable"
+	case *schema.ArrayType:
+		if tt.Length > 0 {
+			return fmt.Sprintf("%d × %s", tt.Length, b.wireSize(tt.ElementType))
+		}
+		return fmt.Sprintf("%d B count + elements", schema.LengthPrefixSize(tt))
+	case *schema.MapType:
+		return fmt.Sprintf("%d B count + entries", schema.LengthPrefixSize(tt))
+	case *schema.UnionType:
+		return "1 B tag + variant"
+	}
+	return "variable"
+}
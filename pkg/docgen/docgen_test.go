@@ -0,0 +1,133 @@
+package docgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const librarySchema = `// @version("1.2.0")
+package audio
+
+// Library is the device catalog of a studio.
+type Library struct {
+	Name    string ` + "`json:\"name\"`" + `
+	Devices []Device
+	Owner   *User
+	Kind    Kind
+	Payload Payload
+}
+
+// Device is one audio interface.
+// @framed
+type Device struct {
+	DeviceID int64
+	// Gain in dB, absent when the device has no gain stage
+	Gain *float32
+}
+
+// @bitmap
+type User struct {
+	ID    int32
+	Email *string
+	Phone *string
+}
+
+type Kind int8
+
+const (
+	// A studio owned by one person
+	KindPersonal Kind = 1
+	KindShared   Kind = 2
+)
+
+type Payload interface {
+	Device | string
+}
+`
+
+func parseLibrary(t *testing.T) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(librarySchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	s.Canonicalize()
+	return s
+}
+
+func TestMarkdown(t *testing.T) {
+	out, err := Markdown(parseLibrary(t))
+	if err != nil {
+		t.Fatalf("Markdown failed: %v", err)
+	}
+	md := string(out)
+
+	for _, want := range []string{
+		"# Package audio v1.2.0",
+		`<a id="message-library"></a>`,
+		"Library is the device catalog of a studio.",
+		"- **Root type:** [`Library`](#type-library)",
+		`"name": "sample-`,
+		"0000  ",
+		`<a id="type-device"></a>`,
+		"### Device (struct)",
+		"- **Wire size:** variable, at most 17 B",
+		"- **Frame:** 4 B length of the rest of the struct, before its fields",
+		"- **Presence:** 1 optional field, one presence byte each",
+		"| DeviceID | `DeviceID` | `int64` | 8 B |  |",
+		"| Gain | `Gain` | `*float32` | 1 B presence + 4 B | Gain in dB, absent when the device has no gain stage |",
+		"| Devices | `Devices` | [`[]Device`](#type-device) | 2 B count + elements |  |",
+		"| Name | `name` | `string` | 2 B length + UTF-8 |  |",
+		"- **Presence:** 2 optional fields, 1 B presence bitmap",
+		"| Email | `Email` | `*string` | bitmap bit + 2 B length + UTF-8 |  |",
+		"### Kind (enum)",
+		"- **Wire size:** fixed, 1 B (int8)",
+		"| 1 | KindPersonal | A studio owned by one person |",
+		"### Payload (union)",
+		"| 1 | [`Device`](#type-device) |",
+		"| 2 | `string` |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("missing %q in:\n%s", want, md)
+		}
+	}
+
+	// Fields are listed in wire order: fixed-size first
+	if strings.Index(md, "| Kind | ") > strings.Index(md, "| Name | ") {
+		t.Errorf("fields not in wire order:\n%s", md)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	out, err := HTML(parseLibrary(t))
+	if err != nil {
+		t.Fatalf("HTML failed: %v", err)
+	}
+	html := string(out)
+
+	for _, want := range []string{
+		"<title>audio v1.2.0 wire format</title>",
+		`<li><a href="#message-library">Library</a></li>`,
+		`<section id="type-user">`,
+		`<h3>Payload <span class="kind">union</span></h3>`,
+		`<td><a href="#type-device"><code>[]Device</code></a></td>`,
+		"<td>bitmap bit &#43; 2 B length &#43; UTF-8</td>",
+		"&#34;name&#34;: &#34;sample-",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %q in:\n%s", want, html)
+		}
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	data := make([]byte, 18)
+	data[0], data[17] = 0x01, 0xff
+	want := "0000  01 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00\n0010  00 ff"
+	if got := hexDump(data); got != want {
+		t.Errorf("hexDump = %q, want %q", got, want)
+	}
+}
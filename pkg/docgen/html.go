@@ -0,0 +1,126 @@
+package docgen
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// HTML renders the documentation of s as a single HTML page with inline
+// styles, so it can be served or opened without other files.
+func HTML(s *schema.Schema) ([]byte, error) {
+	p, err := build(s)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var htmlTemplate = template.Must(template.New("html").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Package}}{{if .Version}} v{{.Version}}{{end}} wire format</title>
+<style>
+body { margin: 0; display: flex; font: 15px/1.5 system-ui, sans-serif; color: #222; }
+nav { position: sticky; top: 0; height: 100vh; overflow-y: auto; min-width: 14em; padding: 1em 1.5em; background: #f5f5f5; box-sizing: border-box; }
+nav ul { list-style: none; padding: 0; margin: 0 0 1em; }
+nav h3 { margin: 0.5em 0; font-size: 0.85em; text-transform: uppercase; color: #666; }
+main { padding: 1em 2em; max-width: 60em; }
+section { margin-bottom: 2.5em; }
+a { color: #0550ae; text-decoration: none; }
+a:hover { text-decoration: underline; }
+code, pre { font: 13px/1.4 ui-monospace, monospace; }
+pre { background: #f5f5f5; padding: 0.75em; overflow-x: auto; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+.kind { font-size: 0.8em; font-weight: normal; color: #666; }
+.facts { color: #444; }
+</style>
+</head>
+<body>
+<nav>
+<h2>{{.Package}}</h2>
+{{- if .Messages}}
+<h3>Messages</h3>
+<ul>
+{{- range .Messages}}
+<li><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Types}}
+<h3>Types</h3>
+<ul>
+{{- range .Types}}
+<li><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+</nav>
+<main>
+<h1>Package {{.Package}}{{if .Version}} <span class="kind">v{{.Version}}</span>{{end}}</h1>
+<p>Wire format documentation generated by ffire. Fields are listed in wire order.</p>
+{{- if .Messages}}
+<h2>Messages</h2>
+{{- range .Messages}}
+<section id="{{.Anchor}}">
+<h3>{{.Name}} <span class="kind">message</span></h3>
+{{- if .Doc}}
+<p>{{.Doc}}</p>
+{{- end}}
+<p class="facts">Root type: {{template "type" .}}<br>Wire size: {{.Size}}</p>
+<h4>Example payload</h4>
+<pre>{{.JSON}}</pre>
+<h4>Encoded ({{.Bytes}} bytes{{if .Omitted}}, first {{.Shown}} shown{{end}})</h4>
+<pre>{{.Hex}}{{if .Omitted}}
+... {{.Omitted}} more bytes{{end}}</pre>
+</section>
+{{- end}}
+{{- end}}
+{{- if .Types}}
+<h2>Types</h2>
+{{- range .Types}}
+<section id="{{.Anchor}}">
+<h3>{{.Name}} <span class="kind">{{.Kind}}</span></h3>
+{{- if .Doc}}
+<p>{{.Doc}}</p>
+{{- end}}
+<p class="facts">Wire size: {{.Size}}{{if .Frame}}<br>Frame: {{.Frame}}{{end}}{{if .Presence}}<br>Presence: {{.Presence}}{{end}}</p>
+{{- if eq .Kind "struct"}}
+{{- if .Fields}}
+<table>
+<tr><th>Field</th><th>JSON</th><th>Type</th><th>Wire size</th><th>Description</th></tr>
+{{- range .Fields}}
+<tr><td>{{.Name}}</td><td><code>{{.JSON}}</code></td><td>{{template "type" .}}</td><td>{{.Size}}</td><td>{{.Doc}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- else if eq .Kind "enum"}}
+<table>
+<tr><th>Value</th><th>Name</th><th>Description</th></tr>
+{{- range .Values}}
+<tr><td>{{.Value}}</td><td><code>{{.Name}}</code></td><td>{{.Doc}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<table>
+<tr><th>Tag</th><th>Variant</th></tr>
+{{- range .Variants}}
+<tr><td>{{.Tag}}</td><td>{{template "type" .}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</section>
+{{- end}}
+{{- end}}
+</main>
+</body>
+</html>
+{{define "type"}}{{if .Link}}<a href="#{{.Link}}"><code>{{.Type}}</code></a>{{else}}<code>{{.Type}}</code>{{end}}{{end}}`))
@@ -0,0 +1,96 @@
+package docgen
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Markdown renders the documentation of s as a Markdown document, with
+// links between the types it lists.
+func Markdown(s *schema.Schema) ([]byte, error) {
+	p, err := build(s)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := markdownTemplate.Execute(&buf, p); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(template.FuncMap{
+	"cell": markdownCell,
+	"code": markdownCode,
+}).Parse(`# Package {{.Package}}{{if .Version}} v{{.Version}}{{end}}
+
+Wire format documentation generated by ffire. Fields are listed in wire order.
+{{if .Messages}}
+## Messages
+{{end}}{{range .Messages}}
+<a id="{{.Anchor}}"></a>
+### {{.Name}}
+{{if .Doc}}
+{{.Doc}}
+{{end}}
+- **Root type:** {{code .Type .Link}}
+- **Wire size:** {{.Size}}
+
+Example payload:
+
+` + "```json" + `
+{{.JSON}}
+` + "```" + `
+
+Encoded ({{.Bytes}} bytes{{if .Omitted}}, first {{.Shown}} shown{{end}}):
+
+` + "```" + `
+{{.Hex}}{{if .Omitted}}
+... {{.Omitted}} more bytes{{end}}
+` + "```" + `
+{{end}}{{if .Types}}
+## Types
+{{end}}{{range .Types}}
+<a id="{{.Anchor}}"></a>
+### {{.Name}} ({{.Kind}})
+{{if .Doc}}
+{{.Doc}}
+{{end}}
+- **Wire size:** {{.Size}}
+{{- if .Frame}}
+- **Frame:** {{.Frame}}
+{{- end}}
+{{- if .Presence}}
+- **Presence:** {{.Presence}}
+{{- end}}
+{{if eq .Kind "struct"}}{{if .Fields}}
+| Field | JSON | Type | Wire size | Description |
+|-------|------|------|-----------|-------------|
+{{range .Fields}}| {{.Name}} | ` + "`{{.JSON}}`" + ` | {{code .Type .Link}} | {{.Size}} | {{cell .Doc}} |
+{{end}}{{end}}{{else if eq .Kind "enum"}}
+| Value | Name | Description |
+|-------|------|-------------|
+{{range .Values}}| {{.Value}} | {{.Name}} | {{cell .Doc}} |
+{{end}}{{else}}
+| Tag | Variant |
+|-----|---------|
+{{range .Variants}}| {{.Tag}} | {{code .Type .Link}} |
+{{end}}{{end}}{{end}}`))
+
+// markdownCell makes text fit a table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// markdownCode renders a type as code, linked to anchor if set.
+func markdownCode(typ, anchor string) string {
+	code := "`" + typ + "`"
+	if anchor == "" {
+		return code
+	}
+	return "[" + code + "](#" + anchor + ")"
+}
@@ -0,0 +1,170 @@
+// Package dynamic encodes and decodes messages of a parsed schema without
+// generated code, so tools can read payloads whose schema they only learn
+// at runtime, e.g. from a descriptor.
+//
+// Values are the trees fixture.Decode produces: structs are
+// map[string]any keyed by JSON field name, arrays []any, maps
+// map[string]any keyed by the JSON spelling of each key, unions an object
+// with one key naming the variant, enums the name of their constant,
+// bytes standard base64 and absent optionals nil. Decoded integers are
+// int64 and floats float64; Encode also accepts int and json.Number, so
+// values unmarshaled by encoding/json encode as well.
+package dynamic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Message is a message type of a schema.
+type Message struct {
+	schema *schema.Schema
+	msg    *schema.MessageType
+}
+
+// Lookup returns the message type name of s.
+func Lookup(s *schema.Schema, name string) (*Message, error) {
+	for i := range s.Messages {
+		if s.Messages[i].Name == name {
+			return &Message{schema: s, msg: &s.Messages[i]}, nil
+		}
+	}
+	return nil, fmt.Errorf("message type %s not found in schema", name)
+}
+
+// Name returns the name of the message type.
+func (m *Message) Name() string { return m.msg.Name }
+
+// Type returns the root type of the message.
+func (m *Message) Type() schema.Type { return m.msg.TargetType }
+
+// Decode decodes a payload of m. Errors in the payload are
+// *fixture.DecodeError values, which locate the failure.
+func (m *Message) Decode(data []byte) (any, error) {
+	return fixture.Decode(m.schema, m.msg.Name, data)
+}
+
+// Encode encodes value as a payload of m.
+func (m *Message) Encode(value any) ([]byte, error) {
+	return fixture.Encode(m.schema, m.msg.Name, value)
+}
+
+// Get returns the part of value, a value tree of m, at path: field names
+// joined by dots, [i] for array elements, ["key"] for map values (integer
+// and bool keys may be unquoted) and the variant name for union values,
+// e.g. `Devices[2].Name` or `Labels["en"]`. The paths in DecodeError have
+// the same form. Get returns nil if the path passes through an absent
+// optional or a union variant the value does not hold, and the whole
+// value for an empty path.
+func (m *Message) Get(value any, path string) (any, error) {
+	typ := m.msg.TargetType
+	done := ""
+	for rest := path; rest != ""; {
+		if value == nil {
+			return nil, nil
+		}
+
+		if rest[0] == '[' {
+			key, n, err := parseIndex(rest)
+			if err != nil {
+				return nil, fmt.Errorf("path %q: %w", path, err)
+			}
+			done, rest = done+rest[:n], rest[n:]
+
+			switch t := typ.(type) {
+			case *schema.ArrayType:
+				arr, ok := value.([]any)
+				if !ok {
+					return nil, fmt.Errorf("%s: expected array, got %T", done, value)
+				}
+				i, err := strconv.Atoi(key)
+				if err != nil || i < 0 || i >= len(arr) {
+					return nil, fmt.Errorf("%s: index out of range (length %d)", done, len(arr))
+				}
+				value, typ = arr[i], t.ElementType
+			case *schema.MapType:
+				obj, ok := value.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("%s: expected map, got %T", done, value)
+				}
+				v, ok := obj[key]
+				if !ok {
+					return nil, fmt.Errorf("%s: no such key", done)
+				}
+				value, typ = v, t.ValueType
+			default:
+				return nil, fmt.Errorf("%s: %s is not an array or map", done, typ.TypeName())
+			}
+			continue
+		}
+
+		if done != "" {
+			if rest[0] != '.' {
+				return nil, fmt.Errorf("path %q: expected . or [ after %s", path, done)
+			}
+			done, rest = done+".", rest[1:]
+		}
+		n := strings.IndexAny(rest, ".[")
+		if n < 0 {
+			n = len(rest)
+		}
+		name := rest[:n]
+		if name == "" {
+			return nil, fmt.Errorf("path %q: missing name after %q", path, done)
+		}
+		done, rest = done+name, rest[n:]
+
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected object, got %T", done, value)
+		}
+		switch t := typ.(type) {
+		case *schema.StructType:
+			field := findField(t, name)
+			if field == nil {
+				return nil, fmt.Errorf("%s: %s has no field %s", done, t.Name, name)
+			}
+			value, typ = obj[field.JSONName()], field.Type
+		case *schema.UnionType:
+			variant, _, ok := t.Variant(name)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s has no variant %s", done, t.Name, name)
+			}
+			value, typ = obj[name], variant
+		default:
+			return nil, fmt.Errorf("%s: %s has no fields", done, typ.TypeName())
+		}
+	}
+	return value, nil
+}
+
+// parseIndex parses the bracketed index at the start of s and returns it
+// unquoted, along with its length including the brackets.
+func parseIndex(s string) (string, int, error) {
+	if strings.HasPrefix(s, `["`) {
+		quoted, err := strconv.QuotedPrefix(s[1:])
+		if err != nil || !strings.HasPrefix(s[1+len(quoted):], "]") {
+			return "", 0, fmt.Errorf("unterminated key in %s", s)
+		}
+		key, _ := strconv.Unquote(quoted)
+		return key, len(quoted) + 2, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated index in %s", s)
+	}
+	return s[1:end], end + 1, nil
+}
+
+func findField(st *schema.StructType, name string) *schema.Field {
+	for i := range st.Fields {
+		if st.Fields[i].Name == name {
+			return &st.Fields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package dynamic
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const librarySchema = `package audio
+
+type Library struct {
+	Name    string ` + "`json:\"name\"`" + `
+	Devices []Device
+	Labels  map[string]string
+	Owner   *User
+	Slot    Slot
+}
+
+type Device struct {
+	ID    int32
+	Gains map[int16]float32
+}
+
+type User struct {
+	Email string
+}
+
+type Slot interface {
+	Device | string
+}
+`
+
+func parseLibrary(t *testing.T) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(librarySchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	s.Canonicalize()
+	return s
+}
+
+const libraryJSON = `{
+	"name": "studio",
+	"Devices": [
+		{"ID": 1, "Gains": {"3": 0.5}},
+		{"ID": 2, "Gains": {}}
+	],
+	"Labels": {"en": "Studio", "a\"b": "quoted"},
+	"Owner": null,
+	"Slot": {"Device": {"ID": 7, "Gains": {}}}
+}`
+
+func TestRoundtrip(t *testing.T) {
+	s := parseLibrary(t)
+	m, err := Lookup(s, "Library")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if m.Name() != "Library" || m.Type().TypeName() != "Library" {
+		t.Errorf("Name, Type = %s, %s, want Library, Library", m.Name(), m.Type().TypeName())
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(libraryJSON), &value); err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.Encode(value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	want, err := fixture.Convert(s, "Library", []byte(libraryJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("Encode = % x, want % x", data, want)
+	}
+
+	decoded, err := m.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	again, err := m.Encode(decoded)
+	if err != nil {
+		t.Fatalf("Encode of decoded value failed: %v", err)
+	}
+	if !reflect.DeepEqual(again, data) {
+		t.Errorf("re-encoded = % x, want % x", again, data)
+	}
+
+	var decErr *fixture.DecodeError
+	if _, err := m.Decode(data[:len(data)-1]); !errors.As(err, &decErr) {
+		t.Errorf("Decode of truncated payload = %v, want *fixture.DecodeError", err)
+	}
+}
+
+func TestLookupMissing(t *testing.T) {
+	if _, err := Lookup(parseLibrary(t), "Nope"); err == nil || !strings.Contains(err.Error(), "Nope") {
+		t.Errorf("Lookup error = %v, want message type Nope not found", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := parseLibrary(t)
+	m, err := Lookup(s, "Library")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := fixture.Convert(s, "Library", []byte(libraryJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := m.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want any
+	}{
+		{"Name", "studio"},
+		{"Devices[1].ID", int64(2)},
+		{"Devices[0].Gains[3]", float64(0.5)},
+		{`Devices[0].Gains["3"]`, float64(0.5)},
+		{`Labels["en"]`, "Studio"},
+		{`Labels["a\"b"]`, "quoted"},
+		{"Owner.Email", nil},
+		{"Slot.Device.ID", int64(7)},
+		{"Slot.string", nil},
+	}
+	for _, tt := range tests {
+		got, err := m.Get(value, tt.path)
+		if err != nil {
+			t.Errorf("Get(%s) failed: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%s) = %#v, want %#v", tt.path, got, tt.want)
+		}
+	}
+
+	if got, err := m.Get(value, ""); err != nil || !reflect.DeepEqual(got, value) {
+		t.Errorf("Get(\"\") = %v, %v, want the whole value", got, err)
+	}
+
+	for _, tt := range []struct{ path, err string }{
+		{"Devices[2]", "Devices[2]: index out of range (length 2)"},
+		{"Devices[x]", "Devices[x]: index out of range"},
+		{`Labels["fr"]`, `Labels["fr"]: no such key`},
+		{"Nope", "Nope: Library has no field Nope"},
+		{"Slot.int32", "Slot.int32: Slot has no variant int32"},
+		{"Name.Length", "Name.Length: expected object"},
+		{"Name[0]", "Name[0]: string is not an array or map"},
+		{"Devices[0", "unterminated index"},
+		{`Labels["en]`, "unterminated key"},
+		{"Devices.ID", "Devices.ID: expected object"},
+		{"Devices[0]ID", "expected . or [ after Devices[0]"},
+		{"Devices[0].", "missing name"},
+	} {
+		if _, err := m.Get(value, tt.path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Get(%s) error = %v, want %q", tt.path, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package editor
+
+import (
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/analyzer"
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/errors"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+// Diagnostic severities, as in LSP.
+const (
+	SeverityError = "error"
+	SeverityHint  = "hint"
+)
+
+// Diagnostic is a problem to show at a range of the source.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"` // The errors package code, e.g. "E005"
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+}
+
+// Diagnose checks a schema the way ffire validate does, with every feature
+// compiled in, and reports what it finds: a syntax error, or the first
+// error building or validating the schema, or hints when it is valid:
+// ignored imports and structs that @bitmap would shrink. It never fails; a
+// valid schema without hints has no diagnostics.
+//
+// Syntax errors carry their exact position. Later errors are placed at the
+// position in their message, or else at the declaration they name; errors
+// naming nothing in the file are placed at the package clause.
+func Diagnose(src []byte) []Diagnostic {
+	d := newDocument(src)
+	file, err := ast.Parse(src)
+	if err != nil {
+		var syntax *ast.Error
+		if stderrors.As(err, &syntax) {
+			return []Diagnostic{{Range: d.word(syntax.Pos.Offset), Severity: SeverityError, Message: syntax.Msg}}
+		}
+		return []Diagnostic{{Range: d.word(0), Severity: SeverityError, Message: err.Error()}}
+	}
+
+	s, err := parser.FromAST(file)
+	if err == nil {
+		err = validator.ValidateSchema(s)
+	}
+	if err != nil {
+		return []Diagnostic{locate(d, file, err)}
+	}
+
+	var diags []Diagnostic
+	for _, decl := range file.Decls {
+		if imports, ok := decl.(*ast.ImportDecl); ok {
+			for _, spec := range imports.Specs {
+				diags = append(diags, Diagnostic{
+					Range:    d.span(spec),
+					Severity: SeverityHint,
+					Message:  fmt.Sprintf("import %s is ignored; schemas cannot use types from other packages", spec.Path.Value),
+				})
+			}
+		}
+	}
+	names := declarations(file)
+	for _, t := range s.Types {
+		st, ok := t.(*schema.StructType)
+		if !ok || st.Bitmap {
+			continue
+		}
+		name, ok := names[st.Name]
+		if !ok {
+			continue // Lifted or instantiated, not declared by name
+		}
+		if hint := analyzer.BitmapHint(st.Name, schema.OptionalFields(st)); hint != "" {
+			diags = append(diags, Diagnostic{
+				Range:    d.span(name),
+				Severity: SeverityHint,
+				Message:  hint,
+			})
+		}
+	}
+	return diags
+}
+
+// positionPattern matches the "line:column: " that parser errors put in
+// front of the part of the message about that position.
+var positionPattern = regexp.MustCompile(`(\d+):(\d+): `)
+
+// locate turns an error building or validating the schema into a
+// Diagnostic.
+func locate(d *document, file *ast.File, err error) Diagnostic {
+	diag := Diagnostic{Severity: SeverityError, Message: err.Error()}
+	var ffErr *errors.Error
+	if stderrors.As(err, &ffErr) {
+		diag.Code, diag.Message, diag.Hint = string(ffErr.Code), ffErr.Message, ffErr.Hint()
+	}
+
+	if m := positionPattern.FindStringSubmatchIndex(diag.Message); m != nil {
+		line, _ := strconv.Atoi(diag.Message[m[2]:m[3]])
+		column, _ := strconv.Atoi(diag.Message[m[4]:m[5]])
+		diag.Range = d.word(d.offset(line, column))
+		diag.Message = diag.Message[:m[0]] + diag.Message[m[1]:]
+		return diag
+	}
+
+	names := declarations(file)
+	if ffErr != nil {
+		// Undefined types are reported with the field or union using them
+		if where, ok := ffErr.Context["field"].(string); ok {
+			if n, ok := names[where]; ok {
+				diag.Range = d.span(n)
+				return diag
+			}
+		}
+		if where, ok := ffErr.Context["union"].(string); ok {
+			if n, ok := names[where]; ok {
+				diag.Range = d.span(n)
+				return diag
+			}
+		}
+	}
+
+	// Otherwise the first declaration the message names, preferring
+	// Type.Field to Type
+	var best *ast.Ident
+	for _, word := range strings.FieldsFunc(diag.Message, func(r rune) bool {
+		return !(r == '.' || r < 0x80 && isWordByte(byte(r)))
+	}) {
+		word = strings.Trim(word, ".")
+		if n, ok := names[word]; ok && (best == nil || strings.Contains(word, ".")) {
+			best = n
+			if strings.Contains(word, ".") {
+				break
+			}
+		}
+	}
+	if best != nil {
+		diag.Range = d.span(best)
+	} else {
+		diag.Range = d.span(file.Name)
+	}
+	return diag
+}
+
+// declarations maps the names of a file's types and constants, and of its
+// fields as "Type.Field", to their identifiers.
+func declarations(file *ast.File) map[string]*ast.Ident {
+	names := make(map[string]*ast.Ident)
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.TypeDecl:
+			for _, spec := range decl.Specs {
+				names[spec.Name.Name] = spec.Name
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					for _, field := range st.Fields {
+						for _, name := range field.Names {
+							names[spec.Name.Name+"."+name.Name] = name
+						}
+					}
+				}
+			}
+		case *ast.ConstDecl:
+			for _, spec := range decl.Specs {
+				names[spec.Name.Name] = spec.Name
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,93 @@
+// Package editor answers the questions an editor extension asks about a
+// schema it has open: the outline of its declarations (Symbols), the
+// problems to underline (Diagnose) and a TextMate grammar for syntax
+// highlighting (Grammar). The CLI exposes them as ffire symbols, ffire
+// validate -stdin and ffire grammar, so an extension needs no Go code.
+//
+// Positions follow the Language Server Protocol: lines and characters start
+// at 0, and characters count UTF-16 code units, as editors index lines.
+package editor
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/shaban/ffire/pkg/ast"
+)
+
+// Position is a place in a source file, between two characters.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is the span of source from Start up to, not including, End.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// document converts the byte positions of the ast package to Positions.
+type document struct {
+	src   []byte
+	lines []int // Offset of the first byte of each line
+}
+
+func newDocument(src []byte) *document {
+	d := &document{src: src, lines: []int{0}}
+	for i, c := range src {
+		if c == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+	return d
+}
+
+// position returns the Position of a byte offset.
+func (d *document) position(offset int) Position {
+	offset = min(max(offset, 0), len(d.src))
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > offset }) - 1
+	character := 0
+	for b := d.src[d.lines[line]:offset]; len(b) > 0; {
+		r, size := utf8.DecodeRune(b)
+		if r >= 0x10000 {
+			character += 2 // A surrogate pair
+		} else {
+			character++
+		}
+		b = b[size:]
+	}
+	return Position{Line: line, Character: character}
+}
+
+// offset returns the byte offset of a 1-based line and byte column, as in
+// ast.Pos and the positions in parser errors.
+func (d *document) offset(line, column int) int {
+	if line < 1 || line > len(d.lines) {
+		return len(d.src)
+	}
+	return min(d.lines[line-1]+max(column-1, 0), len(d.src))
+}
+
+// span returns the Range of a node.
+func (d *document) span(n ast.Node) Range {
+	return Range{Start: d.position(n.Pos().Offset), End: d.position(n.End().Offset)}
+}
+
+// word returns the Range of the identifier or token starting at offset;
+// one character wide when there is none, as at the end of the file.
+func (d *document) word(offset int) Range {
+	end := offset
+	for end < len(d.src) && isWordByte(d.src[end]) {
+		end++
+	}
+	if end == offset && end < len(d.src) {
+		_, size := utf8.DecodeRune(d.src[end:])
+		end += size
+	}
+	return Range{Start: d.position(offset), End: d.position(end)}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c|0x20 >= 'a' && c|0x20 <= 'z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,190 @@
+package editor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const studioSchema = `package studio
+
+// Library is the device catalog.
+type Library []Device
+
+type Device struct {
+	// Name shown in menus
+	Name   string ` + "`json:\"name\"`" + `
+	Mode   Mode
+	Origin struct {
+		X, Y float32
+	}
+	Shape  Shape
+}
+
+type Mode int8
+
+const (
+	Mono Mode = iota + 1
+	Stereo
+)
+
+type Shape interface{ Circle | Square }
+
+type Circle struct{ R float32 }
+type Square struct{ Side float32 }
+`
+
+func TestSymbols(t *testing.T) {
+	outline, err := Symbols([]byte(studioSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outline.Package != "studio" {
+		t.Errorf("package = %q", outline.Package)
+	}
+
+	var kinds []string
+	for _, sym := range outline.Symbols {
+		kinds = append(kinds, sym.Name+":"+sym.Kind)
+	}
+	want := "Library:type Device:struct Mode:enum Shape:union Circle:struct Square:struct"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("symbols = %s, want %s", got, want)
+	}
+
+	library := outline.Symbols[0]
+	if !library.Message || library.Detail != "[]Device" || library.Doc != "Library is the device catalog." {
+		t.Errorf("Library = %+v", library)
+	}
+	if want := (Range{Start: Position{3, 5}, End: Position{3, 12}}); library.Selection != want {
+		t.Errorf("Library selection = %+v, want %+v", library.Selection, want)
+	}
+	if outline.Symbols[1].Message {
+		t.Error("Device is not a message")
+	}
+
+	device := outline.Symbols[1]
+	var fields []string
+	for _, f := range device.Children {
+		fields = append(fields, f.Name+" "+f.Detail)
+	}
+	if got := strings.Join(fields, ", "); got != "Name string, Mode Mode, Origin struct{...}, Shape Shape" {
+		t.Errorf("Device fields = %s", got)
+	}
+	if device.Children[0].Doc != "Name shown in menus" {
+		t.Errorf("Name doc = %q", device.Children[0].Doc)
+	}
+	if origin := device.Children[2]; len(origin.Children) != 2 || origin.Children[1].Name != "Y" {
+		t.Errorf("Origin children = %+v", origin.Children)
+	}
+
+	mode := outline.Symbols[2]
+	if len(mode.Children) != 2 || mode.Children[0].Detail != "iota + 1" || mode.Children[1].Name != "Stereo" {
+		t.Errorf("Mode constants = %+v", mode.Children)
+	}
+	if shape := outline.Symbols[3]; len(shape.Children) != 2 || shape.Children[1].Name != "Square" {
+		t.Errorf("Shape variants = %+v", shape.Children)
+	}
+
+	if _, err := Symbols([]byte("package studio\ntype A struct {")); err == nil {
+		t.Error("expected a syntax error")
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	if diags := Diagnose([]byte(studioSchema)); len(diags) != 0 {
+		t.Errorf("valid schema: %+v", diags)
+	}
+
+	tests := []struct {
+		name  string
+		src   string
+		line  int
+		text  string // Source text of the range
+		code  string
+		inMsg string
+	}{
+		{
+			name: "syntax",
+			src:  "package p\n\ntype A struct {\n\tX int32 +\n}\n",
+			line: 3, text: "+",
+		},
+		{
+			name: "undefined type",
+			src:  "package p\n\ntype A struct {\n\tX int32\n\tY Missing\n}\n",
+			line: 4, text: "Y", code: "E005", inMsg: "Missing",
+		},
+		{
+			name: "position in message",
+			src:  "package p\n\ntype A struct {\n\tX [0]int32\n}\n",
+			line: 3, text: "[", inMsg: "array length 0",
+		},
+		{
+			name: "unicode before the error",
+			src:  "package p\n\n// Ünïcödé 😀\ntype A struct { X chan }\n",
+			line: 3, text: "chan",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := Diagnose([]byte(tt.src))
+			if len(diags) != 1 {
+				t.Fatalf("diagnostics = %+v", diags)
+			}
+			diag := diags[0]
+			if diag.Severity != SeverityError || diag.Code != tt.code || !strings.Contains(diag.Message, tt.inMsg) {
+				t.Errorf("diagnostic = %+v", diag)
+			}
+			if diag.Range.Start.Line != tt.line || diag.Range.End.Line != tt.line {
+				t.Fatalf("range = %+v, want line %d", diag.Range, tt.line)
+			}
+			line := []rune(strings.Split(tt.src, "\n")[tt.line])
+			if got := string(line[diag.Range.Start.Character:diag.Range.End.Character]); got != tt.text {
+				t.Errorf("range covers %q, want %q", got, tt.text)
+			}
+		})
+	}
+
+	hints := Diagnose([]byte("package p\n\ntype A struct {\n\tA *int32\n\tB *int32\n\tC *int32\n\tD *int32\n}\n"))
+	if len(hints) != 1 || hints[0].Severity != SeverityHint || hints[0].Range.Start != (Position{2, 5}) {
+		t.Errorf("hints = %+v", hints)
+	}
+	hints = Diagnose([]byte("package p\n\ntype A struct {\n\tA *int32\n\tB *int32\n}\n"))
+	if len(hints) != 1 || !strings.HasSuffix(hints[0].Message, "would save 1 byte per value") {
+		t.Errorf("two optional fields: hints = %+v", hints)
+	}
+
+	imports := Diagnose([]byte("package p\n\nimport (\n\tstr \"strings\"\n)\n\ntype A struct {\n\tX int32\n}\n"))
+	if len(imports) != 1 || imports[0].Severity != SeverityHint || imports[0].Range != (Range{Position{3, 1}, Position{3, 14}}) {
+		t.Errorf("import hints = %+v", imports)
+	}
+}
+
+func TestPositionUTF16(t *testing.T) {
+	d := newDocument([]byte("a\n😀é x"))
+	if got := d.position(len("a\n😀é ")); got != (Position{1, 4}) {
+		t.Errorf("position = %+v, want {1 4}", got)
+	}
+	if got := d.offset(2, 8); got != len("a\n😀é ") {
+		t.Errorf("offset = %d", got)
+	}
+}
+
+func TestGrammar(t *testing.T) {
+	var g struct {
+		ScopeName  string                     `json:"scopeName"`
+		Repository map[string]json.RawMessage `json:"repository"`
+	}
+	data := Grammar()
+	if err := json.Unmarshal(data, &g); err != nil {
+		t.Fatal(err)
+	}
+	if g.ScopeName != ScopeName {
+		t.Errorf("scopeName = %q", g.ScopeName)
+	}
+	for _, want := range []string{`\\b(?:package|import|type|const)\\b`, "bytes|float32", "storage.type.annotation.ffi"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("grammar lacks %s", want)
+		}
+	}
+}
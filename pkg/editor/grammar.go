@@ -0,0 +1,134 @@
+package editor
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// ScopeName is the TextMate scope of .ffi files.
+const ScopeName = "source.ffi"
+
+// rule is a TextMate grammar rule.
+type rule map[string]interface{}
+
+// Grammar returns a TextMate grammar of the schema language as JSON, for
+// editors to highlight .ffi files: keywords, primitive types, declared
+// type names, annotations in comments, struct tags, numbers and strings.
+// Keywords and primitives come from the ast and schema packages, so the
+// grammar follows the language.
+func Grammar() []byte {
+	var keywords, storage []string
+	for tok := ast.PACKAGE; tok.IsKeyword(); tok++ {
+		switch tok {
+		case ast.STRUCT, ast.INTERFACE, ast.MAP, ast.CHAN, ast.FUNC:
+			storage = append(storage, tok.String())
+		default:
+			keywords = append(keywords, tok.String())
+		}
+	}
+	word := func(words []string) string { return `\b(?:` + strings.Join(words, "|") + `)\b` }
+
+	annotation := rule{
+		"match": `(@)([A-Za-z_][A-Za-z0-9_]*)`,
+		"captures": map[string]rule{
+			"1": {"name": "punctuation.definition.annotation.ffi"},
+			"2": {"name": "storage.type.annotation.ffi"},
+		},
+	}
+	grammar := rule{
+		"$schema":   "https://raw.githubusercontent.com/martinring/tmlanguage/master/tmlanguage.json",
+		"name":      "ffire schema",
+		"scopeName": ScopeName,
+		"fileTypes": []string{"ffi"},
+		"patterns": []rule{
+			{"include": "#comments"},
+			{"include": "#strings"},
+			{"include": "#declarations"},
+			{"include": "#keywords"},
+			{"include": "#types"},
+			{"include": "#numbers"},
+			{"include": "#operators"},
+		},
+		"repository": map[string]rule{
+			"comments": {"patterns": []rule{
+				{
+					"name":     "comment.line.double-slash.ffi",
+					"begin":    `//`,
+					"end":      `$`,
+					"patterns": []rule{annotation},
+				},
+				{
+					"name":     "comment.block.ffi",
+					"begin":    `/\*`,
+					"end":      `\*/`,
+					"patterns": []rule{annotation},
+				},
+			}},
+			"strings": {"patterns": []rule{
+				{
+					"name":  "string.quoted.double.ffi",
+					"begin": `"`,
+					"end":   `"`,
+					"patterns": []rule{
+						{"name": "constant.character.escape.ffi", "match": `\\(?:[abfnrtv\\"']|x[0-9A-Fa-f]{2}|u[0-9A-Fa-f]{4}|U[0-9A-Fa-f]{8}|[0-7]{3})`},
+					},
+				},
+				{
+					// Struct tags: `json:"name"`
+					"name":  "string.quoted.raw.ffi",
+					"begin": "`",
+					"end":   "`",
+					"patterns": []rule{
+						{
+							"match": `([A-Za-z_][A-Za-z0-9_]*)(:)`,
+							"captures": map[string]rule{
+								"1": {"name": "entity.other.attribute-name.ffi"},
+								"2": {"name": "punctuation.separator.key-value.ffi"},
+							},
+						},
+					},
+				},
+			}},
+			"declarations": {"patterns": []rule{
+				{
+					"match": `\b(package)\s+([A-Za-z_][A-Za-z0-9_]*)`,
+					"captures": map[string]rule{
+						"1": {"name": "keyword.other.package.ffi"},
+						"2": {"name": "entity.name.namespace.ffi"},
+					},
+				},
+				{
+					"match": `\b(type)\s+([A-Za-z_][A-Za-z0-9_]*)`,
+					"captures": map[string]rule{
+						"1": {"name": "keyword.other.type.ffi"},
+						"2": {"name": "entity.name.type.ffi"},
+					},
+				},
+			}},
+			"keywords": {"patterns": []rule{
+				{"name": "keyword.other.ffi", "match": word(keywords)},
+				{"name": "storage.type.ffi", "match": word(storage)},
+				{"name": "constant.language.iota.ffi", "match": `\biota\b`},
+			}},
+			"types": {"patterns": []rule{
+				{"name": "support.type.primitive.ffi", "match": word(schema.PrimitiveNames())},
+			}},
+			"numbers": {"patterns": []rule{
+				{"name": "constant.numeric.hex.ffi", "match": `\b0[xX][0-9A-Fa-f_]+\b`},
+				{"name": "constant.numeric.ffi", "match": `\b[0-9][0-9_]*(?:\.[0-9_]+)?(?:[eE][+-]?[0-9]+)?\b`},
+			}},
+			"operators": {"patterns": []rule{
+				{"name": "keyword.operator.ffi", "match": `[*|~=+-]`},
+			}},
+		},
+	}
+
+	data, err := json.MarshalIndent(grammar, "", "  ")
+	if err != nil {
+		panic(err) // The grammar is built from strings and maps only
+	}
+	return append(data, '\n')
+}
@@ -0,0 +1,257 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// Symbol kinds.
+const (
+	KindStruct   = "struct"
+	KindUnion    = "union"
+	KindEnum     = "enum"
+	KindGeneric  = "generic"  // A type with type parameters
+	KindType     = "type"     // Any other named type: []Device, int32, ...
+	KindField    = "field"    // A struct field
+	KindVariant  = "variant"  // A union variant
+	KindConstant = "constant" // An enum value, or a constant of no enum
+)
+
+// Symbol is a declaration in the outline of a schema, shaped like an LSP
+// DocumentSymbol.
+type Symbol struct {
+	Name      string   `json:"name"`
+	Kind      string   `json:"kind"`
+	Detail    string   `json:"detail,omitempty"`  // Field and variant type, base type of enums and named types
+	Doc       string   `json:"doc,omitempty"`     // Doc comment without annotations
+	Message   bool     `json:"message,omitempty"` // A root type, which gets an encoder and decoder
+	Range     Range    `json:"range"`             // The whole declaration
+	Selection Range    `json:"selectionRange"`    // The name
+	Children  []Symbol `json:"children,omitempty"`
+}
+
+// Outline is the result of Symbols.
+type Outline struct {
+	Package string   `json:"package"`
+	Symbols []Symbol `json:"symbols"`
+}
+
+// Symbols lists the declarations of a schema in source order: types with
+// their fields or variants, enums with their constants, and constants of no
+// declared type. The source must parse; Message is only set when it also
+// builds a schema.
+func Symbols(src []byte) (*Outline, error) {
+	file, err := ast.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	d := newDocument(src)
+
+	messages := make(map[string]bool)
+	if s, err := parser.FromAST(file); err == nil {
+		for _, msg := range s.Messages {
+			messages[msg.Name] = true
+		}
+	}
+
+	// Constants of a declared type are listed under it, the others where
+	// they are declared
+	declared := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if td, ok := decl.(*ast.TypeDecl); ok {
+			for _, spec := range td.Specs {
+				declared[spec.Name.Name] = true
+			}
+		}
+	}
+
+	outline := &Outline{Package: file.Name.Name}
+	types := make(map[string]int) // Index of each type in outline.Symbols
+	consts := make(map[string][]Symbol)
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.TypeDecl:
+			for _, spec := range decl.Specs {
+				sym := typeSymbol(d, spec, docText(specDoc(decl.Grouped(), decl.Doc, spec.Doc), spec.Comment))
+				sym.Message = messages[sym.Name]
+				types[sym.Name] = len(outline.Symbols)
+				outline.Symbols = append(outline.Symbols, sym)
+			}
+		case *ast.ConstDecl:
+			var typ string // Carried over to specs that omit it
+			for _, spec := range decl.Specs {
+				if spec.Value != nil {
+					typ = ""
+					if id, ok := spec.Type.(*ast.Ident); ok {
+						typ = id.Name
+					}
+				}
+				sym := Symbol{
+					Name:      spec.Name.Name,
+					Kind:      KindConstant,
+					Detail:    constValue(src, spec),
+					Doc:       docText(specDoc(decl.Grouped(), decl.Doc, spec.Doc), spec.Comment),
+					Range:     d.span(spec),
+					Selection: d.span(spec.Name),
+				}
+				if declared[typ] {
+					consts[typ] = append(consts[typ], sym)
+				} else {
+					outline.Symbols = append(outline.Symbols, sym)
+				}
+			}
+		}
+	}
+	for typ, values := range consts {
+		sym := &outline.Symbols[types[typ]]
+		if sym.Kind == KindType {
+			sym.Kind = KindEnum
+		}
+		sym.Children = append(sym.Children, values...)
+	}
+	return outline, nil
+}
+
+// typeSymbol returns the symbol of a type declaration.
+func typeSymbol(d *document, spec *ast.TypeSpec, doc string) Symbol {
+	sym := Symbol{
+		Name:      spec.Name.Name,
+		Kind:      KindType,
+		Detail:    typeText(spec.Type),
+		Doc:       doc,
+		Range:     d.span(spec),
+		Selection: d.span(spec.Name),
+	}
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		sym.Kind, sym.Detail = KindStruct, ""
+		sym.Children = fieldSymbols(d, t)
+	case *ast.InterfaceType:
+		sym.Kind, sym.Detail = KindUnion, ""
+		for _, term := range t.Terms {
+			sym.Children = append(sym.Children, Symbol{
+				Name:      typeText(term),
+				Kind:      KindVariant,
+				Range:     d.span(term),
+				Selection: d.span(term),
+			})
+		}
+	}
+	if spec.TypeParams != nil {
+		sym.Kind = KindGeneric
+	}
+	return sym
+}
+
+// fieldSymbols lists the fields of a struct; those of an anonymous struct
+// field type are its children.
+func fieldSymbols(d *document, st *ast.StructType) []Symbol {
+	var fields []Symbol
+	for _, field := range st.Fields {
+		var children []Symbol
+		if anon := anonymousStruct(field.Type); anon != nil {
+			children = fieldSymbols(d, anon)
+		}
+		for _, name := range field.Names {
+			fields = append(fields, Symbol{
+				Name:      name.Name,
+				Kind:      KindField,
+				Detail:    typeText(field.Type),
+				Doc:       docText(field.Doc, field.Comment),
+				Range:     d.span(field),
+				Selection: d.span(name),
+				Children:  children,
+			})
+		}
+	}
+	return fields
+}
+
+// anonymousStruct returns the struct literal in a field type such as
+// []struct{...}, or nil.
+func anonymousStruct(x ast.Expr) *ast.StructType {
+	for {
+		switch t := x.(type) {
+		case *ast.StructType:
+			return t
+		case *ast.StarExpr:
+			x = t.X
+		case *ast.ArrayType:
+			x = t.Elt
+		case *ast.MapType:
+			x = t.Value
+		default:
+			return nil
+		}
+	}
+}
+
+// typeText renders a type expression on one line, with struct and
+// interface bodies elided.
+func typeText(x ast.Expr) string {
+	switch t := x.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.BasicLit:
+		return t.Value
+	case *ast.StarExpr:
+		return "*" + typeText(t.X)
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return "[" + typeText(t.Len) + "]" + typeText(t.Elt)
+		}
+		return "[]" + typeText(t.Elt)
+	case *ast.MapType:
+		return "map[" + typeText(t.Key) + "]" + typeText(t.Value)
+	case *ast.IndexExpr:
+		args := make([]string, len(t.Indices))
+		for i, arg := range t.Indices {
+			args[i] = typeText(arg)
+		}
+		return typeText(t.X) + "[" + strings.Join(args, ", ") + "]"
+	case *ast.SelectorExpr:
+		return typeText(t.X) + "." + t.Sel.Name
+	case *ast.StructType:
+		return "struct{...}"
+	case *ast.InterfaceType:
+		return "interface{...}"
+	}
+	return ""
+}
+
+// constValue returns the value expression of a constant as written, or ""
+// when the spec repeats the one before it.
+func constValue(src []byte, spec *ast.ConstSpec) string {
+	if spec.Value == nil {
+		return ""
+	}
+	return string(src[spec.Value.Pos().Offset:spec.Value.End().Offset])
+}
+
+// specDoc returns the comment above a spec: its own in a group, or the
+// declaration's otherwise.
+func specDoc(grouped bool, declDoc, specDoc *ast.CommentGroup) *ast.CommentGroup {
+	if grouped {
+		return specDoc
+	}
+	return declDoc
+}
+
+// docText returns the first comment group with text besides annotations,
+// without them.
+func docText(groups ...*ast.CommentGroup) string {
+	for _, group := range groups {
+		var lines []string
+		for _, line := range strings.Split(group.Text(), "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "@") {
+				lines = append(lines, line)
+			}
+		}
+		if text := strings.TrimSpace(strings.Join(lines, "\n")); text != "" {
+			return text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package errors
+
+import "fmt"
+
+// DecodeCode identifies the kind of a decode failure. Generated decoders
+// in every language report the same codes, so a system mixing languages
+// can branch on the kind of failure instead of parsing messages. The
+// numbers are part of the generated APIs and never change.
+type DecodeCode int
+
+const (
+	DecodeTruncated    DecodeCode = 1 // Data ends inside a value
+	DecodeOverflow     DecodeCode = 2 // A length or count exceeds the data left
+	DecodeInvalidUtf8  DecodeCode = 3 // A string is not valid UTF-8
+	DecodeBadPresence  DecodeCode = 4 // A presence flag is not 0x00 or 0x01, or an unused presence bitmap bit is set
+	DecodeInvalidValue DecodeCode = 5 // A bool, enum value or union tag the schema does not allow
+	DecodeTrailingData DecodeCode = 6 // Bytes follow the end of the message, or the fields of a @framed struct
+)
+
+// DecodeCodeInfo describes a decode failure code to generators and docs.
+type DecodeCodeInfo struct {
+	Code    DecodeCode
+	Name    string // PascalCase; generators convert it to each language's case
+	Summary string
+}
+
+// DecodeCodes lists the decode failure codes in numeric order.
+var DecodeCodes = []DecodeCodeInfo{
+	{DecodeTruncated, "Truncated", "data ends inside a value"},
+	{DecodeOverflow, "Overflow", "a length or count exceeds the data left"},
+	{DecodeInvalidUtf8, "InvalidUtf8", "a string is not valid UTF-8"},
+	{DecodeBadPresence, "BadPresence", "a presence flag is not 0x00 or 0x01, or an unused presence bitmap bit is set"},
+	{DecodeInvalidValue, "InvalidValue", "a bool, enum value or union tag the schema does not allow"},
+	{DecodeTrailingData, "TrailingData", "bytes follow the end of the message, or the fields of a @framed struct"},
+}
+
+// String returns the code's name, e.g. "Truncated".
+func (c DecodeCode) String() string {
+	if c >= 1 && int(c) <= len(DecodeCodes) {
+		return DecodeCodes[c-1].Name
+	}
+	return fmt.Sprintf("DecodeCode(%d)", int(c))
+}
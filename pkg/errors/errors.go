@@ -48,24 +48,68 @@ const (
 	ErrFileWrite  ErrorCode = "E030" // Failed to write file
 	ErrFileParse  ErrorCode = "E031" // Failed to parse schema file
 	ErrFileCreate ErrorCode = "E032" // Failed to create file or directory
+
+	// Map errors (E033-E035)
+	ErrInvalidMapKey ErrorCode = "E033" // Map key type is not a string, integer or bool
+	ErrMapTooLong    ErrorCode = "E034" // Map exceeds maximum size (65535 entries)
+	ErrMapRoot       ErrorCode = "E035" // Message root type cannot be a map
+
+	// Enum errors (E036-E040)
+	ErrInvalidEnumBase     ErrorCode = "E036" // Enum base type is not an integer type
+	ErrDuplicateEnumValue  ErrorCode = "E037" // Two enum constants share a value
+	ErrEnumValueOutOfRange ErrorCode = "E038" // Enum constant does not fit the base type
+	ErrUnknownEnumValue    ErrorCode = "E039" // Value is not a member of the enum
+	ErrEnumRoot            ErrorCode = "E040" // Message root type cannot be an enum
+
+	// Union errors (E041-E045)
+	ErrInvalidUnionVariant   ErrorCode = "E041" // Union variant is not a struct, enum or primitive
+	ErrDuplicateUnionVariant ErrorCode = "E042" // Union lists the same type twice
+	ErrUnionVariantCount     ErrorCode = "E043" // Union has no variants or more than 255
+	ErrUnknownUnionVariant   ErrorCode = "E044" // Value names a type that is not a variant of the union
+	ErrUnionRoot             ErrorCode = "E045" // Message root type cannot be a union
+
+	// Bytes errors (E046-E047)
+	ErrInvalidBase64 ErrorCode = "E046" // Bytes value is not valid base64
+	ErrBytesTooLong  ErrorCode = "E047" // Bytes value exceeds maximum length (65535 bytes)
+
+	// Fixed-size array errors (E048-E049)
+	ErrInvalidFixedArray ErrorCode = "E048" // Fixed-size array element or placement is not supported
+	ErrFixedArrayLength  ErrorCode = "E049" // Value length does not match the fixed array length
 )
 
 // errorHints provides helpful hints for each error code
 var errorHints = map[ErrorCode]string{
-	ErrEmptyPackage:      "Add a package declaration at the top of your schema file, e.g., 'package myapp'",
-	ErrNoMessages:        "Define at least one message type, e.g., 'type Message = YourType'",
-	ErrEmptyMessageName:  "Message type must have a name, e.g., 'type Message = ...'",
-	ErrUndefinedType:     "Make sure the type is defined before using it, or use a built-in type (string, int32, float32, etc.)",
-	ErrEmptyStruct:       "Structs must have at least one field",
-	ErrCircularReference: "Types cannot reference themselves directly or indirectly",
-	ErrMaxNestingDepth:   "Reduce nesting depth by flattening your data structure or using separate types",
-	ErrMessageNotFound:   "Check that the message name matches one defined in your schema",
-	ErrInvalidJSON:       "Ensure your JSON is well-formed (use a JSON validator)",
-	ErrInt8OutOfRange:    "int8 values must be between -128 and 127",
-	ErrInt16OutOfRange:   "int16 values must be between -32768 and 32767",
-	ErrInt32OutOfRange:   "int32 values must be between -2147483648 and 2147483647",
-	ErrStringTooLong:     "Strings are limited to 65,535 bytes in the wire format",
-	ErrArrayTooLong:      "Arrays are limited to 65,535 elements in the wire format",
+	ErrEmptyPackage:          "Add a package declaration at the top of your schema file, e.g., 'package myapp'",
+	ErrNoMessages:            "Define at least one message type, e.g., 'type Message = YourType'",
+	ErrEmptyMessageName:      "Message type must have a name, e.g., 'type Message = ...'",
+	ErrUndefinedType:         "Declare the type anywhere in the schema (order does not matter), or use a built-in type (string, int32, float32, etc.)",
+	ErrEmptyStruct:           "Structs must have at least one field",
+	ErrCircularReference:     "Types cannot reference themselves directly or indirectly",
+	ErrMaxNestingDepth:       "Reduce nesting depth by flattening your data structure or using separate types",
+	ErrMessageNotFound:       "Check that the message name matches one defined in your schema",
+	ErrInvalidJSON:           "Ensure your JSON is well-formed (use a JSON validator)",
+	ErrInt8OutOfRange:        "int8 values must be between -128 and 127",
+	ErrInt16OutOfRange:       "int16 values must be between -32768 and 32767",
+	ErrInt32OutOfRange:       "int32 values must be between -2147483648 and 2147483647",
+	ErrStringTooLong:         "Strings are limited to 65,535 bytes in the wire format; mark the field @large for up to 4 GiB",
+	ErrArrayTooLong:          "Arrays are limited to 65,535 elements in the wire format; mark the field or array type @large for more",
+	ErrInvalidMapKey:         "Map keys must be string, bool or an integer type (int8-int64), and cannot be optional or bytes",
+	ErrMapTooLong:            "Maps are limited to 65,535 entries in the wire format; mark the field or map type @large for more",
+	ErrMapRoot:               "Wrap the map in a struct, e.g., 'type Table struct { Entries map[string]int32 }'",
+	ErrInvalidEnumBase:       "Declare enums on an integer type, e.g., 'type Mode int8'",
+	ErrDuplicateEnumValue:    "Give each enum constant a distinct value; a constant without a value repeats the previous expression, so use iota",
+	ErrEnumValueOutOfRange:   "Pick a wider base type for the enum, e.g., 'type Mode int16'",
+	ErrUnknownEnumValue:      "Use one of the enum's constant names (or its value)",
+	ErrEnumRoot:              "Wrap the enum in a struct, e.g., 'type Settings struct { Mode Mode }'",
+	ErrInvalidUnionVariant:   "Union variants must be named structs, enums or primitives; wrap arrays and maps in a struct",
+	ErrDuplicateUnionVariant: "List each type once; wrap one of them in a struct to tell two variants of the same type apart",
+	ErrUnionVariantCount:     "Unions need 1 to 255 variants; group rarely used variants into a nested union inside a struct",
+	ErrUnknownUnionVariant:   "Write a union value as an object with one key naming the variant, e.g., {\"Circle\": {...}}",
+	ErrUnionRoot:             "Wrap the union in a struct, e.g., 'type Drawing struct { Shape Shape }'",
+	ErrInvalidBase64:         "Write bytes values as standard base64 with padding, e.g., \"3q2+7w==\"",
+	ErrBytesTooLong:          "Bytes values are limited to 65,535 bytes in the wire format; mark the field @large for up to 4 GiB",
+	ErrInvalidFixedArray:     "Fixed-size arrays hold numbers or bools, e.g., '[16]float32', and must be non-optional struct fields",
+	ErrFixedArrayLength:      "A fixed-size array value must have exactly as many elements as the array length",
 }
 
 // Error represents a structured error with code and context.
@@ -70,3 +70,18 @@ func TestErrorWithContext(t *testing.T) {
 		t.Errorf("Error() = %q, want context in output", errStr)
 	}
 }
+
+func TestDecodeCodes(t *testing.T) {
+	// String indexes the table by code, so it must stay dense and in order
+	for i, info := range DecodeCodes {
+		if int(info.Code) != i+1 {
+			t.Errorf("DecodeCodes[%d] has code %d, want %d", i, info.Code, i+1)
+		}
+		if info.Code.String() != info.Name {
+			t.Errorf("%d.String() = %q, want %q", info.Code, info.Code.String(), info.Name)
+		}
+	}
+	if got := DecodeCode(99).String(); got != "DecodeCode(99)" {
+		t.Errorf("unknown code String() = %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// CSV mapping: each element of an array-of-struct message is a row (a
+// struct message is a single row) and each field a column named by its
+// JSON name.
+//
+//	primitive field   -> cell; bytes as base64, empty if absent
+//	enum field        -> cell holding the constant name
+//	struct field      -> one column per field, "<field>.<child>"
+//	[N]primitive      -> one column per element, "<field>.0" ... "<field>.N-1"
+//	slices, maps      -> JSON cell
+//	unions            -> JSON cell holding {"Variant": value}
+//
+// Options.Flatten limits how many levels of structs and fixed-size arrays
+// are spread over columns; deeper ones become JSON cells.
+
+// csvColumn is a leaf column: the path of JSON names leading to its value.
+type csvColumn struct {
+	name string
+	path []string
+	typ  schema.Type
+	json bool // Written as JSON: a slice, map, union or unflattened struct
+}
+
+// CSV renders a decoded message (the value tree of fixture.Decode or
+// convert.Decode) as CSV with a header row.
+func CSV(s *schema.Schema, messageName string, value interface{}, opts Options) ([]byte, error) {
+	var root schema.Type
+	for _, msg := range s.Messages {
+		if msg.Name == messageName {
+			root = msg.TargetType
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("message type %s not found in schema", messageName)
+	}
+
+	var rows []interface{}
+	var row *schema.StructType
+	switch typ := root.(type) {
+	case *schema.StructType:
+		row, rows = typ, []interface{}{value}
+	case *schema.ArrayType:
+		st, ok := typ.ElementType.(*schema.StructType)
+		if !ok {
+			return nil, fmt.Errorf("CSV export needs a struct or array-of-struct message, %s is %s", messageName, typ.TypeName())
+		}
+		elems, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: expected an array, got %T", messageName, value)
+		}
+		row, rows = st, elems
+	default:
+		return nil, fmt.Errorf("CSV export needs a struct or array-of-struct message, %s is %s", messageName, root.TypeName())
+	}
+
+	sep := opts.Separator
+	if sep == "" {
+		sep = "."
+	}
+	columns := csvColumns(row, nil, "", sep, opts.Flatten, map[string]bool{})
+
+	buf := &bytes.Buffer{}
+	if opts.BOM {
+		buf.WriteString("\ufeff")
+	}
+	w := csv.NewWriter(buf)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	w.Write(header)
+
+	record := make([]string, len(columns))
+	for n, r := range rows {
+		for i, c := range columns {
+			cell, err := csvCell(c, r)
+			if err != nil {
+				return nil, fmt.Errorf("row %d, column %s: %w", n+1, c.name, err)
+			}
+			record[i] = cell
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvColumns lists the leaf columns of st, flattening depth more levels
+// (all of them if depth is negative). Structs already on the path are not
+// flattened again, so recursive types end in a JSON cell.
+func csvColumns(st *schema.StructType, path []string, prefix, sep string, depth int, open map[string]bool) []csvColumn {
+	open[st.Name] = true
+	defer delete(open, st.Name)
+
+	var columns []csvColumn
+	for _, field := range st.Fields {
+		name := prefix + field.JSONName()
+		fieldPath := append(append([]string(nil), path...), field.JSONName())
+		switch typ := field.Type.(type) {
+		case *schema.StructType:
+			if depth != 0 && !open[typ.Name] {
+				columns = append(columns, csvColumns(typ, fieldPath, name+sep, sep, depth-1, open)...)
+				continue
+			}
+		case *schema.ArrayType:
+			if depth != 0 && typ.Length > 0 && csvScalar(typ.ElementType) {
+				for i := 0; i < typ.Length; i++ {
+					index := strconv.Itoa(i)
+					columns = append(columns, csvColumn{
+						name: name + sep + index,
+						path: append(append([]string(nil), fieldPath...), index),
+						typ:  typ.ElementType,
+					})
+				}
+				continue
+			}
+		}
+		columns = append(columns, csvColumn{name: name, path: fieldPath, typ: field.Type, json: !csvScalar(field.Type)})
+	}
+	return columns
+}
+
+// csvScalar reports whether values of t fit in a cell without JSON.
+func csvScalar(t schema.Type) bool {
+	switch t.(type) {
+	case *schema.PrimitiveType, *schema.EnumType:
+		return true
+	}
+	return false
+}
+
+// csvCell looks up the column's value in a row and formats it. Path
+// elements are JSON names, or element indexes of fixed-size arrays. An
+// absent optional value, or one inside an absent struct, is an empty cell.
+func csvCell(c csvColumn, row interface{}) (string, error) {
+	v := row
+	for _, key := range c.path {
+		if v == nil {
+			return "", nil
+		}
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, _ := strconv.Atoi(key)
+			if i >= len(node) {
+				return "", fmt.Errorf("array has %d elements", len(node))
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("expected an object or array, got %T", v)
+		}
+	}
+	if v == nil {
+		return "", nil
+	}
+	if c.json {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case bool:
+		return strconv.FormatBool(x), nil
+	case int64:
+		return strconv.FormatInt(x, 10), nil
+	case float64:
+		// float32 values print in their shortest float32 form, 0.1 and not 0.10000000149011612
+		bits := 64
+		if p, ok := c.typ.(*schema.PrimitiveType); ok && p.Name == "float32" {
+			bits = 32
+		}
+		return strconv.FormatFloat(x, 'g', -1, bits), nil
+	case json.Number:
+		return x.String(), nil
+	}
+	return "", fmt.Errorf("unexpected %T value", v)
+}
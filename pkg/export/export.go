@@ -0,0 +1,245 @@
+// Package export renders ffire schemas in other schema languages (SQL DDL,
+// GraphQL SDL, OpenAPI, Protocol Buffers) so systems that store or serve
+// the same data stay consistent with the ffire contract, and flattens
+// decoded messages into CSV for spreadsheets.
+package export
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Options controls export output.
+type Options struct {
+	// TypeMap overrides the target type of ffire primitives, keyed by
+	// primitive name ("int64", "string", ...). Formats may accept extra keys,
+	// e.g. "json" and "id" for SQL.
+	TypeMap map[string]string
+
+	// Dialect selects the SQL dialect: "postgres" (default), "mysql", "sqlite".
+	Dialect string
+
+	// GoPackage sets the go_package option of proto output.
+	GoPackage string
+
+	// Flatten is how many levels of nested structs and fixed-size arrays
+	// CSV output spreads over columns; deeper ones are JSON cells. Negative
+	// flattens every level.
+	Flatten int
+
+	// Separator joins the names of flattened CSV columns (default ".").
+	Separator string
+
+	// BOM starts CSV output with a UTF-8 byte order mark, which Excel needs
+	// to read non-ASCII text correctly.
+	BOM bool
+}
+
+// ParseTypeMap parses a comma-separated mapping like "int64=NUMERIC(20),string=VARCHAR(255)".
+func ParseTypeMap(spec string) (map[string]string, error) {
+	m := make(map[string]string)
+	if strings.TrimSpace(spec) == "" {
+		return m, nil
+	}
+
+	// Split on commas that start a new key=value pair, so target types may
+	// contain commas themselves (e.g. NUMERIC(20,0)).
+	var pairs []string
+	depth, start := 0, 0
+	for i, r := range spec {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				pairs = append(pairs, spec[start:i])
+				start = i + 1
+			}
+		}
+	}
+	pairs = append(pairs, spec[start:])
+
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid type mapping %q: expected type=target", pair)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// checkTypeMap rejects mapping keys the format does not understand.
+func checkTypeMap(typeMap map[string]string, extra ...string) error {
+	for key := range typeMap {
+		if schema.IsPrimitive(key) {
+			continue
+		}
+		known := false
+		for _, e := range extra {
+			if key == e {
+				known = true
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown type %q in type mapping", key)
+		}
+	}
+	return nil
+}
+
+// structs returns every struct type reachable from the schema, in first-seen
+// order. Optional references are copies of the struct, so types are
+// identified by name.
+func structs(s *schema.Schema) []*schema.StructType {
+	var result []*schema.StructType
+	seen := make(map[string]bool)
+
+	var walk func(t schema.Type)
+	walk = func(t schema.Type) {
+		switch typ := t.(type) {
+		case *schema.StructType:
+			if seen[typ.Name] {
+				return
+			}
+			seen[typ.Name] = true
+			result = append(result, typ)
+			for _, field := range typ.Fields {
+				walk(field.Type)
+			}
+		case *schema.ArrayType:
+			walk(typ.ElementType)
+		case *schema.MapType:
+			walk(typ.ValueType)
+		case *schema.UnionType:
+			for _, v := range typ.Variants {
+				walk(v)
+			}
+		}
+	}
+
+	for _, t := range s.Types {
+		walk(t)
+	}
+	for _, msg := range s.Messages {
+		walk(msg.TargetType)
+	}
+	return result
+}
+
+// enums returns every enum type used by a field, union or root message, in
+// first-seen order.
+func enums(s *schema.Schema) []*schema.EnumType {
+	var result []*schema.EnumType
+	seen := make(map[string]bool)
+	for _, t := range fieldTypes(s) {
+		if typ, ok := t.(*schema.EnumType); ok && !seen[typ.Name] {
+			seen[typ.Name] = true
+			result = append(result, typ)
+		}
+	}
+	return result
+}
+
+// unions returns every union type used by a field or root message, in
+// first-seen order.
+func unions(s *schema.Schema) []*schema.UnionType {
+	var result []*schema.UnionType
+	seen := make(map[string]bool)
+	for _, t := range fieldTypes(s) {
+		if typ, ok := t.(*schema.UnionType); ok && !seen[typ.Name] {
+			seen[typ.Name] = true
+			result = append(result, typ)
+		}
+	}
+	return result
+}
+
+// fieldTypes returns the types of struct fields, root messages and union
+// variants, looking through arrays and maps, in first-seen order.
+func fieldTypes(s *schema.Schema) []schema.Type {
+	var result []schema.Type
+	seen := make(map[string]bool)
+
+	var add func(t schema.Type)
+	add = func(t schema.Type) {
+		switch typ := t.(type) {
+		case *schema.ArrayType:
+			add(typ.ElementType)
+		case *schema.MapType:
+			add(typ.ValueType)
+		case *schema.UnionType:
+			result = append(result, typ)
+			if !seen[typ.Name] {
+				seen[typ.Name] = true
+				for _, v := range typ.Variants {
+					add(v)
+				}
+			}
+		default:
+			result = append(result, typ)
+		}
+	}
+
+	for _, st := range structs(s) {
+		for _, field := range st.Fields {
+			add(field.Type)
+		}
+	}
+	for _, msg := range s.Messages {
+		add(msg.TargetType)
+	}
+	return result
+}
+
+// snakeCase converts Go-style names to snake_case, keeping acronyms
+// together: "DeviceID" -> "device_id", "HTTPServer" -> "http_server".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// lowerCamel converts a field name to lowerCamelCase, keeping leading
+// acronyms lowercase: "DeviceID" -> "deviceID", "URL" -> "url", "HTTPPort" -> "httpPort".
+func lowerCamel(name string) string {
+	runes := []rune(name)
+	n := 0
+	for n < len(runes) && unicode.IsUpper(runes[n]) {
+		n++
+	}
+	if n > 1 && n < len(runes) {
+		n-- // The last capital starts the next word: HTTPPort -> http|Port
+	}
+	for i := 0; i < n; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// fieldName returns the name a field is exposed under in JSON-shaped
+// formats: the JSON tag if present, otherwise the lowerCamelCase field name.
+func fieldName(field schema.Field) string {
+	if name := field.JSONName(); name != field.Name {
+		return name
+	}
+	return lowerCamel(field.Name)
+}
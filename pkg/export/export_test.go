@@ -0,0 +1,616 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const librarySchema = `package audio
+
+type Library struct {
+	Name    string ` + "`json:\"name\"`" + `
+	Devices []Device
+	Backup  []Device
+	Owner   *User
+	Tags    []string
+}
+
+type Device struct {
+	DeviceID int64
+	Label    string
+	Gain     *float32
+}
+
+type User struct {
+	ID    string
+	Email string
+}
+`
+
+func parseLibrary(t *testing.T) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(librarySchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	return s
+}
+
+func TestSQL(t *testing.T) {
+	out, err := SQL(parseLibrary(t), Options{})
+	if err != nil {
+		t.Fatalf("SQL failed: %v", err)
+	}
+	sql := string(out)
+
+	for _, want := range []string{
+		`CREATE TABLE "user" (`,
+		`"id" TEXT PRIMARY KEY,`,
+		`"owner_id" TEXT REFERENCES "user" ("id"),`,
+		`"tags" JSONB NOT NULL -- []string as JSON`,
+		`"device_id" BIGINT NOT NULL,`,
+		`"gain" REAL,`,
+		`"library_devices_id" BIGINT REFERENCES "library" ("id"),`,
+		`"library_backup_pos" INTEGER`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+
+	// Referenced tables are created before the tables referencing them
+	if strings.Index(sql, `TABLE "user"`) > strings.Index(sql, `TABLE "library"`) ||
+		strings.Index(sql, `TABLE "library"`) > strings.Index(sql, `TABLE "device"`) {
+		t.Errorf("tables not in dependency order:\n%s", sql)
+	}
+}
+
+func TestSQLDialectAndTypeMap(t *testing.T) {
+	typeMap, err := ParseTypeMap("string=VARCHAR(255), float32=NUMERIC(10,4)")
+	if err != nil {
+		t.Fatalf("ParseTypeMap failed: %v", err)
+	}
+
+	out, err := SQL(parseLibrary(t), Options{Dialect: "mysql", TypeMap: typeMap})
+	if err != nil {
+		t.Fatalf("SQL failed: %v", err)
+	}
+	sql := string(out)
+
+	for _, want := range []string{"`label` VARCHAR(255) NOT NULL", "`gain` NUMERIC(10,4)", "`tags` JSON NOT NULL"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+
+	if _, err := SQL(parseLibrary(t), Options{Dialect: "oracle"}); err == nil {
+		t.Error("expected error for unknown dialect")
+	}
+	if _, err := SQL(parseLibrary(t), Options{TypeMap: map[string]string{"uint64": "NUMERIC"}}); err == nil {
+		t.Error("expected error for unknown type mapping key")
+	}
+}
+
+func TestParseTypeMap(t *testing.T) {
+	if _, err := ParseTypeMap("int64"); err == nil {
+		t.Error("expected error for mapping without target")
+	}
+	m, err := ParseTypeMap("")
+	if err != nil || len(m) != 0 {
+		t.Errorf("ParseTypeMap(\"\") = %v, %v; want empty map", m, err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"DeviceID":   "device_id",
+		"HTTPServer": "http_server",
+		"Name":       "name",
+		"userEmail":  "user_email",
+		"Level2Gain": "level2_gain",
+	}
+	for in, want := range tests {
+		if got := snakeCase(in); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	out, err := GraphQL(parseLibrary(t), Options{})
+	if err != nil {
+		t.Fatalf("GraphQL failed: %v", err)
+	}
+	sdl := string(out)
+
+	for _, want := range []string{
+		"scalar Int64\n",
+		"type Library {\n  name: String!\n  devices: [Device!]!\n",
+		"  owner: User\n",
+		"  tags: [String!]!\n",
+		"  deviceID: Int64!\n",
+		"  gain: Float\n",
+		"type User {\n  id: String!\n",
+	} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL missing %q:\n%s", want, sdl)
+		}
+	}
+}
+
+func TestGraphQLScalarMapping(t *testing.T) {
+	out, err := GraphQL(parseLibrary(t), Options{TypeMap: map[string]string{"int64": "String", "float32": "Decimal"}})
+	if err != nil {
+		t.Fatalf("GraphQL failed: %v", err)
+	}
+	sdl := string(out)
+
+	if strings.Contains(sdl, "Int64") || !strings.Contains(sdl, "deviceID: String!") {
+		t.Errorf("int64 mapping not applied:\n%s", sdl)
+	}
+	if !strings.Contains(sdl, "scalar Decimal\n") || !strings.Contains(sdl, "gain: Decimal\n") {
+		t.Errorf("custom scalar not declared:\n%s", sdl)
+	}
+
+	if _, err := GraphQL(parseLibrary(t), Options{TypeMap: map[string]string{"string": "Not Valid"}}); err == nil {
+		t.Error("expected error for invalid scalar name")
+	}
+}
+
+func TestLowerCamel(t *testing.T) {
+	tests := map[string]string{
+		"DeviceID": "deviceID",
+		"URL":      "url",
+		"HTTPPort": "httpPort",
+		"Name":     "name",
+		"name":     "name",
+	}
+	for in, want := range tests {
+		if got := lowerCamel(in); got != want {
+			t.Errorf("lowerCamel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	out, err := OpenAPI(parseLibrary(t), Options{})
+	if err != nil {
+		t.Fatalf("OpenAPI failed: %v", err)
+	}
+
+	var doc struct {
+		OpenAPI    string
+		Components struct {
+			Schemas       map[string]map[string]interface{}
+			RequestBodies map[string]struct {
+				Content map[string]map[string]interface{}
+			}
+		}
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("openapi = %q, want 3.0.3", doc.OpenAPI)
+	}
+
+	library := doc.Components.Schemas["Library"]
+	props := library["properties"].(map[string]interface{})
+	if _, ok := props["name"]; !ok {
+		t.Errorf("Library properties should use JSON names: %v", props)
+	}
+	owner := props["Owner"].(map[string]interface{})
+	if owner["nullable"] != true || owner["allOf"] == nil {
+		t.Errorf("optional struct should be a nullable allOf ref: %v", owner)
+	}
+	for _, r := range library["required"].([]interface{}) {
+		if r == "Owner" {
+			t.Error("optional field listed as required")
+		}
+	}
+
+	gain := doc.Components.Schemas["Device"]["properties"].(map[string]interface{})["Gain"].(map[string]interface{})
+	if gain["format"] != "float" || gain["nullable"] != true {
+		t.Errorf("Gain = %v, want nullable float", gain)
+	}
+
+	body, ok := doc.Components.RequestBodies["Library"]
+	if !ok {
+		t.Fatal("missing request body for Library message")
+	}
+	binary := body.Content[BinaryMediaType]
+	if binary["x-ffire-message"] != "Library" {
+		t.Errorf("binary content = %v", binary)
+	}
+	if _, ok := body.Content["application/json"]; !ok {
+		t.Error("missing JSON content")
+	}
+
+	if _, err := OpenAPI(parseLibrary(t), Options{TypeMap: map[string]string{"int64": "string"}}); err == nil {
+		t.Error("expected error for type mapping")
+	}
+}
+
+func TestProto(t *testing.T) {
+	out, err := Proto(parseLibrary(t), Options{GoPackage: "example.com/audio/pb"})
+	if err != nil {
+		t.Fatalf("Proto failed: %v", err)
+	}
+	proto := string(out)
+
+	for _, want := range []string{
+		`syntax = "proto3";`,
+		`package audio;`,
+		`option go_package = "example.com/audio/pb";`,
+		"message Library {\n  string name = 1;\n  repeated Device devices = 2 [json_name = \"Devices\"];",
+		`User owner = 4 [json_name = "Owner"];`,
+		`int64 device_id = 1 [json_name = "DeviceID"];`,
+		`optional float gain = 3 [json_name = "Gain"];`,
+	} {
+		if !strings.Contains(proto, want) {
+			t.Errorf("missing %q in:\n%s", want, proto)
+		}
+	}
+
+	if _, err := Proto(parseLibrary(t), Options{TypeMap: map[string]string{"int64": "NUMERIC"}}); err == nil {
+		t.Error("expected error for a type mapping to a non-proto type")
+	}
+}
+
+func TestProtoWrapper(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype IntList []int32\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ProtoWrapper(s.Messages[0]); got != "values" {
+		t.Errorf("ProtoWrapper(IntList) = %q, want values", got)
+	}
+	out, err := Proto(s, Options{TypeMap: map[string]string{"int32": "sint32"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "message IntList {\n  repeated sint32 values = 1;\n}") {
+		t.Errorf("missing wrapper message in:\n%s", out)
+	}
+
+	if got := ProtoWrapper(parseLibrary(t).Messages[0]); got != "" {
+		t.Errorf("ProtoWrapper(Library) = %q, want none", got)
+	}
+}
+
+func TestProtoJSONName(t *testing.T) {
+	tests := map[string]string{
+		"device_id":  "deviceId",
+		"name":       "name",
+		"enable_ssl": "enableSsl",
+	}
+	for in, want := range tests {
+		if got := protoJSONName(in); got != want {
+			t.Errorf("protoJSONName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExportMaps(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package test
+
+type Table struct {
+	Limits map[string]int32
+	Users  map[int64]*User
+	Tags   map[string][]string
+}
+
+type User struct {
+	Name string
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Proto maps cannot hold arrays
+	if _, err := Proto(s, Options{}); err == nil || !strings.Contains(err.Error(), "Tags") {
+		t.Errorf("expected error for Tags, got %v", err)
+	}
+	s.Messages[0].TargetType.(*schema.StructType).Fields = s.Messages[0].TargetType.(*schema.StructType).Fields[:2]
+	out, err := Proto(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`map<string, int32> limits = 1 [json_name = "Limits"];`,
+		`map<int64, User> users = 2 [json_name = "Users"];`,
+		"message User {",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = SQL(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"limits" JSONB NOT NULL, -- map[string]int32 as JSON object`) {
+		t.Errorf("missing JSON map column in:\n%s", out)
+	}
+
+	if _, err := GraphQL(s, Options{}); err == nil {
+		t.Error("expected error for maps in GraphQL")
+	}
+}
+
+func TestExportEnums(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package test
+
+type Mode int8
+
+const (
+	Mono Mode = iota + 1
+	Stereo
+)
+
+type Level int32
+
+const (
+	High Level = 2
+	Off  Level = 0
+)
+
+type Channel struct {
+	Mode   Mode
+	Levels []Level
+	Backup *Mode
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Proto(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"enum Mode {\n  MODE_UNSPECIFIED = 0;\n  Mono = 1;\n  Stereo = 2;\n}",
+		"enum Level {\n  Off = 0;\n  High = 2;\n}",
+		`Mode mode = 1 [json_name = "Mode"];`,
+		`repeated Level levels = 2 [json_name = "Levels"];`,
+		`optional Mode backup = 3 [json_name = "Backup"];`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = GraphQL(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"enum Mode {\n  Mono\n  Stereo\n}", "mode: Mode!", "levels: [Level!]!", "backup: Mode\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = SQL(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"mode" SMALLINT NOT NULL, -- Mode: 1=Mono, 2=Stereo`) {
+		t.Errorf("missing enum column in:\n%s", out)
+	}
+
+	out, err = OpenAPI(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Type string   `json:"type"`
+				Enum []string `json:"enum"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	mode := doc.Components.Schemas["Mode"]
+	if mode.Type != "string" || strings.Join(mode.Enum, ",") != "Mono,Stereo" {
+		t.Errorf("Mode schema = %+v", mode)
+	}
+}
+
+func TestExportUnions(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package test
+
+type Shape interface {
+	Circle | Square
+}
+
+type Drawing struct {
+	Shapes []Shape
+	Hint   *Shape
+}
+
+type Circle struct {
+	R float32
+}
+
+type Square struct {
+	Side int32
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Proto(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"message Shape {\n  oneof value {\n    Circle circle = 1 [json_name = \"Circle\"];\n    Square square = 2 [json_name = \"Square\"];\n  }\n}",
+		`repeated Shape shapes = 1 [json_name = "Shapes"];`,
+		"message Circle {",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = GraphQL(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"union Shape = Circle | Square", "shapes: [Shape!]!", "hint: Shape\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	out, err = SQL(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"hint" JSONB -- Shape as JSON, one of: Circle, Square`) {
+		t.Errorf("missing union column in:\n%s", out)
+	}
+
+	out, err = OpenAPI(s, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				OneOf []struct {
+					Required []string `json:"required"`
+				} `json:"oneOf"`
+				Tags map[string]int `json:"x-ffire-tags"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		t.Fatal(err)
+	}
+	shape := doc.Components.Schemas["Shape"]
+	if len(shape.OneOf) != 2 || shape.OneOf[1].Required[0] != "Square" || shape.Tags["Square"] != 2 {
+		t.Errorf("Shape component = %+v", shape)
+	}
+
+	// GraphQL unions only hold object types
+	union := s.Unions()[0]
+	union.Variants = append(union.Variants, &schema.PrimitiveType{Name: "string"})
+	if _, err := GraphQL(s, Options{}); err == nil || !strings.Contains(err.Error(), "object types") {
+		t.Errorf("expected object type error, got %v", err)
+	}
+}
+
+func TestExportBytes(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package test
+
+type Image struct {
+	Data  bytes
+	Thumb *bytes
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, export := range map[string]func(*schema.Schema, Options) ([]byte, error){
+		"proto":   Proto,
+		"sql":     SQL,
+		"graphql": GraphQL,
+		"openapi": OpenAPI,
+	} {
+		out, err := export(s, Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := map[string]string{
+			"proto":   `bytes data = 1 [json_name = "Data"];`,
+			"sql":     `"data" BYTEA NOT NULL`,
+			"graphql": "data: String!",
+			"openapi": `"format": "byte"`,
+		}[name]
+		if !strings.Contains(string(out), want) {
+			t.Errorf("%s: missing %q in:\n%s", name, want, out)
+		}
+	}
+}
+
+const csvSchema = `package tele
+
+type Mode int8
+
+const (
+	Idle Mode = iota
+	Run
+)
+
+type Point struct {
+	X float32
+	Y float32
+}
+
+type Reading struct {
+	Sensor string
+	Mode   Mode
+	At     *Point
+	Acc    [3]float32
+	Tags   []string
+	Gain   *float64
+}
+
+type Batch []Reading
+`
+
+func TestCSV(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(csvSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := []interface{}{
+		map[string]interface{}{
+			"Sensor": `imu, "a"`, "Mode": "Run", "At": map[string]interface{}{"X": float64(float32(0.1)), "Y": 2.0},
+			"Acc": []interface{}{1.0, 2.5, 3.0}, "Tags": []interface{}{"x", "y"}, "Gain": nil,
+		},
+		map[string]interface{}{
+			"Sensor": "b", "Mode": "Idle", "At": nil,
+			"Acc": []interface{}{0.0, 0.0, 0.0}, "Tags": []interface{}{}, "Gain": 1.25,
+		},
+	}
+
+	out, err := CSV(s, "Batch", value, Options{Flatten: -1})
+	if err != nil {
+		t.Fatalf("CSV failed: %v", err)
+	}
+	want := `Sensor,Mode,At.X,At.Y,Acc.0,Acc.1,Acc.2,Tags,Gain
+"imu, ""a""",Run,0.1,2,1,2.5,3,"[""x"",""y""]",
+b,Idle,,,0,0,0,[],1.25
+`
+	if string(out) != want {
+		t.Errorf("CSV:\n%s\nwant:\n%s", out, want)
+	}
+
+	// Unflattened structs and arrays are JSON cells
+	out, err = CSV(s, "Batch", value, Options{Separator: "_", BOM: true})
+	if err != nil {
+		t.Fatalf("CSV failed: %v", err)
+	}
+	if !strings.HasPrefix(string(out), "\ufeffSensor,Mode,At,Acc,Tags,Gain\n") || !strings.Contains(string(out), `"[1,2.5,3]"`) {
+		t.Errorf("unflattened CSV:\n%s", out)
+	}
+
+	s.Messages = append(s.Messages, schema.MessageType{Name: "Names", TargetType: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "string"}}})
+	if _, err := CSV(s, "Names", []interface{}{"a"}, Options{}); err == nil || !strings.Contains(err.Error(), "array-of-struct") {
+		t.Errorf("expected array-of-struct error, got %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// GraphQL mapping: each struct type becomes an object type with the same
+// name. Fields use their JSON names (lowerCamelCase field name if untagged).
+// Non-optional fields are non-null; arrays become non-null lists of
+// non-null items. Enums become GraphQL enums whose values are the constant
+// names, as in ffire JSON fixtures. Unions become GraphQL unions, which
+// can only hold object types, so unions with primitive or enum variants
+// are rejected. GraphQL has no map type, so schemas with maps are rejected.
+//
+// GraphQL Int is 32-bit, so int64 maps to a custom Int64 scalar by default;
+// bytes map to String, holding base64 as in ffire JSON fixtures.
+// Scalar mappings can be overridden per primitive, e.g. int64=String;
+// every non-built-in scalar used is declared.
+
+var graphqlScalars = map[string]string{
+	"bool": "Boolean", "int8": "Int", "int16": "Int", "int32": "Int", "int64": "Int64",
+	"float32": "Float", "float64": "Float", "string": "String",
+	"bytes": "String",
+}
+
+var graphqlBuiltins = map[string]bool{"Int": true, "Float": true, "String": true, "Boolean": true, "ID": true}
+
+var graphqlNameRE = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// GraphQL renders GraphQL SDL type definitions for every struct type.
+func GraphQL(s *schema.Schema, opts Options) ([]byte, error) {
+	if err := checkTypeMap(opts.TypeMap); err != nil {
+		return nil, err
+	}
+	scalarOf := func(name string) string {
+		if t, ok := opts.TypeMap[name]; ok {
+			return t
+		}
+		return graphqlScalars[name]
+	}
+
+	var types bytes.Buffer
+	var customScalars []string
+	declared := make(map[string]bool)
+
+	var typeRef func(t schema.Type) (string, error)
+	typeRef = func(t schema.Type) (string, error) {
+		var ref string
+		switch typ := t.(type) {
+		case *schema.PrimitiveType:
+			ref = scalarOf(typ.Name)
+			if !graphqlNameRE.MatchString(ref) {
+				return "", fmt.Errorf("invalid GraphQL scalar name %q for %s", ref, typ.Name)
+			}
+			if !graphqlBuiltins[ref] && !declared[ref] {
+				declared[ref] = true
+				customScalars = append(customScalars, ref)
+			}
+		case *schema.StructType, *schema.EnumType, *schema.UnionType:
+			ref = typ.TypeName()
+		case *schema.ArrayType:
+			elem, err := typeRef(typ.ElementType)
+			if err != nil {
+				return "", err
+			}
+			ref = "[" + elem + "]"
+		case *schema.MapType:
+			return "", fmt.Errorf("maps (%s) have no GraphQL equivalent", typ.TypeName())
+		}
+		if !t.IsOptional() {
+			ref += "!"
+		}
+		return ref, nil
+	}
+
+	for _, enum := range enums(s) {
+		fmt.Fprintf(&types, "\nenum %s {\n", enum.Name)
+		for _, v := range enum.Values {
+			if v.Name == "true" || v.Name == "false" || v.Name == "null" {
+				return nil, fmt.Errorf("%s: %s is reserved in GraphQL and cannot be an enum value", enum.Name, v.Name)
+			}
+			fmt.Fprintf(&types, "  %s\n", v.Name)
+		}
+		types.WriteString("}\n")
+	}
+
+	for _, union := range unions(s) {
+		members := make([]string, len(union.Variants))
+		for i, v := range union.Variants {
+			if _, ok := v.(*schema.StructType); !ok {
+				return nil, fmt.Errorf("%s: variant %s is not a struct; GraphQL unions can only hold object types", union.Name, v.TypeName())
+			}
+			members[i] = v.TypeName()
+		}
+		fmt.Fprintf(&types, "\nunion %s = %s\n", union.Name, strings.Join(members, " | "))
+	}
+
+	for _, st := range structs(s) {
+		fmt.Fprintf(&types, "\ntype %s {\n", st.Name)
+		for _, field := range st.Fields {
+			name := fieldName(field)
+			if !graphqlNameRE.MatchString(name) {
+				name = lowerCamel(field.Name)
+			}
+			ref, err := typeRef(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", st.Name, field.Name, err)
+			}
+			fmt.Fprintf(&types, "  %s: %s\n", name, ref)
+		}
+		types.WriteString("}\n")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Code generated by ffire from package %s. DO NOT EDIT.\n", s.Package)
+	if len(customScalars) > 0 {
+		buf.WriteString("\n")
+		for _, name := range customScalars {
+			fmt.Fprintf(&buf, "scalar %s\n", name)
+		}
+	}
+	buf.Write(types.Bytes())
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,256 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// OpenAPI output is an OpenAPI 3.0 document containing only components, for
+// merging into an API description or publishing on a documentation portal:
+//
+//   - components.schemas: the JSON-equivalent shape of every struct type,
+//     enum and root message, keyed by JSON field name (as in ffire JSON
+//     fixtures); enums are strings restricted to the constant names, and
+//     unions are a oneOf of single-property objects keyed by variant name
+//   - components.requestBodies / components.responses: one entry per root
+//     message, offering both the binary ffire encoding and JSON
+//
+// Wire format limits are expressed as constraints (maxItems, integer
+// ranges), so documentation shows what a payload can carry.
+
+// BinaryMediaType is the content type used for ffire-encoded bodies.
+const BinaryMediaType = "application/x-ffire"
+
+// orderedMap marshals keys in insertion order for stable, readable output.
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]interface{})}
+}
+
+func (m *orderedMap) set(key string, value interface{}) *orderedMap {
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+	return m
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// OpenAPI renders an OpenAPI 3.0 components document (JSON).
+func OpenAPI(s *schema.Schema, opts Options) ([]byte, error) {
+	if len(opts.TypeMap) > 0 {
+		return nil, fmt.Errorf("type mapping is not supported for OpenAPI output")
+	}
+
+	schemas := newOrderedMap()
+	for _, st := range structs(s) {
+		schemas.set(st.Name, openAPIStruct(st))
+	}
+	for _, enum := range enums(s) {
+		schemas.set(enum.Name, openAPIEnum(enum))
+	}
+	for _, union := range unions(s) {
+		schemas.set(union.Name, openAPIUnion(union))
+	}
+
+	requestBodies := newOrderedMap()
+	responses := newOrderedMap()
+	// Root types are inferred in no particular order; sort for stable output
+	messages := append([]schema.MessageType(nil), s.Messages...)
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Name < messages[j].Name })
+
+	for _, msg := range messages {
+		if _, isStruct := msg.TargetType.(*schema.StructType); !isStruct {
+			// Non-struct roots (e.g. DeviceList []Device) get a named schema too
+			schemas.set(msg.Name, openAPIType(msg.TargetType))
+		}
+		content := newOrderedMap().
+			set(BinaryMediaType, newOrderedMap().
+				set("schema", newOrderedMap().set("type", "string").set("format", "binary")).
+				set("x-ffire-message", msg.Name)).
+			set("application/json", newOrderedMap().
+				set("schema", openAPIRef(msg.Name)))
+
+		description := msg.Name + " message, ffire binary (" + BinaryMediaType + ") or JSON"
+		requestBodies.set(msg.Name, newOrderedMap().
+			set("description", description).
+			set("required", true).
+			set("content", content))
+		responses.set(msg.Name, newOrderedMap().
+			set("description", description).
+			set("content", content))
+	}
+
+	doc := newOrderedMap().
+		set("openapi", "3.0.3").
+		set("info", newOrderedMap().
+			set("title", s.Package).
+			set("version", "1.0.0").
+			set("description", "Generated by ffire from package "+s.Package+". DO NOT EDIT.")).
+		set("paths", newOrderedMap()).
+		set("components", newOrderedMap().
+			set("schemas", schemas).
+			set("requestBodies", requestBodies).
+			set("responses", responses))
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func openAPIRef(name string) *orderedMap {
+	return newOrderedMap().set("$ref", "#/components/schemas/"+name)
+}
+
+func openAPIStruct(st *schema.StructType) *orderedMap {
+	properties := newOrderedMap()
+	var required []string
+	for _, field := range st.Fields {
+		properties.set(field.JSONName(), openAPIType(field.Type))
+		if !field.Type.IsOptional() {
+			required = append(required, field.JSONName())
+		}
+	}
+
+	obj := newOrderedMap().set("type", "object")
+	if len(required) > 0 {
+		obj.set("required", required)
+	}
+	return obj.set("properties", properties).set("additionalProperties", false)
+}
+
+func openAPIEnum(enum *schema.EnumType) *orderedMap {
+	names := make([]string, len(enum.Values))
+	values := newOrderedMap()
+	for i, v := range enum.Values {
+		names[i] = v.Name
+		values.set(v.Name, v.Value)
+	}
+	return newOrderedMap().
+		set("type", "string").
+		set("enum", names).
+		set("x-ffire-base", enum.Base).
+		set("x-ffire-values", values)
+}
+
+// openAPIUnion describes a union value as ffire JSON writes it: an object
+// with one property naming the variant. x-ffire-tags gives each variant's
+// wire tag.
+func openAPIUnion(union *schema.UnionType) *orderedMap {
+	variants := make([]interface{}, len(union.Variants))
+	tags := newOrderedMap()
+	for i, v := range union.Variants {
+		variants[i] = newOrderedMap().
+			set("type", "object").
+			set("required", []string{v.TypeName()}).
+			set("properties", newOrderedMap().set(v.TypeName(), openAPIType(v))).
+			set("additionalProperties", false)
+		tags.set(v.TypeName(), i+1)
+	}
+	return newOrderedMap().
+		set("oneOf", variants).
+		set("x-ffire-tags", tags)
+}
+
+func openAPIType(t schema.Type) *orderedMap {
+	var result *orderedMap
+
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		result = openAPIPrimitive(typ.Name)
+	case *schema.ArrayType:
+		if typ.Length > 0 {
+			result = newOrderedMap().
+				set("type", "array").
+				set("items", openAPIType(typ.ElementType)).
+				set("minItems", typ.Length).
+				set("maxItems", typ.Length)
+			break
+		}
+		result = newOrderedMap().
+			set("type", "array").
+			set("items", openAPIType(typ.ElementType)).
+			set("maxItems", schema.MaxLengthOf(typ))
+	case *schema.MapType:
+		// JSON object keys are strings; integer and bool keys are written
+		// in decimal and as "true"/"false"
+		result = newOrderedMap().
+			set("type", "object").
+			set("additionalProperties", openAPIType(typ.ValueType)).
+			set("maxProperties", schema.MaxLengthOf(typ))
+	case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		if !typ.IsOptional() {
+			return openAPIRef(typ.TypeName())
+		}
+		// OpenAPI 3.0 ignores siblings of $ref, so nullable refs use allOf
+		return newOrderedMap().
+			set("allOf", []interface{}{openAPIRef(typ.TypeName())}).
+			set("nullable", true)
+	}
+
+	if t.IsOptional() {
+		result.set("nullable", true)
+	}
+	return result
+}
+
+func openAPIPrimitive(name string) *orderedMap {
+	m := newOrderedMap()
+	switch name {
+	case "bool":
+		m.set("type", "boolean")
+	case "int8":
+		m.set("type", "integer").set("format", "int32").set("minimum", math.MinInt8).set("maximum", math.MaxInt8)
+	case "int16":
+		m.set("type", "integer").set("format", "int32").set("minimum", math.MinInt16).set("maximum", math.MaxInt16)
+	case "int32":
+		m.set("type", "integer").set("format", "int32")
+	case "int64":
+		m.set("type", "integer").set("format", "int64")
+	case "float32":
+		m.set("type", "number").set("format", "float")
+	case "float64":
+		m.set("type", "number").set("format", "double")
+	case "bytes":
+		// Base64, as in JSON fixtures
+		m.set("type", "string").set("format", "byte")
+	default:
+		// The length prefix counts bytes, not characters, so no
+		// maxLength is given
+		m.set("type", "string")
+	}
+	return m
+}
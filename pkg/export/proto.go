@@ -0,0 +1,258 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Protocol Buffers mapping: each struct type becomes a proto3 message with
+// the same name. Fields are numbered in declaration order and named in
+// snake_case, with a json_name option wherever protoc's default JSON name
+// would differ from the ffire JSON name, so protojson reads ffire JSON
+// fixtures as they are.
+//
+// Optional primitives use proto3 optional; optional structs, arrays and
+// maps need nothing extra (message fields have presence, absent arrays and
+// maps are empty). Maps become proto maps, which cannot hold arrays, maps
+// or optional primitives as values. Enums become proto enums with the same
+// value names, so protojson reads them by name as ffire JSON writes them;
+// proto3 enums must start at zero, so an enum without a zero value gets
+// an extra NAME_UNSPECIFIED = 0. Unions become a message holding a single
+// oneof, whose field numbers are the ffire tags and whose JSON names are the
+// variant names, so protojson reads {"Circle": {...}} as ffire JSON writes
+// it. A message whose root type is not a struct gets a wrapper message
+// named after it, holding the root in a single field (see ProtoWrapper).
+//
+// Primitive mappings can be overridden, e.g. int32=sint32,int64=fixed64.
+
+var protoScalars = map[string]string{
+	"bool": "bool", "int8": "int32", "int16": "int32", "int32": "int32", "int64": "int64",
+	"float32": "float", "float64": "double", "string": "string",
+	"bytes": "bytes",
+}
+
+var protoScalarNames = map[string]bool{
+	"bool": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "float": true, "double": true, "string": true, "bytes": true,
+}
+
+// protoMapKeys are the scalars proto allows as map keys.
+var protoMapKeys = map[string]bool{
+	"bool": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true,
+	"sfixed32": true, "sfixed64": true, "string": true,
+}
+
+var protoNameRE = regexp.MustCompile(`^[A-Za-z][0-9A-Za-z_]*$`)
+
+// Proto renders a proto3 file with a message for every struct type and a
+// wrapper message for every message type whose root is not a struct.
+// Options.GoPackage, if set, becomes the go_package option.
+func Proto(s *schema.Schema, opts Options) ([]byte, error) {
+	if err := checkTypeMap(opts.TypeMap); err != nil {
+		return nil, err
+	}
+	for name, target := range opts.TypeMap {
+		if !protoScalarNames[target] {
+			return nil, fmt.Errorf("invalid proto scalar type %q for %s", target, name)
+		}
+	}
+	scalarOf := func(name string) string {
+		if t, ok := opts.TypeMap[name]; ok {
+			return t
+		}
+		return protoScalars[name]
+	}
+
+	var typeRef func(t schema.Type) (string, error)
+	typeRef = func(t schema.Type) (string, error) {
+		switch typ := t.(type) {
+		case *schema.PrimitiveType:
+			return scalarOf(typ.Name), nil
+		case *schema.StructType, *schema.EnumType, *schema.UnionType:
+			return typ.TypeName(), nil
+		case *schema.ArrayType:
+			if _, nested := typ.ElementType.(*schema.ArrayType); nested {
+				return "", fmt.Errorf("nested arrays (%s) have no proto equivalent", typ.TypeName())
+			}
+			elem, err := typeRef(typ.ElementType)
+			if err != nil {
+				return "", err
+			}
+			return "repeated " + elem, nil
+		case *schema.MapType:
+			key := scalarOf(typ.KeyType.(*schema.PrimitiveType).Name)
+			if !protoMapKeys[key] {
+				return "", fmt.Errorf("%s is not a valid proto map key type", key)
+			}
+			switch v := typ.ValueType.(type) {
+			case *schema.ArrayType, *schema.MapType:
+				return "", fmt.Errorf("map values of type %s have no proto equivalent", v.TypeName())
+			case *schema.PrimitiveType, *schema.EnumType:
+				if v.IsOptional() {
+					return "", fmt.Errorf("optional map values (%s) have no proto equivalent", v.TypeName())
+				}
+			}
+			value, err := typeRef(typ.ValueType)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("map<%s, %s>", key, value), nil
+		}
+		return "", fmt.Errorf("unsupported type %s", t.TypeName())
+	}
+	field := func(t schema.Type, name, jsonName string, number int) (string, error) {
+		ref, err := typeRef(t)
+		if err != nil {
+			return "", err
+		}
+		switch t.(type) {
+		case *schema.PrimitiveType, *schema.EnumType:
+			if t.IsOptional() {
+				ref = "optional " + ref
+			}
+		}
+		line := fmt.Sprintf("  %s %s = %d", ref, name, number)
+		if jsonName != protoJSONName(name) {
+			line += fmt.Sprintf(" [json_name = %q]", jsonName)
+		}
+		return line + ";\n", nil
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by ffire from package %s. DO NOT EDIT.\n\n", s.Package)
+	buf.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(&buf, "package %s;\n", s.Package)
+	if opts.GoPackage != "" {
+		fmt.Fprintf(&buf, "\noption go_package = %q;\n", opts.GoPackage)
+	}
+
+	declared := make(map[string]bool)
+	for _, msg := range s.Messages {
+		wrap := ProtoWrapper(msg)
+		if wrap == "" {
+			continue
+		}
+		if !protoNameRE.MatchString(msg.Name) {
+			return nil, fmt.Errorf("invalid proto message name %q", msg.Name)
+		}
+		declared[msg.Name] = true
+		line, err := field(msg.TargetType, wrap, wrap, 1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", msg.Name, err)
+		}
+		fmt.Fprintf(&buf, "\nmessage %s {\n%s}\n", msg.Name, line)
+	}
+
+	for _, enum := range enums(s) {
+		body, err := protoEnum(enum)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(body)
+	}
+
+	for _, union := range unions(s) {
+		if declared[union.Name] {
+			return nil, fmt.Errorf("union %s has the same name as a wrapper message", union.Name)
+		}
+		declared[union.Name] = true
+		fmt.Fprintf(&buf, "\nmessage %s {\n  oneof value {\n", union.Name)
+		for i, v := range union.Variants {
+			line, err := field(v, snakeCase(v.TypeName()), v.TypeName(), i+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", union.Name, v.TypeName(), err)
+			}
+			buf.WriteString("  " + line)
+		}
+		buf.WriteString("  }\n}\n")
+	}
+
+	for _, st := range structs(s) {
+		if declared[st.Name] {
+			return nil, fmt.Errorf("struct %s has the same name as a wrapper message or union", st.Name)
+		}
+		fmt.Fprintf(&buf, "\nmessage %s {\n", st.Name)
+		for i, f := range st.Fields {
+			name := snakeCase(f.Name)
+			if !protoNameRE.MatchString(name) {
+				return nil, fmt.Errorf("%s.%s: invalid proto field name %q", st.Name, f.Name, name)
+			}
+			line, err := field(f.Type, name, f.JSONName(), i+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", st.Name, f.Name, err)
+			}
+			buf.WriteString(line)
+		}
+		buf.WriteString("}\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// protoEnum declares enum with the zero value first, as proto3 requires.
+func protoEnum(enum *schema.EnumType) (string, error) {
+	var lines []string
+	hasZero := false
+	for _, v := range enum.Values {
+		if v.Value < math.MinInt32 || v.Value > math.MaxInt32 {
+			return "", fmt.Errorf("%s.%s: value %d does not fit a proto enum (int32)", enum.Name, v.Name, v.Value)
+		}
+		line := fmt.Sprintf("  %s = %d;\n", v.Name, v.Value)
+		if v.Value == 0 {
+			hasZero = true
+			lines = append([]string{line}, lines...)
+		} else {
+			lines = append(lines, line)
+		}
+	}
+	if !hasZero {
+		unspecified := strings.ToUpper(snakeCase(enum.Name)) + "_UNSPECIFIED"
+		if _, taken := enum.ValueOf(unspecified); taken {
+			return "", fmt.Errorf("%s has no zero value and %s is taken", enum.Name, unspecified)
+		}
+		lines = append([]string{fmt.Sprintf("  %s = 0;\n", unspecified)}, lines...)
+	}
+	return fmt.Sprintf("\nenum %s {\n%s}\n", enum.Name, strings.Join(lines, "")), nil
+}
+
+// ProtoWrapper returns the field of the wrapper message Proto declares for
+// msg: "values" for an array root, "value" for a primitive root, or "" for
+// a struct root, which is its own message. JSON for a wrapped message is
+// the ffire JSON nested under that field, e.g. {"values": [1, 2, 3]}.
+func ProtoWrapper(msg schema.MessageType) string {
+	switch t := msg.TargetType.(type) {
+	case *schema.ArrayType:
+		return "values"
+	case *schema.StructType:
+		if t.Name == msg.Name && !t.Optional {
+			return ""
+		}
+	}
+	return "value"
+}
+
+// protoJSONName is the JSON name protoc gives a field by default: the
+// field name with underscores removed and the letter after each one
+// capitalized.
+func protoJSONName(name string) string {
+	var sb strings.Builder
+	upper := false
+	for _, r := range name {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			sb.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
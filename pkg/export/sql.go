@@ -0,0 +1,268 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// SQL mapping: each struct type becomes a table with an "id" primary key
+// (an existing non-optional ID field is used as the key; otherwise a
+// surrogate key is added).
+//
+//	primitive field   -> column (optional fields are NULL-able)
+//	enum field        -> column of the base type, values listed in a comment
+//	struct field      -> <field>_id foreign key to the struct's table
+//	[]struct field    -> <parent>_id / <parent>_pos columns on the child table
+//	other arrays      -> JSON column
+//	maps              -> JSON column
+//	unions            -> JSON column holding {"Variant": value}
+//
+// Type mapping keys are the primitive names plus "json" (array, map and union columns) and
+// "id" (surrogate key type).
+
+var sqlDialects = map[string]map[string]string{
+	"postgres": {
+		"bool": "BOOLEAN", "int8": "SMALLINT", "int16": "SMALLINT", "int32": "INTEGER", "int64": "BIGINT",
+		"float32": "REAL", "float64": "DOUBLE PRECISION", "string": "TEXT", "bytes": "BYTEA", "json": "JSONB", "id": "BIGINT",
+	},
+	"mysql": {
+		"bool": "BOOLEAN", "int8": "TINYINT", "int16": "SMALLINT", "int32": "INT", "int64": "BIGINT",
+		"float32": "FLOAT", "float64": "DOUBLE", "string": "TEXT", "bytes": "BLOB", "json": "JSON", "id": "BIGINT",
+	},
+	"sqlite": {
+		"bool": "INTEGER", "int8": "INTEGER", "int16": "INTEGER", "int32": "INTEGER", "int64": "INTEGER",
+		"float32": "REAL", "float64": "REAL", "string": "TEXT", "bytes": "BLOB", "json": "TEXT", "id": "INTEGER",
+	},
+}
+
+type sqlColumn struct {
+	name       string
+	typ        string
+	notNull    bool
+	primaryKey bool
+	references string // Referenced struct type, if a foreign key
+	comment    string
+}
+
+type sqlTable struct {
+	name    string
+	source  string // Struct type name
+	columns []sqlColumn
+	deps    []string // Tables referenced by foreign keys
+}
+
+// SQL renders CREATE TABLE statements for every struct type in the schema.
+func SQL(s *schema.Schema, opts Options) ([]byte, error) {
+	dialect := opts.Dialect
+	if dialect == "" {
+		dialect = "postgres"
+	}
+	base, ok := sqlDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("unknown SQL dialect %q (supported: postgres, mysql, sqlite)", dialect)
+	}
+	if err := checkTypeMap(opts.TypeMap, "json", "id"); err != nil {
+		return nil, err
+	}
+	typeOf := func(key string) string {
+		if t, ok := opts.TypeMap[key]; ok {
+			return t
+		}
+		return base[key]
+	}
+	quote := func(name string) string {
+		if dialect == "mysql" {
+			return "`" + name + "`"
+		}
+		return `"` + name + `"`
+	}
+
+	structTypes := structs(s)
+	tables := make(map[string]*sqlTable, len(structTypes))
+	var order []string
+
+	for _, st := range structTypes {
+		t := &sqlTable{name: snakeCase(st.Name), source: st.Name}
+		tables[st.Name] = t
+		order = append(order, st.Name)
+
+		hasKey := false
+		for _, field := range st.Fields {
+			if prim, ok := field.Type.(*schema.PrimitiveType); ok && snakeCase(field.Name) == "id" && !prim.Optional {
+				hasKey = true
+			}
+		}
+		if !hasKey {
+			t.columns = append(t.columns, sqlColumn{name: "id", typ: typeOf("id"), notNull: true, primaryKey: true})
+		}
+	}
+
+	// Child tables of []struct fields, collected first so link column
+	// names can be disambiguated when a parent has several such fields.
+	type link struct {
+		parent, field, child string
+	}
+	var links []link
+
+	for _, st := range structTypes {
+		t := tables[st.Name]
+		for _, field := range st.Fields {
+			col := sqlColumn{name: snakeCase(field.Name), notNull: !field.Type.IsOptional()}
+
+			switch ft := field.Type.(type) {
+			case *schema.PrimitiveType:
+				col.typ = typeOf(ft.Name)
+				if col.name == "id" && col.notNull {
+					col.primaryKey = true
+				}
+			case *schema.EnumType:
+				col.typ = typeOf(ft.Base)
+				col.comment = enumComment(ft)
+			case *schema.StructType:
+				col.name += "_id"
+				col.references = ft.Name
+				t.deps = append(t.deps, ft.Name)
+			case *schema.ArrayType:
+				if elem, ok := ft.ElementType.(*schema.StructType); ok {
+					links = append(links, link{parent: st.Name, field: field.Name, child: elem.Name})
+					continue
+				}
+				col.typ = typeOf("json")
+				col.comment = arrayComment(ft)
+			case *schema.MapType:
+				col.typ = typeOf("json")
+				col.comment = ft.TypeName() + " as JSON object"
+			case *schema.UnionType:
+				col.typ = typeOf("json")
+				col.comment = unionComment(ft)
+			}
+			t.columns = append(t.columns, col)
+		}
+	}
+
+	for _, l := range links {
+		parent, child := tables[l.parent], tables[l.child]
+		prefix := parent.name
+		for _, other := range links {
+			if other != l && other.parent == l.parent && other.child == l.child {
+				prefix = parent.name + "_" + snakeCase(l.field)
+				break
+			}
+		}
+		child.columns = append(child.columns,
+			sqlColumn{name: prefix + "_id", references: l.parent, comment: fmt.Sprintf("%s.%s", l.parent, l.field)},
+			sqlColumn{name: prefix + "_pos", typ: typeOf("int32"), comment: "position within " + l.parent + "." + l.field},
+		)
+		child.deps = append(child.deps, l.parent)
+	}
+
+	// Foreign keys take the type of the referenced primary key
+	for _, t := range tables {
+		if err := checkDuplicateColumns(t); err != nil {
+			return nil, err
+		}
+		for i, col := range t.columns {
+			if col.references != "" {
+				t.columns[i].typ = primaryKey(tables[col.references]).typ
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "-- Code generated by ffire from package %s. DO NOT EDIT.\n", s.Package)
+	fmt.Fprintf(&buf, "-- Dialect: %s\n", dialect)
+
+	for _, name := range sqlTableOrder(order, tables) {
+		t := tables[name]
+		fmt.Fprintf(&buf, "\n-- %s\n", t.source)
+		fmt.Fprintf(&buf, "CREATE TABLE %s (\n", quote(t.name))
+		for i, col := range t.columns {
+			fmt.Fprintf(&buf, "    %s %s", quote(col.name), col.typ)
+			if col.primaryKey {
+				buf.WriteString(" PRIMARY KEY")
+			} else if col.notNull {
+				buf.WriteString(" NOT NULL")
+			}
+			if col.references != "" {
+				ref := tables[col.references]
+				fmt.Fprintf(&buf, " REFERENCES %s (%s)", quote(ref.name), quote(primaryKey(ref).name))
+			}
+			if i < len(t.columns)-1 {
+				buf.WriteString(",")
+			}
+			if col.comment != "" {
+				fmt.Fprintf(&buf, " -- %s", col.comment)
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString(");\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func enumComment(t *schema.EnumType) string {
+	values := make([]string, len(t.Values))
+	for i, v := range t.Values {
+		values[i] = fmt.Sprintf("%d=%s", v.Value, v.Name)
+	}
+	return t.Name + ": " + strings.Join(values, ", ")
+}
+
+func unionComment(t *schema.UnionType) string {
+	names := make([]string, len(t.Variants))
+	for i, v := range t.Variants {
+		names[i] = v.TypeName()
+	}
+	return t.Name + " as JSON, one of: " + strings.Join(names, ", ")
+}
+
+func arrayComment(t *schema.ArrayType) string {
+	return t.TypeName() + " as JSON"
+}
+
+func primaryKey(t *sqlTable) sqlColumn {
+	for _, col := range t.columns {
+		if col.primaryKey {
+			return col
+		}
+	}
+	return sqlColumn{}
+}
+
+func checkDuplicateColumns(t *sqlTable) error {
+	seen := make(map[string]bool, len(t.columns))
+	for _, col := range t.columns {
+		if seen[col.name] {
+			return fmt.Errorf("table %s: duplicate column %s", t.name, col.name)
+		}
+		seen[col.name] = true
+	}
+	return nil
+}
+
+// sqlTableOrder orders tables so referenced tables are created first,
+// otherwise keeping schema order.
+func sqlTableOrder(order []string, tables map[string]*sqlTable) []string {
+	var result []string
+	state := make(map[string]int) // 1 = visiting, 2 = done
+	var visit func(name string)
+	visit = func(name string) {
+		if state[name] != 0 {
+			return // Done, or a cycle (ffire rejects recursive types)
+		}
+		state[name] = 1
+		for _, dep := range tables[name].deps {
+			visit(dep)
+		}
+		state[name] = 2
+		result = append(result, name)
+	}
+	for _, name := range order {
+		visit(name)
+	}
+	return result
+}
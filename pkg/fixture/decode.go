@@ -0,0 +1,433 @@
+package fixture
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/shaban/ffire/pkg/errors"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Decode converts binary wire format back to a JSON-compatible value tree
+// according to schema. It is the inverse of Convert: structs become
+// map[string]interface{} keyed by JSON field name, arrays become
+// []interface{}, maps become map[string]interface{} keyed by the JSON
+// spelling of each key, unions become an object with one key naming the
+// variant, enums become the name of their constant, bytes become
+// standard base64, absent
+// optionals become nil, integers become int64 and floats become float64.
+func Decode(s *schema.Schema, messageName string, data []byte) (interface{}, error) {
+	return decode(s, messageName, &decoder{data: data})
+}
+
+// ValidateLayout checks data as Decode does, except that strings need not
+// be valid UTF-8, for tools that show invalid text instead of rejecting it.
+func ValidateLayout(s *schema.Schema, messageName string, data []byte) error {
+	_, err := decode(s, messageName, &decoder{data: data, anyText: true})
+	return err
+}
+
+func decode(s *schema.Schema, messageName string, d *decoder) (interface{}, error) {
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+
+	if messageType == nil {
+		return nil, fmt.Errorf("message type %s not found in schema", messageName)
+	}
+
+	value, err := d.decodeValue(messageType.TargetType)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(d.data) {
+		return nil, &DecodeError{Offset: d.pos, Code: errors.DecodeTrailingData, Message: fmt.Sprintf("%d trailing bytes after message", len(d.data)-d.pos)}
+	}
+
+	return value, nil
+}
+
+// DecodeError reports where in the payload decoding failed, and why.
+type DecodeError struct {
+	Offset  int               // Byte offset where the failure was detected
+	Path    string            // Field path (e.g., "Devices[2].Name"), empty for the root
+	Code    errors.DecodeCode // Kind of failure, as generated decoders report it
+	Message string
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("offset %d: %s", e.Offset, e.Message)
+	}
+	return fmt.Sprintf("offset %d: %s: %s", e.Offset, e.Path, e.Message)
+}
+
+type decoder struct {
+	data    []byte
+	pos     int
+	path    string
+	anyText bool // skip the UTF-8 check of strings
+}
+
+func (d *decoder) fail(code errors.DecodeCode, format string, args ...interface{}) error {
+	return &DecodeError{Offset: d.pos, Path: d.path, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (d *decoder) need(n int, what string) error {
+	if d.pos+n > len(d.data) {
+		return d.fail(errors.DecodeTruncated, "unexpected end of data reading %s (need %d bytes, have %d)", what, n, len(d.data)-d.pos)
+	}
+	return nil
+}
+
+// needCount is need for the n bytes a length prefix or count promises:
+// running out of data there is an overflow of the prefix.
+func (d *decoder) needCount(n int, what string) error {
+	if d.pos+n > len(d.data) {
+		return d.fail(errors.DecodeOverflow, "%s needs %d bytes, have %d", what, n, len(d.data)-d.pos)
+	}
+	return nil
+}
+
+// readLength reads the length prefix of typ: a uint16, or a uint32 if
+// typ is @large.
+func (d *decoder) readLength(typ schema.Type, what string) (int, error) {
+	if schema.IsLarge(typ) {
+		if err := d.need(4, what); err != nil {
+			return 0, err
+		}
+		length := int(binary.LittleEndian.Uint32(d.data[d.pos:]))
+		d.pos += 4
+		return length, nil
+	}
+	if err := d.need(2, what); err != nil {
+		return 0, err
+	}
+	length := int(binary.LittleEndian.Uint16(d.data[d.pos:]))
+	d.pos += 2
+	return length, nil
+}
+
+// decodeValue decodes a single value, including its optional presence flag.
+func (d *decoder) decodeValue(typ schema.Type) (interface{}, error) {
+	if typ.IsOptional() {
+		if err := d.need(1, "optional flag"); err != nil {
+			return nil, err
+		}
+		present := d.data[d.pos]
+		if present > 0x01 {
+			return nil, d.fail(errors.DecodeBadPresence, "invalid optional flag 0x%02x", present)
+		}
+		d.pos++
+		if present == 0x00 {
+			return nil, nil
+		}
+	}
+	return d.decodeContent(typ)
+}
+
+// decodeContent decodes a value whose presence, if it is optional, has
+// already been read.
+func (d *decoder) decodeContent(typ schema.Type) (interface{}, error) {
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		return d.decodePrimitive(t)
+	case *schema.StructType:
+		return d.decodeStruct(t)
+	case *schema.ArrayType:
+		return d.decodeArray(t)
+	case *schema.MapType:
+		return d.decodeMap(t)
+	case *schema.EnumType:
+		start := d.pos
+		v, err := d.decodePrimitive(t.BaseType())
+		if err != nil {
+			return nil, err
+		}
+		name, ok := t.Lookup(v.(int64))
+		if !ok {
+			d.pos = start
+			return nil, d.fail(errors.DecodeInvalidValue, "invalid %s value %d", t.Name, v)
+		}
+		return name, nil
+	case *schema.UnionType:
+		return d.decodeUnion(t)
+	default:
+		return nil, fmt.Errorf("unknown type: %T", typ)
+	}
+}
+
+func (d *decoder) decodePrimitive(typ *schema.PrimitiveType) (interface{}, error) {
+	switch typ.Name {
+	case "bool":
+		if err := d.need(1, "bool"); err != nil {
+			return nil, err
+		}
+		v := d.data[d.pos]
+		if v > 0x01 {
+			return nil, d.fail(errors.DecodeInvalidValue, "invalid bool value 0x%02x", v)
+		}
+		d.pos++
+		return v == 0x01, nil
+
+	case "int8":
+		if err := d.need(1, "int8"); err != nil {
+			return nil, err
+		}
+		v := int8(d.data[d.pos])
+		d.pos++
+		return int64(v), nil
+
+	case "int16":
+		if err := d.need(2, "int16"); err != nil {
+			return nil, err
+		}
+		v := int16(binary.LittleEndian.Uint16(d.data[d.pos:]))
+		d.pos += 2
+		return int64(v), nil
+
+	case "int32":
+		if err := d.need(4, "int32"); err != nil {
+			return nil, err
+		}
+		v := int32(binary.LittleEndian.Uint32(d.data[d.pos:]))
+		d.pos += 4
+		return int64(v), nil
+
+	case "int64":
+		if err := d.need(8, "int64"); err != nil {
+			return nil, err
+		}
+		v := int64(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+		return v, nil
+
+	case "float32":
+		if err := d.need(4, "float32"); err != nil {
+			return nil, err
+		}
+		v := math.Float32frombits(binary.LittleEndian.Uint32(d.data[d.pos:]))
+		d.pos += 4
+		return float64(v), nil
+
+	case "float64":
+		if err := d.need(8, "float64"); err != nil {
+			return nil, err
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+		return v, nil
+
+	case "string":
+		length, err := d.readLength(typ, "string length")
+		if err != nil {
+			return nil, err
+		}
+		if err := d.needCount(length, "string data"); err != nil {
+			return nil, err
+		}
+		if !d.anyText && !utf8.Valid(d.data[d.pos:d.pos+length]) {
+			return nil, d.fail(errors.DecodeInvalidUtf8, "invalid UTF-8 in string")
+		}
+		v := string(d.data[d.pos : d.pos+length])
+		d.pos += length
+		return v, nil
+
+	case "bytes":
+		length, err := d.readLength(typ, "bytes length")
+		if err != nil {
+			return nil, err
+		}
+		if err := d.needCount(length, "bytes data"); err != nil {
+			return nil, err
+		}
+		v := base64.StdEncoding.EncodeToString(d.data[d.pos : d.pos+length])
+		d.pos += length
+		return v, nil
+
+	default:
+		return nil, fmt.Errorf("unknown primitive type: %s", typ.Name)
+	}
+}
+
+func (d *decoder) decodeStruct(typ *schema.StructType) (interface{}, error) {
+	if !typ.Framed {
+		return d.decodeFields(typ)
+	}
+
+	if err := d.need(schema.FrameSize, "struct frame"); err != nil {
+		return nil, err
+	}
+	length := int(binary.LittleEndian.Uint32(d.data[d.pos:]))
+	d.pos += schema.FrameSize
+	if err := d.needCount(length, "struct frame"); err != nil {
+		return nil, err
+	}
+	// The fields may not read past the frame
+	data := d.data
+	end := d.pos + length
+	d.data = data[:end]
+	defer func() { d.data = data }()
+
+	obj, err := d.decodeFields(typ)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != end {
+		return nil, d.fail(errors.DecodeTrailingData, "%d bytes left in the frame of %s", end-d.pos, typ.Name)
+	}
+	return obj, nil
+}
+
+// decodeFields decodes the fields of a struct, after its frame if any.
+func (d *decoder) decodeFields(typ *schema.StructType) (interface{}, error) {
+	obj := make(map[string]interface{}, len(typ.Fields))
+	parent := d.path
+	defer func() { d.path = parent }()
+
+	bitmap, err := d.readBitmap(typ)
+	if err != nil {
+		return nil, err
+	}
+	bit := 0
+	for _, field := range typ.Fields {
+		if parent == "" {
+			d.path = field.Name
+		} else {
+			d.path = parent + "." + field.Name
+		}
+		var value interface{}
+		var err error
+		switch {
+		case bitmap == nil || !field.Type.IsOptional():
+			value, err = d.decodeValue(field.Type)
+		case bitmap[bit/8]&(1<<(bit%8)) != 0:
+			value, err = d.decodeContent(field.Type)
+		}
+		if field.Type.IsOptional() {
+			bit++
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj[field.JSONName()] = value
+	}
+
+	return obj, nil
+}
+
+// readBitmap reads the presence bitmap of a @bitmap struct, or returns
+// nil if typ has none.
+func (d *decoder) readBitmap(typ *schema.StructType) ([]byte, error) {
+	if !typ.Bitmap {
+		return nil, nil
+	}
+	n := schema.BitmapSize(typ)
+	if err := d.need(n, "presence bitmap"); err != nil {
+		return nil, err
+	}
+	bitmap := d.data[d.pos : d.pos+n]
+	if unused := n*8 - schema.OptionalFields(typ); unused > 0 && bitmap[n-1]>>(8-unused) != 0 {
+		return nil, d.fail(errors.DecodeBadPresence, "invalid presence bitmap 0x%02x", bitmap[n-1])
+	}
+	d.pos += n
+	return bitmap, nil
+}
+
+func (d *decoder) decodeArray(typ *schema.ArrayType) (interface{}, error) {
+	length := typ.Length
+	if length == 0 {
+		var err error
+		if length, err = d.readLength(typ, "array length"); err != nil {
+			return nil, err
+		}
+		// Every element takes at least a byte
+		if err := d.needCount(length, "array elements"); err != nil {
+			return nil, err
+		}
+	}
+
+	parent := d.path
+	defer func() { d.path = parent }()
+
+	arr := make([]interface{}, length)
+	for i := range arr {
+		d.path = fmt.Sprintf("%s[%d]", parent, i)
+		value, err := d.decodeValue(typ.ElementType)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = value
+	}
+
+	return arr, nil
+}
+
+func (d *decoder) decodeMap(typ *schema.MapType) (interface{}, error) {
+	length, err := d.readLength(typ, "map length")
+	if err != nil {
+		return nil, err
+	}
+	if err := d.needCount(length, "map entries"); err != nil {
+		return nil, err
+	}
+
+	key, ok := typ.KeyType.(*schema.PrimitiveType)
+	if !ok {
+		return nil, fmt.Errorf("invalid map key type %s", typ.KeyType.TypeName())
+	}
+
+	parent := d.path
+	defer func() { d.path = parent }()
+
+	obj := make(map[string]interface{}, length)
+	for i := 0; i < length; i++ {
+		d.path = fmt.Sprintf("%s[key %d]", parent, i)
+		k, err := d.decodePrimitive(key)
+		if err != nil {
+			return nil, err
+		}
+		name := FormatMapKey(k)
+		d.path = fmt.Sprintf("%s[%q]", parent, name)
+		value, err := d.decodeValue(typ.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		obj[name] = value
+	}
+
+	return obj, nil
+}
+
+func (d *decoder) decodeUnion(typ *schema.UnionType) (interface{}, error) {
+	if err := d.need(1, typ.Name+" tag"); err != nil {
+		return nil, err
+	}
+	tag := int(d.data[d.pos])
+	if tag == 0 || tag > len(typ.Variants) {
+		return nil, d.fail(errors.DecodeInvalidValue, "invalid %s tag %d", typ.Name, tag)
+	}
+	d.pos++
+
+	variant := typ.Variants[tag-1]
+	parent := d.path
+	defer func() { d.path = parent }()
+	d.path = parent + "." + variant.TypeName()
+	if parent == "" {
+		d.path = variant.TypeName()
+	}
+
+	value, err := d.decodeValue(variant)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{variant.TypeName(): value}, nil
+}
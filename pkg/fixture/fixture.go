@@ -3,8 +3,12 @@ package fixture
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/shaban/ffire/internal/wire"
 	"github.com/shaban/ffire/pkg/schema"
@@ -40,6 +44,29 @@ func Convert(s *schema.Schema, messageName string, jsonData []byte) ([]byte, err
 	return buf.Bytes(), nil
 }
 
+// Encode converts an in-memory value tree (as produced by Decode or
+// json.Unmarshal) to binary wire format according to schema.
+func Encode(s *schema.Schema, messageName string, value interface{}) ([]byte, error) {
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+
+	if messageType == nil {
+		return nil, fmt.Errorf("message type %s not found in schema", messageName)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := encodeValue(buf, s, messageType.TargetType, value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
 // encodeValue encodes a JSON value to binary format.
 func encodeValue(buf *bytes.Buffer, s *schema.Schema, typ schema.Type, value interface{}) error {
 	// Handle optional types
@@ -52,7 +79,12 @@ func encodeValue(buf *bytes.Buffer, s *schema.Schema, typ schema.Type, value int
 		// Write present flag = true
 		wire.EncodeBool(buf, true)
 	}
+	return encodeContent(buf, s, typ, value)
+}
 
+// encodeContent encodes a JSON value to binary format without the presence
+// flag of an optional type.
+func encodeContent(buf *bytes.Buffer, s *schema.Schema, typ schema.Type, value interface{}) error {
 	switch t := typ.(type) {
 	case *schema.PrimitiveType:
 		return encodePrimitive(buf, t, value)
@@ -63,11 +95,69 @@ func encodeValue(buf *bytes.Buffer, s *schema.Schema, typ schema.Type, value int
 	case *schema.ArrayType:
 		return encodeArray(buf, s, t, value)
 
+	case *schema.MapType:
+		return encodeMap(buf, s, t, value)
+
+	case *schema.EnumType:
+		v, err := EnumValue(t, value)
+		if err != nil {
+			return err
+		}
+		return encodePrimitive(buf, t.BaseType(), v)
+
+	case *schema.UnionType:
+		tag, variant, inner, err := UnionValue(t, value)
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(byte(tag))
+		if err := encodeValue(buf, s, variant, inner); err != nil {
+			return fmt.Errorf("%s: %w", variant.TypeName(), err)
+		}
+		return nil
+
 	default:
 		return fmt.Errorf("unknown type: %T", typ)
 	}
 }
 
+// EnumValue returns the integer for an enum value in a value tree: a
+// constant name, as Decode produces, or the number it stands for.
+func EnumValue(typ *schema.EnumType, value interface{}) (int64, error) {
+	if name, ok := value.(string); ok {
+		v, ok := typ.ValueOf(name)
+		if !ok {
+			return 0, fmt.Errorf("%q is not a %s", name, typ.Name)
+		}
+		return v, nil
+	}
+	v, ok := toInt64(value)
+	if !ok {
+		return 0, fmt.Errorf("expected %s name, got %T", typ.Name, value)
+	}
+	if _, ok := typ.Lookup(v); !ok {
+		return 0, fmt.Errorf("%d is not a %s value", v, typ.Name)
+	}
+	return v, nil
+}
+
+// UnionValue unpacks a union value in a value tree, an object with a single
+// key naming the variant, into the variant's tag, type and value.
+func UnionValue(typ *schema.UnionType, value interface{}) (int, schema.Type, interface{}, error) {
+	obj, ok := value.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return 0, nil, nil, fmt.Errorf("expected object with one key naming a %s variant, got %v", typ.Name, value)
+	}
+	for name, inner := range obj {
+		variant, tag, ok := typ.Variant(name)
+		if !ok {
+			return 0, nil, nil, fmt.Errorf("%q is not a %s variant", name, typ.Name)
+		}
+		return tag, variant, inner, nil
+	}
+	return 0, nil, nil, nil
+}
+
 // encodePrimitive encodes a primitive value.
 func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interface{}) error {
 	if value == nil && typ.Optional {
@@ -84,7 +174,7 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		return nil
 
 	case "int8":
-		num, ok := value.(float64)
+		num, ok := toInt64(value)
 		if !ok {
 			return fmt.Errorf("expected number, got %T", value)
 		}
@@ -92,7 +182,7 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		return nil
 
 	case "int16":
-		num, ok := value.(float64)
+		num, ok := toInt64(value)
 		if !ok {
 			return fmt.Errorf("expected number, got %T", value)
 		}
@@ -100,7 +190,7 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		return nil
 
 	case "int32":
-		num, ok := value.(float64)
+		num, ok := toInt64(value)
 		if !ok {
 			return fmt.Errorf("expected number, got %T", value)
 		}
@@ -108,7 +198,7 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		return nil
 
 	case "int64":
-		num, ok := value.(float64)
+		num, ok := toInt64(value)
 		if !ok {
 			return fmt.Errorf("expected number, got %T", value)
 		}
@@ -116,7 +206,7 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		return nil
 
 	case "float32":
-		num, ok := value.(float64)
+		num, ok := toFloat64(value)
 		if !ok {
 			return fmt.Errorf("expected number, got %T", value)
 		}
@@ -124,7 +214,7 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		return nil
 
 	case "float64":
-		num, ok := value.(float64)
+		num, ok := toFloat64(value)
 		if !ok {
 			return fmt.Errorf("expected number, got %T", value)
 		}
@@ -136,7 +226,27 @@ func encodePrimitive(buf *bytes.Buffer, typ *schema.PrimitiveType, value interfa
 		if !ok {
 			return fmt.Errorf("expected string, got %T", value)
 		}
-		wire.EncodeString(buf, str)
+		if typ.Large {
+			wire.EncodeLargeString(buf, str)
+		} else {
+			wire.EncodeString(buf, str)
+		}
+		return nil
+
+	case "bytes":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected base64 string, got %T", value)
+		}
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return fmt.Errorf("invalid base64: %w", err)
+		}
+		if typ.Large {
+			wire.EncodeLargeBytes(buf, data)
+		} else {
+			wire.EncodeBytes(buf, data)
+		}
 		return nil
 
 	default:
@@ -155,6 +265,27 @@ func encodeStruct(buf *bytes.Buffer, s *schema.Schema, typ *schema.StructType, v
 		return fmt.Errorf("expected object, got %T", value)
 	}
 
+	if !typ.Framed {
+		return encodeFields(buf, s, typ, obj)
+	}
+	// The frame holds the length of the fields, so they are encoded first
+	var fields bytes.Buffer
+	if err := encodeFields(&fields, s, typ, obj); err != nil {
+		return err
+	}
+	var frame [schema.FrameSize]byte
+	binary.LittleEndian.PutUint32(frame[:], uint32(fields.Len()))
+	buf.Write(frame[:])
+	buf.Write(fields.Bytes())
+	return nil
+}
+
+// encodeFields encodes the fields of a struct, after its frame if any.
+func encodeFields(buf *bytes.Buffer, s *schema.Schema, typ *schema.StructType, obj map[string]interface{}) error {
+	if typ.Bitmap {
+		return encodeBitmapStruct(buf, s, typ, obj)
+	}
+
 	// Encode each field in order
 	for _, field := range typ.Fields {
 		jsonName := field.JSONName()
@@ -176,6 +307,38 @@ func encodeStruct(buf *bytes.Buffer, s *schema.Schema, typ *schema.StructType, v
 	return nil
 }
 
+// encodeBitmapStruct encodes a @bitmap struct: its presence bitmap, then
+// its fields with the absent optional ones left out.
+func encodeBitmapStruct(buf *bytes.Buffer, s *schema.Schema, typ *schema.StructType, obj map[string]interface{}) error {
+	bitmap := make([]byte, schema.BitmapSize(typ))
+	bit := 0
+	for _, field := range typ.Fields {
+		fieldValue, exists := obj[field.JSONName()]
+		if !field.Type.IsOptional() {
+			if !exists {
+				return fmt.Errorf("required field %s missing", field.Name)
+			}
+			continue
+		}
+		if fieldValue != nil {
+			bitmap[bit/8] |= 1 << (bit % 8)
+		}
+		bit++
+	}
+	buf.Write(bitmap)
+
+	for _, field := range typ.Fields {
+		fieldValue := obj[field.JSONName()]
+		if field.Type.IsOptional() && fieldValue == nil {
+			continue
+		}
+		if err := encodeContent(buf, s, field.Type, fieldValue); err != nil {
+			return fmt.Errorf("encode field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
 // encodeArray encodes an array value.
 func encodeArray(buf *bytes.Buffer, s *schema.Schema, typ *schema.ArrayType, value interface{}) error {
 	if value == nil && typ.Optional {
@@ -187,8 +350,17 @@ func encodeArray(buf *bytes.Buffer, s *schema.Schema, typ *schema.ArrayType, val
 		return fmt.Errorf("expected array, got %T", value)
 	}
 
-	// Write array length (uint16 - validator ensures < 65536)
-	wire.EncodeArrayHeader(buf, uint16(len(arr)))
+	// Write array length (uint16, or uint32 if @large - validator ensures
+	// it fits). Fixed-size arrays have no length on the wire.
+	if typ.Length > 0 {
+		if len(arr) != typ.Length {
+			return fmt.Errorf("expected %d elements, got %d", typ.Length, len(arr))
+		}
+	} else if typ.Large {
+		wire.EncodeLargeArrayHeader(buf, uint32(len(arr)))
+	} else {
+		wire.EncodeArrayHeader(buf, uint16(len(arr)))
+	}
 
 	// Write each element
 	for i, elem := range arr {
@@ -199,3 +371,143 @@ func encodeArray(buf *bytes.Buffer, s *schema.Schema, typ *schema.ArrayType, val
 
 	return nil
 }
+
+// encodeMap encodes a map value: a JSON object keyed by the JSON spelling
+// of each key. Entries are written in ascending key order so the encoding
+// is deterministic.
+func encodeMap(buf *bytes.Buffer, s *schema.Schema, typ *schema.MapType, value interface{}) error {
+	if value == nil && typ.Optional {
+		return nil // Already handled by encodeValue
+	}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected object, got %T", value)
+	}
+	key, ok := typ.KeyType.(*schema.PrimitiveType)
+	if !ok {
+		return fmt.Errorf("invalid map key type %s", typ.KeyType.TypeName())
+	}
+
+	keys, err := SortMapKeys(key, obj)
+	if err != nil {
+		return err
+	}
+
+	// Write entry count (uint16, or uint32 if @large - validator ensures
+	// it fits)
+	if typ.Large {
+		wire.EncodeLargeArrayHeader(buf, uint32(len(keys)))
+	} else {
+		wire.EncodeArrayHeader(buf, uint16(len(keys)))
+	}
+
+	for _, k := range keys {
+		kv, _ := ParseMapKey(key, k)
+		if err := encodePrimitive(buf, key, kv); err != nil {
+			return fmt.Errorf("encode key %q: %w", k, err)
+		}
+		if err := encodeValue(buf, s, typ.ValueType, obj[k]); err != nil {
+			return fmt.Errorf("encode value %q: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// ParseMapKey parses the JSON spelling of a map key: the string itself,
+// a decimal integer, or "true"/"false". Integer keys become int64.
+func ParseMapKey(key *schema.PrimitiveType, k string) (interface{}, error) {
+	switch key.Name {
+	case "string":
+		return k, nil
+	case "bool":
+		b, err := strconv.ParseBool(k)
+		if err != nil || (k != "true" && k != "false") {
+			return nil, fmt.Errorf("map key %q is not a bool", k)
+		}
+		return b, nil
+	case "int8", "int16", "int32", "int64":
+		n, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("map key %q is not an integer", k)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("invalid map key type %s", key.Name)
+}
+
+// FormatMapKey is the inverse of ParseMapKey.
+func FormatMapKey(k interface{}) string {
+	switch v := k.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprint(k)
+}
+
+// SortMapKeys returns the keys of obj in wire order: strings by their
+// UTF-8 bytes, integers numerically, false before true.
+func SortMapKeys(key *schema.PrimitiveType, obj map[string]interface{}) ([]string, error) {
+	keys := make([]string, 0, len(obj))
+	parsed := make(map[string]interface{}, len(obj))
+	for k := range obj {
+		v, err := ParseMapKey(key, k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		parsed[k] = v
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		switch a := parsed[keys[i]].(type) {
+		case int64:
+			return a < parsed[keys[j]].(int64)
+		case bool:
+			return !a && parsed[keys[j]].(bool)
+		}
+		return keys[i] < keys[j]
+	})
+	return keys, nil
+}
+
+// toInt64 accepts the numeric representations produced by json.Unmarshal
+// (float64, json.Number) and by Decode (int64).
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+		f, err := v.Float64()
+		return int64(f), err == nil
+	}
+	return 0, false
+}
+
+// toFloat64 accepts the numeric representations produced by json.Unmarshal
+// (float64, json.Number) and by Decode (int64 for integer sources).
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -2,10 +2,15 @@ package fixture
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/shaban/ffire/internal/wire"
+	fferrors "github.com/shaban/ffire/pkg/errors"
 	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/validator"
 )
 
 func TestConvertPrimitiveArray(t *testing.T) {
@@ -717,3 +722,530 @@ func TestConvertArrayElementError(t *testing.T) {
 		t.Error("Expected error for wrong element type in array")
 	}
 }
+
+func TestDecodeRoundtrip(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Record",
+					Fields: []schema.Field{
+						{Name: "ID", Type: &schema.PrimitiveType{Name: "int64"}},
+						{Name: "Ratio", Type: &schema.PrimitiveType{Name: "float64"}},
+						{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}, Tag: "`json:\"name\"`"},
+						{Name: "Tags", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "string"}}},
+						{Name: "Note", Type: &schema.PrimitiveType{Name: "string", Optional: true}},
+					},
+				},
+			},
+		},
+	}
+	s.Messages[0].TargetType.(*schema.StructType).Fields[2].SetJSONTag("name")
+
+	jsonData := []byte(`{"ID": 9007199254740993, "Ratio": 0.5, "name": "x", "Tags": ["a", "b"]}`)
+	binary, err := Convert(s, "Message", jsonData)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	obj := value.(map[string]interface{})
+	if obj["name"] != "x" {
+		t.Errorf("name = %v, want x", obj["name"])
+	}
+	if obj["Ratio"] != 0.5 {
+		t.Errorf("Ratio = %v, want 0.5", obj["Ratio"])
+	}
+	if obj["Note"] != nil {
+		t.Errorf("Note = %v, want nil", obj["Note"])
+	}
+	if tags := obj["Tags"].([]interface{}); len(tags) != 2 || tags[1] != "b" {
+		t.Errorf("Tags = %v, want [a b]", tags)
+	}
+
+	// Re-encoding the decoded tree must reproduce the original bytes
+	reencoded, err := Encode(s, "Message", value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(reencoded, binary) {
+		t.Errorf("re-encoded bytes differ:\n got %x\nwant %x", reencoded, binary)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Message", TargetType: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int32"}}},
+		},
+	}
+
+	binary, err := Convert(s, "Message", []byte(`[1, 2, 3]`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	_, err = Decode(s, "Message", binary[:len(binary)-1])
+	decErr, ok := err.(*DecodeError)
+	if !ok {
+		t.Fatalf("Decode error = %v, want *DecodeError", err)
+	}
+	if decErr.Offset != 10 || decErr.Path != "[2]" || decErr.Code != fferrors.DecodeTruncated {
+		t.Errorf("DecodeError = %+v, want Truncated at offset 10 path [2]", decErr)
+	}
+
+	_, err = Decode(s, "Message", append(binary, 0xff))
+	if !errors.As(err, &decErr) || decErr.Code != fferrors.DecodeTrailingData {
+		t.Errorf("Decode error = %v, want TrailingData", err)
+	}
+
+	// A count the remaining data cannot hold overflows
+	_, err = Decode(s, "Message", []byte{0xff, 0x00, 0x01})
+	if !errors.As(err, &decErr) || decErr.Code != fferrors.DecodeOverflow {
+		t.Errorf("Decode error = %v, want Overflow", err)
+	}
+}
+
+func TestDecodeInvalidUtf8(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Message", TargetType: &schema.PrimitiveType{Name: "string"}},
+		},
+	}
+
+	var decErr *DecodeError
+	_, err := Decode(s, "Message", []byte{0x02, 0x00, 0xc3, 0x28})
+	if !errors.As(err, &decErr) || decErr.Code != fferrors.DecodeInvalidUtf8 {
+		t.Errorf("Decode error = %v, want InvalidUtf8", err)
+	}
+}
+
+func TestConvertMap(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Names", Type: &schema.MapType{
+							KeyType:   &schema.PrimitiveType{Name: "int16"},
+							ValueType: &schema.PrimitiveType{Name: "string"},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	// Keys are written in numeric order, not JSON or string order
+	binary, err := Convert(s, "Message", []byte(`{"Names": {"10": "b", "-1": "a", "9": "c"}}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{
+		0x03, 0x00, // 3 entries
+		0xff, 0xff, 0x01, 0x00, 'a', // -1: "a"
+		0x09, 0x00, 0x01, 0x00, 'c', // 9: "c"
+		0x0a, 0x00, 0x01, 0x00, 'b', // 10: "b"
+	}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if obj := value.(map[string]interface{})["Names"].(map[string]interface{}); len(obj) != 3 || obj["-1"] != "a" {
+		t.Errorf("Decode = %v", obj)
+	}
+
+	if _, err := Convert(s, "Message", []byte(`{"Names": {"x": "a"}}`)); err == nil {
+		t.Error("expected error for a non-integer key")
+	}
+}
+
+func TestConvertEnum(t *testing.T) {
+	mode := &schema.EnumType{Name: "Mode", Base: "int16", Values: []schema.EnumValue{
+		{Name: "Off", Value: -1},
+		{Name: "On", Value: 1},
+	}}
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Modes", Type: &schema.ArrayType{ElementType: mode}},
+					},
+				},
+			},
+		},
+	}
+
+	// Names and values are both accepted; the wire carries the base integer
+	binary, err := Convert(s, "Message", []byte(`{"Modes": ["Off", 1]}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{0x02, 0x00, 0xff, 0xff, 0x01, 0x00}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if modes := value.(map[string]interface{})["Modes"].([]interface{}); modes[0] != "Off" || modes[1] != "On" {
+		t.Errorf("Decode = %v, want [Off On]", modes)
+	}
+
+	for _, bad := range []string{`["Auto"]`, `[2]`} {
+		if _, err := Convert(s, "Message", []byte(`{"Modes": `+bad+`}`)); err == nil {
+			t.Errorf("Convert(%s) should fail", bad)
+		}
+	}
+
+	// Decoding rejects values that are not listed
+	_, err = Decode(s, "Message", []byte{0x01, 0x00, 0x02, 0x00})
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) || decErr.Offset != 2 || decErr.Code != fferrors.DecodeInvalidValue {
+		t.Errorf("Decode error = %v, want invalid Mode value at offset 2", err)
+	}
+}
+
+func TestConvertUnion(t *testing.T) {
+	circle := &schema.StructType{Name: "Circle", Fields: []schema.Field{
+		{Name: "R", Type: &schema.PrimitiveType{Name: "int16"}},
+	}}
+	shape := &schema.UnionType{Name: "Shape", Variants: []schema.Type{circle, &schema.PrimitiveType{Name: "string"}}}
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Shapes", Type: &schema.ArrayType{ElementType: shape}},
+						{Name: "Hint", Type: &schema.UnionType{Name: "Shape", Variants: shape.Variants, Optional: true}},
+					},
+				},
+			},
+		},
+	}
+
+	// Each value is a tag (the 1-based variant index) and the variant
+	binary, err := Convert(s, "Message", []byte(`{"Shapes": [{"Circle": {"R": 3}}, {"string": "hi"}], "Hint": null}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{0x02, 0x00, 0x01, 0x03, 0x00, 0x02, 0x02, 0x00, 'h', 'i', 0x00}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	shapes := value.(map[string]interface{})["Shapes"].([]interface{})
+	if got := fmt.Sprint(shapes); got != "[map[Circle:map[R:3]] map[string:hi]]" {
+		t.Errorf("Decode = %s", got)
+	}
+
+	for _, bad := range []string{`[{"Square": {}}]`, `[{}]`, `["hi"]`} {
+		if _, err := Convert(s, "Message", []byte(`{"Shapes": `+bad+`, "Hint": null}`)); err == nil {
+			t.Errorf("Convert(%s) should fail", bad)
+		}
+	}
+
+	// Tag 0 and tags past the last variant are rejected
+	for _, tag := range []byte{0x00, 0x03} {
+		_, err = Decode(s, "Message", []byte{0x01, 0x00, tag, 0x00})
+		var decErr *DecodeError
+		if !errors.As(err, &decErr) || decErr.Offset != 2 {
+			t.Errorf("Decode tag %d error = %v, want invalid Shape tag at offset 2", tag, err)
+		}
+	}
+}
+
+func TestConvertBytes(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Blob", Type: &schema.PrimitiveType{Name: "bytes"}},
+					},
+				},
+			},
+		},
+	}
+
+	// JSON carries bytes as standard base64; the wire is a length prefix
+	// and the raw bytes, which need not be UTF-8
+	binary, err := Convert(s, "Message", []byte(`{"Blob": "3q2+7w=="}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{0x04, 0x00, 0xde, 0xad, 0xbe, 0xef}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got := value.(map[string]interface{})["Blob"]; got != "3q2+7w==" {
+		t.Errorf("Decode = %v, want 3q2+7w==", got)
+	}
+
+	for _, bad := range []string{`"not base64!"`, `[222, 173]`} {
+		if _, err := Convert(s, "Message", []byte(`{"Blob": `+bad+`}`)); err == nil {
+			t.Errorf("Convert(%s) should fail", bad)
+		}
+	}
+}
+
+func TestConvertFixedArray(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Taps", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int16"}, Length: 2}},
+						{Name: "Flags", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "bool"}, Length: 3}},
+					},
+				},
+			},
+		},
+	}
+
+	// Fixed-size arrays are written without a length prefix
+	binary, err := Convert(s, "Message", []byte(`{"Taps": [1, -2], "Flags": [true, false, true]}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := []byte{0x01, 0x00, 0xfe, 0xff, 0x01, 0x00, 0x01}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got := value.(map[string]interface{})["Taps"].([]interface{}); len(got) != 2 {
+		t.Errorf("Decode Taps = %v, want 2 elements", got)
+	}
+	if _, err := Decode(s, "Message", binary[:len(binary)-1]); err == nil {
+		t.Error("Decode of a truncated fixed array should fail")
+	}
+
+	if _, err := Convert(s, "Message", []byte(`{"Taps": [1], "Flags": [true, false, true]}`)); err == nil {
+		t.Error("Convert with a short fixed array should fail")
+	}
+}
+
+func TestConvertLarge(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Samples", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int8"}, Large: true}},
+						{Name: "Text", Type: &schema.PrimitiveType{Name: "string", Large: true}},
+						{Name: "Small", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int8"}}},
+					},
+				},
+			},
+		},
+	}
+
+	// 70000 elements need the uint32 prefix of a @large array
+	samples := "[" + strings.TrimSuffix(strings.Repeat("1,", 70000), ",") + "]"
+	jsonData := `{"Samples": ` + samples + `, "Text": "hi", "Small": [2]}`
+	binary, err := Convert(s, "Message", []byte(jsonData))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if want := []byte{0x70, 0x11, 0x01, 0x00}; !bytes.Equal(binary[:4], want) {
+		t.Errorf("Samples prefix = %x, want %x", binary[:4], want)
+	}
+	if want := []byte{0x02, 0x00, 0x00, 0x00, 'h', 'i', 0x01, 0x00, 0x02}; !bytes.Equal(binary[4+70000:], want) {
+		t.Errorf("Text and Small = %x, want %x", binary[4+70000:], want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	reencoded, err := Encode(s, "Message", value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(reencoded, binary) {
+		t.Error("re-encoded bytes differ")
+	}
+
+	// Only the annotated field lifts the limit
+	if err := validator.ValidateJSON(s, "Message", []byte(jsonData)); err != nil {
+		t.Errorf("ValidateJSON failed: %v", err)
+	}
+	if err := validator.ValidateJSON(s, "Message", []byte(`{"Samples": [], "Text": "", "Small": `+samples+`}`)); err == nil {
+		t.Error("ValidateJSON with 70000 elements in a plain array should fail")
+	}
+
+	// A count the remaining data cannot hold fails before allocating
+	corrupt := append([]byte{0xff, 0xff, 0xff, 0x7f}, binary[4:]...)
+	if _, err := Decode(s, "Message", corrupt); err == nil {
+		t.Error("Decode with a corrupt count should fail")
+	}
+}
+
+func TestConvertBitmap(t *testing.T) {
+	inner := &schema.StructType{
+		Name:     "Inner",
+		Optional: true,
+		Bitmap:   true,
+		Fields:   []schema.Field{{Name: "X", Type: &schema.PrimitiveType{Name: "int8", Optional: true}}},
+	}
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name:   "Message",
+					Bitmap: true,
+					Fields: []schema.Field{
+						{Name: "ID", Type: &schema.PrimitiveType{Name: "int32"}},
+						{Name: "A", Type: &schema.PrimitiveType{Name: "int8", Optional: true}},
+						{Name: "B", Type: &schema.PrimitiveType{Name: "string", Optional: true}},
+						{Name: "C", Type: inner},
+					},
+				},
+			},
+		},
+	}
+
+	binary, err := Convert(s, "Message", []byte(`{"ID": 1, "B": "hi", "C": {"X": 5}}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	// Bits 1 and 2 for B and C, no presence bytes, then C's own bitmap
+	want := []byte{0x06, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 'h', 'i', 0x01, 0x05}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if obj := value.(map[string]interface{}); obj["A"] != nil || obj["B"] != "hi" {
+		t.Errorf("Decode = %v", value)
+	}
+	reencoded, err := Encode(s, "Message", value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(reencoded, binary) {
+		t.Errorf("re-encoded bytes = %x, want %x", reencoded, binary)
+	}
+
+	// Bits past the last optional field must be zero
+	padded := append([]byte{0x0e}, binary[1:]...)
+	var decErr *DecodeError
+	if _, err := Decode(s, "Message", padded); !errors.As(err, &decErr) || decErr.Code != fferrors.DecodeBadPresence {
+		t.Errorf("Decode with a stray bit = %v, want invalid presence bitmap", err)
+	}
+}
+
+func TestConvertFramed(t *testing.T) {
+	inner := &schema.StructType{
+		Name:     "Inner",
+		Optional: true,
+		Framed:   true,
+		Fields:   []schema.Field{{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}}},
+	}
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "ID", Type: &schema.PrimitiveType{Name: "int8"}},
+						{Name: "Inner", Type: inner},
+					},
+				},
+			},
+		},
+	}
+
+	binary, err := Convert(s, "Message", []byte(`{"ID": 1, "Inner": {"Name": "hi"}}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	// The frame follows Inner's presence byte and counts its 4 bytes
+	want := []byte{0x01, 0x01, 0x04, 0x00, 0x00, 0x00, 0x02, 0x00, 'h', 'i'}
+	if !bytes.Equal(binary, want) {
+		t.Errorf("Convert = %x, want %x", binary, want)
+	}
+
+	value, err := Decode(s, "Message", binary)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	reencoded, err := Encode(s, "Message", value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(reencoded, binary) {
+		t.Errorf("re-encoded bytes = %x, want %x", reencoded, binary)
+	}
+
+	tests := []struct {
+		name  string
+		frame byte
+		data  []byte
+		code  fferrors.DecodeCode
+	}{
+		{"frame past the data", 0x05, want[6:], fferrors.DecodeOverflow},
+		{"fields past the frame", 0x03, want[6:], fferrors.DecodeOverflow},
+		{"bytes left in the frame", 0x05, append(want[6:], 0x00), fferrors.DecodeTrailingData},
+	}
+	for _, tt := range tests {
+		data := append([]byte{0x01, 0x01, tt.frame, 0x00, 0x00, 0x00}, tt.data...)
+		var decErr *DecodeError
+		if _, err := Decode(s, "Message", data); !errors.As(err, &decErr) || decErr.Code != tt.code {
+			t.Errorf("%s: Decode = %v, want code %v", tt.name, err, tt.code)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package fixture
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// sampleRecursion bounds how often Sample re-enters a struct type that is
+// already being sampled, so recursive schemas produce a finite value.
+const sampleRecursion = 2
+
+// Sample returns a deterministic value for a message type in the same form
+// as Decode, with every field populated: optional values are present and
+// arrays and maps have two elements, except where a recursive type would
+// nest further. Encoding it gives wire data that exercises every field of
+// the message, for use as a generated test fixture when no real fixture is
+// available.
+func Sample(s *schema.Schema, messageName string) (interface{}, error) {
+	for _, msg := range s.Messages {
+		if msg.Name == messageName {
+			g := &sampler{active: make(map[string]int)}
+			return g.value(msg.TargetType), nil
+		}
+	}
+	return nil, fmt.Errorf("message type %s not found in schema", messageName)
+}
+
+// sampler numbers the values it produces so that fields of the same type
+// get different contents. active counts the struct types on the current
+// path and repeats how many of those entries were recursive.
+type sampler struct {
+	n       int64
+	active  map[string]int
+	repeats int
+}
+
+func (g *sampler) value(typ schema.Type) interface{} {
+	if typ.IsOptional() && g.repeats >= sampleRecursion {
+		return nil
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		return g.primitive(t.Name)
+
+	case *schema.EnumType:
+		g.n++
+		return t.Values[int(g.n)%len(t.Values)].Name
+
+	case *schema.UnionType:
+		// Rotate through the variants so arrays of unions mix them
+		g.n++
+		variant := t.Variants[int(g.n)%len(t.Variants)]
+		return map[string]interface{}{variant.TypeName(): g.value(variant)}
+
+	case *schema.StructType:
+		if g.active[t.Name] > 0 {
+			g.repeats++
+			defer func() { g.repeats-- }()
+		}
+		g.active[t.Name]++
+		defer func() { g.active[t.Name]-- }()
+
+		obj := make(map[string]interface{}, len(t.Fields))
+		for _, field := range t.Fields {
+			obj[field.JSONName()] = g.value(field.Type)
+		}
+		return obj
+
+	case *schema.ArrayType:
+		n := 2
+		if t.Length > 0 {
+			n = t.Length
+		} else if g.repeats >= sampleRecursion {
+			n = 0
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			arr[i] = g.value(t.ElementType)
+		}
+		return arr
+
+	case *schema.MapType:
+		n := 2
+		if g.repeats >= sampleRecursion {
+			n = 0
+		}
+		obj := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			k := FormatMapKey(g.primitive(t.KeyType.TypeName()))
+			obj[k] = g.value(t.ValueType)
+		}
+		return obj
+	}
+	return nil
+}
+
+func (g *sampler) primitive(name string) interface{} {
+	g.n++
+	switch name {
+	case "bool":
+		return g.n%2 == 1
+	case "int8":
+		return -(g.n % 100)
+	case "int16", "int32", "int64":
+		return g.n * 1000
+	case "float32", "float64":
+		// Exactly representable in both widths
+		return float64(g.n) + 0.5
+	case "string":
+		return fmt.Sprintf("sample-%d", g.n)
+	case "bytes":
+		// Includes a byte that is not valid UTF-8
+		return base64.StdEncoding.EncodeToString([]byte{byte(g.n), 0xff, byte(g.n >> 8)})
+	}
+	return nil
+}
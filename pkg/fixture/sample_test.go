@@ -0,0 +1,107 @@
+package fixture
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func TestSampleRoundtrip(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Flag", Type: &schema.PrimitiveType{Name: "bool"}},
+						{Name: "Small", Type: &schema.PrimitiveType{Name: "int8"}},
+						{Name: "Count", Type: &schema.PrimitiveType{Name: "int32"}},
+						{Name: "Ratio", Type: &schema.PrimitiveType{Name: "float32"}},
+						{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+						{Name: "Note", Type: &schema.PrimitiveType{Name: "string", Optional: true}},
+						{Name: "Blob", Type: &schema.PrimitiveType{Name: "bytes"}},
+						{Name: "Values", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int64"}}},
+						{Name: "Limits", Type: &schema.MapType{KeyType: &schema.PrimitiveType{Name: "int8"}, ValueType: &schema.PrimitiveType{Name: "string"}}},
+						{Name: "Mode", Type: &schema.EnumType{Name: "Mode", Base: "int8", Values: []schema.EnumValue{{Name: "Off", Value: 0}, {Name: "On", Value: 1}}}},
+					},
+				},
+			},
+		},
+	}
+
+	value, err := Sample(s, "Message")
+	if err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+	obj := value.(map[string]interface{})
+	if obj["Note"] == nil {
+		t.Error("optional field should be present")
+	}
+	if got := len(obj["Values"].([]interface{})); got != 2 {
+		t.Errorf("array length = %d, want 2", got)
+	}
+	if got := len(obj["Limits"].(map[string]interface{})); got != 2 {
+		t.Errorf("map size = %d, want 2", got)
+	}
+
+	data, err := Encode(s, "Message", value)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := Decode(s, "Message", data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	again, err := Encode(s, "Message", decoded)
+	if err != nil {
+		t.Fatalf("Encode of decoded value failed: %v", err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("roundtrip changed the wire data:\n got %x\nwant %x", again, data)
+	}
+
+	// Samples are deterministic
+	second, _ := Sample(s, "Message")
+	secondData, _ := Encode(s, "Message", second)
+	if !bytes.Equal(data, secondData) {
+		t.Error("Sample is not deterministic")
+	}
+}
+
+func TestSampleRecursiveType(t *testing.T) {
+	// type Node struct { ID int32; Children []Node; Parent *Node }
+	node := &schema.StructType{Name: "Node"}
+	parent := &schema.StructType{Name: "Node", Optional: true}
+	node.Fields = []schema.Field{
+		{Name: "ID", Type: &schema.PrimitiveType{Name: "int32"}},
+		{Name: "Children", Type: &schema.ArrayType{ElementType: node}},
+		{Name: "Parent", Type: parent},
+	}
+	parent.Fields = node.Fields
+	s := &schema.Schema{
+		Package:  "test",
+		Messages: []schema.MessageType{{Name: "Node", TargetType: node}},
+	}
+
+	value, err := Sample(s, "Node")
+	if err != nil {
+		t.Fatalf("Sample failed: %v", err)
+	}
+	if _, err := Encode(s, "Node", value); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	children := value.(map[string]interface{})["Children"].([]interface{})
+	if len(children) != 2 {
+		t.Fatalf("top-level children = %d, want 2", len(children))
+	}
+}
+
+func TestSampleMissingMessageType(t *testing.T) {
+	s := &schema.Schema{Package: "test"}
+	if _, err := Sample(s, "Missing"); err == nil {
+		t.Error("expected error for missing message type")
+	}
+}
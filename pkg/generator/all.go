@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+)
+
+// LanguageAll is the Language that generates every built-in language with
+// GenerateAll.
+const LanguageAll = "all"
+
+// AllLanguages lists the languages LanguageAll generates, by canonical
+// name. Aliases (c, javascript, octave, ...) and igniffi, which the js and
+// python packages already wrap, are left out.
+var AllLanguages = []string{
+	"go", "cpp", "js", "python", "swift", "dart", "java", "kotlin",
+	"csharp", "rust", "zig", "godot", "matlab",
+}
+
+// LanguageResult is the outcome of one language of GenerateAll.
+type LanguageResult struct {
+	Language string
+	Dir      string // Package directory
+	Duration time.Duration
+	Err      error
+}
+
+// GenerateAll generates the package of every language in AllLanguages from
+// config, one after the other. In the default layout each goes to
+// <OutputDir>/<lang>/, which is its package directory; the monorepo layout
+// is unchanged. Options that only some languages support (WithTests,
+// KotlinOkio, CppSIMD, BuildRules, ...) apply to those languages only.
+//
+// A failing language does not stop the others: GenerateAll returns one
+// result per language, and stops early only when ctx is done. The schema is
+// parsed once and shared; its field order is restored after each language,
+// so every package matches the one generated on its own.
+func GenerateAll(ctx context.Context, config *PackageConfig) []LanguageResult {
+	restore := config.Schema.SaveFieldOrder()
+	defer restore()
+
+	var results []LanguageResult
+	for _, lang := range AllLanguages {
+		if ctx.Err() != nil {
+			break
+		}
+		c := languageConfig(config, lang)
+		start := time.Now()
+		err := GeneratePackageContext(ctx, c)
+		restore()
+		results = append(results, LanguageResult{Language: lang, Dir: c.langDir(lang), Duration: time.Since(start), Err: err})
+	}
+	return results
+}
+
+// languageConfig returns the config generating lang for GenerateAll.
+func languageConfig(config *PackageConfig, lang string) *PackageConfig {
+	c := *config
+	c.Language = lang
+	if c.Layout == "" || c.Layout == LayoutDefault {
+		c.OutputDir = filepath.Join(config.OutputDir, lang)
+		c.packageDir = true
+	}
+
+	c.WithTests = c.WithTests && supportsRoundtripTests(lang)
+	c.Handshake = c.Handshake && supportsHandshake(lang)
+	c.Descriptor = c.Descriptor && supportsDescriptor(lang)
+	c.VerifyLint = c.VerifyLint && lang == "go"
+	c.BorrowedDecode = c.BorrowedDecode && lang == "go"
+	if !sharedRuntimeLanguages[lang] {
+		c.SharedRuntime = ""
+	}
+	if langs, ok := buildRulesLanguages[c.BuildRules]; ok && !contains(langs, lang) {
+		c.BuildRules = ""
+	}
+	if !contains(sanitizeLanguages, lang) {
+		c.Sanitize = nil
+	}
+	c.KotlinOkio = c.KotlinOkio && lang == "kotlin"
+	c.SwiftCodable = c.SwiftCodable && lang == "swift"
+	c.JavaFFM = c.JavaFFM && lang == "java"
+	c.CSharpUnity = c.CSharpUnity && lang == "csharp"
+	c.PythonPure = c.PythonPure && lang == "python"
+	c.CppSIMD = c.CppSIMD && contains(cppSIMDLanguages, lang)
+	c.CppEmbedded = c.CppEmbedded && lang == "cpp"
+	c.CppArduino = c.CppArduino && lang == "cpp"
+	return &c
+}
@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const allTestSchema = `package tele
+
+type Reading struct {
+	Sensor string
+	T      float64
+	Raw    bytes
+}
+
+type Batch []Reading
+`
+
+func TestGenerateAll(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(allTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	out := t.TempDir()
+	config := &PackageConfig{
+		Schema: s, Language: LanguageAll, OutputDir: out,
+		NoCompile: true, NoFormat: true, PythonPure: true, KotlinOkio: true,
+	}
+	results := GenerateAll(context.Background(), config)
+	if len(results) != len(AllLanguages) {
+		t.Fatalf("got %d results, want %d", len(results), len(AllLanguages))
+	}
+	for _, r := range results {
+		switch {
+		case r.Language == "js":
+			// igniffi has no bytes; the other languages carry on
+			if r.Err == nil || !strings.Contains(r.Err.Error(), "bytes") {
+				t.Errorf("js: err = %v, want bytes unsupported", r.Err)
+			}
+		case r.Err != nil:
+			t.Errorf("%s: %v", r.Language, r.Err)
+		case r.Dir != filepath.Join(out, r.Language):
+			t.Errorf("%s: Dir = %s", r.Language, r.Dir)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "go", "tele.go")); err != nil {
+		t.Errorf("Go package not in go/: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "python", "tele", "__init__.pyi")); err != nil {
+		t.Errorf("--python-pure not applied to python: %v", err)
+	}
+
+	// The shared schema keeps its declared field order
+	if st := s.Types[0].(*schema.StructType); st.Fields[0].Name != "Sensor" {
+		t.Errorf("field order not restored: %s first", st.Fields[0].Name)
+	}
+}
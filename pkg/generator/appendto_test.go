@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// TestGoAppendToReusesBuffer runs generated AppendTo and the encode buffer
+// pool: output must match Encode, keep what was already in the buffer, and
+// allocate nothing once the buffer is large enough.
+func TestGoAppendToReusesBuffer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	s, err := parser.ParseBytes([]byte(`package shapes
+
+type Point struct {
+	X int32
+	Y int32
+}
+
+type Path struct {
+	Name   string
+	Points []Point
+	Note   *string
+}
+`))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "shapes"), 0755)
+	files := map[string]string{
+		"go.mod":           "module appendto\n\ngo 1.21\n",
+		"shapes/shapes.go": string(code),
+		"main.go": `package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"appendto/shapes"
+)
+
+func main() {
+	note := "n"
+	msg := shapes.PathMessage{Name: "p", Points: []shapes.Point{{X: 1, Y: 2}}, Note: &note}
+	want := msg.Encode()
+
+	out := msg.AppendTo([]byte("prefix"))
+	fmt.Println(string(out[:6]), bytes.Equal(out[6:], want))
+
+	buf := make([]byte, 0, 1024)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = msg.AppendTo(buf[:0])
+	})
+	fmt.Println(bytes.Equal(buf, want), allocs)
+
+	b := shapes.GetEncodeBuffer()
+	b.B = msg.AppendTo(b.B)
+	fmt.Println(bytes.Equal(b.B, want))
+	b.Release()
+	fmt.Println(len(shapes.GetEncodeBuffer().B))
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+
+	want := "prefix true\ntrue 0\ntrue\n0\n"
+	if string(out) != want {
+		t.Errorf("unexpected output\nwant:\n%s\ngot:\n%s", want, strings.TrimSpace(string(out)))
+	}
+}
@@ -0,0 +1,181 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Arduino libraries (--cpp-arduino) hold the embedded C++ profile (see
+// embedded.go) in the Arduino library format, which the Arduino IDE,
+// arduino-cli and PlatformIO all install: the header is src/<package>.h,
+// described by library.properties for Arduino and library.json for
+// PlatformIO, with keywords.txt for the IDE's highlighting and a sketch
+// under examples/ that encodes and decodes the first message.
+
+// arduinoURL is the url of library.properties, which the Arduino Library
+// Manager requires.
+const arduinoURL = "https://github.com/shaban/ffire"
+
+// arduinoFile is a file of an Arduino library, at a slash-separated path
+// relative to the library root.
+type arduinoFile struct {
+	path    string
+	content []byte
+}
+
+func generateArduinoLibrary(config *PackageConfig) error {
+	code, err := GenerateCppWithOptions(config.Schema, CppOptions{Embedded: true})
+	if err != nil {
+		return fmt.Errorf("failed to generate C++ code: %w", err)
+	}
+	pkg := config.Schema.Package
+	header := pkg + ".h"
+	files := []arduinoFile{
+		{"library.properties", arduinoProperties(config, header)},
+		{"library.json", arduinoManifest(config, header)},
+		{"keywords.txt", arduinoKeywords(config.Schema)},
+		{"src/" + header, code},
+		{"README.md", []byte(embeddedReadme(config.Schema, header, arduinoInstall(config)) + readmeLicenseSection(config))},
+	}
+	if len(config.Schema.Messages) > 0 {
+		files = append(files, arduinoFile{"examples/Roundtrip/Roundtrip.ino", arduinoExample(config.Schema, header)})
+	}
+
+	libDir := config.langDir(config.Language)
+	for _, f := range files {
+		path := filepath.Join(libDir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, f.content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+	}
+	config.infof("✓ Generated Arduino library: %s\n", libDir)
+
+	config.infof("\n✅ Arduino library ready at: %s\n", libDir)
+	config.infof("   Copy it into the Arduino libraries folder, or add it to lib_deps in platformio.ini\n")
+	return nil
+}
+
+// arduinoLibraryName returns the library name: the package in PascalCase,
+// as Arduino libraries are usually named.
+func arduinoLibraryName(config *PackageConfig) string {
+	return ToPascalCase(config.Schema.Package)
+}
+
+func arduinoSentence(pkg string) string {
+	return "Encodes and decodes ffire messages of the " + pkg + " schema, without heap, exceptions or RTTI."
+}
+
+func arduinoProperties(config *PackageConfig, header string) []byte {
+	author := valueOr(config.Copyright, "The "+config.Namespace+" Authors")
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "name=%s\n", arduinoLibraryName(config))
+	fmt.Fprintf(buf, "version=%s\n", config.Version)
+	fmt.Fprintf(buf, "author=%s\n", author)
+	fmt.Fprintf(buf, "maintainer=%s\n", author)
+	fmt.Fprintf(buf, "sentence=%s\n", arduinoSentence(config.Schema.Package))
+	buf.WriteString("paragraph=Messages are stored in fixed-size buffers, so firmware can exchange them with desktop and server programs that use ffire.\n")
+	buf.WriteString("category=Communication\n")
+	fmt.Fprintf(buf, "url=%s\n", arduinoURL)
+	buf.WriteString("architectures=*\n")
+	fmt.Fprintf(buf, "includes=%s\n", header)
+	return buf.Bytes()
+}
+
+// arduinoManifest returns library.json, the PlatformIO manifest. PlatformIO
+// also reads library.properties, but only library.json carries the
+// license.
+func arduinoManifest(config *PackageConfig, header string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{\n")
+	fmt.Fprintf(buf, "  \"name\": %q,\n", arduinoLibraryName(config))
+	fmt.Fprintf(buf, "  \"version\": %q,\n", config.Version)
+	fmt.Fprintf(buf, "  \"description\": %q,\n", arduinoSentence(config.Schema.Package))
+	fmt.Fprintf(buf, "  \"keywords\": [\"ffire\", \"serialization\", %q],\n", config.Schema.Package)
+	if license := spdxLicense(config); license != "" {
+		fmt.Fprintf(buf, "  \"license\": %q,\n", license)
+	}
+	fmt.Fprintf(buf, "  \"homepage\": %q,\n", arduinoURL)
+	buf.WriteString("  \"frameworks\": \"*\",\n")
+	buf.WriteString("  \"platforms\": \"*\",\n")
+	fmt.Fprintf(buf, "  \"headers\": %q\n", header)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// arduinoKeywords returns keywords.txt, which the Arduino IDE highlights:
+// types as KEYWORD1, functions as KEYWORD2 and the size constants as
+// LITERAL1. Fields are separated by a single tab.
+func arduinoKeywords(s *schema.Schema) []byte {
+	buf := &bytes.Buffer{}
+	for _, name := range []string{"String", "Bytes", "Vector", "Array", "Optional", "Map", "DecodeErrorCode"} {
+		fmt.Fprintf(buf, "%s\tKEYWORD1\n", name)
+	}
+	for _, msg := range s.Messages {
+		fmt.Fprintf(buf, "%sMessage\tKEYWORD1\n", msg.Name)
+	}
+	for _, t := range s.Types {
+		switch t.(type) {
+		case *schema.StructType, *schema.EnumType, *schema.UnionType:
+			fmt.Fprintf(buf, "%s\tKEYWORD1\n", t.TypeName())
+		}
+	}
+	for _, msg := range s.Messages {
+		name := strings.ToLower(msg.Name)
+		fmt.Fprintf(buf, "encode_%s_message\tKEYWORD2\n", name)
+		fmt.Fprintf(buf, "decode_%s_message\tKEYWORD2\n", name)
+		fmt.Fprintf(buf, "%s_message_max_size\tLITERAL1\n", name)
+	}
+	return buf.Bytes()
+}
+
+// arduinoExample returns a sketch that encodes the first message into a
+// buffer of its largest size and decodes it back, printing the results.
+func arduinoExample(s *schema.Schema, header string) []byte {
+	pkg := s.Package
+	name := strings.ToLower(s.Messages[0].Name)
+	typ := pkg + "::" + s.Messages[0].Name + "Message"
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Encodes a %s into a buffer of its largest size and decodes it\n", s.Messages[0].Name)
+	buf.WriteString("// back. Send the encoded bytes with Serial.write(buf, n) to a program on\n")
+	buf.WriteString("// the other end that uses ffire.\n")
+	fmt.Fprintf(buf, "#include <%s>\n\n", header)
+	buf.WriteString("// Messages hold their full capacity, so they are kept off the stack\n")
+	fmt.Fprintf(buf, "static uint8_t buf[%s::%s_message_max_size];\n", pkg, name)
+	fmt.Fprintf(buf, "static %s msg;\n", typ)
+	fmt.Fprintf(buf, "static %s decoded;\n\n", typ)
+	buf.WriteString("void setup() {\n")
+	buf.WriteString("    Serial.begin(115200);\n")
+	buf.WriteString("    size_t n;\n")
+	fmt.Fprintf(buf, "    if (!%s::encode_%s_message(msg, buf, sizeof(buf), n)) {\n", pkg, name)
+	buf.WriteString("        Serial.println(\"encode failed\");\n")
+	buf.WriteString("        return;\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("    Serial.print(\"encoded bytes: \");\n")
+	buf.WriteString("    Serial.println(n);\n")
+	fmt.Fprintf(buf, "    if (%s::decode_%s_message(buf, n, decoded) == %s::DecodeErrorCode::Ok) {\n", pkg, name, pkg)
+	buf.WriteString("        Serial.println(\"decoded\");\n")
+	buf.WriteString("    } else {\n")
+	buf.WriteString("        Serial.println(\"decode failed\");\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("void loop() {}\n")
+	return buf.Bytes()
+}
+
+func arduinoInstall(config *PackageConfig) string {
+	var b strings.Builder
+	b.WriteString("## Installing\n\n")
+	fmt.Fprintf(&b, "- **Arduino IDE**: copy this directory into the `libraries` folder of your sketchbook as `%s`, or zip it and use Sketch > Include Library > Add .ZIP Library\n", arduinoLibraryName(config))
+	b.WriteString("- **arduino-cli**: `arduino-cli lib install --zip-path <zip>` (needs `library.enable_unsafe_install`)\n")
+	b.WriteString("- **PlatformIO**: add `lib_deps = file://<path to this directory>` to `platformio.ini`\n\n")
+	b.WriteString("Then open File > Examples > " + arduinoLibraryName(config) + " > Roundtrip. Schemas with float64 fields need a 64-bit `double`, which AVR boards such as the Uno lack; ESP32, STM32 and other ARM boards have one.\n\n")
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const arduinoTestSchema = `package tele
+
+type Reading struct {
+	Sensor string // @max(16)
+	Value  float32
+	Tags   []string // @max(3, 8)
+}
+`
+
+// arduinoStub stands in for Arduino.h, which sketches include implicitly,
+// with the Serial methods the example uses and the macros that clash with
+// careless identifiers.
+const arduinoStub = `#include <stdio.h>
+#include <stdint.h>
+struct SerialStub {
+    void begin(long) {}
+    void print(const char* s) { fputs(s, stdout); }
+    void println(const char* s) { puts(s); }
+    void println(unsigned long n) { printf("%lu\n", n); }
+};
+static SerialStub Serial;
+#define min(a, b) ((a) < (b) ? (a) : (b))
+#define max(a, b) ((a) > (b) ? (a) : (b))
+`
+
+func TestGenerateArduinoLibrary(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(arduinoTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	outDir := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "cpp", OutputDir: outDir, Namespace: "tele", Version: "1.2.0", License: LicenseMIT, CppArduino: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	libDir := config.langDir("cpp")
+	for _, name := range []string{"library.properties", "library.json", "keywords.txt", "README.md", "LICENSE", "src/tele.h", "examples/Roundtrip/Roundtrip.ino"} {
+		if _, err := os.Stat(filepath.Join(libDir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	props, err := os.ReadFile(filepath.Join(libDir, "library.properties"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"name=Tele\n", "version=1.2.0\n", "architectures=*\n", "includes=tele.h\n"} {
+		if !strings.Contains(string(props), want) {
+			t.Errorf("library.properties missing %q:\n%s", want, props)
+		}
+	}
+	manifest, err := os.ReadFile(filepath.Join(libDir, "library.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), `"license": "MIT"`) {
+		t.Errorf("library.json has no license:\n%s", manifest)
+	}
+
+	// The sketch must build as the Arduino IDE builds it: C++11 with
+	// Arduino.h included first
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	dir := t.TempDir()
+	main := "#include <Arduino.h>\n#include \"" + filepath.Join(libDir, "examples", "Roundtrip", "Roundtrip.ino") + "\"\nint main() {\n    setup();\n    return 0;\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "Arduino.h"), []byte(arduinoStub), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.cpp"), []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "sketch")
+	args := []string{"-std=gnu++11", "-Wall", "-Wextra", "-Werror", "-fno-exceptions", "-fno-rtti", "-I", dir, "-I", filepath.Join(libDir, "src"), "-o", bin, filepath.Join(dir, "main.cpp")}
+	if out, err := exec.Command("g++", args...).CombinedOutput(); err != nil {
+		t.Fatalf("g++ failed: %v\n%s", err, out)
+	}
+	out, err := exec.Command(bin).CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "decoded") {
+		t.Errorf("sketch: %v\n%s", err, out)
+	}
+}
+
+func TestCheckCppArduino(t *testing.T) {
+	err := checkCppEmbedded(&PackageConfig{Language: "go", CppArduino: true})
+	if err == nil || !strings.Contains(err.Error(), "--cpp-arduino is not supported for go") {
+		t.Errorf("err = %v, want --cpp-arduino rejected for go", err)
+	}
+	err = checkCppEmbedded(&PackageConfig{Language: "cpp", CppArduino: true, Handshake: true})
+	if err == nil || !strings.Contains(err.Error(), "--cpp-arduino cannot be combined with --handshake") {
+		t.Errorf("err = %v, want --handshake rejected", err)
+	}
+}
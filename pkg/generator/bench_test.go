@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/synth"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+// benchSizes are the synthetic schema sizes (number of struct types) used by
+// the pipeline benchmarks.
+var benchSizes = []int{10, 100, 1000}
+
+func synthSchema(b *testing.B, types int) (*schema.Schema, []byte) {
+	b.Helper()
+	src := synth.Schema(synth.Options{Types: types, Seed: 1})
+	s, err := parser.ParseBytes(src)
+	if err != nil {
+		b.Fatalf("parse synthetic schema: %v", err)
+	}
+	if err := validator.ValidateSchema(s); err != nil {
+		b.Fatalf("validate synthetic schema: %v", err)
+	}
+	return s, src
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, n := range benchSizes {
+		_, src := synthSchema(b, n)
+		b.Run(fmt.Sprintf("types=%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.ParseBytes(src); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidate(b *testing.B) {
+	for _, n := range benchSizes {
+		s, _ := synthSchema(b, n)
+		b.Run(fmt.Sprintf("types=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := validator.ValidateSchema(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	generators := []struct {
+		lang string
+		fn   func(*schema.Schema) ([]byte, error)
+	}{
+		{"go", GenerateGo},
+		{"cpp", GenerateCpp},
+		{"java", GenerateJava},
+		{"csharp", GenerateCSharp},
+	}
+
+	for _, g := range generators {
+		for _, n := range benchSizes {
+			s, _ := synthSchema(b, n)
+			b.Run(fmt.Sprintf("%s/types=%d", g.lang, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					out, err := g.fn(s)
+					if err != nil {
+						b.Fatal(err)
+					}
+					b.SetBytes(int64(len(out)))
+				}
+			})
+		}
+	}
+}
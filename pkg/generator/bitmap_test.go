@@ -0,0 +1,340 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// bitmapTestSchema has a @bitmap root with eleven optional fields, so its
+// bitmap takes two bytes, and a @bitmap struct nested both optionally and
+// in an array.
+const bitmapTestSchema = `package bits
+
+type Mode int8
+
+const (
+	Off Mode = 0
+	On  Mode = 1
+)
+
+type Circle struct {
+	R float32
+}
+
+type Shape interface {
+	Circle | string
+}
+
+// @bitmap
+type Meta struct {
+	Note *string
+	Rank *int32
+}
+
+// @bitmap
+type Record struct {
+	ID    int64
+	Name  string
+	A     *int8
+	B     *int16
+	C     *float64
+	D     *bool
+	E     *string
+	F     *bytes
+	G     *[]int32
+	H     *map[string]int32
+	I     *Mode
+	J     *Shape
+	K     *Meta
+	Items []Meta
+}
+`
+
+var bitmapTestValues = []string{
+	`{"ID": 1, "Name": "none", "Items": []}`,
+	`{"ID": 2, "Name": "all", "A": -3, "B": 300, "C": 2.5, "D": true, "E": "e", "F": "aGk=",
+	  "G": [1, 2], "H": {"x": 1, "y": 2}, "I": "On", "J": {"Circle": {"R": 1.5}},
+	  "K": {"Note": "n", "Rank": 7}, "Items": [{}, {"Rank": 1}, {"Note": "only"}]}`,
+	`{"ID": 3, "Name": "some", "D": false, "J": {"string": "s"}, "K": {}, "Items": [{"Note": "a", "Rank": 2}]}`,
+}
+
+// bitmapFixtures parses bitmapTestSchema, generates code for it with gen
+// and returns the reference encodings of bitmapTestValues.
+func bitmapFixtures(t *testing.T, gen func(*schema.Schema) ([]byte, error)) ([]byte, [][]byte) {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(bitmapTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := gen(s) // canonicalizes s
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	var payloads [][]byte
+	for _, value := range bitmapTestValues {
+		data, err := fixture.Convert(s, "Record", []byte(value))
+		if err != nil {
+			t.Fatalf("fixture encode failed: %v", err)
+		}
+		payloads = append(payloads, data)
+	}
+	return code, payloads
+}
+
+// checkBitmapOutputs compares the re-encodings a test program wrote to
+// <i><ext> in dir with the payloads it read.
+func checkBitmapOutputs(t *testing.T, dir string, payloads [][]byte, exts ...string) {
+	t.Helper()
+	for i, expected := range payloads {
+		for _, ext := range exts {
+			actual, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d%s", i, ext)))
+			if err != nil {
+				t.Errorf("value %d%s: no output: %v", i, ext, err)
+				continue
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("value %d%s: re-encoding differs\nwant % x\ngot  % x", i, ext, expected, actual)
+			}
+		}
+	}
+}
+
+func TestGenerateGoBitmap(t *testing.T) {
+	code, _ := bitmapFixtures(t, GenerateGo)
+	src := string(code)
+	for _, want := range []string{
+		"buf[pos] = bits",
+		"data[pos : pos+2]",
+		"[1]&0x04 != 0 {",
+		`{Name: "@bitmap", Decode: func() error {`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code lacks %q", want)
+		}
+	}
+}
+
+// TestGenerateBitmap checks the bitmap code of the generators whose
+// toolchains the roundtrip tests cannot assume.
+func TestGenerateBitmap(t *testing.T) {
+	tests := []struct {
+		lang string
+		gen  func(*schema.Schema) ([]byte, error)
+		want []string
+	}{
+		{"kotlin", func(s *schema.Schema) ([]byte, error) { return generateKotlinNative(s, "bits") }, []string{
+			"w.bitmap(this.note != null, this.rank != null)",
+			"val bits = r.bitmap(11)",
+			"k = if ((bits[1].toInt() and 0x04) != 0) Meta.readFrom(r) else null,",
+		}},
+		{"swift", generateSwiftNative, []string{
+			"if message.K != nil { bits1 |= 0x04 }",
+			"if bits1 & 0xf8 != 0 { throw FFireError.badPresence }",
+		}},
+		{"java", GenerateJava, []string{
+			"if (K != null) bits1 |= 0x04;",
+			"if ((bits1 & 0xf8) != 0) {",
+			"DecodeErrorCode.BAD_PRESENCE",
+		}},
+		{"csharp", GenerateCSharp, []string{
+			"public Meta? K { get; set; }",
+			"if (K != null) bits1 |= 0x04;",
+			"if ((bits1 & 0xf8) != 0) throw new DecodeException(DecodeErrorCode.BadPresence",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			code, _ := bitmapFixtures(t, tt.gen)
+			for _, want := range tt.want {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("generated code lacks %q", want)
+				}
+			}
+		})
+	}
+}
+
+// TestGoBitmapRoundtrip decodes reference payloads with generated Go, with
+// Decode and the lenient decoder, and checks that they re-encode to the
+// same bytes.
+func TestGoBitmapRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	code, payloads := bitmapFixtures(t, GenerateGo)
+
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "bits"), 0755)
+	files := map[string]string{
+		"go.mod":       "module bitmap\n\ngo 1.21\n",
+		"bits/bits.go": string(code),
+		"main.go": `package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"bitmap/bits"
+)
+
+func main() {
+	for i := 0; ; i++ {
+		data, err := os.ReadFile(fmt.Sprint(i, ".bin"))
+		if err != nil {
+			return
+		}
+		var m bits.RecordMessage
+		if err := m.Decode(data); err != nil {
+			panic(err)
+		}
+		if m.EncodedSize() != len(data) {
+			panic(fmt.Sprint("EncodedSize ", m.EncodedSize(), ", encoded ", len(data)))
+		}
+		os.WriteFile(fmt.Sprint(i, ".out"), m.Encode(), 0644)
+
+		lenient, errs := bits.DecodeRecordMessageLenient(data)
+		if len(errs) != 0 {
+			panic(fmt.Sprint(errs))
+		}
+		os.WriteFile(fmt.Sprint(i, ".lenient"), lenient.Encode(), 0644)
+
+		// Eleven optional fields leave five unused bits in the second byte
+		data[1] |= 0x80
+		var derr *bits.DecodeError
+		if err := m.Decode(data); !errors.As(err, &derr) || derr.Code != bits.DecodeBadPresence {
+			panic(fmt.Sprint("unused bits: ", err))
+		}
+	}
+}
+`,
+	}
+	for i, data := range payloads {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	checkBitmapOutputs(t, tmpDir, payloads, ".out", ".lenient")
+}
+
+// TestCppBitmapRoundtrip decodes reference payloads with generated C++ and
+// checks that they re-encode to the same bytes, and that set unused bitmap
+// bits are rejected.
+func TestCppBitmapRoundtrip(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	code, payloads := bitmapFixtures(t, GenerateCpp)
+
+	dir := t.TempDir()
+	src := `#include "bits.hpp"
+#include <fstream>
+#include <iterator>
+#include <string>
+
+int main() {
+    for (int i = 0; ; i++) {
+        std::ifstream in(std::to_string(i) + ".bin", std::ios::binary);
+        if (!in) return 0;
+        std::vector<uint8_t> data((std::istreambuf_iterator<char>(in)), std::istreambuf_iterator<char>());
+        auto out = bits::encode_record_message(bits::decode_record_message(data));
+        std::ofstream(std::to_string(i) + ".out", std::ios::binary).write(reinterpret_cast<const char*>(out.data()), out.size());
+
+        // Eleven optional fields leave five unused bits in the second byte
+        data[1] |= 0x80;
+        try {
+            bits::decode_record_message(data);
+            return 1;
+        } catch (const std::runtime_error&) {
+        }
+    }
+}
+`
+	files := map[string]string{"bits.hpp": string(code), "main.cpp": src}
+	for i, data := range payloads {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bin := filepath.Join(dir, "roundtrip")
+	if out, err := exec.Command("g++", "-std=c++17", "-O2", "-o", bin, filepath.Join(dir, "main.cpp")).CombinedOutput(); err != nil {
+		t.Fatalf("g++ failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("roundtrip failed: %v\n%s", err, out)
+	}
+	checkBitmapOutputs(t, dir, payloads, ".out")
+}
+
+// TestRustBitmapRoundtrip decodes reference payloads with generated Rust
+// and checks that they re-encode to the same bytes, and that set unused
+// bitmap bits are rejected.
+func TestRustBitmapRoundtrip(t *testing.T) {
+	if _, err := exec.LookPath("rustc"); err != nil {
+		t.Skip("rustc not installed")
+	}
+	code, payloads := bitmapFixtures(t, generateRustNative)
+
+	dir := t.TempDir()
+	src := `#[allow(dead_code)]
+mod bits;
+
+fn main() {
+    for i in 0.. {
+        let Ok(mut data) = std::fs::read(format!("{}.bin", i)) else { return };
+        let m = bits::decode_record_message(&data).unwrap();
+        std::fs::write(format!("{}.out", i), bits::encode_record_message(&m)).unwrap();
+
+        // Eleven optional fields leave five unused bits in the second byte
+        data[1] |= 0x80;
+        assert_eq!(bits::decode_record_message(&data), Err(bits::FFireError::BadPresence));
+    }
+}
+`
+	files := map[string]string{"bits.rs": string(code), "main.rs": src}
+	for i, data := range payloads {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bin := filepath.Join(dir, "roundtrip")
+	if out, err := exec.Command("rustc", "--edition", "2021", "-o", bin, filepath.Join(dir, "main.rs")).CombinedOutput(); err != nil {
+		t.Fatalf("rustc failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("roundtrip failed: %v\n%s", err, out)
+	}
+	checkBitmapOutputs(t, dir, payloads, ".out")
+}
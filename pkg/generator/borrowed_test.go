@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestGenerateGoBorrowedDecode(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(lenientTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	if src := string(code); strings.Contains(src, "DecodeBorrowed") || !strings.Contains(src, "// The result does not alias data") {
+		t.Error("default output should document that Decode copies and have no DecodeBorrowed")
+	}
+
+	code, err = GenerateGoWithOptions(s, GoOptions{Borrowed: true})
+	if err != nil {
+		t.Fatalf("GenerateGoWithOptions failed: %v", err)
+	}
+	src := string(code)
+	for _, want := range []string{
+		"func (v *EntryMessage) DecodeBorrowed(data []byte) error {",
+		"func (v *BatchMessage) DecodeBorrowed(data []byte) error {",
+		"unsafe.String(unsafe.SliceData(",
+		"// unsafe: DecodeBorrowed callers keep data unchanged while the result is in use",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("borrowed output lacks %q", want)
+		}
+	}
+	// Lenient decoding keeps copying
+	if strings.Contains(src, "DecodeEntryMessageLenientBorrowed") {
+		t.Error("lenient decoding should not get a borrowed variant")
+	}
+
+	config := &PackageConfig{Schema: s, Language: "rust", OutputDir: t.TempDir(), BorrowedDecode: true, NoCompile: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--borrowed-decode is not supported for rust") {
+		t.Errorf("GeneratePackage(rust) = %v, want unsupported error", err)
+	}
+}
+
+// TestGoDecodeOwnership checks for every testdata fixture that Decode
+// results survive the input being overwritten, and that DecodeBorrowed
+// decodes the same values while the input is intact.
+func TestGoDecodeOwnership(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module ownership\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var imports, checks strings.Builder
+	var names []string
+	schemas, _ := filepath.Glob("../../testdata/schema/*.ffi")
+	for _, schemaPath := range schemas {
+		name := strings.TrimSuffix(filepath.Base(schemaPath), ".ffi")
+		fixtureJSON, err := os.ReadFile(filepath.Join("../../testdata/json", name+".json"))
+		if err != nil {
+			continue
+		}
+
+		s, err := parser.Parse(schemaPath)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", name, err)
+		}
+		if len(s.Messages) != 1 {
+			continue
+		}
+		msg := s.Messages[0].Name
+
+		code, err := GenerateGoWithOptions(s, GoOptions{Borrowed: true}) // canonicalizes s
+		if err != nil {
+			t.Fatalf("%s: GenerateGo failed: %v", name, err)
+		}
+		expected, err := fixture.Convert(s, msg, fixtureJSON)
+		if err != nil {
+			t.Fatalf("%s: fixture encode failed: %v", name, err)
+		}
+
+		pkgDir := filepath.Join(tmpDir, name)
+		os.MkdirAll(pkgDir, 0755)
+		if err := os.WriteFile(filepath.Join(pkgDir, name+".go"), code, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name+".bin"), expected, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fmt.Fprintf(&imports, "\tp_%s \"ownership/%s\"\n", name, name)
+		fmt.Fprintf(&checks, "\tcheck(%q, func(data []byte) (func() []byte, error) {\n\t\tvar m p_%s.%sMessage\n\t\treturn m.Encode, m.Decode(data)\n\t}, func(data []byte) (func() []byte, error) {\n\t\tvar m p_%s.%sMessage\n\t\treturn m.Encode, m.DecodeBorrowed(data)\n\t})\n",
+			name, name, msg, name, msg)
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		t.Fatal("no testdata fixtures found")
+	}
+
+	// Encode is bound to the decoded value, so it sees what the value
+	// points at after the input is overwritten.
+	mainSrc := "package main\n\nimport (\n\t\"bytes\"\n\t\"os\"\n\n" + imports.String() + `)
+
+type decodeFunc func([]byte) (func() []byte, error)
+
+func check(name string, owned, borrowed decodeFunc) {
+	data, _ := os.ReadFile(name + ".bin")
+
+	input := bytes.Clone(data)
+	encode, err := owned(input)
+	if err != nil {
+		panic(err)
+	}
+	for i := range input {
+		input[i] = 0xa5
+	}
+	os.WriteFile(name+".owned", encode(), 0644)
+
+	input = bytes.Clone(data)
+	if encode, err = borrowed(input); err != nil {
+		panic(err)
+	}
+	os.WriteFile(name+".borrowed", encode(), 0644)
+}
+
+func main() {
+` + checks.String() + "}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(mainSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+
+	t.Logf("checked %d fixtures: %s", len(names), strings.Join(names, ", "))
+	for _, name := range names {
+		expected, _ := os.ReadFile(filepath.Join(tmpDir, name+".bin"))
+		for _, ext := range []string{".owned", ".borrowed"} {
+			actual, err := os.ReadFile(filepath.Join(tmpDir, name+ext))
+			if err != nil {
+				t.Errorf("%s%s: no output: %v", name, ext, err)
+				continue
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("%s%s: re-encoding differs from the input\nwant % x\ngot  % x", name, ext, expected, actual)
+			}
+		}
+	}
+}
+
+// TestGoDecodeBorrowedAliases shows the difference the two decoders make
+// to a caller that reuses its buffer.
+func TestGoDecodeBorrowedAliases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	s, err := parser.ParseBytes([]byte(lenientTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGoWithOptions(s, GoOptions{Borrowed: true})
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "logs"), 0755)
+	files := map[string]string{
+		"go.mod":       "module borrowed\n\ngo 1.21\n",
+		"logs/logs.go": string(code),
+		"main.go": `package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"borrowed/logs"
+)
+
+func main() {
+	host := "h1"
+	data := logs.EntryMessage{Msg: "hello", Host: &host, Tags: []string{"a"}}.Encode()
+
+	var owned, borrowed logs.EntryMessage
+	if err := owned.Decode(data); err != nil {
+		panic(err)
+	}
+	if err := borrowed.DecodeBorrowed(data); err != nil {
+		panic(err)
+	}
+	copy(data, bytes.Repeat([]byte("x"), len(data)))
+	fmt.Println(owned.Msg, *owned.Host, owned.Tags)
+	fmt.Println(borrowed.Msg, *borrowed.Host, borrowed.Tags)
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	if want := "hello h1 [a]\nxxxxx xx [x]\n"; string(out) != want {
+		t.Errorf("unexpected output\nwant:\n%s\ngot:\n%s", want, out)
+	}
+}
@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BuildLogName is the file, next to the package's lib directory, that
+// receives the full output of a failed native build.
+const BuildLogName = "build.log"
+
+// maxBuildDiagnostics is the number of compiler errors shown in a
+// BuildError message; the rest are in the log file.
+const maxBuildDiagnostics = 5
+
+// BuildFailure classifies why a native build failed.
+type BuildFailure int
+
+const (
+	BuildToolchainMissing BuildFailure = iota // Compiler not found on PATH
+	BuildCompileError                         // Compiler reported errors in the sources
+	BuildLinkError                            // Sources compiled but linking failed
+	BuildFailed                               // Non-zero exit without recognizable diagnostics
+)
+
+func (f BuildFailure) String() string {
+	switch f {
+	case BuildToolchainMissing:
+		return "toolchain missing"
+	case BuildCompileError:
+		return "compile error"
+	case BuildLinkError:
+		return "link error"
+	default:
+		return "build failed"
+	}
+}
+
+// Diagnostic is one compiler message in GCC/Clang format
+// (file:line:column: severity: message). Errors from the compiler driver
+// have the driver as File and no position.
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Severity string // "error" or "fatal error"
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", d.File, d.Severity, d.Message)
+	}
+	if d.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Line, d.Column, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", d.File, d.Line, d.Severity, d.Message)
+}
+
+// BuildError reports a failed native build. Errors holds the parsed
+// compiler errors, and LogPath the file with the complete command output.
+type BuildError struct {
+	Tool    string // Compiler executable
+	Target  string // File being built, e.g. libaudio.so
+	Failure BuildFailure
+	Errors  []Diagnostic
+	Linker  []string // Linker messages, for BuildLinkError
+	LogPath string
+	Err     error
+}
+
+func (e *BuildError) Error() string {
+	var b strings.Builder
+	switch e.Failure {
+	case BuildToolchainMissing:
+		fmt.Fprintf(&b, "%s not found on PATH, cannot build %s", e.Tool, e.Target)
+		if hint := toolchainHint(e.Tool); hint != "" {
+			fmt.Fprintf(&b, "\n  %s", hint)
+		}
+		b.WriteString("\n  Use --no-compile to generate sources only")
+		return b.String()
+	case BuildCompileError:
+		fmt.Fprintf(&b, "%s: %s reported %d error(s) building %s", e.Failure, e.Tool, len(e.Errors), e.Target)
+		for i, d := range e.Errors {
+			if i == maxBuildDiagnostics {
+				fmt.Fprintf(&b, "\n  ... and %d more", len(e.Errors)-i)
+				break
+			}
+			fmt.Fprintf(&b, "\n  %s", d)
+		}
+	case BuildLinkError:
+		fmt.Fprintf(&b, "%s: %s could not link %s", e.Failure, e.Tool, e.Target)
+		for i, line := range e.Linker {
+			if i == maxBuildDiagnostics {
+				fmt.Fprintf(&b, "\n  ... and %d more", len(e.Linker)-i)
+				break
+			}
+			fmt.Fprintf(&b, "\n  %s", line)
+		}
+	default:
+		fmt.Fprintf(&b, "%s: %s building %s: %v", e.Failure, e.Tool, e.Target, e.Err)
+	}
+	if e.LogPath != "" {
+		fmt.Fprintf(&b, "\n  Full log: %s", e.LogPath)
+	}
+	return b.String()
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+// toolchainHint suggests how to install a missing compiler.
+func toolchainHint(tool string) string {
+	switch {
+	case tool == "clang++" || tool == "clang":
+		return "Install the Xcode Command Line Tools: xcode-select --install"
+	case strings.Contains(tool, "mingw"):
+		return "Install the MinGW-w64 cross compiler (apt install mingw-w64, brew install mingw-w64)"
+	case tool == "g++" || tool == "gcc":
+		return "Install a C/C++ compiler (apt install build-essential, dnf install gcc-c++)"
+	}
+	return ""
+}
+
+// runBuild runs a native build command. On failure the full output is
+// written to logDir/build.log and a *BuildError summarizing it is returned.
+func runBuild(config *PackageConfig, cmd *exec.Cmd, target, logDir string) error {
+	tool := cmd.Args[0]
+	config.debugf("Running: %s\n", strings.Join(cmd.Args, " "))
+
+	logPath := filepath.Join(logDir, BuildLogName)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		if len(output) > 0 {
+			config.debugf("Compiler output:\n%s\n", string(output))
+		}
+		// Drop the log of an earlier failed build
+		os.Remove(logPath)
+		return nil
+	}
+
+	if ctxErr := config.context().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	buildErr := &BuildError{Tool: tool, Target: target, Err: err}
+	if errors.Is(err, exec.ErrNotFound) {
+		buildErr.Failure = BuildToolchainMissing
+		return buildErr
+	}
+
+	buildErr.Errors, buildErr.Linker = parseBuildOutput(output)
+	switch {
+	case len(buildErr.Errors) > 0:
+		buildErr.Failure = BuildCompileError
+	case len(buildErr.Linker) > 0:
+		buildErr.Failure = BuildLinkError
+	default:
+		buildErr.Failure = BuildFailed
+	}
+
+	if writeErr := writeBuildLog(logPath, cmd, err, output); writeErr == nil {
+		buildErr.LogPath = logPath
+	} else {
+		config.debugf("⚠ could not write build log: %v\n", writeErr)
+	}
+	return buildErr
+}
+
+func writeBuildLog(path string, cmd *exec.Cmd, err error, output []byte) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&buf, "# %s\n", strings.Join(cmd.Args, " "))
+	fmt.Fprintf(&buf, "# %v\n\n", err)
+	buf.Write(output)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// diagnosticPattern matches GCC and Clang diagnostics. The column is
+// optional (GCC omits it for some messages).
+var diagnosticPattern = regexp.MustCompile(`^(.+?):(\d+):(?:(\d+):)? (fatal error|error|warning): (.*)$`)
+
+// driverPattern matches errors from the compiler driver itself, such as an
+// unknown option ("g++: error: ...", "cc1plus: error: ...").
+var driverPattern = regexp.MustCompile(`^([\w.+-]+): (fatal error|error): (.*)$`)
+
+// linkerPatterns match linker failures from GNU ld, lld and Apple ld.
+var linkerPatterns = []string{
+	"undefined reference to",
+	"Undefined symbols for architecture",
+	"ld: ",
+	"ld.lld: error",
+	"collect2: error",
+}
+
+// parseBuildOutput extracts compiler errors and linker messages from the
+// combined output of a GCC or Clang invocation. Warnings are left to the log.
+func parseBuildOutput(output []byte) (errs []Diagnostic, linker []string) {
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := diagnosticPattern.FindStringSubmatch(line); m != nil {
+			if m[4] == "warning" {
+				continue
+			}
+			d := Diagnostic{File: m[1], Severity: m[4], Message: m[5]}
+			d.Line, _ = strconv.Atoi(m[2])
+			d.Column, _ = strconv.Atoi(m[3])
+			errs = append(errs, d)
+			continue
+		}
+		if m := driverPattern.FindStringSubmatch(line); m != nil && !isLinker(m[1]) {
+			errs = append(errs, Diagnostic{File: m[1], Severity: m[2], Message: m[3]})
+			continue
+		}
+		for _, p := range linkerPatterns {
+			if strings.Contains(line, p) {
+				linker = append(linker, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	return errs, linker
+}
+
+// isLinker reports whether a driver error comes from the linker, which
+// parseBuildOutput reports separately.
+func isLinker(tool string) bool {
+	return tool == "ld" || tool == "collect2" || strings.HasPrefix(tool, "ld.")
+}
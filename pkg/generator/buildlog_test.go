@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseBuildOutput(t *testing.T) {
+	output := `/out/cpp/src/generated_c.cpp: In function 'int f()':
+/out/cpp/src/generated_c.cpp:12:5: error: 'foo' was not declared in this scope
+   12 |     foo();
+      |     ^~~
+/out/cpp/include/generated.hpp:40: warning: unused variable 'x'
+/out/cpp/include/generated.hpp:41:1: fatal error: missing.h: No such file or directory
+compilation terminated.
+`
+	errs, linker := parseBuildOutput([]byte(output))
+	if len(linker) != 0 {
+		t.Errorf("unexpected linker messages: %v", linker)
+	}
+	want := []string{
+		"/out/cpp/src/generated_c.cpp:12:5: error: 'foo' was not declared in this scope",
+		"/out/cpp/include/generated.hpp:41:1: fatal error: missing.h: No such file or directory",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, d := range errs {
+		if d.String() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, d, want[i])
+		}
+	}
+	if errs[0].Line != 12 || errs[0].Column != 5 {
+		t.Errorf("position = %d:%d, want 12:5", errs[0].Line, errs[0].Column)
+	}
+
+	errs, _ = parseBuildOutput([]byte("cc1plus: error: '-Werror=bogus': no option -Wbogus\n"))
+	if len(errs) != 1 || errs[0].String() != "cc1plus: error: '-Werror=bogus': no option -Wbogus" {
+		t.Errorf("driver error not parsed: %v", errs)
+	}
+
+	_, linker = parseBuildOutput([]byte("/usr/bin/ld: /tmp/x.o: in function `main':\nx.c:(.text+0x9): undefined reference to `bar'\ncollect2: error: ld returned 1 exit status\n"))
+	if len(linker) != 3 {
+		t.Errorf("got %d linker messages, want 3: %v", len(linker), linker)
+	}
+}
+
+func TestRunBuildToolchainMissing(t *testing.T) {
+	dir := t.TempDir()
+	cmd := exec.Command("ffire-no-such-compiler", "-c", "x.cpp")
+	err := runBuild(&PackageConfig{}, cmd, "libx.so", dir)
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("err = %v, want *BuildError", err)
+	}
+	if buildErr.Failure != BuildToolchainMissing {
+		t.Errorf("Failure = %v, want %v", buildErr.Failure, BuildToolchainMissing)
+	}
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Error("error should wrap exec.ErrNotFound")
+	}
+	if !strings.Contains(err.Error(), "--no-compile") {
+		t.Errorf("message should suggest --no-compile: %v", err)
+	}
+}
+
+func TestRunBuildCompileError(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "bad.cpp")
+	if err := os.WriteFile(src, []byte("int f() { return undeclared; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("g++", "-c", "-o", filepath.Join(dir, "bad.o"), src)
+	err := runBuild(&PackageConfig{}, cmd, "bad.o", dir)
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("err = %v, want *BuildError", err)
+	}
+	if buildErr.Failure != BuildCompileError || len(buildErr.Errors) != 1 {
+		t.Fatalf("Failure = %v with %d errors, want one compile error\n%v", buildErr.Failure, len(buildErr.Errors), err)
+	}
+	if !strings.Contains(err.Error(), "bad.cpp:1:") || !strings.Contains(err.Error(), "Full log: "+filepath.Join(dir, BuildLogName)) {
+		t.Errorf("message does not reference the source and log:\n%v", err)
+	}
+
+	log, readErr := os.ReadFile(buildErr.LogPath)
+	if readErr != nil {
+		t.Fatalf("build log not written: %v", readErr)
+	}
+	if !strings.Contains(string(log), "g++ -c") || !strings.Contains(string(log), "undeclared") {
+		t.Errorf("build log lacks command or output:\n%s", log)
+	}
+
+	// A successful build removes the stale log
+	if err := os.WriteFile(src, []byte("int f() { return 0; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command("g++", "-c", "-o", filepath.Join(dir, "bad.o"), src)
+	if err := runBuild(&PackageConfig{}, cmd, "bad.o", dir); err != nil {
+		t.Fatalf("runBuild failed: %v", err)
+	}
+	if _, err := os.Stat(buildErr.LogPath); !os.IsNotExist(err) {
+		t.Error("stale build log was not removed")
+	}
+}
@@ -0,0 +1,183 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Build systems for PackageConfig.BuildRules.
+const (
+	BuildRulesBazel = "bazel" // BUILD.bazel with rules_go, rules_cc, rules_swift and rules_java
+	BuildRulesBuck  = "buck"  // BUCK with the Buck2 prelude rules
+)
+
+// buildRulesLanguages lists the languages each build system gets rules for.
+var buildRulesLanguages = map[string][]string{
+	BuildRulesBazel: {"go", "cpp", "swift", "java"},
+	BuildRulesBuck:  {"go", "cpp", "java"},
+}
+
+// checkBuildRules rejects build systems and languages without rules before
+// anything is generated.
+func checkBuildRules(config *PackageConfig) error {
+	if config.BuildRules == "" {
+		return nil
+	}
+	langs, ok := buildRulesLanguages[config.BuildRules]
+	if !ok {
+		return fmt.Errorf("unknown build system %q (supported: %s, %s)", config.BuildRules, BuildRulesBazel, BuildRulesBuck)
+	}
+	if !contains(langs, canonicalLanguage(config.Language)) {
+		return fmt.Errorf("%s rules are not supported for %s (supported: %s)", config.BuildRules, config.Language, strings.Join(langs, ", "))
+	}
+	return nil
+}
+
+// buildTarget is one library rule of a generated package. Paths are
+// relative to the package directory.
+type buildTarget struct {
+	name       string
+	importPath string   // Go
+	module     string   // Swift
+	srcs       []string // Sources
+	hdrs       []string // C++ public headers
+}
+
+// generateBuildRules writes a BUILD.bazel or BUCK file declaring the
+// generated package as a library, so other targets can depend on it.
+func generateBuildRules(config *PackageConfig) error {
+	lang := canonicalLanguage(config.Language)
+	dir := config.OutputDir
+	switch lang {
+	case "cpp":
+		dir = config.langDir(config.Language)
+	case "swift":
+		dir = config.langDir(SwiftLayout.Name)
+	}
+	files, err := packageFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	t := buildTarget{name: config.Namespace}
+	switch lang {
+	case "go":
+		t.importPath = valueOr(config.Coordinates.Go, config.Namespace)
+		t.srcs = matchFiles(files, "", ".go", "_test.go")
+	case "cpp":
+		t.hdrs = matchFiles(files, "include/", ".h", "")
+		t.hdrs = append(t.hdrs, matchFiles(files, "include/", ".hpp", "")...)
+		t.srcs = matchFiles(files, "src/", ".cpp", "")
+	case "swift":
+		t.module = config.Namespace
+		t.srcs = matchFiles(files, "Sources/"+config.Namespace+"/", ".swift", "")
+	case "java":
+		t.srcs = matchFiles(files, "src/", ".java", "")
+	}
+	if len(t.srcs) == 0 {
+		return fmt.Errorf("no %s sources in %s", lang, dir)
+	}
+
+	var name string
+	var content []byte
+	if config.BuildRules == BuildRulesBuck {
+		name, content = "BUCK", buckRules(lang, t)
+	} else {
+		name, content = "BUILD.bazel", bazelRules(lang, t)
+	}
+	rulesPath := filepath.Join(dir, name)
+	if err := os.WriteFile(rulesPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	config.infof("✓ Generated %s: %s\n", name, rulesPath)
+	return nil
+}
+
+// matchFiles returns the files under prefix that end in suffix but not in
+// exclude. An empty prefix matches only top-level files.
+func matchFiles(files []string, prefix, suffix, exclude string) []string {
+	var matched []string
+	for _, f := range files {
+		if prefix == "" && path.Dir(f) != "." {
+			continue
+		}
+		if !strings.HasPrefix(f, prefix) || !strings.HasSuffix(f, suffix) {
+			continue
+		}
+		if exclude != "" && strings.HasSuffix(f, exclude) {
+			continue
+		}
+		matched = append(matched, f)
+	}
+	return matched
+}
+
+func bazelRules(lang string, t buildTarget) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Code generated by ffire. DO NOT EDIT.\n\n")
+	switch lang {
+	case "go":
+		buf.WriteString("load(\"@rules_go//go:def.bzl\", \"go_library\")\n\n")
+		fmt.Fprintf(buf, "go_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+		fmt.Fprintf(buf, "    importpath = %q,\n", t.importPath)
+	case "cpp":
+		buf.WriteString("load(\"@rules_cc//cc:defs.bzl\", \"cc_library\")\n\n")
+		fmt.Fprintf(buf, "cc_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+		writeStarlarkList(buf, "hdrs", t.hdrs)
+		buf.WriteString("    copts = [\"-std=c++17\"],\n")
+		buf.WriteString("    includes = [\"include\"],\n")
+	case "swift":
+		buf.WriteString("load(\"@rules_swift//swift:swift.bzl\", \"swift_library\")\n\n")
+		fmt.Fprintf(buf, "swift_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+		fmt.Fprintf(buf, "    module_name = %q,\n", t.module)
+	case "java":
+		buf.WriteString("load(\"@rules_java//java:defs.bzl\", \"java_library\")\n\n")
+		fmt.Fprintf(buf, "java_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+	}
+	buf.WriteString("    visibility = [\"//visibility:public\"],\n)\n")
+	return buf.Bytes()
+}
+
+func buckRules(lang string, t buildTarget) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Code generated by ffire. DO NOT EDIT.\n\n")
+	switch lang {
+	case "go":
+		fmt.Fprintf(buf, "go_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+		fmt.Fprintf(buf, "    package_name = %q,\n", t.importPath)
+	case "cpp":
+		fmt.Fprintf(buf, "cxx_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+		// Headers are included by file name, as in the C++ sources
+		buf.WriteString("    header_namespace = \"\",\n")
+		buf.WriteString("    exported_headers = {\n")
+		for _, h := range t.hdrs {
+			fmt.Fprintf(buf, "        %q: %q,\n", path.Base(h), h)
+		}
+		buf.WriteString("    },\n")
+		buf.WriteString("    compiler_flags = [\"-std=c++17\"],\n")
+	case "java":
+		fmt.Fprintf(buf, "java_library(\n    name = %q,\n", t.name)
+		writeStarlarkList(buf, "srcs", t.srcs)
+	}
+	buf.WriteString("    visibility = [\"PUBLIC\"],\n)\n")
+	return buf.Bytes()
+}
+
+// writeStarlarkList writes a list attribute, one element per line.
+func writeStarlarkList(buf *bytes.Buffer, attr string, values []string) {
+	fmt.Fprintf(buf, "    %s = [\n", attr)
+	for _, v := range values {
+		fmt.Fprintf(buf, "        %q,\n", v)
+	}
+	buf.WriteString("    ],\n")
+}
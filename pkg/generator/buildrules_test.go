@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestBuildRules(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pkg := s.Package
+	tests := []struct {
+		system string
+		lang   string
+		file   string // Relative to the package directory
+		want   []string
+		reject []string
+	}{
+		{"bazel", "go", "BUILD.bazel", []string{
+			`load("@rules_go//go:def.bzl", "go_library")`,
+			`"` + pkg + `.go",`,
+			`importpath = "example.com/acme/audio",`,
+		}, []string{"_test.go"}},
+		{"bazel", "cpp", "BUILD.bazel", []string{
+			"cc_library(",
+			`"src/generated_c.cpp",`,
+			`"include/generated.hpp",`,
+			`"include/generated_c.h",`,
+			`includes = ["include"],`,
+		}, []string{"roundtrip_test.cpp"}},
+		{"bazel", "swift", "BUILD.bazel", []string{
+			"swift_library(",
+			`"Sources/` + pkg + `/Generated.swift",`,
+			`module_name = "` + pkg + `",`,
+		}, nil},
+		{"bazel", "java", "BUILD.bazel", []string{
+			`load("@rules_java//java:defs.bzl", "java_library")`,
+			`"src/` + pkg + `/` + pkg + `.java",`,
+		}, []string{"RoundtripTest"}},
+		{"buck", "go", "BUCK", []string{"go_library(", `package_name = "example.com/acme/audio",`, `visibility = ["PUBLIC"],`}, nil},
+		{"buck", "cpp", "BUCK", []string{"cxx_library(", `"generated.hpp": "include/generated.hpp",`, `header_namespace = "",`}, nil},
+		{"buck", "java", "BUCK", []string{"java_library(", `"src/` + pkg + `/` + pkg + `.java",`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.system+"/"+tt.lang, func(t *testing.T) {
+			root := t.TempDir()
+			config := &PackageConfig{
+				Schema:      s,
+				Language:    tt.lang,
+				OutputDir:   root,
+				Layout:      LayoutMonorepo,
+				BuildRules:  tt.system,
+				Coordinates: Coordinates{Go: "example.com/acme/audio"},
+				WithTests:   true,
+				NoCompile:   true,
+				NoFormat:    true,
+			}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(config.OutputDir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rules := string(data)
+			for _, want := range tt.want {
+				if !strings.Contains(rules, want) {
+					t.Errorf("%s lacks %s:\n%s", tt.file, want, rules)
+				}
+			}
+			for _, reject := range tt.reject {
+				if strings.Contains(rules, reject) {
+					t.Errorf("%s lists %s:\n%s", tt.file, reject, rules)
+				}
+			}
+
+			// The rules are part of the artifact in the manifest
+			m, err := ReadManifest(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Artifacts) != 1 || !contains(m.Artifacts[0].Files, tt.file) {
+				t.Errorf("manifest does not list %s: %+v", tt.file, m.Artifacts)
+			}
+		})
+	}
+}
+
+func TestBuildRulesErrors(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tests := []struct {
+		system, lang, want string
+	}{
+		{"make", "go", "unknown build system"},
+		{"bazel", "python", "bazel rules are not supported for python"},
+		{"buck", "swift", "buck rules are not supported for swift"},
+	}
+	for _, tt := range tests {
+		out := t.TempDir()
+		config := &PackageConfig{Schema: s, Language: tt.lang, OutputDir: out, BuildRules: tt.system, NoCompile: true}
+		if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s/%s: err = %v, want %q", tt.system, tt.lang, err, tt.want)
+		}
+		if entries, _ := os.ReadDir(out); len(entries) != 0 {
+			t.Errorf("%s/%s: generated files before failing", tt.system, tt.lang)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Native builds use a platform default compiler (clang++ on macOS, g++ on
+// Linux, MinGW for Windows) unless PackageConfig says otherwise. For builds
+// targeting the host platform, unset PackageConfig values fall back to the
+// conventional CC, CXX, CFLAGS, CXXFLAGS and LDFLAGS environment variables.
+// Cross builds ignore the environment, since it describes the host
+// toolchain.
+
+// isHostBuild reports whether the native library is built for the platform
+// ffire runs on.
+func (c *PackageConfig) isHostBuild() bool {
+	return c.Platform == "" || c.Platform == runtime.GOOS
+}
+
+// cxxCommand returns the C++ compiler command, which may carry arguments
+// (e.g. "ccache g++" or "zig c++ -target aarch64-linux-musl").
+func (c *PackageConfig) cxxCommand(def string) ([]string, error) {
+	return c.buildSetting("CXX", c.CXX, def)
+}
+
+// ccCommand returns the C compiler command.
+func (c *PackageConfig) ccCommand(def string) ([]string, error) {
+	return c.buildSetting("CC", c.CC, def)
+}
+
+// cxxFlags returns extra C++ compiler flags, added after ffire's own so they
+// can override them.
+func (c *PackageConfig) cxxFlags() ([]string, error) {
+	return c.buildSetting("CXXFLAGS", c.CXXFlags, "")
+}
+
+// cFlags returns extra C compiler flags.
+func (c *PackageConfig) cFlags() ([]string, error) {
+	return c.buildSetting("CFLAGS", c.CFlags, "")
+}
+
+// ldFlags returns extra linker flags, added after the sources.
+func (c *PackageConfig) ldFlags() ([]string, error) {
+	return c.buildSetting("LDFLAGS", c.LDFlags, "")
+}
+
+func (c *PackageConfig) buildSetting(envVar, configured, def string) ([]string, error) {
+	value, source := configured, "--"+strings.ToLower(envVar)
+	if value == "" && c.isHostBuild() {
+		value, source = os.Getenv(envVar), "$"+envVar
+	}
+	if strings.TrimSpace(value) == "" {
+		value, source = def, ""
+	}
+	args, err := splitFlags(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", source, err)
+	}
+	return args, nil
+}
+
+// splitFlags splits a flag string the way a POSIX shell splits words:
+// whitespace separates arguments, single and double quotes group them, and
+// a backslash escapes the next character outside single quotes.
+func splitFlags(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case quote == '"':
+			switch {
+			case r == '"':
+				quote = 0
+			case r == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]):
+				i++
+				cur.WriteRune(runes[i])
+			default:
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("trailing backslash in %q", s)
+			}
+			i++
+			cur.WriteRune(runes[i])
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %q", quote, s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestSplitFlags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  -O3   -g ", []string{"-O3", "-g"}},
+		{`--sysroot="/opt/my sysroot" -DNAME='a b'`, []string{"--sysroot=/opt/my sysroot", "-DNAME=a b"}},
+		{`-DQ=\"x\" a\ b`, []string{`-DQ="x"`, "a b"}},
+		{`"" -x`, []string{"", "-x"}},
+		{`"say \"hi\""`, []string{`say "hi"`}},
+	}
+	for _, tt := range tests {
+		got, err := splitFlags(tt.in)
+		if err != nil {
+			t.Errorf("splitFlags(%q) error: %v", tt.in, err)
+			continue
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitFlags(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`-I"unterminated`, `trailing\`} {
+		if _, err := splitFlags(bad); err == nil {
+			t.Errorf("splitFlags(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBuildSettingPrecedence(t *testing.T) {
+	t.Setenv("CXX", "ccache clang++")
+	t.Setenv("CXXFLAGS", "-march=native")
+
+	host := &PackageConfig{Platform: runtime.GOOS}
+	if got, _ := host.cxxCommand("g++"); strings.Join(got, " ") != "ccache clang++" {
+		t.Errorf("host build: CXX = %q, want the environment", got)
+	}
+	if got, _ := host.cxxFlags(); strings.Join(got, " ") != "-march=native" {
+		t.Errorf("host build: CXXFLAGS = %q, want the environment", got)
+	}
+
+	host.CXX = "zig c++ -target x86_64-linux-musl"
+	if got, _ := host.cxxCommand("g++"); strings.Join(got, " ") != "zig c++ -target x86_64-linux-musl" {
+		t.Errorf("configured CXX = %q, want the config value", got)
+	}
+
+	cross := &PackageConfig{Platform: "windows"}
+	if runtime.GOOS == "windows" {
+		cross.Platform = "linux"
+	}
+	if got, _ := cross.cxxCommand("x86_64-w64-mingw32-g++"); strings.Join(got, " ") != "x86_64-w64-mingw32-g++" {
+		t.Errorf("cross build: CXX = %q, want the platform default", got)
+	}
+	if got, _ := cross.cxxFlags(); len(got) != 0 {
+		t.Errorf("cross build: CXXFLAGS = %q, want none", got)
+	}
+
+	bad := &PackageConfig{LDFlags: `-L"/opt/lib`}
+	if _, err := bad.ldFlags(); err == nil || !strings.Contains(err.Error(), "--ldflags") {
+		t.Errorf("invalid --ldflags: err = %v", err)
+	}
+}
+
+func TestCompileDylibUsesConfiguredCompiler(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("platform without a default native build")
+	}
+	logPath := fakeFormatter(t, "my-cxx")
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	out := t.TempDir()
+	config := &PackageConfig{
+		Schema:    s,
+		Language:  "dart",
+		OutputDir: out,
+		Platform:  runtime.GOOS,
+		Optimize:  2,
+		NoFormat:  true,
+		CXX:       "my-cxx --driver-mode=g++",
+		CXXFlags:  "-O3 --sysroot=/opt/sysroot",
+		LDFlags:   "-static-libstdc++",
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	logged, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("configured compiler was not run: %v", err)
+	}
+	args := strings.Fields(string(logged))
+	index := func(arg string) int {
+		for i, a := range args {
+			if a == arg {
+				return i
+			}
+		}
+		t.Fatalf("compiler args %v lack %s", args, arg)
+		return -1
+	}
+	src := index(filepath.Join(out, "dart", "src", "generated_c.cpp"))
+	if args[0] != "--driver-mode=g++" {
+		t.Errorf("compiler arguments should come first: %v", args)
+	}
+	if index("-O2") > index("-O3") {
+		t.Errorf("--cxxflags should follow the default flags: %v", args)
+	}
+	if index("--sysroot=/opt/sysroot") > src || index("-static-libstdc++") < src {
+		t.Errorf("--cxxflags should precede and --ldflags follow the source: %v", args)
+	}
+}
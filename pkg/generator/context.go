@@ -0,0 +1,28 @@
+package generator
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// waitDelay bounds how long a canceled command may hold on to its output.
+const waitDelay = 2 * time.Second
+
+// context returns the context of the running GeneratePackageContext call.
+func (c *PackageConfig) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// command is exec.Command for compilers, formatters, linters and hooks.
+// The process is killed when the generation context is canceled; callers
+// then return the context's error rather than "signal: killed". Grandchildren
+// that keep its output open (a hook's "sleep") get waitDelay to exit.
+func (c *PackageConfig) command(name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(c.context(), name, args...)
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
@@ -0,0 +1,43 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGeneratePackageContextCanceled(t *testing.T) {
+	config := hookTestConfig(t, Hooks{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := GeneratePackageContext(ctx, config); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GeneratePackageContext error = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "hooks.go")); err == nil {
+		t.Error("package generated after the context was canceled")
+	}
+}
+
+func TestGeneratePackageContextKillsHook(t *testing.T) {
+	config := hookTestConfig(t, Hooks{
+		Pre: []Hook{{Name: "slow", Run: "sleep 30"}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := GeneratePackageContext(ctx, config)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GeneratePackageContext error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("hook was not killed: returned after %s", elapsed)
+	}
+	if config.ctx != nil {
+		t.Error("context still set on the config after return")
+	}
+}
@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Coordinates override the names generated packages are published under.
+// Empty fields keep the defaults derived from the namespace.
+type Coordinates struct {
+	Go      string           `yaml:"go"`      // Go import path of the package (Bazel importpath)
+	Maven   MavenCoordinates `yaml:"maven"`   // Java pom.xml and Kotlin build.gradle.kts
+	NPM     NPMCoordinates   `yaml:"npm"`     // JavaScript package.json
+	PyPI    string           `yaml:"pypi"`    // Python distribution name (the import name stays the schema package)
+	NuGet   string           `yaml:"nuget"`   // C# package id
+	SwiftPM string           `yaml:"swiftpm"` // Swift library product name (the module stays the namespace)
+	Pub     string           `yaml:"pub"`     // Dart package name
+	Crate   string           `yaml:"crate"`   // Rust crate name on crates.io (the library stays the namespace)
+}
+
+// MavenCoordinates identify a Java artifact.
+type MavenCoordinates struct {
+	GroupID    string `yaml:"group_id"`    // Default: com.ffire
+	ArtifactID string `yaml:"artifact_id"` // Default: the namespace
+}
+
+// NPMCoordinates identify a JavaScript package.
+type NPMCoordinates struct {
+	Scope string `yaml:"scope"` // e.g. "@acme"; unscoped if empty
+	Name  string `yaml:"name"`  // Default: the namespace
+}
+
+// defaultMavenGroupID is the groupId of Java packages without a configured one.
+const defaultMavenGroupID = "com.ffire"
+
+// Naming rules of each registry, checked before anything is written so a
+// bad name fails here rather than at publish time.
+var coordinateRules = []struct {
+	key     string
+	value   func(Coordinates) string
+	pattern *regexp.Regexp
+	rule    string
+}{
+	{"go", func(c Coordinates) string { return c.Go },
+		regexp.MustCompile(`^[A-Za-z0-9._~-]+(/[A-Za-z0-9._~-]+)*$`), "a slash-separated import path, e.g. example.com/acme/audio"},
+	{"maven.group_id", func(c Coordinates) string { return c.Maven.GroupID },
+		regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`), "dot-separated Java identifiers, e.g. com.example"},
+	{"maven.artifact_id", func(c Coordinates) string { return c.Maven.ArtifactID },
+		regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`), "letters, digits, '_', '.' and '-'"},
+	{"npm.scope", func(c Coordinates) string { return c.NPM.Scope },
+		regexp.MustCompile(`^@[a-z0-9][a-z0-9._~-]*$`), "'@' followed by lowercase letters, digits, '.', '_', '~' and '-'"},
+	{"npm.name", func(c Coordinates) string { return c.NPM.Name },
+		regexp.MustCompile(`^[a-z0-9][a-z0-9._~-]{0,213}$`), "lowercase letters, digits, '.', '_', '~' and '-'"},
+	{"pypi", func(c Coordinates) string { return c.PyPI },
+		regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`), "letters, digits, '.', '_' and '-', starting and ending with a letter or digit"},
+	{"nuget", func(c Coordinates) string { return c.NuGet },
+		regexp.MustCompile(`^[A-Za-z0-9_]+([.-][A-Za-z0-9_]+)*$`), "letters, digits and '_' separated by '.' or '-'"},
+	{"swiftpm", func(c Coordinates) string { return c.SwiftPM },
+		regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`), "letters, digits, '_', '.' and '-'"},
+	{"pub", func(c Coordinates) string { return c.Pub },
+		regexp.MustCompile(`^[a-z_][a-z0-9_]*$`), "a lowercase Dart identifier, e.g. acme_audio"},
+	{"crate", func(c Coordinates) string { return c.Crate },
+		regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`), "at most 64 letters, digits, '_' and '-', starting with a letter"},
+}
+
+// Validate checks each set coordinate against its registry's naming rules.
+// Errors name the ffire.yaml key, e.g. "packages.npm.scope".
+func (c Coordinates) Validate() error {
+	for _, r := range coordinateRules {
+		if v := r.value(c); v != "" && !r.pattern.MatchString(v) {
+			return fmt.Errorf("packages.%s: invalid name %q: want %s", r.key, v, r.rule)
+		}
+	}
+	return nil
+}
+
+// npmName returns the package.json name, with its scope if one is set.
+func (c *PackageConfig) npmName() string {
+	name := valueOr(c.Coordinates.NPM.Name, c.Namespace)
+	if c.Coordinates.NPM.Scope != "" {
+		return c.Coordinates.NPM.Scope + "/" + name
+	}
+	return name
+}
+
+// publishedName returns the name the package for config.Language is
+// published under, e.g. "com.ffire:audio" for Java.
+func publishedName(c *PackageConfig) string {
+	switch canonicalLanguage(c.Language) {
+	case "go":
+		return valueOr(c.Coordinates.Go, c.Namespace)
+	case "java", "kotlin":
+		return valueOr(c.Coordinates.Maven.GroupID, defaultMavenGroupID) + ":" + valueOr(c.Coordinates.Maven.ArtifactID, c.Namespace)
+	case "js":
+		return c.npmName()
+	case "python":
+		return valueOr(c.Coordinates.PyPI, toPythonIdentifier(c.Schema.Package))
+	case "csharp":
+		return valueOr(c.Coordinates.NuGet, c.Schema.Package)
+	case "swift":
+		return valueOr(c.Coordinates.SwiftPM, c.Namespace)
+	case "dart":
+		return valueOr(c.Coordinates.Pub, c.Namespace)
+	case "rust":
+		return valueOr(c.Coordinates.Crate, c.Namespace)
+	default:
+		return c.Namespace
+	}
+}
+
+func valueOr(value, def string) string {
+	if value != "" {
+		return value
+	}
+	return def
+}
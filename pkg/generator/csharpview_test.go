@@ -0,0 +1,268 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// csharpViewTestSchema has strings and bytes directly, optionally, in
+// arrays and in nested structs, next to fields views copy, in a @bitmap
+// root. Stats holds no strings, so it has no view. Tagged has strings in a
+// union and in @bitmap optional fields, which decode through
+// FFireHelpers.DecodeString.
+const csharpViewTestSchema = `package catalog
+
+type Kind int8
+
+const (
+	Mic  Kind = 0
+	Line Kind = 1
+)
+
+type Point struct {
+	X     int32
+	Label string
+}
+
+type Device struct {
+	ID    int64
+	Name  string
+	Kind  Kind
+	Tags  []string
+	Gain  *float32
+	Blob  []byte
+	Alias *string
+	Pos   Point
+	Props map[string]int32
+}
+
+// @bitmap
+type Library struct {
+	Title   string
+	Devices []Device
+	Owner   *Device
+	Note    *string
+}
+
+type Names []string
+
+type Stats struct {
+	Count int32
+	Peak  *int32
+}
+
+type Value interface{ int32 | string }
+
+// @bitmap
+type Tagged struct {
+	Value Value
+	Label *string
+	Code  *int32
+	Owner *string
+}
+`
+
+var csharpViewTestValues = []string{
+	`{"Title": "empty", "Devices": []}`,
+	`{"Title": "Studio ✓", "Note": "n",
+	  "Devices": [
+	    {"ID": 1, "Name": "mic", "Kind": "Mic", "Tags": ["a", "bb"], "Gain": 0.5, "Blob": "AQID",
+	     "Pos": {"X": 3, "Label": "left"}, "Props": {"k": 1}},
+	    {"ID": 2, "Name": "", "Kind": "Line", "Tags": [], "Blob": "", "Alias": "line in",
+	     "Pos": {"X": -1, "Label": ""}, "Props": {}}],
+	  "Owner": {"ID": 9, "Name": "owner", "Kind": "Mic", "Tags": ["x"], "Blob": "/w==",
+	            "Pos": {"X": 0, "Label": "o"}, "Props": {}}}`,
+}
+
+var csharpViewTestTagged = []string{
+	`{"Value": {"string": "a union string"}, "Label": "label", "Owner": "owner"}`,
+	`{"Value": {"int32": 7}, "Label": "only label", "Code": 3}`,
+}
+
+func TestGenerateCSharpViews(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(csharpViewTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateCSharp(s)
+	if err != nil {
+		t.Fatalf("GenerateCSharp failed: %v", err)
+	}
+	src := string(code)
+	for _, want := range []string{
+		"public static bool TryDecode(ReadOnlySpan<byte> data, out LibraryMessage value)",
+		"public static bool TryDecode(ReadOnlySpan<byte> data, out StatsMessage value)",
+		"public ref struct LibraryMessageView",
+		"public static bool TryDecode(ReadOnlySpan<byte> data, out LibraryMessageView value)",
+		"public ref struct NamesMessageView",
+		"public DeviceViewList Devices { get; private set; }",
+		"public PointView Pos { get; private set; }",
+		"public FFireSpanList Tags { get; private set; }",
+		"public bool HasAlias { get; private set; }",
+		"public Dictionary<string, int> Props { get; private set; }",
+		"public ref struct FFireSpanList",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code lacks %q", want)
+		}
+	}
+	if strings.Contains(src, "StatsMessageView") {
+		t.Error("a struct without strings or bytes should have no view")
+	}
+}
+
+// TestCSharpViewRoundtrip decodes reference payloads with generated C#,
+// checks that TryDecode re-encodes them to the same bytes, that views hold
+// the same values and alias the data, and that truncated data is rejected
+// without exceptions.
+func TestCSharpViewRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated C# code")
+	}
+	if _, err := exec.LookPath("dotnet"); err != nil {
+		t.Skip("dotnet not installed")
+	}
+	version, err := exec.Command("dotnet", "--version").Output()
+	if err != nil {
+		t.Skipf("no .NET SDK: %v", err)
+	}
+	major, _, _ := strings.Cut(strings.TrimSpace(string(version)), ".")
+
+	s, err := parser.ParseBytes([]byte(csharpViewTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateCSharp(s) // canonicalizes s
+	if err != nil {
+		t.Fatalf("GenerateCSharp failed: %v", err)
+	}
+	var payloads [][]byte
+	for _, value := range csharpViewTestValues {
+		data, err := fixture.Convert(s, "Library", []byte(value))
+		if err != nil {
+			t.Fatalf("fixture encode failed: %v", err)
+		}
+		payloads = append(payloads, data)
+	}
+	var tagged [][]byte
+	for _, value := range csharpViewTestTagged {
+		data, err := fixture.Convert(s, "Tagged", []byte(value))
+		if err != nil {
+			t.Fatalf("fixture encode failed: %v", err)
+		}
+		tagged = append(tagged, data)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"Generated.cs": string(code),
+		"Views.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <OutputType>Exe</OutputType>
+    <TargetFramework>net` + major + `.0</TargetFramework>
+    <AllowUnsafeBlocks>true</AllowUnsafeBlocks>
+    <Nullable>enable</Nullable>
+  </PropertyGroup>
+</Project>
+`,
+		"Main.cs": `using System;
+using System.IO;
+using System.Linq;
+using System.Text;
+using Catalog;
+
+static class Program
+{
+    static void Check(bool ok, string what)
+    {
+        if (!ok) throw new Exception(what);
+    }
+
+    static void CheckDevice(Device d, DeviceView v)
+    {
+        Check(d.ID == v.ID && d.Kind == v.Kind && d.Gain == v.Gain, "device primitives");
+        Check(d.Name == Encoding.UTF8.GetString(v.Name), "device name");
+        Check(d.Blob.AsSpan().SequenceEqual(v.Blob), "device blob");
+        Check(d.Tags.SequenceEqual(v.Tags.ToStringArray()), "device tags");
+        Check((d.Alias != null) == v.HasAlias && (d.Alias ?? "") == Encoding.UTF8.GetString(v.Alias), "device alias");
+        Check(d.Pos.X == v.Pos.X && d.Pos.Label == Encoding.UTF8.GetString(v.Pos.Label), "device pos");
+        var props = v.Props;
+        Check(d.Props.Count == props.Count && d.Props.All(p => props[p.Key] == p.Value), "device props");
+    }
+
+    static void Main()
+    {
+        for (int i = 0; File.Exists(i + ".bin"); i++)
+        {
+            byte[] data = File.ReadAllBytes(i + ".bin");
+            Check(LibraryMessage.TryDecode(data, out var m), "TryDecode");
+            File.WriteAllBytes(i + ".out", m.Encode());
+
+            Check(LibraryMessageView.TryDecode(data, out var v), "view TryDecode");
+            Check(m.Title == Encoding.UTF8.GetString(v.Title), "title");
+            Check((m.Note != null) == v.HasNote && (m.Note ?? "") == Encoding.UTF8.GetString(v.Note), "note");
+            Check((m.Owner != null) == v.HasOwner, "owner presence");
+            if (m.Owner != null) CheckDevice(m.Owner.Value, v.Owner);
+            Check(m.Devices.Length == v.Devices.Count, "device count");
+            int n = 0;
+            foreach (var d in v.Devices) CheckDevice(m.Devices[n++], d);
+            Check(n == m.Devices.Length, "devices enumerated");
+
+            // The view reads the title from data
+            int at = data.AsSpan().IndexOf(Encoding.UTF8.GetBytes(m.Title));
+            data[at] ^= 0x20;
+            Check(Encoding.UTF8.GetString(v.Title) != m.Title, "view does not alias data");
+            data[at] ^= 0x20;
+
+            for (int end = 0; end < data.Length; end++)
+            {
+                Check(!LibraryMessage.TryDecode(data.AsSpan(0, end), out _), "truncated TryDecode at " + end);
+                Check(!LibraryMessageView.TryDecode(data.AsSpan(0, end), out _), "truncated view TryDecode at " + end);
+            }
+        }
+
+        for (int i = 0; File.Exists("tagged" + i + ".bin"); i++)
+        {
+            byte[] data = File.ReadAllBytes("tagged" + i + ".bin");
+            Check(TaggedMessage.TryDecode(data, out var m), "tagged TryDecode");
+            File.WriteAllBytes(i + ".tagged", m.Encode());
+            for (int end = 0; end < data.Length; end++)
+            {
+                Check(!TaggedMessage.TryDecode(data.AsSpan(0, end), out _), "truncated tagged TryDecode at " + end);
+            }
+        }
+
+        var names = new NamesMessage { Items = new[] { "a", "", "ü" } }.Encode();
+        Check(NamesMessageView.TryDecode(names, out var nv) && nv.Items.ToStringArray().SequenceEqual(new[] { "a", "", "ü" }), "names view");
+    }
+}
+`,
+	}
+	for i, data := range payloads {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for i, data := range tagged {
+		files[fmt.Sprintf("tagged%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("dotnet", "run", "-c", "Release")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "DOTNET_CLI_TELEMETRY_OPTOUT=1", "DOTNET_NOLOGO=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("dotnet run failed: %v\n%s", err, out)
+	}
+	checkBitmapOutputs(t, dir, payloads, ".out")
+	checkBitmapOutputs(t, dir, tagged, ".tagged")
+}
@@ -0,0 +1,347 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	fferrors "github.com/shaban/ffire/pkg/errors"
+)
+
+// Generated decoders report failures with the codes of
+// fferrors.DecodeCodes, under the same numbers in every language, so a
+// system mixing languages can branch on the kind of failure. Each
+// language emits the table as its own enum; the emitters below write it
+// in that language's case and comment style.
+
+// decodeErrorCases writes one line per decode code through format, which
+// receives the code's name converted by name, its number and its summary.
+func decodeErrorCases(format string, name func(string) string) string {
+	var b strings.Builder
+	for _, info := range fferrors.DecodeCodes {
+		fmt.Fprintf(&b, format, name(info.Name), int(info.Code), info.Summary)
+	}
+	return b.String()
+}
+
+func goDecodeName(name string) string { return "Decode" + name }
+
+// goDecodeErrorRuntime is the decode error type of a Go package.
+func goDecodeErrorRuntime() string {
+	return `// DecodeErrorCode is the kind of a decode failure. Decoders generated for
+// every language report the same codes.
+type DecodeErrorCode uint8
+
+const (
+` + decodeErrorCases("\t%s DecodeErrorCode = %d // %s\n", goDecodeName) + `)
+
+// DecodeError is a decode failure and its kind.
+type DecodeError struct {
+	Code DecodeErrorCode
+	Msg  string
+}
+
+func (e *DecodeError) Error() string { return e.Msg }
+
+`
+}
+
+// goSharedDecodeError keeps the decode error API of a package that uses
+// the shared runtime.
+func goSharedDecodeError() string {
+	return `// DecodeErrorCode is the kind of a decode failure. Decoders generated for
+// every language report the same codes.
+type DecodeErrorCode = ffirert.DecodeErrorCode
+
+const (
+` + decodeErrorCases("\t%[1]s = ffirert.%[1]s // %[3]s\n", goDecodeName) + `)
+
+// DecodeError is a decode failure and its kind.
+type DecodeError = ffirert.DecodeError
+
+`
+}
+
+func identity(name string) string { return name }
+
+// cppDecodeErrorRuntime is the exception the C++ decoder throws. It
+// derives from std::runtime_error, so existing handlers keep catching it.
+func cppDecodeErrorRuntime() string {
+	return `// Kind of a decode failure. Decoders generated for every language report
+// the same codes.
+enum class DecodeErrorCode : uint8_t {
+` + decodeErrorCases("    %s = %d, // %s\n", identity) + `};
+
+// Thrown by the decoder for malformed data
+class DecodeError : public std::runtime_error {
+public:
+    DecodeErrorCode code;
+
+    DecodeError(DecodeErrorCode c, const std::string& msg) : std::runtime_error(msg), code(c) {}
+};
+
+`
+}
+
+// cABIDecodeErrorDefines writes the decode failure codes as macros of the
+// C ABI header, prefixed with the package name.
+func cABIDecodeErrorDefines(pkg string) string {
+	prefix := strings.ToUpper(pkg) + "_DECODE_"
+	return decodeErrorCases("#define "+prefix+"%s %d // %s\n", ToScreamingSnakeCase)
+}
+
+// rustDecodeErrorCode is the code enum of a Rust crate and the mapping of
+// its FFireError variants onto it.
+func rustDecodeErrorCode() string {
+	return `/// Kind of a decode failure. Decoders generated for every language report
+/// the same codes.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+#[repr(u8)]
+pub enum DecodeErrorCode {
+` + decodeErrorCases("    /// %[3]s\n    %[1]s = %[2]d,\n", identity) + `}
+
+impl FFireError {
+    /// Returns the kind of the failure.
+    pub fn code(&self) -> DecodeErrorCode {
+        match self {
+            FFireError::BufferTooShort => DecodeErrorCode::Truncated,
+            FFireError::InvalidUtf8 => DecodeErrorCode::InvalidUtf8,
+            FFireError::InvalidData => DecodeErrorCode::InvalidValue,
+            FFireError::Overflow => DecodeErrorCode::Overflow,
+            FFireError::BadPresence => DecodeErrorCode::BadPresence,
+            FFireError::TrailingData => DecodeErrorCode::TrailingData,
+        }
+    }
+}
+
+`
+}
+
+// swiftDecodeErrorCode is the code enum of a Swift package and the mapping
+// of its FFireError cases onto it.
+func swiftDecodeErrorCode() string {
+	return `/// Kind of a decode failure. Decoders generated for every language report
+/// the same codes.
+public enum DecodeErrorCode: UInt8 {
+` + decodeErrorCases("    /// %[3]s\n    case %[1]s = %[2]d\n", ToCamelCase) + `}
+
+extension FFireError {
+    /// The kind of the failure.
+    public var code: DecodeErrorCode {
+        switch self {
+        case .truncated: return .truncated
+        case .invalidData: return .invalidValue
+        case .invalidString: return .invalidUtf8
+        case .overflow: return .overflow
+        case .badPresence: return .badPresence
+        case .trailingData: return .trailingData
+        }
+    }
+}
+
+`
+}
+
+// kotlinDecodeErrorCode is the code enum of a Kotlin package; the reader
+// passes its constants to FFireException.
+func kotlinDecodeErrorCode() string {
+	return `/** Kind of a decode failure. Decoders generated for every language report the same codes. */
+enum class DecodeErrorCode(val value: Int) {
+` + strings.TrimSuffix(decodeErrorCases("    /** %[3]s */\n    %[1]s(%[2]d),\n", ToScreamingSnakeCase), ",\n") + `,
+}
+
+`
+}
+
+// javaDecodeErrors declares the code enum of a Java package and the
+// exception its decoders throw. DecodeException extends
+// IllegalArgumentException, which the decoders threw before it existed.
+func javaDecodeErrors() string {
+	return `/** Kind of a decode failure. Decoders generated for every language report the same codes. */
+public enum DecodeErrorCode {
+` + strings.TrimSuffix(decodeErrorCases("    /** %[3]s */\n    %[1]s(%[2]d),\n", ToScreamingSnakeCase), ",\n") + `;
+
+    public final int value;
+
+    DecodeErrorCode(int value) {
+        this.value = value;
+    }
+}
+
+/** Thrown when decoding data that is not a valid encoding of the message. */
+public class DecodeException extends IllegalArgumentException {
+    public final DecodeErrorCode code;
+
+    public DecodeException(DecodeErrorCode code, String message) {
+        super(message);
+        this.code = code;
+    }
+}
+
+/** Reads the values whose range or length the decoder must check. */
+final class FFireDecode {
+    private FFireDecode() {}
+
+    static boolean readPresence(ByteBuffer buf) {
+        int flag = buf.get() & 0xFF;
+        if (flag > 1) {
+            throw new DecodeException(DecodeErrorCode.BAD_PRESENCE, "invalid presence flag " + flag + " at offset " + (buf.position() - 1));
+        }
+        return flag == 1;
+    }
+
+    static boolean readBool(ByteBuffer buf) {
+        int b = buf.get() & 0xFF;
+        if (b > 1) {
+            throw new DecodeException(DecodeErrorCode.INVALID_VALUE, "invalid bool value " + b + " at offset " + (buf.position() - 1));
+        }
+        return b == 1;
+    }
+
+    // Every element or byte takes at least one byte on the wire, so a
+    // length past the end of the buffer is rejected before allocating
+    static int checkLength(ByteBuffer buf, int len) {
+        if (len > buf.remaining()) {
+            throw new DecodeException(DecodeErrorCode.OVERFLOW, "length " + len + " exceeds the remaining " + buf.remaining() + " bytes");
+        }
+        return len;
+    }
+
+    static String readString(ByteBuffer buf, int len) {
+        byte[] bytes = new byte[checkLength(buf, len)];
+        buf.get(bytes);
+        try {
+            return StandardCharsets.UTF_8.newDecoder().decode(ByteBuffer.wrap(bytes)).toString();
+        } catch (java.nio.charset.CharacterCodingException e) {
+            throw new DecodeException(DecodeErrorCode.INVALID_UTF8, "invalid UTF-8 in string at offset " + (buf.position() - len));
+        }
+    }
+}
+
+`
+}
+
+// csharpDecodeErrors declares the code enum of a C# namespace and the
+// exception its decoders throw.
+func csharpDecodeErrors() string {
+	return `    /// <summary>Kind of a decode failure. Decoders generated for every language report the same codes.</summary>
+    public enum DecodeErrorCode : byte
+    {
+` + decodeErrorCases("        /// <summary>%[3]s</summary>\n        %[1]s = %[2]d,\n", identity) + `    }
+
+    /// <summary>Thrown when decoding data that is not a valid encoding of the message.</summary>
+    public class DecodeException : Exception
+    {
+        public DecodeErrorCode Code { get; }
+
+        public DecodeException(DecodeErrorCode code, string message) : base(message)
+        {
+            Code = code;
+        }
+    }
+
+    /// <summary>Reads the values whose range or length the decoder must check.</summary>
+    internal static class FFireDecode
+    {
+        private static readonly UTF8Encoding StrictUtf8 = new UTF8Encoding(false, true);
+
+        internal static bool ReadPresence(ReadOnlySpan<byte> buffer, ref int offset)
+        {
+            byte flag = buffer[offset];
+            if (flag > 1) throw new DecodeException(DecodeErrorCode.BadPresence, $"invalid presence flag 0x{flag:x2} at offset {offset}");
+            offset++;
+            return flag == 1;
+        }
+
+        internal static bool ReadBool(ReadOnlySpan<byte> buffer, ref int offset)
+        {
+            CheckBools(buffer.Slice(offset, 1), offset);
+            return buffer[offset++] == 1;
+        }
+
+        internal static void CheckBools(ReadOnlySpan<byte> bools, int offset)
+        {
+            for (int i = 0; i < bools.Length; i++)
+            {
+                if (bools[i] > 1) throw new DecodeException(DecodeErrorCode.InvalidValue, $"invalid bool value 0x{bools[i]:x2} at offset {offset + i}");
+            }
+        }
+
+        // Every element or byte takes at least one byte on the wire, so a
+        // length past the end of the buffer is rejected before allocating
+        internal static void CheckLength(int length, ReadOnlySpan<byte> buffer, int offset)
+        {
+            if (length > buffer.Length - offset) throw new DecodeException(DecodeErrorCode.Overflow, $"length {length} exceeds the remaining {buffer.Length - offset} bytes");
+        }
+
+        internal static string ReadString(ReadOnlySpan<byte> buffer, int offset, int length)
+        {
+            CheckLength(length, buffer, offset);
+            try
+            {
+                return StrictUtf8.GetString(buffer.Slice(offset, length));
+            }
+            catch (DecoderFallbackException)
+            {
+                throw new DecodeException(DecodeErrorCode.InvalidUtf8, $"invalid UTF-8 in string at offset {offset}");
+            }
+        }
+    }
+
+`
+}
+
+// pythonDecodeErrorCode is the code enum of an igniffi Python package.
+func pythonDecodeErrorCode() string {
+	return `class DecodeErrorCode(IntEnum):
+    """Kind of a decode failure. Decoders generated for every language report the same codes."""
+` + decodeErrorCases("    %[1]s = %[2]d  # %[3]s\n", ToScreamingSnakeCase) + `
+
+`
+}
+
+// jsDecodeErrorCode is the code table of an igniffi JavaScript package.
+func jsDecodeErrorCode() string {
+	return `/** Kind of a decode failure. Decoders generated for every language report the same codes. */
+const DecodeErrorCode = Object.freeze({
+` + decodeErrorCases("  %[1]s: %[2]d, // %[3]s\n", identity) + `});
+
+/** Thrown when decoding fails; code is a DecodeErrorCode, or 0 for other failures. */
+class DecodeError extends Error {
+  constructor(message, code) {
+    super(message);
+    this.name = 'DecodeError';
+    this.code = code;
+  }
+}
+
+`
+}
+
+// dartDecodeErrorCode is the code table of a Dart package.
+func dartDecodeErrorCode() string {
+	return `/// Kind of a decode failure. Decoders generated for every language report
+/// the same codes.
+abstract class DecodeErrorCode {
+` + decodeErrorCases("  /// %[3]s\n  static const int %[1]s = %[2]d;\n", ToCamelCase) + `}
+
+`
+}
+
+// zigDecodeErrorCode is the code enum of a Zig package and the accessor of
+// the last decode failure, which the C ABI keeps per thread.
+func zigDecodeErrorCode(pkg string) string {
+	return `/// Kind of a decode failure. Decoders generated for every language report
+/// the same codes; 0 means the last decode succeeded or failed otherwise.
+pub const DecodeErrorCode = enum(c_int) {
+` + decodeErrorCases("    /// %[3]s\n    %[1]s = %[2]d,\n", ToSnakeCase) + `    _,
+};
+
+extern fn ` + pkg + `_decode_error_code() c_int;
+
+/// Returns the kind of the last DecodeFailed on the calling thread.
+pub fn lastDecodeErrorCode() DecodeErrorCode {
+    return @enumFromInt(` + pkg + `_decode_error_code());
+}
+
+`
+}
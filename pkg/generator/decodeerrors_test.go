@@ -0,0 +1,584 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	fferrors "github.com/shaban/ffire/pkg/errors"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/generator/igniffi"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const decodeErrorTestSchema = `package errs
+
+type Mode int8
+
+const (
+	Off Mode = 0
+	On  Mode = 1
+)
+
+type Shape interface {
+	int32 | string
+}
+
+type Extra struct {
+	Flag bool
+	C    *int32
+}
+
+// @bitmap
+type Record struct {
+	ID    int32
+	Name  string
+	Mode  Mode
+	Shape Shape
+	Tags  []string
+	A     *int8
+	B     *string
+	X     Extra
+}
+`
+
+// decodeErrorTestRecord is a valid Record of decodeErrorTestSchema.
+const decodeErrorTestRecord = `{"ID": 7, "Name": "ab", "Mode": "On", "Shape": {"string": "sq"}, "Tags": ["x", "yz"], "B": "b", "X": {"Flag": true, "C": 5}}`
+
+// decodeErrorTestInputs returns every truncation of valid, many
+// single-byte corruptions of it, and valid with a byte appended. The
+// truncations come first.
+func decodeErrorTestInputs(valid []byte) [][]byte {
+	var inputs [][]byte
+	for i := 0; i < len(valid); i++ {
+		inputs = append(inputs, valid[:i])
+	}
+	for i := range valid {
+		for _, b := range []byte{0x02, 0x7f, 0xff} {
+			corrupt := append([]byte(nil), valid...)
+			corrupt[i] = b
+			inputs = append(inputs, corrupt)
+		}
+	}
+	return append(inputs, append(append([]byte(nil), valid...), 0x00))
+}
+
+// TestDecodeErrorTables checks that every backend declares each decode
+// failure code under its shared number.
+func TestDecodeErrorTables(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	source := func(gen func(*schema.Schema) ([]byte, error)) func() string {
+		return func() string {
+			code, err := gen(s)
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			return string(code)
+		}
+	}
+	fragment := func(code string) func() string { return func() string { return code } }
+
+	tests := []struct {
+		lang   string
+		code   func() string
+		format string // of a code's declaration, given its name and number; spacing is ignored
+		name   func(string) string
+	}{
+		{"go", source(GenerateGo), "%s DecodeErrorCode = %d", goDecodeName},
+		{"cpp", source(GenerateCpp), "%s = %d,", identity},
+		{"c", source(GenerateCABIHeader), "#define ERRS_DECODE_%s %d", ToScreamingSnakeCase},
+		{"rust", source(generateRustNative), "%s = %d,", identity},
+		{"swift", source(generateSwiftNative), "case %s = %d", ToCamelCase},
+		{"kotlin", source(generateKotlinTest), "%s(%d)", ToScreamingSnakeCase},
+		{"java", source(GenerateJava), "%s(%d)", ToScreamingSnakeCase},
+		{"csharp", source(GenerateCSharp), "%s = %d,", identity},
+		{"igniffi", fragment(igniffi.GenerateTypesHeader()), "#define IGNIFFI_DECODE_%s %d", ToScreamingSnakeCase},
+		{"python", fragment(pythonDecodeErrorCode()), "%s = %d", ToScreamingSnakeCase},
+		{"javascript", fragment(jsDecodeErrorCode()), "%s: %d,", identity},
+		{"dart", fragment(dartDecodeErrorCode()), "static const int %s = %d;", ToCamelCase},
+		{"zig", fragment(zigDecodeErrorCode("errs")), "%s = %d,", ToSnakeCase},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			code := collapseSpace(tt.code())
+			for _, info := range fferrors.DecodeCodes {
+				if want := collapseSpace(fmt.Sprintf(tt.format, tt.name(info.Name), int(info.Code))); !strings.Contains(code, want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func collapseSpace(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+// TestCppDecodeErrorCodes decodes every truncation and many single-byte
+// corruptions of a payload with generated C++, which must report the code
+// the reference decoder reports for each input, and accept what it
+// accepts.
+func TestCppDecodeErrorCodes(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateCpp(s) // canonicalizes s
+	if err != nil {
+		t.Fatalf("GenerateCpp failed: %v", err)
+	}
+	valid, err := fixture.Convert(s, "Record", []byte(decodeErrorTestRecord))
+	if err != nil {
+		t.Fatalf("fixture encode failed: %v", err)
+	}
+	inputs := decodeErrorTestInputs(valid)
+
+	dir := t.TempDir()
+	src := `#include "errs.hpp"
+#include <fstream>
+#include <iostream>
+#include <iterator>
+#include <string>
+
+int main() {
+    for (int i = 0; ; i++) {
+        std::ifstream in(std::to_string(i) + ".bin", std::ios::binary);
+        if (!in) return 0;
+        std::vector<uint8_t> data((std::istreambuf_iterator<char>(in)), std::istreambuf_iterator<char>());
+        try {
+            errs::decode_record_message(data);
+            std::cout << 0 << "\n";
+        } catch (const errs::DecodeError& e) {
+            std::cout << static_cast<int>(e.code) << "\n";
+        } catch (const std::exception& e) {
+            std::cout << -1 << "\n";
+        }
+    }
+}
+`
+	files := map[string]string{"errs.hpp": string(code), "main.cpp": src}
+	for i, data := range inputs {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bin := filepath.Join(dir, "codes")
+	if out, err := exec.Command("g++", "-std=c++17", "-O2", "-o", bin, filepath.Join(dir, "main.cpp")).CombinedOutput(); err != nil {
+		t.Fatalf("g++ failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("decoder failed: %v", err)
+	}
+	checkDecodeErrorCodes(t, "C++", s, inputs, strings.Fields(string(out)))
+}
+
+// TestGoDecodeErrorCodes decodes every truncation and many single-byte
+// corruptions of a payload with generated Go, which must report the code
+// the reference decoder reports for each input, and accept what it
+// accepts.
+func TestGoDecodeErrorCodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGo(s) // canonicalizes s
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	valid, err := fixture.Convert(s, "Record", []byte(decodeErrorTestRecord))
+	if err != nil {
+		t.Fatalf("fixture encode failed: %v", err)
+	}
+	inputs := decodeErrorTestInputs(valid)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module codes\n\ngo 1.21\n",
+		"errs/errs.go": string(code),
+		"main.go": `package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"codes/errs"
+)
+
+func main() {
+	for i := 0; ; i++ {
+		data, err := os.ReadFile(fmt.Sprintf("%d.bin", i))
+		if err != nil {
+			return
+		}
+		var v errs.RecordMessage
+		var de *errs.DecodeError
+		if err := v.Decode(data); err == nil {
+			fmt.Println(0)
+		} else if errors.As(err, &de) {
+			fmt.Println(int(de.Code))
+		} else {
+			fmt.Println(-1)
+		}
+	}
+}
+`,
+	}
+	for i, data := range inputs {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	checkDecodeErrorCodes(t, "Go", s, inputs, strings.Fields(string(out)))
+}
+
+// TestRustDecodeErrorCodes decodes every truncation and many single-byte
+// corruptions of a payload with generated Rust, which must report the code
+// the reference decoder reports for each input, and accept what it
+// accepts.
+func TestRustDecodeErrorCodes(t *testing.T) {
+	if _, err := exec.LookPath("rustc"); err != nil {
+		t.Skip("rustc not installed")
+	}
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := generateRustNative(s)
+	if err != nil {
+		t.Fatalf("generateRustNative failed: %v", err)
+	}
+	valid, err := fixture.Convert(s, "Record", []byte(decodeErrorTestRecord))
+	if err != nil {
+		t.Fatalf("fixture encode failed: %v", err)
+	}
+	inputs := decodeErrorTestInputs(valid)
+
+	dir := t.TempDir()
+	src := `#[allow(dead_code)]
+mod errs;
+
+fn main() {
+    for i in 0.. {
+        let Ok(data) = std::fs::read(format!("{}.bin", i)) else { return };
+        match errs::decode_record_message(&data) {
+            Ok(_) => println!("0"),
+            Err(e) => println!("{}", e.code() as u8),
+        }
+    }
+}
+`
+	files := map[string]string{"errs.rs": string(code), "main.rs": src}
+	for i, data := range inputs {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bin := filepath.Join(dir, "codes")
+	if out, err := exec.Command("rustc", "--edition", "2021", "-o", bin, filepath.Join(dir, "main.rs")).CombinedOutput(); err != nil {
+		t.Fatalf("rustc failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("decoder failed: %v", err)
+	}
+	checkDecodeErrorCodes(t, "Rust", s, inputs, strings.Fields(string(out)))
+}
+
+// TestSwiftDecodeErrorCodes decodes every truncation and many single-byte
+// corruptions of a payload with generated Swift, which must report the
+// code the reference decoder reports for each input, and accept what it
+// accepts.
+func TestSwiftDecodeErrorCodes(t *testing.T) {
+	if _, err := exec.LookPath("swiftc"); err != nil {
+		t.Skip("swiftc not installed")
+	}
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := generateSwiftNative(s)
+	if err != nil {
+		t.Fatalf("generateSwiftNative failed: %v", err)
+	}
+	valid, err := fixture.Convert(s, "Record", []byte(decodeErrorTestRecord))
+	if err != nil {
+		t.Fatalf("fixture encode failed: %v", err)
+	}
+	inputs := decodeErrorTestInputs(valid)
+
+	dir := t.TempDir()
+	src := `import Foundation
+
+var i = 0
+while let data = FileManager.default.contents(atPath: "\(i).bin") {
+    do {
+        _ = try decodeRecordMessage(data)
+        print(0)
+    } catch let e as FFireError {
+        print(e.code.rawValue)
+    } catch {
+        print(-1)
+    }
+    i += 1
+}
+`
+	files := map[string]string{"errs.swift": string(code), "main.swift": src}
+	for i, data := range inputs {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bin := filepath.Join(dir, "codes")
+	if out, err := exec.Command("swiftc", "-O", "-o", bin, filepath.Join(dir, "errs.swift"), filepath.Join(dir, "main.swift")).CombinedOutput(); err != nil {
+		t.Fatalf("swiftc failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("decoder failed: %v", err)
+	}
+	checkDecodeErrorCodes(t, "Swift", s, inputs, strings.Fields(string(out)))
+}
+
+// TestKotlinDecodeErrorCodes decodes every truncation and many single-byte
+// corruptions of a payload with generated Kotlin, which must report the
+// code the reference decoder reports for each input, and accept what it
+// accepts.
+func TestKotlinDecodeErrorCodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Kotlin code")
+	}
+	for _, tool := range []string{"kotlinc", "java"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := generateKotlinTest(s)
+	if err != nil {
+		t.Fatalf("generateKotlinNative failed: %v", err)
+	}
+	s.Canonicalize()
+	valid, err := fixture.Convert(s, "Record", []byte(decodeErrorTestRecord))
+	if err != nil {
+		t.Fatalf("fixture encode failed: %v", err)
+	}
+	inputs := decodeErrorTestInputs(valid)
+
+	dir := t.TempDir()
+	src := `import errs.*
+import java.io.File
+
+fun main() {
+    var i = 0
+    while (File("$i.bin").exists()) {
+        try {
+            RecordMessage.decode(File("$i.bin").readBytes())
+            println(0)
+        } catch (e: FFireException) {
+            println(e.code.value)
+        } catch (e: Exception) {
+            println(-1)
+        }
+        i++
+    }
+}
+`
+	files := map[string]string{"Errs.kt": string(code), "Main.kt": src}
+	for i, data := range inputs {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jar := filepath.Join(dir, "codes.jar")
+	if out, err := exec.Command("kotlinc", filepath.Join(dir, "Errs.kt"), filepath.Join(dir, "Main.kt"), "-include-runtime", "-d", jar).CombinedOutput(); err != nil {
+		t.Fatalf("kotlinc failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command("java", "-jar", jar)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("decoder failed: %v", err)
+	}
+	checkDecodeErrorCodes(t, "Kotlin", s, inputs, strings.Fields(string(out)))
+}
+
+// TestCSharpDecodeErrorCodes decodes every truncation and many single-byte
+// corruptions of a payload with generated C#, which must report the code
+// the reference decoder reports for each input, and accept what it
+// accepts.
+func TestCSharpDecodeErrorCodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated C# code")
+	}
+	if _, err := exec.LookPath("dotnet"); err != nil {
+		t.Skip("dotnet not installed")
+	}
+	version, err := exec.Command("dotnet", "--version").Output()
+	if err != nil {
+		t.Skipf("no .NET SDK: %v", err)
+	}
+	major, _, _ := strings.Cut(strings.TrimSpace(string(version)), ".")
+
+	s, err := parser.ParseBytes([]byte(decodeErrorTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateCSharp(s) // canonicalizes s
+	if err != nil {
+		t.Fatalf("GenerateCSharp failed: %v", err)
+	}
+	valid, err := fixture.Convert(s, "Record", []byte(decodeErrorTestRecord))
+	if err != nil {
+		t.Fatalf("fixture encode failed: %v", err)
+	}
+	inputs := decodeErrorTestInputs(valid)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"Generated.cs": string(code),
+		"Codes.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <OutputType>Exe</OutputType>
+    <TargetFramework>net` + major + `.0</TargetFramework>
+    <AllowUnsafeBlocks>true</AllowUnsafeBlocks>
+    <Nullable>enable</Nullable>
+  </PropertyGroup>
+</Project>
+`,
+		"Main.cs": `using System;
+using System.IO;
+using Errs;
+
+static class Program
+{
+    static void Main()
+    {
+        for (int i = 0; File.Exists(i + ".bin"); i++)
+        {
+            byte[] data = File.ReadAllBytes(i + ".bin");
+            try
+            {
+                RecordMessage.Decode(data);
+                Console.WriteLine(0);
+            }
+            catch (DecodeException e)
+            {
+                Console.WriteLine((int)e.Code);
+            }
+            catch (Exception)
+            {
+                Console.WriteLine(-1);
+            }
+        }
+    }
+}
+`,
+	}
+	for i, data := range inputs {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	env := append(os.Environ(), "DOTNET_CLI_TELEMETRY_OPTOUT=1", "DOTNET_NOLOGO=1")
+	// Built first, so the build's warnings stay out of the results
+	cmd := exec.Command("dotnet", "build", "-c", "Release")
+	cmd.Dir = dir
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("dotnet build failed: %v\n%s", err, out)
+	}
+	cmd = exec.Command("dotnet", "run", "--no-build", "-c", "Release")
+	cmd.Dir = dir
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("decoder failed: %v", err)
+	}
+	checkDecodeErrorCodes(t, "C#", s, inputs, strings.Fields(string(out)))
+}
+
+// checkDecodeErrorCodes checks that a backend decoded each input as the
+// reference decoder does: lines holds the code it reported per input, 0
+// for success. Every code must turn up.
+func checkDecodeErrorCodes(t *testing.T, lang string, s *schema.Schema, inputs [][]byte, lines []string) {
+	t.Helper()
+	if len(lines) != len(inputs) {
+		t.Fatalf("got %d results for %d inputs", len(lines), len(inputs))
+	}
+	seen := make(map[fferrors.DecodeCode]bool)
+	for i, line := range lines {
+		got, _ := strconv.Atoi(line)
+		var want fferrors.DecodeCode
+		var decodeErr *fixture.DecodeError
+		if _, err := fixture.Decode(s, "Record", inputs[i]); errors.As(err, &decodeErr) {
+			want = decodeErr.Code
+		} else if err != nil {
+			t.Fatalf("reference decoder failed: %v", err)
+		}
+		if got != int(want) {
+			t.Errorf("input % x: %s reports %v, reference decoder %v", inputs[i], lang, fferrors.DecodeCode(got), want)
+		}
+		seen[want] = true
+	}
+	for _, info := range fferrors.DecodeCodes {
+		if !seen[info.Code] {
+			t.Errorf("no input failed with %v", info.Code)
+		}
+	}
+}
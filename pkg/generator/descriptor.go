@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/shaban/ffire/pkg/descriptor"
+)
+
+// A schema descriptor is the schema of a package encoded as an ffire
+// message (see pkg/descriptor). Packages generated with --descriptor embed
+// theirs, so a process can send it to peers, which check it against their
+// own schema or decode the process's messages without generated code.
+
+// descriptorLanguages lists the languages with a descriptor emitter.
+var descriptorLanguages = map[string]bool{
+	"go":    true,
+	"c":     true,
+	"cpp":   true,
+	"c++":   true,
+	"swift": true,
+}
+
+// supportsDescriptor reports whether --descriptor works for lang.
+func supportsDescriptor(lang string) bool {
+	return descriptorLanguages[strings.ToLower(lang)]
+}
+
+type descriptorData struct {
+	Package string
+	Size    int
+	Rows    []string // byte literals, 12 per row
+}
+
+// generateDescriptor writes the schema descriptor next to the generated
+// package.
+func generateDescriptor(config *PackageConfig) error {
+	data, err := descriptor.Encode(config.Schema)
+	if err != nil {
+		return err
+	}
+	d := &descriptorData{Package: config.Schema.Package, Size: len(data)}
+	for len(data) > 0 {
+		n := min(len(data), 12)
+		literals := make([]string, n)
+		for i, b := range data[:n] {
+			literals[i] = fmt.Sprintf("0x%02x,", b)
+		}
+		d.Rows = append(d.Rows, strings.Join(literals, " "))
+		data = data[n:]
+	}
+
+	var path string
+	var tmpl *template.Template
+	switch lang := strings.ToLower(config.Language); lang {
+	case "go":
+		path = filepath.Join(config.OutputDir, "descriptor.go")
+		tmpl = goDescriptorTemplate
+	case "c", "cpp", "c++":
+		path = filepath.Join(config.langDir(config.Language), "include", "descriptor.hpp")
+		tmpl = cppDescriptorTemplate
+	case "swift":
+		path = filepath.Join(config.langDir(SwiftLayout.Name), "Sources", config.Namespace, "Descriptor.swift")
+		tmpl = swiftDescriptorTemplate
+	default:
+		return fmt.Errorf("descriptors are not supported for %s", config.Language)
+	}
+
+	if err := writeRoundtripFile(path, tmpl, d); err != nil {
+		return err
+	}
+	config.infof("✓ Generated schema descriptor (%d bytes): %s\n", d.Size, path)
+	return nil
+}
+
+var goDescriptorTemplate = template.Must(template.New("go").Parse(`// Code generated by ffire. DO NOT EDIT.
+
+package {{.Package}}
+
+// SchemaDescriptor is the schema of this package as an ffire descriptor
+// ({{.Size}} bytes). Peers decode it with github.com/shaban/ffire/pkg/descriptor
+// to check it against their own schema, or to decode this package's
+// messages without generated code.
+var SchemaDescriptor = []byte{
+{{- range .Rows}}
+	{{.}}
+{{- end}}
+}
+`))
+
+var cppDescriptorTemplate = template.Must(template.New("cpp").Parse(`// Code generated by ffire. DO NOT EDIT.
+#pragma once
+
+#include <cstddef>
+#include <cstdint>
+
+namespace {{.Package}} {
+
+/// The schema of this package as an ffire descriptor. Peers decode it to
+/// check it against their own schema, or to decode this package's messages
+/// without generated code.
+inline constexpr uint8_t schema_descriptor[] = {
+{{- range .Rows}}
+    {{.}}
+{{- end}}
+};
+
+inline constexpr size_t schema_descriptor_size = {{.Size}};
+
+} // namespace {{.Package}}
+`))
+
+var swiftDescriptorTemplate = template.Must(template.New("swift").Parse(`// Code generated by ffire. DO NOT EDIT.
+
+/// The schema of this package as an ffire descriptor ({{.Size}} bytes).
+/// Peers decode it to check it against their own schema, or to decode
+/// this package's messages without generated code.
+public let schemaDescriptor: [UInt8] = [
+{{- range .Rows}}
+    {{.}}
+{{- end}}
+]
+`))
@@ -0,0 +1,139 @@
+package generator
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/descriptor"
+	"github.com/shaban/ffire/pkg/fixture"
+)
+
+func TestGenerateDescriptor(t *testing.T) {
+	tests := []struct {
+		lang string
+		file string
+		want []string
+	}{
+		{"go", "descriptor.go", []string{"package audio", "var SchemaDescriptor = []byte{\n\t"}},
+		{"cpp", "cpp/include/descriptor.hpp", []string{"namespace audio {", "inline constexpr uint8_t schema_descriptor[] = {\n    "}},
+		{"swift", "swift/Sources/audio/Descriptor.swift", []string{"public let schemaDescriptor: [UInt8] = [\n    "}},
+	}
+	encoded, err := descriptor.Encode(parseHandshakeSchema(t, "v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var firstRow []string
+	for _, b := range encoded[:12] {
+		firstRow = append(firstRow, fmt.Sprintf("0x%02x,", b))
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			config := &PackageConfig{Schema: parseHandshakeSchema(t, "v2"), Language: tt.lang, OutputDir: t.TempDir(), Descriptor: true, NoCompile: true, NoFormat: true}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(config.OutputDir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := string(data)
+			for _, want := range append(tt.want, strings.Join(firstRow, " ")) {
+				if !strings.Contains(code, want) {
+					t.Errorf("%s lacks %q:\n%s", tt.file, want, code)
+				}
+			}
+		})
+	}
+
+	config := &PackageConfig{Schema: parseHandshakeSchema(t), Language: "rust", OutputDir: t.TempDir(), Descriptor: true, NoCompile: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--descriptor is not supported for rust") {
+		t.Errorf("GeneratePackage(rust) = %v, want unsupported error", err)
+	}
+}
+
+// TestGoDescriptorDecodesMessages reads the descriptor a generated Go
+// package embeds, as a peer would, and decodes a message the package
+// encoded with it.
+func TestGoDescriptorDecodesMessages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	tmpDir := t.TempDir()
+	config := &PackageConfig{
+		Schema:     parseHandshakeSchema(t, "v2"),
+		Language:   "go",
+		OutputDir:  filepath.Join(tmpDir, "audio"),
+		Descriptor: true,
+		NoCompile:  true,
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	files := map[string]string{
+		"go.mod": "module peer\n\ngo 1.21\n",
+		"main.go": `package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"peer/audio"
+)
+
+func main() {
+	msg := audio.PluginMessage{Name: "reverb", Latency: 64}
+	fmt.Println(hex.EncodeToString(audio.SchemaDescriptor))
+	fmt.Println(hex.EncodeToString(msg.Encode()))
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go run failed: %v", err)
+	}
+	lines := strings.Fields(string(out))
+	if len(lines) != 2 {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	desc, _ := hex.DecodeString(lines[0])
+	msg, _ := hex.DecodeString(lines[1])
+
+	s, err := descriptor.Decode(desc)
+	if err != nil {
+		t.Fatalf("descriptor.Decode failed: %v", err)
+	}
+	if err := descriptor.Verify(parseHandshakeSchema(t, "v2"), desc); err != nil {
+		t.Errorf("Verify(same schema) = %v", err)
+	}
+	if err := descriptor.Verify(parseHandshakeSchema(t), desc); err == nil {
+		t.Error("Verify(schema without v2) = nil, want an error")
+	}
+
+	value, err := fixture.Decode(s, "Plugin", msg)
+	if err != nil {
+		t.Fatalf("fixture.Decode failed: %v", err)
+	}
+	got, _ := json.Marshal(value)
+	if want := `{"Latency":64,"Name":"reverb"}`; string(got) != want {
+		t.Errorf("decoded %s, want %s", got, want)
+	}
+}
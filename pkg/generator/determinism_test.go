@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// TestGoEncodingDeterministic checks that generated Go code re-encodes every
+// testdata fixture to exactly the bytes produced by the reference encoder in
+// pkg/fixture, which is what all generated languages are held to.
+func TestGoEncodingDeterministic(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte("module determinism\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var imports, checks strings.Builder
+	var names []string
+	schemas, _ := filepath.Glob("../../testdata/schema/*.ffi")
+	for _, schemaPath := range schemas {
+		name := strings.TrimSuffix(filepath.Base(schemaPath), ".ffi")
+		fixtureJSON, err := os.ReadFile(filepath.Join("../../testdata/json", name+".json"))
+		if err != nil {
+			continue
+		}
+
+		s, err := parser.Parse(schemaPath)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", name, err)
+		}
+		if len(s.Messages) != 1 {
+			continue
+		}
+		msg := s.Messages[0].Name
+
+		code, err := GenerateGo(s) // canonicalizes s
+		if err != nil {
+			t.Fatalf("%s: GenerateGo failed: %v", name, err)
+		}
+		expected, err := fixture.Convert(s, msg, fixtureJSON)
+		if err != nil {
+			t.Fatalf("%s: fixture encode failed: %v", name, err)
+		}
+
+		pkgDir := filepath.Join(tmpDir, name)
+		os.MkdirAll(pkgDir, 0755)
+		if err := os.WriteFile(filepath.Join(pkgDir, name+".go"), code, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, name+".bin"), expected, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		fmt.Fprintf(&imports, "\tp_%s \"determinism/%s\"\n", name, name)
+		fmt.Fprintf(&checks, "\t{\n\t\tdata, _ := os.ReadFile(%q)\n\t\tvar m p_%s.%sMessage\n\t\tif err := m.Decode(data); err != nil {\n\t\t\tpanic(err)\n\t\t}\n\t\tos.WriteFile(%q, m.Encode(), 0644)\n\t}\n",
+			name+".bin", name, msg, name+".out")
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		t.Fatal("no testdata fixtures found")
+	}
+
+	mainSrc := "package main\n\nimport (\n\t\"os\"\n\n" + imports.String() + ")\n\nfunc main() {\n" + checks.String() + "}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte(mainSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+
+	t.Logf("checked %d fixtures: %s", len(names), strings.Join(names, ", "))
+	for _, name := range names {
+		expected, _ := os.ReadFile(filepath.Join(tmpDir, name+".bin"))
+		actual, err := os.ReadFile(filepath.Join(tmpDir, name+".out"))
+		if err != nil {
+			t.Errorf("%s: no output: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s: generated Go encoding differs from reference\nwant % x\ngot  % x", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package generator
+
+import "strings"
+
+// Schema doc comments (schema.StructType.Doc and friends) are copied into
+// generated code in each language's documentation syntax, so they show up
+// in godoc, Javadoc, IDE hovers and help(). Each helper returns "" for an
+// empty doc.
+
+// lineDoc formats doc as line comments starting with marker, e.g. "//" for
+// Go or "///" for Rust and Swift, indented by indent.
+func lineDoc(indent, marker, doc string) string {
+	if doc == "" {
+		return ""
+	}
+	var sb strings.Builder
+	for _, line := range strings.Split(doc, "\n") {
+		sb.WriteString(indent + marker)
+		if line != "" {
+			sb.WriteString(" " + line)
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// blockDoc formats doc as a /** */ comment, for Javadoc, KDoc and JSDoc,
+// on one line if doc is. A "*/" in the text would end the comment early
+// and is broken up.
+func blockDoc(indent, doc string) string {
+	if doc == "" {
+		return ""
+	}
+	doc = strings.ReplaceAll(doc, "*/", "*&#47;")
+	if !strings.Contains(doc, "\n") {
+		return indent + "/** " + doc + " */\n"
+	}
+	var sb strings.Builder
+	sb.WriteString(indent + "/**\n")
+	for _, line := range strings.Split(doc, "\n") {
+		sb.WriteString(indent + " *")
+		if line != "" {
+			sb.WriteString(" " + line)
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(indent + " */\n")
+	return sb.String()
+}
+
+// markupEscaper escapes text for HTML Javadoc and XML C# doc comments,
+// whose tools reject stray '<' and '&'.
+var markupEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// javadoc formats doc as a Javadoc comment.
+func javadoc(indent, doc string) string {
+	return blockDoc(indent, markupEscaper.Replace(doc))
+}
+
+// xmlDoc formats doc as a C# <summary> documentation comment.
+func xmlDoc(indent, doc string) string {
+	if doc == "" {
+		return ""
+	}
+	return indent + "/// <summary>\n" + lineDoc(indent, "///", markupEscaper.Replace(doc)) + indent + "/// </summary>\n"
+}
+
+// docstring formats doc as a Python docstring, the first statement of a
+// class or function body indented by indent.
+func docstring(indent, doc string) string {
+	if doc == "" {
+		return ""
+	}
+	doc = strings.ReplaceAll(doc, `\`, `\\`)
+	doc = strings.ReplaceAll(doc, `"""`, `\"\"\"`)
+	if strings.HasSuffix(doc, `"`) {
+		doc += " "
+	}
+	lines := strings.Split(doc, "\n")
+	if len(lines) == 1 {
+		return indent + `"""` + doc + `"""` + "\n"
+	}
+	var sb strings.Builder
+	sb.WriteString(indent + `"""` + lines[0] + "\n")
+	for _, line := range lines[1:] {
+		if line != "" {
+			sb.WriteString(indent + line)
+		}
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(indent + `"""` + "\n")
+	return sb.String()
+}
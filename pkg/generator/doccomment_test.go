@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const docTestSchema = `package devices
+
+// Device is an audio device.
+//
+// Names are <unique> & stable.
+type Device struct {
+	// Name is shown to users.
+	Name string
+	Kind Kind // How the device is used
+}
+
+// Kind classifies devices.
+type Kind int8
+
+const (
+	Input  Kind = 0 // Records audio
+	Output Kind = 1
+)
+
+// DeviceList is every device on the host.
+type DeviceList []Device
+`
+
+func TestDocHelpers(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"line", lineDoc("\t", "//", "One.\n\nTwo."), "\t// One.\n\t//\n\t// Two.\n"},
+		{"block short", blockDoc("  ", "Ends */ early"), "  /** Ends *&#47; early */\n"},
+		{"block long", blockDoc("", "One.\n\nTwo."), "/**\n * One.\n *\n * Two.\n */\n"},
+		{"javadoc", javadoc("", "a < b & c"), "/** a &lt; b &amp; c */\n"},
+		{"xml", xmlDoc("    ", "a<b"), "    /// <summary>\n    /// a&lt;b\n    /// </summary>\n"},
+		{"docstring short", docstring("    ", `Say "hi"`), "    \"\"\"Say \"hi\" \"\"\"\n"},
+		{"docstring long", docstring("", "One.\n\nTwo \"\"\" \\n"), "\"\"\"One.\n\nTwo \\\"\\\"\\\" \\\\n\n\"\"\"\n"},
+		{"empty", lineDoc("", "///", "") + blockDoc("", "") + xmlDoc("", "") + docstring("", ""), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestGenerateDocComments(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(docTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	single := func(lang string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			var buf bytes.Buffer
+			err := Generate(&buf, s, lang)
+			return buf.Bytes(), err
+		}
+	}
+	tests := []struct {
+		lang     string
+		generate func() ([]byte, error)
+		want     []string
+	}{
+		{"go", single("go"), []string{
+			"// Kind classifies devices.\ntype Kind int8",
+			"\t// Records audio\n\tInput  Kind = 0",
+			"// Device is an audio device.\n//\n// Names are <unique> & stable.\ntype Device struct",
+			"\t// Name is shown to users.\n\tName string",
+			"// DeviceList is every device on the host.\ntype DeviceListMessage []Device",
+		}},
+		{"cpp", single("cpp"), []string{
+			"/// Kind classifies devices.\nenum class Kind",
+			"/// Device is an audio device.\n///\n/// Names are <unique> & stable.\nstruct Device",
+			"    /// How the device is used\n",
+		}},
+		{"csharp", single("csharp"), []string{
+			"    /// <summary>\n    /// Kind classifies devices.\n    /// </summary>\n    public enum Kind",
+			"/// Names are &lt;unique&gt; &amp; stable.\n",
+			"    /// <summary>\n    /// DeviceList is every device on the host.\n    /// </summary>\n    public struct DeviceListMessage",
+		}},
+		{"java", single("java"), []string{
+			"/** Kind classifies devices. */\nenum Kind",
+			"/**\n * Device is an audio device.\n *\n * Names are &lt;unique&gt; &amp; stable.\n */\n",
+			"    /** Name is shown to users. */\n",
+		}},
+		{"kotlin", func() ([]byte, error) { return generateKotlinNative(s, "devices") }, []string{
+			"/** Kind classifies devices. */\nenum class Kind",
+			"    /** Records audio */\n    Input(0),",
+			"    /** Name is shown to users. */\n    val name: String,",
+			"/** DeviceList is every device on the host. */\nobject DeviceListMessage",
+		}},
+		{"rust", func() ([]byte, error) { return generateRustNative(s) }, []string{
+			"/// Kind classifies devices.\n#[derive",
+			"    /// Records audio\n    Input = 0,",
+			"    /// Name is shown to users.\n    pub name: String,",
+			"/// DeviceList is every device on the host.\npub type DeviceListMessage",
+		}},
+		{"swift", func() ([]byte, error) { return generateSwiftNative(s) }, []string{
+			"/// Kind classifies devices.\npublic enum Kind",
+			"    /// Name is shown to users.\n    public var Name: String",
+			"/// DeviceList is every device on the host.\npublic typealias DeviceListMessage",
+		}},
+		{"python", func() ([]byte, error) {
+			var buf bytes.Buffer
+			for _, typ := range s.Types {
+				switch typ := typ.(type) {
+				case *schema.EnumType:
+					generatePythonEnum(&buf, typ)
+				case *schema.StructType:
+					generatePythonDataclass(&buf, s, typ)
+				}
+			}
+			return buf.Bytes(), nil
+		}, []string{
+			"class Kind(IntEnum):\n    \"\"\"Kind classifies devices.\"\"\"\n",
+			"    Input = 0\n    \"\"\"Records audio\"\"\"\n",
+			"    \"\"\"Device is an audio device.\n\n    Names are <unique> & stable.\n    \"\"\"\n",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			code, err := tt.generate()
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			out := string(code)
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("missing %q in:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,1257 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/analyzer"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// The embedded C++ profile (--cpp-embedded, CppOptions.Embedded) is a
+// header for microcontroller firmware, such as on the ESP32 or STM32,
+// that exchanges messages with a host. It allocates nothing, throws
+// nothing, uses no RTTI and includes only <stddef.h>, <stdint.h> and
+// <string.h>. Strings, bytes, slices and maps are stored inline at the
+// capacity of their @max limits, so every one a message reaches needs a
+// limit, and each message gets the largest size it encodes to, computed
+// by the analyzer, for sizing static buffers.
+//
+// Encoders report a buffer that is too small instead of growing it, and
+// decoders return the DecodeErrorCode of the C++ core instead of throwing,
+// rejecting a length over its limit as InvalidValue. Both keep going
+// after a failure without touching memory they do not own, so the
+// generated code checks for errors once per message.
+
+// cppEmbeddedRuntime is the part of the header that does not depend on
+// the schema: the containers and the encoder and decoder. It is C++11,
+// the newest standard some Arduino cores compile.
+func cppEmbeddedRuntime() string {
+	return `// Kind of a decode failure. Decoders generated for every language report
+// the same codes; Ok means the message decoded.
+enum class DecodeErrorCode : uint8_t {
+    Ok = 0, // no failure
+` + decodeErrorCases("    %s = %d, // %s\n", identity) + `};
+
+class Decoder;
+
+// String holds up to N bytes inline, followed by a NUL so that c_str()
+// can be passed to C functions.
+template <size_t N>
+class String {
+public:
+    String() : size_(0) { data_[0] = '\0'; }
+
+    // Sets the string to the n bytes at s. Returns false, leaving the
+    // string unchanged, if they do not fit.
+    bool assign(const char* s, size_t n) {
+        if (n > N) return false;
+        memcpy(data_, s, n);
+        data_[n] = '\0';
+        size_ = n;
+        return true;
+    }
+    bool assign(const char* s) { return assign(s, strlen(s)); }
+
+    const char* data() const { return data_; }
+    const char* c_str() const { return data_; }
+    size_t size() const { return size_; }
+    bool empty() const { return size_ == 0; }
+    static constexpr size_t capacity() { return N; }
+
+    bool operator==(const String& o) const { return size_ == o.size_ && memcmp(data_, o.data_, size_) == 0; }
+    bool operator!=(const String& o) const { return !(*this == o); }
+
+private:
+    friend class Decoder;
+    char data_[N + 1];
+    size_t size_;
+};
+
+// Bytes holds up to N bytes inline.
+template <size_t N>
+class Bytes {
+public:
+    Bytes() : size_(0) {}
+
+    // Sets the contents to the n bytes at b. Returns false, leaving the
+    // contents unchanged, if they do not fit.
+    bool assign(const uint8_t* b, size_t n) {
+        if (n > N) return false;
+        memcpy(data_, b, n);
+        size_ = n;
+        return true;
+    }
+
+    uint8_t& operator[](size_t i) { return data_[i]; }
+    const uint8_t& operator[](size_t i) const { return data_[i]; }
+    const uint8_t* data() const { return data_; }
+    size_t size() const { return size_; }
+    bool empty() const { return size_ == 0; }
+    static constexpr size_t capacity() { return N; }
+
+    bool operator==(const Bytes& o) const { return size_ == o.size_ && memcmp(data_, o.data_, size_) == 0; }
+    bool operator!=(const Bytes& o) const { return !(*this == o); }
+
+private:
+    friend class Decoder;
+    uint8_t data_[N];
+    size_t size_;
+};
+
+// Vector holds up to N elements inline.
+template <typename T, size_t N>
+class Vector {
+public:
+    Vector() : size_(0) {}
+
+    // Appends v. Returns false if the vector is full.
+    bool push_back(const T& v) {
+        if (size_ == N) return false;
+        items_[size_++] = v;
+        return true;
+    }
+    void pop_back() {
+        if (size_ > 0) --size_;
+    }
+    void clear() { size_ = 0; }
+
+    T& operator[](size_t i) { return items_[i]; }
+    const T& operator[](size_t i) const { return items_[i]; }
+    T* data() { return items_; }
+    const T* data() const { return items_; }
+    T* begin() { return items_; }
+    T* end() { return items_ + size_; }
+    const T* begin() const { return items_; }
+    const T* end() const { return items_ + size_; }
+    size_t size() const { return size_; }
+    bool empty() const { return size_ == 0; }
+    static constexpr size_t capacity() { return N; }
+
+private:
+    friend class Decoder;
+    T items_[N];
+    size_t size_;
+};
+
+// Array holds exactly N elements, like std::array.
+template <typename T, size_t N>
+struct Array {
+    T items[N];
+
+    T& operator[](size_t i) { return items[i]; }
+    const T& operator[](size_t i) const { return items[i]; }
+    T* data() { return items; }
+    const T* data() const { return items; }
+    T* begin() { return items; }
+    T* end() { return items + N; }
+    const T* begin() const { return items; }
+    const T* end() const { return items + N; }
+    static constexpr size_t size() { return N; }
+};
+
+// Optional holds a T or nothing, like std::optional. The T is stored
+// inline either way.
+template <typename T>
+class Optional {
+public:
+    Optional() : present_(false), value_() {}
+    Optional(const T& v) : present_(true), value_(v) {}
+    Optional& operator=(const T& v) {
+        present_ = true;
+        value_ = v;
+        return *this;
+    }
+
+    // Makes the optional hold a default T and returns it.
+    T& emplace() {
+        present_ = true;
+        value_ = T();
+        return value_;
+    }
+    void reset() { present_ = false; }
+
+    bool has_value() const { return present_; }
+    explicit operator bool() const { return present_; }
+    T& value() { return value_; }
+    const T& value() const { return value_; }
+    T& operator*() { return value_; }
+    const T& operator*() const { return value_; }
+    T* operator->() { return &value_; }
+    const T* operator->() const { return &value_; }
+
+private:
+    friend class Decoder;
+    bool present_;
+    T value_;
+};
+
+// compare_keys orders map keys the way encoders write them: strings by
+// their bytes, numbers numerically and false before true.
+template <size_t N>
+inline int compare_keys(const String<N>& a, const String<N>& b) {
+    size_t n = a.size() < b.size() ? a.size() : b.size();
+    int c = memcmp(a.data(), b.data(), n);
+    if (c != 0) return c;
+    return a.size() < b.size() ? -1 : (a.size() > b.size() ? 1 : 0);
+}
+
+template <typename T>
+inline int compare_keys(T a, T b) {
+    return a < b ? -1 : (b < a ? 1 : 0);
+}
+
+// Map holds up to N entries inline, sorted by key, which is the order
+// they are encoded in.
+template <typename K, typename V, size_t N>
+class Map {
+public:
+    struct Entry {
+        K key;
+        V value;
+    };
+
+    Map() : size_(0) {}
+
+    // Returns the value of key, or nullptr if the map does not hold it.
+    V* find(const K& key) {
+        size_t i = lower_bound(key);
+        return i < size_ && compare_keys(entries_[i].key, key) == 0 ? &entries_[i].value : nullptr;
+    }
+    const V* find(const K& key) const {
+        size_t i = lower_bound(key);
+        return i < size_ && compare_keys(entries_[i].key, key) == 0 ? &entries_[i].value : nullptr;
+    }
+
+    // Returns the value of key, adding key with a default value if the
+    // map does not hold it. Returns nullptr if the key is new and the map
+    // is full.
+    V* insert(const K& key) {
+        size_t i = lower_bound(key);
+        if (i < size_ && compare_keys(entries_[i].key, key) == 0) return &entries_[i].value;
+        if (size_ == N) return nullptr;
+        for (size_t j = size_; j > i; --j) entries_[j] = entries_[j - 1];
+        entries_[i].key = key;
+        entries_[i].value = V();
+        ++size_;
+        return &entries_[i].value;
+    }
+
+    // Sets the value of key. Returns false if the key is new and the map
+    // is full.
+    bool set(const K& key, const V& value) {
+        V* v = insert(key);
+        if (v == nullptr) return false;
+        *v = value;
+        return true;
+    }
+
+    void clear() { size_ = 0; }
+
+    Entry* begin() { return entries_; }
+    Entry* end() { return entries_ + size_; }
+    const Entry* begin() const { return entries_; }
+    const Entry* end() const { return entries_ + size_; }
+    size_t size() const { return size_; }
+    bool empty() const { return size_ == 0; }
+    static constexpr size_t capacity() { return N; }
+
+private:
+    size_t lower_bound(const K& key) const {
+        size_t lo = 0, hi = size_;
+        while (lo < hi) {
+            size_t mid = lo + (hi - lo) / 2;
+            if (compare_keys(entries_[mid].key, key) < 0) {
+                lo = mid + 1;
+            } else {
+                hi = mid;
+            }
+        }
+        return lo;
+    }
+
+    Entry entries_[N];
+    size_t size_;
+};
+
+// Binary encoder for wire format. It writes into a buffer it does not
+// own; a write that does not fit fails the encoder, which then drops
+// every later write.
+class Encoder {
+public:
+    Encoder(uint8_t* data, size_t capacity) : data_(data), capacity_(capacity), pos_(0), ok_(true) {}
+
+    bool ok() const { return ok_; }
+    size_t size() const { return pos_; }
+    void fail() { ok_ = false; }
+
+    void write_raw(const void* src, size_t n) {
+        if (!ok_ || n > capacity_ - pos_) {
+            ok_ = false;
+            return;
+        }
+        memcpy(data_ + pos_, src, n);
+        pos_ += n;
+    }
+
+    void write_byte(uint8_t b) { write_raw(&b, 1); }
+    void write_bool(bool v) { write_byte(v ? 0x01 : 0x00); }
+    void write_int8(int8_t v) { write_byte(static_cast<uint8_t>(v)); }
+    void write_int16(int16_t v) { write_uint(static_cast<uint16_t>(v), 2); }
+    void write_int32(int32_t v) { write_uint(static_cast<uint32_t>(v), 4); }
+
+    void write_int64(int64_t v) {
+        uint64_t u = static_cast<uint64_t>(v);
+        write_uint(static_cast<uint32_t>(u), 4);
+        write_uint(static_cast<uint32_t>(u >> 32), 4);
+    }
+
+    void write_float32(float v) {
+        uint32_t u;
+        memcpy(&u, &v, sizeof(u));
+        write_uint(u, 4);
+    }
+
+    void write_float64(double v) {
+        uint64_t u;
+        memcpy(&u, &v, sizeof(u));
+        write_int64(static_cast<int64_t>(u));
+    }
+
+    void write_length(size_t n) { write_uint(static_cast<uint32_t>(n), 2); }
+    void write_large_length(size_t n) { write_uint(static_cast<uint32_t>(n), 4); }
+
+    // The frame of a @framed struct is reserved before its fields and
+    // filled in after them
+    size_t begin_frame() {
+        size_t start = pos_;
+        write_uint(0, 4);
+        return start;
+    }
+
+    void end_frame(size_t start) {
+        if (!ok_) return;
+        uint32_t n = static_cast<uint32_t>(pos_ - start - 4);
+        for (size_t i = 0; i < 4; ++i) data_[start + i] = static_cast<uint8_t>(n >> (8 * i));
+    }
+
+private:
+    void write_uint(uint32_t u, size_t n) {
+        uint8_t b[4];
+        for (size_t i = 0; i < n; ++i) b[i] = static_cast<uint8_t>(u >> (8 * i));
+        write_raw(b, n);
+    }
+
+    uint8_t* data_;
+    size_t capacity_;
+    size_t pos_;
+    bool ok_;
+};
+
+// Binary decoder for wire format. The first failure is kept in error();
+// reads after it return zeros without touching the data.
+class Decoder {
+public:
+    Decoder(const uint8_t* data, size_t size) : data_(data), size_(size), pos_(0), error_(DecodeErrorCode::Ok) {}
+
+    DecodeErrorCode error() const { return error_; }
+    bool ok() const { return error_ == DecodeErrorCode::Ok; }
+
+    void fail(DecodeErrorCode code) {
+        if (error_ == DecodeErrorCode::Ok) error_ = code;
+    }
+
+    bool check_remaining(size_t n) {
+        if (!ok()) return false;
+        if (n > size_ - pos_) {
+            fail(DecodeErrorCode::Truncated);
+            return false;
+        }
+        return true;
+    }
+
+    // Lengths and counts are checked against the data left: every
+    // element takes at least a byte. They stay 32-bit until checked,
+    // since size_t has 16 bits on AVR
+    bool check_count(uint32_t n) {
+        if (!ok()) return false;
+        if (n > size_ - pos_) {
+            fail(DecodeErrorCode::Overflow);
+            return false;
+        }
+        return true;
+    }
+
+    // A length over the @max limit of its value does not fit
+    bool check_capacity(size_t n, size_t capacity) {
+        if (!ok()) return false;
+        if (n > capacity) {
+            fail(DecodeErrorCode::InvalidValue);
+            return false;
+        }
+        return true;
+    }
+
+    void read_raw(void* dst, size_t n) {
+        if (!check_remaining(n)) {
+            memset(dst, 0, n);
+            return;
+        }
+        memcpy(dst, data_ + pos_, n);
+        pos_ += n;
+    }
+
+    uint8_t read_byte() {
+        if (!check_remaining(1)) return 0;
+        return data_[pos_++];
+    }
+
+    bool read_bool() {
+        uint8_t b = read_byte();
+        if (b > 0x01) fail(DecodeErrorCode::InvalidValue);
+        return b != 0x00;
+    }
+
+    bool read_presence() {
+        uint8_t b = read_byte();
+        if (b > 0x01) fail(DecodeErrorCode::BadPresence);
+        return b == 0x01;
+    }
+
+    int8_t read_int8() { return static_cast<int8_t>(read_byte()); }
+    int16_t read_int16() { return static_cast<int16_t>(read_uint(2)); }
+    int32_t read_int32() { return static_cast<int32_t>(read_uint(4)); }
+
+    int64_t read_int64() {
+        uint64_t lo = read_uint(4);
+        uint64_t hi = read_uint(4);
+        return static_cast<int64_t>(lo | (hi << 32));
+    }
+
+    float read_float32() {
+        uint32_t u = read_uint(4);
+        float f;
+        memcpy(&f, &u, sizeof(f));
+        return f;
+    }
+
+    double read_float64() {
+        uint64_t u = static_cast<uint64_t>(read_int64());
+        double d;
+        memcpy(&d, &u, sizeof(d));
+        return d;
+    }
+
+    size_t read_length() {
+        uint32_t n = read_uint(2);
+        return check_count(n) ? n : 0;
+    }
+
+    size_t read_large_length() {
+        uint32_t n = read_uint(4);
+        return check_count(n) ? n : 0;
+    }
+
+    template <size_t N>
+    void read_string(String<N>& s, size_t n) {
+        s.size_ = 0;
+        s.data_[0] = '\0';
+        if (!check_capacity(n, N) || !check_remaining(n)) return;
+        if (!valid_utf8(data_ + pos_, n)) {
+            fail(DecodeErrorCode::InvalidUtf8);
+            return;
+        }
+        memcpy(s.data_, data_ + pos_, n);
+        s.data_[n] = '\0';
+        s.size_ = n;
+        pos_ += n;
+    }
+
+    template <size_t N>
+    void read_bytes(Bytes<N>& b, size_t n) {
+        b.size_ = 0;
+        if (!check_capacity(n, N) || !check_remaining(n)) return;
+        memcpy(b.data_, data_ + pos_, n);
+        b.size_ = n;
+        pos_ += n;
+    }
+
+    // Sizes v for n elements, which the caller then decodes in place.
+    // Returns false if they do not fit.
+    template <typename T, size_t N>
+    bool read_count(Vector<T, N>& v, size_t n) {
+        v.size_ = 0;
+        if (!check_capacity(n, N)) return false;
+        v.size_ = n;
+        return true;
+    }
+
+    // Marks o present and returns its value, which the caller decodes in
+    // place.
+    template <typename T>
+    T& read_present(Optional<T>& o) {
+        o.present_ = true;
+        return o.value_;
+    }
+
+    // The fields of a @framed struct decode with size lowered to the end
+    // of its frame, so they cannot read past it
+    size_t begin_frame() {
+        uint32_t n = read_uint(4);
+        size_t outer = size_;
+        if (check_count(n)) size_ = pos_ + n;
+        return outer;
+    }
+
+    void end_frame(size_t outer) {
+        if (ok() && pos_ != size_) fail(DecodeErrorCode::TrailingData);
+        size_ = outer;
+    }
+
+    // No bytes may follow the message
+    void finish() {
+        if (ok() && pos_ != size_) fail(DecodeErrorCode::TrailingData);
+    }
+
+private:
+    uint32_t read_uint(size_t n) {
+        if (!check_remaining(n)) return 0;
+        uint32_t u = 0;
+        for (size_t i = 0; i < n; ++i) u |= static_cast<uint32_t>(data_[pos_ + i]) << (8 * i);
+        pos_ += n;
+        return u;
+    }
+
+    // Whether p[0..n) is well-formed UTF-8: no overlong forms, surrogates
+    // or code points above U+10FFFF
+    static bool valid_utf8(const uint8_t* p, size_t n) {
+        size_t i = 0;
+        while (i < n) {
+            uint8_t c = p[i];
+            if (c < 0x80) {
+                i++;
+                continue;
+            }
+            size_t len;
+            uint32_t cp;
+            if (c >= 0xC2 && c <= 0xDF) {
+                len = 2;
+                cp = c & 0x1F;
+            } else if (c >= 0xE0 && c <= 0xEF) {
+                len = 3;
+                cp = c & 0x0F;
+            } else if (c >= 0xF0 && c <= 0xF4) {
+                len = 4;
+                cp = c & 0x07;
+            } else {
+                return false;
+            }
+            if (n - i < len) return false;
+            for (size_t k = 1; k < len; k++) {
+                if ((p[i + k] & 0xC0) != 0x80) return false;
+                cp = (cp << 6) | (p[i + k] & 0x3F);
+            }
+            if (len == 3 && (cp < 0x800 || (cp >= 0xD800 && cp <= 0xDFFF))) return false;
+            if (len == 4 && (cp < 0x10000 || cp > 0x10FFFF)) return false;
+            i += len;
+        }
+        return true;
+    }
+
+    const uint8_t* data_;
+    size_t size_;
+    size_t pos_;
+    DecodeErrorCode error_;
+};
+
+`
+}
+
+// embeddedLimitErrors returns the paths of the strings, bytes, slices and
+// maps in the messages and declared types of s that have no @max limit,
+// and of structs that contain themselves, which fixed storage cannot hold.
+func embeddedLimitErrors(s *schema.Schema) []string {
+	var errs []string
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var visit func(path string, t schema.Type)
+	visit = func(path string, t schema.Type) {
+		switch typ := t.(type) {
+		case *schema.PrimitiveType:
+			if (typ.Name == "string" || typ.Name == "bytes") && typ.Max == 0 {
+				errs = append(errs, path+" ("+typ.Name+")")
+			}
+		case *schema.ArrayType:
+			if typ.Length == 0 && typ.Max == 0 {
+				errs = append(errs, path+" ("+typ.TypeName()+")")
+			}
+			visit(path+"[]", typ.ElementType)
+		case *schema.MapType:
+			if typ.Max == 0 {
+				errs = append(errs, path+" ("+typ.TypeName()+")")
+			}
+			visit(path+"{key}", typ.KeyType)
+			visit(path+"{}", typ.ValueType)
+		case *schema.UnionType:
+			for _, v := range typ.Variants {
+				visit(typ.Name+"."+v.TypeName(), v)
+			}
+		case *schema.StructType:
+			if visiting[typ.Name] {
+				errs = append(errs, path+" (contains itself)")
+				return
+			}
+			if done[typ.Name] {
+				return
+			}
+			visiting[typ.Name] = true
+			for _, f := range typ.Fields {
+				visit(typ.Name+"."+f.Name, f.Type)
+			}
+			visiting[typ.Name] = false
+			done[typ.Name] = true
+		}
+	}
+	for _, msg := range s.Messages {
+		visit(msg.Name, msg.TargetType)
+	}
+	for _, t := range s.Types {
+		switch t.(type) {
+		case *schema.StructType, *schema.UnionType:
+			visit(t.TypeName(), t)
+		}
+	}
+	return errs
+}
+
+// generateCppEmbedded generates the header of the embedded profile.
+func generateCppEmbedded(s *schema.Schema) ([]byte, error) {
+	if errs := embeddedLimitErrors(s); len(errs) > 0 {
+		return nil, fmt.Errorf("the embedded C++ profile stores values in fixed capacity, so every string, bytes, slice and map needs a @max limit; missing on %s", strings.Join(errs, ", "))
+	}
+	g := &cppEmbeddedGenerator{schema: s, buf: &bytes.Buffer{}, roots: make(map[string]bool)}
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok {
+			g.roots[st.Name] = true
+		}
+	}
+	return g.generate(), nil
+}
+
+type cppEmbeddedGenerator struct {
+	schema *schema.Schema
+	buf    *bytes.Buffer
+	roots  map[string]bool // Structs of root messages, named <Name>Message
+	depth  int             // Nesting depth, for unique loop variable names
+}
+
+func (g *cppEmbeddedGenerator) generate() []byte {
+	pkg := g.schema.Package
+	guard := strings.ToUpper(pkg) + "_H"
+	g.buf.WriteString("// Code generated by ffire. DO NOT EDIT.\n")
+	g.buf.WriteString("//\n")
+	g.buf.WriteString("// Embedded profile: no heap, no exceptions and no RTTI. Strings, bytes,\n")
+	g.buf.WriteString("// slices and maps are stored inline at the capacity of their @max limits.\n\n")
+	fmt.Fprintf(g.buf, "#ifndef %s\n", guard)
+	fmt.Fprintf(g.buf, "#define %s\n\n", guard)
+	g.buf.WriteString("#include <stddef.h>\n")
+	g.buf.WriteString("#include <stdint.h>\n")
+	g.buf.WriteString("#include <string.h>\n\n")
+	fmt.Fprintf(g.buf, "namespace %s {\n\n", pkg)
+	g.buf.WriteString(cppEmbeddedRuntime())
+	if g.schema.HasFloat64() {
+		g.buf.WriteString("static_assert(sizeof(double) == 8, \"float64 needs a 64-bit double, which AVR has only with avr-gcc 10 or later and -mdouble=64\");\n\n")
+	}
+
+	core := &cppGenerator{schema: g.schema, buf: g.buf}
+	for _, enum := range g.schema.Enums() {
+		core.generateEnum(enum)
+	}
+
+	// Unions hold their variants by value, so each is defined after its
+	// variant structs and before the first struct that uses it
+	structs := sortStructsByDependency(schemaStructs(g.schema))
+	for _, st := range structs {
+		fmt.Fprintf(g.buf, "struct %s;\n", g.structName(st))
+	}
+	for _, u := range g.schema.Unions() {
+		fmt.Fprintf(g.buf, "struct %s;\n", u.Name)
+	}
+	g.buf.WriteString("\n")
+	defined := make(map[string]bool)
+	for _, st := range structs {
+		for _, f := range st.Fields {
+			for _, u := range embeddedUnions(f.Type) {
+				if !defined[u.Name] {
+					defined[u.Name] = true
+					g.generateUnion(u)
+				}
+			}
+		}
+		g.generateStruct(st)
+	}
+	for _, u := range g.schema.Unions() {
+		if !defined[u.Name] {
+			g.generateUnion(u)
+		}
+	}
+	for _, msg := range g.schema.Messages {
+		if _, ok := msg.TargetType.(*schema.StructType); !ok {
+			fmt.Fprintf(g.buf, "using %sMessage = %s;\n\n", msg.Name, g.typeString(msg.TargetType))
+		}
+	}
+
+	// Declared first, since structs and unions can use each other
+	for _, st := range structs {
+		fmt.Fprintf(g.buf, "inline void encode(Encoder& enc, const %s& v);\n", g.structName(st))
+		fmt.Fprintf(g.buf, "inline void decode(Decoder& dec, %s& v);\n", g.structName(st))
+	}
+	for _, u := range g.schema.Unions() {
+		fmt.Fprintf(g.buf, "inline void encode(Encoder& enc, const %s& v);\n", u.Name)
+		fmt.Fprintf(g.buf, "inline void decode(Decoder& dec, %s& v);\n", u.Name)
+	}
+	g.buf.WriteString("\n")
+	for _, st := range structs {
+		g.generateStructCodec(st)
+	}
+	for _, u := range g.schema.Unions() {
+		g.generateUnionCodec(u)
+	}
+
+	for _, msg := range g.schema.Messages {
+		g.generateMessage(msg)
+	}
+
+	fmt.Fprintf(g.buf, "} // namespace %s\n\n", pkg)
+	fmt.Fprintf(g.buf, "#endif // %s\n", guard)
+	return g.buf.Bytes()
+}
+
+// embeddedUnions returns the unions a value of type t holds directly or
+// in its elements, entries and variants.
+func embeddedUnions(t schema.Type) []*schema.UnionType {
+	switch typ := t.(type) {
+	case *schema.UnionType:
+		return []*schema.UnionType{typ}
+	case *schema.ArrayType:
+		return embeddedUnions(typ.ElementType)
+	case *schema.MapType:
+		return embeddedUnions(typ.ValueType)
+	}
+	return nil
+}
+
+func (g *cppEmbeddedGenerator) structName(st *schema.StructType) string {
+	if g.roots[st.Name] {
+		return st.Name + "Message"
+	}
+	return st.Name
+}
+
+// cppOnlyKeywords are the C++ keywords that C does not reserve, which
+// union member names are checked against besides IsCOrGoKeyword.
+var cppOnlyKeywords = map[string]bool{
+	"bool": true, "catch": true, "class": true, "delete": true, "explicit": true,
+	"friend": true, "mutable": true, "namespace": true, "new": true, "operator": true,
+	"private": true, "protected": true, "public": true, "template": true, "this": true,
+	"throw": true, "try": true, "typename": true, "using": true, "virtual": true,
+}
+
+// unionMember returns the name of the member of a union holding variant
+// v: its type name in lowercase, with a trailing underscore if that is a
+// keyword or the tag.
+func unionMember(v schema.Type) string {
+	name := strings.ToLower(v.TypeName())
+	if IsCOrGoKeyword(name) || cppOnlyKeywords[name] || name == "tag" {
+		name += "_"
+	}
+	return name
+}
+
+func (g *cppEmbeddedGenerator) typeString(t schema.Type) string {
+	var s string
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		switch typ.Name {
+		case "string":
+			s = fmt.Sprintf("String<%d>", typ.Max)
+		case "bytes":
+			s = fmt.Sprintf("Bytes<%d>", typ.Max)
+		default:
+			s = (&cppGenerator{}).cppPrimitiveType(typ.Name)
+		}
+	case *schema.EnumType:
+		// Named types are qualified, since fields are often named after
+		// their type (Mode Mode)
+		s = g.schema.Package + "::" + typ.Name
+	case *schema.StructType:
+		s = g.schema.Package + "::" + g.structName(typ)
+	case *schema.UnionType:
+		s = g.schema.Package + "::" + typ.Name
+	case *schema.ArrayType:
+		if typ.Length > 0 {
+			s = fmt.Sprintf("Array<%s, %d>", g.typeString(typ.ElementType), typ.Length)
+		} else {
+			s = fmt.Sprintf("Vector<%s, %d>", g.typeString(typ.ElementType), typ.Max)
+		}
+	case *schema.MapType:
+		s = fmt.Sprintf("Map<%s, %s, %d>", g.typeString(typ.KeyType), g.typeString(typ.ValueType), typ.Max)
+	}
+	if t.IsOptional() {
+		return "Optional<" + s + ">"
+	}
+	return s
+}
+
+// memberInit returns the initializer of a struct member of type t:
+// numbers, enums and fixed arrays start zeroed, the other types have
+// constructors.
+func memberInit(t schema.Type) string {
+	if t.IsOptional() {
+		return ""
+	}
+	switch typ := t.(type) {
+	case *schema.EnumType:
+		return "{}"
+	case *schema.PrimitiveType:
+		if typ.Name != "string" && typ.Name != "bytes" {
+			return "{}"
+		}
+	case *schema.ArrayType:
+		if typ.Length > 0 {
+			return "{}"
+		}
+	}
+	return ""
+}
+
+func (g *cppEmbeddedGenerator) generateStruct(st *schema.StructType) {
+	g.buf.WriteString(lineDoc("", "///", st.Doc))
+	fmt.Fprintf(g.buf, "struct %s {\n", g.structName(st))
+	for _, f := range st.Fields {
+		g.buf.WriteString(lineDoc("    ", "///", f.Doc))
+		fmt.Fprintf(g.buf, "    %s %s%s;\n", g.typeString(f.Type), f.Name, memberInit(f.Type))
+	}
+	g.buf.WriteString("};\n\n")
+}
+
+func (g *cppEmbeddedGenerator) generateUnion(u *schema.UnionType) {
+	tags := make([]string, len(u.Variants))
+	for i, v := range u.Variants {
+		tags[i] = fmt.Sprintf("%d for %s", i+1, unionMember(v))
+	}
+	g.buf.WriteString(lineDoc("", "///", u.Doc))
+	fmt.Fprintf(g.buf, "// %s holds the variant selected by tag, its wire tag: %s.\n", u.Name, strings.Join(tags, ", "))
+	g.buf.WriteString("// The members of the other variants are unused.\n")
+	fmt.Fprintf(g.buf, "struct %s {\n", u.Name)
+	g.buf.WriteString("    uint8_t tag = 1;\n")
+	for _, v := range u.Variants {
+		fmt.Fprintf(g.buf, "    %s %s%s;\n", g.typeString(v), unionMember(v), memberInit(v))
+	}
+	g.buf.WriteString("};\n\n")
+}
+
+func (g *cppEmbeddedGenerator) generateStructCodec(st *schema.StructType) {
+	name := g.structName(st)
+	fmt.Fprintf(g.buf, "inline void encode(Encoder& enc, const %s& v) {\n", name)
+	if len(st.Fields) == 0 && !st.Framed {
+		g.buf.WriteString("    (void)enc;\n    (void)v;\n")
+	}
+	if st.Framed {
+		g.buf.WriteString("    size_t frame = enc.begin_frame();\n")
+	}
+	if st.Bitmap {
+		g.writeBitmap(st)
+	}
+	for _, f := range st.Fields {
+		g.encodeValue("v."+f.Name, f.Type, st.Bitmap, "    ")
+	}
+	if st.Framed {
+		g.buf.WriteString("    enc.end_frame(frame);\n")
+	}
+	g.buf.WriteString("}\n\n")
+
+	fmt.Fprintf(g.buf, "inline void decode(Decoder& dec, %s& v) {\n", name)
+	if len(st.Fields) == 0 && !st.Framed {
+		g.buf.WriteString("    (void)dec;\n    (void)v;\n")
+	}
+	if st.Framed {
+		g.buf.WriteString("    size_t outer = dec.begin_frame();\n")
+	}
+	bits := g.readBitmap(st)
+	for _, f := range st.Fields {
+		g.decodeValue("v."+f.Name, f.Type, bits[f.Name], "    ")
+	}
+	if st.Framed {
+		g.buf.WriteString("    dec.end_frame(outer);\n")
+	}
+	g.buf.WriteString("}\n\n")
+}
+
+// writeBitmap writes the presence bitmap of a @bitmap struct, one byte per
+// eight optional fields.
+func (g *cppEmbeddedGenerator) writeBitmap(st *schema.StructType) {
+	var optional []string
+	for _, f := range st.Fields {
+		if f.Type.IsOptional() {
+			optional = append(optional, f.Name)
+		}
+	}
+	for start := 0; start < len(optional); start += 8 {
+		bitsVar := fmt.Sprintf("bits%d", start/8)
+		fmt.Fprintf(g.buf, "    uint8_t %s = 0;\n", bitsVar)
+		for i, name := range optional[start:min(start+8, len(optional))] {
+			fmt.Fprintf(g.buf, "    if (v.%s.has_value()) %s |= 0x%02x;\n", name, bitsVar, 1<<i)
+		}
+		fmt.Fprintf(g.buf, "    enc.write_byte(%s);\n", bitsVar)
+	}
+}
+
+// readBitmap reads the presence bitmap of a @bitmap struct, failing if
+// unused bits are set, and returns the bit test of each optional field by
+// name. It returns nil for other structs.
+func (g *cppEmbeddedGenerator) readBitmap(st *schema.StructType) map[string]string {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return nil
+	}
+	fmt.Fprintf(g.buf, "    uint8_t bits[%d];\n", n)
+	fmt.Fprintf(g.buf, "    dec.read_raw(bits, %d);\n", n)
+	if used := schema.OptionalFields(st) % 8; used != 0 {
+		fmt.Fprintf(g.buf, "    if (bits[%d] & 0x%02x) dec.fail(DecodeErrorCode::BadPresence);\n", n-1, 0xff<<used&0xff)
+	}
+	tests := make(map[string]string)
+	bit := 0
+	for _, f := range st.Fields {
+		if f.Type.IsOptional() {
+			tests[f.Name] = fmt.Sprintf("bits[%d] & 0x%02x", bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	return tests
+}
+
+func (g *cppEmbeddedGenerator) generateUnionCodec(u *schema.UnionType) {
+	fmt.Fprintf(g.buf, "inline void encode(Encoder& enc, const %s& v) {\n", u.Name)
+	g.buf.WriteString("    enc.write_byte(v.tag);\n")
+	g.buf.WriteString("    switch (v.tag) {\n")
+	for i, v := range u.Variants {
+		fmt.Fprintf(g.buf, "    case %d:\n", i+1)
+		g.encodeContent("v."+unionMember(v), v, "        ")
+		g.buf.WriteString("        break;\n")
+	}
+	g.buf.WriteString("    default:\n")
+	g.buf.WriteString("        enc.fail();\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+
+	fmt.Fprintf(g.buf, "inline void decode(Decoder& dec, %s& v) {\n", u.Name)
+	g.buf.WriteString("    v.tag = dec.read_byte();\n")
+	g.buf.WriteString("    switch (v.tag) {\n")
+	for i, v := range u.Variants {
+		fmt.Fprintf(g.buf, "    case %d:\n", i+1)
+		g.decodeContent("v."+unionMember(v), v, "        ")
+		g.buf.WriteString("        break;\n")
+	}
+	g.buf.WriteString("    default:\n")
+	g.buf.WriteString("        dec.fail(DecodeErrorCode::InvalidValue);\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// encodeValue writes the value in val, with its presence byte if it is
+// optional. The presence of a field of a @bitmap struct is in the bitmap.
+func (g *cppEmbeddedGenerator) encodeValue(val string, t schema.Type, bitmapped bool, indent string) {
+	if !t.IsOptional() {
+		g.encodeContent(val, t, indent)
+		return
+	}
+	fmt.Fprintf(g.buf, "%sif (%s.has_value()) {\n", indent, val)
+	if !bitmapped {
+		fmt.Fprintf(g.buf, "%s    enc.write_byte(0x01);\n", indent)
+	}
+	g.encodeContent("(*"+val+")", t, indent+"    ")
+	if !bitmapped {
+		fmt.Fprintf(g.buf, "%s} else {\n", indent)
+		fmt.Fprintf(g.buf, "%s    enc.write_byte(0x00);\n", indent)
+	}
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+}
+
+// encodeContent writes a value whose presence, if it is optional, has been
+// written.
+func (g *cppEmbeddedGenerator) encodeContent(val string, t schema.Type, indent string) {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		switch typ.Name {
+		case "string", "bytes":
+			g.writeLength(val, t, indent)
+			fmt.Fprintf(g.buf, "%senc.write_raw(%s.data(), %s.size());\n", indent, val, val)
+		default:
+			fmt.Fprintf(g.buf, "%senc.write_%s(%s);\n", indent, typ.Name, val)
+		}
+	case *schema.EnumType:
+		fmt.Fprintf(g.buf, "%senc.write_%s(static_cast<%s>(%s));\n", indent, typ.Base, (&cppGenerator{}).cppPrimitiveType(typ.Base), val)
+	case *schema.StructType, *schema.UnionType:
+		fmt.Fprintf(g.buf, "%sencode(enc, %s);\n", indent, val)
+	case *schema.ArrayType:
+		if typ.Length == 0 {
+			g.writeLength(val, t, indent)
+		}
+		elem := fmt.Sprintf("e%d", g.depth)
+		g.depth++
+		fmt.Fprintf(g.buf, "%sfor (const auto& %s : %s) {\n", indent, elem, val)
+		g.encodeValue(elem, typ.ElementType, false, indent+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		g.depth--
+	case *schema.MapType:
+		g.writeLength(val, t, indent)
+		entry := fmt.Sprintf("e%d", g.depth)
+		g.depth++
+		fmt.Fprintf(g.buf, "%sfor (const auto& %s : %s) {\n", indent, entry, val)
+		g.encodeContent(entry+".key", typ.KeyType, indent+"    ")
+		g.encodeValue(entry+".value", typ.ValueType, false, indent+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		g.depth--
+	}
+}
+
+// writeLength writes the length prefix of val: a uint16, or a uint32 if t
+// is @large.
+func (g *cppEmbeddedGenerator) writeLength(val string, t schema.Type, indent string) {
+	fmt.Fprintf(g.buf, "%senc.%s(%s.size());\n", indent, cppLargeName("write_length", t), val)
+}
+
+// readLength returns the expression that reads the length prefix of a
+// value of type t.
+func readLength(t schema.Type) string {
+	return "dec." + cppLargeName("read_length", t) + "()"
+}
+
+// decodeValue reads the value into dst, with its presence byte if it is
+// optional. present is the bitmap test of a field of a @bitmap struct,
+// whose presence is read instead of a byte.
+func (g *cppEmbeddedGenerator) decodeValue(dst string, t schema.Type, present, indent string) {
+	if !t.IsOptional() {
+		g.decodeContent(dst, t, indent)
+		return
+	}
+	if present == "" {
+		present = "dec.read_presence()"
+	}
+	v := fmt.Sprintf("o%d", g.depth)
+	g.depth++
+	fmt.Fprintf(g.buf, "%sif (%s) {\n", indent, present)
+	fmt.Fprintf(g.buf, "%s    auto& %s = dec.read_present(%s);\n", indent, v, dst)
+	g.decodeContent(v, t, indent+"    ")
+	fmt.Fprintf(g.buf, "%s} else {\n", indent)
+	fmt.Fprintf(g.buf, "%s    %s.reset();\n", indent, dst)
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+	g.depth--
+}
+
+// decodeContent reads a value whose presence, if it is optional, has been
+// read. Values are decoded in place, so nothing large is copied through
+// the stack.
+func (g *cppEmbeddedGenerator) decodeContent(dst string, t schema.Type, indent string) {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		switch typ.Name {
+		case "string":
+			fmt.Fprintf(g.buf, "%sdec.read_string(%s, %s);\n", indent, dst, readLength(t))
+		case "bytes":
+			fmt.Fprintf(g.buf, "%sdec.read_bytes(%s, %s);\n", indent, dst, readLength(t))
+		default:
+			fmt.Fprintf(g.buf, "%s%s = dec.read_%s();\n", indent, dst, typ.Name)
+		}
+	case *schema.EnumType:
+		name := g.schema.Package + "::" + typ.Name
+		raw := fmt.Sprintf("raw%d", g.depth)
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		fmt.Fprintf(g.buf, "%s    auto %s = dec.read_%s();\n", indent, raw, typ.Base)
+		fmt.Fprintf(g.buf, "%s    if (!is_valid(static_cast<%s>(%s))) dec.fail(DecodeErrorCode::InvalidValue);\n", indent, name, raw)
+		fmt.Fprintf(g.buf, "%s    %s = static_cast<%s>(%s);\n", indent, dst, name, raw)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	case *schema.StructType, *schema.UnionType:
+		fmt.Fprintf(g.buf, "%sdecode(dec, %s);\n", indent, dst)
+	case *schema.ArrayType:
+		elem := fmt.Sprintf("e%d", g.depth)
+		g.depth++
+		if typ.Length == 0 {
+			fmt.Fprintf(g.buf, "%sif (dec.read_count(%s, %s)) {\n", indent, dst, readLength(t))
+			indent += "    "
+		}
+		fmt.Fprintf(g.buf, "%sfor (auto& %s : %s) {\n", indent, elem, dst)
+		g.decodeValue(elem, typ.ElementType, "", indent+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		if typ.Length == 0 {
+			indent = indent[:len(indent)-4]
+			fmt.Fprintf(g.buf, "%s}\n", indent)
+		}
+		g.depth--
+	case *schema.MapType:
+		// Entries are inserted in key order; a repeated key keeps its
+		// last value
+		n := fmt.Sprintf("n%d", g.depth)
+		i := fmt.Sprintf("i%d", g.depth)
+		key := fmt.Sprintf("k%d", g.depth)
+		val := fmt.Sprintf("v%d", g.depth)
+		g.depth++
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		fmt.Fprintf(g.buf, "%s    size_t %s = %s;\n", indent, n, readLength(t))
+		fmt.Fprintf(g.buf, "%s    %s.clear();\n", indent, dst)
+		fmt.Fprintf(g.buf, "%s    if (dec.check_capacity(%s, %s.capacity())) {\n", indent, n, dst)
+		fmt.Fprintf(g.buf, "%s        for (size_t %s = 0; %s < %s && dec.ok(); ++%s) {\n", indent, i, i, n, i)
+		fmt.Fprintf(g.buf, "%s            %s %s{};\n", indent, g.typeString(typ.KeyType), key)
+		g.decodeContent(key, typ.KeyType, indent+"            ")
+		fmt.Fprintf(g.buf, "%s            %s* %s = %s.insert(%s);\n", indent, g.typeString(typ.ValueType), val, dst, key)
+		g.decodeValue("(*"+val+")", typ.ValueType, "", indent+"            ")
+		fmt.Fprintf(g.buf, "%s        }\n", indent)
+		fmt.Fprintf(g.buf, "%s    }\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		g.depth--
+	}
+}
+
+func (g *cppEmbeddedGenerator) generateMessage(msg schema.MessageType) {
+	name := strings.ToLower(msg.Name)
+	typ := msg.Name + "Message"
+	maxSize := analyzer.AnalyzeType(g.schema, msg.TargetType).MaxSize
+	_, isStruct := msg.TargetType.(*schema.StructType)
+
+	fmt.Fprintf(g.buf, "// Largest encoding of a %s: a buffer of this size holds any message\n", typ)
+	g.buf.WriteString("// within the @max limits.\n")
+	fmt.Fprintf(g.buf, "constexpr size_t %s_message_max_size = %d;\n\n", name, maxSize)
+
+	fmt.Fprintf(g.buf, "// Encode %s into the capacity bytes at out and set size to the number\n", msg.Name)
+	g.buf.WriteString("// written. Returns false if the message does not fit.\n")
+	fmt.Fprintf(g.buf, "inline bool encode_%s_message(const %s& value, uint8_t* out, size_t capacity, size_t& size) {\n", name, typ)
+	g.buf.WriteString("    Encoder enc(out, capacity);\n")
+	if isStruct {
+		g.buf.WriteString("    encode(enc, value);\n")
+	} else {
+		g.encodeContent("value", msg.TargetType, "    ")
+	}
+	g.buf.WriteString("    size = enc.size();\n")
+	g.buf.WriteString("    return enc.ok();\n")
+	g.buf.WriteString("}\n\n")
+
+	fmt.Fprintf(g.buf, "// Decode %s from the size bytes at data into result. Returns\n", msg.Name)
+	g.buf.WriteString("// DecodeErrorCode::Ok, or the kind of failure, which leaves result\n")
+	g.buf.WriteString("// unspecified. The result holds copies: nothing in it points into data.\n")
+	fmt.Fprintf(g.buf, "inline DecodeErrorCode decode_%s_message(const uint8_t* data, size_t size, %s& result) {\n", name, typ)
+	g.buf.WriteString("    Decoder dec(data, size);\n")
+	if isStruct {
+		g.buf.WriteString("    decode(dec, result);\n")
+	} else {
+		g.decodeContent("result", msg.TargetType, "    ")
+	}
+	g.buf.WriteString("    dec.finish();\n")
+	g.buf.WriteString("    return dec.error();\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// checkCppEmbedded reports settings that --cpp-embedded, or --cpp-arduino,
+// which packages the same profile, cannot work with. The profile has no C
+// ABI, shared library or SIMD paths, and the generated tests and
+// handshake use the standard library.
+func checkCppEmbedded(config *PackageConfig) error {
+	flag := "--cpp-embedded"
+	switch {
+	case config.CppArduino:
+		flag = "--cpp-arduino"
+	case !config.CppEmbedded:
+		return nil
+	}
+	if canonicalLanguage(config.Language) != "cpp" {
+		return fmt.Errorf("%s is not supported for %s (supported: cpp)", flag, config.Language)
+	}
+	for _, other := range []struct {
+		set  bool
+		flag string
+	}{
+		{config.CppSIMD, "--cpp-simd"},
+		{config.WithTests, "--with-tests"},
+		{config.Handshake, "--handshake"},
+		{config.Descriptor, "--descriptor"},
+		{len(config.Sanitize) > 0, "--sanitize"},
+		{config.BuildRules != "", "--build-rules"},
+	} {
+		if other.set {
+			return fmt.Errorf("%s cannot be combined with %s", flag, other.flag)
+		}
+	}
+	return nil
+}
+
+// generateEmbeddedPackage writes the embedded header and a README. There
+// is nothing to compile: firmware builds include the header.
+func generateEmbeddedPackage(config *PackageConfig) error {
+	code, err := GenerateCppWithOptions(config.Schema, CppOptions{Embedded: true})
+	if err != nil {
+		return fmt.Errorf("failed to generate C++ code: %w", err)
+	}
+	langDir := config.langDir(config.Language)
+	includeDir := filepath.Join(langDir, "include")
+	if err := os.MkdirAll(includeDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", includeDir, err)
+	}
+	headerPath := filepath.Join(includeDir, "generated.hpp")
+	if err := os.WriteFile(headerPath, code, 0644); err != nil {
+		return fmt.Errorf("failed to write C++ header: %w", err)
+	}
+	config.infof("✓ Generated embedded C++ header: %s\n", headerPath)
+
+	readme := embeddedReadme(config.Schema, "generated.hpp", "") + readmeLicenseSection(config)
+	if err := os.WriteFile(filepath.Join(langDir, "README.md"), []byte(readme), 0644); err != nil {
+		return fmt.Errorf("failed to write README: %w", err)
+	}
+
+	config.infof("\n✅ Package ready at: %s\n", langDir)
+	config.infof("   Header only: add include/ to the firmware's include path\n")
+	return nil
+}
+
+// embeddedReadme returns the README of an embedded package whose header
+// sketches include as header, with an optional section on installing it.
+func embeddedReadme(s *schema.Schema, header, install string) string {
+	pkg := s.Package
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", pkg)
+	fmt.Fprintf(&b, "Embedded C++ code for the %s schema, generated by ffire. The header allocates nothing, throws nothing and needs no RTTI, so it builds for microcontrollers with `-fno-exceptions -fno-rtti` and C++11 or later.\n\n", pkg)
+	b.WriteString(install)
+	b.WriteString("## Message sizes\n\n")
+	b.WriteString("A buffer of the maximum size holds any message within the schema's `@max` limits.\n\n")
+	b.WriteString("| Message | Maximum size (bytes) |\n")
+	b.WriteString("|---------|----------------------|\n")
+	for _, msg := range s.Messages {
+		fmt.Fprintf(&b, "| %s | %d |\n", msg.Name, analyzer.AnalyzeType(s, msg.TargetType).MaxSize)
+	}
+	if len(s.Messages) > 0 {
+		name := strings.ToLower(s.Messages[0].Name)
+		typ := s.Messages[0].Name + "Message"
+		b.WriteString("\n## Usage\n\n")
+		b.WriteString("```cpp\n")
+		fmt.Fprintf(&b, "#include <%s>\n\n", header)
+		fmt.Fprintf(&b, "static uint8_t buf[%s::%s_message_max_size];\n", pkg, name)
+		fmt.Fprintf(&b, "static %s::%s msg;\n\n", pkg, typ)
+		b.WriteString("size_t n;\n")
+		fmt.Fprintf(&b, "if (%s::encode_%s_message(msg, buf, sizeof(buf), n)) {\n", pkg, name)
+		b.WriteString("    uart_write(buf, n);\n")
+		b.WriteString("}\n\n")
+		fmt.Fprintf(&b, "if (%s::decode_%s_message(rx, rx_len, msg) != %s::DecodeErrorCode::Ok) {\n", pkg, name, pkg)
+		b.WriteString("    // not a valid message\n")
+		b.WriteString("}\n")
+		b.WriteString("```\n")
+	}
+	b.WriteString("\n## Types\n\n")
+	b.WriteString("- Strings are `String<N>`, NUL-terminated, and bytes are `Bytes<N>`, with `N` from `@max`; `assign` returns false for values that do not fit\n")
+	b.WriteString("- Slices are `Vector<T, N>`, fixed-size arrays `Array<T, N>` and maps `Map<K, V, N>`, which keeps its entries sorted by key\n")
+	b.WriteString("- Optional fields are `Optional<T>`\n")
+	b.WriteString("- Unions are structs with a `tag`, the 1-based variant, and a member per variant\n")
+	b.WriteString("- Messages are large: declare them `static` or global rather than on the stack\n")
+	return b.String()
+}
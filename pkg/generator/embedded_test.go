@@ -0,0 +1,198 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const embeddedTestSchema = `package tele
+
+type Mode int8
+
+const (
+	Idle Mode = iota
+	Run
+)
+
+type Point struct {
+	X float32
+	Y float32
+}
+
+// @max(8)
+type Value interface {
+	Point | string
+}
+
+// @bitmap
+type Flags struct {
+	A *int32
+	B *bool
+}
+
+type Reading struct {
+	Sensor string // @max(16)
+	Mode   Mode
+	Unit   *string // @max(4)
+	Tags   []string // @max(2, 6)
+	Limits map[string]int32 // @max(2, 5)
+	V      *Value
+	Flags  Flags
+}
+
+// @max(2)
+type Batch []Reading
+`
+
+func TestGenerateCppEmbedded(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(embeddedTestSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	code, err := GenerateCppWithOptions(s, CppOptions{Embedded: true})
+	if err != nil {
+		t.Fatalf("GenerateCppWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"String<16> Sensor;",
+		"Optional<String<4>> Unit;",
+		"Vector<String<6>, 2> Tags;",
+		"Map<String<5>, int32_t, 2> Limits;",
+		"using BatchMessage = Vector<tele::Reading, 2>;",
+		"constexpr size_t batch_message_max_size = ",
+		"inline bool encode_batch_message(const BatchMessage& value, uint8_t* out, size_t capacity, size_t& size) {",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"#include <string>", "#include <vector>", "throw ", "std::string", "std::vector"} {
+		if strings.Contains(string(code), unwanted) {
+			t.Errorf("output contains %q", unwanted)
+		}
+	}
+
+	s, err = parser.ParseBytes([]byte("package p\n\ntype A struct {\n\tName string\n\tIDs []int32 // @max(4)\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	_, err = GenerateCppWithOptions(s, CppOptions{Embedded: true})
+	if err == nil || !strings.Contains(err.Error(), "missing on A.Name (string)") || strings.Contains(err.Error(), "IDs") {
+		t.Errorf("err = %v, want it to name A.Name only", err)
+	}
+}
+
+// TestCppEmbeddedRoundtrip builds the header without exceptions or RTTI,
+// as firmware does, and checks that messages survive a roundtrip, that
+// buffers of the maximum size hold full messages and that decoding
+// reports truncation, trailing data, invalid UTF-8 and values over their
+// limits.
+func TestCppEmbeddedRoundtrip(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	s, err := parser.ParseBytes([]byte(embeddedTestSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	code, err := GenerateCppWithOptions(s, CppOptions{Embedded: true})
+	if err != nil {
+		t.Fatalf("GenerateCppWithOptions failed: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tele.hpp"), code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := `#include "tele.hpp"
+#include <stdio.h>
+
+using namespace tele;
+
+static BatchMessage in, out;
+static uint8_t buf[batch_message_max_size + 1];
+
+int main() {
+    for (int i = 0; i < 2; ++i) {
+        Reading r;
+        r.Sensor.assign("0123456789abcdef");
+        r.Mode = Mode::Run;
+        r.Unit = String<4>();
+        r.Unit->assign("degC");
+        String<6> tag;
+        tag.assign("abcdef");
+        r.Tags.push_back(tag);
+        r.Tags.push_back(tag);
+        String<5> key;
+        key.assign("zz");
+        r.Limits.set(key, -1);
+        key.assign("aa");
+        r.Limits.set(key, 7);
+        Value v;
+        v.tag = 2;
+        v.string.assign("12345678");
+        r.V = v;
+        r.Flags.B = true;
+        in.push_back(r);
+    }
+    size_t n;
+    if (!encode_batch_message(in, buf, sizeof(buf), n)) return 1;
+    if (encode_batch_message(in, buf, n - 1, n)) return 2;
+    encode_batch_message(in, buf, sizeof(buf), n);
+    if (decode_batch_message(buf, n, out) != DecodeErrorCode::Ok) return 3;
+    const Reading& r = out[1];
+    if (out.size() != 2 || r.Sensor != in[1].Sensor || r.Mode != Mode::Run || !r.Unit || *r.Unit != *in[1].Unit) return 4;
+    if (r.Tags.size() != 2 || r.Limits.begin()->value != 7 || !r.V || r.V->tag != 2 || r.V->string != in[1].V->string) return 5;
+    if (r.Flags.A.has_value() || !r.Flags.B || !*r.Flags.B) return 6;
+    for (size_t i = 0; i < n; ++i) {
+        if (decode_batch_message(buf, i, out) == DecodeErrorCode::Ok) return 7;
+    }
+    if (decode_batch_message(buf, n + 1, out) != DecodeErrorCode::TrailingData) return 9;
+    in[0].Sensor.assign("\xff");
+    encode_batch_message(in, buf, sizeof(buf), n);
+    if (decode_batch_message(buf, n, out) != DecodeErrorCode::InvalidUtf8) return 10;
+    buf[0] = 3; // count over @max(2)
+    if (decode_batch_message(buf, n, out) != DecodeErrorCode::InvalidValue) return 8;
+    puts("ok");
+    return 0;
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.cpp"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "main")
+	args := []string{"-std=c++11", "-Wall", "-Wextra", "-Werror", "-fno-exceptions", "-fno-rtti", "-o", bin, filepath.Join(dir, "main.cpp")}
+	if out, err := exec.Command("g++", args...).CombinedOutput(); err != nil {
+		t.Fatalf("g++ failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(bin).CombinedOutput(); err != nil {
+		t.Errorf("%v\n%s", err, out)
+	}
+}
+
+func TestCheckCppEmbedded(t *testing.T) {
+	tests := []struct {
+		config PackageConfig
+		want   string
+	}{
+		{PackageConfig{Language: "cpp", CppEmbedded: true}, ""},
+		{PackageConfig{Language: "c++", CppEmbedded: true}, ""},
+		{PackageConfig{Language: "swift", CppEmbedded: true}, "not supported for swift"},
+		{PackageConfig{Language: "cpp", CppEmbedded: true, CppSIMD: true}, "--cpp-simd"},
+		{PackageConfig{Language: "cpp", CppEmbedded: true, WithTests: true}, "--with-tests"},
+		{PackageConfig{Language: "go"}, ""},
+	}
+	for _, tt := range tests {
+		err := checkCppEmbedded(&tt.config)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.config.Language, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.config.Language, err, tt.want)
+		}
+	}
+}
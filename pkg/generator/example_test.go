@@ -0,0 +1,34 @@
+package generator_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func ExampleGenerate() {
+	src := `package audio
+
+type Device struct {
+	Name     string
+	Channels int32
+}
+`
+	s, err := parser.ParseReader(strings.NewReader(src), parser.Options{Validate: true})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var code bytes.Buffer
+	if err := generator.Generate(&code, s, "go"); err != nil {
+		log.Fatal(err)
+	}
+	line, _ := bufio.NewReader(&code).ReadString('\n')
+	fmt.Print(line)
+	// Output: // Code generated by ffire. DO NOT EDIT.
+}
@@ -0,0 +1,139 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sourceFormatter is a native formatter applied to generated sources.
+// Formatters pick up project settings (.clang-format, .swift-format,
+// rustfmt.toml) from the directories above the output, so generated code
+// follows the style of the repository it is committed to.
+type sourceFormatter struct {
+	lang  string   // Language name shown in output
+	exts  []string // File extensions the formatter handles
+	tools [][]string
+}
+
+// sourceFormatters lists the formatters tried after generation. For each
+// one the first tool found on PATH is used, with the files appended to its
+// arguments. Go is formatted in-process by GenerateGo.
+var sourceFormatters = []sourceFormatter{
+	{lang: "C/C++", exts: []string{".h", ".hpp", ".c", ".cc", ".cpp"}, tools: [][]string{{"clang-format", "-i"}}},
+	{lang: "Swift", exts: []string{".swift"}, tools: [][]string{{"swift-format", "format", "-i"}, {"swift", "format", "-i"}}},
+	{lang: "Rust", exts: []string{".rs"}, tools: [][]string{{"rustfmt", "--edition", "2021"}}},
+	{lang: "Dart", exts: []string{".dart"}, tools: [][]string{{"dart", "format"}}},
+	{lang: "Zig", exts: []string{".zig"}, tools: [][]string{{"zig", "fmt"}}},
+}
+
+// formatSkipDirs are directories under the output that hold build
+// artifacts or fetched dependencies rather than generated sources.
+var formatSkipDirs = map[string]bool{
+	"target":       true,
+	"node_modules": true,
+	"build":        true,
+}
+
+// formatOutput runs the available native formatters over the source files
+// written to config.OutputDir since start. Formatting is best effort: a
+// missing tool leaves its files as generated, and a failing one is reported
+// as a warning.
+func formatOutput(config *PackageConfig, start time.Time) error {
+	files, err := generatedSources(config.OutputDir, start)
+	if err != nil {
+		return fmt.Errorf("failed to list generated sources: %w", err)
+	}
+
+	for _, f := range sourceFormatters {
+		var matched []string
+		for _, path := range files {
+			if hasExt(path, f.exts) {
+				matched = append(matched, path)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		tool := findTool(f.tools)
+		if tool == nil {
+			config.debugf("No %s formatter found (tried %s), leaving %d files unformatted\n", f.lang, toolNames(f.tools), len(matched))
+			continue
+		}
+
+		args := append(append([]string{}, tool[1:]...), matched...)
+		cmd := config.command(tool[0], args...)
+		config.debugf("Running: %s %s\n", tool[0], strings.Join(args, " "))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if ctxErr := config.context().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			config.warnf("⚠ %s failed, leaving %s sources unformatted: %v\n%s", tool[0], f.lang, err, output)
+			continue
+		}
+		config.infof("✓ Formatted %s sources with %s (%d files)\n", f.lang, tool[0], len(matched))
+	}
+	return nil
+}
+
+// generatedSources returns the files under dir modified at or after start.
+func generatedSources(dir string, start time.Time) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || formatSkipDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		since := start
+		if info.ModTime().Nanosecond() == 0 {
+			// File systems with whole-second timestamps round down
+			since = start.Truncate(time.Second)
+		}
+		if !info.ModTime().Before(since) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func hasExt(path string, exts []string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// findTool returns the first candidate command whose executable is on PATH.
+func findTool(candidates [][]string) []string {
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+func toolNames(candidates [][]string) string {
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		names[i] = c[0]
+	}
+	return strings.Join(names, ", ")
+}
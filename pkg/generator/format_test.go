@@ -0,0 +1,113 @@
+package generator
+
+import (
+	"bytes"
+	"go/format"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// fakeFormatter puts an executable named tool on PATH that records the
+// files it was asked to format.
+func fakeFormatter(t *testing.T, tool string) (logPath string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake formatter is a shell script")
+	}
+	bin := t.TempDir()
+	logPath = filepath.Join(bin, "calls.log")
+	script := "#!/bin/sh\nfor a in \"$@\"; do echo \"$a\" >> " + logPath + "; done\n"
+	if err := os.WriteFile(filepath.Join(bin, tool), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath
+}
+
+func TestFormatOutputOnlyTouchesNewSources(t *testing.T) {
+	logPath := fakeFormatter(t, "clang-format")
+	out := t.TempDir()
+
+	write := func(rel string) string {
+		path := filepath.Join(out, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("int x;\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	stale := write("old/stale.cpp")
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	header := write("cpp/include/msg.h")
+	source := write("cpp/src/msg.cpp")
+	write("cpp/.build/dep.cpp")
+	write("cpp/README.md")
+
+	if err := formatOutput(&PackageConfig{OutputDir: out}, start); err != nil {
+		t.Fatalf("formatOutput failed: %v", err)
+	}
+
+	logged, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("formatter was not run: %v", err)
+	}
+	got := strings.Fields(string(logged))
+	want := []string{"-i", header, source}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("formatter args = %v, want %v", got, want)
+	}
+}
+
+func TestNoFormatSkipsFormatters(t *testing.T) {
+	logPath := fakeFormatter(t, "clang-format")
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	config := &PackageConfig{Schema: s, Language: "cpp", OutputDir: t.TempDir(), NoCompile: true, NoFormat: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	if _, err := os.Stat(logPath); err == nil {
+		t.Error("formatter ran despite NoFormat")
+	}
+}
+
+func TestGenerateGoUnformatted(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/complex.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	raw, err := GenerateGoUnformatted(s)
+	if err != nil {
+		t.Fatalf("GenerateGoUnformatted failed: %v", err)
+	}
+	formatted, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+
+	gofmted, err := format.Source(raw)
+	if err != nil {
+		t.Fatalf("unformatted output does not parse: %v", err)
+	}
+	if !bytes.Equal(gofmted, formatted) {
+		t.Error("GenerateGo should equal gofmt applied to GenerateGoUnformatted")
+	}
+}
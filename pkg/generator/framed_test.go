@@ -0,0 +1,235 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// framedTestSchema has a @framed root that is also @bitmap, and a @framed
+// struct nested optionally, in an array and in a union.
+const framedTestSchema = `package frames
+
+// @framed
+type Point struct {
+	X int32
+	Y int32
+}
+
+type Shape interface {
+	Point | string
+}
+
+// @framed
+// @bitmap
+type Record struct {
+	ID     int64
+	Name   string
+	Note   *string
+	Origin *Point
+	Path   []Point
+	Shape  Shape
+}
+`
+
+var framedTestValues = []string{
+	`{"ID": 1, "Name": "none", "Path": [], "Shape": {"string": "s"}}`,
+	`{"ID": 2, "Name": "all", "Note": "n", "Origin": {"X": 1, "Y": -1},
+	  "Path": [{"X": 2, "Y": 3}, {"X": 4, "Y": 5}], "Shape": {"Point": {"X": 6, "Y": 7}}}`,
+}
+
+// framedFixtures parses framedTestSchema, generates code for it with gen
+// and returns the reference encodings of framedTestValues.
+func framedFixtures(t *testing.T, gen func(*schema.Schema) ([]byte, error)) ([]byte, [][]byte) {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(framedTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := gen(s) // canonicalizes s
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	var payloads [][]byte
+	for _, value := range framedTestValues {
+		data, err := fixture.Convert(s, "Record", []byte(value))
+		if err != nil {
+			t.Fatalf("fixture encode failed: %v", err)
+		}
+		payloads = append(payloads, data)
+	}
+	return code, payloads
+}
+
+func TestGenerateGoFramed(t *testing.T) {
+	code, _ := framedFixtures(t, GenerateGo)
+	src := string(code)
+	for _, want := range []string{
+		"binary.LittleEndian.PutUint32(buf[frame",
+		"Code: DecodeOverflow",
+		"bytes left in the frame of Point",
+		`{Name: "@frame", Size: 4, Decode: func() error {`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code lacks %q", want)
+		}
+	}
+
+	s, err := parser.ParseBytes([]byte(framedTestSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &PackageConfig{Schema: s, Language: "swift", OutputDir: t.TempDir(), NoCompile: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "@framed is not supported for swift") {
+		t.Errorf("GeneratePackage(swift) = %v, want unsupported error", err)
+	}
+}
+
+// TestGoFramedRoundtrip decodes reference payloads with generated Go, with
+// Decode and the lenient decoder, checks that they re-encode to the same
+// bytes, and that frames that do not match their fields are rejected.
+func TestGoFramedRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+	code, payloads := framedFixtures(t, GenerateGo)
+
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "frames"), 0755)
+	files := map[string]string{
+		"go.mod":           "module framed\n\ngo 1.21\n",
+		"frames/frames.go": string(code),
+		"main.go": `package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"framed/frames"
+)
+
+func main() {
+	for i := 0; ; i++ {
+		data, err := os.ReadFile(fmt.Sprint(i, ".bin"))
+		if err != nil {
+			return
+		}
+		var m frames.RecordMessage
+		if err := m.Decode(data); err != nil {
+			panic(err)
+		}
+		if m.EncodedSize() != len(data) {
+			panic(fmt.Sprint("EncodedSize ", m.EncodedSize(), ", encoded ", len(data)))
+		}
+		os.WriteFile(fmt.Sprint(i, ".out"), m.Encode(), 0644)
+
+		lenient, errs := frames.DecodeRecordMessageLenient(data)
+		if len(errs) != 0 {
+			panic(fmt.Sprint(errs))
+		}
+		os.WriteFile(fmt.Sprint(i, ".lenient"), lenient.Encode(), 0644)
+
+		// The root frame holds the rest of the message
+		if n := binary.LittleEndian.Uint32(data); int(n) != len(data)-4 {
+			panic(fmt.Sprint("root frame ", n, ", message ", len(data)))
+		}
+
+		var derr *frames.DecodeError
+		long := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(long, uint32(len(data)-3))
+		if err := m.Decode(long); !errors.As(err, &derr) || derr.Code != frames.DecodeOverflow {
+			panic(fmt.Sprint("long frame: ", err))
+		}
+		padded := append(long, 0)
+		if err := m.Decode(padded); !errors.As(err, &derr) || derr.Code != frames.DecodeTrailingData {
+			panic(fmt.Sprint("padded frame: ", err))
+		}
+	}
+}
+`,
+	}
+	for i, data := range payloads {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	checkBitmapOutputs(t, tmpDir, payloads, ".out", ".lenient")
+}
+
+// TestCppFramedRoundtrip decodes reference payloads with generated C++ and
+// checks that they re-encode to the same bytes, and that a frame longer
+// than its fields is rejected.
+func TestCppFramedRoundtrip(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	code, payloads := framedFixtures(t, GenerateCpp)
+
+	dir := t.TempDir()
+	src := `#include "frames.hpp"
+#include <fstream>
+#include <iterator>
+#include <string>
+
+int main() {
+    for (int i = 0; ; i++) {
+        std::ifstream in(std::to_string(i) + ".bin", std::ios::binary);
+        if (!in) return 0;
+        std::vector<uint8_t> data((std::istreambuf_iterator<char>(in)), std::istreambuf_iterator<char>());
+        auto out = frames::encode_record_message(frames::decode_record_message(data));
+        std::ofstream(std::to_string(i) + ".out", std::ios::binary).write(reinterpret_cast<const char*>(out.data()), out.size());
+
+        // A byte inside the root frame that no field reads
+        data[0]++;
+        data.push_back(0);
+        try {
+            frames::decode_record_message(data);
+            return 1;
+        } catch (const frames::DecodeError& e) {
+            if (e.code != frames::DecodeErrorCode::TrailingData) return 2;
+        }
+    }
+}
+`
+	files := map[string]string{"frames.hpp": string(code), "main.cpp": src}
+	for i, data := range payloads {
+		files[fmt.Sprintf("%d.bin", i)] = string(data)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bin := filepath.Join(dir, "roundtrip")
+	if out, err := exec.Command("g++", "-std=c++17", "-O2", "-o", bin, filepath.Join(dir, "main.cpp")).CombinedOutput(); err != nil {
+		t.Fatalf("g++ failed: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("roundtrip failed: %v\n%s", err, out)
+	}
+	checkBitmapOutputs(t, dir, payloads, ".out")
+}
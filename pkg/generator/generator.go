@@ -1,12 +1,61 @@
 // Package generator generates encoder/decoder code for various languages.
+//
+// It is the library behind ffire generate. GeneratePackage writes a
+// complete package for one language, configured by a PackageConfig; Generate
+// writes the source of a single-file codec to an io.Writer. Neither prints
+// to the terminal nor exits: progress goes to PackageConfig.Logger, and
+// failures are returned as errors.
+//
+// Schemas come from the parser package, for example:
+//
+//	s, err := parser.ParseReader(f, parser.Options{Validate: true})
+//	if err != nil {
+//		return err
+//	}
+//	return generator.GeneratePackage(&generator.PackageConfig{
+//		Schema:    s,
+//		Language:  "go",
+//		OutputDir: "gen/audio",
+//	})
 package generator
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 
 	"github.com/shaban/ffire/pkg/schema"
 )
 
+// sourceGenerators are the languages whose codec is a single source file.
+var sourceGenerators = map[string]func(*schema.Schema) ([]byte, error){
+	"go":     GenerateGo,
+	"cpp":    GenerateCpp,
+	"csharp": GenerateCSharp,
+	"java":   GenerateJava,
+}
+
+// Generate writes the single-file codec for lang (go, cpp, csharp or java)
+// to w. Other languages need a package; use GeneratePackage.
+func Generate(w io.Writer, s *schema.Schema, lang string) error {
+	generate, ok := sourceGenerators[canonicalLanguage(lang)]
+	if !ok {
+		langs := make([]string, 0, len(sourceGenerators))
+		for l := range sourceGenerators {
+			langs = append(langs, l)
+		}
+		sort.Strings(langs)
+		return fmt.Errorf("no single-file generator for %s (supported: %s); use GeneratePackage", lang, strings.Join(langs, ", "))
+	}
+	code, err := generate(s)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(code)
+	return err
+}
+
 // GenerateSwift generates Swift encoder/decoder code.
 // Currently unimplemented - use package system instead.
 func GenerateSwift(s *schema.Schema) ([]byte, error) {
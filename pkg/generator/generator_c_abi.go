@@ -68,6 +68,12 @@ func GenerateCABIHeader(s *schema.Schema) ([]byte, error) {
 	buf.WriteString("#include <stddef.h>\n")
 	buf.WriteString("#include <stdint.h>\n\n")
 
+	buf.WriteString("// Decode failure codes, the same in every ffire language\n")
+	buf.WriteString(cABIDecodeErrorDefines(s.Package))
+	buf.WriteString("\n// Code of the last failed decode on the calling thread, or 0 if it\n")
+	buf.WriteString("// succeeded or failed for another reason (e.g. out of memory)\n")
+	fmt.Fprintf(buf, "int %s_decode_error_code(void);\n\n", s.Package)
+
 	buf.WriteString("// Opaque handle types\n")
 
 	// Generate handle type for each message type
@@ -174,8 +180,14 @@ func GenerateCABIImpl(s *schema.Schema) ([]byte, error) {
 	buf.WriteString("    return error;\n")
 	buf.WriteString("}\n\n")
 
+	buf.WriteString("static thread_local int last_decode_error = 0;\n\n")
+
 	buf.WriteString("extern \"C\" {\n\n")
 
+	fmt.Fprintf(buf, "int %s_decode_error_code(void) {\n", s.Package)
+	buf.WriteString("    return last_decode_error;\n")
+	buf.WriteString("}\n\n")
+
 	// Generate functions for each message
 	for _, msg := range s.Messages {
 		generateDecodeFunction(buf, s, &msg)
@@ -199,7 +211,9 @@ func generateDecodeFunction(buf *bytes.Buffer, s *schema.Schema, msg *schema.Mes
 	cppFuncName := fmt.Sprintf("decode_%s_message", strings.ToLower(typeName))
 
 	fmt.Fprintf(buf, "%s %s(const uint8_t* data, size_t len, char** error_msg) {\n", handleName, funcName)
+	buf.WriteString("    last_decode_error = 0;\n")
 	buf.WriteString("    if (!data || len == 0) {\n")
+	fmt.Fprintf(buf, "        last_decode_error = %s_DECODE_TRUNCATED;\n", strings.ToUpper(s.Package))
 	buf.WriteString("        if (error_msg) *error_msg = make_error_msg(\"Invalid input data\");\n")
 	buf.WriteString("        return nullptr;\n")
 	buf.WriteString("    }\n")
@@ -223,6 +237,10 @@ func generateDecodeFunction(buf *bytes.Buffer, s *schema.Schema, msg *schema.Mes
 	}
 
 	buf.WriteString("        return static_cast<" + handleName + ">(handle);\n")
+	fmt.Fprintf(buf, "    } catch (const %s::DecodeError& e) {\n", s.Package)
+	buf.WriteString("        last_decode_error = static_cast<int>(e.code);\n")
+	buf.WriteString("        if (error_msg) *error_msg = make_error_msg(e.what());\n")
+	buf.WriteString("        return nullptr;\n")
 	buf.WriteString("    } catch (const std::exception& e) {\n")
 	buf.WriteString("        if (error_msg) *error_msg = make_error_msg(e.what());\n")
 	buf.WriteString("        return nullptr;\n")
@@ -341,14 +359,20 @@ func cppTypeForType(packageName string, typ schema.Type) string {
 			return "double"
 		case "string":
 			return "std::string"
+		case "bytes":
+			return "std::vector<uint8_t>"
 		default:
 			return "void"
 		}
-	case *schema.StructType:
-		return fmt.Sprintf("%s::%s", packageName, t.Name)
+	case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		return fmt.Sprintf("%s::%s", packageName, t.TypeName())
 	case *schema.ArrayType:
 		elemType := cppTypeForType(packageName, t.ElementType)
 		return fmt.Sprintf("std::vector<%s>", elemType)
+	case *schema.MapType:
+		keyType := cppTypeForType(packageName, t.KeyType)
+		valueType := cppTypeForType(packageName, t.ValueType)
+		return fmt.Sprintf("std::map<%s, %s>", keyType, valueType)
 	default:
 		return "void"
 	}
@@ -3,20 +3,60 @@ package generator
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/shaban/ffire/pkg/schema"
 )
 
 func GenerateCpp(s *schema.Schema) ([]byte, error) {
-	gen := &cppGenerator{schema: s, buf: &bytes.Buffer{}}
+	return GenerateCppWithOptions(s, CppOptions{})
+}
+
+// CppOptions select optional code paths in the generated C++ header.
+type CppOptions struct {
+	// SIMD runs the decoder's UTF-8 and bool checks with SSE2 or NEON
+	// when the target has them, and decodes bool arrays in bulk (see
+	// simd.go).
+	SIMD bool
+
+	// Embedded generates the profile for microcontrollers instead: no
+	// heap, exceptions or RTTI, with storage sized by @max limits (see
+	// embedded.go).
+	Embedded bool
+}
+
+// GenerateCppWithOptions is GenerateCpp with optional code paths.
+func GenerateCppWithOptions(s *schema.Schema, opts CppOptions) ([]byte, error) {
+	// Canonicalize field order for optimal wire format
+	s.Canonicalize()
+
+	if opts.Embedded {
+		if opts.SIMD {
+			return nil, fmt.Errorf("the embedded C++ profile has no SIMD paths")
+		}
+		return generateCppEmbedded(s)
+	}
+	gen := &cppGenerator{schema: s, buf: &bytes.Buffer{}, opts: opts}
 	return gen.generate()
 }
 
 type cppGenerator struct {
 	schema *schema.Schema
 	buf    *bytes.Buffer
+	opts   CppOptions
 	depth  int // Track nesting depth for unique variable names
+
+	// The next optional value is a field of a @bitmap struct: bitmapped
+	// drops its presence byte when encoding, and presentBit is the bitmap
+	// test that decoding reads instead of the byte. absent records, for
+	// each open present branch, whether its else branch writes the absent
+	// byte.
+	bitmapped  bool
+	presentBit string
+	absent     []bool
+	bitmaps    int // Counter for unique bitmap variable names
+	frames     int // Counter for unique frame variable names
 }
 
 func (g *cppGenerator) generate() ([]byte, error) {
@@ -29,15 +69,32 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	fmt.Fprintf(g.buf, "#define %s\n\n", guardName)
 
 	// Includes
+	if g.schema.HasFixedArrays() {
+		g.buf.WriteString("#include <array>\n")
+	}
 	g.buf.WriteString("#include <cstdint>\n")
 	g.buf.WriteString("#include <cstring>\n")
+	if g.schema.HasMaps() {
+		g.buf.WriteString("#include <map>\n")
+	}
 	g.buf.WriteString("#include <string>\n")
 	g.buf.WriteString("#include <vector>\n")
 	g.buf.WriteString("#include <optional>\n")
+	if len(g.schema.Unions()) > 0 {
+		g.buf.WriteString("#include <variant>\n")
+	}
 	g.buf.WriteString("#include <stdexcept>\n\n")
+	if g.opts.SIMD {
+		g.buf.WriteString(cppSIMDIncludes)
+	}
 
 	// Namespace
 	fmt.Fprintf(g.buf, "namespace %s {\n\n", g.schema.Package)
+	g.buf.WriteString(cppSIMDHelpers)
+
+	for _, enum := range g.schema.Enums() {
+		g.generateEnum(enum)
+	}
 
 	// Forward declarations for all structs (needed for mutual references)
 	// Include Message suffix for root message types
@@ -63,53 +120,21 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	}
 	g.buf.WriteString("\n")
 
-	// Build unified list of all structs (both root messages and embedded types)
-	// We need to sort them together because root messages can depend on embedded types
-	type structInfo struct {
-		structType *schema.StructType
-		isRoot     bool
-	}
-
-	allStructs := make([]structInfo, 0)
-
-	// Add root message structs
-	for _, msg := range g.schema.Messages {
-		if structType, ok := msg.TargetType.(*schema.StructType); ok {
-			allStructs = append(allStructs, structInfo{
-				structType: structType,
-				isRoot:     true,
-			})
-		}
-	}
-
-	// Add embedded/helper structs
-	for _, typ := range g.schema.Types {
-		if structType, ok := typ.(*schema.StructType); ok {
-			// Skip if this is a root message type (already added above)
-			isRootType := false
-			for _, msg := range g.schema.Messages {
-				if st, ok := msg.TargetType.(*schema.StructType); ok && st.Name == structType.Name {
-					isRootType = true
-					break
-				}
-			}
-			if !isRootType {
-				allStructs = append(allStructs, structInfo{
-					structType: structType,
-					isRoot:     false,
-				})
-			}
+	// Unions are variants in tag order; std::variant only needs complete
+	// types where it is used, which the struct sort below guarantees
+	for _, union := range g.schema.Unions() {
+		variants := make([]string, len(union.Variants))
+		for i, v := range union.Variants {
+			variants[i] = g.cppTypeString(v)
 		}
+		g.buf.WriteString(lineDoc("", "///", union.Doc))
+		fmt.Fprintf(g.buf, "// %s holds one of its variants; index() + 1 is the wire tag.\n", union.Name)
+		fmt.Fprintf(g.buf, "using %s = std::variant<%s>;\n\n", union.Name, strings.Join(variants, ", "))
 	}
 
-	// Extract just the struct types for topological sort
-	structTypes := make([]*schema.StructType, len(allStructs))
-	for i, info := range allStructs {
-		structTypes[i] = info.structType
-	}
-
-	// Sort by dependencies
-	sortedStructTypes := g.topologicalSort(structTypes)
+	// Root messages and embedded types are sorted together, because root
+	// messages can depend on embedded types
+	sortedStructTypes := sortStructsByDependency(schemaStructs(g.schema))
 
 	// Generate in dependency order, applying Message suffix to root types
 	for _, structType := range sortedStructTypes {
@@ -183,6 +208,30 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("        buffer.insert(buffer.end(), s.begin(), s.end());\n")
 	g.buf.WriteString("    }\n\n")
 
+	g.buf.WriteString("    void write_bytes(const std::vector<uint8_t>& b) {\n")
+	g.buf.WriteString("        uint16_t len = static_cast<uint16_t>(b.size());\n")
+	g.buf.WriteString("        buffer.push_back(static_cast<uint8_t>(len));\n")
+	g.buf.WriteString("        buffer.push_back(static_cast<uint8_t>(len >> 8));\n")
+	g.buf.WriteString("        buffer.insert(buffer.end(), b.begin(), b.end());\n")
+	g.buf.WriteString("    }\n\n")
+
+	// @large values have a uint32 length prefix
+	if g.schema.HasLarge() {
+		g.buf.WriteString("    void write_large_length(size_t n) {\n")
+		g.buf.WriteString("        write_int32(static_cast<int32_t>(static_cast<uint32_t>(n)));\n")
+		g.buf.WriteString("    }\n\n")
+
+		g.buf.WriteString("    void write_large_string(const std::string& s) {\n")
+		g.buf.WriteString("        write_large_length(s.size());\n")
+		g.buf.WriteString("        buffer.insert(buffer.end(), s.begin(), s.end());\n")
+		g.buf.WriteString("    }\n\n")
+
+		g.buf.WriteString("    void write_large_bytes(const std::vector<uint8_t>& b) {\n")
+		g.buf.WriteString("        write_large_length(b.size());\n")
+		g.buf.WriteString("        buffer.insert(buffer.end(), b.begin(), b.end());\n")
+		g.buf.WriteString("    }\n\n")
+	}
+
 	// Bulk write methods for zero-copy encoding of primitive arrays
 	g.buf.WriteString("    // Bulk write methods for array optimization\n")
 	g.buf.WriteString("    void write_bulk_int8(const std::vector<int8_t>& arr) {\n")
@@ -220,8 +269,30 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("        const uint8_t* ptr = reinterpret_cast<const uint8_t*>(arr.data());\n")
 	g.buf.WriteString("        buffer.insert(buffer.end(), ptr, ptr + arr.size() * 8);\n")
 	g.buf.WriteString("    }\n")
+	if g.schema.HasFixedArrays() {
+		// Fixed-size arrays of numbers are written as their memory image
+		g.buf.WriteString("\n    void write_raw(const void* src, size_t bytes) {\n")
+		g.buf.WriteString("        const uint8_t* ptr = static_cast<const uint8_t*>(src);\n")
+		g.buf.WriteString("        buffer.insert(buffer.end(), ptr, ptr + bytes);\n")
+		g.buf.WriteString("    }\n")
+	}
+	if g.schema.HasFramed() {
+		// The frame of a @framed struct is reserved before its fields and
+		// filled in after them
+		g.buf.WriteString("\n    size_t begin_frame() {\n")
+		g.buf.WriteString("        size_t start = buffer.size();\n")
+		g.buf.WriteString("        write_int32(0);\n")
+		g.buf.WriteString("        return start;\n")
+		g.buf.WriteString("    }\n\n")
+		g.buf.WriteString("    void end_frame(size_t start) {\n")
+		g.buf.WriteString("        uint32_t n = static_cast<uint32_t>(buffer.size() - start - 4);\n")
+		g.buf.WriteString("        for (int i = 0; i < 4; i++) buffer[start + i] = static_cast<uint8_t>(n >> (8 * i));\n")
+		g.buf.WriteString("    }\n")
+	}
 	g.buf.WriteString("};\n\n")
 
+	g.buf.WriteString(cppDecodeErrorRuntime())
+
 	// Generate decoder class
 	g.buf.WriteString("// Binary decoder for wire format\n")
 	g.buf.WriteString("class Decoder {\n")
@@ -234,12 +305,31 @@ func (g *cppGenerator) generate() ([]byte, error) {
 
 	g.buf.WriteString("    void check_remaining(size_t needed) {\n")
 	g.buf.WriteString("        if (pos + needed > size) {\n")
-	g.buf.WriteString("            throw std::runtime_error(\"insufficient data for decode\");\n")
+	g.buf.WriteString("            throw DecodeError(DecodeErrorCode::Truncated, \"insufficient data for decode\");\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+
+	// Lengths and counts are checked against the data left before
+	// anything is read or allocated for them
+	g.buf.WriteString("    void check_count(size_t needed) {\n")
+	g.buf.WriteString("        if (pos + needed > size) {\n")
+	g.buf.WriteString("            throw DecodeError(DecodeErrorCode::Overflow, \"length exceeds remaining data\");\n")
 	g.buf.WriteString("        }\n")
 	g.buf.WriteString("    }\n\n")
 
 	g.buf.WriteString("    bool read_bool() {\n")
 	g.buf.WriteString("        check_remaining(1);\n")
+	g.buf.WriteString("        if (data[pos] > 0x01) {\n")
+	g.buf.WriteString("            throw DecodeError(DecodeErrorCode::InvalidValue, \"invalid bool\");\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("        return data[pos++] != 0x00;\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    bool read_presence() {\n")
+	g.buf.WriteString("        check_remaining(1);\n")
+	g.buf.WriteString("        if (data[pos] > 0x01) {\n")
+	g.buf.WriteString("            throw DecodeError(DecodeErrorCode::BadPresence, \"invalid presence flag\");\n")
+	g.buf.WriteString("        }\n")
 	g.buf.WriteString("        return data[pos++] != 0x00;\n")
 	g.buf.WriteString("    }\n\n")
 
@@ -299,25 +389,84 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("        uint16_t len = static_cast<uint16_t>(data[pos]) |\n")
 	g.buf.WriteString("                       (static_cast<uint16_t>(data[pos + 1]) << 8);\n")
 	g.buf.WriteString("        pos += 2;\n")
-	g.buf.WriteString("        check_remaining(len);\n")
+	g.buf.WriteString("        check_count(len);\n")
+	g.buf.WriteString("        if (!simd::valid_utf8(data + pos, len)) {\n")
+	g.buf.WriteString("            throw DecodeError(DecodeErrorCode::InvalidUtf8, \"invalid UTF-8 in string\");\n")
+	g.buf.WriteString("        }\n")
 	g.buf.WriteString("        std::string s(reinterpret_cast<const char*>(data + pos), len);\n")
 	g.buf.WriteString("        pos += len;\n")
 	g.buf.WriteString("        return s;\n")
 	g.buf.WriteString("    }\n\n")
 
+	// Blobs are not UTF-8 checked
+	g.buf.WriteString("    std::vector<uint8_t> read_bytes() {\n")
+	g.buf.WriteString("        check_remaining(2);\n")
+	g.buf.WriteString("        uint16_t len = static_cast<uint16_t>(data[pos]) |\n")
+	g.buf.WriteString("                       (static_cast<uint16_t>(data[pos + 1]) << 8);\n")
+	g.buf.WriteString("        pos += 2;\n")
+	g.buf.WriteString("        check_count(len);\n")
+	g.buf.WriteString("        std::vector<uint8_t> b(data + pos, data + pos + len);\n")
+	g.buf.WriteString("        pos += len;\n")
+	g.buf.WriteString("        return b;\n")
+	g.buf.WriteString("    }\n\n")
+
 	g.buf.WriteString("    uint16_t read_array_length() {\n")
 	g.buf.WriteString("        check_remaining(2);\n")
 	g.buf.WriteString("        uint16_t len = static_cast<uint16_t>(data[pos]) |\n")
 	g.buf.WriteString("                       (static_cast<uint16_t>(data[pos + 1]) << 8);\n")
 	g.buf.WriteString("        pos += 2;\n")
+	g.buf.WriteString("        // Every element takes at least a byte\n")
+	g.buf.WriteString("        check_count(len);\n")
 	g.buf.WriteString("        return len;\n")
 	g.buf.WriteString("    }\n\n")
 
+	if g.schema.HasLarge() {
+		g.buf.WriteString("    uint32_t read_large_length() {\n")
+		g.buf.WriteString("        return static_cast<uint32_t>(read_int32());\n")
+		g.buf.WriteString("    }\n\n")
+
+		// Every element takes at least a byte, so a count past the end of
+		// the data is rejected before anything is allocated for it
+		g.buf.WriteString("    uint32_t read_large_count() {\n")
+		g.buf.WriteString("        uint32_t len = read_large_length();\n")
+		g.buf.WriteString("        check_count(len);\n")
+		g.buf.WriteString("        return len;\n")
+		g.buf.WriteString("    }\n\n")
+
+		g.buf.WriteString("    std::string read_large_string() {\n")
+		g.buf.WriteString("        uint32_t len = read_large_count();\n")
+		g.buf.WriteString("        if (!simd::valid_utf8(data + pos, len)) {\n")
+		g.buf.WriteString("            throw DecodeError(DecodeErrorCode::InvalidUtf8, \"invalid UTF-8 in string\");\n")
+		g.buf.WriteString("        }\n")
+		g.buf.WriteString("        std::string s(reinterpret_cast<const char*>(data + pos), len);\n")
+		g.buf.WriteString("        pos += len;\n")
+		g.buf.WriteString("        return s;\n")
+		g.buf.WriteString("    }\n\n")
+
+		g.buf.WriteString("    std::vector<uint8_t> read_large_bytes() {\n")
+		g.buf.WriteString("        uint32_t len = read_large_count();\n")
+		g.buf.WriteString("        std::vector<uint8_t> b(data + pos, data + pos + len);\n")
+		g.buf.WriteString("        pos += len;\n")
+		g.buf.WriteString("        return b;\n")
+		g.buf.WriteString("    }\n\n")
+	}
+
 	// Bulk read methods for zero-copy decoding of primitive arrays
 	g.buf.WriteString("    // Bulk read methods for array optimization\n")
+	if g.opts.SIMD {
+		g.buf.WriteString("    void read_bulk_bool(std::vector<bool>& arr, size_t count) {\n")
+		g.buf.WriteString("        if (count == 0) return;\n")
+		g.buf.WriteString("        check_count(count);\n")
+		g.buf.WriteString("        if (!simd::valid_bools(data + pos, count)) {\n")
+		g.buf.WriteString("            throw DecodeError(DecodeErrorCode::InvalidValue, \"invalid bool\");\n")
+		g.buf.WriteString("        }\n")
+		g.buf.WriteString("        arr.assign(data + pos, data + pos + count);\n")
+		g.buf.WriteString("        pos += count;\n")
+		g.buf.WriteString("    }\n\n")
+	}
 	g.buf.WriteString("    void read_bulk_int8(std::vector<int8_t>& arr, size_t count) {\n")
 	g.buf.WriteString("        if (count == 0) return;\n")
-	g.buf.WriteString("        check_remaining(count);\n")
+	g.buf.WriteString("        check_count(count);\n")
 	g.buf.WriteString("        arr.resize(count);\n")
 	g.buf.WriteString("        std::memcpy(arr.data(), data + pos, count);\n")
 	g.buf.WriteString("        pos += count;\n")
@@ -326,7 +475,7 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("    void read_bulk_int16(std::vector<int16_t>& arr, size_t count) {\n")
 	g.buf.WriteString("        if (count == 0) return;\n")
 	g.buf.WriteString("        size_t bytes = count * 2;\n")
-	g.buf.WriteString("        check_remaining(bytes);\n")
+	g.buf.WriteString("        check_count(bytes);\n")
 	g.buf.WriteString("        arr.resize(count);\n")
 	g.buf.WriteString("        std::memcpy(arr.data(), data + pos, bytes);\n")
 	g.buf.WriteString("        pos += bytes;\n")
@@ -335,7 +484,7 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("    void read_bulk_int32(std::vector<int32_t>& arr, size_t count) {\n")
 	g.buf.WriteString("        if (count == 0) return;\n")
 	g.buf.WriteString("        size_t bytes = count * 4;\n")
-	g.buf.WriteString("        check_remaining(bytes);\n")
+	g.buf.WriteString("        check_count(bytes);\n")
 	g.buf.WriteString("        arr.resize(count);\n")
 	g.buf.WriteString("        std::memcpy(arr.data(), data + pos, bytes);\n")
 	g.buf.WriteString("        pos += bytes;\n")
@@ -344,7 +493,7 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("    void read_bulk_int64(std::vector<int64_t>& arr, size_t count) {\n")
 	g.buf.WriteString("        if (count == 0) return;\n")
 	g.buf.WriteString("        size_t bytes = count * 8;\n")
-	g.buf.WriteString("        check_remaining(bytes);\n")
+	g.buf.WriteString("        check_count(bytes);\n")
 	g.buf.WriteString("        arr.resize(count);\n")
 	g.buf.WriteString("        std::memcpy(arr.data(), data + pos, bytes);\n")
 	g.buf.WriteString("        pos += bytes;\n")
@@ -353,7 +502,7 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("    void read_bulk_float32(std::vector<float>& arr, size_t count) {\n")
 	g.buf.WriteString("        if (count == 0) return;\n")
 	g.buf.WriteString("        size_t bytes = count * 4;\n")
-	g.buf.WriteString("        check_remaining(bytes);\n")
+	g.buf.WriteString("        check_count(bytes);\n")
 	g.buf.WriteString("        arr.resize(count);\n")
 	g.buf.WriteString("        std::memcpy(arr.data(), data + pos, bytes);\n")
 	g.buf.WriteString("        pos += bytes;\n")
@@ -362,11 +511,35 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	g.buf.WriteString("    void read_bulk_float64(std::vector<double>& arr, size_t count) {\n")
 	g.buf.WriteString("        if (count == 0) return;\n")
 	g.buf.WriteString("        size_t bytes = count * 8;\n")
-	g.buf.WriteString("        check_remaining(bytes);\n")
+	g.buf.WriteString("        check_count(bytes);\n")
 	g.buf.WriteString("        arr.resize(count);\n")
 	g.buf.WriteString("        std::memcpy(arr.data(), data + pos, bytes);\n")
 	g.buf.WriteString("        pos += bytes;\n")
 	g.buf.WriteString("    }\n")
+	if g.schema.HasFixedArrays() {
+		g.buf.WriteString("\n    void read_raw(void* dst, size_t bytes) {\n")
+		g.buf.WriteString("        check_remaining(bytes);\n")
+		g.buf.WriteString("        std::memcpy(dst, data + pos, bytes);\n")
+		g.buf.WriteString("        pos += bytes;\n")
+		g.buf.WriteString("    }\n")
+	}
+	if g.schema.HasFramed() {
+		// The fields of a @framed struct decode with size lowered to the
+		// end of its frame, so they cannot read past it
+		g.buf.WriteString("\n    size_t begin_frame() {\n")
+		g.buf.WriteString("        uint32_t n = static_cast<uint32_t>(read_int32());\n")
+		g.buf.WriteString("        check_count(n);\n")
+		g.buf.WriteString("        size_t outer = size;\n")
+		g.buf.WriteString("        size = pos + n;\n")
+		g.buf.WriteString("        return outer;\n")
+		g.buf.WriteString("    }\n\n")
+		g.buf.WriteString("    void end_frame(size_t outer) {\n")
+		g.buf.WriteString("        if (pos != size) {\n")
+		g.buf.WriteString("            throw DecodeError(DecodeErrorCode::TrailingData, std::to_string(size - pos) + \" bytes left in struct frame\");\n")
+		g.buf.WriteString("        }\n")
+		g.buf.WriteString("        size = outer;\n")
+		g.buf.WriteString("    }\n")
+	}
 	g.buf.WriteString("};\n\n")
 
 	// Generate message encode/decode functions
@@ -391,10 +564,48 @@ func (g *cppGenerator) generate() ([]byte, error) {
 	return g.buf.Bytes(), nil
 }
 
+// generateEnum declares a scoped enum over the base integer type, with
+// is_valid for decode checks and to_string for the enumerator name.
+func (g *cppGenerator) generateEnum(enum *schema.EnumType) {
+	g.buf.WriteString(lineDoc("", "///", enum.Doc))
+	fmt.Fprintf(g.buf, "enum class %s : %s {\n", enum.Name, g.cppPrimitiveType(enum.Base))
+	for _, v := range enum.Values {
+		g.buf.WriteString(lineDoc("    ", "///", v.Doc))
+		if v.Value == math.MinInt64 {
+			// The literal 9223372036854775808 does not fit int64_t
+			fmt.Fprintf(g.buf, "    %s = INT64_MIN,\n", v.Name)
+		} else {
+			fmt.Fprintf(g.buf, "    %s = %d,\n", v.Name, v.Value)
+		}
+	}
+	g.buf.WriteString("};\n\n")
+
+	fmt.Fprintf(g.buf, "inline bool is_valid(%s v) {\n", enum.Name)
+	g.buf.WriteString("    switch (v) {\n")
+	for _, v := range enum.Values {
+		fmt.Fprintf(g.buf, "    case %s::%s:\n", enum.Name, v.Name)
+	}
+	g.buf.WriteString("        return true;\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("    return false;\n")
+	g.buf.WriteString("}\n\n")
+
+	fmt.Fprintf(g.buf, "inline const char* to_string(%s v) {\n", enum.Name)
+	g.buf.WriteString("    switch (v) {\n")
+	for _, v := range enum.Values {
+		fmt.Fprintf(g.buf, "    case %s::%s: return \"%s\";\n", enum.Name, v.Name, v.Name)
+	}
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("    return \"\";\n")
+	g.buf.WriteString("}\n\n")
+}
+
 func (g *cppGenerator) generateMessageStruct(structType *schema.StructType) {
 	// Generate root message struct with Message suffix to avoid keyword collisions
+	g.buf.WriteString(lineDoc("", "///", structType.Doc))
 	fmt.Fprintf(g.buf, "struct %sMessage {\n", structType.Name)
 	for _, field := range structType.Fields {
+		g.buf.WriteString(lineDoc("    ", "///", field.Doc))
 		typeStr := g.cppTypeString(field.Type)
 		fmt.Fprintf(g.buf, "    %s %s;\n", typeStr, field.Name)
 	}
@@ -403,8 +614,10 @@ func (g *cppGenerator) generateMessageStruct(structType *schema.StructType) {
 
 func (g *cppGenerator) generateStruct(structType *schema.StructType) {
 	// Generate helper/embedded struct (no Message suffix)
+	g.buf.WriteString(lineDoc("", "///", structType.Doc))
 	fmt.Fprintf(g.buf, "struct %s {\n", structType.Name)
 	for _, field := range structType.Fields {
+		g.buf.WriteString(lineDoc("    ", "///", field.Doc))
 		typeStr := g.cppTypeString(field.Type)
 		fmt.Fprintf(g.buf, "    %s %s;\n", typeStr, field.Name)
 	}
@@ -426,14 +639,40 @@ func (g *cppGenerator) cppTypeString(typ schema.Type) string {
 		}
 		return t.Name
 
+	case *schema.EnumType:
+		// Qualified, since fields are often named after their enum (Mode Mode)
+		name := g.schema.Package + "::" + t.Name
+		if t.Optional {
+			return "std::optional<" + name + ">"
+		}
+		return name
+
 	case *schema.ArrayType:
 		elemType := g.cppTypeString(t.ElementType)
+		if t.Length > 0 {
+			return fmt.Sprintf("std::array<%s, %d>", elemType, t.Length)
+		}
 		vectorType := "std::vector<" + elemType + ">"
 		if t.Optional {
 			return "std::optional<" + vectorType + ">"
 		}
 		return vectorType
 
+	case *schema.UnionType:
+		name := g.schema.Package + "::" + t.Name
+		if t.Optional {
+			return "std::optional<" + name + ">"
+		}
+		return name
+
+	case *schema.MapType:
+		// std::map iterates in ascending key order, which is the wire order
+		mapType := "std::map<" + g.cppTypeString(t.KeyType) + ", " + g.cppTypeString(t.ValueType) + ">"
+		if t.Optional {
+			return "std::optional<" + mapType + ">"
+		}
+		return mapType
+
 	default:
 		return "void*"
 	}
@@ -457,6 +696,8 @@ func (g *cppGenerator) cppPrimitiveType(name string) string {
 		return "double"
 	case "string":
 		return "std::string"
+	case "bytes":
+		return "std::vector<uint8_t>"
 	default:
 		return "void*"
 	}
@@ -504,11 +745,15 @@ func (g *cppGenerator) generateMessageDecode(msg schema.MessageType) {
 		returnType = g.cppTypeString(msg.TargetType)
 	}
 
-	fmt.Fprintf(g.buf, "// Decode %s from binary wire format\n", msg.Name)
+	fmt.Fprintf(g.buf, "// Decode %s from binary wire format. The result owns its strings and\n", msg.Name)
+	g.buf.WriteString("// vectors: nothing in it points into data.\n")
 	fmt.Fprintf(g.buf, "inline %s %s(const uint8_t* data, size_t size) {\n", returnType, funcName)
 	g.buf.WriteString("    Decoder dec(data, size);\n")
 	fmt.Fprintf(g.buf, "    %s result;\n", returnType)
 	g.generateDecodeValue("dec", "result", msg.TargetType, "    ")
+	g.buf.WriteString("    if (dec.pos != dec.size) {\n")
+	g.buf.WriteString("        throw DecodeError(DecodeErrorCode::TrailingData, std::to_string(dec.size - dec.pos) + \" trailing bytes after message\");\n")
+	g.buf.WriteString("    }\n")
 	g.buf.WriteString("    return result;\n")
 	g.buf.WriteString("}\n\n")
 
@@ -535,17 +780,22 @@ func (g *cppGenerator) generateEncodeValue(encVar, valueVar string, typ schema.T
 	switch t := typ.(type) {
 	case *schema.PrimitiveType:
 		g.generateEncodePrimitive(encVar, valueVar, t, indent)
+	case *schema.EnumType:
+		g.generateEncodeEnum(encVar, valueVar, t, indent)
 	case *schema.StructType:
 		g.generateEncodeStruct(encVar, valueVar, t, indent)
 	case *schema.ArrayType:
 		g.generateEncodeArray(encVar, valueVar, t, indent)
+	case *schema.MapType:
+		g.generateEncodeMap(encVar, valueVar, t, indent)
+	case *schema.UnionType:
+		g.generateEncodeUnion(encVar, valueVar, t, indent)
 	}
 }
 
 func (g *cppGenerator) generateEncodePrimitive(encVar, valueVar string, typ *schema.PrimitiveType, indent string) {
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "%sif (%s.has_value()) {\n", indent, valueVar)
-		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x01);\n", indent, encVar)
+		g.generateWritePresence(encVar, valueVar, indent)
 		valueVar = valueVar + ".value()"
 		indent += "    "
 	}
@@ -566,24 +816,47 @@ func (g *cppGenerator) generateEncodePrimitive(encVar, valueVar string, typ *sch
 	case "float64":
 		fmt.Fprintf(g.buf, "%s%s.write_float64(%s);\n", indent, encVar, valueVar)
 	case "string":
-		fmt.Fprintf(g.buf, "%s%s.write_string(%s);\n", indent, encVar, valueVar)
+		fmt.Fprintf(g.buf, "%s%s.%s(%s);\n", indent, encVar, cppLargeName("write_string", typ), valueVar)
+	case "bytes":
+		fmt.Fprintf(g.buf, "%s%s.%s(%s);\n", indent, encVar, cppLargeName("write_bytes", typ), valueVar)
 	}
 
 	if typ.Optional {
 		indent = indent[:len(indent)-4]
-		fmt.Fprintf(g.buf, "%s} else {\n", indent)
-		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x00);\n", indent, encVar)
-		fmt.Fprintf(g.buf, "%s}\n", indent)
+		g.generateClosePresence(encVar, indent)
+	}
+}
+
+func (g *cppGenerator) generateEncodeEnum(encVar, valueVar string, typ *schema.EnumType, indent string) {
+	if typ.Optional {
+		g.generateWritePresence(encVar, valueVar, indent)
+		valueVar = valueVar + ".value()"
+		indent += "    "
+	}
+
+	fmt.Fprintf(g.buf, "%s%s.write_%s(static_cast<%s>(%s));\n", indent, encVar, typ.Base, g.cppPrimitiveType(typ.Base), valueVar)
+
+	if typ.Optional {
+		indent = indent[:len(indent)-4]
+		g.generateClosePresence(encVar, indent)
 	}
 }
 
 func (g *cppGenerator) generateEncodeStruct(encVar, valueVar string, typ *schema.StructType, indent string) {
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "%sif (%s.has_value()) {\n", indent, valueVar)
-		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x01);\n", indent, encVar)
+		g.generateWritePresence(encVar, valueVar, indent)
 		valueVar = valueVar + ".value()"
 		indent += "    "
 	}
+	frameVar := ""
+	if typ.Framed {
+		frameVar = fmt.Sprintf("frame%d", g.frames)
+		g.frames++
+		fmt.Fprintf(g.buf, "%ssize_t %s = %s.begin_frame();\n", indent, frameVar, encVar)
+	}
+	if typ.Bitmap {
+		g.generateWriteBitmap(encVar, valueVar, typ, indent)
+	}
 
 	// Check for runs of fixed-size primitive fields for bulk encoding
 	runs := schema.GetFixedFieldRuns(typ.Fields)
@@ -594,20 +867,67 @@ func (g *cppGenerator) generateEncodeStruct(encVar, valueVar string, typ *schema
 		// Encode remaining fields normally
 		for i := run.EndIndex + 1; i < len(typ.Fields); i++ {
 			fieldVar := valueVar + "." + typ.Fields[i].Name
+			g.bitmapped = typ.Bitmap && typ.Fields[i].Type.IsOptional()
 			g.generateEncodeValue(encVar, fieldVar, typ.Fields[i].Type, indent)
 		}
 	} else {
 		for _, field := range typ.Fields {
 			fieldVar := valueVar + "." + field.Name
+			g.bitmapped = typ.Bitmap && field.Type.IsOptional()
 			g.generateEncodeValue(encVar, fieldVar, field.Type, indent)
 		}
 	}
+	if typ.Framed {
+		fmt.Fprintf(g.buf, "%s%s.end_frame(%s);\n", indent, encVar, frameVar)
+	}
 
 	if typ.Optional {
 		indent = indent[:len(indent)-4]
+		g.generateClosePresence(encVar, indent)
+	}
+}
+
+// generateWritePresence opens the present branch of an optional value and
+// writes its presence byte, unless the value's flag is in a bitmap. The
+// branch is closed by generateClosePresence.
+func (g *cppGenerator) generateWritePresence(encVar, valueVar, indent string) {
+	fmt.Fprintf(g.buf, "%sif (%s.has_value()) {\n", indent, valueVar)
+	if !g.bitmapped {
+		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x01);\n", indent, encVar)
+	}
+	g.absent = append(g.absent, !g.bitmapped)
+	g.bitmapped = false
+}
+
+// generateClosePresence closes the innermost present branch, writing the
+// absent byte in the else branch if the branch wrote a presence byte.
+func (g *cppGenerator) generateClosePresence(encVar, indent string) {
+	absent := g.absent[len(g.absent)-1]
+	g.absent = g.absent[:len(g.absent)-1]
+	if absent {
 		fmt.Fprintf(g.buf, "%s} else {\n", indent)
 		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x00);\n", indent, encVar)
-		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+}
+
+// generateWriteBitmap writes the presence bitmap of a @bitmap struct, one
+// byte per eight optional fields.
+func (g *cppGenerator) generateWriteBitmap(encVar, structVar string, st *schema.StructType, indent string) {
+	var optional []string
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			optional = append(optional, field.Name)
+		}
+	}
+	for start := 0; start < len(optional); start += 8 {
+		bitsVar := fmt.Sprintf("bits%d", g.bitmaps)
+		g.bitmaps++
+		fmt.Fprintf(g.buf, "%suint8_t %s = 0;\n", indent, bitsVar)
+		for i, name := range optional[start:min(start+8, len(optional))] {
+			fmt.Fprintf(g.buf, "%sif (%s.%s.has_value()) %s |= 0x%02x;\n", indent, structVar, name, bitsVar, 1<<i)
+		}
+		fmt.Fprintf(g.buf, "%s%s.write_byte(%s);\n", indent, encVar, bitsVar)
 	}
 }
 
@@ -699,6 +1019,11 @@ func (g *cppGenerator) generateBulkArrayDecode(decVar, resultVar string, typ *sc
 
 	var bulkMethod string
 	switch primType.Name {
+	case "bool":
+		if !g.opts.SIMD {
+			return false // Element-wise; only the --cpp-simd decoder checks bools in bulk with SIMD
+		}
+		bulkMethod = "read_bulk_bool"
 	case "int8":
 		bulkMethod = "read_bulk_int8"
 	case "int16":
@@ -717,26 +1042,60 @@ func (g *cppGenerator) generateBulkArrayDecode(decVar, resultVar string, typ *sc
 
 	// Generate bulk read
 	fmt.Fprintf(g.buf, "%s{\n", indent)
-	fmt.Fprintf(g.buf, "%s    uint16_t len = %s.read_array_length();\n", indent, decVar)
+	g.generateReadLength(decVar, typ, indent+"    ")
 	fmt.Fprintf(g.buf, "%s    %s.%s(%s, len);\n", indent, decVar, bulkMethod, resultVar)
 	fmt.Fprintf(g.buf, "%s}\n", indent)
 	return true
 }
 
-func (g *cppGenerator) generateEncodeArray(encVar, valueVar string, typ *schema.ArrayType, indent string) {
-	if typ.Optional {
-		fmt.Fprintf(g.buf, "%sif (%s.has_value()) {\n", indent, valueVar)
-		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x01);\n", indent, encVar)
-		valueVar = valueVar + ".value()"
-		indent += "    "
+// cppLargeName returns the name of the encoder or decoder method for a
+// string or bytes value of typ: name, or its @large counterpart.
+func cppLargeName(name string, typ schema.Type) string {
+	if schema.IsLarge(typ) {
+		return strings.Replace(name, "_", "_large_", 1)
 	}
+	return name
+}
 
-	// Write array length
+// generateWriteLength writes the length prefix of the collection in
+// valueVar: a uint16, or a uint32 if typ is @large.
+func (g *cppGenerator) generateWriteLength(encVar, valueVar string, typ schema.Type, indent string) {
+	if schema.IsLarge(typ) {
+		fmt.Fprintf(g.buf, "%s%s.write_large_length(%s.size());\n", indent, encVar, valueVar)
+		return
+	}
 	fmt.Fprintf(g.buf, "%s{\n", indent)
 	fmt.Fprintf(g.buf, "%s    uint16_t len = static_cast<uint16_t>(%s.size());\n", indent, valueVar)
 	fmt.Fprintf(g.buf, "%s    %s.write_byte(static_cast<uint8_t>(len));\n", indent, encVar)
 	fmt.Fprintf(g.buf, "%s    %s.write_byte(static_cast<uint8_t>(len >> 8));\n", indent, encVar)
 	fmt.Fprintf(g.buf, "%s}\n", indent)
+}
+
+// generateReadLength declares len, the length prefix of a collection of
+// typ, and returns its C++ type. A @large count is checked against the
+// remaining data before the collection is allocated.
+func (g *cppGenerator) generateReadLength(decVar string, typ schema.Type, indent string) string {
+	if schema.IsLarge(typ) {
+		fmt.Fprintf(g.buf, "%suint32_t len = %s.read_large_count();\n", indent, decVar)
+		return "uint32_t"
+	}
+	fmt.Fprintf(g.buf, "%suint16_t len = %s.read_array_length();\n", indent, decVar)
+	return "uint16_t"
+}
+
+func (g *cppGenerator) generateEncodeArray(encVar, valueVar string, typ *schema.ArrayType, indent string) {
+	if typ.Length > 0 {
+		g.generateEncodeFixedArray(encVar, valueVar, typ, indent)
+		return
+	}
+	if typ.Optional {
+		g.generateWritePresence(encVar, valueVar, indent)
+		valueVar = valueVar + ".value()"
+		indent += "    "
+	}
+
+	// Write array length
+	g.generateWriteLength(encVar, valueVar, typ, indent)
 
 	// Try bulk encoding first for primitive arrays
 	if !g.generateBulkArrayEncode(encVar, valueVar, typ, indent) {
@@ -748,9 +1107,7 @@ func (g *cppGenerator) generateEncodeArray(encVar, valueVar string, typ *schema.
 
 	if typ.Optional {
 		indent = indent[:len(indent)-4]
-		fmt.Fprintf(g.buf, "%s} else {\n", indent)
-		fmt.Fprintf(g.buf, "%s    %s.write_byte(0x00);\n", indent, encVar)
-		fmt.Fprintf(g.buf, "%s}\n", indent)
+		g.generateClosePresence(encVar, indent)
 	}
 }
 
@@ -758,16 +1115,22 @@ func (g *cppGenerator) generateDecodeValue(decVar, resultVar string, typ schema.
 	switch t := typ.(type) {
 	case *schema.PrimitiveType:
 		g.generateDecodePrimitive(decVar, resultVar, t, indent)
+	case *schema.EnumType:
+		g.generateDecodeEnum(decVar, resultVar, t, indent)
 	case *schema.StructType:
 		g.generateDecodeStruct(decVar, resultVar, t, indent)
 	case *schema.ArrayType:
 		g.generateDecodeArray(decVar, resultVar, t, indent)
+	case *schema.MapType:
+		g.generateDecodeMap(decVar, resultVar, t, indent)
+	case *schema.UnionType:
+		g.generateDecodeUnion(decVar, resultVar, t, indent)
 	}
 }
 
 func (g *cppGenerator) generateDecodePrimitive(decVar, resultVar string, typ *schema.PrimitiveType, indent string) {
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "%sif (%s.read_bool()) {\n", indent, decVar)
+		g.generateReadPresence(decVar, indent)
 		indent += "    "
 	}
 
@@ -815,11 +1178,9 @@ func (g *cppGenerator) generateDecodePrimitive(decVar, resultVar string, typ *sc
 			fmt.Fprintf(g.buf, "%s%s = %s.read_float64();\n", indent, resultVar, decVar)
 		}
 	case "string":
-		if typ.Optional {
-			fmt.Fprintf(g.buf, "%s%s = %s.read_string();\n", indent, resultVar, decVar)
-		} else {
-			fmt.Fprintf(g.buf, "%s%s = %s.read_string();\n", indent, resultVar, decVar)
-		}
+		fmt.Fprintf(g.buf, "%s%s = %s.%s();\n", indent, resultVar, decVar, cppLargeName("read_string", typ))
+	case "bytes":
+		fmt.Fprintf(g.buf, "%s%s = %s.%s();\n", indent, resultVar, decVar, cppLargeName("read_bytes", typ))
 	}
 
 	if typ.Optional {
@@ -828,13 +1189,44 @@ func (g *cppGenerator) generateDecodePrimitive(decVar, resultVar string, typ *sc
 	}
 }
 
+// generateDecodeEnum reads the base integer and throws for values that are
+// not enumerators.
+func (g *cppGenerator) generateDecodeEnum(decVar, resultVar string, typ *schema.EnumType, indent string) {
+	if typ.Optional {
+		g.generateReadPresence(decVar, indent)
+		indent += "    "
+	}
+
+	fmt.Fprintf(g.buf, "%s{\n", indent)
+	fmt.Fprintf(g.buf, "%s    auto raw = %s.read_%s();\n", indent, decVar, typ.Base)
+	fmt.Fprintf(g.buf, "%s    if (!is_valid(static_cast<%s>(raw))) {\n", indent, typ.Name)
+	fmt.Fprintf(g.buf, "%s        throw DecodeError(DecodeErrorCode::InvalidValue, \"invalid %s value \" + std::to_string(raw));\n", indent, typ.Name)
+	fmt.Fprintf(g.buf, "%s    }\n", indent)
+	fmt.Fprintf(g.buf, "%s    %s = static_cast<%s>(raw);\n", indent, resultVar, typ.Name)
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+
+	if typ.Optional {
+		indent = indent[:len(indent)-4]
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
 func (g *cppGenerator) generateDecodeStruct(decVar, resultVar string, typ *schema.StructType, indent string) {
+	originalResultVar := resultVar // Save for optional assignment
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "%sif (%s.read_bool()) {\n", indent, decVar)
+		g.generateReadPresence(decVar, indent)
 		fmt.Fprintf(g.buf, "%s    %s tmp;\n", indent, typ.Name)
 		indent += "    "
 		resultVar = "tmp"
 	}
+	frameVar := ""
+	if typ.Framed {
+		frameVar = fmt.Sprintf("frame%d", g.frames)
+		g.frames++
+		fmt.Fprintf(g.buf, "%ssize_t %s = %s.begin_frame();\n", indent, frameVar, decVar)
+	}
+	// The presence bitmap of a @bitmap struct comes first
+	bits := g.generateReadBitmap(decVar, typ, indent)
 
 	// Check for runs of fixed-size primitive fields for bulk decoding
 	runs := schema.GetFixedFieldRuns(typ.Fields)
@@ -845,25 +1237,68 @@ func (g *cppGenerator) generateDecodeStruct(decVar, resultVar string, typ *schem
 		// Decode remaining fields normally
 		for i := run.EndIndex + 1; i < len(typ.Fields); i++ {
 			fieldVar := resultVar + "." + typ.Fields[i].Name
+			g.presentBit = bits[typ.Fields[i].Name]
 			g.generateDecodeValue(decVar, fieldVar, typ.Fields[i].Type, indent)
 		}
 	} else {
 		for _, field := range typ.Fields {
 			fieldVar := resultVar + "." + field.Name
+			g.presentBit = bits[field.Name]
 			g.generateDecodeValue(decVar, fieldVar, field.Type, indent)
 		}
 	}
+	if typ.Framed {
+		fmt.Fprintf(g.buf, "%s%s.end_frame(%s);\n", indent, decVar, frameVar)
+	}
 
 	if typ.Optional {
 		indent = indent[:len(indent)-4]
-		// Strip last ".value()" if added
-		baseResultVar := resultVar
-		if strings.HasSuffix(resultVar, ".value()") {
-			baseResultVar = resultVar[:len(resultVar)-8]
-		}
-		fmt.Fprintf(g.buf, "%s    %s = tmp;\n", indent, baseResultVar)
+		// Use the original result variable for assignment, not the temp variable
+		fmt.Fprintf(g.buf, "%s    %s = std::move(tmp);\n", indent, originalResultVar)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+// generateReadPresence opens the present branch of an optional value: it
+// reads the value's presence byte, or tests its bit in the bitmap of the
+// enclosing struct. The caller closes it.
+func (g *cppGenerator) generateReadPresence(decVar, indent string) {
+	if g.presentBit != "" {
+		fmt.Fprintf(g.buf, "%sif (%s) {\n", indent, g.presentBit)
+		g.presentBit = ""
+		return
+	}
+	fmt.Fprintf(g.buf, "%sif (%s.read_presence()) {\n", indent, decVar)
+}
+
+// generateReadBitmap reads the presence bitmap of a @bitmap struct, throwing
+// if unused bits are set, and returns the bit test of each optional field,
+// by field name. It returns nil for other structs.
+func (g *cppGenerator) generateReadBitmap(decVar string, st *schema.StructType, indent string) map[string]string {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return nil
+	}
+	bitsVar := fmt.Sprintf("bits%d", g.bitmaps)
+	g.bitmaps++
+	fmt.Fprintf(g.buf, "%suint8_t %s[%d];\n", indent, bitsVar, n)
+	fmt.Fprintf(g.buf, "%s%s.check_remaining(%d);\n", indent, decVar, n)
+	fmt.Fprintf(g.buf, "%sstd::memcpy(%s, &%s.data[%s.pos], %d);\n", indent, bitsVar, decVar, decVar, n)
+	fmt.Fprintf(g.buf, "%s%s.pos += %d;\n", indent, decVar, n)
+	if used := schema.OptionalFields(st) % 8; used != 0 {
+		fmt.Fprintf(g.buf, "%sif (%s[%d] & 0x%02x) {\n", indent, bitsVar, n-1, 0xff<<used&0xff)
+		fmt.Fprintf(g.buf, "%s    throw DecodeError(DecodeErrorCode::BadPresence, \"invalid presence bitmap\");\n", indent)
 		fmt.Fprintf(g.buf, "%s}\n", indent)
 	}
+	tests := make(map[string]string)
+	bit := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			tests[field.Name] = fmt.Sprintf("%s[%d] & 0x%02x", bitsVar, bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	return tests
 }
 
 // generateCppBulkStructDecode generates code to decode multiple fixed-size fields using memcpy
@@ -904,10 +1339,41 @@ func (g *cppGenerator) generateCppBulkStructDecode(decVar, structVar string, fie
 	fmt.Fprintf(g.buf, "%s%s.pos += %d;\n", indent, decVar, totalBytes)
 }
 
+// generateEncodeFixedArray writes a std::array without a length prefix.
+// Numbers go out as the array's memory image, bools one by one.
+func (g *cppGenerator) generateEncodeFixedArray(encVar, valueVar string, typ *schema.ArrayType, indent string) {
+	if prim := typ.ElementType.(*schema.PrimitiveType); prim.Name == "bool" {
+		flagVar := fmt.Sprintf("flag%d", g.depth)
+		fmt.Fprintf(g.buf, "%sfor (bool %s : %s) {\n", indent, flagVar, valueVar)
+		fmt.Fprintf(g.buf, "%s    %s.write_bool(%s);\n", indent, encVar, flagVar)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		return
+	}
+	size := typ.Length * schema.GetPrimitiveSize(typ.ElementType)
+	fmt.Fprintf(g.buf, "%s%s.write_raw(%s.data(), %d);\n", indent, encVar, valueVar, size)
+}
+
+// generateDecodeFixedArray reads a std::array in place.
+func (g *cppGenerator) generateDecodeFixedArray(decVar, resultVar string, typ *schema.ArrayType, indent string) {
+	if prim := typ.ElementType.(*schema.PrimitiveType); prim.Name == "bool" {
+		flagVar := fmt.Sprintf("flag%d", g.depth)
+		fmt.Fprintf(g.buf, "%sfor (bool& %s : %s) {\n", indent, flagVar, resultVar)
+		fmt.Fprintf(g.buf, "%s    %s = %s.read_bool();\n", indent, flagVar, decVar)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		return
+	}
+	size := typ.Length * schema.GetPrimitiveSize(typ.ElementType)
+	fmt.Fprintf(g.buf, "%s%s.read_raw(%s.data(), %d);\n", indent, decVar, resultVar, size)
+}
+
 func (g *cppGenerator) generateDecodeArray(decVar, resultVar string, typ *schema.ArrayType, indent string) {
+	if typ.Length > 0 {
+		g.generateDecodeFixedArray(decVar, resultVar, typ, indent)
+		return
+	}
 	originalResultVar := resultVar // Save for optional assignment
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "%sif (%s.read_bool()) {\n", indent, decVar)
+		g.generateReadPresence(decVar, indent)
 		indent += "    "
 		elemType := g.cppTypeString(typ.ElementType)
 		fmt.Fprintf(g.buf, "%sstd::vector<%s> tmp;\n", indent, elemType)
@@ -926,9 +1392,9 @@ func (g *cppGenerator) generateDecodeArray(decVar, resultVar string, typ *schema
 
 	// Fall back to element-by-element decoding
 	fmt.Fprintf(g.buf, "%s{\n", indent)
-	fmt.Fprintf(g.buf, "%s    uint16_t len = %s.read_array_length();\n", indent, decVar)
+	lenType := g.generateReadLength(decVar, typ, indent+"    ")
 	fmt.Fprintf(g.buf, "%s    %s.reserve(len);\n", indent, resultVar)
-	fmt.Fprintf(g.buf, "%s    for (uint16_t i = 0; i < len; ++i) {\n", indent)
+	fmt.Fprintf(g.buf, "%s    for (%s i = 0; i < len; ++i) {\n", indent, lenType)
 
 	// Use unique variable name based on depth to avoid shadowing in nested arrays
 	elemVar := fmt.Sprintf("elem%d", g.depth)
@@ -949,95 +1415,122 @@ func (g *cppGenerator) generateDecodeArray(decVar, resultVar string, typ *schema
 	}
 }
 
-// topologicalSort sorts structs so that structs with no dependencies come first.
-// This ensures that when Level1 contains Level2, Level2 is defined before Level1.
-func (g *cppGenerator) topologicalSort(structs []*schema.StructType) []*schema.StructType {
-	// Build a map of struct names to their types
-	structMap := make(map[string]*schema.StructType)
-	for _, s := range structs {
-		structMap[s.Name] = s
-	}
-
-	// Track dependencies: for each struct, which other structs does it depend on?
-	dependencies := make(map[string][]string)
-	for _, s := range structs {
-		deps := make([]string, 0)
-		for _, field := range s.Fields {
-			if depName := g.getStructDependency(field.Type, structMap); depName != "" {
-				deps = append(deps, depName)
-			}
-		}
-		dependencies[s.Name] = deps
+func (g *cppGenerator) generateEncodeMap(encVar, valueVar string, typ *schema.MapType, indent string) {
+	if typ.Optional {
+		g.generateWritePresence(encVar, valueVar, indent)
+		valueVar = valueVar + ".value()"
+		indent += "    "
 	}
 
-	// Topological sort using Kahn's algorithm
-	result := make([]*schema.StructType, 0, len(structs))
+	g.generateWriteLength(encVar, valueVar, typ, indent)
 
-	// Count dependencies for each struct (outgoing edges - how many structs does this depend on?)
-	remainingDeps := make(map[string]int)
-	for name, deps := range dependencies {
-		remainingDeps[name] = len(deps)
+	entryVar := fmt.Sprintf("entry%d", g.depth)
+	g.depth++
+	fmt.Fprintf(g.buf, "%sfor (const auto& %s : %s) {\n", indent, entryVar, valueVar)
+	g.generateEncodeValue(encVar, entryVar+".first", typ.KeyType, indent+"    ")
+	g.generateEncodeValue(encVar, entryVar+".second", typ.ValueType, indent+"    ")
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+	g.depth--
+
+	if typ.Optional {
+		indent = indent[:len(indent)-4]
+		g.generateClosePresence(encVar, indent)
 	}
+}
 
-	// Start with structs that have no dependencies
-	queue := make([]string, 0)
-	for name, count := range remainingDeps {
-		if count == 0 {
-			queue = append(queue, name)
-		}
+// generateDecodeMap accepts entries in any order; a repeated key keeps its
+// last value.
+func (g *cppGenerator) generateDecodeMap(decVar, resultVar string, typ *schema.MapType, indent string) {
+	originalResultVar := resultVar
+	mapType := "std::map<" + g.cppTypeString(typ.KeyType) + ", " + g.cppTypeString(typ.ValueType) + ">"
+	if typ.Optional {
+		g.generateReadPresence(decVar, indent)
+		indent += "    "
+		resultVar = fmt.Sprintf("map%d", g.depth)
+		fmt.Fprintf(g.buf, "%s%s %s;\n", indent, mapType, resultVar)
 	}
 
-	// Process queue
-	for len(queue) > 0 {
-		name := queue[0]
-		queue = queue[1:]
-		result = append(result, structMap[name])
-
-		// Find structs that depend on this one and reduce their dependency count
-		for depName, deps := range dependencies {
-			for _, dep := range deps {
-				if dep == name {
-					remainingDeps[depName]--
-					if remainingDeps[depName] == 0 {
-						queue = append(queue, depName)
-					}
-					break
-				}
-			}
-		}
+	keyVar := fmt.Sprintf("key%d", g.depth)
+	valVar := fmt.Sprintf("val%d", g.depth)
+	g.depth++
+	fmt.Fprintf(g.buf, "%s{\n", indent)
+	lenType := g.generateReadLength(decVar, typ, indent+"    ")
+	fmt.Fprintf(g.buf, "%s    for (%s i = 0; i < len; ++i) {\n", indent, lenType)
+	fmt.Fprintf(g.buf, "%s        %s %s;\n", indent, g.cppTypeString(typ.KeyType), keyVar)
+	g.generateDecodeValue(decVar, keyVar, typ.KeyType, indent+"        ")
+	fmt.Fprintf(g.buf, "%s        %s %s;\n", indent, g.cppTypeString(typ.ValueType), valVar)
+	g.generateDecodeValue(decVar, valVar, typ.ValueType, indent+"        ")
+	fmt.Fprintf(g.buf, "%s        %s.insert_or_assign(std::move(%s), std::move(%s));\n", indent, resultVar, keyVar, valVar)
+	fmt.Fprintf(g.buf, "%s    }\n", indent)
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+	g.depth--
+
+	if typ.Optional {
+		indent = indent[:len(indent)-4]
+		fmt.Fprintf(g.buf, "%s    %s = std::move(%s);\n", indent, originalResultVar, resultVar)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
 	}
+}
 
-	// If there are cycles, just append remaining structs (shouldn't happen in valid schemas)
-	if len(result) < len(structs) {
-		for _, s := range structs {
-			found := false
-			for _, r := range result {
-				if r.Name == s.Name {
-					found = true
-					break
-				}
-			}
-			if !found {
-				result = append(result, s)
-			}
-		}
+// generateEncodeUnion writes the tag of the held alternative and its value.
+// A variant left valueless by an exception cannot be encoded.
+func (g *cppGenerator) generateEncodeUnion(encVar, valueVar string, typ *schema.UnionType, indent string) {
+	if typ.Optional {
+		g.generateWritePresence(encVar, valueVar, indent)
+		valueVar = valueVar + ".value()"
+		indent += "    "
 	}
 
-	return result
+	fmt.Fprintf(g.buf, "%sswitch (%s.index()) {\n", indent, valueVar)
+	for i, v := range typ.Variants {
+		fmt.Fprintf(g.buf, "%scase %d: {\n", indent, i)
+		fmt.Fprintf(g.buf, "%s    %s.write_byte(%d);\n", indent, encVar, i+1)
+		g.generateEncodeValue(encVar, fmt.Sprintf("std::get<%d>(%s)", i, valueVar), v, indent+"    ")
+		fmt.Fprintf(g.buf, "%s    break;\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+	fmt.Fprintf(g.buf, "%sdefault:\n", indent)
+	fmt.Fprintf(g.buf, "%s    throw std::runtime_error(\"%s is valueless\");\n", indent, typ.Name)
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+
+	if typ.Optional {
+		indent = indent[:len(indent)-4]
+		g.generateClosePresence(encVar, indent)
+	}
 }
 
-// getStructDependency returns the name of the struct type that this field type depends on,
-// or empty string if it doesn't depend on any struct.
-func (g *cppGenerator) getStructDependency(typ schema.Type, structMap map[string]*schema.StructType) string {
-	switch t := typ.(type) {
-	case *schema.StructType:
-		if _, ok := structMap[t.Name]; ok {
-			return t.Name
-		}
-	case *schema.ArrayType:
-		return g.getStructDependency(t.ElementType, structMap)
+// generateDecodeUnion reads the tag and decodes the variant it selects,
+// throwing for tags that select none.
+func (g *cppGenerator) generateDecodeUnion(decVar, resultVar string, typ *schema.UnionType, indent string) {
+	if typ.Optional {
+		g.generateReadPresence(decVar, indent)
+		indent += "    "
+		fmt.Fprintf(g.buf, "%s%s.emplace();\n", indent, resultVar)
+		resultVar = "(*" + resultVar + ")"
+	}
+
+	tagVar := fmt.Sprintf("tag%d", g.depth)
+	valVar := fmt.Sprintf("variant%d", g.depth)
+	g.depth++
+	fmt.Fprintf(g.buf, "%suint8_t %s = static_cast<uint8_t>(%s.read_int8());\n", indent, tagVar, decVar)
+	fmt.Fprintf(g.buf, "%sswitch (%s) {\n", indent, tagVar)
+	for i, v := range typ.Variants {
+		fmt.Fprintf(g.buf, "%scase %d: {\n", indent, i+1)
+		fmt.Fprintf(g.buf, "%s    %s %s;\n", indent, g.cppTypeString(v), valVar)
+		g.generateDecodeValue(decVar, valVar, v, indent+"    ")
+		fmt.Fprintf(g.buf, "%s    %s.emplace<%d>(std::move(%s));\n", indent, resultVar, i, valVar)
+		fmt.Fprintf(g.buf, "%s    break;\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+	fmt.Fprintf(g.buf, "%sdefault:\n", indent)
+	fmt.Fprintf(g.buf, "%s    throw DecodeError(DecodeErrorCode::InvalidValue, \"invalid %s tag \" + std::to_string(%s));\n", indent, typ.Name, tagVar)
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+	g.depth--
+
+	if typ.Optional {
+		indent = indent[:len(indent)-4]
+		fmt.Fprintf(g.buf, "%s}\n", indent)
 	}
-	return ""
 }
 
 func (g *cppGenerator) generateStructHelpers(structType *schema.StructType) {
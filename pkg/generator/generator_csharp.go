@@ -3,7 +3,6 @@ package generator
 import (
 	"bytes"
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/shaban/ffire/pkg/schema"
@@ -12,28 +11,67 @@ import (
 // GenerateCSharp generates native C# code with Span<byte> encoding/decoding
 // Uses modern .NET patterns: Span<byte>, BinaryPrimitives, MemoryMarshal
 func GenerateCSharp(s *schema.Schema) ([]byte, error) {
+	return GenerateCSharpWithOptions(s, CSharpOptions{})
+}
+
+// CSharpOptions select optional code in the generated C# source.
+type CSharpOptions struct {
+	// Unity restricts the code to what Unity 2021.3 and later compile:
+	// C# 9 and the .NET Standard 2.1 API, under Mono and IL2CPP. The
+	// zero-copy views, which need C# 11, are left out.
+	Unity bool
+}
+
+// GenerateCSharpWithOptions is GenerateCSharp with optional code.
+func GenerateCSharpWithOptions(s *schema.Schema, opts CSharpOptions) ([]byte, error) {
 	s.Canonicalize()
 
 	gen := &csharpGenerator{
 		schema:     s,
+		opts:       opts,
 		buf:        &bytes.Buffer{},
 		seenTypes:  make(map[string]bool),
 		needsTypes: make(map[string]bool),
+		views:      make(map[string]bool),
+		viewLists:  make(map[string]bool),
 	}
 	return gen.generate()
 }
 
+// CSharpHasView reports whether GenerateCSharp writes a zero-copy view,
+// <message>MessageView, for the message of s named message.
+func CSharpHasView(s *schema.Schema, message string) bool {
+	g := &csharpGenerator{schema: s, views: make(map[string]bool), viewLists: make(map[string]bool)}
+	g.findViews()
+	for _, msg := range s.Messages {
+		if msg.Name == message {
+			return g.csharpViewType(msg.TargetType) != ""
+		}
+	}
+	return false
+}
+
 type csharpGenerator struct {
 	schema     *schema.Schema
+	opts       CSharpOptions
 	buf        *bytes.Buffer
 	seenTypes  map[string]bool
 	needsTypes map[string]bool
+	varCounter int
+
+	views     map[string]bool // Whether each struct, by name, has a view
+	viewLists map[string]bool // Structs whose views are array elements
+	spanLists bool            // Whether a view holds an FFireSpanList
 }
 
 func (g *csharpGenerator) generate() ([]byte, error) {
 	fmt.Fprintf(g.buf, "// Code generated by ffire. DO NOT EDIT.\n\n")
 	fmt.Fprintf(g.buf, "using System;\n")
+	fmt.Fprintf(g.buf, "using System.Buffers;\n")
 	fmt.Fprintf(g.buf, "using System.Buffers.Binary;\n")
+	if g.schema.HasMaps() {
+		fmt.Fprintf(g.buf, "using System.Collections.Generic;\n")
+	}
 	fmt.Fprintf(g.buf, "using System.Runtime.CompilerServices;\n")
 	fmt.Fprintf(g.buf, "using System.Runtime.InteropServices;\n")
 	fmt.Fprintf(g.buf, "using System.Text;\n\n")
@@ -56,6 +94,16 @@ func (g *csharpGenerator) generate() ([]byte, error) {
 		return nil, err
 	}
 
+	g.buf.WriteString(csharpDecodeErrors())
+
+	for _, enum := range g.schema.Enums() {
+		g.generateEnum(enum)
+	}
+
+	for _, union := range g.schema.Unions() {
+		g.generateUnion(union)
+	}
+
 	// Generate helper classes first (public visibility - needed for array element types)
 	for _, name := range sorted {
 		if !messageTypes[name] {
@@ -72,22 +120,58 @@ func (g *csharpGenerator) generate() ([]byte, error) {
 		}
 	}
 
-	// Generate string decoder helper if needed
-	if g.needsStringDecoder() {
+	// Zero-copy views, for the types that hold strings or bytes
+	hasViews := !g.opts.Unity && g.generateViews(sorted, messageTypes)
+
+	// Generate string and bytes decoder helpers if needed
+	usesStrings, usesBytes, usesLarge := g.needsStringDecoder(), g.schema.HasBytes(), g.schema.HasLarge()
+	if usesStrings || usesBytes || usesLarge {
 		g.buf.WriteString("    internal static class FFireHelpers\n")
 		g.buf.WriteString("    {\n")
+	}
+	if usesStrings {
 		g.buf.WriteString("        [MethodImpl(MethodImplOptions.AggressiveInlining)]\n")
-		g.buf.WriteString("        internal static unsafe string DecodeString(ReadOnlySpan<byte> buffer, ref int offset)\n")
+		// Bounds-checked, so TryDecode rejects a string cut short
+		g.buf.WriteString("        internal static string DecodeString(ReadOnlySpan<byte> buffer, ref int offset)\n")
 		g.buf.WriteString("        {\n")
-		g.buf.WriteString("            fixed (byte* ptr = buffer)\n")
-		g.buf.WriteString("            {\n")
-		g.buf.WriteString("                ushort length = *(ushort*)(ptr + offset);\n")
-		g.buf.WriteString("                offset += 2;\n")
-		g.buf.WriteString("                string result = Encoding.UTF8.GetString(ptr + offset, length);\n")
-		g.buf.WriteString("                offset += length;\n")
-		g.buf.WriteString("                return result;\n")
-		g.buf.WriteString("            }\n")
+		g.buf.WriteString("            int length = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset));\n")
+		g.buf.WriteString("            offset += 2;\n")
+		g.buf.WriteString("            string result = FFireDecode.ReadString(buffer, offset, length);\n")
+		g.buf.WriteString("            offset += length;\n")
+		g.buf.WriteString("            return result;\n")
+		g.buf.WriteString("        }\n")
+		if g.schema.HasMaps() {
+			// Map keys are encoded in UTF-8 byte order, which ordinal
+			// (UTF-16) comparison does not match for surrogate pairs
+			g.buf.WriteString("\n")
+			g.buf.WriteString("        internal static int CompareUtf8(string a, string b)\n")
+			g.buf.WriteString("        {\n")
+			g.buf.WriteString("            return Encoding.UTF8.GetBytes(a).AsSpan().SequenceCompareTo(Encoding.UTF8.GetBytes(b));\n")
+			g.buf.WriteString("        }\n")
+		}
+	}
+	if usesBytes {
+		if usesStrings {
+			g.buf.WriteString("\n")
+		}
+		// Copies out of the buffer, so the result outlives it
+		g.buf.WriteString("        internal static byte[] DecodeBytes(ReadOnlySpan<byte> buffer, ref int offset)\n")
+		g.buf.WriteString("        {\n")
+		g.buf.WriteString("            int length = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n")
+		g.buf.WriteString("            offset += 2;\n")
+		g.buf.WriteString("            FFireDecode.CheckLength(length, buffer, offset);\n")
+		g.buf.WriteString("            byte[] result = buffer.Slice(offset, length).ToArray();\n")
+		g.buf.WriteString("            offset += length;\n")
+		g.buf.WriteString("            return result;\n")
 		g.buf.WriteString("        }\n")
+	}
+	if usesLarge {
+		g.generateLargeHelpers(usesStrings || usesBytes, usesStrings, usesBytes)
+	}
+	if hasViews {
+		g.generateViewHelpers(usesLarge)
+	}
+	if usesStrings || usesBytes || usesLarge {
 		g.buf.WriteString("    }\n\n")
 	}
 
@@ -95,6 +179,93 @@ func (g *csharpGenerator) generate() ([]byte, error) {
 	return g.buf.Bytes(), nil
 }
 
+// generateLargeHelpers writes the FFireHelpers members that read @large
+// values, whose length prefix is a uint32.
+func (g *csharpGenerator) generateLargeHelpers(separate, usesStrings, usesBytes bool) {
+	if separate {
+		g.buf.WriteString("\n")
+	}
+	// Every element or byte takes at least one byte on the wire, so a
+	// length past the end of the buffer is rejected before allocating
+	g.buf.WriteString("        internal static int ReadLargeLength(ReadOnlySpan<byte> buffer, ref int offset)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            uint length = BinaryPrimitives.ReadUInt32LittleEndian(buffer.Slice(offset, 4));\n")
+	g.buf.WriteString("            offset += 4;\n")
+	g.buf.WriteString("            if (length > (uint)(buffer.Length - offset)) throw new DecodeException(DecodeErrorCode.Overflow, $\"length {length} exceeds the remaining {buffer.Length - offset} bytes\");\n")
+	g.buf.WriteString("            return (int)length;\n")
+	g.buf.WriteString("        }\n")
+	if usesStrings {
+		g.buf.WriteString("\n")
+		g.buf.WriteString("        internal static string DecodeLargeString(ReadOnlySpan<byte> buffer, ref int offset)\n")
+		g.buf.WriteString("        {\n")
+		g.buf.WriteString("            int length = ReadLargeLength(buffer, ref offset);\n")
+		g.buf.WriteString("            string result = FFireDecode.ReadString(buffer, offset, length);\n")
+		g.buf.WriteString("            offset += length;\n")
+		g.buf.WriteString("            return result;\n")
+		g.buf.WriteString("        }\n")
+	}
+	if usesBytes {
+		g.buf.WriteString("\n")
+		g.buf.WriteString("        internal static byte[] DecodeLargeBytes(ReadOnlySpan<byte> buffer, ref int offset)\n")
+		g.buf.WriteString("        {\n")
+		g.buf.WriteString("            int length = ReadLargeLength(buffer, ref offset);\n")
+		g.buf.WriteString("            byte[] result = buffer.Slice(offset, length).ToArray();\n")
+		g.buf.WriteString("            offset += length;\n")
+		g.buf.WriteString("            return result;\n")
+		g.buf.WriteString("        }\n")
+	}
+}
+
+// writeLength writes the length prefix of t, a string, bytes, array or
+// map, as the value of count (cast to the prefix type as written).
+func (g *csharpGenerator) writeLength(count string, t schema.Type, indent string) {
+	if schema.IsLarge(t) {
+		fmt.Fprintf(g.buf, "%sBinaryPrimitives.WriteUInt32LittleEndian(buffer.AsSpan(offset), (uint)%s); offset += 4;\n", indent, count)
+		return
+	}
+	fmt.Fprintf(g.buf, "%s{ ushort len = (ushort)%s; buffer[offset++] = (byte)len; buffer[offset++] = (byte)(len >> 8); }\n", indent, count)
+}
+
+// readLength declares lenVar and reads the length prefix of t into it.
+func (g *csharpGenerator) readLength(lenVar string, t schema.Type, indent string) {
+	if schema.IsLarge(t) {
+		fmt.Fprintf(g.buf, "%sint %s = FFireHelpers.ReadLargeLength(buffer, ref offset);\n", indent, lenVar)
+		return
+	}
+	fmt.Fprintf(g.buf, "%sint %s = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n", indent, lenVar)
+	fmt.Fprintf(g.buf, "%soffset += 2;\n", indent)
+	fmt.Fprintf(g.buf, "%sFFireDecode.CheckLength(%s, buffer, offset);\n", indent, lenVar)
+}
+
+// generateEnum declares enum with its base as the underlying type, and an
+// IsValid extension method that decoders use to reject undeclared values.
+func (g *csharpGenerator) generateEnum(enum *schema.EnumType) {
+	g.buf.WriteString(xmlDoc("    ", enum.Doc))
+	fmt.Fprintf(g.buf, "    public enum %s : %s\n", enum.Name, g.csharpBaseType(enum.Base))
+	g.buf.WriteString("    {\n")
+	for _, v := range enum.Values {
+		g.buf.WriteString(xmlDoc("        ", v.Doc))
+		fmt.Fprintf(g.buf, "        %s = %d,\n", v.Name, v.Value)
+	}
+	g.buf.WriteString("    }\n\n")
+
+	fmt.Fprintf(g.buf, "    public static class %sExtensions\n", enum.Name)
+	g.buf.WriteString("    {\n")
+	fmt.Fprintf(g.buf, "        public static bool IsValid(this %s v)\n", enum.Name)
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            switch (v)\n")
+	g.buf.WriteString("            {\n")
+	for _, v := range enum.Values {
+		fmt.Fprintf(g.buf, "                case %s.%s:\n", enum.Name, v.Name)
+	}
+	g.buf.WriteString("                    return true;\n")
+	g.buf.WriteString("                default:\n")
+	g.buf.WriteString("                    return false;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+}
+
 func (g *csharpGenerator) collectNeededTypes(t schema.Type) {
 	switch typ := t.(type) {
 	case *schema.StructType:
@@ -107,71 +278,103 @@ func (g *csharpGenerator) collectNeededTypes(t schema.Type) {
 		}
 	case *schema.ArrayType:
 		g.collectNeededTypes(typ.ElementType)
+	case *schema.MapType:
+		g.collectNeededTypes(typ.ValueType)
+	case *schema.UnionType:
+		for _, v := range typ.Variants {
+			g.collectNeededTypes(v)
+		}
 	}
 }
 
-func (g *csharpGenerator) topologicalSort() ([]string, error) {
-	deps := make(map[string][]string)
-	for name := range g.needsTypes {
-		structType := g.findStructType(name)
-		if structType != nil {
-			for _, field := range structType.Fields {
-				if st, ok := field.Type.(*schema.StructType); ok {
-					if g.needsTypes[st.Name] {
-						deps[name] = append(deps[name], st.Name)
-					}
-				}
-				if arr, ok := field.Type.(*schema.ArrayType); ok {
-					if st, ok := arr.ElementType.(*schema.StructType); ok {
-						if g.needsTypes[st.Name] {
-							deps[name] = append(deps[name], st.Name)
-						}
-					}
-				}
-			}
-		}
+// generateUnion declares a class holding the value of each variant and the
+// Tag of the one that is set. Assigning a variant property sets Tag.
+func (g *csharpGenerator) generateUnion(union *schema.UnionType) {
+	g.buf.WriteString(xmlDoc("    ", union.Doc))
+	fmt.Fprintf(g.buf, "    // %s holds one of its variants; Tag is the wire tag of the one set.\n", union.Name)
+	fmt.Fprintf(g.buf, "    public sealed class %s\n", union.Name)
+	g.buf.WriteString("    {\n")
+	g.buf.WriteString("        public byte Tag { get; private set; }\n")
+	for i, v := range union.Variants {
+		prop := g.csharpVariantProperty(v)
+		fmt.Fprintf(g.buf, "\n        private %s _%s;\n", g.csharpType(v), g.toCamelCase(prop))
+		fmt.Fprintf(g.buf, "        public %s %s { get => _%s; set { _%s = value; Tag = %d; } }\n", g.csharpType(v), prop, g.toCamelCase(prop), g.toCamelCase(prop), i+1)
 	}
+	g.buf.WriteString("\n")
 
-	inDegree := make(map[string]int)
-	for name := range g.needsTypes {
-		if _, exists := deps[name]; !exists {
-			inDegree[name] = 0
-		} else {
-			inDegree[name] = len(deps[name])
-		}
+	g.buf.WriteString("        internal int ComputeMaxSize()\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int size = 1;\n")
+	g.buf.WriteString("            switch (Tag)\n")
+	g.buf.WriteString("            {\n")
+	for i, v := range union.Variants {
+		fmt.Fprintf(g.buf, "                case %d:\n", i+1)
+		g.generateMapValueMaxSize(v, "_"+g.toCamelCase(g.csharpVariantProperty(v)), "                    ")
+		g.buf.WriteString("                    break;\n")
 	}
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            return size;\n")
+	g.buf.WriteString("        }\n\n")
 
-	var queue []string
-	for name, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, name)
-		}
+	g.buf.WriteString("        internal unsafe void EncodeTo(byte[] buffer, ref int offset)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            switch (Tag)\n")
+	g.buf.WriteString("            {\n")
+	for i, v := range union.Variants {
+		fmt.Fprintf(g.buf, "                case %d:\n", i+1)
+		g.buf.WriteString("                {\n")
+		fmt.Fprintf(g.buf, "                    buffer[offset++] = %d;\n", i+1)
+		g.generateMapValueEncode(v, "_"+g.toCamelCase(g.csharpVariantProperty(v)), "                    ")
+		g.buf.WriteString("                    break;\n")
+		g.buf.WriteString("                }\n")
+	}
+	g.buf.WriteString("                default:\n")
+	fmt.Fprintf(g.buf, "                    throw new InvalidOperationException(\"%s has no variant set\");\n", union.Name)
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n\n")
+
+	fmt.Fprintf(g.buf, "        internal static %s DecodeFrom(ReadOnlySpan<byte> buffer, ref int offset)\n", union.Name)
+	g.buf.WriteString("        {\n")
+	fmt.Fprintf(g.buf, "            var obj = new %s();\n", union.Name)
+	g.buf.WriteString("            byte tag = buffer[offset++];\n")
+	g.buf.WriteString("            switch (tag)\n")
+	g.buf.WriteString("            {\n")
+	for i, v := range union.Variants {
+		fmt.Fprintf(g.buf, "                case %d:\n", i+1)
+		g.buf.WriteString("                {\n")
+		g.generateMapValueDecode(v, "obj."+g.csharpVariantProperty(v), "                    ")
+		g.buf.WriteString("                    break;\n")
+		g.buf.WriteString("                }\n")
 	}
+	g.buf.WriteString("                default:\n")
+	fmt.Fprintf(g.buf, "                    throw new DecodeException(DecodeErrorCode.InvalidValue, $\"invalid %s tag {tag}\");\n", union.Name)
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            return obj;\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+}
 
-	var sorted []string
-	for len(queue) > 0 {
-		sort.Strings(queue)
-		name := queue[0]
-		queue = queue[1:]
-		sorted = append(sorted, name)
-
-		for dependent, depList := range deps {
-			for _, dep := range depList {
-				if dep == name {
-					inDegree[dependent]--
-					if inDegree[dependent] == 0 {
-						queue = append(queue, dependent)
-					}
-					break
-				}
-			}
+// csharpVariantProperty names the property holding a union variant. Struct
+// and enum variants share their type's name, which C# resolves either way.
+func (g *csharpGenerator) csharpVariantProperty(v schema.Type) string {
+	return ToPascalCase(v.TypeName())
+}
+
+func (g *csharpGenerator) topologicalSort() ([]string, error) {
+	var needed []*schema.StructType
+	for _, st := range schemaStructs(g.schema) {
+		if g.needsTypes[st.Name] {
+			needed = append(needed, st)
 		}
 	}
-
-	if len(sorted) != len(g.needsTypes) {
-		return nil, fmt.Errorf("circular dependency detected in struct types")
+	if len(needed) != len(g.needsTypes) {
+		return nil, fmt.Errorf("struct types used by messages are not declared in the schema")
 	}
 
+	var sorted []string
+	for _, st := range sortStructsByDependency(needed) {
+		sorted = append(sorted, st.Name)
+	}
 	return sorted, nil
 }
 
@@ -198,8 +401,8 @@ func (g *csharpGenerator) isPrimitiveOnlyStruct(structType *schema.StructType) b
 	for _, field := range structType.Fields {
 		switch t := field.Type.(type) {
 		case *schema.PrimitiveType:
-			// String requires UTF8 encoding, optional requires null handling
-			if t.Name == "string" || t.Optional {
+			// Strings and bytes are variable-size, optional requires null handling
+			if csharpIsReference(t.Name) || t.Optional {
 				return false
 			}
 			// Only bool, int8/16/32/64, float32/64 are allowed
@@ -212,6 +415,16 @@ func (g *csharpGenerator) isPrimitiveOnlyStruct(structType *schema.StructType) b
 	return true
 }
 
+// primitiveOnlySize returns the encoded size of a struct for which
+// isPrimitiveOnlyStruct holds.
+func (g *csharpGenerator) primitiveOnlySize(structType *schema.StructType) int {
+	size := 0
+	for _, field := range structType.Fields {
+		size += schema.GetPrimitiveSize(field.Type)
+	}
+	return size
+}
+
 func (g *csharpGenerator) isStringField(t schema.Type) bool {
 	if prim, ok := t.(*schema.PrimitiveType); ok {
 		return prim.Name == "string"
@@ -230,8 +443,8 @@ func (g *csharpGenerator) reorderFieldsForEncoding(fields []schema.Field) []sche
 	for _, field := range fields {
 		isPrimitive := false
 		if prim, ok := field.Type.(*schema.PrimitiveType); ok {
-			// Non-optional primitives except string go first
-			if !prim.Optional && prim.Name != "string" {
+			// Non-optional primitives except string and bytes go first
+			if !prim.Optional && !csharpIsReference(prim.Name) {
 				isPrimitive = true
 			}
 		}
@@ -247,11 +460,94 @@ func (g *csharpGenerator) reorderFieldsForEncoding(fields []schema.Field) []sche
 	return append(primitives, others...)
 }
 
+// generateTryDecode writes TryDecode, which decodes a className from a span
+// as the method named like does, but returns false instead of throwing for
+// invalid data.
+func (g *csharpGenerator) generateTryDecode(className, like string) {
+	fmt.Fprintf(g.buf, "        /// <summary>Decodes data like %s, but returns false instead of throwing if it is not a valid encoding.</summary>\n", like)
+	fmt.Fprintf(g.buf, "        public static bool TryDecode(ReadOnlySpan<byte> data, out %s value)\n", className)
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int offset = 0;\n")
+	g.buf.WriteString("            try\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                value = DecodeFrom(data, ref offset);\n")
+	g.buf.WriteString("                return offset == data.Length;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            catch (Exception e) when (e is DecodeException || e is ArgumentOutOfRangeException || e is IndexOutOfRangeException)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                value = default;\n")
+	g.buf.WriteString("                return false;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n\n")
+}
+
+// generateDecode writes the Decode overloads, for arrays, spans and
+// memory, which report data that ends early as a truncation.
+func (g *csharpGenerator) generateDecode(className string) {
+	fmt.Fprintf(g.buf, "        public static %s Decode(byte[] data) => Decode(new ReadOnlySpan<byte>(data));\n\n", className)
+	g.buf.WriteString("        /// <summary>Decodes data, which may be a slice of a larger buffer.</summary>\n")
+	fmt.Fprintf(g.buf, "        public static %s Decode(ReadOnlySpan<byte> data)\n", className)
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int offset = 0;\n")
+	g.buf.WriteString("            try\n")
+	g.buf.WriteString("            {\n")
+	fmt.Fprintf(g.buf, "                %s value = DecodeFrom(data, ref offset);\n", className)
+	g.buf.WriteString("                if (offset != data.Length) throw new DecodeException(DecodeErrorCode.TrailingData, $\"{data.Length - offset} trailing bytes after message\");\n")
+	g.buf.WriteString("                return value;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            catch (Exception e) when (e is ArgumentOutOfRangeException || e is IndexOutOfRangeException)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                throw new DecodeException(DecodeErrorCode.Truncated, $\"unexpected end of data at offset {offset}\");\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        /// <summary>Decodes data held in memory, such as a pooled buffer or a segment of a ReadOnlySequence.</summary>\n")
+	fmt.Fprintf(g.buf, "        public static %s Decode(ReadOnlyMemory<byte> data) => Decode(data.Span);\n\n", className)
+}
+
+// generateSpanEncode writes the encoders into caller-owned memory. They
+// encode into a pooled array, since EncodeTo writes to a byte[], and copy
+// the result, so encoding allocates nothing.
+func (g *csharpGenerator) generateSpanEncode() {
+	g.buf.WriteString("        /// <summary>Encodes the message into destination, returning false if it is too small.</summary>\n")
+	g.buf.WriteString("        public bool TryEncode(Span<byte> destination, out int bytesWritten)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            byte[] buffer = ArrayPool<byte>.Shared.Rent(ComputeMaxSize());\n")
+	g.buf.WriteString("            try\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                int offset = 0;\n")
+	g.buf.WriteString("                EncodeTo(buffer, ref offset);\n")
+	g.buf.WriteString("                bytesWritten = buffer.AsSpan(0, offset).TryCopyTo(destination) ? offset : 0;\n")
+	g.buf.WriteString("                return bytesWritten == offset;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            finally\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                ArrayPool<byte>.Shared.Return(buffer);\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        /// <summary>Encodes the message to writer, such as a PipeWriter.</summary>\n")
+	g.buf.WriteString("        public void Encode(IBufferWriter<byte> writer)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            byte[] buffer = ArrayPool<byte>.Shared.Rent(ComputeMaxSize());\n")
+	g.buf.WriteString("            try\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                int offset = 0;\n")
+	g.buf.WriteString("                EncodeTo(buffer, ref offset);\n")
+	g.buf.WriteString("                buffer.AsSpan(0, offset).CopyTo(writer.GetSpan(offset));\n")
+	g.buf.WriteString("                writer.Advance(offset);\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            finally\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                ArrayPool<byte>.Shared.Return(buffer);\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n\n")
+}
+
 func (g *csharpGenerator) generateMessageClass(msg *schema.MessageType) error {
 	switch targetType := msg.TargetType.(type) {
 	case *schema.StructType:
 		return g.generateStructClassWithName(targetType, msg.Name+"Message", true)
 	case *schema.ArrayType:
+		g.buf.WriteString(xmlDoc("    ", msg.Doc))
 		return g.generateArrayMessageClass(msg.Name, targetType, true)
 	default:
 		return fmt.Errorf("message %s has unsupported type %T", msg.Name, msg.TargetType)
@@ -277,9 +573,13 @@ func (g *csharpGenerator) generateStructClassWithName(structType *schema.StructT
 		visibility = "public"
 	}
 
-	// Add StructLayout attribute for primitive-only structs to enable fast bulk operations
+	g.buf.WriteString(xmlDoc("    ", structType.Doc))
+
+	// Add StructLayout attribute for primitive-only structs to enable fast
+	// bulk operations. Pack = 1 leaves out the trailing padding the wire
+	// format does not have.
 	if g.isPrimitiveOnlyStruct(structType) {
-		g.buf.WriteString("    [StructLayout(LayoutKind.Sequential)]\n")
+		g.buf.WriteString("    [StructLayout(LayoutKind.Sequential, Pack = 1)]\n")
 	}
 
 	// Use readonly struct for better performance (passed by value, no heap allocation)
@@ -290,6 +590,10 @@ func (g *csharpGenerator) generateStructClassWithName(structType *schema.StructT
 	for _, field := range structType.Fields {
 		fieldName := g.toPascalCase(field.Name)
 		csType := g.csharpType(field.Type)
+		if st, ok := field.Type.(*schema.StructType); ok && st.Optional {
+			csType = g.csharpValueType(st)
+		}
+		g.buf.WriteString(xmlDoc("        ", field.Doc))
 		fmt.Fprintf(g.buf, "        public %s %s { get; set; }\n", csType, fieldName)
 	}
 	g.buf.WriteString("\n")
@@ -309,24 +613,28 @@ func (g *csharpGenerator) generateStructClassWithName(structType *schema.StructT
 	g.buf.WriteString("        }\n\n")
 
 	// Decode method
-	fmt.Fprintf(g.buf, "        public static %s Decode(byte[] data)\n", className)
-	g.buf.WriteString("        {\n")
-	g.buf.WriteString("            ReadOnlySpan<byte> span = data;\n")
-	g.buf.WriteString("            int offset = 0;\n")
-	fmt.Fprintf(g.buf, "            return DecodeFrom(span, ref offset);\n")
-	g.buf.WriteString("        }\n\n")
+	g.generateDecode(className)
+	g.generateTryDecode(className, "Decode")
+	g.generateSpanEncode()
 
 	// ComputeMaxSize method - fast upper bound using UTF-8 byte array lengths
 	g.buf.WriteString("        [MethodImpl(MethodImplOptions.AggressiveInlining)]\n")
 	g.buf.WriteString("        internal int ComputeMaxSize()\n")
 	g.buf.WriteString("        {\n")
 
-	// Use sizeof for primitive-only structs
+	// Primitive-only structs have a fixed size
 	if g.isPrimitiveOnlyStruct(structType) {
-		fmt.Fprintf(g.buf, "            return sizeof(%s);\n", className)
+		fmt.Fprintf(g.buf, "            return %d;\n", g.primitiveOnlySize(structType))
 	} else {
 		g.buf.WriteString("            int size = 0;\n")
+		if n := schema.BitmapSize(structType); n > 0 {
+			fmt.Fprintf(g.buf, "            size += %d;\n", n)
+		}
 		for _, field := range structType.Fields {
+			if structType.Bitmap && field.Type.IsOptional() {
+				g.generateBitmappedMaxSize(&field)
+				continue
+			}
 			g.generateMaxSizeComputation(&field)
 		}
 		g.buf.WriteString("            return size;\n")
@@ -343,9 +651,14 @@ func (g *csharpGenerator) generateStructClassWithName(structType *schema.StructT
 		g.buf.WriteString("            // Fast path: bulk copy for primitive-only struct\n")
 		fmt.Fprintf(g.buf, "            Span<%s> structSpan = MemoryMarshal.Cast<byte, %s>(buffer.AsSpan(offset));\n", className, className)
 		g.buf.WriteString("            structSpan[0] = this;\n")
-		fmt.Fprintf(g.buf, "            offset += sizeof(%s);\n", className)
+		fmt.Fprintf(g.buf, "            offset += %d;\n", g.primitiveOnlySize(structType))
 	} else {
+		g.generateWriteBitmap(structType)
 		for _, field := range structType.Fields {
+			if structType.Bitmap && field.Type.IsOptional() {
+				g.generateBitmappedEncode(&field)
+				continue
+			}
 			g.generateEncodeField(&field)
 		}
 	}
@@ -353,22 +666,29 @@ func (g *csharpGenerator) generateStructClassWithName(structType *schema.StructT
 	fmt.Fprintf(g.buf, "        internal static %s DecodeFrom(ReadOnlySpan<byte> buffer, ref int offset)\n", className)
 	g.buf.WriteString("        {\n")
 
-	// Use unsafe pointer read for primitive-only structs (1.19x faster than field-by-field)
+	// Use a bulk read for primitive-only structs (1.19x faster than
+	// field-by-field). MemoryMarshal.Read checks the bounds, so data that
+	// ends early throws instead of reading past the span.
 	if g.isPrimitiveOnlyStruct(structType) {
-		g.buf.WriteString("            // Fast path: unsafe pointer read for primitive-only struct\n")
-		fmt.Fprintf(g.buf, "            %s result;\n", className)
-		g.buf.WriteString("            unsafe\n")
-		g.buf.WriteString("            {\n")
-		g.buf.WriteString("                fixed (byte* ptr = buffer)\n")
-		g.buf.WriteString("                {\n")
-		fmt.Fprintf(g.buf, "                    result = *(%s*)(ptr + offset);\n", className)
-		g.buf.WriteString("                }\n")
-		g.buf.WriteString("            }\n")
-		fmt.Fprintf(g.buf, "            offset += sizeof(%s);\n", className)
+		g.buf.WriteString("            // Fast path: bulk read for primitive-only struct\n")
+		fmt.Fprintf(g.buf, "            var result = MemoryMarshal.Read<%s>(buffer.Slice(offset));\n", className)
+		at := 0
+		for _, field := range structType.Fields {
+			if field.Type.(*schema.PrimitiveType).Name == "bool" {
+				fmt.Fprintf(g.buf, "            FFireDecode.CheckBools(buffer.Slice(offset + %d, 1), offset + %d);\n", at, at)
+			}
+			at += schema.GetPrimitiveSize(field.Type)
+		}
+		fmt.Fprintf(g.buf, "            offset += %d;\n", g.primitiveOnlySize(structType))
 		g.buf.WriteString("            return result;\n")
 	} else {
 		fmt.Fprintf(g.buf, "            var obj = new %s();\n", className)
+		bits := g.generateReadBitmap(structType)
 		for _, field := range structType.Fields {
+			if test, ok := bits[field.Name]; ok {
+				g.generateBitmappedDecode(&field, test)
+				continue
+			}
 			g.generateDecodeField(&field)
 		}
 		g.buf.WriteString("            return obj;\n")
@@ -407,27 +727,26 @@ func (g *csharpGenerator) generateArrayMessageClass(msgName string, arrayType *s
 	g.buf.WriteString("        }\n\n")
 
 	// Decode
-	fmt.Fprintf(g.buf, "        public static %s Decode(byte[] data)\n", className)
-	g.buf.WriteString("        {\n")
-	g.buf.WriteString("            ReadOnlySpan<byte> span = data;\n")
-	g.buf.WriteString("            int offset = 0;\n")
-	g.buf.WriteString("            return DecodeFrom(span, ref offset);\n")
-	g.buf.WriteString("        }\n\n")
+	g.generateDecode(className)
+	g.generateTryDecode(className, "Decode")
+	g.generateSpanEncode()
 
 	// ComputeSize - compute exact size
 	g.buf.WriteString("        internal int ComputeMaxSize()\n")
 	g.buf.WriteString("        {\n")
-	g.buf.WriteString("            int size = 2; // array length\n")
+	fmt.Fprintf(g.buf, "            int size = %d; // array length\n", schema.LengthPrefixSize(arrayType))
 	g.buf.WriteString("            if (Items != null)\n")
 	g.buf.WriteString("            {\n")
 
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !csharpIsReference(prim.Name) {
 		fmt.Fprintf(g.buf, "                size += Items.Length * %d;\n", g.sizeOfPrimitive(prim.Name))
 	} else {
 		g.buf.WriteString("                foreach (var item in Items)\n")
 		g.buf.WriteString("                {\n")
 		if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && prim.Name == "string" {
 			g.buf.WriteString("                    size += 2 + Encoding.UTF8.GetByteCount(item ?? \"\");\n")
+		} else if ok && prim.Name == "bytes" {
+			g.buf.WriteString("                    size += 2 + (item?.Length ?? 0);\n")
 		} else if _, ok := arrayType.ElementType.(*schema.StructType); ok {
 			g.buf.WriteString("                    size += item.ComputeMaxSize();\n")
 		}
@@ -441,12 +760,12 @@ func (g *csharpGenerator) generateArrayMessageClass(msgName string, arrayType *s
 	// EncodeTo
 	g.buf.WriteString("        internal unsafe void EncodeTo(byte[] buffer, ref int offset)\n")
 	g.buf.WriteString("        {\n")
-	g.buf.WriteString("            { ushort len = (ushort)(Items?.Length ?? 0); buffer[offset++] = (byte)len; buffer[offset++] = (byte)(len >> 8); }\n")
+	g.writeLength("(Items?.Length ?? 0)", arrayType, "            ")
 	g.buf.WriteString("            if (Items != null)\n")
 	g.buf.WriteString("            {\n")
 
 	// Use bulk operations for primitive arrays
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && !csharpIsReference(prim.Name) {
 		g.generateBulkArrayEncode("Items", prim.Name)
 	} else {
 		g.buf.WriteString("                foreach (var item in Items)\n")
@@ -467,12 +786,11 @@ func (g *csharpGenerator) generateArrayMessageClass(msgName string, arrayType *s
 	fmt.Fprintf(g.buf, "        internal static %s DecodeFrom(ReadOnlySpan<byte> buffer, ref int offset)\n", className)
 	g.buf.WriteString("        {\n")
 	fmt.Fprintf(g.buf, "            var obj = new %s();\n", className)
-	g.buf.WriteString("            int length = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n")
-	g.buf.WriteString("            offset += 2;\n")
-	fmt.Fprintf(g.buf, "            obj.Items = new %s[length];\n", elemType)
+	g.readLength("length", arrayType, "            ")
+	fmt.Fprintf(g.buf, "            obj.Items = %s;\n", csharpNewArray(elemType, "length"))
 
 	// Use bulk operations for primitive arrays
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && !csharpIsReference(prim.Name) {
 		g.generateBulkArrayDecode("obj.Items", prim.Name)
 	} else {
 		g.buf.WriteString("            for (int i = 0; i < length; i++)\n")
@@ -505,8 +823,15 @@ func (g *csharpGenerator) csharpType(t schema.Type) string {
 	case *schema.ArrayType:
 		elemType := g.csharpType(typ.ElementType)
 		return elemType + "[]"
-	case *schema.StructType:
+	case *schema.MapType:
+		return "Dictionary<" + g.csharpType(typ.KeyType) + ", " + g.csharpValueType(typ.ValueType) + ">"
+	case *schema.EnumType:
+		if typ.Optional {
+			return typ.Name + "?"
+		}
 		return typ.Name
+	case *schema.StructType, *schema.UnionType:
+		return typ.TypeName()
 	}
 	return "object"
 }
@@ -537,6 +862,8 @@ func (g *csharpGenerator) csharpBaseType(kind string) string {
 		return "double"
 	case "string":
 		return "string"
+	case "bytes":
+		return "byte[]"
 	default:
 		return "object"
 	}
@@ -569,7 +896,9 @@ func (g *csharpGenerator) generateSizeComputationWithCache(field *schema.Field)
 				// Encode to UTF-8 once and cache the bytes
 				cacheFieldName := fmt.Sprintf("_cached%sUtf8", fieldName)
 				fmt.Fprintf(g.buf, "                %s = Encoding.UTF8.GetBytes(%s);\n", cacheFieldName, fieldName)
-				fmt.Fprintf(g.buf, "                size += 2 + %s.Length;\n", cacheFieldName)
+				fmt.Fprintf(g.buf, "                size += %d + %s.Length;\n", schema.LengthPrefixSize(typ), cacheFieldName)
+			} else if typ.Name == "bytes" {
+				fmt.Fprintf(g.buf, "                size += %d + (%s?.Length ?? 0);\n", schema.LengthPrefixSize(typ), fieldName)
 			} else {
 				fmt.Fprintf(g.buf, "                size += %d;\n", g.sizeOfPrimitive(typ.Name))
 			}
@@ -579,26 +908,32 @@ func (g *csharpGenerator) generateSizeComputationWithCache(field *schema.Field)
 				// Encode to UTF-8 once and cache the bytes
 				cacheFieldName := fmt.Sprintf("_cached%sUtf8", fieldName)
 				fmt.Fprintf(g.buf, "            %s = Encoding.UTF8.GetBytes(%s ?? \"\");\n", cacheFieldName, fieldName)
-				fmt.Fprintf(g.buf, "            size += 2 + %s.Length;\n", cacheFieldName)
+				fmt.Fprintf(g.buf, "            size += %d + %s.Length;\n", schema.LengthPrefixSize(typ), cacheFieldName)
+			} else if typ.Name == "bytes" {
+				fmt.Fprintf(g.buf, "            size += %d + (%s?.Length ?? 0);\n", schema.LengthPrefixSize(typ), fieldName)
 			} else {
 				fmt.Fprintf(g.buf, "            size += %d;\n", g.sizeOfPrimitive(typ.Name))
 			}
 		}
 	case *schema.ArrayType:
-		if typ.Optional {
+		if typ.Length > 0 {
+			fmt.Fprintf(g.buf, "            size += %d;\n", typ.Length*g.sizeOfPrimitive(typ.ElementType.(*schema.PrimitiveType).Name))
+		} else if typ.Optional {
 			g.buf.WriteString("            size += 1;\n")
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
-			g.buf.WriteString("                size += 2;\n")
+			fmt.Fprintf(g.buf, "                size += %d;\n", schema.LengthPrefixSize(typ))
 			g.generateArraySizeLogic(fieldName, typ)
 			g.buf.WriteString("            }\n")
 		} else {
-			g.buf.WriteString("            size += 2;\n")
+			fmt.Fprintf(g.buf, "            size += %d;\n", schema.LengthPrefixSize(typ))
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
 			g.generateArraySizeLogic(fieldName, typ)
 			g.buf.WriteString("            }\n")
 		}
+	case *schema.MapType:
+		g.generateMapValueMaxSize(typ, fieldName, "            ")
 	case *schema.StructType:
 		fmt.Fprintf(g.buf, "            size += %s.ComputeMaxSize();\n", fieldName)
 	}
@@ -615,7 +950,9 @@ func (g *csharpGenerator) generateMaxSizeComputation(field *schema.Field) {
 			g.buf.WriteString("            {\n")
 			if typ.Name == "string" {
 				// Use * 3 multiplier for max UTF-8 expansion (like Approach 3)
-				fmt.Fprintf(g.buf, "                size += 2 + (%s?.Length ?? 0) * 3;\n", fieldName)
+				fmt.Fprintf(g.buf, "                size += %d + (%s?.Length ?? 0) * 3;\n", schema.LengthPrefixSize(typ), fieldName)
+			} else if typ.Name == "bytes" {
+				fmt.Fprintf(g.buf, "                size += %d + (%s?.Length ?? 0);\n", schema.LengthPrefixSize(typ), fieldName)
 			} else {
 				fmt.Fprintf(g.buf, "                size += %d;\n", g.sizeOfPrimitive(typ.Name))
 			}
@@ -623,27 +960,37 @@ func (g *csharpGenerator) generateMaxSizeComputation(field *schema.Field) {
 		} else {
 			if typ.Name == "string" {
 				// Use * 3 multiplier for max UTF-8 expansion (like Approach 3)
-				fmt.Fprintf(g.buf, "            size += 2 + (%s?.Length ?? 0) * 3;\n", fieldName)
+				fmt.Fprintf(g.buf, "            size += %d + (%s?.Length ?? 0) * 3;\n", schema.LengthPrefixSize(typ), fieldName)
+			} else if typ.Name == "bytes" {
+				fmt.Fprintf(g.buf, "            size += %d + (%s?.Length ?? 0);\n", schema.LengthPrefixSize(typ), fieldName)
 			} else {
 				fmt.Fprintf(g.buf, "            size += %d;\n", g.sizeOfPrimitive(typ.Name))
 			}
 		}
 	case *schema.ArrayType:
-		if typ.Optional {
+		if typ.Length > 0 {
+			fmt.Fprintf(g.buf, "            size += %d;\n", typ.Length*g.sizeOfPrimitive(typ.ElementType.(*schema.PrimitiveType).Name))
+		} else if typ.Optional {
 			g.buf.WriteString("            size += 1;\n")
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
-			g.buf.WriteString("                size += 2;\n")
+			fmt.Fprintf(g.buf, "                size += %d;\n", schema.LengthPrefixSize(typ))
 			g.generateArrayMaxSizeLogic(fieldName, typ)
 			g.buf.WriteString("            }\n")
 		} else {
-			g.buf.WriteString("            size += 2;\n")
+			fmt.Fprintf(g.buf, "            size += %d;\n", schema.LengthPrefixSize(typ))
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
 			g.generateArrayMaxSizeLogic(fieldName, typ)
 			g.buf.WriteString("            }\n")
 		}
+	case *schema.MapType, *schema.EnumType, *schema.UnionType:
+		g.generateMapValueMaxSize(typ, fieldName, "            ")
 	case *schema.StructType:
+		if typ.Optional {
+			g.generateMapValueMaxSize(typ, fieldName, "            ")
+			break
+		}
 		fmt.Fprintf(g.buf, "            size += %s.ComputeMaxSize();\n", fieldName)
 	}
 }
@@ -659,7 +1006,9 @@ func (g *csharpGenerator) generateSizeComputation(field *schema.Field) {
 			g.buf.WriteString("            {\n")
 			if typ.Name == "string" {
 				// Use * 3 multiplier for max UTF-8 expansion (like Approach 3)
-				fmt.Fprintf(g.buf, "                size += 2 + (%s?.Length ?? 0) * 3;\n", fieldName)
+				fmt.Fprintf(g.buf, "                size += %d + (%s?.Length ?? 0) * 3;\n", schema.LengthPrefixSize(typ), fieldName)
+			} else if typ.Name == "bytes" {
+				fmt.Fprintf(g.buf, "                size += %d + (%s?.Length ?? 0);\n", schema.LengthPrefixSize(typ), fieldName)
 			} else {
 				fmt.Fprintf(g.buf, "                size += %d;\n", g.sizeOfPrimitive(typ.Name))
 			}
@@ -667,33 +1016,39 @@ func (g *csharpGenerator) generateSizeComputation(field *schema.Field) {
 		} else {
 			if typ.Name == "string" {
 				// Use * 3 multiplier for max UTF-8 expansion (like Approach 3)
-				fmt.Fprintf(g.buf, "            size += 2 + (%s?.Length ?? 0) * 3;\n", fieldName)
+				fmt.Fprintf(g.buf, "            size += %d + (%s?.Length ?? 0) * 3;\n", schema.LengthPrefixSize(typ), fieldName)
+			} else if typ.Name == "bytes" {
+				fmt.Fprintf(g.buf, "            size += %d + (%s?.Length ?? 0);\n", schema.LengthPrefixSize(typ), fieldName)
 			} else {
 				fmt.Fprintf(g.buf, "            size += %d;\n", g.sizeOfPrimitive(typ.Name))
 			}
 		}
 	case *schema.ArrayType:
-		if typ.Optional {
+		if typ.Length > 0 {
+			fmt.Fprintf(g.buf, "            size += %d;\n", typ.Length*g.sizeOfPrimitive(typ.ElementType.(*schema.PrimitiveType).Name))
+		} else if typ.Optional {
 			g.buf.WriteString("            size += 1;\n")
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
-			g.buf.WriteString("                size += 2;\n")
+			fmt.Fprintf(g.buf, "                size += %d;\n", schema.LengthPrefixSize(typ))
 			g.generateArraySizeLogic(fieldName, typ)
 			g.buf.WriteString("            }\n")
 		} else {
-			g.buf.WriteString("            size += 2;\n")
+			fmt.Fprintf(g.buf, "            size += %d;\n", schema.LengthPrefixSize(typ))
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
 			g.generateArraySizeLogic(fieldName, typ)
 			g.buf.WriteString("            }\n")
 		}
+	case *schema.MapType:
+		g.generateMapValueMaxSize(typ, fieldName, "            ")
 	case *schema.StructType:
 		fmt.Fprintf(g.buf, "            size += %s.ComputeMaxSize();\n", fieldName)
 	}
 }
 
 func (g *csharpGenerator) generateArrayMaxSizeLogic(fieldName string, arrayType *schema.ArrayType) {
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && !csharpIsReference(prim.Name) {
 		fmt.Fprintf(g.buf, "                size += %s.Length * %d;\n", fieldName, g.sizeOfPrimitive(prim.Name))
 	} else {
 		fmt.Fprintf(g.buf, "                foreach (var item in %s)\n", fieldName)
@@ -702,6 +1057,8 @@ func (g *csharpGenerator) generateArrayMaxSizeLogic(fieldName string, arrayType
 			if prim.Name == "string" {
 				// UTF-8 max: 3 bytes per char
 				g.buf.WriteString("                    size += 2 + (item?.Length ?? 0) * 3;\n")
+			} else if prim.Name == "bytes" {
+				g.buf.WriteString("                    size += 2 + (item?.Length ?? 0);\n")
 			} else if prim.Optional {
 				g.buf.WriteString("                    size += 1;\n")
 				g.buf.WriteString("                    if (item != null)\n")
@@ -713,13 +1070,15 @@ func (g *csharpGenerator) generateArrayMaxSizeLogic(fieldName string, arrayType
 			}
 		} else if _, ok := arrayType.ElementType.(*schema.StructType); ok {
 			g.buf.WriteString("                    size += item.ComputeMaxSize();\n")
+		} else if isEnumOrUnion(arrayType.ElementType) {
+			g.generateMapValueMaxSize(arrayType.ElementType, "item", "                    ")
 		}
 		g.buf.WriteString("                }\n")
 	}
 }
 
 func (g *csharpGenerator) generateArraySizeLogic(fieldName string, arrayType *schema.ArrayType) {
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && !csharpIsReference(prim.Name) {
 		fmt.Fprintf(g.buf, "                size += %s.Length * %d;\n", fieldName, g.sizeOfPrimitive(prim.Name))
 	} else {
 		fmt.Fprintf(g.buf, "                foreach (var item in %s)\n", fieldName)
@@ -728,6 +1087,8 @@ func (g *csharpGenerator) generateArraySizeLogic(fieldName string, arrayType *sc
 			if prim.Name == "string" {
 				// Use * 3 multiplier for max UTF-8 expansion (like Approach 3)
 				g.buf.WriteString("                    size += 2 + (item?.Length ?? 0) * 3;\n")
+			} else if prim.Name == "bytes" {
+				g.buf.WriteString("                    size += 2 + (item?.Length ?? 0);\n")
 			} else if prim.Optional {
 				g.buf.WriteString("                    size += 1;\n")
 				g.buf.WriteString("                    if (item != null)\n")
@@ -755,24 +1116,32 @@ func (g *csharpGenerator) generateEncodeField(field *schema.Field) {
 			g.buf.WriteString("                buffer[offset++] = 1;\n")
 			// Unwrap nullable value types (not reference types like string)
 			encodeFieldName := fieldName
-			if typ.Name != "string" {
+			if !csharpIsReference(typ.Name) {
 				encodeFieldName = fieldName + ".Value"
 			}
-			g.generatePrimitiveEncode(encodeFieldName, typ.Name, "                ")
+			if typ.Large {
+				g.generateLargeEncode(encodeFieldName, typ.Name, "                ")
+			} else {
+				g.generatePrimitiveEncode(encodeFieldName, typ.Name, "                ")
+			}
 			g.buf.WriteString("            }\n")
 			g.buf.WriteString("            else\n")
 			g.buf.WriteString("            {\n")
 			g.buf.WriteString("                buffer[offset++] = 0;\n")
 			g.buf.WriteString("            }\n")
+		} else if typ.Large {
+			g.generateLargeEncode(fieldName, typ.Name, "            ")
 		} else {
 			g.generatePrimitiveEncode(fieldName, typ.Name, "            ")
 		}
 	case *schema.ArrayType:
-		if typ.Optional {
+		if typ.Length > 0 {
+			g.generateFixedArrayEncode(fieldName, typ)
+		} else if typ.Optional {
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
 			g.buf.WriteString("                buffer[offset++] = 1;\n")
-			fmt.Fprintf(g.buf, "                { ushort len = (ushort)%s.Length; buffer[offset++] = (byte)len; buffer[offset++] = (byte)(len >> 8); }\n", fieldName)
+			g.writeLength(fieldName+".Length", typ, "                ")
 			g.generateArrayEncodeLogic(fieldName, typ, "                ")
 			g.buf.WriteString("            }\n")
 			g.buf.WriteString("            else\n")
@@ -780,20 +1149,149 @@ func (g *csharpGenerator) generateEncodeField(field *schema.Field) {
 			g.buf.WriteString("                buffer[offset++] = 0;\n")
 			g.buf.WriteString("            }\n")
 		} else {
-			fmt.Fprintf(g.buf, "            { ushort len = (ushort)(%s?.Length ?? 0); buffer[offset++] = (byte)len; buffer[offset++] = (byte)(len >> 8); }\n", fieldName)
+			g.writeLength("("+fieldName+"?.Length ?? 0)", typ, "            ")
 			fmt.Fprintf(g.buf, "            if (%s != null)\n", fieldName)
 			g.buf.WriteString("            {\n")
 			g.generateArrayEncodeLogic(fieldName, typ, "                ")
 			g.buf.WriteString("            }\n")
 		}
+	case *schema.MapType, *schema.EnumType, *schema.UnionType:
+		g.generateMapValueEncode(typ, fieldName, "            ")
 	case *schema.StructType:
+		if typ.Optional {
+			g.generateMapValueEncode(typ, fieldName, "            ")
+			break
+		}
 		fmt.Fprintf(g.buf, "            %s.EncodeTo(buffer, ref offset);\n", fieldName)
 	}
 }
 
+// generateWriteBitmap writes the presence bitmap of a @bitmap struct, one
+// bit per optional field in field order.
+func (g *csharpGenerator) generateWriteBitmap(st *schema.StructType) {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return
+	}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(g.buf, "            byte bits%d = 0;\n", i)
+	}
+	bit := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			fmt.Fprintf(g.buf, "            if (%s != null) bits%d |= 0x%02x;\n", g.toPascalCase(field.Name), bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(g.buf, "            buffer[offset++] = bits%d;\n", i)
+	}
+}
+
+// generateReadBitmap reads the presence bitmap of a @bitmap struct,
+// rejecting set unused bits, and returns the bit test of each optional
+// field by field name. It returns nil for other structs.
+func (g *csharpGenerator) generateReadBitmap(st *schema.StructType) map[string]string {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(g.buf, "            byte bits%d = buffer[offset++];\n", i)
+	}
+	if used := schema.OptionalFields(st) % 8; used != 0 {
+		fmt.Fprintf(g.buf, "            if ((bits%d & 0x%02x) != 0) throw new DecodeException(DecodeErrorCode.BadPresence, $\"unused presence bitmap bits set at offset {offset - 1}\");\n", n-1, 0xff<<used&0xff)
+	}
+	tests := make(map[string]string)
+	bit := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			tests[field.Name] = fmt.Sprintf("(bits%d & 0x%02x) != 0", bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	return tests
+}
+
+// bitmappedValue returns the value of an optional field of a @bitmap
+// struct, unwrapped from its Nullable<T>, and its type without presence.
+func (g *csharpGenerator) bitmappedValue(field *schema.Field) (string, schema.Type) {
+	expr := g.toPascalCase(field.Name)
+	if g.isNullableValue(field.Type) {
+		expr += ".Value"
+	}
+	return expr, schema.WithoutPresence(field.Type)
+}
+
+func (g *csharpGenerator) generateBitmappedMaxSize(field *schema.Field) {
+	expr, typ := g.bitmappedValue(field)
+	fmt.Fprintf(g.buf, "            if (%s != null)\n", g.toPascalCase(field.Name))
+	g.buf.WriteString("            {\n")
+	g.generateMapValueMaxSize(typ, expr, "                ")
+	g.buf.WriteString("            }\n")
+}
+
+func (g *csharpGenerator) generateBitmappedEncode(field *schema.Field) {
+	expr, typ := g.bitmappedValue(field)
+	fmt.Fprintf(g.buf, "            if (%s != null)\n", g.toPascalCase(field.Name))
+	g.buf.WriteString("            {\n")
+	if prim, ok := typ.(*schema.PrimitiveType); ok && prim.Large {
+		g.generateLargeEncode(expr, prim.Name, "                ")
+	} else {
+		g.generateMapValueEncode(typ, expr, "                ")
+	}
+	g.buf.WriteString("            }\n")
+}
+
+func (g *csharpGenerator) generateBitmappedDecode(field *schema.Field, test string) {
+	_, typ := g.bitmappedValue(field)
+	target := "obj." + g.toPascalCase(field.Name)
+	fmt.Fprintf(g.buf, "            if (%s)\n", test)
+	g.buf.WriteString("            {\n")
+	if prim, ok := typ.(*schema.PrimitiveType); ok {
+		fmt.Fprintf(g.buf, "                %s = ", target)
+		g.generateFieldPrimitiveDecode(prim)
+		g.buf.WriteString(";\n")
+	} else {
+		g.generateMapValueDecode(typ, target, "                ")
+	}
+	g.buf.WriteString("            }\n")
+}
+
+// generateFixedArrayEncode writes a fixed-size array field without a length
+// prefix. A null array encodes as zeroed elements, like a default struct.
+func (g *csharpGenerator) generateFixedArrayEncode(fieldName string, arrayType *schema.ArrayType) {
+	prim := arrayType.ElementType.(*schema.PrimitiveType)
+	size := arrayType.Length * g.sizeOfPrimitive(prim.Name)
+	fmt.Fprintf(g.buf, "            if (%s == null)\n", fieldName)
+	g.buf.WriteString("            {\n")
+	fmt.Fprintf(g.buf, "                buffer.AsSpan(offset, %d).Clear();\n", size)
+	fmt.Fprintf(g.buf, "                offset += %d;\n", size)
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            else\n")
+	g.buf.WriteString("            {\n")
+	fmt.Fprintf(g.buf, "                if (%s.Length != %d) throw new ArgumentException($\"%s must hold %d elements, got {%s.Length}\");\n",
+		fieldName, arrayType.Length, fieldName, arrayType.Length, fieldName)
+	g.generateBulkArrayEncode(fieldName, prim.Name)
+	g.buf.WriteString("            }\n")
+}
+
+// generateFixedArrayDecode reads a fixed-size array field; the element count
+// comes from the schema rather than the wire.
+func (g *csharpGenerator) generateFixedArrayDecode(fieldName string, arrayType *schema.ArrayType) {
+	prim := arrayType.ElementType.(*schema.PrimitiveType)
+	elemType := g.csharpBaseType(prim.Name)
+	size := arrayType.Length * g.sizeOfPrimitive(prim.Name)
+	if prim.Name == "bool" {
+		fmt.Fprintf(g.buf, "            FFireDecode.CheckBools(buffer.Slice(offset, %d), offset);\n", size)
+	}
+	fmt.Fprintf(g.buf, "            obj.%s = MemoryMarshal.Cast<byte, %s>(buffer.Slice(offset, %d)).ToArray();\n", fieldName, elemType, size)
+	fmt.Fprintf(g.buf, "            offset += %d;\n", size)
+}
+
 func (g *csharpGenerator) generateArrayEncodeLogic(fieldName string, arrayType *schema.ArrayType, indent string) {
 	// Use MemoryMarshal for bulk primitive array encoding
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && !csharpIsReference(prim.Name) {
 		g.generateBulkArrayEncode(fieldName, prim.Name)
 	} else {
 		fmt.Fprintf(g.buf, "%sforeach (var item in %s)\n", indent, fieldName)
@@ -803,6 +1301,8 @@ func (g *csharpGenerator) generateArrayEncodeLogic(fieldName string, arrayType *
 			g.generatePrimitiveEncodeNoCache("item", prim.Name, indent+"    ")
 		} else if _, ok := arrayType.ElementType.(*schema.StructType); ok {
 			fmt.Fprintf(g.buf, "%s    item.EncodeTo(buffer, ref offset);\n", indent)
+		} else if isEnumOrUnion(arrayType.ElementType) {
+			g.generateMapValueEncode(arrayType.ElementType, "item", indent+"    ")
 		}
 		fmt.Fprintf(g.buf, "%s}\n", indent)
 	}
@@ -821,6 +1321,9 @@ func (g *csharpGenerator) generateBulkArrayDecode(fieldName, kind string) {
 	// Use MemoryMarshal.Cast for zero-copy bulk decoding
 	g.buf.WriteString("            // Bulk copy using MemoryMarshal for zero-copy performance\n")
 	fmt.Fprintf(g.buf, "            int byteCount = length * %d;\n", g.sizeOfPrimitive(kind))
+	if kind == "bool" {
+		g.buf.WriteString("            FFireDecode.CheckBools(buffer.Slice(offset, byteCount), offset);\n")
+	}
 	fmt.Fprintf(g.buf, "            ReadOnlySpan<%s> span = MemoryMarshal.Cast<byte, %s>(buffer.Slice(offset, byteCount));\n",
 		g.csharpBaseType(kind), g.csharpBaseType(kind))
 	fmt.Fprintf(g.buf, "            span.CopyTo(%s);\n", fieldName)
@@ -858,9 +1361,24 @@ func (g *csharpGenerator) generatePrimitiveEncode(fieldName, kind, indent string
 		fmt.Fprintf(g.buf, "%sbuffer[offset++] = (byte)byteCount_%s;\n", indent, cleanFieldName)
 		fmt.Fprintf(g.buf, "%sbuffer[offset++] = (byte)(byteCount_%s >> 8);\n", indent, cleanFieldName)
 		fmt.Fprintf(g.buf, "%soffset += byteCount_%s;\n", indent, cleanFieldName)
+	case "bytes":
+		fmt.Fprintf(g.buf, "%s{ var blob = %s ?? Array.Empty<byte>(); buffer[offset++] = (byte)blob.Length; buffer[offset++] = (byte)(blob.Length >> 8); blob.CopyTo(buffer, offset); offset += blob.Length; }\n", indent, fieldName)
 	}
 }
 
+// generateLargeEncode writes a @large string or bytes field, whose length
+// prefix is a uint32.
+func (g *csharpGenerator) generateLargeEncode(fieldName, kind, indent string) {
+	if kind == "string" {
+		varName := fmt.Sprintf("byteCount_%s", fieldName)
+		fmt.Fprintf(g.buf, "%sint %s = Encoding.UTF8.GetBytes(%s ?? \"\", buffer.AsSpan(offset + 4));\n", indent, varName, fieldName)
+		fmt.Fprintf(g.buf, "%sBinaryPrimitives.WriteUInt32LittleEndian(buffer.AsSpan(offset), (uint)%s);\n", indent, varName)
+		fmt.Fprintf(g.buf, "%soffset += 4 + %s;\n", indent, varName)
+		return
+	}
+	fmt.Fprintf(g.buf, "%s{ var blob = %s ?? Array.Empty<byte>(); BinaryPrimitives.WriteUInt32LittleEndian(buffer.AsSpan(offset), (uint)blob.Length); offset += 4; blob.CopyTo(buffer, offset); offset += blob.Length; }\n", indent, fieldName)
+}
+
 func (g *csharpGenerator) generatePrimitiveEncodeNoCache(fieldName, kind, indent string) {
 	switch kind {
 	case "bool":
@@ -893,6 +1411,8 @@ func (g *csharpGenerator) generatePrimitiveEncodeNoCache(fieldName, kind, indent
 		fmt.Fprintf(g.buf, "%sbuffer[offset++] = (byte)%s;\n", indent, varName)
 		fmt.Fprintf(g.buf, "%sbuffer[offset++] = (byte)(%s >> 8);\n", indent, varName)
 		fmt.Fprintf(g.buf, "%soffset += %s;\n", indent, varName)
+	case "bytes":
+		fmt.Fprintf(g.buf, "%s{ var blob = %s ?? Array.Empty<byte>(); buffer[offset++] = (byte)blob.Length; buffer[offset++] = (byte)(blob.Length >> 8); blob.CopyTo(buffer, offset); offset += blob.Length; }\n", indent, fieldName)
 	}
 }
 
@@ -902,60 +1422,66 @@ func (g *csharpGenerator) generateDecodeField(field *schema.Field) {
 	switch typ := field.Type.(type) {
 	case *schema.PrimitiveType:
 		if typ.Optional {
-			g.buf.WriteString("            if (buffer[offset++] == 1)\n")
+			g.buf.WriteString("            if (FFireDecode.ReadPresence(buffer, ref offset))\n")
 			g.buf.WriteString("            {\n")
-			if typ.Name == "string" {
+			if typ.Name == "string" && !typ.Large {
 				// Inline string decoding (like blueprint) for better performance
 				lenVar := fmt.Sprintf("_len_%s", strings.ToLower(fieldName))
 				fmt.Fprintf(g.buf, "                int %s = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n", lenVar)
 				g.buf.WriteString("                offset += 2;\n")
-				fmt.Fprintf(g.buf, "                obj.%s = %s > 0 ? Encoding.UTF8.GetString(buffer.Slice(offset, %s)) : \"\";\n", fieldName, lenVar, lenVar)
+				fmt.Fprintf(g.buf, "                obj.%s = FFireDecode.ReadString(buffer, offset, %s);\n", fieldName, lenVar)
 				fmt.Fprintf(g.buf, "                offset += %s;\n", lenVar)
 			} else {
 				fmt.Fprintf(g.buf, "                obj.%s = ", fieldName)
-				g.generatePrimitiveDecode(typ.Name)
+				g.generateFieldPrimitiveDecode(typ)
 				g.buf.WriteString(";\n")
 			}
 			g.buf.WriteString("            }\n")
 		} else {
-			if typ.Name == "string" {
+			if typ.Name == "string" && !typ.Large {
 				// Inline string decoding (like blueprint) for better performance
 				lenVar := fmt.Sprintf("_len_%s", strings.ToLower(fieldName))
 				fmt.Fprintf(g.buf, "            int %s = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n", lenVar)
 				g.buf.WriteString("            offset += 2;\n")
-				fmt.Fprintf(g.buf, "            obj.%s = %s > 0 ? Encoding.UTF8.GetString(buffer.Slice(offset, %s)) : \"\";\n", fieldName, lenVar, lenVar)
+				fmt.Fprintf(g.buf, "            obj.%s = FFireDecode.ReadString(buffer, offset, %s);\n", fieldName, lenVar)
 				fmt.Fprintf(g.buf, "            offset += %s;\n", lenVar)
 			} else {
 				fmt.Fprintf(g.buf, "            obj.%s = ", fieldName)
-				g.generatePrimitiveDecode(typ.Name)
+				g.generateFieldPrimitiveDecode(typ)
 				g.buf.WriteString(";\n")
 			}
 		}
 	case *schema.ArrayType:
-		if typ.Optional {
-			g.buf.WriteString("            if (buffer[offset++] == 1)\n")
+		if typ.Length > 0 {
+			g.generateFixedArrayDecode(fieldName, typ)
+		} else if typ.Optional {
+			g.buf.WriteString("            if (FFireDecode.ReadPresence(buffer, ref offset))\n")
 			g.buf.WriteString("            {\n")
-			g.buf.WriteString("                int length = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n")
-			g.buf.WriteString("                offset += 2;\n")
+			g.readLength("length", typ, "                ")
 			elemType := g.csharpType(typ.ElementType)
-			fmt.Fprintf(g.buf, "                obj.%s = new %s[length];\n", fieldName, elemType)
+			fmt.Fprintf(g.buf, "                obj.%s = %s;\n", fieldName, csharpNewArray(elemType, "length"))
 			g.generateArrayDecodeLogic(fmt.Sprintf("obj.%s", fieldName), typ, "                ")
 			g.buf.WriteString("            }\n")
 		} else {
-			g.buf.WriteString("            int length = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n")
-			g.buf.WriteString("            offset += 2;\n")
+			g.readLength("length", typ, "            ")
 			elemType := g.csharpType(typ.ElementType)
-			fmt.Fprintf(g.buf, "            obj.%s = new %s[length];\n", fieldName, elemType)
+			fmt.Fprintf(g.buf, "            obj.%s = %s;\n", fieldName, csharpNewArray(elemType, "length"))
 			g.generateArrayDecodeLogic(fmt.Sprintf("obj.%s", fieldName), typ, "            ")
 		}
+	case *schema.MapType, *schema.EnumType, *schema.UnionType:
+		g.generateMapValueDecode(typ, "obj."+fieldName, "            ")
 	case *schema.StructType:
+		if typ.Optional {
+			g.generateMapValueDecode(typ, "obj."+fieldName, "            ")
+			break
+		}
 		fmt.Fprintf(g.buf, "            obj.%s = %s.DecodeFrom(buffer, ref offset);\n", fieldName, typ.Name)
 	}
 }
 
 func (g *csharpGenerator) generateArrayDecodeLogic(fieldName string, arrayType *schema.ArrayType, indent string) {
 	// Use MemoryMarshal for bulk primitive array decoding
-	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && !csharpIsReference(prim.Name) {
 		g.generateBulkArrayDecode(fieldName, prim.Name)
 	} else {
 		fmt.Fprintf(g.buf, "%sfor (int i = 0; i < length; i++)\n", indent)
@@ -965,7 +1491,7 @@ func (g *csharpGenerator) generateArrayDecodeLogic(fieldName string, arrayType *
 				// Inline string decoding for better performance (use unique variable name per loop iteration)
 				fmt.Fprintf(g.buf, "%s    int _itemLen = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n", indent)
 				fmt.Fprintf(g.buf, "%s    offset += 2;\n", indent)
-				fmt.Fprintf(g.buf, "%s    %s[i] = _itemLen > 0 ? Encoding.UTF8.GetString(buffer.Slice(offset, _itemLen)) : \"\";\n", indent, fieldName)
+				fmt.Fprintf(g.buf, "%s    %s[i] = FFireDecode.ReadString(buffer, offset, _itemLen);\n", indent, fieldName)
 				fmt.Fprintf(g.buf, "%s    offset += _itemLen;\n", indent)
 			} else {
 				fmt.Fprintf(g.buf, "%s    %s[i] = ", indent, fieldName)
@@ -974,15 +1500,30 @@ func (g *csharpGenerator) generateArrayDecodeLogic(fieldName string, arrayType *
 			}
 		} else if st, ok := arrayType.ElementType.(*schema.StructType); ok {
 			fmt.Fprintf(g.buf, "%s    %s[i] = %s.DecodeFrom(buffer, ref offset);\n", indent, fieldName, st.Name)
+		} else if isEnumOrUnion(arrayType.ElementType) {
+			g.generateMapValueDecode(arrayType.ElementType, fieldName+"[i]", indent+"    ")
 		}
 		fmt.Fprintf(g.buf, "%s}\n", indent)
 	}
 }
 
+// generateFieldPrimitiveDecode is generatePrimitiveDecode for a field,
+// which may be a @large string or bytes.
+func (g *csharpGenerator) generateFieldPrimitiveDecode(typ *schema.PrimitiveType) {
+	switch {
+	case typ.Large && typ.Name == "string":
+		g.buf.WriteString("FFireHelpers.DecodeLargeString(buffer, ref offset)")
+	case typ.Large:
+		g.buf.WriteString("FFireHelpers.DecodeLargeBytes(buffer, ref offset)")
+	default:
+		g.generatePrimitiveDecode(typ.Name)
+	}
+}
+
 func (g *csharpGenerator) generatePrimitiveDecode(kind string) {
 	switch kind {
 	case "bool":
-		g.buf.WriteString("buffer[offset++] != 0")
+		g.buf.WriteString("FFireDecode.ReadBool(buffer, ref offset)")
 	case "i8", "int8":
 		g.buf.WriteString("(sbyte)buffer[offset++]")
 	case "u8", "uint8":
@@ -1000,11 +1541,22 @@ func (g *csharpGenerator) generatePrimitiveDecode(kind string) {
 	case "u64", "uint64":
 		g.buf.WriteString("BinaryPrimitives.ReadUInt64LittleEndian(buffer.Slice(offset, 8)); offset += 8")
 	case "f32", "float32":
+		if g.opts.Unity {
+			// ReadSingleLittleEndian is .NET 5 and later
+			g.buf.WriteString("BitConverter.Int32BitsToSingle(BinaryPrimitives.ReadInt32LittleEndian(buffer.Slice(offset, 4))); offset += 4")
+			return
+		}
 		g.buf.WriteString("BinaryPrimitives.ReadSingleLittleEndian(buffer.Slice(offset, 4)); offset += 4")
 	case "f64", "float64":
+		if g.opts.Unity {
+			g.buf.WriteString("BitConverter.Int64BitsToDouble(BinaryPrimitives.ReadInt64LittleEndian(buffer.Slice(offset, 8))); offset += 8")
+			return
+		}
 		g.buf.WriteString("BinaryPrimitives.ReadDoubleLittleEndian(buffer.Slice(offset, 8)); offset += 8")
 	case "string":
 		g.buf.WriteString("FFireHelpers.DecodeString(buffer, ref offset)")
+	case "bytes":
+		g.buf.WriteString("FFireHelpers.DecodeBytes(buffer, ref offset)")
 	}
 }
 
@@ -1047,6 +1599,14 @@ func (g *csharpGenerator) typeUsesStrings(t schema.Type) bool {
 		return typ.Name == "string"
 	case *schema.ArrayType:
 		return g.typeUsesStrings(typ.ElementType)
+	case *schema.MapType:
+		return g.typeUsesStrings(typ.KeyType) || g.typeUsesStrings(typ.ValueType)
+	case *schema.UnionType:
+		for _, v := range typ.Variants {
+			if g.typeUsesStrings(v) {
+				return true
+			}
+		}
 	case *schema.StructType:
 		for _, field := range typ.Fields {
 			if g.typeUsesStrings(field.Type) {
@@ -1056,3 +1616,645 @@ func (g *csharpGenerator) typeUsesStrings(t schema.Type) bool {
 	}
 	return false
 }
+
+// Map fields are encoded by the generateMapValue* functions below, which
+// recurse through the key and value types so that map values may be any
+// type, including arrays and maps. Entries are encoded in ascending key
+// order; decoding fills a Dictionary in wire order.
+
+// csharpValueType is csharpType for map values and array elements
+// reached through a map, where an optional struct becomes Nullable<T>.
+func (g *csharpGenerator) csharpValueType(t schema.Type) string {
+	switch typ := t.(type) {
+	case *schema.StructType:
+		if typ.Optional {
+			return typ.Name + "?"
+		}
+	case *schema.ArrayType:
+		return g.csharpValueType(typ.ElementType) + "[]"
+	}
+	return g.csharpType(t)
+}
+
+func (g *csharpGenerator) uniqueVar(prefix string) string {
+	g.varCounter++
+	return fmt.Sprintf("%s%d", prefix, g.varCounter)
+}
+
+// generateMapValueMaxSize adds an upper bound on the encoded size of expr
+// to size. Optional value types are unwrapped with .Value.
+func (g *csharpGenerator) generateMapValueMaxSize(t schema.Type, expr, indent string) {
+	inner := indent
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%ssize += 1;\n", indent)
+		fmt.Fprintf(g.buf, "%sif (%s != null)\n", indent, expr)
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		inner += "    "
+		if g.isNullableValue(t) {
+			expr += ".Value"
+		}
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		if typ.Name == "string" {
+			fmt.Fprintf(g.buf, "%ssize += %d + (%s?.Length ?? 0) * 3;\n", inner, schema.LengthPrefixSize(typ), expr)
+		} else if typ.Name == "bytes" {
+			fmt.Fprintf(g.buf, "%ssize += %d + (%s?.Length ?? 0);\n", inner, schema.LengthPrefixSize(typ), expr)
+		} else {
+			fmt.Fprintf(g.buf, "%ssize += %d;\n", inner, g.sizeOfPrimitive(typ.Name))
+		}
+	case *schema.EnumType:
+		fmt.Fprintf(g.buf, "%ssize += %d;\n", inner, g.sizeOfPrimitive(typ.Base))
+	case *schema.StructType, *schema.UnionType:
+		fmt.Fprintf(g.buf, "%ssize += %s.ComputeMaxSize();\n", inner, expr)
+	case *schema.ArrayType:
+		elemVar := g.uniqueVar("elem")
+		fmt.Fprintf(g.buf, "%ssize += %d;\n", inner, schema.LengthPrefixSize(typ))
+		body := g.openNullCheck(t, expr, inner)
+		fmt.Fprintf(g.buf, "%sforeach (var %s in %s)\n", body, elemVar, expr)
+		fmt.Fprintf(g.buf, "%s{\n", body)
+		g.generateMapValueMaxSize(typ.ElementType, elemVar, body+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", body)
+		g.closeNullCheck(t, inner)
+	case *schema.MapType:
+		entryVar := g.uniqueVar("entry")
+		keyVar := g.uniqueVar("key")
+		valVar := g.uniqueVar("val")
+		fmt.Fprintf(g.buf, "%ssize += %d;\n", inner, schema.LengthPrefixSize(typ))
+		body := g.openNullCheck(t, expr, inner)
+		fmt.Fprintf(g.buf, "%sforeach (var %s in %s)\n", body, entryVar, expr)
+		fmt.Fprintf(g.buf, "%s{\n", body)
+		fmt.Fprintf(g.buf, "%s    var %s = %s.Key;\n", body, keyVar, entryVar)
+		fmt.Fprintf(g.buf, "%s    var %s = %s.Value;\n", body, valVar, entryVar)
+		g.generateMapValueMaxSize(typ.KeyType, keyVar, body+"    ")
+		g.generateMapValueMaxSize(typ.ValueType, valVar, body+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", body)
+		g.closeNullCheck(t, inner)
+	}
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+func (g *csharpGenerator) generateMapValueEncode(t schema.Type, expr, indent string) {
+	inner := indent
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%sif (%s != null)\n", indent, expr)
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		fmt.Fprintf(g.buf, "%s    buffer[offset++] = 1;\n", indent)
+		inner += "    "
+		if g.isNullableValue(t) {
+			expr += ".Value"
+		}
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		g.generatePrimitiveEncodeNoCache(expr, typ.Name, inner)
+	case *schema.EnumType:
+		g.generatePrimitiveEncodeNoCache(fmt.Sprintf("(%s)%s", g.csharpBaseType(typ.Base), expr), typ.Base, inner)
+	case *schema.StructType, *schema.UnionType:
+		fmt.Fprintf(g.buf, "%s%s.EncodeTo(buffer, ref offset);\n", inner, expr)
+	case *schema.ArrayType:
+		elemVar := g.uniqueVar("elem")
+		g.writeLength("("+expr+"?.Length ?? 0)", typ, inner)
+		body := g.openNullCheck(t, expr, inner)
+		fmt.Fprintf(g.buf, "%sforeach (var %s in %s)\n", body, elemVar, expr)
+		fmt.Fprintf(g.buf, "%s{\n", body)
+		g.generateMapValueEncode(typ.ElementType, elemVar, body+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", body)
+		g.closeNullCheck(t, inner)
+	case *schema.MapType:
+		keysVar := g.uniqueVar("keys")
+		keyVar := g.uniqueVar("key")
+		valVar := g.uniqueVar("val")
+		key := typ.KeyType.(*schema.PrimitiveType)
+		g.writeLength("("+expr+"?.Count ?? 0)", typ, inner)
+		body := g.openNullCheck(t, expr, inner)
+		fmt.Fprintf(g.buf, "%svar %s = new List<%s>(%s.Keys);\n", body, keysVar, g.csharpType(key), expr)
+		if key.Name == "string" {
+			fmt.Fprintf(g.buf, "%s%s.Sort(FFireHelpers.CompareUtf8);\n", body, keysVar)
+		} else {
+			fmt.Fprintf(g.buf, "%s%s.Sort();\n", body, keysVar)
+		}
+		fmt.Fprintf(g.buf, "%sforeach (var %s in %s)\n", body, keyVar, keysVar)
+		fmt.Fprintf(g.buf, "%s{\n", body)
+		fmt.Fprintf(g.buf, "%s    var %s = %s[%s];\n", body, valVar, expr, keyVar)
+		g.generateMapValueEncode(key, keyVar, body+"    ")
+		g.generateMapValueEncode(typ.ValueType, valVar, body+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", body)
+		g.closeNullCheck(t, inner)
+	}
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+		fmt.Fprintf(g.buf, "%selse\n", indent)
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		fmt.Fprintf(g.buf, "%s    buffer[offset++] = 0;\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+// generateMapValueDecode decodes a value of type t into target, an
+// already declared variable or property.
+func (g *csharpGenerator) generateMapValueDecode(t schema.Type, target, indent string) {
+	inner := indent
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%sif (FFireDecode.ReadPresence(buffer, ref offset))\n", indent)
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		inner += "    "
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		fmt.Fprintf(g.buf, "%s%s = ", inner, target)
+		g.generatePrimitiveDecode(typ.Name)
+		g.buf.WriteString(";\n")
+	case *schema.EnumType:
+		rawVar := g.uniqueVar("raw")
+		fmt.Fprintf(g.buf, "%s%s %s = ", inner, g.csharpBaseType(typ.Base), rawVar)
+		g.generatePrimitiveDecode(typ.Base)
+		g.buf.WriteString(";\n")
+		fmt.Fprintf(g.buf, "%sif (!((%s)%s).IsValid()) throw new DecodeException(DecodeErrorCode.InvalidValue, $\"invalid %s value {%s}\");\n", inner, typ.Name, rawVar, typ.Name, rawVar)
+		fmt.Fprintf(g.buf, "%s%s = (%s)%s;\n", inner, target, typ.Name, rawVar)
+	case *schema.StructType:
+		fmt.Fprintf(g.buf, "%s%s = %s.DecodeFrom(buffer, ref offset);\n", inner, target, typ.Name)
+	case *schema.UnionType:
+		fmt.Fprintf(g.buf, "%s%s = %s.DecodeFrom(buffer, ref offset);\n", inner, target, typ.Name)
+	case *schema.ArrayType:
+		lenVar := g.uniqueVar("len")
+		arrVar := g.uniqueVar("arr")
+		idxVar := g.uniqueVar("i")
+		elemType := g.csharpValueType(typ.ElementType)
+		g.readLength(lenVar, typ, inner)
+		fmt.Fprintf(g.buf, "%svar %s = %s;\n", inner, arrVar, csharpNewArray(elemType, lenVar))
+		fmt.Fprintf(g.buf, "%sfor (int %s = 0; %s < %s; %s++)\n", inner, idxVar, idxVar, lenVar, idxVar)
+		fmt.Fprintf(g.buf, "%s{\n", inner)
+		g.generateMapValueDecode(typ.ElementType, arrVar+"["+idxVar+"]", inner+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", inner)
+		fmt.Fprintf(g.buf, "%s%s = %s;\n", inner, target, arrVar)
+	case *schema.MapType:
+		lenVar := g.uniqueVar("len")
+		mapVar := g.uniqueVar("map")
+		idxVar := g.uniqueVar("i")
+		keyVar := g.uniqueVar("key")
+		valVar := g.uniqueVar("val")
+		g.readLength(lenVar, typ, inner)
+		fmt.Fprintf(g.buf, "%svar %s = new %s(%s);\n", inner, mapVar, g.csharpType(&schema.MapType{KeyType: typ.KeyType, ValueType: typ.ValueType}), lenVar)
+		fmt.Fprintf(g.buf, "%sfor (int %s = 0; %s < %s; %s++)\n", inner, idxVar, idxVar, lenVar, idxVar)
+		fmt.Fprintf(g.buf, "%s{\n", inner)
+		fmt.Fprintf(g.buf, "%s    %s %s;\n", inner, g.csharpType(typ.KeyType), keyVar)
+		g.generateMapValueDecode(typ.KeyType, keyVar, inner+"    ")
+		fmt.Fprintf(g.buf, "%s    %s %s = default;\n", inner, g.csharpValueType(typ.ValueType), valVar)
+		g.generateMapValueDecode(typ.ValueType, valVar, inner+"    ")
+		fmt.Fprintf(g.buf, "%s    %s[%s] = %s;\n", inner, mapVar, keyVar, valVar)
+		fmt.Fprintf(g.buf, "%s}\n", inner)
+		fmt.Fprintf(g.buf, "%s%s = %s;\n", inner, target, mapVar)
+	}
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+// openNullCheck opens a null check on expr unless t is optional, in which
+// case the presence check already covers it, and returns the body indent.
+func (g *csharpGenerator) openNullCheck(t schema.Type, expr, indent string) string {
+	if t.IsOptional() {
+		return indent
+	}
+	fmt.Fprintf(g.buf, "%sif (%s != null)\n", indent, expr)
+	fmt.Fprintf(g.buf, "%s{\n", indent)
+	return indent + "    "
+}
+
+func (g *csharpGenerator) closeNullCheck(t schema.Type, indent string) {
+	if !t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+// isNullableValue reports whether t is held in a Nullable<T>, whose value
+// is reached through .Value.
+func (g *csharpGenerator) isNullableValue(t schema.Type) bool {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		return typ.Optional && !csharpIsReference(typ.Name)
+	case *schema.EnumType, *schema.StructType:
+		return typ.IsOptional()
+	}
+	return false
+}
+
+// csharpNewArray returns the creation expression for an array of length
+// elements of elemType. The length goes before the element's own rank
+// specifiers: an array of byte[] is new byte[n][].
+func csharpNewArray(elemType, length string) string {
+	base := strings.TrimRight(elemType, "[]")
+	if !strings.HasSuffix(elemType, "]") {
+		base = elemType
+	}
+	return fmt.Sprintf("new %s[%s]%s", base, length, elemType[len(base):])
+}
+
+// csharpIsReference reports whether primitive kind is held in a reference
+// type: nullable without Nullable<T> and length-prefixed on the wire.
+func csharpIsReference(kind string) bool {
+	return kind == "string" || kind == "bytes"
+}
+
+func isEnumOrUnion(t schema.Type) bool {
+	switch t.(type) {
+	case *schema.EnumType, *schema.UnionType:
+		return true
+	}
+	return false
+}
+
+// Views decode without copying: a view of a struct that holds strings or
+// bytes is a ref struct whose strings and bytes are ReadOnlySpan<byte>
+// slices of the decoded buffer, UTF-8 for strings. Arrays of them are
+// lists that enumerate spans or views, and optional structs are decoded
+// again on each access, so that an optional self-reference does not make
+// the view contain itself. Other fields are copied as Decode copies them.
+
+// findViews sets which structs have views: those that hold a string or
+// bytes, directly or through structs and arrays. Marking them until none
+// is left to mark follows optional self-references.
+func (g *csharpGenerator) findViews() {
+	var structs []*schema.StructType
+	for _, msg := range g.schema.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok {
+			structs = append(structs, st)
+		}
+	}
+	for _, t := range g.schema.Types {
+		if st, ok := t.(*schema.StructType); ok {
+			structs = append(structs, st)
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, st := range structs {
+			if g.views[st.Name] {
+				continue
+			}
+			for _, field := range st.Fields {
+				if g.csharpViewType(field.Type) != "" {
+					g.views[st.Name] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// hasView reports whether the struct named name has a view.
+func (g *csharpGenerator) hasView(name string) bool {
+	return g.views[name]
+}
+
+// csharpViewType returns the type a view holds t in, or "" if it copies t.
+func (g *csharpGenerator) csharpViewType(t schema.Type) string {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		if csharpIsReference(typ.Name) {
+			return "ReadOnlySpan<byte>"
+		}
+	case *schema.StructType:
+		if g.hasView(typ.Name) {
+			return typ.Name + "View"
+		}
+	case *schema.ArrayType:
+		if typ.Length > 0 || typ.ElementType.IsOptional() {
+			return ""
+		}
+		switch elem := typ.ElementType.(type) {
+		case *schema.PrimitiveType:
+			if csharpIsReference(elem.Name) {
+				return "FFireSpanList"
+			}
+		case *schema.StructType:
+			if g.hasView(elem.Name) {
+				return elem.Name + "ViewList"
+			}
+		}
+	}
+	return ""
+}
+
+// generateViews writes the views of the structs in sorted and of the
+// messages, and the lists of views that arrays hold. It reports whether it
+// wrote any.
+func (g *csharpGenerator) generateViews(sorted []string, messageTypes map[string]bool) bool {
+	g.findViews()
+	wrote := false
+	for _, name := range sorted {
+		if !messageTypes[name] && g.hasView(name) {
+			g.generateStructView(g.findStructType(name), name+"View")
+			wrote = true
+		}
+	}
+	for _, msg := range g.schema.Messages {
+		switch typ := msg.TargetType.(type) {
+		case *schema.StructType:
+			if g.hasView(typ.Name) {
+				g.generateStructView(typ, msg.Name+"MessageView")
+				wrote = true
+			}
+		case *schema.ArrayType:
+			if listType := g.csharpViewType(typ); listType != "" {
+				g.generateArrayMessageView(msg.Name+"MessageView", typ, listType)
+				wrote = true
+			}
+		}
+	}
+	for _, name := range sorted {
+		if g.viewLists[name] {
+			g.generateViewList(name)
+		}
+	}
+	if g.spanLists {
+		g.generateSpanList()
+	}
+	return wrote
+}
+
+// generateViewTryDecode writes the TryDecode of view viewName, for
+// the value of typeName.
+func (g *csharpGenerator) generateViewTryDecode(viewName, typeName string) {
+	fmt.Fprintf(g.buf, "        /// <summary>Decodes data like %s.TryDecode, without copying strings and bytes out of it.</summary>\n", typeName)
+	fmt.Fprintf(g.buf, "        public static bool TryDecode(ReadOnlySpan<byte> data, out %s value)\n", viewName)
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int offset = 0;\n")
+	g.buf.WriteString("            try\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                value = DecodeFrom(data, ref offset);\n")
+	g.buf.WriteString("                return offset == data.Length;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            catch (Exception e) when (e is DecodeException || e is ArgumentOutOfRangeException || e is IndexOutOfRangeException)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                value = default;\n")
+	g.buf.WriteString("                return false;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n\n")
+}
+
+func (g *csharpGenerator) generateStructView(st *schema.StructType, viewName string) {
+	typeName := strings.TrimSuffix(viewName, "View")
+	fmt.Fprintf(g.buf, "    /// <summary>Zero-copy view of a %s: strings (UTF-8) and bytes are spans of the decoded data, which must not change while the view is in use.</summary>\n", typeName)
+	fmt.Fprintf(g.buf, "    public ref struct %s\n", viewName)
+	g.buf.WriteString("    {\n")
+	for _, field := range st.Fields {
+		fieldName := g.toPascalCase(field.Name)
+		viewType := g.csharpViewType(field.Type)
+		if viewType == "" {
+			csType := g.csharpType(field.Type)
+			if sub, ok := field.Type.(*schema.StructType); ok && sub.Optional {
+				csType = g.csharpValueType(sub)
+			}
+			fmt.Fprintf(g.buf, "        public %s %s { get; private set; }\n", csType, fieldName)
+			continue
+		}
+		if !field.Type.IsOptional() {
+			fmt.Fprintf(g.buf, "        public %s %s { get; private set; }\n", viewType, fieldName)
+			continue
+		}
+		fmt.Fprintf(g.buf, "        public bool Has%s { get; private set; }\n", fieldName)
+		if _, ok := field.Type.(*schema.StructType); ok {
+			private := "_" + g.toCamelCase(field.Name)
+			fmt.Fprintf(g.buf, "        private ReadOnlySpan<byte> %s;\n", private)
+			fmt.Fprintf(g.buf, "        /// <summary>Decoded from the data on each access; default unless Has%s.</summary>\n", fieldName)
+			fmt.Fprintf(g.buf, "        public %s %s { get { int offset = 0; return Has%s ? %s.DecodeFrom(%s, ref offset) : default; } }\n", viewType, fieldName, fieldName, viewType, private)
+			continue
+		}
+		fmt.Fprintf(g.buf, "        public %s %s { get; private set; }\n", viewType, fieldName)
+	}
+	g.buf.WriteString("\n")
+
+	g.generateViewTryDecode(viewName, typeName)
+
+	fmt.Fprintf(g.buf, "        internal static %s DecodeFrom(ReadOnlySpan<byte> buffer, scoped ref int offset)\n", viewName)
+	g.buf.WriteString("        {\n")
+	fmt.Fprintf(g.buf, "            var obj = new %s();\n", viewName)
+	bits := g.generateReadBitmap(st)
+	for _, field := range st.Fields {
+		test, bitmapped := bits[field.Name]
+		if g.csharpViewType(field.Type) != "" {
+			g.generateViewDecodeField(&field, test)
+		} else if bitmapped {
+			g.generateBitmappedDecode(&field, test)
+		} else {
+			g.generateDecodeField(&field)
+		}
+	}
+	g.buf.WriteString("            return obj;\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+}
+
+// generateViewDecodeField decodes a field a view borrows. An optional
+// field is present if test, the presence bit of a @bitmap struct, is set,
+// or else if its presence flag is.
+func (g *csharpGenerator) generateViewDecodeField(field *schema.Field, test string) {
+	fieldName := g.toPascalCase(field.Name)
+	indent := "            "
+	if field.Type.IsOptional() {
+		if test == "" {
+			test = "FFireDecode.ReadPresence(buffer, ref offset)"
+		}
+		fmt.Fprintf(g.buf, "%sif (%s)\n", indent, test)
+		fmt.Fprintf(g.buf, "%s{\n", indent)
+		indent += "    "
+		fmt.Fprintf(g.buf, "%sobj.Has%s = true;\n", indent, fieldName)
+	}
+	switch typ := field.Type.(type) {
+	case *schema.PrimitiveType:
+		read := "ReadSpan"
+		if typ.Large {
+			read = "ReadLargeSpan"
+		}
+		fmt.Fprintf(g.buf, "%sobj.%s = FFireHelpers.%s(buffer, ref offset);\n", indent, fieldName, read)
+	case *schema.StructType:
+		if !typ.Optional {
+			fmt.Fprintf(g.buf, "%sobj.%s = %sView.DecodeFrom(buffer, ref offset);\n", indent, fieldName, typ.Name)
+			break
+		}
+		// Decoded once to find its end, and again on each access
+		startVar := g.uniqueVar("start")
+		fmt.Fprintf(g.buf, "%sint %s = offset;\n", indent, startVar)
+		fmt.Fprintf(g.buf, "%s%sView.DecodeFrom(buffer, ref offset);\n", indent, typ.Name)
+		fmt.Fprintf(g.buf, "%sobj._%s = buffer.Slice(%s, offset - %s);\n", indent, g.toCamelCase(field.Name), startVar, startVar)
+	case *schema.ArrayType:
+		lenVar := g.uniqueVar("len")
+		g.readLength(lenVar, typ, indent)
+		fmt.Fprintf(g.buf, "%sobj.%s = %s;\n", indent, fieldName, g.viewListDecode(typ, lenVar))
+	}
+	if field.Type.IsOptional() {
+		fmt.Fprintf(g.buf, "            }\n")
+	}
+}
+
+// viewListDecode returns the expression that decodes the list view of
+// array, whose length has been read into lenVar.
+func (g *csharpGenerator) viewListDecode(array *schema.ArrayType, lenVar string) string {
+	if st, ok := array.ElementType.(*schema.StructType); ok {
+		g.viewLists[st.Name] = true
+		return fmt.Sprintf("%sViewList.DecodeFrom(buffer, ref offset, %s)", st.Name, lenVar)
+	}
+	g.spanLists = true
+	return fmt.Sprintf("FFireSpanList.DecodeFrom(buffer, ref offset, %s, %d)", lenVar, schema.LengthPrefixSize(array.ElementType))
+}
+
+func (g *csharpGenerator) generateArrayMessageView(viewName string, array *schema.ArrayType, listType string) {
+	typeName := strings.TrimSuffix(viewName, "View")
+	fmt.Fprintf(g.buf, "    /// <summary>Zero-copy view of a %s: its items are spans or views of the decoded data, which must not change while the view is in use.</summary>\n", typeName)
+	fmt.Fprintf(g.buf, "    public ref struct %s\n", viewName)
+	g.buf.WriteString("    {\n")
+	fmt.Fprintf(g.buf, "        public %s Items { get; private set; }\n\n", listType)
+	g.generateViewTryDecode(viewName, typeName)
+	fmt.Fprintf(g.buf, "        internal static %s DecodeFrom(ReadOnlySpan<byte> buffer, scoped ref int offset)\n", viewName)
+	g.buf.WriteString("        {\n")
+	fmt.Fprintf(g.buf, "            var obj = new %s();\n", viewName)
+	g.readLength("length", array, "            ")
+	fmt.Fprintf(g.buf, "            obj.Items = %s;\n", g.viewListDecode(array, "length"))
+	g.buf.WriteString("            return obj;\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+}
+
+// generateViewList writes the list of the views of the struct named name,
+// which decodes each view again as it enumerates it.
+func (g *csharpGenerator) generateViewList(name string) {
+	fmt.Fprintf(g.buf, "    /// <summary>Zero-copy view of an array of %s, enumerated as views.</summary>\n", name)
+	fmt.Fprintf(g.buf, "    public ref struct %sViewList\n", name)
+	g.buf.WriteString("    {\n")
+	g.buf.WriteString("        private readonly ReadOnlySpan<byte> _data;\n")
+	g.buf.WriteString("        public int Count { get; }\n\n")
+	fmt.Fprintf(g.buf, "        internal %sViewList(ReadOnlySpan<byte> data, int count)\n", name)
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            _data = data;\n")
+	g.buf.WriteString("            Count = count;\n")
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        public Enumerator GetEnumerator() => new Enumerator(_data, Count);\n\n")
+	fmt.Fprintf(g.buf, "        internal static %sViewList DecodeFrom(ReadOnlySpan<byte> buffer, scoped ref int offset, int count)\n", name)
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int start = offset;\n")
+	g.buf.WriteString("            for (int i = 0; i < count; i++)\n")
+	g.buf.WriteString("            {\n")
+	fmt.Fprintf(g.buf, "                %sView.DecodeFrom(buffer, ref offset);\n", name)
+	g.buf.WriteString("            }\n")
+	fmt.Fprintf(g.buf, "            return new %sViewList(buffer.Slice(start, offset - start), count);\n", name)
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        public ref struct Enumerator\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            private readonly ReadOnlySpan<byte> _data;\n")
+	g.buf.WriteString("            private int _remaining;\n")
+	g.buf.WriteString("            private int _offset;\n\n")
+	g.buf.WriteString("            internal Enumerator(ReadOnlySpan<byte> data, int count)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                _data = data;\n")
+	g.buf.WriteString("                _remaining = count;\n")
+	g.buf.WriteString("                _offset = 0;\n")
+	g.buf.WriteString("                Current = default;\n")
+	g.buf.WriteString("            }\n\n")
+	fmt.Fprintf(g.buf, "            public %sView Current { get; private set; }\n\n", name)
+	g.buf.WriteString("            public bool MoveNext()\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                if (_remaining == 0) return false;\n")
+	g.buf.WriteString("                _remaining--;\n")
+	fmt.Fprintf(g.buf, "                Current = %sView.DecodeFrom(_data, ref _offset);\n", name)
+	g.buf.WriteString("                return true;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+}
+
+// generateSpanList writes FFireSpanList, the view of an array of strings
+// or bytes.
+func (g *csharpGenerator) generateSpanList() {
+	g.buf.WriteString("    /// <summary>Zero-copy view of an array of strings (UTF-8) or bytes, enumerated as spans of the decoded data.</summary>\n")
+	g.buf.WriteString("    public ref struct FFireSpanList\n")
+	g.buf.WriteString("    {\n")
+	g.buf.WriteString("        private readonly ReadOnlySpan<byte> _data;\n")
+	g.buf.WriteString("        private readonly int _prefix;\n")
+	g.buf.WriteString("        public int Count { get; }\n\n")
+	g.buf.WriteString("        internal FFireSpanList(ReadOnlySpan<byte> data, int count, int prefix)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            _data = data;\n")
+	g.buf.WriteString("            _prefix = prefix;\n")
+	g.buf.WriteString("            Count = count;\n")
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        public Enumerator GetEnumerator() => new Enumerator(_data, Count, _prefix);\n\n")
+	g.buf.WriteString("        /// <summary>Copies the items out of the data, as strings.</summary>\n")
+	g.buf.WriteString("        public string[] ToStringArray()\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            var result = new string[Count];\n")
+	g.buf.WriteString("            int i = 0;\n")
+	g.buf.WriteString("            foreach (var item in this)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                result[i++] = Encoding.UTF8.GetString(item);\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            return result;\n")
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        internal static FFireSpanList DecodeFrom(ReadOnlySpan<byte> buffer, scoped ref int offset, int count, int prefix)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int start = offset;\n")
+	g.buf.WriteString("            for (int i = 0; i < count; i++)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                FFireHelpers.ReadSpan(buffer, ref offset, prefix);\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            return new FFireSpanList(buffer.Slice(start, offset - start), count, prefix);\n")
+	g.buf.WriteString("        }\n\n")
+	g.buf.WriteString("        public ref struct Enumerator\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            private readonly ReadOnlySpan<byte> _data;\n")
+	g.buf.WriteString("            private readonly int _prefix;\n")
+	g.buf.WriteString("            private int _remaining;\n")
+	g.buf.WriteString("            private int _offset;\n\n")
+	g.buf.WriteString("            internal Enumerator(ReadOnlySpan<byte> data, int count, int prefix)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                _data = data;\n")
+	g.buf.WriteString("                _prefix = prefix;\n")
+	g.buf.WriteString("                _remaining = count;\n")
+	g.buf.WriteString("                _offset = 0;\n")
+	g.buf.WriteString("                Current = default;\n")
+	g.buf.WriteString("            }\n\n")
+	g.buf.WriteString("            public ReadOnlySpan<byte> Current { get; private set; }\n\n")
+	g.buf.WriteString("            public bool MoveNext()\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                if (_remaining == 0) return false;\n")
+	g.buf.WriteString("                _remaining--;\n")
+	g.buf.WriteString("                Current = FFireHelpers.ReadSpan(_data, ref _offset, _prefix);\n")
+	g.buf.WriteString("                return true;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+}
+
+// generateViewHelpers writes the FFireHelpers members that views read
+// strings and bytes with.
+func (g *csharpGenerator) generateViewHelpers(usesLarge bool) {
+	g.buf.WriteString("\n")
+	g.buf.WriteString("        // Returns the string or bytes at offset as a slice of buffer\n")
+	g.buf.WriteString("        internal static ReadOnlySpan<byte> ReadSpan(ReadOnlySpan<byte> buffer, scoped ref int offset, int prefix = 2)\n")
+	g.buf.WriteString("        {\n")
+	g.buf.WriteString("            int length;\n")
+	g.buf.WriteString("            if (prefix == 2)\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                length = BinaryPrimitives.ReadUInt16LittleEndian(buffer.Slice(offset, 2));\n")
+	g.buf.WriteString("                offset += 2;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            else\n")
+	g.buf.WriteString("            {\n")
+	g.buf.WriteString("                uint large = BinaryPrimitives.ReadUInt32LittleEndian(buffer.Slice(offset, 4));\n")
+	g.buf.WriteString("                offset += 4;\n")
+	g.buf.WriteString("                if (large > (uint)(buffer.Length - offset)) throw new DecodeException(DecodeErrorCode.Overflow, $\"length {large} exceeds the remaining {buffer.Length - offset} bytes\");\n")
+	g.buf.WriteString("                length = (int)large;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("            ReadOnlySpan<byte> result = buffer.Slice(offset, length);\n")
+	g.buf.WriteString("            offset += length;\n")
+	g.buf.WriteString("            return result;\n")
+	g.buf.WriteString("        }\n")
+	if usesLarge {
+		g.buf.WriteString("\n")
+		g.buf.WriteString("        internal static ReadOnlySpan<byte> ReadLargeSpan(ReadOnlySpan<byte> buffer, scoped ref int offset) => ReadSpan(buffer, ref offset, 4);\n")
+	}
+}
@@ -43,17 +43,19 @@ func generateDartMetadataOrchestrated(config *PackageConfig, paths *PackagePaths
 }
 
 func printDartInstructions(config *PackageConfig, paths *PackagePaths) {
-	fmt.Printf("\n✅ Dart package ready at: %s\n\n", paths.Root)
-	fmt.Println("Build:")
-	fmt.Printf("  cd %s\n", paths.Root)
-	fmt.Println("  dart pub get")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Printf("  import 'package:%s/%s.dart';\n\n", config.Namespace, config.Namespace)
-	fmt.Println("  final data = await File('data.bin').readAsBytes();")
-	fmt.Println("  final msg = Message.decode(data);")
-	fmt.Println("  final encoded = msg.encode();")
-	fmt.Println()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Dart package ready at: %s\n\n", paths.Root)
+	fmt.Fprintln(&b, "Build:")
+	fmt.Fprintf(&b, "  cd %s\n", paths.Root)
+	fmt.Fprintln(&b, "  dart pub get")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  import 'package:%s/%s.dart';\n\n", valueOr(config.Coordinates.Pub, config.Namespace), config.Namespace)
+	fmt.Fprintln(&b, "  final data = await File('data.bin').readAsBytes();")
+	fmt.Fprintln(&b, "  final msg = Message.decode(data);")
+	fmt.Fprintln(&b, "  final encoded = msg.encode();")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
 }
 
 func generateDartFiles(config *PackageConfig, libDir, nativeLibDir string) error {
@@ -70,10 +72,13 @@ func generateDartFiles(config *PackageConfig, libDir, nativeLibDir string) error
 	buf.WriteString("import 'dart:typed_data';\n")
 	buf.WriteString("import 'package:ffi/ffi.dart';\n\n")
 
-	// Exception class
+	buf.WriteString(dartDecodeErrorCode())
+
+	// Exception class; code is a DecodeErrorCode for decode failures
 	fmt.Fprintf(buf, "class %sException implements Exception {\n", ToPascalCase(packageName))
 	buf.WriteString("  final String message;\n")
-	fmt.Fprintf(buf, "  %sException(this.message);\n", ToPascalCase(packageName))
+	buf.WriteString("  final int code;\n")
+	fmt.Fprintf(buf, "  %sException(this.message, [this.code = 0]);\n", ToPascalCase(packageName))
 	buf.WriteString("  @override\n")
 	buf.WriteString("  String toString() => message;\n")
 	buf.WriteString("}\n\n")
@@ -90,7 +95,10 @@ func generateDartFiles(config *PackageConfig, libDir, nativeLibDir string) error
 	fmt.Fprintf(buf, "      return DynamicLibrary.open('lib/%s.dll');\n", config.Schema.Package)
 	buf.WriteString("    }\n")
 	buf.WriteString("    throw UnsupportedError('Platform not supported');\n")
-	buf.WriteString("  }\n")
+	buf.WriteString("  }\n\n")
+	buf.WriteString("  static final decodeErrorCode = _lib\n")
+	fmt.Fprintf(buf, "      .lookup<NativeFunction<Int32 Function()>>('%s_decode_error_code')\n", config.Schema.Package)
+	buf.WriteString("      .asFunction<int Function()>();\n")
 	buf.WriteString("}\n\n")
 
 	// Generate bindings for each message type
@@ -105,7 +113,7 @@ func generateDartFiles(config *PackageConfig, libDir, nativeLibDir string) error
 		return fmt.Errorf("failed to write Dart library: %w", err)
 	}
 
-	fmt.Printf("✓ Generated %s.dart\n", packageName)
+	config.infof("✓ Generated %s.dart\n", packageName)
 	return nil
 }
 
@@ -142,6 +150,7 @@ func generateDartMessageBindings(buf *bytes.Buffer, s *schema.Schema, msg *schem
 	buf.WriteString("}\n\n")
 
 	// Message class
+	buf.WriteString(lineDoc("", "///", msg.Doc))
 	fmt.Fprintf(buf, "class %s {\n", className)
 	buf.WriteString("  Pointer<Void>? _handle;\n")
 	buf.WriteString("  bool _disposed = false;\n\n")
@@ -169,7 +178,7 @@ func generateDartMessageBindings(buf *bytes.Buffer, s *schema.Schema, msg *schem
 	buf.WriteString("      }\n")
 	buf.WriteString("      malloc.free(errorPtr);\n")
 	buf.WriteString("      throw ")
-	fmt.Fprintf(buf, "%sException('Decode failed: $errMsg');\n", ToPascalCase(packageName))
+	fmt.Fprintf(buf, "%sException('Decode failed: $errMsg', _NativeLibrary.decodeErrorCode());\n", ToPascalCase(packageName))
 	buf.WriteString("    }\n\n")
 
 	buf.WriteString("    malloc.free(errorPtr);\n")
@@ -226,11 +235,11 @@ func generateDartMessageBindings(buf *bytes.Buffer, s *schema.Schema, msg *schem
 
 func generateDartPubspec(config *PackageConfig, dartDir string) error {
 	buf := &bytes.Buffer{}
-	packageName := config.Namespace
+	packageName := valueOr(config.Coordinates.Pub, config.Namespace)
 
 	fmt.Fprintf(buf, "name: %s\n", packageName)
 	buf.WriteString("description: Dart FFI bindings for ffire schema\n")
-	buf.WriteString("version: 1.0.0\n\n")
+	fmt.Fprintf(buf, "version: %s\n\n", config.Version)
 
 	buf.WriteString("environment:\n")
 	buf.WriteString("  sdk: '>=2.17.0 <4.0.0'\n\n")
@@ -243,7 +252,7 @@ func generateDartPubspec(config *PackageConfig, dartDir string) error {
 		return fmt.Errorf("failed to write pubspec.yaml: %w", err)
 	}
 
-	fmt.Println("✓ Generated pubspec.yaml")
+	config.infof("✓ Generated pubspec.yaml")
 	return nil
 }
 
@@ -258,7 +267,7 @@ func generateDartReadme(config *PackageConfig, dartDir string) error {
 	buf.WriteString("Add to your `pubspec.yaml`:\n\n")
 	buf.WriteString("```yaml\n")
 	buf.WriteString("dependencies:\n")
-	fmt.Fprintf(buf, "  %s:\n", packageName)
+	fmt.Fprintf(buf, "  %s:\n", valueOr(config.Coordinates.Pub, packageName))
 	buf.WriteString("    path: ../path/to/package\n")
 	buf.WriteString("```\n\n")
 
@@ -273,7 +282,7 @@ func generateDartReadme(config *PackageConfig, dartDir string) error {
 
 	buf.WriteString("## Usage\n\n")
 	buf.WriteString("```dart\n")
-	fmt.Fprintf(buf, "import 'package:%s/%s.dart';\n", packageName, packageName)
+	fmt.Fprintf(buf, "import 'package:%s/%s.dart';\n", valueOr(config.Coordinates.Pub, packageName), packageName)
 	buf.WriteString("import 'dart:io';\n\n")
 
 	buf.WriteString("void main() async {\n")
@@ -308,13 +317,13 @@ func generateDartReadme(config *PackageConfig, dartDir string) error {
 	buf.WriteString("  Free native resources. Always call when done.\n\n")
 
 	buf.WriteString("## License\n\n")
-	buf.WriteString("Generated by FFireGenerator\n")
+	buf.WriteString(readmeLicense(config, "Generated by FFireGenerator\n"))
 
 	filePath := filepath.Join(dartDir, "README.md")
 	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write README.md: %w", err)
 	}
 
-	fmt.Println("✓ Generated README.md")
+	config.infof("✓ Generated README.md")
 	return nil
 }
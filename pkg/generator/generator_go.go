@@ -11,16 +11,63 @@ import (
 
 // GenerateGo generates Go encoder/decoder code.
 func GenerateGo(s *schema.Schema) ([]byte, error) {
+	return GenerateGoWithOptions(s, GoOptions{})
+}
+
+// GenerateGoUnformatted generates the same code as GenerateGo without
+// running it through go/format, which dominates generation time for large
+// schemas. The output compiles but is not gofmt-clean.
+func GenerateGoUnformatted(s *schema.Schema) ([]byte, error) {
+	return GenerateGoWithOptions(s, GoOptions{NoFormat: true})
+}
+
+// GoOptions select optional code paths in generated Go code.
+type GoOptions struct {
+	// NoFormat skips go/format (see GenerateGoUnformatted).
+	NoFormat bool
+
+	// SharedRuntime is the import path of a package written by
+	// GenerateGoRuntime. The code then imports the encode buffer pool and
+	// lenient decoding from it instead of defining its own copy, so the
+	// packages of several schemas in one program share them.
+	SharedRuntime string
+
+	// Borrowed also generates DecodeBorrowed methods, whose strings and
+	// byte slices point into the input instead of copying it.
+	Borrowed bool
+}
+
+// GenerateGoWithOptions generates Go encoder/decoder code with opts.
+func GenerateGoWithOptions(s *schema.Schema, opts GoOptions) ([]byte, error) {
 	// Canonicalize field order for optimal wire format
 	s.Canonicalize()
-	gen := &goGenerator{schema: s, buf: &bytes.Buffer{}}
-	return gen.generate()
+	gen := &goGenerator{schema: s, buf: &bytes.Buffer{}, opts: opts}
+	code, err := gen.generate()
+	if err != nil || opts.NoFormat {
+		return code, err
+	}
+
+	formatted, err := format.Source(code)
+	if err != nil {
+		// Return unformatted code with error for debugging
+		return code, fmt.Errorf("format go code: %w", err)
+	}
+	return formatted, nil
 }
 
 type goGenerator struct {
 	schema     *schema.Schema
 	buf        *bytes.Buffer
+	opts       GoOptions
 	varCounter int
+	borrow     bool // Decoding for DecodeBorrowed: strings and bytes alias the data
+	lenient    bool // Decoding a field of a lenient decoder: running out of data panics
+
+	// The next optional value is a field of a @bitmap struct: bitmapped
+	// drops its presence byte when sizing and encoding, and presentBit is
+	// the bitmap test that decoding reads instead of the byte
+	bitmapped  bool
+	presentBit string
 }
 
 func (g *goGenerator) uniqueVar(prefix string) string {
@@ -47,6 +94,15 @@ func (g *goGenerator) typeContainsString(typ schema.Type) bool {
 		return t.Name == "string"
 	case *schema.ArrayType:
 		return g.typeContainsString(t.ElementType)
+	case *schema.MapType:
+		return g.typeContainsString(t.KeyType) || g.typeContainsString(t.ValueType)
+	case *schema.UnionType:
+		for _, v := range t.Variants {
+			if g.typeContainsString(v) {
+				return true
+			}
+		}
+		return false
 	case *schema.StructType:
 		for _, field := range t.Fields {
 			if g.typeContainsString(field.Type) {
@@ -90,7 +146,20 @@ func (g *goGenerator) typeContainsFloat(typ schema.Type) bool {
 	case *schema.PrimitiveType:
 		return t.Name == "float32" || t.Name == "float64"
 	case *schema.ArrayType:
+		// Arrays of plain numbers are copied in bulk without math
+		if prim, ok := t.ElementType.(*schema.PrimitiveType); ok && !prim.Optional {
+			return false
+		}
 		return g.typeContainsFloat(t.ElementType)
+	case *schema.MapType:
+		return g.typeContainsFloat(t.ValueType)
+	case *schema.UnionType:
+		for _, v := range t.Variants {
+			if g.typeContainsFloat(v) {
+				return true
+			}
+		}
+		return false
 	case *schema.StructType:
 		for _, field := range t.Fields {
 			if g.typeContainsFloat(field.Type) {
@@ -121,11 +190,20 @@ func (g *goGenerator) typeContainsPrimitiveArray(typ schema.Type) bool {
 	case *schema.ArrayType:
 		// Check if this is an array of non-optional, non-string primitives (uses unsafe)
 		// Bool arrays CAN use unsafe bulk copy since Go's bool memory layout (0x00/0x01) matches wire format
-		if primType, ok := t.ElementType.(*schema.PrimitiveType); ok && !primType.Optional && primType.Name != "string" {
+		if primType, ok := t.ElementType.(*schema.PrimitiveType); ok && !primType.Optional && primType.Name != "string" && primType.Name != "bytes" {
 			return true
 		}
 		// Recursively check element type
 		return g.typeContainsPrimitiveArray(t.ElementType)
+	case *schema.MapType:
+		return g.typeContainsPrimitiveArray(t.ValueType)
+	case *schema.UnionType:
+		for _, v := range t.Variants {
+			if g.typeContainsPrimitiveArray(v) {
+				return true
+			}
+		}
+		return false
 	case *schema.StructType:
 		for _, field := range t.Fields {
 			if g.typeContainsPrimitiveArray(field.Type) {
@@ -138,36 +216,82 @@ func (g *goGenerator) typeContainsPrimitiveArray(typ schema.Type) bool {
 	}
 }
 
-// schemaHasBulkEncodableStructs returns true if any struct has a fixed-field run >= 8 bytes
-func (g *goGenerator) schemaHasBulkEncodableStructs() bool {
+// schemaUsesBinary reports whether the generated code calls encoding/binary:
+// encoders write every value wider than a byte and every length prefix
+// with it.
+func (g *goGenerator) schemaUsesBinary() bool {
 	for _, msg := range g.schema.Messages {
-		if g.typeHasBulkEncodableStruct(msg.TargetType) {
+		if g.typeUsesBinary(msg.TargetType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *goGenerator) typeUsesBinary(t schema.Type) bool {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		return schema.PrimitiveSize(typ.Name) != 1
+	case *schema.EnumType:
+		return schema.PrimitiveSize(typ.Base) != 1
+	case *schema.ArrayType, *schema.MapType:
+		return true
+	case *schema.StructType:
+		if typ.Framed {
+			return true
+		}
+		for _, field := range typ.Fields {
+			if g.typeUsesBinary(field.Type) {
+				return true
+			}
+		}
+	case *schema.UnionType:
+		for _, v := range typ.Variants {
+			if g.typeUsesBinary(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schemaHasSortedMaps reports whether encoding needs sort.Slice: maps
+// with bool keys are written false-then-true without sorting.
+func (g *goGenerator) schemaHasSortedMaps() bool {
+	for _, msg := range g.schema.Messages {
+		if g.typeHasSortedMap(msg.TargetType) {
 			return true
 		}
 	}
 	for _, t := range g.schema.Types {
-		if g.typeHasBulkEncodableStruct(t) {
+		if g.typeHasSortedMap(t) {
 			return true
 		}
 	}
 	return false
 }
 
-func (g *goGenerator) typeHasBulkEncodableStruct(t schema.Type) bool {
+func (g *goGenerator) typeHasSortedMap(t schema.Type) bool {
 	switch typ := t.(type) {
 	case *schema.StructType:
-		runs := schema.GetFixedFieldRuns(typ.Fields)
-		if len(runs) > 0 && runs[0].TotalBytes >= 8 && runs[0].StartIndex == 0 {
-			return true
-		}
-		// Check nested structs
 		for _, field := range typ.Fields {
-			if g.typeHasBulkEncodableStruct(field.Type) {
+			if g.typeHasSortedMap(field.Type) {
 				return true
 			}
 		}
 	case *schema.ArrayType:
-		return g.typeHasBulkEncodableStruct(typ.ElementType)
+		return g.typeHasSortedMap(typ.ElementType)
+	case *schema.MapType:
+		if key, ok := typ.KeyType.(*schema.PrimitiveType); ok && key.Name != "bool" {
+			return true
+		}
+		return g.typeHasSortedMap(typ.ValueType)
+	case *schema.UnionType:
+		for _, v := range typ.Variants {
+			if g.typeHasSortedMap(v) {
+				return true
+			}
+		}
 	}
 	return false
 }
@@ -181,9 +305,14 @@ func (g *goGenerator) generate() ([]byte, error) {
 
 	// Imports
 	g.buf.WriteString("import (\n")
-	g.buf.WriteString("\"bytes\"\n")
-	// Import encoding/binary for bulk struct encoding
-	if g.schemaHasBulkEncodableStructs() {
+	// Import fmt for enum names and decode errors
+	g.buf.WriteString("\"fmt\"\n")
+	// Import errors for the sentinel errors of lenient decoding
+	if g.hasStructMessage() && g.opts.SharedRuntime == "" {
+		g.buf.WriteString("\"errors\"\n")
+	}
+	// Import encoding/binary for multi-byte values and length prefixes
+	if g.schemaUsesBinary() {
 		g.buf.WriteString("\"encoding/binary\"\n")
 	}
 	// Only import math if schema contains floats that need math.Float*bits
@@ -192,17 +321,56 @@ func (g *goGenerator) generate() ([]byte, error) {
 		g.buf.WriteString("\"math\"\n")
 	}
 	// Import unsafe for zero-copy array encoding (reinterpret []T as []byte)
-	if g.schemaHasPrimitiveArrays() {
+	// Import sort to write map entries in key order
+	if g.schemaHasSortedMaps() {
+		g.buf.WriteString("\"sort\"\n")
+	}
+	if g.schemaHasPrimitiveArrays() || (g.opts.Borrowed && g.schemaHasStrings()) {
 		g.buf.WriteString("\"unsafe\"\n")
 	}
+	// Import unicode/utf8 to validate decoded strings
+	if g.schemaHasStrings() {
+		g.buf.WriteString("\"unicode/utf8\"\n")
+	}
+	// Import sync for the encode buffer pool
+	if g.opts.SharedRuntime == "" {
+		g.buf.WriteString("\"sync\"\n")
+	}
+	if g.opts.SharedRuntime != "" {
+		fmt.Fprintf(g.buf, "\n%s %q\n", goRuntimeAlias, g.opts.SharedRuntime)
+	}
 	g.buf.WriteString(")\n\n")
 
+	for _, enum := range g.schema.Enums() {
+		g.generateEnum(enum)
+	}
+	for _, union := range g.schema.Unions() {
+		g.generateUnion(union)
+	}
+
+	// Generate helper type definitions (embedded/referenced types, no Message suffix)
+	// in the dependency order shared by all generators
+	for _, structType := range topSortStructTypes(g.schema.Types) {
+		// Skip if this is a root message type (generated below)
+		isRootType := false
+		for _, msg := range g.schema.Messages {
+			if st, ok := msg.TargetType.(*schema.StructType); ok && st.Name == structType.Name {
+				isRootType = true
+				break
+			}
+		}
+		if !isRootType {
+			g.generateStruct(structType)
+		}
+	}
+
 	// Generate root message type definitions with Message suffix
 	for _, msg := range g.schema.Messages {
 		if structType, ok := msg.TargetType.(*schema.StructType); ok {
 			g.generateMessageStruct(structType)
 		} else if arrayType, ok := msg.TargetType.(*schema.ArrayType); ok {
 			// Array type alias: type IntListMessage []int32 or *[]int32 for optional
+			g.buf.WriteString(lineDoc("", "//", msg.Doc))
 			elementTypeStr := g.goTypeString(arrayType.ElementType)
 			if arrayType.Optional {
 				fmt.Fprintf(g.buf, "type %sMessage *[]%s\n\n", msg.Name, elementTypeStr)
@@ -211,28 +379,12 @@ func (g *goGenerator) generate() ([]byte, error) {
 			}
 		} else {
 			// Primitive type alias: type Int32Message int32
+			g.buf.WriteString(lineDoc("", "//", msg.Doc))
 			typeStr := g.goTypeString(msg.TargetType)
 			fmt.Fprintf(g.buf, "type %sMessage %s\n\n", msg.Name, typeStr)
 		}
 	}
 
-	// Generate helper type definitions (embedded/referenced types, no Message suffix)
-	for _, typ := range g.schema.Types {
-		if structType, ok := typ.(*schema.StructType); ok {
-			// Skip if this is a root message type (already generated above)
-			isRootType := false
-			for _, msg := range g.schema.Messages {
-				if st, ok := msg.TargetType.(*schema.StructType); ok && st.Name == structType.Name {
-					isRootType = true
-					break
-				}
-			}
-			if !isRootType {
-				g.generateStruct(structType)
-			}
-		}
-	}
-
 	// Generate public message encode/decode functions
 	for _, msg := range g.schema.Messages {
 		g.generateMessageEncode(msg)
@@ -245,21 +397,87 @@ func (g *goGenerator) generate() ([]byte, error) {
 			g.generateStructHelpers(structType)
 		}
 	}
+	if g.opts.SharedRuntime != "" {
+		g.buf.WriteString(goSharedEncodeBuffer)
+		g.buf.WriteString(goSharedDecodeError())
+		if g.hasStructMessage() {
+			g.buf.WriteString(goSharedLenient)
+		}
+		return g.buf.Bytes(), nil
+	}
+	g.buf.WriteString(goEncodeBufferRuntime)
+	g.buf.WriteString(goDecodeErrorRuntime())
+	if g.hasStructMessage() {
+		g.buf.WriteString(goLenientRuntime)
+	}
 
-	// Format the code
-	formatted, err := format.Source(g.buf.Bytes())
-	if err != nil {
-		// Return unformatted code with error for debugging
-		return g.buf.Bytes(), fmt.Errorf("format go code: %w", err)
+	return g.buf.Bytes(), nil
+}
+
+// generateEnum declares a named integer type with its constants, IsValid
+// for decode checks and String for the constant name.
+func (g *goGenerator) generateEnum(enum *schema.EnumType) {
+	g.buf.WriteString(lineDoc("", "//", enum.Doc))
+	fmt.Fprintf(g.buf, "type %s %s\n\n", enum.Name, enum.Base)
+	g.buf.WriteString("const (\n")
+	for _, v := range enum.Values {
+		g.buf.WriteString(lineDoc("", "//", v.Doc))
+		fmt.Fprintf(g.buf, "%s %s = %d\n", v.Name, enum.Name, v.Value)
 	}
+	g.buf.WriteString(")\n\n")
 
-	return formatted, nil
+	names := make([]string, len(enum.Values))
+	for i, v := range enum.Values {
+		names[i] = v.Name
+	}
+	fmt.Fprintf(g.buf, "// IsValid reports whether v is one of the %s constants.\n", enum.Name)
+	fmt.Fprintf(g.buf, "func (v %s) IsValid() bool {\n", enum.Name)
+	fmt.Fprintf(g.buf, "switch v {\ncase %s:\nreturn true\n}\n", strings.Join(names, ", "))
+	g.buf.WriteString("return false\n}\n\n")
+
+	fmt.Fprintf(g.buf, "// String returns the name of the %s constant v.\n", enum.Name)
+	fmt.Fprintf(g.buf, "func (v %s) String() string {\n", enum.Name)
+	g.buf.WriteString("switch v {\n")
+	for _, v := range enum.Values {
+		fmt.Fprintf(g.buf, "case %s:\nreturn %q\n", v.Name, v.Name)
+	}
+	g.buf.WriteString("}\n")
+	fmt.Fprintf(g.buf, "return fmt.Sprintf(\"%s(%%d)\", %s(v))\n", enum.Name, enum.Base)
+	g.buf.WriteString("}\n\n")
+}
+
+// generateUnion declares a struct with one pointer field per variant.
+// Exactly one field must be set; the JSON tags give the fixture form
+// {"Variant": value}.
+func (g *goGenerator) generateUnion(union *schema.UnionType) {
+	if union.Doc != "" {
+		g.buf.WriteString(lineDoc("", "//", union.Doc) + "//\n")
+	}
+	fmt.Fprintf(g.buf, "// %s holds exactly one of its variants: set one field and leave the\n", union.Name)
+	g.buf.WriteString("// others nil.\n")
+	fmt.Fprintf(g.buf, "type %s struct {\n", union.Name)
+	for _, v := range union.Variants {
+		name := goVariantField(v)
+		tag := ""
+		if name != v.TypeName() {
+			tag = v.TypeName()
+		}
+		fmt.Fprintf(g.buf, "%s *%s `json:\"%s,omitempty\"`\n", name, g.goTypeString(v), tag)
+	}
+	g.buf.WriteString("}\n\n")
+}
+
+// goVariantField names the union field holding variant v.
+func goVariantField(v schema.Type) string {
+	return ToPascalCase(v.TypeName())
 }
 
 func (g *goGenerator) generateMessageStruct(structType *schema.StructType) {
 	// Generate root message type with Message suffix to avoid keyword collisions
+	g.buf.WriteString(lineDoc("", "//", structType.Doc))
 	fmt.Fprintf(g.buf, "type %sMessage struct {\n", structType.Name)
 	for _, field := range structType.Fields {
+		g.buf.WriteString(lineDoc("", "//", field.Doc))
 		typeStr := g.goTypeString(field.Type)
 		if field.Tag != "" {
 			fmt.Fprintf(g.buf, "%s %s %s\n", field.Name, typeStr, field.Tag)
@@ -272,8 +490,10 @@ func (g *goGenerator) generateMessageStruct(structType *schema.StructType) {
 
 func (g *goGenerator) generateStruct(structType *schema.StructType) {
 	// Generate helper/embedded type (no Message suffix)
+	g.buf.WriteString(lineDoc("", "//", structType.Doc))
 	fmt.Fprintf(g.buf, "type %s struct {\n", structType.Name)
 	for _, field := range structType.Fields {
+		g.buf.WriteString(lineDoc("", "//", field.Doc))
 		typeStr := g.goTypeString(field.Type)
 		if field.Tag != "" {
 			fmt.Fprintf(g.buf, "%s %s %s\n", field.Name, typeStr, field.Tag)
@@ -291,7 +511,7 @@ func (g *goGenerator) goTypeString(typ schema.Type) string {
 		if t.Optional {
 			prefix = "*"
 		}
-		return prefix + t.Name
+		return prefix + goPrimitiveType(t.Name)
 
 	case *schema.StructType:
 		prefix := ""
@@ -300,31 +520,79 @@ func (g *goGenerator) goTypeString(typ schema.Type) string {
 		}
 		return prefix + t.Name
 
+	case *schema.EnumType:
+		prefix := ""
+		if t.Optional {
+			prefix = "*"
+		}
+		return prefix + t.Name
+
+	case *schema.UnionType:
+		prefix := ""
+		if t.Optional {
+			prefix = "*"
+		}
+		return prefix + t.Name
+
 	case *schema.ArrayType:
 		prefix := ""
 		if t.Optional {
 			prefix = "*"
 		}
+		if t.Length > 0 {
+			return prefix + fmt.Sprintf("[%d]", t.Length) + g.goTypeString(t.ElementType)
+		}
 		return prefix + "[]" + g.goTypeString(t.ElementType)
 
+	case *schema.MapType:
+		prefix := ""
+		if t.Optional {
+			prefix = "*"
+		}
+		return prefix + "map[" + g.goTypeString(t.KeyType) + "]" + g.goTypeString(t.ValueType)
+
 	default:
 		return "interface{}"
 	}
 }
 
+// goPrimitiveType returns the Go spelling of a schema primitive.
+func goPrimitiveType(name string) string {
+	if name == "bytes" {
+		return "[]byte"
+	}
+	return name
+}
+
 func (g *goGenerator) generateMessageEncode(msg schema.MessageType) {
 	// Determine root type name for function naming
 	rootTypeName := g.rootTypeName(msg.TargetType)
 
 	// Method signature - use Message suffix type
 	paramType := msg.Name + "Message"
+
+	// Size pass: the exact wire size, so Encode allocates once
+	fmt.Fprintf(g.buf, "// EncodedSize returns the number of bytes Encode writes for v.\n")
+	fmt.Fprintf(g.buf, "func (v %s) EncodedSize() int {\n", paramType)
+	g.buf.WriteString("size := 0\n")
+	g.generateSizeValue("v", msg.TargetType)
+	g.buf.WriteString("return size\n")
+	g.buf.WriteString("}\n\n")
+
 	fmt.Fprintf(g.buf, "// Encode encodes %sMessage to binary wire format.\n", msg.Name)
 	fmt.Fprintf(g.buf, "func (v %s) Encode() []byte {\n", paramType)
+	g.buf.WriteString("return v.AppendTo(nil)\n")
+	g.buf.WriteString("}\n\n")
 
-	// Use default buffer - bytes.Buffer automatically grows efficiently
-	g.buf.WriteString("buf := &bytes.Buffer{}\n")
+	// Write pass: index-based writes into the exactly sized tail of buf
+	fmt.Fprintf(g.buf, "// AppendTo appends the wire format of v to buf and returns the extended\n")
+	fmt.Fprintf(g.buf, "// slice. It allocates only when buf lacks room for EncodedSize more bytes,\n")
+	fmt.Fprintf(g.buf, "// so reusing buf across calls keeps encoding allocation-free.\n")
+	fmt.Fprintf(g.buf, "func (v %s) AppendTo(buf []byte) []byte {\n", paramType)
+	g.buf.WriteString("pos := len(buf)\n")
+	fmt.Fprintf(g.buf, "buf = %s(buf, v.EncodedSize())\n", g.runtimeName("ffireGrow"))
 	g.generateEncodeValue("buf", "v", msg.TargetType)
-	g.buf.WriteString("return buf.Bytes()\n")
+	g.buf.WriteString("return buf[:pos]\n")
 	g.buf.WriteString("}\n\n")
 
 	// Also generate free function for backward compatibility
@@ -343,14 +611,21 @@ func (g *goGenerator) generateMessageDecode(msg schema.MessageType) {
 	// Method signature - decode into receiver
 	returnType := msg.Name + "Message"
 	fmt.Fprintf(g.buf, "// Decode decodes %s from binary wire format into the receiver.\n", msg.Name)
+	g.buf.WriteString("// The result does not alias data, which may be reused or freed once\n")
+	g.buf.WriteString("// Decode returns.\n")
 	fmt.Fprintf(g.buf, "func (v *%s) Decode(data []byte) error {\n", returnType)
-
-	// Direct slice indexing - no Reader allocation
-	g.buf.WriteString("var pos int\n")
-
-	g.generateDecodeValueDirect("data", "pos", "(*v)", msg.TargetType, false)
-	g.buf.WriteString("return nil\n")
-	g.buf.WriteString("}\n\n")
+	g.generateDecodeBody(msg)
+
+	if g.opts.Borrowed {
+		fmt.Fprintf(g.buf, "// DecodeBorrowed decodes %s like Decode, but the strings and byte\n", msg.Name)
+		g.buf.WriteString("// slices of the result point into data instead of copying it. data must\n")
+		g.buf.WriteString("// stay unchanged and alive while the result is in use: use Decode for\n")
+		g.buf.WriteString("// buffers owned by someone else, such as a plugin that may be unloaded.\n")
+		fmt.Fprintf(g.buf, "func (v *%s) DecodeBorrowed(data []byte) error {\n", returnType)
+		g.borrow = true
+		g.generateDecodeBody(msg)
+		g.borrow = false
+	}
 
 	// Also generate free function for backward compatibility and convenience
 	fmt.Fprintf(g.buf, "// %s decodes %s from binary wire format.\n", funcName, msg.Name)
@@ -359,6 +634,89 @@ func (g *goGenerator) generateMessageDecode(msg schema.MessageType) {
 	g.buf.WriteString("err := result.Decode(data)\n")
 	g.buf.WriteString("return result, err\n")
 	g.buf.WriteString("}\n\n")
+
+	if st, ok := msg.TargetType.(*schema.StructType); ok {
+		g.generateLenientDecode(funcName+"Lenient", returnType, msg.Name, st)
+	}
+}
+
+// generateDecodeBody writes the body of a Decode method for msg. Every
+// read is checked against the data left, so bad input fails with the code
+// the reference decoder reports instead of panicking.
+func (g *goGenerator) generateDecodeBody(msg schema.MessageType) {
+	// Direct slice indexing - no Reader allocation
+	g.buf.WriteString("var pos int\n")
+	g.generateDecodeValueDirect("data", "pos", "(*v)", msg.TargetType, false)
+	g.buf.WriteString("if pos != len(data) {\nreturn &DecodeError{Code: DecodeTrailingData, Msg: fmt.Sprintf(\"%d trailing bytes after message\", len(data)-pos)}\n}\n")
+	g.buf.WriteString("return nil\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// hasStructMessage reports whether a message is a struct, which gets a
+// lenient decoder.
+func (g *goGenerator) hasStructMessage() bool {
+	for _, msg := range g.schema.Messages {
+		if _, ok := msg.TargetType.(*schema.StructType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// generateLenientDecode writes a decoder that keeps every field it can
+// read. Each field is decoded into a temporary by its own closure, so a
+// failed field stays zero; decodeLenient (goLenientRuntime) runs them.
+func (g *goGenerator) generateLenientDecode(funcName, returnType, name string, st *schema.StructType) {
+	fmt.Fprintf(g.buf, "// %s decodes %s field by field, keeping every field it can read,\n", funcName, name)
+	g.buf.WriteString("// for callers that prefer partial data to none. Failed fields are zero and\n")
+	g.buf.WriteString("// listed in the returned errors, which are empty for valid data.\n")
+	fmt.Fprintf(g.buf, "func %s(data []byte) (%s, []FieldError) {\n", funcName, returnType)
+	fmt.Fprintf(g.buf, "var v %s\n", returnType)
+	g.buf.WriteString("data = data[:len(data):len(data)] // reads past the end must panic, not see spare capacity\n")
+	g.buf.WriteString("var pos int\n")
+	var bits map[string]string
+	bitsVar := ""
+	if schema.BitmapSize(st) > 0 {
+		// Lenient fields decode in closures, so the bitmap is read by one
+		bitsVar = g.uniqueVar("bits")
+		fmt.Fprintf(g.buf, "var %s []byte\n", bitsVar)
+		bits = make(map[string]string)
+		bit := 0
+		for _, field := range st.Fields {
+			if field.Type.IsOptional() {
+				bits[field.Name] = fmt.Sprintf("%s[%d]&0x%02x != 0", bitsVar, bit/8, 1<<(bit%8))
+				bit++
+			}
+		}
+	}
+	fmt.Fprintf(g.buf, "fields := []%s{\n", g.runtimeName("lenientField"))
+	if st.Framed {
+		// The fields decode from the frame if it fits in the data, and
+		// from what there is otherwise
+		g.buf.WriteString("{Name: \"@frame\", Size: 4, Decode: func() error {\n")
+		g.buf.WriteString("frame := int(binary.LittleEndian.Uint32(data[pos:]))\npos += 4\n")
+		g.buf.WriteString("if frame > len(data)-pos {\nreturn &DecodeError{Code: DecodeOverflow, Msg: fmt.Sprintf(\"frame of %d bytes exceeds the %d bytes left\", frame, len(data)-pos)}\n}\n")
+		g.buf.WriteString("data = data[:pos+frame : pos+frame]\nreturn nil\n}},\n")
+	}
+	if n := schema.BitmapSize(st); n > 0 {
+		fmt.Fprintf(g.buf, "{Name: \"@bitmap\", Decode: func() error {\n%s = data[pos : pos+%d]\npos += %d\nreturn nil\n}},\n", bitsVar, n, n)
+	}
+	for _, field := range st.Fields {
+		fmt.Fprintf(g.buf, "{Name: %q, Size: %d, Decode: func() error {\n", field.Name, goFixedSize(field.Type))
+		tmpVar := g.uniqueVar("field")
+		fmt.Fprintf(g.buf, "var %s %s\n", tmpVar, g.goTypeString(field.Type))
+		g.presentBit = bits[field.Name]
+		g.lenient = true
+		g.generateDecodeValueDirect("data", "pos", tmpVar, field.Type, false)
+		g.lenient = false
+		fmt.Fprintf(g.buf, "v.%s = %s\n", field.Name, tmpVar)
+		g.buf.WriteString("return nil\n")
+		g.buf.WriteString("}},\n")
+	}
+	g.buf.WriteString("}\n")
+	fmt.Fprintf(g.buf, "errs := %s(data, &pos, fields)\n", g.runtimeName("decodeLenient"))
+	g.buf.WriteString("return v, errs\n")
+	g.buf.WriteString("}\n\n")
 }
 
 func (g *goGenerator) rootTypeName(typ schema.Type) string {
@@ -375,48 +733,226 @@ func (g *goGenerator) rootTypeName(typ schema.Type) string {
 	}
 }
 
+// goFixedSize returns the encoded size of a required value whose size does
+// not depend on its contents, or 0 for optional and variable-size values.
+func goFixedSize(typ schema.Type) int {
+	if typ.IsOptional() {
+		return 0
+	}
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		return schema.PrimitiveSize(t.Name)
+	case *schema.EnumType:
+		return schema.PrimitiveSize(t.Base)
+	case *schema.ArrayType:
+		return t.Length * goFixedSize(t.ElementType)
+	case *schema.StructType:
+		size := schema.FramePrefixSize(t)
+		for _, field := range t.Fields {
+			n := goFixedSize(field.Type)
+			if n == 0 {
+				return 0
+			}
+			size += n
+		}
+		return size
+	}
+	return 0
+}
+
+// generateSizeValue adds the number of bytes generateEncodeValue writes for
+// valueVar to size. Fixed-size parts are folded into constants.
+func (g *goGenerator) generateSizeValue(valueVar string, typ schema.Type) {
+	if n := goFixedSize(typ); n > 0 {
+		fmt.Fprintf(g.buf, "size += %d\n", n)
+		return
+	}
+
+	if typ.IsOptional() {
+		if !g.bitmapped {
+			g.buf.WriteString("size++\n")
+		}
+		g.bitmapped = false
+		fmt.Fprintf(g.buf, "if %s != nil {\n", valueVar)
+		switch typ.(type) {
+		case *schema.PrimitiveType, *schema.EnumType, *schema.ArrayType, *schema.MapType:
+			// Field selectors dereference struct and union pointers themselves
+			valueVar = "*" + valueVar
+		}
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		if t.Name == "string" || t.Name == "bytes" {
+			fmt.Fprintf(g.buf, "size += %d + len(%s)\n", schema.LengthPrefixSize(t), valueVar)
+		} else {
+			fmt.Fprintf(g.buf, "size += %d\n", schema.PrimitiveSize(t.Name))
+		}
+	case *schema.EnumType:
+		fmt.Fprintf(g.buf, "size += %d\n", schema.PrimitiveSize(t.Base))
+	case *schema.StructType:
+		fixed := schema.FramePrefixSize(t) + schema.BitmapSize(t)
+		for _, field := range t.Fields {
+			if n := goFixedSize(field.Type); n > 0 {
+				fixed += n
+			} else {
+				g.bitmapped = t.Bitmap && field.Type.IsOptional()
+				g.generateSizeValue(valueVar+"."+field.Name, field.Type)
+			}
+		}
+		if fixed > 0 {
+			fmt.Fprintf(g.buf, "size += %d\n", fixed)
+		}
+	case *schema.ArrayType:
+		if n := goFixedSize(t.ElementType); n > 0 {
+			fmt.Fprintf(g.buf, "size += %d + len(%s)*%d\n", schema.LengthPrefixSize(t), valueVar, n)
+		} else {
+			fmt.Fprintf(g.buf, "size += %d\n", schema.LengthPrefixSize(t))
+			fmt.Fprintf(g.buf, "for _, elem := range %s {\n", valueVar)
+			g.generateSizeValue("elem", t.ElementType)
+			g.buf.WriteString("}\n")
+		}
+	case *schema.MapType:
+		g.generateSizeMap(valueVar, t)
+	case *schema.UnionType:
+		g.buf.WriteString("size++\n")
+		g.buf.WriteString("switch {\n")
+		for _, v := range t.Variants {
+			fieldVar := valueVar + "." + goVariantField(v)
+			fmt.Fprintf(g.buf, "case %s != nil:\n", fieldVar)
+			if _, ok := v.(*schema.StructType); !ok {
+				fieldVar = "*" + fieldVar
+			}
+			g.generateSizeValue(fieldVar, v)
+		}
+		g.buf.WriteString("}\n")
+	}
+
+	if typ.IsOptional() {
+		g.buf.WriteString("}\n")
+	}
+}
+
+// generateSizeMap sizes a map's count and entries. Fixed-size keys and
+// values are counted per entry without visiting them.
+func (g *goGenerator) generateSizeMap(valueVar string, typ *schema.MapType) {
+	keySize := goFixedSize(typ.KeyType)
+	valSize := goFixedSize(typ.ValueType)
+	if keySize+valSize > 0 {
+		fmt.Fprintf(g.buf, "size += %d + len(%s)*%d\n", schema.LengthPrefixSize(typ), valueVar, keySize+valSize)
+	} else {
+		fmt.Fprintf(g.buf, "size += %d\n", schema.LengthPrefixSize(typ))
+	}
+	if keySize > 0 && valSize > 0 {
+		return
+	}
+
+	keyVar, valVar := "_", "_"
+	if keySize == 0 {
+		keyVar = g.uniqueVar("k")
+	}
+	if valSize == 0 {
+		valVar = g.uniqueVar("val")
+	}
+	if valVar == "_" {
+		fmt.Fprintf(g.buf, "for %s := range %s {\n", keyVar, valueVar)
+	} else {
+		fmt.Fprintf(g.buf, "for %s, %s := range %s {\n", keyVar, valVar, valueVar)
+	}
+	if keySize == 0 {
+		g.generateSizeValue(keyVar, typ.KeyType)
+	}
+	if valSize == 0 {
+		g.generateSizeValue(valVar, typ.ValueType)
+	}
+	g.buf.WriteString("}\n")
+}
+
 func (g *goGenerator) generateEncodeValue(bufVar, valueVar string, typ schema.Type) {
 	switch t := typ.(type) {
 	case *schema.PrimitiveType:
 		g.generateEncodePrimitive(bufVar, valueVar, t)
+	case *schema.EnumType:
+		// The conversions in the primitive encoder accept the named type
+		g.generateEncodePrimitive(bufVar, valueVar, t.BaseType())
 	case *schema.StructType:
 		g.generateEncodeStruct(bufVar, valueVar, t)
 	case *schema.ArrayType:
 		g.generateEncodeArray(bufVar, valueVar, t)
+	case *schema.MapType:
+		g.generateEncodeMap(bufVar, valueVar, t)
+	case *schema.UnionType:
+		g.generateEncodeUnion(bufVar, valueVar, t)
+	}
+}
+
+// generateWriteByte stores one byte at pos and advances past it.
+func (g *goGenerator) generateWriteByte(bufVar, expr string) {
+	fmt.Fprintf(g.buf, "%s[pos] = %s\n", bufVar, expr)
+	g.buf.WriteString("pos++\n")
+}
+
+// generateWriteLength stores the length prefix of typ at pos: a uint16,
+// or a uint32 if typ is @large.
+func (g *goGenerator) generateWriteLength(bufVar, lenExpr string, typ schema.Type) {
+	if schema.IsLarge(typ) {
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint32(%s[pos:], uint32(%s))\n", bufVar, lenExpr)
+		g.buf.WriteString("pos += 4\n")
+		return
 	}
+	fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint16(%s[pos:], uint16(%s))\n", bufVar, lenExpr)
+	g.buf.WriteString("pos += 2\n")
+}
+
+// generateWritePresence opens the present branch of an optional value
+// after writing its presence byte, or only opens it if the value's flag is
+// in a bitmap; the caller closes it.
+func (g *goGenerator) generateWritePresence(bufVar, valueVar string) {
+	if g.bitmapped {
+		g.bitmapped = false
+		fmt.Fprintf(g.buf, "if %s != nil {\n", valueVar)
+		return
+	}
+	fmt.Fprintf(g.buf, "if %s == nil {\n", valueVar)
+	g.generateWriteByte(bufVar, "0x00")
+	g.buf.WriteString("} else {\n")
+	g.generateWriteByte(bufVar, "0x01")
 }
 
 func (g *goGenerator) generateEncodePrimitive(bufVar, valueVar string, typ *schema.PrimitiveType) {
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "if %s == nil {\n", valueVar)
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x00)\n", bufVar)
-		g.buf.WriteString("} else {\n")
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x01)\n", bufVar)
+		g.generateWritePresence(bufVar, valueVar)
 		valueVar = "*" + valueVar
 	}
 
 	switch typ.Name {
 	case "bool":
 		fmt.Fprintf(g.buf, "if %s {\n", valueVar)
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x01)\n", bufVar)
+		fmt.Fprintf(g.buf, "%s[pos] = 0x01\n", bufVar)
 		g.buf.WriteString("} else {\n")
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x00)\n", bufVar)
+		fmt.Fprintf(g.buf, "%s[pos] = 0x00\n", bufVar)
 		g.buf.WriteString("}\n")
+		g.buf.WriteString("pos++\n")
 	case "int8":
-		fmt.Fprintf(g.buf, "%s.WriteByte(byte(%s))\n", bufVar, valueVar)
+		g.generateWriteByte(bufVar, "byte("+valueVar+")")
 	case "int16":
-		fmt.Fprintf(g.buf, "{ v := uint16(%s); %s.WriteByte(byte(v)); %s.WriteByte(byte(v>>8)) }\n", valueVar, bufVar, bufVar)
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint16(%s[pos:], uint16(%s))\n", bufVar, valueVar)
+		g.buf.WriteString("pos += 2\n")
 	case "int32":
-		fmt.Fprintf(g.buf, "{ v := uint32(%s); %s.WriteByte(byte(v)); %s.WriteByte(byte(v>>8)); %s.WriteByte(byte(v>>16)); %s.WriteByte(byte(v>>24)) }\n", valueVar, bufVar, bufVar, bufVar, bufVar)
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint32(%s[pos:], uint32(%s))\n", bufVar, valueVar)
+		g.buf.WriteString("pos += 4\n")
 	case "int64":
-		fmt.Fprintf(g.buf, "{ v := uint64(%s); %s.WriteByte(byte(v)); %s.WriteByte(byte(v>>8)); %s.WriteByte(byte(v>>16)); %s.WriteByte(byte(v>>24)); %s.WriteByte(byte(v>>32)); %s.WriteByte(byte(v>>40)); %s.WriteByte(byte(v>>48)); %s.WriteByte(byte(v>>56)) }\n", valueVar, bufVar, bufVar, bufVar, bufVar, bufVar, bufVar, bufVar, bufVar)
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint64(%s[pos:], uint64(%s))\n", bufVar, valueVar)
+		g.buf.WriteString("pos += 8\n")
 	case "float32":
-		fmt.Fprintf(g.buf, "{ v := math.Float32bits(%s); %s.WriteByte(byte(v)); %s.WriteByte(byte(v>>8)); %s.WriteByte(byte(v>>16)); %s.WriteByte(byte(v>>24)) }\n", valueVar, bufVar, bufVar, bufVar, bufVar)
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint32(%s[pos:], math.Float32bits(%s))\n", bufVar, valueVar)
+		g.buf.WriteString("pos += 4\n")
 	case "float64":
-		fmt.Fprintf(g.buf, "{ v := math.Float64bits(%s); %s.WriteByte(byte(v)); %s.WriteByte(byte(v>>8)); %s.WriteByte(byte(v>>16)); %s.WriteByte(byte(v>>24)); %s.WriteByte(byte(v>>32)); %s.WriteByte(byte(v>>40)); %s.WriteByte(byte(v>>48)); %s.WriteByte(byte(v>>56)) }\n", valueVar, bufVar, bufVar, bufVar, bufVar, bufVar, bufVar, bufVar, bufVar)
-	case "string":
-		fmt.Fprintf(g.buf, "{ l := uint16(len(%s)); %s.WriteByte(byte(l)); %s.WriteByte(byte(l>>8)) }\n", valueVar, bufVar, bufVar)
-		fmt.Fprintf(g.buf, "%s.WriteString(%s)\n", bufVar, valueVar)
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint64(%s[pos:], math.Float64bits(%s))\n", bufVar, valueVar)
+		g.buf.WriteString("pos += 8\n")
+	case "string", "bytes":
+		g.generateWriteLength(bufVar, "len("+valueVar+")", typ)
+		fmt.Fprintf(g.buf, "pos += copy(%s[pos:], %s)\n", bufVar, valueVar)
 	}
 
 	if typ.Optional {
@@ -426,52 +962,91 @@ func (g *goGenerator) generateEncodePrimitive(bufVar, valueVar string, typ *sche
 
 func (g *goGenerator) generateEncodeStruct(bufVar, valueVar string, typ *schema.StructType) {
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "if %s == nil {\n", valueVar)
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x00)\n", bufVar)
-		g.buf.WriteString("} else {\n")
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x01)\n", bufVar)
-		valueVar = "*" + valueVar
+		g.generateWritePresence(bufVar, valueVar)
+		// Field selectors dereference the pointer; "*" + valueVar would
+		// apply to the field instead
+	}
+	// The frame is filled in once the fields are written
+	frameVar := ""
+	if typ.Framed {
+		frameVar = g.uniqueVar("frame")
+		fmt.Fprintf(g.buf, "%s := pos\n", frameVar)
+		g.buf.WriteString("pos += 4\n")
+	}
+	if typ.Bitmap {
+		g.generateWriteBitmap(bufVar, valueVar, typ)
 	}
 
 	// Check for runs of fixed-size primitive fields for bulk encoding
 	runs := schema.GetFixedFieldRuns(typ.Fields)
-	
+
 	// If we have a substantial run of fixed fields, use bulk encoding
 	if len(runs) > 0 && runs[0].TotalBytes >= 8 && runs[0].StartIndex == 0 {
 		run := runs[0]
 		g.generateBulkStructEncode(bufVar, valueVar, typ.Fields[run.StartIndex:run.EndIndex+1], run.TotalBytes)
-		
+
 		// Encode remaining fields normally
 		for i := run.EndIndex + 1; i < len(typ.Fields); i++ {
-			fieldVar := valueVar + "." + typ.Fields[i].Name
-			g.generateEncodeValue(bufVar, fieldVar, typ.Fields[i].Type)
+			g.generateEncodeField(bufVar, valueVar, typ, typ.Fields[i])
 		}
 	} else {
 		// No significant fixed field run, encode all fields individually
 		for _, field := range typ.Fields {
-			fieldVar := valueVar + "." + field.Name
-			g.generateEncodeValue(bufVar, fieldVar, field.Type)
+			g.generateEncodeField(bufVar, valueVar, typ, field)
 		}
 	}
+	if typ.Framed {
+		fmt.Fprintf(g.buf, "binary.LittleEndian.PutUint32(%s[%s:], uint32(pos-%s-4))\n", bufVar, frameVar, frameVar)
+	}
 
 	if typ.Optional {
 		g.buf.WriteString("}\n")
 	}
 }
 
-// generateBulkStructEncode generates code to encode multiple fixed-size fields in one buffer write
+// generateEncodeField encodes one field of the struct in structVar. A
+// @cached field is encoded like any other: copying a Go string, which is
+// already UTF-8, is cheaper than looking up a cache.
+func (g *goGenerator) generateEncodeField(bufVar, structVar string, st *schema.StructType, field schema.Field) {
+	g.bitmapped = st.Bitmap && field.Type.IsOptional()
+	g.generateEncodeValue(bufVar, structVar+"."+field.Name, field.Type)
+}
+
+// generateWriteBitmap writes the presence bitmap of a @bitmap struct, one
+// byte per eight optional fields.
+func (g *goGenerator) generateWriteBitmap(bufVar, structVar string, st *schema.StructType) {
+	var optional []string
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			optional = append(optional, field.Name)
+		}
+	}
+	for start := 0; start < len(optional); start += 8 {
+		bitsVar := g.uniqueVar("bits")
+		fmt.Fprintf(g.buf, "var %s byte\n", bitsVar)
+		for i, name := range optional[start:min(start+8, len(optional))] {
+			fmt.Fprintf(g.buf, "if %s.%s != nil {\n", structVar, name)
+			fmt.Fprintf(g.buf, "%s |= 0x%02x\n", bitsVar, 1<<i)
+			g.buf.WriteString("}\n")
+		}
+		g.generateWriteByte(bufVar, bitsVar)
+	}
+}
+
+// generateBulkStructEncode writes a run of fixed-size fields through one
+// subslice of the output, so the compiler checks its bounds once
 func (g *goGenerator) generateBulkStructEncode(bufVar, structVar string, fields []schema.Field, totalBytes int) {
 	tmpVar := g.uniqueVar("fixedBuf")
-	fmt.Fprintf(g.buf, "{ %s := make([]byte, %d)\n", tmpVar, totalBytes)
-	
+	fmt.Fprintf(g.buf, "{ %s := %s[pos : pos+%d]\n", tmpVar, bufVar, totalBytes)
+
 	offset := 0
 	for _, field := range fields {
 		fieldVar := structVar + "." + field.Name
 		primType := field.Type.(*schema.PrimitiveType)
-		
+
 		switch primType.Name {
 		case "bool":
-			fmt.Fprintf(g.buf, "if %s { %s[%d] = 0x01 }\n", fieldVar, tmpVar, offset)
+			fmt.Fprintf(g.buf, "if %s { %s[%d] = 0x01 } else { %s[%d] = 0x00 }\n", fieldVar, tmpVar, offset, tmpVar, offset)
 			offset += 1
 		case "int8":
 			fmt.Fprintf(g.buf, "%s[%d] = byte(%s)\n", tmpVar, offset, fieldVar)
@@ -493,21 +1068,23 @@ func (g *goGenerator) generateBulkStructEncode(bufVar, structVar string, fields
 			offset += 8
 		}
 	}
-	
-	fmt.Fprintf(g.buf, "%s.Write(%s) }\n", bufVar, tmpVar)
+
+	fmt.Fprintf(g.buf, "pos += %d }\n", totalBytes)
 }
 
 func (g *goGenerator) generateEncodeArray(bufVar, valueVar string, typ *schema.ArrayType) {
 	if typ.Optional {
-		fmt.Fprintf(g.buf, "if %s == nil {\n", valueVar)
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x00)\n", bufVar)
-		g.buf.WriteString("} else {\n")
-		fmt.Fprintf(g.buf, "%s.WriteByte(0x01)\n", bufVar)
-		valueVar = "*" + valueVar
+		g.generateWritePresence(bufVar, valueVar)
+		// A local copy of the slice header, so indexing applies to the slice
+		arrVar := g.uniqueVar("arr")
+		fmt.Fprintf(g.buf, "%s := *%s\n", arrVar, valueVar)
+		valueVar = arrVar
 	}
 
-	// Write array length
-	fmt.Fprintf(g.buf, "{ l := uint16(len(%s)); %s.WriteByte(byte(l)); %s.WriteByte(byte(l>>8)) }\n", valueVar, bufVar, bufVar)
+	// Write array length; fixed-size arrays have none
+	if typ.Length == 0 {
+		g.generateWriteLength(bufVar, "len("+valueVar+")", typ)
+	}
 
 	// Check if we can do bulk write for primitive arrays
 	if primType, ok := typ.ElementType.(*schema.PrimitiveType); ok && !primType.Optional {
@@ -524,48 +1101,100 @@ func (g *goGenerator) generateEncodeArray(bufVar, valueVar string, typ *schema.A
 	}
 }
 
+// generateEncodeMap writes the entry count, then each entry in ascending
+// key order so equal maps encode to equal bytes.
+func (g *goGenerator) generateEncodeMap(bufVar, valueVar string, typ *schema.MapType) {
+	if typ.Optional {
+		g.generateWritePresence(bufVar, valueVar)
+		mapVar := g.uniqueVar("m")
+		fmt.Fprintf(g.buf, "%s := *%s\n", mapVar, valueVar)
+		valueVar = mapVar
+	}
+
+	g.generateWriteLength(bufVar, "len("+valueVar+")", typ)
+
+	keyType := typ.KeyType.(*schema.PrimitiveType)
+	keyVar := g.uniqueVar("k")
+	valVar := g.uniqueVar("val")
+	if keyType.Name == "bool" {
+		fmt.Fprintf(g.buf, "for _, %s := range [2]bool{false, true} {\n", keyVar)
+		fmt.Fprintf(g.buf, "%s, ok := %s[%s]\n", valVar, valueVar, keyVar)
+		g.buf.WriteString("if !ok {\ncontinue\n}\n")
+	} else {
+		keysVar := g.uniqueVar("keys")
+		fmt.Fprintf(g.buf, "%s := make([]%s, 0, len(%s))\n", keysVar, keyType.Name, valueVar)
+		fmt.Fprintf(g.buf, "for %s := range %s {\n%s = append(%s, %s)\n}\n", keyVar, valueVar, keysVar, keysVar, keyVar)
+		fmt.Fprintf(g.buf, "sort.Slice(%s, func(i, j int) bool { return %s[i] < %s[j] })\n", keysVar, keysVar, keysVar)
+		fmt.Fprintf(g.buf, "for _, %s := range %s {\n", keyVar, keysVar)
+		fmt.Fprintf(g.buf, "%s := %s[%s]\n", valVar, valueVar, keyVar)
+	}
+	g.generateEncodePrimitive(bufVar, keyVar, keyType)
+	g.generateEncodeValue(bufVar, valVar, typ.ValueType)
+	g.buf.WriteString("}\n")
+
+	if typ.Optional {
+		g.buf.WriteString("}\n")
+	}
+}
+
+// generateEncodeUnion writes the tag of the set variant and its value.
+// Encode has no error result, so a union with no variant set panics.
+func (g *goGenerator) generateEncodeUnion(bufVar, valueVar string, typ *schema.UnionType) {
+	if typ.Optional {
+		g.generateWritePresence(bufVar, valueVar)
+	}
+
+	g.buf.WriteString("switch {\n")
+	for i, v := range typ.Variants {
+		fieldVar := valueVar + "." + goVariantField(v)
+		fmt.Fprintf(g.buf, "case %s != nil:\n", fieldVar)
+		g.generateWriteByte(bufVar, fmt.Sprintf("%d", i+1))
+		if _, ok := v.(*schema.StructType); !ok {
+			// Struct field selectors dereference the pointer themselves
+			fieldVar = "(*" + fieldVar + ")"
+		}
+		g.generateEncodeValue(bufVar, fieldVar, v)
+	}
+	fmt.Fprintf(g.buf, "default:\npanic(\"ffire: %s has no variant set\")\n", typ.Name)
+	g.buf.WriteString("}\n")
+
+	if typ.Optional {
+		g.buf.WriteString("}\n")
+	}
+}
+
 func (g *goGenerator) generateBulkArrayEncode(bufVar, valueVar string, primType *schema.PrimitiveType) {
 	switch primType.Name {
 	case "bool":
 		// Bools need individual handling (can't bulk write due to 0x00/0x01 encoding)
 		fmt.Fprintf(g.buf, "for _, elem := range %s {\n", valueVar)
-		fmt.Fprintf(g.buf, "if elem { %s.WriteByte(0x01) } else { %s.WriteByte(0x00) }\n", bufVar, bufVar)
+		fmt.Fprintf(g.buf, "if elem { %s[pos] = 0x01 } else { %s[pos] = 0x00 }\n", bufVar, bufVar)
+		g.buf.WriteString("pos++\n")
 		g.buf.WriteString("}\n")
 	case "int8":
 		// int8/uint8 can be reinterpreted directly as []byte (no endianness issue)
-		// No len check needed - unsafe.Slice handles empty slices correctly
 		fmt.Fprintf(g.buf, "if len(%s) > 0 {\n", valueVar)
-		fmt.Fprintf(g.buf, "%s.Write(unsafe.Slice((*byte)(unsafe.Pointer(&%s[0])), len(%s)))\n", bufVar, valueVar, valueVar)
+		g.buf.WriteString("// unsafe: int8 has the layout of byte; the slice is non-empty\n")
+		fmt.Fprintf(g.buf, "pos += copy(%s[pos:], unsafe.Slice((*byte)(unsafe.Pointer(&%s[0])), len(%s)))\n", bufVar, valueVar, valueVar)
 		g.buf.WriteString("}\n")
 	case "int16", "int32", "int64", "float32", "float64":
 		// Zero-copy reinterpret for multi-byte types (little-endian wire format)
-		typeSize := map[string]int{
-			"int16":   2,
-			"int32":   4,
-			"int64":   8,
-			"float32": 4,
-			"float64": 8,
-		}[primType.Name]
-
-		// Reinterpret array as []byte using unsafe - zero-copy, no allocation
+		typeSize := schema.PrimitiveSize(primType.Name)
+
+		// Reinterpret array as []byte using unsafe and copy it in one go
 		// Keep len check for safety with unsafe pointer
 		fmt.Fprintf(g.buf, "if len(%s) > 0 {\n", valueVar)
-		fmt.Fprintf(g.buf, "%s.Write(unsafe.Slice((*byte)(unsafe.Pointer(&%s[0])), len(%s)*%d))\n",
+		fmt.Fprintf(g.buf, "// unsafe: the wire format is the memory layout of []%s on little-endian hosts; the slice is non-empty\n", primType.Name)
+		fmt.Fprintf(g.buf, "pos += copy(%s[pos:], unsafe.Slice((*byte)(unsafe.Pointer(&%s[0])), len(%s)*%d))\n",
 			bufVar, valueVar, valueVar, typeSize)
 		g.buf.WriteString("}\n")
 
-	case "string":
-		// Strings need individual length prefixes - optimize with pre-calculated Grow()
-		// Calculate total wire size: all string data + 2 bytes per string for length prefixes
-		totalVar := g.uniqueVar("totalSize")
-		fmt.Fprintf(g.buf, "%s := 2 * len(%s)\n", totalVar, valueVar)                     // length prefixes
-		fmt.Fprintf(g.buf, "for _, s := range %s { %s += len(s) }\n", valueVar, totalVar) // string data
-		fmt.Fprintf(g.buf, "%s.Grow(%s)\n", bufVar, totalVar)
-
-		// Now encode normally
+	case "string", "bytes":
+		// Strings and blobs need individual length prefixes; the size pass
+		// already counted their bytes
 		fmt.Fprintf(g.buf, "for _, elem := range %s {\n", valueVar)
-		fmt.Fprintf(g.buf, "{ l := uint16(len(elem)); %s.WriteByte(byte(l)); %s.WriteByte(byte(l>>8)) }\n", bufVar, bufVar)
-		fmt.Fprintf(g.buf, "%s.WriteString(elem)\n", bufVar)
+		g.generateWriteLength(bufVar, "len(elem)", primType)
+		fmt.Fprintf(g.buf, "pos += copy(%s[pos:], elem)\n", bufVar)
 		g.buf.WriteString("}\n")
 	}
 }
@@ -702,6 +1331,16 @@ func (g *goGenerator) generateDecodeArray(readerVar, resultVar string, typ *sche
 		fmt.Fprintf(g.buf, "if %s == 0x01 {\n", presentVar)
 	}
 
+	if typ.Length > 0 {
+		arrVar := g.uniqueVar("tmpArray")
+		fmt.Fprintf(g.buf, "{ var %s %s\n", arrVar, g.goTypeString(typ))
+		fmt.Fprintf(g.buf, "for i := range %s {\n", arrVar)
+		g.generateDecodeValue(readerVar, arrVar+"[i]", typ.ElementType, false)
+		g.buf.WriteString("}\n")
+		fmt.Fprintf(g.buf, "%s = %s }\n", resultVar, arrVar)
+		return
+	}
+
 	// Read array length
 	lenVar := g.uniqueVar("length")
 	bVar := g.uniqueVar("b")
@@ -737,21 +1376,25 @@ func (g *goGenerator) generateDecodeValueDirect(dataVar, posVar, resultVar strin
 	switch t := typ.(type) {
 	case *schema.PrimitiveType:
 		g.generateDecodePrimitiveDirect(dataVar, posVar, resultVar, t, isPointer)
+	case *schema.EnumType:
+		g.generateDecodeEnumDirect(dataVar, posVar, resultVar, t, isPointer)
 	case *schema.StructType:
 		g.generateDecodeStructDirect(dataVar, posVar, resultVar, t, isPointer)
 	case *schema.ArrayType:
 		g.generateDecodeArrayDirect(dataVar, posVar, resultVar, t, isPointer)
+	case *schema.MapType:
+		g.generateDecodeMapDirect(dataVar, posVar, resultVar, t)
+	case *schema.UnionType:
+		g.generateDecodeUnionDirect(dataVar, posVar, resultVar, t)
 	}
 }
 
 func (g *goGenerator) generateDecodePrimitiveDirect(dataVar, posVar, resultVar string, typ *schema.PrimitiveType, isPointer bool) {
 	if typ.Optional {
-		presentVar := g.uniqueVar("present")
-		fmt.Fprintf(g.buf, "%s := %s[%s]; %s++\n", presentVar, dataVar, posVar, posVar)
-		fmt.Fprintf(g.buf, "if %s == 0x01 {\n", presentVar)
+		g.generateReadPresence(dataVar, posVar)
 
 		tmpVar := g.uniqueVar("tmp")
-		fmt.Fprintf(g.buf, "var %s %s\n", tmpVar, typ.Name)
+		fmt.Fprintf(g.buf, "var %s %s\n", tmpVar, goPrimitiveType(typ.Name))
 		g.decodeNonOptionalPrimitiveDirect(dataVar, posVar, tmpVar, typ)
 		fmt.Fprintf(g.buf, "%s = &%s\n", resultVar, tmpVar)
 
@@ -761,7 +1404,7 @@ func (g *goGenerator) generateDecodePrimitiveDirect(dataVar, posVar, resultVar s
 
 	if isPointer {
 		tmpVar := g.uniqueVar("tmp")
-		fmt.Fprintf(g.buf, "var %s %s\n", tmpVar, typ.Name)
+		fmt.Fprintf(g.buf, "var %s %s\n", tmpVar, goPrimitiveType(typ.Name))
 		g.decodeNonOptionalPrimitiveDirect(dataVar, posVar, tmpVar, typ)
 		fmt.Fprintf(g.buf, "%s = &%s\n", resultVar, tmpVar)
 	} else {
@@ -770,8 +1413,12 @@ func (g *goGenerator) generateDecodePrimitiveDirect(dataVar, posVar, resultVar s
 }
 
 func (g *goGenerator) decodeNonOptionalPrimitiveDirect(dataVar, posVar, resultVar string, typ *schema.PrimitiveType) {
+	if size := schema.PrimitiveSize(typ.Name); size > 0 {
+		g.generateNeed(dataVar, posVar, fmt.Sprint(size))
+	}
 	switch typ.Name {
 	case "bool":
+		g.generateCheckBool(fmt.Sprintf("%s[%s]", dataVar, posVar))
 		fmt.Fprintf(g.buf, "%s = %s[%s] == 0x01; %s++\n", resultVar, dataVar, posVar, posVar)
 	case "int8":
 		fmt.Fprintf(g.buf, "%s = int8(%s[%s]); %s++\n", resultVar, dataVar, posVar, posVar)
@@ -786,22 +1433,148 @@ func (g *goGenerator) decodeNonOptionalPrimitiveDirect(dataVar, posVar, resultVa
 	case "float64":
 		fmt.Fprintf(g.buf, "%s = math.Float64frombits(uint64(%s[%s]) | uint64(%s[%s+1])<<8 | uint64(%s[%s+2])<<16 | uint64(%s[%s+3])<<24 | uint64(%s[%s+4])<<32 | uint64(%s[%s+5])<<40 | uint64(%s[%s+6])<<48 | uint64(%s[%s+7])<<56); %s += 8\n", resultVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, posVar)
 	case "string":
-		lenVar := g.uniqueVar("length")
+		lenVar := g.generateReadLength(dataVar, posVar, typ)
+		g.generateCheckLength(dataVar, posVar, lenVar)
+		g.generateCheckUTF8(dataVar, posVar, lenVar)
+		fmt.Fprintf(g.buf, "%s = %s; %s += int(%s)\n", resultVar, g.decodeStringExpr(dataVar, posVar, lenVar), posVar, lenVar)
+	case "bytes":
+		lenVar := g.generateReadLength(dataVar, posVar, typ)
+		g.generateCheckLength(dataVar, posVar, lenVar)
+		// Copied like strings, so the result does not alias the input,
+		// except in DecodeBorrowed
+		if g.borrow {
+			fmt.Fprintf(g.buf, "%s = %s[%s:%s+int(%s):%s+int(%s)]; %s += int(%s)\n", resultVar, dataVar, posVar, posVar, lenVar, posVar, lenVar, posVar, lenVar)
+		} else {
+			fmt.Fprintf(g.buf, "%s = append([]byte(nil), %s[%s:%s+int(%s)]...); %s += int(%s)\n", resultVar, dataVar, posVar, posVar, lenVar, posVar, lenVar)
+		}
+	}
+}
+
+// generateReadPresence opens the present branch of an optional value: it
+// reads the value's presence byte, or tests its bit in the bitmap of the
+// enclosing struct. The caller closes it.
+func (g *goGenerator) generateReadPresence(dataVar, posVar string) {
+	if g.presentBit != "" {
+		fmt.Fprintf(g.buf, "if %s {\n", g.presentBit)
+		g.presentBit = ""
+		return
+	}
+	presentVar := g.uniqueVar("present")
+	g.generateNeed(dataVar, posVar, "1")
+	fmt.Fprintf(g.buf, "%s := %s[%s]\n", presentVar, dataVar, posVar)
+	fmt.Fprintf(g.buf, "if %s > 0x01 {\nreturn &DecodeError{Code: DecodeBadPresence, Msg: fmt.Sprintf(\"invalid optional flag 0x%%02x\", %s)}\n}\n", presentVar, presentVar)
+	fmt.Fprintf(g.buf, "%s++\n", posVar)
+	fmt.Fprintf(g.buf, "if %s == 0x01 {\n", presentVar)
+}
+
+// decodeStringExpr returns an expression for the string in the n bytes of
+// data at pos: a copy, so the result does not alias the input, or in
+// DecodeBorrowed a view of the bytes.
+func (g *goGenerator) decodeStringExpr(dataVar, posVar, lenVar string) string {
+	if g.borrow {
+		g.buf.WriteString("// unsafe: DecodeBorrowed callers keep data unchanged while the result is in use\n")
+		return fmt.Sprintf("unsafe.String(unsafe.SliceData(%s[%s:%s+int(%s)]), int(%s))", dataVar, posVar, posVar, lenVar, lenVar)
+	}
+	return fmt.Sprintf("string(%s[%s:%s+int(%s)])", dataVar, posVar, posVar, lenVar)
+}
+
+// generateReadLength reads the length prefix of typ at pos into a new
+// variable, a uint16 or a uint32 if typ is @large, and returns its name.
+func (g *goGenerator) generateReadLength(dataVar, posVar string, typ schema.Type) string {
+	lenVar := g.uniqueVar("length")
+	if schema.IsLarge(typ) {
+		g.generateNeed(dataVar, posVar, "4")
+		fmt.Fprintf(g.buf, "%s := uint32(%s[%s]) | uint32(%s[%s+1])<<8 | uint32(%s[%s+2])<<16 | uint32(%s[%s+3])<<24; %s += 4\n",
+			lenVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, posVar)
+	} else {
+		g.generateNeed(dataVar, posVar, "2")
 		fmt.Fprintf(g.buf, "%s := uint16(%s[%s]) | uint16(%s[%s+1])<<8; %s += 2\n", lenVar, dataVar, posVar, dataVar, posVar, posVar)
-		// Safe string copy - creates independent copy to avoid lifetime issues
-		fmt.Fprintf(g.buf, "%s = string(%s[%s:%s+int(%s)]); %s += int(%s)\n", resultVar, dataVar, posVar, posVar, lenVar, posVar, lenVar)
+	}
+	return lenVar
+}
+
+// generateNeed rejects data with fewer than n bytes left at pos with
+// DecodeTruncated, as the reference decoder does. Lenient fields report
+// ErrTruncated.
+func (g *goGenerator) generateNeed(dataVar, posVar, n string) {
+	fmt.Fprintf(g.buf, "if len(%s)-%s < %s {\nreturn %s\n}\n", dataVar, posVar, n, g.truncatedError())
+}
+
+// truncatedError returns the error of data that ends inside a value.
+func (g *goGenerator) truncatedError() string {
+	if g.lenient {
+		return "ErrTruncated"
+	}
+	return "&DecodeError{Code: DecodeTruncated, Msg: \"unexpected end of data\"}"
+}
+
+// generateCheckLength rejects a length prefix that exceeds the data left
+// with DecodeOverflow, as the reference decoder does. It also bounds
+// element counts before a slice or map is allocated: every element takes
+// at least a byte, so a corrupt count fails instead of allocating up to 4G
+// elements. Lenient fields report ErrTruncated.
+func (g *goGenerator) generateCheckLength(dataVar, posVar, lenVar string) {
+	err := "&DecodeError{Code: DecodeOverflow, Msg: \"length exceeds the data left\"}"
+	if g.lenient {
+		err = "ErrTruncated"
+	}
+	fmt.Fprintf(g.buf, "if int(%s) > len(%s)-%s {\nreturn %s\n}\n", lenVar, dataVar, posVar, err)
+}
+
+// generateCheckBool rejects a bool byte other than 0x00 and 0x01 with
+// DecodeInvalidValue.
+func (g *goGenerator) generateCheckBool(byteExpr string) {
+	fmt.Fprintf(g.buf, "if %s > 0x01 {\nreturn &DecodeError{Code: DecodeInvalidValue, Msg: fmt.Sprintf(\"invalid bool value 0x%%02x\", %s)}\n}\n", byteExpr, byteExpr)
+}
+
+// generateCheckUTF8 rejects a string that is not valid UTF-8 with
+// DecodeInvalidUtf8.
+func (g *goGenerator) generateCheckUTF8(dataVar, posVar, lenVar string) {
+	fmt.Fprintf(g.buf, "if !utf8.Valid(%s[%s : %s+int(%s)]) {\nreturn &DecodeError{Code: DecodeInvalidUtf8, Msg: \"invalid UTF-8 in string\"}\n}\n", dataVar, posVar, posVar, lenVar)
+}
+
+// goLengthType returns the Go type of typ's length prefix.
+func goLengthType(typ schema.Type) string {
+	if schema.IsLarge(typ) {
+		return "uint32"
+	}
+	return "uint16"
+}
+
+// generateDecodeEnumDirect decodes the base integer and rejects values
+// that are not constants of the enum.
+func (g *goGenerator) generateDecodeEnumDirect(dataVar, posVar, resultVar string, typ *schema.EnumType, isPointer bool) {
+	if typ.Optional {
+		g.generateReadPresence(dataVar, posVar)
+	}
+
+	rawVar := g.uniqueVar("raw")
+	tmpVar := g.uniqueVar("tmp")
+	fmt.Fprintf(g.buf, "var %s %s\n", rawVar, typ.Base)
+	g.decodeNonOptionalPrimitiveDirect(dataVar, posVar, rawVar, typ.BaseType())
+	fmt.Fprintf(g.buf, "%s := %s(%s)\n", tmpVar, typ.Name, rawVar)
+	fmt.Fprintf(g.buf, "if !%s.IsValid() {\n", tmpVar)
+	fmt.Fprintf(g.buf, "return &DecodeError{Code: DecodeInvalidValue, Msg: fmt.Sprintf(\"invalid %s value %%d\", %s)}\n", typ.Name, rawVar)
+	g.buf.WriteString("}\n")
+
+	if typ.Optional || isPointer {
+		fmt.Fprintf(g.buf, "%s = &%s\n", resultVar, tmpVar)
+	} else {
+		fmt.Fprintf(g.buf, "%s = %s\n", resultVar, tmpVar)
+	}
+
+	if typ.Optional {
+		g.buf.WriteString("}\n")
 	}
 }
 
 func (g *goGenerator) generateDecodeStructDirect(dataVar, posVar, resultVar string, typ *schema.StructType, isPointer bool) {
 	if typ.Optional {
-		presentVar := g.uniqueVar("present")
-		fmt.Fprintf(g.buf, "%s := %s[%s]; %s++\n", presentVar, dataVar, posVar, posVar)
-		fmt.Fprintf(g.buf, "if %s == 0x01 {\n", presentVar)
+		g.generateReadPresence(dataVar, posVar)
 
 		tmpVar := g.uniqueVar("tmp")
 		fmt.Fprintf(g.buf, "%s := &%s{}\n", tmpVar, typ.Name)
-		g.decodeStructFieldsDirect(dataVar, posVar, tmpVar, typ.Fields)
+		g.decodeStructFieldsDirect(dataVar, posVar, tmpVar, typ)
 		fmt.Fprintf(g.buf, "%s = %s\n", resultVar, tmpVar)
 
 		g.buf.WriteString("}\n")
@@ -811,15 +1584,27 @@ func (g *goGenerator) generateDecodeStructDirect(dataVar, posVar, resultVar stri
 	if isPointer {
 		tmpVar := g.uniqueVar("tmp")
 		fmt.Fprintf(g.buf, "%s := &%s{}\n", tmpVar, typ.Name)
-		g.decodeStructFieldsDirect(dataVar, posVar, tmpVar, typ.Fields)
+		g.decodeStructFieldsDirect(dataVar, posVar, tmpVar, typ)
 		fmt.Fprintf(g.buf, "%s = %s\n", resultVar, tmpVar)
 	} else {
-		g.decodeStructFieldsDirect(dataVar, posVar, resultVar, typ.Fields)
+		g.decodeStructFieldsDirect(dataVar, posVar, resultVar, typ)
 	}
 }
 
 // decodeStructFieldsDirect generates code to decode struct fields, using bulk decoding for fixed fields
-func (g *goGenerator) decodeStructFieldsDirect(dataVar, posVar, resultVar string, fields []schema.Field) {
+func (g *goGenerator) decodeStructFieldsDirect(dataVar, posVar, resultVar string, st *schema.StructType) {
+	fields := st.Fields
+	if st.Framed {
+		// The fields of a @framed struct decode from a slice that ends
+		// with the frame, so they cannot read past it
+		var endVar string
+		dataVar, endVar = g.generateReadFrame(dataVar, posVar)
+		defer fmt.Fprintf(g.buf, "if %s != %s {\nreturn &DecodeError{Code: DecodeTrailingData, Msg: fmt.Sprintf(\"%%d bytes left in the frame of %s\", %s-%s)}\n}\n",
+			posVar, endVar, st.Name, endVar, posVar)
+	}
+	// The presence bitmap of a @bitmap struct comes first
+	bits := g.generateReadBitmap(dataVar, posVar, st)
+
 	// Check for runs of fixed-size primitive fields for bulk decoding
 	runs := schema.GetFixedFieldRuns(fields)
 	
@@ -830,18 +1615,63 @@ func (g *goGenerator) decodeStructFieldsDirect(dataVar, posVar, resultVar string
 		
 		// Decode remaining fields normally
 		for i := run.EndIndex + 1; i < len(fields); i++ {
+			g.presentBit = bits[fields[i].Name]
 			g.generateDecodeValueDirect(dataVar, posVar, resultVar+"."+fields[i].Name, fields[i].Type, false)
 		}
 	} else {
 		// No significant fixed field run, decode all fields individually
 		for _, field := range fields {
+			g.presentBit = bits[field.Name]
 			g.generateDecodeValueDirect(dataVar, posVar, resultVar+"."+field.Name, field.Type, false)
 		}
 	}
 }
 
+// generateReadFrame reads the frame of a @framed struct, rejecting one
+// longer than the data left, and returns the names of the data up to the
+// end of the frame and of the end offset.
+func (g *goGenerator) generateReadFrame(dataVar, posVar string) (string, string) {
+	lenVar := g.uniqueVar("frame")
+	endVar := g.uniqueVar("frameEnd")
+	frameVar := g.uniqueVar("frameData")
+	g.generateNeed(dataVar, posVar, "4")
+	fmt.Fprintf(g.buf, "%s := int(uint32(%s[%s]) | uint32(%s[%s+1])<<8 | uint32(%s[%s+2])<<16 | uint32(%s[%s+3])<<24); %s += 4\n",
+		lenVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, dataVar, posVar, posVar)
+	fmt.Fprintf(g.buf, "if %s > len(%s)-%s {\nreturn &DecodeError{Code: DecodeOverflow, Msg: fmt.Sprintf(\"frame of %%d bytes exceeds the %%d bytes left\", %s, len(%s)-%s)}\n}\n",
+		lenVar, dataVar, posVar, lenVar, dataVar, posVar)
+	fmt.Fprintf(g.buf, "%s := %s + %s\n", endVar, posVar, lenVar)
+	fmt.Fprintf(g.buf, "%s := %s[:%s:%s]\n", frameVar, dataVar, endVar, endVar)
+	return frameVar, endVar
+}
+
+// generateReadBitmap reads the presence bitmap of a @bitmap struct,
+// rejecting set unused bits, and returns the bit test of each optional
+// field, by field name. It returns nil for other structs.
+func (g *goGenerator) generateReadBitmap(dataVar, posVar string, st *schema.StructType) map[string]string {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return nil
+	}
+	bitsVar := g.uniqueVar("bits")
+	g.generateNeed(dataVar, posVar, fmt.Sprint(n))
+	fmt.Fprintf(g.buf, "%s := %s[%s : %s+%d]; %s += %d\n", bitsVar, dataVar, posVar, posVar, n, posVar, n)
+	if used := schema.OptionalFields(st) % 8; used != 0 {
+		fmt.Fprintf(g.buf, "if %s[%d]&0x%02x != 0 {\nreturn &DecodeError{Code: DecodeBadPresence, Msg: \"unused presence bitmap bits set\"}\n}\n", bitsVar, n-1, 0xff<<used&0xff)
+	}
+	tests := make(map[string]string)
+	bit := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			tests[field.Name] = fmt.Sprintf("%s[%d]&0x%02x != 0", bitsVar, bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	return tests
+}
+
 // generateBulkStructDecode generates code to decode multiple fixed-size fields using binary.LittleEndian
 func (g *goGenerator) generateBulkStructDecode(dataVar, posVar, structVar string, fields []schema.Field, totalBytes int) {
+	g.generateNeed(dataVar, posVar, fmt.Sprint(totalBytes))
 	offset := 0
 	for _, field := range fields {
 		fieldVar := structVar + "." + field.Name
@@ -849,6 +1679,7 @@ func (g *goGenerator) generateBulkStructDecode(dataVar, posVar, structVar string
 		
 		switch primType.Name {
 		case "bool":
+			g.generateCheckBool(fmt.Sprintf("%s[%s+%d]", dataVar, posVar, offset))
 			fmt.Fprintf(g.buf, "%s = %s[%s+%d] == 0x01\n", fieldVar, dataVar, posVar, offset)
 			offset += 1
 		case "int8":
@@ -877,52 +1708,58 @@ func (g *goGenerator) generateBulkStructDecode(dataVar, posVar, structVar string
 
 func (g *goGenerator) generateDecodeArrayDirect(dataVar, posVar, resultVar string, typ *schema.ArrayType, isPointer bool) {
 	if typ.Optional {
-		presentVar := g.uniqueVar("present")
-		fmt.Fprintf(g.buf, "%s := %s[%s]; %s++\n", presentVar, dataVar, posVar, posVar)
-		fmt.Fprintf(g.buf, "if %s == 0x01 {\n", presentVar)
+		g.generateReadPresence(dataVar, posVar)
+	}
+
+	if typ.Length > 0 {
+		g.generateDecodeFixedArrayDirect(dataVar, posVar, resultVar, typ)
+		return
 	}
 
 	// Read array length
-	lenVar := g.uniqueVar("length")
-	fmt.Fprintf(g.buf, "%s := uint16(%s[%s]) | uint16(%s[%s+1])<<8; %s += 2\n", lenVar, dataVar, posVar, dataVar, posVar, posVar)
+	lenVar := g.generateReadLength(dataVar, posVar, typ)
+	g.generateCheckLength(dataVar, posVar, lenVar)
 
 	// Determine element type string
 	elemTypeStr := g.goTypeString(typ.ElementType)
 
 	// Optimization: use append(nil, src...) for primitive arrays to avoid make() zeroing overhead
+	// SliceData keeps empty arrays at the end of the data in bounds
 	sliceVar := g.uniqueVar("tmpSlice")
 	if primType, ok := typ.ElementType.(*schema.PrimitiveType); ok && !primType.Optional {
 		switch primType.Name {
-		case "int8", "bool":
-			// 1-byte types: append from unsafe.Slice avoids zeroing
-			fmt.Fprintf(g.buf, "%s := append([]%s(nil), unsafe.Slice((*%s)(unsafe.Pointer(&%s[%s])), int(%s))...)\n",
-				sliceVar, elemTypeStr, elemTypeStr, dataVar, posVar, lenVar)
-			fmt.Fprintf(g.buf, "%s += int(%s)\n", posVar, lenVar)
-		case "int16":
-			// 2-byte types
-			fmt.Fprintf(g.buf, "%s := append([]%s(nil), unsafe.Slice((*%s)(unsafe.Pointer(&%s[%s])), int(%s))...)\n",
-				sliceVar, elemTypeStr, elemTypeStr, dataVar, posVar, lenVar)
-			fmt.Fprintf(g.buf, "%s += int(%s) * 2\n", posVar, lenVar)
-		case "int32", "float32":
-			// 4-byte types
-			fmt.Fprintf(g.buf, "%s := append([]%s(nil), unsafe.Slice((*%s)(unsafe.Pointer(&%s[%s])), int(%s))...)\n",
-				sliceVar, elemTypeStr, elemTypeStr, dataVar, posVar, lenVar)
-			fmt.Fprintf(g.buf, "%s += int(%s) * 4\n", posVar, lenVar)
-		case "int64", "float64":
-			// 8-byte types
-			fmt.Fprintf(g.buf, "%s := append([]%s(nil), unsafe.Slice((*%s)(unsafe.Pointer(&%s[%s])), int(%s))...)\n",
-				sliceVar, elemTypeStr, elemTypeStr, dataVar, posVar, lenVar)
-			fmt.Fprintf(g.buf, "%s += int(%s) * 8\n", posVar, lenVar)
+		case "int8", "bool", "int16", "int32", "float32", "int64", "float64":
+			// append from unsafe.Slice avoids zeroing. The elements are
+			// checked against the data before they are reinterpreted.
+			size := schema.PrimitiveSize(primType.Name)
+			if size > 1 {
+				g.generateNeed(dataVar, posVar, fmt.Sprintf("int(%s)*%d", lenVar, size))
+			}
+			if primType.Name == "bool" {
+				g.generateCheckBools(dataVar, posVar, "int("+lenVar+")")
+			}
+			fmt.Fprintf(g.buf, "// unsafe: the wire format is the memory layout of []%s on little-endian hosts; the bytes are copied out\n", elemTypeStr)
+			if size == 1 {
+				fmt.Fprintf(g.buf, "%s := append([]%s(nil), unsafe.Slice((*%s)(unsafe.Pointer(unsafe.SliceData(%s[%s:%s+int(%s)]))), int(%s))...)\n",
+					sliceVar, elemTypeStr, elemTypeStr, dataVar, posVar, posVar, lenVar, lenVar)
+				fmt.Fprintf(g.buf, "%s += int(%s)\n", posVar, lenVar)
+			} else {
+				fmt.Fprintf(g.buf, "%s := append([]%s(nil), unsafe.Slice((*%s)(unsafe.Pointer(unsafe.SliceData(%s[%s:%s+int(%s)*%d]))), int(%s))...)\n",
+					sliceVar, elemTypeStr, elemTypeStr, dataVar, posVar, posVar, lenVar, size, lenVar)
+				fmt.Fprintf(g.buf, "%s += int(%s) * %d\n", posVar, lenVar, size)
+			}
 		case "string":
 			// Strings need element-by-element decode
 			fmt.Fprintf(g.buf, "%s := make([]%s, %s)\n", sliceVar, elemTypeStr, lenVar)
 			fmt.Fprintf(g.buf, "for i := range %s {\n", sliceVar)
 			strLenVar := g.uniqueVar("strLen")
+			g.generateNeed(dataVar, posVar, "2")
 			fmt.Fprintf(g.buf, "%s := uint16(%s[%s]) | uint16(%s[%s+1])<<8\n",
 				strLenVar, dataVar, posVar, dataVar, posVar)
 			fmt.Fprintf(g.buf, "%s += 2\n", posVar)
-			fmt.Fprintf(g.buf, "%s[i] = string(%s[%s:%s+int(%s)])\n",
-				sliceVar, dataVar, posVar, posVar, strLenVar)
+			g.generateCheckLength(dataVar, posVar, strLenVar)
+			g.generateCheckUTF8(dataVar, posVar, strLenVar)
+			fmt.Fprintf(g.buf, "%s[i] = %s\n", sliceVar, g.decodeStringExpr(dataVar, posVar, strLenVar))
 			fmt.Fprintf(g.buf, "%s += int(%s)\n", posVar, strLenVar)
 			fmt.Fprintf(g.buf, "}\n")
 		default:
@@ -949,3 +1786,204 @@ func (g *goGenerator) generateDecodeArrayDirect(dataVar, posVar, resultVar strin
 		fmt.Fprintf(g.buf, "%s = %s\n", resultVar, sliceVar)
 	}
 }
+
+// generateDecodeFixedArrayDirect decodes a fixed-size array in place.
+// Numbers are copied out of the wire bytes in one go; bools are checked
+// one by one like single bool fields.
+func (g *goGenerator) generateDecodeFixedArrayDirect(dataVar, posVar, resultVar string, typ *schema.ArrayType) {
+	prim := typ.ElementType.(*schema.PrimitiveType)
+	g.generateNeed(dataVar, posVar, fmt.Sprint(typ.Length*schema.PrimitiveSize(prim.Name)))
+	if prim.Name == "bool" {
+		g.generateCheckBools(dataVar, posVar, fmt.Sprint(typ.Length))
+		iVar := g.uniqueVar("i")
+		fmt.Fprintf(g.buf, "for %s := range %s {\n", iVar, resultVar)
+		fmt.Fprintf(g.buf, "%s[%s] = %s[%s+%s] == 0x01\n", resultVar, iVar, dataVar, posVar, iVar)
+		g.buf.WriteString("}\n")
+		fmt.Fprintf(g.buf, "%s += %d\n", posVar, typ.Length)
+		return
+	}
+	elemTypeStr := goPrimitiveType(prim.Name)
+	size := typ.Length * schema.PrimitiveSize(prim.Name)
+	fmt.Fprintf(g.buf, "// unsafe: the wire format is the memory layout of %s on little-endian hosts; the bytes are copied out\n", g.goTypeString(typ))
+	fmt.Fprintf(g.buf, "copy(%s[:], unsafe.Slice((*%s)(unsafe.Pointer(unsafe.SliceData(%s[%s:%s+%d]))), %d))\n",
+		resultVar, elemTypeStr, dataVar, posVar, posVar, size, typ.Length)
+	fmt.Fprintf(g.buf, "%s += %d\n", posVar, size)
+}
+
+// generateCheckBools rejects any of the n bool bytes at pos that is not
+// 0x00 or 0x01 with DecodeInvalidValue.
+func (g *goGenerator) generateCheckBools(dataVar, posVar, n string) {
+	bVar := g.uniqueVar("b")
+	fmt.Fprintf(g.buf, "for _, %s := range %s[%s : %s+%s] {\n", bVar, dataVar, posVar, posVar, n)
+	g.generateCheckBool(bVar)
+	g.buf.WriteString("}\n")
+}
+
+// generateDecodeMapDirect accepts entries in any order; a repeated key
+// keeps its last value.
+func (g *goGenerator) generateDecodeMapDirect(dataVar, posVar, resultVar string, typ *schema.MapType) {
+	if typ.Optional {
+		g.generateReadPresence(dataVar, posVar)
+	}
+
+	lenVar := g.generateReadLength(dataVar, posVar, typ)
+	g.generateCheckLength(dataVar, posVar, lenVar)
+
+	keyType := typ.KeyType.(*schema.PrimitiveType)
+	mapVar := g.uniqueVar("tmpMap")
+	keyVar := g.uniqueVar("k")
+	valVar := g.uniqueVar("val")
+	fmt.Fprintf(g.buf, "%s := make(map[%s]%s, %s)\n", mapVar, keyType.Name, g.goTypeString(typ.ValueType), lenVar)
+	fmt.Fprintf(g.buf, "for i := %s(0); i < %s; i++ {\n", goLengthType(typ), lenVar)
+	fmt.Fprintf(g.buf, "var %s %s\n", keyVar, keyType.Name)
+	g.decodeNonOptionalPrimitiveDirect(dataVar, posVar, keyVar, keyType)
+	fmt.Fprintf(g.buf, "var %s %s\n", valVar, g.goTypeString(typ.ValueType))
+	g.generateDecodeValueDirect(dataVar, posVar, valVar, typ.ValueType, false)
+	fmt.Fprintf(g.buf, "%s[%s] = %s\n", mapVar, keyVar, valVar)
+	g.buf.WriteString("}\n")
+
+	if typ.Optional {
+		fmt.Fprintf(g.buf, "%s = &%s\n", resultVar, mapVar)
+		g.buf.WriteString("}\n")
+	} else {
+		fmt.Fprintf(g.buf, "%s = %s\n", resultVar, mapVar)
+	}
+}
+
+// generateDecodeUnionDirect reads the tag and decodes the variant it
+// selects, rejecting tags that select none.
+func (g *goGenerator) generateDecodeUnionDirect(dataVar, posVar, resultVar string, typ *schema.UnionType) {
+	if typ.Optional {
+		g.generateReadPresence(dataVar, posVar)
+		tmpVar := g.uniqueVar("tmp")
+		fmt.Fprintf(g.buf, "%s := &%s{}\n", tmpVar, typ.Name)
+		fmt.Fprintf(g.buf, "%s = %s\n", resultVar, tmpVar)
+		resultVar = tmpVar
+	}
+
+	tagVar := g.uniqueVar("tag")
+	g.generateNeed(dataVar, posVar, "1")
+	fmt.Fprintf(g.buf, "%s := %s[%s]; %s++\n", tagVar, dataVar, posVar, posVar)
+	fmt.Fprintf(g.buf, "switch %s {\n", tagVar)
+	for i, v := range typ.Variants {
+		fmt.Fprintf(g.buf, "case %d:\n", i+1)
+		g.generateDecodeValueDirect(dataVar, posVar, resultVar+"."+goVariantField(v), v, true)
+	}
+	fmt.Fprintf(g.buf, "default:\nreturn &DecodeError{Code: DecodeInvalidValue, Msg: fmt.Sprintf(\"invalid %s tag %%d\", %s)}\n", typ.Name, tagVar)
+	g.buf.WriteString("}\n")
+
+	if typ.Optional {
+		g.buf.WriteString("}\n")
+	}
+}
+
+// goEncodeBufferRuntime grows AppendTo buffers and pools them for reuse.
+const goEncodeBufferRuntime = `// EncodeBuffer is a reusable encode buffer from a shared pool:
+//
+//	b := GetEncodeBuffer()
+//	b.B = msg.AppendTo(b.B)
+//	send(b.B)
+//	b.Release()
+//
+// b.B must not be used after Release.
+type EncodeBuffer struct {
+	B []byte
+}
+
+// maxPooledEncodeBuffer caps the buffers kept by the pool, so one huge
+// message does not pin its memory for the life of the process.
+const maxPooledEncodeBuffer = 64 << 10
+
+var encodeBufferPool = sync.Pool{New: func() any { return new(EncodeBuffer) }}
+
+// GetEncodeBuffer returns an empty buffer from the pool.
+func GetEncodeBuffer() *EncodeBuffer {
+	b := encodeBufferPool.Get().(*EncodeBuffer)
+	b.B = b.B[:0]
+	return b
+}
+
+// Release returns b to the pool.
+func (b *EncodeBuffer) Release() {
+	if cap(b.B) > maxPooledEncodeBuffer {
+		return
+	}
+	encodeBufferPool.Put(b)
+}
+
+// ffireGrow extends buf by n bytes, reallocating only when its capacity
+// is short. Every added byte is written by the encoder.
+func ffireGrow(buf []byte, n int) []byte {
+	if cap(buf)-len(buf) < n {
+		grown := make([]byte, len(buf), 2*cap(buf)+n)
+		copy(grown, buf)
+		buf = grown
+	}
+	return buf[:len(buf)+n]
+}
+
+`
+
+// goLenientRuntime runs the field decoders of lenient decoding.
+const goLenientRuntime = `// FieldError is a field that a lenient decode could not read.
+type FieldError struct {
+	Field string // Field name in the schema
+	Err   error
+}
+
+func (e FieldError) Error() string { return e.Field + ": " + e.Err.Error() }
+
+func (e FieldError) Unwrap() error { return e.Err }
+
+var (
+	// ErrTruncated reports a field that runs past the end of the data.
+	ErrTruncated error = &DecodeError{Code: DecodeTruncated, Msg: "unexpected end of data"}
+
+	// ErrNotDecoded reports a field after a failed field whose size is
+	// not fixed, since where the field starts is then unknown.
+	ErrNotDecoded = errors.New("not decoded: an earlier field failed")
+)
+
+// lenientField is one field of a lenient decode: its name, its wire size
+// if that is fixed (0 otherwise), and its decoder.
+type lenientField struct {
+	Name   string
+	Size   int
+	Decode func() error
+}
+
+// decodeLenient runs the field decoders in wire order and collects their
+// errors. A failed field of fixed size is skipped; after any other failure
+// the remaining fields are reported as ErrNotDecoded.
+func decodeLenient(data []byte, pos *int, fields []lenientField) []FieldError {
+	var errs []FieldError
+	for i, f := range fields {
+		start := *pos
+		err := recoverTruncated(f.Decode)
+		if err == nil {
+			continue
+		}
+		errs = append(errs, FieldError{Field: f.Name, Err: err})
+		if f.Size > 0 && start+f.Size <= len(data) {
+			*pos = start + f.Size
+			continue
+		}
+		for _, rest := range fields[i+1:] {
+			errs = append(errs, FieldError{Field: rest.Name, Err: ErrNotDecoded})
+		}
+		break
+	}
+	return errs
+}
+
+// recoverTruncated runs decode, turning the index panic of data that ends
+// early into ErrTruncated.
+func recoverTruncated(decode func() error) (err error) {
+	defer func() {
+		if recover() != nil {
+			err = ErrTruncated
+		}
+	}()
+	return decode()
+}
+`
@@ -0,0 +1,973 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Godot packages are GDExtensions built with godot-cpp. The C++ core does
+// the encoding; generated classes wrap its structs as RefCounted objects
+// with a property per field, so GDScript reads and writes messages like
+// any other object. Each struct becomes a class named after the package
+// and the type (GamePlayer for Player in package game), so schema names
+// cannot collide with engine classes. Message classes add encode() and a
+// static decode(), messages that are not structs become classes with a
+// static encode() and decode(), and enums become classes holding their
+// constants.
+
+// godotVersion is the Godot release the packages build against, with
+// godot-cpp at the matching tag. Extensions load in that release and
+// later ones.
+const godotVersion = "4.3"
+
+// GenerateGodotPackage writes a GDExtension for the schema: the C++
+// sources, an SConstruct that builds them with godot-cpp, and an addon
+// directory holding the .gdextension file and, once built, the libraries.
+func GenerateGodotPackage(config *PackageConfig) error {
+	godotDir := config.langDir("godot")
+	srcDir := filepath.Join(godotDir, "src")
+	pkg := config.Schema.Package
+	addonDir := filepath.Join(godotDir, "addons", pkg)
+	for _, dir := range []string{srcDir, addonDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	cppCode, err := GenerateCppWithOptions(config.Schema, CppOptions{SIMD: config.CppSIMD})
+	if err != nil {
+		return fmt.Errorf("failed to generate C++ code: %w", err)
+	}
+	g := newGodotGenerator(config.Schema)
+	files := []struct {
+		path    string
+		content []byte
+	}{
+		{filepath.Join(srcDir, "generated.hpp"), cppCode},
+		{filepath.Join(srcDir, pkg+"_godot.hpp"), g.generateHeader()},
+		{filepath.Join(srcDir, pkg+"_godot.cpp"), g.generateSource()},
+		{filepath.Join(srcDir, "register_types.cpp"), g.generateRegisterTypes()},
+		{filepath.Join(addonDir, pkg+".gdextension"), generateGodotExtensionConfig(pkg)},
+		{filepath.Join(godotDir, "SConstruct"), generateGodotSConstruct(pkg)},
+		{filepath.Join(godotDir, "README.md"), []byte(g.generateReadme() + readmeLicenseSection(config))},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, f.content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+		config.infof("✓ Generated %s\n", f.path)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Godot extension ready at: %s\n\n", godotDir)
+	fmt.Fprintln(&b, "Build:")
+	fmt.Fprintf(&b, "  cd %s\n", godotDir)
+	fmt.Fprintf(&b, "  git clone --depth 1 -b godot-%s-stable https://github.com/godotengine/godot-cpp\n", godotVersion)
+	fmt.Fprintln(&b, "  scons")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "Copy addons/%s into your Godot project, then:\n", pkg)
+	fmt.Fprintf(&b, "  var msg := %s.decode(data)\n", g.className(firstMessageName(config.Schema)))
+	fmt.Fprintln(&b, "  var encoded := msg.encode()")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
+	return nil
+}
+
+type godotGenerator struct {
+	schema  *schema.Schema
+	prefix  string               // Class name prefix, the package in PascalCase
+	structs []*schema.StructType // In dependency order
+	roots   map[string]bool      // Names of message structs
+}
+
+func newGodotGenerator(s *schema.Schema) *godotGenerator {
+	s.Canonicalize()
+	g := &godotGenerator{
+		schema:  s,
+		prefix:  ToPascalCase(s.Package),
+		structs: sortStructsByDependency(schemaStructs(s)),
+		roots:   make(map[string]bool),
+	}
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok {
+			g.roots[st.Name] = true
+		}
+	}
+	return g
+}
+
+// firstMessageName returns the name of the first message, for usage
+// examples.
+func firstMessageName(s *schema.Schema) string {
+	if len(s.Messages) == 0 {
+		return "Message"
+	}
+	return s.Messages[0].Name
+}
+
+// className returns the Godot class for the schema type name.
+func (g *godotGenerator) className(name string) string {
+	return g.prefix + name
+}
+
+// nativeType returns the C++ core type of t, qualified with the package
+// namespace since the wrappers are outside it. Message structs carry the
+// Message suffix the core gives them.
+func (g *godotGenerator) nativeType(t schema.Type) string {
+	var name string
+	switch t := t.(type) {
+	case *schema.PrimitiveType:
+		name = (&cppGenerator{}).cppPrimitiveType(t.Name)
+	case *schema.StructType:
+		name = "::" + g.schema.Package + "::" + t.Name
+		if g.roots[t.Name] {
+			name += "Message"
+		}
+	case *schema.EnumType:
+		name = "::" + g.schema.Package + "::" + t.Name
+	case *schema.UnionType:
+		name = "::" + g.schema.Package + "::" + t.Name
+	case *schema.ArrayType:
+		if t.Length > 0 {
+			return fmt.Sprintf("std::array<%s, %d>", g.nativeType(t.ElementType), t.Length)
+		}
+		name = "std::vector<" + g.nativeType(t.ElementType) + ">"
+	case *schema.MapType:
+		name = "std::map<" + g.nativeType(t.KeyType) + ", " + g.nativeType(t.ValueType) + ">"
+	}
+	if t.IsOptional() {
+		return "std::optional<" + name + ">"
+	}
+	return name
+}
+
+// godotType returns the type a value of t has on the Godot side. Optional
+// values other than structs are Variants, null when absent; optional
+// structs are null references.
+func (g *godotGenerator) godotType(t schema.Type) string {
+	if st, ok := t.(*schema.StructType); ok {
+		return "Ref<" + g.className(st.Name) + ">"
+	}
+	if t.IsOptional() {
+		return "Variant"
+	}
+	switch t := t.(type) {
+	case *schema.PrimitiveType:
+		switch t.Name {
+		case "bool":
+			return "bool"
+		case "float32", "float64":
+			return "double"
+		case "string":
+			return "String"
+		case "bytes":
+			return "PackedByteArray"
+		}
+		return "int64_t"
+	case *schema.EnumType:
+		return "int64_t"
+	case *schema.ArrayType:
+		if packed := godotPackedArray(t.ElementType); packed != "" && t.Length == 0 {
+			return packed
+		}
+		return "Array"
+	}
+	return "Dictionary" // Maps and unions
+}
+
+// godotPackedArray returns the packed array that holds elements of type t,
+// or "" if they go in an Array.
+func godotPackedArray(t schema.Type) string {
+	p, ok := t.(*schema.PrimitiveType)
+	if !ok || p.Optional {
+		return ""
+	}
+	switch p.Name {
+	case "int8", "int16", "int32":
+		return "PackedInt32Array"
+	case "int64":
+		return "PackedInt64Array"
+	case "float32":
+		return "PackedFloat32Array"
+	case "float64":
+		return "PackedFloat64Array"
+	case "string":
+		return "PackedStringArray"
+	}
+	return ""
+}
+
+// godotVariantTypes maps Godot types to their Variant::Type.
+var godotVariantTypes = map[string]string{
+	"bool":               "BOOL",
+	"int64_t":            "INT",
+	"double":             "FLOAT",
+	"String":             "STRING",
+	"PackedByteArray":    "PACKED_BYTE_ARRAY",
+	"PackedInt32Array":   "PACKED_INT32_ARRAY",
+	"PackedInt64Array":   "PACKED_INT64_ARRAY",
+	"PackedFloat32Array": "PACKED_FLOAT32_ARRAY",
+	"PackedFloat64Array": "PACKED_FLOAT64_ARRAY",
+	"PackedStringArray":  "PACKED_STRING_ARRAY",
+	"Array":              "ARRAY",
+	"Dictionary":         "DICTIONARY",
+	"Variant":            "NIL",
+}
+
+// propertyInfo returns the PropertyInfo of a field, with the hints the
+// editor and GDScript type checks use.
+func (g *godotGenerator) propertyInfo(name string, t schema.Type) string {
+	switch t := t.(type) {
+	case *schema.StructType:
+		return fmt.Sprintf("PropertyInfo(Variant::OBJECT, %q, PROPERTY_HINT_NONE, \"\", PROPERTY_USAGE_DEFAULT, %q)", name, g.className(t.Name))
+	case *schema.EnumType:
+		if !t.Optional {
+			values := make([]string, len(t.Values))
+			for i, v := range t.Values {
+				values[i] = fmt.Sprintf("%s:%d", v.Name, v.Value)
+			}
+			return fmt.Sprintf("PropertyInfo(Variant::INT, %q, PROPERTY_HINT_ENUM, %q)", name, strings.Join(values, ","))
+		}
+	case *schema.ArrayType:
+		if st, ok := t.ElementType.(*schema.StructType); ok && !t.Optional {
+			return fmt.Sprintf("PropertyInfo(Variant::ARRAY, %q, PROPERTY_HINT_ARRAY_TYPE, %q)", name, g.className(st.Name))
+		}
+	}
+	typ := g.godotType(t)
+	if typ == "Variant" {
+		return fmt.Sprintf("PropertyInfo(Variant::NIL, %q, PROPERTY_HINT_NONE, \"\", PROPERTY_USAGE_DEFAULT | PROPERTY_USAGE_NIL_IS_VARIANT)", name)
+	}
+	return fmt.Sprintf("PropertyInfo(Variant::%s, %q)", godotVariantTypes[typ], name)
+}
+
+// godotParam returns how a value of Godot type typ is passed: scalars by
+// value, everything else by const reference.
+func godotParam(typ string) string {
+	switch typ {
+	case "bool", "int64_t", "double":
+		return typ
+	}
+	return "const " + typ + " &"
+}
+
+// godotZero returns the initializer of a member of Godot type typ, or ""
+// if it is default-constructed.
+func godotZero(typ string) string {
+	switch typ {
+	case "bool":
+		return " = false"
+	case "int64_t":
+		return " = 0"
+	case "double":
+		return " = 0.0"
+	}
+	return ""
+}
+
+// godotProperty returns the property name of a field: snake_case, as
+// GDScript names properties, with acronyms kept together ("DeviceID" is
+// device_id, "HTTPPort" is http_port).
+func godotProperty(f schema.Field) string {
+	runes := []rune(f.Name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			afterLower := i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]))
+			endsAcronym := i > 0 && i+1 < len(runes) && unicode.IsUpper(runes[i-1]) && unicode.IsLower(runes[i+1])
+			if afterLower || endsAcronym {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// godotCodecFuncs returns the C++ core functions that encode and decode
+// messages of type t.
+func (g *godotGenerator) godotCodecFuncs(t schema.Type) (encode, decode string) {
+	name := strings.ToLower((&cppGenerator{schema: g.schema}).rootTypeName(t))
+	ns := "::" + g.schema.Package + "::"
+	return ns + "encode_" + name + "_message", ns + "decode_" + name + "_message"
+}
+
+func (g *godotGenerator) generateHeader() []byte {
+	buf := &bytes.Buffer{}
+	guard := strings.ToUpper(g.schema.Package) + "_GODOT_HPP"
+	buf.WriteString("// Code generated by ffire. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "#ifndef %s\n#define %s\n\n", guard, guard)
+	buf.WriteString("#include <godot_cpp/classes/ref_counted.hpp>\n")
+	buf.WriteString("#include <godot_cpp/core/class_db.hpp>\n")
+	buf.WriteString("#include <godot_cpp/variant/variant.hpp>\n\n")
+	buf.WriteString("#include \"generated.hpp\"\n\n")
+	buf.WriteString("namespace godot {\n\n")
+
+	for _, st := range g.structs {
+		fmt.Fprintf(buf, "class %s;\n", g.className(st.Name))
+	}
+	if len(g.structs) > 0 {
+		buf.WriteString("\n")
+	}
+
+	for _, enum := range g.schema.Enums() {
+		buf.WriteString(lineDoc("", "///", enum.Doc))
+		fmt.Fprintf(buf, "// %s holds the constants of %s.\n", g.className(enum.Name), enum.Name)
+		fmt.Fprintf(buf, "class %s : public Object {\n", g.className(enum.Name))
+		fmt.Fprintf(buf, "    GDCLASS(%s, Object)\n\n", g.className(enum.Name))
+		buf.WriteString("protected:\n")
+		buf.WriteString("    static void _bind_methods();\n")
+		buf.WriteString("};\n\n")
+	}
+
+	for _, st := range g.structs {
+		g.generateClassDecl(buf, st)
+	}
+
+	for _, msg := range g.schema.Messages {
+		if _, ok := msg.TargetType.(*schema.StructType); ok {
+			continue
+		}
+		class := g.className(msg.Name)
+		typ := g.godotType(msg.TargetType)
+		fmt.Fprintf(buf, "// %s encodes and decodes %s messages.\n", class, msg.Name)
+		fmt.Fprintf(buf, "class %s : public Object {\n", class)
+		fmt.Fprintf(buf, "    GDCLASS(%s, Object)\n\n", class)
+		buf.WriteString("protected:\n")
+		buf.WriteString("    static void _bind_methods();\n\n")
+		buf.WriteString("public:\n")
+		fmt.Fprintf(buf, "    static PackedByteArray encode(%sp_value);\n", spaced(godotParam(typ)))
+		fmt.Fprintf(buf, "    static %s decode(const PackedByteArray &p_data);\n", typ)
+		buf.WriteString("};\n\n")
+	}
+
+	buf.WriteString("} // namespace godot\n\n")
+	fmt.Fprintf(buf, "#endif // %s\n", guard)
+	return buf.Bytes()
+}
+
+// spaced returns a parameter type followed by the space before its name,
+// unless it ends in a reference.
+func spaced(param string) string {
+	if strings.HasSuffix(param, "&") {
+		return param
+	}
+	return param + " "
+}
+
+func (g *godotGenerator) generateClassDecl(buf *bytes.Buffer, st *schema.StructType) {
+	class := g.className(st.Name)
+	isRoot := g.roots[st.Name]
+	buf.WriteString(lineDoc("", "///", st.Doc))
+	fmt.Fprintf(buf, "class %s : public RefCounted {\n", class)
+	fmt.Fprintf(buf, "    GDCLASS(%s, RefCounted)\n\n", class)
+	for _, f := range st.Fields {
+		typ := g.godotType(f.Type)
+		buf.WriteString(lineDoc("    ", "///", f.Doc))
+		fmt.Fprintf(buf, "    %s %s_%s;\n", typ, godotProperty(f), godotZero(typ))
+	}
+	if len(st.Fields) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("protected:\n")
+	buf.WriteString("    static void _bind_methods();\n\n")
+	buf.WriteString("public:\n")
+	if g.hasRequiredStructs(st) {
+		fmt.Fprintf(buf, "    %s();\n\n", class)
+	}
+	for _, f := range st.Fields {
+		typ := g.godotType(f.Type)
+		prop := godotProperty(f)
+		fmt.Fprintf(buf, "    %s get_%s() const;\n", typ, prop)
+		fmt.Fprintf(buf, "    void set_%s(%sp_value);\n", prop, spaced(godotParam(typ)))
+	}
+	if len(st.Fields) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("    // Conversions from and to the C++ core, for other native code.\n")
+	fmt.Fprintf(buf, "    void from_native(const %s &p_value);\n", g.nativeType(st))
+	fmt.Fprintf(buf, "    void to_native(%s &r_value) const;\n", g.nativeType(st))
+	if isRoot {
+		buf.WriteString("\n")
+		buf.WriteString("    PackedByteArray encode() const;\n")
+		fmt.Fprintf(buf, "    static Ref<%s> decode(const PackedByteArray &p_data);\n", class)
+	}
+	buf.WriteString("};\n\n")
+}
+
+// hasRequiredStructs reports whether st has struct fields that are not
+// optional. The constructor creates them, so they are never null.
+func (g *godotGenerator) hasRequiredStructs(st *schema.StructType) bool {
+	for _, f := range st.Fields {
+		if s, ok := f.Type.(*schema.StructType); ok && !s.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+// godotConversions converts between the C++ core types and the Godot
+// types. Overloads for the types the generator knows are declared first,
+// then the templates for containers, so every template sees all of them.
+const godotConversions = `// Conversions between C++ core values and Godot values. to_godot returns
+// the Godot type of a field; from_godot reads any Variant, and leaves the
+// zero value when the Variant holds something else.
+
+inline bool to_godot(bool p_value) { return p_value; }
+inline int64_t to_godot(int8_t p_value) { return p_value; }
+inline int64_t to_godot(int16_t p_value) { return p_value; }
+inline int64_t to_godot(int32_t p_value) { return p_value; }
+inline int64_t to_godot(int64_t p_value) { return p_value; }
+inline double to_godot(float p_value) { return p_value; }
+inline double to_godot(double p_value) { return p_value; }
+
+inline String to_godot(const std::string &p_value) {
+    return String::utf8(p_value.data(), static_cast<int>(p_value.size()));
+}
+
+inline PackedByteArray to_godot(const std::vector<uint8_t> &p_value) {
+    PackedByteArray out;
+    out.resize(static_cast<int64_t>(p_value.size()));
+    if (!p_value.empty()) {
+        std::memcpy(out.ptrw(), p_value.data(), p_value.size());
+    }
+    return out;
+}
+
+template <typename P, typename T>
+P to_packed(const std::vector<T> &p_value) {
+    P out;
+    out.resize(static_cast<int64_t>(p_value.size()));
+    for (size_t i = 0; i < p_value.size(); i++) {
+        out.set(static_cast<int64_t>(i), p_value[i]);
+    }
+    return out;
+}
+
+inline PackedInt32Array to_godot(const std::vector<int8_t> &p_value) { return to_packed<PackedInt32Array>(p_value); }
+inline PackedInt32Array to_godot(const std::vector<int16_t> &p_value) { return to_packed<PackedInt32Array>(p_value); }
+inline PackedInt32Array to_godot(const std::vector<int32_t> &p_value) { return to_packed<PackedInt32Array>(p_value); }
+inline PackedInt64Array to_godot(const std::vector<int64_t> &p_value) { return to_packed<PackedInt64Array>(p_value); }
+inline PackedFloat32Array to_godot(const std::vector<float> &p_value) { return to_packed<PackedFloat32Array>(p_value); }
+inline PackedFloat64Array to_godot(const std::vector<double> &p_value) { return to_packed<PackedFloat64Array>(p_value); }
+
+inline PackedStringArray to_godot(const std::vector<std::string> &p_value) {
+    PackedStringArray out;
+    for (const std::string &s : p_value) {
+        out.push_back(to_godot(s));
+    }
+    return out;
+}
+
+inline void from_godot(const Variant &p_variant, bool &r_value) { r_value = p_variant; }
+inline void from_godot(const Variant &p_variant, int8_t &r_value) { r_value = static_cast<int8_t>(static_cast<int64_t>(p_variant)); }
+inline void from_godot(const Variant &p_variant, int16_t &r_value) { r_value = static_cast<int16_t>(static_cast<int64_t>(p_variant)); }
+inline void from_godot(const Variant &p_variant, int32_t &r_value) { r_value = static_cast<int32_t>(static_cast<int64_t>(p_variant)); }
+inline void from_godot(const Variant &p_variant, int64_t &r_value) { r_value = p_variant; }
+inline void from_godot(const Variant &p_variant, float &r_value) { r_value = static_cast<float>(static_cast<double>(p_variant)); }
+inline void from_godot(const Variant &p_variant, double &r_value) { r_value = p_variant; }
+
+inline void from_godot(const Variant &p_variant, std::string &r_value) {
+    String value = p_variant;
+    CharString utf8 = value.utf8();
+    r_value.assign(utf8.get_data(), static_cast<size_t>(utf8.length()));
+}
+
+inline void from_godot(const Variant &p_variant, std::vector<uint8_t> &r_value) {
+    PackedByteArray value = p_variant;
+    r_value.assign(value.ptr(), value.ptr() + value.size());
+}
+
+template <typename P, typename T>
+void from_packed(const Variant &p_variant, std::vector<T> &r_value) {
+    P value = p_variant;
+    r_value.resize(static_cast<size_t>(value.size()));
+    for (int64_t i = 0; i < value.size(); i++) {
+        r_value[static_cast<size_t>(i)] = static_cast<T>(value[i]);
+    }
+}
+
+inline void from_godot(const Variant &p_variant, std::vector<int8_t> &r_value) { from_packed<PackedInt32Array>(p_variant, r_value); }
+inline void from_godot(const Variant &p_variant, std::vector<int16_t> &r_value) { from_packed<PackedInt32Array>(p_variant, r_value); }
+inline void from_godot(const Variant &p_variant, std::vector<int32_t> &r_value) { from_packed<PackedInt32Array>(p_variant, r_value); }
+inline void from_godot(const Variant &p_variant, std::vector<int64_t> &r_value) { from_packed<PackedInt64Array>(p_variant, r_value); }
+inline void from_godot(const Variant &p_variant, std::vector<float> &r_value) { from_packed<PackedFloat32Array>(p_variant, r_value); }
+inline void from_godot(const Variant &p_variant, std::vector<double> &r_value) { from_packed<PackedFloat64Array>(p_variant, r_value); }
+
+inline void from_godot(const Variant &p_variant, std::vector<std::string> &r_value) {
+    PackedStringArray value = p_variant;
+    r_value.resize(static_cast<size_t>(value.size()));
+    for (int64_t i = 0; i < value.size(); i++) {
+        from_godot(value[i], r_value[static_cast<size_t>(i)]);
+    }
+}
+`
+
+// godotTemplates converts enums and the containers of the C++ core.
+// Containers of structs, enums, bools and other containers are Arrays, and
+// maps are Dictionaries.
+const godotTemplates = `template <typename E, std::enable_if_t<std::is_enum_v<E>, int> = 0>
+int64_t to_godot(E p_value);
+template <typename T>
+Variant to_godot(const std::optional<T> &p_value);
+template <typename T>
+Array to_godot(const std::vector<T> &p_value);
+template <typename T, size_t N>
+Array to_godot(const std::array<T, N> &p_value);
+template <typename K, typename V>
+Dictionary to_godot(const std::map<K, V> &p_value);
+
+template <typename E, std::enable_if_t<std::is_enum_v<E>, int> = 0>
+void from_godot(const Variant &p_variant, E &r_value);
+template <typename T>
+void from_godot(const Variant &p_variant, std::optional<T> &r_value);
+template <typename T>
+void from_godot(const Variant &p_variant, std::vector<T> &r_value);
+template <typename T, size_t N>
+void from_godot(const Variant &p_variant, std::array<T, N> &r_value);
+template <typename K, typename V>
+void from_godot(const Variant &p_variant, std::map<K, V> &r_value);
+
+template <typename E, std::enable_if_t<std::is_enum_v<E>, int>>
+int64_t to_godot(E p_value) {
+    return static_cast<int64_t>(p_value);
+}
+
+template <typename T>
+Variant to_godot(const std::optional<T> &p_value) {
+    if (!p_value) {
+        return Variant();
+    }
+    return to_godot(*p_value);
+}
+
+template <typename T>
+Array to_godot(const std::vector<T> &p_value) {
+    Array out;
+    for (const T &element : p_value) {
+        out.push_back(to_godot(element));
+    }
+    return out;
+}
+
+template <typename T, size_t N>
+Array to_godot(const std::array<T, N> &p_value) {
+    Array out;
+    for (const T &element : p_value) {
+        out.push_back(to_godot(element));
+    }
+    return out;
+}
+
+template <typename K, typename V>
+Dictionary to_godot(const std::map<K, V> &p_value) {
+    Dictionary out;
+    for (const auto &entry : p_value) {
+        out[to_godot(entry.first)] = to_godot(entry.second);
+    }
+    return out;
+}
+
+template <typename E, std::enable_if_t<std::is_enum_v<E>, int>>
+void from_godot(const Variant &p_variant, E &r_value) {
+    r_value = static_cast<E>(static_cast<int64_t>(p_variant));
+}
+
+template <typename T>
+void from_godot(const Variant &p_variant, std::optional<T> &r_value) {
+    if (p_variant.get_type() == Variant::NIL) {
+        r_value.reset();
+        return;
+    }
+    T value{};
+    from_godot(p_variant, value);
+    r_value = std::move(value);
+}
+
+template <typename T>
+void from_godot(const Variant &p_variant, std::vector<T> &r_value) {
+    Array value = p_variant;
+    r_value.clear();
+    r_value.reserve(static_cast<size_t>(value.size()));
+    for (int64_t i = 0; i < value.size(); i++) {
+        T element{};
+        from_godot(value[i], element);
+        r_value.push_back(std::move(element));
+    }
+}
+
+// Elements past N are dropped, and missing ones are zero.
+template <typename T, size_t N>
+void from_godot(const Variant &p_variant, std::array<T, N> &r_value) {
+    Array value = p_variant;
+    for (size_t i = 0; i < N; i++) {
+        r_value[i] = T{};
+        if (static_cast<int64_t>(i) < value.size()) {
+            from_godot(value[static_cast<int64_t>(i)], r_value[i]);
+        }
+    }
+}
+
+template <typename K, typename V>
+void from_godot(const Variant &p_variant, std::map<K, V> &r_value) {
+    Dictionary value = p_variant;
+    Array keys = value.keys();
+    r_value.clear();
+    for (int64_t i = 0; i < keys.size(); i++) {
+        K key{};
+        V element{};
+        from_godot(keys[i], key);
+        from_godot(value[keys[i]], element);
+        r_value[std::move(key)] = std::move(element);
+    }
+}
+`
+
+func (g *godotGenerator) generateSource() []byte {
+	buf := &bytes.Buffer{}
+	pkg := g.schema.Package
+	buf.WriteString("// Code generated by ffire. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "#include \"%s_godot.hpp\"\n\n", pkg)
+	buf.WriteString("#include <cstring>\n")
+	buf.WriteString("#include <exception>\n")
+	buf.WriteString("#include <type_traits>\n\n")
+	buf.WriteString("#include <godot_cpp/core/error_macros.hpp>\n\n")
+	buf.WriteString("using namespace godot;\n\n")
+	buf.WriteString("namespace {\n\n")
+	buf.WriteString(godotConversions)
+	buf.WriteString("\n")
+
+	// Structs are objects, and optional structs null references rather
+	// than Variants, so they have their own overloads for std::optional
+	for _, st := range g.structs {
+		class, native := g.className(st.Name), g.nativeType(st)
+		fmt.Fprintf(buf, "inline Ref<%s> to_godot(const %s &p_value);\n", class, native)
+		fmt.Fprintf(buf, "inline Ref<%s> to_godot(const std::optional<%s> &p_value);\n", class, native)
+		fmt.Fprintf(buf, "inline void from_godot(const Variant &p_variant, %s &r_value);\n", native)
+		fmt.Fprintf(buf, "inline void from_godot(const Variant &p_variant, std::optional<%s> &r_value);\n", native)
+	}
+	for _, u := range g.schema.Unions() {
+		fmt.Fprintf(buf, "inline Dictionary to_godot(const ::%s::%s &p_value);\n", g.schema.Package, u.Name)
+		fmt.Fprintf(buf, "inline void from_godot(const Variant &p_variant, ::%s::%s &r_value);\n", g.schema.Package, u.Name)
+	}
+	buf.WriteString("\n")
+	buf.WriteString(godotTemplates)
+
+	for _, st := range g.structs {
+		class, native := g.className(st.Name), g.nativeType(st)
+		fmt.Fprintf(buf, "\nRef<%s> to_godot(const %s &p_value) {\n", class, native)
+		fmt.Fprintf(buf, "    Ref<%s> out;\n", class)
+		buf.WriteString("    out.instantiate();\n")
+		buf.WriteString("    out->from_native(p_value);\n")
+		buf.WriteString("    return out;\n")
+		buf.WriteString("}\n\n")
+		fmt.Fprintf(buf, "Ref<%s> to_godot(const std::optional<%s> &p_value) {\n", class, native)
+		fmt.Fprintf(buf, "    return p_value ? to_godot(*p_value) : Ref<%s>();\n", class)
+		buf.WriteString("}\n\n")
+		fmt.Fprintf(buf, "void from_godot(const Variant &p_variant, %s &r_value) {\n", native)
+		buf.WriteString("    r_value = {};\n")
+		fmt.Fprintf(buf, "    if (%s *value = Object::cast_to<%s>(p_variant)) {\n", class, class)
+		buf.WriteString("        value->to_native(r_value);\n")
+		buf.WriteString("    }\n")
+		buf.WriteString("}\n\n")
+		fmt.Fprintf(buf, "void from_godot(const Variant &p_variant, std::optional<%s> &r_value) {\n", native)
+		buf.WriteString("    r_value.reset();\n")
+		fmt.Fprintf(buf, "    if (%s *value = Object::cast_to<%s>(p_variant)) {\n", class, class)
+		buf.WriteString("        value->to_native(r_value.emplace());\n")
+		buf.WriteString("    }\n")
+		buf.WriteString("}\n")
+	}
+	for _, u := range g.schema.Unions() {
+		g.generateUnionConversions(buf, u)
+	}
+	buf.WriteString("\n} // namespace\n")
+
+	for _, enum := range g.schema.Enums() {
+		class := g.className(enum.Name)
+		fmt.Fprintf(buf, "\nvoid %s::_bind_methods() {\n", class)
+		for _, v := range enum.Values {
+			fmt.Fprintf(buf, "    ClassDB::bind_integer_constant(get_class_static(), %q, %q, %d);\n", enum.Name, v.Name, v.Value)
+		}
+		buf.WriteString("}\n")
+	}
+	for _, st := range g.structs {
+		g.generateClassMethods(buf, st)
+	}
+	for _, msg := range g.schema.Messages {
+		if _, ok := msg.TargetType.(*schema.StructType); !ok {
+			g.generateMessageMethods(buf, msg)
+		}
+	}
+	return buf.Bytes()
+}
+
+// generateUnionConversions converts a union to and from a Dictionary with
+// one key naming the variant, the form ffire uses for unions in JSON.
+func (g *godotGenerator) generateUnionConversions(buf *bytes.Buffer, u *schema.UnionType) {
+	native := "::" + g.schema.Package + "::" + u.Name
+	fmt.Fprintf(buf, "\nDictionary to_godot(const %s &p_value) {\n", native)
+	buf.WriteString("    Dictionary out;\n")
+	buf.WriteString("    switch (p_value.index()) {\n")
+	for i, v := range u.Variants {
+		fmt.Fprintf(buf, "    case %d:\n", i)
+		fmt.Fprintf(buf, "        out[%q] = to_godot(std::get<%d>(p_value));\n", v.TypeName(), i)
+		buf.WriteString("        break;\n")
+	}
+	buf.WriteString("    }\n")
+	buf.WriteString("    return out;\n")
+	buf.WriteString("}\n\n")
+
+	names := make([]string, len(u.Variants))
+	fmt.Fprintf(buf, "void from_godot(const Variant &p_variant, %s &r_value) {\n", native)
+	buf.WriteString("    Dictionary value = p_variant;\n")
+	for i, v := range u.Variants {
+		names[i] = v.TypeName()
+		if i == 0 {
+			fmt.Fprintf(buf, "    if (value.has(%q)) {\n", v.TypeName())
+		} else {
+			fmt.Fprintf(buf, "    } else if (value.has(%q)) {\n", v.TypeName())
+		}
+		fmt.Fprintf(buf, "        %s variant{};\n", g.nativeType(v))
+		fmt.Fprintf(buf, "        from_godot(value[%q], variant);\n", v.TypeName())
+		fmt.Fprintf(buf, "        r_value.emplace<%d>(std::move(variant));\n", i)
+	}
+	buf.WriteString("    } else {\n")
+	fmt.Fprintf(buf, "        ERR_FAIL_MSG(\"%s needs one of the keys %s\");\n", u.Name, strings.Join(names, ", "))
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n")
+}
+
+func (g *godotGenerator) generateClassMethods(buf *bytes.Buffer, st *schema.StructType) {
+	class := g.className(st.Name)
+	native := g.nativeType(st)
+
+	fmt.Fprintf(buf, "\nvoid %s::_bind_methods() {\n", class)
+	for _, f := range st.Fields {
+		prop := godotProperty(f)
+		fmt.Fprintf(buf, "    ClassDB::bind_method(D_METHOD(\"get_%s\"), &%s::get_%s);\n", prop, class, prop)
+		fmt.Fprintf(buf, "    ClassDB::bind_method(D_METHOD(\"set_%s\", \"value\"), &%s::set_%s);\n", prop, class, prop)
+		fmt.Fprintf(buf, "    ADD_PROPERTY(%s, \"set_%s\", \"get_%s\");\n", g.propertyInfo(prop, f.Type), prop, prop)
+	}
+	if g.roots[st.Name] {
+		fmt.Fprintf(buf, "    ClassDB::bind_method(D_METHOD(\"encode\"), &%s::encode);\n", class)
+		fmt.Fprintf(buf, "    ClassDB::bind_static_method(get_class_static(), D_METHOD(\"decode\", \"data\"), &%s::decode);\n", class)
+	}
+	buf.WriteString("}\n")
+
+	if g.hasRequiredStructs(st) {
+		fmt.Fprintf(buf, "\n%s::%s() {\n", class, class)
+		for _, f := range st.Fields {
+			if s, ok := f.Type.(*schema.StructType); ok && !s.Optional {
+				fmt.Fprintf(buf, "    %s_.instantiate();\n", godotProperty(f))
+			}
+		}
+		buf.WriteString("}\n")
+	}
+
+	for _, f := range st.Fields {
+		typ := g.godotType(f.Type)
+		prop := godotProperty(f)
+		fmt.Fprintf(buf, "\n%s %s::get_%s() const {\n", typ, class, prop)
+		fmt.Fprintf(buf, "    return %s_;\n", prop)
+		buf.WriteString("}\n\n")
+		fmt.Fprintf(buf, "void %s::set_%s(%sp_value) {\n", class, prop, spaced(godotParam(typ)))
+		fmt.Fprintf(buf, "    %s_ = p_value;\n", prop)
+		buf.WriteString("}\n")
+	}
+
+	fmt.Fprintf(buf, "\nvoid %s::from_native(const %s &p_value) {\n", class, native)
+	for _, f := range st.Fields {
+		fmt.Fprintf(buf, "    %s_ = to_godot(p_value.%s);\n", godotProperty(f), f.Name)
+	}
+	buf.WriteString("}\n\n")
+	fmt.Fprintf(buf, "void %s::to_native(%s &r_value) const {\n", class, native)
+	for _, f := range st.Fields {
+		fmt.Fprintf(buf, "    from_godot(%s_, r_value.%s);\n", godotProperty(f), f.Name)
+	}
+	buf.WriteString("}\n")
+
+	if !g.roots[st.Name] {
+		return
+	}
+	encode, decode := g.godotCodecFuncs(st)
+	fmt.Fprintf(buf, "\nPackedByteArray %s::encode() const {\n", class)
+	fmt.Fprintf(buf, "    %s value;\n", native)
+	buf.WriteString("    to_native(value);\n")
+	buf.WriteString("    try {\n")
+	fmt.Fprintf(buf, "        return to_godot(%s(value));\n", encode)
+	buf.WriteString("    } catch (const std::exception &e) {\n")
+	fmt.Fprintf(buf, "        ERR_FAIL_V_MSG(PackedByteArray(), String(\"%s.encode: \") + e.what());\n", class)
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+	fmt.Fprintf(buf, "Ref<%s> %s::decode(const PackedByteArray &p_data) {\n", class, class)
+	buf.WriteString("    try {\n")
+	fmt.Fprintf(buf, "        return to_godot(%s(p_data.ptr(), static_cast<size_t>(p_data.size())));\n", decode)
+	buf.WriteString("    } catch (const std::exception &e) {\n")
+	fmt.Fprintf(buf, "        ERR_FAIL_V_MSG(Ref<%s>(), String(\"%s.decode: \") + e.what());\n", class, class)
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n")
+}
+
+// generateMessageMethods writes the static encode and decode of a message
+// that is not a struct.
+func (g *godotGenerator) generateMessageMethods(buf *bytes.Buffer, msg schema.MessageType) {
+	class := g.className(msg.Name)
+	typ := g.godotType(msg.TargetType)
+	encode, decode := g.godotCodecFuncs(msg.TargetType)
+
+	fmt.Fprintf(buf, "\nvoid %s::_bind_methods() {\n", class)
+	fmt.Fprintf(buf, "    ClassDB::bind_static_method(get_class_static(), D_METHOD(\"encode\", \"value\"), &%s::encode);\n", class)
+	fmt.Fprintf(buf, "    ClassDB::bind_static_method(get_class_static(), D_METHOD(\"decode\", \"data\"), &%s::decode);\n", class)
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "PackedByteArray %s::encode(%sp_value) {\n", class, spaced(godotParam(typ)))
+	fmt.Fprintf(buf, "    %s value{};\n", g.nativeType(msg.TargetType))
+	buf.WriteString("    from_godot(p_value, value);\n")
+	buf.WriteString("    try {\n")
+	fmt.Fprintf(buf, "        return to_godot(%s(value));\n", encode)
+	buf.WriteString("    } catch (const std::exception &e) {\n")
+	fmt.Fprintf(buf, "        ERR_FAIL_V_MSG(PackedByteArray(), String(\"%s.encode: \") + e.what());\n", class)
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+	fmt.Fprintf(buf, "%s %s::decode(const PackedByteArray &p_data) {\n", typ, class)
+	buf.WriteString("    try {\n")
+	fmt.Fprintf(buf, "        return to_godot(%s(p_data.ptr(), static_cast<size_t>(p_data.size())));\n", decode)
+	buf.WriteString("    } catch (const std::exception &e) {\n")
+	fmt.Fprintf(buf, "        ERR_FAIL_V_MSG(%s(), String(\"%s.decode: \") + e.what());\n", typ, class)
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n")
+}
+
+// generateRegisterTypes returns the entry point Godot calls when it loads
+// the extension, which registers the classes.
+func (g *godotGenerator) generateRegisterTypes() []byte {
+	buf := &bytes.Buffer{}
+	pkg := g.schema.Package
+	buf.WriteString("// Code generated by ffire. DO NOT EDIT.\n\n")
+	buf.WriteString("#include <gdextension_interface.h>\n")
+	buf.WriteString("#include <godot_cpp/core/class_db.hpp>\n")
+	buf.WriteString("#include <godot_cpp/core/defs.hpp>\n")
+	buf.WriteString("#include <godot_cpp/godot.hpp>\n\n")
+	fmt.Fprintf(buf, "#include \"%s_godot.hpp\"\n\n", pkg)
+	buf.WriteString("using namespace godot;\n\n")
+
+	fmt.Fprintf(buf, "void initialize_%s_module(ModuleInitializationLevel p_level) {\n", pkg)
+	buf.WriteString("    if (p_level != MODULE_INITIALIZATION_LEVEL_SCENE) {\n")
+	buf.WriteString("        return;\n")
+	buf.WriteString("    }\n")
+	for _, enum := range g.schema.Enums() {
+		fmt.Fprintf(buf, "    GDREGISTER_ABSTRACT_CLASS(%s);\n", g.className(enum.Name))
+	}
+	for _, st := range g.structs {
+		fmt.Fprintf(buf, "    GDREGISTER_CLASS(%s);\n", g.className(st.Name))
+	}
+	for _, msg := range g.schema.Messages {
+		if _, ok := msg.TargetType.(*schema.StructType); !ok {
+			fmt.Fprintf(buf, "    GDREGISTER_ABSTRACT_CLASS(%s);\n", g.className(msg.Name))
+		}
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "void uninitialize_%s_module(ModuleInitializationLevel p_level) {\n", pkg)
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("extern \"C\" {\n\n")
+	fmt.Fprintf(buf, "GDExtensionBool GDE_EXPORT %s_library_init(GDExtensionInterfaceGetProcAddress p_get_proc_address, const GDExtensionClassLibraryPtr p_library, GDExtensionInitialization *r_initialization) {\n", pkg)
+	buf.WriteString("    GDExtensionBinding::InitObject init_obj(p_get_proc_address, p_library, r_initialization);\n")
+	fmt.Fprintf(buf, "    init_obj.register_initializer(initialize_%s_module);\n", pkg)
+	fmt.Fprintf(buf, "    init_obj.register_terminator(uninitialize_%s_module);\n", pkg)
+	buf.WriteString("    init_obj.set_minimum_library_initialization_level(MODULE_INITIALIZATION_LEVEL_SCENE);\n")
+	buf.WriteString("    return init_obj.init();\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("} // extern \"C\"\n")
+	return buf.Bytes()
+}
+
+// generateGodotExtensionConfig returns the .gdextension file, which tells
+// Godot the entry point and which library to load on each platform. The
+// names are the ones the SConstruct gives the libraries.
+func generateGodotExtensionConfig(pkg string) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("; Code generated by ffire. DO NOT EDIT.\n\n")
+	buf.WriteString("[configuration]\n\n")
+	fmt.Fprintf(buf, "entry_symbol = \"%s_library_init\"\n", pkg)
+	fmt.Fprintf(buf, "compatibility_minimum = \"%s\"\n\n", godotVersion)
+	buf.WriteString("[libraries]\n\n")
+	bin := "res://addons/" + pkg + "/bin/lib" + pkg
+	for _, target := range []string{"debug", "release"} {
+		fmt.Fprintf(buf, "macos.%s = \"%s.macos.template_%s.framework\"\n", target, bin, target)
+		for _, platform := range []struct{ name, arch, ext string }{
+			{"windows", "x86_64", "dll"},
+			{"linux", "x86_64", "so"},
+			{"linux", "arm64", "so"},
+			{"android", "arm64", "so"},
+		} {
+			fmt.Fprintf(buf, "%s.%s.%s = \"%s.%s.template_%s.%s.%s\"\n", platform.name, target, platform.arch, bin, platform.name, target, platform.arch, platform.ext)
+		}
+	}
+	return buf.Bytes()
+}
+
+// generateGodotSConstruct returns the SCons build of the extension. It
+// builds godot-cpp from a checkout next to it and puts the library in the
+// addon directory.
+func generateGodotSConstruct(pkg string) []byte {
+	return []byte(fmt.Sprintf(`#!/usr/bin/env python
+# Code generated by ffire. DO NOT EDIT.
+
+# The C++ core reports decode errors with exceptions, which godot-cpp
+# disables unless told otherwise.
+ARGUMENTS.setdefault("disable_exceptions", "no")
+
+env = SConscript("godot-cpp/SConstruct")
+env.Append(CPPPATH=["src/"])
+sources = Glob("src/*.cpp")
+
+if env["platform"] == "macos":
+    name = "lib%[1]s.{}.{}".format(env["platform"], env["target"])
+    library = env.SharedLibrary("addons/%[1]s/bin/{}.framework/{}".format(name, name), source=sources)
+else:
+    library = env.SharedLibrary("addons/%[1]s/bin/lib%[1]s{}{}".format(env["suffix"], env["SHLIBSUFFIX"]), source=sources)
+
+Default(library)
+`, pkg))
+}
+
+func (g *godotGenerator) generateReadme() string {
+	pkg := g.schema.Package
+	msg := firstMessageName(g.schema)
+	class := g.className(msg)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", pkg)
+	fmt.Fprintf(&b, "Godot %s+ GDExtension for the %s schema, generated by ffire. Messages are RefCounted objects with a property per field, for networking and save data.\n\n", godotVersion, pkg)
+	b.WriteString("## Building\n\n")
+	b.WriteString("Building needs a C++17 compiler, Python and SCons.\n\n")
+	b.WriteString("```bash\n")
+	fmt.Fprintf(&b, "git clone --depth 1 -b godot-%s-stable https://github.com/godotengine/godot-cpp\n", godotVersion)
+	b.WriteString("scons                              # debug build for this platform\n")
+	b.WriteString("scons target=template_release      # release build\n")
+	b.WriteString("scons platform=android arch=arm64  # Android, with ANDROID_HOME set\n")
+	b.WriteString("```\n\n")
+	fmt.Fprintf(&b, "Copy `addons/%s` into your project. Godot loads the extension on the next start.\n\n", pkg)
+	b.WriteString("## Usage\n\n")
+	b.WriteString("```gdscript\n")
+	fmt.Fprintf(&b, "var bytes := msg.encode()  # msg is a %s\n", class)
+	fmt.Fprintf(&b, "var decoded := %s.decode(bytes)\n", class)
+	b.WriteString("if decoded == null:\n")
+	b.WriteString("    return  # not a valid message; the error is logged\n")
+	b.WriteString("```\n\n")
+	b.WriteString("## Types\n\n")
+	fmt.Fprintf(&b, "- Structs are classes named after the package and the type, e.g. `%s`. Properties are the fields in snake_case\n", class)
+	b.WriteString("- Integers are `int` and floats are `float`; values out of range for the schema type are truncated when encoding\n")
+	b.WriteString("- Arrays of numbers and strings are packed arrays, other arrays are `Array`s, and maps are `Dictionary`s\n")
+	b.WriteString("- Optional fields are `null` when absent\n")
+	b.WriteString("- Enums are `int`, with the constants on a class named like the struct classes\n")
+	b.WriteString("- Unions are `Dictionary`s with one key naming the variant\n")
+	return b.String()
+}
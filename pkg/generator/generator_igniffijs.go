@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -19,9 +18,7 @@ import (
 // - Koffi provides type-precise bindings with ~80-150ns overhead
 // - Zero-copy buffer access where possible
 func GenerateIgniffiJSPackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating igniffi JavaScript package (Koffi FFI)")
-	}
+	config.debugf("Generating igniffi JavaScript package (Koffi FFI)")
 
 	// Directory structure:
 	// javascript/
@@ -32,7 +29,7 @@ func GenerateIgniffiJSPackage(config *PackageConfig) error {
 	//   package.json      - npm package metadata
 	//   README.md         - Usage documentation
 
-	jsDir := filepath.Join(config.OutputDir, "javascript")
+	jsDir := config.langDir("javascript")
 	libDir := filepath.Join(jsDir, "lib")
 	srcDir := filepath.Join(jsDir, "src")
 	includeDir := filepath.Join(jsDir, "include")
@@ -77,6 +74,16 @@ func GenerateIgniffiJSPackage(config *PackageConfig) error {
 }
 
 func compileIgniffiDylib(config *PackageConfig, srcDir, includeDir, libDir string) error {
+	// The compiler runs in the package root, so all paths are absolute
+	for _, dir := range []*string{&srcDir, &includeDir, &libDir} {
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", *dir, err)
+		}
+		*dir = abs
+	}
+	root := filepath.Dir(libDir)
+
 	// Find all .c files in src/
 	srcFiles, err := filepath.Glob(filepath.Join(srcDir, "*.c"))
 	if err != nil || len(srcFiles) == 0 {
@@ -119,17 +126,35 @@ func compileIgniffiDylib(config *PackageConfig, srcDir, includeDir, libDir strin
 		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
 
-	// Compile: gcc [flags] src/*.c
-	args := append(flags, srcFiles...)
-	cmd := exec.Command("gcc", args...)
-	// Don't set cmd.Dir - srcFiles already contains full paths from filepath.Glob
-
-	output, err := cmd.CombinedOutput()
+	cc, err := config.ccCommand("gcc")
 	if err != nil {
-		return fmt.Errorf("gcc failed: %w\nOutput: %s", err, string(output))
+		return err
+	}
+	cFlags, err := config.cFlags()
+	if err != nil {
+		return err
+	}
+	ldFlags, err := config.ldFlags()
+	if err != nil {
+		return err
+	}
+
+	// Compile: gcc [flags] [CFLAGS] src/*.c [LDFLAGS]
+	args := append(append([]string{}, cc[1:]...), flags...)
+	args = append(args, reproducibleFlags(runtime.GOOS, root)...)
+	args = append(append(args, SanitizerFlags(config.Sanitize)...), cFlags...)
+	args = append(append(args, srcFiles...), ldFlags...)
+	cmd := config.command(cc[0], args...)
+	if err := reproducibleCommand(cmd, root); err != nil {
+		return err
 	}
 
-	fmt.Printf("✓ Compiled %s\n", libName)
+	if err := runBuild(config, cmd, libName, root); err != nil {
+		return err
+	}
+
+	config.infof("✓ Compiled %s%s\n", libName, checksumSuffix(filepath.Join(libDir, libName)))
+	printSanitizerPreload(config, cc[0])
 	return nil
 }
 
@@ -175,8 +200,10 @@ func generateKoffiWrapper(config *PackageConfig, jsDir string) error {
 	// Status struct
 	buf.WriteString("const Status = koffi.struct('igniffi_Status', {\n")
 	buf.WriteString("  ok: 'bool',\n")
-	buf.WriteString("  message: 'const char*'\n")
+	buf.WriteString("  message: 'const char*',\n")
+	buf.WriteString("  code: 'int'\n")
 	buf.WriteString("});\n\n")
+	buf.WriteString(jsDecodeErrorCode())
 
 	// Arena pointer (opaque)
 	buf.WriteString("const Arena = koffi.pointer('igniffi_Arena', koffi.opaque());\n\n")
@@ -224,6 +251,19 @@ func generateKoffiWrapper(config *PackageConfig, jsDir string) error {
 	buf.WriteString("// High-Level API\n")
 	buf.WriteString("// ============================================================================\n\n")
 
+	// Enum values, read and written as their base integer
+	for _, enumType := range s.Enums() {
+		fmt.Fprintf(buf, "const %s = Object.freeze({\n", enumType.Name)
+		for i, v := range enumType.Values {
+			comma := ","
+			if i == len(enumType.Values)-1 {
+				comma = ""
+			}
+			fmt.Fprintf(buf, "  %s: %d%s\n", v.Name, v.Value, comma)
+		}
+		buf.WriteString("});\n\n")
+	}
+
 	for _, msg := range s.Messages {
 		generateKoffiMessageClass(buf, s, &msg)
 	}
@@ -233,13 +273,19 @@ func generateKoffiWrapper(config *PackageConfig, jsDir string) error {
 	buf.WriteString("// Exports\n")
 	buf.WriteString("// ============================================================================\n\n")
 
+	exports := []string{"DecodeErrorCode", "DecodeError"}
+	for _, enumType := range s.Enums() {
+		exports = append(exports, enumType.Name)
+	}
+	for _, msg := range s.Messages {
+		exports = append(exports, msg.Name+"Message")
+	}
 	buf.WriteString("module.exports = {\n")
-	for i, msg := range s.Messages {
-		className := msg.Name + "Message"
-		if i < len(s.Messages)-1 {
-			fmt.Fprintf(buf, "  %s,\n", className)
+	for i, name := range exports {
+		if i < len(exports)-1 {
+			fmt.Fprintf(buf, "  %s,\n", name)
 		} else {
-			fmt.Fprintf(buf, "  %s\n", className)
+			fmt.Fprintf(buf, "  %s\n", name)
 		}
 	}
 	buf.WriteString("};\n")
@@ -250,7 +296,7 @@ func generateKoffiWrapper(config *PackageConfig, jsDir string) error {
 		return fmt.Errorf("failed to write index.js: %w", err)
 	}
 
-	fmt.Println("✓ Generated index.js (Koffi bindings)")
+	config.infof("✓ Generated index.js (Koffi bindings)")
 	return nil
 }
 
@@ -301,6 +347,7 @@ func generateKoffiMessageClass(buf *bytes.Buffer, s *schema.Schema, msg *schema.
 	className := msg.Name + "Message"
 	msgName := toCIdentifier(msg.Name)
 
+	buf.WriteString(blockDoc("", msg.Doc))
 	fmt.Fprintf(buf, "class %s {\n", className)
 	buf.WriteString("  #arena = null;\n")
 	buf.WriteString("  #handle = null;\n")
@@ -329,7 +376,7 @@ func generateKoffiMessageClass(buf *bytes.Buffer, s *schema.Schema, msg *schema.
 	buf.WriteString("    if (!status.ok) {\n")
 	buf.WriteString("      const errMsg = status.message ? koffi.decode(status.message, 'string') : 'Unknown decode error';\n")
 	buf.WriteString("      arena_free(arena);\n")
-	buf.WriteString("      throw new Error(`Decode failed: ${errMsg}`);\n")
+	buf.WriteString("      throw new DecodeError(`Decode failed: ${errMsg}`, status.code);\n")
 	buf.WriteString("    }\n")
 	buf.WriteString("    \n")
 	fmt.Fprintf(buf, "    return new %s(handle, arena);\n", className)
@@ -378,64 +425,6 @@ func generateKoffiMessageClass(buf *bytes.Buffer, s *schema.Schema, msg *schema.
 	buf.WriteString("}\n\n")
 }
 
-// topSortStructTypes returns struct types sorted in topological order (dependencies first)
-// This ensures that when a struct refers to another struct, the dependency is defined first
-func topSortStructTypes(types []schema.Type) []*schema.StructType {
-	// Build a map of struct types by name
-	typeMap := make(map[string]*schema.StructType)
-	for _, typ := range types {
-		if st, ok := typ.(*schema.StructType); ok {
-			typeMap[st.Name] = st
-		}
-	}
-
-	// Build dependency graph
-	deps := make(map[string][]string) // map from type to its dependencies
-	for _, st := range typeMap {
-		var typeDeps []string
-		for _, field := range st.Fields {
-			if depSt, ok := field.Type.(*schema.StructType); ok {
-				typeDeps = append(typeDeps, depSt.Name)
-			}
-		}
-		deps[st.Name] = typeDeps
-	}
-
-	// Topological sort using DFS
-	var result []*schema.StructType
-	visited := make(map[string]bool)
-	inStack := make(map[string]bool)
-
-	var visit func(name string)
-	visit = func(name string) {
-		if inStack[name] {
-			return // Circular dependency - skip
-		}
-		if visited[name] {
-			return
-		}
-
-		inStack[name] = true
-		for _, dep := range deps[name] {
-			if _, exists := typeMap[dep]; exists {
-				visit(dep)
-			}
-		}
-		inStack[name] = false
-		visited[name] = true
-		if st, ok := typeMap[name]; ok {
-			result = append(result, st)
-		}
-	}
-
-	// Visit all types
-	for name := range typeMap {
-		visit(name)
-	}
-
-	return result
-}
-
 func koffiTypeForField(s *schema.Schema, field *schema.Field) string {
 	return koffiTypeForSchemaType(s, field.Type)
 }
@@ -444,6 +433,8 @@ func koffiTypeForSchemaType(s *schema.Schema, t schema.Type) string {
 	switch typ := t.(type) {
 	case *schema.PrimitiveType:
 		return koffiTypeForPrimitive(typ)
+	case *schema.EnumType:
+		return koffiTypeForPrimitive(typ.BaseType())
 	case *schema.StructType:
 		return fmt.Sprintf("igniffi_%s", toCIdentifier(typ.Name))
 	case *schema.ArrayType:
@@ -488,11 +479,11 @@ func koffiTypeForPrimitive(p *schema.PrimitiveType) string {
 
 func generateJSPackageJSON(config *PackageConfig, jsDir string) error {
 	buf := &bytes.Buffer{}
-	packageName := config.Namespace
+	packageName := config.npmName()
 
 	buf.WriteString("{\n")
 	fmt.Fprintf(buf, "  \"name\": \"%s\",\n", packageName)
-	buf.WriteString("  \"version\": \"1.0.0\",\n")
+	fmt.Fprintf(buf, "  \"version\": \"%s\",\n", config.Version)
 	buf.WriteString("  \"description\": \"ffire serialization bindings via Koffi FFI\",\n")
 	buf.WriteString("  \"main\": \"index.js\",\n")
 	buf.WriteString("  \"scripts\": {\n")
@@ -500,7 +491,12 @@ func generateJSPackageJSON(config *PackageConfig, jsDir string) error {
 	buf.WriteString("    \"bench\": \"node bench.js\"\n")
 	buf.WriteString("  },\n")
 	buf.WriteString("  \"keywords\": [\"ffire\", \"serialization\", \"binary\", \"ffi\"],\n")
-	buf.WriteString("  \"license\": \"MIT\",\n")
+	// npm's value for packages that are not licensed for use by others
+	license := valueOr(spdxLicense(config), LicenseMIT)
+	if config.License == LicenseProprietary {
+		license = "UNLICENSED"
+	}
+	fmt.Fprintf(buf, "  \"license\": \"%s\",\n", license)
 	buf.WriteString("  \"dependencies\": {\n")
 	buf.WriteString("    \"koffi\": \"^2.8.0\"\n")
 	buf.WriteString("  },\n")
@@ -514,7 +510,7 @@ func generateJSPackageJSON(config *PackageConfig, jsDir string) error {
 		return fmt.Errorf("failed to write package.json: %w", err)
 	}
 
-	fmt.Println("✓ Generated package.json")
+	config.infof("✓ Generated package.json")
 	return nil
 }
 
@@ -536,7 +532,7 @@ func generateJSReadme(config *PackageConfig, jsDir string) error {
 
 	buf.WriteString("## Usage\n\n")
 	buf.WriteString("```javascript\n")
-	fmt.Fprintf(buf, "const { %sMessage } = require('%s');\n", config.Schema.Messages[0].Name, packageName)
+	fmt.Fprintf(buf, "const { %sMessage } = require('%s');\n", config.Schema.Messages[0].Name, config.npmName())
 	buf.WriteString("const fs = require('fs');\n\n")
 
 	buf.WriteString("// Decode binary data\n")
@@ -565,29 +561,31 @@ func generateJSReadme(config *PackageConfig, jsDir string) error {
 	buf.WriteString("For large messages, the encode/decode time dominates, making FFI overhead negligible.\n\n")
 
 	buf.WriteString("## License\n\n")
-	buf.WriteString("Generated by ffire\n")
+	buf.WriteString(readmeLicense(config, "Generated by ffire\n"))
 
 	filePath := filepath.Join(jsDir, "README.md")
 	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write README.md: %w", err)
 	}
 
-	fmt.Println("✓ Generated README.md")
+	config.infof("✓ Generated README.md")
 	return nil
 }
 
 func printJSInstructions(config *PackageConfig, jsDir string) {
-	fmt.Printf("\n✅ JavaScript package ready at: %s\n\n", jsDir)
-	fmt.Println("Install dependencies:")
-	fmt.Printf("  cd %s\n", jsDir)
-	fmt.Println("  npm install")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Printf("  const { %sMessage } = require('./');\n", config.Schema.Messages[0].Name)
-	fmt.Println("  const msg = Message.decode(buffer);")
-	fmt.Println("  const encoded = msg.encode();")
-	fmt.Println("  msg.dispose();")
-	fmt.Println()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ JavaScript package ready at: %s\n\n", jsDir)
+	fmt.Fprintln(&b, "Install dependencies:")
+	fmt.Fprintf(&b, "  cd %s\n", jsDir)
+	fmt.Fprintln(&b, "  npm install")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  const { %sMessage } = require('./');\n", config.Schema.Messages[0].Name)
+	fmt.Fprintln(&b, "  const msg = Message.decode(buffer);")
+	fmt.Fprintln(&b, "  const encoded = msg.encode();")
+	fmt.Fprintln(&b, "  msg.dispose();")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
 }
 
 // toCIdentifier converts a name to a valid C identifier (lowercase with underscores)
@@ -20,9 +20,7 @@ import (
 // - Zero-copy buffer access via ffi.buffer()
 // - Full type precision (int8, int16, int32, int64, float32, float64)
 func GenerateIgniffiPythonPackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating igniffi Python package (CFFI API mode)")
-	}
+	config.debugf("Generating igniffi Python package (CFFI API mode)")
 
 	// Directory structure:
 	// python/
@@ -36,7 +34,7 @@ func GenerateIgniffiPythonPackage(config *PackageConfig) error {
 	//   setup.py            - Build script with CFFI
 	//   README.md           - Usage documentation
 
-	pyDir := filepath.Join(config.OutputDir, "python")
+	pyDir := config.langDir("python")
 	pkgName := toPythonIdentifier(config.Schema.Package)
 	pkgDir := filepath.Join(pyDir, pkgName)
 	srcDir := filepath.Join(pyDir, "src")
@@ -87,8 +85,11 @@ func GenerateIgniffiPythonPackage(config *PackageConfig) error {
 	// Step 8: Optionally compile the extension
 	if !config.NoCompile {
 		if err := compilePythonExtension(config, pyDir); err != nil {
+			if ctxErr := config.context().Err(); ctxErr != nil {
+				return ctxErr
+			}
 			// Don't fail - user can compile manually
-			fmt.Printf("⚠ Could not compile extension (user can run 'pip install .'): %v\n", err)
+			config.infof("⚠ Could not compile extension (user can run 'pip install .'): %v\n", err)
 		}
 	}
 
@@ -120,6 +121,7 @@ func generateCFFIDefs(config *PackageConfig, pkgDir string) error {
 	buf.WriteString("typedef struct {\n")
 	buf.WriteString("    bool ok;\n")
 	buf.WriteString("    const char* message;\n")
+	buf.WriteString("    int code;\n")
 	buf.WriteString("} igniffi_Status;\n\n")
 
 	// Generate struct types in topological order
@@ -162,7 +164,7 @@ func generateCFFIDefs(config *PackageConfig, pkgDir string) error {
 		return fmt.Errorf("failed to write _cffi_defs.h: %w", err)
 	}
 
-	fmt.Println("✓ Generated _cffi_defs.h (CFFI declarations)")
+	config.infof("✓ Generated _cffi_defs.h (CFFI declarations)")
 	return nil
 }
 
@@ -179,7 +181,7 @@ func generateCFFIStructType(buf *bytes.Buffer, s *schema.Schema, structType *sch
 		
 		// If it's an array, add length field (matches igniffi C layout)
 		if _, isArray := field.Type.(*schema.ArrayType); isArray {
-			fmt.Fprintf(buf, "    uint16_t %s_len;\n", fieldName)
+			fmt.Fprintf(buf, "    %s %s_len;\n", igniffi.LengthCType(field.Type), fieldName)
 		}
 		
 		// If it's optional, add has_{field} boolean (matches igniffi C layout)
@@ -214,7 +216,7 @@ func generateCFFIMessageType(buf *bytes.Buffer, s *schema.Schema, msg *schema.Me
 				cType := cffiCTypeForField(&field)
 				fmt.Fprintf(buf, "    %s %s;\n", cType, fieldName)
 				if _, isArray := field.Type.(*schema.ArrayType); isArray {
-					fmt.Fprintf(buf, "    uint16_t %s_len;\n", fieldName)
+					fmt.Fprintf(buf, "    %s %s_len;\n", igniffi.LengthCType(field.Type), fieldName)
 				}
 				if field.Type.IsOptional() {
 					fmt.Fprintf(buf, "    bool has_%s;\n", fieldName)
@@ -227,7 +229,7 @@ func generateCFFIMessageType(buf *bytes.Buffer, s *schema.Schema, msg *schema.Me
 		elemType := cffiCTypeForSchemaType(t.ElementType)
 		fmt.Fprintf(buf, "typedef struct {\n")
 		fmt.Fprintf(buf, "    %s* items;\n", elemType)
-		fmt.Fprintf(buf, "    uint16_t len;\n")
+		fmt.Fprintf(buf, "    %s len;\n", igniffi.LengthCType(t))
 		fmt.Fprintf(buf, "} %s;\n\n", structName)
 
 	case *schema.PrimitiveType:
@@ -283,7 +285,7 @@ if __name__ == '__main__':
 		return fmt.Errorf("failed to write _ffi_build.py: %w", err)
 	}
 
-	fmt.Println("✓ Generated _ffi_build.py (CFFI builder)")
+	config.infof("✓ Generated _ffi_build.py (CFFI builder)")
 	return nil
 }
 
@@ -300,15 +302,23 @@ High-performance binary serialization using ffire format with CFFI.
 from __future__ import annotations
 from typing import Optional, List, Union, Any
 from dataclasses import dataclass
+from enum import IntEnum
 import numpy as np
 
 # Import the compiled CFFI extension
 from ._igniffi import ffi, lib
 
 
+` + pythonDecodeErrorCode() + `
 class FFIError(Exception):
-    """Exception raised for FFI-related errors."""
-    pass
+    """Exception raised for FFI-related errors.
+
+    code is a DecodeErrorCode for decode failures, and 0 otherwise.
+    """
+
+    def __init__(self, message: str, code: int = 0):
+        super().__init__(message)
+        self.code = code
 
 
 class Arena:
@@ -344,7 +354,7 @@ def _check_status(status) -> None:
     """Check FFI status and raise exception if failed."""
     if not status.ok:
         msg = ffi.string(status.message).decode('utf-8') if status.message != ffi.NULL else 'Unknown error'
-        raise FFIError(msg)
+        raise FFIError(msg, status.code)
 
 
 def _string_to_view(s: str, arena) -> 'ffi.CData':
@@ -365,6 +375,11 @@ def _view_to_string(view) -> str:
 
 `)
 
+	// Generate IntEnum classes, which the dataclasses below refer to
+	for _, enumType := range s.Enums() {
+		generatePythonEnum(buf, enumType)
+	}
+
 	// Generate struct dataclasses for pure Python representation
 	sortedTypes := topSortStructTypes(s.Types)
 	for _, structType := range sortedTypes {
@@ -382,15 +397,33 @@ def _view_to_string(view) -> str:
 		return fmt.Errorf("failed to write __init__.py: %w", err)
 	}
 
-	fmt.Println("✓ Generated __init__.py (Python API)")
+	config.infof("✓ Generated __init__.py (Python API)")
 	return nil
 }
 
+func generatePythonEnum(buf *bytes.Buffer, enumType *schema.EnumType) {
+	fmt.Fprintf(buf, "\nclass %s(IntEnum):\n", enumType.Name)
+	if enumType.Doc != "" {
+		buf.WriteString(docstring("    ", enumType.Doc))
+	} else {
+		fmt.Fprintf(buf, "    \"\"\"%s enum values.\"\"\"\n", enumType.Name)
+	}
+	for _, v := range enumType.Values {
+		fmt.Fprintf(buf, "    %s = %d\n", v.Name, v.Value)
+		buf.WriteString(docstring("    ", v.Doc)) // Attribute docstring, read by Sphinx and IDEs
+	}
+	buf.WriteString("\n")
+}
+
 func generatePythonDataclass(buf *bytes.Buffer, s *schema.Schema, structType *schema.StructType) {
 	className := structType.Name
 	fmt.Fprintf(buf, "\n@dataclass\n")
 	fmt.Fprintf(buf, "class %s:\n", className)
-	fmt.Fprintf(buf, "    \"\"\"%s data structure.\"\"\"\n", className)
+	if structType.Doc != "" {
+		buf.WriteString(docstring("    ", structType.Doc))
+	} else {
+		fmt.Fprintf(buf, "    \"\"\"%s data structure.\"\"\"\n", className)
+	}
 
 	if len(structType.Fields) == 0 {
 		buf.WriteString("    pass\n")
@@ -405,6 +438,7 @@ func generatePythonDataclass(buf *bytes.Buffer, s *schema.Schema, structType *sc
 			defaultVal := pythonDefaultForType(s, field.Type)
 			fmt.Fprintf(buf, "    %s: %s = %s\n", field.Name, pyType, defaultVal)
 		}
+		buf.WriteString(docstring("    ", field.Doc))
 	}
 	buf.WriteString("\n")
 }
@@ -415,12 +449,16 @@ func generatePythonMessageClass(buf *bytes.Buffer, s *schema.Schema, msg *schema
 	structName := fmt.Sprintf("igniffi_%s", msgName)
 
 	fmt.Fprintf(buf, "\nclass %s:\n", className)
-	fmt.Fprintf(buf, "    \"\"\"\n")
-	fmt.Fprintf(buf, "    %s encoder/decoder.\n", msg.Name)
-	fmt.Fprintf(buf, "    \n")
-	fmt.Fprintf(buf, "    This class provides high-performance encoding/decoding of %s messages\n", msg.Name)
-	fmt.Fprintf(buf, "    using the ffire wire format via CFFI bindings.\n")
-	fmt.Fprintf(buf, "    \"\"\"\n\n")
+	if msg.Doc != "" {
+		buf.WriteString(docstring("    ", msg.Doc) + "\n")
+	} else {
+		fmt.Fprintf(buf, "    \"\"\"\n")
+		fmt.Fprintf(buf, "    %s encoder/decoder.\n", msg.Name)
+		fmt.Fprintf(buf, "    \n")
+		fmt.Fprintf(buf, "    This class provides high-performance encoding/decoding of %s messages\n", msg.Name)
+		fmt.Fprintf(buf, "    using the ffire wire format via CFFI bindings.\n")
+		fmt.Fprintf(buf, "    \"\"\"\n\n")
+	}
 
 	fmt.Fprintf(buf, "    __slots__ = ('_arena', '_handle', '_disposed')\n\n")
 
@@ -591,6 +629,9 @@ func generatePythonValueConversion(buf *bytes.Buffer, s *schema.Schema, t schema
 			fmt.Fprintf(buf, "%sreturn %s\n", indent, cAccess)
 		}
 
+	case *schema.EnumType:
+		fmt.Fprintf(buf, "%sreturn %s(%s)\n", indent, typ.Name, cAccess)
+
 	case *schema.StructType:
 		// Return nested struct as dataclass
 		className := typ.Name
@@ -608,6 +649,8 @@ func generatePythonValueConversion(buf *bytes.Buffer, s *schema.Schema, t schema
 				} else {
 					fmt.Fprintf(buf, "%s    %s=%s%s\n", indent, field.Name, fieldAccess, comma)
 				}
+			} else if enumType, ok := field.Type.(*schema.EnumType); ok {
+				fmt.Fprintf(buf, "%s    %s=%s%s\n", indent, field.Name, pythonEnumConversion(enumType, cAccess, cFieldName), comma)
 			} else {
 				// Nested complex type - simplified for now
 				fmt.Fprintf(buf, "%s    %s=%s%s  # TODO: deep conversion\n", indent, field.Name, fieldAccess, comma)
@@ -633,6 +676,8 @@ func generatePythonElementConversion(buf *bytes.Buffer, s *schema.Schema, elemTy
 		} else {
 			fmt.Fprintf(buf, "%selem = %s\n", indent, cAccess)
 		}
+	case *schema.EnumType:
+		fmt.Fprintf(buf, "%selem = %s(%s)\n", indent, typ.Name, cAccess)
 	case *schema.StructType:
 		className := typ.Name
 		fmt.Fprintf(buf, "%selem = %s(", indent, className)
@@ -645,6 +690,8 @@ func generatePythonElementConversion(buf *bytes.Buffer, s *schema.Schema, elemTy
 			fieldAccess := fmt.Sprintf("%s.%s", cAccess, cFieldName)
 			if prim, ok := field.Type.(*schema.PrimitiveType); ok && prim.Name == "string" {
 				fmt.Fprintf(buf, "%s=_view_to_string(%s)%s", field.Name, fieldAccess, comma)
+			} else if enumType, ok := field.Type.(*schema.EnumType); ok {
+				fmt.Fprintf(buf, "%s=%s%s", field.Name, pythonEnumConversion(enumType, cAccess, cFieldName), comma)
 			} else {
 				fmt.Fprintf(buf, "%s=%s%s", field.Name, fieldAccess, comma)
 			}
@@ -655,21 +702,35 @@ func generatePythonElementConversion(buf *bytes.Buffer, s *schema.Schema, elemTy
 	}
 }
 
+// pythonEnumConversion returns the expression converting a struct's enum field
+// to its IntEnum, guarded by the presence flag when the field is optional.
+func pythonEnumConversion(enumType *schema.EnumType, structAccess, cFieldName string) string {
+	conv := fmt.Sprintf("%s(%s.%s)", enumType.Name, structAccess, cFieldName)
+	if enumType.Optional {
+		return fmt.Sprintf("(%s if %s.has_%s else None)", conv, structAccess, cFieldName)
+	}
+	return conv
+}
+
 // generatePyProjectTOML generates pyproject.toml for modern Python packaging
 func generatePyProjectTOML(config *PackageConfig, pyDir, pkgName string) error {
 	buf := &bytes.Buffer{}
 
 	buf.WriteString("[build-system]\n")
-	buf.WriteString("requires = [\"setuptools>=45\", \"wheel\", \"cffi>=1.0.0\"]\n")
+	// SPDX license strings and license-files need setuptools 77 (PEP 639)
+	buf.WriteString("requires = [\"setuptools>=77\", \"wheel\", \"cffi>=1.0.0\"]\n")
 	buf.WriteString("build-backend = \"setuptools.build_meta\"\n\n")
 
 	buf.WriteString("[project]\n")
-	fmt.Fprintf(buf, "name = \"%s\"\n", pkgName)
-	buf.WriteString("version = \"1.0.0\"\n")
+	fmt.Fprintf(buf, "name = \"%s\"\n", valueOr(config.Coordinates.PyPI, pkgName))
+	fmt.Fprintf(buf, "version = \"%s\"\n", config.Version)
 	buf.WriteString("description = \"ffire serialization bindings via CFFI\"\n")
 	buf.WriteString("readme = \"README.md\"\n")
 	buf.WriteString("requires-python = \">=3.8\"\n")
-	buf.WriteString("license = \"MIT\"\n")
+	fmt.Fprintf(buf, "license = \"%s\"\n", valueOr(spdxLicense(config), LicenseMIT))
+	if config.License != "" {
+		fmt.Fprintf(buf, "license-files = [\"%s\"]\n", LicenseFile)
+	}
 	buf.WriteString("keywords = [\"ffire\", \"serialization\", \"binary\", \"ffi\"]\n\n")
 
 	buf.WriteString("dependencies = [\n")
@@ -688,7 +749,7 @@ func generatePyProjectTOML(config *PackageConfig, pyDir, pkgName string) error {
 		return fmt.Errorf("failed to write pyproject.toml: %w", err)
 	}
 
-	fmt.Println("✓ Generated pyproject.toml")
+	config.infof("✓ Generated pyproject.toml")
 	return nil
 }
 
@@ -702,9 +763,9 @@ from setuptools import setup, find_packages
 # CFFI compilation happens at install time
 setup(
 `)
-	fmt.Fprintf(buf, "    name='%s',\n", pkgName)
-	buf.WriteString(`    version='1.0.0',
-    packages=find_packages(),
+	fmt.Fprintf(buf, "    name='%s',\n", valueOr(config.Coordinates.PyPI, pkgName))
+	fmt.Fprintf(buf, "    version='%s',\n", config.Version)
+	buf.WriteString(`    packages=find_packages(),
     install_requires=['cffi>=1.0.0', 'numpy>=1.19.0'],
     setup_requires=['cffi>=1.0.0'],
     cffi_modules=[
@@ -720,7 +781,7 @@ setup(
 		return fmt.Errorf("failed to write setup.py: %w", err)
 	}
 
-	fmt.Println("✓ Generated setup.py")
+	config.infof("✓ Generated setup.py")
 	return nil
 }
 
@@ -783,14 +844,14 @@ func generatePythonReadme(config *PackageConfig, pyDir, pkgName string) error {
 	buf.WriteString("- `bool`, `string`\n\n")
 
 	buf.WriteString("## License\n\n")
-	buf.WriteString("Generated by ffire\n")
+	buf.WriteString(readmeLicense(config, "Generated by ffire\n"))
 
 	filePath := filepath.Join(pyDir, "README.md")
 	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write README.md: %w", err)
 	}
 
-	fmt.Println("✓ Generated README.md")
+	config.infof("✓ Generated README.md")
 	return nil
 }
 
@@ -805,12 +866,40 @@ func compilePythonExtension(config *PackageConfig, pyDir string) error {
 	}
 
 	// Run pip install in development mode
-	cmd := exec.Command(pythonCmd, "-m", "pip", "install", "-e", ".")
+	cmd := config.command(pythonCmd, "-m", "pip", "install", "-e", ".")
 	cmd.Dir = pyDir
 
+	cmd.Env = reproducibleEnv()
+
 	// On macOS, we might need to set architecture
 	if runtime.GOOS == "darwin" {
-		cmd.Env = append(os.Environ(), fmt.Sprintf("ARCHFLAGS=-arch %s", runtime.GOARCH))
+		cmd.Env = append(cmd.Env, fmt.Sprintf("ARCHFLAGS=-arch %s", runtime.GOARCH))
+	}
+
+	// setuptools reads the compiler settings from the environment, so
+	// ffire's flags are combined with $CFLAGS rather than replacing it
+	absDir, err := filepath.Abs(pyDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for %s: %w", pyDir, err)
+	}
+	userCFlags := config.CFlags
+	if userCFlags == "" {
+		userCFlags = os.Getenv("CFLAGS")
+	}
+	cflags := strings.TrimSpace(strings.Join(reproducibleFlags(runtime.GOOS, absDir), " ") + " " + userCFlags)
+	ldflags := config.LDFlags
+	if sanitize := strings.Join(SanitizerFlags(config.Sanitize), " "); sanitize != "" {
+		cflags = sanitize + " " + cflags
+		ldflags = strings.TrimSpace("-fsanitize=" + strings.Join(config.Sanitize, ",") + " " + ldflags)
+	}
+	for _, v := range []struct{ name, value string }{
+		{"CC", config.CC},
+		{"CFLAGS", cflags},
+		{"LDFLAGS", ldflags},
+	} {
+		if v.value != "" {
+			cmd.Env = append(cmd.Env, v.name+"="+v.value)
+		}
 	}
 
 	output, err := cmd.CombinedOutput()
@@ -818,25 +907,28 @@ func compilePythonExtension(config *PackageConfig, pyDir string) error {
 		return fmt.Errorf("pip install failed: %w\nOutput: %s", err, string(output))
 	}
 
-	fmt.Println("✓ Compiled Python extension")
+	config.infof("✓ Compiled Python extension")
+	printSanitizerPreload(config, "gcc")
 	return nil
 }
 
 func printPythonInstructions(config *PackageConfig, pyDir, pkgName string) {
-	fmt.Printf("\n✅ Python package ready at: %s\n\n", pyDir)
-	fmt.Println("Install the package:")
-	fmt.Printf("  cd %s\n", pyDir)
-	fmt.Println("  pip install .")
-	fmt.Println()
-	fmt.Println("Or install in development mode:")
-	fmt.Println("  pip install -e .")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Printf("  from %s import %sMessage\n", pkgName, config.Schema.Messages[0].Name)
-	fmt.Println()
-	fmt.Printf("  with %sMessage.decode(data) as msg:\n", config.Schema.Messages[0].Name)
-	fmt.Println("      encoded = msg.encode()")
-	fmt.Println()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Python package ready at: %s\n\n", pyDir)
+	fmt.Fprintln(&b, "Install the package:")
+	fmt.Fprintf(&b, "  cd %s\n", pyDir)
+	fmt.Fprintln(&b, "  pip install .")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Or install in development mode:")
+	fmt.Fprintln(&b, "  pip install -e .")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  from %s import %sMessage\n", pkgName, config.Schema.Messages[0].Name)
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "  with %sMessage.decode(data) as msg:\n", config.Schema.Messages[0].Name)
+	fmt.Fprintln(&b, "      encoded = msg.encode()")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
 }
 
 // Helper functions
@@ -867,6 +959,8 @@ func cffiCTypeForSchemaType(t schema.Type) string {
 	switch typ := t.(type) {
 	case *schema.PrimitiveType:
 		return cffiCTypeForPrimitive(typ)
+	case *schema.EnumType:
+		return cffiCTypeForPrimitive(typ.BaseType())
 	case *schema.StructType:
 		return fmt.Sprintf("igniffi_%s", toCIdentifier(typ.Name))
 	case *schema.ArrayType:
@@ -916,8 +1010,8 @@ func pythonTypeForSchemaType(s *schema.Schema, t schema.Type) string {
 	switch typ := t.(type) {
 	case *schema.PrimitiveType:
 		return pythonTypeForPrimitive(typ)
-	case *schema.StructType:
-		return typ.Name
+	case *schema.EnumType, *schema.StructType:
+		return typ.TypeName()
 	case *schema.ArrayType:
 		elemType := pythonTypeForSchemaType(s, typ.ElementType)
 		return fmt.Sprintf("List[%s]", elemType)
@@ -956,6 +1050,8 @@ func pythonDefaultForType(s *schema.Schema, t schema.Type) string {
 		case "string":
 			return "''"
 		}
+	case *schema.EnumType:
+		return typ.Name + "." + typ.Values[0].Name
 	case *schema.ArrayType:
 		return "None"  // Use None for mutable default
 	case *schema.StructType:
@@ -3,7 +3,6 @@ package generator
 import (
 	"bytes"
 	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/shaban/ffire/pkg/schema"
@@ -11,10 +10,24 @@ import (
 
 // GenerateJava generates native Java code with ByteBuffer encoding/decoding
 func GenerateJava(s *schema.Schema) ([]byte, error) {
+	return GenerateJavaWithOptions(s, JavaOptions{})
+}
+
+// JavaOptions select optional code in the generated Java source.
+type JavaOptions struct {
+	// FFM adds encode and decode methods for MemorySegments of the
+	// Foreign Function & Memory API (java.lang.foreign, final in Java 22),
+	// so messages move to and from native memory without byte[] copies.
+	FFM bool
+}
+
+// GenerateJavaWithOptions is GenerateJava with optional code.
+func GenerateJavaWithOptions(s *schema.Schema, opts JavaOptions) ([]byte, error) {
 	s.Canonicalize()
 
 	gen := &javaGenerator{
 		schema:     s,
+		opts:       opts,
 		buf:        &bytes.Buffer{},
 		seenTypes:  make(map[string]bool),
 		needsTypes: make(map[string]bool),
@@ -24,15 +37,22 @@ func GenerateJava(s *schema.Schema) ([]byte, error) {
 
 type javaGenerator struct {
 	schema     *schema.Schema
+	opts       JavaOptions
 	buf        *bytes.Buffer
 	seenTypes  map[string]bool
 	needsTypes map[string]bool
+	varCounter int
+	cached     bool // Sizing or encoding a @cached field
 }
 
 func (g *javaGenerator) generate() ([]byte, error) {
 	fmt.Fprintf(g.buf, "// Code generated by ffire. DO NOT EDIT.\n\n")
 	fmt.Fprintf(g.buf, "package %s;\n\n", g.schema.Package)
 
+	if g.opts.FFM {
+		g.buf.WriteString("import java.lang.foreign.MemorySegment;\n")
+		g.buf.WriteString("import java.lang.foreign.SegmentAllocator;\n")
+	}
 	g.buf.WriteString("import java.nio.ByteBuffer;\n")
 	g.buf.WriteString("import java.nio.ByteOrder;\n")
 	g.buf.WriteString("import java.nio.charset.StandardCharsets;\n")
@@ -43,11 +63,29 @@ func (g *javaGenerator) generate() ([]byte, error) {
 		g.collectNeededTypes(msg.TargetType)
 	}
 
+	g.buf.WriteString(javaDecodeErrors())
+
 	// Generate slice helper classes
 	if err := g.generateSliceClasses(); err != nil {
 		return nil, err
 	}
 
+	for _, enum := range g.schema.Enums() {
+		g.generateEnum(enum)
+	}
+
+	for _, union := range g.schema.Unions() {
+		g.generateUnion(union)
+	}
+
+	if g.schema.HasCachedFields() {
+		g.generateStringCache()
+	}
+
+	if g.schema.HasLarge() {
+		g.generateLargeHelpers()
+	}
+
 	if err := g.generateHelperClasses(); err != nil {
 		return nil, err
 	}
@@ -61,6 +99,174 @@ func (g *javaGenerator) generate() ([]byte, error) {
 	return g.buf.Bytes(), nil
 }
 
+// generateStringCache declares the encoder-side cache of @cached fields:
+// each distinct string is encoded once, length prefix included, and later
+// encodes copy those bytes. The cache stops growing at a fixed size, so
+// unbounded label sets cost one lookup and no memory.
+func (g *javaGenerator) generateStringCache() {
+	g.buf.WriteString("final class FFireStringCache {\n")
+	g.buf.WriteString("    private static final int LIMIT = 4096;\n")
+	g.buf.WriteString("    private static final java.util.concurrent.ConcurrentHashMap<String, byte[]> entries = new java.util.concurrent.ConcurrentHashMap<>();\n\n")
+	g.buf.WriteString("    private FFireStringCache() {}\n\n")
+	g.buf.WriteString("    static byte[] encoded(String s) {\n")
+	g.buf.WriteString("        byte[] hit = entries.get(s);\n")
+	g.buf.WriteString("        if (hit != null) {\n")
+	g.buf.WriteString("            return hit;\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("        byte[] utf8 = s.getBytes(StandardCharsets.UTF_8);\n")
+	g.buf.WriteString("        byte[] bytes = new byte[2 + utf8.length];\n")
+	g.buf.WriteString("        bytes[0] = (byte) utf8.length;\n")
+	g.buf.WriteString("        bytes[1] = (byte) (utf8.length >>> 8);\n")
+	g.buf.WriteString("        System.arraycopy(utf8, 0, bytes, 2, utf8.length);\n")
+	g.buf.WriteString("        if (entries.size() < LIMIT) {\n")
+	g.buf.WriteString("            entries.putIfAbsent(s, bytes);\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("        return bytes;\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// generateLargeHelpers declares FFireLarge, which reads the uint32 length
+// prefix of @large values. Every element or byte takes at least one byte on
+// the wire, so a length past the end of the buffer is rejected before
+// anything is allocated for it.
+func (g *javaGenerator) generateLargeHelpers() {
+	g.buf.WriteString("final class FFireLarge {\n")
+	g.buf.WriteString("    private FFireLarge() {}\n\n")
+	g.buf.WriteString("    static int readLength(ByteBuffer buf) {\n")
+	g.buf.WriteString("        long len = buf.getInt() & 0xFFFFFFFFL;\n")
+	g.buf.WriteString("        if (len > buf.remaining()) {\n")
+	g.buf.WriteString("            throw new DecodeException(DecodeErrorCode.OVERFLOW, \"length \" + len + \" exceeds the remaining \" + buf.remaining() + \" bytes\");\n")
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("        return (int) len;\n")
+	g.buf.WriteString("    }\n\n")
+	g.buf.WriteString("    static String decodeString(ByteBuffer buf) {\n")
+	g.buf.WriteString("        return FFireDecode.readString(buf, readLength(buf));\n")
+	g.buf.WriteString("    }\n\n")
+	g.buf.WriteString("    static byte[] decodeBytes(ByteBuffer buf) {\n")
+	g.buf.WriteString("        byte[] bytes = new byte[readLength(buf)];\n")
+	g.buf.WriteString("        buf.get(bytes);\n")
+	g.buf.WriteString("        return bytes;\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// javaPutLength returns the statement writing count as the length prefix of t.
+func javaPutLength(count string, t schema.Type) string {
+	if schema.IsLarge(t) {
+		return fmt.Sprintf("buf.putInt(%s);", count)
+	}
+	return fmt.Sprintf("buf.putShort((short) %s);", count)
+}
+
+// javaGetLength returns the expression reading the length prefix of t.
+func javaGetLength(t schema.Type) string {
+	if schema.IsLarge(t) {
+		return "FFireLarge.readLength(buf)"
+	}
+	return "FFireDecode.checkLength(buf, buf.getShort() & 0xFFFF)"
+}
+
+// generateDecodeCall decodes obj from buf, reporting data that ends early
+// as a truncation. If whole, the message must fill buf.
+func (g *javaGenerator) generateDecodeCall(whole bool) {
+	g.buf.WriteString("        try {\n")
+	g.buf.WriteString("            obj.decodeFrom(buf);\n")
+	if whole {
+		g.buf.WriteString("            if (buf.hasRemaining()) {\n")
+		g.buf.WriteString("                throw new DecodeException(DecodeErrorCode.TRAILING_DATA, buf.remaining() + \" trailing bytes after message\");\n")
+		g.buf.WriteString("            }\n")
+	}
+	g.buf.WriteString("        } catch (java.nio.BufferUnderflowException e) {\n")
+	g.buf.WriteString("            throw new DecodeException(DecodeErrorCode.TRUNCATED, \"unexpected end of data at offset \" + buf.position());\n")
+	g.buf.WriteString("        }\n")
+}
+
+// generateByteBufferMethods adds the ByteBuffer overloads of encode and
+// decode to a message class. They read and write at the buffer's position,
+// so messages can be framed in direct buffers from NIO channels or Netty
+// without a byte[] copy, and leave the buffer's byte order unchanged.
+func (g *javaGenerator) generateByteBufferMethods(className string) {
+	g.buf.WriteString("    /** Returns the size in bytes of the encoded message. */\n")
+	g.buf.WriteString("    public int encodedSize() {\n")
+	g.buf.WriteString("        return computeSize();\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    /** Encodes the message into out at its position and advances it past the message. */\n")
+	g.buf.WriteString("    public void encode(ByteBuffer out) {\n")
+	g.buf.WriteString("        ByteBuffer buf = out.slice().order(ByteOrder.LITTLE_ENDIAN);\n")
+	g.buf.WriteString("        encodeTo(buf);\n")
+	g.buf.WriteString("        out.position(out.position() + buf.position());\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    /** Decodes a message at the position of data and advances it past the message. */\n")
+	g.buf.WriteString("    public static " + className + " decode(ByteBuffer data) {\n")
+	g.buf.WriteString("        ByteBuffer buf = data.slice().order(ByteOrder.LITTLE_ENDIAN);\n")
+	fmt.Fprintf(g.buf, "        %s obj = new %s();\n", className, className)
+	g.generateDecodeCall(false)
+	g.buf.WriteString("        data.position(data.position() + buf.position());\n")
+	g.buf.WriteString("        return obj;\n")
+	g.buf.WriteString("    }\n\n")
+}
+
+// generateSegmentMethods adds the MemorySegment overloads of encode and
+// decode to a message class when generating for the FFM API. They view the
+// segment as a ByteBuffer, so native memory is read and written in place.
+func (g *javaGenerator) generateSegmentMethods(className string) {
+	if !g.opts.FFM {
+		return
+	}
+	g.buf.WriteString("    /** Encodes the message into a segment allocated from allocator, such as an Arena. */\n")
+	g.buf.WriteString("    public MemorySegment encode(SegmentAllocator allocator) {\n")
+	g.buf.WriteString("        MemorySegment segment = allocator.allocate(computeSize());\n")
+	g.buf.WriteString("        encodeTo(segment.asByteBuffer().order(ByteOrder.LITTLE_ENDIAN));\n")
+	g.buf.WriteString("        return segment;\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    /** Decodes a message from data, which may be native memory, such as a buffer returned by a downcall. */\n")
+	g.buf.WriteString("    public static " + className + " decode(MemorySegment data) {\n")
+	g.buf.WriteString("        ByteBuffer buf = data.asByteBuffer().order(ByteOrder.LITTLE_ENDIAN);\n")
+	fmt.Fprintf(g.buf, "        %s obj = new %s();\n", className, className)
+	g.generateDecodeCall(true)
+	g.buf.WriteString("        return obj;\n")
+	g.buf.WriteString("    }\n\n")
+}
+
+// generateEnum declares enum with each constant holding its wire value.
+// fromValue maps a wire value back to its constant and throws for values
+// that are not declared, which is how decoders reject them.
+func (g *javaGenerator) generateEnum(enum *schema.EnumType) {
+	base := g.javaBaseType(enum.Base)
+	g.buf.WriteString(javadoc("", enum.Doc))
+	fmt.Fprintf(g.buf, "enum %s {\n", enum.Name)
+	for i, v := range enum.Values {
+		g.buf.WriteString(javadoc("    ", v.Doc))
+		sep := ","
+		if i == len(enum.Values)-1 {
+			sep = ";"
+		}
+		literal := fmt.Sprint(v.Value)
+		if base == "long" || v.Value != int64(int32(v.Value)) {
+			literal += "L" // u32 values past MaxInt32 only fit a long literal
+		}
+		fmt.Fprintf(g.buf, "    %s((%s) %s)%s\n", v.Name, base, literal, sep)
+	}
+	g.buf.WriteString("\n")
+	fmt.Fprintf(g.buf, "    public final %s value;\n\n", base)
+	fmt.Fprintf(g.buf, "    %s(%s value) {\n", enum.Name, base)
+	g.buf.WriteString("        this.value = value;\n")
+	g.buf.WriteString("    }\n\n")
+	fmt.Fprintf(g.buf, "    public static %s fromValue(%s value) {\n", enum.Name, base)
+	fmt.Fprintf(g.buf, "        for (%s v : values()) {\n", enum.Name)
+	g.buf.WriteString("            if (v.value == value) {\n")
+	g.buf.WriteString("                return v;\n")
+	g.buf.WriteString("            }\n")
+	g.buf.WriteString("        }\n")
+	fmt.Fprintf(g.buf, "        throw new DecodeException(DecodeErrorCode.INVALID_VALUE, \"invalid %s value \" + value);\n", enum.Name)
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
+
 func (g *javaGenerator) collectNeededTypes(t schema.Type) {
 	switch typ := t.(type) {
 	case *schema.StructType:
@@ -73,7 +279,88 @@ func (g *javaGenerator) collectNeededTypes(t schema.Type) {
 		}
 	case *schema.ArrayType:
 		g.collectNeededTypes(typ.ElementType)
+	case *schema.MapType:
+		g.collectNeededTypes(typ.ValueType)
+	case *schema.UnionType:
+		for _, v := range typ.Variants {
+			g.collectNeededTypes(v)
+		}
+	}
+}
+
+// generateUnion declares a class with one field per variant, boxed so that
+// null means unset. Exactly one field must be set when encoding; the first
+// non-null one, in variant order, is the one written.
+func (g *javaGenerator) generateUnion(union *schema.UnionType) {
+	g.buf.WriteString(javadoc("", union.Doc))
+	fmt.Fprintf(g.buf, "class %s {\n", union.Name)
+	for _, v := range union.Variants {
+		fmt.Fprintf(g.buf, "    public %s %s;\n", g.javaRefType(v), javaVariantField(v))
+	}
+	g.buf.WriteString("\n")
+
+	g.buf.WriteString("    int computeSize() {\n")
+	g.buf.WriteString("        int size = 1;\n")
+	for i, v := range union.Variants {
+		field := javaVariantField(v)
+		if i == 0 {
+			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field)
+		} else {
+			fmt.Fprintf(g.buf, "        } else if (%s != null) {\n", field)
+		}
+		g.generateMapValueSize(v, field, "            ")
+	}
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("        return size;\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    void encodeTo(ByteBuffer buf) {\n")
+	for i, v := range union.Variants {
+		field := javaVariantField(v)
+		if i == 0 {
+			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field)
+		} else {
+			fmt.Fprintf(g.buf, "        } else if (%s != null) {\n", field)
+		}
+		fmt.Fprintf(g.buf, "            buf.put((byte) %d);\n", i+1)
+		g.generateMapValueEncode(v, field, "            ")
+	}
+	g.buf.WriteString("        } else {\n")
+	fmt.Fprintf(g.buf, "            throw new IllegalStateException(\"%s has no variant set\");\n", union.Name)
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    void decodeFrom(ByteBuffer buf) {\n")
+	g.buf.WriteString("        int tag = buf.get() & 0xFF;\n")
+	g.buf.WriteString("        switch (tag) {\n")
+	for i, v := range union.Variants {
+		fmt.Fprintf(g.buf, "            case %d:\n", i+1)
+		g.generateMapValueDecode(v, javaVariantField(v), "                ")
+		g.buf.WriteString("                break;\n")
 	}
+	g.buf.WriteString("            default:\n")
+	fmt.Fprintf(g.buf, "                throw new DecodeException(DecodeErrorCode.INVALID_VALUE, \"invalid %s tag \" + tag);\n", union.Name)
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n")
+
+	for _, v := range union.Variants {
+		if prim, ok := v.(*schema.PrimitiveType); ok && prim.Name == "string" {
+			g.buf.WriteString("\n")
+			g.buf.WriteString("    private static String decodeString(ByteBuffer buf) {\n")
+			g.buf.WriteString("        return FFireDecode.readString(buf, buf.getShort() & 0xFFFF);\n")
+			g.buf.WriteString("    }\n")
+		}
+		if prim, ok := v.(*schema.PrimitiveType); ok && prim.Name == "bytes" {
+			g.buf.WriteString("\n")
+			g.generateDecodeBytesHelper()
+		}
+	}
+	g.buf.WriteString("}\n\n")
+}
+
+// javaVariantField names the field holding a union variant.
+func javaVariantField(v schema.Type) string {
+	return ToCamelCase(v.TypeName())
 }
 
 func (g *javaGenerator) generateSliceClasses() error {
@@ -802,75 +1089,20 @@ func (g *javaGenerator) generateHelperClasses() error {
 }
 
 func (g *javaGenerator) topologicalSort() ([]string, error) {
-	// deps[A] = [B, C] means A depends on B and C (A uses B and C)
-	deps := make(map[string][]string)
-	for name := range g.needsTypes {
-		structType := g.findStructType(name)
-		if structType != nil {
-			for _, field := range structType.Fields {
-				if st, ok := field.Type.(*schema.StructType); ok {
-					if g.needsTypes[st.Name] {
-						deps[name] = append(deps[name], st.Name)
-					}
-				}
-				if arr, ok := field.Type.(*schema.ArrayType); ok {
-					if st, ok := arr.ElementType.(*schema.StructType); ok {
-						if g.needsTypes[st.Name] {
-							deps[name] = append(deps[name], st.Name)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// inDegree[A] = number of types that depend on A
-	// Types with inDegree 0 have no dependencies, so they can be generated first
-	inDegree := make(map[string]int)
-	for name := range g.needsTypes {
-		if _, exists := deps[name]; !exists {
-			inDegree[name] = 0
-		} else {
-			inDegree[name] = len(deps[name])
+	var needed []*schema.StructType
+	for _, st := range schemaStructs(g.schema) {
+		if g.needsTypes[st.Name] {
+			needed = append(needed, st)
 		}
 	}
-
-	// Start with types that have no dependencies
-	var queue []string
-	for name, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, name)
-		}
+	if len(needed) != len(g.needsTypes) {
+		return nil, fmt.Errorf("struct types used by messages are not declared in the schema")
 	}
 
 	var sorted []string
-	for len(queue) > 0 {
-		sort.Strings(queue)
-
-		// Take the first type with no dependencies
-		name := queue[0]
-		queue = queue[1:]
-		sorted = append(sorted, name)
-
-		// Now that we've processed 'name', reduce the dependency count
-		// for all types that depend on it
-		for dependent, depList := range deps {
-			for _, dep := range depList {
-				if dep == name {
-					inDegree[dependent]--
-					if inDegree[dependent] == 0 {
-						queue = append(queue, dependent)
-					}
-					break
-				}
-			}
-		}
-	}
-
-	if len(sorted) != len(g.needsTypes) {
-		return nil, fmt.Errorf("circular dependency detected in struct types")
+	for _, st := range sortStructsByDependency(needed) {
+		sorted = append(sorted, st.Name)
 	}
-
 	return sorted, nil
 }
 
@@ -900,6 +1132,7 @@ func (g *javaGenerator) generateMessageClass(msg *schema.MessageType) error {
 
 	case *schema.ArrayType:
 		// Array message - generate as XxxMessage with array field
+		g.buf.WriteString(javadoc("", msg.Doc))
 		return g.generateArrayMessageClass(msg.Name, targetType)
 
 	default:
@@ -923,6 +1156,7 @@ func (g *javaGenerator) generateStructClass(typeName string, isMessage bool) err
 
 func (g *javaGenerator) generateStructClassWithName(structType *schema.StructType, className string, isHelper bool) error {
 	// Helper classes are package-private, message classes are public
+	g.buf.WriteString(javadoc("", structType.Doc))
 	if isHelper {
 		fmt.Fprintf(g.buf, "class %s {\n", className)
 	} else {
@@ -931,6 +1165,11 @@ func (g *javaGenerator) generateStructClassWithName(structType *schema.StructTyp
 
 	for _, field := range structType.Fields {
 		javaType := g.javaType(field.Type)
+		g.buf.WriteString(javadoc("    ", field.Doc))
+		if arr, ok := field.Type.(*schema.ArrayType); ok && arr.Length > 0 {
+			fmt.Fprintf(g.buf, "    public %s %s = new %s[%d];\n", javaType, field.Name, strings.TrimSuffix(javaType, "[]"), arr.Length)
+			continue
+		}
 		fmt.Fprintf(g.buf, "    public %s %s;\n", javaType, field.Name)
 	}
 	g.buf.WriteString("\n")
@@ -948,39 +1187,65 @@ func (g *javaGenerator) generateStructClassWithName(structType *schema.StructTyp
 	g.buf.WriteString("        ByteBuffer buf = ByteBuffer.wrap(data);\n")
 	g.buf.WriteString("        buf.order(ByteOrder.LITTLE_ENDIAN);\n")
 	fmt.Fprintf(g.buf, "        %s obj = new %s();\n", className, className)
-	g.buf.WriteString("        obj.decodeFrom(buf);\n")
+	g.generateDecodeCall(true)
 	g.buf.WriteString("        return obj;\n")
 	g.buf.WriteString("    }\n\n")
 
+	if !isHelper {
+		g.generateByteBufferMethods(className)
+		g.generateSegmentMethods(className)
+	}
+
 	// computeSize, encodeTo, decodeFrom are package-private so they can be called by other classes in the same package
 	g.buf.WriteString("    int computeSize() {\n")
 	g.buf.WriteString("        int size = 0;\n")
+	if n := schema.BitmapSize(structType); n > 0 {
+		fmt.Fprintf(g.buf, "        size += %d;\n", n)
+	}
 	for _, field := range structType.Fields {
+		g.cached = field.Cached
+		if structType.Bitmap && field.Type.IsOptional() {
+			g.generateBitmappedField(&field, fmt.Sprintf("%s != null", field.Name), g.generateSizeComputation)
+			continue
+		}
 		g.generateSizeComputation(&field)
 	}
+	g.cached = false
 	g.buf.WriteString("        return size;\n")
 	g.buf.WriteString("    }\n\n")
 
 	g.buf.WriteString("    void encodeTo(ByteBuffer buf) {\n")
+	g.generateWriteBitmap(structType)
 	for _, field := range structType.Fields {
+		g.cached = field.Cached
+		if structType.Bitmap && field.Type.IsOptional() {
+			g.generateBitmappedField(&field, fmt.Sprintf("%s != null", field.Name), g.generateEncodeField)
+			continue
+		}
 		g.generateEncodeField(&field)
 	}
+	g.cached = false
 	g.buf.WriteString("    }\n\n")
 
 	g.buf.WriteString("    void decodeFrom(ByteBuffer buf) {\n")
+	bits := g.generateReadBitmap(structType)
 	for _, field := range structType.Fields {
+		if test, ok := bits[field.Name]; ok {
+			g.generateBitmappedField(&field, test, g.generateDecodeField)
+			continue
+		}
 		g.generateDecodeField(&field)
 	}
 	g.buf.WriteString("    }\n\n")
 
-	if g.structUsesStrings(structType) {
+	if g.structUses(structType, "string") {
 		g.buf.WriteString("    private static String decodeString(ByteBuffer buf) {\n")
-		g.buf.WriteString("        int len = buf.getShort() & 0xFFFF;\n")
-		g.buf.WriteString("        byte[] bytes = new byte[len];\n")
-		g.buf.WriteString("        buf.get(bytes);\n")
-		g.buf.WriteString("        return new String(bytes, StandardCharsets.UTF_8);\n")
+		g.buf.WriteString("        return FFireDecode.readString(buf, buf.getShort() & 0xFFFF);\n")
 		g.buf.WriteString("    }\n")
 	}
+	if g.structUses(structType, "bytes") {
+		g.generateDecodeBytesHelper()
+	}
 
 	g.buf.WriteString("}\n\n")
 	return nil
@@ -1049,13 +1314,16 @@ func (g *javaGenerator) generateArrayMessageClass(msgName string, arrayType *sch
 	g.buf.WriteString("        ByteBuffer buf = ByteBuffer.wrap(data);\n")
 	g.buf.WriteString("        buf.order(ByteOrder.LITTLE_ENDIAN);\n")
 	fmt.Fprintf(g.buf, "        %s obj = new %s();\n", className, className)
-	g.buf.WriteString("        obj.decodeFrom(buf);\n")
+	g.generateDecodeCall(true)
 	g.buf.WriteString("        return obj;\n")
 	g.buf.WriteString("    }\n\n")
 
+	g.generateByteBufferMethods(className)
+	g.generateSegmentMethods(className)
+
 	// computeSize() - package-private
 	g.buf.WriteString("    int computeSize() {\n")
-	g.buf.WriteString("        int size = 2; // array length prefix\n")
+	fmt.Fprintf(g.buf, "        int size = %d; // array length prefix\n", schema.LengthPrefixSize(arrayType))
 
 	if isPrimitiveArray {
 		// For slice types, use len() and fixed element size
@@ -1091,11 +1359,11 @@ func (g *javaGenerator) generateArrayMessageClass(msgName string, arrayType *sch
 
 	if isPrimitiveArray {
 		// For slice types, use len() and built-in encodeTo()
-		g.buf.WriteString("        buf.putShort((short) items.len());\n")
+		fmt.Fprintf(g.buf, "        %s\n", javaPutLength("items.len()", arrayType))
 		g.buf.WriteString("        items.encodeTo(buf);\n")
 	} else {
 		// For List types
-		g.buf.WriteString("        buf.putShort((short) items.size());\n")
+		fmt.Fprintf(g.buf, "        %s\n", javaPutLength("items.size()", arrayType))
 		g.buf.WriteString("        for (var elem : items) {\n")
 		switch elemType := arrayType.ElementType.(type) {
 		case *schema.PrimitiveType:
@@ -1110,7 +1378,7 @@ func (g *javaGenerator) generateArrayMessageClass(msgName string, arrayType *sch
 
 	// decodeFrom() - package-private
 	g.buf.WriteString("    void decodeFrom(ByteBuffer buf) {\n")
-	g.buf.WriteString("        int count = buf.getShort() & 0xFFFF;\n")
+	fmt.Fprintf(g.buf, "        int count = %s;\n", javaGetLength(arrayType))
 
 	if isPrimitiveArray {
 		// For slice types, use static decodeFrom()
@@ -1138,12 +1406,12 @@ func (g *javaGenerator) generateArrayMessageClass(msgName string, arrayType *sch
 	// Helper for string decoding if needed
 	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && prim.Name == "string" {
 		g.buf.WriteString("    private static String decodeString(ByteBuffer buf) {\n")
-		g.buf.WriteString("        int len = buf.getShort() & 0xFFFF;\n")
-		g.buf.WriteString("        byte[] bytes = new byte[len];\n")
-		g.buf.WriteString("        buf.get(bytes);\n")
-		g.buf.WriteString("        return new String(bytes, StandardCharsets.UTF_8);\n")
+		g.buf.WriteString("        return FFireDecode.readString(buf, buf.getShort() & 0xFFFF);\n")
 		g.buf.WriteString("    }\n")
 	}
+	if prim, ok := arrayType.ElementType.(*schema.PrimitiveType); ok && prim.Name == "bytes" {
+		g.generateDecodeBytesHelper()
+	}
 
 	g.buf.WriteString("}\n\n")
 	return nil
@@ -1158,6 +1426,10 @@ func (g *javaGenerator) javaType(t schema.Type) string {
 		}
 		return baseType
 	case *schema.ArrayType:
+		// Fixed-size arrays are plain Java arrays
+		if typ.Length > 0 {
+			return g.javaBaseType(typ.ElementType.(*schema.PrimitiveType).Name) + "[]"
+		}
 		// For primitive arrays, use slice types instead of List<Boxed>
 		if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional {
 			switch prim.Name {
@@ -1181,8 +1453,10 @@ func (g *javaGenerator) javaType(t schema.Type) string {
 			elemType = g.boxedType(g.javaBaseType(prim.Name))
 		}
 		return "List<" + elemType + ">"
-	case *schema.StructType:
-		return typ.Name
+	case *schema.MapType:
+		return "java.util.Map<" + g.javaRefType(typ.KeyType) + ", " + g.javaRefType(typ.ValueType) + ">"
+	case *schema.EnumType, *schema.StructType, *schema.UnionType:
+		return typ.TypeName()
 	}
 	return "Object"
 }
@@ -1205,6 +1479,8 @@ func (g *javaGenerator) javaBaseType(kind string) string {
 		return "double"
 	case "string":
 		return "String"
+	case "bytes":
+		return "byte[]"
 	default:
 		return "Object"
 	}
@@ -1237,12 +1513,16 @@ func (g *javaGenerator) generateSizeComputation(field *schema.Field) {
 		if typ.Optional {
 			g.buf.WriteString("        size += 1;\n")
 			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
-			g.buf.WriteString("            size += " + g.sizeOf(typ.Name, field.Name) + ";\n")
+			g.buf.WriteString("            size += " + g.fieldSizeOf(typ, field.Name) + ";\n")
 			g.buf.WriteString("        }\n")
 		} else {
-			g.buf.WriteString("        size += " + g.sizeOf(typ.Name, field.Name) + ";\n")
+			g.buf.WriteString("        size += " + g.fieldSizeOf(typ, field.Name) + ";\n")
 		}
 	case *schema.ArrayType:
+		if typ.Length > 0 {
+			fmt.Fprintf(g.buf, "        size += %d;\n", typ.Length*schema.GetPrimitiveSize(typ.ElementType))
+			break
+		}
 		// Check if this is a primitive array (uses slice type)
 		isPrimitiveArray := false
 		if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional {
@@ -1258,8 +1538,8 @@ func (g *javaGenerator) generateSizeComputation(field *schema.Field) {
 			// Optional array: 1 byte for presence
 			g.buf.WriteString("        size += 1;\n")
 			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
-			g.buf.WriteString("            size += 2;\n") // array length
-			if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+			fmt.Fprintf(g.buf, "            size += %d;\n", schema.LengthPrefixSize(typ)) // array length
+			if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" && prim.Name != "bytes" {
 				if isPrimitiveArray {
 					// For slice types, use len() instead of size()
 					fmt.Fprintf(g.buf, "            size += %s.len() * %s;\n", field.Name, g.sizeOf(prim.Name, ""))
@@ -1275,6 +1555,8 @@ func (g *javaGenerator) generateSizeComputation(field *schema.Field) {
 					g.buf.WriteString("                }\n")
 				} else if _, ok := typ.ElementType.(*schema.StructType); ok {
 					g.buf.WriteString("                size += elem.computeSize();\n")
+				} else if isEnumOrUnion(typ.ElementType) {
+					g.generateMapValueSize(typ.ElementType, "elem", "                ")
 				} else if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok {
 					g.buf.WriteString("                size += " + g.sizeOf(prim.Name, "elem") + ";\n")
 				}
@@ -1282,10 +1564,10 @@ func (g *javaGenerator) generateSizeComputation(field *schema.Field) {
 			}
 			g.buf.WriteString("        }\n")
 		} else {
-			// Non-optional array: 2 bytes for length
-			g.buf.WriteString("        size += 2;\n")
+			// Non-optional array: the length prefix
+			fmt.Fprintf(g.buf, "        size += %d;\n", schema.LengthPrefixSize(typ))
 			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
-			if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" {
+			if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" && prim.Name != "bytes" {
 				if isPrimitiveArray {
 					// For slice types, use len() instead of size()
 					fmt.Fprintf(g.buf, "            size += %s.len() * %s;\n", field.Name, g.sizeOf(prim.Name, ""))
@@ -1301,6 +1583,8 @@ func (g *javaGenerator) generateSizeComputation(field *schema.Field) {
 					g.buf.WriteString("                }\n")
 				} else if _, ok := typ.ElementType.(*schema.StructType); ok {
 					g.buf.WriteString("                size += elem.computeSize();\n")
+				} else if isEnumOrUnion(typ.ElementType) {
+					g.generateMapValueSize(typ.ElementType, "elem", "                ")
 				} else if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok {
 					g.buf.WriteString("                size += " + g.sizeOf(prim.Name, "elem") + ";\n")
 				}
@@ -1308,6 +1592,18 @@ func (g *javaGenerator) generateSizeComputation(field *schema.Field) {
 			}
 			g.buf.WriteString("        }\n")
 		}
+	case *schema.MapType:
+		if typ.Optional {
+			g.generateMapValueSize(typ, field.Name, "        ")
+		} else {
+			// A null map encodes as an empty one, like a null array
+			fmt.Fprintf(g.buf, "        size += %d;\n", schema.LengthPrefixSize(typ))
+			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
+			g.generateMapEntriesSize(typ, field.Name, "            ")
+			g.buf.WriteString("        }\n")
+		}
+	case *schema.EnumType, *schema.UnionType:
+		g.generateMapValueSize(typ, field.Name, "        ")
 	case *schema.StructType:
 		fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
 		fmt.Fprintf(g.buf, "            size += %s.computeSize();\n", field.Name)
@@ -1326,29 +1622,113 @@ func (g *javaGenerator) sizeOf(kind string, varName string) string {
 	case "i64", "u64", "int64", "uint64", "f64", "float64":
 		return "8"
 	case "string":
+		if varName == "" {
+			varName = "elem"
+		}
+		if g.cached {
+			return "FFireStringCache.encoded(" + varName + ").length"
+		}
+		return varName + ".getBytes(StandardCharsets.UTF_8).length + 2"
+	case "bytes":
 		if varName != "" {
-			return varName + ".getBytes(StandardCharsets.UTF_8).length + 2"
+			return varName + ".length + 2"
 		}
-		return "elem.getBytes(StandardCharsets.UTF_8).length + 2"
+		return "elem.length + 2"
 	default:
 		return "0"
 	}
 }
 
+// fieldSizeOf is sizeOf for a field, which may be a @large string or bytes.
+func (g *javaGenerator) fieldSizeOf(typ *schema.PrimitiveType, varName string) string {
+	if !typ.Large {
+		return g.sizeOf(typ.Name, varName)
+	}
+	if typ.Name == "string" {
+		return varName + ".getBytes(StandardCharsets.UTF_8).length + 4"
+	}
+	return varName + ".length + 4"
+}
+
+// generateWriteBitmap writes the presence bitmap of a @bitmap struct, one
+// bit per optional field in field order.
+func (g *javaGenerator) generateWriteBitmap(st *schema.StructType) {
+	n := schema.BitmapSize(st)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(g.buf, "        byte bits%d = 0;\n", i)
+	}
+	bit := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			fmt.Fprintf(g.buf, "        if (%s != null) bits%d |= 0x%02x;\n", field.Name, bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(g.buf, "        buf.put(bits%d);\n", i)
+	}
+}
+
+// generateReadBitmap reads the presence bitmap of a @bitmap struct,
+// rejecting set unused bits, and returns the bit test of each optional
+// field by field name. It returns nil for other structs.
+func (g *javaGenerator) generateReadBitmap(st *schema.StructType) map[string]string {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return nil
+	}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(g.buf, "        byte bits%d = buf.get();\n", i)
+	}
+	if used := schema.OptionalFields(st) % 8; used != 0 {
+		fmt.Fprintf(g.buf, "        if ((bits%d & 0x%02x) != 0) {\n", n-1, 0xff<<used&0xff)
+		g.buf.WriteString("            throw new DecodeException(DecodeErrorCode.BAD_PRESENCE, \"unused presence bitmap bits set at offset \" + (buf.position() - 1));\n")
+		g.buf.WriteString("        }\n")
+	}
+	tests := make(map[string]string)
+	bit := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			tests[field.Name] = fmt.Sprintf("(bits%d & 0x%02x) != 0", bit/8, 1<<(bit%8))
+			bit++
+		}
+	}
+	return tests
+}
+
+// generateBitmappedField emits the code gen writes for an optional field of
+// a @bitmap struct, without its presence byte, guarded by cond.
+func (g *javaGenerator) generateBitmappedField(field *schema.Field, cond string, gen func(*schema.Field)) {
+	stripped := *field
+	stripped.Type = schema.WithoutPresence(field.Type)
+	outer := g.buf
+	g.buf = &bytes.Buffer{}
+	gen(&stripped)
+	inner := g.buf.String()
+	g.buf = outer
+	fmt.Fprintf(g.buf, "        if (%s) {\n", cond)
+	g.buf.WriteString(indentCode(inner, "    "))
+	g.buf.WriteString("        }\n")
+}
+
 func (g *javaGenerator) generateEncodeField(field *schema.Field) {
 	switch typ := field.Type.(type) {
 	case *schema.PrimitiveType:
 		if typ.Optional {
 			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
 			g.buf.WriteString("            buf.put((byte) 1);\n")
-			g.generatePrimitiveEncode(field.Name, typ.Name)
+			g.generateFieldPrimitiveEncode(field.Name, typ)
 			g.buf.WriteString("        } else {\n")
 			g.buf.WriteString("            buf.put((byte) 0);\n")
 			g.buf.WriteString("        }\n")
 		} else {
-			g.generatePrimitiveEncode(field.Name, typ.Name)
+			g.generateFieldPrimitiveEncode(field.Name, typ)
 		}
 	case *schema.ArrayType:
+		if typ.Length > 0 {
+			g.generateFixedArrayEncode(field.Name, typ)
+			break
+		}
 		// Check if this is a primitive array (uses slice type)
 		isPrimitiveArray := false
 		if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && !prim.Optional {
@@ -1367,11 +1747,11 @@ func (g *javaGenerator) generateEncodeField(field *schema.Field) {
 
 			if isPrimitiveArray {
 				// For slice types, use len() instead of size()
-				fmt.Fprintf(g.buf, "            buf.putShort((short) %s.len());\n", field.Name)
+				fmt.Fprintf(g.buf, "            %s\n", javaPutLength(field.Name+".len()", typ))
 				// Use slice's built-in encodeTo() method
 				fmt.Fprintf(g.buf, "            %s.encodeTo(buf);\n", field.Name)
 			} else {
-				fmt.Fprintf(g.buf, "            buf.putShort((short) %s.size());\n", field.Name)
+				fmt.Fprintf(g.buf, "            %s\n", javaPutLength(field.Name+".size()", typ))
 				fmt.Fprintf(g.buf, "            for (var elem : %s) {\n", field.Name)
 				if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && prim.Optional {
 					g.buf.WriteString("                if (elem != null) {\n")
@@ -1382,6 +1762,8 @@ func (g *javaGenerator) generateEncodeField(field *schema.Field) {
 					g.buf.WriteString("                }\n")
 				} else if _, ok := typ.ElementType.(*schema.StructType); ok {
 					g.buf.WriteString("                elem.encodeTo(buf);\n")
+				} else if isEnumOrUnion(typ.ElementType) {
+					g.generateMapValueEncode(typ.ElementType, "elem", "                ")
 				} else if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok {
 					g.generatePrimitiveEncode("elem", prim.Name)
 				}
@@ -1394,13 +1776,13 @@ func (g *javaGenerator) generateEncodeField(field *schema.Field) {
 			// Non-optional array
 			if isPrimitiveArray {
 				// For slice types
-				fmt.Fprintf(g.buf, "        buf.putShort((short) (%s != null ? %s.len() : 0));\n", field.Name, field.Name)
+				fmt.Fprintf(g.buf, "        %s\n", javaPutLength(fmt.Sprintf("(%s != null ? %s.len() : 0)", field.Name, field.Name), typ))
 				fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
 				fmt.Fprintf(g.buf, "            %s.encodeTo(buf);\n", field.Name)
 				g.buf.WriteString("        }\n")
 			} else {
 				// For List types
-				fmt.Fprintf(g.buf, "        buf.putShort((short) (%s != null ? %s.size() : 0));\n", field.Name, field.Name)
+				fmt.Fprintf(g.buf, "        %s\n", javaPutLength(fmt.Sprintf("(%s != null ? %s.size() : 0)", field.Name, field.Name), typ))
 				fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
 				fmt.Fprintf(g.buf, "            for (var elem : %s) {\n", field.Name)
 				if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && prim.Optional {
@@ -1412,6 +1794,8 @@ func (g *javaGenerator) generateEncodeField(field *schema.Field) {
 					g.buf.WriteString("                }\n")
 				} else if _, ok := typ.ElementType.(*schema.StructType); ok {
 					g.buf.WriteString("                elem.encodeTo(buf);\n")
+				} else if isEnumOrUnion(typ.ElementType) {
+					g.generateMapValueEncode(typ.ElementType, "elem", "                ")
 				} else if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok {
 					g.generatePrimitiveEncode("elem", prim.Name)
 				}
@@ -1419,6 +1803,18 @@ func (g *javaGenerator) generateEncodeField(field *schema.Field) {
 				g.buf.WriteString("        }\n")
 			}
 		}
+	case *schema.MapType:
+		if typ.Optional {
+			g.generateMapValueEncode(typ, field.Name, "        ")
+		} else {
+			fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
+			g.generateMapValueEncode(typ, field.Name, "            ")
+			g.buf.WriteString("        } else {\n")
+			fmt.Fprintf(g.buf, "            %s\n", javaPutLength("0", typ))
+			g.buf.WriteString("        }\n")
+		}
+	case *schema.EnumType, *schema.UnionType:
+		g.generateMapValueEncode(typ, field.Name, "        ")
 	case *schema.StructType:
 		fmt.Fprintf(g.buf, "        if (%s != null) {\n", field.Name)
 		fmt.Fprintf(g.buf, "            %s.encodeTo(buf);\n", field.Name)
@@ -1482,29 +1878,58 @@ func (g *javaGenerator) generatePrimitiveEncode(fieldName, kind string) {
 	case "f64", "float64":
 		fmt.Fprintf(g.buf, "            buf.putDouble(%s);\n", fieldName)
 	case "string":
+		if g.cached {
+			fmt.Fprintf(g.buf, "            buf.put(FFireStringCache.encoded(%s));\n", fieldName)
+			break
+		}
 		// Use field name to make variable unique
 		bytesVar := strings.ToLower(fieldName[:1]) + fieldName[1:] + "Bytes"
 		fmt.Fprintf(g.buf, "            byte[] %s = %s.getBytes(StandardCharsets.UTF_8);\n", bytesVar, fieldName)
 		fmt.Fprintf(g.buf, "            buf.putShort((short) %s.length);\n", bytesVar)
 		fmt.Fprintf(g.buf, "            buf.put(%s);\n", bytesVar)
+	case "bytes":
+		fmt.Fprintf(g.buf, "            buf.putShort((short) %s.length);\n", fieldName)
+		fmt.Fprintf(g.buf, "            buf.put(%s);\n", fieldName)
+	}
+}
+
+// generateFieldPrimitiveEncode is generatePrimitiveEncode for a field,
+// which may be a @large string or bytes.
+func (g *javaGenerator) generateFieldPrimitiveEncode(fieldName string, typ *schema.PrimitiveType) {
+	if !typ.Large {
+		g.generatePrimitiveEncode(fieldName, typ.Name)
+		return
+	}
+	if typ.Name == "string" {
+		bytesVar := strings.ToLower(fieldName[:1]) + fieldName[1:] + "Bytes"
+		fmt.Fprintf(g.buf, "            byte[] %s = %s.getBytes(StandardCharsets.UTF_8);\n", bytesVar, fieldName)
+		fmt.Fprintf(g.buf, "            buf.putInt(%s.length);\n", bytesVar)
+		fmt.Fprintf(g.buf, "            buf.put(%s);\n", bytesVar)
+		return
 	}
+	fmt.Fprintf(g.buf, "            buf.putInt(%s.length);\n", fieldName)
+	fmt.Fprintf(g.buf, "            buf.put(%s);\n", fieldName)
 }
 
 func (g *javaGenerator) generateDecodeField(field *schema.Field) {
 	switch typ := field.Type.(type) {
 	case *schema.PrimitiveType:
 		if typ.Optional {
-			g.buf.WriteString("        if (buf.get() == 1) {\n")
+			g.buf.WriteString("        if (FFireDecode.readPresence(buf)) {\n")
 			fmt.Fprintf(g.buf, "            %s = ", field.Name)
-			g.generatePrimitiveDecode(typ.Name)
+			g.generateFieldPrimitiveDecode(typ)
 			g.buf.WriteString(";\n")
 			g.buf.WriteString("        }\n")
 		} else {
 			fmt.Fprintf(g.buf, "        %s = ", field.Name)
-			g.generatePrimitiveDecode(typ.Name)
+			g.generateFieldPrimitiveDecode(typ)
 			g.buf.WriteString(";\n")
 		}
 	case *schema.ArrayType:
+		if typ.Length > 0 {
+			g.generateFixedArrayDecode(field.Name, typ)
+			break
+		}
 		// Check if this is a primitive array (uses slice type)
 		isPrimitiveArray := false
 		sliceType := ""
@@ -1531,19 +1956,21 @@ func (g *javaGenerator) generateDecodeField(field *schema.Field) {
 			}
 		}
 
+		// Array fields share decodeFrom's scope, so each needs its own length variable
+		lenVar := g.uniqueVar("len")
 		if typ.Optional {
 			// Optional array: check presence byte first
-			g.buf.WriteString("        if (buf.get() == 1) {\n")
-			g.buf.WriteString("            int len = buf.getShort() & 0xFFFF;\n")
+			g.buf.WriteString("        if (FFireDecode.readPresence(buf)) {\n")
+			fmt.Fprintf(g.buf, "            int %s = %s;\n", lenVar, javaGetLength(typ))
 
 			if isPrimitiveArray {
 				// Use slice's decodeFrom() method
-				fmt.Fprintf(g.buf, "            %s = %s.decodeFrom(buf, len);\n", field.Name, sliceType)
+				fmt.Fprintf(g.buf, "            %s = %s.decodeFrom(buf, %s);\n", field.Name, sliceType, lenVar)
 			} else {
-				fmt.Fprintf(g.buf, "            %s = new ArrayList<>(len);\n", field.Name)
-				g.buf.WriteString("            for (int i = 0; i < len; i++) {\n")
+				fmt.Fprintf(g.buf, "            %s = new ArrayList<>(%s);\n", field.Name, lenVar)
+				fmt.Fprintf(g.buf, "            for (int i = 0; i < %s; i++) {\n", lenVar)
 				if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && prim.Optional {
-					g.buf.WriteString("                if (buf.get() == 1) {\n")
+					g.buf.WriteString("                if (FFireDecode.readPresence(buf)) {\n")
 					fmt.Fprintf(g.buf, "                    %s.add(", field.Name)
 					g.generatePrimitiveDecode(prim.Name)
 					g.buf.WriteString(");\n")
@@ -1554,6 +1981,10 @@ func (g *javaGenerator) generateDecodeField(field *schema.Field) {
 					fmt.Fprintf(g.buf, "                %s elem = new %s();\n", st.Name, st.Name)
 					g.buf.WriteString("                elem.decodeFrom(buf);\n")
 					fmt.Fprintf(g.buf, "                    %s.add(elem);\n", field.Name)
+				} else if isEnumOrUnion(typ.ElementType) {
+					fmt.Fprintf(g.buf, "                %s elem = null;\n", typ.ElementType.TypeName())
+					g.generateMapValueDecode(typ.ElementType, "elem", "                ")
+					fmt.Fprintf(g.buf, "                %s.add(elem);\n", field.Name)
 				} else if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok {
 					fmt.Fprintf(g.buf, "                %s.add(", field.Name)
 					g.generatePrimitiveDecode(prim.Name)
@@ -1564,16 +1995,16 @@ func (g *javaGenerator) generateDecodeField(field *schema.Field) {
 			g.buf.WriteString("        }\n")
 		} else {
 			// Non-optional array
-			g.buf.WriteString("        int len = buf.getShort() & 0xFFFF;\n")
+			fmt.Fprintf(g.buf, "        int %s = %s;\n", lenVar, javaGetLength(typ))
 
 			if isPrimitiveArray {
 				// Use slice's decodeFrom() method
-				fmt.Fprintf(g.buf, "        %s = %s.decodeFrom(buf, len);\n", field.Name, sliceType)
+				fmt.Fprintf(g.buf, "        %s = %s.decodeFrom(buf, %s);\n", field.Name, sliceType, lenVar)
 			} else {
-				fmt.Fprintf(g.buf, "        %s = new ArrayList<>(len);\n", field.Name)
-				g.buf.WriteString("        for (int i = 0; i < len; i++) {\n")
+				fmt.Fprintf(g.buf, "        %s = new ArrayList<>(%s);\n", field.Name, lenVar)
+				fmt.Fprintf(g.buf, "        for (int i = 0; i < %s; i++) {\n", lenVar)
 				if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok && prim.Optional {
-					g.buf.WriteString("            if (buf.get() == 1) {\n")
+					g.buf.WriteString("            if (FFireDecode.readPresence(buf)) {\n")
 					fmt.Fprintf(g.buf, "                %s.add(", field.Name)
 					g.generatePrimitiveDecode(prim.Name)
 					g.buf.WriteString(");\n")
@@ -1584,6 +2015,10 @@ func (g *javaGenerator) generateDecodeField(field *schema.Field) {
 					fmt.Fprintf(g.buf, "            %s elem = new %s();\n", st.Name, st.Name)
 					g.buf.WriteString("            elem.decodeFrom(buf);\n")
 					fmt.Fprintf(g.buf, "            %s.add(elem);\n", field.Name)
+				} else if isEnumOrUnion(typ.ElementType) {
+					fmt.Fprintf(g.buf, "            %s elem = null;\n", typ.ElementType.TypeName())
+					g.generateMapValueDecode(typ.ElementType, "elem", "            ")
+					fmt.Fprintf(g.buf, "            %s.add(elem);\n", field.Name)
 				} else if prim, ok := typ.ElementType.(*schema.PrimitiveType); ok {
 					fmt.Fprintf(g.buf, "            %s.add(", field.Name)
 					g.generatePrimitiveDecode(prim.Name)
@@ -1592,6 +2027,8 @@ func (g *javaGenerator) generateDecodeField(field *schema.Field) {
 				g.buf.WriteString("        }\n")
 			}
 		}
+	case *schema.MapType, *schema.EnumType, *schema.UnionType:
+		g.generateMapValueDecode(typ, field.Name, "        ")
 	case *schema.StructType:
 		fmt.Fprintf(g.buf, "        %s = new %s();\n", field.Name, typ.Name)
 		fmt.Fprintf(g.buf, "        %s.decodeFrom(buf);\n", field.Name)
@@ -1603,7 +2040,7 @@ func (g *javaGenerator) generateBulkArrayDecode(fieldName, kind string) {
 	case "bool":
 		// Boolean: element-by-element (can't use bulk operations)
 		fmt.Fprintf(g.buf, "        for (int i = 0; i < len; i++) {\n")
-		fmt.Fprintf(g.buf, "            %s.add(buf.get() != 0);\n", fieldName)
+		fmt.Fprintf(g.buf, "            %s.add(FFireDecode.readBool(buf));\n", fieldName)
 		g.buf.WriteString("        }\n")
 	case "i8", "u8", "int8", "uint8":
 		// Byte: element-by-element (ByteBuffer.get(byte[]) would work but need primitive array conversion)
@@ -1668,10 +2105,23 @@ func (g *javaGenerator) generateBulkArrayDecode(fieldName, kind string) {
 	}
 }
 
+// generateFieldPrimitiveDecode is generatePrimitiveDecode for a field,
+// which may be a @large string or bytes.
+func (g *javaGenerator) generateFieldPrimitiveDecode(typ *schema.PrimitiveType) {
+	switch {
+	case typ.Large && typ.Name == "string":
+		g.buf.WriteString("FFireLarge.decodeString(buf)")
+	case typ.Large:
+		g.buf.WriteString("FFireLarge.decodeBytes(buf)")
+	default:
+		g.generatePrimitiveDecode(typ.Name)
+	}
+}
+
 func (g *javaGenerator) generatePrimitiveDecode(kind string) {
 	switch kind {
 	case "bool":
-		g.buf.WriteString("buf.get() != 0")
+		g.buf.WriteString("FFireDecode.readBool(buf)")
 	case "i8", "u8", "int8", "uint8":
 		g.buf.WriteString("buf.get()")
 	case "i16", "u16", "int16", "uint16":
@@ -1686,30 +2136,302 @@ func (g *javaGenerator) generatePrimitiveDecode(kind string) {
 		g.buf.WriteString("buf.getDouble()")
 	case "string":
 		g.buf.WriteString("decodeString(buf)")
+	case "bytes":
+		g.buf.WriteString("decodeBytes(buf)")
 	}
 }
 
-func (g *javaGenerator) structUsesStrings(structType *schema.StructType) bool {
+// structUses reports whether a struct reaches the primitive kind, which
+// decides the decode helpers it needs.
+func (g *javaGenerator) structUses(structType *schema.StructType, kind string) bool {
 	for _, field := range structType.Fields {
-		if g.typeUsesStrings(field.Type) {
+		if g.typeUses(field.Type, kind) {
 			return true
 		}
 	}
 	return false
 }
 
-func (g *javaGenerator) typeUsesStrings(t schema.Type) bool {
+func (g *javaGenerator) typeUses(t schema.Type, kind string) bool {
 	switch typ := t.(type) {
 	case *schema.PrimitiveType:
-		return typ.Name == "string"
+		return typ.Name == kind
 	case *schema.ArrayType:
-		return g.typeUsesStrings(typ.ElementType)
+		return g.typeUses(typ.ElementType, kind)
+	case *schema.MapType:
+		return g.typeUses(typ.KeyType, kind) || g.typeUses(typ.ValueType, kind)
 	case *schema.StructType:
 		for _, field := range typ.Fields {
-			if g.typeUsesStrings(field.Type) {
+			if g.typeUses(field.Type, kind) {
 				return true
 			}
 		}
 	}
 	return false
 }
+
+// generateDecodeBytesHelper writes decodeBytes, which copies a blob out of
+// the buffer without decoding it.
+func (g *javaGenerator) generateDecodeBytesHelper() {
+	g.buf.WriteString("    private static byte[] decodeBytes(ByteBuffer buf) {\n")
+	g.buf.WriteString("        byte[] bytes = new byte[FFireDecode.checkLength(buf, buf.getShort() & 0xFFFF)];\n")
+	g.buf.WriteString("        buf.get(bytes);\n")
+	g.buf.WriteString("        return bytes;\n")
+	g.buf.WriteString("    }\n")
+}
+
+// javaBufferViews maps the numeric Java types to the ByteBuffer view that
+// copies arrays of them in bulk.
+var javaBufferViews = map[string]string{
+	"short":  "asShortBuffer",
+	"int":    "asIntBuffer",
+	"long":   "asLongBuffer",
+	"float":  "asFloatBuffer",
+	"double": "asDoubleBuffer",
+}
+
+// generateFixedArrayEncode writes a fixed-size array field without a length
+// prefix, rejecting arrays that were replaced with one of another length.
+func (g *javaGenerator) generateFixedArrayEncode(name string, typ *schema.ArrayType) {
+	elem := g.javaBaseType(typ.ElementType.(*schema.PrimitiveType).Name)
+	fmt.Fprintf(g.buf, "        if (%s.length != %d) {\n", name, typ.Length)
+	fmt.Fprintf(g.buf, "            throw new IllegalArgumentException(\"%s must hold %d elements, got \" + %s.length);\n", name, typ.Length, name)
+	g.buf.WriteString("        }\n")
+	switch elem {
+	case "boolean":
+		fmt.Fprintf(g.buf, "        for (boolean flag : %s) {\n", name)
+		g.buf.WriteString("            buf.put((byte) (flag ? 1 : 0));\n")
+		g.buf.WriteString("        }\n")
+	case "byte":
+		fmt.Fprintf(g.buf, "        buf.put(%s);\n", name)
+	default:
+		fmt.Fprintf(g.buf, "        buf.%s().put(%s);\n", javaBufferViews[elem], name)
+		fmt.Fprintf(g.buf, "        buf.position(buf.position() + %d);\n", typ.Length*schema.GetPrimitiveSize(typ.ElementType))
+	}
+}
+
+// generateFixedArrayDecode reads a fixed-size array field; the element count
+// comes from the schema rather than the wire.
+func (g *javaGenerator) generateFixedArrayDecode(name string, typ *schema.ArrayType) {
+	elem := g.javaBaseType(typ.ElementType.(*schema.PrimitiveType).Name)
+	fmt.Fprintf(g.buf, "        %s = new %s[%d];\n", name, elem, typ.Length)
+	switch elem {
+	case "boolean":
+		fmt.Fprintf(g.buf, "        for (int i = 0; i < %d; i++) {\n", typ.Length)
+		fmt.Fprintf(g.buf, "            %s[i] = FFireDecode.readBool(buf);\n", name)
+		g.buf.WriteString("        }\n")
+	case "byte":
+		fmt.Fprintf(g.buf, "        buf.get(%s);\n", name)
+	default:
+		fmt.Fprintf(g.buf, "        buf.%s().get(%s);\n", javaBufferViews[elem], name)
+		fmt.Fprintf(g.buf, "        buf.position(buf.position() + %d);\n", typ.Length*schema.GetPrimitiveSize(typ.ElementType))
+	}
+}
+
+// javaRefType is javaType with primitives boxed, for type arguments.
+func (g *javaGenerator) javaRefType(t schema.Type) string {
+	if prim, ok := t.(*schema.PrimitiveType); ok {
+		return g.boxedType(g.javaBaseType(prim.Name))
+	}
+	return g.javaType(t)
+}
+
+// javaSliceType returns the slice class javaType uses for an array of
+// non-optional numeric primitives, or "".
+func (g *javaGenerator) javaSliceType(t *schema.ArrayType) string {
+	if s := g.javaType(&schema.ArrayType{ElementType: t.ElementType}); strings.HasSuffix(s, "Slice") {
+		return s
+	}
+	return ""
+}
+
+func (g *javaGenerator) uniqueVar(prefix string) string {
+	g.varCounter++
+	return fmt.Sprintf("%s%d", prefix, g.varCounter)
+}
+
+// Map fields are encoded by the generateMapValue* functions below, which
+// recurse through the key and value types; map values may be any type,
+// including arrays and maps. Fields hold java.util.Map so callers may pass
+// any implementation; entries are sorted on encode and decoded into a
+// LinkedHashMap, which keeps the wire (ascending key) order.
+
+// javaMapKeyOrder returns the comparator that sorts keys in wire order:
+// numeric for integers, false before true, and UTF-8 byte order for
+// strings, which String.compareTo (UTF-16 order) does not match.
+func (g *javaGenerator) javaMapKeyOrder(key *schema.PrimitiveType) string {
+	if key.Name == "string" {
+		return "java.util.Comparator.comparing((String k) -> k.getBytes(StandardCharsets.UTF_8), java.util.Arrays::compareUnsigned)"
+	}
+	return "null"
+}
+
+func (g *javaGenerator) generateMapValueSize(t schema.Type, expr, indent string) {
+	inner := indent
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%ssize += 1;\n", indent)
+		fmt.Fprintf(g.buf, "%sif (%s != null) {\n", indent, expr)
+		inner += "    "
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		fmt.Fprintf(g.buf, "%ssize += %s;\n", inner, g.sizeOf(typ.Name, expr))
+	case *schema.EnumType:
+		fmt.Fprintf(g.buf, "%ssize += %s;\n", inner, g.sizeOf(typ.Base, ""))
+	case *schema.StructType, *schema.UnionType:
+		fmt.Fprintf(g.buf, "%ssize += %s.computeSize();\n", inner, expr)
+	case *schema.ArrayType:
+		fmt.Fprintf(g.buf, "%ssize += %d;\n", inner, schema.LengthPrefixSize(typ))
+		if elem, ok := typ.ElementType.(*schema.PrimitiveType); ok && g.javaSliceType(typ) != "" {
+			fmt.Fprintf(g.buf, "%ssize += %s.len() * %s;\n", inner, expr, g.sizeOf(elem.Name, ""))
+		} else {
+			elemVar := g.uniqueVar("elem")
+			fmt.Fprintf(g.buf, "%sfor (var %s : %s) {\n", inner, elemVar, expr)
+			g.generateMapValueSize(typ.ElementType, elemVar, inner+"    ")
+			fmt.Fprintf(g.buf, "%s}\n", inner)
+		}
+	case *schema.MapType:
+		fmt.Fprintf(g.buf, "%ssize += %d;\n", inner, schema.LengthPrefixSize(typ))
+		g.generateMapEntriesSize(typ, expr, inner)
+	}
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+func (g *javaGenerator) generateMapEntriesSize(t *schema.MapType, expr, indent string) {
+	entryVar := g.uniqueVar("entry")
+	fmt.Fprintf(g.buf, "%sfor (var %s : %s.entrySet()) {\n", indent, entryVar, expr)
+	g.generateMapValueSize(t.KeyType, entryVar+".getKey()", indent+"    ")
+	g.generateMapValueSize(t.ValueType, entryVar+".getValue()", indent+"    ")
+	fmt.Fprintf(g.buf, "%s}\n", indent)
+}
+
+func (g *javaGenerator) generateMapValueEncode(t schema.Type, expr, indent string) {
+	inner := indent
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%sif (%s != null) {\n", indent, expr)
+		fmt.Fprintf(g.buf, "%s    buf.put((byte) 1);\n", indent)
+		inner += "    "
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		switch typ.Name {
+		case "string":
+			bytesVar := g.uniqueVar("bytes")
+			fmt.Fprintf(g.buf, "%sbyte[] %s = %s.getBytes(StandardCharsets.UTF_8);\n", inner, bytesVar, expr)
+			fmt.Fprintf(g.buf, "%sbuf.putShort((short) %s.length);\n", inner, bytesVar)
+			fmt.Fprintf(g.buf, "%sbuf.put(%s);\n", inner, bytesVar)
+		case "bytes":
+			fmt.Fprintf(g.buf, "%sbuf.putShort((short) %s.length);\n", inner, expr)
+			fmt.Fprintf(g.buf, "%sbuf.put(%s);\n", inner, expr)
+		default:
+			// generatePrimitiveEncode writes at a fixed indent
+			out := g.buf
+			g.buf = &bytes.Buffer{}
+			g.generatePrimitiveEncode(expr, typ.Name)
+			fmt.Fprintf(out, "%s%s\n", inner, strings.TrimSpace(g.buf.String()))
+			g.buf = out
+		}
+	case *schema.EnumType:
+		out := g.buf
+		g.buf = &bytes.Buffer{}
+		g.generatePrimitiveEncode(expr+".value", typ.Base)
+		fmt.Fprintf(out, "%s%s\n", inner, strings.TrimSpace(g.buf.String()))
+		g.buf = out
+	case *schema.StructType, *schema.UnionType:
+		fmt.Fprintf(g.buf, "%s%s.encodeTo(buf);\n", inner, expr)
+	case *schema.ArrayType:
+		if g.javaSliceType(typ) != "" {
+			fmt.Fprintf(g.buf, "%s%s\n", inner, javaPutLength(expr+".len()", typ))
+			fmt.Fprintf(g.buf, "%s%s.encodeTo(buf);\n", inner, expr)
+		} else {
+			elemVar := g.uniqueVar("elem")
+			fmt.Fprintf(g.buf, "%s%s\n", inner, javaPutLength(expr+".size()", typ))
+			fmt.Fprintf(g.buf, "%sfor (var %s : %s) {\n", inner, elemVar, expr)
+			g.generateMapValueEncode(typ.ElementType, elemVar, inner+"    ")
+			fmt.Fprintf(g.buf, "%s}\n", inner)
+		}
+	case *schema.MapType:
+		key := typ.KeyType.(*schema.PrimitiveType)
+		keysVar := g.uniqueVar("keys")
+		keyVar := g.uniqueVar("key")
+		fmt.Fprintf(g.buf, "%s%s\n", inner, javaPutLength(expr+".size()", typ))
+		fmt.Fprintf(g.buf, "%svar %s = new ArrayList<>(%s.keySet());\n", inner, keysVar, expr)
+		fmt.Fprintf(g.buf, "%s%s.sort(%s);\n", inner, keysVar, g.javaMapKeyOrder(key))
+		valVar := g.uniqueVar("val")
+		fmt.Fprintf(g.buf, "%sfor (var %s : %s) {\n", inner, keyVar, keysVar)
+		fmt.Fprintf(g.buf, "%s    var %s = %s.get(%s);\n", inner, valVar, expr, keyVar)
+		g.generateMapValueEncode(key, keyVar, inner+"    ")
+		g.generateMapValueEncode(typ.ValueType, valVar, inner+"    ")
+		fmt.Fprintf(g.buf, "%s}\n", inner)
+	}
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s} else {\n", indent)
+		fmt.Fprintf(g.buf, "%s    buf.put((byte) 0);\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
+
+// generateMapValueDecode decodes a value of type t into target, an
+// already declared variable or field.
+func (g *javaGenerator) generateMapValueDecode(t schema.Type, target, indent string) {
+	inner := indent
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%sif (FFireDecode.readPresence(buf)) {\n", indent)
+		inner += "    "
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		fmt.Fprintf(g.buf, "%s%s = ", inner, target)
+		g.generatePrimitiveDecode(typ.Name)
+		g.buf.WriteString(";\n")
+	case *schema.EnumType:
+		fmt.Fprintf(g.buf, "%s%s = %s.fromValue(", inner, target, typ.Name)
+		g.generatePrimitiveDecode(typ.Base)
+		g.buf.WriteString(");\n")
+	case *schema.StructType, *schema.UnionType:
+		objVar := g.uniqueVar("obj")
+		fmt.Fprintf(g.buf, "%s%s %s = new %s();\n", inner, typ.TypeName(), objVar, typ.TypeName())
+		fmt.Fprintf(g.buf, "%s%s.decodeFrom(buf);\n", inner, objVar)
+		fmt.Fprintf(g.buf, "%s%s = %s;\n", inner, target, objVar)
+	case *schema.ArrayType:
+		lenVar := g.uniqueVar("len")
+		fmt.Fprintf(g.buf, "%sint %s = %s;\n", inner, lenVar, javaGetLength(typ))
+		if slice := g.javaSliceType(typ); slice != "" {
+			fmt.Fprintf(g.buf, "%s%s = %s.decodeFrom(buf, %s);\n", inner, target, slice, lenVar)
+		} else {
+			listVar := g.uniqueVar("list")
+			elemVar := g.uniqueVar("elem")
+			idxVar := g.uniqueVar("i")
+			elemType := g.javaRefType(typ.ElementType)
+			fmt.Fprintf(g.buf, "%sList<%s> %s = new ArrayList<>(%s);\n", inner, elemType, listVar, lenVar)
+			fmt.Fprintf(g.buf, "%sfor (int %s = 0; %s < %s; %s++) {\n", inner, idxVar, idxVar, lenVar, idxVar)
+			fmt.Fprintf(g.buf, "%s    %s %s = null;\n", inner, elemType, elemVar)
+			g.generateMapValueDecode(typ.ElementType, elemVar, inner+"    ")
+			fmt.Fprintf(g.buf, "%s    %s.add(%s);\n", inner, listVar, elemVar)
+			fmt.Fprintf(g.buf, "%s}\n", inner)
+			fmt.Fprintf(g.buf, "%s%s = %s;\n", inner, target, listVar)
+		}
+	case *schema.MapType:
+		lenVar := g.uniqueVar("len")
+		mapVar := g.uniqueVar("map")
+		keyVar := g.uniqueVar("key")
+		valVar := g.uniqueVar("val")
+		idxVar := g.uniqueVar("i")
+		keyType, valType := g.javaRefType(typ.KeyType), g.javaRefType(typ.ValueType)
+		fmt.Fprintf(g.buf, "%sint %s = %s;\n", inner, lenVar, javaGetLength(typ))
+		fmt.Fprintf(g.buf, "%sjava.util.Map<%s, %s> %s = new java.util.LinkedHashMap<>();\n", inner, keyType, valType, mapVar)
+		fmt.Fprintf(g.buf, "%sfor (int %s = 0; %s < %s; %s++) {\n", inner, idxVar, idxVar, lenVar, idxVar)
+		fmt.Fprintf(g.buf, "%s    %s %s = null;\n", inner, keyType, keyVar)
+		g.generateMapValueDecode(typ.KeyType, keyVar, inner+"    ")
+		fmt.Fprintf(g.buf, "%s    %s %s = null;\n", inner, valType, valVar)
+		g.generateMapValueDecode(typ.ValueType, valVar, inner+"    ")
+		fmt.Fprintf(g.buf, "%s    %s.put(%s, %s);\n", inner, mapVar, keyVar, valVar)
+		fmt.Fprintf(g.buf, "%s}\n", inner)
+		fmt.Fprintf(g.buf, "%s%s = %s;\n", inner, target, mapVar)
+	}
+	if t.IsOptional() {
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	}
+}
@@ -0,0 +1,965 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// kotlinKeywords lists the hard keywords of Kotlin, which must be quoted in
+// backticks to be used as identifiers.
+var kotlinKeywords = map[string]bool{
+	"as": true, "break": true, "class": true, "continue": true, "do": true,
+	"else": true, "false": true, "for": true, "fun": true, "if": true,
+	"in": true, "interface": true, "is": true, "null": true, "object": true,
+	"package": true, "return": true, "super": true, "this": true, "throw": true,
+	"true": true, "try": true, "typealias": true, "typeof": true, "val": true,
+	"var": true, "when": true, "while": true,
+}
+
+// escapeKotlinName quotes Kotlin keywords used as identifiers.
+func escapeKotlinName(name string) string {
+	if kotlinKeywords[name] {
+		return "`" + name + "`"
+	}
+	return name
+}
+
+// kotlinFieldName returns the property name of a schema field: the field
+// name with its leading capitals lowercased, so ID becomes id and
+// HTTPPort becomes httpPort.
+func kotlinFieldName(name string) string {
+	runes := []rune(name)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	if upper > 1 && upper < len(runes) {
+		upper-- // The last capital starts the next word
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return escapeKotlinName(string(runes))
+}
+
+// kotlinPackageName returns the Kotlin package of a namespace.
+func kotlinPackageName(namespace string) string {
+	parts := strings.Split(namespace, ".")
+	for i, part := range parts {
+		parts[i] = escapeKotlinName(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// GenerateKotlinPackage generates a Kotlin Multiplatform library with a
+// pure Kotlin codec, buildable for the JVM, Kotlin/Native and iOS with
+// Gradle. With config.KotlinOkio it also generates okio extensions.
+func GenerateKotlinPackage(config *PackageConfig) error {
+	kotlinDir := config.langDir("kotlin")
+	pkg := kotlinPackageName(config.Namespace)
+	srcDir := filepath.Join(append([]string{kotlinDir, "src", "commonMain", "kotlin"}, strings.Split(config.Namespace, ".")...)...)
+	if err := os.MkdirAll(srcDir, 0755); err != nil {
+		return fmt.Errorf("failed to create kotlin directory: %w", err)
+	}
+
+	kotlinCode, err := generateKotlinNative(config.Schema, pkg)
+	if err != nil {
+		return fmt.Errorf("failed to generate Kotlin code: %w", err)
+	}
+
+	framework := ToPascalCase(config.Schema.Package)
+	srcPath := filepath.Join(srcDir, framework+".kt")
+	if err := os.WriteFile(srcPath, kotlinCode, 0644); err != nil {
+		return fmt.Errorf("failed to write Kotlin source: %w", err)
+	}
+	config.infof("✓ Generated Kotlin source: %s\n", srcPath)
+
+	if config.KotlinOkio {
+		okioPath := filepath.Join(srcDir, framework+"Okio.kt")
+		if err := os.WriteFile(okioPath, generateKotlinOkio(config.Schema, pkg), 0644); err != nil {
+			return fmt.Errorf("failed to write Kotlin source: %w", err)
+		}
+		config.infof("✓ Generated Kotlin source: %s\n", okioPath)
+	}
+
+	artifact := valueOr(config.Coordinates.Maven.ArtifactID, config.Namespace)
+	groupID := valueOr(config.Coordinates.Maven.GroupID, defaultMavenGroupID)
+	files := []struct{ name, content string }{
+		{"settings.gradle.kts", fmt.Sprintf("rootProject.name = %q\n", artifact)},
+		{"build.gradle.kts", generateKotlinBuildGradle(groupID, config.Version, framework, config.KotlinOkio)},
+		{"README.md", generateKotlinReadme(config.Namespace, pkg, framework, config.KotlinOkio) + readmeLicenseSection(config)},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(kotlinDir, f.name), []byte(f.content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+		config.infof("✓ Generated %s\n", f.name)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Kotlin package ready at: %s\n\n", kotlinDir)
+	fmt.Fprintln(&b, "Build:")
+	fmt.Fprintf(&b, "  cd %s\n", kotlinDir)
+	fmt.Fprintln(&b, "  gradle build")
+	fmt.Fprintf(&b, "  gradle assemble%sXCFramework  # iOS, on macOS\n", framework)
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  import %s.*\n", pkg)
+	fmt.Fprintln(&b, "  val msg = MyMessage.decode(data)")
+	fmt.Fprintln(&b, "  val encoded = msg.encode()")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
+
+	return nil
+}
+
+// kotlinVersion is the Kotlin Gradle plugin version of generated builds,
+// which also brings in the matching standard library.
+const kotlinVersion = "2.0.21"
+
+// kotlinOkio is the okio dependency of packages generated with
+// --kotlin-okio. Okio is multiplatform, so it does not narrow the targets.
+var kotlinOkio = runtimeDependency{purlType: "maven", group: "com.squareup.okio", name: "okio", constraint: "3.9.1"}
+
+// generateKotlinBuildGradle returns the Gradle build of the library. The
+// code only uses the Kotlin standard library, and okio if asked for, so it
+// builds for the JVM, which Android apps consume, for the Kotlin/Native
+// target of the build host, and for iOS as an XCFramework named framework.
+// Gradle skips the iOS targets on hosts other than macOS.
+func generateKotlinBuildGradle(groupID, version, framework string, okio bool) string {
+	deps := ""
+	if okio {
+		deps = fmt.Sprintf(`
+
+    sourceSets {
+        commonMain.dependencies {
+            implementation("%s:%s:%s")
+        }
+    }`, kotlinOkio.group, kotlinOkio.name, kotlinOkio.constraint)
+	}
+	return fmt.Sprintf(`import org.jetbrains.kotlin.gradle.plugin.mpp.apple.XCFramework
+
+plugins {
+    kotlin("multiplatform") version "%s"
+}
+
+group = "%s"
+version = "%s"
+
+repositories {
+    mavenCentral()
+}
+
+kotlin {
+    jvm()
+
+    val hostOs = System.getProperty("os.name")
+    val isArm64 = System.getProperty("os.arch") == "aarch64"
+    when {
+        hostOs == "Mac OS X" && isArm64 -> macosArm64("native")
+        hostOs == "Mac OS X" -> macosX64("native")
+        hostOs == "Linux" && isArm64 -> linuxArm64("native")
+        hostOs == "Linux" -> linuxX64("native")
+        hostOs.startsWith("Windows") -> mingwX64("native")
+        else -> throw GradleException("Host OS $hostOs is not supported by Kotlin/Native")
+    }
+
+    val xcf = XCFramework("%s")
+    listOf(iosX64(), iosArm64(), iosSimulatorArm64()).forEach {
+        it.binaries.framework {
+            baseName = "%s"
+            xcf.add(this)
+        }
+    }%s
+}
+`, kotlinVersion, groupID, version, framework, framework, deps)
+}
+
+func generateKotlinReadme(namespace, pkg, framework string, okio bool) string {
+	deps := "only on the Kotlin standard library"
+	if okio {
+		deps = "on the Kotlin standard library and okio"
+	}
+	readme := fmt.Sprintf(`# %s - Kotlin FFire Bindings
+
+Kotlin Multiplatform implementation for ffire serialization, with no JNI
+or native library. It builds for the JVM, Kotlin/Native and iOS from one
+common source set, and depends %s.
+
+## Building
+
+`+"```bash"+`
+gradle build
+gradle assemble%sXCFramework  # on macOS
+`+"```"+`
+
+The XCFramework is written to `+"`build/XCFrameworks/`"+`; Xcode projects
+link it and `+"`import %s`"+`. Android apps depend on the JVM artifact
+published by `+"`gradle publishToMavenLocal`"+`, or include the sources in a
+shared Kotlin Multiplatform module.
+
+## Usage
+
+`+"```kotlin"+`
+import %s.*
+
+val msg = MyMessage.decode(bytes)  // throws FFireException on invalid data
+val encoded = msg.encode()
+`+"```"+`
+
+Messages are data classes with `+"`encode()`"+` and a companion `+"`decode()`"+`.
+Array messages are objects with `+"`encode(value)`"+` and `+"`decode(bytes)`"+`.
+`, namespace, deps, framework, framework, pkg)
+	if okio {
+		readme += `
+With okio, messages also encode to and decode from a ` + "`BufferedSink`" + `,
+` + "`BufferedSource`" + ` or ` + "`ByteString`" + `:
+
+` + "```kotlin" + `
+msg.encodeTo(sink)
+val copy = MyMessage.decode(source)  // reads the rest of source
+val bytes = msg.encodeToByteString()
+` + "```" + `
+`
+	}
+	return readme
+}
+
+// generateKotlinOkio generates okio extensions for the messages of s, which
+// generateKotlinNative has already declared in package pkg.
+func generateKotlinOkio(s *schema.Schema, pkg string) []byte {
+	g := &kotlinGenerator{buf: &bytes.Buffer{}, pkg: pkg}
+	g.buf.WriteString("// Code generated by ffire. DO NOT EDIT.\n\n")
+	fmt.Fprintf(g.buf, "package %s\n\n", pkg)
+	g.buf.WriteString("import okio.BufferedSink\n")
+	g.buf.WriteString("import okio.BufferedSource\n")
+	g.buf.WriteString("import okio.ByteString\n")
+	g.buf.WriteString("import okio.ByteString.Companion.toByteString\n")
+
+	for _, msg := range s.Messages {
+		name := msg.Name + "Message"
+		g.buf.WriteString("\n")
+		if _, ok := msg.TargetType.(*schema.StructType); ok {
+			g.buf.WriteString("/** Writes the encoded message to sink. */\n")
+			fmt.Fprintf(g.buf, "fun %s.encodeTo(sink: BufferedSink) {\n    sink.write(encode())\n}\n\n", name)
+			g.buf.WriteString("/** Encodes the message to the ffire wire format. */\n")
+			fmt.Fprintf(g.buf, "fun %s.encodeToByteString(): ByteString = encode().toByteString()\n\n", name)
+			g.buf.WriteString("/** Decodes a message from the ffire wire format, throwing FFireException if it is invalid. */\n")
+			fmt.Fprintf(g.buf, "fun %s.Companion.decode(data: ByteString): %s = decode(data.toByteArray())\n\n", name, name)
+			g.buf.WriteString("/** Decodes a message from the rest of source, throwing FFireException if it is invalid. */\n")
+			fmt.Fprintf(g.buf, "fun %s.Companion.decode(source: BufferedSource): %s = decode(source.readByteArray())\n", name, name)
+			continue
+		}
+		typ := g.kotlinType(msg.TargetType, false)
+		g.buf.WriteString("/** Writes the encoded message to sink. */\n")
+		fmt.Fprintf(g.buf, "fun %s.encodeTo(value: %s, sink: BufferedSink) {\n    sink.write(encode(value))\n}\n\n", name, typ)
+		g.buf.WriteString("/** Encodes the message to the ffire wire format. */\n")
+		fmt.Fprintf(g.buf, "fun %s.encodeToByteString(value: %s): ByteString = encode(value).toByteString()\n\n", name, typ)
+		g.buf.WriteString("/** Decodes a message from the ffire wire format, throwing FFireException if it is invalid. */\n")
+		fmt.Fprintf(g.buf, "fun %s.decode(data: ByteString): %s = decode(data.toByteArray())\n\n", name, typ)
+		g.buf.WriteString("/** Decodes a message from the rest of source, throwing FFireException if it is invalid. */\n")
+		fmt.Fprintf(g.buf, "fun %s.decode(source: BufferedSource): %s = decode(source.readByteArray())\n", name, typ)
+	}
+	return g.buf.Bytes()
+}
+
+// kotlinRuntime is the reader and writer of the wire format shared by all
+// generated types.
+const kotlinRuntime = `/** Thrown when decoding data that is not a valid encoding of the message. */
+class FFireException(message: String, val code: DecodeErrorCode) : Exception(message)
+
+/** Little-endian writer of the ffire wire format. */
+internal class FFireWriter {
+    private var buf = ByteArray(64)
+    private var pos = 0
+
+    private fun ensure(n: Int) {
+        if (pos + n > buf.size) buf = buf.copyOf(maxOf(buf.size * 2, pos + n))
+    }
+
+    fun bool(v: Boolean) = int8(if (v) 1 else 0)
+
+    fun int8(v: Byte) {
+        ensure(1)
+        buf[pos++] = v
+    }
+
+    fun int16(v: Short) {
+        ensure(2)
+        buf[pos] = v.toByte()
+        buf[pos + 1] = (v.toInt() shr 8).toByte()
+        pos += 2
+    }
+
+    fun int32(v: Int) {
+        ensure(4)
+        for (i in 0 until 4) buf[pos + i] = (v shr (8 * i)).toByte()
+        pos += 4
+    }
+
+    fun int64(v: Long) {
+        ensure(8)
+        for (i in 0 until 8) buf[pos + i] = (v shr (8 * i)).toByte()
+        pos += 8
+    }
+
+    fun float32(v: Float) = int32(v.toRawBits())
+
+    fun float64(v: Double) = int64(v.toRawBits())
+
+    /** Writes a length prefix: a uint16, or a uint32 for @large values. */
+    fun length(n: Int, large: Boolean = false) {
+        if (large) return int32(n)
+        require(n <= 65535) { "length $n exceeds the wire format limit of 65535; mark it @large" }
+        int16(n.toShort())
+    }
+
+    fun fixed(n: Int, want: Int) {
+        require(n == want) { "fixed-size array has $n elements, want $want" }
+    }
+
+    fun bytes(v: ByteArray, large: Boolean = false) {
+        length(v.size, large)
+        ensure(v.size)
+        v.copyInto(buf, pos)
+        pos += v.size
+    }
+
+    fun string(v: String, large: Boolean = false) = bytes(v.encodeToByteArray(), large)
+
+    inline fun <T : Any> optional(v: T?, write: (T) -> Unit) {
+        bool(v != null)
+        if (v != null) write(v)
+    }
+
+    /** Writes the presence bitmap of a @bitmap struct, one bit per optional field. */
+    fun bitmap(vararg present: Boolean) {
+        val bits = ByteArray((present.size + 7) / 8)
+        for (i in present.indices) {
+            if (present[i]) bits[i / 8] = (bits[i / 8].toInt() or (1 shl (i % 8))).toByte()
+        }
+        ensure(bits.size)
+        bits.copyInto(buf, pos)
+        pos += bits.size
+    }
+
+    inline fun <T> list(v: List<T>, large: Boolean = false, write: (T) -> Unit) {
+        length(v.size, large)
+        for (x in v) write(x)
+    }
+
+    inline fun <K : Comparable<K>, V> map(v: Map<K, V>, key: (K) -> Unit, large: Boolean = false, value: (V) -> Unit) {
+        length(v.size, large)
+        for (k in v.keys.sorted()) {
+            key(k)
+            value(v.getValue(k))
+        }
+    }
+
+    /** Writes a map with string keys, sorted by their UTF-8 bytes. */
+    inline fun <V> stringMap(v: Map<String, V>, large: Boolean = false, value: (V) -> Unit) {
+        length(v.size, large)
+        val keys = v.keys.map { it.encodeToByteArray() to it }.sortedWith(Comparator { a, b -> compareUtf8(a.first, b.first) })
+        for ((raw, k) in keys) {
+            bytes(raw)
+            value(v.getValue(k))
+        }
+    }
+
+    fun compareUtf8(a: ByteArray, b: ByteArray): Int {
+        for (i in 0 until minOf(a.size, b.size)) {
+            val d = (a[i].toInt() and 0xFF) - (b[i].toInt() and 0xFF)
+            if (d != 0) return d
+        }
+        return a.size - b.size
+    }
+
+    fun toByteArray(): ByteArray = buf.copyOf(pos)
+}
+
+/** Little-endian reader of the ffire wire format. */
+internal class FFireReader(private val buf: ByteArray) {
+    private var pos = 0
+
+    private fun need(n: Int) {
+        if (buf.size - pos < n) throw FFireException("unexpected end of data at offset $pos", DecodeErrorCode.TRUNCATED)
+    }
+
+    fun bool(): Boolean = when (val v = int8().toInt()) {
+        0 -> false
+        1 -> true
+        else -> throw FFireException("invalid bool $v at offset ${pos - 1}", DecodeErrorCode.INVALID_VALUE)
+    }
+
+    fun int8(): Byte {
+        need(1)
+        return buf[pos++]
+    }
+
+    fun int16(): Short {
+        need(2)
+        val v = (buf[pos].toInt() and 0xFF) or ((buf[pos + 1].toInt() and 0xFF) shl 8)
+        pos += 2
+        return v.toShort()
+    }
+
+    fun int32(): Int {
+        need(4)
+        var v = 0
+        for (i in 0 until 4) v = v or ((buf[pos + i].toInt() and 0xFF) shl (8 * i))
+        pos += 4
+        return v
+    }
+
+    fun int64(): Long {
+        need(8)
+        var v = 0L
+        for (i in 0 until 8) v = v or ((buf[pos + i].toLong() and 0xFF) shl (8 * i))
+        pos += 8
+        return v
+    }
+
+    fun float32(): Float = Float.fromBits(int32())
+
+    fun float64(): Double = Double.fromBits(int64())
+
+    /**
+     * Reads a length prefix: a uint16, or a uint32 for @large values. Every
+     * element or byte takes at least one byte, so a length past the end of
+     * the data is rejected before anything is allocated for it.
+     */
+    fun length(large: Boolean = false): Int {
+        val n = if (large) int32().toLong() and 0xFFFFFFFFL else (int16().toLong() and 0xFFFFL)
+        if (n > buf.size - pos) throw FFireException("length $n at offset $pos exceeds the remaining ${buf.size - pos} bytes", DecodeErrorCode.OVERFLOW)
+        return n.toInt()
+    }
+
+    fun bytes(large: Boolean = false): ByteArray {
+        val n = length(large)
+        val v = buf.copyOfRange(pos, pos + n)
+        pos += n
+        return v
+    }
+
+    fun string(large: Boolean = false): String {
+        val n = length(large)
+        val v = try {
+            buf.decodeToString(pos, pos + n, throwOnInvalidSequence = true)
+        } catch (e: CharacterCodingException) {
+            throw FFireException("invalid UTF-8 at offset $pos", DecodeErrorCode.INVALID_UTF8)
+        }
+        pos += n
+        return v
+    }
+
+    fun present(): Boolean = when (val v = int8().toInt()) {
+        0 -> false
+        1 -> true
+        else -> throw FFireException("invalid presence flag $v at offset ${pos - 1}", DecodeErrorCode.BAD_PRESENCE)
+    }
+
+    /** Returns value, the decoded message, if it took up all of the data. */
+    fun <T> end(value: T): T {
+        if (pos != buf.size) throw FFireException("${buf.size - pos} trailing bytes after message", DecodeErrorCode.TRAILING_DATA)
+        return value
+    }
+
+    inline fun <T> optional(read: () -> T): T? = if (present()) read() else null
+
+    /** Reads the presence bitmap of a @bitmap struct with n optional fields. */
+    fun bitmap(n: Int): ByteArray {
+        val size = (n + 7) / 8
+        need(size)
+        val bits = buf.copyOfRange(pos, pos + size)
+        if (n % 8 != 0 && (bits[size - 1].toInt() and (0xFF shl (n % 8)) and 0xFF) != 0) {
+            throw FFireException("unused presence bitmap bits set at offset ${pos + size - 1}", DecodeErrorCode.BAD_PRESENCE)
+        }
+        pos += size
+        return bits
+    }
+
+    inline fun <T> list(large: Boolean = false, read: () -> T): List<T> = List(length(large)) { read() }
+
+    /** Reads a map; the last of duplicate keys wins. */
+    inline fun <K, V> map(key: () -> K, value: () -> V, large: Boolean = false): Map<K, V> {
+        val n = length(large)
+        val m = LinkedHashMap<K, V>(n)
+        repeat(n) {
+            val k = key()
+            m[k] = value()
+        }
+        return m
+    }
+}
+
+`
+
+// kotlinPrimitiveTypes maps schema primitives to Kotlin types.
+var kotlinPrimitiveTypes = map[string]string{
+	"bool": "Boolean", "int8": "Byte", "int16": "Short", "int32": "Int",
+	"int64": "Long", "float32": "Float", "float64": "Double",
+	"string": "String", "bytes": "ByteArray",
+}
+
+// kotlinPrimitiveArrays maps schema primitives to the Kotlin array types
+// fixed-size arrays of them use.
+var kotlinPrimitiveArrays = map[string]string{
+	"bool": "BooleanArray", "int8": "ByteArray", "int16": "ShortArray",
+	"int32": "IntArray", "int64": "LongArray", "float32": "FloatArray",
+	"float64": "DoubleArray",
+}
+
+// kotlinGenerator generates the Kotlin source of a schema.
+type kotlinGenerator struct {
+	buf *bytes.Buffer
+	pkg string // Kotlin package, for qualified names in unions
+}
+
+// generateKotlinNative generates pure Kotlin code in package pkg.
+func generateKotlinNative(s *schema.Schema, pkg string) ([]byte, error) {
+	s.Canonicalize()
+
+	g := &kotlinGenerator{buf: &bytes.Buffer{}, pkg: pkg}
+	g.buf.WriteString("// Code generated by ffire. DO NOT EDIT.\n\n")
+	fmt.Fprintf(g.buf, "package %s\n\n", pkg)
+	g.buf.WriteString(kotlinDecodeErrorCode())
+	g.buf.WriteString(kotlinRuntime)
+
+	for _, enum := range s.Enums() {
+		g.generateEnum(enum)
+	}
+	for _, union := range s.Unions() {
+		g.generateUnion(union)
+	}
+
+	rootMessageTypes := make(map[string]bool)
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok {
+			rootMessageTypes[st.Name] = true
+		}
+	}
+	for _, st := range topSortStructTypes(s.Types) {
+		if !rootMessageTypes[st.Name] {
+			g.generateStruct(st.Name, st, false)
+		}
+	}
+
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok {
+			g.generateStruct(msg.Name+"Message", st, true)
+		} else {
+			g.buf.WriteString(blockDoc("", msg.Doc))
+			g.generateValueMessage(msg.Name+"Message", msg.TargetType)
+		}
+	}
+
+	return bytes.TrimSuffix(g.buf.Bytes(), []byte("\n")), nil
+}
+
+// kotlinType returns the Kotlin type of t. Qualified names are used inside
+// unions, whose variant classes shadow the types they hold.
+func (g *kotlinGenerator) kotlinType(t schema.Type, qualified bool) string {
+	var name string
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		name = kotlinPrimitiveTypes[typ.Name]
+		if qualified {
+			name = "kotlin." + name
+		}
+	case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		name = t.TypeName()
+		if qualified {
+			name = g.pkg + "." + name
+		}
+	case *schema.ArrayType:
+		if arr := kotlinPrimitiveArray(typ); arr != "" {
+			name = arr
+		} else {
+			name = "List<" + g.kotlinType(typ.ElementType, qualified) + ">"
+		}
+	case *schema.MapType:
+		name = "Map<" + g.kotlinType(typ.KeyType, qualified) + ", " + g.kotlinType(typ.ValueType, qualified) + ">"
+	}
+	if t.IsOptional() {
+		name += "?"
+	}
+	return name
+}
+
+// kotlinPrimitiveArray returns the primitive array type of a fixed-size
+// array, which holds non-optional numbers or bools, or "" for a slice.
+func kotlinPrimitiveArray(arr *schema.ArrayType) string {
+	if arr.Length == 0 {
+		return ""
+	}
+	if prim, ok := arr.ElementType.(*schema.PrimitiveType); ok && !prim.Optional {
+		return kotlinPrimitiveArrays[prim.Name]
+	}
+	return ""
+}
+
+// hasContentEquality reports whether values of t are Kotlin arrays, which
+// data classes would compare by identity.
+func hasContentEquality(t schema.Type) bool {
+	if prim, ok := t.(*schema.PrimitiveType); ok {
+		return prim.Name == "bytes"
+	}
+	if arr, ok := t.(*schema.ArrayType); ok {
+		return kotlinPrimitiveArray(arr) != ""
+	}
+	return false
+}
+
+// encode returns a statement writing v of type t to w. depth numbers the
+// lambda parameters of nested containers.
+func (g *kotlinGenerator) encode(t schema.Type, v string, depth int) string {
+	x := fmt.Sprintf("x%d", depth)
+	if t.IsOptional() {
+		return fmt.Sprintf("w.optional(%s) { %s -> %s }", v, x, g.encodeValue(t, x, depth+1))
+	}
+	return g.encodeValue(t, v, depth)
+}
+
+func (g *kotlinGenerator) encodeValue(t schema.Type, v string, depth int) string {
+	x := fmt.Sprintf("x%d", depth)
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		if typ.Large {
+			return fmt.Sprintf("w.%s(%s, large = true)", typ.Name, v)
+		}
+		return fmt.Sprintf("w.%s(%s)", typ.Name, v)
+	case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		return v + ".writeTo(w)"
+	case *schema.ArrayType:
+		if typ.Length > 0 {
+			return fmt.Sprintf("%s.also { w.fixed(it.size, %d) }.forEach { %s -> %s }", v, typ.Length, x, g.encode(typ.ElementType, x, depth+1))
+		}
+		return fmt.Sprintf("w.list(%s%s) { %s -> %s }", v, kotlinLargeArg(typ), x, g.encode(typ.ElementType, x, depth+1))
+	case *schema.MapType:
+		key := typ.KeyType.(*schema.PrimitiveType)
+		value := fmt.Sprintf("{ %s -> %s }", x, g.encode(typ.ValueType, x, depth+1))
+		if key.Name == "string" {
+			return fmt.Sprintf("w.stringMap(%s%s) %s", v, kotlinLargeArg(typ), value)
+		}
+		k := fmt.Sprintf("k%d", depth)
+		return fmt.Sprintf("w.map(%s, { %s -> w.%s(%s) }%s) %s", v, k, key.Name, k, kotlinLargeArg(typ), value)
+	}
+	return ""
+}
+
+// decode returns an expression reading a value of type t from r.
+func (g *kotlinGenerator) decode(t schema.Type) string {
+	if t.IsOptional() {
+		return fmt.Sprintf("r.optional { %s }", g.decodeValue(t))
+	}
+	return g.decodeValue(t)
+}
+
+func (g *kotlinGenerator) decodeValue(t schema.Type) string {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		if typ.Large {
+			return fmt.Sprintf("r.%s(large = true)", typ.Name)
+		}
+		return fmt.Sprintf("r.%s()", typ.Name)
+	case *schema.StructType, *schema.EnumType, *schema.UnionType:
+		return t.TypeName() + ".readFrom(r)"
+	case *schema.ArrayType:
+		if arr := kotlinPrimitiveArray(typ); arr != "" {
+			return fmt.Sprintf("%s(%d) { %s }", arr, typ.Length, g.decode(typ.ElementType))
+		}
+		if typ.Large {
+			return fmt.Sprintf("r.list(large = true) { %s }", g.decode(typ.ElementType))
+		}
+		return fmt.Sprintf("r.list { %s }", g.decode(typ.ElementType))
+	case *schema.MapType:
+		return fmt.Sprintf("r.map({ %s }, { %s }%s)", g.decode(typ.KeyType), g.decode(typ.ValueType), kotlinLargeArg(typ))
+	}
+	return ""
+}
+
+// kotlinLargeArg returns the argument that makes a runtime call use the
+// uint32 length prefix of a @large array or map, or "" for other types.
+func kotlinLargeArg(t schema.Type) string {
+	if schema.IsLarge(t) {
+		return ", large = true"
+	}
+	return ""
+}
+
+// kotlinIntLiteral returns v as a literal of the Kotlin type of an enum
+// base, spelling out minimum values whose negation would not fit.
+func kotlinIntLiteral(v int64, base string) string {
+	mins := map[string]int64{"int8": math.MinInt8, "int16": math.MinInt16, "int32": math.MinInt32, "int64": math.MinInt64}
+	if v == mins[base] {
+		return kotlinPrimitiveTypes[base] + ".MIN_VALUE"
+	}
+	if base == "int64" {
+		return fmt.Sprintf("%dL", v)
+	}
+	return fmt.Sprint(v)
+}
+
+// generateEnum declares an enum class holding the wire value of each
+// constant, with fromValue to map wire values back to constants.
+func (g *kotlinGenerator) generateEnum(enum *schema.EnumType) {
+	base := kotlinPrimitiveTypes[enum.Base]
+	g.buf.WriteString(blockDoc("", enum.Doc))
+	fmt.Fprintf(g.buf, "enum class %s(val value: %s) {\n", enum.Name, base)
+	for i, v := range enum.Values {
+		sep := ","
+		if i == len(enum.Values)-1 {
+			sep = ";"
+		}
+		g.buf.WriteString(blockDoc("    ", v.Doc))
+		fmt.Fprintf(g.buf, "    %s(%s)%s\n", escapeKotlinName(v.Name), kotlinIntLiteral(v.Value, enum.Base), sep)
+	}
+	g.buf.WriteString("\n")
+	fmt.Fprintf(g.buf, "    internal fun writeTo(w: FFireWriter) = w.%s(value)\n\n", enum.Base)
+	g.buf.WriteString("    companion object {\n")
+	g.buf.WriteString("        /** Returns the constant with wire value [value], or null if there is none. */\n")
+	fmt.Fprintf(g.buf, "        fun fromValue(value: %s): %s? = when (value.toLong()) {\n", base, enum.Name)
+	for _, v := range enum.Values {
+		fmt.Fprintf(g.buf, "            %s -> %s\n", kotlinIntLiteral(v.Value, "int64"), escapeKotlinName(v.Name))
+	}
+	g.buf.WriteString("            else -> null\n")
+	g.buf.WriteString("        }\n\n")
+	fmt.Fprintf(g.buf, "        internal fun readFrom(r: FFireReader): %s {\n", enum.Name)
+	fmt.Fprintf(g.buf, "            val v = r.%s()\n", enum.Base)
+	fmt.Fprintf(g.buf, "            return fromValue(v) ?: throw FFireException(\"invalid %s value $v\", DecodeErrorCode.INVALID_VALUE)\n", enum.Name)
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// generateUnion declares a sealed class with one data class per variant,
+// in tag order.
+func (g *kotlinGenerator) generateUnion(union *schema.UnionType) {
+	g.buf.WriteString(blockDoc("", union.Doc))
+	fmt.Fprintf(g.buf, "sealed class %s {\n", union.Name)
+	for _, v := range union.Variants {
+		name := ToPascalCase(v.TypeName())
+		g.generateDataClass("    ", name, []kotlinProperty{{name: "value", typ: v, qualified: true}}, union.Name+"()", nil)
+	}
+	g.buf.WriteString("\n")
+
+	g.buf.WriteString("    internal fun writeTo(w: FFireWriter) {\n")
+	g.buf.WriteString("        when (this) {\n")
+	for i, v := range union.Variants {
+		fmt.Fprintf(g.buf, "            is %s -> {\n", ToPascalCase(v.TypeName()))
+		fmt.Fprintf(g.buf, "                w.int8(%d)\n", i+1)
+		fmt.Fprintf(g.buf, "                %s\n", g.encode(v, "value", 1))
+		g.buf.WriteString("            }\n")
+	}
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n\n")
+
+	g.buf.WriteString("    companion object {\n")
+	fmt.Fprintf(g.buf, "        internal fun readFrom(r: FFireReader): %s = when (val tag = r.int8().toInt() and 0xFF) {\n", union.Name)
+	for i, v := range union.Variants {
+		decode := g.decode(v)
+		if _, ok := v.(*schema.PrimitiveType); !ok {
+			decode = g.pkg + "." + decode
+		}
+		fmt.Fprintf(g.buf, "            %d -> %s(%s)\n", i+1, ToPascalCase(v.TypeName()), decode)
+	}
+	fmt.Fprintf(g.buf, "            else -> throw FFireException(\"invalid %s tag $tag\", DecodeErrorCode.INVALID_VALUE)\n", union.Name)
+	g.buf.WriteString("        }\n")
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
+
+// kotlinProperty is a constructor property of a generated data class.
+type kotlinProperty struct {
+	name      string
+	typ       schema.Type
+	qualified bool   // Use qualified type names (see kotlinType)
+	doc       string // KDoc for the property
+}
+
+// generateDataClass declares a data class with the given properties,
+// calling body to write its members. Properties holding Kotlin arrays
+// override equals, hashCode and toString to compare and print contents.
+func (g *kotlinGenerator) generateDataClass(indent, name string, props []kotlinProperty, super string, body func(indent string)) {
+	params := make([]string, len(props))
+	documented := false
+	for i, p := range props {
+		documented = documented || p.doc != ""
+		def := ""
+		if p.typ.IsOptional() {
+			def = " = null"
+		} else if arr, ok := p.typ.(*schema.ArrayType); ok && kotlinPrimitiveArray(arr) != "" {
+			def = fmt.Sprintf(" = %s(%d)", kotlinPrimitiveArray(arr), arr.Length)
+		}
+		params[i] = fmt.Sprintf("val %s: %s%s", p.name, g.kotlinType(p.typ, p.qualified), def)
+	}
+	if len(params) == 1 && !documented {
+		fmt.Fprintf(g.buf, "%sdata class %s(%s)", indent, name, params[0])
+	} else {
+		fmt.Fprintf(g.buf, "%sdata class %s(\n", indent, name)
+		for i, param := range params {
+			g.buf.WriteString(blockDoc(indent+"    ", props[i].doc))
+			fmt.Fprintf(g.buf, "%s    %s,\n", indent, param)
+		}
+		g.buf.WriteString(indent + ")")
+	}
+	if super != "" {
+		g.buf.WriteString(" : " + super)
+	}
+
+	content := false
+	for _, p := range props {
+		content = content || hasContentEquality(p.typ)
+	}
+	if !content && body == nil {
+		g.buf.WriteString("\n")
+		return
+	}
+	g.buf.WriteString(" {\n")
+	if content {
+		g.generateContentEquality(indent+"    ", name, props)
+		if body != nil {
+			g.buf.WriteString("\n")
+		}
+	}
+	if body != nil {
+		body(indent + "    ")
+	}
+	g.buf.WriteString(indent + "}\n")
+}
+
+// generateContentEquality overrides equals, hashCode and toString to use
+// the contents of array properties.
+func (g *kotlinGenerator) generateContentEquality(indent, name string, props []kotlinProperty) {
+	str, integer := "String", "Int"
+	if len(props) > 0 && props[0].qualified {
+		str, integer = "kotlin.String", "kotlin.Int" // A variant may be named String
+	}
+	equals := []string{"other is " + name}
+	hash := []string{}
+	fields := []string{}
+	for _, p := range props {
+		if hasContentEquality(p.typ) {
+			equals = append(equals, fmt.Sprintf("this.%s.contentEquals(other.%s)", p.name, p.name))
+			hash = append(hash, p.name+".contentHashCode()")
+			fields = append(fields, fmt.Sprintf("%s=${%s.contentToString()}", strings.Trim(p.name, "`"), p.name))
+		} else {
+			equals = append(equals, fmt.Sprintf("this.%s == other.%s", p.name, p.name))
+			hash = append(hash, p.name+".hashCode()")
+			fields = append(fields, fmt.Sprintf("%s=${%s}", strings.Trim(p.name, "`"), p.name))
+		}
+	}
+
+	fmt.Fprintf(g.buf, "%soverride fun equals(other: Any?): Boolean =\n", indent)
+	fmt.Fprintf(g.buf, "%s    %s\n\n", indent, strings.Join(equals, " && "))
+	if len(hash) == 1 {
+		fmt.Fprintf(g.buf, "%soverride fun hashCode(): %s = %s\n\n", indent, integer, hash[0])
+	} else {
+		fmt.Fprintf(g.buf, "%soverride fun hashCode(): %s {\n", indent, integer)
+		fmt.Fprintf(g.buf, "%s    var h = %s\n", indent, hash[0])
+		for _, h := range hash[1:] {
+			fmt.Fprintf(g.buf, "%s    h = 31 * h + %s\n", indent, h)
+		}
+		fmt.Fprintf(g.buf, "%s    return h\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n\n", indent)
+	}
+	fmt.Fprintf(g.buf, "%soverride fun toString(): %s = \"%s(%s)\"\n", indent, str, name, strings.Join(fields, ", "))
+}
+
+// generateStruct declares a struct as a data class. Messages also get a
+// public encode() and a companion decode().
+func (g *kotlinGenerator) generateStruct(name string, st *schema.StructType, isMessage bool) {
+	props := make([]kotlinProperty, len(st.Fields))
+	for i, field := range st.Fields {
+		props[i] = kotlinProperty{name: kotlinFieldName(field.Name), typ: field.Type, doc: field.Doc}
+	}
+
+	g.buf.WriteString(blockDoc("", st.Doc))
+	g.generateDataClass("", name, props, "", func(indent string) {
+		if isMessage {
+			fmt.Fprintf(g.buf, "%s/** Encodes the message to the ffire wire format. */\n", indent)
+			fmt.Fprintf(g.buf, "%sfun encode(): ByteArray = FFireWriter().also { writeTo(it) }.toByteArray()\n\n", indent)
+		}
+		fmt.Fprintf(g.buf, "%sinternal fun writeTo(w: FFireWriter) {\n", indent)
+		var present []string
+		for _, p := range props {
+			if p.typ.IsOptional() {
+				present = append(present, "this."+p.name+" != null")
+			}
+		}
+		if st.Bitmap && len(present) > 0 {
+			fmt.Fprintf(g.buf, "%s    w.bitmap(%s)\n", indent, strings.Join(present, ", "))
+		}
+		for _, p := range props {
+			if st.Bitmap && p.typ.IsOptional() {
+				// The presence is in the bitmap
+				fmt.Fprintf(g.buf, "%s    this.%s?.let { x1 -> %s }\n", indent, p.name, g.encodeValue(p.typ, "x1", 2))
+				continue
+			}
+			fmt.Fprintf(g.buf, "%s    %s\n", indent, g.encode(p.typ, "this."+p.name, 1))
+		}
+		fmt.Fprintf(g.buf, "%s}\n\n", indent)
+
+		fmt.Fprintf(g.buf, "%scompanion object {\n", indent)
+		if isMessage {
+			fmt.Fprintf(g.buf, "%s    /** Decodes a message from the ffire wire format, throwing FFireException if it is invalid. */\n", indent)
+			fmt.Fprintf(g.buf, "%s    fun decode(data: ByteArray): %s {\n", indent, name)
+			fmt.Fprintf(g.buf, "%s        val r = FFireReader(data)\n", indent)
+			fmt.Fprintf(g.buf, "%s        return r.end(readFrom(r))\n", indent)
+			fmt.Fprintf(g.buf, "%s    }\n\n", indent)
+		}
+		if !st.Bitmap || len(present) == 0 {
+			fmt.Fprintf(g.buf, "%s    internal fun readFrom(r: FFireReader): %s = %s(\n", indent, name, name)
+			for _, p := range props {
+				fmt.Fprintf(g.buf, "%s        %s = %s,\n", indent, p.name, g.decode(p.typ))
+			}
+			fmt.Fprintf(g.buf, "%s    )\n", indent)
+			fmt.Fprintf(g.buf, "%s}\n", indent)
+			return
+		}
+
+		// The presence bitmap comes first and replaces the presence bytes
+		fmt.Fprintf(g.buf, "%s    internal fun readFrom(r: FFireReader): %s {\n", indent, name)
+		fmt.Fprintf(g.buf, "%s        val bits = r.bitmap(%d)\n", indent, len(present))
+		fmt.Fprintf(g.buf, "%s        return %s(\n", indent, name)
+		bit := 0
+		for _, p := range props {
+			decode := g.decode(p.typ)
+			if p.typ.IsOptional() {
+				decode = fmt.Sprintf("if ((bits[%d].toInt() and 0x%02x) != 0) %s else null", bit/8, 1<<(bit%8), g.decodeValue(p.typ))
+				bit++
+			}
+			fmt.Fprintf(g.buf, "%s            %s = %s,\n", indent, p.name, decode)
+		}
+		fmt.Fprintf(g.buf, "%s        )\n", indent)
+		fmt.Fprintf(g.buf, "%s    }\n", indent)
+		fmt.Fprintf(g.buf, "%s}\n", indent)
+	})
+	g.buf.WriteString("\n")
+}
+
+// generateValueMessage declares an object with encode and decode for a
+// message that is not a struct, such as an array of structs.
+func (g *kotlinGenerator) generateValueMessage(name string, t schema.Type) {
+	typ := g.kotlinType(t, false)
+	fmt.Fprintf(g.buf, "object %s {\n", name)
+	g.buf.WriteString("    /** Encodes the message to the ffire wire format. */\n")
+	fmt.Fprintf(g.buf, "    fun encode(value: %s): ByteArray {\n", typ)
+	g.buf.WriteString("        val w = FFireWriter()\n")
+	fmt.Fprintf(g.buf, "        %s\n", g.encode(t, "value", 1))
+	g.buf.WriteString("        return w.toByteArray()\n")
+	g.buf.WriteString("    }\n\n")
+	g.buf.WriteString("    /** Decodes a message from the ffire wire format, throwing FFireException if it is invalid. */\n")
+	fmt.Fprintf(g.buf, "    fun decode(data: ByteArray): %s {\n", typ)
+	g.buf.WriteString("        val r = FFireReader(data)\n")
+	fmt.Fprintf(g.buf, "        return r.end(%s)\n", g.decode(t))
+	g.buf.WriteString("    }\n")
+	g.buf.WriteString("}\n\n")
+}
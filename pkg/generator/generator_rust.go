@@ -46,7 +46,7 @@ func GenerateRustPackage(config *PackageConfig) error {
 	config.Namespace = SanitizeRustModuleName(config.Namespace)
 
 	// Create rust directory
-	rustDir := filepath.Join(config.OutputDir, "rust")
+	rustDir := config.langDir("rust")
 	srcDir := filepath.Join(rustDir, "src")
 	if err := os.MkdirAll(srcDir, 0755); err != nil {
 		return fmt.Errorf("failed to create rust directory: %w", err)
@@ -63,51 +63,65 @@ func GenerateRustPackage(config *PackageConfig) error {
 	if err := os.WriteFile(libPath, rustCode, 0644); err != nil {
 		return fmt.Errorf("failed to write Rust source: %w", err)
 	}
-	fmt.Printf("✓ Generated Rust source: %s\n", libPath)
+	config.infof("✓ Generated Rust source: %s\n", libPath)
 
 	// Generate Cargo.toml
-	cargoToml := generateCargoToml(config.Namespace)
+	cargoToml := generateCargoToml(valueOr(config.Coordinates.Crate, config.Namespace), config.Namespace, config.Version, config.License)
 	cargoPath := filepath.Join(rustDir, "Cargo.toml")
 	if err := os.WriteFile(cargoPath, []byte(cargoToml), 0644); err != nil {
 		return fmt.Errorf("failed to write Cargo.toml: %w", err)
 	}
-	fmt.Printf("✓ Generated Cargo.toml\n")
+	config.infof("✓ Generated Cargo.toml\n")
 
 	// Generate README
-	readme := generateRustReadme(config.Namespace)
+	readme := generateRustReadme(config.Namespace) + readmeLicenseSection(config)
 	readmePath := filepath.Join(rustDir, "README.md")
 	if err := os.WriteFile(readmePath, []byte(readme), 0644); err != nil {
 		return fmt.Errorf("failed to write README.md: %w", err)
 	}
-	fmt.Printf("✓ Generated README.md\n")
-
-	fmt.Printf("\n✅ Rust package ready at: %s\n\n", rustDir)
-	fmt.Println("Build:")
-	fmt.Printf("  cd %s\n", rustDir)
-	fmt.Println("  cargo build --release")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Printf("  use %s::*;\n", config.Namespace)
-	fmt.Println("  let msg = MyMessage::decode(&data)?;")
-	fmt.Println("  let encoded = msg.encode();")
-	fmt.Println()
+	config.infof("✓ Generated README.md\n")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Rust package ready at: %s\n\n", rustDir)
+	fmt.Fprintln(&b, "Build:")
+	fmt.Fprintf(&b, "  cd %s\n", rustDir)
+	fmt.Fprintln(&b, "  cargo build --release")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  use %s::*;\n", config.Namespace)
+	fmt.Fprintln(&b, "  let msg = MyMessage::decode(&data)?;")
+	fmt.Fprintln(&b, "  let encoded = msg.encode();")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
 
 	return nil
 }
 
-func generateCargoToml(namespace string) string {
+// generateCargoToml returns the manifest of the crate. The library keeps
+// the namespace as its name so `use namespace::*` works whatever the crate
+// is published as. A license other than LicenseProprietary is an SPDX
+// expression crates.io accepts as is; a proprietary one points at the file.
+func generateCargoToml(crate, namespace, version, license string) string {
+	var licenseField string
+	switch license {
+	case "":
+	case LicenseProprietary:
+		licenseField = fmt.Sprintf("license-file = %q\n", LicenseFile)
+	default:
+		licenseField = fmt.Sprintf("license = %q\n", license)
+	}
 	return fmt.Sprintf(`[package]
 name = "%s"
-version = "0.1.0"
+version = "%s"
 edition = "2021"
 description = "Generated ffire serialization code"
-
+%s
 [lib]
 name = "%s"
 path = "src/lib.rs"
 
 [dependencies]
-`, namespace, namespace)
+`, crate, version, licenseField, namespace)
 }
 
 func generateRustReadme(namespace string) string {
@@ -164,6 +178,9 @@ func generateRustNative(s *schema.Schema) ([]byte, error) {
 	buf.WriteString("    BufferTooShort,\n")
 	buf.WriteString("    InvalidUtf8,\n")
 	buf.WriteString("    InvalidData,\n")
+	buf.WriteString("    Overflow,\n")
+	buf.WriteString("    BadPresence,\n")
+	buf.WriteString("    TrailingData,\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("impl std::fmt::Display for FFireError {\n")
@@ -172,11 +189,23 @@ func generateRustNative(s *schema.Schema) ([]byte, error) {
 	buf.WriteString("            FFireError::BufferTooShort => write!(f, \"Buffer too short\"),\n")
 	buf.WriteString("            FFireError::InvalidUtf8 => write!(f, \"Invalid UTF-8\"),\n")
 	buf.WriteString("            FFireError::InvalidData => write!(f, \"Invalid data\"),\n")
+	buf.WriteString("            FFireError::Overflow => write!(f, \"Length exceeds data\"),\n")
+	buf.WriteString("            FFireError::BadPresence => write!(f, \"Invalid presence flag\"),\n")
+	buf.WriteString("            FFireError::TrailingData => write!(f, \"Trailing data\"),\n")
 	buf.WriteString("        }\n")
 	buf.WriteString("    }\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("impl std::error::Error for FFireError {}\n\n")
+	buf.WriteString(rustDecodeErrorCode())
+	buf.WriteString(rustReadHelpers)
+
+	for _, enum := range s.Enums() {
+		generateRustEnum(&buf, enum)
+	}
+	for _, union := range s.Unions() {
+		generateRustUnion(&buf, union)
+	}
 
 	// Generate struct definitions
 	// First, generate helper structs (non-root types)
@@ -187,11 +216,9 @@ func generateRustNative(s *schema.Schema) ([]byte, error) {
 		}
 	}
 
-	for _, typ := range s.Types {
-		if structType, ok := typ.(*schema.StructType); ok {
-			if !rootMessageTypes[structType.Name] {
-				generateRustStruct(&buf, structType, false)
-			}
+	for _, structType := range topSortStructTypes(s.Types) {
+		if !rootMessageTypes[structType.Name] {
+			generateRustStruct(&buf, structType, false)
 		}
 	}
 
@@ -201,7 +228,7 @@ func generateRustNative(s *schema.Schema) ([]byte, error) {
 			generateRustStruct(&buf, structType, true)
 			generateRustMessageImpl(&buf, msg.Name, structType)
 		} else if arrayType, ok := msg.TargetType.(*schema.ArrayType); ok {
-			generateRustArrayMessage(&buf, msg.Name, arrayType)
+			generateRustArrayMessage(&buf, msg.Name, msg.Doc, arrayType)
 		}
 	}
 
@@ -217,16 +244,107 @@ func generateRustNative(s *schema.Schema) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// generateRustEnum declares a fieldless enum with the wire values as
+// discriminants, and from_value to map wire values back to variants.
+func generateRustEnum(buf *bytes.Buffer, enum *schema.EnumType) {
+	base := getRustTypeString(&schema.PrimitiveType{Name: enum.Base})
+	buf.WriteString(lineDoc("", "///", enum.Doc))
+	buf.WriteString("#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord)]\n")
+	buf.WriteString(fmt.Sprintf("#[repr(%s)]\n", base))
+	buf.WriteString(fmt.Sprintf("pub enum %s {\n", enum.Name))
+	for _, v := range enum.Values {
+		buf.WriteString(lineDoc("    ", "///", v.Doc))
+		buf.WriteString(fmt.Sprintf("    %s = %d,\n", v.Name, v.Value))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("impl %s {\n", enum.Name))
+	buf.WriteString("    /// Returns the variant with wire value v, or None if there is none\n")
+	buf.WriteString(fmt.Sprintf("    pub fn from_value(v: %s) -> Option<Self> {\n", base))
+	buf.WriteString("        match v {\n")
+	for _, v := range enum.Values {
+		buf.WriteString(fmt.Sprintf("            %d => Some(%s::%s),\n", v.Value, enum.Name, v.Name))
+	}
+	buf.WriteString("            _ => None,\n")
+	buf.WriteString("        }\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+}
+
+// generateRustDecodeEnum decodes the base integer into varName_raw and
+// rejects values that are not variants.
+func generateRustDecodeEnum(buf *bytes.Buffer, t *schema.EnumType, varName string, indent string, withPos bool) {
+	raw := varName + "_raw"
+	if withPos {
+		generateRustDecodePrimitiveWithPos(buf, t.Base, raw, indent)
+	} else {
+		generateRustDecodePrimitive(buf, t.Base, raw, indent)
+	}
+	buf.WriteString(fmt.Sprintf("%slet %s = %s::from_value(%s).ok_or(FFireError::InvalidData)?;\n", indent, varName, t.Name, raw))
+}
+
+// generateRustUnion declares an enum with one tuple variant per union
+// variant, in tag order, with encode_to and decode_from like helper structs.
+func generateRustUnion(buf *bytes.Buffer, union *schema.UnionType) {
+	buf.WriteString(lineDoc("", "///", union.Doc))
+	buf.WriteString("#[derive(Debug, Clone, PartialEq)]\n")
+	buf.WriteString(fmt.Sprintf("pub enum %s {\n", union.Name))
+	for _, v := range union.Variants {
+		buf.WriteString(fmt.Sprintf("    %s(%s),\n", ToPascalCase(v.TypeName()), getRustTypeString(v)))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString(fmt.Sprintf("impl %s {\n", union.Name))
+	buf.WriteString("    fn encode_to(&self, buf: &mut Vec<u8>) {\n")
+	buf.WriteString("        match self {\n")
+	for i, v := range union.Variants {
+		buf.WriteString(fmt.Sprintf("            %s::%s(v) => {\n", union.Name, ToPascalCase(v.TypeName())))
+		buf.WriteString(fmt.Sprintf("                buf.push(%d);\n", i+1))
+		switch t := v.(type) {
+		case *schema.PrimitiveType:
+			generateRustEncodePrimitive(buf, t.Name, "v", "                ", true)
+		case *schema.EnumType:
+			generateRustEncodeField(buf, t, "*v", "                ", true)
+		default:
+			generateRustEncodeField(buf, t, "v", "                ", true)
+		}
+		buf.WriteString("            }\n")
+	}
+	buf.WriteString("        }\n")
+	buf.WriteString("    }\n\n")
+
+	buf.WriteString("    fn decode_from(bytes: &[u8], pos: &mut usize) -> Result<Self, FFireError> {\n")
+	buf.WriteString("        let tag = *bytes.get(*pos).ok_or(FFireError::BufferTooShort)?;\n")
+	buf.WriteString("        *pos += 1;\n")
+	buf.WriteString("        match tag {\n")
+	for i, v := range union.Variants {
+		buf.WriteString(fmt.Sprintf("            %d => {\n", i+1))
+		if p, ok := v.(*schema.PrimitiveType); ok {
+			generateRustDecodePrimitiveWithPos(buf, p.Name, "v", "                ")
+		} else {
+			generateRustDecodeFieldWithPos(buf, v, "v", "                ")
+		}
+		buf.WriteString(fmt.Sprintf("                Ok(%s::%s(v))\n", union.Name, ToPascalCase(v.TypeName())))
+		buf.WriteString("            }\n")
+	}
+	buf.WriteString("            _ => Err(FFireError::InvalidData),\n")
+	buf.WriteString("        }\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+}
+
 func generateRustStruct(buf *bytes.Buffer, structType *schema.StructType, isMessage bool) {
 	structName := structType.Name
 	if isMessage {
 		structName = structType.Name + "Message"
 	}
 
+	buf.WriteString(lineDoc("", "///", structType.Doc))
 	buf.WriteString("#[derive(Debug, Clone, PartialEq)]\n")
 	buf.WriteString(fmt.Sprintf("pub struct %s {\n", structName))
 
 	for _, field := range structType.Fields {
+		buf.WriteString(lineDoc("    ", "///", field.Doc))
 		rustType := getRustTypeString(field.Type)
 		fieldName := escapeRustFieldName(toSnakeCase(field.Name))
 		buf.WriteString(fmt.Sprintf("    pub %s: %s,\n", fieldName, rustType))
@@ -249,19 +367,17 @@ func generateRustMessageImpl(buf *bytes.Buffer, messageName string, structType *
 	buf.WriteString("    /// Encode the message to binary wire format\n")
 	buf.WriteString("    pub fn encode(&self) -> Vec<u8> {\n")
 	buf.WriteString("        let mut buf = Vec::new();\n")
+	generateRustWriteBitmap(buf, structType, "        ")
 
 	if hasBulkRun {
 		run := runs[0]
 		generateRustBulkEncode(buf, structType.Fields[run.StartIndex:run.EndIndex+1], run.TotalBytes, "        ")
 		for i := run.EndIndex + 1; i < len(structType.Fields); i++ {
-			field := structType.Fields[i]
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustEncodeField(buf, field.Type, fmt.Sprintf("self.%s", fieldName), "        ", false)
+			generateRustEncodeStructField(buf, structType, structType.Fields[i], "        ", false)
 		}
 	} else {
 		for _, field := range structType.Fields {
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustEncodeField(buf, field.Type, fmt.Sprintf("self.%s", fieldName), "        ", false)
+			generateRustEncodeStructField(buf, structType, field, "        ", false)
 		}
 	}
 
@@ -272,21 +388,20 @@ func generateRustMessageImpl(buf *bytes.Buffer, messageName string, structType *
 	buf.WriteString("    /// Decode a message from binary wire format\n")
 	buf.WriteString("    pub fn decode(bytes: &[u8]) -> Result<Self, FFireError> {\n")
 	buf.WriteString("        let mut pos = 0;\n")
+	bits := generateRustReadBitmap(buf, structType, "pos", "        ")
 
 	if hasBulkRun {
 		run := runs[0]
 		generateRustBulkDecodeLocal(buf, structType.Fields[run.StartIndex:run.EndIndex+1], run.TotalBytes, "        ")
 		for i := run.EndIndex + 1; i < len(structType.Fields); i++ {
-			field := structType.Fields[i]
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustDecodeField(buf, field.Type, fieldName, "        ")
+			generateRustDecodeStructField(buf, structType.Fields[i], bits, "        ", false)
 		}
 	} else {
 		for _, field := range structType.Fields {
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustDecodeField(buf, field.Type, fieldName, "        ")
+			generateRustDecodeStructField(buf, field, bits, "        ", false)
 		}
 	}
+	buf.WriteString("        if pos != bytes.len() { return Err(FFireError::TrailingData); }\n")
 
 	buf.WriteString(fmt.Sprintf("        Ok(%s {\n", structName))
 	for _, field := range structType.Fields {
@@ -310,11 +425,14 @@ func generateRustMessageImpl(buf *bytes.Buffer, messageName string, structType *
 	buf.WriteString("}\n\n")
 }
 
-func generateRustArrayMessage(buf *bytes.Buffer, messageName string, arrayType *schema.ArrayType) {
+func generateRustArrayMessage(buf *bytes.Buffer, messageName, doc string, arrayType *schema.ArrayType) {
 	structName := messageName + "Message"
 	elemType := getRustTypeString(arrayType.ElementType)
 
-	buf.WriteString("/// Type alias for array message\n")
+	if doc == "" {
+		doc = "Type alias for array message"
+	}
+	buf.WriteString(lineDoc("", "///", doc))
 	buf.WriteString(fmt.Sprintf("pub type %s = Vec<%s>;\n\n", structName, elemType))
 
 	// Generate encode/decode functions for array messages
@@ -323,7 +441,7 @@ func generateRustArrayMessage(buf *bytes.Buffer, messageName string, arrayType *
 	buf.WriteString("    let mut buf = Vec::new();\n")
 
 	// Write length prefix
-	buf.WriteString("    buf.extend_from_slice(&(arr.len() as u16).to_le_bytes());\n")
+	buf.WriteString(fmt.Sprintf("    buf.extend_from_slice(&(arr.len() as %s).to_le_bytes());\n", rustLengthType(arrayType)))
 
 	// Write elements
 	generateRustEncodeArrayElements(buf, arrayType.ElementType, "arr", "    ", false)
@@ -334,13 +452,9 @@ func generateRustArrayMessage(buf *bytes.Buffer, messageName string, arrayType *
 	buf.WriteString(fmt.Sprintf("/// Decode %s from binary wire format\n", structName))
 	buf.WriteString(fmt.Sprintf("pub fn decode_%s_message(bytes: &[u8]) -> Result<%s, FFireError> {\n", toSnakeCase(messageName), structName))
 	buf.WriteString("    let mut pos = 0;\n")
-	buf.WriteString("    if bytes.len() < 2 {\n")
-	buf.WriteString("        return Err(FFireError::BufferTooShort);\n")
-	buf.WriteString("    }\n")
-	buf.WriteString("    let len = u16::from_le_bytes([bytes[0], bytes[1]]) as usize;\n")
-	buf.WriteString("    pos += 2;\n")
-
+	generateRustReadCount(buf, arrayType, "pos", "    ")
 	generateRustDecodeArrayElements(buf, arrayType.ElementType, "result", "len", "    ")
+	buf.WriteString("    if pos != bytes.len() { return Err(FFireError::TrailingData); }\n")
 
 	buf.WriteString("    Ok(result)\n")
 	buf.WriteString("}\n\n")
@@ -355,40 +469,36 @@ func generateRustStructImpl(buf *bytes.Buffer, structType *schema.StructType) {
 
 	// Encode method
 	buf.WriteString("    fn encode_to(&self, buf: &mut Vec<u8>) {\n")
+	generateRustWriteBitmap(buf, structType, "        ")
 	
 	if hasBulkRun {
 		run := runs[0]
 		generateRustBulkEncode(buf, structType.Fields[run.StartIndex:run.EndIndex+1], run.TotalBytes, "        ")
 		// Encode remaining fields normally
 		for i := run.EndIndex + 1; i < len(structType.Fields); i++ {
-			field := structType.Fields[i]
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustEncodeField(buf, field.Type, fmt.Sprintf("self.%s", fieldName), "        ", true)
+			generateRustEncodeStructField(buf, structType, structType.Fields[i], "        ", true)
 		}
 	} else {
 		for _, field := range structType.Fields {
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustEncodeField(buf, field.Type, fmt.Sprintf("self.%s", fieldName), "        ", true)
+			generateRustEncodeStructField(buf, structType, field, "        ", true)
 		}
 	}
 	buf.WriteString("    }\n\n")
 
 	// Decode method
 	buf.WriteString("    fn decode_from(bytes: &[u8], pos: &mut usize) -> Result<Self, FFireError> {\n")
+	bits := generateRustReadBitmap(buf, structType, "*pos", "        ")
 	
 	if hasBulkRun {
 		run := runs[0]
 		generateRustBulkDecode(buf, structType.Fields[run.StartIndex:run.EndIndex+1], run.TotalBytes, "        ")
 		// Decode remaining fields normally
 		for i := run.EndIndex + 1; i < len(structType.Fields); i++ {
-			field := structType.Fields[i]
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustDecodeFieldWithPos(buf, field.Type, fieldName, "        ")
+			generateRustDecodeStructField(buf, structType.Fields[i], bits, "        ", true)
 		}
 	} else {
 		for _, field := range structType.Fields {
-			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
-			generateRustDecodeFieldWithPos(buf, field.Type, fieldName, "        ")
+			generateRustDecodeStructField(buf, field, bits, "        ", true)
 		}
 	}
 
@@ -403,6 +513,99 @@ func generateRustStructImpl(buf *bytes.Buffer, structType *schema.StructType) {
 	buf.WriteString("}\n\n")
 }
 
+// generateRustWriteBitmap writes the presence bitmap of a @bitmap struct,
+// read from self, one byte per eight optional fields.
+func generateRustWriteBitmap(buf *bytes.Buffer, st *schema.StructType, indent string) {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%slet mut ffire_bits = [0u8; %d];\n", indent, n))
+	i := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			fieldName := escapeRustFieldName(toSnakeCase(field.Name))
+			buf.WriteString(fmt.Sprintf("%sif self.%s.is_some() { ffire_bits[%d] |= 0x%02x; }\n", indent, fieldName, i/8, 1<<(i%8)))
+			i++
+		}
+	}
+	buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&ffire_bits);\n", indent))
+}
+
+// generateRustReadBitmap reads the presence bitmap of a @bitmap struct at
+// pos ("pos" or "*pos"), rejecting set unused bits, and returns the bit
+// test of each optional field, by field name. It returns nil for other
+// structs.
+func generateRustReadBitmap(buf *bytes.Buffer, st *schema.StructType, pos string, indent string) map[string]string {
+	n := schema.BitmapSize(st)
+	if n == 0 {
+		return nil
+	}
+	buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + %d { return Err(FFireError::BufferTooShort); }\n", indent, pos, n))
+	buf.WriteString(fmt.Sprintf("%slet mut ffire_bits = [0u8; %d];\n", indent, n))
+	buf.WriteString(fmt.Sprintf("%sffire_bits.copy_from_slice(&bytes[%s..%s + %d]);\n", indent, pos, pos, n))
+	buf.WriteString(fmt.Sprintf("%s%s += %d;\n", indent, pos, n))
+	if used := schema.OptionalFields(st) % 8; used != 0 {
+		buf.WriteString(fmt.Sprintf("%sif ffire_bits[%d] & 0x%02x != 0 { return Err(FFireError::BadPresence); }\n", indent, n-1, 0xff<<used&0xff))
+	}
+	bits := make(map[string]string)
+	i := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			bits[field.Name] = fmt.Sprintf("ffire_bits[%d] & 0x%02x != 0", i/8, 1<<(i%8))
+			i++
+		}
+	}
+	return bits
+}
+
+// generateRustEncodeStructField encodes a field read from self. An
+// optional field of a @bitmap struct has its presence in the bitmap, so
+// only a present value is written.
+func generateRustEncodeStructField(buf *bytes.Buffer, st *schema.StructType, field schema.Field, indent string, bufIsMutRef bool) {
+	accessor := "self." + escapeRustFieldName(toSnakeCase(field.Name))
+	if !st.Bitmap || !field.Type.IsOptional() {
+		generateRustEncodeField(buf, field.Type, accessor, indent, bufIsMutRef)
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sif let Some(ref v) = %s {\n", indent, accessor))
+	switch t := schema.WithoutPresence(field.Type).(type) {
+	case *schema.PrimitiveType:
+		if t.Large {
+			generateRustEncodeBlob(buf, t.Name, "v", indent+"    ", "u32")
+		} else {
+			generateRustEncodePrimitive(buf, t.Name, "v", indent+"    ", true)
+		}
+	case *schema.EnumType:
+		generateRustEncodeField(buf, t, "*v", indent+"    ", bufIsMutRef)
+	default:
+		generateRustEncodeField(buf, t, "v", indent+"    ", bufIsMutRef)
+	}
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
+// generateRustDecodeStructField decodes a field into a local of its name.
+// bits holds the bitmap tests of a @bitmap struct's optional fields (see
+// generateRustReadBitmap). withPos selects the decode_from form.
+func generateRustDecodeStructField(buf *bytes.Buffer, field schema.Field, bits map[string]string, indent string, withPos bool) {
+	fieldName := escapeRustFieldName(toSnakeCase(field.Name))
+	decode := generateRustDecodeField
+	if withPos {
+		decode = generateRustDecodeFieldWithPos
+	}
+	bit, ok := bits[field.Name]
+	if !ok {
+		decode(buf, field.Type, fieldName, indent)
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%slet %s = if %s {\n", indent, fieldName, bit))
+	decode(buf, schema.WithoutPresence(field.Type), "v", indent+"    ")
+	buf.WriteString(fmt.Sprintf("%s    Some(v)\n", indent))
+	buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+	buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+	buf.WriteString(fmt.Sprintf("%s};\n", indent))
+}
+
 // generateRustBulkEncode generates code to encode multiple fixed-size fields in one buffer write
 func generateRustBulkEncode(buf *bytes.Buffer, fields []schema.Field, totalBytes int, indent string) {
 	buf.WriteString(fmt.Sprintf("%s// Bulk encode %d bytes of fixed-size fields\n", indent, totalBytes))
@@ -454,7 +657,8 @@ func generateRustBulkDecode(buf *bytes.Buffer, fields []schema.Field, totalBytes
 		
 		switch primType.Name {
 		case "bool":
-			buf.WriteString(fmt.Sprintf("%slet %s = bytes[*pos + %d] != 0;\n", indent, fieldName, offset))
+			buf.WriteString(fmt.Sprintf("%sif bytes[*pos + %d] > 1 { return Err(FFireError::InvalidData); }\n", indent, offset))
+			buf.WriteString(fmt.Sprintf("%slet %s = bytes[*pos + %d] == 1;\n", indent, fieldName, offset))
 			offset += 1
 		case "int8":
 			buf.WriteString(fmt.Sprintf("%slet %s = bytes[*pos + %d] as i8;\n", indent, fieldName, offset))
@@ -494,7 +698,8 @@ func generateRustBulkDecodeLocal(buf *bytes.Buffer, fields []schema.Field, total
 		
 		switch primType.Name {
 		case "bool":
-			buf.WriteString(fmt.Sprintf("%slet %s = bytes[pos + %d] != 0;\n", indent, fieldName, offset))
+			buf.WriteString(fmt.Sprintf("%sif bytes[pos + %d] > 1 { return Err(FFireError::InvalidData); }\n", indent, offset))
+			buf.WriteString(fmt.Sprintf("%slet %s = bytes[pos + %d] == 1;\n", indent, fieldName, offset))
 			offset += 1
 		case "int8":
 			buf.WriteString(fmt.Sprintf("%slet %s = bytes[pos + %d] as i8;\n", indent, fieldName, offset))
@@ -534,33 +739,101 @@ func generateRustEncodeField(buf *bytes.Buffer, fieldType schema.Type, accessor
 		if t.Optional {
 			buf.WriteString(fmt.Sprintf("%sif let Some(ref v) = %s {\n", indent, accessor))
 			buf.WriteString(fmt.Sprintf("%s    buf.push(1);\n", indent))
-			generateRustEncodePrimitive(buf, t.Name, "v", indent+"    ", true) // v is a reference
+			if t.Large {
+				generateRustEncodeBlob(buf, t.Name, "v", indent+"    ", "u32")
+			} else {
+				generateRustEncodePrimitive(buf, t.Name, "v", indent+"    ", true) // v is a reference
+			}
 			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    buf.push(0);\n", indent))
 			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		} else if t.Large {
+			generateRustEncodeBlob(buf, t.Name, accessor, indent, "u32")
 		} else {
 			generateRustEncodePrimitive(buf, t.Name, accessor, indent, false) // accessor is a value
 		}
 
 	case *schema.ArrayType:
-		if t.Optional {
+		if t.Length > 0 {
+			// Fixed-size arrays have no length prefix
+			generateRustEncodeArrayElements(buf, t.ElementType, accessor, indent, bufIsMutRef)
+		} else if t.Optional {
 			buf.WriteString(fmt.Sprintf("%sif let Some(ref arr) = %s {\n", indent, accessor))
 			buf.WriteString(fmt.Sprintf("%s    buf.push(1);\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(&(arr.len() as u16).to_le_bytes());\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(&(arr.len() as %s).to_le_bytes());\n", indent, rustLengthType(t)))
 			generateRustEncodeArrayElements(buf, t.ElementType, "arr", indent+"    ", bufIsMutRef)
 			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    buf.push(0);\n", indent))
 			buf.WriteString(fmt.Sprintf("%s}\n", indent))
 		} else {
-			buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(%s.len() as u16).to_le_bytes());\n", indent, accessor))
+			buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(%s.len() as %s).to_le_bytes());\n", indent, accessor, rustLengthType(t)))
 			generateRustEncodeArrayElements(buf, t.ElementType, accessor, indent, bufIsMutRef)
 		}
 
-	case *schema.StructType:
-		buf.WriteString(fmt.Sprintf("%s%s.encode_to(%s);\n", indent, accessor, bufArg))
+	case *schema.MapType:
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%sif let Some(ref map) = %s {\n", indent, accessor))
+			buf.WriteString(fmt.Sprintf("%s    buf.push(1);\n", indent))
+			generateRustEncodeMapEntries(buf, t, "map", indent+"    ", bufIsMutRef)
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    buf.push(0);\n", indent))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		} else {
+			generateRustEncodeMapEntries(buf, t, accessor, indent, bufIsMutRef)
+		}
+
+	case *schema.EnumType:
+		base := getRustTypeString(&schema.PrimitiveType{Name: t.Base})
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%sif let Some(v) = %s {\n", indent, accessor))
+			buf.WriteString(fmt.Sprintf("%s    buf.push(1);\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(&(v as %s).to_le_bytes());\n", indent, base))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    buf.push(0);\n", indent))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		} else {
+			buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(%s as %s).to_le_bytes());\n", indent, accessor, base))
+		}
+
+	case *schema.StructType, *schema.UnionType:
+		if t.IsOptional() {
+			buf.WriteString(fmt.Sprintf("%sif let Some(ref v) = %s {\n", indent, accessor))
+			buf.WriteString(fmt.Sprintf("%s    buf.push(1);\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    v.encode_to(%s);\n", indent, bufArg))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    buf.push(0);\n", indent))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		} else {
+			buf.WriteString(fmt.Sprintf("%s%s.encode_to(%s);\n", indent, accessor, bufArg))
+		}
 	}
 }
 
+// generateRustEncodeMapEntries writes the entry count and the entries.
+// BTreeMap iterates in ascending key order, which is the wire order.
+func generateRustEncodeMapEntries(buf *bytes.Buffer, t *schema.MapType, accessor string, indent string, bufIsMutRef bool) {
+	keyType := t.KeyType.(*schema.PrimitiveType)
+	// Copy keys and values out of the iterator where the encoders expect values
+	keyPat := "&k"
+	if keyType.Name == "string" {
+		keyPat = "k"
+	}
+	valPat, valAccessor := "v", "v"
+	if prim, ok := t.ValueType.(*schema.PrimitiveType); ok && !prim.Optional && prim.Name != "string" && prim.Name != "bytes" {
+		valPat = "&v"
+	} else if enum, ok := t.ValueType.(*schema.EnumType); ok && !enum.Optional {
+		valPat = "&v"
+	} else if t.ValueType.IsOptional() {
+		valAccessor = "*v"
+	}
+
+	buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(%s.len() as %s).to_le_bytes());\n", indent, accessor, rustLengthType(t)))
+	buf.WriteString(fmt.Sprintf("%sfor (%s, %s) in %s.iter() {\n", indent, keyPat, valPat, accessor))
+	generateRustEncodePrimitive(buf, keyType.Name, "k", indent+"    ", false)
+	generateRustEncodeField(buf, t.ValueType, valAccessor, indent+"    ", bufIsMutRef)
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
 func generateRustEncodePrimitive(buf *bytes.Buffer, typeName string, accessor string, indent string, isRef bool) {
 	// For references, we may need to dereference (*) for some types
 	deref := ""
@@ -575,11 +848,79 @@ func generateRustEncodePrimitive(buf *bytes.Buffer, typeName string, accessor st
 		buf.WriteString(fmt.Sprintf("%sbuf.push(%s%s as u8);\n", indent, deref, accessor))
 	case "int16", "int32", "int64", "float32", "float64":
 		buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&%s.to_le_bytes());\n", indent, accessor))
-	case "string":
+	case "string", "bytes":
+		generateRustEncodeBlob(buf, typeName, accessor, indent, "u16")
+	}
+}
+
+// generateRustEncodeBlob writes a string or bytes value with a length
+// prefix of lenType: u16, or u32 for @large values.
+func generateRustEncodeBlob(buf *bytes.Buffer, typeName string, accessor string, indent string, lenType string) {
+	if typeName == "string" {
 		buf.WriteString(fmt.Sprintf("%slet bytes = %s.as_bytes();\n", indent, accessor))
-		buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(bytes.len() as u16).to_le_bytes());\n", indent))
+		buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(bytes.len() as %s).to_le_bytes());\n", indent, lenType))
 		buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(bytes);\n", indent))
+		return
+	}
+	buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&(%s.len() as %s).to_le_bytes());\n", indent, accessor, lenType))
+	buf.WriteString(fmt.Sprintf("%sbuf.extend_from_slice(&%s);\n", indent, accessor))
+}
+
+// rustReadHelpers reads the one-byte values whose range the decoder must
+// check: a presence flag and a bool are each 0x00 or 0x01.
+const rustReadHelpers = `fn ffire_read_presence(bytes: &[u8], pos: &mut usize) -> Result<bool, FFireError> {
+    let b = *bytes.get(*pos).ok_or(FFireError::BufferTooShort)?;
+    if b > 1 { return Err(FFireError::BadPresence); }
+    *pos += 1;
+    Ok(b == 1)
+}
+
+fn ffire_read_bool(bytes: &[u8], pos: &mut usize) -> Result<bool, FFireError> {
+    let b = *bytes.get(*pos).ok_or(FFireError::BufferTooShort)?;
+    if b > 1 { return Err(FFireError::InvalidData); }
+    *pos += 1;
+    Ok(b == 1)
+}
+
+`
+
+// rustLengthType returns the integer type of t's length prefix.
+func rustLengthType(t schema.Type) string {
+	if schema.IsLarge(t) {
+		return "u32"
+	}
+	return "u16"
+}
+
+// generateRustReadCount reads the element count of an array or map at pos
+// ("pos" or "*pos") into len. Every element takes at least a byte, so a
+// count past the end of the data fails before anything is allocated.
+func generateRustReadCount(buf *bytes.Buffer, t schema.Type, pos string, indent string) {
+	if rustLengthType(t) == "u32" {
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + 4 { return Err(FFireError::BufferTooShort); }\n", indent, pos))
+		buf.WriteString(fmt.Sprintf("%slet len = u32::from_le_bytes([bytes[%s], bytes[%s+1], bytes[%s+2], bytes[%s+3]]) as usize;\n", indent, pos, pos, pos, pos))
+		buf.WriteString(fmt.Sprintf("%s%s += 4;\n", indent, pos))
+	} else {
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + 2 { return Err(FFireError::BufferTooShort); }\n", indent, pos))
+		buf.WriteString(fmt.Sprintf("%slet len = u16::from_le_bytes([bytes[%s], bytes[%s+1]]) as usize;\n", indent, pos, pos))
+		buf.WriteString(fmt.Sprintf("%s%s += 2;\n", indent, pos))
+	}
+	buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + len { return Err(FFireError::Overflow); }\n", indent, pos))
+}
+
+// generateRustDecodeLarge decodes a @large string or bytes value, whose
+// length prefix is a u32, at pos ("pos" or "*pos").
+func generateRustDecodeLarge(buf *bytes.Buffer, typeName string, varName string, pos string, indent string) {
+	buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + 4 { return Err(FFireError::BufferTooShort); }\n", indent, pos))
+	buf.WriteString(fmt.Sprintf("%slet blob_len = u32::from_le_bytes([bytes[%s], bytes[%s+1], bytes[%s+2], bytes[%s+3]]) as usize;\n", indent, pos, pos, pos, pos))
+	buf.WriteString(fmt.Sprintf("%s%s += 4;\n", indent, pos))
+	buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + blob_len { return Err(FFireError::Overflow); }\n", indent, pos))
+	if typeName == "string" {
+		buf.WriteString(fmt.Sprintf("%slet %s = std::str::from_utf8(&bytes[%s..%s+blob_len]).map_err(|_| FFireError::InvalidUtf8)?.to_string();\n", indent, varName, pos, pos))
+	} else {
+		buf.WriteString(fmt.Sprintf("%slet %s = bytes[%s..%s+blob_len].to_vec();\n", indent, varName, pos, pos))
 	}
+	buf.WriteString(fmt.Sprintf("%s%s += blob_len;\n", indent, pos))
 }
 
 func generateRustEncodeArrayElements(buf *bytes.Buffer, elemType schema.Type, accessor string, indent string, bufIsMutRef bool) {
@@ -614,11 +955,34 @@ func generateRustEncodeArrayElements(buf *bytes.Buffer, elemType schema.Type, ac
 			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(&(bytes.len() as u16).to_le_bytes());\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(bytes);\n", indent))
 			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		case "bytes":
+			buf.WriteString(fmt.Sprintf("%sfor b in %s.iter() {\n", indent, accessor))
+			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(&(b.len() as u16).to_le_bytes());\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    buf.extend_from_slice(b);\n", indent))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
 		}
+	case *schema.EnumType:
+		buf.WriteString(fmt.Sprintf("%sfor item in %s.iter() {\n", indent, accessor))
+		generateRustEncodeField(buf, t, "*item", indent+"    ", bufIsMutRef)
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	case *schema.StructType:
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%sfor item in %s.iter() {\n", indent, accessor))
+			generateRustEncodeField(buf, t, "*item", indent+"    ", bufIsMutRef)
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+			break
+		}
 		buf.WriteString(fmt.Sprintf("%sfor item in %s.iter() {\n", indent, accessor))
 		buf.WriteString(fmt.Sprintf("%s    item.encode_to(%s);\n", indent, bufArg))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case *schema.UnionType:
+		item := "item"
+		if t.Optional {
+			item = "*item"
+		}
+		buf.WriteString(fmt.Sprintf("%sfor item in %s.iter() {\n", indent, accessor))
+		generateRustEncodeField(buf, t, item, indent+"    ", bufIsMutRef)
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	}
 }
 
@@ -626,40 +990,72 @@ func generateRustDecodeField(buf *bytes.Buffer, fieldType schema.Type, varName s
 	switch t := fieldType.(type) {
 	case *schema.PrimitiveType:
 		if t.Optional {
-			buf.WriteString(fmt.Sprintf("%slet %s = if bytes.get(pos).copied().unwrap_or(0) == 1 {\n", indent, varName))
-			buf.WriteString(fmt.Sprintf("%s    pos += 1;\n", indent))
-			generateRustDecodePrimitive(buf, t.Name, "v", indent+"    ")
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, &mut pos)? {\n", indent, varName))
+			if t.Large {
+				generateRustDecodeLarge(buf, t.Name, "v", "pos", indent+"    ")
+			} else {
+				generateRustDecodePrimitive(buf, t.Name, "v", indent+"    ")
+			}
 			buf.WriteString(fmt.Sprintf("%s    Some(v)\n", indent))
 			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    pos += 1;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
 			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else if t.Large {
+			generateRustDecodeLarge(buf, t.Name, varName, "pos", indent)
 		} else {
 			generateRustDecodePrimitive(buf, t.Name, varName, indent)
 		}
 
 	case *schema.ArrayType:
-		if t.Optional {
-			buf.WriteString(fmt.Sprintf("%slet %s = if bytes.get(pos).copied().unwrap_or(0) == 1 {\n", indent, varName))
-			buf.WriteString(fmt.Sprintf("%s    pos += 1;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    let len = u16::from_le_bytes([bytes[pos], bytes[pos+1]]) as usize;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    pos += 2;\n", indent))
+		if t.Length > 0 {
+			generateRustDecodeFixedArray(buf, t, varName, "pos", indent)
+		} else if t.Optional {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, &mut pos)? {\n", indent, varName))
+			generateRustReadCount(buf, t, "pos", indent+"    ")
 			generateRustDecodeArrayElements(buf, t.ElementType, "arr", "len", indent+"    ")
 			buf.WriteString(fmt.Sprintf("%s    Some(arr)\n", indent))
 			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    pos += 1;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
 			buf.WriteString(fmt.Sprintf("%s};\n", indent))
 		} else {
-			buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
-			buf.WriteString(fmt.Sprintf("%slet len = u16::from_le_bytes([bytes[pos], bytes[pos+1]]) as usize;\n", indent))
-			buf.WriteString(fmt.Sprintf("%spos += 2;\n", indent))
+			generateRustReadCount(buf, t, "pos", indent)
 			generateRustDecodeArrayElements(buf, t.ElementType, varName, "len", indent)
 		}
 
-	case *schema.StructType:
-		buf.WriteString(fmt.Sprintf("%slet %s = %s::decode_from(bytes, &mut pos)?;\n", indent, varName, t.Name))
+	case *schema.MapType:
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, &mut pos)? {\n", indent, varName))
+			generateRustDecodeMapEntries(buf, t, "map", indent+"    ", false)
+			buf.WriteString(fmt.Sprintf("%s    Some(map)\n", indent))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else {
+			generateRustDecodeMapEntries(buf, t, varName, indent, false)
+		}
+
+	case *schema.EnumType:
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, &mut pos)? {\n", indent, varName))
+			generateRustDecodeEnum(buf, t, "v", indent+"    ", false)
+			buf.WriteString(fmt.Sprintf("%s    Some(v)\n", indent))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else {
+			generateRustDecodeEnum(buf, t, varName, indent, false)
+		}
+
+	case *schema.StructType, *schema.UnionType:
+		if t.IsOptional() {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, &mut pos)? {\n", indent, varName))
+			buf.WriteString(fmt.Sprintf("%s    Some(%s::decode_from(bytes, &mut pos)?)\n", indent, t.TypeName()))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else {
+			buf.WriteString(fmt.Sprintf("%slet %s = %s::decode_from(bytes, &mut pos)?;\n", indent, varName, t.TypeName()))
+		}
 	}
 }
 
@@ -668,50 +1064,132 @@ func generateRustDecodeFieldWithPos(buf *bytes.Buffer, fieldType schema.Type, va
 	switch t := fieldType.(type) {
 	case *schema.PrimitiveType:
 		if t.Optional {
-			buf.WriteString(fmt.Sprintf("%slet %s = if bytes.get(*pos).copied().unwrap_or(0) == 1 {\n", indent, varName))
-			buf.WriteString(fmt.Sprintf("%s    *pos += 1;\n", indent))
-			generateRustDecodePrimitiveWithPos(buf, t.Name, "v", indent+"    ")
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, pos)? {\n", indent, varName))
+			if t.Large {
+				generateRustDecodeLarge(buf, t.Name, "v", "*pos", indent+"    ")
+			} else {
+				generateRustDecodePrimitiveWithPos(buf, t.Name, "v", indent+"    ")
+			}
 			buf.WriteString(fmt.Sprintf("%s    Some(v)\n", indent))
 			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    *pos += 1;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
 			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else if t.Large {
+			generateRustDecodeLarge(buf, t.Name, varName, "*pos", indent)
 		} else {
 			generateRustDecodePrimitiveWithPos(buf, t.Name, varName, indent)
 		}
 
 	case *schema.ArrayType:
-		if t.Optional {
-			buf.WriteString(fmt.Sprintf("%slet %s = if bytes.get(*pos).copied().unwrap_or(0) == 1 {\n", indent, varName))
-			buf.WriteString(fmt.Sprintf("%s    *pos += 1;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    let len = u16::from_le_bytes([bytes[*pos], bytes[*pos+1]]) as usize;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    *pos += 2;\n", indent))
+		if t.Length > 0 {
+			generateRustDecodeFixedArray(buf, t, varName, "*pos", indent)
+		} else if t.Optional {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, pos)? {\n", indent, varName))
+			generateRustReadCount(buf, t, "*pos", indent+"    ")
 			generateRustDecodeArrayElementsWithPos(buf, t.ElementType, "arr", "len", indent+"    ")
 			buf.WriteString(fmt.Sprintf("%s    Some(arr)\n", indent))
 			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    *pos += 1;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
 			buf.WriteString(fmt.Sprintf("%s};\n", indent))
 		} else {
-			buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
-			buf.WriteString(fmt.Sprintf("%slet len = u16::from_le_bytes([bytes[*pos], bytes[*pos+1]]) as usize;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s*pos += 2;\n", indent))
+			generateRustReadCount(buf, t, "*pos", indent)
 			generateRustDecodeArrayElementsWithPos(buf, t.ElementType, varName, "len", indent)
 		}
 
-	case *schema.StructType:
-		buf.WriteString(fmt.Sprintf("%slet %s = %s::decode_from(bytes, pos)?;\n", indent, varName, t.Name))
+	case *schema.MapType:
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, pos)? {\n", indent, varName))
+			generateRustDecodeMapEntries(buf, t, "map", indent+"    ", true)
+			buf.WriteString(fmt.Sprintf("%s    Some(map)\n", indent))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else {
+			generateRustDecodeMapEntries(buf, t, varName, indent, true)
+		}
+
+	case *schema.EnumType:
+		if t.Optional {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, pos)? {\n", indent, varName))
+			generateRustDecodeEnum(buf, t, "v", indent+"    ", true)
+			buf.WriteString(fmt.Sprintf("%s    Some(v)\n", indent))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else {
+			generateRustDecodeEnum(buf, t, varName, indent, true)
+		}
+
+	case *schema.StructType, *schema.UnionType:
+		if t.IsOptional() {
+			buf.WriteString(fmt.Sprintf("%slet %s = if ffire_read_presence(bytes, pos)? {\n", indent, varName))
+			buf.WriteString(fmt.Sprintf("%s    Some(%s::decode_from(bytes, pos)?)\n", indent, t.TypeName()))
+			buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    None\n", indent))
+			buf.WriteString(fmt.Sprintf("%s};\n", indent))
+		} else {
+			buf.WriteString(fmt.Sprintf("%slet %s = %s::decode_from(bytes, pos)?;\n", indent, varName, t.TypeName()))
+		}
 	}
 }
 
+// generateRustDecodeFixedArray decodes a fixed-size array, whose element
+// count comes from the schema. pos is "pos" or "*pos", as in
+// generateRustDecodeMapEntries.
+func generateRustDecodeFixedArray(buf *bytes.Buffer, t *schema.ArrayType, varName string, pos string, indent string) {
+	elem := t.ElementType.(*schema.PrimitiveType).Name
+	elemType := getRustTypeString(t.ElementType)
+	byteLen := t.Length * getRustPrimitiveSize(elem)
+	buf.WriteString(fmt.Sprintf("%sif bytes.len() < %s + %d { return Err(FFireError::BufferTooShort); }\n", indent, pos, byteLen))
+	switch elem {
+	case "bool":
+		buf.WriteString(fmt.Sprintf("%sif bytes[%s..%s + %d].iter().any(|&b| b > 1) { return Err(FFireError::InvalidData); }\n", indent, pos, pos, byteLen))
+		buf.WriteString(fmt.Sprintf("%slet %s: %s = std::array::from_fn(|i| bytes[%s + i] == 1);\n", indent, varName, getRustTypeString(t), pos))
+	case "int8":
+		buf.WriteString(fmt.Sprintf("%slet %s: %s = std::array::from_fn(|i| bytes[%s + i] as i8);\n", indent, varName, getRustTypeString(t), pos))
+	default:
+		buf.WriteString(fmt.Sprintf("%slet mut %s = [0 as %s; %d];\n", indent, varName, elemType, t.Length))
+		buf.WriteString(fmt.Sprintf("%s// SAFETY: %s has known size, wire format is little-endian\n", indent, elem))
+		buf.WriteString(fmt.Sprintf("%sunsafe {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s    let dst = std::slice::from_raw_parts_mut(%s.as_mut_ptr() as *mut u8, %d);\n", indent, varName, byteLen))
+		buf.WriteString(fmt.Sprintf("%s    dst.copy_from_slice(&bytes[%s..%s + %d]);\n", indent, pos, pos, byteLen))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+	buf.WriteString(fmt.Sprintf("%s%s += %d;\n", indent, pos, byteLen))
+}
+
+// generateRustDecodeMapEntries decodes the entry count and the entries
+// into a BTreeMap. Entries may arrive in any order; a repeated key keeps
+// its last value. withPos selects the decode_from form, where pos is a
+// &mut usize.
+func generateRustDecodeMapEntries(buf *bytes.Buffer, t *schema.MapType, varName string, indent string, withPos bool) {
+	pos := "pos"
+	if withPos {
+		pos = "*pos"
+	}
+	generateRustReadCount(buf, t, pos, indent)
+	buf.WriteString(fmt.Sprintf("%slet mut %s: %s = std::collections::BTreeMap::new();\n", indent, varName, getRustTypeString(&schema.MapType{KeyType: t.KeyType, ValueType: t.ValueType})))
+	buf.WriteString(fmt.Sprintf("%sfor _ in 0..len {\n", indent))
+	// Entry names derive from the map's, so nested maps don't shadow them
+	keyVar, valVar := varName+"_k", varName+"_v"
+	keyType := t.KeyType.(*schema.PrimitiveType)
+	if withPos {
+		generateRustDecodePrimitiveWithPos(buf, keyType.Name, keyVar, indent+"    ")
+		generateRustDecodeFieldWithPos(buf, t.ValueType, valVar, indent+"    ")
+	} else {
+		generateRustDecodePrimitive(buf, keyType.Name, keyVar, indent+"    ")
+		generateRustDecodeField(buf, t.ValueType, valVar, indent+"    ")
+	}
+	buf.WriteString(fmt.Sprintf("%s    %s.insert(%s, %s);\n", indent, varName, keyVar, valVar))
+	buf.WriteString(fmt.Sprintf("%s}\n", indent))
+}
+
 func generateRustDecodePrimitive(buf *bytes.Buffer, typeName string, varName string, indent string) {
 	switch typeName {
 	case "bool":
-		buf.WriteString(fmt.Sprintf("%slet %s = bytes.get(pos).copied().unwrap_or(0) != 0;\n", indent, varName))
-		buf.WriteString(fmt.Sprintf("%spos += 1;\n", indent))
+		buf.WriteString(fmt.Sprintf("%slet %s = ffire_read_bool(bytes, &mut pos)?;\n", indent, varName))
 	case "int8":
-		buf.WriteString(fmt.Sprintf("%slet %s = bytes.get(pos).copied().unwrap_or(0) as i8;\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%slet %s = *bytes.get(pos).ok_or(FFireError::BufferTooShort)? as i8;\n", indent, varName))
 		buf.WriteString(fmt.Sprintf("%spos += 1;\n", indent))
 	case "int16":
 		buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
@@ -737,19 +1215,25 @@ func generateRustDecodePrimitive(buf *bytes.Buffer, typeName string, varName str
 		buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
 		buf.WriteString(fmt.Sprintf("%slet str_len = u16::from_le_bytes([bytes[pos], bytes[pos+1]]) as usize;\n", indent))
 		buf.WriteString(fmt.Sprintf("%spos += 2;\n", indent))
-		buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + str_len { return Err(FFireError::BufferTooShort); }\n", indent))
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + str_len { return Err(FFireError::Overflow); }\n", indent))
 		buf.WriteString(fmt.Sprintf("%slet %s = std::str::from_utf8(&bytes[pos..pos+str_len]).map_err(|_| FFireError::InvalidUtf8)?.to_string();\n", indent, varName))
 		buf.WriteString(fmt.Sprintf("%spos += str_len;\n", indent))
+	case "bytes":
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
+		buf.WriteString(fmt.Sprintf("%slet blob_len = u16::from_le_bytes([bytes[pos], bytes[pos+1]]) as usize;\n", indent))
+		buf.WriteString(fmt.Sprintf("%spos += 2;\n", indent))
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < pos + blob_len { return Err(FFireError::Overflow); }\n", indent))
+		buf.WriteString(fmt.Sprintf("%slet %s = bytes[pos..pos+blob_len].to_vec();\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%spos += blob_len;\n", indent))
 	}
 }
 
 func generateRustDecodePrimitiveWithPos(buf *bytes.Buffer, typeName string, varName string, indent string) {
 	switch typeName {
 	case "bool":
-		buf.WriteString(fmt.Sprintf("%slet %s = bytes.get(*pos).copied().unwrap_or(0) != 0;\n", indent, varName))
-		buf.WriteString(fmt.Sprintf("%s*pos += 1;\n", indent))
+		buf.WriteString(fmt.Sprintf("%slet %s = ffire_read_bool(bytes, pos)?;\n", indent, varName))
 	case "int8":
-		buf.WriteString(fmt.Sprintf("%slet %s = bytes.get(*pos).copied().unwrap_or(0) as i8;\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%slet %s = *bytes.get(*pos).ok_or(FFireError::BufferTooShort)? as i8;\n", indent, varName))
 		buf.WriteString(fmt.Sprintf("%s*pos += 1;\n", indent))
 	case "int16":
 		buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
@@ -775,9 +1259,16 @@ func generateRustDecodePrimitiveWithPos(buf *bytes.Buffer, typeName string, varN
 		buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
 		buf.WriteString(fmt.Sprintf("%slet str_len = u16::from_le_bytes([bytes[*pos], bytes[*pos+1]]) as usize;\n", indent))
 		buf.WriteString(fmt.Sprintf("%s*pos += 2;\n", indent))
-		buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + str_len { return Err(FFireError::BufferTooShort); }\n", indent))
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + str_len { return Err(FFireError::Overflow); }\n", indent))
 		buf.WriteString(fmt.Sprintf("%slet %s = std::str::from_utf8(&bytes[*pos..*pos+str_len]).map_err(|_| FFireError::InvalidUtf8)?.to_string();\n", indent, varName))
 		buf.WriteString(fmt.Sprintf("%s*pos += str_len;\n", indent))
+	case "bytes":
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
+		buf.WriteString(fmt.Sprintf("%slet blob_len = u16::from_le_bytes([bytes[*pos], bytes[*pos+1]]) as usize;\n", indent))
+		buf.WriteString(fmt.Sprintf("%s*pos += 2;\n", indent))
+		buf.WriteString(fmt.Sprintf("%sif bytes.len() < *pos + blob_len { return Err(FFireError::Overflow); }\n", indent))
+		buf.WriteString(fmt.Sprintf("%slet %s = bytes[*pos..*pos+blob_len].to_vec();\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%s*pos += blob_len;\n", indent))
 	}
 }
 
@@ -786,14 +1277,10 @@ func generateRustDecodeArrayElements(buf *bytes.Buffer, elemType schema.Type, va
 	case *schema.PrimitiveType:
 		switch t.Name {
 		case "bool":
-			buf.WriteString(fmt.Sprintf("%slet %s: Vec<bool> = (0..%s).map(|i| {\n", indent, varName, lenVar))
-			buf.WriteString(fmt.Sprintf("%s    bytes.get(pos + i).copied().unwrap_or(0) != 0\n", indent))
-			buf.WriteString(fmt.Sprintf("%s}).collect();\n", indent))
+			buf.WriteString(fmt.Sprintf("%slet %s: Vec<bool> = bytes[pos..pos + %s].iter().map(|&b| if b > 1 { Err(FFireError::InvalidData) } else { Ok(b == 1) }).collect::<Result<_, _>>()?;\n", indent, varName, lenVar))
 			buf.WriteString(fmt.Sprintf("%spos += %s;\n", indent, lenVar))
 		case "int8":
-			buf.WriteString(fmt.Sprintf("%slet %s: Vec<i8> = (0..%s).map(|i| {\n", indent, varName, lenVar))
-			buf.WriteString(fmt.Sprintf("%s    bytes.get(pos + i).copied().unwrap_or(0) as i8\n", indent))
-			buf.WriteString(fmt.Sprintf("%s}).collect();\n", indent))
+			buf.WriteString(fmt.Sprintf("%slet %s: Vec<i8> = bytes[pos..pos + %s].iter().map(|&b| b as i8).collect();\n", indent, varName, lenVar))
 			buf.WriteString(fmt.Sprintf("%spos += %s;\n", indent, lenVar))
 		case "int16":
 			buf.WriteString(fmt.Sprintf("%slet byte_len = %s * 2;\n", indent, lenVar))
@@ -851,16 +1338,42 @@ func generateRustDecodeArrayElements(buf *bytes.Buffer, elemType schema.Type, va
 			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    let str_len = u16::from_le_bytes([bytes[pos], bytes[pos+1]]) as usize;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    pos += 2;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < pos + str_len { return Err(FFireError::BufferTooShort); }\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < pos + str_len { return Err(FFireError::Overflow); }\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    let s = std::str::from_utf8(&bytes[pos..pos+str_len]).map_err(|_| FFireError::InvalidUtf8)?.to_string();\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    pos += str_len;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    %s.push(s);\n", indent, varName))
 			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		case "bytes":
+			buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<Vec<u8>> = Vec::with_capacity(%s);\n", indent, varName, lenVar))
+			buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
+			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    let blob_len = u16::from_le_bytes([bytes[pos], bytes[pos+1]]) as usize;\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    pos += 2;\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < pos + blob_len { return Err(FFireError::Overflow); }\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    %s.push(bytes[pos..pos+blob_len].to_vec());\n", indent, varName))
+			buf.WriteString(fmt.Sprintf("%s    pos += blob_len;\n", indent))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
 		}
+	case *schema.EnumType:
+		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, getRustTypeString(t), lenVar))
+		buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
+		if false {
+			generateRustDecodeFieldWithPos(buf, t, "item", indent+"    ")
+		} else {
+			generateRustDecodeField(buf, t, "item", indent+"    ")
+		}
+		buf.WriteString(fmt.Sprintf("%s    %s.push(item);\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	case *schema.StructType:
-		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, t.Name, lenVar))
+		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, getRustTypeString(t), lenVar))
 		buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
-		buf.WriteString(fmt.Sprintf("%s    let item = %s::decode_from(bytes, &mut pos)?;\n", indent, t.Name))
+		generateRustDecodeField(buf, t, "item", indent+"    ")
+		buf.WriteString(fmt.Sprintf("%s    %s.push(item);\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case *schema.UnionType:
+		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, getRustTypeString(t), lenVar))
+		buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
+		generateRustDecodeField(buf, t, "item", indent+"    ")
 		buf.WriteString(fmt.Sprintf("%s    %s.push(item);\n", indent, varName))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	}
@@ -871,14 +1384,10 @@ func generateRustDecodeArrayElementsWithPos(buf *bytes.Buffer, elemType schema.T
 	case *schema.PrimitiveType:
 		switch t.Name {
 		case "bool":
-			buf.WriteString(fmt.Sprintf("%slet %s: Vec<bool> = (0..%s).map(|i| {\n", indent, varName, lenVar))
-			buf.WriteString(fmt.Sprintf("%s    bytes.get(*pos + i).copied().unwrap_or(0) != 0\n", indent))
-			buf.WriteString(fmt.Sprintf("%s}).collect();\n", indent))
+			buf.WriteString(fmt.Sprintf("%slet %s: Vec<bool> = bytes[*pos..*pos + %s].iter().map(|&b| if b > 1 { Err(FFireError::InvalidData) } else { Ok(b == 1) }).collect::<Result<_, _>>()?;\n", indent, varName, lenVar))
 			buf.WriteString(fmt.Sprintf("%s*pos += %s;\n", indent, lenVar))
 		case "int8":
-			buf.WriteString(fmt.Sprintf("%slet %s: Vec<i8> = (0..%s).map(|i| {\n", indent, varName, lenVar))
-			buf.WriteString(fmt.Sprintf("%s    bytes.get(*pos + i).copied().unwrap_or(0) as i8\n", indent))
-			buf.WriteString(fmt.Sprintf("%s}).collect();\n", indent))
+			buf.WriteString(fmt.Sprintf("%slet %s: Vec<i8> = bytes[*pos..*pos + %s].iter().map(|&b| b as i8).collect();\n", indent, varName, lenVar))
 			buf.WriteString(fmt.Sprintf("%s*pos += %s;\n", indent, lenVar))
 		case "int16":
 			buf.WriteString(fmt.Sprintf("%slet byte_len = %s * 2;\n", indent, lenVar))
@@ -936,16 +1445,42 @@ func generateRustDecodeArrayElementsWithPos(buf *bytes.Buffer, elemType schema.T
 			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    let str_len = u16::from_le_bytes([bytes[*pos], bytes[*pos+1]]) as usize;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    *pos += 2;\n", indent))
-			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < *pos + str_len { return Err(FFireError::BufferTooShort); }\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < *pos + str_len { return Err(FFireError::Overflow); }\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    let s = std::str::from_utf8(&bytes[*pos..*pos+str_len]).map_err(|_| FFireError::InvalidUtf8)?.to_string();\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    *pos += str_len;\n", indent))
 			buf.WriteString(fmt.Sprintf("%s    %s.push(s);\n", indent, varName))
 			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		case "bytes":
+			buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<Vec<u8>> = Vec::with_capacity(%s);\n", indent, varName, lenVar))
+			buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
+			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < *pos + 2 { return Err(FFireError::BufferTooShort); }\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    let blob_len = u16::from_le_bytes([bytes[*pos], bytes[*pos+1]]) as usize;\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    *pos += 2;\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    if bytes.len() < *pos + blob_len { return Err(FFireError::Overflow); }\n", indent))
+			buf.WriteString(fmt.Sprintf("%s    %s.push(bytes[*pos..*pos+blob_len].to_vec());\n", indent, varName))
+			buf.WriteString(fmt.Sprintf("%s    *pos += blob_len;\n", indent))
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
 		}
+	case *schema.EnumType:
+		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, getRustTypeString(t), lenVar))
+		buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
+		if true {
+			generateRustDecodeFieldWithPos(buf, t, "item", indent+"    ")
+		} else {
+			generateRustDecodeField(buf, t, "item", indent+"    ")
+		}
+		buf.WriteString(fmt.Sprintf("%s    %s.push(item);\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	case *schema.StructType:
-		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, t.Name, lenVar))
+		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, getRustTypeString(t), lenVar))
+		buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
+		generateRustDecodeFieldWithPos(buf, t, "item", indent+"    ")
+		buf.WriteString(fmt.Sprintf("%s    %s.push(item);\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case *schema.UnionType:
+		buf.WriteString(fmt.Sprintf("%slet mut %s: Vec<%s> = Vec::with_capacity(%s);\n", indent, varName, getRustTypeString(t), lenVar))
 		buf.WriteString(fmt.Sprintf("%sfor _ in 0..%s {\n", indent, lenVar))
-		buf.WriteString(fmt.Sprintf("%s    let item = %s::decode_from(bytes, pos)?;\n", indent, t.Name))
+		generateRustDecodeFieldWithPos(buf, t, "item", indent+"    ")
 		buf.WriteString(fmt.Sprintf("%s    %s.push(item);\n", indent, varName))
 		buf.WriteString(fmt.Sprintf("%s}\n", indent))
 	}
@@ -972,6 +1507,8 @@ func getRustTypeString(t schema.Type) string {
 			baseType = "f64"
 		case "string":
 			baseType = "String"
+		case "bytes":
+			baseType = "Vec<u8>"
 		default:
 			baseType = typ.Name
 		}
@@ -981,11 +1518,33 @@ func getRustTypeString(t schema.Type) string {
 		return baseType
 	case *schema.ArrayType:
 		elemType := getRustTypeString(typ.ElementType)
+		if typ.Length > 0 {
+			return fmt.Sprintf("[%s; %d]", elemType, typ.Length)
+		}
 		if typ.Optional {
 			return fmt.Sprintf("Option<Vec<%s>>", elemType)
 		}
 		return fmt.Sprintf("Vec<%s>", elemType)
+	case *schema.MapType:
+		mapType := fmt.Sprintf("std::collections::BTreeMap<%s, %s>", getRustTypeString(typ.KeyType), getRustTypeString(typ.ValueType))
+		if typ.Optional {
+			return fmt.Sprintf("Option<%s>", mapType)
+		}
+		return mapType
+	case *schema.EnumType:
+		if typ.Optional {
+			return fmt.Sprintf("Option<%s>", typ.Name)
+		}
+		return typ.Name
 	case *schema.StructType:
+		if typ.Optional {
+			return fmt.Sprintf("Option<%s>", typ.Name)
+		}
+		return typ.Name
+	case *schema.UnionType:
+		if typ.Optional {
+			return fmt.Sprintf("Option<%s>", typ.Name)
+		}
 		return typ.Name
 	default:
 		return "Unknown"
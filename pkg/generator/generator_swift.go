@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/shaban/ffire/pkg/schema"
 )
@@ -58,55 +59,6 @@ func SanitizeSwiftModuleName(name string) string {
 	return name
 }
 
-// estimateStructSize calculates the minimum encoded size of a struct type.
-// This is used for capacity pre-allocation to minimize buffer reallocations.
-// Returns the sum of:
-// - Fixed-size primitive fields
-// - 2 bytes per string/array length prefix
-// - 1 byte per optional presence flag
-// - 16 bytes per string (heuristic for average string content)
-// - Recursively calculates nested struct sizes
-func estimateStructSize(structType *schema.StructType) int {
-	size := 0
-	for _, field := range structType.Fields {
-		size += estimateFieldSize(field.Type)
-	}
-	return size
-}
-
-func estimateFieldSize(fieldType schema.Type) int {
-	switch t := fieldType.(type) {
-	case *schema.PrimitiveType:
-		baseSize := 0
-		switch t.Name {
-		case "bool", "int8":
-			baseSize = 1
-		case "int16":
-			baseSize = 2
-		case "int32", "float32":
-			baseSize = 4
-		case "int64", "float64":
-			baseSize = 8
-		case "string":
-			baseSize = 2 + 16 // length prefix + avg string content
-		}
-		if t.Optional {
-			return 1 + baseSize // presence flag + value
-		}
-		return baseSize
-	case *schema.ArrayType:
-		elemSize := estimateFieldSize(t.ElementType)
-		// Assume avg 5 elements + 2 bytes length prefix
-		if t.Optional {
-			return 1 + 2 + elemSize*5
-		}
-		return 2 + elemSize*5
-	case *schema.StructType:
-		return estimateStructSize(t)
-	}
-	return 32 // fallback
-}
-
 // GenerateSwiftPackage generates a complete Swift package using the orchestrator
 func GenerateSwiftPackage(config *PackageConfig) error {
 	// Sanitize the namespace to avoid Swift keywords
@@ -123,7 +75,7 @@ func GenerateSwiftPackage(config *PackageConfig) error {
 
 func generateSwiftWrapperOrchestrated(config *PackageConfig, paths *PackagePaths) error {
 	// Generate native Swift code
-	swiftCode, err := generateSwiftNative(config.Schema)
+	swiftCode, err := generateSwiftNativeWithOptions(config.Schema, swiftOptions{Codable: config.SwiftCodable})
 	if err != nil {
 		return fmt.Errorf("failed to generate Swift code: %w", err)
 	}
@@ -139,13 +91,25 @@ func generateSwiftWrapperOrchestrated(config *PackageConfig, paths *PackagePaths
 	if err := os.WriteFile(swiftPath, swiftCode, 0644); err != nil {
 		return fmt.Errorf("failed to write Swift source: %w", err)
 	}
-	fmt.Printf("✓ Generated Swift source: %s\n", swiftPath)
+	config.infof("✓ Generated Swift source: %s\n", swiftPath)
 
 	return nil
 }
 
+// swiftOptions select optional code in the generated Swift source.
+type swiftOptions struct {
+	// Codable adds Codable conformances whose JSON is the value tree of
+	// ffire fixtures (see generateSwiftCodable).
+	Codable bool
+}
+
 // generateSwiftNative generates pure Swift code optimized for maximum performance
 func generateSwiftNative(s *schema.Schema) ([]byte, error) {
+	return generateSwiftNativeWithOptions(s, swiftOptions{})
+}
+
+// generateSwiftNativeWithOptions is generateSwiftNative with optional code.
+func generateSwiftNativeWithOptions(s *schema.Schema, opts swiftOptions) ([]byte, error) {
 	s.Canonicalize()
 
 	var buf bytes.Buffer
@@ -155,6 +119,30 @@ func generateSwiftNative(s *schema.Schema) ([]byte, error) {
 	buf.WriteString("// DO NOT EDIT - This file is auto-generated\n\n")
 	buf.WriteString("import Foundation\n\n")
 
+	for _, enum := range s.Enums() {
+		generateSwiftEnum(&buf, enum)
+	}
+
+	for _, union := range s.Unions() {
+		generateSwiftUnion(&buf, union)
+	}
+
+	// Generate helper structs (embedded types, no Message suffix) in the
+	// dependency order shared by all generators
+	for _, structType := range topSortStructTypes(s.Types) {
+		// Skip if this is a root message type
+		isRootType := false
+		for _, msg := range s.Messages {
+			if st, ok := msg.TargetType.(*schema.StructType); ok && st.Name == structType.Name {
+				isRootType = true
+				break
+			}
+		}
+		if !isRootType {
+			generateSwiftStruct(&buf, structType)
+		}
+	}
+
 	// Generate message type definitions (root types with Message suffix)
 	for _, msg := range s.Messages {
 		if structType, ok := msg.TargetType.(*schema.StructType); ok {
@@ -162,31 +150,16 @@ func generateSwiftNative(s *schema.Schema) ([]byte, error) {
 		} else if arrayType, ok := msg.TargetType.(*schema.ArrayType); ok {
 			// Array type alias
 			elemTypeStr := getSwiftTypeString(arrayType.ElementType)
+			buf.WriteString(lineDoc("", "///", msg.Doc))
 			buf.WriteString(fmt.Sprintf("public typealias %sMessage = [%s]\n\n", msg.Name, elemTypeStr))
 		} else {
 			// Primitive type alias
 			typeStr := getSwiftTypeString(msg.TargetType)
+			buf.WriteString(lineDoc("", "///", msg.Doc))
 			buf.WriteString(fmt.Sprintf("public typealias %sMessage = %s\n\n", msg.Name, typeStr))
 		}
 	}
 
-	// Generate helper structs (embedded types, no Message suffix)
-	for _, typ := range s.Types {
-		if structType, ok := typ.(*schema.StructType); ok {
-			// Skip if this is a root message type
-			isRootType := false
-			for _, msg := range s.Messages {
-				if st, ok := msg.TargetType.(*schema.StructType); ok && st.Name == structType.Name {
-					isRootType = true
-					break
-				}
-			}
-			if !isRootType {
-				generateSwiftStruct(&buf, structType)
-			}
-		}
-	}
-
 	// Generate encode functions
 	buf.WriteString("// MARK: - Encoding\n\n")
 	for _, msg := range s.Messages {
@@ -224,19 +197,205 @@ func generateSwiftNative(s *schema.Schema) ([]byte, error) {
 		}
 	}
 
+	for _, union := range s.Unions() {
+		generateSwiftUnionHelpers(&buf, union)
+	}
+
+	if opts.Codable {
+		generateSwiftCodable(&buf, s)
+	}
+
 	// Generate helper functions
 	generateSwiftHelpers(&buf)
+	if s.HasLarge() {
+		generateSwiftLargeHelpers(&buf)
+	}
+	if s.HasCachedFields() {
+		generateSwiftStringCache(&buf)
+	}
 
 	return buf.Bytes(), nil
 }
 
+// swiftConformances are the protocols every generated struct, enum and
+// union adopts. The compiler synthesizes them, since every field type
+// conforms: values compare with ==, go in sets and dictionary keys, and
+// cross actor and task boundaries.
+const swiftConformances = "Equatable, Hashable, Sendable"
+
+// generateSwiftCodable adds Codable conformances to the types of s. The
+// JSON they read and write is the value tree of ffire fixtures, so it works
+// with ffire fixture and ffire convert: properties are named after the
+// fields already, bytes are base64, optional fields are left out when nil,
+// enums are their constant names and unions objects with one key naming
+// the variant. Maps with keys other than String are encoded the way Swift
+// encodes dictionaries, as arrays alternating keys and values.
+func generateSwiftCodable(buf *bytes.Buffer, s *schema.Schema) {
+	buf.WriteString("// MARK: - Codable\n\n")
+	for _, enum := range s.Enums() {
+		buf.WriteString(fmt.Sprintf("extension %s: Codable {\n", enum.Name))
+		buf.WriteString("    public init(from decoder: Decoder) throws {\n")
+		buf.WriteString("        let container = try decoder.singleValueContainer()\n")
+		buf.WriteString("        let name = try container.decode(String.self)\n")
+		buf.WriteString("        switch name {\n")
+		for _, v := range enum.Values {
+			buf.WriteString(fmt.Sprintf("        case %q: self = .%s\n", v.Name, v.Name))
+		}
+		buf.WriteString("        default:\n")
+		buf.WriteString(fmt.Sprintf("            throw DecodingError.dataCorruptedError(in: container, debugDescription: \"\\\"\\(name)\\\" is not a %s\")\n", enum.Name))
+		buf.WriteString("        }\n")
+		buf.WriteString("    }\n\n")
+		buf.WriteString("    public func encode(to encoder: Encoder) throws {\n")
+		buf.WriteString("        var container = encoder.singleValueContainer()\n")
+		buf.WriteString("        switch self {\n")
+		for _, v := range enum.Values {
+			buf.WriteString(fmt.Sprintf("        case .%s: try container.encode(%q)\n", v.Name, v.Name))
+		}
+		buf.WriteString("        }\n")
+		buf.WriteString("    }\n")
+		buf.WriteString("}\n\n")
+	}
+
+	for _, union := range s.Unions() {
+		buf.WriteString(fmt.Sprintf("extension %s: Codable {\n", union.Name))
+		buf.WriteString("    private enum CodingKeys: String, CodingKey {\n")
+		for _, v := range union.Variants {
+			buf.WriteString(fmt.Sprintf("        case %s = %q\n", swiftVariantCase(v), v.TypeName()))
+		}
+		buf.WriteString("    }\n\n")
+		buf.WriteString("    public init(from decoder: Decoder) throws {\n")
+		buf.WriteString("        let container = try decoder.container(keyedBy: CodingKeys.self)\n")
+		buf.WriteString("        guard container.allKeys.count == 1, let key = container.allKeys.first else {\n")
+		buf.WriteString("            throw DecodingError.dataCorrupted(DecodingError.Context(\n")
+		buf.WriteString(fmt.Sprintf("                codingPath: container.codingPath, debugDescription: \"expected one key naming a %s variant\"))\n", union.Name))
+		buf.WriteString("        }\n")
+		buf.WriteString("        switch key {\n")
+		for _, v := range union.Variants {
+			c := swiftVariantCase(v)
+			buf.WriteString(fmt.Sprintf("        case .%s: self = .%s(try container.decode(%s.self, forKey: .%s))\n", c, c, getSwiftTypeString(v), c))
+		}
+		buf.WriteString("        }\n")
+		buf.WriteString("    }\n\n")
+		buf.WriteString("    public func encode(to encoder: Encoder) throws {\n")
+		buf.WriteString("        var container = encoder.container(keyedBy: CodingKeys.self)\n")
+		buf.WriteString("        switch self {\n")
+		for _, v := range union.Variants {
+			c := swiftVariantCase(v)
+			buf.WriteString(fmt.Sprintf("        case .%s(let value): try container.encode(value, forKey: .%s)\n", c, c))
+		}
+		buf.WriteString("        }\n")
+		buf.WriteString("    }\n")
+		buf.WriteString("}\n\n")
+	}
+
+	// Structs get the synthesized conformance
+	rootMessageTypes := make(map[string]string)
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok {
+			rootMessageTypes[st.Name] = msg.Name + "Message"
+		}
+	}
+	for _, st := range topSortStructTypes(s.Types) {
+		name := st.Name
+		if msg, ok := rootMessageTypes[st.Name]; ok {
+			name = msg
+		}
+		buf.WriteString(fmt.Sprintf("extension %s: Codable {}\n", name))
+	}
+	buf.WriteString("\n")
+}
+
+// generateSwiftEnum declares enum with its base as the raw type, and a
+// decodeEnum helper that rejects wire values init(rawValue:) does not
+// know. Decoders call the helper because a field may share the enum's name.
+func generateSwiftEnum(buf *bytes.Buffer, enum *schema.EnumType) {
+	base := getSwiftPrimitiveType(enum.Base)
+	buf.WriteString(lineDoc("", "///", enum.Doc))
+	buf.WriteString(fmt.Sprintf("public enum %s: %s, %s {\n", enum.Name, base, swiftConformances))
+	for _, v := range enum.Values {
+		buf.WriteString(lineDoc("    ", "///", v.Doc))
+		buf.WriteString(fmt.Sprintf("    case %s = %d\n", v.Name, v.Value))
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString(fmt.Sprintf("func decodeEnum_%s(_ raw: %s) throws -> %s {\n", enum.Name, base, enum.Name))
+	buf.WriteString(fmt.Sprintf("    guard let value = %s(rawValue: raw) else { throw FFireError.invalidData }\n", enum.Name))
+	buf.WriteString("    return value\n")
+	buf.WriteString("}\n\n")
+}
+
+// generateSwiftUnion declares union as an enum with one case per variant,
+// each carrying the variant's value. A case's position is its wire tag.
+func generateSwiftUnion(buf *bytes.Buffer, union *schema.UnionType) {
+	buf.WriteString(lineDoc("", "///", union.Doc))
+	buf.WriteString(fmt.Sprintf("public enum %s: %s {\n", union.Name, swiftConformances))
+	for _, v := range union.Variants {
+		buf.WriteString(fmt.Sprintf("    case %s(%s)\n", swiftVariantCase(v), getSwiftTypeString(v)))
+	}
+	buf.WriteString("}\n\n")
+}
+
+// swiftVariantCase names the enum case holding a union variant.
+func swiftVariantCase(v schema.Type) string {
+	return ToCamelCase(v.TypeName())
+}
+
+func generateSwiftUnionHelpers(buf *bytes.Buffer, union *schema.UnionType) {
+	buf.WriteString("@inlinable\n")
+	buf.WriteString(fmt.Sprintf("func encodeUnion_%s(_ buffer: inout FFireWriter, _ value: %s) {\n", union.Name, union.Name))
+	buf.WriteString("    switch value {\n")
+	for i, v := range union.Variants {
+		buf.WriteString(fmt.Sprintf("    case .%s(let variant):\n", swiftVariantCase(v)))
+		buf.WriteString(fmt.Sprintf("        buffer.append(%d)\n", i+1))
+		generateSwiftEncodeValue(buf, v, "variant", "        ", 1)
+	}
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString(fmt.Sprintf("func sizeUnion_%s(_ value: %s) -> Int {\n", union.Name, union.Name))
+	buf.WriteString("    var size = 1\n")
+	buf.WriteString("    switch value {\n")
+	for _, v := range union.Variants {
+		if n := swiftFixedSize(v); n > 0 {
+			buf.WriteString(fmt.Sprintf("    case .%s:\n", swiftVariantCase(v)))
+			buf.WriteString(fmt.Sprintf("        size += %d\n", n))
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("    case .%s(let variant):\n", swiftVariantCase(v)))
+		generateSwiftSizeValue(buf, v, "variant", "        ", 1)
+	}
+	buf.WriteString("    }\n")
+	buf.WriteString("    return size\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString(fmt.Sprintf("func decodeUnion_%s(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> %s {\n", union.Name, union.Name))
+	buf.WriteString("    try need(base, pos, 1)\n")
+	buf.WriteString("    let tag = base[pos]\n")
+	buf.WriteString("    pos += 1\n")
+	buf.WriteString("    switch tag {\n")
+	for i, v := range union.Variants {
+		buf.WriteString(fmt.Sprintf("    case %d:\n", i+1))
+		generateSwiftDecodeValue(buf, v, "variant", "        ")
+		buf.WriteString(fmt.Sprintf("        return .%s(variant)\n", swiftVariantCase(v)))
+	}
+	buf.WriteString("    default:\n")
+	buf.WriteString("        throw FFireError.invalidData\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+}
+
 func generateSwiftMessageStruct(buf *bytes.Buffer, messageName string, structType *schema.StructType) {
 	structName := messageName + "Message"
-	buf.WriteString(fmt.Sprintf("public struct %s {\n", structName))
+	buf.WriteString(lineDoc("", "///", structType.Doc))
+	buf.WriteString(fmt.Sprintf("public struct %s: %s {\n", structName, swiftConformances))
 
 	for _, field := range structType.Fields {
 		swiftType := getSwiftTypeString(field.Type)
 		fieldName := escapeSwiftFieldName(field.Name)
+		buf.WriteString(lineDoc("    ", "///", field.Doc))
 		buf.WriteString(fmt.Sprintf("    public var %s: %s\n", fieldName, swiftType))
 	}
 
@@ -262,11 +421,13 @@ func generateSwiftMessageStruct(buf *bytes.Buffer, messageName string, structTyp
 }
 
 func generateSwiftStruct(buf *bytes.Buffer, structType *schema.StructType) {
-	buf.WriteString(fmt.Sprintf("public struct %s {\n", structType.Name))
+	buf.WriteString(lineDoc("", "///", structType.Doc))
+	buf.WriteString(fmt.Sprintf("public struct %s: %s {\n", structType.Name, swiftConformances))
 
 	for _, field := range structType.Fields {
 		swiftType := getSwiftTypeString(field.Type)
 		fieldName := escapeSwiftFieldName(field.Name)
+		buf.WriteString(lineDoc("    ", "///", field.Doc))
 		buf.WriteString(fmt.Sprintf("    public var %s: %s\n", fieldName, swiftType))
 	}
 
@@ -297,47 +458,23 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 
 	buf.WriteString("@inlinable\n")
 	buf.WriteString(fmt.Sprintf("public func %s(_ message: %s) -> Data {\n", funcName, structName))
-	buf.WriteString("    var buffer = [UInt8]()\n")
-	// Dynamic capacity based on message type
-	if arrayType, ok := msg.TargetType.(*schema.ArrayType); ok {
-		if primType, ok := arrayType.ElementType.(*schema.PrimitiveType); ok {
-			switch primType.Name {
-			case "int8", "bool":
-				buf.WriteString("    buffer.reserveCapacity(2 + message.count)\n")
-			case "int16":
-				buf.WriteString("    buffer.reserveCapacity(2 + message.count * 2)\n")
-			case "int32", "float32":
-				buf.WriteString("    buffer.reserveCapacity(2 + message.count * 4)\n")
-			case "int64", "float64":
-				buf.WriteString("    buffer.reserveCapacity(2 + message.count * 8)\n")
-			default:
-				// For strings, use heuristic based on average string length
-				buf.WriteString("    buffer.reserveCapacity(max(1024, message.count * 32))\n")
-			}
-		} else if structType, ok := arrayType.ElementType.(*schema.StructType); ok {
-			// For struct arrays, estimate based on struct field sizes
-			estimatedSize := estimateStructSize(structType)
-			buf.WriteString(fmt.Sprintf("    buffer.reserveCapacity(max(1024, message.count * %d))\n", estimatedSize))
-		} else {
-			// Fallback for other types
-			buf.WriteString("    buffer.reserveCapacity(max(1024, message.count * 64))\n")
-		}
-	} else if structType, ok := msg.TargetType.(*schema.StructType); ok {
-		// For struct messages, estimate based on struct field sizes
-		estimatedSize := estimateStructSize(structType)
-		buf.WriteString(fmt.Sprintf("    buffer.reserveCapacity(%d)\n", max(1024, estimatedSize)))
-	} else {
-		// Fallback
-		buf.WriteString("    buffer.reserveCapacity(1024)\n")
-	}
+	// The fast paths below fill a Data of their own and return it. Every
+	// other message is sized first and then written straight into storage
+	// that the returned Data takes over, so nothing is copied at the end.
+	out := buf
+	buf = &bytes.Buffer{}
+	fast := false
 
 	switch t := msg.TargetType.(type) {
 	case *schema.StructType:
 		// Sequential encoding - Swift's append is already optimized
-		for _, field := range t.Fields {
-			generateSwiftEncodeField(buf, field, "message."+field.Name)
-		}
+		generateSwiftEncodeFields(buf, t, "message")
 	case *schema.ArrayType:
+		if t.Large {
+			// The fast paths below all write a uint16 count
+			generateSwiftEncodeValue(buf, t, "message", "    ", 0)
+			break
+		}
 		// For array types, encode as array
 		buf.WriteString("    let len = UInt16(message.count)\n")
 		if primType, ok := t.ElementType.(*schema.PrimitiveType); ok {
@@ -362,6 +499,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
 			case "int32":
 				// Optimized: pre-allocated Data with memcpy for Int32 arrays
 				buf.WriteString("    let totalSize = 2 + message.count * 4\n")
@@ -374,6 +512,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
 			case "int64":
 				// Optimized: pre-allocated Data with memcpy for Int64 arrays
 				buf.WriteString("    let totalSize = 2 + message.count * 8\n")
@@ -386,6 +525,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
 			case "float32":
 				// Optimized: pre-allocated Data with memcpy for Float arrays
 				buf.WriteString("    let totalSize = 2 + message.count * 4\n")
@@ -398,6 +538,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
 			case "float64":
 				// Optimized: pre-allocated Data with memcpy for Double arrays
 				buf.WriteString("    let totalSize = 2 + message.count * 8\n")
@@ -410,6 +551,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
 			case "string":
 				// Two-pass encoding: calculate total size, then bulk write
 				buf.WriteString("    // Fast path: pre-calculate total size and write in one pass\n")
@@ -441,10 +583,14 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
+			case "bytes":
+				buf.WriteString("    withUnsafeBytes(of: len.littleEndian) { buffer.append(contentsOf: $0) }\n")
+				buf.WriteString("    for item in message { encodeBytes(&buffer, item) }\n")
 			}
 		} else if structType, ok := t.ElementType.(*schema.StructType); ok {
 			// Check if struct has only primitive fields (no strings, arrays, or nested structs)
-			hasOnlyPrimitives := true
+			hasOnlyPrimitives := schema.BitmapSize(structType) == 0
 			fixedSize := 0
 			for _, field := range structType.Fields {
 				if primType, ok := field.Type.(*schema.PrimitiveType); ok {
@@ -457,7 +603,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 						fixedSize += 4
 					case "int64", "float64":
 						fixedSize += 8
-					case "string":
+					case "string", "bytes":
 						hasOnlyPrimitives = false
 					}
 				} else {
@@ -508,6 +654,7 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        }\n")
 				buf.WriteString("    }\n")
 				buf.WriteString("    return result\n")
+				fast = true
 			} else {
 				// Check if struct has only non-optional primitives and strings (no arrays, nested structs, or optionals)
 				hasOnlySimpleFields := true
@@ -515,8 +662,8 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				fixedSize := 0
 				for _, field := range structType.Fields {
 					if primType, ok := field.Type.(*schema.PrimitiveType); ok {
-						// Skip optional fields - they have variable encoding
-						if primType.Optional {
+						// Skip optional, @cached and @large fields - they have variable encoding
+						if primType.Optional || field.Cached || primType.Large {
 							hasOnlySimpleFields = false
 							continue
 						}
@@ -532,6 +679,8 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 						case "string":
 							fixedSize += 2 // length prefix
 							stringFields = append(stringFields, field.Name)
+						case "bytes":
+							hasOnlySimpleFields = false
 						}
 					} else {
 						hasOnlySimpleFields = false
@@ -597,12 +746,13 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 					buf.WriteString("        }\n")
 					buf.WriteString("    }\n")
 					buf.WriteString("    return result\n")
+					fast = true
 				} else {
 					// Check if struct has only primitives/strings with some optionals (no arrays or nested structs)
-					hasOnlyPrimitivesAndOptionals := true
+					hasOnlyPrimitivesAndOptionals := schema.BitmapSize(structType) == 0
 					hasOptionals := false
 					for _, field := range structType.Fields {
-						if primType, ok := field.Type.(*schema.PrimitiveType); ok {
+						if primType, ok := field.Type.(*schema.PrimitiveType); ok && primType.Name != "bytes" && !field.Cached && !primType.Large {
 							if primType.Optional {
 								hasOptionals = true
 							}
@@ -781,20 +931,183 @@ func generateSwiftEncoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 						buf.WriteString("        }\n")
 						buf.WriteString("    }\n")
 						buf.WriteString("    return result\n")
+						fast = true
 					} else {
 						// Fallback: structs with arrays or nested structs
 						buf.WriteString(fmt.Sprintf("    withUnsafeBytes(of: len.littleEndian) { buffer.append(contentsOf: $0) }\n    for item in message { encodeStruct_%s(&buffer, item) }\n", structType.Name))
 					}
 				}
 			}
+		} else if swiftUsesValueCodec(t.ElementType) {
+			buf.WriteString("    withUnsafeBytes(of: len.littleEndian) { buffer.append(contentsOf: $0) }\n")
+			buf.WriteString("    for item in message {\n")
+			generateSwiftEncodeValue(buf, t.ElementType, "item", "        ", 0)
+			buf.WriteString("    }\n")
 		}
 	}
 
-	buf.WriteString("    return Data(buffer)\n")
-	buf.WriteString("}\n\n")
+	if fast {
+		out.Write(buf.Bytes())
+		out.WriteString("}\n\n")
+		return
+	}
+
+	out.WriteString("    var size = 0\n")
+	switch t := msg.TargetType.(type) {
+	case *schema.StructType:
+		generateSwiftSizeFields(out, t, "message")
+	case *schema.ArrayType:
+		generateSwiftSizeValue(out, t, "message", "    ", 0)
+	}
+	out.WriteString("    let storage = UnsafeMutableRawPointer.allocate(byteCount: max(size, 1), alignment: 1)\n")
+	out.WriteString("    var buffer = FFireWriter(storage)\n")
+	out.Write(buf.Bytes())
+	out.WriteString("    assert(buffer.count == size)\n")
+	out.WriteString("    return Data(bytesNoCopy: storage, count: size, deallocator: .custom { ptr, _ in ptr.deallocate() })\n")
+	out.WriteString("}\n\n")
+}
+
+// swiftUsesValueCodec reports whether values of t are coded by
+// generateSwiftEncodeValue and generateSwiftDecodeValue rather than the
+// specialized field paths: maps, enums, unions and arrays of them.
+func swiftUsesValueCodec(t schema.Type) bool {
+	switch t := t.(type) {
+	case *schema.MapType, *schema.EnumType, *schema.UnionType:
+		return true
+	case *schema.ArrayType:
+		return swiftUsesValueCodec(t.ElementType)
+	}
+	return false
+}
+
+// The optional fields of a @bitmap struct have their presence in a bitmap
+// of bytes bits0, bits1, ... ahead of the fields instead of presence bytes.
+// Their values are coded as if they were not optional, inside a branch on
+// their bit.
+
+// generateSwiftEncodeFields encodes the fields of st, read from value.
+func generateSwiftEncodeFields(buf *bytes.Buffer, st *schema.StructType, value string) {
+	bits := swiftBitmapBits(st)
+	for i := 0; i < schema.BitmapSize(st); i++ {
+		buf.WriteString(fmt.Sprintf("    var bits%d: UInt8 = 0\n", i))
+		for _, field := range st.Fields {
+			if bit, ok := bits[field.Name]; ok && bit/8 == i {
+				buf.WriteString(fmt.Sprintf("    if %s.%s != nil { bits%d |= 0x%02x }\n", value, field.Name, i, 1<<(bit%8)))
+			}
+		}
+		buf.WriteString(fmt.Sprintf("    buffer.append(bits%d)\n", i))
+	}
+	for _, field := range st.Fields {
+		accessor := value + "." + field.Name
+		if _, ok := bits[field.Name]; !ok {
+			generateSwiftEncodeField(buf, field, accessor)
+			continue
+		}
+		var inner bytes.Buffer
+		required := field
+		required.Type = schema.WithoutPresence(field.Type)
+		generateSwiftEncodeField(&inner, required, "unwrapped")
+		buf.WriteString(fmt.Sprintf("    if let unwrapped = %s {\n", accessor))
+		buf.WriteString(indentCode(inner.String(), "    "))
+		buf.WriteString("    }\n")
+	}
+}
+
+// generateSwiftSizeFields adds the encoded size of the fields of st, read
+// from value, to size.
+func generateSwiftSizeFields(buf *bytes.Buffer, st *schema.StructType, value string) {
+	bits := swiftBitmapBits(st)
+	if n := schema.BitmapSize(st); n > 0 {
+		buf.WriteString(fmt.Sprintf("    size += %d\n", n))
+	}
+	for _, field := range st.Fields {
+		accessor := value + "." + field.Name
+		if _, ok := bits[field.Name]; !ok {
+			generateSwiftSizeValue(buf, field.Type, accessor, "    ", 0)
+			continue
+		}
+		if n := swiftFixedSize(field.Type); n > 0 {
+			buf.WriteString(fmt.Sprintf("    if %s != nil { size += %d }\n", accessor, n))
+			continue
+		}
+		buf.WriteString(fmt.Sprintf("    if let unwrapped = %s {\n", accessor))
+		generateSwiftSizeValue(buf, schema.WithoutPresence(field.Type), "unwrapped", "        ", 1)
+		buf.WriteString("    }\n")
+	}
+}
+
+// generateSwiftDecodeFields decodes the fields of st into locals named
+// after them, rejecting set unused bitmap bits.
+func generateSwiftDecodeFields(buf *bytes.Buffer, st *schema.StructType) {
+	bits := swiftBitmapBits(st)
+	n := schema.BitmapSize(st)
+	if n > 0 {
+		buf.WriteString(fmt.Sprintf("        try need(base, pos, %d)\n", n))
+	}
+	for i := 0; i < n; i++ {
+		buf.WriteString(fmt.Sprintf("        let bits%d = base[pos]\n", i))
+		buf.WriteString("        pos += 1\n")
+	}
+	if used := schema.OptionalFields(st) % 8; n > 0 && used != 0 {
+		buf.WriteString(fmt.Sprintf("        if bits%d & 0x%02x != 0 { throw FFireError.badPresence }\n", n-1, 0xff<<used&0xff))
+	}
+	for _, field := range st.Fields {
+		bit, ok := bits[field.Name]
+		if !ok {
+			generateSwiftDecodeField(buf, field)
+			continue
+		}
+		var inner bytes.Buffer
+		required := field
+		required.Name = field.Name + "Value"
+		required.Type = schema.WithoutPresence(field.Type)
+		generateSwiftDecodeField(&inner, required)
+		buf.WriteString(fmt.Sprintf("        let %s: %s\n", field.Name, getSwiftTypeString(field.Type)))
+		buf.WriteString(fmt.Sprintf("        if bits%d & 0x%02x != 0 {\n", bit/8, 1<<(bit%8)))
+		buf.WriteString(indentCode(inner.String(), "    "))
+		buf.WriteString(fmt.Sprintf("            %s = %sValue\n", field.Name, field.Name))
+		buf.WriteString("        } else {\n")
+		buf.WriteString(fmt.Sprintf("            %s = nil\n", field.Name))
+		buf.WriteString("        }\n")
+	}
+}
+
+// swiftBitmapBits returns the bitmap bit of each optional field of a
+// @bitmap struct, by field name, or nil for other structs.
+func swiftBitmapBits(st *schema.StructType) map[string]int {
+	if !st.Bitmap {
+		return nil
+	}
+	bits := make(map[string]int)
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			bits[field.Name] = len(bits)
+		}
+	}
+	return bits
+}
+
+// indentCode prefixes each line of code with indent.
+func indentCode(code, indent string) string {
+	lines := strings.SplitAfter(code, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "")
 }
 
 func generateSwiftEncodeField(buf *bytes.Buffer, field schema.Field, accessor string) {
+	if field.Cached {
+		generateSwiftEncodeCachedField(buf, field.Type, accessor)
+		return
+	}
+	if swiftUsesValueCodec(field.Type) {
+		generateSwiftEncodeValue(buf, field.Type, accessor, "    ", 0)
+		return
+	}
+
 	// Check if optional
 	isOptional := field.Type.IsOptional()
 
@@ -802,23 +1115,25 @@ func generateSwiftEncodeField(buf *bytes.Buffer, field schema.Field, accessor st
 	if isOptional {
 		switch t := field.Type.(type) {
 		case *schema.PrimitiveType:
-			switch t.Name {
-			case "bool":
+			switch {
+			case t.Large:
+				// Written by the fallback below
+			case t.Name == "bool":
 				buf.WriteString(fmt.Sprintf("    writeOptionalBool(&buffer, %s)\n", accessor))
 				return
-			case "int32":
+			case t.Name == "int32":
 				buf.WriteString(fmt.Sprintf("    writeOptionalInt32(&buffer, %s)\n", accessor))
 				return
-			case "int64":
+			case t.Name == "int64":
 				buf.WriteString(fmt.Sprintf("    writeOptionalInt64(&buffer, %s)\n", accessor))
 				return
-			case "float32":
+			case t.Name == "float32":
 				buf.WriteString(fmt.Sprintf("    writeOptionalFloat(&buffer, %s)\n", accessor))
 				return
-			case "float64":
+			case t.Name == "float64":
 				buf.WriteString(fmt.Sprintf("    writeOptionalDouble(&buffer, %s)\n", accessor))
 				return
-			case "string":
+			case t.Name == "string":
 				buf.WriteString(fmt.Sprintf("    writeOptionalString(&buffer, %s)\n", accessor))
 				return
 			}
@@ -834,7 +1149,11 @@ func generateSwiftEncodeField(buf *bytes.Buffer, field schema.Field, accessor st
 
 	switch t := field.Type.(type) {
 	case *schema.PrimitiveType:
-		generateSwiftEncodePrimitive(buf, t.Name, accessor)
+		if t.Large {
+			buf.WriteString(fmt.Sprintf("    encodeLarge%s(&buffer, %s)\n", swiftLargeSuffix(t), accessor))
+		} else {
+			generateSwiftEncodePrimitive(buf, t.Name, accessor)
+		}
 	case *schema.ArrayType:
 		generateSwiftEncodeArray(buf, t, accessor)
 	case *schema.StructType:
@@ -848,6 +1167,27 @@ func generateSwiftEncodeField(buf *bytes.Buffer, field schema.Field, accessor st
 	}
 }
 
+// generateSwiftEncodeCachedField encodes a @cached string or string array
+// field through the string cache.
+func generateSwiftEncodeCachedField(buf *bytes.Buffer, typ schema.Type, accessor string) {
+	if typ.IsOptional() {
+		buf.WriteString(fmt.Sprintf("    if let unwrapped = %s {\n", accessor))
+		buf.WriteString("        buffer.append(1) // present\n")
+		accessor = "unwrapped"
+	}
+	if _, ok := typ.(*schema.ArrayType); ok {
+		buf.WriteString(fmt.Sprintf("    withUnsafeBytes(of: UInt16(%s.count).littleEndian) { buffer.append(contentsOf: $0) }\n", accessor))
+		buf.WriteString(fmt.Sprintf("    for item in %s { encodeCachedString(&buffer, item) }\n", accessor))
+	} else {
+		buf.WriteString(fmt.Sprintf("    encodeCachedString(&buffer, %s)\n", accessor))
+	}
+	if typ.IsOptional() {
+		buf.WriteString("    } else {\n")
+		buf.WriteString("        buffer.append(0) // absent\n")
+		buf.WriteString("    }\n")
+	}
+}
+
 func generateSwiftEncodePrimitive(buf *bytes.Buffer, typeName string, accessor string) {
 	switch typeName {
 	case "bool":
@@ -866,6 +1206,8 @@ func generateSwiftEncodePrimitive(buf *bytes.Buffer, typeName string, accessor s
 		buf.WriteString(fmt.Sprintf("    withUnsafeBytes(of: %s.bitPattern.littleEndian) { buffer.append(contentsOf: $0) }\n", accessor))
 	case "string":
 		buf.WriteString(fmt.Sprintf("    encodeString(&buffer, %s)\n", accessor))
+	case "bytes":
+		buf.WriteString(fmt.Sprintf("    encodeBytes(&buffer, %s)\n", accessor))
 	}
 }
 
@@ -899,12 +1241,13 @@ func generateSwiftBulkEncode(buf *bytes.Buffer, fields []schema.Field, totalByte
 		}
 	}
 
-	buf.WriteString(fmt.Sprintf("%sbuffer.append(contentsOf: fixedBuf)\n", indent))
+	buf.WriteString(fmt.Sprintf("%sfixedBuf.withUnsafeBytes { buffer.append(contentsOf: $0) }\n", indent))
 }
 
 // generateSwiftBulkDecode generates bulk decoding for a run of fixed-size fields
 func generateSwiftBulkDecode(buf *bytes.Buffer, fields []schema.Field, totalBytes int, indent string) {
 	buf.WriteString(fmt.Sprintf("%s// Bulk decode %d bytes of fixed-size fields\n", indent, totalBytes))
+	buf.WriteString(fmt.Sprintf("%stry need(base, pos, %d)\n", indent, totalBytes))
 
 	offset := 0
 	for _, field := range fields {
@@ -913,7 +1256,8 @@ func generateSwiftBulkDecode(buf *bytes.Buffer, fields []schema.Field, totalByte
 			size := schema.GetPrimitiveSize(primType)
 			switch primType.Name {
 			case "bool":
-				buf.WriteString(fmt.Sprintf("%slet %s = base.load(fromByteOffset: pos + %d, as: UInt8.self) != 0\n", indent, varName, offset))
+				buf.WriteString(fmt.Sprintf("%sif base[pos + %d] > 1 { throw FFireError.invalidData }\n", indent, offset))
+				buf.WriteString(fmt.Sprintf("%slet %s = base[pos + %d] == 1\n", indent, varName, offset))
 			case "int8":
 				buf.WriteString(fmt.Sprintf("%slet %s = base.load(fromByteOffset: pos + %d, as: Int8.self)\n", indent, varName, offset))
 			case "int16":
@@ -934,8 +1278,13 @@ func generateSwiftBulkDecode(buf *bytes.Buffer, fields []schema.Field, totalByte
 }
 
 func generateSwiftEncodeArray(buf *bytes.Buffer, arrayType *schema.ArrayType, accessor string) {
-	buf.WriteString(fmt.Sprintf("    let len = UInt16(%s.count)\n", accessor))
-	buf.WriteString("    withUnsafeBytes(of: len.littleEndian) { buffer.append(contentsOf: $0) }\n")
+	if arrayType.Length > 0 {
+		// Fixed-size arrays have no length prefix; the count must match the schema
+		buf.WriteString(fmt.Sprintf("    precondition(%s.count == %d, \"%s must hold %d elements\")\n", accessor, arrayType.Length, accessor, arrayType.Length))
+	} else {
+		buf.WriteString(fmt.Sprintf("    let len = %s(%s.count)\n", swiftLengthType(arrayType), accessor))
+		buf.WriteString("    withUnsafeBytes(of: len.littleEndian) { buffer.append(contentsOf: $0) }\n")
+	}
 
 	if primType, ok := arrayType.ElementType.(*schema.PrimitiveType); ok {
 		switch primType.Name {
@@ -962,6 +1311,8 @@ func generateSwiftEncodeArray(buf *bytes.Buffer, arrayType *schema.ArrayType, ac
 			buf.WriteString(fmt.Sprintf("    %s.withUnsafeBytes { buffer.append(contentsOf: $0) }\n", accessor))
 		case "string":
 			buf.WriteString(fmt.Sprintf("    for item in %s { encodeString(&buffer, item) }\n", accessor))
+		case "bytes":
+			buf.WriteString(fmt.Sprintf("    for item in %s { encodeBytes(&buffer, item) }\n", accessor))
 		}
 	} else if structType, ok := arrayType.ElementType.(*schema.StructType); ok {
 		buf.WriteString(fmt.Sprintf("    for item in %s { encodeStruct_%s(&buffer, item) }\n", accessor, structType.Name))
@@ -974,16 +1325,13 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 
 	buf.WriteString("@inlinable\n")
 	buf.WriteString(fmt.Sprintf("public func %s(_ data: Data) throws -> %s {\n", funcName, structName))
-	buf.WriteString("    return try data.withUnsafeBytes { (ptr: UnsafeRawBufferPointer) in\n")
-	buf.WriteString("        guard let base = ptr.baseAddress else { throw FFireError.invalidData }\n")
-	buf.WriteString("        var pos = 0\n")
+	buf.WriteString("    var pos = 0\n")
+	buf.WriteString(fmt.Sprintf("    let message = try data.withUnsafeBytes { (base: UnsafeRawBufferPointer) -> %s in\n", structName))
 
 	switch t := msg.TargetType.(type) {
 	case *schema.StructType:
 		// Sequential decoding - direct memory access is already efficient
-		for _, field := range t.Fields {
-			generateSwiftDecodeField(buf, field)
-		}
+		generateSwiftDecodeFields(buf, t)
 
 		buf.WriteString(fmt.Sprintf("        return %s(\n", structName))
 		for i, field := range t.Fields {
@@ -997,31 +1345,27 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 		buf.WriteString("        )\n")
 	case *schema.ArrayType:
 		// Decode array
-		buf.WriteString("        let len = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n")
-		buf.WriteString("        pos += 2\n")
+		generateSwiftReadLength(buf, t, "len", "        ")
 		if primType, ok := t.ElementType.(*schema.PrimitiveType); ok {
 			switch primType.Name {
 			case "bool":
 				// Bool arrays need element-by-element conversion
-				buf.WriteString("        return (0..<len).map { _ in\n")
-				buf.WriteString("            let v = base.load(fromByteOffset: pos, as: UInt8.self) != 0\n")
-				buf.WriteString("            pos += 1\n")
+				buf.WriteString("        return try (0..<len).map { _ in\n")
+				buf.WriteString("            let v = try readBool(base, &pos)\n")
 				buf.WriteString("            return v\n")
 				buf.WriteString("        }\n")
 			case "int8":
 				// Int8 arrays need element-by-element read
-				buf.WriteString("        return (0..<len).map { _ in\n")
-				buf.WriteString("            let v = base.load(fromByteOffset: pos, as: Int8.self)\n")
-				buf.WriteString("            pos += 1\n")
+				buf.WriteString("        return try (0..<len).map { _ in\n")
+				buf.WriteString("            let v = try readInt8(base, &pos)\n")
 				buf.WriteString("            return v\n")
 				buf.WriteString("        }\n")
 			case "int16":
 				// Bulk copy for Int16 arrays (little-endian platforms)
 				buf.WriteString("        let byteCount = len * MemoryLayout<Int16>.stride\n")
+				buf.WriteString("        try need(base, pos, byteCount)\n")
 				buf.WriteString("        let result = [Int16](unsafeUninitializedCapacity: len) { buffer, initializedCount in\n")
-				buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-				buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-				buf.WriteString("            dst.copyMemory(from: src, byteCount: byteCount)\n")
+				buf.WriteString("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + byteCount]))\n")
 				buf.WriteString("            initializedCount = len\n")
 				buf.WriteString("        }\n")
 				buf.WriteString("        pos += byteCount\n")
@@ -1029,10 +1373,9 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 			case "int32":
 				// Bulk copy for Int32 arrays (little-endian platforms)
 				buf.WriteString("        let byteCount = len * MemoryLayout<Int32>.stride\n")
+				buf.WriteString("        try need(base, pos, byteCount)\n")
 				buf.WriteString("        let result = [Int32](unsafeUninitializedCapacity: len) { buffer, initializedCount in\n")
-				buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-				buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-				buf.WriteString("            dst.copyMemory(from: src, byteCount: byteCount)\n")
+				buf.WriteString("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + byteCount]))\n")
 				buf.WriteString("            initializedCount = len\n")
 				buf.WriteString("        }\n")
 				buf.WriteString("        pos += byteCount\n")
@@ -1040,10 +1383,9 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 			case "int64":
 				// Bulk copy for Int64 arrays (little-endian platforms)
 				buf.WriteString("        let byteCount = len * MemoryLayout<Int64>.stride\n")
+				buf.WriteString("        try need(base, pos, byteCount)\n")
 				buf.WriteString("        let result = [Int64](unsafeUninitializedCapacity: len) { buffer, initializedCount in\n")
-				buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-				buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-				buf.WriteString("            dst.copyMemory(from: src, byteCount: byteCount)\n")
+				buf.WriteString("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + byteCount]))\n")
 				buf.WriteString("            initializedCount = len\n")
 				buf.WriteString("        }\n")
 				buf.WriteString("        pos += byteCount\n")
@@ -1051,10 +1393,9 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 			case "float32":
 				// Bulk copy for Float arrays (little-endian platforms, IEEE 754)
 				buf.WriteString("        let byteCount = len * MemoryLayout<Float>.stride\n")
+				buf.WriteString("        try need(base, pos, byteCount)\n")
 				buf.WriteString("        let result = [Float](unsafeUninitializedCapacity: len) { buffer, initializedCount in\n")
-				buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-				buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-				buf.WriteString("            dst.copyMemory(from: src, byteCount: byteCount)\n")
+				buf.WriteString("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + byteCount]))\n")
 				buf.WriteString("            initializedCount = len\n")
 				buf.WriteString("        }\n")
 				buf.WriteString("        pos += byteCount\n")
@@ -1062,10 +1403,9 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 			case "float64":
 				// Bulk copy for Double arrays (little-endian platforms, IEEE 754)
 				buf.WriteString("        let byteCount = len * MemoryLayout<Double>.stride\n")
+				buf.WriteString("        try need(base, pos, byteCount)\n")
 				buf.WriteString("        let result = [Double](unsafeUninitializedCapacity: len) { buffer, initializedCount in\n")
-				buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-				buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-				buf.WriteString("            dst.copyMemory(from: src, byteCount: byteCount)\n")
+				buf.WriteString("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + byteCount]))\n")
 				buf.WriteString("            initializedCount = len\n")
 				buf.WriteString("        }\n")
 				buf.WriteString("        pos += byteCount\n")
@@ -1075,44 +1415,64 @@ func generateSwiftDecoderFunc(buf *bytes.Buffer, msg schema.MessageType) {
 				buf.WriteString("        var result = [String]()\n")
 				buf.WriteString("        result.reserveCapacity(len)\n")
 				buf.WriteString("        for _ in 0..<len {\n")
-				buf.WriteString("            let strLen = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n")
-				buf.WriteString("            pos += 2\n")
-				buf.WriteString("            let str = String(decoding: UnsafeBufferPointer(start: base.advanced(by: pos).assumingMemoryBound(to: UInt8.self), count: strLen), as: UTF8.self)\n")
+				buf.WriteString("            let str = try decodeString(base, &pos)\n")
 				buf.WriteString("            result.append(str)\n")
-				buf.WriteString("            pos += strLen\n")
+				buf.WriteString("        }\n")
+				buf.WriteString("        return result\n")
+			case "bytes":
+				buf.WriteString("        var result = [Data]()\n")
+				buf.WriteString("        result.reserveCapacity(len)\n")
+				buf.WriteString("        for _ in 0..<len {\n")
+				buf.WriteString("            result.append(try decodeBytes(base, &pos))\n")
 				buf.WriteString("        }\n")
 				buf.WriteString("        return result\n")
 			}
 		} else if structType, ok := t.ElementType.(*schema.StructType); ok {
 			buf.WriteString(fmt.Sprintf("        return try (0..<len).map { _ in try decodeStruct_%s(base, &pos) }\n", structType.Name))
+		} else if swiftUsesValueCodec(t.ElementType) {
+			buf.WriteString(fmt.Sprintf("        var result = %s()\n", getSwiftTypeString(t)))
+			buf.WriteString("        result.reserveCapacity(len)\n")
+			buf.WriteString("        for _ in 0..<len {\n")
+			generateSwiftDecodeValue(buf, t.ElementType, "item", "            ")
+			buf.WriteString("            result.append(item)\n")
+			buf.WriteString("        }\n")
+			buf.WriteString("        return result\n")
 		}
 	}
 
 	buf.WriteString("    }\n")
+	buf.WriteString("    if pos != data.count { throw FFireError.trailingData }\n")
+	buf.WriteString("    return message\n")
 	buf.WriteString("}\n\n")
 }
 
 func generateSwiftDecodeField(buf *bytes.Buffer, field schema.Field) {
 	varName := field.Name
+	if swiftUsesValueCodec(field.Type) {
+		generateSwiftDecodeValue(buf, field.Type, varName, "        ")
+		return
+	}
 	isOptional := field.Type.IsOptional()
 
 	// For optional primitives and strings, use dedicated helper functions
 	if isOptional {
 		switch t := field.Type.(type) {
 		case *schema.PrimitiveType:
-			switch t.Name {
-			case "bool":
-				buf.WriteString(fmt.Sprintf("        let %s = readOptionalBool(base, &pos)\n", varName))
-			case "int32":
-				buf.WriteString(fmt.Sprintf("        let %s = readOptionalInt32(base, &pos)\n", varName))
-			case "int64":
-				buf.WriteString(fmt.Sprintf("        let %s = readOptionalInt64(base, &pos)\n", varName))
-			case "float32":
-				buf.WriteString(fmt.Sprintf("        let %s = readOptionalFloat(base, &pos)\n", varName))
-			case "float64":
-				buf.WriteString(fmt.Sprintf("        let %s = readOptionalDouble(base, &pos)\n", varName))
-			case "string":
-				buf.WriteString(fmt.Sprintf("        let %s = readOptionalString(base, &pos)\n", varName))
+			switch {
+			case t.Large:
+				generateSwiftDecodeOptionalFallback(buf, field)
+			case t.Name == "bool":
+				buf.WriteString(fmt.Sprintf("        let %s = try readOptionalBool(base, &pos)\n", varName))
+			case t.Name == "int32":
+				buf.WriteString(fmt.Sprintf("        let %s = try readOptionalInt32(base, &pos)\n", varName))
+			case t.Name == "int64":
+				buf.WriteString(fmt.Sprintf("        let %s = try readOptionalInt64(base, &pos)\n", varName))
+			case t.Name == "float32":
+				buf.WriteString(fmt.Sprintf("        let %s = try readOptionalFloat(base, &pos)\n", varName))
+			case t.Name == "float64":
+				buf.WriteString(fmt.Sprintf("        let %s = try readOptionalDouble(base, &pos)\n", varName))
+			case t.Name == "string":
+				buf.WriteString(fmt.Sprintf("        let %s = try readOptionalString(base, &pos)\n", varName))
 			default:
 				// Fallback for int8, int16 - use branching approach
 				generateSwiftDecodeOptionalFallback(buf, field)
@@ -1123,15 +1483,14 @@ func generateSwiftDecodeField(buf *bytes.Buffer, field schema.Field) {
 
 	// Non-optional primitives and strings, or types that need branching
 	if isOptional {
-		buf.WriteString(fmt.Sprintf("        let %sPresent = base.load(fromByteOffset: pos, as: UInt8.self) != 0\n", varName))
-		buf.WriteString("        pos += 1\n")
+		buf.WriteString(fmt.Sprintf("        let %sPresent = try readPresence(base, &pos)\n", varName))
 		buf.WriteString(fmt.Sprintf("        let %s: %s\n", varName, getSwiftTypeString(field.Type)))
 		buf.WriteString(fmt.Sprintf("        if %sPresent {\n", varName))
 	}
 
 	switch t := field.Type.(type) {
 	case *schema.PrimitiveType:
-		generateSwiftDecodePrimitive(buf, t.Name, varName)
+		generateSwiftDecodeFieldPrimitive(buf, t, varName)
 	case *schema.ArrayType:
 		if isOptional {
 			generateSwiftDecodeArray(buf, t, varName+"Value")
@@ -1157,13 +1516,12 @@ func generateSwiftDecodeField(buf *bytes.Buffer, field schema.Field) {
 
 func generateSwiftDecodeOptionalFallback(buf *bytes.Buffer, field schema.Field) {
 	varName := field.Name
-	buf.WriteString(fmt.Sprintf("        let %sPresent = base.load(fromByteOffset: pos, as: UInt8.self) != 0\n", varName))
-	buf.WriteString("        pos += 1\n")
+	buf.WriteString(fmt.Sprintf("        let %sPresent = try readPresence(base, &pos)\n", varName))
 	buf.WriteString(fmt.Sprintf("        let %s: %s\n", varName, getSwiftTypeString(field.Type)))
 	buf.WriteString(fmt.Sprintf("        if %sPresent {\n", varName))
 
 	if t, ok := field.Type.(*schema.PrimitiveType); ok {
-		generateSwiftDecodePrimitive(buf, t.Name, varName+"Value")
+		generateSwiftDecodeFieldPrimitive(buf, t, varName+"Value")
 		buf.WriteString(fmt.Sprintf("            %s = %sValue\n", varName, varName))
 	}
 
@@ -1172,95 +1530,103 @@ func generateSwiftDecodeOptionalFallback(buf *bytes.Buffer, field schema.Field)
 	buf.WriteString("        }\n")
 }
 
+// generateSwiftDecodeFieldPrimitive decodes a primitive field, reading a
+// uint32 length prefix for @large strings and bytes.
+func generateSwiftDecodeFieldPrimitive(buf *bytes.Buffer, t *schema.PrimitiveType, varName string) {
+	if !t.Large {
+		generateSwiftDecodePrimitive(buf, t.Name, varName)
+		return
+	}
+	buf.WriteString(fmt.Sprintf("        let %s = try decodeLarge%s(base, &pos)\n", varName, swiftLargeSuffix(t)))
+}
+
 func generateSwiftDecodePrimitive(buf *bytes.Buffer, typeName string, varName string) {
 	switch typeName {
 	case "bool":
-		buf.WriteString(fmt.Sprintf("        let %s = readBool(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readBool(base, &pos)\n", varName))
 	case "int8":
-		buf.WriteString(fmt.Sprintf("        let %s = readInt8(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readInt8(base, &pos)\n", varName))
 	case "int16":
-		buf.WriteString(fmt.Sprintf("        let %s = readInt16(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readInt16(base, &pos)\n", varName))
 	case "int32":
-		buf.WriteString(fmt.Sprintf("        let %s = readInt32(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readInt32(base, &pos)\n", varName))
 	case "int64":
-		buf.WriteString(fmt.Sprintf("        let %s = readInt64(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readInt64(base, &pos)\n", varName))
 	case "float32":
-		buf.WriteString(fmt.Sprintf("        let %s = readFloat(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readFloat(base, &pos)\n", varName))
 	case "float64":
-		buf.WriteString(fmt.Sprintf("        let %s = readDouble(base, &pos)\n", varName))
+		buf.WriteString(fmt.Sprintf("        let %s = try readDouble(base, &pos)\n", varName))
 	case "string":
 		buf.WriteString(fmt.Sprintf("        let %s = try decodeString(base, &pos)\n", varName))
+	case "bytes":
+		buf.WriteString(fmt.Sprintf("        let %s = try decodeBytes(base, &pos)\n", varName))
 	}
 }
 
 func generateSwiftDecodeArray(buf *bytes.Buffer, arrayType *schema.ArrayType, varName string) {
 	elemSwiftType := getSwiftTypeString(arrayType.ElementType)
-	buf.WriteString(fmt.Sprintf("        let %sLen = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n", varName))
-	buf.WriteString("        pos += 2\n")
+	if arrayType.Length > 0 {
+		buf.WriteString(fmt.Sprintf("        let %sLen = %d\n", varName, arrayType.Length))
+	} else {
+		generateSwiftReadLength(buf, arrayType, varName+"Len", "        ")
+	}
 
 	if primType, ok := arrayType.ElementType.(*schema.PrimitiveType); ok {
 		switch primType.Name {
 		case "bool":
 			// Bool arrays need element-by-element conversion (UInt8 to Bool)
-			buf.WriteString(fmt.Sprintf("        let %s: [Bool] = (0..<%sLen).map { _ in\n", varName, varName))
-			buf.WriteString("            let v = base.load(fromByteOffset: pos, as: UInt8.self) != 0\n")
-			buf.WriteString("            pos += 1\n")
+			buf.WriteString(fmt.Sprintf("        let %s: [Bool] = try (0..<%sLen).map { _ in\n", varName, varName))
+			buf.WriteString("            let v = try readBool(base, &pos)\n")
 			buf.WriteString("            return v\n")
 			buf.WriteString("        }\n")
 		case "int8":
 			// Int8 arrays need element-by-element read
-			buf.WriteString(fmt.Sprintf("        let %s: [Int8] = (0..<%sLen).map { _ in\n", varName, varName))
-			buf.WriteString("            let v = base.load(fromByteOffset: pos, as: Int8.self)\n")
-			buf.WriteString("            pos += 1\n")
+			buf.WriteString(fmt.Sprintf("        let %s: [Int8] = try (0..<%sLen).map { _ in\n", varName, varName))
+			buf.WriteString("            let v = try readInt8(base, &pos)\n")
 			buf.WriteString("            return v\n")
 			buf.WriteString("        }\n")
 		case "int16":
 			// Bulk copy for Int16 arrays (little-endian platforms)
 			buf.WriteString(fmt.Sprintf("        let %sByteCount = %sLen * MemoryLayout<Int16>.stride\n", varName, varName))
+			buf.WriteString(fmt.Sprintf("        try need(base, pos, %sByteCount)\n", varName))
 			buf.WriteString(fmt.Sprintf("        let %s = [Int16](unsafeUninitializedCapacity: %sLen) { buffer, initializedCount in\n", varName, varName))
-			buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-			buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-			buf.WriteString(fmt.Sprintf("            dst.copyMemory(from: src, byteCount: %sByteCount)\n", varName))
+			buf.WriteString(fmt.Sprintf("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + %sByteCount]))\n", varName))
 			buf.WriteString(fmt.Sprintf("            initializedCount = %sLen\n", varName))
 			buf.WriteString("        }\n")
 			buf.WriteString(fmt.Sprintf("        pos += %sByteCount\n", varName))
 		case "int32":
 			// Bulk copy for Int32 arrays (little-endian platforms)
 			buf.WriteString(fmt.Sprintf("        let %sByteCount = %sLen * MemoryLayout<Int32>.stride\n", varName, varName))
+			buf.WriteString(fmt.Sprintf("        try need(base, pos, %sByteCount)\n", varName))
 			buf.WriteString(fmt.Sprintf("        let %s = [Int32](unsafeUninitializedCapacity: %sLen) { buffer, initializedCount in\n", varName, varName))
-			buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-			buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-			buf.WriteString(fmt.Sprintf("            dst.copyMemory(from: src, byteCount: %sByteCount)\n", varName))
+			buf.WriteString(fmt.Sprintf("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + %sByteCount]))\n", varName))
 			buf.WriteString(fmt.Sprintf("            initializedCount = %sLen\n", varName))
 			buf.WriteString("        }\n")
 			buf.WriteString(fmt.Sprintf("        pos += %sByteCount\n", varName))
 		case "int64":
 			// Bulk copy for Int64 arrays (little-endian platforms)
 			buf.WriteString(fmt.Sprintf("        let %sByteCount = %sLen * MemoryLayout<Int64>.stride\n", varName, varName))
+			buf.WriteString(fmt.Sprintf("        try need(base, pos, %sByteCount)\n", varName))
 			buf.WriteString(fmt.Sprintf("        let %s = [Int64](unsafeUninitializedCapacity: %sLen) { buffer, initializedCount in\n", varName, varName))
-			buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-			buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-			buf.WriteString(fmt.Sprintf("            dst.copyMemory(from: src, byteCount: %sByteCount)\n", varName))
+			buf.WriteString(fmt.Sprintf("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + %sByteCount]))\n", varName))
 			buf.WriteString(fmt.Sprintf("            initializedCount = %sLen\n", varName))
 			buf.WriteString("        }\n")
 			buf.WriteString(fmt.Sprintf("        pos += %sByteCount\n", varName))
 		case "float32":
 			// Bulk copy for Float arrays (little-endian platforms, IEEE 754)
 			buf.WriteString(fmt.Sprintf("        let %sByteCount = %sLen * MemoryLayout<Float>.stride\n", varName, varName))
+			buf.WriteString(fmt.Sprintf("        try need(base, pos, %sByteCount)\n", varName))
 			buf.WriteString(fmt.Sprintf("        let %s = [Float](unsafeUninitializedCapacity: %sLen) { buffer, initializedCount in\n", varName, varName))
-			buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-			buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-			buf.WriteString(fmt.Sprintf("            dst.copyMemory(from: src, byteCount: %sByteCount)\n", varName))
+			buf.WriteString(fmt.Sprintf("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + %sByteCount]))\n", varName))
 			buf.WriteString(fmt.Sprintf("            initializedCount = %sLen\n", varName))
 			buf.WriteString("        }\n")
 			buf.WriteString(fmt.Sprintf("        pos += %sByteCount\n", varName))
 		case "float64":
 			// Bulk copy for Double arrays (little-endian platforms, IEEE 754)
 			buf.WriteString(fmt.Sprintf("        let %sByteCount = %sLen * MemoryLayout<Double>.stride\n", varName, varName))
+			buf.WriteString(fmt.Sprintf("        try need(base, pos, %sByteCount)\n", varName))
 			buf.WriteString(fmt.Sprintf("        let %s = [Double](unsafeUninitializedCapacity: %sLen) { buffer, initializedCount in\n", varName, varName))
-			buf.WriteString("            let src = UnsafeRawPointer(base.advanced(by: pos))\n")
-			buf.WriteString("            let dst = UnsafeMutableRawPointer(buffer.baseAddress!)\n")
-			buf.WriteString(fmt.Sprintf("            dst.copyMemory(from: src, byteCount: %sByteCount)\n", varName))
+			buf.WriteString(fmt.Sprintf("            UnsafeMutableRawBufferPointer(buffer).copyMemory(from: UnsafeRawBufferPointer(rebasing: base[pos..<pos + %sByteCount]))\n", varName))
 			buf.WriteString(fmt.Sprintf("            initializedCount = %sLen\n", varName))
 			buf.WriteString("        }\n")
 			buf.WriteString(fmt.Sprintf("        pos += %sByteCount\n", varName))
@@ -1269,11 +1635,14 @@ func generateSwiftDecodeArray(buf *bytes.Buffer, arrayType *schema.ArrayType, va
 			buf.WriteString(fmt.Sprintf("        var %s = [String]()\n", varName))
 			buf.WriteString(fmt.Sprintf("        %s.reserveCapacity(%sLen)\n", varName, varName))
 			buf.WriteString(fmt.Sprintf("        for _ in 0..<%sLen {\n", varName))
-			buf.WriteString("            let strLen = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n")
-			buf.WriteString("            pos += 2\n")
-			buf.WriteString("            let str = String(decoding: UnsafeBufferPointer(start: base.advanced(by: pos).assumingMemoryBound(to: UInt8.self), count: strLen), as: UTF8.self)\n")
+			buf.WriteString("            let str = try decodeString(base, &pos)\n")
 			buf.WriteString(fmt.Sprintf("            %s.append(str)\n", varName))
-			buf.WriteString("            pos += strLen\n")
+			buf.WriteString("        }\n")
+		case "bytes":
+			buf.WriteString(fmt.Sprintf("        var %s = [Data]()\n", varName))
+			buf.WriteString(fmt.Sprintf("        %s.reserveCapacity(%sLen)\n", varName, varName))
+			buf.WriteString(fmt.Sprintf("        for _ in 0..<%sLen {\n", varName))
+			buf.WriteString(fmt.Sprintf("            %s.append(try decodeBytes(base, &pos))\n", varName))
 			buf.WriteString("        }\n")
 		}
 	} else if structType, ok := arrayType.ElementType.(*schema.StructType); ok {
@@ -1311,22 +1680,26 @@ func generateSwiftExtensionMethods(buf *bytes.Buffer, msg schema.MessageType) {
 func generateSwiftStructHelpers(buf *bytes.Buffer, structType *schema.StructType) {
 	// Encode helper
 	buf.WriteString("@inlinable\n")
-	buf.WriteString(fmt.Sprintf("func encodeStruct_%s(_ buffer: inout [UInt8], _ value: %s) {\n", structType.Name, structType.Name))
+	buf.WriteString(fmt.Sprintf("func encodeStruct_%s(_ buffer: inout FFireWriter, _ value: %s) {\n", structType.Name, structType.Name))
 	
 	// Sequential encoding - Swift's append is already optimized
-	for _, field := range structType.Fields {
-		generateSwiftEncodeField(buf, field, "value."+field.Name)
-	}
+	generateSwiftEncodeFields(buf, structType, "value")
+	buf.WriteString("}\n\n")
+
+	// Size helper, matching the encode helper byte for byte
+	buf.WriteString("@inlinable\n")
+	buf.WriteString(fmt.Sprintf("func sizeStruct_%s(_ value: %s) -> Int {\n", structType.Name, structType.Name))
+	buf.WriteString("    var size = 0\n")
+	generateSwiftSizeFields(buf, structType, "value")
+	buf.WriteString("    return size\n")
 	buf.WriteString("}\n\n")
 
 	// Decode helper
 	buf.WriteString("@inlinable\n")
-	buf.WriteString(fmt.Sprintf("func decodeStruct_%s(_ base: UnsafeRawPointer, _ pos: inout Int) throws -> %s {\n", structType.Name, structType.Name))
+	buf.WriteString(fmt.Sprintf("func decodeStruct_%s(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> %s {\n", structType.Name, structType.Name))
 	
 	// Sequential decoding - direct memory access is already efficient
-	for _, field := range structType.Fields {
-		generateSwiftDecodeField(buf, field)
-	}
+	generateSwiftDecodeFields(buf, structType)
 	
 	buf.WriteString(fmt.Sprintf("    return %s(\n", structType.Name))
 	for i, field := range structType.Fields {
@@ -1345,180 +1718,339 @@ func generateSwiftHelpers(buf *bytes.Buffer) {
 	buf.WriteString("// MARK: - Helper Functions\n\n")
 	
 	buf.WriteString("public enum FFireError: Error {\n")
+	buf.WriteString("    case truncated\n")
 	buf.WriteString("    case invalidData\n")
 	buf.WriteString("    case invalidString\n")
+	buf.WriteString("    case overflow\n")
+	buf.WriteString("    case badPresence\n")
+	buf.WriteString("    case trailingData\n")
 	buf.WriteString("}\n\n")
+	buf.WriteString(swiftDecodeErrorCode())
 
-	// Add inline helper functions for primitive reads
+	// Primitive readers check the data left before each load, so a short
+	// message throws instead of reading past the buffer
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readInt16(_ base: UnsafeRawPointer, _ pos: inout Int) -> Int16 {\n")
+	buf.WriteString("func need(_ base: UnsafeRawBufferPointer, _ pos: Int, _ n: Int) throws {\n")
+	buf.WriteString("    if base.count - pos < n { throw FFireError.truncated }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func needCount(_ base: UnsafeRawBufferPointer, _ pos: Int, _ n: Int) throws {\n")
+	buf.WriteString("    if base.count - pos < n { throw FFireError.overflow }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func readInt16(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Int16 {\n")
+	buf.WriteString("    try need(base, pos, 2)\n")
 	buf.WriteString("    defer { pos += 2 }\n")
 	buf.WriteString("    return Int16(littleEndian: base.load(fromByteOffset: pos, as: Int16.self))\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readInt32(_ base: UnsafeRawPointer, _ pos: inout Int) -> Int32 {\n")
+	buf.WriteString("func readInt32(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Int32 {\n")
+	buf.WriteString("    try need(base, pos, 4)\n")
 	buf.WriteString("    defer { pos += 4 }\n")
 	buf.WriteString("    return Int32(littleEndian: base.load(fromByteOffset: pos, as: Int32.self))\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readInt64(_ base: UnsafeRawPointer, _ pos: inout Int) -> Int64 {\n")
+	buf.WriteString("func readInt64(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Int64 {\n")
+	buf.WriteString("    try need(base, pos, 8)\n")
 	buf.WriteString("    defer { pos += 8 }\n")
 	buf.WriteString("    return Int64(littleEndian: base.load(fromByteOffset: pos, as: Int64.self))\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readFloat(_ base: UnsafeRawPointer, _ pos: inout Int) -> Float {\n")
+	buf.WriteString("func readFloat(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Float {\n")
+	buf.WriteString("    try need(base, pos, 4)\n")
 	buf.WriteString("    defer { pos += 4 }\n")
 	buf.WriteString("    return Float(bitPattern: UInt32(littleEndian: base.load(fromByteOffset: pos, as: UInt32.self)))\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readDouble(_ base: UnsafeRawPointer, _ pos: inout Int) -> Double {\n")
+	buf.WriteString("func readDouble(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Double {\n")
+	buf.WriteString("    try need(base, pos, 8)\n")
 	buf.WriteString("    defer { pos += 8 }\n")
 	buf.WriteString("    return Double(bitPattern: UInt64(littleEndian: base.load(fromByteOffset: pos, as: UInt64.self)))\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readBool(_ base: UnsafeRawPointer, _ pos: inout Int) -> Bool {\n")
-	buf.WriteString("    defer { pos += 1 }\n")
-	buf.WriteString("    return base.load(fromByteOffset: pos, as: UInt8.self) != 0\n")
+	buf.WriteString("func readBool(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Bool {\n")
+	buf.WriteString("    try need(base, pos, 1)\n")
+	buf.WriteString("    let v = base[pos]\n")
+	buf.WriteString("    if v > 1 { throw FFireError.invalidData }\n")
+	buf.WriteString("    pos += 1\n")
+	buf.WriteString("    return v == 1\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readInt8(_ base: UnsafeRawPointer, _ pos: inout Int) -> Int8 {\n")
+	buf.WriteString("func readInt8(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Int8 {\n")
+	buf.WriteString("    try need(base, pos, 1)\n")
 	buf.WriteString("    defer { pos += 1 }\n")
-	buf.WriteString("    return base.load(fromByteOffset: pos, as: Int8.self)\n")
+	buf.WriteString("    return Int8(bitPattern: base[pos])\n")
 	buf.WriteString("}\n\n")
 
-	// Optional primitive readers - combine presence check + value read
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readOptionalInt32(_ base: UnsafeRawPointer, _ pos: inout Int) -> Int32? {\n")
-	buf.WriteString("    let present = base.load(fromByteOffset: pos, as: UInt8.self)\n")
+	buf.WriteString("func readPresence(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Bool {\n")
+	buf.WriteString("    try need(base, pos, 1)\n")
+	buf.WriteString("    let flag = base[pos]\n")
+	buf.WriteString("    if flag > 1 { throw FFireError.badPresence }\n")
 	buf.WriteString("    pos += 1\n")
-	buf.WriteString("    guard present != 0 else { return nil }\n")
-	buf.WriteString("    defer { pos += 4 }\n")
-	buf.WriteString("    return Int32(littleEndian: base.load(fromByteOffset: pos, as: Int32.self))\n")
+	buf.WriteString("    return flag == 1\n")
 	buf.WriteString("}\n\n")
 
+	// Optional primitive readers - combine presence check + value read
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readOptionalInt64(_ base: UnsafeRawPointer, _ pos: inout Int) -> Int64? {\n")
-	buf.WriteString("    let present = base.load(fromByteOffset: pos, as: UInt8.self)\n")
-	buf.WriteString("    pos += 1\n")
-	buf.WriteString("    guard present != 0 else { return nil }\n")
-	buf.WriteString("    defer { pos += 8 }\n")
-	buf.WriteString("    return Int64(littleEndian: base.load(fromByteOffset: pos, as: Int64.self))\n")
+	buf.WriteString("func readOptionalInt32(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Int32? {\n")
+	buf.WriteString("    guard try readPresence(base, &pos) else { return nil }\n")
+	buf.WriteString("    return try readInt32(base, &pos)\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readOptionalFloat(_ base: UnsafeRawPointer, _ pos: inout Int) -> Float? {\n")
-	buf.WriteString("    let present = base.load(fromByteOffset: pos, as: UInt8.self)\n")
-	buf.WriteString("    pos += 1\n")
-	buf.WriteString("    guard present != 0 else { return nil }\n")
-	buf.WriteString("    defer { pos += 4 }\n")
-	buf.WriteString("    return Float(bitPattern: UInt32(littleEndian: base.load(fromByteOffset: pos, as: UInt32.self)))\n")
+	buf.WriteString("func readOptionalInt64(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Int64? {\n")
+	buf.WriteString("    guard try readPresence(base, &pos) else { return nil }\n")
+	buf.WriteString("    return try readInt64(base, &pos)\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readOptionalDouble(_ base: UnsafeRawPointer, _ pos: inout Int) -> Double? {\n")
-	buf.WriteString("    let present = base.load(fromByteOffset: pos, as: UInt8.self)\n")
-	buf.WriteString("    pos += 1\n")
-	buf.WriteString("    guard present != 0 else { return nil }\n")
-	buf.WriteString("    defer { pos += 8 }\n")
-	buf.WriteString("    return Double(bitPattern: UInt64(littleEndian: base.load(fromByteOffset: pos, as: UInt64.self)))\n")
+	buf.WriteString("func readOptionalFloat(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Float? {\n")
+	buf.WriteString("    guard try readPresence(base, &pos) else { return nil }\n")
+	buf.WriteString("    return try readFloat(base, &pos)\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readOptionalBool(_ base: UnsafeRawPointer, _ pos: inout Int) -> Bool? {\n")
-	buf.WriteString("    let present = base.load(fromByteOffset: pos, as: UInt8.self)\n")
-	buf.WriteString("    pos += 1\n")
-	buf.WriteString("    guard present != 0 else { return nil }\n")
-	buf.WriteString("    defer { pos += 1 }\n")
-	buf.WriteString("    return base.load(fromByteOffset: pos, as: UInt8.self) != 0\n")
+	buf.WriteString("func readOptionalDouble(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Double? {\n")
+	buf.WriteString("    guard try readPresence(base, &pos) else { return nil }\n")
+	buf.WriteString("    return try readDouble(base, &pos)\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func readOptionalString(_ base: UnsafeRawPointer, _ pos: inout Int) -> String? {\n")
-	buf.WriteString("    let present = base.load(fromByteOffset: pos, as: UInt8.self)\n")
-	buf.WriteString("    pos += 1\n")
-	buf.WriteString("    guard present != 0 else { return nil }\n")
-	buf.WriteString("    let len = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n")
-	buf.WriteString("    pos += 2\n")
-	buf.WriteString("    let result = String(decoding: UnsafeBufferPointer(start: base.advanced(by: pos).assumingMemoryBound(to: UInt8.self), count: len), as: UTF8.self)\n")
-	buf.WriteString("    pos += len\n")
-	buf.WriteString("    return result\n")
+	buf.WriteString("func readOptionalBool(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Bool? {\n")
+	buf.WriteString("    guard try readPresence(base, &pos) else { return nil }\n")
+	buf.WriteString("    return try readBool(base, &pos)\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func readOptionalString(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> String? {\n")
+	buf.WriteString("    guard try readPresence(base, &pos) else { return nil }\n")
+	buf.WriteString("    return try decodeString(base, &pos)\n")
+	buf.WriteString("}\n\n")
+
+	// FFireWriter appends into storage the encoder sized exactly beforehand,
+	// so it never grows or bounds-checks.
+	buf.WriteString("@usableFromInline\n")
+	buf.WriteString("struct FFireWriter {\n")
+	buf.WriteString("    @usableFromInline let base: UnsafeMutableRawPointer\n")
+	buf.WriteString("    @usableFromInline var count = 0\n\n")
+	buf.WriteString("    @inlinable init(_ base: UnsafeMutableRawPointer) { self.base = base }\n\n")
+	buf.WriteString("    @inlinable mutating func append(_ byte: UInt8) {\n")
+	buf.WriteString("        base.storeBytes(of: byte, toByteOffset: count, as: UInt8.self)\n")
+	buf.WriteString("        count += 1\n")
+	buf.WriteString("    }\n\n")
+	buf.WriteString("    @inlinable mutating func append(contentsOf bytes: UnsafeRawBufferPointer) {\n")
+	buf.WriteString("        guard let src = bytes.baseAddress else { return }\n")
+	buf.WriteString("        (base + count).copyMemory(from: src, byteCount: bytes.count)\n")
+	buf.WriteString("        count += bytes.count\n")
+	buf.WriteString("    }\n")
 	buf.WriteString("}\n\n")
 
 	// Optional primitive writers - combine presence byte + value in single call
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func writeOptionalInt32(_ buffer: inout [UInt8], _ value: Int32?) {\n")
+	buf.WriteString("func writeOptionalInt32(_ buffer: inout FFireWriter, _ value: Int32?) {\n")
 	buf.WriteString("    guard let v = value else { buffer.append(0); return }\n")
 	buf.WriteString("    buffer.append(1)\n")
 	buf.WriteString("    withUnsafeBytes(of: v.littleEndian) { buffer.append(contentsOf: $0) }\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func writeOptionalInt64(_ buffer: inout [UInt8], _ value: Int64?) {\n")
+	buf.WriteString("func writeOptionalInt64(_ buffer: inout FFireWriter, _ value: Int64?) {\n")
 	buf.WriteString("    guard let v = value else { buffer.append(0); return }\n")
 	buf.WriteString("    buffer.append(1)\n")
 	buf.WriteString("    withUnsafeBytes(of: v.littleEndian) { buffer.append(contentsOf: $0) }\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func writeOptionalFloat(_ buffer: inout [UInt8], _ value: Float?) {\n")
+	buf.WriteString("func writeOptionalFloat(_ buffer: inout FFireWriter, _ value: Float?) {\n")
 	buf.WriteString("    guard let v = value else { buffer.append(0); return }\n")
 	buf.WriteString("    buffer.append(1)\n")
 	buf.WriteString("    withUnsafeBytes(of: v.bitPattern.littleEndian) { buffer.append(contentsOf: $0) }\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func writeOptionalDouble(_ buffer: inout [UInt8], _ value: Double?) {\n")
+	buf.WriteString("func writeOptionalDouble(_ buffer: inout FFireWriter, _ value: Double?) {\n")
 	buf.WriteString("    guard let v = value else { buffer.append(0); return }\n")
 	buf.WriteString("    buffer.append(1)\n")
 	buf.WriteString("    withUnsafeBytes(of: v.bitPattern.littleEndian) { buffer.append(contentsOf: $0) }\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func writeOptionalBool(_ buffer: inout [UInt8], _ value: Bool?) {\n")
+	buf.WriteString("func writeOptionalBool(_ buffer: inout FFireWriter, _ value: Bool?) {\n")
 	buf.WriteString("    guard let v = value else { buffer.append(0); return }\n")
 	buf.WriteString("    buffer.append(1)\n")
 	buf.WriteString("    buffer.append(v ? 1 : 0)\n")
 	buf.WriteString("}\n\n")
 
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func writeOptionalString(_ buffer: inout [UInt8], _ value: String?) {\n")
+	buf.WriteString("func writeOptionalString(_ buffer: inout FFireWriter, _ value: String?) {\n")
 	buf.WriteString("    guard let v = value else { buffer.append(0); return }\n")
 	buf.WriteString("    buffer.append(1)\n")
 	buf.WriteString("    // Reuse encodeString for consistency\n")
 	buf.WriteString("    encodeString(&buffer, v)\n")
 	buf.WriteString("}\n\n")
 
-	// String encoding - use append for [UInt8] (very fast compared to Data.append)
+	// String encoding - copies the UTF-8 view straight into the writer
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func encodeString(_ buffer: inout [UInt8], _ string: String) {\n")
+	buf.WriteString("func encodeString(_ buffer: inout FFireWriter, _ string: String) {\n")
 	buf.WriteString("    var s = string\n")
 	buf.WriteString("    s.withUTF8 { utf8 in\n")
 	buf.WriteString("        let len = UInt16(utf8.count)\n")
 	buf.WriteString("        buffer.append(UInt8(len & 0xFF))\n")
 	buf.WriteString("        buffer.append(UInt8(len >> 8))\n")
-	buf.WriteString("        if let base = utf8.baseAddress {\n")
-	buf.WriteString("            buffer.append(contentsOf: UnsafeBufferPointer(start: base, count: utf8.count))\n")
-	buf.WriteString("        }\n")
+	buf.WriteString("        buffer.append(contentsOf: UnsafeRawBufferPointer(utf8))\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func decodeString(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> String {\n")
+	buf.WriteString("    try need(base, pos, 2)\n")
+	buf.WriteString("    let len = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n")
+	buf.WriteString("    pos += 2\n")
+	buf.WriteString("    let result = try makeString(base, pos, len)\n")
+	buf.WriteString("    pos += len\n")
+	buf.WriteString("    return result\n")
+	buf.WriteString("}\n\n")
+
+	// Strings are validated, as the reference decoder does, before the
+	// unchecked conversion
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func makeString(_ base: UnsafeRawBufferPointer, _ pos: Int, _ len: Int) throws -> String {\n")
+	buf.WriteString("    try needCount(base, pos, len)\n")
+	buf.WriteString("    let bytes = UnsafeRawBufferPointer(rebasing: base[pos..<pos + len]).bindMemory(to: UInt8.self)\n")
+	buf.WriteString("    if transcode(bytes.makeIterator(), from: UTF8.self, to: UTF8.self, stoppingOnError: true, into: { _ in }) {\n")
+	buf.WriteString("        throw FFireError.invalidString\n")
 	buf.WriteString("    }\n")
+	buf.WriteString("    return String(decoding: bytes, as: UTF8.self)\n")
 	buf.WriteString("}\n\n")
 
+	// Bytes are copied out as Data, so the result outlives the input
 	buf.WriteString("@inlinable\n")
-	buf.WriteString("func decodeString(_ base: UnsafeRawPointer, _ pos: inout Int) throws -> String {\n")
+	buf.WriteString("func encodeBytes(_ buffer: inout FFireWriter, _ data: Data) {\n")
+	buf.WriteString("    let len = UInt16(data.count)\n")
+	buf.WriteString("    buffer.append(UInt8(len & 0xFF))\n")
+	buf.WriteString("    buffer.append(UInt8(len >> 8))\n")
+	buf.WriteString("    data.withUnsafeBytes { buffer.append(contentsOf: $0) }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func decodeBytes(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Data {\n")
+	buf.WriteString("    try need(base, pos, 2)\n")
 	buf.WriteString("    let len = Int(UInt16(littleEndian: base.load(fromByteOffset: pos, as: UInt16.self)))\n")
 	buf.WriteString("    pos += 2\n")
-	buf.WriteString("    // Use unsafe decoding - assumes valid UTF-8 (ffire guarantees this)\n")
-	buf.WriteString("    let result = String(decoding: UnsafeBufferPointer(start: base.advanced(by: pos).assumingMemoryBound(to: UInt8.self), count: len), as: UTF8.self)\n")
+	buf.WriteString("    try needCount(base, pos, len)\n")
+	buf.WriteString("    let result = Data(base[pos..<pos + len])\n")
+	buf.WriteString("    pos += len\n")
+	buf.WriteString("    return result\n")
+	buf.WriteString("}\n")
+}
+
+// generateSwiftLargeHelpers writes the coders of @large strings and bytes,
+// which carry a uint32 length prefix instead of a uint16 one.
+func generateSwiftLargeHelpers(buf *bytes.Buffer) {
+	buf.WriteString("\n// MARK: - Large Values\n\n")
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func encodeLargeString(_ buffer: inout FFireWriter, _ string: String) {\n")
+	buf.WriteString("    var s = string\n")
+	buf.WriteString("    s.withUTF8 { utf8 in\n")
+	buf.WriteString("        withUnsafeBytes(of: UInt32(utf8.count).littleEndian) { buffer.append(contentsOf: $0) }\n")
+	buf.WriteString("        buffer.append(contentsOf: UnsafeRawBufferPointer(utf8))\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func decodeLargeString(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> String {\n")
+	buf.WriteString("    try need(base, pos, 4)\n")
+	buf.WriteString("    let len = Int(UInt32(littleEndian: base.load(fromByteOffset: pos, as: UInt32.self)))\n")
+	buf.WriteString("    pos += 4\n")
+	buf.WriteString("    let result = try makeString(base, pos, len)\n")
+	buf.WriteString("    pos += len\n")
+	buf.WriteString("    return result\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func encodeLargeBytes(_ buffer: inout FFireWriter, _ data: Data) {\n")
+	buf.WriteString("    withUnsafeBytes(of: UInt32(data.count).littleEndian) { buffer.append(contentsOf: $0) }\n")
+	buf.WriteString("    data.withUnsafeBytes { buffer.append(contentsOf: $0) }\n")
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func decodeLargeBytes(_ base: UnsafeRawBufferPointer, _ pos: inout Int) throws -> Data {\n")
+	buf.WriteString("    try need(base, pos, 4)\n")
+	buf.WriteString("    let len = Int(UInt32(littleEndian: base.load(fromByteOffset: pos, as: UInt32.self)))\n")
+	buf.WriteString("    pos += 4\n")
+	buf.WriteString("    try needCount(base, pos, len)\n")
+	buf.WriteString("    let result = Data(base[pos..<pos + len])\n")
 	buf.WriteString("    pos += len\n")
 	buf.WriteString("    return result\n")
 	buf.WriteString("}\n")
 }
 
+// swiftLengthType returns the Swift type of t's length prefix.
+func swiftLengthType(t schema.Type) string {
+	if schema.IsLarge(t) {
+		return "UInt32"
+	}
+	return "UInt16"
+}
+
+// swiftLargeSuffix names the large helper for a @large string or bytes.
+func swiftLargeSuffix(t *schema.PrimitiveType) string {
+	if t.Name == "bytes" {
+		return "Bytes"
+	}
+	return "String"
+}
+
+// generateSwiftReadLength declares lenVar and reads t's length prefix
+// into it.
+func generateSwiftReadLength(buf *bytes.Buffer, t schema.Type, lenVar string, indent string) {
+	typ := swiftLengthType(t)
+	buf.WriteString(fmt.Sprintf("%stry need(base, pos, %d)\n", indent, schema.LengthPrefixSize(t)))
+	buf.WriteString(fmt.Sprintf("%slet %s = Int(%s(littleEndian: base.load(fromByteOffset: pos, as: %s.self)))\n", indent, lenVar, typ, typ))
+	buf.WriteString(fmt.Sprintf("%spos += %d\n", indent, schema.LengthPrefixSize(t)))
+	buf.WriteString(fmt.Sprintf("%stry needCount(base, pos, %s)\n", indent, lenVar))
+}
+
+// generateSwiftStringCache writes the encoder-side cache of @cached
+// fields: each distinct string is encoded once, length prefix included,
+// and later encodes copy those bytes. The cache stops growing at a fixed
+// size, so unbounded label sets cost one lookup and no memory.
+func generateSwiftStringCache(buf *bytes.Buffer) {
+	buf.WriteString("\n// MARK: - String Cache\n\n")
+	buf.WriteString("@usableFromInline\n")
+	buf.WriteString("final class FFireStringCache: @unchecked Sendable {\n")
+	buf.WriteString("    private let lock = NSLock()\n")
+	buf.WriteString("    private var entries: [String: [UInt8]] = [:]\n")
+	buf.WriteString("    private let limit = 4096\n\n")
+	buf.WriteString("    @usableFromInline init() {}\n\n")
+	buf.WriteString("    @usableFromInline func encoded(_ string: String) -> [UInt8] {\n")
+	buf.WriteString("        lock.lock()\n")
+	buf.WriteString("        defer { lock.unlock() }\n")
+	buf.WriteString("        if let hit = entries[string] { return hit }\n")
+	buf.WriteString("        let len = UInt16(string.utf8.count)\n")
+	buf.WriteString("        var bytes: [UInt8] = [UInt8(len & 0xFF), UInt8(len >> 8)]\n")
+	buf.WriteString("        bytes.append(contentsOf: string.utf8)\n")
+	buf.WriteString("        if entries.count < limit { entries[string] = bytes }\n")
+	buf.WriteString("        return bytes\n")
+	buf.WriteString("    }\n")
+	buf.WriteString("}\n\n")
+	buf.WriteString("@usableFromInline\n")
+	buf.WriteString("let ffireStringCache = FFireStringCache()\n\n")
+	buf.WriteString("@inlinable\n")
+	buf.WriteString("func encodeCachedString(_ buffer: inout FFireWriter, _ string: String) {\n")
+	buf.WriteString("    ffireStringCache.encoded(string).withUnsafeBytes { buffer.append(contentsOf: $0) }\n")
+	buf.WriteString("}\n")
+}
+
 func getSwiftTypeString(typ schema.Type) string {
 	switch t := typ.(type) {
 	case *schema.PrimitiveType:
@@ -1534,11 +2066,17 @@ func getSwiftTypeString(typ schema.Type) string {
 			return arrayType + "?"
 		}
 		return arrayType
-	case *schema.StructType:
+	case *schema.MapType:
+		mapType := fmt.Sprintf("[%s: %s]", getSwiftTypeString(t.KeyType), getSwiftTypeString(t.ValueType))
 		if t.Optional {
-			return t.Name + "?"
+			return mapType + "?"
+		}
+		return mapType
+	case *schema.EnumType, *schema.StructType, *schema.UnionType:
+		if t.IsOptional() {
+			return t.TypeName() + "?"
 		}
-		return t.Name
+		return t.TypeName()
 	default:
 		return "Any"
 	}
@@ -1562,6 +2100,8 @@ func getSwiftPrimitiveType(name string) string {
 		return "Double"
 	case "string":
 		return "String"
+	case "bytes":
+		return "Data"
 	default:
 		return "Any"
 	}
@@ -1600,17 +2140,19 @@ func generateSwiftMetadataOrchestrated(config *PackageConfig, paths *PackagePath
 }
 
 func printSwiftInstructions(config *PackageConfig, paths *PackagePaths) {
-	fmt.Printf("\n✅ Native Swift package ready at: %s\n\n", paths.Root)
-	fmt.Println("Build:")
-	fmt.Printf("  cd %s\n", paths.Root)
-	fmt.Println("  swift build")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Printf("  import %s\n", config.Namespace)
-	fmt.Printf("  let msg = PluginMessage(name: \"test\", version: \"1.0\")\n")
-	fmt.Printf("  let encoded = encodePluginMessage(msg)\n")
-	fmt.Printf("  let decoded = try decodePluginMessage(encoded)\n")
-	fmt.Println()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Native Swift package ready at: %s\n\n", paths.Root)
+	fmt.Fprintln(&b, "Build:")
+	fmt.Fprintf(&b, "  cd %s\n", paths.Root)
+	fmt.Fprintln(&b, "  swift build")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  import %s\n", config.Namespace)
+	fmt.Fprintf(&b, "  let msg = PluginMessage(name: \"test\", version: \"1.0\")\n")
+	fmt.Fprintf(&b, "  let encoded = encodePluginMessage(msg)\n")
+	fmt.Fprintf(&b, "  let decoded = try decodePluginMessage(encoded)\n")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
 }
 
 // generateSwiftPackageManifest generates Package.swift for native Swift
@@ -1618,6 +2160,7 @@ func generateSwiftPackageManifest(config *PackageConfig, packageDir string) erro
 	buf := &bytes.Buffer{}
 
 	fmt.Fprintf(buf, `// swift-tools-version:5.9
+// Version %s. SwiftPM takes package versions from git tags: tag the release with it.
 import PackageDescription
 
 let package = Package(
@@ -1641,16 +2184,24 @@ let package = Package(
             dependencies: [],
             path: "Sources/%s"
         ),
-    ]
-)
-`, config.Namespace, config.Namespace, config.Namespace, config.Namespace, config.Namespace)
+`, config.Version, config.Namespace, valueOr(config.Coordinates.SwiftPM, config.Namespace), config.Namespace, config.Namespace, config.Namespace)
+
+	if config.WithTests {
+		fmt.Fprintf(buf, `        .testTarget(
+            name: "%sTests",
+            dependencies: ["%s"],
+            path: "Tests/%sTests"
+        ),
+`, config.Namespace, config.Namespace, config.Namespace)
+	}
+	buf.WriteString("    ]\n)\n")
 
 	manifestPath := filepath.Join(packageDir, "Package.swift")
 	if err := os.WriteFile(manifestPath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write Package.swift: %w", err)
 	}
 
-	fmt.Printf("✓ Generated Package.swift: %s\n", manifestPath)
+	config.infof("✓ Generated Package.swift: %s\n", manifestPath)
 	return nil
 }
 
@@ -1707,7 +2258,11 @@ func generateSwiftReadme(config *PackageConfig, packageDir string) error {
 
 	buf.WriteString("## API\n\n")
 	buf.WriteString("### Message Types\n\n")
-	buf.WriteString("All message types are Swift structs:\n\n")
+	if config.SwiftCodable {
+		buf.WriteString("All message types are Swift structs, and all generated types are `Equatable`, `Hashable`, `Sendable` and `Codable`:\n\n")
+	} else {
+		buf.WriteString("All message types are Swift structs, and all generated types are `Equatable`, `Hashable` and `Sendable`:\n\n")
+	}
 
 	for _, msg := range config.Schema.Messages {
 		fmt.Fprintf(buf, "- `%sMessage`\n", msg.Name)
@@ -1742,13 +2297,212 @@ func generateSwiftReadme(config *PackageConfig, packageDir string) error {
 	buf.WriteString("- watchOS 9+\n\n")
 
 	buf.WriteString("## License\n\n")
-	buf.WriteString("Generated by FFire. See your schema's license for terms.\n")
+	buf.WriteString(readmeLicense(config, "Generated by FFire. See your schema's license for terms.\n"))
 
 	readmePath := filepath.Join(packageDir, "README.md")
 	if err := os.WriteFile(readmePath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write README.md: %w", err)
 	}
 
-	fmt.Printf("✓ Generated README.md: %s\n", readmePath)
+	config.infof("✓ Generated README.md: %s\n", readmePath)
 	return nil
 }
+
+// Map fields are encoded and decoded by generateSwiftEncodeValue and
+// generateSwiftDecodeValue, which recurse through the key and value types
+// so that map values may be any type, including arrays and maps.
+
+// generateSwiftEncodeValue appends the encoding of accessor to buffer.
+// depth keeps loop variables of nested values apart.
+func generateSwiftEncodeValue(buf *bytes.Buffer, typ schema.Type, accessor string, indent string, depth int) {
+	inner := indent
+	if typ.IsOptional() {
+		unwrapped := fmt.Sprintf("unwrapped%d", depth)
+		buf.WriteString(fmt.Sprintf("%sif let %s = %s {\n", indent, unwrapped, accessor))
+		buf.WriteString(fmt.Sprintf("%s    buffer.append(1) // present\n", indent))
+		accessor = unwrapped
+		inner += "    "
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		var prim bytes.Buffer
+		generateSwiftEncodePrimitive(&prim, t.Name, accessor)
+		buf.WriteString(inner + strings.TrimPrefix(prim.String(), "    "))
+	case *schema.EnumType:
+		var prim bytes.Buffer
+		generateSwiftEncodePrimitive(&prim, t.Base, accessor+".rawValue")
+		buf.WriteString(inner + strings.TrimPrefix(prim.String(), "    "))
+	case *schema.StructType:
+		buf.WriteString(fmt.Sprintf("%sencodeStruct_%s(&buffer, %s)\n", inner, t.Name, accessor))
+	case *schema.UnionType:
+		buf.WriteString(fmt.Sprintf("%sencodeUnion_%s(&buffer, %s)\n", inner, t.Name, accessor))
+	case *schema.ArrayType:
+		item := fmt.Sprintf("item%d", depth)
+		buf.WriteString(fmt.Sprintf("%swithUnsafeBytes(of: %s(%s.count).littleEndian) { buffer.append(contentsOf: $0) }\n", inner, swiftLengthType(t), accessor))
+		buf.WriteString(fmt.Sprintf("%sfor %s in %s {\n", inner, item, accessor))
+		generateSwiftEncodeValue(buf, t.ElementType, item, inner+"    ", depth+1)
+		buf.WriteString(fmt.Sprintf("%s}\n", inner))
+	case *schema.MapType:
+		key := fmt.Sprintf("key%d", depth)
+		value := fmt.Sprintf("value%d", depth)
+		buf.WriteString(fmt.Sprintf("%swithUnsafeBytes(of: %s(%s.count).littleEndian) { buffer.append(contentsOf: $0) }\n", inner, swiftLengthType(t), accessor))
+		buf.WriteString(fmt.Sprintf("%sfor %s in %s.keys%s {\n", inner, key, accessor, swiftMapKeyOrder(t.KeyType.(*schema.PrimitiveType))))
+		buf.WriteString(fmt.Sprintf("%s    let %s = %s[%s]!\n", inner, value, accessor, key))
+		generateSwiftEncodeValue(buf, t.KeyType, key, inner+"    ", depth+1)
+		generateSwiftEncodeValue(buf, t.ValueType, value, inner+"    ", depth+1)
+		buf.WriteString(fmt.Sprintf("%s}\n", inner))
+	}
+
+	if typ.IsOptional() {
+		buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s    buffer.append(0) // absent\n", indent))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+}
+
+// generateSwiftSizeValue adds the number of bytes the encoders write for
+// accessor to size, so an encode can allocate its output exactly once.
+func generateSwiftSizeValue(buf *bytes.Buffer, typ schema.Type, accessor string, indent string, depth int) {
+	inner := indent
+	if typ.IsOptional() {
+		buf.WriteString(fmt.Sprintf("%ssize += 1\n", indent))
+		if n := swiftFixedSize(typ); n > 0 {
+			buf.WriteString(fmt.Sprintf("%sif %s != nil { size += %d }\n", indent, accessor, n))
+			return
+		}
+		unwrapped := fmt.Sprintf("unwrapped%d", depth)
+		buf.WriteString(fmt.Sprintf("%sif let %s = %s {\n", indent, unwrapped, accessor))
+		accessor = unwrapped
+		inner += "    "
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType, *schema.EnumType:
+		if n := swiftFixedSize(t); n > 0 {
+			buf.WriteString(fmt.Sprintf("%ssize += %d\n", inner, n))
+		} else if t.TypeName() == "bytes" {
+			buf.WriteString(fmt.Sprintf("%ssize += %d + %s.count\n", inner, schema.LengthPrefixSize(t), accessor))
+		} else {
+			buf.WriteString(fmt.Sprintf("%ssize += %d + %s.utf8.count\n", inner, schema.LengthPrefixSize(t), accessor))
+		}
+	case *schema.StructType:
+		buf.WriteString(fmt.Sprintf("%ssize += sizeStruct_%s(%s)\n", inner, t.Name, accessor))
+	case *schema.UnionType:
+		buf.WriteString(fmt.Sprintf("%ssize += sizeUnion_%s(%s)\n", inner, t.Name, accessor))
+	case *schema.ArrayType:
+		if t.Length > 0 {
+			buf.WriteString(fmt.Sprintf("%ssize += %d\n", inner, t.Length*swiftFixedSize(t.ElementType)))
+			break
+		}
+		if n := swiftFixedSize(t.ElementType); n > 0 && !t.ElementType.IsOptional() {
+			buf.WriteString(fmt.Sprintf("%ssize += %d + %s.count * %d\n", inner, schema.LengthPrefixSize(t), accessor, n))
+			break
+		}
+		item := fmt.Sprintf("item%d", depth)
+		buf.WriteString(fmt.Sprintf("%ssize += %d\n", inner, schema.LengthPrefixSize(t)))
+		buf.WriteString(fmt.Sprintf("%sfor %s in %s {\n", inner, item, accessor))
+		generateSwiftSizeValue(buf, t.ElementType, item, inner+"    ", depth+1)
+		buf.WriteString(fmt.Sprintf("%s}\n", inner))
+	case *schema.MapType:
+		buf.WriteString(fmt.Sprintf("%ssize += %d\n", inner, schema.LengthPrefixSize(t)))
+		for _, part := range []struct {
+			typ  schema.Type
+			name string
+			seq  string
+		}{{t.KeyType, "key", "keys"}, {t.ValueType, "value", "values"}} {
+			if n := swiftFixedSize(part.typ); n > 0 && !part.typ.IsOptional() {
+				buf.WriteString(fmt.Sprintf("%ssize += %s.count * %d\n", inner, accessor, n))
+				continue
+			}
+			elem := fmt.Sprintf("%s%d", part.name, depth)
+			buf.WriteString(fmt.Sprintf("%sfor %s in %s.%s {\n", inner, elem, accessor, part.seq))
+			generateSwiftSizeValue(buf, part.typ, elem, inner+"    ", depth+1)
+			buf.WriteString(fmt.Sprintf("%s}\n", inner))
+		}
+	}
+
+	if typ.IsOptional() {
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+}
+
+// swiftFixedSize returns the encoded size of a present fixed-width value,
+// not counting any presence byte, or 0 when the size depends on the value.
+func swiftFixedSize(typ schema.Type) int {
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		return schema.PrimitiveSize(t.Name)
+	case *schema.EnumType:
+		return schema.PrimitiveSize(t.Base)
+	}
+	return 0
+}
+
+// swiftMapKeyOrder sorts map keys into wire order: numeric for integers,
+// false before true, and UTF-8 byte order for strings, which String's <
+// (Unicode scalar order) does not match.
+func swiftMapKeyOrder(key *schema.PrimitiveType) string {
+	switch key.Name {
+	case "string":
+		return ".sorted(by: { $0.utf8.lexicographicallyPrecedes($1.utf8) })"
+	case "bool":
+		return ".sorted(by: { !$0 && $1 })"
+	}
+	return ".sorted()"
+}
+
+// generateSwiftDecodeValue declares varName and decodes a value of typ
+// into it. Nested values use variables named after varName.
+func generateSwiftDecodeValue(buf *bytes.Buffer, typ schema.Type, varName string, indent string) {
+	inner := indent
+	target := varName
+	if typ.IsOptional() {
+		buf.WriteString(fmt.Sprintf("%slet %sPresent = try readPresence(base, &pos)\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%slet %s: %s\n", indent, varName, getSwiftTypeString(typ)))
+		buf.WriteString(fmt.Sprintf("%sif %sPresent {\n", indent, varName))
+		inner += "    "
+		target = varName + "Value"
+	}
+
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		var prim bytes.Buffer
+		generateSwiftDecodePrimitive(&prim, t.Name, target)
+		buf.WriteString(inner + strings.TrimPrefix(prim.String(), "        "))
+	case *schema.EnumType:
+		var prim bytes.Buffer
+		generateSwiftDecodePrimitive(&prim, t.Base, target+"Raw")
+		buf.WriteString(inner + strings.TrimPrefix(prim.String(), "        "))
+		buf.WriteString(fmt.Sprintf("%slet %s = try decodeEnum_%s(%sRaw)\n", inner, target, t.Name, target))
+	case *schema.StructType:
+		buf.WriteString(fmt.Sprintf("%slet %s = try decodeStruct_%s(base, &pos)\n", inner, target, t.Name))
+	case *schema.UnionType:
+		buf.WriteString(fmt.Sprintf("%slet %s = try decodeUnion_%s(base, &pos)\n", inner, target, t.Name))
+	case *schema.ArrayType:
+		elem := target + "Elem"
+		generateSwiftReadLength(buf, t, target+"Len", inner)
+		buf.WriteString(fmt.Sprintf("%svar %s = %s()\n", inner, target, getSwiftTypeString(&schema.ArrayType{ElementType: t.ElementType})))
+		buf.WriteString(fmt.Sprintf("%s%s.reserveCapacity(%sLen)\n", inner, target, target))
+		buf.WriteString(fmt.Sprintf("%sfor _ in 0..<%sLen {\n", inner, target))
+		generateSwiftDecodeValue(buf, t.ElementType, elem, inner+"    ")
+		buf.WriteString(fmt.Sprintf("%s    %s.append(%s)\n", inner, target, elem))
+		buf.WriteString(fmt.Sprintf("%s}\n", inner))
+	case *schema.MapType:
+		key, value := target+"Key", target+"Value"
+		generateSwiftReadLength(buf, t, target+"Len", inner)
+		buf.WriteString(fmt.Sprintf("%svar %s = %s(minimumCapacity: %sLen)\n", inner, target, getSwiftTypeString(&schema.MapType{KeyType: t.KeyType, ValueType: t.ValueType}), target))
+		buf.WriteString(fmt.Sprintf("%sfor _ in 0..<%sLen {\n", inner, target))
+		generateSwiftDecodeValue(buf, t.KeyType, key, inner+"    ")
+		generateSwiftDecodeValue(buf, t.ValueType, value, inner+"    ")
+		buf.WriteString(fmt.Sprintf("%s    %s[%s] = %s\n", inner, target, key, value))
+		buf.WriteString(fmt.Sprintf("%s}\n", inner))
+	}
+
+	if typ.IsOptional() {
+		buf.WriteString(fmt.Sprintf("%s    %s = %s\n", indent, varName, target))
+		buf.WriteString(fmt.Sprintf("%s} else {\n", indent))
+		buf.WriteString(fmt.Sprintf("%s    %s = nil\n", indent, varName))
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	}
+}
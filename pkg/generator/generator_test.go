@@ -1,9 +1,15 @@
 package generator
 
 import (
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
 	"github.com/shaban/ffire/pkg/schema"
 )
 
@@ -693,6 +699,54 @@ func TestBoolArrayUsesBulkCopy(t *testing.T) {
 	}
 }
 
+func TestGenerateGoExactSizeEncode(t *testing.T) {
+	point := &schema.StructType{
+		Name: "Point",
+		Fields: []schema.Field{
+			{Name: "X", Type: &schema.PrimitiveType{Name: "int32"}},
+			{Name: "Y", Type: &schema.PrimitiveType{Name: "int32"}},
+		},
+	}
+	path := &schema.StructType{
+		Name: "Path",
+		Fields: []schema.Field{
+			{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+			{Name: "Points", Type: &schema.ArrayType{ElementType: point}},
+		},
+	}
+	s := &schema.Schema{
+		Package:  "testpkg",
+		Types:    []schema.Type{point, path},
+		Messages: []schema.MessageType{{Name: "Path", TargetType: path}},
+	}
+
+	code, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"func (v PathMessage) EncodedSize() int {",
+		// Fixed-size elements are counted without visiting them
+		"size += 2 + len(v.Points)*8",
+		"size += 2 + len(v.Name)",
+		"buf = ffireGrow(buf, v.EncodedSize())",
+		"pos += copy(buf[pos:], v.Name)",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	encoder := codeStr[strings.Index(codeStr, "func (v PathMessage) AppendTo("):]
+	encoder = encoder[:strings.Index(encoder, "\n}\n")]
+	for _, unwanted := range []string{"bytes.Buffer", "append("} {
+		if strings.Contains(encoder, unwanted) {
+			t.Errorf("encoder should write into a presized slice, found %q", unwanted)
+		}
+	}
+}
+
 func TestGenerateCppSimpleStruct(t *testing.T) {
 	s := &schema.Schema{
 		Package: "test",
@@ -887,7 +941,7 @@ func TestGenerateCppOptional(t *testing.T) {
 	}
 
 	// Check optional decoding
-	if !strings.Contains(codeStr, "if (dec.read_bool())") {
+	if !strings.Contains(codeStr, "if (dec.read_presence())") {
 		t.Errorf("missing optional presence check in decode")
 	}
 }
@@ -1002,3 +1056,1064 @@ func TestGenerateCppNestedStruct(t *testing.T) {
 		t.Errorf("missing nested field access in encoding")
 	}
 }
+
+func TestGenerateGoMap(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Config", TargetType: &schema.StructType{
+				Name: "Config",
+				Fields: []schema.Field{
+					{Name: "Limits", Type: &schema.MapType{
+						KeyType:   &schema.PrimitiveType{Name: "string"},
+						ValueType: &schema.PrimitiveType{Name: "int32"},
+					}},
+					{Name: "Flags", Type: &schema.MapType{
+						KeyType:   &schema.PrimitiveType{Name: "bool"},
+						ValueType: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "string"}},
+						Optional:  true,
+					}},
+				},
+			}},
+		},
+	}
+
+	code, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"Limits map[string]int32",
+		"Flags  *map[bool][]string",
+		"sort.Slice(",
+		"range [2]bool{false, true}",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestGenerateMapOtherLanguages(t *testing.T) {
+	newSchema := func() *schema.Schema {
+		return &schema.Schema{
+			Package: "test",
+			Messages: []schema.MessageType{
+				{Name: "Config", TargetType: &schema.StructType{
+					Name: "Config",
+					Fields: []schema.Field{
+						{Name: "Limits", Type: &schema.MapType{
+							KeyType:   &schema.PrimitiveType{Name: "string"},
+							ValueType: &schema.PrimitiveType{Name: "int32"},
+						}},
+					},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		generate func(*schema.Schema) ([]byte, error)
+		want     []string
+	}{
+		{"cpp", GenerateCpp, []string{"#include <map>", "std::map<std::string, int32_t> Limits"}},
+		{"csharp", GenerateCSharp, []string{"Dictionary<string, int> Limits", "Sort(FFireHelpers.CompareUtf8)"}},
+		{"java", GenerateJava, []string{"java.util.Map<String, Integer> Limits", "java.util.Arrays::compareUnsigned"}},
+		{"kotlin", generateKotlinTest, []string{"data class ConfigMessage(val limits: Map<String, Int>)", "w.stringMap(this.limits)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generate(newSchema())
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func enumTestSchema() *schema.Schema {
+	mode := &schema.EnumType{
+		Name: "Mode",
+		Base: "int8",
+		Values: []schema.EnumValue{
+			{Name: "Mono", Value: 1},
+			{Name: "Stereo", Value: 2},
+		},
+	}
+	device := &schema.StructType{
+		Name: "Device",
+		Fields: []schema.Field{
+			{Name: "Mode", Type: mode},
+			{Name: "Fallback", Type: &schema.EnumType{Name: mode.Name, Base: mode.Base, Values: mode.Values, Optional: true}},
+			{Name: "Modes", Type: &schema.ArrayType{ElementType: mode}},
+		},
+	}
+	return &schema.Schema{
+		Package:  "test",
+		Types:    []schema.Type{mode, device},
+		Messages: []schema.MessageType{{Name: "Device", TargetType: device}},
+	}
+}
+
+func TestGenerateGoEnum(t *testing.T) {
+	code, err := GenerateGo(enumTestSchema())
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"type Mode int8",
+		"Mono   Mode = 1",
+		"func (v Mode) IsValid() bool",
+		"func (v Mode) String() string",
+		"Fallback *Mode",
+		"Modes    []Mode",
+		"invalid Mode value %d",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestGenerateEnumOtherLanguages(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(*schema.Schema) ([]byte, error)
+		want     []string
+	}{
+		{"cpp", GenerateCpp, []string{"enum class Mode : int8_t", "inline bool is_valid(Mode v)"}},
+		{"csharp", GenerateCSharp, []string{"public enum Mode : sbyte", "public Mode? Fallback", "invalid Mode value"}},
+		{"java", GenerateJava, []string{"enum Mode {", "static Mode fromValue(byte value)", "Mode.fromValue("}},
+		{"kotlin", generateKotlinTest, []string{"enum class Mode(val value: Byte) {", "fun fromValue(value: Byte): Mode?", "val fallback: Mode? = null,", "invalid Mode value"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generate(enumTestSchema())
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func unionTestSchema() *schema.Schema {
+	circle := &schema.StructType{
+		Name:   "Circle",
+		Fields: []schema.Field{{Name: "R", Type: &schema.PrimitiveType{Name: "float32"}}},
+	}
+	shape := &schema.UnionType{
+		Name:     "Shape",
+		Variants: []schema.Type{circle, &schema.PrimitiveType{Name: "string"}},
+	}
+	drawing := &schema.StructType{
+		Name: "Drawing",
+		Fields: []schema.Field{
+			{Name: "Shapes", Type: &schema.ArrayType{ElementType: shape}},
+			{Name: "Hint", Type: &schema.UnionType{Name: shape.Name, Variants: shape.Variants, Optional: true}},
+		},
+	}
+	return &schema.Schema{
+		Package:  "test",
+		Types:    []schema.Type{shape, circle, drawing},
+		Messages: []schema.MessageType{{Name: "Drawing", TargetType: drawing}},
+	}
+}
+
+func TestGenerateGoUnion(t *testing.T) {
+	code, err := GenerateGo(unionTestSchema())
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"type Shape struct {",
+		"Circle *Circle",
+		"String *string `json:\"string,omitempty\"`",
+		"Shapes []Shape",
+		"Hint   *Shape",
+		"invalid Shape tag %d",
+		"ffire: Shape has no variant set",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+func TestGenerateUnionOtherLanguages(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(*schema.Schema) ([]byte, error)
+		want     []string
+	}{
+		{"cpp", GenerateCpp, []string{"#include <variant>", "using Shape = std::variant<Circle, std::string>;", "invalid Shape tag "}},
+		{"csharp", GenerateCSharp, []string{"public sealed class Shape", "public Circle Circle { get => _circle; set { _circle = value; Tag = 1; } }", "invalid Shape tag {tag}"}},
+		{"java", GenerateJava, []string{"class Shape {", "public String string;", "invalid Shape tag "}},
+		{"kotlin", generateKotlinTest, []string{"sealed class Shape {", "data class Circle(val value: test.Circle) : Shape()", "data class String(val value: kotlin.String) : Shape()", "invalid Shape tag $tag"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generate(unionTestSchema())
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func bytesTestSchema() *schema.Schema {
+	image := &schema.StructType{
+		Name: "Image",
+		Fields: []schema.Field{
+			{Name: "Data", Type: &schema.PrimitiveType{Name: "bytes"}},
+			{Name: "Thumb", Type: &schema.PrimitiveType{Name: "bytes", Optional: true}},
+			{Name: "Tiles", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "bytes"}}},
+		},
+	}
+	return &schema.Schema{
+		Package:  "test",
+		Types:    []schema.Type{image},
+		Messages: []schema.MessageType{{Name: "Image", TargetType: image}},
+	}
+}
+
+func cachedTestSchema() *schema.Schema {
+	metric := &schema.StructType{
+		Name: "Metric",
+		Fields: []schema.Field{
+			{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}, Cached: true},
+			{Name: "Host", Type: &schema.PrimitiveType{Name: "string", Optional: true}, Cached: true},
+			{Name: "Tags", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "string"}}, Cached: true},
+			{Name: "Note", Type: &schema.PrimitiveType{Name: "string"}},
+			{Name: "Value", Type: &schema.PrimitiveType{Name: "float64"}},
+		},
+	}
+	metrics := &schema.ArrayType{ElementType: metric}
+	return &schema.Schema{
+		Package:  "test",
+		Types:    []schema.Type{metric},
+		Messages: []schema.MessageType{{Name: "Metrics", TargetType: metrics}},
+	}
+}
+
+func TestGenerateCachedStrings(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(*schema.Schema) ([]byte, error)
+		want     []string
+		uncached string
+	}{
+		{"java", GenerateJava, []string{"final class FFireStringCache {", "buf.put(FFireStringCache.encoded(Name));", "size += FFireStringCache.encoded(elem).length;"}, "Note.getBytes(StandardCharsets.UTF_8)"},
+		{"swift", generateSwiftNative, []string{"final class FFireStringCache", "encodeCachedString(&buffer, value.Name)", "for item in value.Tags { encodeCachedString(&buffer, item) }"}, "encodeString(&buffer, value.Note)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generate(cachedTestSchema())
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			for _, want := range append(tt.want, tt.uncached) {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+
+			// Schemas without @cached fields get no cache
+			plain := cachedTestSchema()
+			st := plain.Types[0].(*schema.StructType)
+			for i := range st.Fields {
+				st.Fields[i].Cached = false
+			}
+			code, err = tt.generate(plain)
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			if strings.Contains(string(code), "StringCache") {
+				t.Error("cache emitted for a schema without @cached fields")
+			}
+		})
+	}
+}
+
+// Go ignores @cached: the cache lookup costs more than copying the string
+func TestGenerateGoIgnoresCached(t *testing.T) {
+	code, err := GenerateGo(cachedTestSchema())
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"copy(buf[pos:], elem.Name)",
+		"copy(buf[pos:], *elem.Host)",
+		"copy(buf[pos:], elem.Note)",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"ffireWriteCachedString", "sync/atomic"} {
+		if strings.Contains(codeStr, unwanted) {
+			t.Errorf("unexpected %q", unwanted)
+		}
+	}
+}
+
+func TestGenerateGoBytes(t *testing.T) {
+	code, err := GenerateGo(bytesTestSchema())
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"Data  []byte",
+		"Thumb *[]byte",
+		"Tiles [][]byte",
+		"append([]byte(nil), ",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(codeStr, "utf8.Valid") {
+		t.Error("bytes must not be checked for UTF-8")
+	}
+}
+
+func TestGenerateBytesOtherLanguages(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(*schema.Schema) ([]byte, error)
+		want     []string
+	}{
+		{"cpp", GenerateCpp, []string{"std::vector<uint8_t> Data;", "std::optional<std::vector<uint8_t>> Thumb;", "read_bytes()"}},
+		{"csharp", GenerateCSharp, []string{"public byte[] Data", "new byte[length][]", "FFireHelpers.DecodeBytes(buffer, ref offset)"}},
+		{"java", GenerateJava, []string{"public byte[] Data;", "List<byte[]> Tiles", "private static byte[] decodeBytes(ByteBuffer buf)"}},
+		{"rust", generateRustNative, []string{"pub data: Vec<u8>,", "Option<Vec<u8>>", "Vec<Vec<u8>>"}},
+		{"kotlin", generateKotlinTest, []string{"val data: ByteArray,", "val thumb: ByteArray? = null,", "this.data.contentEquals(other.data)"}},
+		{"swift", generateSwiftNative, []string{"public var Data: Data", "public var Thumb: Data?", "func decodeBytes("}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generate(bytesTestSchema())
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+func fixedArrayTestSchema() *schema.Schema {
+	frame := &schema.StructType{
+		Name: "Frame",
+		Fields: []schema.Field{
+			{Name: "Samples", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "float32"}, Length: 16}},
+			{Name: "Flags", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "bool"}, Length: 3}},
+			{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+		},
+	}
+	return &schema.Schema{
+		Package:  "test",
+		Types:    []schema.Type{frame},
+		Messages: []schema.MessageType{{Name: "Frames", TargetType: &schema.ArrayType{ElementType: frame}}},
+	}
+}
+
+func TestGenerateGoFixedArray(t *testing.T) {
+	code, err := GenerateGo(fixedArrayTestSchema())
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"Samples [16]float32",
+		"Flags   [3]bool",
+		"data[pos:pos+64]",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(codeStr, "len(elem.Samples))") {
+		t.Error("fixed arrays must not write a length prefix")
+	}
+}
+
+func TestGenerateFixedArrayOtherLanguages(t *testing.T) {
+	tests := []struct {
+		name     string
+		generate func(*schema.Schema) ([]byte, error)
+		want     []string
+	}{
+		{"cpp", GenerateCpp, []string{"#include <array>", "std::array<float, 16> Samples;", "write_raw(elem.Samples.data(), 64)", "read_raw("}},
+		{"csharp", GenerateCSharp, []string{"public float[] Samples", "size += 64;", "MemoryMarshal.Cast<byte, float>(buffer.Slice(offset, 64)).ToArray()"}},
+		{"java", GenerateJava, []string{"public float[] Samples = new float[16];", "buf.asFloatBuffer().get(Samples);", "Samples must hold 16 elements"}},
+		{"rust", generateRustNative, []string{"pub samples: [f32; 16],", "pub flags: [bool; 3],", "std::array::from_fn("}},
+		{"kotlin", generateKotlinTest, []string{"val samples: FloatArray = FloatArray(16),", "val flags: BooleanArray = BooleanArray(3),", "w.fixed(it.size, 16)", "FloatArray(16) { r.float32() }"}},
+		{"swift", generateSwiftNative, []string{"let SamplesLen = 16", "size += 64", "must hold 16 elements"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.generate(fixedArrayTestSchema())
+			if err != nil {
+				t.Fatalf("generate failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(code), want) {
+					t.Errorf("missing %q", want)
+				}
+			}
+		})
+	}
+}
+
+// generateKotlinTest generates Kotlin in the schema's package.
+func generateKotlinTest(s *schema.Schema) ([]byte, error) {
+	return generateKotlinNative(s, s.Package)
+}
+
+func TestGenerateKotlin(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package demo
+
+type Device struct {
+	ID       int32
+	HTTPPort int16
+	In       string
+	Nick     *string
+	Limits   map[int32]*Device
+}
+
+type DeviceList []Device
+`))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := generateKotlinTest(s)
+	if err != nil {
+		t.Fatalf("generateKotlinNative failed: %v", err)
+	}
+	codeStr := string(code)
+
+	for _, want := range []string{
+		"package demo\n",
+		"class FFireException(message: String, val code: DecodeErrorCode) : Exception(message)",
+		"data class Device(",
+		"    val id: Int,",
+		"    val httpPort: Short,",
+		"    val `in`: String,",
+		"    val nick: String? = null,",
+		"w.map(this.limits, { k1 -> w.int32(k1) }) { x1 -> w.optional(x1) { x2 -> x2.writeTo(w) } }",
+		"limits = r.map({ r.int32() }, { r.optional { Device.readFrom(r) } }),",
+		"object DeviceListMessage {",
+		"fun encode(value: List<Device>): ByteArray {",
+		"fun decode(data: ByteArray): List<Device> {",
+	} {
+		if !strings.Contains(codeStr, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(codeStr, "java.") {
+		t.Error("Kotlin code must only use the standard library, to build for Kotlin/Native")
+	}
+}
+
+const kotlinRoundtripSchema = `package demo
+
+type Mode int8
+
+const (
+	Off Mode = 0
+	On  Mode = 1
+)
+
+type Shape interface {
+	int32 | string
+}
+
+type Limit struct {
+	Max   int64
+	Label *string
+}
+
+type Device struct {
+	ID       int32
+	HTTPPort int16
+	In       string
+	Nick     *string
+	Mode     Mode
+	Shape    Shape
+	Raw      bytes
+	Gains    []float32
+	Limits   map[int32]*Limit
+}
+
+type DeviceList []Device
+`
+
+// TestKotlinRoundtrip compiles the generated Kotlin with kotlinc, decodes
+// a message encoded by the reference encoder and checks it re-encodes to
+// the same bytes.
+func TestKotlinRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Kotlin code")
+	}
+	for _, tool := range []string{"kotlinc", "java"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+	s, err := parser.ParseBytes([]byte(kotlinRoundtripSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := generateKotlinTest(s)
+	if err != nil {
+		t.Fatalf("generateKotlinNative failed: %v", err)
+	}
+	s.Canonicalize()
+	data, err := fixture.Convert(s, "DeviceList", []byte(`[
+		{"ID": 1, "HTTPPort": 80, "In": "in", "Nick": "n", "Mode": "On", "Shape": {"string": "sq"}, "Raw": "AQID", "Gains": [0.5, -2],
+		 "Limits": {"7": {"Max": -5, "Label": "l"}, "3": null}},
+		{"ID": 9, "HTTPPort": 0, "In": "ü", "Mode": "Off", "Shape": {"int32": -4}, "Raw": "", "Gains": [], "Limits": {}}
+	]`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"Demo.kt": string(code),
+		"Main.kt": `import demo.*
+
+fun main(args: Array<String>) {
+    val data = args[0].chunked(2).map { it.toInt(16).toByte() }.toByteArray()
+    val devices = DeviceListMessage.decode(data)
+    val first = devices[0]
+    check(first.nick == "n" && first.mode == Mode.On && first.shape == Shape.String("sq")) { first.toString() }
+    check(first.limits[7] == Limit(-5L, "l") && first.limits.containsKey(3) && first.limits[3] == null) { first.toString() }
+    check(devices[1].nick == null && devices[1].` + "`in`" + ` == "\u00fc") { devices[1].toString() }
+    check(DeviceListMessage.encode(devices).contentEquals(data)) { "re-encoded bytes differ" }
+    println("ok")
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jar := filepath.Join(dir, "demo.jar")
+	if out, err := exec.Command("kotlinc", filepath.Join(dir, "Demo.kt"), filepath.Join(dir, "Main.kt"), "-include-runtime", "-d", jar).CombinedOutput(); err != nil {
+		t.Fatalf("kotlinc failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command("java", "-jar", jar, hex.EncodeToString(data)).CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
+		t.Errorf("java: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateKotlinOkio(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package demo
+
+type Device struct {
+	Name string
+}
+
+type DeviceList []Device
+
+type Config struct {
+	Gain float32
+}
+`))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	out := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "kotlin", OutputDir: out, KotlinOkio: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(out, "kotlin", filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	okio := read("src/commonMain/kotlin/demo/DemoOkio.kt")
+	for _, want := range []string{
+		"package demo\n",
+		"import okio.BufferedSink\n",
+		"fun ConfigMessage.encodeTo(sink: BufferedSink) {",
+		"fun ConfigMessage.encodeToByteString(): ByteString = encode().toByteString()",
+		"fun ConfigMessage.Companion.decode(data: ByteString): ConfigMessage = decode(data.toByteArray())",
+		"fun ConfigMessage.Companion.decode(source: BufferedSource): ConfigMessage = decode(source.readByteArray())",
+		"fun DeviceListMessage.encodeTo(value: List<Device>, sink: BufferedSink) {",
+		"fun DeviceListMessage.decode(source: BufferedSource): List<Device> = decode(source.readByteArray())",
+	} {
+		if !strings.Contains(okio, want) {
+			t.Errorf("okio extensions lack %q", want)
+		}
+	}
+	build := read("build.gradle.kts")
+	for _, want := range []string{
+		`implementation("com.squareup.okio:okio:`,
+		`val xcf = XCFramework("Demo")`,
+		"iosSimulatorArm64()",
+		`baseName = "Demo"`,
+	} {
+		if !strings.Contains(build, want) {
+			t.Errorf("build.gradle.kts lacks %q", want)
+		}
+	}
+
+	config = &PackageConfig{Schema: s, Language: "kotlin", OutputDir: t.TempDir()}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "kotlin", "src", "commonMain", "kotlin", "demo", "DemoOkio.kt")); err == nil {
+		t.Error("okio extensions generated without KotlinOkio")
+	}
+
+	config = &PackageConfig{Schema: s, Language: "java", OutputDir: t.TempDir(), NoCompile: true, KotlinOkio: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--kotlin-okio is not supported for java") {
+		t.Errorf("GeneratePackage(java) = %v, want unsupported error", err)
+	}
+}
+
+// TestKotlinOkioBuild builds the JVM target of a --kotlin-okio package with
+// Gradle, which fetches the Kotlin plugin and okio.
+func TestKotlinOkioBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds a generated Gradle project")
+	}
+	if _, err := exec.LookPath("gradle"); err != nil {
+		t.Skip("gradle not installed")
+	}
+	s, err := parser.ParseBytes([]byte(kotlinRoundtripSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "kotlin", OutputDir: t.TempDir(), KotlinOkio: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	cmd := exec.Command("gradle", "--no-daemon", "-q", "compileKotlinJvm")
+	cmd.Dir = config.langDir("kotlin")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("gradle failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateSwiftConformances(t *testing.T) {
+	src := `package catalog
+
+type Kind int8
+
+const (
+	Mic  Kind = 0
+	Line Kind = 1
+)
+
+type Point struct {
+	X int32
+}
+
+type Shape interface {
+	Point | string
+}
+
+type Device struct {
+	Name  string
+	Kind  Kind
+	Shape Shape
+	Pos   Point
+}
+`
+	s, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := generateSwiftNative(s)
+	if err != nil {
+		t.Fatalf("generateSwiftNative failed: %v", err)
+	}
+	for _, want := range []string{
+		"public enum Kind: Int8, Equatable, Hashable, Sendable {",
+		"public enum Shape: Equatable, Hashable, Sendable {",
+		"public struct Point: Equatable, Hashable, Sendable {",
+		"public struct DeviceMessage: Equatable, Hashable, Sendable {",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(string(code), "Codable") {
+		t.Error("Codable conformances generated without the option")
+	}
+
+	code, err = generateSwiftNativeWithOptions(s, swiftOptions{Codable: true})
+	if err != nil {
+		t.Fatalf("generateSwiftNativeWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"extension Kind: Codable {",
+		`case "Line": self = .Line`,
+		`case .Mic: try container.encode("Mic")`,
+		"extension Shape: Codable {",
+		`case point = "Point"`,
+		"case .string: self = .string(try container.decode(String.self, forKey: .string))",
+		"extension Point: Codable {}",
+		"extension DeviceMessage: Codable {}",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+
+	config := &PackageConfig{Schema: s, Language: "kotlin", OutputDir: t.TempDir(), SwiftCodable: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--swift-codable is not supported for kotlin") {
+		t.Errorf("GeneratePackage(kotlin) = %v, want unsupported error", err)
+	}
+
+	t.Run("compile", func(t *testing.T) {
+		if _, err := exec.LookPath("swiftc"); err != nil {
+			t.Skip("swiftc not installed")
+		}
+		fixtureJSON := `{"Name": "mic", "Kind": "Line", "Shape": {"Point": {"X": 3}}, "Pos": {"X": -1}}`
+		data, err := fixture.Convert(s, "Device", []byte(fixtureJSON))
+		if err != nil {
+			t.Fatalf("Convert failed: %v", err)
+		}
+		dir := t.TempDir()
+		files := map[string]string{
+			"catalog.swift": string(code),
+			"message.bin":   string(data),
+			"message.json":  fixtureJSON,
+			"main.swift": `import Foundation
+
+let data = FileManager.default.contents(atPath: "message.bin")!
+let json = FileManager.default.contents(atPath: "message.json")!
+let msg = try decodeDeviceMessage(data)
+let fromJSON = try JSONDecoder().decode(DeviceMessage.self, from: json)
+precondition(msg == fromJSON, "\(msg) != \(fromJSON)")
+precondition(Set([msg, fromJSON]).count == 1)
+let again = try JSONDecoder().decode(DeviceMessage.self, from: JSONEncoder().encode(msg))
+precondition(again == msg, "\(again) != \(msg)")
+precondition(encodeDeviceMessage(msg) == data)
+print("ok")
+`,
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		bin := filepath.Join(dir, "catalog")
+		if out, err := exec.Command("swiftc", "-o", bin, filepath.Join(dir, "catalog.swift"), filepath.Join(dir, "main.swift")).CombinedOutput(); err != nil {
+			t.Fatalf("swiftc failed: %v\n%s", err, out)
+		}
+		cmd := exec.Command(bin)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
+			t.Errorf("%s: %v\n%s", bin, err, out)
+		}
+	})
+}
+
+func TestGenerateJavaFFM(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package demo
+
+type Device struct {
+	Name string
+}
+
+type DeviceList []Device
+
+type Config struct {
+	Gain float32
+}
+`))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := GenerateJava(s)
+	if err != nil {
+		t.Fatalf("GenerateJava failed: %v", err)
+	}
+	if strings.Contains(string(code), "MemorySegment") {
+		t.Error("MemorySegment methods generated without FFM")
+	}
+
+	code, err = GenerateJavaWithOptions(s, JavaOptions{FFM: true})
+	if err != nil {
+		t.Fatalf("GenerateJavaWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"import java.lang.foreign.MemorySegment;",
+		"public static ConfigMessage decode(MemorySegment data) {",
+		"public static DeviceListMessage decode(MemorySegment data) {",
+		"public MemorySegment encode(SegmentAllocator allocator) {",
+		"encodeTo(segment.asByteBuffer().order(ByteOrder.LITTLE_ENDIAN));",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(string(code), "static Device decode(MemorySegment") {
+		t.Error("helper classes should not get MemorySegment methods")
+	}
+
+	config := &PackageConfig{Schema: s, Language: "java", OutputDir: t.TempDir(), JavaFFM: true}
+	if pom := string(generateJavaPom(config)); !strings.Contains(pom, "<maven.compiler.release>22</maven.compiler.release>") {
+		t.Errorf("pom.xml does not build for Java 22:\n%s", pom)
+	}
+	config.Language = "kotlin"
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--java-ffm is not supported for kotlin") {
+		t.Errorf("GeneratePackage(kotlin) = %v, want unsupported error", err)
+	}
+}
+
+func TestGenerateJavaByteBuffer(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package demo
+
+type Device struct {
+	Name string
+}
+
+type DeviceList []Device
+
+type Config struct {
+	Gain float32
+}
+`))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := GenerateJava(s)
+	if err != nil {
+		t.Fatalf("GenerateJava failed: %v", err)
+	}
+	for _, want := range []string{
+		"public static ConfigMessage decode(ByteBuffer data) {",
+		"public static DeviceListMessage decode(ByteBuffer data) {",
+		"public void encode(ByteBuffer out) {",
+		"public int encodedSize() {",
+		"ByteBuffer buf = data.slice().order(ByteOrder.LITTLE_ENDIAN);",
+		"data.position(data.position() + buf.position());",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(string(code), "static Device decode(ByteBuffer") {
+		t.Error("helper classes should not get ByteBuffer methods")
+	}
+
+	config := &PackageConfig{Schema: s, Language: "java", OutputDir: t.TempDir(), CppSIMD: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--cpp-simd needs the C++ core") {
+		t.Errorf("GeneratePackage(java, CppSIMD) = %v, want C++ core error", err)
+	}
+}
+
+const godotTestSchema = `package arena
+
+type Kind int8
+
+const (
+	KindA Kind = iota
+	KindB
+)
+
+type Circle struct {
+	R float64
+}
+
+type Shape interface {
+	Circle | string
+}
+
+type Item struct {
+	HTTPPort int16
+	Kind     Kind
+	Scores   []int32
+	Shape    Shape
+	Center   Circle
+	Hint     *Circle
+	Label    *string
+}
+
+type Items []Item
+`
+
+func TestGenerateGodotPackage(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(godotTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "godot", OutputDir: t.TempDir(), Namespace: "arena", Version: "1.0.0"}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	dir := filepath.Join(config.OutputDir, "godot")
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	header := read("src/arena_godot.hpp")
+	for _, want := range []string{
+		`#include "generated.hpp"`,
+		"class ArenaKind : public Object {",
+		"class ArenaItem : public RefCounted {",
+		"    int64_t http_port_ = 0;",
+		"    PackedInt32Array scores_;",
+		"    Dictionary shape_;",
+		"    Ref<ArenaCircle> hint_;",
+		"    Variant label_;",
+		"    void to_native(::arena::Item &r_value) const;",
+		"class ArenaItems : public Object {",
+		"    static Array decode(const PackedByteArray &p_data);",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header missing %q", want)
+		}
+	}
+	if strings.Contains(header, "Ref<ArenaItem> decode") {
+		t.Error("Item is not a message but has decode")
+	}
+
+	source := read("src/arena_godot.cpp")
+	for _, want := range []string{
+		`ClassDB::bind_integer_constant(get_class_static(), "Kind", "KindB", 1);`,
+		`ADD_PROPERTY(PropertyInfo(Variant::INT, "kind", PROPERTY_HINT_ENUM, "KindA:0,KindB:1"), "set_kind", "get_kind");`,
+		`ClassDB::bind_static_method(get_class_static(), D_METHOD("decode", "data"), &ArenaItems::decode);`,
+		"return to_godot(::arena::decode_item_message(p_data.ptr(), static_cast<size_t>(p_data.size())));",
+		`out["Circle"] = to_godot(std::get<0>(p_value));`,
+		"    center_.instantiate();",
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("source missing %q", want)
+		}
+	}
+	if register := read("src/register_types.cpp"); !strings.Contains(register, "GDREGISTER_CLASS(ArenaItem);") || !strings.Contains(register, "arena_library_init(") {
+		t.Errorf("register_types.cpp does not register the classes:\n%s", register)
+	}
+	if ext := read("addons/arena/arena.gdextension"); !strings.Contains(ext, `entry_symbol = "arena_library_init"`) || !strings.Contains(ext, "res://addons/arena/bin/libarena.linux.template_debug.x86_64.so") {
+		t.Errorf("arena.gdextension:\n%s", ext)
+	}
+	if sconstruct := read("SConstruct"); !strings.Contains(sconstruct, `ARGUMENTS.setdefault("disable_exceptions", "no")`) {
+		t.Errorf("SConstruct leaves exceptions disabled:\n%s", sconstruct)
+	}
+}
+
+// TestGodotPackageBuilds builds a generated extension with scons. Building
+// godot-cpp takes minutes and needs a checkout at the tag the package
+// targets, so the test only runs when GODOT_CPP names one.
+func TestGodotPackageBuilds(t *testing.T) {
+	godotCpp := os.Getenv("GODOT_CPP")
+	if godotCpp == "" {
+		t.Skip("GODOT_CPP does not name a godot-cpp checkout")
+	}
+	if _, err := exec.LookPath("scons"); err != nil {
+		t.Skip("scons not installed")
+	}
+	s, err := parser.ParseBytes([]byte(godotTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "godot", OutputDir: t.TempDir(), Namespace: "arena", Version: "1.0.0"}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	dir := filepath.Join(config.OutputDir, "godot")
+	godotCpp, err = filepath.Abs(godotCpp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(godotCpp, filepath.Join(dir, "godot-cpp")); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("scons")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("scons failed: %v\n%s", err, out)
+	}
+	if libs, _ := filepath.Glob(filepath.Join(dir, "addons", "arena", "bin", "libarena.*")); len(libs) == 0 {
+		t.Error("scons built no library in addons/arena/bin")
+	}
+}
+
+// TestJavaFFMCompiles compiles a --java-ffm package with javac for Java 22,
+// the release its pom targets.
+func TestJavaFFMCompiles(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package demo
+
+type Device struct {
+	Name  string
+	Tags  []string
+	Gain  *float32
+	Props map[string]int32
+}
+
+type DeviceList []Device
+
+type Config struct {
+	Gain    float32
+	Primary Device
+}
+`))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	dir := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "java", OutputDir: dir, JavaFFM: true, Logger: testLogger{t}}
+	if err := generateJavaPackage(config); err != nil {
+		t.Fatalf("generateJavaPackage failed: %v", err)
+	}
+	if !hasJava() {
+		t.Skip("javac not installed")
+	}
+	classes := filepath.Join(dir, "classes")
+	out, err := exec.Command("javac", "--release", "22", "-d", classes, filepath.Join(dir, "src", "demo", "demo.java")).CombinedOutput()
+	if err != nil && strings.Contains(string(out), "release version 22 not supported") {
+		t.Skipf("javac does not support Java 22: %s", out)
+	}
+	if err != nil {
+		t.Fatalf("javac failed: %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(classes, "demo", "ConfigMessage.class")); err != nil {
+		t.Errorf("ConfigMessage not compiled: %v", err)
+	}
+}
@@ -48,16 +48,18 @@ func generateZigMetadataOrchestrated(config *PackageConfig, paths *PackagePaths)
 }
 
 func printZigInstructions(config *PackageConfig, paths *PackagePaths) {
-	fmt.Printf("\n✅ Zig package ready at: %s\n\n", paths.Root)
-	fmt.Println("Build:")
-	fmt.Printf("  cd %s\n", paths.Root)
-	fmt.Println("  zig build")
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Printf("  const %s = @import(\"%s\");\n", config.Namespace, config.Namespace)
-	fmt.Println("  const msg = Message.decode(data);")
-	fmt.Println("  const encoded = msg.encode();")
-	fmt.Println()
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ Zig package ready at: %s\n\n", paths.Root)
+	fmt.Fprintln(&b, "Build:")
+	fmt.Fprintf(&b, "  cd %s\n", paths.Root)
+	fmt.Fprintln(&b, "  zig build")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "Usage:")
+	fmt.Fprintf(&b, "  const %s = @import(\"%s\");\n", config.Namespace, config.Namespace)
+	fmt.Fprintln(&b, "  const msg = Message.decode(data);")
+	fmt.Fprintln(&b, "  const encoded = msg.encode();")
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
 }
 
 func generateZigFiles(config *PackageConfig, srcDir, libDir string) error {
@@ -77,6 +79,7 @@ func generateZigFiles(config *PackageConfig, srcDir, libDir string) error {
 	buf.WriteString("    EncodeFailed,\n")
 	buf.WriteString("    NullPointer,\n")
 	buf.WriteString("};\n\n")
+	buf.WriteString(zigDecodeErrorCode(config.Schema.Package))
 
 	// Generate extern declarations and wrapper types for each message
 	for _, msg := range config.Schema.Messages {
@@ -90,7 +93,7 @@ func generateZigFiles(config *PackageConfig, srcDir, libDir string) error {
 		return fmt.Errorf("failed to write Zig library: %w", err)
 	}
 
-	fmt.Printf("✓ Generated %s.zig\n", config.Namespace)
+	config.infof("✓ Generated %s.zig\n", config.Namespace)
 	return nil
 }
 
@@ -107,6 +110,7 @@ func generateZigMessageBindings(buf *bytes.Buffer, s *schema.Schema, msg *schema
 	fmt.Fprintf(buf, "extern fn %s_free_error(err: [*:0]u8) void;\n\n", baseName)
 
 	// Wrapper struct
+	buf.WriteString(lineDoc("", "///", msg.Doc))
 	fmt.Fprintf(buf, "pub const %s = struct {\n", typeName)
 	buf.WriteString("    handle: *anyopaque,\n\n")
 
@@ -207,7 +211,7 @@ func generateZigBuild(config *PackageConfig, rootDir string) error {
 		return fmt.Errorf("failed to write build.zig: %w", err)
 	}
 
-	fmt.Printf("✓ Generated build.zig\n")
+	config.infof("✓ Generated build.zig\n")
 	return nil
 }
 
@@ -241,12 +245,13 @@ func generateZigReadme(config *PackageConfig, rootDir string) error {
 	buf.WriteString("## Requirements\n\n")
 	buf.WriteString("- Zig 0.11.0 or later\n")
 	buf.WriteString("- The native library (`lib/lib*.dylib` or `lib/lib*.so`)\n")
+	buf.WriteString(readmeLicenseSection(config))
 
 	filePath := filepath.Join(rootDir, "README.md")
 	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
 		return fmt.Errorf("failed to write README.md: %w", err)
 	}
 
-	fmt.Printf("✓ Generated README.md\n")
+	config.infof("✓ Generated README.md\n")
 	return nil
 }
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/semver"
+)
+
+// goModuleGoVersion is the go directive of generated modules: generated
+// code uses unsafe.SliceData, which needs Go 1.20.
+const goModuleGoVersion = "1.20"
+
+// checkGoModule validates config.GoModule before anything is generated.
+// Releases from v2 on need the major version suffix in the module path
+// (https://go.dev/ref/mod#major-version-suffixes).
+func checkGoModule(config *PackageConfig) error {
+	if config.GoModule == "" {
+		return nil
+	}
+	if canonicalLanguage(config.Language) != "go" {
+		return fmt.Errorf("--go-module only applies to Go packages, not %s", config.Language)
+	}
+	if err := (Coordinates{Go: config.GoModule}).Validate(); err != nil {
+		return fmt.Errorf("go module: %w", err)
+	}
+	if config.Coordinates.Go != "" && config.Coordinates.Go != config.GoModule {
+		return fmt.Errorf("go module %q differs from packages.go %q; the package is the module root, so they must match", config.GoModule, config.Coordinates.Go)
+	}
+
+	v, err := semver.Parse(config.Version)
+	if err != nil {
+		return err
+	}
+	suffix := ""
+	if i := strings.LastIndex(config.GoModule, "/v"); i >= 0 {
+		suffix = config.GoModule[i+1:]
+	}
+	want := fmt.Sprintf("v%d", v.Major)
+	switch {
+	case v.Major >= 2 && suffix != want:
+		return fmt.Errorf("go module %q: version %s needs the module path to end in /%s", config.GoModule, config.Version, want)
+	case v.Major < 2 && isMajorSuffix(suffix):
+		return fmt.Errorf("go module %q: version %s must not have a major version suffix", config.GoModule, config.Version)
+	}
+	return nil
+}
+
+// isMajorSuffix reports whether s is a major version suffix such as "v2".
+func isMajorSuffix(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateGoModule writes go.mod and doc.go next to the generated Go
+// package, making the output directory a module of its own. The code only
+// imports the standard library, so there is no go.sum.
+func generateGoModule(config *PackageConfig) error {
+	gomod := fmt.Sprintf("module %s\n\ngo %s\n", config.GoModule, goModuleGoVersion)
+	gomodPath := filepath.Join(config.OutputDir, "go.mod")
+	if err := os.WriteFile(gomodPath, []byte(gomod), 0644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+
+	var names []string
+	for _, msg := range config.Schema.Messages {
+		names = append(names, msg.Name)
+	}
+	doc := fmt.Sprintf(`// Code generated by ffire. DO NOT EDIT.
+
+// Package %s encodes and decodes the messages of the %s ffire schema,
+// version %s: %s.
+package %s
+`, config.Schema.Package, config.Schema.Package, config.Version, strings.Join(names, ", "), config.Schema.Package)
+	docPath := filepath.Join(config.OutputDir, "doc.go")
+	if err := os.WriteFile(docPath, []byte(doc), 0644); err != nil {
+		return fmt.Errorf("failed to write doc.go: %w", err)
+	}
+
+	config.infof("✓ Generated go.mod: module %s (tag the release v%s)\n", config.GoModule, config.Version)
+	return nil
+}
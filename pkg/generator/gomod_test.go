@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestGoModule(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/complex.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	out := t.TempDir()
+	config := &PackageConfig{
+		Schema:     s,
+		Language:   "go",
+		OutputDir:  out,
+		GoModule:   "example.com/acme/audio/v2",
+		Version:    "2.1.0",
+		BuildRules: BuildRulesBazel,
+		WithTests:  true,
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	gomod, err := os.ReadFile(filepath.Join(out, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "module example.com/acme/audio/v2\n\ngo " + goModuleGoVersion + "\n"; string(gomod) != want {
+		t.Errorf("go.mod =\n%s\nwant:\n%s", gomod, want)
+	}
+	doc, err := os.ReadFile(filepath.Join(out, "doc.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(doc), "version 2.1.0") || !strings.Contains(string(doc), "package "+s.Package+"\n") {
+		t.Errorf("doc.go:\n%s", doc)
+	}
+	// The module path is the Bazel importpath too
+	rules, err := os.ReadFile(filepath.Join(out, "BUILD.bazel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rules), `importpath = "example.com/acme/audio/v2"`) {
+		t.Errorf("BUILD.bazel:\n%s", rules)
+	}
+
+	// The output is a module of its own that vets and tests cleanly
+	if testing.Short() {
+		t.Skip("skipping go vet/test of the generated module in short mode")
+	}
+	for _, args := range [][]string{{"vet", "./..."}, {"test", "./..."}} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = out
+		cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("go %s failed: %v\n%s", strings.Join(args, " "), err, output)
+		}
+	}
+}
+
+func TestGoModuleErrors(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tests := []struct {
+		name   string
+		config PackageConfig
+		want   string
+	}{
+		{"not go", PackageConfig{Language: "rust", GoModule: "example.com/audio"}, "only applies to Go"},
+		{"invalid path", PackageConfig{Language: "go", GoModule: "example.com//audio"}, "go module: packages.go: invalid name"},
+		{"missing suffix", PackageConfig{Language: "go", GoModule: "example.com/audio", Version: "2.0.0"}, "needs the module path to end in /v2"},
+		{"wrong suffix", PackageConfig{Language: "go", GoModule: "example.com/audio/v2", Version: "3.1.0"}, "end in /v3"},
+		{"suffix on v1", PackageConfig{Language: "go", GoModule: "example.com/audio/v1", Version: "1.0.0"}, "must not have a major version suffix"},
+		{"differs from packages.go", PackageConfig{Language: "go", GoModule: "example.com/audio",
+			Coordinates: Coordinates{Go: "example.com/other"}}, "differs from packages.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Schema = s
+			config.OutputDir = t.TempDir()
+			config.NoCompile = true
+			if err := GeneratePackage(&config); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
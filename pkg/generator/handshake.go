@@ -0,0 +1,403 @@
+package generator
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/shaban/ffire/internal/wire"
+	"github.com/shaban/ffire/pkg/store"
+)
+
+// A handshake is a small message that hosts and plugins exchange before
+// any other, so a peer built from a different schema is rejected with a
+// clear error instead of mis-decoding:
+//
+//	"FFHS" | uint16 wire version | 32-byte schema fingerprint |
+//	uint16 feature count | count × (uint16 length, UTF-8 feature name)
+//
+// Peers are compatible when the wire version and the fingerprint
+// (store.SchemaFingerprint) match. The enabled feature flags are carried
+// to explain a fingerprint mismatch.
+
+// handshakeLanguages lists the languages with a handshake emitter.
+var handshakeLanguages = map[string]bool{
+	"go":    true,
+	"c":     true,
+	"cpp":   true,
+	"c++":   true,
+	"swift": true,
+}
+
+// supportsHandshake reports whether --handshake works for lang.
+func supportsHandshake(lang string) bool {
+	return handshakeLanguages[strings.ToLower(lang)]
+}
+
+type handshakeData struct {
+	Package     string
+	WireVersion int
+	Fingerprint string   // hex, for comments
+	Bytes       string   // fingerprint as comma-separated byte literals
+	Features    []string // string literals in the target language
+}
+
+// generateHandshake writes the handshake message and negotiation helpers
+// next to the generated package.
+func generateHandshake(config *PackageConfig) error {
+	s := config.Schema
+	fingerprint := store.SchemaFingerprint(s)
+	sum, err := hex.DecodeString(fingerprint)
+	if err != nil {
+		return err
+	}
+	literals := make([]string, len(sum))
+	for i, b := range sum {
+		literals[i] = fmt.Sprintf("0x%02x", b)
+	}
+	data := &handshakeData{
+		Package:     s.Package,
+		WireVersion: wire.Version,
+		Fingerprint: fingerprint,
+		Bytes:       strings.Join(literals, ", "),
+	}
+
+	var path string
+	var tmpl *template.Template
+	var quote func(string) string
+	switch lang := strings.ToLower(config.Language); lang {
+	case "go":
+		path = filepath.Join(config.OutputDir, "handshake.go")
+		tmpl = goHandshakeTemplate
+		quote = strconv.Quote
+	case "c", "cpp", "c++":
+		path = filepath.Join(config.langDir(config.Language), "include", "handshake.hpp")
+		tmpl = cppHandshakeTemplate
+		quote = func(s string) string { return quoteHandshakeString(s, "\\%03o") }
+	case "swift":
+		path = filepath.Join(config.langDir(SwiftLayout.Name), "Sources", config.Namespace, "Handshake.swift")
+		tmpl = swiftHandshakeTemplate
+		quote = func(s string) string { return quoteHandshakeString(s, "\\u{%x}") }
+	default:
+		return fmt.Errorf("handshakes are not supported for %s", config.Language)
+	}
+	for _, f := range s.EnabledFeatures {
+		data.Features = append(data.Features, quote(f))
+	}
+
+	if err := writeRoundtripFile(path, tmpl, data); err != nil {
+		return err
+	}
+	config.infof("✓ Generated handshake (fingerprint %s): %s\n", fingerprint[:16], path)
+	return nil
+}
+
+// quoteHandshakeString returns s as a C++ or Swift string literal, with
+// control characters written using escape, a format for their code.
+func quoteHandshakeString(s, escape string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, escape, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+var goHandshakeTemplate = template.Must(template.New("go").Parse(`// Code generated by ffire. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// WireVersion is the ffire wire format version this package encodes.
+const WireVersion = {{.WireVersion}}
+
+// SchemaFingerprint identifies the wire layout of every message in this
+// package. Peers with the same fingerprint encode and decode them alike.
+//
+// Hex: {{.Fingerprint}}
+var SchemaFingerprint = [32]byte{ {{- .Bytes -}} }
+
+// SchemaFeatures lists the feature flags the package was generated with.
+var SchemaFeatures = []string{ {{- range $i, $f := .Features}}{{if $i}}, {{end}}{{$f}}{{end -}} }
+
+// ErrIncompatiblePeer reports a handshake from a peer that cannot exchange
+// messages with this package.
+var ErrIncompatiblePeer = errors.New("incompatible peer")
+
+// handshakeMagic starts every handshake message.
+const handshakeMagic = "FFHS"
+
+// EncodeHandshake returns the handshake message describing this package,
+// to send to a peer before any other message.
+func EncodeHandshake() []byte {
+	buf := make([]byte, 0, 40)
+	buf = append(buf, handshakeMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, WireVersion)
+	buf = append(buf, SchemaFingerprint[:]...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(SchemaFeatures)))
+	for _, f := range SchemaFeatures {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(f)))
+		buf = append(buf, f...)
+	}
+	return buf
+}
+
+// CheckHandshake checks the handshake message of a peer. It returns nil if
+// the peer exchanges messages with the same wire layout, and otherwise an
+// error wrapping ErrIncompatiblePeer that says what differs.
+func CheckHandshake(peer []byte) error {
+	if len(peer) < 40 || string(peer[:4]) != handshakeMagic {
+		return fmt.Errorf("%w: not an ffire handshake", ErrIncompatiblePeer)
+	}
+	if v := binary.LittleEndian.Uint16(peer[4:]); v != WireVersion {
+		return fmt.Errorf("%w: peer uses wire format version %d, this package version %d", ErrIncompatiblePeer, v, WireVersion)
+	}
+	features, ok := decodeHandshakeFeatures(peer[38:])
+	if !ok {
+		return fmt.Errorf("%w: malformed handshake", ErrIncompatiblePeer)
+	}
+	if bytes.Equal(peer[6:38], SchemaFingerprint[:]) {
+		return nil
+	}
+	reason := "schema fingerprints differ"
+	if !sameFeatures(features, SchemaFeatures) {
+		reason += fmt.Sprintf(" (peer features [%s], this package [%s])", strings.Join(features, " "), strings.Join(SchemaFeatures, " "))
+	}
+	return fmt.Errorf("%w: %s", ErrIncompatiblePeer, reason)
+}
+
+func decodeHandshakeFeatures(data []byte) ([]string, bool) {
+	count := int(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+	features := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if len(data) < 2 {
+			return nil, false
+		}
+		n := int(binary.LittleEndian.Uint16(data))
+		if len(data)-2 < n {
+			return nil, false
+		}
+		features = append(features, string(data[2:2+n]))
+		data = data[2+n:]
+	}
+	return features, true
+}
+
+func sameFeatures(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, f := range a {
+		seen[f] = true
+	}
+	for _, f := range b {
+		if !seen[f] {
+			return false
+		}
+	}
+	return true
+}
+`))
+
+var cppHandshakeTemplate = template.Must(template.New("cpp").Parse(`// Code generated by ffire. DO NOT EDIT.
+#pragma once
+
+#include <algorithm>
+#include <cstdint>
+#include <cstring>
+#include <stdexcept>
+#include <string>
+#include <vector>
+
+namespace {{.Package}} {
+
+/// ffire wire format version this package encodes.
+inline constexpr uint16_t wire_version = {{.WireVersion}};
+
+/// Identifies the wire layout of every message in this package. Peers with
+/// the same fingerprint encode and decode them alike.
+///
+/// Hex: {{.Fingerprint}}
+inline constexpr uint8_t schema_fingerprint[32] = { {{- .Bytes -}} };
+
+/// Feature flags the package was generated with.
+inline const std::vector<std::string> schema_features = { {{- range $i, $f := .Features}}{{if $i}}, {{end}}{{$f}}{{end -}} };
+
+/// Thrown by check_handshake for a peer that cannot exchange messages with
+/// this package.
+class incompatible_peer : public std::runtime_error {
+public:
+    using std::runtime_error::runtime_error;
+};
+
+/// Handshake message describing this package, to send to a peer before any
+/// other message.
+inline std::vector<uint8_t> encode_handshake() {
+    std::vector<uint8_t> buf = {'F', 'F', 'H', 'S', uint8_t(wire_version), uint8_t(wire_version >> 8)};
+    buf.insert(buf.end(), schema_fingerprint, schema_fingerprint + 32);
+    buf.push_back(uint8_t(schema_features.size()));
+    buf.push_back(uint8_t(schema_features.size() >> 8));
+    for (const auto& f : schema_features) {
+        buf.push_back(uint8_t(f.size()));
+        buf.push_back(uint8_t(f.size() >> 8));
+        buf.insert(buf.end(), f.begin(), f.end());
+    }
+    return buf;
+}
+
+/// Checks the handshake message of a peer. Throws incompatible_peer, saying
+/// what differs, unless the peer exchanges messages with the same wire
+/// layout.
+inline void check_handshake(const uint8_t* data, size_t size) {
+    if (size < 40 || std::memcmp(data, "FFHS", 4) != 0) {
+        throw incompatible_peer("not an ffire handshake");
+    }
+    uint16_t version = uint16_t(data[4] | data[5] << 8);
+    if (version != wire_version) {
+        throw incompatible_peer("peer uses wire format version " + std::to_string(version) +
+                                ", this package version " + std::to_string(wire_version));
+    }
+    size_t count = size_t(data[38] | data[39] << 8);
+    size_t pos = 40;
+    std::vector<std::string> features;
+    for (size_t i = 0; i < count; i++) {
+        if (size - pos < 2) {
+            throw incompatible_peer("malformed handshake");
+        }
+        size_t len = size_t(data[pos] | data[pos + 1] << 8);
+        pos += 2;
+        if (size - pos < len) {
+            throw incompatible_peer("malformed handshake");
+        }
+        features.emplace_back(reinterpret_cast<const char*>(data + pos), len);
+        pos += len;
+    }
+    if (std::memcmp(data + 6, schema_fingerprint, 32) == 0) {
+        return;
+    }
+    std::string reason = "schema fingerprints differ";
+    std::vector<std::string> theirs = features, ours = schema_features;
+    std::sort(theirs.begin(), theirs.end());
+    std::sort(ours.begin(), ours.end());
+    if (theirs != ours) {
+        auto join = [](const std::vector<std::string>& names) {
+            std::string out;
+            for (const auto& name : names) {
+                out += (out.empty() ? "" : " ") + name;
+            }
+            return out;
+        };
+        reason += " (peer features [" + join(features) + "], this package [" + join(schema_features) + "])";
+    }
+    throw incompatible_peer(reason);
+}
+
+inline void check_handshake(const std::vector<uint8_t>& data) {
+    check_handshake(data.data(), data.size());
+}
+
+} // namespace {{.Package}}
+`))
+
+var swiftHandshakeTemplate = template.Must(template.New("swift").Parse(`// Code generated by ffire. DO NOT EDIT.
+
+import Foundation
+
+/// The ffire wire format version this package encodes.
+public let wireVersion: UInt16 = {{.WireVersion}}
+
+/// Identifies the wire layout of every message in this package. Peers with
+/// the same fingerprint encode and decode them alike.
+///
+/// Hex: {{.Fingerprint}}
+public let schemaFingerprint: [UInt8] = [{{.Bytes}}]
+
+/// The feature flags the package was generated with.
+public let schemaFeatures: [String] = [{{range $i, $f := .Features}}{{if $i}}, {{end}}{{$f}}{{end}}]
+
+/// Thrown by ` + "`checkHandshake`" + ` for a peer that cannot exchange messages with
+/// this package.
+public struct IncompatiblePeerError: Error, CustomStringConvertible {
+    public let reason: String
+
+    public var description: String { "incompatible peer: \(reason)" }
+}
+
+/// Returns the handshake message describing this package, to send to a peer
+/// before any other message.
+public func encodeHandshake() -> Data {
+    var buf = Data("FFHS".utf8)
+    appendHandshakeUInt16(&buf, wireVersion)
+    buf.append(contentsOf: schemaFingerprint)
+    appendHandshakeUInt16(&buf, UInt16(schemaFeatures.count))
+    for feature in schemaFeatures {
+        let bytes = Array(feature.utf8)
+        appendHandshakeUInt16(&buf, UInt16(bytes.count))
+        buf.append(contentsOf: bytes)
+    }
+    return buf
+}
+
+/// Checks the handshake message of a peer. Throws ` + "`IncompatiblePeerError`" + `,
+/// saying what differs, unless the peer exchanges messages with the same
+/// wire layout.
+public func checkHandshake(_ peer: Data) throws {
+    let bytes = [UInt8](peer)
+    guard bytes.count >= 40, bytes[0..<4].elementsEqual("FFHS".utf8) else {
+        throw IncompatiblePeerError(reason: "not an ffire handshake")
+    }
+    let version = UInt16(bytes[4]) | UInt16(bytes[5]) << 8
+    guard version == wireVersion else {
+        throw IncompatiblePeerError(reason: "peer uses wire format version \(version), this package version \(wireVersion)")
+    }
+    let count = Int(bytes[38]) | Int(bytes[39]) << 8
+    var pos = 40
+    var features: [String] = []
+    for _ in 0..<count {
+        guard bytes.count - pos >= 2 else {
+            throw IncompatiblePeerError(reason: "malformed handshake")
+        }
+        let length = Int(bytes[pos]) | Int(bytes[pos + 1]) << 8
+        pos += 2
+        guard bytes.count - pos >= length else {
+            throw IncompatiblePeerError(reason: "malformed handshake")
+        }
+        features.append(String(decoding: bytes[pos..<pos + length], as: UTF8.self))
+        pos += length
+    }
+    if bytes[6..<38].elementsEqual(schemaFingerprint) {
+        return
+    }
+    var reason = "schema fingerprints differ"
+    if Set(features) != Set(schemaFeatures) {
+        reason += " (peer features [\(features.joined(separator: " "))], this package [\(schemaFeatures.joined(separator: " "))])"
+    }
+    throw IncompatiblePeerError(reason: reason)
+}
+
+private func appendHandshakeUInt16(_ buf: inout Data, _ value: UInt16) {
+    buf.append(UInt8(value & 0xff))
+    buf.append(UInt8(value >> 8))
+}
+`))
@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/store"
+)
+
+const handshakeTestSchema = `package audio
+
+type Plugin struct {
+	Name    string
+	Latency int32 // @feature("v2")
+}
+`
+
+func parseHandshakeSchema(t *testing.T, features ...string) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseReader(strings.NewReader(handshakeTestSchema), parser.Options{Features: features})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	return s
+}
+
+func TestGenerateHandshake(t *testing.T) {
+	tests := []struct {
+		lang string
+		file string
+		want []string
+	}{
+		{"go", "handshake.go", []string{"package audio", "func CheckHandshake(peer []byte) error", `var SchemaFeatures = []string{"v2"}`}},
+		{"cpp", "cpp/include/handshake.hpp", []string{"namespace audio {", "inline void check_handshake(const uint8_t* data, size_t size)", `schema_features = {"v2"};`}},
+		{"swift", "swift/Sources/audio/Handshake.swift", []string{"public func checkHandshake(_ peer: Data) throws", `public let schemaFeatures: [String] = ["v2"]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			s := parseHandshakeSchema(t, "v2")
+			fingerprint := store.SchemaFingerprint(s)
+			config := &PackageConfig{Schema: s, Language: tt.lang, OutputDir: t.TempDir(), Handshake: true, NoCompile: true, NoFormat: true}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(config.OutputDir, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := string(data)
+			for _, want := range append(tt.want, "Hex: "+fingerprint) {
+				if !strings.Contains(code, want) {
+					t.Errorf("%s lacks %s:\n%s", tt.file, want, code)
+				}
+			}
+		})
+	}
+
+	config := &PackageConfig{Schema: parseHandshakeSchema(t), Language: "rust", OutputDir: t.TempDir(), Handshake: true, NoCompile: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--handshake is not supported for rust") {
+		t.Errorf("GeneratePackage(rust) = %v, want unsupported error", err)
+	}
+}
+
+func TestQuoteHandshakeString(t *testing.T) {
+	if got := quoteHandshakeString("a\"b\\c\n", "\\%03o"); got != `"a\"b\\c\012"` {
+		t.Errorf("C++ literal = %s", got)
+	}
+	if got := quoteHandshakeString("é\t", "\\u{%x}"); got != `"é\u{9}"` {
+		t.Errorf("Swift literal = %s", got)
+	}
+}
+
+// TestGoHandshakeNegotiates checks generated Go handshakes between a host
+// and plugins built with and without a feature flag.
+func TestGoHandshakeNegotiates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	tmpDir := t.TempDir()
+	for _, pkg := range []struct {
+		dir      string
+		features []string
+	}{{"host", []string{"v2"}}, {"plugin", nil}} {
+		config := &PackageConfig{
+			Schema:    parseHandshakeSchema(t, pkg.features...),
+			Language:  "go",
+			OutputDir: filepath.Join(tmpDir, pkg.dir),
+			Handshake: true,
+			NoCompile: true,
+		}
+		if err := GeneratePackage(config); err != nil {
+			t.Fatalf("GeneratePackage(%s) failed: %v", pkg.dir, err)
+		}
+	}
+	files := map[string]string{
+		"go.mod": "module handshake\n\ngo 1.21\n",
+		"main.go": `package main
+
+import (
+	"errors"
+	"fmt"
+
+	host "handshake/host"
+	plugin "handshake/plugin"
+)
+
+func main() {
+	fmt.Println(host.CheckHandshake(host.EncodeHandshake()))
+
+	err := host.CheckHandshake(plugin.EncodeHandshake())
+	fmt.Println(err, errors.Is(err, host.ErrIncompatiblePeer))
+
+	peer := host.EncodeHandshake()
+	peer[4] = 9
+	fmt.Println(host.CheckHandshake(peer))
+	fmt.Println(host.CheckHandshake(host.EncodeHandshake()[:42]))
+	fmt.Println(host.CheckHandshake([]byte("hello")))
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+
+	want := strings.Join([]string{
+		"<nil>",
+		"incompatible peer: schema fingerprints differ (peer features [], this package [v2]) true",
+		"incompatible peer: peer uses wire format version 9, this package version 1",
+		"incompatible peer: malformed handshake",
+		"incompatible peer: not an ffire handshake",
+	}, "\n") + "\n"
+	if string(out) != want {
+		t.Errorf("unexpected output\nwant:\n%s\ngot:\n%s", want, out)
+	}
+}
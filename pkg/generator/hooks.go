@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Hook is a shell command run before or after package generation, such as
+// a formatter over the generated sources or a project-specific script.
+type Hook struct {
+	Name         string   `yaml:"name"`          // Label shown in output and errors (defaults to the command)
+	Run          string   `yaml:"run"`           // Shell command (sh -c, or cmd /C on Windows)
+	Lang         []string `yaml:"lang"`          // Only run for these languages (all if empty)
+	Dir          string   `yaml:"dir"`           // Working directory, relative to the output directory
+	AllowFailure bool     `yaml:"allow_failure"` // Report a failure as a warning instead of an error
+}
+
+// Hooks groups the hooks for each generation phase.
+type Hooks struct {
+	Pre  []Hook `yaml:"pre"`  // Run after the output directory is created, before generation
+	Post []Hook `yaml:"post"` // Run after generation succeeded
+
+	// ConfigDir is the directory of the file the hooks were loaded from,
+	// exported to hooks as FFIRE_CONFIG_DIR so they can find project scripts
+	ConfigDir string `yaml:"-"`
+}
+
+// HookError reports a hook that exited with an error. Output holds the
+// combined stdout and stderr of the command.
+type HookError struct {
+	Phase  string
+	Hook   string
+	Err    error
+	Output string
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("%s-generate hook %q failed: %v", e.Phase, e.Hook, e.Err)
+	if out := strings.TrimSpace(e.Output); out != "" {
+		msg += "\n" + out
+	}
+	return msg
+}
+
+func (e *HookError) Unwrap() error { return e.Err }
+
+// label returns the name used for h in output.
+func (h Hook) label() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Run
+}
+
+// appliesTo reports whether h runs when generating lang.
+func (h Hook) appliesTo(lang string) bool {
+	if len(h.Lang) == 0 {
+		return true
+	}
+	for _, l := range h.Lang {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks runs the hooks of one phase in order, stopping at the first
+// failure that is not allowed. Hooks see the generation settings in FFIRE_*
+// environment variables.
+func runHooks(config *PackageConfig, phase string, hooks []Hook) error {
+	outDir, err := filepath.Abs(config.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+
+	for _, h := range hooks {
+		if !h.appliesTo(config.Language) {
+			continue
+		}
+		if strings.TrimSpace(h.Run) == "" {
+			return &HookError{Phase: phase, Hook: h.label(), Err: fmt.Errorf("no command to run")}
+		}
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = config.command("cmd", "/C", h.Run)
+		} else {
+			cmd = config.command("sh", "-c", h.Run)
+		}
+		cmd.Dir = outDir
+		if h.Dir != "" {
+			cmd.Dir = filepath.Join(outDir, h.Dir)
+		}
+		cmd.Env = append(os.Environ(),
+			"FFIRE_PHASE="+phase,
+			"FFIRE_LANG="+config.Language,
+			"FFIRE_OUT="+outDir,
+			"FFIRE_PACKAGE="+config.Schema.Package,
+			"FFIRE_NAMESPACE="+config.Namespace,
+			"FFIRE_CONFIG_DIR="+config.Hooks.ConfigDir,
+		)
+
+		config.debugf("Running %s-generate hook: %s\n", phase, h.Run)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			if ctxErr := config.context().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			hookErr := &HookError{Phase: phase, Hook: h.label(), Err: err, Output: output.String()}
+			if !h.AllowFailure {
+				return hookErr
+			}
+			config.warnf("⚠ %v\n", hookErr)
+			continue
+		}
+		if output.Len() > 0 {
+			config.debugf("%s", output.String())
+		}
+		config.infof("✓ Ran %s-generate hook: %s\n", phase, h.label())
+	}
+	return nil
+}
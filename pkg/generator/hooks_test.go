@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func hookTestConfig(t *testing.T, hooks Hooks) *PackageConfig {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh")
+	}
+	return &PackageConfig{
+		Schema: &schema.Schema{
+			Package: "hooks",
+			Types: []schema.Type{
+				&schema.StructType{Name: "Point", Fields: []schema.Field{
+					{Name: "X", Type: &schema.PrimitiveType{Name: "int32"}},
+				}},
+			},
+			Messages: []schema.MessageType{
+				{Name: "Point", TargetType: &schema.StructType{Name: "Point", Fields: []schema.Field{
+					{Name: "X", Type: &schema.PrimitiveType{Name: "int32"}},
+				}}},
+			},
+		},
+		Language:  "go",
+		OutputDir: t.TempDir(),
+		Hooks:     hooks,
+	}
+}
+
+func TestHooksRunAroundGeneration(t *testing.T) {
+	config := hookTestConfig(t, Hooks{
+		Pre: []Hook{
+			{Name: "pre", Run: `test ! -e hooks.go && echo "$FFIRE_PHASE $FFIRE_LANG $FFIRE_PACKAGE" > pre.txt`},
+		},
+		Post: []Hook{
+			{Name: "post", Run: `test -e hooks.go && echo "$FFIRE_PHASE" > post.txt`},
+			{Name: "cpp only", Run: `touch cpp.txt`, Lang: []string{"cpp"}},
+		},
+	})
+
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	pre, err := os.ReadFile(filepath.Join(config.OutputDir, "pre.txt"))
+	if err != nil {
+		t.Fatalf("pre hook did not run before generation: %v", err)
+	}
+	if got := strings.TrimSpace(string(pre)); got != "pre go hooks" {
+		t.Errorf("pre hook environment = %q, want %q", got, "pre go hooks")
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "post.txt")); err != nil {
+		t.Errorf("post hook did not run after generation: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "cpp.txt")); err == nil {
+		t.Error("hook restricted to cpp ran for go")
+	}
+}
+
+func TestHookFailure(t *testing.T) {
+	config := hookTestConfig(t, Hooks{
+		Post: []Hook{
+			{Name: "lint", Run: `echo "bad formatting" >&2; exit 3`},
+			{Name: "after", Run: `touch after.txt`},
+		},
+	})
+
+	err := GeneratePackage(config)
+	var hookErr *HookError
+	if !errors.As(err, &hookErr) {
+		t.Fatalf("expected *HookError, got %v", err)
+	}
+	if hookErr.Phase != "post" || hookErr.Hook != "lint" {
+		t.Errorf("HookError = %+v", hookErr)
+	}
+	if !strings.Contains(err.Error(), "bad formatting") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("error %q should include the exit status and hook output", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "after.txt")); err == nil {
+		t.Error("hooks after a failed hook should not run")
+	}
+}
+
+func TestHookFailureInPreSkipsGeneration(t *testing.T) {
+	config := hookTestConfig(t, Hooks{Pre: []Hook{{Run: "false"}}})
+
+	if err := GeneratePackage(config); err == nil {
+		t.Fatal("expected pre hook failure")
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "hooks.go")); err == nil {
+		t.Error("generation ran despite a failed pre hook")
+	}
+}
+
+func TestHookAllowFailure(t *testing.T) {
+	config := hookTestConfig(t, Hooks{
+		Post: []Hook{
+			{Name: "optional", Run: "exit 1", AllowFailure: true},
+			{Name: "after", Run: "touch after.txt"},
+		},
+	})
+
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(config.OutputDir, "after.txt")); err != nil {
+		t.Error("hooks after an allowed failure should still run")
+	}
+}
@@ -33,6 +33,11 @@ func GenerateStructs(s *schema.Schema) string {
 	}
 	b.WriteString("\n")
 
+	// Enums are stored as their base integer; the constants name the values
+	for _, enumType := range s.Enums() {
+		generateEnumDef(&b, enumType)
+	}
+
 	// Generate struct definitions in order
 	for _, typ := range s.Types {
 		if structType, ok := typ.(*schema.StructType); ok {
@@ -64,6 +69,28 @@ func GenerateStructs(s *schema.Schema) string {
 	return b.String()
 }
 
+// generateEnumDef emits a constant per enum value and a check the decoder
+// uses to reject values the schema does not declare.
+func generateEnumDef(b *strings.Builder, enumType *schema.EnumType) {
+	enumName := toCIdentifier(enumType.Name)
+	prefix := strings.ToUpper("igniffi_" + enumName)
+
+	fmt.Fprintf(b, "// %s values\n", enumType.Name)
+	for _, v := range enumType.Values {
+		fmt.Fprintf(b, "#define %s_%s %d\n", prefix, strings.ToUpper(toCIdentifier(v.Name)), v.Value)
+	}
+	fmt.Fprintf(b, "\nstatic inline bool igniffi_%s_valid(int64_t v) {\n", enumName)
+	b.WriteString("    switch (v) {\n")
+	for _, v := range enumType.Values {
+		fmt.Fprintf(b, "    case %d:\n", v.Value)
+	}
+	b.WriteString("        return true;\n")
+	b.WriteString("    default:\n")
+	b.WriteString("        return false;\n")
+	b.WriteString("    }\n")
+	b.WriteString("}\n\n")
+}
+
 func generateStructDef(b *strings.Builder, structType *schema.StructType) {
 	structName := toCIdentifier(structType.Name)
 
@@ -76,7 +103,7 @@ func generateStructDef(b *strings.Builder, structType *schema.StructType) {
 
 		// If it's an array, add length field
 		if _, isArray := field.Type.(*schema.ArrayType); isArray {
-			fmt.Fprintf(b, "    uint16_t %s_len;\n", fieldName)
+			fmt.Fprintf(b, "    %s %s_len;\n", LengthCType(field.Type), fieldName)
 		}
 
 		// If it's optional, add has_{field} boolean
@@ -97,7 +124,7 @@ func generateArrayRootStruct(b *strings.Builder, msgName string, arrayType *sche
 
 	fmt.Fprintf(b, "struct igniffi_%s {\n", structName)
 	fmt.Fprintf(b, "    %s* items;\n", elementType)
-	fmt.Fprintf(b, "    uint16_t len;\n")
+	fmt.Fprintf(b, "    %s len;\n", LengthCType(arrayType))
 	fmt.Fprintf(b, "};\n\n")
 }
 
@@ -153,26 +180,43 @@ func generateStructAccessors(b *strings.Builder, structType *schema.StructType)
 			elementType := getCType(arrayType.ElementType)
 			elementType = strings.TrimSuffix(elementType, "*")
 
+			lenType := LengthCType(arrayType)
+
 			// Length getter
-			fmt.Fprintf(b, "static inline uint16_t igniffi_%s_get_%s_len(const igniffi_%s* obj) {\n",
-				structName, fieldName, structName)
+			fmt.Fprintf(b, "static inline %s igniffi_%s_get_%s_len(const igniffi_%s* obj) {\n",
+				lenType, structName, fieldName, structName)
 			fmt.Fprintf(b, "    return obj->%s_len;\n", fieldName)
 			fmt.Fprintf(b, "}\n\n")
 
 			// Array element getter (const)
-			fmt.Fprintf(b, "static inline const %s* igniffi_%s_get_%s(const igniffi_%s* obj, uint16_t index) {\n",
-				elementType, structName, fieldName, structName)
+			fmt.Fprintf(b, "static inline const %s* igniffi_%s_get_%s(const igniffi_%s* obj, %s index) {\n",
+				elementType, structName, fieldName, structName, lenType)
 			fmt.Fprintf(b, "    if (index >= obj->%s_len) return NULL;\n", fieldName)
 			fmt.Fprintf(b, "    return &obj->%s[index];\n", fieldName)
 			fmt.Fprintf(b, "}\n\n")
 
 			// Array element getter (mutable)
-			fmt.Fprintf(b, "static inline %s* igniffi_%s_get_mutable_%s(igniffi_%s* obj, uint16_t index) {\n",
-				elementType, structName, fieldName, structName)
+			fmt.Fprintf(b, "static inline %s* igniffi_%s_get_mutable_%s(igniffi_%s* obj, %s index) {\n",
+				elementType, structName, fieldName, structName, lenType)
 			fmt.Fprintf(b, "    if (index >= obj->%s_len) return NULL;\n", fieldName)
 			fmt.Fprintf(b, "    return &obj->%s[index];\n", fieldName)
 			fmt.Fprintf(b, "}\n\n")
 
+		} else if _, ok := field.Type.(*schema.EnumType); ok {
+			cType := getCType(field.Type)
+
+			// Getter
+			fmt.Fprintf(b, "static inline %s igniffi_%s_get_%s(const igniffi_%s* obj) {\n",
+				cType, structName, fieldName, structName)
+			fmt.Fprintf(b, "    return obj->%s;\n", fieldName)
+			fmt.Fprintf(b, "}\n\n")
+
+			// Setter
+			fmt.Fprintf(b, "static inline void igniffi_%s_set_%s(igniffi_%s* obj, %s val) {\n",
+				structName, fieldName, structName, cType)
+			fmt.Fprintf(b, "    obj->%s = val;\n", fieldName)
+			fmt.Fprintf(b, "}\n\n")
+
 		} else if primitiveType, ok := field.Type.(*schema.PrimitiveType); ok {
 			cType := getCType(field.Type)
 
@@ -223,29 +267,40 @@ func generateArrayRootAccessors(b *strings.Builder, msgName string, arrayType *s
 	elementType := getCType(arrayType.ElementType)
 	elementType = strings.TrimSuffix(elementType, "*")
 
+	lenType := LengthCType(arrayType)
+
 	fmt.Fprintf(b, "// %s accessors (array root type)\n", msgName)
 
 	// Length getter
-	fmt.Fprintf(b, "static inline uint16_t igniffi_%s_len(const igniffi_%s* list) {\n",
-		structName, structName)
+	fmt.Fprintf(b, "static inline %s igniffi_%s_len(const igniffi_%s* list) {\n",
+		lenType, structName, structName)
 	fmt.Fprintf(b, "    return list->len;\n")
 	fmt.Fprintf(b, "}\n\n")
 
 	// Element getter (const)
-	fmt.Fprintf(b, "static inline const %s* igniffi_%s_get(const igniffi_%s* list, uint16_t index) {\n",
-		elementType, structName, structName)
+	fmt.Fprintf(b, "static inline const %s* igniffi_%s_get(const igniffi_%s* list, %s index) {\n",
+		elementType, structName, structName, lenType)
 	fmt.Fprintf(b, "    if (index >= list->len) return NULL;\n")
 	fmt.Fprintf(b, "    return &list->items[index];\n")
 	fmt.Fprintf(b, "}\n\n")
 
 	// Element getter (mutable)
-	fmt.Fprintf(b, "static inline %s* igniffi_%s_get_mutable(igniffi_%s* list, uint16_t index) {\n",
-		elementType, structName, structName)
+	fmt.Fprintf(b, "static inline %s* igniffi_%s_get_mutable(igniffi_%s* list, %s index) {\n",
+		elementType, structName, structName, lenType)
 	fmt.Fprintf(b, "    if (index >= list->len) return NULL;\n")
 	fmt.Fprintf(b, "    return &list->items[index];\n")
 	fmt.Fprintf(b, "}\n\n")
 }
 
+// LengthCType returns the C type that holds the length of an array: a
+// uint32_t for @large arrays, a uint16_t otherwise.
+func LengthCType(t schema.Type) string {
+	if schema.IsLarge(t) {
+		return "uint32_t"
+	}
+	return "uint16_t"
+}
+
 // getCType returns the C type for a schema type
 func getCType(t schema.Type) string {
 	switch typ := t.(type) {
@@ -268,6 +323,8 @@ func getCType(t schema.Type) string {
 		case "string":
 			return "igniffi_StringView"
 		}
+	case *schema.EnumType:
+		return getCType(&schema.PrimitiveType{Name: typ.Base})
 	case *schema.ArrayType:
 		// Arrays are represented as pointer to element type
 		elemType := getCType(typ.ElementType)
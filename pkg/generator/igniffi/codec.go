@@ -171,6 +171,7 @@ typedef struct {
     const uint8_t* data;
     size_t size;
     size_t pos;
+    int error;  // IGNIFFI_DECODE_* of the failure, 0 if none
 } igniffi_Decoder;
 
 static igniffi_Decoder* decoder_new(const uint8_t* data, size_t size, igniffi_Arena* arena) {
@@ -179,11 +180,25 @@ static igniffi_Decoder* decoder_new(const uint8_t* data, size_t size, igniffi_Ar
     dec->data = data;
     dec->size = size;
     dec->pos = 0;
+    dec->error = 0;
     return dec;
 }
 
+static bool decoder_fail(igniffi_Decoder* dec, int code) {
+    dec->error = code;
+    return false;
+}
+
 static bool decoder_check_remaining(igniffi_Decoder* dec, size_t needed) {
-    return dec->pos + needed <= dec->size;
+    if (dec->pos + needed > dec->size) return decoder_fail(dec, IGNIFFI_DECODE_TRUNCATED);
+    return true;
+}
+
+// Lengths and counts are checked against the data left before anything
+// is read or allocated for them
+static bool decoder_check_count(igniffi_Decoder* dec, size_t needed) {
+    if (dec->pos + needed > dec->size) return decoder_fail(dec, IGNIFFI_DECODE_OVERFLOW);
+    return true;
 }
 
 static bool decoder_read_bool(igniffi_Decoder* dec, bool* out) {
@@ -256,7 +271,7 @@ static bool decoder_read_string(igniffi_Decoder* dec, igniffi_StringView* out, i
     if (!decoder_read_int16(dec, &len_signed)) return false;
     uint16_t len = (uint16_t)len_signed;
     
-    if (!decoder_check_remaining(dec, len)) return false;
+    if (!decoder_check_count(dec, len)) return false;
     
     if (len == 0) {
         out->data = NULL;
@@ -287,6 +302,12 @@ static bool decoder_read_optional_present(igniffi_Decoder* dec, bool* out) {
 }
 
 `)
+	if s.HasLarge() {
+		b.WriteString(largeCodec)
+	}
+	if s.HasBitmaps() {
+		b.WriteString(bitmapCodec)
+	}
 
 	// Generate encode/decode implementations for each root message
 	for _, msg := range s.Messages {
@@ -296,6 +317,89 @@ static bool decoder_read_optional_present(igniffi_Decoder* dec, bool* out) {
 	return b.String()
 }
 
+// largeCodec holds the coders of @large strings and arrays, whose length
+// prefix is a uint32. Every element takes at least a byte, so a count
+// past the end of the data is rejected before anything is allocated.
+const largeCodec = `// ============================================================================
+// Large (uint32) Length Prefixes
+// ============================================================================
+
+static void encoder_write_large_length(igniffi_Encoder* enc, uint32_t len) {
+    encoder_write_int32(enc, (int32_t)len);
+}
+
+static void encoder_write_large_string(igniffi_Encoder* enc, igniffi_StringView sv) {
+    uint32_t len = (uint32_t)sv.size;
+    encoder_write_large_length(enc, len);
+    if (len > 0) {
+        encoder_ensure_capacity(enc, len);
+        memcpy(enc->data + enc->size, sv.data, len);
+        enc->size += len;
+    }
+}
+
+static bool decoder_read_large_length(igniffi_Decoder* dec, uint32_t* out) {
+    int32_t len;
+    if (!decoder_read_int32(dec, &len)) return false;
+    if (!decoder_check_count(dec, (uint32_t)len)) return false;
+    *out = (uint32_t)len;
+    return true;
+}
+
+static bool decoder_read_large_string(igniffi_Decoder* dec, igniffi_StringView* out, igniffi_Arena* arena) {
+    uint32_t len;
+    if (!decoder_read_large_length(dec, &len)) return false;
+
+    if (len == 0) {
+        out->data = NULL;
+        out->size = 0;
+        return true;
+    }
+
+    char* buf = (char*)igniffi_arena_alloc(arena, len);
+    if (!buf) return false;
+    memcpy(buf, dec->data + dec->pos, len);
+    dec->pos += len;
+
+    out->data = buf;
+    out->size = len;
+    return true;
+}
+
+`
+
+// bitmapCodec holds the coders of the presence bitmaps of @bitmap
+// structs, which carry one bit per optional field instead of a presence
+// byte. Set bits past the last optional field are rejected.
+const bitmapCodec = `// ============================================================================
+// Presence Bitmaps
+// ============================================================================
+
+static void encoder_write_bitmap(igniffi_Encoder* enc, const uint8_t* bits, size_t n) {
+    encoder_ensure_capacity(enc, n);
+    memcpy(enc->data + enc->size, bits, n);
+    enc->size += n;
+}
+
+static bool decoder_read_bitmap(igniffi_Decoder* dec, uint8_t* bits, size_t n, uint8_t unused) {
+    if (!decoder_check_remaining(dec, n)) return false;
+    memcpy(bits, dec->data + dec->pos, n);
+    dec->pos += n;
+    if (bits[n - 1] & unused) return decoder_fail(dec, IGNIFFI_DECODE_BAD_PRESENCE);
+    return true;
+}
+
+`
+
+// lengthCodec returns the infix of the coder functions for t's length
+// prefix: "large_" for @large types, "" otherwise.
+func lengthCodec(t schema.Type) string {
+	if schema.IsLarge(t) {
+		return "large_"
+	}
+	return ""
+}
+
 func generateMessageCodec(b *strings.Builder, s *schema.Schema, msg *schema.MessageType) {
 	msgName := toCIdentifier(msg.Name)
 
@@ -336,9 +440,9 @@ func generateMessageCodec(b *strings.Builder, s *schema.Schema, msg *schema.Mess
 	// Decode based on target type - both struct and array use decode_{msgName}
 	fmt.Fprintf(b, "    if (!decode_%s(dec, msg, arena)) {\n", msgName)
 	if _, ok := msg.TargetType.(*schema.ArrayType); ok {
-		b.WriteString("        *status = igniffi_error(\"Failed to decode array\");\n")
+		b.WriteString("        *status = igniffi_decode_error(\"Failed to decode array\", dec->error);\n")
 	} else {
-		b.WriteString("        *status = igniffi_error(\"Failed to decode message\");\n")
+		b.WriteString("        *status = igniffi_decode_error(\"Failed to decode message\", dec->error);\n")
 	}
 	b.WriteString("        return NULL;\n")
 	b.WriteString("    }\n\n")
@@ -470,8 +574,30 @@ func generateStructCodecHelper(b *strings.Builder, s *schema.Schema, structType
 	fmt.Fprintf(b, "static bool decode_%s(igniffi_Decoder* dec, igniffi_%s* out, igniffi_Arena* arena) {\n",
 		structName, structName)
 
+	n := schema.BitmapSize(structType)
+	if n > 0 {
+		unused := 0
+		if used := schema.OptionalFields(structType) % 8; used != 0 {
+			unused = 0xff << used & 0xff
+		}
+		fmt.Fprintf(b, "    uint8_t bits[%d];\n", n)
+		fmt.Fprintf(b, "    if (!decoder_read_bitmap(dec, bits, %d, 0x%02x)) return false;\n", n, unused)
+	}
+	bit := 0
 	for _, field := range structType.Fields {
 		fieldName := toCIdentifier(field.Name)
+		if n > 0 && field.Type.IsOptional() {
+			stripped := field
+			stripped.Type = schema.WithoutPresence(field.Type)
+			fmt.Fprintf(b, "    out->has_%s = (bits[%d] & 0x%02x) != 0;\n", fieldName, bit/8, 1<<(bit%8))
+			fmt.Fprintf(b, "    if (out->has_%s) {\n", fieldName)
+			var inner strings.Builder
+			generateFieldDecode(&inner, &stripped, fieldName, "out->"+fieldName)
+			b.WriteString(indentBlock(inner.String()))
+			b.WriteString("    }\n")
+			bit++
+			continue
+		}
 		generateFieldDecode(b, &field, fieldName, "out->"+fieldName)
 	}
 
@@ -482,8 +608,29 @@ func generateStructCodecHelper(b *strings.Builder, s *schema.Schema, structType
 	fmt.Fprintf(b, "static void encode_%s(igniffi_Encoder* enc, const igniffi_%s* msg) {\n",
 		structName, structName)
 
+	if n > 0 {
+		fmt.Fprintf(b, "    uint8_t bits[%d] = {0};\n", n)
+		bit = 0
+		for _, field := range structType.Fields {
+			if field.Type.IsOptional() {
+				fmt.Fprintf(b, "    if (msg->has_%s) bits[%d] |= 0x%02x;\n", toCIdentifier(field.Name), bit/8, 1<<(bit%8))
+				bit++
+			}
+		}
+		fmt.Fprintf(b, "    encoder_write_bitmap(enc, bits, %d);\n", n)
+	}
 	for _, field := range structType.Fields {
 		fieldName := toCIdentifier(field.Name)
+		if n > 0 && field.Type.IsOptional() {
+			stripped := field
+			stripped.Type = schema.WithoutPresence(field.Type)
+			fmt.Fprintf(b, "    if (msg->has_%s) {\n", fieldName)
+			var inner strings.Builder
+			generateFieldEncode(&inner, &stripped, fieldName, "msg->"+fieldName)
+			b.WriteString(indentBlock(inner.String()))
+			b.WriteString("    }\n")
+			continue
+		}
 		generateFieldEncode(b, &field, fieldName, "msg->"+fieldName)
 	}
 
@@ -519,8 +666,12 @@ func generateArrayRootCodecHelper(b *strings.Builder, s *schema.Schema, arrayTyp
 	// Decode helper
 	fmt.Fprintf(b, "static bool decode_%s(igniffi_Decoder* dec, igniffi_%s* out, igniffi_Arena* arena) {\n",
 		structName, structName)
-	b.WriteString("    uint16_t len;\n")
-	b.WriteString("    if (!decoder_read_array_length(dec, &len)) return false;\n")
+	fmt.Fprintf(b, "    %s len;\n", LengthCType(arrayType))
+	if arrayType.Large {
+		b.WriteString("    if (!decoder_read_large_length(dec, &len)) return false;\n")
+	} else {
+		b.WriteString("    if (!decoder_read_array_length(dec, &len)) return false;\n")
+	}
 	b.WriteString("    out->len = len;\n\n")
 	b.WriteString("    if (len == 0) {\n")
 	b.WriteString("        out->items = NULL;\n")
@@ -534,12 +685,12 @@ func generateArrayRootCodecHelper(b *strings.Builder, s *schema.Schema, arrayTyp
 	if isPrimitive && primitiveSize > 0 {
 		// Bulk memcpy for primitive arrays (little-endian assumed)
 		fmt.Fprintf(b, "    size_t bytes = len * %d;\n", primitiveSize)
-		b.WriteString("    if (!decoder_check_remaining(dec, bytes)) return false;\n")
+		b.WriteString("    if (!decoder_check_count(dec, bytes)) return false;\n")
 		b.WriteString("    memcpy(out->items, dec->data + dec->pos, bytes);\n")
 		b.WriteString("    dec->pos += bytes;\n")
 	} else {
 		// Loop for struct/string arrays
-		b.WriteString("    for (uint16_t i = 0; i < len; i++) {\n")
+		fmt.Fprintf(b, "    for (%s i = 0; i < len; i++) {\n", LengthCType(arrayType))
 		generateElementDecode(b, arrayType.ElementType, "out->items[i]", "        ")
 		b.WriteString("    }\n")
 	}
@@ -549,7 +700,11 @@ func generateArrayRootCodecHelper(b *strings.Builder, s *schema.Schema, arrayTyp
 	// Encode helper
 	fmt.Fprintf(b, "static void encode_%s(igniffi_Encoder* enc, const igniffi_%s* msg) {\n",
 		structName, structName)
-	b.WriteString("    encoder_write_array_length(enc, msg->len);\n")
+	if arrayType.Large {
+		b.WriteString("    encoder_write_large_length(enc, msg->len);\n")
+	} else {
+		b.WriteString("    encoder_write_array_length(enc, msg->len);\n")
+	}
 
 	if isPrimitive && primitiveSize > 0 {
 		// Bulk memcpy for primitive arrays
@@ -559,7 +714,7 @@ func generateArrayRootCodecHelper(b *strings.Builder, s *schema.Schema, arrayTyp
 		b.WriteString("    enc->size += bytes;\n")
 	} else {
 		// Loop for struct/string arrays
-		b.WriteString("    for (uint16_t i = 0; i < msg->len; i++) {\n")
+		fmt.Fprintf(b, "    for (%s i = 0; i < msg->len; i++) {\n", LengthCType(arrayType))
 		generateElementEncode(b, arrayType.ElementType, "msg->items[i]", "        ")
 		b.WriteString("    }\n")
 	}
@@ -592,6 +747,9 @@ func generateElementDecode(b *strings.Builder, elemType schema.Type, resultVar,
 		structName := toCIdentifier(typ.Name)
 		// For struct elements in arrays, the element is stored by value - decode into address
 		fmt.Fprintf(b, "%sif (!decode_%s(dec, &%s, arena)) return false;\n", indent, structName, resultVar)
+	case *schema.EnumType:
+		generateElementDecode(b, &schema.PrimitiveType{Name: typ.Base}, resultVar, indent)
+		fmt.Fprintf(b, "%sif (!igniffi_%s_valid(%s)) return decoder_fail(dec, IGNIFFI_DECODE_INVALID_VALUE);\n", indent, toCIdentifier(typ.Name), resultVar)
 	}
 }
 
@@ -621,7 +779,20 @@ func generateElementEncode(b *strings.Builder, elemType schema.Type, valueVar, i
 		structName := toCIdentifier(typ.Name)
 		// For struct elements in arrays, they're stored by value - pass address
 		fmt.Fprintf(b, "%sencode_%s(enc, &%s);\n", indent, structName, valueVar)
+	case *schema.EnumType:
+		generateElementEncode(b, &schema.PrimitiveType{Name: typ.Base}, valueVar, indent)
+	}
+}
+
+// indentBlock indents each line of code by one level.
+func indentBlock(code string) string {
+	lines := strings.SplitAfter(code, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = "    " + line
+		}
 	}
+	return strings.Join(lines, "")
 }
 
 func generateFieldDecode(b *strings.Builder, field *schema.Field, fieldName, resultVar string) {
@@ -660,28 +831,36 @@ func generateFieldDecode(b *strings.Builder, field *schema.Field, fieldName, res
 		case "float64":
 			fmt.Fprintf(b, "%sif (!decoder_read_float64(dec, &%s)) return false;\n", indent, resultVar)
 		case "string":
-			fmt.Fprintf(b, "%sif (!decoder_read_string(dec, &%s, arena)) return false;\n", indent, resultVar)
+			fmt.Fprintf(b, "%sif (!decoder_read_%sstring(dec, &%s, arena)) return false;\n", indent, lengthCodec(typ), resultVar)
 		}
 	case *schema.ArrayType:
 		// Array field - read length, allocate, decode elements
 		elemCType := getCType(typ.ElementType)
 		elemCTypeBase := strings.TrimSuffix(elemCType, "*")
 
-		fmt.Fprintf(b, "%sif (!decoder_read_array_length(dec, &out->%s_len)) return false;\n", indent, fieldName)
+		if typ.Large {
+			fmt.Fprintf(b, "%sif (!decoder_read_large_length(dec, &out->%s_len)) return false;\n", indent, fieldName)
+		} else {
+			fmt.Fprintf(b, "%sif (!decoder_read_array_length(dec, &out->%s_len)) return false;\n", indent, fieldName)
+		}
 		fmt.Fprintf(b, "%sif (out->%s_len > 0) {\n", indent, fieldName)
 		fmt.Fprintf(b, "%s    out->%s = (%s*)igniffi_arena_alloc(arena, out->%s_len * sizeof(%s));\n",
 			indent, fieldName, elemCTypeBase, fieldName, elemCTypeBase)
 		fmt.Fprintf(b, "%s    if (!out->%s) return false;\n", indent, fieldName)
-		fmt.Fprintf(b, "%s    for (uint16_t i = 0; i < out->%s_len; i++) {\n", indent, fieldName)
+		fmt.Fprintf(b, "%s    for (%s i = 0; i < out->%s_len; i++) {\n", indent, LengthCType(typ), fieldName)
 		generateElementDecode(b, typ.ElementType, fmt.Sprintf("out->%s[i]", fieldName), indent+"        ")
 		fmt.Fprintf(b, "%s    }\n", indent)
 		fmt.Fprintf(b, "%s} else {\n", indent)
 		fmt.Fprintf(b, "%s    out->%s = NULL;\n", indent, fieldName)
 		fmt.Fprintf(b, "%s}\n", indent)
 	case *schema.StructType:
-		// Nested struct - decode inline
+		// Nested struct fields are pointers into the arena
 		structName := toCIdentifier(typ.Name)
-		fmt.Fprintf(b, "%sif (!decode_%s(dec, &%s, arena)) return false;\n", indent, structName, resultVar)
+		fmt.Fprintf(b, "%s%s = (igniffi_%s*)igniffi_arena_alloc(arena, sizeof(igniffi_%s));\n", indent, resultVar, structName, structName)
+		fmt.Fprintf(b, "%sif (!%s || !decode_%s(dec, %s, arena)) return false;\n", indent, resultVar, structName, resultVar)
+	case *schema.EnumType:
+		// Read the base integer, then reject undeclared values
+		generateElementDecode(b, typ, resultVar, indent)
 	}
 
 	if isOptional {
@@ -722,18 +901,24 @@ func generateFieldEncode(b *strings.Builder, field *schema.Field, fieldName, val
 		case "float64":
 			fmt.Fprintf(b, "%sencoder_write_float64(enc, %s);\n", indent, valueVar)
 		case "string":
-			fmt.Fprintf(b, "%sencoder_write_string(enc, %s);\n", indent, valueVar)
+			fmt.Fprintf(b, "%sencoder_write_%sstring(enc, %s);\n", indent, lengthCodec(typ), valueVar)
 		}
 	case *schema.ArrayType:
 		// Array field - encode length then elements
-		fmt.Fprintf(b, "%sencoder_write_array_length(enc, msg->%s_len);\n", indent, fieldName)
-		fmt.Fprintf(b, "%sfor (uint16_t i = 0; i < msg->%s_len; i++) {\n", indent, fieldName)
+		if typ.Large {
+			fmt.Fprintf(b, "%sencoder_write_large_length(enc, msg->%s_len);\n", indent, fieldName)
+		} else {
+			fmt.Fprintf(b, "%sencoder_write_array_length(enc, msg->%s_len);\n", indent, fieldName)
+		}
+		fmt.Fprintf(b, "%sfor (%s i = 0; i < msg->%s_len; i++) {\n", indent, LengthCType(typ), fieldName)
 		generateElementEncode(b, typ.ElementType, fmt.Sprintf("msg->%s[i]", fieldName), indent+"    ")
 		fmt.Fprintf(b, "%s}\n", indent)
 	case *schema.StructType:
-		// Nested struct - encode inline
+		// Nested struct fields are pointers into the arena
 		structName := toCIdentifier(typ.Name)
-		fmt.Fprintf(b, "%sencode_%s(enc, &%s);\n", indent, structName, valueVar)
+		fmt.Fprintf(b, "%sencode_%s(enc, %s);\n", indent, structName, valueVar)
+	case *schema.EnumType:
+		generateElementEncode(b, typ, valueVar, indent)
 	}
 
 	if isOptional {
@@ -13,6 +13,20 @@ import (
 
 // Generate creates igniffi C API code for the given schema
 func Generate(s *schema.Schema, outputDir string) error {
+	// The C structs have no map, union, bytes or fixed array representation yet
+	if s.HasMaps() {
+		return fmt.Errorf("map types are not supported by igniffi")
+	}
+	if len(s.Unions()) > 0 {
+		return fmt.Errorf("union types are not supported by igniffi")
+	}
+	if s.HasBytes() {
+		return fmt.Errorf("bytes types are not supported by igniffi")
+	}
+	if s.HasFixedArrays() {
+		return fmt.Errorf("fixed-size arrays are not supported by igniffi")
+	}
+
 	// Create output directories
 	includeDir := filepath.Join(outputDir, "include")
 	srcDir := filepath.Join(outputDir, "src")
@@ -1,6 +1,12 @@
 package igniffi
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	fferrors "github.com/shaban/ffire/pkg/errors"
+)
 
 // GenerateTypesHeader generates igniffi_types.h with core type definitions
 func GenerateTypesHeader() string {
@@ -56,9 +62,16 @@ static inline bool igniffi_stringview_eq(igniffi_StringView a, igniffi_StringVie
 // Status / Error Handling
 // ============================================================================
 
+`)
+	// Decode failure codes, the same in every ffire language
+	for _, info := range fferrors.DecodeCodes {
+		fmt.Fprintf(&b, "#define IGNIFFI_DECODE_%s %d // %s\n", screamingSnake(info.Name), int(info.Code), info.Summary)
+	}
+	b.WriteString(`
 typedef struct {
     bool ok;
     const char* message;  // Borrowed string, valid until next operation
+    int code;             // IGNIFFI_DECODE_* for decode failures, 0 otherwise
 } igniffi_Status;
 
 // Helper to create success status
@@ -66,6 +79,7 @@ static inline igniffi_Status igniffi_ok(void) {
     igniffi_Status status;
     status.ok = true;
     status.message = NULL;
+    status.code = 0;
     return status;
 }
 
@@ -74,6 +88,14 @@ static inline igniffi_Status igniffi_error(const char* msg) {
     igniffi_Status status;
     status.ok = false;
     status.message = msg;
+    status.code = 0;
+    return status;
+}
+
+// Helper to create decode error status
+static inline igniffi_Status igniffi_decode_error(const char* msg, int code) {
+    igniffi_Status status = igniffi_error(msg);
+    status.code = code;
     return status;
 }
 
@@ -95,3 +117,15 @@ typedef struct {
 
 	return b.String()
 }
+
+// screamingSnake converts a PascalCase name to SCREAMING_SNAKE_CASE.
+func screamingSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -38,7 +38,7 @@ func TestRubyPackageIntegration(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config) // Use GeneratePackage to get platform resolution
@@ -120,7 +120,7 @@ func TestSwiftPackageIntegration(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config)
@@ -190,7 +190,7 @@ func TestPHPPackageIntegration(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config)
@@ -267,7 +267,7 @@ func TestJavaPackageIntegration(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config)
@@ -338,7 +338,7 @@ func TestCSharpPackageIntegration(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config)
@@ -399,7 +399,7 @@ func TestDartPackageIntegration(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config)
@@ -712,7 +712,6 @@ func TestPackageCompilationErrors(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: false,
-		Verbose:   false,
 	}
 
 	// This should either succeed (if platform validation is permissive)
@@ -747,7 +746,7 @@ func TestPackageNoCompile(t *testing.T) {
 		Arch:      "current",
 		Namespace: schema.Package,
 		NoCompile: true, // Skip compilation
-		Verbose:   testing.Verbose(),
+		Logger:    testLogger{t},
 	}
 
 	err = GeneratePackage(config)
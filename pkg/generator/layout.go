@@ -0,0 +1,188 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Output layouts (PackageConfig.Layout).
+const (
+	// LayoutDefault writes each language to its own directory under the
+	// output directory (python/, javascript/, rust/, ...), or directly into
+	// it for Go and Java.
+	LayoutDefault = "default"
+
+	// LayoutMonorepo writes every package to <out>/<lang>/<schema>/ and
+	// records it in <out>/ffire-manifest.json, so generated code for many
+	// schemas and languages shares one predictable tree.
+	LayoutMonorepo = "monorepo"
+)
+
+// ManifestFile is the name of the artifact manifest of a monorepo layout.
+const ManifestFile = "ffire-manifest.json"
+
+// manifestVersion is the format version of the manifest.
+const manifestVersion = 1
+
+// Manifest lists the packages generated into a monorepo layout.
+type Manifest struct {
+	Version   int        `json:"version"`
+	Artifacts []Artifact `json:"artifacts"` // Sorted by schema, then language
+}
+
+// Artifact is one generated package in a monorepo layout.
+type Artifact struct {
+	Schema  string   `json:"schema"`  // Schema package name
+	Lang    string   `json:"lang"`    // Canonical language name, e.g. "js" for javascript
+	Path    string   `json:"path"`    // Package directory, slash-separated and relative to the manifest
+	Name    string   `json:"name"`    // Published package name (see Coordinates)
+	Version string   `json:"version"` // Package version
+	Files   []string `json:"files"`   // Files in the package directory, relative to Path
+}
+
+// canonicalLanguages maps language aliases to the directory names of the
+// monorepo layout.
+var canonicalLanguages = map[string]string{
+	"c":              "cpp",
+	"c++":            "cpp",
+	"javascript":     "js",
+	"igniffi-js":     "js",
+	"py":             "python",
+	"igniffi-python": "python",
+	"octave":         "matlab",
+}
+
+// canonicalLanguage returns the canonical name of lang.
+func canonicalLanguage(lang string) string {
+	lang = strings.ToLower(lang)
+	if c, ok := canonicalLanguages[lang]; ok {
+		return c
+	}
+	return lang
+}
+
+// langDir returns the directory the package for a language is written to:
+// <out>/<name> in the default layout, and the package directory itself in
+// the monorepo layout and for GenerateAll.
+func (c *PackageConfig) langDir(name string) string {
+	if c.Layout == LayoutMonorepo || c.packageDir {
+		return c.OutputDir
+	}
+	return filepath.Join(c.OutputDir, name)
+}
+
+// setupLayout validates config.Layout. For the monorepo layout it moves
+// config.OutputDir to the package directory and returns the layout root,
+// where the manifest goes; otherwise it returns "".
+func setupLayout(config *PackageConfig) (string, error) {
+	switch config.Layout {
+	case "", LayoutDefault:
+		return "", nil
+	case LayoutMonorepo:
+		root := config.OutputDir
+		config.OutputDir = filepath.Join(root, canonicalLanguage(config.Language), config.Schema.Package)
+		return root, nil
+	default:
+		return "", fmt.Errorf("unknown layout %q (supported: %s, %s)", config.Layout, LayoutDefault, LayoutMonorepo)
+	}
+}
+
+// ReadManifest reads the manifest in root. A missing manifest is empty.
+func ReadManifest(root string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(root, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Manifest{Version: manifestVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestFile, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d (want %d)", ManifestFile, m.Version, manifestVersion)
+	}
+	return m, nil
+}
+
+// Put adds a, replacing the artifact for the same schema and language.
+func (m *Manifest) Put(a Artifact) {
+	for i, old := range m.Artifacts {
+		if old.Schema == a.Schema && old.Lang == a.Lang {
+			m.Artifacts[i] = a
+			return
+		}
+	}
+	m.Artifacts = append(m.Artifacts, a)
+	sort.Slice(m.Artifacts, func(i, j int) bool {
+		x, y := m.Artifacts[i], m.Artifacts[j]
+		if x.Schema != y.Schema {
+			return x.Schema < y.Schema
+		}
+		return x.Lang < y.Lang
+	})
+}
+
+// Write writes the manifest to root.
+func (m *Manifest) Write(root string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, ManifestFile), append(data, '\n'), 0644)
+}
+
+// updateManifest records the package just generated into config.OutputDir
+// in the manifest of the monorepo layout at root.
+func updateManifest(config *PackageConfig, root string) error {
+	m, err := ReadManifest(root)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, config.OutputDir)
+	if err != nil {
+		return err
+	}
+	files, err := packageFiles(config.OutputDir)
+	if err != nil {
+		return err
+	}
+	m.Put(Artifact{
+		Schema:  config.Schema.Package,
+		Lang:    canonicalLanguage(config.Language),
+		Path:    filepath.ToSlash(rel),
+		Name:    publishedName(config),
+		Version: config.Version,
+		Files:   files,
+	})
+	if err := m.Write(root); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ManifestFile, err)
+	}
+	config.infof("✓ Updated %s\n", filepath.Join(root, ManifestFile))
+	return nil
+}
+
+// packageFiles returns the files under dir, slash-separated and sorted.
+func packageFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
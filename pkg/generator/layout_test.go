@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestMonorepoLayout(t *testing.T) {
+	root := t.TempDir()
+	schemas := map[string]string{
+		"struct.ffi": "../../testdata/schema/struct.ffi",
+		"audio.ffi":  filepath.Join(t.TempDir(), "audio.ffi"),
+	}
+	audio := "package audio\n\ntype Device struct {\n\tID   int32\n\tName string\n}\n\ntype DeviceList []Device\n"
+	if err := os.WriteFile(schemas["audio.ffi"], []byte(audio), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generate := func(schemaFile, lang string) *PackageConfig {
+		t.Helper()
+		s, err := parser.Parse(schemas[schemaFile])
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		config := &PackageConfig{
+			Schema:    s,
+			Language:  lang,
+			OutputDir: root,
+			Layout:    LayoutMonorepo,
+			NoCompile: true,
+			NoFormat:  true,
+		}
+		if err := GeneratePackage(config); err != nil {
+			t.Fatalf("GeneratePackage(%s, %s) failed: %v", schemaFile, lang, err)
+		}
+		return config
+	}
+
+	generate("struct.ffi", "go")
+	generate("struct.ffi", "javascript")
+	generate("struct.ffi", "java")
+	generate("audio.ffi", "python")
+	// Regenerating replaces the artifact instead of adding another
+	last := generate("struct.ffi", "go")
+
+	if want := filepath.Join(root, "go", last.Schema.Package); last.OutputDir != want {
+		t.Errorf("OutputDir = %s, want %s", last.OutputDir, want)
+	}
+
+	m, err := ReadManifest(root)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	var got []string
+	for _, a := range m.Artifacts {
+		got = append(got, a.Schema+" "+a.Lang+" "+a.Path+" "+a.Name)
+		if len(a.Files) == 0 {
+			t.Errorf("%s/%s lists no files", a.Schema, a.Lang)
+		}
+		for _, f := range a.Files {
+			if _, err := os.Stat(filepath.Join(root, a.Path, f)); err != nil {
+				t.Errorf("%s/%s lists %s: %v", a.Schema, a.Lang, f, err)
+			}
+		}
+	}
+	pkg := last.Schema.Package
+	want := []string{
+		"audio python python/audio audio",
+		pkg + " go go/" + pkg + " " + pkg,
+		pkg + " java java/" + pkg + " com.ffire:" + pkg,
+		pkg + " js js/" + pkg + " " + pkg,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("artifacts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Packages are written to the package directory, not a language subdirectory
+	for _, path := range []string{
+		"go/" + pkg + "/" + pkg + ".go",
+		"js/" + pkg + "/package.json",
+		"java/" + pkg + "/pom.xml",
+		"python/audio/pyproject.toml",
+	} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("missing %s: %v", path, err)
+		}
+	}
+}
+
+func TestLayoutErrors(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "go", OutputDir: t.TempDir(), Layout: "flat", NoCompile: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "unknown layout") {
+		t.Errorf("unknown layout: err = %v", err)
+	}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ManifestFile), []byte(`{"version": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadManifest(root); err == nil || !strings.Contains(err.Error(), "unsupported version") {
+		t.Errorf("ReadManifest: err = %v", err)
+	}
+}
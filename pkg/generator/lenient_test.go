@@ -0,0 +1,129 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const lenientTestSchema = `package logs
+
+type Level int8
+
+const (
+	Debug Level = 0
+	Info  Level = 1
+)
+
+type Entry struct {
+	TS    int64
+	Level Level
+	Msg   string
+	Host  *string
+	Tags  []string
+}
+
+type Batch []string
+`
+
+func TestGenerateGoLenientDecode(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(lenientTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+	src := string(code)
+
+	if !strings.Contains(src, "func DecodeEntryMessageLenient(data []byte) (EntryMessage, []FieldError)") {
+		t.Error("missing lenient decoder for struct message Entry")
+	}
+	if strings.Contains(src, "DecodeBatchMessageLenient") {
+		t.Error("array message Batch should not get a lenient decoder")
+	}
+	if strings.Count(src, "func decodeLenient(") != 1 {
+		t.Error("lenient runtime should be emitted exactly once")
+	}
+}
+
+// TestGoLenientDecodeRecovers runs the generated lenient decoder on a corrupt
+// and a truncated message and checks which fields survive.
+func TestGoLenientDecodeRecovers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	s, err := parser.ParseBytes([]byte(lenientTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGo(s)
+	if err != nil {
+		t.Fatalf("GenerateGo failed: %v", err)
+	}
+
+	tmpDir := t.TempDir()
+	pkgDir := filepath.Join(tmpDir, "logs")
+	os.MkdirAll(pkgDir, 0755)
+	files := map[string]string{
+		"go.mod":       "module lenient\n\ngo 1.21\n",
+		"logs/logs.go": string(code),
+		"main.go": `package main
+
+import (
+	"errors"
+	"fmt"
+
+	"lenient/logs"
+)
+
+func main() {
+	host := "h1"
+	data := logs.EntryMessage{TS: 7, Level: logs.Info, Msg: "hello", Host: &host, Tags: []string{"a"}}.Encode()
+
+	v, errs := logs.DecodeEntryMessageLenient(data)
+	fmt.Println(v.TS, v.Level, v.Msg, *v.Host, v.Tags, len(errs))
+
+	corrupt := append([]byte(nil), data...)
+	corrupt[8] = 9 // Level
+	v, errs = logs.DecodeEntryMessageLenient(corrupt)
+	fmt.Println(v.TS, v.Level, v.Msg, *v.Host, v.Tags, errs)
+
+	v, errs = logs.DecodeEntryMessageLenient(data[:14])
+	fmt.Println(v.TS, v.Level, v.Msg == "", v.Host == nil, errs,
+		errors.Is(errs[0], logs.ErrTruncated), errors.Is(errs[2], logs.ErrNotDecoded))
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+
+	want := strings.Join([]string{
+		"7 Info hello h1 [a] 0",
+		"7 Debug hello h1 [a] [Level: invalid Level value 9]",
+		"7 Info true true [Msg: unexpected end of data Tags: not decoded: an earlier field failed Host: not decoded: an earlier field failed] true true",
+	}, "\n") + "\n"
+	if string(out) != want {
+		t.Errorf("unexpected output\nwant:\n%s\ngot:\n%s", want, out)
+	}
+}
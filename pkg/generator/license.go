@@ -0,0 +1,153 @@
+package generator
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With PackageConfig.License, a generated package is released under that
+// license: a LICENSE file is written to the package directory, manifests
+// with a license field (package.json, pyproject.toml, Cargo.toml, pom.xml,
+// .csproj) name it, and the README says so. Without it, manifests keep
+// their defaults and no LICENSE file is written.
+
+// Licenses for PackageConfig.License.
+const (
+	LicenseMIT         = "MIT"
+	LicenseApache      = "Apache-2.0"
+	LicenseProprietary = "proprietary"
+)
+
+// LicenseFile is the name of the license in a package directory.
+const LicenseFile = "LICENSE"
+
+// apacheLicense is the Apache License 2.0, verbatim.
+//
+//go:embed licenses/Apache-2.0.txt
+var apacheLicense string
+
+const mitLicense = `MIT License
+
+%s
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+`
+
+const proprietaryLicense = `%s. All rights reserved.
+
+This software is proprietary and confidential. No license to use, copy,
+modify or distribute it is granted except under a written agreement with
+the copyright holder.
+`
+
+// checkLicense normalizes config.License, accepting any case, and rejects
+// unknown licenses before anything is generated.
+func checkLicense(config *PackageConfig) error {
+	if config.License == "" {
+		if config.Copyright != "" {
+			return fmt.Errorf("--copyright needs --license")
+		}
+		return nil
+	}
+	for _, l := range []string{LicenseMIT, LicenseApache, LicenseProprietary} {
+		if strings.EqualFold(config.License, l) {
+			config.License = l
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown license %q (supported: %s, %s, %s)", config.License, LicenseMIT, LicenseApache, LicenseProprietary)
+}
+
+// writeLicense writes LicenseFile to the package directory and, for the
+// Apache License, a NOTICE with the copyright line.
+func writeLicense(config *PackageConfig) error {
+	if config.License == "" {
+		return nil
+	}
+	dir := packageDir(config)
+	var text string
+	switch config.License {
+	case LicenseMIT:
+		text = fmt.Sprintf(mitLicense, copyrightLine(config))
+	case LicenseApache:
+		text = apacheLicense
+		notice := config.Namespace + "\n" + copyrightLine(config) + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "NOTICE"), []byte(notice), 0644); err != nil {
+			return err
+		}
+	case LicenseProprietary:
+		text = fmt.Sprintf(proprietaryLicense, copyrightLine(config))
+	}
+	path := filepath.Join(dir, LicenseFile)
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return err
+	}
+	config.infof("✓ Wrote %s license: %s\n", config.License, path)
+	return nil
+}
+
+// copyrightLine returns "Copyright (c) <year> <holder>". The year comes
+// from SOURCE_DATE_EPOCH when set, so reproducible builds agree on it.
+func copyrightLine(config *PackageConfig) string {
+	year := time.Now().Year()
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		year = time.Unix(epoch, 0).UTC().Year()
+	}
+	holder := valueOr(config.Copyright, "The "+config.Namespace+" Authors")
+	return fmt.Sprintf("Copyright (c) %d %s", year, holder)
+}
+
+// spdxLicense returns the SPDX license expression of the package, or ""
+// if it has no license. Proprietary packages get a LicenseRef, which
+// registries show as a custom license found in LicenseFile.
+func spdxLicense(config *PackageConfig) string {
+	if config.License == LicenseProprietary {
+		return "LicenseRef-Proprietary"
+	}
+	return config.License
+}
+
+// readmeLicense returns the text of a README's License section, or
+// fallback if the package has no license.
+func readmeLicense(config *PackageConfig, fallback string) string {
+	switch config.License {
+	case LicenseMIT:
+		return "Released under the MIT License. See [LICENSE](LICENSE).\n"
+	case LicenseApache:
+		return "Released under the Apache License 2.0. See [LICENSE](LICENSE) and [NOTICE](NOTICE).\n"
+	case LicenseProprietary:
+		return "Proprietary and confidential. See [LICENSE](LICENSE).\n"
+	default:
+		return fallback
+	}
+}
+
+// readmeLicenseSection returns a License section to append to READMEs
+// that have none, or "" if the package has no license.
+func readmeLicenseSection(config *PackageConfig) string {
+	if config.License == "" {
+		return ""
+	}
+	return "\n## License\n\n" + readmeLicense(config, "")
+}
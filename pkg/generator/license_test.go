@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func TestCheckLicense(t *testing.T) {
+	tests := []struct {
+		license, copyright string
+		want               string // Normalized license, or an error substring
+		wantErr            bool
+	}{
+		{"", "", "", false},
+		{"mit", "Acme", LicenseMIT, false},
+		{"APACHE-2.0", "", LicenseApache, false},
+		{"Proprietary", "", LicenseProprietary, false},
+		{"GPL-3.0", "", `unknown license "GPL-3.0"`, true},
+		{"", "Acme", "--copyright needs --license", true},
+	}
+	for _, tt := range tests {
+		config := &PackageConfig{License: tt.license, Copyright: tt.copyright}
+		err := checkLicense(config)
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkLicense(%q, %q) = %v, want %q", tt.license, tt.copyright, err, tt.want)
+			}
+			continue
+		}
+		if err != nil || config.License != tt.want {
+			t.Errorf("checkLicense(%q, %q) = %v, license %q, want %q", tt.license, tt.copyright, err, config.License, tt.want)
+		}
+	}
+}
+
+func TestWriteLicense(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1735689600") // 2025-01-01
+	tests := []struct {
+		license, copyright string
+		want               []string
+		notice             string
+	}{
+		{LicenseMIT, "Acme Corp", []string{"MIT License\n\nCopyright (c) 2025 Acme Corp\n", "Permission is hereby granted"}, ""},
+		{LicenseApache, "", []string{"Apache License\n", "Version 2.0, January 2004", "END OF TERMS AND CONDITIONS"},
+			"audio\nCopyright (c) 2025 The audio Authors\n"},
+		{LicenseProprietary, "Acme Corp", []string{"Copyright (c) 2025 Acme Corp. All rights reserved.", "proprietary and confidential"}, ""},
+	}
+	for _, tt := range tests {
+		out := t.TempDir()
+		config := &PackageConfig{
+			Schema:    &schema.Schema{Package: "audio"},
+			Language:  "go",
+			OutputDir: out,
+			Namespace: "audio",
+			License:   tt.license,
+			Copyright: tt.copyright,
+		}
+		if err := writeLicense(config); err != nil {
+			t.Fatalf("%s: writeLicense failed: %v", tt.license, err)
+		}
+		data, err := os.ReadFile(filepath.Join(out, LicenseFile))
+		if err != nil {
+			t.Fatalf("%s: LICENSE not written: %v", tt.license, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: LICENSE missing %q:\n%s", tt.license, want, data)
+			}
+		}
+		notice, err := os.ReadFile(filepath.Join(out, "NOTICE"))
+		if tt.notice == "" {
+			if err == nil {
+				t.Errorf("%s: unexpected NOTICE", tt.license)
+			}
+		} else if string(notice) != tt.notice {
+			t.Errorf("%s: NOTICE = %q, want %q", tt.license, notice, tt.notice)
+		}
+	}
+}
+
+func TestLicenseManifests(t *testing.T) {
+	tests := []struct {
+		license string
+		want    map[string]string // Manifest to the license field it must contain
+	}{
+		{"", map[string]string{
+			"package.json":   `"license": "MIT",`,
+			"pyproject.toml": "license = \"MIT\"\nkeywords",
+		}},
+		{LicenseApache, map[string]string{
+			"package.json":   `"license": "Apache-2.0",`,
+			"pyproject.toml": "license = \"Apache-2.0\"\nlicense-files = [\"LICENSE\"]\n",
+			"Cargo.toml":     "license = \"Apache-2.0\"\n",
+			"pom.xml":        "<name>Apache License, Version 2.0</name>\n            <url>https://www.apache.org/licenses/LICENSE-2.0</url>",
+		}},
+		{LicenseProprietary, map[string]string{
+			"package.json":   `"license": "UNLICENSED",`,
+			"pyproject.toml": "license = \"LicenseRef-Proprietary\"\nlicense-files = [\"LICENSE\"]\n",
+			"Cargo.toml":     "license-file = \"LICENSE\"\n",
+			"pom.xml":        "<license>\n            <name>Proprietary</name>\n        </license>",
+		}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		config := &PackageConfig{Schema: &schema.Schema{Package: "audio"}, Namespace: "audio", Version: "1.0.0", License: tt.license}
+		if err := generateJSPackageJSON(config, dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := generatePyProjectTOML(config, dir, "audio"); err != nil {
+			t.Fatal(err)
+		}
+		manifests := map[string]string{
+			"Cargo.toml": generateCargoToml("audio", "audio", "1.0.0", tt.license),
+			"pom.xml":    string(generateJavaPom(config)),
+		}
+		for _, name := range []string{"package.json", "pyproject.toml"} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifests[name] = string(data)
+		}
+
+		for name, want := range tt.want {
+			if !strings.Contains(manifests[name], want) {
+				t.Errorf("%q: %s does not contain %q:\n%s", tt.license, name, want, manifests[name])
+			}
+		}
+		if tt.license == "" {
+			for _, name := range []string{"Cargo.toml", "pom.xml"} {
+				if strings.Contains(manifests[name], "icense") {
+					t.Errorf("%s names a license without --license:\n%s", name, manifests[name])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goLinter is a check run over generated Go packages by VerifyLint.
+type goLinter struct {
+	name       string   // Shown in output
+	args       []string // Command line, run in the package directory
+	optional   bool     // Skipped with a warning when not on PATH
+	listsFiles bool     // Exits 0 and lists offending files (gofumpt -l)
+}
+
+// goLinters are the checks generated Go code is guaranteed to pass. go vet
+// ships with Go; staticcheck and gofumpt run when they are installed.
+// ST1000 is off: without --go-module the code joins an existing package,
+// whose documentation is the user's.
+var goLinters = []goLinter{
+	{name: "go vet", args: []string{"go", "vet", "./..."}},
+	{name: "staticcheck", args: []string{"staticcheck", "-checks", "all,-ST1000", "./..."}, optional: true},
+	{name: "gofumpt", args: []string{"gofumpt", "-l", "."}, optional: true, listsFiles: true},
+}
+
+func checkVerifyLint(config *PackageConfig) error {
+	if config.VerifyLint && canonicalLanguage(config.Language) != "go" {
+		return fmt.Errorf("--verify-lint only applies to Go packages, not %s", config.Language)
+	}
+	return nil
+}
+
+// verifyLint runs goLinters over the generated Go package and fails if any
+// of them reports a finding.
+func verifyLint(config *PackageConfig) error {
+	dir, cleanup, err := lintModule(config.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to prepare lint module: %w", err)
+	}
+	defer cleanup()
+
+	var failed []string
+	for _, l := range goLinters {
+		if _, err := exec.LookPath(l.args[0]); err != nil {
+			if !l.optional {
+				return fmt.Errorf("--verify-lint needs %s on PATH", l.args[0])
+			}
+			config.warnf("⚠ %s not found, skipping it\n", l.name)
+			continue
+		}
+
+		cmd := config.command(l.args[0], l.args[1:]...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod")
+		config.debugf("Running: %s\n", strings.Join(l.args, " "))
+		output, err := cmd.CombinedOutput()
+		if ctxErr := config.context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || (l.listsFiles && len(bytes.TrimSpace(output)) > 0) {
+			config.warnf("✗ %s:\n%s", strings.Join(l.args, " "), output)
+			failed = append(failed, l.name)
+			continue
+		}
+		config.infof("✓ %s: no findings\n", l.name)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("generated Go code failed lint checks: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// lintModule returns a module directory holding the generated package. A
+// package generated with --go-module is linted in place; otherwise its Go
+// files are copied into a temporary module, as the linters need one.
+func lintModule(dir string) (string, func(), error) {
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return dir, func() {}, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "ffire-lint-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		if err := os.WriteFile(filepath.Join(tmp, filepath.Base(f)), data, 0644); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+	gomod := fmt.Sprintf("module ffirelint\n\ngo %s\n", goModuleGoVersion)
+	if err := os.WriteFile(filepath.Join(tmp, "go.mod"), []byte(gomod), 0644); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp, cleanup, nil
+}
@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// TestGoLint generates every test schema with roundtrip tests and runs
+// --verify-lint over the output. staticcheck and gofumpt are skipped when
+// not installed; CI installs them.
+func TestGoLint(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping lint checks of generated Go code in short mode")
+	}
+	schemas, err := filepath.Glob("../../testdata/schema/*.ffi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edgecases, err := filepath.Glob("../../testdata/edgecases/*.ffi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range append(schemas, edgecases...) {
+		name := strings.TrimSuffix(filepath.Base(filepath.Dir(path))+"/"+filepath.Base(path), ".ffi")
+		t.Run(name, func(t *testing.T) {
+			s, err := parser.Parse(path)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			for _, module := range []string{"", "example.com/lint"} {
+				config := &PackageConfig{
+					Schema:     s,
+					Language:   "go",
+					OutputDir:  t.TempDir(),
+					GoModule:   module,
+					WithTests:  true,
+					VerifyLint: true,
+				}
+				if err := GeneratePackage(config); err != nil {
+					t.Errorf("go module %q: %v", module, err)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyLintFindings(t *testing.T) {
+	out := t.TempDir()
+	code := "package bad\n\nimport \"fmt\"\n\nfunc Bad() string { return fmt.Sprintf(\"%d\", \"x\") }\n"
+	if err := os.WriteFile(filepath.Join(out, "bad.go"), []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := verifyLint(&PackageConfig{Language: "go", OutputDir: out})
+	if err == nil || !strings.Contains(err.Error(), "go vet") {
+		t.Errorf("err = %v, want go vet findings", err)
+	}
+	// The temporary module is gone and nothing was added to the output
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("output has %d entries, want only bad.go", len(entries))
+	}
+}
+
+func TestVerifyLintOnlyGo(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "cpp", OutputDir: t.TempDir(), NoCompile: true, VerifyLint: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "only applies to Go") {
+		t.Errorf("err = %v, want only applies to Go", err)
+	}
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Level is the verbosity of a log message.
+type Level int
+
+const (
+	LevelDebug Level = iota // Commands run and tool output, as shown by ffire generate -v
+	LevelInfo               // Progress, such as "✓ Generated Go package: ..."
+	LevelWarn               // Problems that do not fail generation
+	LevelQuiet              // Minimum level that drops every message
+)
+
+// Logger receives the progress of GeneratePackage. A message may span
+// several lines and has no trailing newline.
+type Logger interface {
+	Log(level Level, msg string)
+}
+
+// NewLogger returns a Logger that writes messages at level and above as
+// lines: warnings to stderr, everything else to stdout. This is what
+// ffire generate prints.
+func NewLogger(stdout, stderr io.Writer, level Level) Logger {
+	return &writerLogger{stdout: stdout, stderr: stderr, level: level}
+}
+
+type writerLogger struct {
+	stdout, stderr io.Writer
+	level          Level
+}
+
+func (l *writerLogger) Log(level Level, msg string) {
+	if level < l.level {
+		return
+	}
+	w := l.stdout
+	if level >= LevelWarn {
+		w = l.stderr
+	}
+	fmt.Fprintln(w, msg)
+}
+
+// NewSlogLogger returns a Logger that sends messages to l as structured
+// records, for tools that log JSON or forward logs elsewhere.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (s slogLogger) Log(level Level, msg string) {
+	sl := slog.LevelInfo
+	switch level {
+	case LevelDebug:
+		sl = slog.LevelDebug
+	case LevelWarn:
+		sl = slog.LevelWarn
+	}
+	s.l.Log(context.Background(), sl, strings.TrimSpace(msg))
+}
+
+// discardLogger drops every message. It is the default, so a library
+// caller sees no output unless it sets PackageConfig.Logger or Verbose.
+type discardLogger struct{}
+
+func (discardLogger) Log(Level, string) {}
+
+func (c *PackageConfig) logger() Logger {
+	if c.Logger == nil {
+		if c.Verbose {
+			return NewLogger(os.Stdout, os.Stderr, LevelDebug)
+		}
+		return discardLogger{}
+	}
+	return c.Logger
+}
+
+func (c *PackageConfig) logf(level Level, format string, args ...any) {
+	c.logger().Log(level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
+
+func (c *PackageConfig) debugf(format string, args ...any) { c.logf(LevelDebug, format, args...) }
+func (c *PackageConfig) infof(format string, args ...any)  { c.logf(LevelInfo, format, args...) }
+func (c *PackageConfig) warnf(format string, args ...any)  { c.logf(LevelWarn, format, args...) }
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testLogger logs generation progress with t.Log, shown by go test -v and
+// for failed tests.
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Log(_ Level, msg string) {
+	l.t.Helper()
+	l.t.Log(msg)
+}
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		level          Level
+		stdout, stderr string
+	}{
+		{LevelDebug, "debug\ninfo\n", "warn\n"},
+		{LevelInfo, "info\n", "warn\n"},
+		{LevelWarn, "", "warn\n"},
+		{LevelQuiet, "", ""},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		config := &PackageConfig{Logger: NewLogger(&stdout, &stderr, tt.level)}
+		config.debugf("debug")
+		config.infof("info\n")
+		config.warnf("%s", "warn")
+		if stdout.String() != tt.stdout || stderr.String() != tt.stderr {
+			t.Errorf("level %d: stdout %q, stderr %q; want %q, %q", tt.level, stdout.String(), stderr.String(), tt.stdout, tt.stderr)
+		}
+	}
+}
+
+func TestVerboseLogger(t *testing.T) {
+	l, ok := (&PackageConfig{Verbose: true}).logger().(*writerLogger)
+	if !ok || l.level != LevelDebug || l.stdout != os.Stdout || l.stderr != os.Stderr {
+		t.Errorf("Verbose logger = %#v, want debug to os.Stdout and os.Stderr", l)
+	}
+	var stdout bytes.Buffer
+	config := &PackageConfig{Verbose: true, Logger: NewLogger(&stdout, &stdout, LevelInfo)}
+	config.debugf("debug")
+	if stdout.Len() != 0 {
+		t.Errorf("Verbose overrode Logger: %q", stdout.String())
+	}
+}
+
+func TestNewSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	config := &PackageConfig{Logger: NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))}
+	config.debugf("dropped by the handler's level")
+	config.warnf("⚠ hook failed\n")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d records, want 1:\n%s", len(lines), buf.String())
+	}
+	var record struct{ Level, Msg string }
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Level != "WARN" || record.Msg != "⚠ hook failed" {
+		t.Errorf("record = %+v", record)
+	}
+}
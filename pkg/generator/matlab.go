@@ -0,0 +1,697 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// MATLAB and Octave packages are readers: a +package folder with a
+// decode function per message, written in plain .m code that both
+// interpreters run without a compiler or a MEX file. Each function file
+// holds the local functions it needs, so one can be copied on its own.
+//
+// Numbers are read with typecast, which decodes a whole numeric array in
+// one call. Arrays of structs whose fields are all numbers, bools, enums
+// or fixed-size arrays of them decode to one struct of columns, whose
+// fields are column vectors or matrices with a row per element, so
+// captured data is ready for signal processing without a loop.
+//
+// Decoders make every check of the reference decoder in pkg/fixture and
+// fail with an error whose identifier is ffire:<DecodeErrorCode name>,
+// such as ffire:Truncated.
+
+// matlabRuntime holds the local functions the decoders read with. d is the
+// message as a uint8 column, p the index of the next byte and e the index
+// of the last byte of the message, or of the frame being decoded.
+const matlabRuntime = `function [v, p] = ff_read(d, p, e, cls, n, width)
+% Reads n little-endian numbers of class cls, of width bytes each.
+q = p + n * width;
+if q - 1 > e
+    ff_fail('Truncated', 'unexpected end of data at offset %d', p - 1);
+end
+if n == 0
+    v = zeros(0, 1, cls);
+else
+    v = typecast(d(p:q-1), cls);
+end
+p = q;
+end
+
+function v = ff_bools(b, p)
+% Converts bytes read before offset p to logicals, rejecting values other than 0 and 1.
+if any(b(:) > 1)
+    ff_fail('InvalidValue', 'invalid bool value before offset %d', p - 1);
+end
+v = b ~= 0;
+end
+
+function [v, p] = ff_bool(d, p, e, n)
+[b, p] = ff_read(d, p, e, 'uint8', n, 1);
+v = ff_bools(b, p);
+end
+
+function [v, p] = ff_present(d, p, e)
+[b, p] = ff_read(d, p, e, 'uint8', 1, 1);
+if b > 1
+    ff_fail('BadPresence', 'invalid optional flag 0x%02x at offset %d', b, p - 2);
+end
+v = b == 1;
+end
+
+function [n, p] = ff_count(d, p, e, large)
+% Reads a length prefix, checking the data left holds n bytes: every element takes at least one.
+if large
+    [n, p] = ff_read(d, p, e, 'uint32', 1, 4);
+else
+    [n, p] = ff_read(d, p, e, 'uint16', 1, 2);
+end
+n = double(n);
+if n > e - p + 1
+    ff_fail('Overflow', 'length %d exceeds the %d bytes left at offset %d', n, e - p + 1, p - 1);
+end
+end
+
+function [v, p] = ff_bytes(d, p, e, large)
+[n, p] = ff_count(d, p, e, large);
+v = d(p:p+n-1);
+p = p + n;
+end
+
+function [v, p] = ff_string(d, p, e, large)
+[b, p] = ff_bytes(d, p, e, large);
+if ~ff_utf8(b)
+    ff_fail('InvalidUtf8', 'invalid UTF-8 in string at offset %d', p - numel(b) - 1);
+end
+if isempty(b)
+    v = '';
+else
+    v = native2unicode(b.', 'UTF-8');
+end
+end
+
+function ok = ff_utf8(b)
+% Reports whether b is valid UTF-8, without overlong forms, surrogates or code points above U+10FFFF.
+ok = true;
+if all(b < 128)
+    return;
+end
+b = double(b);
+n = numel(b);
+i = 1;
+while i <= n
+    c = b(i);
+    lo = 128;
+    hi = 191;
+    if c < 128
+        i = i + 1;
+        continue;
+    elseif c >= 194 && c <= 223
+        k = 1;
+    elseif c >= 224 && c <= 239
+        k = 2;
+        if c == 224
+            lo = 160;
+        elseif c == 237
+            hi = 159;
+        end
+    elseif c >= 240 && c <= 244
+        k = 3;
+        if c == 240
+            lo = 144;
+        elseif c == 244
+            hi = 143;
+        end
+    else
+        ok = false;
+        return;
+    end
+    if i + k > n || b(i+1) < lo || b(i+1) > hi || any(b(i+2:i+k) < 128 | b(i+2:i+k) > 191)
+        ok = false;
+        return;
+    end
+    i = i + k + 1;
+end
+end
+
+function [bits, p] = ff_bitmap(d, p, e, width, used)
+% Reads the presence bitmap of a @bitmap struct, rejecting unused bits that are set.
+[bits, p] = ff_read(d, p, e, 'uint8', width, 1);
+if mod(used, 8) > 0 && bitshift(bits(end), -mod(used, 8)) ~= 0
+    ff_fail('BadPresence', 'invalid presence bitmap at offset %d', p - width - 1);
+end
+end
+
+function [e, p] = ff_frame(d, p, e)
+% Reads the length of a @framed struct, returning the index of its last byte.
+[n, p] = ff_read(d, p, e, 'uint32', 1, 4);
+n = double(n);
+if n > e - p + 1
+    ff_fail('Overflow', 'frame length %d exceeds the %d bytes left at offset %d', n, e - p + 1, p - 1);
+end
+e = p + n - 1;
+end
+
+function ff_end_frame(p, e)
+if p ~= e + 1
+    ff_fail('TrailingData', '%d bytes left in a frame at offset %d', e - p + 1, p - 1);
+end
+end
+
+function raw = ff_rows(d, p, e, n, width)
+% Returns n elements of width bytes as the columns of a matrix.
+if p + n * width - 1 > e
+    ff_fail('Truncated', 'unexpected end of data at offset %d', p - 1);
+end
+raw = reshape(d(p:p+n*width-1), width, n);
+end
+
+function v = ff_column(raw, offset, count, width, cls)
+% Returns the count numbers of class cls at byte offset of each column of raw, a row per column.
+if isempty(raw)
+    v = zeros(0, count, cls);
+else
+    v = reshape(typecast(reshape(raw(offset+1:offset+count*width, :), [], 1), cls), count, []).';
+end
+end
+
+function ff_fail(code, varargin)
+error(['ffire:' code], varargin{:});
+end
+`
+
+// matlabClasses maps number primitives to MATLAB classes.
+var matlabClasses = map[string]string{
+	"int8":    "int8",
+	"int16":   "int16",
+	"int32":   "int32",
+	"int64":   "int64",
+	"float32": "single",
+	"float64": "double",
+}
+
+// GenerateMatlabPackage generates a MATLAB and Octave reader package:
+//
+//	matlab/
+//	  +{package}/
+//	    decode{Message}.m  - Decoder of each message
+//	    Contents.m         - Listing shown by help {package}
+//	  README.md
+func GenerateMatlabPackage(config *PackageConfig) error {
+	config.debugf("Generating MATLAB package")
+
+	pkgName := matlabIdentifier(config.Schema.Package)
+	matlabDir := config.langDir("matlab")
+	pkgDir := filepath.Join(matlabDir, "+"+pkgName)
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", pkgDir, err)
+	}
+
+	files, err := GenerateMatlab(config.Schema)
+	if err != nil {
+		return err
+	}
+	files["Contents.m"] = matlabContents(config.Schema, pkgName)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(pkgDir, name), content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	readmePath := filepath.Join(matlabDir, "README.md")
+	if err := os.WriteFile(readmePath, matlabReadme(config, pkgName), 0644); err != nil {
+		return fmt.Errorf("failed to write README.md: %w", err)
+	}
+	config.infof("✓ Generated MATLAB package: %s\n", pkgDir)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n✅ MATLAB package ready at: %s\n\n", matlabDir)
+	fmt.Fprintln(&b, "Add it to the path in MATLAB or Octave (no compiler needed):")
+	fmt.Fprintf(&b, "  addpath('%s')\n", matlabDir)
+	if len(config.Schema.Messages) > 0 {
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "Usage:")
+		fmt.Fprintf(&b, "  value = %s.decode%s('capture.bin');\n", pkgName, config.Schema.Messages[0].Name)
+	}
+	fmt.Fprintln(&b)
+	config.infof("%s", b.String())
+	return nil
+}
+
+// GenerateMatlab returns the function files of a MATLAB package for s,
+// by file name.
+func GenerateMatlab(s *schema.Schema) (map[string][]byte, error) {
+	s.Canonicalize()
+	pkgName := matlabIdentifier(s.Package)
+	files := make(map[string][]byte, len(s.Messages))
+	for _, msg := range s.Messages {
+		g := &matlabGenerator{buf: &bytes.Buffer{}}
+		g.generateMessage(pkgName, msg)
+		files["decode"+msg.Name+".m"] = g.buf.Bytes()
+	}
+	return files, nil
+}
+
+// matlabIdentifier returns name as a MATLAB identifier: a letter followed
+// by letters, digits and underscores.
+func matlabIdentifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r < 128 && (r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	id := b.String()
+	if id == "" || id[0] < 'A' || id[0] == '_' || id[0] > 'Z' && id[0] < 'a' {
+		id = "x" + id
+	}
+	return id
+}
+
+type matlabGenerator struct {
+	buf  *bytes.Buffer
+	vars int // Counter for unique variables
+}
+
+func (g *matlabGenerator) uniqueVar(prefix string) string {
+	g.vars++
+	return fmt.Sprintf("%s%d", prefix, g.vars)
+}
+
+// generateMessage writes the function file decoding msg: the decode
+// function, then the local functions of the types it reaches and the
+// runtime.
+func (g *matlabGenerator) generateMessage(pkgName string, msg schema.MessageType) {
+	fn := "decode" + msg.Name
+	upper := strings.ToUpper(pkgName + "." + fn)
+	fmt.Fprintf(g.buf, "function value = %s(data)\n", fn)
+	fmt.Fprintf(g.buf, "%%%s Decode a %s message.\n", strings.ToUpper(fn), msg.Name)
+	for _, line := range strings.Split(msg.Doc, "\n") {
+		if line != "" {
+			fmt.Fprintf(g.buf, "%%   %s\n", line)
+		}
+	}
+	fmt.Fprintf(g.buf, "%%   VALUE = %s(DATA) decodes the ffire encoding of a %s message in\n", upper, msg.Name)
+	g.buf.WriteString("%   DATA, a uint8 or int8 vector. VALUE = " + upper + "(FILENAME) reads the\n")
+	g.buf.WriteString("%   message from a file.\n")
+	g.buf.WriteString("%\n")
+	g.buf.WriteString("%   Structs decode to structs and optional values that are absent to []. Numbers\n")
+	g.buf.WriteString("%   keep their class, enums decode to their integer values and bools to logicals.\n")
+	g.buf.WriteString("%   Arrays of numbers are column vectors, and arrays of structs whose fields are\n")
+	g.buf.WriteString("%   all numbers, bools, enums or fixed-size arrays decode to one struct of\n")
+	g.buf.WriteString("%   columns, with a row per element. Other arrays of structs are struct arrays,\n")
+	g.buf.WriteString("%   and the remaining arrays cell columns. Maps are containers.Map objects and\n")
+	g.buf.WriteString("%   unions structs with the Type and Value of their variant.\n")
+	g.buf.WriteString("%\n")
+	g.buf.WriteString("%   Invalid data raises an error whose identifier is ffire:<code>, such as\n")
+	g.buf.WriteString("%   ffire:Truncated, ffire:Overflow, ffire:InvalidUtf8, ffire:BadPresence,\n")
+	g.buf.WriteString("%   ffire:InvalidValue or ffire:TrailingData.\n\n")
+	g.buf.WriteString("% Code generated by ffire. DO NOT EDIT.\n\n")
+
+	g.buf.WriteString("if ischar(data) || isstring(data)\n")
+	g.buf.WriteString("    fid = fopen(data, 'r');\n")
+	g.buf.WriteString("    if fid < 0\n")
+	g.buf.WriteString("        error('ffire:File', 'cannot open %s', data);\n")
+	g.buf.WriteString("    end\n")
+	g.buf.WriteString("    data = fread(fid, Inf, '*uint8');\n")
+	g.buf.WriteString("    fclose(fid);\n")
+	g.buf.WriteString("elseif isa(data, 'int8')\n")
+	g.buf.WriteString("    data = typecast(data(:), 'uint8');\n")
+	g.buf.WriteString("end\n")
+	g.buf.WriteString("d = uint8(data(:));\n")
+	g.buf.WriteString("e = numel(d);\n")
+	g.buf.WriteString("p = 1;\n")
+	g.decode(msg.TargetType, "value", "")
+	g.buf.WriteString("if p <= e\n")
+	g.buf.WriteString("    ff_fail('TrailingData', '%d trailing bytes after message', e - p + 1);\n")
+	g.buf.WriteString("end\n")
+	g.buf.WriteString("end\n")
+
+	types, single, columns := matlabReachable(msg.TargetType)
+	for _, t := range types {
+		switch v := t.(type) {
+		case *schema.StructType:
+			if single[v.Name] {
+				g.generateStruct(v)
+			}
+			if columns[v.Name] {
+				g.generateColumns(v)
+			}
+		case *schema.UnionType:
+			g.generateUnion(v)
+		case *schema.EnumType:
+			g.generateEnum(v)
+		}
+	}
+	g.buf.WriteString("\n")
+	g.buf.WriteString(matlabRuntime)
+}
+
+// matlabReachable returns the structs, unions and enums a value of type t
+// may hold, each once, and by name the structs decoded one at a time and
+// those decoded as columns.
+func matlabReachable(t schema.Type) (types []schema.Type, single, columns map[string]bool) {
+	seen := make(map[string]bool)
+	single, columns = make(map[string]bool), make(map[string]bool)
+	var walk func(t schema.Type)
+	walk = func(t schema.Type) {
+		switch v := t.(type) {
+		case *schema.StructType:
+			single[v.Name] = true
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				types = append(types, v)
+				for _, f := range v.Fields {
+					walk(f.Type)
+				}
+			}
+		case *schema.UnionType:
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				types = append(types, v)
+				for _, variant := range v.Variants {
+					walk(variant)
+				}
+			}
+		case *schema.EnumType:
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				types = append(types, v)
+			}
+		case *schema.ArrayType:
+			if st, ok := v.ElementType.(*schema.StructType); ok && matlabColumnar(st) {
+				// Columnar structs hold no other types
+				columns[st.Name] = true
+				if !seen[st.Name] {
+					seen[st.Name] = true
+					types = append(types, st)
+					for _, f := range st.Fields {
+						walk(f.Type)
+					}
+				}
+				return
+			}
+			walk(v.ElementType)
+		case *schema.MapType:
+			walk(v.KeyType)
+			walk(v.ValueType)
+		}
+	}
+	walk(t)
+	return types, single, columns
+}
+
+// matlabColumnar reports whether arrays of st decode to a struct of
+// columns: st is not optional and its fields are all non-optional
+// numbers, bools, enums or fixed-size arrays of numbers and bools, so its
+// encoding has a fixed size.
+func matlabColumnar(st *schema.StructType) bool {
+	if st.Optional || st.Framed || len(st.Fields) == 0 {
+		return false
+	}
+	for _, f := range st.Fields {
+		if matlabFixedWidth(f.Type) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// matlabFixedWidth returns the encoded size of a field of a columnar
+// struct, or 0 if t cannot be one.
+func matlabFixedWidth(t schema.Type) int {
+	switch v := t.(type) {
+	case *schema.EnumType:
+		if v.Optional {
+			return 0
+		}
+		return schema.PrimitiveSize(v.Base)
+	case *schema.ArrayType:
+		if v.Length == 0 {
+			return 0
+		}
+		return v.Length * schema.GetPrimitiveSize(v.ElementType)
+	}
+	return schema.GetPrimitiveSize(t)
+}
+
+func (g *matlabGenerator) generateStruct(st *schema.StructType) {
+	fmt.Fprintf(g.buf, "\nfunction [v, p] = dec_%s(d, p, e)\n", st.Name)
+	if st.Framed {
+		g.buf.WriteString("[e, p] = ff_frame(d, p, e);\n")
+	}
+	if st.Bitmap {
+		fmt.Fprintf(g.buf, "[bits, p] = ff_bitmap(d, p, e, %d, %d);\n", schema.BitmapSize(st), schema.OptionalFields(st))
+	}
+	g.buf.WriteString("v = struct();\n")
+	bit := 0
+	for _, f := range st.Fields {
+		target := "v." + f.Name
+		if st.Bitmap && f.Type.IsOptional() {
+			fmt.Fprintf(g.buf, "if bitand(bits(%d), %d)\n", bit/8+1, 1<<(bit%8))
+			g.decodeContent(f.Type, target, "    ")
+			g.buf.WriteString("else\n")
+			fmt.Fprintf(g.buf, "    %s = [];\n", target)
+			g.buf.WriteString("end\n")
+			bit++
+			continue
+		}
+		g.decode(f.Type, target, "")
+	}
+	if st.Framed {
+		g.buf.WriteString("ff_end_frame(p, e);\n")
+	}
+	g.buf.WriteString("end\n")
+}
+
+// generateColumns writes the decoder of n elements of a columnar struct
+// into one struct of columns.
+func (g *matlabGenerator) generateColumns(st *schema.StructType) {
+	width := 0
+	for _, f := range st.Fields {
+		width += matlabFixedWidth(f.Type)
+	}
+	fmt.Fprintf(g.buf, "\nfunction [v, p] = dec_%s_columns(d, p, e, n)\n", st.Name)
+	fmt.Fprintf(g.buf, "raw = ff_rows(d, p, e, n, %d);\n", width)
+	g.buf.WriteString("v = struct();\n")
+	offset := 0
+	for _, f := range st.Fields {
+		count, elem := 1, f.Type
+		if arr, ok := f.Type.(*schema.ArrayType); ok {
+			count, elem = arr.Length, arr.ElementType
+		}
+		switch v := elem.(type) {
+		case *schema.EnumType:
+			fmt.Fprintf(g.buf, "v.%s = chk_%s(ff_column(raw, %d, %d, %d, '%s'), p);\n", f.Name, v.Name, offset, count, schema.PrimitiveSize(v.Base), matlabClasses[v.Base])
+		default:
+			size := schema.GetPrimitiveSize(elem)
+			if elem.TypeName() == "bool" {
+				fmt.Fprintf(g.buf, "v.%s = ff_bools(ff_column(raw, %d, %d, 1, 'uint8'), p);\n", f.Name, offset, count)
+			} else {
+				fmt.Fprintf(g.buf, "v.%s = ff_column(raw, %d, %d, %d, '%s');\n", f.Name, offset, count, size, matlabClasses[elem.TypeName()])
+			}
+		}
+		offset += matlabFixedWidth(f.Type)
+	}
+	fmt.Fprintf(g.buf, "p = p + n * %d;\n", width)
+	g.buf.WriteString("end\n")
+}
+
+// generateUnion writes the decoder of a union, which returns a struct
+// with the Type name and the Value of the variant.
+func (g *matlabGenerator) generateUnion(u *schema.UnionType) {
+	fmt.Fprintf(g.buf, "\nfunction [v, p] = dec_%s(d, p, e)\n", u.Name)
+	g.buf.WriteString("[tag, p] = ff_read(d, p, e, 'uint8', 1, 1);\n")
+	g.buf.WriteString("v = struct();\n")
+	g.buf.WriteString("switch tag\n")
+	for i, variant := range u.Variants {
+		fmt.Fprintf(g.buf, "    case %d\n", i+1)
+		fmt.Fprintf(g.buf, "        v.Type = '%s';\n", variant.TypeName())
+		g.decode(variant, "v.Value", "        ")
+	}
+	g.buf.WriteString("    otherwise\n")
+	fmt.Fprintf(g.buf, "        ff_fail('InvalidValue', 'invalid %s tag %%d at offset %%d', tag, p - 2);\n", u.Name)
+	g.buf.WriteString("end\n")
+	g.buf.WriteString("end\n")
+}
+
+// generateEnum writes the check that values read as an enum are listed.
+func (g *matlabGenerator) generateEnum(enum *schema.EnumType) {
+	values := make([]string, len(enum.Values))
+	for i, v := range enum.Values {
+		values[i] = fmt.Sprint(v.Value)
+	}
+	fmt.Fprintf(g.buf, "\nfunction v = chk_%s(v, p)\n", enum.Name)
+	fmt.Fprintf(g.buf, "if ~all(ismember(v(:), [%s]))\n", strings.Join(values, " "))
+	fmt.Fprintf(g.buf, "    ff_fail('InvalidValue', 'invalid %s value before offset %%d', p - 1);\n", enum.Name)
+	g.buf.WriteString("end\n")
+	g.buf.WriteString("end\n")
+}
+
+// decode writes the statements that read a value of type t into target.
+func (g *matlabGenerator) decode(t schema.Type, target, indent string) {
+	if !t.IsOptional() {
+		g.decodeContent(t, target, indent)
+		return
+	}
+	has := g.uniqueVar("has")
+	fmt.Fprintf(g.buf, "%s[%s, p] = ff_present(d, p, e);\n", indent, has)
+	fmt.Fprintf(g.buf, "%sif %s\n", indent, has)
+	g.decodeContent(t, target, indent+"    ")
+	fmt.Fprintf(g.buf, "%selse\n", indent)
+	fmt.Fprintf(g.buf, "%s    %s = [];\n", indent, target)
+	fmt.Fprintf(g.buf, "%send\n", indent)
+}
+
+// decodeContent is decode for a value whose presence, if it is optional,
+// has been read.
+func (g *matlabGenerator) decodeContent(t schema.Type, target, indent string) {
+	switch v := t.(type) {
+	case *schema.PrimitiveType:
+		g.decodeNumbers(v, "1", target, indent)
+	case *schema.EnumType:
+		g.decodeNumbers(v, "1", target, indent)
+	case *schema.StructType:
+		fmt.Fprintf(g.buf, "%s[%s, p] = dec_%s(d, p, e);\n", indent, target, v.Name)
+	case *schema.UnionType:
+		fmt.Fprintf(g.buf, "%s[%s, p] = dec_%s(d, p, e);\n", indent, target, v.Name)
+	case *schema.ArrayType:
+		count := fmt.Sprint(v.Length)
+		if v.Length == 0 {
+			count = g.uniqueVar("n")
+			fmt.Fprintf(g.buf, "%s[%s, p] = ff_count(d, p, e, %s);\n", indent, count, matlabBool(v.Large))
+		}
+		elem := v.ElementType
+		if !elem.IsOptional() {
+			switch e := elem.(type) {
+			case *schema.PrimitiveType:
+				if e.Name != "string" && e.Name != "bytes" {
+					g.decodeNumbers(e, count, target, indent)
+					return
+				}
+			case *schema.EnumType:
+				g.decodeNumbers(e, count, target, indent)
+				return
+			case *schema.StructType:
+				if matlabColumnar(e) {
+					fmt.Fprintf(g.buf, "%s[%s, p] = dec_%s_columns(d, p, e, %s);\n", indent, target, e.Name, count)
+					return
+				}
+			}
+		}
+		i, c := g.uniqueVar("i"), g.uniqueVar("c")
+		fmt.Fprintf(g.buf, "%s%s = cell(%s, 1);\n", indent, c, count)
+		fmt.Fprintf(g.buf, "%sfor %s = 1:%s\n", indent, i, count)
+		g.decode(elem, c+"{"+i+"}", indent+"    ")
+		fmt.Fprintf(g.buf, "%send\n", indent)
+		if st, ok := elem.(*schema.StructType); ok && !st.Optional {
+			// Structs of one type concatenate into a struct array
+			fmt.Fprintf(g.buf, "%s%s = vertcat(%s{:});\n", indent, target, c)
+		} else {
+			fmt.Fprintf(g.buf, "%s%s = %s;\n", indent, target, c)
+		}
+	case *schema.MapType:
+		n, i, k, x := g.uniqueVar("n"), g.uniqueVar("i"), g.uniqueVar("k"), g.uniqueVar("x")
+		keyType, key := "double", "double("+k+")"
+		switch v.KeyType.TypeName() {
+		case "string":
+			keyType, key = "char", k
+		case "int64":
+			keyType, key = "int64", k
+		}
+		fmt.Fprintf(g.buf, "%s[%s, p] = ff_count(d, p, e, %s);\n", indent, n, matlabBool(v.Large))
+		fmt.Fprintf(g.buf, "%s%s = containers.Map('KeyType', '%s', 'ValueType', 'any');\n", indent, target, keyType)
+		fmt.Fprintf(g.buf, "%sfor %s = 1:%s\n", indent, i, n)
+		g.decodeContent(v.KeyType, k, indent+"    ")
+		g.decode(v.ValueType, x, indent+"    ")
+		// A repeated key keeps the last entry, as in the other decoders
+		fmt.Fprintf(g.buf, "%s    %s(%s) = %s;\n", indent, target, key, x)
+		fmt.Fprintf(g.buf, "%send\n", indent)
+	}
+}
+
+// decodeNumbers writes the statements that read count values of a
+// primitive or enum type t into target: a scalar for count 1, and
+// otherwise a column vector, or bytes for a string or bytes.
+func (g *matlabGenerator) decodeNumbers(t schema.Type, count, target, indent string) {
+	switch v := t.(type) {
+	case *schema.EnumType:
+		fmt.Fprintf(g.buf, "%s[%s, p] = ff_read(d, p, e, '%s', %s, %d);\n", indent, target, matlabClasses[v.Base], count, schema.PrimitiveSize(v.Base))
+		fmt.Fprintf(g.buf, "%s%s = chk_%s(%s, p);\n", indent, target, v.Name, target)
+	case *schema.PrimitiveType:
+		switch v.Name {
+		case "bool":
+			fmt.Fprintf(g.buf, "%s[%s, p] = ff_bool(d, p, e, %s);\n", indent, target, count)
+		case "string":
+			fmt.Fprintf(g.buf, "%s[%s, p] = ff_string(d, p, e, %s);\n", indent, target, matlabBool(v.Large))
+		case "bytes":
+			fmt.Fprintf(g.buf, "%s[%s, p] = ff_bytes(d, p, e, %s);\n", indent, target, matlabBool(v.Large))
+		default:
+			fmt.Fprintf(g.buf, "%s[%s, p] = ff_read(d, p, e, '%s', %s, %d);\n", indent, target, matlabClasses[v.Name], count, schema.PrimitiveSize(v.Name))
+		}
+	}
+}
+
+func matlabBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// matlabContents returns the Contents.m of a package, which help
+// {package} shows.
+func matlabContents(s *schema.Schema, pkgName string) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%% %s ffire readers for the %s schema.\n", strings.ToUpper(pkgName), s.Package)
+	buf.WriteString("%\n")
+	buf.WriteString("% Messages\n")
+	for _, msg := range s.Messages {
+		fmt.Fprintf(buf, "%%   decode%s - Decode a %s message.\n", msg.Name, msg.Name)
+	}
+	buf.WriteString("%\n")
+	buf.WriteString("% Code generated by ffire. DO NOT EDIT.\n")
+	return buf.Bytes()
+}
+
+func matlabReadme(config *PackageConfig, pkgName string) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s - ffire MATLAB and Octave readers\n\n", pkgName)
+	buf.WriteString("Functions that decode ffire messages in MATLAB R2016b or later and GNU Octave 7 or later. They are plain `.m` code: no compiler, MEX file or native library.\n\n")
+
+	buf.WriteString("## Installation\n\n")
+	buf.WriteString("Add this directory to the path:\n\n")
+	buf.WriteString("```matlab\n")
+	buf.WriteString("addpath('/path/to/matlab')\n")
+	buf.WriteString("```\n\n")
+	fmt.Fprintf(buf, "`help %s` lists the functions. Each `+%s/decode*.m` file is self-contained and can be copied on its own.\n\n", pkgName, pkgName)
+
+	if len(config.Schema.Messages) > 0 {
+		name := config.Schema.Messages[0].Name
+		buf.WriteString("## Usage\n\n")
+		buf.WriteString("```matlab\n")
+		fmt.Fprintf(buf, "value = %s.decode%s('capture.bin');  %% or a uint8 vector\n", pkgName, name)
+		buf.WriteString("```\n\n")
+	}
+
+	buf.WriteString("## Types\n\n")
+	buf.WriteString("- Structs are structs, and optional values that are absent are `[]`\n")
+	buf.WriteString("- Numbers keep their class (`int32`, `single`, ...), bools are logicals and enums are their integer values\n")
+	buf.WriteString("- Strings are char vectors and bytes are `uint8` column vectors\n")
+	buf.WriteString("- Arrays of numbers and bools are column vectors, decoded with one `typecast`\n")
+	buf.WriteString("- Arrays of structs whose fields are all numbers, bools, enums or fixed-size arrays of them decode to one struct of columns: each field is a column vector, or a matrix with a row per element for fixed-size arrays. `struct2table` turns it into a table\n")
+	buf.WriteString("- Other arrays of structs are struct arrays, and the remaining arrays are cell columns\n")
+	buf.WriteString("- Maps are `containers.Map` objects\n")
+	buf.WriteString("- A union is a struct with the `Type` name and the `Value` of its variant\n\n")
+
+	buf.WriteString("Invalid data raises an error whose identifier is `ffire:` followed by the DecodeErrorCode name: `ffire:Truncated`, `ffire:Overflow`, `ffire:InvalidUtf8`, `ffire:BadPresence`, `ffire:InvalidValue` or `ffire:TrailingData`.\n\n")
+
+	buf.WriteString("## License\n\n")
+	buf.WriteString(readmeLicense(config, "Generated by ffire\n"))
+	return buf.Bytes()
+}
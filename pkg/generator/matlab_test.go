@@ -0,0 +1,227 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const matlabTestSchema = `package tele
+
+type Mode int8
+
+const (
+	Idle Mode = iota
+	Run
+)
+
+type Sample struct {
+	T    float64
+	Acc  [3]float32
+	On   bool
+	Mode Mode
+}
+
+type Point struct {
+	X float32
+	Y float32
+}
+
+type Value interface {
+	Point | string
+}
+
+// @bitmap
+type Flags struct {
+	A *int32
+	B *bool
+}
+
+// @framed
+type Extra struct {
+	Note string
+	Raw  bytes
+}
+
+type Reading struct {
+	Sensor  string
+	Mode    Mode
+	Unit    *string
+	Tags    []string
+	Limits  map[string]int32
+	V       *Value
+	Flags   Flags
+	Extra   Extra
+	Points  []Point
+	Nums    []int16
+	Samples []Sample
+}
+
+type Batch []Reading
+`
+
+const matlabTestFixture = `[
+	{"Sensor": "imu", "Mode": "Run", "Unit": "degC", "Tags": ["a", "ü"], "Limits": {"zz": -1, "aa": 7},
+	 "V": {"Point": {"X": 1.5, "Y": -2}}, "Flags": {"A": 5, "B": null}, "Extra": {"Note": "n", "Raw": "AQID"},
+	 "Points": [], "Nums": [1, -2, 3],
+	 "Samples": [{"T": 0.5, "Acc": [1, 2, 3], "On": true, "Mode": "Run"}, {"T": 1, "Acc": [4, 5, 6], "On": false, "Mode": "Idle"}]},
+	{"Sensor": "", "Mode": "Idle", "Unit": null, "Tags": [], "Limits": {}, "V": {"string": "hi"},
+	 "Flags": {"A": null, "B": false}, "Extra": {"Note": "", "Raw": ""}, "Points": [], "Nums": [], "Samples": []}
+]`
+
+func TestGenerateMatlab(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(matlabTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	files, err := GenerateMatlab(s)
+	if err != nil {
+		t.Fatalf("GenerateMatlab failed: %v", err)
+	}
+	code := string(files["decodeBatch.m"])
+	for _, want := range []string{
+		"function value = decodeBatch(data)\n%DECODEBATCH Decode a Batch message.\n",
+		"value = vertcat(c3{:});\n",
+		"[v.Nums, p] = ff_read(d, p, e, 'int16', n",
+		"[v.Samples, p] = dec_Sample_columns(d, p, e, n",
+		"v.Acc = ff_column(raw, 10, 3, 4, 'single');\n",
+		"v.Mode = chk_Mode(ff_column(raw, 8, 1, 1, 'int8'), p);\n",
+		"[bits, p] = ff_bitmap(d, p, e, 1, 2);\n",
+		"[e, p] = ff_frame(d, p, e);\n",
+		"v.Limits = containers.Map('KeyType', 'char', 'ValueType', 'any');\n",
+		"        v.Type = 'Point';\n",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	// Sample is only decoded as columns, and Point both ways
+	if strings.Contains(code, "function [v, p] = dec_Sample(") {
+		t.Error("Sample has a decoder it does not use")
+	}
+	if !strings.Contains(code, "function [v, p] = dec_Point(") || !strings.Contains(code, "function [v, p] = dec_Point_columns(") {
+		t.Error("Point lacks a decoder")
+	}
+
+	// Every block closes, so the file parses as functions ending in end
+	depth := 0
+	for i, line := range strings.Split(code, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "%") {
+			continue
+		}
+		switch fields[0] {
+		case "function":
+			if depth != 0 {
+				t.Fatalf("line %d: function inside a block", i+1)
+			}
+			depth++
+		case "if", "for", "while", "switch":
+			depth++
+		case "end":
+			depth--
+		}
+		if depth < 0 {
+			t.Fatalf("line %d: end without a block", i+1)
+		}
+	}
+	if depth != 0 {
+		t.Errorf("%d blocks left open", depth)
+	}
+}
+
+func TestMatlabPackage(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(matlabTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "octave", OutputDir: t.TempDir()}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	dir := config.langDir("matlab")
+	for _, name := range []string{"README.md", "+tele/Contents.m", "+tele/decodeBatch.m"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+}
+
+func TestMatlabIdentifier(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"tele", "tele"},
+		{"audio_v2", "audio_v2"},
+		{"2d", "x2d"},
+		{"my-pkg", "my_pkg"},
+	} {
+		if got := matlabIdentifier(tt.in); got != tt.want {
+			t.Errorf("matlabIdentifier(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestMatlabOctaveRoundtrip decodes a message encoded by the reference
+// encoder with the generated functions under GNU Octave, and checks that
+// invalid payloads raise the right error identifiers.
+func TestMatlabOctaveRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: runs generated Octave code")
+	}
+	if _, err := exec.LookPath("octave"); err != nil {
+		t.Skip("octave not installed")
+	}
+	s, err := parser.ParseBytes([]byte(matlabTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "matlab", OutputDir: t.TempDir()}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	data, err := fixture.Convert(s, "Batch", []byte(matlabTestFixture))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	dir := config.langDir("matlab")
+	if err := os.WriteFile(filepath.Join(dir, "data.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	script := `v = tele.decodeBatch('data.bin');
+r = v(1);
+assert(numel(v) == 2 && r.Mode == 1 && strcmp(r.Unit, 'degC') && isempty(v(2).Unit));
+assert(isequal(r.Tags, {'a'; native2unicode(uint8([195 188]), 'UTF-8')}));
+assert(r.Limits('aa') == 7 && r.Limits('zz') == -1 && v(2).Limits.Count == 0);
+assert(strcmp(r.V.Type, 'Point') && r.V.Value.X == 1.5 && strcmp(v(2).V.Value, 'hi'));
+assert(r.Flags.A == 5 && isempty(r.Flags.B) && v(2).Flags.B == false);
+assert(isequal(r.Extra.Raw, uint8([1; 2; 3])) && isequal(r.Nums, int16([1; -2; 3])));
+assert(isequal(r.Samples.T, [0.5; 1]) && isequal(r.Samples.Acc, single([1 2 3; 4 5 6])));
+assert(isequal(r.Samples.On, [true; false]) && isequal(r.Samples.Mode, int8([1; 0])));
+assert(isempty(v(2).Samples.T) && size(v(2).Samples.Acc, 2) == 3);
+d = fread(fopen('data.bin'), Inf, '*uint8');
+for i = 0:numel(d) - 1
+    try
+        tele.decodeBatch(d(1:i));
+        error('decoded truncated data');
+    catch err
+        assert(any(strcmp(err.identifier, {'ffire:Truncated', 'ffire:Overflow'})), err.message);
+    end
+end
+try
+    tele.decodeBatch([d; 0]);
+    error('decoded trailing data');
+catch err
+    assert(strcmp(err.identifier, 'ffire:TrailingData'), err.message);
+end
+disp('ok');
+`
+	cmd := exec.Command("octave", "--no-gui", "--quiet", "--no-init-file", "--eval", script)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
+		t.Errorf("octave: %v\n%s", err, out)
+	}
+}
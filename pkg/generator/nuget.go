@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// csharpProject returns the .csproj of a C# package. It packs with
+// `dotnet pack` into a .nupkg holding the README and license, and a
+// .snupkg of symbols whose SourceLink entries point at the repository the
+// package is built in, so debuggers step into the generated code.
+// SourceLink is part of the .NET 8 SDK and needs no package reference.
+func csharpProject(config *PackageConfig) []byte {
+	pkg := config.Schema.Package
+	ns := (&csharpGenerator{}).toPascalCase(pkg)
+
+	// NuGet takes an SPDX expression, or the license file packed into the
+	// package for a proprietary license.
+	var license, licenseItem string
+	switch config.License {
+	case "":
+	case LicenseProprietary:
+		license = "    <PackageLicenseFile>" + LicenseFile + "</PackageLicenseFile>\n"
+		licenseItem = "    <None Include=\"" + LicenseFile + "\" Pack=\"true\" PackagePath=\"\" />\n"
+	default:
+		license = "    <PackageLicenseExpression>" + config.License + "</PackageLicenseExpression>\n"
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("<Project Sdk=\"Microsoft.NET.Sdk\">\n\n")
+	buf.WriteString("  <PropertyGroup>\n")
+	buf.WriteString("    <TargetFramework>net9.0</TargetFramework>\n")
+	buf.WriteString("    <LangVersion>latest</LangVersion>\n")
+	buf.WriteString("    <Nullable>enable</Nullable>\n")
+	buf.WriteString("    <AllowUnsafeBlocks>true</AllowUnsafeBlocks>\n")
+	fmt.Fprintf(buf, "    <RootNamespace>%s</RootNamespace>\n", pkg)
+	buf.WriteString("  </PropertyGroup>\n\n")
+
+	buf.WriteString("  <PropertyGroup>\n")
+	fmt.Fprintf(buf, "    <PackageId>%s</PackageId>\n", valueOr(config.Coordinates.NuGet, pkg))
+	fmt.Fprintf(buf, "    <Version>%s</Version>\n", config.Version)
+	fmt.Fprintf(buf, "    <Authors>%s</Authors>\n", markupEscaper.Replace(valueOr(config.Copyright, "The "+ns+" Authors")))
+	fmt.Fprintf(buf, "    <Description>ffire binary serialization for the %s schema.</Description>\n", pkg)
+	buf.WriteString("    <PackageTags>ffire;serialization;binary</PackageTags>\n")
+	buf.WriteString("    <PackageReadmeFile>README.md</PackageReadmeFile>\n")
+	buf.WriteString(license)
+	buf.WriteString("    <GenerateDocumentationFile>true</GenerateDocumentationFile>\n")
+	buf.WriteString("    <NoWarn>$(NoWarn);CS1591</NoWarn>\n")
+	buf.WriteString("    <Deterministic>true</Deterministic>\n")
+	buf.WriteString("    <PublishRepositoryUrl>true</PublishRepositoryUrl>\n")
+	buf.WriteString("    <EmbedUntrackedSources>true</EmbedUntrackedSources>\n")
+	buf.WriteString("    <IncludeSymbols>true</IncludeSymbols>\n")
+	buf.WriteString("    <SymbolPackageFormat>snupkg</SymbolPackageFormat>\n")
+	buf.WriteString("  </PropertyGroup>\n\n")
+
+	// Reproducible paths in the PDB, which CI builds set and local builds
+	// leave off so breakpoints still bind to the working copy.
+	buf.WriteString("  <PropertyGroup Condition=\"'$(CI)' == 'true' Or '$(GITHUB_ACTIONS)' == 'true'\">\n")
+	buf.WriteString("    <ContinuousIntegrationBuild>true</ContinuousIntegrationBuild>\n")
+	buf.WriteString("  </PropertyGroup>\n\n")
+
+	buf.WriteString("  <ItemGroup>\n")
+	buf.WriteString("    <None Include=\"README.md\" Pack=\"true\" PackagePath=\"\" />\n")
+	buf.WriteString(licenseItem)
+	buf.WriteString("  </ItemGroup>\n\n")
+	buf.WriteString("</Project>\n")
+	return buf.Bytes()
+}
+
+// csharpReadme returns the README of a C# package, which is also the
+// package's page on NuGet.
+func csharpReadme(config *PackageConfig) []byte {
+	pkg := config.Schema.Package
+	id := valueOr(config.Coordinates.NuGet, pkg)
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s - ffire C# package\n\n", id)
+	buf.WriteString("Binary serialization in the ffire format, in managed C#: the package uses `Span<byte>` and `BinaryPrimitives` and loads no native library.\n\n")
+
+	buf.WriteString("## Installation\n\n")
+	buf.WriteString("```bash\n")
+	fmt.Fprintf(buf, "dotnet add package %s\n", id)
+	buf.WriteString("```\n\n")
+	buf.WriteString("Or build the package from this directory:\n\n")
+	buf.WriteString("```bash\n")
+	buf.WriteString("dotnet pack -c Release\n")
+	buf.WriteString("```\n\n")
+
+	if len(config.Schema.Messages) > 0 {
+		name := config.Schema.Messages[0].Name + "Message"
+		buf.WriteString("## Usage\n\n")
+		buf.WriteString("```csharp\n")
+		fmt.Fprintf(buf, "using %s;\n\n", (&csharpGenerator{}).toPascalCase(pkg))
+		buf.WriteString("byte[] data = File.ReadAllBytes(\"data.bin\");\n")
+		fmt.Fprintf(buf, "var msg = %s.Decode(data);\n", name)
+		buf.WriteString("byte[] encoded = msg.Encode();\n\n")
+		buf.WriteString("// Decode a slice, and encode into a span or an IBufferWriter<byte>, without copying buffers\n")
+		fmt.Fprintf(buf, "if (%s.TryDecode(data.AsSpan(), out var value) && value.TryEncode(destination, out int written))\n", name)
+		buf.WriteString("{\n")
+		buf.WriteString("    // ...\n")
+		buf.WriteString("}\n")
+		buf.WriteString("```\n\n")
+	}
+
+	buf.WriteString("`Decode` takes a `byte[]`, `ReadOnlySpan<byte>` or `ReadOnlyMemory<byte>` and throws `DecodeException`, whose `Code` is a `DecodeErrorCode`; `TryDecode` returns false instead. Messages also have zero-copy views, `ref struct`s that read fields straight from the encoded bytes.\n\n")
+
+	buf.WriteString("## License\n\n")
+	buf.WriteString(readmeLicense(config, "Generated by ffire\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,223 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const nugetTestSchema = `package tele
+
+type Point struct {
+	X int32
+	Y int8
+}
+
+type Reading struct {
+	Sensor string
+	Tags   []string
+	P      *Point
+	Q      Point
+}
+
+type Batch []Reading
+`
+
+const nugetTestFixture = `[
+	{"Sensor": "imu", "Tags": ["a", "b"], "P": {"X": 1, "Y": 2}, "Q": {"X": -1, "Y": 3}},
+	{"Sensor": "", "Tags": [], "P": null, "Q": {"X": 0, "Y": 0}}
+]`
+
+func TestCSharpProject(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(nugetTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{
+		Schema: s, Language: "csharp", OutputDir: t.TempDir(), Version: "1.2.0",
+		License: LicenseProprietary, Copyright: "Acme & Sons",
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	dir := config.langDir("tele")
+	csproj, err := os.ReadFile(filepath.Join(dir, "tele.csproj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<AllowUnsafeBlocks>true</AllowUnsafeBlocks>",
+		"<Version>1.2.0</Version>",
+		"<Authors>Acme &amp; Sons</Authors>",
+		"<PackageReadmeFile>README.md</PackageReadmeFile>",
+		"<PackageLicenseFile>LICENSE</PackageLicenseFile>",
+		"<PublishRepositoryUrl>true</PublishRepositoryUrl>",
+		"<SymbolPackageFormat>snupkg</SymbolPackageFormat>",
+		`<None Include="README.md" Pack="true" PackagePath="" />`,
+		`<None Include="LICENSE" Pack="true" PackagePath="" />`,
+	} {
+		if !strings.Contains(string(csproj), want) {
+			t.Errorf("csproj missing %q", want)
+		}
+	}
+	readme, err := os.ReadFile(filepath.Join(dir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(readme), "using Tele;") || !strings.Contains(string(readme), "BatchMessage.Decode(data)") {
+		t.Errorf("README has no usage example:\n%s", readme)
+	}
+
+	code, err := GenerateCSharp(s)
+	if err != nil {
+		t.Fatalf("GenerateCSharp failed: %v", err)
+	}
+	for _, want := range []string{
+		"[StructLayout(LayoutKind.Sequential, Pack = 1)]\n",
+		"            return 5;\n",
+		"            var result = MemoryMarshal.Read<Point>(buffer.Slice(offset));\n",
+		"        public static BatchMessage Decode(ReadOnlyMemory<byte> data) => Decode(data.Span);\n",
+		"        public bool TryEncode(Span<byte> destination, out int bytesWritten)\n",
+		"        public void Encode(IBufferWriter<byte> writer)\n",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(string(code), "DllImport") || strings.Contains(string(code), "sizeof(") {
+		t.Error("managed C# uses P/Invoke or sizeof")
+	}
+}
+
+// TestCSharpPackRoundtrip packs the generated project into a .nupkg and
+// .snupkg, and checks from a program referencing it that the span,
+// memory and IBufferWriter overloads reproduce reference payloads.
+func TestCSharpPackRoundtrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated C# code")
+	}
+	if _, err := exec.LookPath("dotnet"); err != nil {
+		t.Skip("dotnet not installed")
+	}
+	version, err := exec.Command("dotnet", "--version").Output()
+	if err != nil {
+		t.Skipf("no .NET SDK: %v", err)
+	}
+	major, _, _ := strings.Cut(strings.TrimSpace(string(version)), ".")
+
+	s, err := parser.ParseBytes([]byte(nugetTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	root := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "csharp", OutputDir: root, Version: "1.0.0"}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	s.Canonicalize()
+	data, err := fixture.Convert(s, "Batch", []byte(nugetTestFixture))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	// Build for the installed SDK, which may predate the generated target
+	dir := config.langDir("tele")
+	csprojPath := filepath.Join(dir, "tele.csproj")
+	csproj, err := os.ReadFile(csprojPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csproj = []byte(strings.Replace(string(csproj), "net9.0", "net"+major+".0", 1))
+	if err := os.WriteFile(csprojPath, csproj, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("dotnet", "pack", "-c", "Release", "-o", "nupkg")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("dotnet pack failed: %v\n%s", err, out)
+	}
+	for _, name := range []string{"tele.1.0.0.nupkg", "tele.1.0.0.snupkg"} {
+		if _, err := os.Stat(filepath.Join(dir, "nupkg", name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+
+	app := filepath.Join(root, "app")
+	files := map[string]string{
+		"App.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <OutputType>Exe</OutputType>
+    <TargetFramework>net` + major + `.0</TargetFramework>
+  </PropertyGroup>
+  <ItemGroup>
+    <ProjectReference Include="` + csprojPath + `" />
+  </ItemGroup>
+</Project>
+`,
+		"Main.cs": `using System;
+using System.Buffers;
+using System.IO;
+using Tele;
+
+static class Program
+{
+    static void Check(bool ok, string what)
+    {
+        if (!ok) throw new Exception(what);
+    }
+
+    static void Main()
+    {
+        byte[] data = File.ReadAllBytes("data.bin");
+        var m = BatchMessage.Decode(data);
+        Check(m.Encode().AsSpan().SequenceEqual(data), "byte[] roundtrip");
+
+        var padded = new byte[data.Length + 8];
+        data.CopyTo(padded, 4);
+        m = BatchMessage.Decode(new ReadOnlyMemory<byte>(padded, 4, data.Length));
+        var dst = new byte[data.Length];
+        Check(m.TryEncode(dst, out int n) && n == data.Length && dst.AsSpan().SequenceEqual(data), "span roundtrip");
+        Check(!m.TryEncode(new byte[data.Length - 1], out n) && n == 0, "short destination");
+        var writer = new ArrayBufferWriter<byte>();
+        m.Encode(writer);
+        Check(writer.WrittenSpan.SequenceEqual(data), "IBufferWriter roundtrip");
+
+        for (int end = 0; end < data.Length; end++)
+        {
+            try
+            {
+                BatchMessage.Decode(data.AsSpan(0, end));
+                throw new Exception("decoded truncated data at " + end);
+            }
+            catch (DecodeException e)
+            {
+                Check(e.Code == DecodeErrorCode.Truncated || e.Code == DecodeErrorCode.Overflow, "error code");
+            }
+        }
+        Console.WriteLine("ok");
+    }
+}
+`,
+	}
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(app, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(app, "data.bin"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command("dotnet", "run")
+	cmd.Dir = app
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
+		t.Errorf("dotnet run: %v\n%s", err, out)
+	}
+}
@@ -1,15 +1,18 @@
 package generator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/shaban/ffire/pkg/generator/igniffi"
 	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/semver"
 )
 
 // PackageConfig holds configuration for package generation
@@ -21,15 +24,181 @@ type PackageConfig struct {
 	Platform  string // "darwin", "linux", "windows", "current", "all"
 	Arch      string // "arm64", "x86_64", "current", "all"
 	Namespace string // Optional namespace/package name override
+	Version   string // Package version for manifests (default: schema @version, then 1.0.0)
+	GoModule  string // Module path of a go.mod for Go packages (see gomod.go); empty for none
 	NoCompile bool   // Skip dylib compilation
-	Verbose   bool   // Verbose output
+	NoFormat  bool   // Skip gofmt and native formatters on the output
+	Hooks     Hooks  // Commands to run before and after generation
+	Layout    string // LayoutDefault or LayoutMonorepo (see layout.go)
+
+	// VerifyLint runs go vet, staticcheck and gofumpt over a generated Go
+	// package and fails on findings (see lint.go).
+	VerifyLint bool
+
+	// SharedRuntime is the import path of a shared runtime written by
+	// GenerateSharedRuntime, which the package then imports instead of
+	// defining its own runtime helpers (see sharedruntime.go). Go only.
+	SharedRuntime string
+
+	// BorrowedDecode also generates DecodeBorrowed methods, which alias
+	// the input instead of copying strings and bytes out of it. Go only.
+	BorrowedDecode bool
+
+	// KotlinOkio also generates okio extensions that encode to sinks and
+	// decode from sources and ByteStrings, and adds the okio dependency.
+	// Kotlin only.
+	KotlinOkio bool
+
+	// SwiftCodable also makes the generated Swift types Codable, with the
+	// JSON of ffire fixtures. Swift only.
+	SwiftCodable bool
+
+	// JavaFFM also generates MemorySegment overloads of encode and decode
+	// for the Foreign Function & Memory API, and builds for Java 22. Java
+	// only.
+	JavaFFM bool
+
+	// CSharpUnity generates C# that Unity 2021.3 and later compile, under
+	// Mono and IL2CPP, as a Unity package and a .unitypackage instead of a
+	// .csproj (see unity.go). C# only.
+	CSharpUnity bool
+
+	// KeepAllTypes generates every declared type. By default structs,
+	// enums and unions that no message reaches are left out (see
+	// pruneTypes).
+	KeepAllTypes bool
+
+	// BuildRules also emits Bazel or Buck rules declaring the package as a
+	// library (see buildrules.go). Empty for none.
+	BuildRules string
+
+	// License releases the package under LicenseMIT, LicenseApache or
+	// LicenseProprietary, with Copyright as the holder (see license.go).
+	// Empty for none.
+	License   string
+	Copyright string
+
+	// SBOM writes a CycloneDX bill of materials for the package (see
+	// sbom.go).
+	SBOM bool
+
+	// Provenance writes an SLSA provenance statement for the package, and
+	// Sign signs its native libraries, archives and the statement with
+	// SignCosign or SignMinisign using SignKey (see provenance.go).
+	// SchemaSource and SchemaDigest, the schema's path or URL and its
+	// remote.Checksum, are recorded in the statement.
+	Provenance   bool
+	Sign         string
+	SignKey      string
+	SchemaSource string
+	SchemaDigest string
+
+	// Coordinates override the published package names per language
+	// (see coordinates.go).
+	Coordinates Coordinates
+
+	// Native build overrides (see compiler.go). Empty values fall back to
+	// the CC, CXX, CFLAGS, CXXFLAGS and LDFLAGS environment variables when
+	// building for the host platform.
+	CC       string // C compiler for igniffi libraries
+	CXX      string // C++ compiler for the C++ core library
+	CFlags   string // Extra C compiler flags
+	CXXFlags string // Extra C++ compiler flags
+	LDFlags  string // Extra linker flags
+
+	// CppSIMD generates the C++ core with the --cpp-simd decode checks
+	// (see simd.go and CppOptions).
+	CppSIMD bool
+
+	// CppEmbedded generates the header-only C++ profile for
+	// microcontrollers instead of the C++ core (see embedded.go and
+	// CppOptions).
+	CppEmbedded bool
+
+	// CppArduino packages the embedded profile as an Arduino library,
+	// which PlatformIO also installs (see arduino.go). It implies
+	// CppEmbedded.
+	CppArduino bool
+
+	// PythonPure generates a pure-Python package, which encodes with the
+	// struct module instead of binding the C library (see pythonpure.go).
+	PythonPure bool
+
+	// Sanitize builds the native library with these sanitizers (see
+	// ParseSanitizers) and runs the generated C++ tests under them.
+	Sanitize []string
+
+	// WithTests emits roundtrip unit tests in the language's native test
+	// framework. Fixtures maps message names to JSON test data used as
+	// additional cases.
+	WithTests bool
+	Fixtures  map[string][]byte
+
+	// Handshake emits a handshake message and negotiation helpers, so
+	// hosts and plugins can check they share a wire layout before
+	// exchanging messages (see handshake.go).
+	Handshake bool
+
+	// Descriptor embeds the schema as an ffire descriptor, which peers
+	// decode to check or read the package's messages at runtime (see
+	// descriptor.go).
+	Descriptor bool
+
+	// Plugin is the path of a generator plugin that writes the package
+	// instead of the built-in generator for Language (see plugin.go).
+	Plugin string
+
+	// Logger receives progress and warnings. Nil discards them; the CLI
+	// uses NewLogger(os.Stdout, os.Stderr, LevelInfo), or LevelDebug
+	// with -v.
+	Logger Logger
+
+	// Verbose prints every message, debug included, to os.Stdout and
+	// warnings to os.Stderr when Logger is nil.
+	//
+	// Deprecated: Set Logger to NewLogger(os.Stdout, os.Stderr, LevelDebug).
+	Verbose bool
+
+	ctx        context.Context // Set by GeneratePackageContext (see context.go)
+	packageDir bool            // OutputDir is the package directory, as set by GenerateAll
 }
 
+// pruneTypes points config at a copy of its schema without the types no
+// message reaches, so generators emit no helpers for them. The caller's
+// schema is left unchanged.
+func pruneTypes(config *PackageConfig) {
+	if config.KeepAllTypes {
+		return
+	}
+	kept := config.Schema.ReachableTypes()
+	if len(kept) == len(config.Schema.Types) {
+		return
+	}
+	config.debugf("Omitting %d of %d declared types that no message reaches (use --keep-all to keep them)\n",
+		len(config.Schema.Types)-len(kept), len(config.Schema.Types))
+	pruned := *config.Schema
+	pruned.Types = kept
+	config.Schema = &pruned
+}
+
+// defaultPackageVersion is the manifest version when neither
+// PackageConfig.Version nor the schema's @version is set.
+const defaultPackageVersion = "1.0.0"
+
 // GeneratePackage generates a complete production-ready package
 func GeneratePackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Printf("Generating %s package for schema: %s\n", config.Language, config.Schema.Package)
-	}
+	return GeneratePackageContext(context.Background(), config)
+}
+
+// GeneratePackageContext is GeneratePackage with a context. Canceling ctx
+// kills the compilers, formatters, linters and hooks it runs and stops
+// generation between steps; the error is then ctx.Err(). Files written
+// before that are left in place.
+func GeneratePackageContext(ctx context.Context, config *PackageConfig) error {
+	config.ctx = ctx
+	defer func() { config.ctx = nil }()
+
+	config.debugf("Generating %s package for schema: %s\n", config.Language, config.Schema.Package)
 
 	// Set default namespace if not provided
 	if config.Namespace == "" {
@@ -44,11 +213,176 @@ func GeneratePackage(config *PackageConfig) error {
 		config.Arch = runtime.GOARCH
 	}
 
+	if config.Version == "" {
+		config.Version = config.Schema.Version
+	}
+	if config.Version == "" {
+		config.Version = defaultPackageVersion
+	}
+	if _, err := semver.Parse(config.Version); err != nil {
+		return fmt.Errorf("package version: %w", err)
+	}
+	if err := config.Coordinates.Validate(); err != nil {
+		return err
+	}
+	if err := checkGoModule(config); err != nil {
+		return err
+	}
+	if config.GoModule != "" {
+		config.Coordinates.Go = config.GoModule
+	}
+
+	if config.WithTests && !supportsRoundtripTests(config.Language) {
+		return fmt.Errorf("--with-tests is not supported for %s (supported: go, cpp, swift, java, python)", config.Language)
+	}
+	if config.BorrowedDecode && canonicalLanguage(config.Language) != "go" {
+		return fmt.Errorf("--borrowed-decode is not supported for %s (supported: go)", config.Language)
+	}
+	if config.KotlinOkio && canonicalLanguage(config.Language) != "kotlin" {
+		return fmt.Errorf("--kotlin-okio is not supported for %s (supported: kotlin)", config.Language)
+	}
+	if config.SwiftCodable && canonicalLanguage(config.Language) != "swift" {
+		return fmt.Errorf("--swift-codable is not supported for %s (supported: swift)", config.Language)
+	}
+	if config.JavaFFM && canonicalLanguage(config.Language) != "java" {
+		return fmt.Errorf("--java-ffm is not supported for %s (supported: java)", config.Language)
+	}
+	if config.CSharpUnity && canonicalLanguage(config.Language) != "csharp" {
+		return fmt.Errorf("--csharp-unity is not supported for %s (supported: csharp)", config.Language)
+	}
+	if config.PythonPure && canonicalLanguage(config.Language) != "python" {
+		return fmt.Errorf("--python-pure is not supported for %s (supported: python)", config.Language)
+	}
+	if config.Handshake && !supportsHandshake(config.Language) {
+		return fmt.Errorf("--handshake is not supported for %s (supported: go, cpp, swift)", config.Language)
+	}
+	if config.Descriptor && !supportsDescriptor(config.Language) {
+		return fmt.Errorf("--descriptor is not supported for %s (supported: go, cpp, swift)", config.Language)
+	}
+	if config.Schema.HasFramed() && !supportsFramed(config.Language) && !config.PythonPure && externalGenerator(config) == nil {
+		return fmt.Errorf("@framed is not supported for %s (supported: go, cpp, matlab, python with --python-pure)", config.Language)
+	}
+	if err := checkSanitize(config); err != nil {
+		return err
+	}
+	if err := checkCppSIMD(config); err != nil {
+		return err
+	}
+	if err := checkCppEmbedded(config); err != nil {
+		return err
+	}
+	if err := checkBuildRules(config); err != nil {
+		return err
+	}
+	if err := checkVerifyLint(config); err != nil {
+		return err
+	}
+	if err := checkSigning(config); err != nil {
+		return err
+	}
+	if err := checkLicense(config); err != nil {
+		return err
+	}
+	if err := checkSharedRuntime(config); err != nil {
+		return err
+	}
+	layoutRoot, err := setupLayout(config)
+	if err != nil {
+		return err
+	}
+
 	// Create output directory
 	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	if err := runHooks(config, "pre", config.Hooks.Pre); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	pruneTypes(config)
+	start := time.Now()
+	if err := generatePackage(config); err != nil {
+		return err
+	}
+	if err := writeLicense(config); err != nil {
+		return fmt.Errorf("failed to write license: %w", err)
+	}
+	if config.Handshake {
+		if err := generateHandshake(config); err != nil {
+			return fmt.Errorf("failed to generate handshake: %w", err)
+		}
+	}
+	if config.Descriptor {
+		if err := generateDescriptor(config); err != nil {
+			return fmt.Errorf("failed to generate descriptor: %w", err)
+		}
+	}
+	if config.WithTests {
+		if err := generateRoundtripTests(config); err != nil {
+			return fmt.Errorf("failed to generate roundtrip tests: %w", err)
+		}
+		if len(config.Sanitize) > 0 && roundtripTestLanguages[strings.ToLower(config.Language)] == "GoogleTest" {
+			if err := runSanitizedTests(config); err != nil {
+				return err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !config.NoFormat {
+		if err := formatOutput(config, start); err != nil {
+			return err
+		}
+	}
+	if config.VerifyLint {
+		if err := verifyLint(config); err != nil {
+			return err
+		}
+	}
+	if config.SBOM {
+		if err := writeSBOM(config); err != nil {
+			return fmt.Errorf("failed to write SBOM: %w", err)
+		}
+	}
+	if config.BuildRules != "" {
+		if err := generateBuildRules(config); err != nil {
+			return fmt.Errorf("failed to generate %s rules: %w", config.BuildRules, err)
+		}
+	}
+	if layoutRoot != "" {
+		if err := updateManifest(config, layoutRoot); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := runHooks(config, "post", config.Hooks.Post); err != nil {
+		return err
+	}
+	return attestPackage(config, start)
+}
+
+// supportsFramed reports whether the generator for lang encodes @framed
+// structs.
+func supportsFramed(lang string) bool {
+	switch canonicalLanguage(lang) {
+	case "go", "cpp", "matlab":
+		return true
+	}
+	return false
+}
+
+// generatePackage dispatches to the generator for config.Language.
+func generatePackage(config *PackageConfig) error {
+	if generate := externalGenerator(config); generate != nil {
+		return generate(config)
+	}
+
 	// Normalize language to lowercase for case-insensitive matching
 	lang := strings.ToLower(config.Language)
 
@@ -69,6 +403,9 @@ func GeneratePackage(config *PackageConfig) error {
 
 	// Handle igniffi Python bindings (CFFI API mode)
 	if lang == "igniffi-python" || lang == "python" || lang == "py" {
+		if config.PythonPure {
+			return GeneratePythonPurePackage(config)
+		}
 		return GenerateIgniffiPythonPackage(config)
 	}
 
@@ -79,21 +416,31 @@ func GeneratePackage(config *PackageConfig) error {
 	case "rust":
 		// Rust uses native implementation (like Go)
 		return GenerateRustPackage(config)
+	case "kotlin":
+		return GenerateKotlinPackage(config)
+	case "godot":
+		return GenerateGodotPackage(config)
+	case "matlab", "octave":
+		return GenerateMatlabPackage(config)
 	case "swift", "dart", "java", "csharp", "zig":
 		return generateTierBPackage(config)
 	default:
-		return fmt.Errorf("unsupported language: %s (supported: go, cpp, swift, dart, java, csharp, rust, zig, igniffi, igniffi-js, python)", config.Language)
+		return fmt.Errorf("unsupported language: %s (supported: go, cpp, swift, dart, java, kotlin, csharp, rust, zig, godot, matlab, igniffi, igniffi-js, python, or a plugin named %s%s on the PATH)", config.Language, PluginPrefix, lang)
 	}
 }
 
 // generateTierAPackage generates native code + C ABI (no wrapper layer)
 func generateTierAPackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating Tier A package (native code + C ABI)")
+	if config.CppArduino {
+		return generateArduinoLibrary(config)
 	}
+	if config.CppEmbedded {
+		return generateEmbeddedPackage(config)
+	}
+	config.debugf("Generating Tier A package (native code + C ABI)")
 
 	// Create directory structure
-	langDir := filepath.Join(config.OutputDir, config.Language)
+	langDir := config.langDir(config.Language)
 	includeDir := filepath.Join(langDir, "include")
 	libDir := filepath.Join(langDir, "lib")
 	srcDir := filepath.Join(langDir, "src")
@@ -106,7 +453,7 @@ func generateTierAPackage(config *PackageConfig) error {
 	}
 
 	// Generate C++ header
-	cppCode, err := GenerateCpp(config.Schema)
+	cppCode, err := GenerateCppWithOptions(config.Schema, CppOptions{SIMD: config.CppSIMD})
 	if err != nil {
 		return fmt.Errorf("failed to generate C++ code: %w", err)
 	}
@@ -115,7 +462,7 @@ func generateTierAPackage(config *PackageConfig) error {
 	if err := os.WriteFile(headerPath, cppCode, 0644); err != nil {
 		return fmt.Errorf("failed to write C++ header: %w", err)
 	}
-	fmt.Printf("✓ Generated C++ code: %s\n", headerPath)
+	config.infof("✓ Generated C++ code: %s\n", headerPath)
 
 	// Generate C ABI wrapper
 	if err := generateCABI(config, includeDir, srcDir); err != nil {
@@ -139,15 +486,13 @@ func generateTierAPackage(config *PackageConfig) error {
 		return fmt.Errorf("failed to generate README: %w", err)
 	}
 
-	fmt.Printf("\n✅ Package ready at: %s\n", langDir)
+	config.infof("\n✅ Package ready at: %s\n", langDir)
 	return nil
 }
 
 // generateTierBPackage generates complete package with language-specific wrapper
 func generateTierBPackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating Tier B package (with language wrapper)")
-	}
+	config.debugf("Generating Tier B package (with language wrapper)")
 
 	// Normalize language to lowercase
 	lang := strings.ToLower(config.Language)
@@ -166,18 +511,16 @@ func generateTierBPackage(config *PackageConfig) error {
 	case "zig":
 		return GenerateZigPackage(config)
 	default:
-		return fmt.Errorf("Tier B package generation not yet implemented for %s (TODO: ruby, php, rust)", config.Language)
+		return fmt.Errorf("Tier B package generation not implemented for %s", config.Language)
 	}
 }
 
 // generateIgniffiPackage generates the micro ffire C API (igniffi)
 func generateIgniffiPackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating igniffi package (micro C API)")
-	}
+	config.debugf("Generating igniffi package (micro C API)")
 
 	// Create igniffi directory
-	igniffiDir := filepath.Join(config.OutputDir, "igniffi")
+	igniffiDir := config.langDir("igniffi")
 	if err := os.MkdirAll(igniffiDir, 0755); err != nil {
 		return fmt.Errorf("failed to create igniffi directory: %w", err)
 	}
@@ -187,21 +530,19 @@ func generateIgniffiPackage(config *PackageConfig) error {
 		return fmt.Errorf("failed to generate igniffi code: %w", err)
 	}
 
-	fmt.Printf("✓ Generated igniffi C API: %s\n", igniffiDir)
-	fmt.Printf("\nTo use igniffi:\n")
-	fmt.Printf("  1. Include header: #include \"igniffi.h\"\n")
-	fmt.Printf("  2. Compile: gcc -c src/*.c -Iinclude\n")
-	fmt.Printf("  3. Link: gcc -o myapp myapp.o *.o\n")
-	fmt.Printf("\nFor Python/PHP/JS/Ruby bindings, see documentation.\n")
+	config.infof("✓ Generated igniffi C API: %s\n", igniffiDir)
+	config.infof("\nTo use igniffi:\n")
+	config.infof("  1. Include header: #include \"igniffi.h\"\n")
+	config.infof("  2. Compile: gcc -c src/*.c -Iinclude\n")
+	config.infof("  3. Link: gcc -o myapp myapp.o *.o\n")
+	config.infof("\nFor Python/PHP/JS/Ruby bindings, see documentation.\n")
 
 	return nil
 }
 
 // generateCppWithSwiftPackaging generates native Swift package (no C++ interop)
 func generateCppWithSwiftPackaging(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating native Swift package")
-	}
+	config.debugf("Generating native Swift package")
 
 	// Use the Swift package generator with native unsafe pointer implementation
 	return GenerateSwiftPackage(config)
@@ -221,7 +562,7 @@ func generateCABI(config *PackageConfig, includeDir, srcDir string) error {
 	if err := os.WriteFile(headerPath, headerCode, 0644); err != nil {
 		return fmt.Errorf("failed to write C ABI header: %w", err)
 	}
-	fmt.Printf("✓ Generated C ABI header: %s\n", headerPath)
+	config.infof("✓ Generated C ABI header: %s\n", headerPath)
 
 	// Generate C ABI implementation
 	implCode, err := GenerateCABIImpl(config.Schema)
@@ -232,17 +573,15 @@ func generateCABI(config *PackageConfig, includeDir, srcDir string) error {
 	if err := os.WriteFile(implPath, implCode, 0644); err != nil {
 		return fmt.Errorf("failed to write C ABI implementation: %w", err)
 	}
-	fmt.Printf("✓ Generated C ABI implementation: %s\n", implPath)
+	config.infof("✓ Generated C ABI implementation: %s\n", implPath)
 
 	return nil
 }
 
 // compileDylib compiles the C++ code into a dynamic library
 func compileDylib(config *PackageConfig, srcDir, libDir string) error {
-	if config.Verbose {
-		fmt.Printf("Compiling dylib for platform=%s arch=%s optimize=%d\n",
-			config.Platform, config.Arch, config.Optimize)
-	}
+	config.debugf("Compiling dylib for platform=%s arch=%s optimize=%d\n",
+		config.Platform, config.Arch, config.Optimize)
 
 	// Determine compiler and flags based on platform
 	var compiler string
@@ -313,37 +652,53 @@ func compileDylib(config *PackageConfig, srcDir, libDir string) error {
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for output file: %w", err)
 	}
+	// The language package root, where the compiler runs and the log goes
+	absRoot := filepath.Dir(filepath.Dir(absOutputFile))
 
-	args := compileFlags
+	cxx, err := config.cxxCommand(compiler)
+	if err != nil {
+		return err
+	}
+	cxxFlags, err := config.cxxFlags()
+	if err != nil {
+		return err
+	}
+	ldFlags, err := config.ldFlags()
+	if err != nil {
+		return err
+	}
+
+	// User flags come after ours so they can override them, and linker
+	// flags after the source so libraries resolve its symbols
+	args := append(append([]string{}, cxx[1:]...), compileFlags...)
+	args = append(args, reproducibleFlags(config.Platform, absRoot)...)
+	args = append(args, SanitizerFlags(config.Sanitize)...)
+	args = append(args, cxxFlags...)
 	args = append(args, "-I"+absIncludeDir)
 	args = append(args, "-o", absOutputFile)
 	args = append(args, absSrcFile)
-
-	if config.Verbose {
-		fmt.Printf("Running: %s %s\n", compiler, strings.Join(args, " "))
-	}
+	args = append(args, ldFlags...)
 
 	// Execute compilation
-	cmd := exec.Command(compiler, args...)
-	// Don't set cmd.Dir - we're using absolute paths
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("compilation failed: %w\nOutput: %s", err, string(output))
+	cmd := config.command(cxx[0], args...)
+	if err := reproducibleCommand(cmd, absRoot); err != nil {
+		return err
 	}
 
-	if len(output) > 0 && config.Verbose {
-		fmt.Printf("Compiler output:\n%s\n", string(output))
+	// The log goes next to lib/, at the root of the language package
+	if err := runBuild(config, cmd, filepath.Base(outputFile), filepath.Dir(libDir)); err != nil {
+		return err
 	}
 
-	fmt.Printf("✓ Compiled dylib: %s\n", outputFile)
+	config.infof("✓ Compiled dylib: %s%s\n", outputFile, checksumSuffix(absOutputFile))
+	printSanitizerPreload(config, cxx[0])
 	return nil
 }
 
 // generateExamples generates example code
 func generateExamples(config *PackageConfig, examplesDir string) error {
 	// TODO: Generate language-specific examples
-	fmt.Printf("TODO: Generate examples in %s\n", examplesDir)
+	config.infof("TODO: Generate examples in %s\n", examplesDir)
 
 	return nil
 }
@@ -352,19 +707,21 @@ func generateExamples(config *PackageConfig, examplesDir string) error {
 func generateREADME(config *PackageConfig, langDir string) error {
 	// TODO: Generate comprehensive README
 	readmePath := filepath.Join(langDir, "README.md")
-	fmt.Printf("TODO: Generate README at %s\n", readmePath)
+	config.infof("TODO: Generate README at %s\n", readmePath)
 
 	return nil
 }
 
 // generateGoPackage generates a native Go package (Tier 0 reference implementation)
 func generateGoPackage(config *PackageConfig) error {
-	if config.Verbose {
-		fmt.Println("Generating Go package (native implementation)")
-	}
+	config.debugf("Generating Go package (native implementation)")
 
 	// Generate Go code for all message types
-	code, err := GenerateGo(config.Schema)
+	code, err := GenerateGoWithOptions(config.Schema, GoOptions{
+		NoFormat:      config.NoFormat,
+		SharedRuntime: config.SharedRuntime,
+		Borrowed:      config.BorrowedDecode,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to generate Go code: %w", err)
 	}
@@ -375,7 +732,11 @@ func generateGoPackage(config *PackageConfig) error {
 		return fmt.Errorf("failed to write Go code: %w", err)
 	}
 
-	fmt.Printf("✓ Generated Go package: %s\n", outputPath)
+	config.infof("✓ Generated Go package: %s\n", outputPath)
+
+	if config.GoModule != "" {
+		return generateGoModule(config)
+	}
 	return nil
 }
 
@@ -389,7 +750,7 @@ func generateDartPackage(config *PackageConfig) error {
 
 func generateJavaPackage(config *PackageConfig) error {
 	// Generate Java code
-	javaCode, err := GenerateJava(config.Schema)
+	javaCode, err := GenerateJavaWithOptions(config.Schema, JavaOptions{FFM: config.JavaFFM})
 	if err != nil {
 		return fmt.Errorf("failed to generate Java code: %w", err)
 	}
@@ -409,14 +770,113 @@ func generateJavaPackage(config *PackageConfig) error {
 		return fmt.Errorf("failed to write Java file: %w", err)
 	}
 
-	fmt.Printf("✓ Generated Java code: %s\n", javaPath)
-	fmt.Printf("\n✅ Java package ready at: %s\n", outDir)
-	fmt.Printf("   No native compilation needed - pure Java implementation\n")
+	config.infof("✓ Generated Java code: %s\n", javaPath)
+
+	pomPath := filepath.Join(config.OutputDir, "pom.xml")
+	if err := os.WriteFile(pomPath, generateJavaPom(config), 0644); err != nil {
+		return fmt.Errorf("failed to write pom.xml: %w", err)
+	}
+	config.infof("✓ Generated pom.xml: %s\n", pomPath)
+
+	config.infof("\n✅ Java package ready at: %s\n", outDir)
+	config.infof("   No native compilation needed - pure Java implementation\n")
+	if config.JavaFFM {
+		config.infof("   MemorySegment encode/decode need Java 22 (java.lang.foreign)\n")
+	}
 
 	return nil
 }
 
+// pomLicenses returns the <licenses> element of pom.xml, or "" if the
+// package has no license.
+func pomLicenses(config *PackageConfig) string {
+	var name, url string
+	switch config.License {
+	case LicenseMIT:
+		name, url = "MIT License", "https://opensource.org/licenses/MIT"
+	case LicenseApache:
+		name, url = "Apache License, Version 2.0", "https://www.apache.org/licenses/LICENSE-2.0"
+	case LicenseProprietary:
+		name = "Proprietary"
+	default:
+		return ""
+	}
+	if url != "" {
+		url = "\n            <url>" + url + "</url>"
+	}
+	return fmt.Sprintf(`    <licenses>
+        <license>
+            <name>%s</name>%s
+        </license>
+    </licenses>
+`, name, url)
+}
+
+// javaRelease returns the Java release the package compiles for: 17, or 22
+// with --java-ffm, the first release where java.lang.foreign is not a
+// preview API.
+func javaRelease(config *PackageConfig) int {
+	if config.JavaFFM {
+		return 22
+	}
+	return 17
+}
+
+// generateJavaPom returns a Maven manifest for the sources under src/ and,
+// with --with-tests, the JUnit tests under test/.
+func generateJavaPom(config *PackageConfig) []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0"
+         xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+         xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 http://maven.apache.org/xsd/maven-4.0.0.xsd">
+    <modelVersion>4.0.0</modelVersion>
+
+    <groupId>%s</groupId>
+    <artifactId>%s</artifactId>
+    <version>%s</version>
+    <packaging>jar</packaging>
+    <description>ffire serialization code for the %s schema</description>
+%s
+    <properties>
+        <maven.compiler.release>%d</maven.compiler.release>
+        <project.build.sourceEncoding>UTF-8</project.build.sourceEncoding>
+    </properties>
+`, valueOr(config.Coordinates.Maven.GroupID, defaultMavenGroupID),
+		valueOr(config.Coordinates.Maven.ArtifactID, config.Namespace),
+		config.Version, config.Schema.Package, pomLicenses(config), javaRelease(config))
+
+	if config.WithTests {
+		buf.WriteString(`
+    <dependencies>
+        <dependency>
+            <groupId>org.junit.jupiter</groupId>
+            <artifactId>junit-jupiter</artifactId>
+            <version>5.10.2</version>
+            <scope>test</scope>
+        </dependency>
+    </dependencies>
+`)
+	}
+
+	buf.WriteString(`
+    <build>
+        <sourceDirectory>src</sourceDirectory>
+`)
+	if config.WithTests {
+		buf.WriteString("        <testSourceDirectory>test</testSourceDirectory>\n")
+	}
+	buf.WriteString(`    </build>
+</project>
+`)
+	return buf.Bytes()
+}
+
 func generateCSharpPackage(config *PackageConfig) error {
+	if config.CSharpUnity {
+		return generateUnityPackage(config)
+	}
+
 	// Generate C# code
 	csCode, err := GenerateCSharp(config.Schema)
 	if err != nil {
@@ -424,7 +884,7 @@ func generateCSharpPackage(config *PackageConfig) error {
 	}
 
 	// Create output directory
-	outDir := filepath.Join(config.OutputDir, config.Schema.Package)
+	outDir := config.langDir(config.Schema.Package)
 	if err := os.MkdirAll(outDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
@@ -435,30 +895,23 @@ func generateCSharpPackage(config *PackageConfig) error {
 		return fmt.Errorf("failed to write C# file: %w", err)
 	}
 
-	fmt.Printf("✓ Generated C# code: %s\n", csPath)
-
-	// Generate .csproj file
-	csprojContent := fmt.Sprintf(`<Project Sdk="Microsoft.NET.Sdk">
-
-  <PropertyGroup>
-    <TargetFramework>net9.0</TargetFramework>
-    <LangVersion>latest</LangVersion>
-    <Nullable>enable</Nullable>
-    <RootNamespace>%s</RootNamespace>
-  </PropertyGroup>
-
-</Project>
-`, config.Schema.Package)
+	config.infof("✓ Generated C# code: %s\n", csPath)
 
+	// Generate .csproj file and the README it packs (see nuget.go)
 	csprojPath := filepath.Join(outDir, config.Schema.Package+".csproj")
-	if err := os.WriteFile(csprojPath, []byte(csprojContent), 0644); err != nil {
+	if err := os.WriteFile(csprojPath, csharpProject(config), 0644); err != nil {
 		return fmt.Errorf("failed to write .csproj file: %w", err)
 	}
+	readmePath := filepath.Join(outDir, "README.md")
+	if err := os.WriteFile(readmePath, csharpReadme(config), 0644); err != nil {
+		return fmt.Errorf("failed to write README.md: %w", err)
+	}
 
-	fmt.Printf("✓ Generated .csproj: %s\n", csprojPath)
-	fmt.Printf("\n✅ C# package ready at: %s\n", outDir)
-	fmt.Printf("   No native compilation needed - pure C# implementation with Span<byte>\n")
-	fmt.Printf("   Build with: dotnet build %s\n", csprojPath)
+	config.infof("✓ Generated .csproj: %s\n", csprojPath)
+	config.infof("\n✅ C# package ready at: %s\n", outDir)
+	config.infof("   No native compilation needed - pure C# implementation with Span<byte>\n")
+	config.infof("   Build with: dotnet build %s\n", csprojPath)
+	config.infof("   Pack with:  dotnet pack -c Release %s\n", csprojPath)
 
 	return nil
 }
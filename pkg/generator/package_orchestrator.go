@@ -68,11 +68,12 @@ var (
 
 // setupPackageDirectories creates the standard directory structure
 func setupPackageDirectories(config *PackageConfig, layout DirectoryLayout) (*PackagePaths, error) {
+	root := config.langDir(layout.Name)
 	paths := &PackagePaths{
-		Root:     filepath.Join(config.OutputDir, layout.Name),
-		Include:  filepath.Join(config.OutputDir, layout.Name, "include"),
-		Src:      filepath.Join(config.OutputDir, layout.Name, "src"),
-		Examples: filepath.Join(config.OutputDir, layout.Name, "examples"),
+		Root:     root,
+		Include:  filepath.Join(root, "include"),
+		Src:      filepath.Join(root, "src"),
+		Examples: filepath.Join(root, "examples"),
 	}
 
 	// Determine lib and package paths based on layout
@@ -117,7 +118,7 @@ func setupPackageDirectories(config *PackageConfig, layout DirectoryLayout) (*Pa
 // generateNativeComponents generates C++ header, C ABI, and compiles dylib
 func generateNativeComponents(config *PackageConfig, paths *PackagePaths) error {
 	// Generate C++ header
-	cppCode, err := GenerateCpp(config.Schema)
+	cppCode, err := GenerateCppWithOptions(config.Schema, CppOptions{SIMD: config.CppSIMD})
 	if err != nil {
 		return fmt.Errorf("failed to generate C++ code: %w", err)
 	}
@@ -150,9 +151,7 @@ func orchestrateTierBPackage(
 	generateMetadata func(*PackageConfig, *PackagePaths) error,
 	printInstructions func(*PackageConfig, *PackagePaths),
 ) error {
-	if config.Verbose {
-		fmt.Printf("Generating %s package\n", layout.Name)
-	}
+	config.debugf("Generating %s package\n", layout.Name)
 
 	// Setup directories
 	paths, err := setupPackageDirectories(config, layout)
@@ -0,0 +1,260 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestPackageVersion(t *testing.T) {
+	src := `// @version("1.4.0")
+package audio
+
+type Device struct {
+	ID   int32
+	Name string
+}
+
+type DeviceList []Device
+`
+	tests := []struct {
+		lang     string
+		version  string // PackageConfig.Version
+		manifest string
+		want     string
+	}{
+		{"js", "", "javascript/package.json", `"version": "1.4.0"`},
+		{"python", "", "python/pyproject.toml", `version = "1.4.0"`},
+		{"python", "2.0.0-rc.1", "python/setup.py", `version='2.0.0-rc.1'`},
+		{"dart", "2.0.0", "dart/pubspec.yaml", "version: 2.0.0"},
+		{"csharp", "", "audio/audio.csproj", "<Version>1.4.0</Version>"},
+		{"rust", "", "rust/Cargo.toml", `version = "1.4.0"`},
+		{"kotlin", "", "kotlin/build.gradle.kts", `version = "1.4.0"`},
+		{"swift", "", "swift/Package.swift", "// Version 1.4.0."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.version, func(t *testing.T) {
+			s, err := parser.ParseBytes([]byte(src))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			out := t.TempDir()
+			config := &PackageConfig{
+				Schema:    s,
+				Language:  tt.lang,
+				OutputDir: out,
+				Version:   tt.version,
+				NoCompile: true,
+				NoFormat:  true,
+			}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(out, tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("%s lacks %s:\n%s", tt.manifest, tt.want, data)
+			}
+		})
+	}
+}
+
+func TestPackageVersionDefaultAndInvalid(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "js", OutputDir: t.TempDir(), NoCompile: true, NoFormat: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	if config.Version != defaultPackageVersion {
+		t.Errorf("Version = %q, want %s", config.Version, defaultPackageVersion)
+	}
+
+	config = &PackageConfig{Schema: s, Language: "js", OutputDir: t.TempDir(), Version: "2.0", NoCompile: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "package version") {
+		t.Errorf("invalid version: err = %v", err)
+	}
+}
+
+func TestPackageCoordinates(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	coords := Coordinates{
+		Maven:   MavenCoordinates{GroupID: "com.acme.audio", ArtifactID: "audio-codec"},
+		NPM:     NPMCoordinates{Scope: "@acme", Name: "audio"},
+		PyPI:    "acme-audio",
+		NuGet:   "Acme.Audio",
+		SwiftPM: "AcmeAudio",
+		Pub:     "acme_audio",
+		Crate:   "acme-audio",
+	}
+	pkg := s.Package
+	tests := []struct {
+		lang     string
+		manifest string
+		want     []string
+	}{
+		{"java", "pom.xml", []string{"<groupId>com.acme.audio</groupId>", "<artifactId>audio-codec</artifactId>"}},
+		{"js", "javascript/package.json", []string{`"name": "@acme/audio"`}},
+		{"python", "python/pyproject.toml", []string{`name = "acme-audio"`}},
+		{"python", "python/setup.py", []string{"name='acme-audio'", "'" + pkg + "/_ffi_build.py:ffibuilder'"}},
+		{"csharp", pkg + "/" + pkg + ".csproj", []string{"<PackageId>Acme.Audio</PackageId>", "<RootNamespace>" + pkg + "</RootNamespace>"}},
+		{"swift", "swift/Package.swift", []string{`name: "AcmeAudio"`, `targets: ["` + pkg + `"]`}},
+		{"dart", "dart/pubspec.yaml", []string{"name: acme_audio"}},
+		{"rust", "rust/Cargo.toml", []string{`name = "acme-audio"`, `name = "` + pkg + `"`}},
+		{"kotlin", "kotlin/build.gradle.kts", []string{`group = "com.acme.audio"`}},
+		{"kotlin", "kotlin/settings.gradle.kts", []string{`rootProject.name = "audio-codec"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+filepath.Base(tt.manifest), func(t *testing.T) {
+			out := t.TempDir()
+			config := &PackageConfig{
+				Schema:      s,
+				Language:    tt.lang,
+				OutputDir:   out,
+				Coordinates: coords,
+				NoCompile:   true,
+				NoFormat:    true,
+			}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(out, tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("%s lacks %s:\n%s", tt.manifest, want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestPackageCoordinatesDefaultsAndInvalid(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	out := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "java", OutputDir: out, NoCompile: true, NoFormat: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	pom, err := os.ReadFile(filepath.Join(out, "pom.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<groupId>com.ffire</groupId>", "<artifactId>" + s.Package + "</artifactId>", "<version>1.0.0</version>"} {
+		if !strings.Contains(string(pom), want) {
+			t.Errorf("pom.xml lacks %s:\n%s", want, pom)
+		}
+	}
+
+	config = &PackageConfig{Schema: s, Language: "js", OutputDir: t.TempDir(), NoCompile: true,
+		Coordinates: Coordinates{NPM: NPMCoordinates{Name: "Audio"}}}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "packages.npm.name") {
+		t.Errorf("invalid npm name: err = %v", err)
+	}
+}
+
+func TestPackageOutput(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	config := &PackageConfig{
+		Schema:    s,
+		Language:  "go",
+		OutputDir: t.TempDir(),
+		Logger:    NewLogger(&stdout, &stderr, LevelInfo),
+		Hooks: Hooks{Post: []Hook{
+			{Name: "warn", Run: "echo hook output; exit 1", AllowFailure: true},
+		}},
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "✓ Generated Go package") {
+		t.Errorf("stdout:\n%s", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "⚠") || !strings.Contains(stderr.String(), "warn") {
+		t.Errorf("stderr:\n%s", stderr.String())
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want, err := GenerateGo(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Generate(&buf, s, "Go"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("Generate output differs from GenerateGo")
+	}
+	if err := Generate(&buf, s, "python"); err == nil || !strings.Contains(err.Error(), "use GeneratePackage") {
+		t.Errorf("err = %v, want a pointer to GeneratePackage", err)
+	}
+}
+
+func TestPackagePrunesUnreachableTypes(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(`package shared
+
+type Device struct {
+	ID int32
+}
+
+type Plugin struct {
+	Name string
+	Tags map[string]int32
+}
+
+type PluginList = []Plugin
+`))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	if err := s.SelectMessages([]string{"Device"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keepAll := range []bool{false, true} {
+		out := t.TempDir()
+		config := &PackageConfig{Schema: s, Language: "go", OutputDir: out, KeepAllTypes: keepAll}
+		if err := GeneratePackage(config); err != nil {
+			t.Fatalf("GeneratePackage(KeepAllTypes=%v) failed: %v", keepAll, err)
+		}
+		code, err := os.ReadFile(filepath.Join(out, "shared.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Plugin's map is what pulls in the sort import
+		for _, want := range []string{"type Plugin struct", `"sort"`} {
+			if strings.Contains(string(code), want) != keepAll {
+				t.Errorf("KeepAllTypes=%v: output contains %s = %v", keepAll, want, !keepAll)
+			}
+		}
+	}
+	if len(s.Types) != 3 {
+		t.Errorf("schema types = %d after generation, want 3 (the caller's schema is not pruned)", len(s.Types))
+	}
+}
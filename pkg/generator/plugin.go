@@ -0,0 +1,216 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shaban/ffire/pkg/descriptor"
+	"github.com/shaban/ffire/pkg/fixture"
+)
+
+// Target languages can be added without changing this package, in two
+// ways. Programs that import it call Register with a GenerateFunc. Any
+// other program can be a generator plugin, in the style of protoc
+// plugins: it reads a PluginRequest as JSON from stdin, writes a
+// PluginResponse as JSON to stdout, and is named by PackageConfig.Plugin
+// or found on the PATH as ffire-gen-<lang>.
+
+// GenerateFunc writes the package for config.Language under
+// config.OutputDir. The schema is validated, in canonical order and
+// without the types no message reaches, unless config.KeepAllTypes.
+type GenerateFunc func(config *PackageConfig) error
+
+// PluginPrefix starts the name of a generator plugin found on the PATH:
+// ffire-gen-lua generates packages for -lang lua.
+const PluginPrefix = "ffire-gen-"
+
+// builtinLanguages lists the language names generatePackage handles.
+var builtinLanguages = map[string]bool{
+	"go": true, "igniffi": true, "igniffi-js": true, "javascript": true, "js": true,
+	"igniffi-python": true, "python": true, "py": true, "c": true, "cpp": true, "c++": true,
+	"rust": true, "kotlin": true, "swift": true, "dart": true, "java": true, "csharp": true, "zig": true,
+	"godot": true, "matlab": true, "octave": true,
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]GenerateFunc)
+)
+
+// Register makes fn the generator for lang, so GeneratePackage and ffire
+// generate -lang accept it. It is meant to be called from init functions
+// and panics if fn is nil, lang is built in or lang is already registered.
+func Register(lang string, fn GenerateFunc) {
+	lang = strings.ToLower(lang)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	switch {
+	case fn == nil:
+		panic("generator: Register of a nil GenerateFunc for " + lang)
+	case builtinLanguages[lang]:
+		panic("generator: Register of built-in language " + lang)
+	case registry[lang] != nil:
+		panic("generator: Register called twice for " + lang)
+	}
+	registry[lang] = fn
+}
+
+// Registered returns the languages added with Register, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	langs := make([]string, 0, len(registry))
+	for lang := range registry {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// externalGenerator returns the generator that writes the package instead
+// of a built-in one: config.Plugin, a registered generator, or for other
+// languages ffire-gen-<lang> on the PATH. It returns nil if a built-in
+// generator handles config.Language.
+func externalGenerator(config *PackageConfig) GenerateFunc {
+	if config.Plugin != "" {
+		return Plugin(config.Plugin)
+	}
+	lang := strings.ToLower(config.Language)
+	registryMu.RLock()
+	fn := registry[lang]
+	registryMu.RUnlock()
+	if fn != nil || builtinLanguages[lang] {
+		return fn
+	}
+	if path, err := exec.LookPath(PluginPrefix + lang); err == nil {
+		return Plugin(path)
+	}
+	return nil
+}
+
+// PluginRequest is the JSON a generator plugin reads from stdin.
+type PluginRequest struct {
+	Language  string `json:"language"`
+	Namespace string `json:"namespace"`
+	Version   string `json:"version"` // Package version for manifests
+
+	// Schema is the schema descriptor (see pkg/descriptor) as the JSON
+	// value tree of a Descriptor message, and Descriptor the same
+	// descriptor encoded, for plugins that decode it with generated code.
+	// encoding/json writes Descriptor as base64.
+	Schema     json.RawMessage `json:"schema"`
+	Descriptor []byte          `json:"descriptor"`
+}
+
+// PluginResponse is the JSON a generator plugin writes to stdout: the
+// files of the package, or why it could not generate one.
+type PluginResponse struct {
+	Files []PluginFile `json:"files"`
+	Error string       `json:"error,omitempty"`
+}
+
+// PluginFile is a file of a plugin's package. Name is slash-separated and
+// relative to the package directory.
+type PluginFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Plugin returns a GenerateFunc that runs the generator plugin at path
+// and writes the files it returns to the package directory.
+func Plugin(path string) GenerateFunc {
+	return func(config *PackageConfig) error {
+		return runPlugin(config, path)
+	}
+}
+
+// newPluginRequest describes the package config asks for.
+func newPluginRequest(config *PackageConfig) (*PluginRequest, error) {
+	data, err := descriptor.Encode(config.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema descriptor: %w", err)
+	}
+	value, err := fixture.Decode(descriptor.Schema(), descriptor.MessageName, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode schema descriptor: %w", err)
+	}
+	tree, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &PluginRequest{
+		Language:   config.Language,
+		Namespace:  config.Namespace,
+		Version:    config.Version,
+		Schema:     tree,
+		Descriptor: data,
+	}, nil
+}
+
+func runPlugin(config *PackageConfig, path string) error {
+	req, err := newPluginRequest(config)
+	if err != nil {
+		return err
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	config.debugf("Running generator plugin: %s\n", path)
+	cmd := config.command(path)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctxErr := config.context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		msg := fmt.Sprintf("plugin %s failed: %v", path, err)
+		if out := strings.TrimSpace(stderr.String()); out != "" {
+			msg += "\n" + out
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	if out := strings.TrimSpace(stderr.String()); out != "" {
+		config.warnf("%s: %s\n", filepath.Base(path), out)
+	}
+
+	var resp PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return fmt.Errorf("plugin %s: invalid response: %w", path, err)
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("plugin %s: %s", path, resp.Error)
+	}
+	if len(resp.Files) == 0 {
+		return fmt.Errorf("plugin %s generated no files", path)
+	}
+
+	langDir := config.langDir(canonicalLanguage(config.Language))
+	for _, f := range resp.Files {
+		name := filepath.FromSlash(f.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("plugin %s: file %q is outside the package directory", path, f.Name)
+		}
+		out := filepath.Join(langDir, name)
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(out, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", out, err)
+		}
+		config.debugf("✓ Generated %s\n", out)
+	}
+
+	config.infof("\n✅ Package ready at: %s\n", langDir)
+	return nil
+}
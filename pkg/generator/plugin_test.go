@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/descriptor"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const pluginTestSchema = `package audio
+
+type Device struct {
+	Name string
+	Gain *float32
+}
+`
+
+func parsePluginSchema(t *testing.T) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(pluginTestSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// fakePlugin puts a generator plugin for lang on PATH that saves its
+// request to request.json next to it and prints response.
+func fakePlugin(t *testing.T, lang, response string) (requestPath string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake plugin is a shell script")
+	}
+	bin := t.TempDir()
+	requestPath = filepath.Join(bin, "request.json")
+	script := "#!/bin/sh\ncat > " + requestPath + "\ncat <<'EOF'\n" + response + "\nEOF\n"
+	if err := os.WriteFile(filepath.Join(bin, PluginPrefix+lang), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return requestPath
+}
+
+func TestPluginOnPath(t *testing.T) {
+	requestPath := fakePlugin(t, "lua", `{"files": [{"name": "audio.lua", "content": "-- audio\n"}, {"name": "spec/audio_spec.lua", "content": "-- spec\n"}]}`)
+	out := t.TempDir()
+	config := &PackageConfig{Schema: parsePluginSchema(t), Language: "lua", OutputDir: out, NoFormat: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage: %v", err)
+	}
+
+	for name, want := range map[string]string{"audio.lua": "-- audio\n", "spec/audio_spec.lua": "-- spec\n"} {
+		got, err := os.ReadFile(filepath.Join(out, "lua", name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	data, err := os.ReadFile(requestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req PluginRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("request is not JSON: %v\n%s", err, data)
+	}
+	if req.Language != "lua" || req.Namespace != "audio" || req.Version != defaultPackageVersion {
+		t.Errorf("request = %+v", req)
+	}
+	var tree struct {
+		Package string
+		Structs []struct{ Name string }
+	}
+	if err := json.Unmarshal(req.Schema, &tree); err != nil || tree.Package != "audio" || len(tree.Structs) != 1 || tree.Structs[0].Name != "Device" {
+		t.Errorf("schema = %s (%v)", req.Schema, err)
+	}
+	s, err := descriptor.Decode(req.Descriptor)
+	if err != nil {
+		t.Fatalf("descriptor: %v", err)
+	}
+	if s.FindType("Device") == nil {
+		t.Errorf("descriptor lacks Device")
+	}
+}
+
+func TestPluginErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"error", `{"error": "no maps in Lua 4"}`, "no maps in Lua 4"},
+		{"outside", `{"files": [{"name": "../evil.lua", "content": ""}]}`, "outside the package directory"},
+		{"empty", `{"files": []}`, "generated no files"},
+		{"garbage", `not json`, "invalid response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakePlugin(t, "lua", tt.response)
+			config := &PackageConfig{Schema: parsePluginSchema(t), Language: "lua", OutputDir: t.TempDir(), NoFormat: true}
+			if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("GeneratePackage = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var got *PackageConfig
+	Register("testlang", func(config *PackageConfig) error {
+		got = config
+		return os.WriteFile(filepath.Join(config.OutputDir, "out.txt"), []byte(config.Schema.Package), 0644)
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "testlang")
+		registryMu.Unlock()
+	}()
+
+	if langs := Registered(); len(langs) != 1 || langs[0] != "testlang" {
+		t.Errorf("Registered() = %v", langs)
+	}
+	config := &PackageConfig{Schema: parsePluginSchema(t), Language: "TestLang", OutputDir: t.TempDir(), NoFormat: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage: %v", err)
+	}
+	if got != config {
+		t.Errorf("registered generator not called")
+	}
+
+	for _, lang := range []string{"go", "testlang"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", lang)
+				}
+			}()
+			Register(lang, func(*PackageConfig) error { return nil })
+		}()
+	}
+}
@@ -0,0 +1,326 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// Generated packages can be attested for supply-chain-sensitive consumers.
+// With PackageConfig.Provenance, ffire writes an SLSA provenance statement
+// (an in-toto v1 Statement) naming every file of the package with its
+// SHA-256 digest, and how it was generated: the schema and its digest, the
+// language, version and build settings. With PackageConfig.Sign, the
+// native libraries and archives in the package, and the statement, are
+// signed with cosign or minisign. Both run last, after the post hooks, so
+// archives a hook packs (npm pack, cargo package, ...) are covered.
+
+// Signing tools for PackageConfig.Sign.
+const (
+	SignCosign   = "cosign"   // Sigstore bundle <file>.sigstore.json, keyless unless SignKey is set
+	SignMinisign = "minisign" // <file>.minisig
+)
+
+// ProvenanceFile is the name of the provenance statement in a package
+// directory.
+const ProvenanceFile = "provenance.intoto.json"
+
+const (
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	ffireBuildType      = "https://github.com/shaban/ffire/generate/v1"
+	ffireBuilderID      = "https://github.com/shaban/ffire"
+)
+
+// archiveSuffixes are the file names of package archives, signed along
+// with native libraries (see nativeLibrarySuffixes).
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".zip", ".jar", ".whl", ".nupkg", ".gem", ".crate"}
+
+// signatureSuffixes are the files signing writes, left out of the
+// statement as they are made after it.
+var signatureSuffixes = []string{".sigstore.json", ".minisig"}
+
+// checkSigning rejects unknown signing tools, and tools that are not
+// installed, before anything is generated.
+func checkSigning(config *PackageConfig) error {
+	switch config.Sign {
+	case "":
+		if config.SignKey != "" {
+			return fmt.Errorf("--sign-key needs --sign")
+		}
+		return nil
+	case SignCosign, SignMinisign:
+	default:
+		return fmt.Errorf("unknown signing tool %q (supported: %s, %s)", config.Sign, SignCosign, SignMinisign)
+	}
+	if _, err := exec.LookPath(config.Sign); err != nil {
+		return fmt.Errorf("--sign %s needs %s on PATH", config.Sign, config.Sign)
+	}
+	return nil
+}
+
+// packageDir returns the directory generatePackage writes the package for
+// config.Language to.
+func packageDir(config *PackageConfig) string {
+	switch lang := canonicalLanguage(config.Language); lang {
+	case "go", "java":
+		return config.OutputDir
+	case "csharp":
+		return config.langDir(config.Schema.Package)
+	case "cpp":
+		return config.langDir(config.Language)
+	case "js":
+		return config.langDir(JavaScriptLayout.Name)
+	default:
+		return config.langDir(lang)
+	}
+}
+
+// attestPackage writes the provenance statement and signs the package,
+// as configured.
+func attestPackage(config *PackageConfig, started time.Time) error {
+	if !config.Provenance && config.Sign == "" {
+		return nil
+	}
+	dir := packageDir(config)
+	files, err := packageFiles(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list package files: %w", err)
+	}
+	files = attestedFiles(files)
+
+	var toSign []string
+	for _, f := range files {
+		if hasAnySuffix(f, nativeLibrarySuffixes) || hasAnySuffix(f, archiveSuffixes) {
+			toSign = append(toSign, f)
+		}
+	}
+	if config.Provenance {
+		st, err := newProvenance(config, dir, files, started)
+		if err != nil {
+			return fmt.Errorf("failed to create provenance: %w", err)
+		}
+		data, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, ProvenanceFile)
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("failed to write provenance: %w", err)
+		}
+		config.infof("✓ Wrote provenance for %d files: %s\n", len(files), path)
+		toSign = append(toSign, ProvenanceFile)
+	}
+
+	if config.Sign == "" {
+		return nil
+	}
+	if len(toSign) == 0 {
+		config.warnf("⚠ --sign: the package has no native libraries or archives to sign (add --provenance to sign a digest of every file)\n")
+		return nil
+	}
+	for _, f := range toSign {
+		sig, err := signFile(config, filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return err
+		}
+		config.infof("✓ Signed %s: %s\n", f, sig)
+	}
+	return nil
+}
+
+// attestedFiles drops the statement and signatures of an earlier run from
+// the package files.
+func attestedFiles(files []string) []string {
+	var kept []string
+	for _, f := range files {
+		if f == ProvenanceFile || hasAnySuffix(f, signatureSuffixes) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// signFile signs path with config.Sign and returns the signature path.
+func signFile(config *PackageConfig, path string) (string, error) {
+	var args []string
+	var sig string
+	switch config.Sign {
+	case SignCosign:
+		sig = path + ".sigstore.json"
+		args = []string{"sign-blob", "--yes", "--bundle", sig}
+		if config.SignKey != "" {
+			args = append(args, "--key", config.SignKey)
+		}
+		args = append(args, path)
+	case SignMinisign:
+		sig = path + ".minisig"
+		args = []string{"-S", "-m", path, "-x", sig}
+		if config.SignKey != "" {
+			args = append(args, "-s", config.SignKey)
+		}
+	}
+
+	cmd := config.command(config.Sign, args...)
+	cmd.Stdin = os.Stdin // Key passwords and keyless sign-in prompts
+	config.debugf("Running: %s %s\n", config.Sign, strings.Join(args, " "))
+	output, err := cmd.CombinedOutput()
+	if ctxErr := config.context().Err(); ctxErr != nil {
+		return "", ctxErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s failed to sign %s: %w\n%s", config.Sign, path, err, strings.TrimSpace(string(output)))
+	}
+	return sig, nil
+}
+
+// provenanceStatement is an in-toto v1 Statement with an SLSA v1
+// provenance predicate.
+type provenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []provenanceResource `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     provenancePredicate  `json:"predicate"`
+}
+
+// provenanceResource is an in-toto ResourceDescriptor.
+type provenanceResource struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenancePredicate struct {
+	BuildDefinition struct {
+		BuildType            string               `json:"buildType"`
+		ExternalParameters   map[string]any       `json:"externalParameters"`
+		InternalParameters   map[string]any       `json:"internalParameters,omitempty"`
+		ResolvedDependencies []provenanceResource `json:"resolvedDependencies,omitempty"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version,omitempty"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn  time.Time `json:"startedOn"`
+			FinishedOn time.Time `json:"finishedOn"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// newProvenance describes the generation of the package in dir, whose
+// files are the subjects.
+func newProvenance(config *PackageConfig, dir string, files []string, started time.Time) (*provenanceStatement, error) {
+	st := &provenanceStatement{
+		Type:          inTotoStatementType,
+		Subject:       []provenanceResource{},
+		PredicateType: slsaProvenanceType,
+	}
+	for _, f := range files {
+		sum, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		st.Subject = append(st.Subject, provenanceResource{Name: f, Digest: map[string]string{"sha256": sum}})
+	}
+
+	def := &st.Predicate.BuildDefinition
+	def.BuildType = ffireBuildType
+	def.ExternalParameters = map[string]any{
+		"schema":   config.SchemaSource,
+		"language": canonicalLanguage(config.Language),
+		"version":  config.Version,
+		"platform": config.Platform,
+		"arch":     config.Arch,
+		"optimize": config.Optimize,
+	}
+	if config.Namespace != config.Schema.Package {
+		def.ExternalParameters["namespace"] = config.Namespace
+	}
+	if config.NoCompile {
+		def.ExternalParameters["noCompile"] = true
+	}
+	if config.CppSIMD {
+		def.ExternalParameters["cppSIMD"] = true
+	}
+	if config.CppEmbedded {
+		def.ExternalParameters["cppEmbedded"] = true
+	}
+	if config.CppArduino {
+		def.ExternalParameters["cppArduino"] = true
+	}
+	if config.PythonPure {
+		def.ExternalParameters["pythonPure"] = true
+	}
+	if config.KotlinOkio {
+		def.ExternalParameters["kotlinOkio"] = true
+	}
+	if config.SwiftCodable {
+		def.ExternalParameters["swiftCodable"] = true
+	}
+	if config.JavaFFM {
+		def.ExternalParameters["javaFFM"] = true
+	}
+	if config.CSharpUnity {
+		def.ExternalParameters["csharpUnity"] = true
+	}
+	if len(config.Sanitize) > 0 {
+		def.ExternalParameters["sanitize"] = config.Sanitize
+	}
+	internal := map[string]any{}
+	for name, value := range map[string]string{
+		"cc": config.CC, "cxx": config.CXX, "cflags": config.CFlags, "cxxflags": config.CXXFlags, "ldflags": config.LDFlags,
+	} {
+		if value != "" {
+			internal[name] = value
+		}
+	}
+	if len(internal) > 0 {
+		def.InternalParameters = internal
+	}
+	if algo, sum, ok := strings.Cut(config.SchemaDigest, ":"); ok {
+		def.ResolvedDependencies = []provenanceResource{{URI: config.SchemaSource, Digest: map[string]string{algo: sum}}}
+	}
+
+	run := &st.Predicate.RunDetails
+	run.Builder.ID = ffireBuilderID
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		run.Builder.Version = map[string]string{"ffire": info.Main.Version}
+	}
+	run.Metadata.StartedOn = started.UTC().Truncate(time.Second)
+	run.Metadata.FinishedOn = time.Now().UTC().Truncate(time.Second)
+	return st, nil
+}
+
+// fileSHA256 returns the hex SHA-256 digest of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// fakeSigner puts an executable named tool on PATH that logs its arguments
+// and writes the signature file named by -x (minisign) or --bundle (cosign).
+func fakeSigner(t *testing.T, tool string) (logPath string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake signer is a shell script")
+	}
+	bin := t.TempDir()
+	logPath = filepath.Join(bin, "calls.log")
+	script := `#!/bin/sh
+echo "$@" >> ` + logPath + `
+prev=
+for a in "$@"; do
+	case "$prev" in -x|--bundle) echo signature > "$a" ;; esac
+	prev=$a
+done
+`
+	if err := os.WriteFile(filepath.Join(bin, tool), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return logPath
+}
+
+func TestAttestPackage(t *testing.T) {
+	logPath := fakeSigner(t, SignMinisign)
+	out := t.TempDir()
+	for rel, data := range map[string]string{
+		"audio.go":                      "package audio\n",
+		"lib/libaudio.so":               "ELF",
+		ProvenanceFile:                  "{}", // From an earlier run
+		"lib/libaudio.so.minisig":       "old",
+		"lib/libaudio.so.sigstore.json": "old",
+	} {
+		path := filepath.Join(out, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &PackageConfig{
+		Schema:       &schema.Schema{Package: "audio"},
+		Language:     "go",
+		OutputDir:    out,
+		Namespace:    "audio",
+		Version:      "1.2.0",
+		Platform:     "linux",
+		Arch:         "amd64",
+		CXX:          "clang++",
+		Provenance:   true,
+		Sign:         SignMinisign,
+		SignKey:      "ci.key",
+		SchemaSource: "audio.ffi",
+		SchemaDigest: "sha256:abc",
+	}
+	if err := checkSigning(config); err != nil {
+		t.Fatalf("checkSigning failed: %v", err)
+	}
+	if err := attestPackage(config, time.Now()); err != nil {
+		t.Fatalf("attestPackage failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, ProvenanceFile))
+	if err != nil {
+		t.Fatalf("provenance not written: %v", err)
+	}
+	var st provenanceStatement
+	if err := json.Unmarshal(data, &st); err != nil {
+		t.Fatalf("provenance is not valid JSON: %v", err)
+	}
+	if st.Type != inTotoStatementType || st.PredicateType != slsaProvenanceType {
+		t.Errorf("statement types = %q, %q", st.Type, st.PredicateType)
+	}
+	var names []string
+	for _, s := range st.Subject {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, " "); got != "audio.go lib/libaudio.so" {
+		t.Errorf("subjects = %s, want the package files without old attestations", got)
+	}
+	if got := st.Subject[1].Digest["sha256"]; got != "706abe3c90152075e656b661079730facf323f3ebccda7547ee1935c90845a09" {
+		t.Errorf("libaudio.so digest = %s, want sha256 of its contents", got)
+	}
+	def := st.Predicate.BuildDefinition
+	if def.ExternalParameters["language"] != "go" || def.ExternalParameters["version"] != "1.2.0" {
+		t.Errorf("external parameters = %v", def.ExternalParameters)
+	}
+	if def.InternalParameters["cxx"] != "clang++" {
+		t.Errorf("internal parameters = %v", def.InternalParameters)
+	}
+	if len(def.ResolvedDependencies) != 1 || def.ResolvedDependencies[0].URI != "audio.ffi" || def.ResolvedDependencies[0].Digest["sha256"] != "abc" {
+		t.Errorf("resolved dependencies = %+v", def.ResolvedDependencies)
+	}
+
+	logged, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("signer was not run: %v", err)
+	}
+	lib := filepath.Join(out, "lib", "libaudio.so")
+	prov := filepath.Join(out, ProvenanceFile)
+	want := "-S -m " + lib + " -x " + lib + ".minisig -s ci.key\n" +
+		"-S -m " + prov + " -x " + prov + ".minisig -s ci.key\n"
+	if string(logged) != want {
+		t.Errorf("signer calls:\n%s\nwant:\n%s", logged, want)
+	}
+	if _, err := os.Stat(prov + ".minisig"); err != nil {
+		t.Errorf("provenance signature missing: %v", err)
+	}
+}
+
+func TestCheckSigning(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	tests := []struct {
+		sign, key string
+		want      string
+	}{
+		{"", "", ""},
+		{"", "ci.key", "--sign-key needs --sign"},
+		{"gpg", "", `unknown signing tool "gpg"`},
+		{SignCosign, "", "needs cosign on PATH"},
+	}
+	for _, tt := range tests {
+		err := checkSigning(&PackageConfig{Sign: tt.sign, SignKey: tt.key})
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("checkSigning(%q, %q) = %v, want nil", tt.sign, tt.key, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("checkSigning(%q, %q) = %v, want %q", tt.sign, tt.key, err, tt.want)
+		}
+	}
+}
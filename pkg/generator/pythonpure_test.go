@@ -0,0 +1,181 @@
+package generator
+
+import (
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const pythonPureTestSchema = `package tele
+
+type Mode int8
+
+const (
+	Idle Mode = iota
+	Run
+)
+
+type Point struct {
+	X float32
+	Y float32
+}
+
+type Value interface {
+	Point | string
+}
+
+// @bitmap
+type Flags struct {
+	A *int32
+	B *bool
+}
+
+// @framed
+type Extra struct {
+	Note string
+	Raw  bytes
+}
+
+type Reading struct {
+	Sensor string
+	Mode   Mode
+	Unit   *string
+	Tags   []string
+	Limits map[string]int32
+	V      *Value
+	Flags  Flags
+	Extra  Extra
+	Grid   [3]float64
+	Nums   []int16
+	Big    string // @large
+}
+
+type Batch []Reading
+`
+
+const pythonPureTestFixture = `[
+	{"Sensor": "imu", "Mode": "Run", "Unit": "degC", "Tags": ["a", "ü"], "Limits": {"zz": -1, "aa": 7},
+	 "V": {"Point": {"X": 1.5, "Y": -2}}, "Flags": {"A": 5, "B": null}, "Extra": {"Note": "n", "Raw": "AQID"},
+	 "Grid": [1, 2, 3], "Nums": [1, -2, 3], "Big": "large"},
+	{"Sensor": "", "Mode": "Idle", "Unit": null, "Tags": [], "Limits": {}, "V": {"string": "hi"},
+	 "Flags": {"A": null, "B": false}, "Extra": {"Note": "", "Raw": ""}, "Grid": [0, 0, 0], "Nums": [], "Big": ""}
+]`
+
+func TestGeneratePythonPure(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(pythonPureTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := GeneratePythonPure(s)
+	if err != nil {
+		t.Fatalf("GeneratePythonPure failed: %v", err)
+	}
+	for _, want := range []string{
+		"    Mode: _Mode = Mode.Idle\n",
+		"    Limits: Dict[str, int] = field(default_factory=dict)\n",
+		"    V: Optional[Value] = None\n",
+		"class ValuePoint(Value):\n",
+		"        Grid=r.numbers('d', 8, 3),\n",
+		"    _U32.pack_into(out, start, len(out) - start - 4)\n",
+		"class BatchMessage:\n",
+		"_Mode = Mode\n",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(string(code), "cffi") || strings.Contains(string(code), "numpy") {
+		t.Error("pure-Python module refers to the CFFI package")
+	}
+
+	stub := string(generatePythonStub(s))
+	if strings.Contains(stub, "_Reader") || strings.Contains(stub, "def _encode") || strings.Contains(stub, "def _decode") {
+		t.Error("stub declares the codec")
+	}
+	if !strings.Contains(stub, "    def decode(cls, data: bytes) -> BatchMessage: ...\n") {
+		t.Errorf("stub has no BatchMessage.decode:\n%s", stub)
+	}
+}
+
+// TestPythonPureRoundtrip decodes messages encoded by the reference
+// encoder with the generated package, checks they re-encode to the same
+// bytes and that invalid payloads raise the right DecodeErrorCode.
+func TestPythonPureRoundtrip(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not installed")
+	}
+	s, err := parser.ParseBytes([]byte(pythonPureTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "python", OutputDir: t.TempDir(), Namespace: "tele", PythonPure: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	pyDir := config.langDir("python")
+	for _, name := range []string{"pyproject.toml", "README.md", "tele/__init__.py", "tele/__init__.pyi", "tele/py.typed"} {
+		if _, err := os.Stat(filepath.Join(pyDir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(pyDir, "setup.py")); err == nil {
+		t.Error("pure-Python package has setup.py")
+	}
+
+	s.Canonicalize()
+	data, err := fixture.Convert(s, "Batch", []byte(pythonPureTestFixture))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	script := `import sys
+from tele import BatchMessage, DecodeError, DecodeErrorCode, Mode, ValuePoint
+
+data = bytes.fromhex(sys.argv[1])
+msg = BatchMessage.decode(data)
+r = msg.value[0]
+assert r.Mode is Mode.Run and r.Unit == 'degC' and r.Limits == {'aa': 7, 'zz': -1}, r
+assert r.V == ValuePoint(r.V.value) and r.Flags.A == 5 and r.Flags.B is None, r
+assert r.Extra.Raw == b'\x01\x02\x03' and r.Grid == [1.0, 2.0, 3.0] and r.Big == 'large', r
+assert msg.encode() == data
+
+
+def code(payload):
+    try:
+        BatchMessage.decode(payload)
+    except DecodeError as e:
+        return e.code
+    return None
+
+
+for i in range(len(data)):
+    assert code(data[:i]) in (DecodeErrorCode.TRUNCATED, DecodeErrorCode.OVERFLOW), i
+assert code(data + b'\x00') == DecodeErrorCode.TRAILING_DATA
+assert code(b'\x03\x00') == DecodeErrorCode.OVERFLOW
+bad = bytearray(data)
+bad[2] = 9  # Mode of the first reading
+assert code(bytes(bad)) == DecodeErrorCode.INVALID_VALUE
+print('ok')
+`
+	cmd := exec.Command("python3", "-c", script, hex.EncodeToString(data))
+	cmd.Dir = pyDir
+	if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
+		t.Errorf("python3: %v\n%s", err, out)
+	}
+}
+
+func TestPythonPureRejectedForOtherLanguages(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(pythonPureTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	config := &PackageConfig{Schema: s, Language: "go", OutputDir: t.TempDir(), PythonPure: true}
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--python-pure is not supported for go") {
+		t.Errorf("err = %v, want --python-pure rejected for go", err)
+	}
+}
@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/shaban/ffire/pkg/remote"
+)
+
+// Native libraries are built reproducibly: the same schema, compiler and
+// flags produce a bit-identical library wherever and whenever the package
+// is generated, so a checksum of it can be pinned. The compiler runs in the
+// language package root, which debug info records as ".", timestamps are
+// left out of Windows DLLs, and SOURCE_DATE_EPOCH is set for compilers that
+// expand __DATE__ and __TIME__.
+
+// defaultSourceDateEpoch is used when SOURCE_DATE_EPOCH is not set:
+// 1980-01-01, the earliest time every archive format can represent.
+const defaultSourceDateEpoch = "315532800"
+
+// reproducibleFlags returns the compiler flags that keep the build
+// directory and the build time out of a library for platform built in
+// root.
+func reproducibleFlags(platform, root string) []string {
+	flags := []string{"-fdebug-prefix-map=" + root + "=."}
+	if platform == "windows" {
+		flags = append(flags, "-Wl,--no-insert-timestamp")
+	}
+	return flags
+}
+
+// reproducibleEnv returns the environment for a native build, with
+// SOURCE_DATE_EPOCH pinned unless the caller already set it.
+func reproducibleEnv() []string {
+	env := os.Environ()
+	if os.Getenv("SOURCE_DATE_EPOCH") == "" {
+		env = append(env, "SOURCE_DATE_EPOCH="+defaultSourceDateEpoch)
+	}
+	return env
+}
+
+// reproducibleCommand prepares cmd to run in root with a pinned
+// environment. A compiler given by relative path stays relative to the
+// directory ffire runs in.
+func reproducibleCommand(cmd *exec.Cmd, root string) error {
+	if filepath.Base(cmd.Path) != cmd.Path && !filepath.IsAbs(cmd.Path) {
+		abs, err := filepath.Abs(cmd.Path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", cmd.Path, err)
+		}
+		cmd.Path = abs
+	}
+	cmd.Dir = root
+	cmd.Env = reproducibleEnv()
+	return nil
+}
+
+// checksumSuffix returns " (sha256:<hex>)" for a built library, the form
+// used for pinning, or "" if it cannot be read.
+func checksumSuffix(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return " (" + remote.Checksum(data) + ")"
+}
@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestReproducibleFlags(t *testing.T) {
+	flags := strings.Join(reproducibleFlags("linux", "/tmp/out/dart"), " ")
+	if flags != "-fdebug-prefix-map=/tmp/out/dart=." {
+		t.Errorf("linux flags = %q", flags)
+	}
+	flags = strings.Join(reproducibleFlags("windows", "/tmp/out/dart"), " ")
+	if !strings.Contains(flags, "-Wl,--no-insert-timestamp") {
+		t.Errorf("windows flags = %q, want the PE timestamp left out", flags)
+	}
+}
+
+func TestReproducibleEnv(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	if env := strings.Join(reproducibleEnv(), "\n"); !strings.Contains(env, "SOURCE_DATE_EPOCH="+defaultSourceDateEpoch) {
+		t.Errorf("SOURCE_DATE_EPOCH not pinned")
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	env := reproducibleEnv()
+	for _, v := range env {
+		if v == "SOURCE_DATE_EPOCH="+defaultSourceDateEpoch {
+			t.Errorf("caller's SOURCE_DATE_EPOCH was overridden")
+		}
+	}
+}
+
+func TestReproducibleLibrary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reproducibility check runs on linux")
+	}
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+
+	// Build with debug info, which records source paths, into two
+	// different directories
+	build := func() []byte {
+		s, err := parser.Parse("../../testdata/schema/complex.ffi")
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		out := t.TempDir()
+		config := &PackageConfig{
+			Schema:    s,
+			Language:  "dart",
+			OutputDir: out,
+			Platform:  "current",
+			Optimize:  2,
+			NoFormat:  true,
+			CXXFlags:  "-g",
+		}
+		if err := GeneratePackage(config); err != nil {
+			t.Fatalf("GeneratePackage failed: %v", err)
+		}
+		lib, err := os.ReadFile(filepath.Join(out, "dart", "lib", "libtest.so"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(lib, []byte(out)) {
+			t.Errorf("library contains its build directory %s", out)
+		}
+		return lib
+	}
+
+	if !bytes.Equal(build(), build()) {
+		t.Errorf("rebuilding the library produced different bytes")
+	}
+}
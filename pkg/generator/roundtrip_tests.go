@@ -0,0 +1,395 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Roundtrip tests decode a wire-format sample with the generated code,
+// encode the result again and check that the bytes are unchanged. Every
+// message gets a "sample" case built by fixture.Sample; messages with a
+// JSON fixture in PackageConfig.Fixtures also get a "fixture" case.
+
+// roundtripTestLanguages maps the languages with a test emitter to the
+// framework used, for messages and docs.
+var roundtripTestLanguages = map[string]string{
+	"go":             "go test",
+	"c":              "GoogleTest",
+	"cpp":            "GoogleTest",
+	"c++":            "GoogleTest",
+	"swift":          "XCTest",
+	"java":           "JUnit 5",
+	"python":         "pytest",
+	"py":             "pytest",
+	"igniffi-python": "pytest",
+}
+
+// supportsRoundtripTests reports whether --with-tests works for lang.
+func supportsRoundtripTests(lang string) bool {
+	_, ok := roundtripTestLanguages[strings.ToLower(lang)]
+	return ok
+}
+
+type roundtripCase struct {
+	Name string // "sample" or "fixture"
+	Hex  string // wire bytes, hex-encoded
+}
+
+type roundtripMessage struct {
+	Name     string // message name, e.g. PluginList
+	RootType string // root type name used in Go and C++ function names
+	Cases    []roundtripCase
+}
+
+type roundtripData struct {
+	Package   string
+	Namespace string
+	Sanitize  string // comma-separated sanitizers for the C++ test build
+	Messages  []roundtripMessage
+}
+
+// roundtripCases builds the test cases for every message in the schema.
+func roundtripCases(config *PackageConfig) (*roundtripData, error) {
+	s := config.Schema
+	// Generators canonicalize field order; fixtures must use the same order
+	s.Canonicalize()
+
+	for name := range config.Fixtures {
+		if !hasMessage(s, name) {
+			return nil, fmt.Errorf("fixture for unknown message type %s", name)
+		}
+	}
+
+	data := &roundtripData{Package: s.Package, Namespace: config.Namespace, Sanitize: strings.Join(config.Sanitize, ",")}
+	for _, msg := range s.Messages {
+		m := roundtripMessage{Name: msg.Name, RootType: rootTypeName(msg.TargetType)}
+
+		value, err := fixture.Sample(s, msg.Name)
+		if err != nil {
+			return nil, err
+		}
+		wire, err := fixture.Encode(s, msg.Name, value)
+		if err != nil {
+			return nil, fmt.Errorf("encode sample for %s: %w", msg.Name, err)
+		}
+		m.Cases = append(m.Cases, roundtripCase{Name: "sample", Hex: hex.EncodeToString(wire)})
+
+		if jsonData, ok := config.Fixtures[msg.Name]; ok {
+			wire, err := fixture.Convert(s, msg.Name, jsonData)
+			if err != nil {
+				return nil, fmt.Errorf("convert fixture for %s: %w", msg.Name, err)
+			}
+			m.Cases = append(m.Cases, roundtripCase{Name: "fixture", Hex: hex.EncodeToString(wire)})
+		}
+		data.Messages = append(data.Messages, m)
+	}
+	return data, nil
+}
+
+func hasMessage(s *schema.Schema, name string) bool {
+	for _, msg := range s.Messages {
+		if msg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// generateRoundtripTests writes roundtrip tests next to the generated
+// package, in the native test framework of config.Language.
+func generateRoundtripTests(config *PackageConfig) error {
+	data, err := roundtripCases(config)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	var tmpl *template.Template
+	switch lang := strings.ToLower(config.Language); lang {
+	case "go":
+		path = filepath.Join(config.OutputDir, config.Namespace+"_test.go")
+		tmpl = goRoundtripTemplate
+	case "c", "cpp", "c++":
+		testsDir := filepath.Join(config.langDir(config.Language), "tests")
+		if err := writeRoundtripFile(filepath.Join(testsDir, "CMakeLists.txt"), gtestCMakeTemplate, data); err != nil {
+			return err
+		}
+		path = filepath.Join(testsDir, "roundtrip_test.cpp")
+		tmpl = gtestRoundtripTemplate
+	case "swift":
+		path = filepath.Join(config.langDir(SwiftLayout.Name), "Tests", config.Namespace+"Tests", "RoundtripTests.swift")
+		tmpl = xctestRoundtripTemplate
+	case "java":
+		path = filepath.Join(config.OutputDir, "test", strings.ReplaceAll(config.Schema.Package, ".", "/"), "RoundtripTest.java")
+		tmpl = junitRoundtripTemplate
+	case "python", "py", "igniffi-python":
+		data.Package = toPythonIdentifier(config.Schema.Package)
+		path = filepath.Join(config.langDir("python"), "tests", "test_roundtrip.py")
+		tmpl = pytestRoundtripTemplate
+	default:
+		return fmt.Errorf("roundtrip tests are not supported for %s", config.Language)
+	}
+
+	if err := writeRoundtripFile(path, tmpl, data); err != nil {
+		return err
+	}
+	config.infof("✓ Generated roundtrip tests (%s): %s\n", roundtripTestLanguages[strings.ToLower(config.Language)], path)
+	return nil
+}
+
+func writeRoundtripFile(path string, tmpl *template.Template, data any) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("failed to generate %s: %w", filepath.Base(path), err)
+	}
+	code := buf.Bytes()
+	if strings.HasSuffix(path, ".go") {
+		formatted, err := format.Source(code)
+		if err != nil {
+			return fmt.Errorf("format go test code: %w", err)
+		}
+		code = formatted
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create test directory: %w", err)
+	}
+	if err := os.WriteFile(path, code, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+var roundtripFuncs = template.FuncMap{
+	"lower":  strings.ToLower,
+	"snake":  ToSnakeCase,
+	"pascal": ToPascalCase,
+}
+
+var goRoundtripTemplate = template.Must(template.New("go").Funcs(roundtripFuncs).Parse(`// Code generated by ffire. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+{{range .Messages}}
+func Test{{.Name}}Roundtrip(t *testing.T) {
+	cases := []struct {
+		name string
+		wire string
+	}{
+{{- range .Cases}}
+		{"{{.Name}}", "{{.Hex}}"},
+{{- end}}
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tc.wire)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := Decode{{.RootType}}Message(data)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got := Encode{{.RootType}}Message(msg); !bytes.Equal(got, data) {
+				t.Errorf("re-encoded %d bytes differ from the original %d bytes", len(got), len(data))
+			}
+		})
+	}
+}
+{{end}}`))
+
+var gtestRoundtripTemplate = template.Must(template.New("gtest").Funcs(roundtripFuncs).Parse(`// Code generated by ffire. DO NOT EDIT.
+
+#include <cstdint>
+#include <string>
+#include <vector>
+
+#ifdef FFIRE_NO_GTEST
+// Minimal stand-in for GoogleTest, used by ffire generate --sanitize to run
+// these tests without a GoogleTest installation.
+#include <cstdio>
+
+namespace ffire_test {
+
+struct Case {
+    const char* name;
+    void (*fn)();
+};
+
+inline std::vector<Case>& cases() {
+    static std::vector<Case> all;
+    return all;
+}
+
+inline int failures = 0;
+
+struct Registrar {
+    Registrar(const char* name, void (*fn)()) { cases().push_back({name, fn}); }
+};
+
+}  // namespace ffire_test
+
+#define TEST(suite, name)                                                         \
+    static void suite##_##name();                                                 \
+    static ffire_test::Registrar suite##_##name##_registrar(#suite "." #name,     \
+                                                            suite##_##name);      \
+    static void suite##_##name()
+
+#define EXPECT_EQ(a, b)                                                           \
+    do {                                                                          \
+        if (!((a) == (b))) {                                                      \
+            std::fprintf(stderr, "%s:%d: EXPECT_EQ(%s, %s) failed\n", __FILE__,    \
+                         __LINE__, #a, #b);                                       \
+            ++ffire_test::failures;                                               \
+        }                                                                         \
+    } while (0)
+
+int main() {
+    for (const auto& c : ffire_test::cases()) {
+        std::printf("[ RUN      ] %s\n", c.name);
+        const int before = ffire_test::failures;
+        c.fn();
+        std::printf("%s %s\n", ffire_test::failures == before ? "[       OK ]" : "[  FAILED  ]", c.name);
+    }
+    return ffire_test::failures == 0 ? 0 : 1;
+}
+#else
+#include <gtest/gtest.h>
+#endif
+
+#include "generated.hpp"
+
+namespace {
+
+std::vector<uint8_t> from_hex(const std::string& hex) {
+    std::vector<uint8_t> out;
+    out.reserve(hex.size() / 2);
+    for (size_t i = 0; i + 1 < hex.size(); i += 2) {
+        out.push_back(static_cast<uint8_t>(std::stoul(hex.substr(i, 2), nullptr, 16)));
+    }
+    return out;
+}
+
+}  // namespace
+{{range $m := .Messages}}{{range .Cases}}
+TEST({{$m.Name}}Roundtrip, {{pascal .Name}}) {
+    const std::vector<uint8_t> data = from_hex("{{.Hex}}");
+    const auto msg = {{$.Package}}::decode_{{lower $m.RootType}}_message(data);
+    EXPECT_EQ({{$.Package}}::encode_{{lower $m.RootType}}_message(msg), data);
+}
+{{end}}{{end}}`))
+
+var gtestCMakeTemplate = template.Must(template.New("cmake").Parse(`# Code generated by ffire. DO NOT EDIT.
+#
+#   cmake -S tests -B build/tests && cmake --build build/tests && ctest --test-dir build/tests
+
+cmake_minimum_required(VERSION 3.14)
+project({{.Package}}_tests CXX)
+
+set(CMAKE_CXX_STANDARD 17)
+set(CMAKE_CXX_STANDARD_REQUIRED ON)
+
+# Sanitizers to build the tests with, e.g. -DFFIRE_SANITIZE=address,undefined
+set(FFIRE_SANITIZE "{{.Sanitize}}" CACHE STRING "Comma-separated -fsanitize list")
+if(FFIRE_SANITIZE)
+    add_compile_options(-fsanitize=${FFIRE_SANITIZE} -fno-sanitize-recover=all -fno-omit-frame-pointer -g)
+    add_link_options(-fsanitize=${FFIRE_SANITIZE})
+endif()
+
+find_package(GTest REQUIRED)
+include(GoogleTest)
+
+add_executable(roundtrip_test roundtrip_test.cpp)
+target_include_directories(roundtrip_test PRIVATE ${CMAKE_CURRENT_SOURCE_DIR}/../include)
+target_link_libraries(roundtrip_test PRIVATE GTest::gtest_main)
+
+enable_testing()
+gtest_discover_tests(roundtrip_test)
+`))
+
+var xctestRoundtripTemplate = template.Must(template.New("xctest").Funcs(roundtripFuncs).Parse(`// Code generated by ffire. DO NOT EDIT.
+
+import Foundation
+import XCTest
+@testable import {{.Namespace}}
+
+final class RoundtripTests: XCTestCase {
+{{- range $m := .Messages}}{{range .Cases}}
+    func test{{$m.Name}}{{pascal .Name}}() throws {
+        let data = Data(hex: "{{.Hex}}")
+        let msg = try decode{{$m.Name}}Message(data)
+        XCTAssertEqual(encode{{$m.Name}}Message(msg), data)
+    }
+{{end}}{{end -}}
+}
+
+private extension Data {
+    init(hex: String) {
+        var bytes = [UInt8]()
+        bytes.reserveCapacity(hex.utf8.count / 2)
+        var index = hex.startIndex
+        while index < hex.endIndex {
+            let next = hex.index(index, offsetBy: 2)
+            bytes.append(UInt8(hex[index..<next], radix: 16)!)
+            index = next
+        }
+        self.init(bytes)
+    }
+}
+`))
+
+var junitRoundtripTemplate = template.Must(template.New("junit").Funcs(roundtripFuncs).Parse(`// Code generated by ffire. DO NOT EDIT.
+
+package {{.Package}};
+
+import static org.junit.jupiter.api.Assertions.assertArrayEquals;
+
+import java.util.HexFormat;
+import org.junit.jupiter.api.Test;
+
+class RoundtripTest {
+{{- range $m := .Messages}}{{range .Cases}}
+    @Test
+    void {{lower (slice $m.Name 0 1)}}{{slice $m.Name 1}}{{pascal .Name}}() {
+        byte[] data = HexFormat.of().parseHex("{{.Hex}}");
+        {{$m.Name}}Message msg = {{$m.Name}}Message.decode(data);
+        assertArrayEquals(data, msg.encode());
+    }
+{{end}}{{end -}}
+}
+`))
+
+var pytestRoundtripTemplate = template.Must(template.New("pytest").Funcs(roundtripFuncs).Parse(`# Code generated by ffire. DO NOT EDIT.
+"""Roundtrip tests: decoding and re-encoding must reproduce the input bytes.
+
+Run from the python/ directory: python -m pytest tests
+"""
+
+import pytest
+
+from {{.Package}} import {{range $i, $m := .Messages}}{{if $i}}, {{end}}{{$m.Name}}Message{{end}}
+{{range $m := .Messages}}
+
+@pytest.mark.parametrize("wire", [
+{{- range .Cases}}
+    pytest.param("{{.Hex}}", id="{{.Name}}"),
+{{- end}}
+])
+def test_{{snake $m.Name}}_roundtrip(wire):
+    data = bytes.fromhex(wire)
+    assert {{$m.Name}}Message.decode(data).encode() == data
+{{end}}`))
@@ -0,0 +1,174 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestRoundtripTestsGoRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go test on the generated package")
+	}
+
+	for _, name := range []string{"complex", "nested", "optional"} {
+		t.Run(name, func(t *testing.T) {
+			s, err := parser.Parse("../../testdata/schema/" + name + ".ffi")
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			fixture, err := os.ReadFile("../../testdata/json/" + name + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out := t.TempDir()
+			config := &PackageConfig{
+				Schema:    s,
+				Language:  "go",
+				OutputDir: out,
+				WithTests: true,
+				Fixtures:  map[string][]byte{s.Messages[0].Name: fixture},
+			}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(out, "go.mod"), []byte("module "+s.Package+"\n\ngo 1.21\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := exec.Command("go", "test", "-v", "./...")
+			cmd.Dir = out
+			output, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("generated tests failed: %v\n%s", err, output)
+			}
+			for _, c := range []string{"/sample", "/fixture"} {
+				if !strings.Contains(string(output), "--- PASS: Test"+s.Messages[0].Name+"Roundtrip"+c) {
+					t.Errorf("case %s did not run:\n%s", c, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundtripTestsPerLanguage(t *testing.T) {
+	tests := []struct {
+		lang  string
+		files []string
+		want  []string
+	}{
+		{"cpp", []string{"cpp/tests/roundtrip_test.cpp", "cpp/tests/CMakeLists.txt"}, []string{
+			"#include <gtest/gtest.h>",
+			"TEST(ConfigRoundtrip, Sample)",
+			"TEST(ConfigRoundtrip, Fixture)",
+			"test::encode_config_message(msg)",
+		}},
+		{"swift", []string{"swift/Tests/testTests/RoundtripTests.swift"}, []string{
+			"@testable import test",
+			"func testConfigSample() throws",
+			"try decodeConfigMessage(data)",
+		}},
+		{"java", []string{"test/test/RoundtripTest.java"}, []string{
+			"package test;",
+			"import org.junit.jupiter.api.Test;",
+			"void configFixture()",
+			"ConfigMessage.decode(data)",
+		}},
+		{"python", []string{"python/tests/test_roundtrip.py"}, []string{
+			"from test import ConfigMessage",
+			`pytest.param("`,
+			`id="fixture"`,
+			"def test_config_roundtrip(wire):",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			s, err := parser.Parse("../../testdata/schema/struct.ffi")
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			out := t.TempDir()
+			config := &PackageConfig{
+				Schema:    s,
+				Language:  tt.lang,
+				OutputDir: out,
+				Platform:  "current",
+				Arch:      "current",
+				NoCompile: true,
+				WithTests: true,
+				Fixtures:  map[string][]byte{"Config": []byte(`{"host":"localhost","port":8080,"enableSSL":true,"timeout":1.5,"maxRetries":3}`)},
+			}
+			if err := GeneratePackage(config); err != nil {
+				t.Fatalf("GeneratePackage failed: %v", err)
+			}
+
+			code, err := os.ReadFile(filepath.Join(out, tt.files[0]))
+			if err != nil {
+				t.Fatalf("test file not generated: %v", err)
+			}
+			for _, f := range tt.files[1:] {
+				if _, err := os.Stat(filepath.Join(out, f)); err != nil {
+					t.Errorf("%s not generated: %v", f, err)
+				}
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(string(code), w) {
+					t.Errorf("%s missing %q:\n%s", tt.files[0], w, code)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundtripTestsSwiftManifest(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	out := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "swift", OutputDir: out, NoCompile: true, WithTests: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(out, "swift", "Package.swift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(manifest), `.testTarget(
+            name: "testTests",`) {
+		t.Errorf("Package.swift has no test target:\n%s", manifest)
+	}
+}
+
+func TestRoundtripTestsErrors(t *testing.T) {
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	err = GeneratePackage(&PackageConfig{Schema: s, Language: "rust", OutputDir: out, WithTests: true})
+	if err == nil || !strings.Contains(err.Error(), "--with-tests is not supported for rust") {
+		t.Errorf("unsupported language: err = %v", err)
+	}
+	if _, statErr := os.Stat(out); statErr == nil {
+		t.Error("output directory created for an unsupported language")
+	}
+
+	err = GeneratePackage(&PackageConfig{
+		Schema:    s,
+		Language:  "go",
+		OutputDir: t.TempDir(),
+		WithTests: true,
+		Fixtures:  map[string][]byte{"Missing": []byte(`{}`)},
+	})
+	if err == nil || !strings.Contains(err.Error(), "fixture for unknown message type Missing") {
+		t.Errorf("unknown fixture message: err = %v", err)
+	}
+}
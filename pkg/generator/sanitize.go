@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// knownSanitizers are the values accepted by --sanitize. They are supported
+// by both GCC and Clang.
+var knownSanitizers = []string{"address", "undefined", "thread", "leak"}
+
+// ParseSanitizers validates a comma-separated --sanitize value such as
+// "address,undefined". The thread sanitizer cannot be combined with the
+// address or leak sanitizers.
+func ParseSanitizers(value string) ([]string, error) {
+	var sanitizers []string
+	seen := make(map[string]bool)
+	for _, s := range strings.Split(value, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || seen[s] {
+			continue
+		}
+		if !contains(knownSanitizers, s) {
+			return nil, fmt.Errorf("unknown sanitizer %q (supported: %s)", s, strings.Join(knownSanitizers, ", "))
+		}
+		seen[s] = true
+		sanitizers = append(sanitizers, s)
+	}
+	if seen["thread"] && (seen["address"] || seen["leak"]) {
+		return nil, fmt.Errorf("the thread sanitizer cannot be combined with address or leak")
+	}
+	return sanitizers, nil
+}
+
+// SanitizerFlags returns the compiler and linker flags for sanitizers:
+// instrumentation, debug info and frame pointers for readable reports, and
+// no recovery so that undefined behavior fails the run.
+func SanitizerFlags(sanitizers []string) []string {
+	if len(sanitizers) == 0 {
+		return nil
+	}
+	return []string{
+		"-fsanitize=" + strings.Join(sanitizers, ","),
+		"-fno-sanitize-recover=all",
+		"-fno-omit-frame-pointer",
+		"-g",
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeLanguages are the languages whose packages build a native
+// library that --sanitize can instrument.
+var sanitizeLanguages = []string{
+	"c", "cpp", "c++", "swift", "dart", "zig",
+	"js", "javascript", "igniffi-js", "python", "py", "igniffi-python",
+}
+
+// checkSanitize reports settings that --sanitize cannot work with.
+func checkSanitize(config *PackageConfig) error {
+	if len(config.Sanitize) == 0 {
+		return nil
+	}
+	if !contains(sanitizeLanguages, strings.ToLower(config.Language)) {
+		return fmt.Errorf("--sanitize needs a native library build, which %s packages do not have", config.Language)
+	}
+	if config.NoCompile {
+		return fmt.Errorf("--sanitize has no effect with --no-compile")
+	}
+	if config.Platform == "windows" {
+		return fmt.Errorf("--sanitize is not supported for windows builds (MinGW has no sanitizer runtimes)")
+	}
+	return nil
+}
+
+// printSanitizerPreload explains how to load an instrumented library into
+// a host process, such as Python or Node.js, that was not built with the
+// sanitizer runtime.
+func printSanitizerPreload(config *PackageConfig, compiler string) {
+	if !contains(config.Sanitize, "address") && !contains(config.Sanitize, "thread") {
+		return
+	}
+	var b strings.Builder
+	runtimeLib := "asan"
+	if contains(config.Sanitize, "thread") {
+		runtimeLib = "tsan"
+	}
+	fmt.Fprintf(&b, "  Instrumented with -fsanitize=%s. Preload the sanitizer runtime when a\n", strings.Join(config.Sanitize, ","))
+	fmt.Fprintf(&b, "  non-instrumented host (python, node, dart) loads the library:\n")
+	if runtime.GOOS == "darwin" {
+		fmt.Fprintf(&b, "    DYLD_INSERT_LIBRARIES=$(%s -print-file-name=libclang_rt.%s_osx_dynamic.dylib) <command>\n", compiler, runtimeLib)
+	} else {
+		fmt.Fprintf(&b, "    LD_PRELOAD=$(%s -print-file-name=lib%s.so) <command>\n", compiler, runtimeLib)
+	}
+	config.infof("%s", b.String())
+}
+
+// runSanitizedTests builds the C++ roundtrip tests with the sanitizers and
+// the built-in test runner (no GoogleTest needed) and runs them, so memory
+// errors in the C++ core surface at generation time.
+func runSanitizedTests(config *PackageConfig) error {
+	testsDir := filepath.Join(config.langDir(config.Language), "tests")
+	includeDir := filepath.Join(config.langDir(config.Language), "include")
+	binary := filepath.Join(testsDir, "roundtrip_test_sanitized")
+
+	cxx, err := config.cxxCommand(defaultCXX())
+	if err != nil {
+		return err
+	}
+	args := append([]string{}, cxx[1:]...)
+	args = append(args, "-std=c++17", "-O1", "-DFFIRE_NO_GTEST")
+	args = append(args, SanitizerFlags(config.Sanitize)...)
+	args = append(args, "-I"+includeDir, "-o", binary, filepath.Join(testsDir, "roundtrip_test.cpp"))
+	if err := runBuild(config, config.command(cxx[0], args...), filepath.Base(binary), testsDir); err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	cmd := config.command(binary)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		if ctxErr := config.context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("roundtrip tests failed under -fsanitize=%s: %v\n%s", strings.Join(config.Sanitize, ","), err, output.String())
+	}
+	config.debugf("%s", output.String())
+	os.Remove(binary)
+	config.infof("✓ Roundtrip tests passed under -fsanitize=%s\n", strings.Join(config.Sanitize, ","))
+	return nil
+}
+
+// defaultCXX is the C++ compiler used for host builds.
+func defaultCXX() string {
+	if runtime.GOOS == "darwin" {
+		return "clang++"
+	}
+	return "g++"
+}
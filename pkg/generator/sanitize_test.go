@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+func TestParseSanitizers(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"address", "address"},
+		{" Address, undefined ,address", "address,undefined"},
+		{"thread,undefined", "thread,undefined"},
+	}
+	for _, tt := range tests {
+		got, err := ParseSanitizers(tt.in)
+		if err != nil {
+			t.Errorf("ParseSanitizers(%q) error: %v", tt.in, err)
+			continue
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("ParseSanitizers(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"memory", "address,thread", "leak,thread"} {
+		if _, err := ParseSanitizers(bad); err == nil {
+			t.Errorf("ParseSanitizers(%q) should fail", bad)
+		}
+	}
+}
+
+func TestCheckSanitize(t *testing.T) {
+	tests := []struct {
+		config PackageConfig
+		want   string
+	}{
+		{PackageConfig{Language: "cpp", Sanitize: []string{"address"}}, ""},
+		{PackageConfig{Language: "go", Sanitize: []string{"address"}}, "native library"},
+		{PackageConfig{Language: "dart", Sanitize: []string{"address"}, NoCompile: true}, "--no-compile"},
+		{PackageConfig{Language: "cpp", Sanitize: []string{"address"}, Platform: "windows"}, "windows"},
+		{PackageConfig{Language: "go", NoCompile: true}, ""},
+	}
+	for _, tt := range tests {
+		err := checkSanitize(&tt.config)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.config.Language, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.config.Language, err, tt.want)
+		}
+	}
+}
+
+func TestCompileDylibSanitizerFlags(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("platform without a default native build")
+	}
+	logPath := fakeFormatter(t, "my-cxx")
+	s, err := parser.Parse("../../testdata/schema/struct.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	config := &PackageConfig{
+		Schema:    s,
+		Language:  "dart",
+		OutputDir: t.TempDir(),
+		Platform:  runtime.GOOS,
+		Optimize:  2,
+		NoFormat:  true,
+		CXX:       "my-cxx",
+		Sanitize:  []string{"address", "undefined"},
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	logged, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("compiler was not run: %v", err)
+	}
+	args := " " + strings.Join(strings.Fields(string(logged)), " ") + " "
+	for _, flag := range []string{"-fsanitize=address,undefined", "-fno-omit-frame-pointer", "-g"} {
+		if !strings.Contains(args, " "+flag+" ") {
+			t.Errorf("compiler args %q lack %s", logged, flag)
+		}
+	}
+}
+
+// requireSanitizers skips the test unless g++ can build and run an
+// AddressSanitizer binary.
+func requireSanitizers(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("sanitizer test runs on linux")
+	}
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "probe.cpp")
+	if err := os.WriteFile(src, []byte("int main() { return 0; }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bin := filepath.Join(dir, "probe")
+	if out, err := exec.Command("g++", "-fsanitize=address", "-o", bin, src).CombinedOutput(); err != nil {
+		t.Skipf("g++ cannot build with AddressSanitizer: %s", out)
+	}
+	if out, err := exec.Command(bin).CombinedOutput(); err != nil {
+		t.Skipf("AddressSanitizer binaries do not run here: %s", out)
+	}
+}
+
+func TestSanitizedRoundtripTests(t *testing.T) {
+	requireSanitizers(t)
+	s, err := parser.Parse("../../testdata/schema/complex.ffi")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	out := t.TempDir()
+	config := &PackageConfig{
+		Schema:    s,
+		Language:  "cpp",
+		OutputDir: out,
+		Platform:  "current",
+		Optimize:  1,
+		NoFormat:  true,
+		WithTests: true,
+		Sanitize:  []string{"address", "undefined"},
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	cmake, err := os.ReadFile(filepath.Join(out, "cpp", "tests", "CMakeLists.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(cmake), `set(FFIRE_SANITIZE "address,undefined"`) {
+		t.Errorf("CMakeLists.txt does not default to the sanitizers:\n%s", cmake)
+	}
+
+	// A failing expectation must fail the run
+	testFile := filepath.Join(out, "cpp", "tests", "roundtrip_test.cpp")
+	f, err := os.OpenFile(testFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\nTEST(Broken, Mismatch) {\n    EXPECT_EQ(1, 2);\n}\n")
+	f.Close()
+	err = runSanitizedTests(config)
+	if err == nil || !strings.Contains(err.Error(), "EXPECT_EQ(1, 2) failed") {
+		t.Errorf("failing test: err = %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// With PackageConfig.SBOM, each generated package gets a CycloneDX software
+// bill of materials listing what ships in it: the package itself, the
+// third-party runtime dependencies its manifest declares, and the native
+// libraries built for it with their digests. Generated code and the C++
+// core are ffire output, part of the package component. The file carries no
+// timestamp or serial number, so it is as reproducible as the package.
+
+// SBOMFile is the name of the SBOM in a package directory.
+const SBOMFile = "bom.cdx.json"
+
+// runtimeDependency is a third-party package a generated package depends
+// on at run time, as declared in its manifest.
+type runtimeDependency struct {
+	purlType   string // Package URL type, e.g. "npm"
+	group      string // Maven groupId
+	name       string
+	constraint string // Version constraint written to the manifest
+}
+
+// runtimeDependencies lists the runtime dependencies of the packages for
+// each canonical language. Build-time and test-only dependencies (setuptools,
+// pytest, JUnit) do not ship and are left out. Keep in sync with the
+// manifest generators; TestRuntimeDependenciesMatchManifests checks them.
+var runtimeDependencies = map[string][]runtimeDependency{
+	"js":     {{purlType: "npm", name: "koffi", constraint: "^2.8.0"}},
+	"python": {{purlType: "pypi", name: "cffi", constraint: ">=1.0.0"}, {purlType: "pypi", name: "numpy", constraint: ">=1.19.0"}},
+	"dart":   {{purlType: "pub", name: "ffi", constraint: "^2.0.0"}},
+	"kotlin": {{purlType: "maven", group: "org.jetbrains.kotlin", name: "kotlin-stdlib", constraint: kotlinVersion}},
+}
+
+// packageDependencies returns the runtime dependencies of the package
+// config describes, including those its options add.
+func packageDependencies(config *PackageConfig) []runtimeDependency {
+	deps := runtimeDependencies[canonicalLanguage(config.Language)]
+	if config.KotlinOkio {
+		deps = append(deps[:len(deps):len(deps)], kotlinOkio)
+	}
+	return deps
+}
+
+// nativeLibrarySuffixes are the file names of built native libraries.
+var nativeLibrarySuffixes = []string{".so", ".dylib", ".dll", ".a"}
+
+// cdxBOM is a CycloneDX 1.6 BOM, with the fields ffire fills in.
+type cdxBOM struct {
+	BOMFormat    string          `json:"bomFormat"`
+	SpecVersion  string          `json:"specVersion"`
+	Version      int             `json:"version"`
+	Metadata     cdxMetadata     `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+type cdxMetadata struct {
+	Tools struct {
+		Components []cdxComponent `json:"components"`
+	} `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxComponent struct {
+	Type        string       `json:"type"`
+	BOMRef      string       `json:"bom-ref,omitempty"`
+	Group       string       `json:"group,omitempty"`
+	Name        string       `json:"name"`
+	Version     string       `json:"version,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Scope       string       `json:"scope,omitempty"`
+	Hashes      []cdxHash    `json:"hashes,omitempty"`
+	Licenses    []cdxLicense `json:"licenses,omitempty"`
+	Purl        string       `json:"purl,omitempty"`
+}
+
+// cdxLicense is a CycloneDX license choice: an SPDX id, or a name for
+// licenses SPDX does not list.
+type cdxLicense struct {
+	License struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	} `json:"license"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// writeSBOM writes the SBOM of the package to SBOMFile in its directory.
+func writeSBOM(config *PackageConfig) error {
+	dir := packageDir(config)
+	files, err := packageFiles(dir)
+	if err != nil {
+		return err
+	}
+	bom, err := newSBOM(config, dir, files)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, SBOMFile)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	config.infof("✓ Wrote SBOM with %d components: %s\n", len(bom.Components), path)
+	return nil
+}
+
+// newSBOM describes the package in dir, whose files are given relative to
+// it.
+func newSBOM(config *PackageConfig, dir string, files []string) (*cdxBOM, error) {
+	lang := canonicalLanguage(config.Language)
+	pkg := cdxComponent{
+		Type:        "library",
+		Name:        publishedName(config),
+		Version:     config.Version,
+		Description: fmt.Sprintf("ffire %s package for the %s schema", lang, config.Schema.Package),
+		Purl:        packagePurl(config),
+	}
+	if lang == "java" || lang == "kotlin" {
+		pkg.Group, pkg.Name, _ = strings.Cut(pkg.Name, ":")
+	}
+	pkg.BOMRef = valueOr(pkg.Purl, pkg.Name)
+	if config.License != "" {
+		var l cdxLicense
+		if config.License == LicenseProprietary {
+			l.License.Name = "Proprietary"
+		} else {
+			l.License.ID = config.License
+		}
+		pkg.Licenses = []cdxLicense{l}
+	}
+
+	bom := &cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.6",
+		Version:      1,
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{},
+	}
+	tool := cdxComponent{Type: "application", Name: "ffire"}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		tool.Version = info.Main.Version
+	}
+	bom.Metadata.Tools.Components = []cdxComponent{tool}
+	bom.Metadata.Component = pkg
+
+	deps := cdxDependency{Ref: pkg.BOMRef, DependsOn: []string{}}
+	for _, d := range packageDependencies(config) {
+		purl := "pkg:" + d.purlType + "/" + d.name
+		if d.group != "" {
+			purl = "pkg:" + d.purlType + "/" + d.group + "/" + d.name
+		}
+		// The manifest declares a constraint; the consumer's package
+		// manager picks the version, so the purl has none.
+		bom.Components = append(bom.Components, cdxComponent{
+			Type:    "library",
+			BOMRef:  purl,
+			Group:   d.group,
+			Name:    d.name,
+			Version: d.constraint,
+			Scope:   "required",
+			Purl:    purl,
+		})
+		deps.DependsOn = append(deps.DependsOn, purl)
+		bom.Dependencies = append(bom.Dependencies, cdxDependency{Ref: purl, DependsOn: []string{}})
+	}
+	bom.Dependencies = append([]cdxDependency{deps}, bom.Dependencies...)
+
+	for _, f := range files {
+		if !hasAnySuffix(f, nativeLibrarySuffixes) {
+			continue
+		}
+		sum, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		bom.Components = append(bom.Components, cdxComponent{
+			Type:        "file",
+			BOMRef:      "file:" + f,
+			Name:        f,
+			Description: "Native library built from the generated C/C++ code",
+			Hashes:      []cdxHash{{Alg: "SHA-256", Content: sum}},
+		})
+	}
+	return bom, nil
+}
+
+// packagePurl returns the package URL the package is published under, or
+// "" for languages without a registry ffire publishes to.
+func packagePurl(config *PackageConfig) string {
+	name := publishedName(config)
+	var purl string
+	switch canonicalLanguage(config.Language) {
+	case "go":
+		return "pkg:golang/" + name + "@v" + config.Version
+	case "java", "kotlin":
+		group, artifact, _ := strings.Cut(name, ":")
+		purl = "pkg:maven/" + group + "/" + artifact
+	case "js":
+		scope, bare, scoped := strings.Cut(name, "/")
+		if scoped {
+			purl = "pkg:npm/" + strings.Replace(scope, "@", "%40", 1) + "/" + bare
+		} else {
+			purl = "pkg:npm/" + name
+		}
+	case "python":
+		// PyPI names are case-insensitive and treat '_' and '-' alike
+		purl = "pkg:pypi/" + strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	case "csharp":
+		purl = "pkg:nuget/" + name
+	case "dart":
+		purl = "pkg:pub/" + name
+	case "rust":
+		purl = "pkg:cargo/" + name
+	default:
+		return ""
+	}
+	return purl + "@" + config.Version
+}
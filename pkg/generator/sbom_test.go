@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// TestRuntimeDependenciesMatchManifests keeps runtimeDependencies in step
+// with the dependencies the manifest generators write.
+func TestRuntimeDependenciesMatchManifests(t *testing.T) {
+	dir := t.TempDir()
+	config := &PackageConfig{Schema: &schema.Schema{Package: "audio"}, Namespace: "audio", Version: "1.0.0"}
+	if err := generateJSPackageJSON(config, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := generatePyProjectTOML(config, dir, "audio"); err != nil {
+		t.Fatal(err)
+	}
+	if err := generateDartPubspec(config, dir); err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	manifests := map[string]string{
+		"js":     read("package.json"),
+		"python": read("pyproject.toml"),
+		"dart":   read("pubspec.yaml"),
+		"kotlin": generateKotlinBuildGradle("com.ffire", "1.0.0", "Audio", false),
+	}
+
+	for lang, deps := range runtimeDependencies {
+		manifest, ok := manifests[lang]
+		if !ok {
+			t.Errorf("no manifest checked for %s", lang)
+			continue
+		}
+		for _, d := range deps {
+			name := d.name
+			if lang == "kotlin" {
+				name = `kotlin("multiplatform")` // Brings in the standard library
+			}
+			if !strings.Contains(manifest, name) || !strings.Contains(manifest, d.constraint) {
+				t.Errorf("%s manifest does not declare %s %s:\n%s", lang, d.name, d.constraint, manifest)
+			}
+		}
+	}
+
+	okio := generateKotlinBuildGradle("com.ffire", "1.0.0", "Audio", true)
+	if want := kotlinOkio.group + ":" + kotlinOkio.name + ":" + kotlinOkio.constraint; !strings.Contains(okio, want) {
+		t.Errorf("kotlin manifest with okio does not declare %s:\n%s", want, okio)
+	}
+}
+
+func TestNewSBOM(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lib", "libaudio.so"), []byte("ELF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &PackageConfig{
+		Schema:      &schema.Schema{Package: "audio"},
+		Language:    "javascript",
+		Namespace:   "audio",
+		Version:     "1.2.0",
+		Coordinates: Coordinates{NPM: NPMCoordinates{Scope: "@acme"}},
+	}
+	bom, err := newSBOM(config, dir, []string{"index.js", "lib/libaudio.so", "package.json"})
+	if err != nil {
+		t.Fatalf("newSBOM failed: %v", err)
+	}
+
+	if got := bom.Metadata.Component; got.Name != "@acme/audio" || got.Purl != "pkg:npm/%40acme/audio@1.2.0" || got.BOMRef != got.Purl {
+		t.Errorf("package component = %+v", got)
+	}
+	if len(bom.Components) != 2 {
+		t.Fatalf("components = %+v, want koffi and the library", bom.Components)
+	}
+	if koffi := bom.Components[0]; koffi.Purl != "pkg:npm/koffi" || koffi.Version != "^2.8.0" || koffi.Scope != "required" {
+		t.Errorf("koffi component = %+v", koffi)
+	}
+	lib := bom.Components[1]
+	if lib.Type != "file" || lib.Name != "lib/libaudio.so" || len(lib.Hashes) != 1 ||
+		lib.Hashes[0].Content != "706abe3c90152075e656b661079730facf323f3ebccda7547ee1935c90845a09" {
+		t.Errorf("library component = %+v", lib)
+	}
+	if len(bom.Dependencies) != 2 || bom.Dependencies[0].Ref != "pkg:npm/%40acme/audio@1.2.0" ||
+		strings.Join(bom.Dependencies[0].DependsOn, ",") != "pkg:npm/koffi" {
+		t.Errorf("dependencies = %+v", bom.Dependencies)
+	}
+
+	// A package without dependencies has empty lists, not null
+	config.Language = "go"
+	bom, err = newSBOM(config, dir, []string{"audio.go"})
+	if err != nil {
+		t.Fatalf("newSBOM failed: %v", err)
+	}
+	data, err := json.Marshal(bom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "null") {
+		t.Errorf("SBOM has null lists: %s", data)
+	}
+}
+
+func TestPackagePurl(t *testing.T) {
+	tests := []struct {
+		lang        string
+		coordinates Coordinates
+		want        string
+	}{
+		{"go", Coordinates{Go: "example.com/acme/audio"}, "pkg:golang/example.com/acme/audio@v1.2.0"},
+		{"java", Coordinates{Maven: MavenCoordinates{GroupID: "com.acme"}}, "pkg:maven/com.acme/audio@1.2.0"},
+		{"kotlin", Coordinates{}, "pkg:maven/com.ffire/audio@1.2.0"},
+		{"js", Coordinates{}, "pkg:npm/audio@1.2.0"},
+		{"python", Coordinates{PyPI: "Acme_Audio"}, "pkg:pypi/acme-audio@1.2.0"},
+		{"csharp", Coordinates{NuGet: "Acme.Audio"}, "pkg:nuget/Acme.Audio@1.2.0"},
+		{"dart", Coordinates{}, "pkg:pub/audio@1.2.0"},
+		{"rust", Coordinates{Crate: "acme-audio"}, "pkg:cargo/acme-audio@1.2.0"},
+		{"cpp", Coordinates{}, ""},
+	}
+	for _, tt := range tests {
+		config := &PackageConfig{
+			Schema:      &schema.Schema{Package: "audio"},
+			Language:    tt.lang,
+			Namespace:   "audio",
+			Version:     "1.2.0",
+			Coordinates: tt.coordinates,
+		}
+		if got := packagePurl(config); got != tt.want {
+			t.Errorf("packagePurl(%s) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
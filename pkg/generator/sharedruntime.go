@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Each generated package carries its own copy of the runtime helpers it
+// needs. When several schemas are generated into one program, the copies
+// add up. With PackageConfig.SharedRuntime, packages import one shared
+// runtime module instead, written once per program by
+// GenerateSharedRuntime. Go is supported; other languages keep their
+// per-package helpers.
+
+// sharedRuntimeLanguages are the languages with a shared runtime mode.
+var sharedRuntimeLanguages = map[string]bool{"go": true}
+
+// DefaultRuntimePackage is the package name of a shared runtime when none
+// is given.
+const DefaultRuntimePackage = "ffirert"
+
+// goRuntimeAlias is the name generated Go code imports the shared runtime
+// under, whatever its package is called.
+const goRuntimeAlias = "ffirert"
+
+// goRuntimeNames maps the runtime helpers of a package to their exported
+// names in the shared runtime.
+var goRuntimeNames = map[string]string{
+	"ffireGrow":     "Grow",
+	"lenientField":  "LenientField",
+	"decodeLenient": "DecodeLenient",
+}
+
+// runtimeName returns how generated code refers to the runtime helper
+// name: itself, or its shared runtime counterpart.
+func (g *goGenerator) runtimeName(name string) string {
+	if g.opts.SharedRuntime == "" {
+		return name
+	}
+	return goRuntimeAlias + "." + goRuntimeNames[name]
+}
+
+// goSharedEncodeBuffer keeps the encode buffer API of a package that uses
+// the shared runtime, so callers do not change.
+const goSharedEncodeBuffer = `// EncodeBuffer is a reusable encode buffer from the pool of the shared
+// runtime, which all schema packages of the program draw from.
+type EncodeBuffer = ffirert.EncodeBuffer
+
+// GetEncodeBuffer returns an empty buffer from the shared pool.
+func GetEncodeBuffer() *EncodeBuffer { return ffirert.GetEncodeBuffer() }
+
+`
+
+// goSharedLenient keeps the lenient decoding errors of a package that uses
+// the shared runtime; errors.Is matches them across schema packages.
+const goSharedLenient = `// FieldError is a field that a lenient decode could not read.
+type FieldError = ffirert.FieldError
+
+var (
+	// ErrTruncated reports a field that runs past the end of the data.
+	ErrTruncated = ffirert.ErrTruncated
+
+	// ErrNotDecoded reports a field after a failed field whose size is
+	// not fixed, since where the field starts is then unknown.
+	ErrNotDecoded = ffirert.ErrNotDecoded
+)
+`
+
+// GenerateGoRuntime returns the Go shared runtime as package pkg. It is
+// the encode buffer pool and lenient decoding that packages generated
+// without GoOptions.SharedRuntime define themselves, with the internal
+// helpers exported.
+func GenerateGoRuntime(pkg string) ([]byte, error) {
+	exported := make([]string, 0, 2*len(goRuntimeNames))
+	for name, export := range goRuntimeNames {
+		exported = append(exported, name, export)
+	}
+	code := "// Code generated by ffire. DO NOT EDIT.\n\n" +
+		"// Package " + pkg + " is the runtime shared by the packages ffire generates\n" +
+		"// with a shared runtime.\n" +
+		"package " + pkg + "\n\n" +
+		"import (\n\"errors\"\n\"sync\"\n)\n\n" +
+		strings.NewReplacer(exported...).Replace(goEncodeBufferRuntime+goDecodeErrorRuntime()+goLenientRuntime)
+	return format.Source([]byte(code))
+}
+
+// checkSharedRuntime rejects a shared runtime for languages without one.
+func checkSharedRuntime(config *PackageConfig) error {
+	if config.SharedRuntime == "" {
+		return nil
+	}
+	if lang := canonicalLanguage(config.Language); !sharedRuntimeLanguages[lang] {
+		return fmt.Errorf("--shared-runtime is not supported for %s (supported: go)", config.Language)
+	}
+	return nil
+}
+
+// GenerateSharedRuntime writes the shared runtime for lang to dir, as
+// package pkg (DefaultRuntimePackage if empty). Packages generated with
+// PackageConfig.SharedRuntime set to its import path use it.
+func GenerateSharedRuntime(lang, dir, pkg string) error {
+	pkg = valueOr(pkg, DefaultRuntimePackage)
+	switch canonicalLanguage(lang) {
+	case "go":
+		if !token.IsIdentifier(pkg) {
+			return fmt.Errorf("invalid Go package name %q", pkg)
+		}
+		code, err := GenerateGoRuntime(pkg)
+		if err != nil {
+			return fmt.Errorf("failed to generate Go runtime: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		return os.WriteFile(filepath.Join(dir, pkg+".go"), code, 0644)
+	default:
+		return fmt.Errorf("no shared runtime for %s (supported: go)", lang)
+	}
+}
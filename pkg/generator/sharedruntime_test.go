@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func TestGenerateGoSharedRuntime(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(lenientTestSchema))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	code, err := GenerateGoWithOptions(s, GoOptions{SharedRuntime: "example.com/app/ffirert"})
+	if err != nil {
+		t.Fatalf("GenerateGoWithOptions failed: %v", err)
+	}
+	src := string(code)
+
+	for _, want := range []string{
+		`ffirert "example.com/app/ffirert"`,
+		"type EncodeBuffer = ffirert.EncodeBuffer",
+		"type FieldError = ffirert.FieldError",
+		"buf = ffirert.Grow(buf, v.EncodedSize())",
+		"errs := ffirert.DecodeLenient(data, &pos, fields)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"func ffireGrow(", "func decodeLenient(", "var encodeBufferPool", `"sync"`, `"errors"`} {
+		if strings.Contains(src, unwanted) {
+			t.Errorf("generated code defines %q, which the shared runtime provides", unwanted)
+		}
+	}
+
+	rt, err := GenerateGoRuntime("ffirert")
+	if err != nil {
+		t.Fatalf("GenerateGoRuntime failed: %v", err)
+	}
+	for _, want := range []string{"package ffirert\n", "func Grow(", "type LenientField struct", "func DecodeLenient(", "func GetEncodeBuffer("} {
+		if !strings.Contains(string(rt), want) {
+			t.Errorf("runtime missing %q", want)
+		}
+	}
+}
+
+// TestGoSharedRuntimeBuilds generates two schema packages against one
+// shared runtime and checks that they share its pool and errors.
+func TestGoSharedRuntimeBuilds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping: builds generated Go code")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	tmpDir := t.TempDir()
+	if err := GenerateSharedRuntime("go", filepath.Join(tmpDir, "rt"), "rt"); err != nil {
+		t.Fatalf("GenerateSharedRuntime failed: %v", err)
+	}
+	for _, src := range []string{lenientTestSchema, "package points\n\ntype Point struct {\n\tX int32\n\tY int32\n}\n"} {
+		s, err := parser.ParseBytes([]byte(src))
+		if err != nil {
+			t.Fatalf("parse failed: %v", err)
+		}
+		config := &PackageConfig{
+			Schema:        s,
+			Language:      "go",
+			OutputDir:     filepath.Join(tmpDir, s.Package),
+			SharedRuntime: "shared/rt",
+		}
+		if err := GeneratePackage(config); err != nil {
+			t.Fatalf("GeneratePackage(%s) failed: %v", s.Package, err)
+		}
+	}
+
+	files := map[string]string{
+		"go.mod": "module shared\n\ngo 1.21\n",
+		"main.go": `package main
+
+import (
+	"errors"
+	"fmt"
+
+	"shared/logs"
+	"shared/points"
+)
+
+func main() {
+	b := points.GetEncodeBuffer()
+	b.B = points.PointMessage{X: 1, Y: 2}.AppendTo(b.B)
+	fmt.Println(len(b.B))
+	b.Release()
+	var _ *logs.EncodeBuffer = points.GetEncodeBuffer()
+
+	_, errs := logs.DecodeEntryMessageLenient(nil)
+	_, perrs := points.DecodePointMessageLenient(nil)
+	fmt.Println(errors.Is(errs[0], points.ErrTruncated), errors.Is(perrs[0], logs.ErrTruncated))
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = tmpDir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	if want := "8\ntrue true\n"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestSharedRuntimeErrors(t *testing.T) {
+	config := &PackageConfig{Schema: &schema.Schema{Package: "audio"}, Language: "rust", SharedRuntime: "audio_rt"}
+	if err := checkSharedRuntime(config); err == nil || !strings.Contains(err.Error(), "not supported for rust") {
+		t.Errorf("checkSharedRuntime(rust) = %v, want unsupported", err)
+	}
+	if err := GenerateSharedRuntime("kotlin", t.TempDir(), ""); err == nil {
+		t.Error("GenerateSharedRuntime(kotlin) succeeded, want an error")
+	}
+	if err := GenerateSharedRuntime("go", t.TempDir(), "ffire-rt"); err == nil || !strings.Contains(err.Error(), "invalid Go package name") {
+		t.Errorf("GenerateSharedRuntime with package ffire-rt = %v, want invalid name", err)
+	}
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cppSIMDLanguages are the languages whose packages are built on the
+// generated C++ header, so --cpp-simd changes their decoders.
+var cppSIMDLanguages = []string{"c", "cpp", "c++", "swift", "dart", "csharp", "zig", "godot"}
+
+// checkCppSIMD reports settings that --cpp-simd cannot work with.
+func checkCppSIMD(config *PackageConfig) error {
+	if !config.CppSIMD {
+		return nil
+	}
+	if !contains(cppSIMDLanguages, strings.ToLower(config.Language)) {
+		return fmt.Errorf("--cpp-simd needs the C++ core, which %s packages do not use", config.Language)
+	}
+	return nil
+}
+
+// cppSIMDIncludes selects the instruction set for the --cpp-simd helpers.
+// Defining FFIRE_NO_SIMD before including the header forces the scalar
+// fallbacks, which check exactly the same things.
+const cppSIMDIncludes = `// SIMD paths (--cpp-simd); define FFIRE_NO_SIMD for the scalar fallbacks
+#if !defined(FFIRE_NO_SIMD) && (defined(__SSE2__) || defined(_M_X64))
+#include <emmintrin.h>
+#define FFIRE_SIMD_SSE2 1
+#elif !defined(FFIRE_NO_SIMD) && (defined(__aarch64__) || defined(_M_ARM64))
+#include <arm_neon.h>
+#define FFIRE_SIMD_NEON 1
+#endif
+
+`
+
+// cppSIMDHelpers are the UTF-8 and bool checks of the C++ decoder. With
+// --cpp-simd each scans 16 bytes per step with SSE2 or NEON and finishes
+// the tail a byte at a time; without it, or without SIMD on the target,
+// it checks the whole input a byte at a time.
+const cppSIMDHelpers = `namespace simd {
+
+#if defined(FFIRE_SIMD_SSE2)
+inline const char* isa() { return "sse2"; }
+#elif defined(FFIRE_SIMD_NEON)
+inline const char* isa() { return "neon"; }
+#else
+inline const char* isa() { return "scalar"; }
+#endif
+
+// ascii_prefix returns the length of the run of ASCII bytes starting at p.
+inline size_t ascii_prefix(const uint8_t* p, size_t n) {
+    size_t i = 0;
+#if defined(FFIRE_SIMD_SSE2)
+    for (; i + 16 <= n; i += 16) {
+        __m128i v = _mm_loadu_si128(reinterpret_cast<const __m128i*>(p + i));
+        if (_mm_movemask_epi8(v) != 0) break;
+    }
+#elif defined(FFIRE_SIMD_NEON)
+    for (; i + 16 <= n; i += 16) {
+        if (vmaxvq_u8(vld1q_u8(p + i)) >= 0x80) break;
+    }
+#endif
+    while (i < n && p[i] < 0x80) i++;
+    return i;
+}
+
+// valid_utf8 reports whether p[0..n) is well-formed UTF-8: no overlong
+// forms, surrogates or code points above U+10FFFF.
+inline bool valid_utf8(const uint8_t* p, size_t n) {
+    size_t i = 0;
+    while (true) {
+        i += ascii_prefix(p + i, n - i);
+        if (i == n) return true;
+        uint8_t c = p[i];
+        size_t len;
+        uint32_t cp;
+        if (c >= 0xC2 && c <= 0xDF) {
+            len = 2;
+            cp = c & 0x1F;
+        } else if (c >= 0xE0 && c <= 0xEF) {
+            len = 3;
+            cp = c & 0x0F;
+        } else if (c >= 0xF0 && c <= 0xF4) {
+            len = 4;
+            cp = c & 0x07;
+        } else {
+            return false;
+        }
+        if (n - i < len) return false;
+        for (size_t k = 1; k < len; k++) {
+            if ((p[i + k] & 0xC0) != 0x80) return false;
+            cp = (cp << 6) | (p[i + k] & 0x3F);
+        }
+        if (len == 3 && (cp < 0x800 || (cp >= 0xD800 && cp <= 0xDFFF))) return false;
+        if (len == 4 && (cp < 0x10000 || cp > 0x10FFFF)) return false;
+        i += len;
+    }
+}
+
+// valid_bools reports whether every byte of p[0..n) is 0x00 or 0x01.
+inline bool valid_bools(const uint8_t* p, size_t n) {
+    size_t i = 0;
+#if defined(FFIRE_SIMD_SSE2)
+    const __m128i one = _mm_set1_epi8(1);
+    for (; i + 16 <= n; i += 16) {
+        __m128i v = _mm_loadu_si128(reinterpret_cast<const __m128i*>(p + i));
+        if (_mm_movemask_epi8(_mm_cmpeq_epi8(_mm_max_epu8(v, one), one)) != 0xFFFF) return false;
+    }
+#elif defined(FFIRE_SIMD_NEON)
+    for (; i + 16 <= n; i += 16) {
+        if (vmaxvq_u8(vld1q_u8(p + i)) > 1) return false;
+    }
+#endif
+    for (; i < n; i++) {
+        if (p[i] > 1) return false;
+    }
+    return true;
+}
+
+} // namespace simd
+
+`
@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func simdTestSchema() *schema.Schema {
+	return &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Rec", TargetType: &schema.StructType{
+				Name: "Rec",
+				Fields: []schema.Field{
+					{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+					{Name: "Flags", Type: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "bool"}}},
+				},
+			}},
+		},
+	}
+}
+
+func TestGenerateCppSIMD(t *testing.T) {
+	plain, err := GenerateCpp(simdTestSchema())
+	if err != nil {
+		t.Fatalf("GenerateCpp failed: %v", err)
+	}
+	// The default decoder makes the same checks without SIMD
+	for _, unwanted := range []string{"#include <emmintrin.h>", "read_bulk_bool"} {
+		if strings.Contains(string(plain), unwanted) {
+			t.Errorf("default output contains %q", unwanted)
+		}
+	}
+	for _, want := range []string{"simd::valid_utf8(data + pos, len)", "throw DecodeError(DecodeErrorCode::InvalidValue, \"invalid bool\");"} {
+		if !strings.Contains(string(plain), want) {
+			t.Errorf("default output lacks %q", want)
+		}
+	}
+
+	code, err := GenerateCppWithOptions(simdTestSchema(), CppOptions{SIMD: true})
+	if err != nil {
+		t.Fatalf("GenerateCppWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"#include <emmintrin.h>",
+		"#include <arm_neon.h>",
+		"simd::valid_utf8(data + pos, len)",
+		"dec.read_bulk_bool(result.Flags, len);",
+		"throw DecodeError(DecodeErrorCode::InvalidValue, \"invalid bool\");",
+	} {
+		if !strings.Contains(string(code), want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+}
+
+// TestCppSIMDDecode compiles the SIMD header with and without FFIRE_NO_SIMD
+// and checks that both accept valid input and reject the same bad input.
+func TestCppSIMDDecode(t *testing.T) {
+	if _, err := exec.LookPath("g++"); err != nil {
+		t.Skip("g++ not installed")
+	}
+	code, err := GenerateCppWithOptions(simdTestSchema(), CppOptions{SIMD: true})
+	if err != nil {
+		t.Fatalf("GenerateCppWithOptions failed: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.hpp"), code, 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := `#include "test.hpp"
+#include <cstdio>
+
+static bool rejects(const std::string& name, std::vector<bool> flags, int corrupt) {
+    test::RecMessage m;
+    m.Name = name;
+    m.Flags = flags;
+    auto data = test::encode_rec_message(m);
+    if (corrupt >= 0) data[corrupt] = 0x02;
+    try {
+        test::decode_rec_message(data);
+        return false;
+    } catch (const std::exception&) {
+        return true;
+    }
+}
+
+int main() {
+    std::vector<bool> flags(40, true);
+    std::string ok = "a long enough ASCII prefix, then h\xc3\xa9llo \xf0\x9f\x98\x80";
+    if (rejects(ok, flags, -1)) return 1;
+    const char* bad[] = {"\xc0\x80", "\xed\xa0\x80", "\xf4\x90\x80\x80", "\xe2\x82", "0123456789abcdef\xff"};
+    for (const char* b : bad) {
+        if (!rejects(b, flags, -1)) return 2;
+    }
+    // Layout: Flags count, 40 bools, Name; corrupt one inside the SIMD block
+    if (!rejects("x", flags, 2 + 20)) return 3;
+    std::puts("ok");
+    return 0;
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "main.cpp"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, variant := range [][]string{{"simd"}, {"scalar", "-DFFIRE_NO_SIMD"}} {
+		bin := filepath.Join(dir, variant[0])
+		args := append([]string{"-std=c++17", "-O2", "-o", bin, filepath.Join(dir, "main.cpp")}, variant[1:]...)
+		if out, err := exec.Command("g++", args...).CombinedOutput(); err != nil {
+			t.Fatalf("g++ (%s) failed: %v\n%s", variant[0], err, out)
+		}
+		if out, err := exec.Command(bin).CombinedOutput(); err != nil {
+			t.Errorf("%s: %v\n%s", variant[0], err, out)
+		}
+	}
+}
+
+func TestCheckCppSIMD(t *testing.T) {
+	tests := []struct {
+		config PackageConfig
+		want   string
+	}{
+		{PackageConfig{Language: "cpp", CppSIMD: true}, ""},
+		{PackageConfig{Language: "swift", CppSIMD: true}, ""},
+		{PackageConfig{Language: "go", CppSIMD: true}, "C++ core"},
+		{PackageConfig{Language: "python", CppSIMD: true}, "C++ core"},
+		{PackageConfig{Language: "go"}, ""},
+	}
+	for _, tt := range tests {
+		err := checkCppSIMD(&tt.config)
+		if tt.want == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.config.Language, err)
+		}
+		if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("%s: err = %v, want it to mention %q", tt.config.Language, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package generator
+
+import "github.com/shaban/ffire/pkg/schema"
+
+// sortStructsByDependency returns structs ordered so that every struct comes
+// after the structs its fields use, directly or through arrays, maps and
+// union variants. Languages that need complete types before use (C++,
+// ctypes, koffi) emit definitions in this order, and the others follow it
+// so every generator lists types the same way.
+//
+// The order depends only on the input: structs are visited in input order
+// and their dependencies in field order, so a schema always generates the
+// same output. Structs outside the input are not dependencies. A cycle,
+// which only an optional self-reference can form, is broken at the struct
+// visited first.
+func sortStructsByDependency(structs []*schema.StructType) []*schema.StructType {
+	byName := make(map[string]*schema.StructType, len(structs))
+	for _, st := range structs {
+		if _, dup := byName[st.Name]; !dup {
+			byName[st.Name] = st
+		}
+	}
+
+	sorted := make([]*schema.StructType, 0, len(byName))
+	done := make(map[string]bool, len(byName))
+	visiting := make(map[string]bool)
+	var visit func(st *schema.StructType)
+	visit = func(st *schema.StructType) {
+		if done[st.Name] || visiting[st.Name] {
+			return
+		}
+		visiting[st.Name] = true
+		for _, field := range st.Fields {
+			for _, dep := range structDependencies(field.Type) {
+				if depType, ok := byName[dep]; ok {
+					visit(depType)
+				}
+			}
+		}
+		visiting[st.Name] = false
+		done[st.Name] = true
+		sorted = append(sorted, byName[st.Name])
+	}
+	for _, st := range structs {
+		visit(st)
+	}
+	return sorted
+}
+
+// structDependencies returns the names of the structs a field of type t
+// needs defined first, in the order they appear in t.
+func structDependencies(t schema.Type) []string {
+	switch typ := t.(type) {
+	case *schema.StructType:
+		return []string{typ.Name}
+	case *schema.ArrayType:
+		return structDependencies(typ.ElementType)
+	case *schema.MapType:
+		return append(structDependencies(typ.KeyType), structDependencies(typ.ValueType)...)
+	case *schema.UnionType:
+		var deps []string
+		for _, v := range typ.Variants {
+			deps = append(deps, structDependencies(v)...)
+		}
+		return deps
+	}
+	return nil
+}
+
+// topSortStructTypes returns the struct types among types in dependency
+// order (see sortStructsByDependency).
+func topSortStructTypes(types []schema.Type) []*schema.StructType {
+	var structs []*schema.StructType
+	for _, typ := range types {
+		if st, ok := typ.(*schema.StructType); ok {
+			structs = append(structs, st)
+		}
+	}
+	return sortStructsByDependency(structs)
+}
+
+// schemaStructs returns the structs of root messages followed by the other
+// declared structs, each once. It is the input order generators sort by.
+func schemaStructs(s *schema.Schema) []*schema.StructType {
+	var structs []*schema.StructType
+	seen := make(map[string]bool)
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*schema.StructType); ok && !seen[st.Name] {
+			seen[st.Name] = true
+			structs = append(structs, st)
+		}
+	}
+	for _, typ := range s.Types {
+		if st, ok := typ.(*schema.StructType); ok && !seen[st.Name] {
+			seen[st.Name] = true
+			structs = append(structs, st)
+		}
+	}
+	return structs
+}
@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func structNames(structs []*schema.StructType) string {
+	names := make([]string, len(structs))
+	for i, st := range structs {
+		names[i] = st.Name
+	}
+	return strings.Join(names, " ")
+}
+
+func TestSortStructsByDependency(t *testing.T) {
+	point := &schema.StructType{Name: "Point", Fields: []schema.Field{{Name: "X", Type: &schema.PrimitiveType{Name: "float32"}}}}
+	line := &schema.StructType{Name: "Line", Fields: []schema.Field{
+		{Name: "From", Type: point},
+		{Name: "To", Type: point},
+	}}
+	optPoint := *point
+	optPoint.Optional = true
+	shape := &schema.UnionType{Name: "Shape", Variants: []schema.Type{line, &schema.PrimitiveType{Name: "string"}}}
+	drawing := &schema.StructType{Name: "Drawing", Fields: []schema.Field{
+		{Name: "Shapes", Type: &schema.ArrayType{ElementType: shape}},
+		{Name: "Labels", Type: &schema.MapType{KeyType: &schema.PrimitiveType{Name: "string"}, ValueType: &optPoint}},
+	}}
+	node := &schema.StructType{Name: "Node"}
+	optNode := *node
+	optNode.Optional = true
+	node.Fields = []schema.Field{{Name: "Next", Type: &optNode}}
+
+	input := []*schema.StructType{drawing, node, line, point}
+	want := "Point Line Drawing Node"
+	for i := 0; i < 10; i++ {
+		if got := structNames(sortStructsByDependency(input)); got != want {
+			t.Fatalf("run %d: order = %s, want %s", i, got, want)
+		}
+	}
+
+	// Structs outside the input are not dependencies
+	if got := structNames(sortStructsByDependency([]*schema.StructType{line})); got != "Line" {
+		t.Errorf("order = %s, want Line", got)
+	}
+}
+
+func TestStructOrderAcrossLanguages(t *testing.T) {
+	// Drawing uses Line before Line is declared, and Line uses Point twice
+	src := `package dup
+
+type Drawing struct {
+	Lines  []Line
+	Origin Point
+}
+
+type Line struct {
+	From  Point
+	To    Point
+	Label string
+}
+
+type Point struct {
+	X float32
+	Y float32
+}
+`
+	want := []string{"Point", "Line", "Drawing"}
+	declarations := map[string]func(name string) string{
+		"go":     func(n string) string { return "type " + n + " struct" },
+		"cpp":    func(n string) string { return "\nstruct " + n + " {" },
+		"rust":   func(n string) string { return "pub struct " + n + " {" },
+		"csharp": func(n string) string { return " " + n + "\n" },
+		"java":   func(n string) string { return "class " + n + " {" },
+		"kotlin": func(n string) string { return "data class " + n + "(" },
+	}
+	generate := map[string]func(*schema.Schema) ([]byte, error){
+		"go":     GenerateGo,
+		"cpp":    GenerateCpp,
+		"rust":   generateRustNative,
+		"csharp": GenerateCSharp,
+		"java":   GenerateJava,
+		"kotlin": generateKotlinTest,
+	}
+
+	for lang, gen := range generate {
+		s, err := parser.ParseBytes([]byte(src))
+		if err != nil {
+			t.Fatalf("ParseBytes failed: %v", err)
+		}
+		code, err := gen(s)
+		if err != nil {
+			t.Fatalf("%s: generate failed: %v", lang, err)
+		}
+		last := -1
+		for _, name := range want {
+			decl := name
+			if name == "Drawing" {
+				decl = name + "Message"
+			}
+			pos := strings.Index(string(code), declarations[lang](decl))
+			if pos < 0 {
+				t.Fatalf("%s: no declaration of %s", lang, decl)
+			}
+			if pos < last {
+				t.Errorf("%s: %s is declared before the types it uses", lang, decl)
+			}
+			last = pos
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package generator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Unity packages (--csharp-unity) hold the C# generated with
+// CSharpOptions.Unity twice over: as a Unity Package Manager package, with
+// an assembly definition under Runtime/, and as <Namespace>.unitypackage,
+// the archive Assets > Import Package imports into Assets/<Namespace>/.
+// Unity finds assets by the GUIDs in their .meta files, so the GUIDs are
+// derived from the package and path, and regenerating a package keeps the
+// references projects hold to it.
+
+// unityVersion is the oldest Unity release the packages support: the
+// first LTS release with .NET Standard 2.1, and so Span<T>, and C# 9.
+const unityVersion = "2021.3"
+
+// unityAsset is a file or folder of a Unity package, at a slash-separated
+// path relative to the package root.
+type unityAsset struct {
+	path    string
+	content []byte // Nil for folders
+	meta    string // Importer section of the .meta file
+}
+
+func generateUnityPackage(config *PackageConfig) error {
+	code, err := GenerateCSharpWithOptions(config.Schema, CSharpOptions{Unity: true})
+	if err != nil {
+		return fmt.Errorf("failed to generate C# code: %w", err)
+	}
+
+	outDir := config.langDir(config.Schema.Package)
+	name := ToPascalCase(config.Schema.Package)
+	assets := []unityAsset{
+		{path: "Runtime", meta: unityFolderImporter},
+		{path: "Runtime/" + name + ".asmdef", content: unityAssemblyDefinition(name), meta: unityAsmdefImporter},
+		{path: "Runtime/Generated.cs", content: code, meta: unityScriptImporter},
+	}
+	upm := append([]unityAsset{{path: "package.json", content: unityPackageManifest(config), meta: unityManifestImporter}}, assets...)
+	for _, a := range upm {
+		if err := writeUnityAsset(outDir, unityPackageName(config), a); err != nil {
+			return err
+		}
+	}
+	config.infof("✓ Generated Unity package: %s\n", outDir)
+
+	archive, err := unityArchive(name, assets)
+	if err != nil {
+		return fmt.Errorf("failed to build %s.unitypackage: %w", name, err)
+	}
+	archivePath := filepath.Join(outDir, name+".unitypackage")
+	if err := os.WriteFile(archivePath, archive, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", archivePath, err)
+	}
+	config.infof("✓ Generated %s\n", archivePath)
+
+	config.infof("\n✅ Unity package ready at: %s\n", outDir)
+	config.infof("   Add it with Package Manager > Add package from disk (package.json), or\n")
+	config.infof("   import %s.unitypackage with Assets > Import Package. Unity %s or later.\n", name, unityVersion)
+	return nil
+}
+
+// unityPackageName returns the Package Manager name of the package, which
+// must be lowercase and in reverse domain notation.
+func unityPackageName(config *PackageConfig) string {
+	return "com.ffire." + strings.ToLower(config.Namespace)
+}
+
+func unityPackageManifest(config *PackageConfig) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{\n")
+	fmt.Fprintf(buf, "  \"name\": %q,\n", unityPackageName(config))
+	fmt.Fprintf(buf, "  \"version\": %q,\n", config.Version)
+	fmt.Fprintf(buf, "  \"displayName\": %q,\n", ToPascalCase(config.Schema.Package)+" (ffire)")
+	fmt.Fprintf(buf, "  \"description\": %q,\n", "ffire serialization code for the "+config.Schema.Package+" schema")
+	if license := spdxLicense(config); license != "" {
+		fmt.Fprintf(buf, "  \"license\": %q,\n", license)
+	}
+	fmt.Fprintf(buf, "  \"unity\": %q\n", unityVersion)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// unityAssemblyDefinition returns the assembly definition of the generated
+// code. The encoders use unsafe code, which IL2CPP supports, and nothing
+// references UnityEngine, so the assembly also works in editor tools and
+// dedicated servers.
+func unityAssemblyDefinition(name string) []byte {
+	return []byte(fmt.Sprintf(`{
+    "name": %q,
+    "rootNamespace": %q,
+    "references": [],
+    "includePlatforms": [],
+    "excludePlatforms": [],
+    "allowUnsafeCode": true,
+    "overrideReferences": false,
+    "precompiledReferences": [],
+    "autoReferenced": true,
+    "defineConstraints": [],
+    "versionDefines": [],
+    "noEngineReferences": true
+}
+`, name, name))
+}
+
+// unityGUID returns the GUID of the asset at path in package pkg.
+func unityGUID(pkg, path string) string {
+	sum := md5.Sum([]byte(pkg + "/" + path))
+	return hex.EncodeToString(sum[:])
+}
+
+// unityMeta returns the .meta file of an asset.
+func unityMeta(guid, importer string) []byte {
+	return []byte("fileFormatVersion: 2\nguid: " + guid + "\n" + importer)
+}
+
+const unityFolderImporter = `folderAsset: yes
+DefaultImporter:
+  externalObjects: {}
+  userData:
+  assetBundleName:
+  assetBundleVariant:
+`
+
+const unityScriptImporter = `MonoImporter:
+  externalObjects: {}
+  serializedVersion: 2
+  defaultReferences: []
+  executionOrder: 0
+  icon: {instanceID: 0}
+  userData:
+  assetBundleName:
+  assetBundleVariant:
+`
+
+const unityAsmdefImporter = `AssemblyDefinitionImporter:
+  externalObjects: {}
+  userData:
+  assetBundleName:
+  assetBundleVariant:
+`
+
+const unityManifestImporter = `PackageManifestImporter:
+  externalObjects: {}
+  userData:
+  assetBundleName:
+  assetBundleVariant:
+`
+
+// writeUnityAsset writes an asset of package pkg and its .meta file under
+// dir.
+func writeUnityAsset(dir, pkg string, a unityAsset) error {
+	p := filepath.Join(dir, filepath.FromSlash(a.path))
+	if a.content == nil {
+		if err := os.MkdirAll(p, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(p, a.content, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", p, err)
+		}
+	}
+	if err := os.WriteFile(p+".meta", unityMeta(unityGUID(pkg, a.path), a.meta), 0644); err != nil {
+		return fmt.Errorf("failed to write %s.meta: %w", p, err)
+	}
+	return nil
+}
+
+// unityArchive returns a .unitypackage that imports assets into
+// Assets/<name>/. It is a gzipped tar with a directory per asset, named by
+// its GUID, holding the asset, its .meta file and its path in the project.
+// Entries have no timestamps, so the archive is reproducible.
+func unityArchive(name string, assets []unityAsset) ([]byte, error) {
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(zw)
+	pkg := "Assets/" + name
+	for _, a := range append([]unityAsset{{path: "", meta: unityFolderImporter}}, assets...) {
+		guid := unityGUID(pkg, a.path)
+		files := map[string][]byte{
+			"pathname":   []byte(path.Join(pkg, a.path)),
+			"asset.meta": unityMeta(guid, a.meta),
+		}
+		if a.content != nil {
+			files["asset"] = a.content
+		}
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: guid + "/", Mode: 0755}); err != nil {
+			return nil, err
+		}
+		for _, name := range []string{"asset", "asset.meta", "pathname"} {
+			content, ok := files[name]
+			if !ok {
+				continue
+			}
+			if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: guid + "/" + name, Mode: 0644, Size: int64(len(content))}); err != nil {
+				return nil, err
+			}
+			if _, err := tw.Write(content); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+package generator
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const unityTestSchema = `package demo
+
+type Reading struct {
+	Gain  float32
+	Level float64
+	Name  string
+}
+
+type ReadingList []Reading
+`
+
+func TestGenerateCSharpUnity(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(unityTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	code, err := GenerateCSharpWithOptions(s, CSharpOptions{Unity: true})
+	if err != nil {
+		t.Fatalf("GenerateCSharpWithOptions failed: %v", err)
+	}
+	// Unity 2021.3 has C# 9 and .NET Standard 2.1: no ref struct views,
+	// which need scoped, and no BinaryPrimitives float reads.
+	for _, unsupported := range []string{"ref struct", "scoped ", "ReadSingleLittleEndian", "ReadDoubleLittleEndian"} {
+		if strings.Contains(string(code), unsupported) {
+			t.Errorf("Unity code uses %q", unsupported)
+		}
+	}
+	if !strings.Contains(string(code), "BitConverter.Int32BitsToSingle(") {
+		t.Error("Unity code does not read float32 through BitConverter")
+	}
+}
+
+func TestGenerateUnityPackage(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(unityTestSchema))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	outDir := t.TempDir()
+	config := &PackageConfig{Schema: s, Language: "csharp", OutputDir: outDir, Namespace: "Demo", Version: "1.0.0", CSharpUnity: true}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+
+	pkgDir := config.langDir(s.Package)
+	for _, name := range []string{"package.json", "package.json.meta", "Runtime.meta", "Runtime/Demo.asmdef.meta", "Runtime/Generated.cs", "Runtime/Generated.cs.meta"} {
+		if _, err := os.Stat(filepath.Join(pkgDir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	asmdef, err := os.ReadFile(filepath.Join(pkgDir, "Runtime", "Demo.asmdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(asmdef), `"allowUnsafeCode": true`) {
+		t.Errorf("assembly definition does not allow unsafe code:\n%s", asmdef)
+	}
+	meta, err := os.ReadFile(filepath.Join(pkgDir, "Runtime", "Generated.cs.meta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "guid: " + unityGUID("com.ffire.demo", "Runtime/Generated.cs") + "\n"; !strings.Contains(string(meta), want) {
+		t.Errorf("Generated.cs.meta does not have a stable GUID:\n%s", meta)
+	}
+
+	archive, err := os.ReadFile(filepath.Join(pkgDir, "Demo.unitypackage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	var pathnames []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(hdr.Name, "/pathname") {
+			b, _ := io.ReadAll(tr)
+			pathnames = append(pathnames, string(b))
+		}
+	}
+	got := strings.Join(pathnames, ",")
+	if want := "Assets/Demo,Assets/Demo/Runtime,Assets/Demo/Runtime/Demo.asmdef,Assets/Demo/Runtime/Generated.cs"; got != want {
+		t.Errorf("unitypackage paths = %s, want %s", got, want)
+	}
+	if err := GeneratePackage(config); err != nil {
+		t.Fatalf("GeneratePackage failed: %v", err)
+	}
+	if regenerated, err := os.ReadFile(filepath.Join(pkgDir, "Demo.unitypackage")); err != nil || !bytes.Equal(archive, regenerated) {
+		t.Errorf("regenerated unitypackage differs (err %v)", err)
+	}
+
+	config.Language = "java"
+	if err := GeneratePackage(config); err == nil || !strings.Contains(err.Error(), "--csharp-unity is not supported for java") {
+		t.Errorf("GeneratePackage(java) = %v, want unsupported error", err)
+	}
+}
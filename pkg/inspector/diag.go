@@ -0,0 +1,264 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shaban/ffire/pkg/errors"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Diagnostic renders a payload in CBOR diagnostic notation (RFC 8949 §8).
+//
+// Unlike JSON it keeps the information needed to reproduce the exact bytes:
+// floats carry their width as an encoding indicator (1.5_2 for float32,
+// 1.5_3 for float64), -0.0, NaN and the infinities are written out, NaNs
+// with a non-default payload get their raw bits in a comment, and strings
+// that are not valid UTF-8 are shown as byte strings (h'...'). Struct fields
+// appear in wire order, keyed by their JSON name. Map entries also appear in
+// wire order, with keys in their native type. Enums are written as their
+// integer value with the constant name in a comment, and unions as a map
+// with one entry keyed by the variant name.
+func Diagnostic(s *schema.Schema, messageName string, data []byte) (string, error) {
+	var messageType *schema.MessageType
+	for i := range s.Messages {
+		if s.Messages[i].Name == messageName {
+			messageType = &s.Messages[i]
+			break
+		}
+	}
+	if messageType == nil {
+		return "", errors.Newf(errors.ErrMessageNotFound, "message type %s not found in schema", messageName)
+	}
+
+	// Validate up front so rendering can read without bounds checks;
+	// invalid text is shown rather than rejected
+	if err := fixture.ValidateLayout(s, messageName, data); err != nil {
+		return "", err
+	}
+
+	d := &diagWriter{data: data}
+	d.value(messageType.TargetType, 0)
+	d.buf.WriteByte('\n')
+	return d.buf.String(), nil
+}
+
+type diagWriter struct {
+	data []byte
+	pos  int
+	buf  bytes.Buffer
+}
+
+func (d *diagWriter) value(typ schema.Type, indent int) {
+	if typ.IsOptional() {
+		present := d.data[d.pos]
+		d.pos++
+		if present == 0x00 {
+			d.buf.WriteString("null")
+			return
+		}
+	}
+	d.content(typ, indent)
+}
+
+// content renders a value whose presence, if it is optional, has been
+// read.
+func (d *diagWriter) content(typ schema.Type, indent int) {
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		d.primitive(t)
+	case *schema.EnumType:
+		start := d.buf.Len()
+		d.primitive(&schema.PrimitiveType{Name: t.Base})
+		v, _ := strconv.ParseInt(d.buf.String()[start:], 10, 64)
+		name, _ := t.Lookup(v)
+		d.buf.WriteString(" / " + name + " /")
+	case *schema.UnionType:
+		variant := t.Variants[d.data[d.pos]-1]
+		d.pos++
+		d.buf.WriteByte('{')
+		d.text(variant.TypeName())
+		d.buf.WriteString(": ")
+		d.value(variant, indent)
+		d.buf.WriteByte('}')
+	case *schema.ArrayType:
+		length := t.Length
+		if length == 0 {
+			length = d.length(t)
+		}
+		if length == 0 {
+			d.buf.WriteString("[]")
+			return
+		}
+		d.buf.WriteString("[\n")
+		for i := 0; i < length; i++ {
+			d.indent(indent + 1)
+			d.value(t.ElementType, indent+1)
+			if i < length-1 {
+				d.buf.WriteByte(',')
+			}
+			d.buf.WriteByte('\n')
+		}
+		d.indent(indent)
+		d.buf.WriteByte(']')
+	case *schema.MapType:
+		length := d.length(t)
+		if length == 0 {
+			d.buf.WriteString("{}")
+			return
+		}
+		d.buf.WriteString("{\n")
+		for i := 0; i < length; i++ {
+			d.indent(indent + 1)
+			d.value(t.KeyType, indent+1)
+			d.buf.WriteString(": ")
+			d.value(t.ValueType, indent+1)
+			if i < length-1 {
+				d.buf.WriteByte(',')
+			}
+			d.buf.WriteByte('\n')
+		}
+		d.indent(indent)
+		d.buf.WriteByte('}')
+	case *schema.StructType:
+		d.pos += schema.FramePrefixSize(t)
+		if len(t.Fields) == 0 {
+			d.buf.WriteString("{}")
+			return
+		}
+		bitmap := d.data[d.pos : d.pos+schema.BitmapSize(t)]
+		d.pos += len(bitmap)
+		bit := 0
+		d.buf.WriteString("{\n")
+		for i, field := range t.Fields {
+			d.indent(indent + 1)
+			d.text(field.JSONName())
+			d.buf.WriteString(": ")
+			switch {
+			case !t.Bitmap || !field.Type.IsOptional():
+				d.value(field.Type, indent+1)
+			case bitmap[bit/8]&(1<<(bit%8)) != 0:
+				d.content(field.Type, indent+1)
+			default:
+				d.buf.WriteString("null")
+			}
+			if field.Type.IsOptional() {
+				bit++
+			}
+			if i < len(t.Fields)-1 {
+				d.buf.WriteByte(',')
+			}
+			d.buf.WriteByte('\n')
+		}
+		d.indent(indent)
+		d.buf.WriteByte('}')
+	}
+}
+
+func (d *diagWriter) primitive(t *schema.PrimitiveType) {
+	switch t.Name {
+	case "bool":
+		d.buf.WriteString(strconv.FormatBool(d.data[d.pos] == 0x01))
+		d.pos++
+	case "int8":
+		d.buf.WriteString(strconv.Itoa(int(int8(d.data[d.pos]))))
+		d.pos++
+	case "int16":
+		d.buf.WriteString(strconv.Itoa(int(int16(binary.LittleEndian.Uint16(d.data[d.pos:])))))
+		d.pos += 2
+	case "int32":
+		d.buf.WriteString(strconv.Itoa(int(int32(binary.LittleEndian.Uint32(d.data[d.pos:])))))
+		d.pos += 4
+	case "int64":
+		d.buf.WriteString(strconv.FormatInt(int64(binary.LittleEndian.Uint64(d.data[d.pos:])), 10))
+		d.pos += 8
+	case "float32":
+		bits := binary.LittleEndian.Uint32(d.data[d.pos:])
+		d.pos += 4
+		d.float(float64(math.Float32frombits(bits)), 32, uint64(bits), bits != 0x7fc00000)
+	case "float64":
+		bits := binary.LittleEndian.Uint64(d.data[d.pos:])
+		d.pos += 8
+		d.float(math.Float64frombits(bits), 64, bits, bits != 0x7ff8000000000000)
+	case "string":
+		length := d.length(t)
+		raw := d.data[d.pos : d.pos+length]
+		d.pos += length
+		if utf8.Valid(raw) {
+			d.text(string(raw))
+		} else {
+			d.buf.WriteString("h'" + hex.EncodeToString(raw) + "' / invalid UTF-8 text /")
+		}
+	case "bytes":
+		length := d.length(t)
+		d.buf.WriteString("h'" + hex.EncodeToString(d.data[d.pos:d.pos+length]) + "'")
+		d.pos += length
+	}
+}
+
+// length reads the length prefix of typ: a uint16, or a uint32 if typ is
+// @large.
+func (d *diagWriter) length(typ schema.Type) int {
+	if schema.IsLarge(typ) {
+		n := int(binary.LittleEndian.Uint32(d.data[d.pos:]))
+		d.pos += 4
+		return n
+	}
+	n := int(binary.LittleEndian.Uint16(d.data[d.pos:]))
+	d.pos += 2
+	return n
+}
+
+// float writes v with its width indicator; bits and oddNaN are only used to
+// annotate NaN payloads, which are lost when a NaN is printed as "NaN".
+func (d *diagWriter) float(v float64, size int, bits uint64, oddNaN bool) {
+	indicator := "_2"
+	if size == 64 {
+		indicator = "_3"
+	}
+
+	switch {
+	case math.IsNaN(v):
+		d.buf.WriteString("NaN" + indicator)
+		if oddNaN {
+			fmt.Fprintf(&d.buf, " / 0x%0*x /", size/4, bits)
+		}
+		return
+	case math.IsInf(v, 1):
+		d.buf.WriteString("Infinity")
+	case math.IsInf(v, -1):
+		d.buf.WriteString("-Infinity")
+	default:
+		// Shortest representation that round-trips at this width, always with
+		// a fraction so it cannot be mistaken for an integer
+		s := strconv.FormatFloat(v, 'g', -1, size)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		} else if i := strings.IndexByte(s, 'e'); i >= 0 && !strings.Contains(s[:i], ".") {
+			s = s[:i] + ".0" + s[i:]
+		}
+		d.buf.WriteString(s)
+	}
+	d.buf.WriteString(indicator)
+}
+
+func (d *diagWriter) text(s string) {
+	// JSON string syntax is valid diagnostic notation; keep <, > and & as-is
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	d.buf.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
+
+func (d *diagWriter) indent(level int) {
+	d.buf.WriteString(strings.Repeat("  ", level))
+}
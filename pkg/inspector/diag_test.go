@@ -0,0 +1,145 @@
+package inspector
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+const diagSchema = `package test
+
+type Sample struct {
+	Name  string ` + "`json:\"name\"`" + `
+	Gain  float32
+	Level float64
+	Count *int32
+	Tags  []string
+}
+`
+
+func TestDiagnostic(t *testing.T) {
+	s, err := parser.ParseBytes([]byte(diagSchema))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var data []byte
+	data = append(data, 2, 0, 'h', 0xff) // not valid UTF-8
+	data = binary.LittleEndian.AppendUint32(data, 0x7fc00001)
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(math.Copysign(0, -1)))
+	data = append(data, 0)    // Count absent
+	data = append(data, 0, 0) // no tags
+
+	out, err := Diagnostic(s, "Sample", data)
+	if err != nil {
+		t.Fatalf("Diagnostic failed: %v", err)
+	}
+
+	want := `{
+  "name": h'68ff' / invalid UTF-8 text /,
+  "Gain": NaN_2 / 0x7fc00001 /,
+  "Level": -0.0_3,
+  "Count": null,
+  "Tags": []
+}
+`
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDiagnosticMap(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Config struct {\n\tLimits map[int8]string\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	data := []byte{2, 0, 0xff, 1, 0, 'a', 7, 0, 0}
+	out, err := Diagnostic(s, "Config", data)
+	if err != nil {
+		t.Fatalf("Diagnostic failed: %v", err)
+	}
+
+	want := `{
+  "Limits": {
+    -1: "a",
+    7: ""
+  }
+}
+`
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDiagnosticEnum(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Config struct {\n\tModes []Mode\n}\n\ntype Mode int16\n\nconst (\n\tOff Mode = iota - 1\n\tOn  Mode = 1\n)\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	data := []byte{2, 0, 0xff, 0xff, 1, 0}
+	out, err := Diagnostic(s, "Config", data)
+	if err != nil {
+		t.Fatalf("Diagnostic failed: %v", err)
+	}
+
+	want := `{
+  "Modes": [
+    -1 / Off /,
+    1 / On /
+  ]
+}
+`
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDiagnosticUnion(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Config struct {\n\tValues []Value\n}\n\ntype Value interface {\n\tstring | int16\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	data := []byte{2, 0, 2, 0xff, 0xff, 1, 1, 0, 'a'}
+	out, err := Diagnostic(s, "Config", data)
+	if err != nil {
+		t.Fatalf("Diagnostic failed: %v", err)
+	}
+
+	want := `{
+  "Values": [
+    {"int16": -1},
+    {"string": "a"}
+  ]
+}
+`
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDiagnosticBytes(t *testing.T) {
+	s, err := parser.ParseBytes([]byte("package test\n\ntype Config struct {\n\tData bytes\n\tEmpty bytes\n}\n"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	data := []byte{3, 0, 0xde, 0xad, 0x00, 0, 0}
+	out, err := Diagnostic(s, "Config", data)
+	if err != nil {
+		t.Fatalf("Diagnostic failed: %v", err)
+	}
+
+	want := `{
+  "Data": h'dead00',
+  "Empty": h''
+}
+`
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
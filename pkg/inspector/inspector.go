@@ -125,6 +125,12 @@ func inspectValue(data []byte, pos *int, typ schema.Type, path string, buf *byte
 		return inspectPrimitive(data, pos, t, path, buf, compact, indent, startPos)
 	case *schema.ArrayType:
 		return inspectArray(data, pos, t, path, buf, compact, indent, startPos)
+	case *schema.MapType:
+		return inspectMap(data, pos, t, path, buf, compact, indent, startPos)
+	case *schema.EnumType:
+		return inspectEnum(data, pos, t, path, buf, compact, indent, startPos)
+	case *schema.UnionType:
+		return inspectUnion(data, pos, t, path, buf, compact, indent, startPos)
 	case *schema.StructType:
 		return inspectStruct(data, pos, t, path, buf, compact, indent, startPos)
 	default:
@@ -213,17 +219,28 @@ func inspectPrimitive(data []byte, pos *int, typ *schema.PrimitiveType, path str
 		*pos += 8
 
 	case "string":
-		if *pos+1 >= len(data) {
-			return fmt.Errorf("unexpected end of data at offset %d", *pos)
+		length, err := readLength(data, pos, typ)
+		if err != nil {
+			return err
 		}
-		length := uint16(data[*pos]) | uint16(data[*pos+1])<<8
-		*pos += 2
 
 		if *pos+int(length) > len(data) {
 			return fmt.Errorf("string length %d exceeds remaining data at offset %d", length, *pos)
 		}
 		value := string(data[*pos : *pos+int(length)])
-		buf.WriteString(fmt.Sprintf("%s[%04x] %s: \"%s\" (string, %d bytes + 2 byte length)\n", indentStr, startPos, path, value, length))
+		buf.WriteString(fmt.Sprintf("%s[%04x] %s: \"%s\" (string, %d bytes + %d byte length)\n", indentStr, startPos, path, value, length, schema.LengthPrefixSize(typ)))
+		*pos += int(length)
+
+	case "bytes":
+		length, err := readLength(data, pos, typ)
+		if err != nil {
+			return err
+		}
+
+		if *pos+int(length) > len(data) {
+			return fmt.Errorf("bytes length %d exceeds remaining data at offset %d", length, *pos)
+		}
+		buf.WriteString(fmt.Sprintf("%s[%04x] %s: %x (bytes, %d bytes + %d byte length)\n", indentStr, startPos, path, data[*pos:*pos+int(length)], length, schema.LengthPrefixSize(typ)))
 		*pos += int(length)
 
 	default:
@@ -252,17 +269,21 @@ func inspectArray(data []byte, pos *int, typ *schema.ArrayType, path string, buf
 		}
 	}
 
-	// Array length
-	if *pos+1 >= len(data) {
-		return fmt.Errorf("unexpected end of data at offset %d", *pos)
-	}
-	length := uint16(data[*pos]) | uint16(data[*pos+1])<<8
-	*pos += 2
+	// Array length, implied by the schema for fixed-size arrays
+	length := typ.Length
+	if typ.Length > 0 {
+		buf.WriteString(fmt.Sprintf("%s[%04x] %s: array[%d] (fixed)\n", indentStr, startPos, path, length))
+	} else {
+		var err error
+		if length, err = readLength(data, pos, typ); err != nil {
+			return err
+		}
 
-	buf.WriteString(fmt.Sprintf("%s[%04x] %s: array[%d]\n", indentStr, startPos, path, length))
+		buf.WriteString(fmt.Sprintf("%s[%04x] %s: array[%d]\n", indentStr, startPos, path, length))
+	}
 
 	// Array elements
-	for i := 0; i < int(length); i++ {
+	for i := 0; i < length; i++ {
 		elemPath := fmt.Sprintf("%s[%d]", path, i)
 		if err := inspectValue(data, pos, typ.ElementType, elemPath, buf, compact, indent+1); err != nil {
 			return err
@@ -272,6 +293,123 @@ func inspectArray(data []byte, pos *int, typ *schema.ArrayType, path string, buf
 	return nil
 }
 
+func inspectEnum(data []byte, pos *int, typ *schema.EnumType, path string, buf *bytes.Buffer, compact bool, indent int, startPos int) error {
+	indentStr := strings.Repeat("  ", indent)
+
+	// Optional flag
+	if typ.Optional {
+		if *pos >= len(data) {
+			return fmt.Errorf("unexpected end of data at offset %d", *pos)
+		}
+		present := data[*pos]
+		*pos++
+
+		if present == 0x00 {
+			if !compact {
+				buf.WriteString(fmt.Sprintf("%s[%04x] %s: null (optional %s)\n", indentStr, startPos, path, typ.Name))
+			}
+			return nil
+		}
+	}
+
+	size := schema.PrimitiveSize(typ.Base)
+	if *pos+size > len(data) {
+		return fmt.Errorf("unexpected end of data at offset %d", *pos)
+	}
+	var value int64
+	for i := size - 1; i >= 0; i-- {
+		value = value<<8 | int64(data[*pos+i])
+	}
+	value = value << (64 - 8*size) >> (64 - 8*size) // Sign-extend
+	*pos += size
+
+	name, ok := typ.Lookup(value)
+	if !ok {
+		name = "<invalid>"
+	}
+	unit := "bytes"
+	if size == 1 {
+		unit = "byte"
+	}
+	buf.WriteString(fmt.Sprintf("%s[%04x] %s: %s (%s = %d, %s, %d %s)\n", indentStr, startPos, path, name, typ.Name, value, typ.Base, size, unit))
+	return nil
+}
+
+func inspectUnion(data []byte, pos *int, typ *schema.UnionType, path string, buf *bytes.Buffer, compact bool, indent int, startPos int) error {
+	indentStr := strings.Repeat("  ", indent)
+
+	// Optional flag
+	if typ.Optional {
+		if *pos >= len(data) {
+			return fmt.Errorf("unexpected end of data at offset %d", *pos)
+		}
+		present := data[*pos]
+		*pos++
+
+		if present == 0x00 {
+			if !compact {
+				buf.WriteString(fmt.Sprintf("%s[%04x] %s: null (optional %s)\n", indentStr, startPos, path, typ.Name))
+			}
+			return nil
+		}
+	}
+
+	// Tag
+	if *pos >= len(data) {
+		return fmt.Errorf("unexpected end of data at offset %d", *pos)
+	}
+	tag := int(data[*pos])
+	*pos++
+	if tag == 0 || tag > len(typ.Variants) {
+		return fmt.Errorf("invalid %s tag %d at offset %d", typ.Name, tag, startPos)
+	}
+
+	variant := typ.Variants[tag-1]
+	buf.WriteString(fmt.Sprintf("%s[%04x] %s: %s (%s tag %d)\n", indentStr, startPos, path, variant.TypeName(), typ.Name, tag))
+	return inspectValue(data, pos, variant, path+"."+variant.TypeName(), buf, compact, indent+1)
+}
+
+func inspectMap(data []byte, pos *int, typ *schema.MapType, path string, buf *bytes.Buffer, compact bool, indent int, startPos int) error {
+	indentStr := strings.Repeat("  ", indent)
+
+	// Optional flag
+	if typ.Optional {
+		if *pos >= len(data) {
+			return fmt.Errorf("unexpected end of data at offset %d", *pos)
+		}
+		present := data[*pos]
+		*pos++
+
+		if present == 0x00 {
+			if !compact {
+				buf.WriteString(fmt.Sprintf("%s[%04x] %s: null (optional map)\n", indentStr, startPos, path))
+			}
+			return nil
+		}
+	}
+
+	// Entry count
+	length, err := readLength(data, pos, typ)
+	if err != nil {
+		return err
+	}
+
+	buf.WriteString(fmt.Sprintf("%s[%04x] %s: map[%d]\n", indentStr, startPos, path, length))
+
+	// Entries: key, then value
+	for i := 0; i < length; i++ {
+		entryPath := fmt.Sprintf("%s[%d]", path, i)
+		if err := inspectValue(data, pos, typ.KeyType, entryPath+".key", buf, compact, indent+1); err != nil {
+			return err
+		}
+		if err := inspectValue(data, pos, typ.ValueType, entryPath+".value", buf, compact, indent+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func inspectStruct(data []byte, pos *int, typ *schema.StructType, path string, buf *bytes.Buffer, compact bool, indent int, startPos int) error {
 	indentStr := strings.Repeat("  ", indent)
 
@@ -297,18 +435,76 @@ func inspectStruct(data []byte, pos *int, typ *schema.StructType, path string, b
 		buf.WriteString(fmt.Sprintf("%s[%04x] %s: %s {\n", indentStr, startPos, path, typ.Name))
 	}
 
+	// Frame of a @framed struct: the length of the rest
+	end := -1
+	if typ.Framed {
+		if *pos+schema.FrameSize > len(data) {
+			return fmt.Errorf("unexpected end of data at offset %d", *pos)
+		}
+		length := int(uint32(data[*pos]) | uint32(data[*pos+1])<<8 | uint32(data[*pos+2])<<16 | uint32(data[*pos+3])<<24)
+		buf.WriteString(fmt.Sprintf("%s  [%04x] frame: %d bytes\n", indentStr, *pos, length))
+		*pos += schema.FrameSize
+		end = *pos + length
+		if end > len(data) {
+			return fmt.Errorf("frame at offset %d needs %d bytes, have %d", *pos-schema.FrameSize, length, len(data)-*pos)
+		}
+	}
+
+	// Presence bitmap of a @bitmap struct
+	var bitmap []byte
+	if typ.Bitmap {
+		n := schema.BitmapSize(typ)
+		if *pos+n > len(data) {
+			return fmt.Errorf("unexpected end of data at offset %d", *pos)
+		}
+		bitmap = data[*pos : *pos+n]
+		buf.WriteString(fmt.Sprintf("%s  [%04x] presence bitmap: %08b (%d bytes)\n", indentStr, *pos, bitmap, n))
+		*pos += n
+	}
+
 	// Struct fields
+	bit := 0
 	for _, field := range typ.Fields {
 		fieldPath := field.Name
 		if path != "" {
 			fieldPath = path + "." + field.Name
 		}
-		if err := inspectValue(data, pos, field.Type, fieldPath, buf, compact, indent+1); err != nil {
+		fieldType := field.Type
+		if bitmap != nil && fieldType.IsOptional() {
+			present := bitmap[bit/8]&(1<<(bit%8)) != 0
+			bit++
+			if !present {
+				if !compact {
+					buf.WriteString(fmt.Sprintf("%s  [%04x] %s: null (optional, in bitmap)\n", indentStr, *pos, fieldPath))
+				}
+				continue
+			}
+			fieldType = schema.WithoutPresence(fieldType)
+		}
+		if err := inspectValue(data, pos, fieldType, fieldPath, buf, compact, indent+1); err != nil {
 			return err
 		}
 	}
+	if end >= 0 && *pos != end {
+		return fmt.Errorf("frame of %s ends at offset %d, but its fields end at %d", typ.Name, end, *pos)
+	}
 
 	buf.WriteString(fmt.Sprintf("%s}\n", indentStr))
 
 	return nil
 }
+
+// readLength reads the length prefix of typ: a uint16, or a uint32 if typ
+// is @large.
+func readLength(data []byte, pos *int, typ schema.Type) (int, error) {
+	size := schema.LengthPrefixSize(typ)
+	if *pos+size > len(data) {
+		return 0, fmt.Errorf("unexpected end of data at offset %d", *pos)
+	}
+	length := 0
+	for i := size - 1; i >= 0; i-- {
+		length = length<<8 | int(data[*pos+i])
+	}
+	*pos += size
+	return length, nil
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Result records the outcome of migrating one payload file.
+type Result struct {
+	Path string // Path relative to the input directory
+	Err  error  // nil on success
+}
+
+// MigrateDir converts every .bin file under inDir and writes the result to
+// the same relative path under outDir. A failure in one file does not stop
+// the batch; per-file outcomes are returned in walk order.
+func (m *Migrator) MigrateDir(inDir, outDir string) ([]Result, error) {
+	var results []Result
+
+	err := filepath.WalkDir(inDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".bin") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(inDir, path)
+		if err != nil {
+			return err
+		}
+		results = append(results, Result{Path: rel, Err: m.MigrateFile(path, filepath.Join(outDir, rel))})
+		return nil
+	})
+	if err != nil {
+		return results, fmt.Errorf("walk %s: %w", inDir, err)
+	}
+
+	return results, nil
+}
+
+// MigrateFile converts a single payload file.
+func (m *Migrator) MigrateFile(inPath, outPath string) error {
+	data, err := os.ReadFile(inPath)
+	if err != nil {
+		return fmt.Errorf("read: %w", err)
+	}
+
+	migrated, err := m.Migrate(data)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	if err := os.WriteFile(outPath, migrated, 0644); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+// Package migrate converts stored binary payloads between schema versions.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Mapping describes how fields of the old schema map onto the new one.
+// Fields with the same name in the same position of the type tree are
+// carried over automatically; the mapping only lists the exceptions.
+//
+//	{
+//	  "message": "Config",
+//	  "renames":  { "Device": { "Label": "Name" } },
+//	  "defaults": { "Device": { "Vendor": "unknown" } }
+//	}
+type Mapping struct {
+	Message  string                            `json:"message,omitempty"`  // New root type name (defaults to the old one)
+	Renames  map[string]map[string]string      `json:"renames,omitempty"`  // New struct name -> old field name -> new field name
+	Defaults map[string]map[string]interface{} `json:"defaults,omitempty"` // New struct name -> new field name -> JSON value
+}
+
+// LoadMapping reads a JSON mapping file.
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mapping: %w", err)
+	}
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse mapping: %w", err)
+	}
+	return &m, nil
+}
+
+// Migrator converts payloads of one message type from an old schema to a new one.
+type Migrator struct {
+	OldSchema  *schema.Schema
+	NewSchema  *schema.Schema
+	OldMessage string
+	NewMessage string
+	Mapping    *Mapping
+}
+
+// New creates a Migrator for messageName. The mapping may be nil when the
+// schemas only differ by added optional fields or removed fields.
+func New(oldSchema, newSchema *schema.Schema, messageName string, mapping *Mapping) (*Migrator, error) {
+	if mapping == nil {
+		mapping = &Mapping{}
+	}
+	newMessage := messageName
+	if mapping.Message != "" {
+		newMessage = mapping.Message
+	}
+
+	m := &Migrator{
+		OldSchema:  oldSchema,
+		NewSchema:  newSchema,
+		OldMessage: messageName,
+		NewMessage: newMessage,
+		Mapping:    mapping,
+	}
+
+	oldType := m.findMessage(oldSchema, messageName)
+	if oldType == nil {
+		return nil, fmt.Errorf("message type %s not found in old schema", messageName)
+	}
+	newType := m.findMessage(newSchema, newMessage)
+	if newType == nil {
+		return nil, fmt.Errorf("message type %s not found in new schema", newMessage)
+	}
+
+	return m, nil
+}
+
+// Migrate converts a single payload from the old layout to the new one.
+func (m *Migrator) Migrate(data []byte) ([]byte, error) {
+	value, err := fixture.Decode(m.OldSchema, m.OldMessage, data)
+	if err != nil {
+		return nil, fmt.Errorf("decode old payload: %w", err)
+	}
+
+	oldType := m.findMessage(m.OldSchema, m.OldMessage)
+	newType := m.findMessage(m.NewSchema, m.NewMessage)
+
+	migrated, err := m.migrateValue(oldType, newType, value, "")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := fixture.Encode(m.NewSchema, m.NewMessage, migrated)
+	if err != nil {
+		return nil, fmt.Errorf("encode new payload: %w", err)
+	}
+	return out, nil
+}
+
+func (m *Migrator) findMessage(s *schema.Schema, name string) schema.Type {
+	for _, msg := range s.Messages {
+		if msg.Name == name {
+			return msg.TargetType
+		}
+	}
+	return nil
+}
+
+// migrateValue rewrites a decoded old value so it matches newType.
+func (m *Migrator) migrateValue(oldType, newType schema.Type, value interface{}, path string) (interface{}, error) {
+	if value == nil {
+		if newType.IsOptional() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s: optional value is absent but the new type is required", displayPath(path))
+	}
+
+	// Enum values are decoded as constant names; integers stand in for them
+	// when converting to or from a plain integer field
+	if ot, ok := oldType.(*schema.EnumType); ok {
+		if _, ok := newType.(*schema.EnumType); !ok {
+			v, _ := ot.ValueOf(value.(string))
+			value, oldType = v, ot.BaseType()
+		}
+	}
+
+	switch nt := newType.(type) {
+	case *schema.EnumType:
+		switch ot := oldType.(type) {
+		case *schema.EnumType:
+			name := value.(string)
+			if _, ok := nt.ValueOf(name); !ok {
+				return nil, fmt.Errorf("%s: %s has no constant %s", displayPath(path), nt.Name, name)
+			}
+			return name, nil
+		case *schema.PrimitiveType:
+			v, isInt := value.(int64)
+			if !isInt {
+				return nil, fmt.Errorf("%s: cannot convert %s to %s", displayPath(path), ot.Name, nt.Name)
+			}
+			name, ok := nt.Lookup(v)
+			if !ok {
+				return nil, fmt.Errorf("%s: value %d is not a %s", displayPath(path), v, nt.Name)
+			}
+			return name, nil
+		}
+		return nil, fmt.Errorf("%s: cannot convert %s to %s", displayPath(path), oldType.TypeName(), nt.Name)
+
+	case *schema.PrimitiveType:
+		ot, ok := oldType.(*schema.PrimitiveType)
+		if !ok || !compatiblePrimitives(ot.Name, nt.Name) {
+			return nil, fmt.Errorf("%s: cannot convert %s to %s", displayPath(path), oldType.TypeName(), nt.Name)
+		}
+		if err := checkRange(nt.Name, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", displayPath(path), err)
+		}
+		return value, nil
+
+	case *schema.ArrayType:
+		ot, ok := oldType.(*schema.ArrayType)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot convert %s to an array", displayPath(path), oldType.TypeName())
+		}
+		arr := value.([]interface{})
+		if nt.Length > 0 && len(arr) != nt.Length {
+			return nil, fmt.Errorf("%s: cannot convert %d elements to %s", displayPath(path), len(arr), nt.TypeName())
+		}
+		out := make([]interface{}, len(arr))
+		for i, elem := range arr {
+			migrated, err := m.migrateValue(ot.ElementType, nt.ElementType, elem, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = migrated
+		}
+		return out, nil
+
+	case *schema.MapType:
+		ot, ok := oldType.(*schema.MapType)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot convert %s to a map", displayPath(path), oldType.TypeName())
+		}
+		oldKey := ot.KeyType.(*schema.PrimitiveType)
+		newKey := nt.KeyType.(*schema.PrimitiveType)
+		if !compatiblePrimitives(oldKey.Name, newKey.Name) {
+			return nil, fmt.Errorf("%s: cannot convert map keys from %s to %s", displayPath(path), oldKey.Name, newKey.Name)
+		}
+		obj := value.(map[string]interface{})
+		out := make(map[string]interface{}, len(obj))
+		for k, v := range obj {
+			entryPath := fmt.Sprintf("%s[%s]", path, k)
+			key, err := fixture.ParseMapKey(oldKey, k)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", displayPath(entryPath), err)
+			}
+			if err := checkRange(newKey.Name, key); err != nil {
+				return nil, fmt.Errorf("%s: key %w", displayPath(entryPath), err)
+			}
+			migrated, err := m.migrateValue(ot.ValueType, nt.ValueType, v, entryPath)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = migrated
+		}
+		return out, nil
+
+	case *schema.StructType:
+		ot, ok := oldType.(*schema.StructType)
+		if !ok {
+			return nil, fmt.Errorf("%s: cannot convert %s to struct %s", displayPath(path), oldType.TypeName(), nt.Name)
+		}
+		return m.migrateStruct(ot, nt, value.(map[string]interface{}), path)
+
+	case *schema.UnionType:
+		return m.migrateUnion(oldType, nt, value, path)
+	}
+
+	return nil, fmt.Errorf("%s: unknown type %T", displayPath(path), newType)
+}
+
+// migrateUnion matches variants by name, so tags may be renumbered. A value
+// of a plain type becomes the variant of that type, which lets a field be
+// widened into a union.
+func (m *Migrator) migrateUnion(oldType schema.Type, newType *schema.UnionType, value interface{}, path string) (interface{}, error) {
+	oldVariant, inner := oldType, value
+	if ot, ok := oldType.(*schema.UnionType); ok {
+		_, variant, v, err := fixture.UnionValue(ot, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", displayPath(path), err)
+		}
+		oldVariant, inner = variant, v
+	}
+
+	name := oldVariant.TypeName()
+	newVariant, _, ok := newType.Variant(name)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s has no variant %s", displayPath(path), newType.Name, name)
+	}
+	migrated, err := m.migrateValue(oldVariant, newVariant, inner, path+"."+name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{name: migrated}, nil
+}
+
+func (m *Migrator) migrateStruct(oldType, newType *schema.StructType, obj map[string]interface{}, path string) (interface{}, error) {
+	// Invert renames: new field name -> old field name
+	sources := make(map[string]string)
+	for oldName, newName := range m.Mapping.Renames[newType.Name] {
+		sources[newName] = oldName
+	}
+
+	out := make(map[string]interface{}, len(newType.Fields))
+	for _, field := range newType.Fields {
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+
+		sourceName := field.Name
+		if renamed, ok := sources[field.Name]; ok {
+			sourceName = renamed
+		}
+
+		var source *schema.Field
+		for i := range oldType.Fields {
+			if oldType.Fields[i].Name == sourceName {
+				source = &oldType.Fields[i]
+				break
+			}
+		}
+
+		if source != nil {
+			migrated, err := m.migrateValue(source.Type, field.Type, obj[source.JSONName()], fieldPath)
+			if err != nil {
+				return nil, err
+			}
+			out[field.JSONName()] = migrated
+			continue
+		}
+
+		if def, ok := m.Mapping.Defaults[newType.Name][field.Name]; ok {
+			out[field.JSONName()] = def
+			continue
+		}
+
+		if field.Type.IsOptional() {
+			out[field.JSONName()] = nil
+			continue
+		}
+
+		return nil, fmt.Errorf("%s: new required field has no source field and no default in the mapping", fieldPath)
+	}
+
+	return out, nil
+}
+
+// compatiblePrimitives reports whether values of primitive type from can be
+// stored as primitive type to without changing their meaning category.
+func compatiblePrimitives(from, to string) bool {
+	if from == to {
+		return true
+	}
+	numeric := map[string]bool{
+		"int8": true, "int16": true, "int32": true, "int64": true,
+		"float32": true, "float64": true,
+	}
+	return numeric[from] && numeric[to]
+}
+
+// checkRange rejects integer values that do not fit a narrower target type.
+func checkRange(to string, value interface{}) error {
+	var v int64
+	switch n := value.(type) {
+	case int64:
+		v = n
+	case float64:
+		if to == "float32" || to == "float64" {
+			return nil
+		}
+		if n != float64(int64(n)) {
+			return fmt.Errorf("value %v is not an integer, cannot convert to %s", n, to)
+		}
+		v = int64(n)
+	default:
+		return nil
+	}
+
+	limits := map[string][2]int64{
+		"int8":  {math.MinInt8, math.MaxInt8},
+		"int16": {math.MinInt16, math.MaxInt16},
+		"int32": {math.MinInt32, math.MaxInt32},
+	}
+	if lim, ok := limits[to]; ok && (v < lim[0] || v > lim[1]) {
+		return fmt.Errorf("value %d out of range for %s", v, to)
+	}
+	return nil
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
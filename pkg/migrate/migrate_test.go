@@ -0,0 +1,292 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+func deviceSchema(fields ...schema.Field) *schema.Schema {
+	return &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name:       "Device",
+				TargetType: &schema.StructType{Name: "Device", Fields: fields},
+			},
+		},
+	}
+}
+
+func TestMigrateRenameAndDefault(t *testing.T) {
+	oldSchema := deviceSchema(
+		schema.Field{Name: "Label", Type: &schema.PrimitiveType{Name: "string"}},
+		schema.Field{Name: "Channels", Type: &schema.PrimitiveType{Name: "int16"}},
+		schema.Field{Name: "Legacy", Type: &schema.PrimitiveType{Name: "bool"}},
+	)
+	newSchema := deviceSchema(
+		schema.Field{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+		schema.Field{Name: "Channels", Type: &schema.PrimitiveType{Name: "int32"}},
+		schema.Field{Name: "Vendor", Type: &schema.PrimitiveType{Name: "string"}},
+		schema.Field{Name: "Notes", Type: &schema.PrimitiveType{Name: "string", Optional: true}},
+	)
+
+	mapping := &Mapping{
+		Renames:  map[string]map[string]string{"Device": {"Label": "Name"}},
+		Defaults: map[string]map[string]interface{}{"Device": {"Vendor": "unknown"}},
+	}
+
+	m, err := New(oldSchema, newSchema, "Device", mapping)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	oldData, err := fixture.Convert(oldSchema, "Device", []byte(`{"Label": "Speaker", "Channels": 2, "Legacy": true}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	newData, err := m.Migrate(oldData)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	value, err := fixture.Decode(newSchema, "Device", newData)
+	if err != nil {
+		t.Fatalf("Decode of migrated payload failed: %v", err)
+	}
+	obj := value.(map[string]interface{})
+
+	if obj["Name"] != "Speaker" {
+		t.Errorf("Name = %v, want Speaker", obj["Name"])
+	}
+	if obj["Channels"] != int64(2) {
+		t.Errorf("Channels = %v, want 2", obj["Channels"])
+	}
+	if obj["Vendor"] != "unknown" {
+		t.Errorf("Vendor = %v, want unknown", obj["Vendor"])
+	}
+	if obj["Notes"] != nil {
+		t.Errorf("Notes = %v, want nil", obj["Notes"])
+	}
+}
+
+func TestMigrateMissingRequiredField(t *testing.T) {
+	oldSchema := deviceSchema(
+		schema.Field{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+	)
+	newSchema := deviceSchema(
+		schema.Field{Name: "Name", Type: &schema.PrimitiveType{Name: "string"}},
+		schema.Field{Name: "Vendor", Type: &schema.PrimitiveType{Name: "string"}},
+	)
+
+	m, err := New(oldSchema, newSchema, "Device", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	oldData, err := fixture.Convert(oldSchema, "Device", []byte(`{"Name": "Speaker"}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	_, err = m.Migrate(oldData)
+	if err == nil || !strings.Contains(err.Error(), "Vendor") {
+		t.Errorf("Migrate error = %v, want error mentioning Vendor", err)
+	}
+}
+
+func TestMigrateOutOfRange(t *testing.T) {
+	oldSchema := deviceSchema(
+		schema.Field{Name: "Channels", Type: &schema.PrimitiveType{Name: "int32"}},
+	)
+	newSchema := deviceSchema(
+		schema.Field{Name: "Channels", Type: &schema.PrimitiveType{Name: "int8"}},
+	)
+
+	m, err := New(oldSchema, newSchema, "Device", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	oldData, err := fixture.Convert(oldSchema, "Device", []byte(`{"Channels": 300}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	_, err = m.Migrate(oldData)
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Migrate error = %v, want out of range error", err)
+	}
+}
+
+func TestMigrateIncompatibleType(t *testing.T) {
+	oldSchema := deviceSchema(
+		schema.Field{Name: "ID", Type: &schema.PrimitiveType{Name: "string"}},
+	)
+	newSchema := deviceSchema(
+		schema.Field{Name: "ID", Type: &schema.PrimitiveType{Name: "int32"}},
+	)
+
+	m, err := New(oldSchema, newSchema, "Device", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	oldData, err := fixture.Convert(oldSchema, "Device", []byte(`{"ID": "abc"}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	if _, err := m.Migrate(oldData); err == nil {
+		t.Error("expected error converting string to int32")
+	}
+}
+
+func TestMigrateMapKeys(t *testing.T) {
+	mapField := func(key string) schema.Field {
+		return schema.Field{Name: "Gains", Type: &schema.MapType{
+			KeyType:   &schema.PrimitiveType{Name: key},
+			ValueType: &schema.PrimitiveType{Name: "float32"},
+		}}
+	}
+	oldSchema := deviceSchema(mapField("int32"))
+
+	oldData, err := fixture.Convert(oldSchema, "Device", []byte(`{"Gains": {"1": 0.5, "-2": 1.5}}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	m, err := New(oldSchema, deviceSchema(mapField("int64")), "Device", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	newData, err := m.Migrate(oldData)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	value, err := fixture.Decode(m.NewSchema, "Device", newData)
+	if err != nil {
+		t.Fatalf("Decode of migrated payload failed: %v", err)
+	}
+	gains := value.(map[string]interface{})["Gains"].(map[string]interface{})
+	if len(gains) != 2 || gains["-2"] != 1.5 {
+		t.Errorf("Gains = %v, want both entries carried over", gains)
+	}
+
+	bigData, err := fixture.Convert(oldSchema, "Device", []byte(`{"Gains": {"300": 0}}`))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	m, err = New(oldSchema, deviceSchema(mapField("int8")), "Device", nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := m.Migrate(bigData); err == nil || !strings.Contains(err.Error(), "Gains[300]: key value 300 out of range") {
+		t.Errorf("Migrate error = %v, want out of range key", err)
+	}
+}
+
+func TestMigrateEnums(t *testing.T) {
+	mode := func(values ...schema.EnumValue) schema.Field {
+		return schema.Field{Name: "Mode", Type: &schema.EnumType{Name: "Mode", Base: "int8", Values: values}}
+	}
+	mono, stereo := schema.EnumValue{Name: "Mono", Value: 1}, schema.EnumValue{Name: "Stereo", Value: 2}
+	intSchema := deviceSchema(schema.Field{Name: "Mode", Type: &schema.PrimitiveType{Name: "int32"}})
+
+	tests := []struct {
+		name     string
+		from, to *schema.Schema
+		input    string
+		want     interface{}
+		wantErr  string
+	}{
+		{"int to enum", intSchema, deviceSchema(mode(mono, stereo)), `{"Mode": 2}`, "Stereo", ""},
+		{"enum to int", deviceSchema(mode(mono, stereo)), intSchema, `{"Mode": "Stereo"}`, int64(2), ""},
+		{"constant kept", deviceSchema(mode(mono, stereo)), deviceSchema(mode(stereo)), `{"Mode": "Stereo"}`, "Stereo", ""},
+		{"constant removed", deviceSchema(mode(mono, stereo)), deviceSchema(mode(stereo)), `{"Mode": "Mono"}`, nil, "Mode has no constant Mono"},
+		{"unlisted int", intSchema, deviceSchema(mode(mono)), `{"Mode": 2}`, nil, "value 2 is not a Mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldData, err := fixture.Convert(tt.from, "Device", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+			m, err := New(tt.from, tt.to, "Device", nil)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			newData, err := m.Migrate(oldData)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Migrate error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Migrate failed: %v", err)
+			}
+			value, err := fixture.Decode(tt.to, "Device", newData)
+			if err != nil {
+				t.Fatalf("Decode of migrated payload failed: %v", err)
+			}
+			if got := value.(map[string]interface{})["Mode"]; got != tt.want {
+				t.Errorf("Mode = %v (%T), want %v", got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateUnions(t *testing.T) {
+	value := func(variants ...schema.Type) *schema.Schema {
+		return deviceSchema(schema.Field{Name: "Value", Type: &schema.UnionType{Name: "Value", Variants: variants}})
+	}
+	str, i32, i64 := &schema.PrimitiveType{Name: "string"}, &schema.PrimitiveType{Name: "int32"}, &schema.PrimitiveType{Name: "int64"}
+	intSchema := deviceSchema(schema.Field{Name: "Value", Type: i32})
+
+	tests := []struct {
+		name     string
+		from, to *schema.Schema
+		input    string
+		want     string
+		wantErr  string
+	}{
+		{"reordered", value(str, i32), value(i32, str), `{"Value": {"string": "a"}}`, "map[string:a]", ""},
+		{"widened", intSchema, value(str, i32), `{"Value": 7}`, "map[int32:7]", ""},
+		{"variant removed", value(str, i32), value(str), `{"Value": {"int32": 7}}`, "", "Value has no variant int32"},
+		{"variant retyped", value(str, i32), value(str, i64), `{"Value": {"int32": 7}}`, "", "Value has no variant int32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldData, err := fixture.Convert(tt.from, "Device", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("Convert failed: %v", err)
+			}
+			m, err := New(tt.from, tt.to, "Device", nil)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			newData, err := m.Migrate(oldData)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Migrate error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Migrate failed: %v", err)
+			}
+			value, err := fixture.Decode(tt.to, "Device", newData)
+			if err != nil {
+				t.Fatalf("Decode of migrated payload failed: %v", err)
+			}
+			if got := fmt.Sprint(value.(map[string]interface{})["Value"]); got != tt.want {
+				t.Errorf("Value = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/ast"
+)
+
+// Annotations are written as comment directives directly above or beside a
+// declaration, so schemas remain valid Go syntax:
+//
+//	type Config struct {
+//	    Host string
+//	    // @feature("v2")
+//	    Tracing *bool
+//	}
+//
+// A directive is a comment line whose text starts with '@', followed by a
+// name and an optional argument list of string or integer literals.
+
+// annotation is a single parsed comment directive.
+type annotation struct {
+	Name string
+	Args []string
+	Pos  ast.Pos
+}
+
+// packageAnnotations lists the directives accepted above the package
+// clause:
+//
+//	// @version("1.4.0")
+//	package audio
+var packageAnnotations = map[string]bool{
+	"version": true,
+}
+
+// typeAnnotations lists the directives accepted on type declarations:
+//
+//	// @large
+//	type Samples []float32
+var typeAnnotations = map[string]bool{
+	"large":  true,
+	"bitmap": true,
+	"framed": true,
+	"max":    true,
+}
+
+// fieldAnnotations lists the directives accepted on struct fields.
+var fieldAnnotations = map[string]bool{
+	"feature": true,
+	"cached":  true,
+	"large":   true,
+	"max":     true,
+}
+
+// parseAnnotations extracts all directives from the given comment groups.
+func parseAnnotations(groups ...*ast.CommentGroup) ([]annotation, error) {
+	var result []annotation
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, c := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), "/*"))
+			text = strings.TrimSpace(strings.TrimSuffix(text, "*/"))
+			if !strings.HasPrefix(text, "@") {
+				continue
+			}
+			ann, err := parseAnnotation(text[1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid annotation %q: %w", text, err)
+			}
+			ann.Pos = c.Pos()
+			result = append(result, ann)
+		}
+	}
+	return result, nil
+}
+
+// docText returns the doc comment of a declaration from its comment
+// groups, tried in order (e.g. the comment above, then the one beside):
+// the text of the first group that has any besides directives.
+func docText(groups ...*ast.CommentGroup) string {
+	for _, group := range groups {
+		var lines []string
+		for _, line := range strings.Split(group.Text(), "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "@") {
+				lines = append(lines, line)
+			}
+		}
+		if text := strings.TrimSpace(strings.Join(lines, "\n")); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// parseAnnotation parses the directive text after '@', e.g. `feature("v2")`.
+func parseAnnotation(text string) (annotation, error) {
+	sc := ast.NewScanner([]byte(text))
+	scan := func() (ast.Token, string) {
+		for {
+			_, tok, lit := sc.Scan()
+			if tok != ast.COMMENT {
+				return tok, lit
+			}
+		}
+	}
+
+	tok, lit := scan()
+	if tok != ast.IDENT {
+		return annotation{}, fmt.Errorf("expected @name or @name(args)")
+	}
+	ann := annotation{Name: lit}
+
+	tok, lit = scan()
+	if tok == ast.LPAREN {
+		for {
+			tok, lit = scan()
+			if tok == ast.RPAREN { // empty list or trailing comma
+				break
+			}
+			switch tok {
+			case ast.STRING:
+				value, err := strconv.Unquote(lit)
+				if err != nil {
+					return annotation{}, err
+				}
+				ann.Args = append(ann.Args, value)
+			case ast.INT, ast.FLOAT:
+				ann.Args = append(ann.Args, lit)
+			default:
+				return annotation{}, fmt.Errorf("arguments must be literals")
+			}
+
+			tok, _ = scan()
+			if tok == ast.RPAREN {
+				break
+			}
+			if tok != ast.COMMA {
+				return annotation{}, fmt.Errorf("expected ',' or ')' in argument list")
+			}
+		}
+		tok, lit = scan()
+	}
+
+	// Only the implicit end-of-input semicolon may follow
+	if tok == ast.SEMICOLON && lit == "\n" {
+		tok, _ = scan()
+	}
+	if tok != ast.EOF || sc.Err() != nil {
+		return annotation{}, fmt.Errorf("expected @name or @name(args)")
+	}
+	return ann, nil
+}
+
+// checkAnnotations rejects directives not in the allowed set, catching typos
+// that would otherwise silently change nothing.
+func checkAnnotations(anns []annotation, allowed map[string]bool, context string) error {
+	for _, ann := range anns {
+		if !allowed[ann.Name] {
+			return fmt.Errorf("%s: unknown annotation @%s", context, ann.Name)
+		}
+	}
+	return nil
+}
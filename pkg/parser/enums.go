@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Enums are declared the Go way: an integer type, and constants of that
+// type listing its values.
+//
+//	type Mode int8
+//
+//	const (
+//	    Mono Mode = iota + 1
+//	    Stereo
+//	)
+//
+// Constants follow Go's rules: iota counts the specs of a const
+// declaration, and a spec without type and value repeats the expression of
+// the one before it. Integer types without constants stay plain integers.
+
+// collectEnums turns every type that has constants into a schema.EnumType.
+// The base type is checked by the validator.
+func (p *schemaParser) collectEnums() error {
+	enums := make(map[string]*schema.EnumType)
+	declared := make(map[string]bool)
+
+	for _, d := range p.file.Decls {
+		decl, ok := d.(*ast.ConstDecl)
+		if !ok {
+			continue
+		}
+		var typ, value ast.Expr // Carried over to specs that omit them
+		for i, spec := range decl.Specs {
+			name := spec.Name.Name
+			if spec.Value != nil {
+				typ, value = spec.Type, spec.Value
+			} else if spec.Type != nil || i == 0 {
+				return fmt.Errorf("%s: constant %s: missing value", spec.Pos(), name)
+			}
+
+			if declared[name] || p.types[name] != nil {
+				return fmt.Errorf("%s: constant %s redeclared", spec.Pos(), name)
+			}
+			declared[name] = true
+
+			enum, err := p.enumFor(name, typ, enums)
+			if err != nil {
+				return fmt.Errorf("%s: constant %s: %w", spec.Pos(), name, err)
+			}
+			v, err := evalConst(value, int64(i))
+			if err != nil {
+				return fmt.Errorf("%s: constant %s: %w", spec.Pos(), name, err)
+			}
+			doc := spec.Doc
+			if !decl.Grouped() {
+				doc = decl.Doc
+			}
+			enum.Values = append(enum.Values, schema.EnumValue{Name: name, Value: v, Doc: docText(doc, spec.Comment)})
+		}
+	}
+
+	return nil
+}
+
+// enumFor returns the enum for a constant's type, converting the integer
+// type declaration to an enum on first use.
+func (p *schemaParser) enumFor(name string, typ ast.Expr, enums map[string]*schema.EnumType) (*schema.EnumType, error) {
+	if typ == nil {
+		return nil, fmt.Errorf("untyped constants are not supported; give it an enum type, e.g. %s Mode = 1", name)
+	}
+	ident, ok := typ.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a named integer type", typeName(typ))
+	}
+	if enum, ok := enums[ident.Name]; ok {
+		return enum, nil
+	}
+
+	base, ok := p.types[ident.Name].(*schema.PrimitiveType)
+	if !ok || base.Optional {
+		if schema.IsPrimitive(ident.Name) {
+			return nil, fmt.Errorf("type %s is not a named type; declare one, e.g. type Mode %s", ident.Name, ident.Name)
+		}
+		return nil, fmt.Errorf("type %s is not a named integer type", ident.Name)
+	}
+
+	enum := &schema.EnumType{Name: ident.Name, Base: base.Name, Doc: p.docs[ident.Name]}
+	enums[ident.Name] = enum
+	p.types[ident.Name] = enum
+	for i, t := range p.schema.Types {
+		if t == schema.Type(base) {
+			p.schema.Types[i] = enum
+		}
+	}
+	return enum, nil
+}
+
+// evalConst evaluates a constant expression with the given iota.
+func evalConst(expr ast.Expr, iota int64) (int64, error) {
+	switch x := expr.(type) {
+	case *ast.BasicLit:
+		v, err := strconv.ParseInt(x.Value, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %s: must be an int64", x.Value)
+		}
+		return v, nil
+
+	case *ast.Ident:
+		if x.Name != "iota" {
+			return 0, fmt.Errorf("unsupported value %s: use integers and iota", x.Name)
+		}
+		return iota, nil
+
+	case *ast.UnaryExpr:
+		v, err := evalConst(x.X, iota)
+		if err != nil {
+			return 0, err
+		}
+		if x.Op == ast.SUB {
+			if v == math.MinInt64 {
+				return 0, fmt.Errorf("value overflows int64")
+			}
+			v = -v
+		}
+		return v, nil
+
+	case *ast.BinaryExpr:
+		a, err := evalConst(x.X, iota)
+		if err != nil {
+			return 0, err
+		}
+		b, err := evalConst(x.Y, iota)
+		if err != nil {
+			return 0, err
+		}
+		if x.Op == ast.SUB {
+			if b == math.MinInt64 {
+				return 0, fmt.Errorf("value overflows int64")
+			}
+			b = -b
+		}
+		if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+			return 0, fmt.Errorf("value overflows int64")
+		}
+		return a + b, nil
+	}
+	return 0, fmt.Errorf("unsupported value %s", typeName(expr))
+}
@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Generic types are monomorphized at parse time: every distinct instantiation
+// such as Page[Device] becomes an ordinary named type in the schema, so
+// generators never see type parameters.
+//
+//	type Page[T any] struct {
+//	    Items  []T
+//	    Cursor string
+//	    Total  int32
+//	}
+//
+//	type DevicePage Page[Device]       // named instantiation -> "DevicePage"
+//	type Catalog struct {
+//	    Plugins Page[Plugin]           // inline instantiation -> "PagePlugin"
+//	}
+
+// collectTemplates records generic declarations and named instantiations
+// before any type is processed, so declaration order does not matter.
+func (p *schemaParser) collectTemplates() error {
+	for _, d := range p.file.Decls {
+		decl, ok := d.(*ast.TypeDecl)
+		if !ok {
+			continue
+		}
+		for _, typeSpec := range decl.Specs {
+			name := typeSpec.Name.Name
+
+			if typeSpec.TypeParams != nil {
+				tmpl := &genericTemplate{name: name, body: typeSpec.Type}
+				for _, field := range typeSpec.TypeParams.List {
+					if ident, ok := field.Constraint.(*ast.Ident); !ok || ident.Name != "any" {
+						return fmt.Errorf("generic type %s: only the 'any' constraint is supported", name)
+					}
+					for _, paramName := range field.Names {
+						tmpl.params = append(tmpl.params, paramName.Name)
+					}
+				}
+				p.templates[name] = tmpl
+				continue
+			}
+			p.declared[name] = true
+
+			// First named instantiation wins the canonical name for its key
+			if isInstantiation(typeSpec.Type) {
+				key := typeName(typeSpec.Type)
+				if _, exists := p.instances[key]; !exists {
+					p.instances[key] = name
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// isInstantiation reports whether expr is a generic instantiation like Page[Device].
+func isInstantiation(expr ast.Expr) bool {
+	_, ok := expr.(*ast.IndexExpr)
+	return ok
+}
+
+// instantiate materializes a generic instantiation and returns the name of the
+// resulting concrete type. If name is empty, the name registered for the
+// instantiation key is used, falling back to a derived name (Page[Device] -> PageDevice).
+func (p *schemaParser) instantiate(expr ast.Expr, name string) (string, error) {
+	index, ok := expr.(*ast.IndexExpr)
+	if !ok {
+		return "", fmt.Errorf("unsupported type: %s", typeName(expr))
+	}
+	base, args := index.X, index.Indices
+
+	baseIdent, ok := base.(*ast.Ident)
+	if !ok {
+		return "", fmt.Errorf("unsupported generic type: %s", typeName(base))
+	}
+	tmpl, ok := p.templates[baseIdent.Name]
+	if !ok {
+		return "", fmt.Errorf("undefined generic type: %s", baseIdent.Name)
+	}
+	if len(args) != len(tmpl.params) {
+		return "", fmt.Errorf("generic type %s expects %d type argument(s), got %d", tmpl.name, len(tmpl.params), len(args))
+	}
+
+	// Resolve the instantiation key with the current bindings applied,
+	// so Page[T] inside another template keys on the concrete argument.
+	key := p.instanceKey(expr)
+	if name == "" {
+		if registered, ok := p.instances[key]; ok {
+			name = registered
+		} else {
+			name = tmpl.name
+			for _, arg := range args {
+				name += p.instanceSuffix(arg)
+			}
+			// A derived name must not reuse a declared type or the type of
+			// another instantiation
+			if p.declared[name] {
+				return "", fmt.Errorf("%s: %s is named %s, which is already declared; rename the type or declare the instantiation by name", index.Pos(), key, name)
+			}
+			if other, ok := p.derived[name]; ok && other != key {
+				return "", fmt.Errorf("%s: %s and %s are both named %s; declare one of them by name", index.Pos(), other, key, name)
+			}
+			p.derived[name] = key
+			p.instances[key] = name
+		}
+	}
+
+	// Already materialized (or currently being materialized)
+	if _, exists := p.types[name]; exists {
+		return name, nil
+	}
+
+	env := make(map[string]typeArg, len(args))
+	for i, param := range tmpl.params {
+		env[param] = typeArg{expr: args[i], env: p.typeArgs}
+	}
+
+	// Reserve the name before parsing the body to stop runaway recursion
+	p.types[name] = &schema.PrimitiveType{Name: name}
+
+	saved, savedScope, savedAnon := p.typeArgs, p.scope, p.anon
+	p.typeArgs, p.scope, p.anon = env, name, ""
+	typ, err := p.parseNamedType(tmpl.body)
+	p.typeArgs, p.scope, p.anon = saved, savedScope, savedAnon
+	if err != nil {
+		delete(p.types, name)
+		return "", fmt.Errorf("instantiate %s: %w", key, err)
+	}
+
+	switch t := typ.(type) {
+	case *schema.StructType:
+		t.Name = name
+	case *schema.UnionType:
+		t.Name = name
+	}
+
+	p.types[name] = typ
+	p.schema.Types = append(p.schema.Types, typ)
+	return name, nil
+}
+
+// instanceKey renders an expression with type parameters substituted by
+// their bound arguments, e.g. Page[T] with T=Device -> "Page[Device]".
+func (p *schemaParser) instanceKey(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if arg, ok := p.typeArgs[t.Name]; ok {
+			saved := p.typeArgs
+			p.typeArgs = arg.env
+			defer func() { p.typeArgs = saved }()
+			return p.instanceKey(arg.expr)
+		}
+		return t.Name
+	case *ast.StarExpr:
+		return "*" + p.instanceKey(t.X)
+	case *ast.ArrayType:
+		if lit, ok := t.Len.(*ast.BasicLit); ok {
+			return "[" + lit.Value + "]" + p.instanceKey(t.Elt)
+		}
+		return "[]" + p.instanceKey(t.Elt)
+	case *ast.MapType:
+		return "map[" + p.instanceKey(t.Key) + "]" + p.instanceKey(t.Value)
+	case *ast.IndexExpr:
+		args := make([]string, len(t.Indices))
+		for i, idx := range t.Indices {
+			args[i] = p.instanceKey(idx)
+		}
+		return p.instanceKey(t.X) + "[" + strings.Join(args, ", ") + "]"
+	default:
+		return typeName(expr)
+	}
+}
+
+// instanceSuffix derives the name fragment a type argument contributes to a
+// derived instantiation name: Device -> "Device", []int32 -> "Int32List",
+// [4]int32 -> "Int32Array4".
+func (p *schemaParser) instanceSuffix(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if arg, ok := p.typeArgs[t.Name]; ok {
+			saved := p.typeArgs
+			p.typeArgs = arg.env
+			defer func() { p.typeArgs = saved }()
+			return p.instanceSuffix(arg.expr)
+		}
+		return strings.ToUpper(t.Name[:1]) + t.Name[1:]
+	case *ast.StarExpr:
+		return "Optional" + p.instanceSuffix(t.X)
+	case *ast.ArrayType:
+		if lit, ok := t.Len.(*ast.BasicLit); ok {
+			return p.instanceSuffix(t.Elt) + "Array" + lit.Value
+		}
+		return p.instanceSuffix(t.Elt) + "List"
+	case *ast.MapType:
+		return p.instanceSuffix(t.Key) + p.instanceSuffix(t.Value) + "Map"
+	case *ast.IndexExpr:
+		key := p.instanceKey(t)
+		if registered, ok := p.instances[key]; ok {
+			return registered
+		}
+		suffix := p.instanceSuffix(t.X)
+		for _, arg := range t.Indices {
+			suffix += p.instanceSuffix(arg)
+		}
+		return suffix
+	default:
+		return ""
+	}
+}
@@ -1,16 +1,57 @@
-// Package parser parses .ffi schema files using Go's ast package.
+// Package parser parses .ffi schema files into a schema.Schema. The syntax
+// tree comes from pkg/ast; this package resolves names, monomorphizes
+// generics and infers root types.
+//
+// Build tools that embed ffire read schemas with ParseReader, which also
+// applies feature flags and validation the way ffire generate does, and
+// pass the result to the generator package.
 package parser
 
 import (
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/errors"
 	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/semver"
+	"github.com/shaban/ffire/pkg/validator"
 )
 
+// Options configure ParseReader.
+type Options struct {
+	// Features lists the enabled @feature flags. Fields guarded only by
+	// other flags are stripped, as with ffire generate -features.
+	Features []string
+
+	// Validate checks the schema with validator.ValidateSchema, as code
+	// generation requires.
+	Validate bool
+}
+
+// ParseReader parses .ffi source read from r and prepares it for code
+// generation according to opts.
+func ParseReader(r io.Reader, opts Options) (*schema.Schema, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read schema: %w", err)
+	}
+	s, err := ParseBytes(src)
+	if err != nil {
+		return nil, err
+	}
+	s.ApplyFeatures(opts.Features)
+	if opts.Validate {
+		if err := validator.ValidateSchema(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
 // Parse parses a .ffi file and returns a Schema.
 func Parse(filePath string) (*schema.Schema, error) {
 	src, err := os.ReadFile(filePath)
@@ -22,50 +63,107 @@ func Parse(filePath string) (*schema.Schema, error) {
 
 // ParseBytes parses .ffi source code from bytes.
 func ParseBytes(src []byte) (*schema.Schema, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	file, err := ast.Parse(src)
 	if err != nil {
 		return nil, fmt.Errorf("parse file: %w", err)
 	}
+	return FromAST(file)
+}
 
+// FromAST builds a Schema from a syntax tree, such as one produced by
+// ast.Parse and then rewritten by a codemod. The tree is not modified.
+func FromAST(file *ast.File) (*schema.Schema, error) {
 	p := &schemaParser{
-		fset:           fset,
 		file:           file,
 		types:          make(map[string]schema.Type),
 		schema:         &schema.Schema{},
 		typeReferences: make(map[string]bool),
+		templates:      make(map[string]*genericTemplate),
+		instances:      make(map[string]string),
+		derived:        make(map[string]string),
+		declared:       make(map[string]bool),
+		lifted:         make(map[string]string),
+		docs:           make(map[string]string),
 	}
 
 	return p.parse()
 }
 
 type schemaParser struct {
-	fset           *token.FileSet
 	file           *ast.File
 	types          map[string]schema.Type
 	schema         *schema.Schema
 	typeReferences map[string]bool // Track which types are referenced by others
+
+	// Generic type support (monomorphized at parse time)
+	templates map[string]*genericTemplate // Generic type declarations by name
+	instances map[string]string           // Instantiation key (e.g., "Page[Device]") -> type name
+	derived   map[string]string           // Derived instantiation name (e.g., "PageDevice") -> its key
+	declared  map[string]bool             // Names of the non-generic type declarations
+	typeArgs  map[string]typeArg          // Type parameter bindings while instantiating a template
+
+	// Anonymous struct support (lifted into named types at parse time)
+	scope     string            // Name of the type being declared
+	anon      string            // Name an anonymous struct in the current field type gets, "" outside field types
+	anonField string            // The current field, e.g. "Device.Position"
+	lifted    map[string]string // Lifted type name -> the field it was declared in
+
+	docs map[string]string // Doc comments of type declarations by name
+}
+
+// genericTemplate is a parameterized type declaration such as
+// type Page[T any] struct { Items []T; Cursor string }
+type genericTemplate struct {
+	name   string
+	params []string
+	body   ast.Expr
+}
+
+// typeArg binds a type parameter to the argument expression at the
+// instantiation site, along with the bindings in effect there.
+type typeArg struct {
+	expr ast.Expr
+	env  map[string]typeArg
 }
 
 func (p *schemaParser) parse() (*schema.Schema, error) {
 	// Extract package name
 	p.schema.Package = p.file.Name.Name
+	if err := p.parsePackageAnnotations(); err != nil {
+		return nil, err
+	}
+
+	// Pre-pass: collect generic templates and named instantiations
+	if err := p.collectTemplates(); err != nil {
+		return nil, err
+	}
 
 	// First pass: collect all type definitions
-	for _, decl := range p.file.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.TYPE {
+	for _, d := range p.file.Decls {
+		decl, ok := d.(*ast.TypeDecl)
+		if !ok {
 			continue
 		}
-
-		for _, spec := range genDecl.Specs {
-			typeSpec := spec.(*ast.TypeSpec)
-			if err := p.processTypeSpec(typeSpec); err != nil {
+		for _, typeSpec := range decl.Specs {
+			if typeSpec.TypeParams != nil {
+				continue // Generic templates are only materialized when instantiated
+			}
+			doc := typeSpec.Doc
+			if !decl.Grouped() {
+				doc = decl.Doc
+			}
+			p.docs[typeSpec.Name.Name] = docText(doc, typeSpec.Comment)
+			if err := p.processTypeSpec(typeSpec, doc); err != nil {
 				return nil, err
 			}
 		}
 	}
 
+	// Integer types with constants become enums
+	if err := p.collectEnums(); err != nil {
+		return nil, err
+	}
+
 	// Second pass: resolve type references and build dependency graph
 	if err := p.resolveTypes(); err != nil {
 		return nil, err
@@ -79,17 +177,131 @@ func (p *schemaParser) parse() (*schema.Schema, error) {
 	return p.schema, nil
 }
 
-func (p *schemaParser) processTypeSpec(spec *ast.TypeSpec) error {
+// parsePackageAnnotations reads the directives above the package clause.
+func (p *schemaParser) parsePackageAnnotations() error {
+	anns, err := parseAnnotations(p.file.Doc)
+	if err != nil {
+		return err
+	}
+	if err := checkAnnotations(anns, packageAnnotations, "package "+p.schema.Package); err != nil {
+		return err
+	}
+	for _, ann := range anns {
+		if ann.Name == "version" {
+			if len(ann.Args) != 1 {
+				return fmt.Errorf("package %s: @version takes one version, e.g. @version(\"1.4.0\")", p.schema.Package)
+			}
+			if _, err := semver.Parse(ann.Args[0]); err != nil {
+				return fmt.Errorf("package %s: @version: %w", p.schema.Package, err)
+			}
+			p.schema.Version = ann.Args[0]
+		}
+	}
+	return nil
+}
+
+func (p *schemaParser) processTypeSpec(spec *ast.TypeSpec, doc *ast.CommentGroup) error {
 	name := spec.Name.Name
+	if field, ok := p.lifted[name]; ok {
+		return fmt.Errorf("%s: type %s has the name of the anonymous struct of field %s; rename the type or declare the struct", spec.Pos(), name, field)
+	}
+	anns, err := parseAnnotations(doc, spec.Comment)
+	if err != nil {
+		return err
+	}
+	if err := checkAnnotations(anns, typeAnnotations, "type "+name); err != nil {
+		return err
+	}
 
 	// Note: type aliases (type X = Y) are no longer treated as message types
 	// Message types are now inferred based on usage
 
+	// Named instantiation of a generic: type DevicePage Page[Device]
+	if isInstantiation(spec.Type) {
+		if _, err := p.instantiate(spec.Type, name); err != nil {
+			return fmt.Errorf("parse type %s: %w", name, err)
+		}
+		if st, ok := p.types[name].(*schema.StructType); ok {
+			st.Doc = p.docs[name]
+		}
+		for _, ann := range anns {
+			switch ann.Name {
+			case "large":
+				return fmt.Errorf("type %s: @large applies to slice and map declarations", name)
+			case "max":
+				return fmt.Errorf("type %s: @max applies to slice, map and union declarations", name)
+			case "bitmap":
+				if err := markBitmap(p.types[name], ann); err != nil {
+					return fmt.Errorf("type %s: %w", name, err)
+				}
+			case "framed":
+				if err := markFramed(p.types[name], ann); err != nil {
+					return fmt.Errorf("type %s: %w", name, err)
+				}
+			}
+		}
+		return nil
+	}
+
 	// Parse the type
-	typ, err := p.parseType(spec.Type)
+	p.scope = name
+	typ, err := p.parseNamedType(spec.Type)
 	if err != nil {
 		return fmt.Errorf("parse type %s: %w", name, err)
 	}
+	var limits *annotation
+	for _, ann := range anns {
+		switch ann.Name {
+		case "bitmap":
+			if err := markBitmap(typ, ann); err != nil {
+				return fmt.Errorf("type %s: %w", name, err)
+			}
+			continue
+		case "framed":
+			if err := markFramed(typ, ann); err != nil {
+				return fmt.Errorf("type %s: %w", name, err)
+			}
+			continue
+		case "max":
+			limits = &ann
+			continue
+		}
+		// Strings and bytes are large per field: a named string type may
+		// also be a map key or an array element, whose prefix stays a uint16
+		switch typ.(type) {
+		case *schema.ArrayType, *schema.MapType:
+		default:
+			return fmt.Errorf("type %s: @large applies to slice and map declarations; mark string or bytes fields instead", name)
+		}
+		if err := markLarge(typ, ann); err != nil {
+			return fmt.Errorf("type %s: %w", name, err)
+		}
+	}
+	// Limits are checked against the prefix, so after @large. Like
+	// @large, string and bytes limits belong on fields
+	if limits != nil {
+		switch typ.(type) {
+		case *schema.ArrayType, *schema.MapType, *schema.UnionType:
+		default:
+			return fmt.Errorf("type %s: @max applies to slice, map and union declarations; mark string or bytes fields instead", name)
+		}
+		if err := markMax(typ, *limits); err != nil {
+			return fmt.Errorf("type %s: %w", name, err)
+		}
+	}
+
+	// Name structs up front: optional references copy the struct during
+	// resolution, possibly before the struct itself has been resolved
+	switch t := typ.(type) {
+	case *schema.StructType:
+		if t.Name == "" {
+			t.Name = name
+		}
+		t.Doc = p.docs[name]
+	case *schema.UnionType:
+		t.Name = name
+		t.Doc = p.docs[name]
+	}
 
 	// Store type
 	p.types[name] = typ
@@ -98,12 +310,54 @@ func (p *schemaParser) processTypeSpec(spec *ast.TypeSpec) error {
 	return nil
 }
 
+// parseNamedType parses the type of a type declaration, which unlike a
+// field type may be a union.
+func (p *schemaParser) parseNamedType(expr ast.Expr) (schema.Type, error) {
+	if it, ok := expr.(*ast.InterfaceType); ok {
+		return p.parseUnion(it)
+	}
+	return p.parseType(expr)
+}
+
+// parseUnion parses a union's variants. Each term must name a type; the
+// validator checks what kind of type it is once references are resolved.
+func (p *schemaParser) parseUnion(it *ast.InterfaceType) (*schema.UnionType, error) {
+	union := &schema.UnionType{}
+	for _, term := range it.Terms {
+		switch term.(type) {
+		case *ast.StructType, *ast.InterfaceType:
+			return nil, fmt.Errorf("%s: union variant %s must be a named type", term.Pos(), typeName(term))
+		}
+		typ, err := p.parseType(term)
+		if err != nil {
+			return nil, err
+		}
+		union.Variants = append(union.Variants, typ)
+	}
+	return union, nil
+}
+
 func (p *schemaParser) parseType(expr ast.Expr) (schema.Type, error) {
 	switch t := expr.(type) {
 	case *ast.Ident:
+		// Type parameter inside a generic template: substitute the bound argument
+		if arg, ok := p.typeArgs[t.Name]; ok {
+			saved := p.typeArgs
+			p.typeArgs = arg.env
+			defer func() { p.typeArgs = saved }()
+			return p.parseType(arg.expr)
+		}
 		// Simple type name: int32, string, Device
 		return &schema.PrimitiveType{Name: t.Name}, nil
 
+	case *ast.IndexExpr:
+		// Generic instantiation: Page[Device], Pair[string, int32]
+		name, err := p.instantiate(t, "")
+		if err != nil {
+			return nil, err
+		}
+		return &schema.PrimitiveType{Name: name}, nil
+
 	case *ast.StarExpr:
 		// Optional type: *string, *int32
 		innerType, err := p.parseType(t.X)
@@ -121,13 +375,32 @@ func (p *schemaParser) parseType(expr ast.Expr) (schema.Type, error) {
 		case *schema.ArrayType:
 			inner.Optional = true
 			return inner, nil
+		case *schema.MapType:
+			inner.Optional = true
+			return inner, nil
 		}
 		return innerType, nil
 
 	case *ast.ArrayType:
-		// Array type: []int32, []Device
+		// Array type: []int32, []Device, or fixed-size [16]float32
 		if t.Len != nil {
-			return nil, fmt.Errorf("fixed-size arrays not supported")
+			lit, ok := t.Len.(*ast.BasicLit)
+			if !ok {
+				return nil, fmt.Errorf("%s: array length must be an integer literal", t.Pos())
+			}
+			n, err := strconv.Atoi(lit.Value)
+			if err != nil || n < 1 || n > schema.MaxFixedArrayLength {
+				return nil, fmt.Errorf("%s: array length %s must be between 1 and %d", t.Pos(), lit.Value, schema.MaxFixedArrayLength)
+			}
+			elemType, err := p.parseType(t.Elt)
+			if err != nil {
+				return nil, err
+			}
+			return &schema.ArrayType{ElementType: elemType, Length: n}, nil
+		}
+		// []byte is the Go spelling of the bytes primitive
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return &schema.PrimitiveType{Name: "bytes"}, nil
 		}
 		elemType, err := p.parseType(t.Elt)
 		if err != nil {
@@ -135,28 +408,94 @@ func (p *schemaParser) parseType(expr ast.Expr) (schema.Type, error) {
 		}
 		return &schema.ArrayType{ElementType: elemType}, nil
 
+	case *ast.MapType:
+		// Map type: map[string]int32, map[int64]Device
+		keyType, err := p.parseType(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		valueType, err := p.parseType(t.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &schema.MapType{KeyType: keyType, ValueType: valueType}, nil
+
 	case *ast.StructType:
-		// Struct type definition
+		// Anonymous struct in a field type, or a struct type definition
+		if p.anon != "" {
+			return p.liftStruct(t)
+		}
 		return p.parseStruct(t)
 
+	case *ast.SelectorExpr:
+		return nil, fmt.Errorf("%s: qualified type %s not supported", t.Pos(), typeName(t))
+
+	case *ast.InterfaceType:
+		return nil, fmt.Errorf("%s: union types must be declared by name, e.g. type Shape interface { Circle | Square }", t.Pos())
+
 	default:
-		return nil, fmt.Errorf("unsupported type: %T", expr)
+		return nil, fmt.Errorf("%s: unsupported type: %s", expr.Pos(), typeName(expr))
 	}
 }
 
 func (p *schemaParser) parseStruct(structType *ast.StructType) (*schema.StructType, error) {
 	var fields []schema.Field
 
-	for _, field := range structType.Fields.List {
+	for _, field := range structType.Fields {
 		if len(field.Names) == 0 {
-			return nil, fmt.Errorf("embedded fields not supported")
+			return nil, fmt.Errorf("%s: embedded fields not supported", field.Pos())
 		}
 
+		savedAnon, savedField := p.anon, p.anonField
+		fieldName := field.Names[0].Name
+		p.anon = p.scope + strings.ToUpper(fieldName[:1]) + fieldName[1:]
+		p.anonField = p.scope + "." + fieldName
 		fieldType, err := p.parseType(field.Type)
+		p.anon, p.anonField = savedAnon, savedField
 		if err != nil {
 			return nil, err
 		}
 
+		anns, err := parseAnnotations(field.Doc, field.Comment)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAnnotations(anns, fieldAnnotations, "field "+field.Names[0].Name); err != nil {
+			return nil, err
+		}
+		var features []string
+		var cached, large bool
+		var limits *annotation
+		for _, ann := range anns {
+			switch ann.Name {
+			case "feature":
+				if len(ann.Args) == 0 {
+					return nil, fmt.Errorf("field %s: @feature requires at least one feature name", field.Names[0].Name)
+				}
+				features = append(features, ann.Args...)
+			case "cached":
+				if len(ann.Args) != 0 {
+					return nil, fmt.Errorf("field %s: @cached takes no arguments", field.Names[0].Name)
+				}
+				cached = true
+			case "large":
+				if err := markLarge(fieldType, ann); err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Names[0].Name, err)
+				}
+				large = true
+			case "max":
+				limits = &ann
+			}
+		}
+		if cached && large {
+			return nil, fmt.Errorf("field %s: @cached and @large cannot be combined", field.Names[0].Name)
+		}
+		if limits != nil {
+			if err := markMax(fieldType, *limits); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Names[0].Name, err)
+			}
+		}
+
 		// Preserve full struct tag
 		var fullTag string
 		var jsonTag string
@@ -167,9 +506,12 @@ func (p *schemaParser) parseStruct(structType *ast.StructType) (*schema.StructTy
 
 		for _, name := range field.Names {
 			f := schema.Field{
-				Name: name.Name,
-				Type: fieldType,
-				Tag:  fullTag,
+				Name:     name.Name,
+				Type:     fieldType,
+				Tag:      fullTag,
+				Features: features,
+				Cached:   cached,
+				Doc:      docText(field.Doc, field.Comment),
 			}
 			f.SetJSONTag(jsonTag)
 			fields = append(fields, f)
@@ -179,6 +521,151 @@ func (p *schemaParser) parseStruct(structType *ast.StructType) (*schema.StructTy
 	return &schema.StructType{Fields: fields}, nil
 }
 
+// markLarge applies @large to t, giving it a uint32 length prefix. Field
+// types are parsed afresh for each field, so the flag stays with the
+// annotated field; a named type is marked on its declaration instead.
+func markLarge(t schema.Type, ann annotation) error {
+	if len(ann.Args) != 0 {
+		return fmt.Errorf("@large takes no arguments")
+	}
+	if prim, ok := t.(*schema.PrimitiveType); ok && !schema.IsPrimitive(prim.Name) {
+		return fmt.Errorf("@large on a field of named type %s: mark the declaration if it is a slice or map, or use string or bytes", prim.Name)
+	}
+	if !schema.CanBeLarge(t) {
+		return fmt.Errorf("@large applies to string, bytes, slice and map types, not %s", t.TypeName())
+	}
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		typ.Large = true
+	case *schema.ArrayType:
+		typ.Large = true
+	case *schema.MapType:
+		typ.Large = true
+	}
+	return nil
+}
+
+// markMax applies @max to t. Each limit caps one string, bytes, slice or
+// map in t, outermost first, so @max(16, 32) on a []string allows 16
+// strings of up to 32 bytes; a map's entries come before its key and
+// value. Trailing values may be left without a limit.
+func markMax(t schema.Type, ann annotation) error {
+	if len(ann.Args) == 0 {
+		return fmt.Errorf("@max requires a limit")
+	}
+	if prim, ok := t.(*schema.PrimitiveType); ok && !schema.IsPrimitive(prim.Name) {
+		return fmt.Errorf("@max on a field of named type %s: mark the declaration if it is a slice, map or union, or use string or bytes", prim.Name)
+	}
+	targets := limitTargets(t)
+	if len(ann.Args) > len(targets) {
+		return fmt.Errorf("@max has %d limits, but %s has %d strings, bytes, slices or maps", len(ann.Args), t.TypeName(), len(targets))
+	}
+	for i, arg := range ann.Args {
+		n, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil || n == 0 {
+			return fmt.Errorf("@max limits must be positive integers, not %q", arg)
+		}
+		if n > schema.PrefixLimit(targets[i]) {
+			return fmt.Errorf("@max(%d) exceeds the %d that the length prefix of %s holds; mark it @large", n, schema.PrefixLimit(targets[i]), targets[i].TypeName())
+		}
+		switch typ := targets[i].(type) {
+		case *schema.PrimitiveType:
+			typ.Max = uint32(n)
+		case *schema.ArrayType:
+			typ.Max = uint32(n)
+		case *schema.MapType:
+			typ.Max = uint32(n)
+		}
+	}
+	return nil
+}
+
+// limitTargets returns the values in t that @max can limit, outermost
+// first. Named types are not followed: their limits are on their
+// declarations.
+func limitTargets(t schema.Type) []schema.Type {
+	switch typ := t.(type) {
+	case *schema.PrimitiveType:
+		if typ.Name == "string" || typ.Name == "bytes" {
+			return []schema.Type{typ}
+		}
+	case *schema.ArrayType:
+		targets := limitTargets(typ.ElementType)
+		if typ.Length == 0 {
+			targets = append([]schema.Type{typ}, targets...)
+		}
+		return targets
+	case *schema.MapType:
+		targets := append([]schema.Type{typ}, limitTargets(typ.KeyType)...)
+		return append(targets, limitTargets(typ.ValueType)...)
+	case *schema.UnionType:
+		var targets []schema.Type
+		for _, v := range typ.Variants {
+			targets = append(targets, limitTargets(v)...)
+		}
+		return targets
+	}
+	return nil
+}
+
+// markBitmap applies @bitmap to a struct declaration, which then carries
+// the presence flags of its optional fields in a leading bitmap.
+func markBitmap(t schema.Type, ann annotation) error {
+	if len(ann.Args) != 0 {
+		return fmt.Errorf("@bitmap takes no arguments")
+	}
+	st, ok := t.(*schema.StructType)
+	if !ok {
+		return fmt.Errorf("@bitmap applies to struct declarations")
+	}
+	if schema.OptionalFields(st) == 0 {
+		return fmt.Errorf("@bitmap needs optional fields to track")
+	}
+	st.Bitmap = true
+	return nil
+}
+
+// markFramed applies @framed to a struct declaration, whose encoding then
+// starts with its length.
+func markFramed(t schema.Type, ann annotation) error {
+	if len(ann.Args) != 0 {
+		return fmt.Errorf("@framed takes no arguments")
+	}
+	st, ok := t.(*schema.StructType)
+	if !ok {
+		return fmt.Errorf("@framed applies to struct declarations")
+	}
+	st.Framed = true
+	return nil
+}
+
+// liftStruct declares an anonymous struct in a field type as a named type,
+// named after the enclosing type and the field: the struct of
+// Device.Position becomes DevicePosition. It returns a reference to the
+// new type, resolved like any other type name.
+func (p *schemaParser) liftStruct(t *ast.StructType) (schema.Type, error) {
+	name, field := p.anon, p.anonField
+	if other, ok := p.lifted[name]; ok {
+		return nil, fmt.Errorf("%s: anonymous structs of fields %s and %s are both named %s; declare one of them by name", t.Pos(), other, field, name)
+	}
+	if _, ok := p.types[name]; ok {
+		return nil, fmt.Errorf("%s: anonymous struct of field %s is named %s, which is already declared; rename the type or declare the struct", t.Pos(), field, name)
+	}
+	p.lifted[name] = field
+
+	saved := p.scope
+	p.scope = name
+	st, err := p.parseStruct(t)
+	p.scope = saved
+	if err != nil {
+		return nil, err
+	}
+	st.Name = name
+	p.types[name] = st
+	p.schema.Types = append(p.schema.Types, st)
+	return &schema.PrimitiveType{Name: name}, nil
+}
+
 func (p *schemaParser) resolveTypes() error {
 	// Resolve type references in all types and track dependencies
 	for _, typ := range p.schema.Types {
@@ -205,10 +692,17 @@ func (p *schemaParser) inferRootTypes() error {
 			continue // Skip referenced types
 		}
 
+		// Enums and unions only describe values of other types' fields
+		switch typ.(type) {
+		case *schema.EnumType, *schema.UnionType:
+			continue
+		}
+
 		// This is a root type - add to messages
 		p.schema.Messages = append(p.schema.Messages, schema.MessageType{
 			Name:       name,
 			TargetType: typ,
+			Doc:        p.docs[name],
 		})
 	}
 
@@ -224,9 +718,12 @@ func (p *schemaParser) resolveTypeReferences(typ schema.Type) error {
 	switch t := typ.(type) {
 	case *schema.StructType:
 		// Update struct name if not set
-		for name, storedType := range p.types {
-			if storedType == t && t.Name == "" {
-				t.Name = name
+		if t.Name == "" {
+			for name, storedType := range p.types {
+				if storedType == t {
+					t.Name = name
+					break
+				}
 			}
 		}
 		// Resolve field types and track references
@@ -236,9 +733,12 @@ func (p *schemaParser) resolveTypeReferences(typ schema.Type) error {
 
 			resolved, err := p.resolveTypeReference(field.Type)
 			if err != nil {
-				return err
+				return withTypeContext(err, "field", t.Name+"."+field.Name)
 			}
 			t.Fields[i].Type = resolved
+			if field.Cached && !schema.IsCacheable(resolved) {
+				return fmt.Errorf("field %s.%s: @cached requires a string or []string field", t.Name, field.Name)
+			}
 		}
 
 	case *schema.ArrayType:
@@ -250,11 +750,38 @@ func (p *schemaParser) resolveTypeReferences(typ schema.Type) error {
 			return err
 		}
 		t.ElementType = resolved
+
+	case *schema.MapType:
+		p.trackTypeReference(t.KeyType)
+		p.trackTypeReference(t.ValueType)
+
+		if _, err := p.resolveTypeReference(t); err != nil {
+			return err
+		}
+
+	case *schema.UnionType:
+		for i, v := range t.Variants {
+			p.trackTypeReference(v)
+
+			resolved, err := p.resolveTypeReference(v)
+			if err != nil {
+				return withTypeContext(err, "union", t.Name)
+			}
+			t.Variants[i] = resolved
+		}
 	}
 
 	return nil
 }
 
+// withTypeContext records where an undefined type was referenced.
+func withTypeContext(err error, key, where string) error {
+	if e, ok := err.(*errors.Error); ok {
+		return e.WithContext(key, where)
+	}
+	return err
+}
+
 // trackTypeReference marks a type as being referenced by another type
 func (p *schemaParser) trackTypeReference(typ schema.Type) {
 	switch t := typ.(type) {
@@ -270,6 +797,9 @@ func (p *schemaParser) trackTypeReference(typ schema.Type) {
 	case *schema.ArrayType:
 		// Recursively track array element types
 		p.trackTypeReference(t.ElementType)
+	case *schema.MapType:
+		p.trackTypeReference(t.KeyType)
+		p.trackTypeReference(t.ValueType)
 	}
 }
 
@@ -283,6 +813,18 @@ func (p *schemaParser) resolveTypeReference(typ schema.Type) (schema.Type, error
 		arrType.ElementType = resolved
 		return arrType, nil
 	}
+	if mapType, ok := typ.(*schema.MapType); ok {
+		key, err := p.resolveTypeReference(mapType.KeyType)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.resolveTypeReference(mapType.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		mapType.KeyType, mapType.ValueType = key, value
+		return mapType, nil
+	}
 
 	prim, ok := typ.(*schema.PrimitiveType)
 	if !ok {
@@ -297,7 +839,11 @@ func (p *schemaParser) resolveTypeReference(typ schema.Type) (schema.Type, error
 	// Look up in defined types
 	resolved, exists := p.types[prim.Name]
 	if !exists {
-		return nil, fmt.Errorf("undefined type: %s", prim.Name)
+		err := errors.Newf(errors.ErrUndefinedType, "undefined type: %s", prim.Name)
+		if suggestion := p.suggestType(prim.Name); suggestion != "" {
+			err.Message += fmt.Sprintf("; did you mean %s?", suggestion)
+		}
+		return nil, err
 	}
 
 	// Preserve optional flag
@@ -311,6 +857,18 @@ func (p *schemaParser) resolveTypeReference(typ schema.Type) (schema.Type, error
 			copy := *r
 			copy.Optional = true
 			return &copy, nil
+		case *schema.MapType:
+			copy := *r
+			copy.Optional = true
+			return &copy, nil
+		case *schema.EnumType:
+			copy := *r
+			copy.Optional = true
+			return &copy, nil
+		case *schema.UnionType:
+			copy := *r
+			copy.Optional = true
+			return &copy, nil
 		}
 	}
 
@@ -370,7 +928,27 @@ func typeName(expr ast.Expr) string {
 	case *ast.StarExpr:
 		return "*" + typeName(t.X)
 	case *ast.ArrayType:
+		if lit, ok := t.Len.(*ast.BasicLit); ok {
+			return "[" + lit.Value + "]" + typeName(t.Elt)
+		}
+		if ident, ok := t.Len.(*ast.Ident); ok {
+			return "[" + ident.Name + "]" + typeName(t.Elt)
+		}
 		return "[]" + typeName(t.Elt)
+	case *ast.MapType:
+		return "map[" + typeName(t.Key) + "]" + typeName(t.Value)
+	case *ast.IndexExpr:
+		args := make([]string, len(t.Indices))
+		for i, idx := range t.Indices {
+			args[i] = typeName(idx)
+		}
+		return typeName(t.X) + "[" + strings.Join(args, ", ") + "]"
+	case *ast.SelectorExpr:
+		return typeName(t.X) + "." + t.Sel.Name
+	case *ast.StructType:
+		return "struct{...}"
+	case *ast.InterfaceType:
+		return "interface{...}"
 	default:
 		return fmt.Sprintf("%T", expr)
 	}
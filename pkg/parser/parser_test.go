@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/shaban/ffire/pkg/ast"
+	"github.com/shaban/ffire/pkg/errors"
 	"github.com/shaban/ffire/pkg/schema"
 )
 
@@ -272,6 +276,94 @@ type Config struct {
 	if err == nil {
 		t.Fatal("Expected error for undefined type, got nil")
 	}
+	if !errors.IsCode(err, errors.ErrUndefinedType) {
+		t.Errorf("error code = %s, want %s", errors.GetCode(err), errors.ErrUndefinedType)
+	}
+	if strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("error %q suggests a type, but no type is close", err)
+	}
+}
+
+func TestErrorUndefinedTypeSuggestion(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"Paramter", "undefined type: Paramter; did you mean Parameter? (field=Plugin.Param)"},
+		{"parameter", "did you mean Parameter?"},
+		{"int23", "did you mean int32?"},
+		{"strng", "did you mean string?"},
+	}
+	for _, tt := range tests {
+		src := `package test
+
+type Plugin struct {
+	Param ` + tt.ref + `
+}
+
+type Parameter struct {
+	ID int32
+}
+`
+		_, err := ParseBytes([]byte(src))
+		if err == nil {
+			t.Fatalf("%s: expected error for undefined type", tt.ref)
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %q, want it to contain %q", tt.ref, err, tt.want)
+		}
+	}
+}
+
+func TestParseOutOfOrderTypes(t *testing.T) {
+	// Every type is used before its declaration
+	src := `package test
+
+type Rack struct {
+	Devices []Device
+	Shape   Shape
+	Index   map[string]Device
+}
+
+type Shape interface {
+	Circle | string
+}
+
+type Device struct {
+	Mode  Mode
+	Inner *Circle
+}
+
+type Circle struct {
+	R float32
+}
+
+type Mode int8
+
+const (
+	ModeA Mode = iota
+	ModeB
+)
+`
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Name != "Rack" {
+		t.Fatalf("Messages = %v, want [Rack]", s.Messages)
+	}
+	rack := s.Messages[0].TargetType.(*schema.StructType)
+	devices := rack.Fields[0].Type.(*schema.ArrayType)
+	device, ok := devices.ElementType.(*schema.StructType)
+	if !ok || device.Name != "Device" {
+		t.Fatalf("Devices element = %#v, want struct Device", devices.ElementType)
+	}
+	if _, ok := device.Fields[0].Type.(*schema.EnumType); !ok {
+		t.Errorf("Device.Mode = %#v, want enum Mode", device.Fields[0].Type)
+	}
+	if inner, ok := device.Fields[1].Type.(*schema.StructType); !ok || inner.Name != "Circle" || !inner.Optional {
+		t.Errorf("Device.Inner = %#v, want optional struct Circle", device.Fields[1].Type)
+	}
 }
 
 func TestParseStructTags(t *testing.T) {
@@ -358,3 +450,1015 @@ type User struct {
 		t.Errorf("JSONName = %q, want %q", structType.Fields[0].JSONName(), "Name")
 	}
 }
+
+func TestParseOptionalForwardReference(t *testing.T) {
+	src := `package test
+
+type Library struct {
+	Owner *User
+}
+
+type User struct {
+	Email string
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	library := s.Messages[0].TargetType.(*schema.StructType)
+	owner, ok := library.Fields[0].Type.(*schema.StructType)
+	if !ok {
+		t.Fatalf("Owner type = %T, want *schema.StructType", library.Fields[0].Type)
+	}
+	if owner.Name != "User" || !owner.Optional {
+		t.Errorf("Owner = %s (optional %v), want optional User", owner.Name, owner.Optional)
+	}
+}
+
+func TestParseEnums(t *testing.T) {
+	src := `package test
+
+type Device struct {
+	Mode     Mode
+	Fallback *Mode
+	Level    Level
+}
+
+const (
+	Mono Mode = iota + 1 // one channel
+	Stereo
+	Off Mode = -1
+)
+
+const Surround Mode = 0x10
+
+type Mode int16
+
+type Level int32
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Name != "Device" {
+		t.Fatalf("Messages = %+v, want only Device", s.Messages)
+	}
+
+	device := s.Messages[0].TargetType.(*schema.StructType)
+	mode, ok := device.Fields[0].Type.(*schema.EnumType)
+	if !ok {
+		t.Fatalf("Mode type = %T, want *schema.EnumType", device.Fields[0].Type)
+	}
+	if mode.Name != "Mode" || mode.Base != "int16" || mode.Optional {
+		t.Errorf("Mode = %+v", mode)
+	}
+	want := []schema.EnumValue{{Name: "Mono", Value: 1, Doc: "one channel"}, {Name: "Stereo", Value: 2}, {Name: "Off", Value: -1}, {Name: "Surround", Value: 16}}
+	if fmt.Sprint(mode.Values) != fmt.Sprint(want) {
+		t.Errorf("Mode values = %v, want %v", mode.Values, want)
+	}
+
+	if fallback, ok := device.Fields[1].Type.(*schema.EnumType); !ok || !fallback.Optional || fallback.Name != "Mode" {
+		t.Errorf("Fallback = %+v, want optional Mode", device.Fields[1].Type)
+	}
+
+	// Integer types without constants stay plain integers
+	if level, ok := device.Fields[2].Type.(*schema.PrimitiveType); !ok || level.Name != "int32" {
+		t.Errorf("Level = %+v, want int32", device.Fields[2].Type)
+	}
+
+	if enums := s.Enums(); len(enums) != 1 || enums[0] != mode {
+		t.Errorf("Enums() = %v, want [Mode]", enums)
+	}
+}
+
+func TestErrorEnumConstants(t *testing.T) {
+	tests := []struct {
+		name   string
+		consts string
+		want   string
+	}{
+		{"untyped", "const A = 1", "untyped constants are not supported"},
+		{"builtin type", "const A int8 = 1", "int8 is not a named type"},
+		{"struct type", "const A Device = 1", "Device is not a named integer type"},
+		{"no value", "const (\n\tA Mode\n)", "constant A: missing value"},
+		{"redeclared", "const (\n\tA Mode = 1\n\tA Mode = 2\n)", "constant A redeclared"},
+		{"unsupported value", "const A Mode = B", "unsupported value B"},
+		{"overflow", "const A Mode = 9223372036854775807 + 1", "overflows int64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package test\n\ntype Mode int8\n\ntype Device struct {\n\tMode Mode\n}\n\n" + tt.consts + "\n"
+			_, err := ParseBytes([]byte(src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUnions(t *testing.T) {
+	src := `package test
+
+type Drawing struct {
+	Shape  Shape
+	Hint   *Shape
+	Result Result[Circle]
+}
+
+type Shape interface {
+	Circle | Square | string
+}
+
+type Result[T any] interface{ T | Failure }
+
+type Circle struct {
+	R float32
+}
+
+type Square struct {
+	Side float32
+}
+
+type Failure struct {
+	Reason string
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Name != "Drawing" {
+		t.Fatalf("Messages = %+v, want only Drawing", s.Messages)
+	}
+
+	drawing := s.Messages[0].TargetType.(*schema.StructType)
+	shape, ok := drawing.Fields[0].Type.(*schema.UnionType)
+	if !ok {
+		t.Fatalf("Shape type = %T, want *schema.UnionType", drawing.Fields[0].Type)
+	}
+	var names []string
+	for _, v := range shape.Variants {
+		names = append(names, v.TypeName())
+	}
+	if got := strings.Join(names, " "); got != "Circle Square string" || shape.Optional {
+		t.Errorf("Shape = %s (optional %v), want Circle Square string", got, shape.Optional)
+	}
+	if _, ok := shape.Variants[0].(*schema.StructType); !ok {
+		t.Errorf("Circle variant = %T, want resolved *schema.StructType", shape.Variants[0])
+	}
+
+	if hint, ok := drawing.Fields[1].Type.(*schema.UnionType); !ok || !hint.Optional || hint.Name != "Shape" {
+		t.Errorf("Hint = %+v, want optional Shape", drawing.Fields[1].Type)
+	}
+
+	result, ok := drawing.Fields[2].Type.(*schema.UnionType)
+	if !ok || result.Name != "ResultCircle" || len(result.Variants) != 2 || result.Variants[0].TypeName() != "Circle" {
+		t.Errorf("Result = %+v, want ResultCircle{Circle | Failure}", drawing.Fields[2].Type)
+	}
+
+	// Inline instantiations are materialized where they are first used
+	if unions := s.Unions(); len(unions) != 2 || unions[0].Name != "ResultCircle" || unions[1] != shape {
+		t.Errorf("Unions() = %v, want [ResultCircle Shape]", unions)
+	}
+}
+
+func TestErrorInlineUnion(t *testing.T) {
+	src := "package test\n\ntype Drawing struct {\n\tShape interface{ int32 | string }\n}\n"
+	_, err := ParseBytes([]byte(src))
+	if err == nil || !strings.Contains(err.Error(), "union types must be declared by name") {
+		t.Errorf("error = %v, want inline union rejected", err)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	src := `package test
+
+type Blob struct {
+	Data   bytes
+	Raw    []byte
+	Thumb  *bytes
+	Chunks [][]byte
+}
+`
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	fields := s.Messages[0].TargetType.(*schema.StructType).Fields
+	for _, f := range fields[:3] {
+		prim, ok := f.Type.(*schema.PrimitiveType)
+		if !ok || prim.Name != "bytes" {
+			t.Errorf("%s type = %#v, want bytes", f.Name, f.Type)
+		}
+	}
+	if !fields[2].Type.IsOptional() {
+		t.Error("Thumb should be optional")
+	}
+	arr, ok := fields[3].Type.(*schema.ArrayType)
+	if !ok {
+		t.Fatalf("Chunks type = %T, want *schema.ArrayType", fields[3].Type)
+	}
+	if prim, ok := arr.ElementType.(*schema.PrimitiveType); !ok || prim.Name != "bytes" {
+		t.Errorf("Chunks element = %#v, want bytes", arr.ElementType)
+	}
+}
+
+func TestParseIgnoresImports(t *testing.T) {
+	src := `package test
+
+import "time"
+
+import (
+	str "strings"
+)
+
+type Event struct {
+	At int64
+}
+`
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(s.Types) != 1 || len(s.Messages) != 1 || s.Messages[0].Name != "Event" {
+		t.Errorf("types = %d, messages = %+v, want only Event", len(s.Types), s.Messages)
+	}
+}
+
+func TestParseFixedArray(t *testing.T) {
+	src := `package test
+
+type Frame struct {
+	Samples [16]float32
+	Flags   [3]bool
+	Taps    [][4]int16
+}
+`
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	fields := s.Messages[0].TargetType.(*schema.StructType).Fields
+	for i, want := range []string{"[16]float32", "[3]bool", "[][4]int16"} {
+		if got := fields[i].Type.TypeName(); got != want {
+			t.Errorf("%s type = %s, want %s", fields[i].Name, got, want)
+		}
+	}
+	if arr := fields[0].Type.(*schema.ArrayType); arr.Length != 16 {
+		t.Errorf("Samples length = %d, want 16", arr.Length)
+	}
+	if arr := fields[2].Type.(*schema.ArrayType); arr.Length != 0 {
+		t.Errorf("Taps length = %d, want 0 for a slice", arr.Length)
+	}
+}
+
+func TestErrorFixedArrayLength(t *testing.T) {
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"[N]float32", "array length must be an integer literal"},
+		{"[0]float32", "array length 0 must be between 1 and 65535"},
+		{"[65536]float32", "array length 65536 must be between 1 and 65535"},
+	}
+	for _, tt := range tests {
+		src := "package test\n\ntype Frame struct {\n\tSamples " + tt.field + "\n}\n"
+		_, err := ParseBytes([]byte(src))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.field, err, tt.want)
+		}
+	}
+}
+
+func TestParseGenericNamedInstantiation(t *testing.T) {
+	src := `package test
+
+type DevicePage Page[Device]
+
+type Page[T any] struct {
+	Items  []T
+	Cursor string
+	Total  int32
+}
+
+type Device struct {
+	Name string
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(s.Messages) != 1 || s.Messages[0].Name != "DevicePage" {
+		t.Fatalf("Messages = %+v, want single DevicePage root", s.Messages)
+	}
+
+	page, ok := s.Messages[0].TargetType.(*schema.StructType)
+	if !ok {
+		t.Fatalf("DevicePage type = %T, want *schema.StructType", s.Messages[0].TargetType)
+	}
+	if page.Name != "DevicePage" {
+		t.Errorf("Struct name = %q, want %q", page.Name, "DevicePage")
+	}
+
+	items, ok := page.Fields[0].Type.(*schema.ArrayType)
+	if !ok {
+		t.Fatalf("Items type = %T, want *schema.ArrayType", page.Fields[0].Type)
+	}
+	if elem, ok := items.ElementType.(*schema.StructType); !ok || elem.Name != "Device" {
+		t.Errorf("Items element = %v, want Device struct", items.ElementType)
+	}
+
+	// The template itself must not become a type
+	if s.FindType("Page") != nil {
+		t.Error("generic template Page should not be emitted as a type")
+	}
+}
+
+func TestParseGenericInlineInstantiation(t *testing.T) {
+	src := `package test
+
+type Pair[K any, V any] struct {
+	Key   K
+	Value V
+}
+
+type Catalog struct {
+	Labels  []Pair[string, int32]
+	Primary *Pair[string, int32]
+	Ids     Pair[int64, []string]
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(s.Messages) != 1 || s.Messages[0].Name != "Catalog" {
+		t.Fatalf("Messages = %+v, want single Catalog root", s.Messages)
+	}
+
+	// One instance per distinct argument list
+	if s.FindType("PairStringInt32") == nil {
+		t.Error("missing instance PairStringInt32")
+	}
+	if s.FindType("PairInt64StringList") == nil {
+		t.Error("missing instance PairInt64StringList")
+	}
+	if len(s.Types) != 3 {
+		t.Errorf("len(Types) = %d, want 3 (Catalog + 2 instances)", len(s.Types))
+	}
+
+	catalog := s.Messages[0].TargetType.(*schema.StructType)
+	primary, ok := catalog.Fields[1].Type.(*schema.StructType)
+	if !ok || !primary.Optional || primary.Name != "PairStringInt32" {
+		t.Errorf("Primary = %+v, want optional PairStringInt32", catalog.Fields[1].Type)
+	}
+}
+
+func TestErrorGenericArity(t *testing.T) {
+	src := `package test
+
+type Page[T any] struct {
+	Items []T
+}
+
+type Bad struct {
+	P Page[int32, string]
+}
+`
+
+	if _, err := ParseBytes([]byte(src)); err == nil {
+		t.Fatal("Expected error for wrong number of type arguments, got nil")
+	}
+}
+
+func TestParseGenericNameClash(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"declared type", `package test
+
+type Page[T any] struct { Items []T }
+
+type Catalog struct {
+	A Page[int32]
+}
+
+type PageInt32 struct { X int8 }
+`, "Page[int32] is named PageInt32, which is already declared"},
+		{"two instantiations", `package test
+
+type Pair[K any, V any] struct {
+	Key   K
+	Value V
+}
+
+type int8List []int8
+
+type Catalog struct {
+	A Pair[int8List, int32]
+	B Pair[[]int8, int32]
+}
+`, "Pair[int8List, int32] and Pair[[]int8, int32] are both named PairInt8ListInt32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBytes([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeatureAnnotations(t *testing.T) {
+	src := `package test
+
+type Config struct {
+	Host string
+	// @feature("v2")
+	Tracing *bool
+	Region  string // @feature("enterprise", "v2")
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	st := s.Messages[0].TargetType.(*schema.StructType)
+	if len(st.Fields[0].Features) != 0 {
+		t.Errorf("Host features = %v, want none", st.Fields[0].Features)
+	}
+	if got := st.Fields[1].Features; len(got) != 1 || got[0] != "v2" {
+		t.Errorf("Tracing features = %v, want [v2]", got)
+	}
+	if got := st.Fields[2].Features; len(got) != 2 || got[0] != "enterprise" || got[1] != "v2" {
+		t.Errorf("Region features = %v, want [enterprise v2]", got)
+	}
+}
+
+func TestParseCachedAnnotation(t *testing.T) {
+	src := `package test
+
+type Label = string
+
+type Metric struct {
+	// @cached
+	Name  string
+	Host  *string  // @cached
+	Tags  []string // @cached
+	Alias Label    // @cached
+	Value float64
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	st := s.Messages[0].TargetType.(*schema.StructType)
+	for _, f := range st.Fields {
+		if want := f.Name != "Value"; f.Cached != want {
+			t.Errorf("%s.Cached = %v, want %v", f.Name, f.Cached, want)
+		}
+	}
+	if !s.HasCachedFields() {
+		t.Error("HasCachedFields() = false, want true")
+	}
+
+	for _, bad := range []string{
+		"Count int32 // @cached",
+		"Data bytes // @cached",
+		`Name string // @cached("x")`,
+	} {
+		src := "package test\n\ntype Metric struct {\n\t" + bad + "\n}\n"
+		if _, err := ParseBytes([]byte(src)); err == nil {
+			t.Errorf("%s: expected error, got nil", bad)
+		}
+	}
+}
+
+func TestParseLargeAnnotation(t *testing.T) {
+	src := `package test
+
+// @large
+type Samples []float32
+
+type Recording struct {
+	// @large
+	Audio bytes
+	Notes *string // @large
+	// @large
+	Tags  map[string]int32
+	Wave  Samples
+	Title string
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var st *schema.StructType
+	for _, msg := range s.Messages {
+		if msg.Name == "Recording" {
+			st = msg.TargetType.(*schema.StructType)
+		}
+	}
+	if st == nil {
+		t.Fatal("Recording message not found")
+	}
+	for _, f := range st.Fields {
+		if want := f.Name != "Title"; schema.IsLarge(f.Type) != want {
+			t.Errorf("%s is large = %v, want %v", f.Name, schema.IsLarge(f.Type), want)
+		}
+	}
+	if !s.HasLarge() {
+		t.Error("HasLarge() = false, want true")
+	}
+
+	for _, bad := range []string{
+		"type Metric struct {\n\tCount int32 // @large\n}",
+		"type Metric struct {\n\tPos [3]float32 // @large\n}",
+		"type Metric struct {\n\tName string // @large(\"x\")\n}",
+		"type Metric struct {\n\t// @large\n\tName string // @cached\n}",
+		"// @large\ntype Label string\n\ntype Metric struct {\n\tName Label\n}",
+		"type Samples []float32\n\ntype Metric struct {\n\tWave Samples // @large\n}",
+		"// @large\ntype Metric struct {\n\tName string\n}",
+	} {
+		if _, err := ParseBytes([]byte("package test\n\n" + bad + "\n")); err == nil {
+			t.Errorf("%q: expected error, got nil", bad)
+		}
+	}
+}
+
+func TestParseMaxAnnotation(t *testing.T) {
+	src := `package test
+
+// @max(8, 16)
+type Tags []string
+
+// @max(24)
+type Value interface {
+	int32 | string
+}
+
+type Reading struct {
+	Name string // @max(32)
+	// @large
+	// @max(70000)
+	Blob   *bytes
+	Labels map[string][]int32 // @max(4, 12, 3)
+	Pos    [3]float32
+	Tags   Tags
+	Value  Value
+	Notes  []string // @max(2)
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	fields := map[string]schema.Type{}
+	for _, msg := range s.Messages {
+		if msg.Name == "Reading" {
+			for _, f := range msg.TargetType.(*schema.StructType).Fields {
+				fields[f.Name] = f.Type
+			}
+		}
+	}
+	labels := fields["Labels"].(*schema.MapType)
+	tags := fields["Tags"].(*schema.ArrayType)
+	notes := fields["Notes"].(*schema.ArrayType)
+	value := fields["Value"].(*schema.UnionType)
+	for _, tt := range []struct {
+		name string
+		typ  schema.Type
+		want uint64
+	}{
+		{"Name", fields["Name"], 32},
+		{"Blob", fields["Blob"], 70000},
+		{"Labels", labels, 4},
+		{"Labels key", labels.KeyType, 12},
+		{"Labels value", labels.ValueType, 3},
+		{"Tags", tags, 8},
+		{"Tags element", tags.ElementType, 16},
+		{"Value string", value.Variants[1], 24},
+		{"Notes", notes, 2},
+		{"Notes element", notes.ElementType, schema.MaxLength},
+	} {
+		if got := schema.MaxLengthOf(tt.typ); got != tt.want {
+			t.Errorf("MaxLengthOf(%s) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"type Metric struct {\n\tCount int32 // @max(4)\n}",
+		"type Metric struct {\n\tName string // @max\n}",
+		"type Metric struct {\n\tName string // @max(0)\n}",
+		"type Metric struct {\n\tName string // @max(\"x\")\n}",
+		"type Metric struct {\n\tName string // @max(70000)\n}",
+		"type Metric struct {\n\tNames []string // @max(2, 3, 4)\n}",
+		"type Metric struct {\n\tPos [3]float32 // @max(3)\n}",
+		"// @max(4)\ntype Label string\n\ntype Metric struct {\n\tName Label\n}",
+		"type Tags []string\n\ntype Metric struct {\n\tTags Tags // @max(4)\n}",
+		"// @max(4)\ntype Metric struct {\n\tName string\n}",
+	} {
+		if _, err := ParseBytes([]byte("package test\n\n" + bad + "\n")); err == nil {
+			t.Errorf("%q: expected error, got nil", bad)
+		}
+	}
+}
+
+func TestParseBitmapAnnotation(t *testing.T) {
+	src := `package test
+
+type Page[T any] struct {
+	Items []T
+	Next  *string
+}
+
+// @bitmap
+type Track struct {
+	Title  string
+	Artist *string
+	Year   *int16
+}
+
+type Album struct {
+	Tracks []Track
+	Cover  *bytes
+	Main   *Track
+}
+
+// @bitmap
+type TrackPage Page[Track]
+
+type AlbumPage Page[Album]
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	bitmap := map[string]bool{}
+	for _, typ := range s.Types {
+		if st, ok := typ.(*schema.StructType); ok {
+			bitmap[st.Name] = st.Bitmap
+		}
+	}
+	want := map[string]bool{"Track": true, "Album": false, "TrackPage": true, "AlbumPage": false}
+	for name, b := range want {
+		if bitmap[name] != b {
+			t.Errorf("%s.Bitmap = %v, want %v", name, bitmap[name], b)
+		}
+	}
+	// An optional reference copies the struct, flag included
+	for _, typ := range s.Types {
+		if st, ok := typ.(*schema.StructType); ok && st.Name == "Album" {
+			for _, f := range st.Fields {
+				if f.Name == "Main" && !f.Type.(*schema.StructType).Bitmap {
+					t.Error("Album.Main lost the @bitmap flag of Track")
+				}
+			}
+		}
+	}
+	if !s.HasBitmaps() {
+		t.Error("HasBitmaps() = false, want true")
+	}
+
+	for _, bad := range []string{
+		"// @bitmap\ntype Metric struct {\n\tName string\n}",
+		"// @bitmap(8)\ntype Metric struct {\n\tName *string\n}",
+		"// @bitmap\ntype Metrics []*int32",
+		"type Metric struct {\n\tName *string // @bitmap\n}",
+	} {
+		if _, err := ParseBytes([]byte("package test\n\n" + bad + "\n")); err == nil {
+			t.Errorf("%q: expected error, got nil", bad)
+		}
+	}
+}
+
+func TestParseFramedAnnotation(t *testing.T) {
+	src := `package test
+
+type Page[T any] struct {
+	Items []T
+}
+
+// @framed
+type Track struct {
+	Title string
+}
+
+type Album struct {
+	Tracks []Track
+	Main   *Track
+}
+
+// @framed
+type TrackPage Page[Track]
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	framed := map[string]bool{}
+	for _, typ := range s.Types {
+		if st, ok := typ.(*schema.StructType); ok {
+			framed[st.Name] = st.Framed
+			if st.Name == "Album" && !st.Fields[1].Type.(*schema.StructType).Framed {
+				t.Error("Album.Main lost the @framed flag of Track")
+			}
+		}
+	}
+	want := map[string]bool{"Track": true, "Album": false, "TrackPage": true}
+	for name, f := range want {
+		if framed[name] != f {
+			t.Errorf("%s.Framed = %v, want %v", name, framed[name], f)
+		}
+	}
+	if !s.HasFramed() {
+		t.Error("HasFramed() = false, want true")
+	}
+
+	for _, bad := range []string{
+		"// @framed(4)\ntype Metric struct {\n\tName string\n}",
+		"// @framed\ntype Metrics []int32",
+		"type Metric struct {\n\tName string // @framed\n}",
+	} {
+		if _, err := ParseBytes([]byte("package test\n\n" + bad + "\n")); err == nil {
+			t.Errorf("%q: expected error, got nil", bad)
+		}
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	src := `package test
+
+type Config struct {
+	Host string
+	// @feature("v2")
+	Tracing *bool
+	Region  string // @feature("enterprise")
+}
+`
+
+	s, err := ParseReader(strings.NewReader(src), Options{Features: []string{"v2"}, Validate: true})
+	if err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+	st := s.Messages[0].TargetType.(*schema.StructType)
+	if len(st.Fields) != 2 || st.Fields[0].Name != "Host" || st.Fields[1].Name != "Tracing" {
+		t.Errorf("fields = %v, want Host and Tracing", st.Fields)
+	}
+
+	// Validation runs after the features are applied, and is opt-in
+	flagged := "package test\n\ntype Config struct {\n\tTracing *bool // @feature(\"v2\")\n}\n"
+	if _, err := ParseReader(strings.NewReader(flagged), Options{}); err != nil {
+		t.Errorf("ParseReader without Validate: %v", err)
+	}
+	if _, err := ParseReader(strings.NewReader(flagged), Options{Validate: true}); err == nil {
+		t.Error("ParseReader with Validate accepted a struct whose only field was stripped")
+	}
+}
+
+func TestErrorUnknownAnnotation(t *testing.T) {
+	src := `package test
+
+type Config struct {
+	// @featur("v2")
+	Host string
+}
+`
+
+	if _, err := ParseBytes([]byte(src)); err == nil {
+		t.Fatal("Expected error for unknown annotation, got nil")
+	}
+}
+
+func TestParseVersionAnnotation(t *testing.T) {
+	src := `// Audio plugin schema.
+// @version("1.4.0")
+package test
+
+type Config struct {
+	Host string
+}
+`
+
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if s.Version != "1.4.0" {
+		t.Errorf("Version = %q, want 1.4.0", s.Version)
+	}
+
+	for _, bad := range []string{`@version("1.4")`, `@version()`, `@versoin("1.4.0")`} {
+		src := "// " + bad + "\npackage test\n\ntype Config struct {\n\tHost string\n}\n"
+		if _, err := ParseBytes([]byte(src)); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestFromASTAfterRewrite(t *testing.T) {
+	src := `package test
+
+type Device struct {
+	ID int32
+}
+
+type DeviceList []Device
+`
+	file, err := ast.Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "Device" {
+			id.Name = "AudioDevice"
+		}
+		return true
+	})
+
+	s, err := FromAST(file)
+	if err != nil {
+		t.Fatalf("FromAST failed: %v", err)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Name != "DeviceList" {
+		t.Fatalf("Messages = %v, want [DeviceList]", s.Messages)
+	}
+	arr := s.Messages[0].TargetType.(*schema.ArrayType)
+	if st, ok := arr.ElementType.(*schema.StructType); !ok || st.Name != "AudioDevice" {
+		t.Errorf("element type = %v, want struct AudioDevice", arr.ElementType)
+	}
+}
+
+func TestParseAnonymousStructs(t *testing.T) {
+	src := `package test
+
+type Order struct {
+	ID       int64
+	Shipping struct {
+		Street string
+		Geo    *struct {
+			Lat float64
+		}
+	}
+	Lines []struct {
+		SKU string
+	}
+	Meta map[string]struct{ Note string }
+}
+`
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Name != "Order" {
+		t.Fatalf("Messages = %v, want [Order]", s.Messages)
+	}
+	for _, name := range []string{"OrderShipping", "OrderShippingGeo", "OrderLines", "OrderMeta"} {
+		if _, ok := s.FindType(name).(*schema.StructType); !ok {
+			t.Errorf("no struct %s", name)
+		}
+	}
+
+	order := s.Messages[0].TargetType.(*schema.StructType)
+	shipping := order.Fields[1].Type.(*schema.StructType)
+	if shipping.Name != "OrderShipping" {
+		t.Errorf("Order.Shipping = %s, want OrderShipping", shipping.Name)
+	}
+	geo := shipping.Fields[1].Type.(*schema.StructType)
+	if geo.Name != "OrderShippingGeo" || !geo.Optional {
+		t.Errorf("OrderShipping.Geo = %s (optional %v), want optional OrderShippingGeo", geo.Name, geo.Optional)
+	}
+	if lines := order.Fields[2].Type.(*schema.ArrayType); lines.ElementType.TypeName() != "OrderLines" {
+		t.Errorf("Order.Lines = %s, want []OrderLines", lines.TypeName())
+	}
+}
+
+func TestParseAnonymousStructNameClash(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"declared before", `package test
+
+type OrderShipping struct { Street string }
+
+type Order struct {
+	Shipping struct { Street string }
+	Other    OrderShipping
+}
+`, "anonymous struct of field Order.Shipping is named OrderShipping, which is already declared"},
+		{"declared after", `package test
+
+type Order struct {
+	Shipping struct { Street string }
+	Other    OrderShipping
+}
+
+type OrderShipping struct { Street string }
+`, "type OrderShipping has the name of the anonymous struct of field Order.Shipping"},
+		{"two anonymous", `package test
+
+type Order struct {
+	ShippingGeo struct { Lat float64 }
+	Shipping    struct {
+		Geo struct { Lat float64 }
+	}
+}
+`, "anonymous structs of fields Order.ShippingGeo and OrderShipping.Geo are both named OrderShippingGeo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseBytes([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDocComments(t *testing.T) {
+	src := `package test
+
+// Device is an audio device.
+//
+// Devices are listed by the host.
+type Device struct {
+	// Name is shown to users.
+	Name string
+	ID   int32 // Stable across restarts
+	// @cached
+	Vendor string
+	// Kind of device.
+	// @feature("v2")
+	Kind Kind
+}
+
+type (
+	// Kind classifies devices.
+	Kind int8
+
+	// Shape is a union.
+	Shape interface{ Device | Kind }
+)
+
+// Kinds
+const (
+	// Input records audio.
+	Input Kind = 0
+	Output Kind = 1 // Plays audio
+)
+
+type DeviceList []Device // Every device
+`
+	s, err := ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+
+	device := s.FindType("Device").(*schema.StructType)
+	if want := "Device is an audio device.\n\nDevices are listed by the host."; device.Doc != want {
+		t.Errorf("Device doc = %q, want %q", device.Doc, want)
+	}
+	for i, want := range []string{"Name is shown to users.", "Stable across restarts", "", "Kind of device."} {
+		if doc := device.Fields[i].Doc; doc != want {
+			t.Errorf("field %s doc = %q, want %q", device.Fields[i].Name, doc, want)
+		}
+	}
+
+	kind := s.FindType("Kind").(*schema.EnumType)
+	if kind.Doc != "Kind classifies devices." {
+		t.Errorf("Kind doc = %q", kind.Doc)
+	}
+	if kind.Values[0].Doc != "Input records audio." || kind.Values[1].Doc != "Plays audio" {
+		t.Errorf("enum value docs = %q, %q", kind.Values[0].Doc, kind.Values[1].Doc)
+	}
+	if doc := s.FindType("Shape").(*schema.UnionType).Doc; doc != "Shape is a union." {
+		t.Errorf("Shape doc = %q", doc)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Doc != "Every device" {
+		t.Errorf("Messages = %+v, want DeviceList documented", s.Messages)
+	}
+}
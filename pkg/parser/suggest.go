@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// suggestType returns the declared or built-in type name closest to an
+// undefined name, or "" if none is close enough to be a likely typo.
+func (p *schemaParser) suggestType(name string) string {
+	candidates := schema.PrimitiveNames()
+	for declared := range p.types {
+		candidates = append(candidates, declared)
+	}
+	sort.Strings(candidates)
+
+	best, bestDist := "", len(name)/3+1
+	for _, c := range candidates {
+		if strings.EqualFold(c, name) {
+			return c
+		}
+		if d := editDistance(strings.ToLower(c), strings.ToLower(name)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b: the number of single-byte insertions, deletions, substitutions and
+// adjacent transpositions that turn one into the other.
+func editDistance(a, b string) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
@@ -0,0 +1,264 @@
+package protoc
+
+import "fmt"
+
+// Field numbers and enum values below follow google/protobuf/descriptor.proto
+// and google/protobuf/compiler/plugin.proto.
+
+// Field types (FieldDescriptorProto.Type).
+const (
+	typeDouble   = 1
+	typeFloat    = 2
+	typeInt64    = 3
+	typeUint64   = 4
+	typeInt32    = 5
+	typeFixed64  = 6
+	typeFixed32  = 7
+	typeBool     = 8
+	typeString   = 9
+	typeGroup    = 10
+	typeMessage  = 11
+	typeBytes    = 12
+	typeUint32   = 13
+	typeEnum     = 14
+	typeSfixed32 = 15
+	typeSfixed64 = 16
+	typeSint32   = 17
+	typeSint64   = 18
+)
+
+// Field labels (FieldDescriptorProto.Label).
+const (
+	labelOptional = 1
+	labelRequired = 2
+	labelRepeated = 3
+)
+
+// featureProto3Optional is CodeGeneratorResponse.Feature.FEATURE_PROTO3_OPTIONAL.
+const featureProto3Optional = 1
+
+// Request is the subset of CodeGeneratorRequest used by the plugin.
+type Request struct {
+	FilesToGenerate []string
+	Parameter       string
+	ProtoFiles      []*FileDescriptor // All files, dependencies first
+}
+
+// FileDescriptor is the subset of FileDescriptorProto used by the plugin.
+type FileDescriptor struct {
+	Name     string
+	Package  string
+	Syntax   string // "proto2" (or empty) or "proto3"
+	Messages []*MessageDescriptor
+	Enums    []string
+}
+
+// MessageDescriptor is the subset of DescriptorProto used by the plugin.
+type MessageDescriptor struct {
+	Name     string
+	Fields   []*FieldDescriptor
+	Nested   []*MessageDescriptor
+	Enums    []string
+	MapEntry bool
+}
+
+// FieldDescriptor is the subset of FieldDescriptorProto used by the plugin.
+type FieldDescriptor struct {
+	Name           string
+	Number         int32
+	Label          int
+	Type           int
+	TypeName       string // Fully qualified, e.g. ".acme.Device"
+	JSONName       string
+	InOneof        bool
+	Proto3Optional bool
+}
+
+// ParseRequest decodes an encoded CodeGeneratorRequest.
+func ParseRequest(data []byte) (*Request, error) {
+	req := &Request{}
+	r := &wireReader{data: data}
+	for {
+		field, _, _, payload, ok, err := r.next()
+		if err != nil {
+			return nil, fmt.Errorf("decode CodeGeneratorRequest: %w", err)
+		}
+		if !ok {
+			return req, nil
+		}
+		switch field {
+		case 1:
+			req.FilesToGenerate = append(req.FilesToGenerate, string(payload))
+		case 2:
+			req.Parameter = string(payload)
+		case 15:
+			file, err := parseFile(payload)
+			if err != nil {
+				return nil, err
+			}
+			req.ProtoFiles = append(req.ProtoFiles, file)
+		}
+	}
+}
+
+func parseFile(data []byte) (*FileDescriptor, error) {
+	file := &FileDescriptor{}
+	r := &wireReader{data: data}
+	for {
+		field, _, _, payload, ok, err := r.next()
+		if err != nil {
+			return nil, fmt.Errorf("decode FileDescriptorProto: %w", err)
+		}
+		if !ok {
+			return file, nil
+		}
+		switch field {
+		case 1:
+			file.Name = string(payload)
+		case 2:
+			file.Package = string(payload)
+		case 4:
+			msg, err := parseMessage(payload)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file.Name, err)
+			}
+			file.Messages = append(file.Messages, msg)
+		case 5:
+			name, err := parseName(payload)
+			if err != nil {
+				return nil, err
+			}
+			file.Enums = append(file.Enums, name)
+		case 12:
+			file.Syntax = string(payload)
+		}
+	}
+}
+
+func parseMessage(data []byte) (*MessageDescriptor, error) {
+	msg := &MessageDescriptor{}
+	r := &wireReader{data: data}
+	for {
+		field, _, _, payload, ok, err := r.next()
+		if err != nil {
+			return nil, fmt.Errorf("decode DescriptorProto: %w", err)
+		}
+		if !ok {
+			return msg, nil
+		}
+		switch field {
+		case 1:
+			msg.Name = string(payload)
+		case 2:
+			f, err := parseField(payload)
+			if err != nil {
+				return nil, err
+			}
+			msg.Fields = append(msg.Fields, f)
+		case 3:
+			nested, err := parseMessage(payload)
+			if err != nil {
+				return nil, err
+			}
+			msg.Nested = append(msg.Nested, nested)
+		case 4:
+			name, err := parseName(payload)
+			if err != nil {
+				return nil, err
+			}
+			msg.Enums = append(msg.Enums, name)
+		case 7:
+			// MessageOptions.map_entry = 7
+			opts := &wireReader{data: payload}
+			for {
+				optField, _, value, _, ok, err := opts.next()
+				if err != nil {
+					return nil, fmt.Errorf("decode MessageOptions: %w", err)
+				}
+				if !ok {
+					break
+				}
+				if optField == 7 {
+					msg.MapEntry = value != 0
+				}
+			}
+		}
+	}
+}
+
+func parseField(data []byte) (*FieldDescriptor, error) {
+	f := &FieldDescriptor{}
+	r := &wireReader{data: data}
+	for {
+		field, _, value, payload, ok, err := r.next()
+		if err != nil {
+			return nil, fmt.Errorf("decode FieldDescriptorProto: %w", err)
+		}
+		if !ok {
+			return f, nil
+		}
+		switch field {
+		case 1:
+			f.Name = string(payload)
+		case 3:
+			f.Number = int32(value)
+		case 4:
+			f.Label = int(value)
+		case 5:
+			f.Type = int(value)
+		case 6:
+			f.TypeName = string(payload)
+		case 9:
+			f.InOneof = true
+		case 10:
+			f.JSONName = string(payload)
+		case 17:
+			f.Proto3Optional = value != 0
+		}
+	}
+}
+
+// parseName extracts field 1 (name) from a descriptor such as EnumDescriptorProto.
+func parseName(data []byte) (string, error) {
+	r := &wireReader{data: data}
+	for {
+		field, _, _, payload, ok, err := r.next()
+		if err != nil {
+			return "", fmt.Errorf("decode descriptor name: %w", err)
+		}
+		if !ok {
+			return "", nil
+		}
+		if field == 1 {
+			return string(payload), nil
+		}
+	}
+}
+
+// Response is the subset of CodeGeneratorResponse written by the plugin.
+type Response struct {
+	Error string // Non-empty if generation failed
+	Files []File
+}
+
+// File is a single generated output file.
+type File struct {
+	Name    string
+	Content string
+}
+
+// Marshal encodes the response as a CodeGeneratorResponse.
+func (resp *Response) Marshal() []byte {
+	w := &wireWriter{}
+	if resp.Error != "" {
+		w.string(1, resp.Error)
+	}
+	w.uint(2, featureProto3Optional)
+	for _, f := range resp.Files {
+		fw := &wireWriter{}
+		fw.string(1, f.Name)
+		fw.string(15, f.Content)
+		w.bytes(15, fw.buf)
+	}
+	return w.buf
+}
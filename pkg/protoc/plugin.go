@@ -0,0 +1,356 @@
+// Package protoc implements protoc-gen-ffire, a protoc plugin that converts
+// protobuf message definitions into ffire schemas and, optionally, generated
+// ffire code. It lets protoc-driven build pipelines adopt ffire one message
+// set at a time.
+//
+// Usage:
+//
+//	protoc --ffire_out=gen/ api.proto                 # emits api.ffi
+//	protoc --ffire_out=lang=go:gen/ api.proto         # emits api.ffi and api.ffire.go
+//	protoc --ffire_out=lang=cpp,package=api:gen/ api.proto
+package protoc
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/shaban/ffire/pkg/generator"
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+// Options are the plugin parameters passed via --ffire_out=key=value,...:dir.
+type Options struct {
+	Lang    string // "" (schema only), "go" or "cpp"
+	Package string // ffire package name override
+}
+
+// ParseOptions parses the comma-separated plugin parameter string.
+func ParseOptions(parameter string) (Options, error) {
+	var opts Options
+	for _, kv := range strings.Split(parameter, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(kv, "=")
+		switch key {
+		case "lang":
+			switch value {
+			case "go", "cpp":
+				opts.Lang = value
+			default:
+				return opts, fmt.Errorf("unsupported lang %q (supported: go, cpp)", value)
+			}
+		case "package":
+			opts.Package = value
+		default:
+			return opts, fmt.Errorf("unknown parameter %q (supported: lang, package)", key)
+		}
+	}
+	return opts, nil
+}
+
+// Generate converts each requested proto file into an ffire schema. Errors
+// are reported through Response.Error as protoc expects.
+func Generate(req *Request) *Response {
+	files, err := generate(req)
+	if err != nil {
+		return &Response{Error: err.Error()}
+	}
+	return &Response{Files: files}
+}
+
+func generate(req *Request) ([]File, error) {
+	opts, err := ParseOptions(req.Parameter)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := newIndex(req.ProtoFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, name := range req.FilesToGenerate {
+		file := idx.files[name]
+		if file == nil {
+			return nil, fmt.Errorf("%s: file not found in request", name)
+		}
+
+		src, err := idx.schemaSource(file, opts.Package)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		base := strings.TrimSuffix(name, ".proto")
+		files = append(files, File{Name: base + ".ffi", Content: src})
+
+		if opts.Lang == "" {
+			continue
+		}
+
+		code, err := generateCode(src, opts.Lang)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		ext := ".ffire.go"
+		if opts.Lang == "cpp" {
+			ext = ".ffire.hpp"
+		}
+		files = append(files, File{Name: base + ext, Content: string(code)})
+	}
+
+	return files, nil
+}
+
+// generateCode runs the emitted schema through the regular ffire pipeline.
+func generateCode(src, lang string) ([]byte, error) {
+	s, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("parse generated schema: %w", err)
+	}
+	if err := validator.ValidateSchema(s); err != nil {
+		return nil, fmt.Errorf("validate generated schema: %w", err)
+	}
+	s.Canonicalize()
+
+	switch lang {
+	case "cpp":
+		return generator.GenerateCpp(s)
+	default:
+		return generator.GenerateGo(s)
+	}
+}
+
+// messageInfo is a message flattened out of its nesting.
+type messageInfo struct {
+	desc  *MessageDescriptor
+	file  *FileDescriptor
+	name  string // ffire type name: Outer.Inner -> OuterInner
+	fqn   string // Fully qualified proto name: .pkg.Outer.Inner
+	proto string // Display name for errors: pkg.Outer.Inner
+}
+
+// index resolves fully qualified proto type names across all request files.
+type index struct {
+	files    map[string]*FileDescriptor
+	messages map[string]*messageInfo
+	enums    map[string]bool
+	byFile   map[string][]*messageInfo // Declaration order per file
+}
+
+func newIndex(files []*FileDescriptor) (*index, error) {
+	idx := &index{
+		files:    make(map[string]*FileDescriptor),
+		messages: make(map[string]*messageInfo),
+		enums:    make(map[string]bool),
+		byFile:   make(map[string][]*messageInfo),
+	}
+
+	names := make(map[string]string) // ffire name -> proto name, to detect collisions
+	var add func(file *FileDescriptor, scope, prefix string, msgs []*MessageDescriptor) error
+	add = func(file *FileDescriptor, scope, prefix string, msgs []*MessageDescriptor) error {
+		for _, msg := range msgs {
+			fqn := scope + "." + msg.Name
+			info := &messageInfo{desc: msg, file: file, name: prefix + msg.Name, fqn: fqn, proto: strings.TrimPrefix(fqn, ".")}
+			if !msg.MapEntry {
+				if other, exists := names[info.name]; exists {
+					return fmt.Errorf("messages %s and %s both map to ffire type %s", other, info.proto, info.name)
+				}
+				names[info.name] = info.proto
+			}
+			idx.messages[fqn] = info
+			idx.byFile[file.Name] = append(idx.byFile[file.Name], info)
+			for _, e := range msg.Enums {
+				idx.enums[fqn+"."+e] = true
+			}
+			if err := add(file, fqn, info.name, msg.Nested); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, file := range files {
+		idx.files[file.Name] = file
+		scope := ""
+		if file.Package != "" {
+			scope = "." + file.Package
+		}
+		for _, e := range file.Enums {
+			idx.enums[scope+"."+e] = true
+		}
+		if err := add(file, scope, "", file.Messages); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
+
+// schemaSource renders the ffire schema for one proto file. Messages from
+// imported files that the file references are included, since ffire schemas
+// are self-contained. Types are emitted dependencies first.
+func (idx *index) schemaSource(file *FileDescriptor, pkgName string) (string, error) {
+	if pkgName == "" {
+		pkgName = strings.ReplaceAll(file.Package, ".", "_")
+	}
+	if pkgName == "" {
+		pkgName = strings.ReplaceAll(strings.TrimSuffix(path.Base(file.Name), ".proto"), "-", "_")
+	}
+
+	var order []*messageInfo
+	state := make(map[*messageInfo]int) // 1 = visiting, 2 = done
+	var visit func(info *messageInfo) error
+	visit = func(info *messageInfo) error {
+		switch state[info] {
+		case 1:
+			return fmt.Errorf("message %s is recursive; ffire does not support recursive types", info.proto)
+		case 2:
+			return nil
+		}
+		state[info] = 1
+		for _, f := range info.desc.Fields {
+			if f.Type != typeMessage {
+				continue
+			}
+			dep := idx.messages[f.TypeName]
+			if dep == nil {
+				return fmt.Errorf("%s.%s: unknown message type %s", info.proto, f.Name, f.TypeName)
+			}
+			if dep.desc.MapEntry {
+				continue // Rejected with a clear message when the field is rendered
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[info] = 2
+		order = append(order, info)
+		return nil
+	}
+
+	for _, info := range idx.byFile[file.Name] {
+		if info.desc.MapEntry {
+			continue
+		}
+		if err := visit(info); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by protoc-gen-ffire from %s. DO NOT EDIT.\n\n", file.Name)
+	fmt.Fprintf(&buf, "package %s\n", pkgName)
+
+	for _, info := range order {
+		if err := idx.writeMessage(&buf, info); err != nil {
+			return "", err
+		}
+	}
+
+	return buf.String(), nil
+}
+
+func (idx *index) writeMessage(buf *bytes.Buffer, info *messageInfo) error {
+	type line struct{ name, typ, tag, comment string }
+	var lines []line
+	nameWidth, typeWidth := 0, 0
+
+	for _, f := range info.desc.Fields {
+		typ, comment, err := idx.fieldType(info, f)
+		if err != nil {
+			return err
+		}
+		jsonName := f.JSONName
+		if jsonName == "" {
+			jsonName = generator.ToCamelCase(f.Name)
+		}
+		l := line{
+			name:    generator.ToPascalCase(f.Name),
+			typ:     typ,
+			tag:     fmt.Sprintf("`json:\"%s\"`", jsonName),
+			comment: comment,
+		}
+		nameWidth = max(nameWidth, len(l.name))
+		typeWidth = max(typeWidth, len(l.typ))
+		lines = append(lines, l)
+	}
+
+	fmt.Fprintf(buf, "\n// %s is generated from message %s.\n", info.name, info.proto)
+	fmt.Fprintf(buf, "type %s struct {\n", info.name)
+	for _, l := range lines {
+		fmt.Fprintf(buf, "\t%-*s %-*s %s", nameWidth, l.name, typeWidth, l.typ, l.tag)
+		if l.comment != "" {
+			fmt.Fprintf(buf, " // %s", l.comment)
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// fieldType maps a proto field to an ffire type expression. The comment
+// documents lossy or widened mappings in the emitted schema.
+func (idx *index) fieldType(info *messageInfo, f *FieldDescriptor) (typ, comment string, err error) {
+	where := info.proto + "." + f.Name
+
+	switch f.Type {
+	case typeDouble:
+		typ = "float64"
+	case typeFloat:
+		typ = "float32"
+	case typeInt64, typeSint64, typeSfixed64:
+		typ = "int64"
+	case typeInt32, typeSint32, typeSfixed32:
+		typ = "int32"
+	case typeUint32, typeFixed32:
+		typ, comment = "int64", "uint32 widened to int64"
+	case typeUint64, typeFixed64:
+		typ, comment = "int64", "uint64 stored as int64; values above 2^63-1 do not fit"
+	case typeBool:
+		typ = "bool"
+	case typeString:
+		typ = "string"
+	case typeEnum:
+		if !idx.enums[f.TypeName] {
+			return "", "", fmt.Errorf("%s: unknown enum type %s", where, f.TypeName)
+		}
+		typ, comment = "int32", "enum "+strings.TrimPrefix(f.TypeName, ".")
+	case typeMessage:
+		dep := idx.messages[f.TypeName]
+		if dep == nil {
+			return "", "", fmt.Errorf("%s: unknown message type %s", where, f.TypeName)
+		}
+		if dep.desc.MapEntry {
+			return "", "", fmt.Errorf("%s: map fields are not supported", where)
+		}
+		typ = dep.name
+	case typeBytes:
+		typ = "bytes"
+	case typeGroup:
+		return "", "", fmt.Errorf("%s: groups are not supported", where)
+	default:
+		return "", "", fmt.Errorf("%s: unknown field type %d", where, f.Type)
+	}
+
+	switch {
+	case f.Label == labelRepeated:
+		typ = "[]" + typ
+	case f.Label == labelRequired:
+		// Always present
+	case f.Type == typeMessage, f.InOneof, f.Proto3Optional:
+		// Field presence is tracked: messages, oneof members and
+		// explicit optionals become optional fields
+		typ = "*" + typ
+	case f.Label == labelOptional && info.file.Syntax != "proto3":
+		// proto2 singular fields have explicit presence
+		typ = "*" + typ
+	}
+
+	return typ, comment, nil
+}
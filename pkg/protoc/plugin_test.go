@@ -0,0 +1,265 @@
+package protoc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+)
+
+// Test helpers encode descriptors the way protoc does, so tests exercise
+// ParseRequest as well as the schema conversion.
+
+func encodeField(f *FieldDescriptor) []byte {
+	w := &wireWriter{}
+	w.string(1, f.Name)
+	w.uint(3, uint64(f.Number))
+	w.uint(4, uint64(f.Label))
+	w.uint(5, uint64(f.Type))
+	if f.TypeName != "" {
+		w.string(6, f.TypeName)
+	}
+	if f.InOneof {
+		w.uint(9, 0)
+	}
+	if f.JSONName != "" {
+		w.string(10, f.JSONName)
+	}
+	if f.Proto3Optional {
+		w.uint(17, 1)
+	}
+	return w.buf
+}
+
+func encodeMessage(m *MessageDescriptor) []byte {
+	w := &wireWriter{}
+	w.string(1, m.Name)
+	for _, f := range m.Fields {
+		w.bytes(2, encodeField(f))
+	}
+	for _, n := range m.Nested {
+		w.bytes(3, encodeMessage(n))
+	}
+	for _, e := range m.Enums {
+		ew := &wireWriter{}
+		ew.string(1, e)
+		w.bytes(4, ew.buf)
+	}
+	if m.MapEntry {
+		ow := &wireWriter{}
+		ow.uint(7, 1)
+		w.bytes(7, ow.buf)
+	}
+	return w.buf
+}
+
+func encodeRequest(req *Request) []byte {
+	w := &wireWriter{}
+	for _, f := range req.FilesToGenerate {
+		w.string(1, f)
+	}
+	if req.Parameter != "" {
+		w.string(2, req.Parameter)
+	}
+	for _, file := range req.ProtoFiles {
+		fw := &wireWriter{}
+		fw.string(1, file.Name)
+		if file.Package != "" {
+			fw.string(2, file.Package)
+		}
+		for _, m := range file.Messages {
+			fw.bytes(4, encodeMessage(m))
+		}
+		for _, e := range file.Enums {
+			ew := &wireWriter{}
+			ew.string(1, e)
+			fw.bytes(5, ew.buf)
+		}
+		if file.Syntax != "" {
+			fw.string(12, file.Syntax)
+		}
+		w.bytes(15, fw.buf)
+	}
+	return w.buf
+}
+
+func audioRequest(parameter string) *Request {
+	common := &FileDescriptor{
+		Name:    "common.proto",
+		Package: "acme.common",
+		Syntax:  "proto3",
+		Messages: []*MessageDescriptor{{
+			Name: "Meta",
+			Fields: []*FieldDescriptor{
+				{Name: "author", Number: 1, Label: labelOptional, Type: typeString, JSONName: "author"},
+			},
+		}},
+	}
+	audio := &FileDescriptor{
+		Name:    "audio.proto",
+		Package: "acme.audio",
+		Syntax:  "proto3",
+		Enums:   []string{"Kind"},
+		Messages: []*MessageDescriptor{{
+			Name: "DeviceList",
+			Fields: []*FieldDescriptor{
+				{Name: "devices", Number: 1, Label: labelRepeated, Type: typeMessage, TypeName: ".acme.audio.DeviceList.Device", JSONName: "devices"},
+				{Name: "meta", Number: 2, Label: labelOptional, Type: typeMessage, TypeName: ".acme.common.Meta", JSONName: "meta"},
+			},
+			Nested: []*MessageDescriptor{{
+				Name: "Device",
+				Fields: []*FieldDescriptor{
+					{Name: "device_id", Number: 1, Label: labelOptional, Type: typeUint32, JSONName: "deviceId"},
+					{Name: "name", Number: 2, Label: labelOptional, Type: typeString, JSONName: "name"},
+					{Name: "kind", Number: 3, Label: labelOptional, Type: typeEnum, TypeName: ".acme.audio.Kind", JSONName: "kind"},
+					{Name: "gain", Number: 4, Label: labelOptional, Type: typeFloat, JSONName: "gain", Proto3Optional: true, InOneof: true},
+					{Name: "icon", Number: 5, Label: labelOptional, Type: typeBytes, JSONName: "icon"},
+				},
+			}},
+		}},
+	}
+	return &Request{
+		FilesToGenerate: []string{"audio.proto"},
+		Parameter:       parameter,
+		ProtoFiles:      []*FileDescriptor{common, audio},
+	}
+}
+
+func TestGenerateSchema(t *testing.T) {
+	req, err := ParseRequest(encodeRequest(audioRequest("")))
+	if err != nil {
+		t.Fatalf("ParseRequest failed: %v", err)
+	}
+
+	resp := Generate(req)
+	if resp.Error != "" {
+		t.Fatalf("Generate failed: %s", resp.Error)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].Name != "audio.ffi" {
+		t.Fatalf("got files %+v, want audio.ffi", resp.Files)
+	}
+
+	src := resp.Files[0].Content
+	for _, want := range []string{
+		"package acme_audio",
+		"type DeviceListDevice struct",
+		"DeviceId int64    `json:\"deviceId\"` // uint32 widened to int64",
+		"Gain     *float32",
+		"Icon     bytes    `json:\"icon\"`",
+		"Kind     int32    `json:\"kind\"` // enum acme.audio.Kind",
+		"Devices []DeviceListDevice `json:\"devices\"`",
+		"Meta    *Meta",
+		"type Meta struct",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("schema missing %q:\n%s", want, src)
+		}
+	}
+
+	// Dependencies are declared before the messages that use them
+	if strings.Index(src, "type DeviceListDevice") > strings.Index(src, "type DeviceList struct") {
+		t.Errorf("nested message should be declared before its parent:\n%s", src)
+	}
+
+	// The emitted schema must be accepted by the ffire parser
+	s, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("emitted schema does not parse: %v\n%s", err, src)
+	}
+	if len(s.Messages) != 1 || s.Messages[0].Name != "DeviceList" {
+		t.Errorf("got root types %+v, want DeviceList", s.Messages)
+	}
+}
+
+func TestGenerateCode(t *testing.T) {
+	resp := Generate(audioRequest("lang=go,package=audio"))
+	if resp.Error != "" {
+		t.Fatalf("Generate failed: %s", resp.Error)
+	}
+	if len(resp.Files) != 2 || resp.Files[1].Name != "audio.ffire.go" {
+		t.Fatalf("got files %+v, want audio.ffi and audio.ffire.go", resp.Files)
+	}
+	if !strings.Contains(resp.Files[0].Content, "package audio\n") {
+		t.Errorf("package override not applied:\n%s", resp.Files[0].Content)
+	}
+	if !strings.Contains(resp.Files[1].Content, "package audio") {
+		t.Errorf("generated Go code has wrong package")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   *FieldDescriptor
+		nested  []*MessageDescriptor
+		param   string
+		wantErr string
+	}{
+		{
+			name:    "group",
+			field:   &FieldDescriptor{Name: "legacy", Number: 1, Label: labelOptional, Type: typeGroup},
+			wantErr: "groups are not supported",
+		},
+		{
+			name:  "map",
+			field: &FieldDescriptor{Name: "labels", Number: 1, Label: labelRepeated, Type: typeMessage, TypeName: ".pkg.Msg.LabelsEntry"},
+			nested: []*MessageDescriptor{{Name: "LabelsEntry", MapEntry: true, Fields: []*FieldDescriptor{
+				{Name: "key", Number: 1, Label: labelOptional, Type: typeString},
+				{Name: "value", Number: 2, Label: labelOptional, Type: typeString},
+			}}},
+			wantErr: "map fields are not supported",
+		},
+		{
+			name:    "recursive",
+			field:   &FieldDescriptor{Name: "child", Number: 1, Label: labelOptional, Type: typeMessage, TypeName: ".pkg.Msg"},
+			wantErr: "recursive",
+		},
+		{
+			name:    "unknown parameter",
+			field:   &FieldDescriptor{Name: "id", Number: 1, Label: labelOptional, Type: typeInt32},
+			param:   "style=fancy",
+			wantErr: "unknown parameter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &Request{
+				FilesToGenerate: []string{"msg.proto"},
+				Parameter:       tt.param,
+				ProtoFiles: []*FileDescriptor{{
+					Name:     "msg.proto",
+					Package:  "pkg",
+					Syntax:   "proto3",
+					Messages: []*MessageDescriptor{{Name: "Msg", Fields: []*FieldDescriptor{tt.field}, Nested: tt.nested}},
+				}},
+			}
+			resp := Generate(req)
+			if !strings.Contains(resp.Error, tt.wantErr) {
+				t.Errorf("Error = %q, want it to contain %q", resp.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProto2Presence(t *testing.T) {
+	req := &Request{
+		FilesToGenerate: []string{"legacy.proto"},
+		ProtoFiles: []*FileDescriptor{{
+			Name: "legacy.proto",
+			Messages: []*MessageDescriptor{{Name: "Legacy", Fields: []*FieldDescriptor{
+				{Name: "id", Number: 1, Label: labelRequired, Type: typeInt64},
+				{Name: "note", Number: 2, Label: labelOptional, Type: typeString},
+			}}},
+		}},
+	}
+
+	resp := Generate(req)
+	if resp.Error != "" {
+		t.Fatalf("Generate failed: %s", resp.Error)
+	}
+	src := resp.Files[0].Content
+	if !strings.Contains(src, "package legacy") || !strings.Contains(src, "Id   int64") || !strings.Contains(src, "Note *string") {
+		t.Errorf("unexpected proto2 mapping:\n%s", src)
+	}
+}
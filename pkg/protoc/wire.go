@@ -0,0 +1,103 @@
+package protoc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Minimal protobuf wire format support, covering only what is needed to read
+// a CodeGeneratorRequest and write a CodeGeneratorResponse. This keeps the
+// plugin free of a protobuf runtime dependency.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// wireReader iterates over the fields of an encoded protobuf message.
+type wireReader struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next field number, wire type and (for length-delimited
+// fields) payload, or (for varints) value. ok is false at end of message.
+func (r *wireReader) next() (field int, wireType int, value uint64, payload []byte, ok bool, err error) {
+	if r.pos >= len(r.data) {
+		return 0, 0, 0, nil, false, nil
+	}
+
+	key, err := r.varint()
+	if err != nil {
+		return 0, 0, 0, nil, false, err
+	}
+	field, wireType = int(key>>3), int(key&7)
+
+	switch wireType {
+	case wireVarint:
+		value, err = r.varint()
+	case wireFixed64:
+		if r.pos+8 > len(r.data) {
+			return 0, 0, 0, nil, false, fmt.Errorf("truncated fixed64 field %d", field)
+		}
+		value = binary.LittleEndian.Uint64(r.data[r.pos:])
+		r.pos += 8
+	case wireFixed32:
+		if r.pos+4 > len(r.data) {
+			return 0, 0, 0, nil, false, fmt.Errorf("truncated fixed32 field %d", field)
+		}
+		value = uint64(binary.LittleEndian.Uint32(r.data[r.pos:]))
+		r.pos += 4
+	case wireBytes:
+		var length uint64
+		if length, err = r.varint(); err != nil {
+			break
+		}
+		if uint64(len(r.data)-r.pos) < length {
+			return 0, 0, 0, nil, false, fmt.Errorf("truncated length-delimited field %d", field)
+		}
+		payload = r.data[r.pos : r.pos+int(length)]
+		r.pos += int(length)
+	default:
+		return 0, 0, 0, nil, false, fmt.Errorf("unsupported wire type %d for field %d", wireType, field)
+	}
+	if err != nil {
+		return 0, 0, 0, nil, false, err
+	}
+	return field, wireType, value, payload, true, nil
+}
+
+func (r *wireReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid varint at offset %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+// wireWriter builds an encoded protobuf message.
+type wireWriter struct {
+	buf []byte
+}
+
+func (w *wireWriter) key(field, wireType int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(field<<3|wireType))
+}
+
+func (w *wireWriter) uint(field int, v uint64) {
+	w.key(field, wireVarint)
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *wireWriter) bytes(field int, b []byte) {
+	w.key(field, wireBytes)
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *wireWriter) string(field int, s string) {
+	w.bytes(field, []byte(s))
+}
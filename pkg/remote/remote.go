@@ -0,0 +1,163 @@
+// Package remote fetches schemas from HTTPS registries with checksum pinning,
+// so repositories that generate code from a shared contract can track the
+// canonical schema without vendoring it.
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxSchemaSize bounds the response body so a misbehaving registry cannot
+// exhaust memory.
+const maxSchemaSize = 16 << 20
+
+// IsURL reports whether a schema location refers to a remote schema.
+func IsURL(location string) bool {
+	return strings.HasPrefix(location, "https://") || strings.HasPrefix(location, "http://")
+}
+
+// Checksum returns the pin for data in "sha256:<hex>" form.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ChecksumError reports a schema whose content does not match its pin.
+type ChecksumError struct {
+	URL  string
+	Want string
+	Got  string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: pinned %s, got %s", e.URL, e.Want, e.Got)
+}
+
+// Fetcher downloads remote schemas. Pinned schemas are cached by checksum,
+// so repeated builds work offline and never see a different contract.
+type Fetcher struct {
+	Client   *http.Client
+	CacheDir string // Empty disables caching
+}
+
+// NewFetcher returns a Fetcher with a 30s timeout, caching in the user cache
+// directory (e.g. ~/.cache/ffire/schemas).
+func NewFetcher() *Fetcher {
+	f := &Fetcher{Client: &http.Client{Timeout: 30 * time.Second}}
+	if dir, err := os.UserCacheDir(); err == nil {
+		f.CacheDir = filepath.Join(dir, "ffire", "schemas")
+	}
+	return f
+}
+
+// Fetch downloads the schema at rawURL. If pin is non-empty ("sha256:<hex>"
+// or bare hex), the content must match it.
+func (f *Fetcher) Fetch(rawURL, pin string) ([]byte, error) {
+	return f.FetchContext(context.Background(), rawURL, pin)
+}
+
+// FetchContext is Fetch with a context that bounds the download.
+func (f *Fetcher) FetchContext(ctx context.Context, rawURL, pin string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("schema URL must use https: %s", rawURL)
+	}
+
+	if pin != "" {
+		pin, err = normalizePin(pin)
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := f.cached(pin); ok {
+			return data, nil
+		}
+	}
+
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema URL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch schema: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch schema: %s returned %s", rawURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSchemaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch schema: %w", err)
+	}
+	if len(data) > maxSchemaSize {
+		return nil, fmt.Errorf("fetch schema: %s exceeds %d bytes", rawURL, maxSchemaSize)
+	}
+
+	if pin != "" {
+		if got := Checksum(data); got != pin {
+			return nil, &ChecksumError{URL: rawURL, Want: pin, Got: got}
+		}
+		f.store(pin, data)
+	}
+
+	return data, nil
+}
+
+func normalizePin(pin string) (string, error) {
+	hexSum := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(pin)), "sha256:")
+	if len(hexSum) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid checksum %q: expected sha256:<64 hex digits>", pin)
+	}
+	if _, err := hex.DecodeString(hexSum); err != nil {
+		return "", fmt.Errorf("invalid checksum %q: %w", pin, err)
+	}
+	return "sha256:" + hexSum, nil
+}
+
+func (f *Fetcher) cachePath(pin string) string {
+	return filepath.Join(f.CacheDir, strings.TrimPrefix(pin, "sha256:")+".ffi")
+}
+
+// cached returns a previously fetched schema, re-verifying its checksum in
+// case the cache file was modified.
+func (f *Fetcher) cached(pin string) ([]byte, bool) {
+	if f.CacheDir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(f.cachePath(pin))
+	if err != nil || Checksum(data) != pin {
+		return nil, false
+	}
+	return data, true
+}
+
+// store writes a verified schema to the cache. Failures are ignored: the
+// cache is an optimization only.
+func (f *Fetcher) store(pin string, data []byte) {
+	if f.CacheDir == "" {
+		return
+	}
+	if err := os.MkdirAll(f.CacheDir, 0755); err != nil {
+		return
+	}
+	_ = os.WriteFile(f.cachePath(pin), data, 0644)
+}
@@ -0,0 +1,84 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSchema = "package audio\n\ntype Device struct {\n\tName string\n}\n"
+
+func TestFetchPinned(t *testing.T) {
+	hits := 0
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(testSchema))
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{Client: srv.Client(), CacheDir: t.TempDir()}
+	pin := Checksum([]byte(testSchema))
+
+	data, err := f.Fetch(srv.URL+"/audio/v3", pin)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+	if string(data) != testSchema {
+		t.Errorf("got %q, want schema", data)
+	}
+
+	// Second fetch is served from the content-addressed cache
+	if _, err := f.Fetch(srv.URL+"/audio/v3", strings.TrimPrefix(pin, "sha256:")); err != nil {
+		t.Fatalf("cached Fetch failed: %v", err)
+	}
+	if hits != 1 {
+		t.Errorf("got %d requests, want 1", hits)
+	}
+}
+
+func TestFetchChecksumMismatch(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testSchema))
+	}))
+	defer srv.Close()
+
+	f := &Fetcher{Client: srv.Client()}
+	_, err := f.Fetch(srv.URL, Checksum([]byte("something else")))
+	if _, ok := err.(*ChecksumError); !ok {
+		t.Errorf("Fetch error = %v, want *ChecksumError", err)
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	f := &Fetcher{Client: srv.Client()}
+
+	if _, err := f.Fetch(srv.URL, ""); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Fetch error = %v, want 404", err)
+	}
+	if _, err := f.Fetch("http://registry.example.com/audio", ""); err == nil {
+		t.Error("expected error for non-https URL")
+	}
+	if _, err := f.Fetch(srv.URL, "sha256:abc"); err == nil {
+		t.Error("expected error for malformed checksum")
+	}
+}
+
+func TestFetchContextCanceled(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f := &Fetcher{Client: srv.Client()}
+	if _, err := f.FetchContext(ctx, srv.URL, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("FetchContext error = %v, want context.Canceled", err)
+	}
+}
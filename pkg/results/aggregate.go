@@ -0,0 +1,115 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CompareOptions configures CompareMachines.
+type CompareOptions struct {
+	Message string  // Only compare this message; empty prints a table per message
+	Metric  *Metric // Timing to compare; nil means total time
+}
+
+// CompareMachines writes a table per message with a row per implementation
+// and a column per machine, after a legend naming the machines. Results
+// are never averaged across machines.
+func CompareMachines(w io.Writer, rs []Result, opts CompareOptions) error {
+	metric := Metrics[2]
+	if opts.Metric != nil {
+		metric = *opts.Metric
+	}
+	messages := Messages(rs)
+	if opts.Message != "" {
+		if !slices.Contains(messages, opts.Message) {
+			return fmt.Errorf("no results for message %q (available: %s)", opts.Message, strings.Join(messages, ", "))
+		}
+		messages = []string{opts.Message}
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("no results to compare")
+	}
+
+	machines := Machines(rs)
+	byMachine := make([][]Result, len(machines))
+	for _, r := range rs {
+		for i, m := range machines {
+			if sameMachine(m, r.Machine) {
+				byMachine[i] = append(byMachine[i], r)
+				break
+			}
+		}
+	}
+
+	fmt.Fprintln(w, "Machines:")
+	for i, m := range machines {
+		fmt.Fprintf(w, "  %-3s %s\n", machineColumn(i), m)
+	}
+
+	for _, msg := range messages {
+		// values[label][i] is the metric on machine i, if it ran there
+		values := make(map[string][]*float64)
+		var labels []string
+		for i := range machines {
+			for _, s := range Summarize(byMachine[i], msg) {
+				if values[s.Label()] == nil {
+					values[s.Label()] = make([]*float64, len(machines))
+					labels = append(labels, s.Label())
+				}
+				v := metric.Value(s)
+				values[s.Label()][i] = &v
+			}
+		}
+		// Group rows by the first machine they ran on, fastest first
+		first := func(label string) (int, float64) {
+			for i, v := range values[label] {
+				if v != nil {
+					return i, *v
+				}
+			}
+			return len(machines), 0
+		}
+		sort.SliceStable(labels, func(a, b int) bool {
+			ia, va := first(labels[a])
+			ib, vb := first(labels[b])
+			if ia != ib {
+				return ia < ib
+			}
+			return va < vb
+		})
+
+		labelWidth := len("Implementation")
+		for _, l := range labels {
+			labelWidth = max(labelWidth, len(l))
+		}
+		fmt.Fprintf(w, "\n%s: %s time (μs)\n", msg, metric.Name)
+		fmt.Fprintf(w, "%-*s", labelWidth, "Implementation")
+		for i := range machines {
+			fmt.Fprintf(w, " %10s", machineColumn(i))
+		}
+		fmt.Fprintln(w)
+		for _, l := range labels {
+			fmt.Fprintf(w, "%-*s", labelWidth, l)
+			for _, v := range values[l] {
+				if v == nil {
+					fmt.Fprintf(w, " %10s", "-")
+				} else {
+					fmt.Fprintf(w, " %10.2f", *v/1000)
+				}
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+// machineColumn names the i-th machine in table headers: A to Z, then M27...
+func machineColumn(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return fmt.Sprintf("M%d", i+1)
+}
@@ -0,0 +1,49 @@
+package results
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompareMachines(t *testing.T) {
+	linux := &Machine{Host: "build-01", OS: "linux", Arch: "amd64", Cores: 16}
+	mac := &Machine{Host: "mbp", OS: "darwin", Arch: "arm64", Cores: 12}
+	rs := []Result{
+		{Language: "go", Format: "ffire", Message: "struct", TotalNs: 3000, Machine: linux},
+		{Language: "go", Format: "proto", Message: "struct", TotalNs: 9000, Machine: linux},
+		{Language: "go", Format: "ffire", Message: "struct", TotalNs: 2000, Machine: mac},
+		{Language: "swift", Format: "ffire", Message: "struct", TotalNs: 2500, Machine: mac},
+		{Language: "go", Format: "ffire", Message: "nested", TotalNs: 4000, Machine: mac},
+	}
+
+	var buf bytes.Buffer
+	if err := CompareMachines(&buf, rs, CompareOptions{Message: "struct"}); err != nil {
+		t.Fatalf("CompareMachines failed: %v", err)
+	}
+	want := `Machines:
+  A   build-01: linux/amd64, 16 cores
+  B   mbp: darwin/arm64, 12 cores
+
+struct: Total time (μs)
+Implementation          A          B
+ffire go             3.00       2.00
+proto go             9.00          -
+ffire swift             -       2.50
+`
+	if got := buf.String(); got != want {
+		t.Errorf("CompareMachines output:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := CompareMachines(&buf, rs, CompareOptions{Metric: &Metrics[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "nested: Encode time") || !strings.Contains(out, "struct: Encode time") {
+		t.Errorf("CompareMachines without a message should print every message:\n%s", out)
+	}
+
+	if err := CompareMachines(&buf, rs, CompareOptions{Message: "complex"}); err == nil {
+		t.Error("expected error for a message without results")
+	}
+}
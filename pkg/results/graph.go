@@ -0,0 +1,79 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GraphOptions configures Graph.
+type GraphOptions struct {
+	Message string   // Only chart this message; empty averages over all
+	Metrics []Metric // Charts to draw; nil draws all Metrics
+	Width   int      // Longest bar in cells; 0 means 50
+	Color   bool     // Color bars with ANSI escapes: green for the fastest, then yellow and red
+}
+
+// Graph writes a bar chart of rs for each metric, the view of the
+// benchmark suite's "mage graph". Each chart is followed by how much faster
+// ffire is than protobuf in every language that has both.
+func Graph(w io.Writer, rs []Result, opts GraphOptions) error {
+	if opts.Metrics == nil {
+		opts.Metrics = Metrics
+	}
+	if opts.Width <= 0 {
+		opts.Width = 50
+	}
+
+	summaries := Summarize(rs, opts.Message)
+	if len(summaries) == 0 {
+		if opts.Message == "" {
+			return fmt.Errorf("no results to graph")
+		}
+		return fmt.Errorf("no results for message %q (available: %s)", opts.Message, strings.Join(Messages(rs), ", "))
+	}
+
+	if opts.Message != "" {
+		fmt.Fprintf(w, "Performance metrics for '%s':\n", opts.Message)
+	} else {
+		fmt.Fprintf(w, "Performance metrics, average across %d messages:\n", len(Messages(rs)))
+	}
+
+	for _, m := range opts.Metrics {
+		title := m.Name + " Time (μs) - Lower is Better"
+		fmt.Fprintf(w, "\n╔%s╗\n", strings.Repeat("═", 66))
+		fmt.Fprintf(w, "║%s║\n", center(title, 66))
+		fmt.Fprintf(w, "╚%s╝\n\n", strings.Repeat("═", 66))
+		for _, line := range chart(summaries, m, opts.Width, opts.Color) {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+
+		// Compare formats within a language only: ffire Go vs proto, json,
+		// msgpack, cbor or ffire-validated Go
+		var speedups []string
+		for _, f := range summaries {
+			if f.Format != "ffire" {
+				continue
+			}
+			for _, p := range summaries {
+				if p.Format != "ffire" && p.Language == f.Language && m.Value(f) > 0 {
+					speedups = append(speedups, fmt.Sprintf("  → %s (%.2f μs) is %.2fx faster than %s (%.2f μs)",
+						f.Label(), m.Value(f)/1000, m.Value(p)/m.Value(f), p.Label(), m.Value(p)/1000))
+				}
+			}
+		}
+		if len(speedups) > 0 {
+			fmt.Fprintf(w, "\n%s\n", strings.Join(speedups, "\n"))
+		}
+	}
+	return nil
+}
+
+// center pads text with spaces on both sides to width runes.
+func center(text string, width int) string {
+	padding := width - len([]rune(text))
+	if padding <= 0 {
+		return text
+	}
+	return strings.Repeat(" ", padding/2) + text + strings.Repeat(" ", padding-padding/2)
+}
@@ -0,0 +1,46 @@
+package results
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	rs, err := Load(writeResults(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Graph(&buf, rs, GraphOptions{Message: "struct", Metrics: Metrics[1:2], Width: 20}); err != nil {
+		t.Fatalf("Graph failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Performance metrics for 'struct':",
+		"Decode Time (μs) - Lower is Better",
+		"ffire go  " + strings.Repeat("█", 5) + " ",
+		"(4.00x)",
+		"→ ffire go (0.20 μs) is 4.00x faster than proto go (0.80 μs)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("graph lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Encode") || strings.Contains(out, "\033[") {
+		t.Errorf("graph has other metrics or colors:\n%s", out)
+	}
+
+	buf.Reset()
+	if err := Graph(&buf, rs, GraphOptions{Color: true}); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "average across 2 messages") || !strings.Contains(out, green+"█") {
+		t.Errorf("colored graph of all messages:\n%s", out)
+	}
+
+	if err := Graph(&buf, rs, GraphOptions{Message: "nested"}); err == nil || !strings.Contains(err.Error(), "array_int, struct") {
+		t.Errorf("Graph of a missing message: error = %v, want available messages", err)
+	}
+}
@@ -0,0 +1,106 @@
+package results
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Machine describes the host a benchmark ran on, so results from different
+// hardware can be told apart.
+type Machine struct {
+	Host   string `json:"host"`
+	OS     string `json:"os,omitempty"`
+	Arch   string `json:"arch,omitempty"`
+	CPU    string `json:"cpu,omitempty"` // Model name, where the OS reports it
+	Cores  int    `json:"cores,omitempty"`
+	Memory int64  `json:"memory,omitempty"` // Bytes of RAM, where the OS reports it
+}
+
+// CurrentMachine describes the host ffire is running on.
+func CurrentMachine() *Machine {
+	m := &Machine{OS: runtime.GOOS, Arch: runtime.GOARCH, Cores: runtime.NumCPU()}
+	m.Host, _ = os.Hostname()
+	switch runtime.GOOS {
+	case "linux":
+		m.CPU = procField("/proc/cpuinfo", "model name")
+		if kb, err := strconv.ParseInt(strings.TrimSuffix(procField("/proc/meminfo", "MemTotal"), " kB"), 10, 64); err == nil {
+			m.Memory = kb * 1024
+		}
+	case "darwin":
+		m.CPU = sysctl("machdep.cpu.brand_string")
+		m.Memory, _ = strconv.ParseInt(sysctl("hw.memsize"), 10, 64)
+	}
+	return m
+}
+
+// procField returns the value of the first "key: value" line of a /proc file.
+func procField(path, key string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func sysctl(name string) string {
+	out, err := exec.Command("sysctl", "-n", name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// String describes m on one line, e.g.
+// "build-01: linux/amd64, AMD EPYC 7763 64-Core Processor, 16 cores, 64 GiB".
+func (m *Machine) String() string {
+	if m == nil {
+		return "unknown machine"
+	}
+	var parts []string
+	if m.OS != "" {
+		parts = append(parts, m.OS+"/"+m.Arch)
+	}
+	if m.CPU != "" {
+		parts = append(parts, m.CPU)
+	}
+	if m.Cores > 0 {
+		parts = append(parts, fmt.Sprintf("%d cores", m.Cores))
+	}
+	if m.Memory > 0 {
+		parts = append(parts, fmt.Sprintf("%d GiB", (m.Memory+1<<29)>>30))
+	}
+	if len(parts) == 0 {
+		return m.Host
+	}
+	return m.Host + ": " + strings.Join(parts, ", ")
+}
+
+// Machines returns the distinct machines of rs, in order of appearance.
+// Results without a machine contribute nil.
+func Machines(rs []Result) []*Machine {
+	var ms []*Machine
+	for _, r := range rs {
+		if !slices.ContainsFunc(ms, func(m *Machine) bool { return sameMachine(m, r.Machine) }) {
+			ms = append(ms, r.Machine)
+		}
+	}
+	return ms
+}
+
+func sameMachine(a, b *Machine) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
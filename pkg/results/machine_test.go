@@ -0,0 +1,27 @@
+package results
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestCurrentMachine(t *testing.T) {
+	m := CurrentMachine()
+	if m.OS != runtime.GOOS || m.Arch != runtime.GOARCH || m.Cores < 1 {
+		t.Errorf("CurrentMachine = %+v", m)
+	}
+}
+
+func TestMachineString(t *testing.T) {
+	m := &Machine{Host: "build-01", OS: "linux", Arch: "amd64", CPU: "AMD EPYC 7763", Cores: 16, Memory: 64 << 30}
+	if got, want := m.String(), "build-01: linux/amd64, AMD EPYC 7763, 16 cores, 64 GiB"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	if got := (&Machine{Host: "results-old/"}).String(); got != "results-old/" {
+		t.Errorf("String without details = %q", got)
+	}
+	var unknown *Machine
+	if got := unknown.String(); got != "unknown machine" {
+		t.Errorf("nil String = %q", got)
+	}
+}
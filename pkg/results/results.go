@@ -0,0 +1,289 @@
+// Package results loads the benchmark results written by the benchmark
+// drivers (BENCH_JSON=1, collected by the benchmarks magefile into
+// results/*.json) and summarizes them for comparison across languages,
+// formats and machines.
+//
+// A results file is a File: a format version, the machine the benchmarks
+// ran on, and the results. Files from before versioning, a bare array of
+// results or a single result as printed by a driver, still load, without
+// machine metadata.
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Result is one benchmark run of one message in one language and format.
+type Result struct {
+	Language    string `json:"language"`
+	Format      string `json:"format"` // "ffire", "ffire-validated", "proto", "json", "msgpack", "cbor"
+	Message     string `json:"message"`
+	Iterations  int    `json:"iterations"`
+	EncodeNs    int64  `json:"encode_ns"`
+	DecodeNs    int64  `json:"decode_ns"`
+	TotalNs     int64  `json:"total_ns"`
+	WireSize    int    `json:"wire_size"`
+	FixtureSize int    `json:"fixture_size"`
+	Timestamp   string `json:"timestamp"`
+
+	// Counters holds the hardware counters and energy the suite collects
+	// on Linux, kept as written.
+	Counters json.RawMessage `json:"counters,omitempty"`
+
+	// Machine is where the benchmark ran. Load sets it from the file;
+	// in a file it is only written when results from several machines
+	// are merged.
+	Machine *Machine `json:"machine,omitempty"`
+}
+
+// Version is the results format written by Write. Load accepts it and
+// older files.
+const Version = 1
+
+// File is the results format.
+type File struct {
+	Version int      `json:"version"`
+	Machine *Machine `json:"machine,omitempty"` // Where all results ran, unless they say otherwise
+	Results []Result `json:"results"`
+}
+
+// Write writes rs as a File. Results from a single machine share the
+// file's machine; merged results keep one each.
+func Write(w io.Writer, rs []Result) error {
+	f := File{Version: Version, Results: rs}
+	if ms := Machines(rs); len(ms) == 1 && ms[0] != nil {
+		f.Machine = ms[0]
+		f.Results = make([]Result, len(rs))
+		for i, r := range rs {
+			r.Machine = nil
+			f.Results[i] = r
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// Load reads results from files and directories; a directory contributes
+// its *.json files.
+func Load(paths ...string) ([]Result, error) {
+	var all []Result
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		files := []string{path}
+		if info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+				return nil, err
+			}
+		}
+		for _, file := range files {
+			rs, err := loadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, rs...)
+		}
+	}
+	return all, nil
+}
+
+func loadFile(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		var rs []Result
+		if err := json.Unmarshal(data, &rs); err != nil {
+			return nil, fmt.Errorf("%s: not a results file: %w", path, err)
+		}
+		return rs, nil
+	}
+
+	// A File has a version; a single result does not
+	var probe struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("%s: not a results file: %w", path, err)
+	}
+	if probe.Version == nil {
+		var r Result
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("%s: not a results file: %w", path, err)
+		}
+		return []Result{r}, nil
+	}
+	if *probe.Version > Version {
+		return nil, fmt.Errorf("%s: results format version %d is newer than this ffire supports (%d)", path, *probe.Version, Version)
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: not a results file: %w", path, err)
+	}
+	for i := range f.Results {
+		if f.Results[i].Machine == nil {
+			f.Results[i].Machine = f.Machine
+		}
+	}
+	return f.Results, nil
+}
+
+// Messages returns the message names in rs, sorted.
+func Messages(rs []Result) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, r := range rs {
+		if !seen[r.Message] {
+			seen[r.Message] = true
+			names = append(names, r.Message)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Summary is the average of the results of one language and format.
+type Summary struct {
+	Language string
+	Format   string
+	EncodeNs float64
+	DecodeNs float64
+	TotalNs  float64
+	WireSize float64
+	Messages int // Number of results averaged
+}
+
+// Label names the implementation, e.g. "ffire go".
+func (s Summary) Label() string {
+	return s.Format + " " + s.Language
+}
+
+// Summarize averages rs per language and format, using only the results
+// for message unless it is empty. Summaries are sorted by total time.
+func Summarize(rs []Result, message string) []Summary {
+	type key struct{ language, format string }
+	sums := make(map[key]*Summary)
+	var keys []key
+	for _, r := range rs {
+		if message != "" && r.Message != message {
+			continue
+		}
+		k := key{r.Language, r.Format}
+		s := sums[k]
+		if s == nil {
+			s = &Summary{Language: r.Language, Format: r.Format}
+			sums[k] = s
+			keys = append(keys, k)
+		}
+		s.EncodeNs += float64(r.EncodeNs)
+		s.DecodeNs += float64(r.DecodeNs)
+		s.TotalNs += float64(r.TotalNs)
+		s.WireSize += float64(r.WireSize)
+		s.Messages++
+	}
+
+	summaries := make([]Summary, 0, len(keys))
+	for _, k := range keys {
+		s := *sums[k]
+		n := float64(s.Messages)
+		s.EncodeNs /= n
+		s.DecodeNs /= n
+		s.TotalNs /= n
+		s.WireSize /= n
+		summaries = append(summaries, s)
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		if summaries[i].TotalNs != summaries[j].TotalNs {
+			return summaries[i].TotalNs < summaries[j].TotalNs
+		}
+		return summaries[i].Label() < summaries[j].Label()
+	})
+	return summaries
+}
+
+// Metric selects one timing of a Summary.
+type Metric struct {
+	Name  string // "Encode", "Decode" or "Total"
+	Value func(Summary) float64
+}
+
+// Metrics are the timings charted by Chart, in display order.
+var Metrics = []Metric{
+	{"Encode", func(s Summary) float64 { return s.EncodeNs }},
+	{"Decode", func(s Summary) float64 { return s.DecodeNs }},
+	{"Total", func(s Summary) float64 { return s.TotalNs }},
+}
+
+// Chart renders summaries as horizontal bars of metric, fastest first, each
+// bar at most width cells long and the slower ones labelled with their
+// ratio to the fastest:
+//
+//	ffire go    ███████                        1.20 μs
+//	proto go    ██████████████████████████     4.51 μs  (3.76x)
+func Chart(summaries []Summary, metric Metric, width int) []string {
+	return chart(summaries, metric, width, false)
+}
+
+// ANSI colors of the bars: the fastest, the faster half, the rest.
+const (
+	green  = "\033[32m"
+	yellow = "\033[33m"
+	red    = "\033[31m"
+	reset  = "\033[0m"
+)
+
+func chart(summaries []Summary, metric Metric, width int, color bool) []string {
+	if len(summaries) == 0 {
+		return nil
+	}
+	sorted := make([]Summary, len(summaries))
+	copy(sorted, summaries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return metric.Value(sorted[i]) < metric.Value(sorted[j])
+	})
+
+	labelWidth := 0
+	for _, s := range sorted {
+		labelWidth = max(labelWidth, len(s.Label()))
+	}
+	fastest := metric.Value(sorted[0])
+	slowest := metric.Value(sorted[len(sorted)-1])
+
+	lines := make([]string, 0, len(sorted))
+	for i, s := range sorted {
+		v := metric.Value(s)
+		n := 1
+		if slowest > 0 {
+			n = max(1, int(math.Round(v/slowest*float64(width))))
+		}
+		bar := strings.Repeat("█", n)
+		if color {
+			c := red
+			switch {
+			case i == 0:
+				c = green
+			case i < (len(sorted)+1)/2:
+				c = yellow
+			}
+			bar = c + bar + reset
+		}
+		line := fmt.Sprintf("%-*s  %s%s %8.2f μs", labelWidth, s.Label(), bar, strings.Repeat(" ", width-n), v/1000)
+		if i > 0 && fastest > 0 {
+			line += fmt.Sprintf("  (%.2fx)", v/fastest)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
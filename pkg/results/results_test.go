@@ -0,0 +1,148 @@
+package results
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const goResults = `[
+  {"language": "go", "format": "ffire", "message": "struct", "encode_ns": 100, "decode_ns": 200, "total_ns": 300, "wire_size": 10},
+  {"language": "go", "format": "ffire", "message": "array_int", "encode_ns": 300, "decode_ns": 400, "total_ns": 700, "wire_size": 30}
+]`
+
+const protoResult = `{"language": "go", "format": "proto", "message": "struct", "encode_ns": 400, "decode_ns": 800, "total_ns": 1200, "wire_size": 12}`
+
+func writeResults(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ffire_go.json"), []byte(goResults), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "proto_go.json"), []byte(protoResult), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "comparison.md"), []byte("# not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeResults(t)
+
+	rs, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(rs) != 3 {
+		t.Fatalf("Load returned %d results, want 3", len(rs))
+	}
+	if got := strings.Join(Messages(rs), ","); got != "array_int,struct" {
+		t.Errorf("Messages = %s, want array_int,struct", got)
+	}
+
+	rs, err = Load(filepath.Join(dir, "proto_go.json"))
+	if err != nil || len(rs) != 1 || rs[0].Format != "proto" {
+		t.Errorf("Load(file) = %+v, %v", rs, err)
+	}
+
+	bad := filepath.Join(dir, "comparison.md")
+	if _, err := Load(bad); err == nil {
+		t.Error("expected error for a file that is not results JSON")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	rs, err := Load(writeResults(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := Summarize(rs, "")
+	if len(all) != 2 || all[0].Label() != "ffire go" || all[1].Label() != "proto go" {
+		t.Fatalf("Summarize = %+v, want ffire go then proto go", all)
+	}
+	if all[0].TotalNs != 500 || all[0].Messages != 2 {
+		t.Errorf("ffire go average = %v over %d results, want 500 over 2", all[0].TotalNs, all[0].Messages)
+	}
+
+	one := Summarize(rs, "array_int")
+	if len(one) != 1 || one[0].EncodeNs != 300 {
+		t.Errorf("Summarize(array_int) = %+v", one)
+	}
+}
+
+func TestChart(t *testing.T) {
+	summaries := []Summary{
+		{Language: "go", Format: "proto", DecodeNs: 800},
+		{Language: "go", Format: "ffire", DecodeNs: 200},
+	}
+	lines := Chart(summaries, Metrics[1], 20)
+	if len(lines) != 2 {
+		t.Fatalf("Chart returned %d lines, want 2", len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "ffire go  "+strings.Repeat("█", 5)+" ") {
+		t.Errorf("fastest bar = %q, want a quarter of the width", lines[0])
+	}
+	if !strings.Contains(lines[1], strings.Repeat("█", 20)) || !strings.HasSuffix(lines[1], "(4.00x)") {
+		t.Errorf("slowest bar = %q, want full width and ratio 4.00x", lines[1])
+	}
+}
+
+func TestWriteLoad(t *testing.T) {
+	m := &Machine{Host: "build-01", OS: "linux", Arch: "amd64", Cores: 16}
+	rs := []Result{
+		{Language: "go", Format: "ffire", Message: "struct", TotalNs: 300, Machine: m},
+		{Language: "cpp", Format: "ffire", Message: "struct", TotalNs: 200, Machine: m},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, rs); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, `"version": 1`) || strings.Count(out, `"host"`) != 1 {
+		t.Errorf("single-machine file should hold the version and the machine once:\n%s", out)
+	}
+
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded) != 2 || loaded[1].Machine == nil || *loaded[1].Machine != *m {
+		t.Fatalf("Load = %+v, want both results on %v", loaded, m)
+	}
+
+	// Merged results keep a machine each
+	other := &Machine{Host: "mbp", OS: "darwin", Arch: "arm64", Cores: 12}
+	merged := append(loaded, Result{Language: "go", Format: "ffire", Message: "struct", TotalNs: 250, Machine: other})
+	buf.Reset()
+	if err := Write(&buf, merged); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms := Machines(loaded); len(ms) != 2 || ms[1].Host != "mbp" {
+		t.Errorf("Machines of the merged file = %v, want build-01 and mbp", ms)
+	}
+}
+
+func TestLoadNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "results": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("Load error = %v, want unsupported version", err)
+	}
+}
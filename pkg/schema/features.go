@@ -0,0 +1,200 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ApplyFeatures removes fields guarded by feature flags that are not enabled.
+// A field annotated with @feature("v2") is kept only if "v2" is in enabled;
+// a field listing several features is kept if any of them is enabled.
+// Fields without feature flags are always kept. The referenced flags that
+// are enabled are recorded in s.EnabledFeatures.
+//
+// This must run before Canonicalize and code generation, since it changes
+// the set of fields (and therefore the wire layout) of affected structs.
+func (s *Schema) ApplyFeatures(enabled []string) {
+	on := make(map[string]bool, len(enabled))
+	for _, f := range enabled {
+		on[f] = true
+	}
+	s.EnabledFeatures = nil
+	for _, f := range s.Features() {
+		if on[f] {
+			s.EnabledFeatures = append(s.EnabledFeatures, f)
+		}
+	}
+
+	// Walk every reachable type: optional references are stored as copies
+	// of the struct, so each copy needs its own field list filtered.
+	visited := make(map[*StructType]bool)
+	var walk func(t Type)
+	walk = func(t Type) {
+		switch typ := t.(type) {
+		case *StructType:
+			if visited[typ] {
+				return
+			}
+			visited[typ] = true
+			kept := typ.Fields[:0:0]
+			for _, field := range typ.Fields {
+				if fieldEnabled(field, on) {
+					kept = append(kept, field)
+				}
+			}
+			typ.Fields = kept
+			for _, field := range typ.Fields {
+				walk(field.Type)
+			}
+		case *ArrayType:
+			walk(typ.ElementType)
+		case *MapType:
+			walk(typ.ValueType)
+		case *UnionType:
+			for _, v := range typ.Variants {
+				walk(v)
+			}
+		}
+	}
+
+	for _, t := range s.Types {
+		walk(t)
+	}
+	for _, msg := range s.Messages {
+		walk(msg.TargetType)
+	}
+}
+
+// SelectMessages keeps only the named message types, so consumers of a large
+// shared schema can generate codecs for just the messages they use. Types are
+// left in place; only the public encode/decode entry points are dropped.
+// An empty names list keeps every message.
+func (s *Schema) SelectMessages(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+
+	kept := s.Messages[:0:0]
+	for _, msg := range s.Messages {
+		if want[msg.Name] {
+			kept = append(kept, msg)
+			delete(want, msg.Name)
+		}
+	}
+
+	if len(want) > 0 {
+		var unknown []string
+		for _, name := range names {
+			if want[name] {
+				unknown = append(unknown, name)
+				delete(want, name)
+			}
+		}
+		var available []string
+		for _, msg := range s.Messages {
+			available = append(available, msg.Name)
+		}
+		return fmt.Errorf("unknown message type %s (schema has: %s)",
+			strings.Join(unknown, ", "), strings.Join(available, ", "))
+	}
+
+	s.Messages = kept
+	return nil
+}
+
+// ReachableTypes returns the declared types that some message refers to,
+// directly or through fields, array elements, map keys and values and union
+// variants, in declaration order. Types outside this set have no encode or
+// decode path, so generators can omit them.
+func (s *Schema) ReachableTypes() []Type {
+	// Named types match by name, since optional references are copies of
+	// the declared type; other declarations (array aliases) by identity.
+	named := make(map[string]bool)
+	seen := make(map[Type]bool)
+	var walk func(t Type)
+	walk = func(t Type) {
+		seen[t] = true
+		switch typ := t.(type) {
+		case *StructType:
+			if named[typ.Name] {
+				return
+			}
+			named[typ.Name] = true
+			for _, field := range typ.Fields {
+				walk(field.Type)
+			}
+		case *EnumType:
+			named[typ.Name] = true
+		case *UnionType:
+			if named[typ.Name] {
+				return
+			}
+			named[typ.Name] = true
+			for _, v := range typ.Variants {
+				walk(v)
+			}
+		case *ArrayType:
+			walk(typ.ElementType)
+		case *MapType:
+			walk(typ.KeyType)
+			walk(typ.ValueType)
+		}
+	}
+	for _, msg := range s.Messages {
+		walk(msg.TargetType)
+	}
+
+	var kept []Type
+	for _, t := range s.Types {
+		switch t.(type) {
+		case *StructType, *EnumType, *UnionType:
+			if named[t.TypeName()] {
+				kept = append(kept, t)
+			}
+		default:
+			if seen[t] {
+				kept = append(kept, t)
+			}
+		}
+	}
+	return kept
+}
+
+// Features returns all feature flag names referenced by the schema, in
+// first-seen order.
+func (s *Schema) Features() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, t := range s.Types {
+		st, ok := t.(*StructType)
+		if !ok {
+			continue
+		}
+		for _, field := range st.Fields {
+			for _, f := range field.Features {
+				if !seen[f] {
+					seen[f] = true
+					names = append(names, f)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func fieldEnabled(f Field, on map[string]bool) bool {
+	if len(f.Features) == 0 {
+		return true
+	}
+	for _, name := range f.Features {
+		if on[name] {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,21 @@
 // Package schema provides the AST representation for ffire schemas.
 package schema
 
+import (
+	"sort"
+	"strconv"
+)
+
 // Schema represents a complete .ffi schema file.
 type Schema struct {
 	Package  string        // Package name
+	Version  string        // Package version from @version, or ""
 	Messages []MessageType // Message types (public encode/decode)
 	Types    []Type        // All type definitions
+
+	// EnabledFeatures lists the feature flags referenced by the schema
+	// that ApplyFeatures kept, in first-seen order.
+	EnabledFeatures []string
 }
 
 // MessageType represents a type alias that generates public encode/decode.
@@ -13,6 +23,7 @@ type Schema struct {
 type MessageType struct {
 	Name       string // Alias name (e.g., "DeviceList")
 	TargetType Type   // What it aliases (e.g., ArrayType of Device)
+	Doc        string // Doc comment of the type declaration, or ""
 }
 
 // Type represents any type in the schema.
@@ -23,8 +34,10 @@ type Type interface {
 
 // PrimitiveType represents built-in types.
 type PrimitiveType struct {
-	Name     string // "bool", "int8", "int16", "int32", "int64", "float32", "float64", "string"
+	Name     string // "bool", "int8", "int16", "int32", "int64", "float32", "float64", "string", "bytes"
 	Optional bool
+	Large    bool   // @large: a string or bytes with a uint32 length prefix
+	Max      uint32 // @max: the most bytes a string or bytes holds, 0 for the prefix limit
 }
 
 func (p *PrimitiveType) TypeName() string { return p.Name }
@@ -35,6 +48,9 @@ type StructType struct {
 	Name     string
 	Fields   []Field
 	Optional bool
+	Doc      string // Doc comment of the type declaration, or ""
+	Bitmap   bool   // @bitmap: a presence bitmap replaces the presence bytes of optional fields
+	Framed   bool   // @framed: the struct's encoded length precedes its fields
 }
 
 func (s *StructType) TypeName() string { return s.Name }
@@ -42,10 +58,13 @@ func (s *StructType) IsOptional() bool { return s.Optional }
 
 // Field represents a struct field.
 type Field struct {
-	Name    string
-	Type    Type
-	Tag     string // Full struct tag (e.g., `json:"name" yaml:"name" db:"name"`)
-	jsonTag string // Cached JSON tag name for internal use
+	Name     string
+	Type     Type
+	Tag      string   // Full struct tag (e.g., `json:"name" yaml:"name" db:"name"`)
+	Features []string // Feature flags guarding this field (empty = always compiled in)
+	Cached   bool     // @cached: encoders reuse pre-encoded bytes for repeated values
+	Doc      string   // Doc comment of the field, or ""
+	jsonTag  string   // Cached JSON tag name for internal use
 }
 
 // JSONName returns the JSON field name (from json tag if present, otherwise field name).
@@ -61,17 +80,449 @@ func (f *Field) SetJSONTag(tag string) {
 	f.jsonTag = tag
 }
 
-// ArrayType represents an array/slice type.
+// ArrayType represents an array/slice type. Length is set for fixed-size
+// arrays such as [16]float32, which are encoded without a length prefix.
 type ArrayType struct {
 	ElementType Type
+	Length      int // Element count of a fixed-size array, 0 for slices
 	Optional    bool
+	Large       bool   // @large: a slice with a uint32 length prefix
+	Max         uint32 // @max: the most elements a slice holds, 0 for the prefix limit
 }
 
 func (a *ArrayType) TypeName() string {
+	if a.Length > 0 {
+		return "[" + strconv.Itoa(a.Length) + "]" + a.ElementType.TypeName()
+	}
 	return "[]" + a.ElementType.TypeName()
 }
 func (a *ArrayType) IsOptional() bool { return a.Optional }
 
+// MaxFixedArrayLength is the largest element count of a fixed-size array,
+// matching the element limit of length-prefixed arrays.
+const MaxFixedArrayLength = 65535
+
+// IsFixedArray reports whether t is a fixed-size array.
+func IsFixedArray(t Type) bool {
+	arr, ok := t.(*ArrayType)
+	return ok && arr.Length > 0
+}
+
+// Length prefixes of strings, bytes, slices and maps are a uint16, which
+// caps them at MaxLength bytes, elements or entries. A type marked @large
+// has a uint32 prefix instead, capped at MaxLargeLength. Only the prefix
+// of the marked type changes: the elements of a @large []string keep
+// their uint16 prefixes.
+
+// Length limits of length-prefixed values.
+const (
+	MaxLength      = 65535
+	MaxLargeLength = 4294967295
+)
+
+// IsLarge reports whether t has a uint32 length prefix.
+func IsLarge(t Type) bool {
+	switch typ := t.(type) {
+	case *PrimitiveType:
+		return typ.Large
+	case *ArrayType:
+		return typ.Large
+	case *MapType:
+		return typ.Large
+	}
+	return false
+}
+
+// CanBeLarge reports whether @large applies to t: a string, bytes, slice
+// or map, which are the types with a length prefix.
+func CanBeLarge(t Type) bool {
+	switch typ := t.(type) {
+	case *PrimitiveType:
+		return typ.Name == "string" || typ.Name == "bytes"
+	case *ArrayType:
+		return typ.Length == 0
+	case *MapType:
+		return true
+	}
+	return false
+}
+
+// LengthPrefixSize returns the size in bytes of t's length prefix: 4 if
+// t is @large, 2 otherwise.
+func LengthPrefixSize(t Type) int {
+	if IsLarge(t) {
+		return 4
+	}
+	return 2
+}
+
+// A string, bytes, slice or map marked @max(n) holds at most n bytes,
+// elements or entries. The limit does not change the encoding: it lets
+// validators reject longer values and lets generators, such as the
+// embedded C++ profile, store the value in fixed capacity.
+
+// MaxOf returns t's @max limit, or 0 if it has none.
+func MaxOf(t Type) uint32 {
+	switch typ := t.(type) {
+	case *PrimitiveType:
+		return typ.Max
+	case *ArrayType:
+		return typ.Max
+	case *MapType:
+		return typ.Max
+	}
+	return 0
+}
+
+// PrefixLimit returns the largest length t's prefix holds.
+func PrefixLimit(t Type) uint64 {
+	if IsLarge(t) {
+		return MaxLargeLength
+	}
+	return MaxLength
+}
+
+// MaxLengthOf returns the largest length t may have: its @max limit, or
+// else the largest length its prefix holds.
+func MaxLengthOf(t Type) uint64 {
+	if n := MaxOf(t); n > 0 {
+		return uint64(n)
+	}
+	return PrefixLimit(t)
+}
+
+// A struct marked @bitmap starts with a presence bitmap instead of giving
+// each optional field a presence byte: bit i%8 of byte i/8 is set when the
+// struct's i-th optional field, counted in wire order, is present. Present
+// fields follow without a presence byte and absent ones are left out. Unused
+// bits are zero. Optional array elements, map values and union variants
+// keep their presence bytes.
+
+// OptionalFields returns the number of optional fields in st.
+func OptionalFields(st *StructType) int {
+	n := 0
+	for _, field := range st.Fields {
+		if field.Type.IsOptional() {
+			n++
+		}
+	}
+	return n
+}
+
+// BitmapSize returns the size in bytes of st's presence bitmap: one bit
+// per optional field, rounded up to whole bytes, or 0 unless st is
+// @bitmap.
+func BitmapSize(st *StructType) int {
+	if !st.Bitmap {
+		return 0
+	}
+	return (OptionalFields(st) + 7) / 8
+}
+
+// WithoutPresence returns a copy of the optional type t that is encoded
+// without a presence byte, for fields whose presence is in a bitmap.
+func WithoutPresence(t Type) Type {
+	switch typ := t.(type) {
+	case *PrimitiveType:
+		c := *typ
+		c.Optional = false
+		return &c
+	case *StructType:
+		c := *typ
+		c.Optional = false
+		return &c
+	case *ArrayType:
+		c := *typ
+		c.Optional = false
+		return &c
+	case *MapType:
+		c := *typ
+		c.Optional = false
+		return &c
+	case *EnumType:
+		c := *typ
+		c.Optional = false
+		return &c
+	case *UnionType:
+		c := *typ
+		c.Optional = false
+		return &c
+	}
+	return t
+}
+
+// A struct marked @framed starts with a uint32 frame: the number of bytes
+// of the rest of the struct, presence bitmap included, so a decoder can
+// skip the struct without decoding it. The frame follows the presence
+// byte of an optional struct and precedes every encoding of the struct:
+// as a field, array element, map value, union variant or message root.
+
+// FrameSize is the size in bytes of a @framed struct's frame.
+const FrameSize = 4
+
+// FramePrefixSize returns the size in bytes of st's frame: FrameSize if
+// st is @framed, 0 otherwise.
+func FramePrefixSize(st *StructType) int {
+	if !st.Framed {
+		return 0
+	}
+	return FrameSize
+}
+
+// MapType represents a map type. Keys are non-optional strings, integers
+// or bools (see IsMapKey); values may be any type.
+type MapType struct {
+	KeyType   Type
+	ValueType Type
+	Optional  bool
+	Large     bool   // @large: a map with a uint32 entry count
+	Max       uint32 // @max: the most entries a map holds, 0 for the prefix limit
+}
+
+func (m *MapType) TypeName() string {
+	return "map[" + m.KeyType.TypeName() + "]" + m.ValueType.TypeName()
+}
+func (m *MapType) IsOptional() bool { return m.Optional }
+
+// IsMapKey reports whether t may be used as a map key: a non-optional
+// string, integer or bool. Floats are excluded since NaN != NaN, and bytes
+// since several languages cannot hash byte arrays by value.
+func IsMapKey(t Type) bool {
+	prim, ok := t.(*PrimitiveType)
+	if !ok || prim.Optional {
+		return false
+	}
+	return prim.Name != "float32" && prim.Name != "float64" && prim.Name != "bytes"
+}
+
+// EnumType is a named integer type with a closed set of values, declared
+// as in Go: type Mode int8 followed by constants of type Mode. It encodes
+// as its base integer; decoders reject values that are not listed.
+type EnumType struct {
+	Name     string
+	Base     string      // Backing integer type: "int8", "int16", "int32" or "int64"
+	Values   []EnumValue // In declaration order
+	Optional bool
+	Doc      string // Doc comment of the type declaration, or ""
+}
+
+// EnumValue is a named enum constant.
+type EnumValue struct {
+	Name  string
+	Value int64
+	Doc   string // Doc comment of the constant, or ""
+}
+
+func (e *EnumType) TypeName() string { return e.Name }
+func (e *EnumType) IsOptional() bool { return e.Optional }
+
+// BaseType returns the integer type e encodes as, carrying e's optional
+// flag.
+func (e *EnumType) BaseType() *PrimitiveType {
+	return &PrimitiveType{Name: e.Base, Optional: e.Optional}
+}
+
+// Lookup returns the name of value v, or false if v is not a member.
+func (e *EnumType) Lookup(v int64) (string, bool) {
+	for _, ev := range e.Values {
+		if ev.Value == v {
+			return ev.Name, true
+		}
+	}
+	return "", false
+}
+
+// ValueOf returns the value of the constant called name.
+func (e *EnumType) ValueOf(name string) (int64, bool) {
+	for _, ev := range e.Values {
+		if ev.Name == name {
+			return ev.Value, true
+		}
+	}
+	return 0, false
+}
+
+// UnionType is a tagged union, declared as a type-set interface:
+// type Shape interface { Circle | Square | string }. A value holds exactly
+// one variant and encodes as a uint8 tag, the variant's 1-based position,
+// followed by the variant's value. Tag 0 is never written.
+type UnionType struct {
+	Name     string
+	Variants []Type // Structs, enums or primitives, in declaration order
+	Optional bool
+	Doc      string // Doc comment of the type declaration, or ""
+}
+
+// MaxUnionVariants is the most variants a union may have: tags are a uint8
+// and 0 is reserved.
+const MaxUnionVariants = 255
+
+func (u *UnionType) TypeName() string { return u.Name }
+func (u *UnionType) IsOptional() bool { return u.Optional }
+
+// Variant returns the variant called name, which is the variant type's
+// name, and its tag.
+func (u *UnionType) Variant(name string) (Type, int, bool) {
+	for i, v := range u.Variants {
+		if v.TypeName() == name {
+			return v, i + 1, true
+		}
+	}
+	return nil, 0, false
+}
+
+// Enums returns the enum types declared in the schema, in declaration
+// order. Generators emit a native enum for each.
+func (s *Schema) Enums() []*EnumType {
+	var enums []*EnumType
+	for _, t := range s.Types {
+		if e, ok := t.(*EnumType); ok {
+			enums = append(enums, e)
+		}
+	}
+	return enums
+}
+
+// Unions returns the union types declared in the schema, in declaration
+// order.
+func (s *Schema) Unions() []*UnionType {
+	var unions []*UnionType
+	for _, t := range s.Types {
+		if u, ok := t.(*UnionType); ok {
+			unions = append(unions, u)
+		}
+	}
+	return unions
+}
+
+// HasMaps reports whether any type reachable from the schema is a map.
+// Generators use it to pull in map support only when needed.
+func (s *Schema) HasMaps() bool {
+	return s.reaches(func(t Type) bool {
+		_, ok := t.(*MapType)
+		return ok
+	})
+}
+
+// HasBytes reports whether any type reachable from the schema is the bytes
+// primitive.
+func (s *Schema) HasBytes() bool {
+	return s.reaches(func(t Type) bool {
+		prim, ok := t.(*PrimitiveType)
+		return ok && prim.Name == "bytes"
+	})
+}
+
+// HasFloat64 reports whether any type reachable from the schema is the
+// float64 primitive.
+func (s *Schema) HasFloat64() bool {
+	return s.reaches(func(t Type) bool {
+		prim, ok := t.(*PrimitiveType)
+		return ok && prim.Name == "float64"
+	})
+}
+
+// HasFixedArrays reports whether any type reachable from the schema is a
+// fixed-size array.
+func (s *Schema) HasFixedArrays() bool {
+	return s.reaches(IsFixedArray)
+}
+
+// HasLarge reports whether any type reachable from the schema is @large,
+// so generators emit their uint32 length helpers only when they are used.
+func (s *Schema) HasLarge() bool {
+	return s.reaches(IsLarge)
+}
+
+// HasBitmaps reports whether any reachable struct is @bitmap.
+func (s *Schema) HasBitmaps() bool {
+	return s.reaches(func(t Type) bool {
+		st, ok := t.(*StructType)
+		return ok && st.Bitmap
+	})
+}
+
+// HasFramed reports whether any reachable struct is @framed.
+func (s *Schema) HasFramed() bool {
+	return s.reaches(func(t Type) bool {
+		st, ok := t.(*StructType)
+		return ok && st.Framed
+	})
+}
+
+// HasCachedFields reports whether any reachable struct has a @cached
+// field, so generators emit their string cache only when it is used.
+func (s *Schema) HasCachedFields() bool {
+	return s.reaches(func(t Type) bool {
+		st, ok := t.(*StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range st.Fields {
+			if field.Cached {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// IsCacheable reports whether @cached applies to t: a string, optional or
+// not, or an array of them.
+func IsCacheable(t Type) bool {
+	if arr, ok := t.(*ArrayType); ok && arr.Length == 0 {
+		t = arr.ElementType
+	}
+	prim, ok := t.(*PrimitiveType)
+	return ok && prim.Name == "string"
+}
+
+// reaches reports whether match holds for any type reachable from the
+// schema's types and messages.
+func (s *Schema) reaches(match func(Type) bool) bool {
+	visited := make(map[*StructType]bool)
+	var walk func(t Type) bool
+	walk = func(t Type) bool {
+		if match(t) {
+			return true
+		}
+		switch typ := t.(type) {
+		case *MapType:
+			return walk(typ.KeyType) || walk(typ.ValueType)
+		case *ArrayType:
+			return walk(typ.ElementType)
+		case *UnionType:
+			for _, v := range typ.Variants {
+				if walk(v) {
+					return true
+				}
+			}
+		case *StructType:
+			if visited[typ] {
+				return false
+			}
+			visited[typ] = true
+			for _, field := range typ.Fields {
+				if walk(field.Type) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	for _, t := range s.Types {
+		if walk(t) {
+			return true
+		}
+	}
+	for _, msg := range s.Messages {
+		if walk(msg.TargetType) {
+			return true
+		}
+	}
+	return false
+}
+
 // Canonicalize sorts all struct fields in canonical wire format order.
 // This should be called once before code generation.
 // The canonical order is:
@@ -79,16 +530,40 @@ func (a *ArrayType) IsOptional() bool { return a.Optional }
 // 2. Variable-size fields (strings, arrays), alphabetically
 // 3. Optional fields, alphabetically
 func (s *Schema) Canonicalize() {
-	// Canonicalize all struct types
+	// Canonicalize all struct types. Fields are sorted in place: the
+	// optional copies of a struct that fields refer to share its Fields.
 	for _, t := range s.Types {
 		if st, ok := t.(*StructType); ok {
-			st.Fields = SortFieldsCanonical(st.Fields)
+			copy(st.Fields, SortFieldsCanonical(st.Fields))
 		}
 	}
 	// Canonicalize root message types that are structs
 	for _, msg := range s.Messages {
 		if st, ok := msg.TargetType.(*StructType); ok {
-			st.Fields = SortFieldsCanonical(st.Fields)
+			copy(st.Fields, SortFieldsCanonical(st.Fields))
+		}
+	}
+}
+
+// SaveFieldOrder records the field order of every struct type and returns
+// a function that restores it, undoing later calls to Canonicalize.
+func (s *Schema) SaveFieldOrder() (restore func()) {
+	saved := make(map[*StructType][]Field)
+	for _, t := range s.Types {
+		if st, ok := t.(*StructType); ok {
+			saved[st] = append([]Field(nil), st.Fields...)
+		}
+	}
+	for _, msg := range s.Messages {
+		if st, ok := msg.TargetType.(*StructType); ok {
+			saved[st] = append([]Field(nil), st.Fields...)
+		}
+	}
+	return func() {
+		// In place, like Canonicalize, so optional copies sharing the
+		// slices are restored too
+		for st, fields := range saved {
+			copy(st.Fields, fields)
 		}
 	}
 }
@@ -120,35 +595,40 @@ func (s *Schema) FindType(name string) Type {
 	return nil
 }
 
+// primitiveSizes maps each built-in primitive to its byte size; string and
+// bytes are variable size and map to 0.
+var primitiveSizes = map[string]int{
+	"bool":    1,
+	"int8":    1,
+	"int16":   2,
+	"int32":   4,
+	"int64":   8,
+	"float32": 4,
+	"float64": 8,
+	"string":  0, // variable size
+	"bytes":   0, // variable size
+}
+
 // IsPrimitive checks if a type name is a built-in primitive.
 func IsPrimitive(name string) bool {
-	primitives := map[string]bool{
-		"bool":    true,
-		"int8":    true,
-		"int16":   true,
-		"int32":   true,
-		"int64":   true,
-		"float32": true,
-		"float64": true,
-		"string":  true,
+	_, ok := primitiveSizes[name]
+	return ok
+}
+
+// PrimitiveNames returns the names of all built-in primitives, sorted.
+func PrimitiveNames() []string {
+	names := make([]string, 0, len(primitiveSizes))
+	for name := range primitiveSizes {
+		names = append(names, name)
 	}
-	return primitives[name]
+	sort.Strings(names)
+	return names
 }
 
 // PrimitiveSize returns the byte size of a primitive type.
-// Returns 0 for variable-size types like string.
+// Returns 0 for variable-size types like string and bytes.
 func PrimitiveSize(name string) int {
-	sizes := map[string]int{
-		"bool":    1,
-		"int8":    1,
-		"int16":   2,
-		"int32":   4,
-		"int64":   8,
-		"float32": 4,
-		"float64": 8,
-		"string":  0, // variable size
-	}
-	return sizes[name]
+	return primitiveSizes[name]
 }
 
 // FieldCategory represents the ordering category for canonical field ordering.
@@ -159,7 +639,7 @@ const (
 	CategoryFixed4                         // 4-byte fixed (int32, float32)
 	CategoryFixed2                         // 2-byte fixed (int16)
 	CategoryFixed1                         // 1-byte fixed (bool, int8)
-	CategoryVariable                       // variable size (string, arrays)
+	CategoryVariable                       // variable size (string, arrays, maps)
 	CategoryOptional                       // optional fields (any type)
 )
 
@@ -183,14 +663,21 @@ func getTypeCategory(t Type) FieldCategory {
 			return CategoryFixed2
 		case "bool", "int8":
 			return CategoryFixed1
-		case "string":
+		case "string", "bytes":
 			return CategoryVariable
 		}
+	case *EnumType:
+		return getTypeCategory(typ.BaseType())
 	case *ArrayType:
 		if typ.Optional {
 			return CategoryOptional
 		}
 		return CategoryVariable
+	case *MapType, *UnionType:
+		if typ.IsOptional() {
+			return CategoryOptional
+		}
+		return CategoryVariable
 	case *StructType:
 		if typ.Optional {
 			return CategoryOptional
@@ -221,14 +708,18 @@ func IsFixedSizeType(t Type) bool {
 		if typ.Optional {
 			return false
 		}
-		return typ.Name != "string"
+		return typ.Name != "string" && typ.Name != "bytes"
 	case *StructType:
 		if typ.Optional {
 			return false
 		}
 		return IsFixedSizeStruct(typ)
-	case *ArrayType:
-		return false // Arrays are always variable size
+	case *EnumType:
+		return !typ.Optional
+	case *ArrayType, *MapType, *UnionType:
+		// Arrays, maps and unions are variable size. Fixed-size arrays are
+		// too for layout purposes, so struct fast paths never see them.
+		return false
 	}
 	return false
 }
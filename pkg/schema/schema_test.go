@@ -1,6 +1,9 @@
 package schema
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestPrimitiveTypes(t *testing.T) {
 	tests := []struct {
@@ -515,6 +518,10 @@ func TestCanonicalizeSchema(t *testing.T) {
 		},
 	}
 
+	// An optional Address, as the parser makes it, shares its fields
+	optAddr := *s.Types[0].(*StructType)
+	optAddr.Optional = true
+
 	// Canonicalize
 	s.Canonicalize()
 
@@ -529,6 +536,9 @@ func TestCanonicalizeSchema(t *testing.T) {
 	if addrType.Fields[2].Name != "Street" {
 		t.Errorf("Address field 2: expected Street, got %s", addrType.Fields[2].Name)
 	}
+	if optAddr.Fields[0].Name != "ZipCode" {
+		t.Errorf("optional Address field 0: expected ZipCode, got %s", optAddr.Fields[0].Name)
+	}
 
 	// Check that Person fields are reordered: Id (int64), Age (int32), Name (string)
 	personType := s.Messages[0].TargetType.(*StructType)
@@ -542,3 +552,223 @@ func TestCanonicalizeSchema(t *testing.T) {
 		t.Errorf("Person field 2: expected Name, got %s", personType.Fields[2].Name)
 	}
 }
+
+func TestSaveFieldOrder(t *testing.T) {
+	addr := &StructType{
+		Name: "Address",
+		Fields: []Field{
+			{Name: "City", Type: &PrimitiveType{Name: "string"}},
+			{Name: "ZipCode", Type: &PrimitiveType{Name: "int32"}},
+		},
+	}
+	optAddr := *addr
+	optAddr.Optional = true
+	s := &Schema{Package: "test", Types: []Type{addr}}
+
+	restore := s.SaveFieldOrder()
+	s.Canonicalize()
+	if addr.Fields[0].Name != "ZipCode" {
+		t.Fatalf("Address field 0: expected ZipCode, got %s", addr.Fields[0].Name)
+	}
+	restore()
+	if addr.Fields[0].Name != "City" || optAddr.Fields[0].Name != "City" {
+		t.Errorf("field order not restored: %s, optional %s", addr.Fields[0].Name, optAddr.Fields[0].Name)
+	}
+}
+
+func TestApplyFeatures(t *testing.T) {
+	inner := &StructType{
+		Name: "Inner",
+		Fields: []Field{
+			{Name: "A", Type: &PrimitiveType{Name: "int32"}},
+			{Name: "B", Type: &PrimitiveType{Name: "int32"}, Features: []string{"beta"}},
+		},
+	}
+	optInner := *inner
+	optInner.Optional = true
+
+	outer := &StructType{
+		Name: "Outer",
+		Fields: []Field{
+			{Name: "Base", Type: &PrimitiveType{Name: "string"}},
+			{Name: "V2", Type: &PrimitiveType{Name: "string"}, Features: []string{"v2"}},
+			{Name: "Either", Type: &PrimitiveType{Name: "bool"}, Features: []string{"v2", "beta"}},
+			{Name: "Child", Type: &optInner},
+		},
+	}
+
+	s := &Schema{
+		Package:  "test",
+		Types:    []Type{outer, inner},
+		Messages: []MessageType{{Name: "Outer", TargetType: outer}},
+	}
+
+	if got := s.Features(); len(got) != 2 || got[0] != "v2" || got[1] != "beta" {
+		t.Errorf("Features() = %v, want [v2 beta]", got)
+	}
+
+	s.ApplyFeatures([]string{"beta", "unused"})
+	if len(s.EnabledFeatures) != 1 || s.EnabledFeatures[0] != "beta" {
+		t.Errorf("EnabledFeatures = %v, want [beta]", s.EnabledFeatures)
+	}
+
+	var names []string
+	for _, f := range outer.Fields {
+		names = append(names, f.Name)
+	}
+	if len(names) != 3 || names[0] != "Base" || names[1] != "Either" || names[2] != "Child" {
+		t.Errorf("Outer fields = %v, want [Base Either Child]", names)
+	}
+	if len(inner.Fields) != 2 || len(optInner.Fields) != 2 {
+		t.Errorf("Inner fields = %d/%d, want 2/2 with beta enabled", len(inner.Fields), len(optInner.Fields))
+	}
+
+	s.ApplyFeatures(nil)
+	if len(outer.Fields) != 2 || len(inner.Fields) != 1 || len(optInner.Fields) != 1 {
+		t.Errorf("after disabling all features: outer=%d inner=%d optInner=%d, want 2/1/1",
+			len(outer.Fields), len(inner.Fields), len(optInner.Fields))
+	}
+}
+
+func TestSelectMessages(t *testing.T) {
+	device := &StructType{Name: "Device", Fields: []Field{{Name: "ID", Type: &PrimitiveType{Name: "int32"}}}}
+	plugin := &StructType{Name: "Plugin", Fields: []Field{{Name: "Name", Type: &PrimitiveType{Name: "string"}}}}
+	s := &Schema{
+		Package: "test",
+		Types:   []Type{device, plugin},
+		Messages: []MessageType{
+			{Name: "Device", TargetType: device},
+			{Name: "PluginList", TargetType: &ArrayType{ElementType: plugin}},
+			{Name: "Plugin", TargetType: plugin},
+		},
+	}
+
+	if err := s.SelectMessages(nil); err != nil || len(s.Messages) != 3 {
+		t.Fatalf("SelectMessages(nil) = %v with %d messages, want nil with 3", err, len(s.Messages))
+	}
+
+	if err := s.SelectMessages([]string{"Plugin", "Missing"}); err == nil {
+		t.Fatal("expected error for unknown message type")
+	} else if !strings.Contains(err.Error(), "Missing") || strings.Contains(err.Error(), "type Plugin") {
+		t.Errorf("error = %q, want it to name only Missing", err)
+	}
+	if len(s.Messages) != 3 {
+		t.Errorf("failed selection changed messages: got %d, want 3", len(s.Messages))
+	}
+
+	if err := s.SelectMessages([]string{"Plugin", "Device"}); err != nil {
+		t.Fatalf("SelectMessages: %v", err)
+	}
+	if len(s.Messages) != 2 || s.Messages[0].Name != "Device" || s.Messages[1].Name != "Plugin" {
+		t.Errorf("Messages = %v, want [Device Plugin] in schema order", s.Messages)
+	}
+	if len(s.Types) != 2 {
+		t.Errorf("Types = %d, want 2 (types are kept)", len(s.Types))
+	}
+}
+
+func TestReachableTypes(t *testing.T) {
+	kind := &EnumType{Name: "Kind", Base: "int8"}
+	device := &StructType{Name: "Device", Fields: []Field{{Name: "Kind", Type: kind}}}
+	optDevice := *device
+	optDevice.Optional = true
+	plugin := &StructType{Name: "Plugin", Fields: []Field{{Name: "Device", Type: &optDevice}}}
+	pluginList := &ArrayType{ElementType: plugin}
+	unused := &StructType{Name: "unused", Fields: []Field{{Name: "Tags", Type: &MapType{
+		KeyType:   &PrimitiveType{Name: "string"},
+		ValueType: &PrimitiveType{Name: "int32"},
+	}}}}
+	s := &Schema{
+		Package:  "test",
+		Types:    []Type{unused, kind, device, plugin, pluginList},
+		Messages: []MessageType{{Name: "PluginList", TargetType: pluginList}},
+	}
+
+	var names []string
+	for _, typ := range s.ReachableTypes() {
+		names = append(names, typ.TypeName())
+	}
+	if got, want := strings.Join(names, " "), "Kind Device Plugin []Plugin"; got != want {
+		t.Errorf("ReachableTypes() = %s, want %s", got, want)
+	}
+
+	s.Messages = []MessageType{{Name: "Device", TargetType: device}}
+	names = nil
+	for _, typ := range s.ReachableTypes() {
+		names = append(names, typ.TypeName())
+	}
+	if got, want := strings.Join(names, " "), "Kind Device"; got != want {
+		t.Errorf("ReachableTypes() for Device = %s, want %s", got, want)
+	}
+}
+
+func TestUnionVariant(t *testing.T) {
+	circle := &StructType{Name: "Circle", Fields: []Field{{Name: "R", Type: &PrimitiveType{Name: "float32"}}}}
+	shape := &UnionType{Name: "Shape", Variants: []Type{circle, &PrimitiveType{Name: "string"}}}
+
+	if typ, tag, ok := shape.Variant("Circle"); !ok || typ != Type(circle) || tag != 1 {
+		t.Errorf("Variant(Circle) = %v, %d, %v; want circle, 1, true", typ, tag, ok)
+	}
+	if _, tag, ok := shape.Variant("string"); !ok || tag != 2 {
+		t.Errorf("Variant(string) tag = %d, %v; want 2, true", tag, ok)
+	}
+	if _, _, ok := shape.Variant("Square"); ok {
+		t.Error("Variant(Square) should not exist")
+	}
+	if got := getTypeCategory(shape); got != CategoryVariable {
+		t.Errorf("union category = %d, want CategoryVariable", got)
+	}
+	if got := getTypeCategory(&UnionType{Name: "Shape", Optional: true}); got != CategoryOptional {
+		t.Errorf("optional union category = %d, want CategoryOptional", got)
+	}
+}
+
+func TestHasBytes(t *testing.T) {
+	blob := &PrimitiveType{Name: "bytes"}
+	inner := &StructType{Name: "Inner", Fields: []Field{{Name: "Data", Type: blob}}}
+	outer := &StructType{Name: "Outer", Fields: []Field{{Name: "Inner", Type: &ArrayType{ElementType: inner}}}}
+
+	s := &Schema{Messages: []MessageType{{Name: "Outer", TargetType: outer}}}
+	if !s.HasBytes() {
+		t.Error("HasBytes() = false for bytes nested in an array of structs")
+	}
+	if s.HasMaps() {
+		t.Error("HasMaps() = true for a schema without maps")
+	}
+
+	inner.Fields[0].Type = &PrimitiveType{Name: "string"}
+	if s.HasBytes() {
+		t.Error("HasBytes() = true for a schema without bytes")
+	}
+
+	if got := getTypeCategory(blob); got != CategoryVariable {
+		t.Errorf("bytes category = %d, want CategoryVariable", got)
+	}
+	if IsFixedSizeType(blob) {
+		t.Error("bytes should not be fixed size")
+	}
+}
+
+func TestHasFixedArrays(t *testing.T) {
+	samples := &ArrayType{ElementType: &PrimitiveType{Name: "float32"}, Length: 16}
+	if got := samples.TypeName(); got != "[16]float32" {
+		t.Errorf("TypeName() = %q, want [16]float32", got)
+	}
+	if !IsFixedArray(samples) || IsFixedArray(&ArrayType{ElementType: samples.ElementType}) {
+		t.Error("IsFixedArray should hold only for arrays with a length")
+	}
+
+	frame := &StructType{Name: "Frame", Fields: []Field{{Name: "Samples", Type: samples}}}
+	s := &Schema{Messages: []MessageType{{Name: "Frames", TargetType: &ArrayType{ElementType: frame}}}}
+	if !s.HasFixedArrays() {
+		t.Error("HasFixedArrays() = false for a fixed array inside an array of structs")
+	}
+	frame.Fields[0].Type = &ArrayType{ElementType: samples.ElementType}
+	if s.HasFixedArrays() {
+		t.Error("HasFixedArrays() = true for a schema of slices")
+	}
+
+	if got := getTypeCategory(samples); got != CategoryVariable {
+		t.Errorf("fixed array category = %d, want CategoryVariable", got)
+	}
+}
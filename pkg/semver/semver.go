@@ -0,0 +1,149 @@
+// Package semver parses and bumps semantic versions (https://semver.org)
+// used for generated package versions.
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version such as 1.4.0 or 2.0.0-rc.1+build.5.
+type Version struct {
+	Major, Minor, Patch int
+	Prerelease          string // e.g. "rc.1", without the '-'
+	Build               string // e.g. "build.5", without the '+'
+}
+
+// versionPattern is the semver 2.0.0 grammar, without leading zeros.
+var versionPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// Parse parses a semantic version. A leading "v" is accepted.
+func Parse(s string) (Version, error) {
+	text := s
+	if len(text) > 0 && text[0] == 'v' {
+		text = text[1:]
+	}
+	m := versionPattern.FindStringSubmatch(text)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q: want MAJOR.MINOR.PATCH, e.g. 1.4.0", s)
+	}
+	var v Version
+	var err error
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		if *p, err = strconv.Atoi(m[i+1]); err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+	}
+	v.Prerelease, v.Build = m[4], m[5]
+	return v, nil
+}
+
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 as a orders before, equal to or after b by
+// semver precedence. Build metadata is ignored.
+func Compare(a, b Version) int {
+	for _, d := range [][2]int{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		if d[0] != d[1] {
+			return cmpInt(d[0], d[1])
+		}
+	}
+	switch {
+	case a.Prerelease == b.Prerelease:
+		return 0
+	case a.Prerelease == "":
+		return 1
+	case b.Prerelease == "":
+		return -1
+	}
+	ap, bp := strings.Split(a.Prerelease, "."), strings.Split(b.Prerelease, ".")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if ap[i] == bp[i] {
+			continue
+		}
+		an, aErr := strconv.Atoi(ap[i])
+		bn, bErr := strconv.Atoi(bp[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			return cmpInt(an, bn)
+		case aErr == nil: // numeric identifiers sort first
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(ap[i], bp[i])
+		}
+	}
+	return cmpInt(len(ap), len(bp))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Level is the part of a version that a release increments.
+type Level int
+
+const (
+	Patch Level = iota // Fixes; no API or wire format change
+	Minor              // Backwards-compatible additions
+	Major              // Breaking changes
+)
+
+func (l Level) String() string {
+	switch l {
+	case Major:
+		return "major"
+	case Minor:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// Bump returns the next release at level. A prerelease of the bumped
+// version is released as is (1.0.0-rc.1 bumps to 1.0.0 at any level that
+// the prerelease already covers). Before 1.0.0, breaking changes bump the
+// minor version, as is customary for unstable APIs.
+func (v Version) Bump(level Level) Version {
+	if v.Major == 0 && level == Major {
+		level = Minor
+	}
+	next := Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	if v.Prerelease != "" {
+		switch {
+		case level == Patch,
+			level == Minor && v.Patch == 0,
+			level == Major && v.Minor == 0 && v.Patch == 0:
+			return next
+		}
+	}
+	switch level {
+	case Major:
+		return Version{Major: v.Major + 1}
+	case Minor:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		next.Patch++
+		return next
+	}
+}
@@ -0,0 +1,80 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Version
+	}{
+		{"1.4.0", Version{Major: 1, Minor: 4}},
+		{"v0.2.10", Version{Minor: 2, Patch: 10}},
+		{"2.0.0-rc.1+build.5", Version{Major: 2, Prerelease: "rc.1", Build: "build.5"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "1.0", "1.0.0.0", "01.0.0", "1.0.0-", "1.0.0-rc..1", "latest"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
+
+func TestBump(t *testing.T) {
+	tests := []struct {
+		from  string
+		level Level
+		want  string
+	}{
+		{"1.4.2", Patch, "1.4.3"},
+		{"1.4.2", Minor, "1.5.0"},
+		{"1.4.2", Major, "2.0.0"},
+		{"0.3.1", Major, "0.4.0"},
+		{"2.0.0-rc.1", Major, "2.0.0"},
+		{"1.5.0-beta", Minor, "1.5.0"},
+		{"1.5.0-beta", Major, "2.0.0"},
+		{"1.4.2+build.7", Patch, "1.4.3"},
+	}
+	for _, tt := range tests {
+		v, err := Parse(tt.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.Bump(tt.level).String(); got != tt.want {
+			t.Errorf("Bump(%s, %s) = %s, want %s", tt.from, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// In ascending precedence, from the semver specification
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := Parse(ordered[i])
+			b, _ := Parse(ordered[j])
+			want := cmpInt(i, j)
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%s, %s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	a, _ := Parse("1.0.0+build.1")
+	b, _ := Parse("1.0.0+build.2")
+	if Compare(a, b) != 0 {
+		t.Errorf("build metadata should not affect precedence")
+	}
+}
@@ -0,0 +1,271 @@
+// Package store keeps encoded messages in a content-addressable directory,
+// keyed by the BLAKE3 hash of the payload. It is meant as a building block
+// for snapshot and caching systems: identical payloads are stored once, and
+// every object records the schema it was written with.
+//
+// Layout:
+//
+//	<dir>/objects/<hash[:2]>/<hash[2:]>       payload bytes
+//	<dir>/objects/<hash[:2]>/<hash[2:]>.json  Meta
+package store
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/shaban/ffire/internal/blake3"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// ErrNotFound is returned by Get for hashes that are not in the store.
+var ErrNotFound = errors.New("object not found")
+
+// Hash is the BLAKE3 hash of a payload.
+type Hash [blake3.Size]byte
+
+// Sum returns the hash of data.
+func Sum(data []byte) Hash {
+	return Hash(blake3.Sum256(data))
+}
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+// ParseHash parses a hash in hex form, as printed by Hash.String.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(h) {
+		return h, fmt.Errorf("invalid hash %q: expected %d hex characters", s, 2*len(h))
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// Meta describes a stored payload.
+type Meta struct {
+	Package     string `json:"package"`     // Schema package name
+	Message     string `json:"message"`     // Root type the payload decodes as
+	Fingerprint string `json:"fingerprint"` // See Fingerprint
+	Size        int    `json:"size"`
+}
+
+// CorruptError reports an object whose content no longer matches its hash.
+type CorruptError struct {
+	Hash Hash
+	Got  Hash
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("object %s is corrupt: content hashes to %s", e.Hash, e.Got)
+}
+
+// Store is a content-addressable message store rooted at a directory.
+type Store struct {
+	dir string
+}
+
+// Open returns the store in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (st *Store) path(h Hash) string {
+	name := h.String()
+	return filepath.Join(st.dir, "objects", name[:2], name[2:])
+}
+
+// Put validates data as an encoded messageName and stores it, returning its
+// hash. Storing a payload that is already present is a no-op. Like generated
+// code, s must use canonical field order (see schema.Canonicalize).
+func (st *Store) Put(s *schema.Schema, messageName string, data []byte) (Hash, error) {
+	if _, err := fixture.Decode(s, messageName, data); err != nil {
+		return Hash{}, err
+	}
+	fingerprint, err := Fingerprint(s, messageName)
+	if err != nil {
+		return Hash{}, err
+	}
+
+	h := Sum(data)
+	path := st.path(h)
+	if _, err := os.Stat(path); err == nil {
+		return h, nil
+	}
+
+	meta, err := json.MarshalIndent(Meta{
+		Package:     s.Package,
+		Message:     messageName,
+		Fingerprint: fingerprint,
+		Size:        len(data),
+	}, "", "  ")
+	if err != nil {
+		return Hash{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return Hash{}, err
+	}
+	// Metadata first: an object without metadata must never be visible
+	if err := writeAtomic(path+".json", append(meta, '\n')); err != nil {
+		return Hash{}, err
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return Hash{}, err
+	}
+	return h, nil
+}
+
+// Get returns a stored payload and its metadata, verifying the content
+// against its hash.
+func (st *Store) Get(h Hash) ([]byte, *Meta, error) {
+	path := st.path(h)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%s: %w", h, ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if got := Sum(data); got != h {
+		return nil, nil, &CorruptError{Hash: h, Got: got}
+	}
+
+	raw, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read metadata for %s: %w", h, err)
+	}
+	var meta Meta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, nil, fmt.Errorf("invalid metadata for %s: %w", h, err)
+	}
+	return data, &meta, nil
+}
+
+// Has reports whether the store contains h.
+func (st *Store) Has(h Hash) bool {
+	_, err := os.Stat(st.path(h))
+	return err == nil
+}
+
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Fingerprint identifies the wire layout of a message: the BLAKE3 hash (hex)
+// of its type tree, with struct fields in canonical order. Payloads with the
+// same fingerprint decode with the same code; renaming a field or changing a
+// type changes it, adding unrelated types to the schema does not.
+func Fingerprint(s *schema.Schema, messageName string) (string, error) {
+	for _, msg := range s.Messages {
+		if msg.Name == messageName {
+			var sb strings.Builder
+			sb.WriteString(msg.Name + "=")
+			describe(&sb, msg.TargetType)
+			sum := blake3.Sum256([]byte(sb.String()))
+			return hex.EncodeToString(sum[:]), nil
+		}
+	}
+	return "", fmt.Errorf("message type %s not found in schema", messageName)
+}
+
+// SchemaFingerprint identifies the wire layout of every message in s: the
+// BLAKE3 hash (hex) of their type trees, as in Fingerprint, in name order.
+// Two builds of a schema with the same fingerprint exchange all of their
+// messages with the same code.
+func SchemaFingerprint(s *schema.Schema) string {
+	msgs := append([]schema.MessageType(nil), s.Messages...)
+	sort.Slice(msgs, func(i, j int) bool { return msgs[i].Name < msgs[j].Name })
+	var sb strings.Builder
+	for _, msg := range msgs {
+		sb.WriteString(msg.Name + "=")
+		describe(&sb, msg.TargetType)
+		sb.WriteByte('\n')
+	}
+	sum := blake3.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func describe(sb *strings.Builder, typ schema.Type) {
+	if typ.IsOptional() {
+		sb.WriteByte('*')
+	}
+	if schema.IsLarge(typ) {
+		sb.WriteString("@large ")
+	}
+	switch t := typ.(type) {
+	case *schema.PrimitiveType:
+		sb.WriteString(t.Name)
+	case *schema.ArrayType:
+		if t.Length > 0 {
+			fmt.Fprintf(sb, "[%d]", t.Length)
+		} else {
+			sb.WriteString("[]")
+		}
+		describe(sb, t.ElementType)
+	case *schema.MapType:
+		sb.WriteString("map[")
+		describe(sb, t.KeyType)
+		sb.WriteByte(']')
+		describe(sb, t.ValueType)
+	case *schema.EnumType:
+		// The accepted values decide what decodes, not their order
+		values := append([]schema.EnumValue(nil), t.Values...)
+		sort.Slice(values, func(i, j int) bool { return values[i].Value < values[j].Value })
+		sb.WriteString(t.Name + "(" + t.Base)
+		for _, v := range values {
+			fmt.Fprintf(sb, ";%s=%d", v.Name, v.Value)
+		}
+		sb.WriteByte(')')
+	case *schema.UnionType:
+		// Variant order fixes the tags, so it is part of the layout
+		sb.WriteString(t.Name + "<")
+		for i, v := range t.Variants {
+			if i > 0 {
+				sb.WriteByte('|')
+			}
+			describe(sb, v)
+		}
+		sb.WriteByte('>')
+	case *schema.StructType:
+		if t.Bitmap {
+			sb.WriteString("@bitmap ")
+		}
+		if t.Framed {
+			sb.WriteString("@framed ")
+		}
+		sb.WriteString(t.Name + "{")
+		for i, field := range schema.SortFieldsCanonical(t.Fields) {
+			if i > 0 {
+				sb.WriteByte(';')
+			}
+			sb.WriteString(field.Name + ":")
+			describe(sb, field.Type)
+		}
+		sb.WriteByte('}')
+	}
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+const testSchema = `package test
+
+type Sample struct {
+	Name  string
+	Value int32
+}
+`
+
+func parseSample(t *testing.T, src string) *schema.Schema {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	s.Canonicalize()
+	return s
+}
+
+func TestPutGet(t *testing.T) {
+	s := parseSample(t, testSchema)
+	st, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	payload := []byte{7, 0, 0, 0, 2, 0, 'h', 'i'} // Value, then Name
+	h, err := st.Put(s, "Sample", payload)
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if h != Sum(payload) {
+		t.Errorf("Put returned %s, want %s", h, Sum(payload))
+	}
+	if again, err := st.Put(s, "Sample", payload); err != nil || again != h {
+		t.Errorf("second Put = %s, %v", again, err)
+	}
+
+	data, meta, err := st.Get(h)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(data, payload) {
+		t.Errorf("Get returned % x, want % x", data, payload)
+	}
+	fingerprint, _ := Fingerprint(s, "Sample")
+	if meta.Package != "test" || meta.Message != "Sample" || meta.Fingerprint != fingerprint || meta.Size != len(payload) {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+
+	parsed, err := ParseHash(h.String())
+	if err != nil || parsed != h {
+		t.Errorf("ParseHash(%s) = %s, %v", h, parsed, err)
+	}
+}
+
+func TestPutRejectsInvalidPayload(t *testing.T) {
+	s := parseSample(t, testSchema)
+	st, _ := Open(t.TempDir())
+	if _, err := st.Put(s, "Sample", []byte{1, 2}); err == nil {
+		t.Error("expected decode error")
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	s := parseSample(t, testSchema)
+	st, _ := Open(t.TempDir())
+
+	if _, _, err := st.Get(Sum([]byte("missing"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	h, err := st.Put(s, "Sample", []byte{1, 0, 0, 0, 0, 0})
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := os.WriteFile(st.path(h), []byte{2, 0, 0, 0, 0, 0}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var corrupt *CorruptError
+	if _, _, err := st.Get(h); !errors.As(err, &corrupt) {
+		t.Errorf("expected CorruptError, got %v", err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a, _ := Fingerprint(parseSample(t, testSchema), "Sample")
+
+	// Declaration order and unrelated types don't matter
+	b, _ := Fingerprint(parseSample(t, "package test\n\ntype Sample struct {\n\tValue int32\n\tName string\n}\n\ntype Other struct {\n\tX bool\n}\n"), "Sample")
+	if a != b {
+		t.Errorf("fingerprints differ: %s vs %s", a, b)
+	}
+
+	c, _ := Fingerprint(parseSample(t, "package test\n\ntype Sample struct {\n\tName  string\n\tValue int64\n}\n"), "Sample")
+	if a == c {
+		t.Error("changing a field type should change the fingerprint")
+	}
+
+	d, _ := Fingerprint(parseSample(t, "package test\n\ntype Sample struct {\n\tName  string // @large\n\tValue int32\n}\n"), "Sample")
+	if a == d {
+		t.Error("a uint32 length prefix should change the fingerprint")
+	}
+
+	e, _ := Fingerprint(parseSample(t, "package test\n\ntype Sample struct {\n\tName  *string\n\tValue int32\n}\n"), "Sample")
+	f, _ := Fingerprint(parseSample(t, "package test\n\n// @bitmap\ntype Sample struct {\n\tName  *string\n\tValue int32\n}\n"), "Sample")
+	if e == f {
+		t.Error("a presence bitmap should change the fingerprint")
+	}
+
+	g, _ := Fingerprint(parseSample(t, "package test\n\n// @framed\ntype Sample struct {\n\tName  *string\n\tValue int32\n}\n"), "Sample")
+	if e == g {
+		t.Error("a struct frame should change the fingerprint")
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	src := "package test\n\ntype Sample struct {\n\tName  string\n\tValue int32\n}\n\ntype Other struct {\n\tX bool\n}\n"
+	a := SchemaFingerprint(parseSample(t, src))
+
+	// Message declaration order doesn't matter
+	b := SchemaFingerprint(parseSample(t, "package test\n\ntype Other struct {\n\tX bool\n}\n\ntype Sample struct {\n\tValue int32\n\tName  string\n}\n"))
+	if a != b {
+		t.Errorf("fingerprints differ: %s vs %s", a, b)
+	}
+
+	c := SchemaFingerprint(parseSample(t, strings.Replace(src, "X bool", "X int8", 1)))
+	if a == c {
+		t.Error("changing any message should change the schema fingerprint")
+	}
+	if one, _ := Fingerprint(parseSample(t, src), "Sample"); a == one {
+		t.Error("schema fingerprint should cover every message, not one")
+	}
+}
+
+func TestFingerprintUnion(t *testing.T) {
+	src := "package test\n\ntype Sample struct {\n\tValue Value\n}\n\ntype Value interface {\n\tstring | int32\n}\n"
+	a, _ := Fingerprint(parseSample(t, src), "Sample")
+	b, _ := Fingerprint(parseSample(t, strings.Replace(src, "string | int32", "int32 | string", 1)), "Sample")
+	if a == b {
+		t.Error("reordering union variants changes their tags and should change the fingerprint")
+	}
+}
@@ -0,0 +1,83 @@
+// Package synth generates synthetic schemas of arbitrary size for stress
+// testing and benchmarking the parse → validate → generate pipeline.
+//
+// Types form a tree: struct k references structs 2k+1 and 2k+2, so a schema
+// of any size has exactly one root type (Struct0) and a nesting depth of
+// about log2(Types). Field types are drawn from a seeded generator, so the
+// same Options always produce the same schema.
+package synth
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+)
+
+// Options controls the shape of a synthetic schema.
+type Options struct {
+	Package string // Package name (default "synth")
+	Types   int    // Number of struct types (default 100)
+	Fields  int    // Fields per struct, excluding references to child structs (default 10)
+	Seed    int64  // Seed for field type selection
+}
+
+var primitives = []string{"bool", "int8", "int16", "int32", "int64", "float32", "float64", "string"}
+
+// Schema returns the source of a synthetic .ffi schema.
+func Schema(opts Options) []byte {
+	if opts.Package == "" {
+		opts.Package = "synth"
+	}
+	if opts.Types <= 0 {
+		opts.Types = 100
+	}
+	if opts.Fields < 0 {
+		opts.Fields = 0
+	} else if opts.Fields == 0 {
+		opts.Fields = 10
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n", opts.Package)
+	fmt.Fprintf(&buf, "\n// Synthetic schema: %d types, %d fields each, seed %d\n", opts.Types, opts.Fields, opts.Seed)
+
+	for k := 0; k < opts.Types; k++ {
+		fmt.Fprintf(&buf, "\ntype Struct%d struct {\n", k)
+		for f := 0; f < opts.Fields; f++ {
+			fmt.Fprintf(&buf, "\tF%d %s `json:\"f%d\"`\n", f, fieldType(rng), f)
+		}
+		for _, child := range []int{2*k + 1, 2*k + 2} {
+			if child >= opts.Types {
+				continue
+			}
+			var typ string
+			switch rng.Intn(3) {
+			case 0:
+				typ = fmt.Sprintf("Struct%d", child)
+			case 1:
+				typ = fmt.Sprintf("*Struct%d", child)
+			default:
+				typ = fmt.Sprintf("[]Struct%d", child)
+			}
+			fmt.Fprintf(&buf, "\tChild%d %s `json:\"child%d\"`\n", child, typ, child)
+		}
+		buf.WriteString("}\n")
+	}
+
+	return buf.Bytes()
+}
+
+// fieldType picks a primitive field type, possibly optional or an array.
+func fieldType(rng *rand.Rand) string {
+	prim := primitives[rng.Intn(len(primitives))]
+	switch n := rng.Intn(10); {
+	case n < 6:
+		return prim
+	case n < 8:
+		return "*" + prim
+	default:
+		return "[]" + prim
+	}
+}
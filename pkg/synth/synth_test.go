@@ -0,0 +1,47 @@
+package synth
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/schema"
+	"github.com/shaban/ffire/pkg/validator"
+)
+
+func TestSchemaParsesAndValidates(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 300} {
+		src := Schema(Options{Types: n, Fields: 6, Seed: 42})
+		s, err := parser.ParseBytes(src)
+		if err != nil {
+			t.Fatalf("types=%d: parse: %v\n%s", n, err, src)
+		}
+		if err := validator.ValidateSchema(s); err != nil {
+			t.Fatalf("types=%d: validate: %v", n, err)
+		}
+		if len(s.Types) != n {
+			t.Errorf("types=%d: got %d types", n, len(s.Types))
+		}
+		if len(s.Messages) != 1 || s.Messages[0].Name != "Struct0" {
+			t.Errorf("types=%d: want single root Struct0, got %v", n, s.Messages)
+		}
+		for _, typ := range s.Types {
+			st := typ.(*schema.StructType)
+			if len(st.Fields) < 6 {
+				t.Errorf("types=%d: %s has %d fields", n, st.Name, len(st.Fields))
+			}
+		}
+	}
+}
+
+func TestSchemaDeterministic(t *testing.T) {
+	a := Schema(Options{Types: 50, Seed: 7})
+	b := Schema(Options{Types: 50, Seed: 7})
+	if !bytes.Equal(a, b) {
+		t.Error("same options produced different schemas")
+	}
+	c := Schema(Options{Types: 50, Seed: 8})
+	if bytes.Equal(a, c) {
+		t.Error("different seeds produced identical schemas")
+	}
+}
@@ -0,0 +1,360 @@
+// Package toolchain detects the compilers and runtimes used to build
+// generated packages and benchmarks, and checks their versions against the
+// ones the generated code needs. It backs the ffire doctor command.
+package toolchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tool is an external program that ffire-generated code needs.
+type Tool struct {
+	Name        string         // Executable looked up on PATH
+	VersionArgs []string       // Arguments that print the version
+	VersionFrom *regexp.Regexp // Extracts the version when the first number is not it
+	MinVersion  string         // Oldest supported version, empty if any works
+	Reason      string         // Why MinVersion is needed
+	Purpose     string         // What ffire uses the tool for
+	Langs       []string       // Target languages that need the tool
+	OS          []string       // Platforms the tool is used on (all if empty)
+	Optional    bool           // Only needed for optional features
+
+	// Install maps GOOS to installation instructions; "" applies to all
+	// platforms without their own entry.
+	Install map[string]string
+}
+
+// Tools lists the programs checked by ffire doctor.
+var Tools = []Tool{
+	{
+		Name: "go", VersionArgs: []string{"version"},
+		MinVersion: "1.21", Reason: "generated benchmark modules declare go 1.21",
+		Purpose: "Go benchmarks and roundtrip tests",
+		Langs:   []string{"go"},
+		Install: map[string]string{"": "https://go.dev/dl/"},
+	},
+	{
+		Name: "clang++", VersionArgs: []string{"--version"},
+		MinVersion: "5", Reason: "the C++ core is C++17",
+		Purpose: "native libraries for C++ and Tier B packages",
+		Langs:   []string{"cpp", "swift", "dart", "csharp", "godot"},
+		OS:      []string{"darwin"},
+		Install: map[string]string{"": "xcode-select --install"},
+	},
+	{
+		Name: "g++", VersionArgs: []string{"--version"},
+		MinVersion: "7", Reason: "the C++ core is C++17",
+		Purpose: "native libraries for C++ and Tier B packages",
+		Langs:   []string{"cpp", "swift", "dart", "csharp", "godot"},
+		OS:      []string{"linux"},
+		Install: map[string]string{"": "apt install g++ (Debian/Ubuntu) or dnf install gcc-c++ (Fedora)"},
+	},
+	{
+		Name: "gcc", VersionArgs: []string{"--version"},
+		Purpose: "igniffi libraries for JavaScript and Python packages",
+		Langs:   []string{"js", "python"},
+		Install: map[string]string{
+			"darwin": "xcode-select --install",
+			"":       "apt install gcc (Debian/Ubuntu) or dnf install gcc (Fedora)",
+		},
+	},
+	{
+		Name: "x86_64-w64-mingw32-g++", VersionArgs: []string{"--version"},
+		Purpose:  "Windows libraries (-platform windows)",
+		OS:       []string{"darwin", "linux"},
+		Optional: true,
+		Install: map[string]string{
+			"darwin": "brew install mingw-w64",
+			"":       "apt install mingw-w64",
+		},
+	},
+	{
+		Name: "swift", VersionArgs: []string{"--version"},
+		MinVersion: "5.9", Reason: "Package.swift uses swift-tools-version 5.9",
+		// macOS prints the swift-driver version first
+		VersionFrom: regexp.MustCompile(`Swift version (\d+(?:\.\d+)+)`),
+		Purpose:     "Swift packages and benchmarks",
+		Langs:       []string{"swift"},
+		Install: map[string]string{
+			"darwin": "install Xcode 15 or newer",
+			"":       "https://www.swift.org/install/",
+		},
+	},
+	{
+		Name: "javac", VersionArgs: []string{"-version"},
+		MinVersion: "17", Reason: "generated code uses var, generated tests use java.util.HexFormat",
+		Purpose: "Java packages and benchmarks",
+		Langs:   []string{"java"},
+		Install: map[string]string{
+			"darwin":  "brew install openjdk@17",
+			"windows": "winget install Microsoft.OpenJDK.17",
+			"":        "apt install openjdk-17-jdk",
+		},
+	},
+	{
+		Name: "dotnet", VersionArgs: []string{"--version"},
+		MinVersion: "9.0", Reason: "generated projects target net9.0",
+		Purpose: "C# packages and benchmarks",
+		Langs:   []string{"csharp"},
+		Install: map[string]string{
+			"darwin":  "brew install dotnet",
+			"windows": "winget install Microsoft.DotNet.SDK.9",
+			"":        "https://learn.microsoft.com/dotnet/core/install/linux",
+		},
+	},
+	{
+		Name: "dart", VersionArgs: []string{"--version"},
+		MinVersion: "2.17", Reason: "pubspec.yaml requires sdk >=2.17.0",
+		Purpose: "Dart packages and benchmarks",
+		Langs:   []string{"dart"},
+		Install: map[string]string{"": "https://dart.dev/get-dart"},
+	},
+	{
+		Name: "node", VersionArgs: []string{"--version"},
+		MinVersion: "16", Reason: "the koffi FFI module needs Node.js 16",
+		Purpose: "JavaScript packages",
+		Langs:   []string{"js"},
+		Install: map[string]string{"": "https://nodejs.org/en/download"},
+	},
+	{
+		Name: "python3", VersionArgs: []string{"--version"},
+		MinVersion: "3.8", Reason: "pyproject.toml requires Python >=3.8",
+		Purpose: "Python packages",
+		Langs:   []string{"python"},
+		Install: map[string]string{
+			"darwin": "brew install python",
+			"":       "apt install python3 python3-pip",
+		},
+	},
+	{
+		Name: "cargo", VersionArgs: []string{"--version"},
+		MinVersion: "1.56", Reason: "generated crates use edition 2021",
+		Purpose: "Rust crates and benchmarks",
+		Langs:   []string{"rust"},
+		Install: map[string]string{"": "https://rustup.rs/"},
+	},
+	{
+		Name: "gradle", VersionArgs: []string{"--version"},
+		VersionFrom: regexp.MustCompile(`Gradle (\d+(?:\.\d+)+)`),
+		MinVersion:  "7.6.3", Reason: "the Kotlin 2.0 Gradle plugin needs Gradle 7.6.3",
+		Purpose: "Kotlin packages",
+		Langs:   []string{"kotlin"},
+		Install: map[string]string{
+			"darwin": "brew install gradle",
+			"":       "https://gradle.org/install/",
+		},
+	},
+	{
+		Name: "zig", VersionArgs: []string{"version"},
+		Purpose: "Zig packages",
+		Langs:   []string{"zig"},
+		Install: map[string]string{"": "https://ziglang.org/download/"},
+	},
+	{
+		Name: "scons", VersionArgs: []string{"--version"},
+		VersionFrom: regexp.MustCompile(`SCons: v(\d+(?:\.\d+)+)`),
+		MinVersion:  "4.0", Reason: "godot-cpp builds need SCons 4.0",
+		Purpose: "Godot extensions",
+		Langs:   []string{"godot"},
+		Install: map[string]string{
+			"darwin": "brew install scons",
+			"":       "python3 -m pip install scons",
+		},
+	},
+	{
+		Name: "protoc", VersionArgs: []string{"--version"},
+		Purpose:  "protobuf comparison benchmarks and protoc-gen-ffire",
+		Optional: true,
+		Install: map[string]string{
+			"darwin": "brew install protobuf",
+			"":       "apt install protobuf-compiler",
+		},
+	},
+}
+
+// Status is the outcome of checking one tool.
+type Status int
+
+const (
+	OK       Status = iota // Installed and recent enough
+	Missing                // Not found on PATH
+	Outdated               // Older than MinVersion
+	Unknown                // Installed, but the version could not be determined
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Missing:
+		return "missing"
+	case Outdated:
+		return "outdated"
+	default:
+		return "unknown"
+	}
+}
+
+// Result is the outcome of checking one tool.
+type Result struct {
+	Tool    Tool
+	Path    string // Resolved executable, empty if missing
+	Version string // Parsed version, empty if unknown
+	Status  Status
+	Problem string // Why the tool is unusable, for Outdated and Unknown
+}
+
+// InstallHint returns installation instructions for the current platform.
+func (r Result) InstallHint() string {
+	if hint, ok := r.Tool.Install[runtime.GOOS]; ok {
+		return hint
+	}
+	return r.Tool.Install[""]
+}
+
+// versionTimeout bounds each version command, since some tools (dotnet,
+// swift on first run) can be slow to start.
+const versionTimeout = 15 * time.Second
+
+// ForPlatform returns the tools used on goos.
+func ForPlatform(tools []Tool, goos string) []Tool {
+	var out []Tool
+	for _, t := range tools {
+		if len(t.OS) == 0 || contains(t.OS, goos) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// ForLangs returns the tools needed by any of langs. Optional tools are
+// not included.
+func ForLangs(tools []Tool, langs []string) []Tool {
+	var out []Tool
+	for _, t := range tools {
+		for _, lang := range langs {
+			if contains(t.Langs, normalizeLang(lang)) {
+				out = append(out, t)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// normalizeLang maps the language aliases accepted by ffire generate to
+// the names used in Tool.Langs.
+func normalizeLang(lang string) string {
+	switch lang = strings.ToLower(lang); lang {
+	case "c", "c++":
+		return "cpp"
+	case "javascript", "igniffi-js":
+		return "js"
+	case "py", "igniffi-python":
+		return "python"
+	}
+	return lang
+}
+
+// Check looks up each tool and runs its version command.
+func Check(ctx context.Context, tools []Tool) []Result {
+	results := make([]Result, len(tools))
+	for i, t := range tools {
+		results[i] = check(ctx, t)
+	}
+	return results
+}
+
+func check(ctx context.Context, t Tool) Result {
+	r := Result{Tool: t}
+	path, err := exec.LookPath(t.Name)
+	if err != nil {
+		r.Status = Missing
+		return r
+	}
+	r.Path = path
+
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, t.VersionArgs...).CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", versionTimeout)
+	}
+	r.Version = ParseVersion(string(output))
+	if t.VersionFrom != nil {
+		r.Version = ""
+		if m := t.VersionFrom.FindStringSubmatch(string(output)); m != nil {
+			r.Version = m[1]
+		}
+	}
+
+	switch {
+	case r.Version == "" && t.MinVersion == "":
+		// Any version works
+		r.Status = OK
+	case r.Version == "":
+		r.Status = Unknown
+		r.Problem = fmt.Sprintf("could not determine version (need %s or newer)", t.MinVersion)
+		if err != nil {
+			r.Problem = fmt.Sprintf("%s %s failed: %v", t.Name, strings.Join(t.VersionArgs, " "), err)
+		}
+	case t.MinVersion != "" && CompareVersions(r.Version, t.MinVersion) < 0:
+		r.Status = Outdated
+		r.Problem = fmt.Sprintf("need %s or newer: %s", t.MinVersion, t.Reason)
+	default:
+		r.Status = OK
+	}
+	return r
+}
+
+// versionPattern matches a dotted version number, optionally with a
+// leading "v" or "go" as printed by node and go.
+var versionPattern = regexp.MustCompile(`(?:^|[^\w.])(?:v|go)?(\d+(?:\.\d+)+)`)
+
+// ParseVersion returns the first dotted version number in a tool's version
+// output, e.g. "13.2.0" from "g++ (Ubuntu 13.2.0-4ubuntu3) 13.2.0".
+func ParseVersion(output string) string {
+	if m := versionPattern.FindStringSubmatch(output); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// CompareVersions compares dotted version numbers numerically, treating
+// missing components as zero. It returns -1, 0 or +1.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package toolchain
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"go version go1.25.3 linux/amd64", "1.25.3"},
+		{"g++ (Ubuntu 13.2.0-23ubuntu4) 13.2.0\nCopyright (C) 2023", "13.2.0"},
+		{"Apple clang version 15.0.0 (clang-1500.0.40.1)", "15.0.0"},
+		{"javac 1.8.0_292", "1.8.0"},
+		{"javac 17.0.2", "17.0.2"},
+		{"v20.11.1", "20.11.1"},
+		{"Python 3.12.3", "3.12.3"},
+		{"libprotoc 25.1", "25.1"},
+		{"Dart SDK version: 3.3.0 (stable) on \"linux_x64\"", "3.3.0"},
+		{"x86_64-w64-mingw32-g++ (GCC) 10-win32 20220113", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseVersion(tt.output); got != tt.want {
+			t.Errorf("ParseVersion(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.21", "1.21.0", 0},
+		{"1.9", "1.21", -1},
+		{"17.0.2", "17", 1},
+		{"8.0.404", "9.0", -1},
+		{"5.10", "5.9", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// fakeTool puts an executable named name on a fresh PATH that prints output.
+func fakeTool(t *testing.T, dir, name, output string) {
+	t.Helper()
+	script := "#!/bin/sh\necho '" + output + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func toolNamed(t *testing.T, name string) Tool {
+	for _, tool := range Tools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	t.Fatalf("no tool %s", name)
+	return Tool{}
+}
+
+func TestCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	fakeTool(t, bin, "newc", "newc version 9.0.1")
+	fakeTool(t, bin, "oldc", "oldc 8.0.404")
+	fakeTool(t, bin, "oddc", "no version here")
+	fakeTool(t, bin, "swiftish", "swift-driver version: 1.90.11.1 Apple Swift version 5.10")
+
+	tools := []Tool{
+		{Name: "newc", MinVersion: "9.0"},
+		{Name: "oldc", MinVersion: "9.0", Reason: "projects target net9.0"},
+		{Name: "oddc", MinVersion: "1.0"},
+		{Name: "oddc"},
+		{Name: "absent", Install: map[string]string{"": "get it"}},
+		{Name: "swiftish", MinVersion: "5.9", VersionFrom: toolNamed(t, "swift").VersionFrom},
+	}
+	want := []struct {
+		status  Status
+		version string
+	}{
+		{OK, "9.0.1"},
+		{Outdated, "8.0.404"},
+		{Unknown, ""},
+		{OK, ""},
+		{Missing, ""},
+		{OK, "5.10"},
+	}
+
+	results := Check(context.Background(), tools)
+	for i, r := range results {
+		if r.Status != want[i].status || r.Version != want[i].version {
+			t.Errorf("%s: status %v version %q, want %v %q", r.Tool.Name, r.Status, r.Version, want[i].status, want[i].version)
+		}
+	}
+	if !strings.Contains(results[1].Problem, "need 9.0 or newer: projects target net9.0") {
+		t.Errorf("outdated problem = %q", results[1].Problem)
+	}
+	if results[4].InstallHint() != "get it" {
+		t.Errorf("InstallHint = %q", results[4].InstallHint())
+	}
+}
+
+func TestForLangs(t *testing.T) {
+	var names []string
+	for _, tool := range ForLangs(ForPlatform(Tools, "linux"), []string{"c++", "py"}) {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "g++,gcc,python3" {
+		t.Errorf("ForLangs = %s, want g++,gcc,python3", got)
+	}
+}
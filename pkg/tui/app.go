@@ -0,0 +1,485 @@
+// Package tui is the interactive terminal UI of ffire ui. It browses the
+// types of a schema with their analyzer data, previews a JSON fixture and
+// its wire encoding, and shows benchmark results as a table and as bar
+// charts.
+//
+// App holds the state and renders it to plain lines, independent of the
+// terminal; Run drives it on an interactive terminal.
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/shaban/ffire/pkg/analyzer"
+	"github.com/shaban/ffire/pkg/fixture"
+	"github.com/shaban/ffire/pkg/inspector"
+	"github.com/shaban/ffire/pkg/results"
+	"github.com/shaban/ffire/pkg/schema"
+)
+
+// Config is the data shown by an App.
+type Config struct {
+	Schema      *schema.Schema   // Canonicalized, so fields show in wire order
+	Fixture     []byte           // JSON fixture to preview, or nil
+	FixtureName string           // Shown above the fixture, e.g. its path
+	Message     string           // Message type of Fixture
+	Results     []results.Result // Benchmark results, or nil
+}
+
+// App is the state of the UI: four tabs, each a list of items on the left
+// and the details of the selected item on the right.
+type App struct {
+	tabs   []*tab
+	active int
+}
+
+type tab struct {
+	name     string
+	items    []string
+	empty    string                      // Shown when there are no items
+	detail   func(i, width int) []string // Lines for item i, at most width wide if it matters
+	selected int
+	scroll   int
+}
+
+// New returns an App showing cfg, on the first tab.
+func New(cfg Config) *App {
+	return &App{tabs: []*tab{typesTab(cfg), fixtureTab(cfg), resultsTab(cfg), graphTab(cfg)}}
+}
+
+// Update applies a key press and reports whether the UI should quit.
+func (a *App) Update(k Key) bool {
+	t := a.tabs[a.active]
+	switch k {
+	case KeyQuit:
+		return true
+	case KeyTab, KeyRight:
+		a.active = (a.active + 1) % len(a.tabs)
+	case KeyBackTab, KeyLeft:
+		a.active = (a.active + len(a.tabs) - 1) % len(a.tabs)
+	case KeyUp:
+		if t.selected > 0 {
+			t.selected--
+			t.scroll = 0
+		}
+	case KeyDown:
+		if t.selected < len(t.items)-1 {
+			t.selected++
+			t.scroll = 0
+		}
+	case KeyPageUp:
+		t.scroll = max(0, t.scroll-pageSize)
+	case KeyPageDown:
+		t.scroll += pageSize
+	default:
+		if n := int(k - Key1); n >= 0 && n < len(a.tabs) {
+			a.active = n
+		}
+	}
+	return false
+}
+
+// pageSize is how far PgUp and PgDn scroll the details.
+const pageSize = 10
+
+// View renders the UI as exactly height lines of at most width runes,
+// without trailing spaces.
+func (a *App) View(width, height int) []string {
+	t := a.tabs[a.active]
+
+	var names []string
+	for i, tt := range a.tabs {
+		if i == a.active {
+			names = append(names, fmt.Sprintf("[%d %s]", i+1, tt.name))
+		} else {
+			names = append(names, fmt.Sprintf(" %d %s ", i+1, tt.name))
+		}
+	}
+	lines := []string{
+		strings.TrimRight(fit(strings.Join(names, " "), width), " "),
+		strings.Repeat("─", width),
+	}
+
+	listWidth := 0
+	for _, item := range t.items {
+		listWidth = max(listWidth, utf8.RuneCountInString(item)+2)
+	}
+	listWidth = min(listWidth, width/3)
+	detailWidth := width - listWidth
+	if listWidth > 0 {
+		detailWidth -= 3 // " │ "
+	}
+
+	var detail []string
+	if len(t.items) == 0 {
+		detail = strings.Split(t.empty, "\n")
+	} else {
+		detail = t.detail(t.selected, detailWidth)
+	}
+	// Keep the last page of details on screen
+	body := height - 3
+	t.scroll = max(0, min(t.scroll, len(detail)-body))
+
+	// Scroll the list so the selection stays visible
+	first := max(0, t.selected-body+1)
+	for row := 0; row < body; row++ {
+		var line string
+		if listWidth > 0 {
+			item := ""
+			if i := first + row; i < len(t.items) {
+				item = "  " + t.items[i]
+				if i == t.selected {
+					item = "▸ " + t.items[i]
+				}
+			}
+			line = fit(item, listWidth) + " │ "
+		}
+		if i := t.scroll + row; i < len(detail) {
+			line += fit(detail[i], detailWidth)
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+
+	help := "↑↓ select  ←→/tab switch view  PgUp/PgDn scroll  q quit"
+	if len(detail) > body {
+		help += fmt.Sprintf("  (lines %d-%d of %d)", t.scroll+1, min(t.scroll+body, len(detail)), len(detail))
+	}
+	return append(lines, strings.TrimRight(fit(help, width), " "))
+}
+
+// fit pads or truncates s to exactly width runes.
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
+
+func typesTab(cfg Config) *tab {
+	s := cfg.Schema
+	info := analyzer.Analyze(s)
+
+	// usedBy maps a struct name to the types and messages referring to it
+	usedBy := make(map[string][]string)
+	refer := func(from string, t schema.Type) {
+		for {
+			if at, ok := t.(*schema.ArrayType); ok {
+				t = at.ElementType
+			} else if mt, ok := t.(*schema.MapType); ok {
+				t = mt.ValueType
+			} else {
+				break
+			}
+		}
+		switch nt := t.(type) {
+		case *schema.StructType:
+			usedBy[nt.Name] = append(usedBy[nt.Name], from)
+		case *schema.EnumType:
+			usedBy[nt.Name] = append(usedBy[nt.Name], from)
+		case *schema.UnionType:
+			usedBy[nt.Name] = append(usedBy[nt.Name], from)
+		}
+	}
+	for _, m := range s.Messages {
+		refer(m.Name, m.TargetType)
+	}
+	for _, t := range s.Types {
+		switch nt := t.(type) {
+		case *schema.StructType:
+			for _, f := range nt.Fields {
+				refer(nt.Name, f.Type)
+			}
+		case *schema.UnionType:
+			for _, v := range nt.Variants {
+				refer(nt.Name, v)
+			}
+		}
+	}
+	for name, users := range usedBy {
+		sort.Strings(users)
+		usedBy[name] = slices.Compact(users)
+	}
+
+	type entry struct {
+		name    string
+		typ     schema.Type
+		message bool
+	}
+	var entries []entry
+	var items []string
+	for _, m := range s.Messages {
+		entries = append(entries, entry{m.Name, m.TargetType, true})
+		items = append(items, m.Name+" (message)")
+	}
+	for _, t := range s.Types {
+		// Array types are the anonymous targets of messages, shown with them
+		switch nt := t.(type) {
+		case *schema.StructType:
+			entries = append(entries, entry{nt.Name, nt, false})
+			items = append(items, nt.Name)
+		case *schema.EnumType:
+			entries = append(entries, entry{nt.Name, nt, false})
+			items = append(items, nt.Name+" (enum)")
+		case *schema.UnionType:
+			entries = append(entries, entry{nt.Name, nt, false})
+			items = append(items, nt.Name+" (union)")
+		}
+	}
+
+	return &tab{
+		name:  "Types",
+		items: items,
+		empty: "The schema defines no types.",
+		detail: func(i, _ int) []string {
+			e := entries[i]
+			var lines []string
+			if e.message {
+				lines = append(lines, fmt.Sprintf("message %s = %s", e.name, typeString(e.typ)))
+			}
+			if et, isEnum := e.typ.(*schema.EnumType); isEnum {
+				lines = append(lines, "enum "+et.Name+" ("+et.Base+")")
+				if users := usedBy[et.Name]; len(users) > 0 {
+					lines = append(lines, "used by   "+strings.Join(users, ", "))
+				}
+				lines = append(lines, "", "Values:")
+				nameWidth := 0
+				for _, v := range et.Values {
+					nameWidth = max(nameWidth, len(v.Name))
+				}
+				for _, v := range et.Values {
+					lines = append(lines, fmt.Sprintf("  %-*s  %d", nameWidth, v.Name, v.Value))
+				}
+				return lines
+			}
+			if ut, isUnion := e.typ.(*schema.UnionType); isUnion {
+				lines = append(lines, "union "+ut.Name)
+				if users := usedBy[ut.Name]; len(users) > 0 {
+					lines = append(lines, "used by   "+strings.Join(users, ", "))
+				}
+				lines = append(lines, "", "Variants by tag:")
+				for i, v := range ut.Variants {
+					lines = append(lines, fmt.Sprintf("  %3d  %s", i+1, v.TypeName()))
+				}
+				return lines
+			}
+			st, ok := e.typ.(*schema.StructType)
+			if !ok {
+				if at, isArray := e.typ.(*schema.ArrayType); isArray {
+					lines = append(lines, "", fmt.Sprintf("A list of %s, up to %d elements.", typeString(at.ElementType), schema.MaxLengthOf(at)))
+				}
+				return lines
+			}
+			if !e.message {
+				lines = append(lines, "struct "+st.Name)
+			}
+			if users := usedBy[st.Name]; len(users) > 0 {
+				lines = append(lines, "used by   "+strings.Join(users, ", "))
+			}
+			if ti := info[st.Name]; ti != nil {
+				lines = append(lines,
+					"size      "+sizeString(ti),
+					fmt.Sprintf("strings   %s    arrays  %s    nesting  %d", yesNo(ti.HasStrings), yesNo(ti.HasArrays), ti.NestDepth),
+				)
+				if line := presenceString(st, ti); line != "" {
+					lines = append(lines, "presence  "+line)
+				}
+			}
+
+			lines = append(lines, "", "Fields in wire order:")
+			nameWidth, typeWidth := 0, 0
+			for _, f := range st.Fields {
+				nameWidth = max(nameWidth, len(f.Name))
+				typeWidth = max(typeWidth, len(typeString(f.Type)))
+			}
+			for _, f := range st.Fields {
+				lines = append(lines, fmt.Sprintf("  %-*s  %-*s  %s", nameWidth, f.Name, typeWidth, typeString(f.Type), fieldSize(f)))
+			}
+			return lines
+		},
+	}
+}
+
+func typeString(t schema.Type) string {
+	prefix := ""
+	if t.IsOptional() {
+		prefix = "*"
+	}
+	switch tt := t.(type) {
+	case *schema.ArrayType:
+		if tt.Length > 0 {
+			return prefix + fmt.Sprintf("[%d]", tt.Length) + typeString(tt.ElementType)
+		}
+		return prefix + "[]" + typeString(tt.ElementType)
+	case *schema.MapType:
+		return prefix + "map[" + typeString(tt.KeyType) + "]" + typeString(tt.ValueType)
+	}
+	return prefix + t.TypeName()
+}
+
+func sizeString(ti *analyzer.TypeInfo) string {
+	switch {
+	case ti.IsFixedSize:
+		return fmt.Sprintf("fixed, %d B", ti.FixedSize)
+	case ti.MaxSize < 0:
+		return "variable, unbounded (recursive)"
+	default:
+		return fmt.Sprintf("variable, at most %d B", ti.MaxSize)
+	}
+}
+
+// presenceString describes the presence flags of the optional fields of
+// st, or returns "" if it has none.
+func presenceString(st *schema.StructType, ti *analyzer.TypeInfo) string {
+	switch {
+	case ti.OptionalFields == 0:
+		return ""
+	case st.Bitmap:
+		return fmt.Sprintf("%d optional, %d B bitmap", ti.OptionalFields, ti.PresenceBytes)
+	case ti.BitmapSavings > 0:
+		return fmt.Sprintf("%d optional, %d B; @bitmap would save %d B", ti.OptionalFields, ti.PresenceBytes, ti.BitmapSavings)
+	}
+	return fmt.Sprintf("%d optional, %d B", ti.OptionalFields, ti.PresenceBytes)
+}
+
+func fieldSize(f schema.Field) string {
+	switch schema.GetFieldCategory(f) {
+	case schema.CategoryOptional:
+		return "optional"
+	case schema.CategoryVariable:
+		return "variable"
+	}
+	if size := schema.GetPrimitiveSize(f.Type); size > 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	if et, ok := f.Type.(*schema.EnumType); ok {
+		return fmt.Sprintf("%d B", schema.PrimitiveSize(et.Base))
+	}
+	return "fixed"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func fixtureTab(cfg Config) *tab {
+	t := &tab{
+		name:  "Fixture",
+		empty: "No fixture loaded.\nStart with -fixture data.json to preview one.",
+	}
+	if cfg.Fixture == nil {
+		return t
+	}
+
+	header := []string{fmt.Sprintf("%s (%s), %d B of JSON", cfg.FixtureName, cfg.Message, len(cfg.Fixture))}
+	var pretty bytes.Buffer
+	jsonLines := header
+	if err := json.Indent(&pretty, cfg.Fixture, "", "  "); err != nil {
+		jsonLines = append(jsonLines, "", "Invalid JSON: "+err.Error())
+	} else {
+		jsonLines = append(jsonLines, "")
+		jsonLines = append(jsonLines, strings.Split(strings.TrimRight(pretty.String(), "\n"), "\n")...)
+	}
+
+	wireLines := header
+	wire, err := fixture.Convert(cfg.Schema, cfg.Message, cfg.Fixture)
+	if err == nil {
+		var view string
+		view, err = inspector.Inspect(&inspector.Config{Schema: cfg.Schema, MessageName: cfg.Message, Data: wire, ShowHex: true})
+		if err == nil {
+			wireLines = []string{fmt.Sprintf("%s (%s), %d B of JSON encode to %d B (%.0f%%)",
+				cfg.FixtureName, cfg.Message, len(cfg.Fixture), len(wire), 100*float64(len(wire))/float64(len(cfg.Fixture))), ""}
+			wireLines = append(wireLines, strings.Split(strings.TrimRight(view, "\n"), "\n")...)
+		}
+	}
+	if err != nil {
+		wireLines = append(wireLines, "", "Cannot encode: "+err.Error())
+	}
+
+	t.items = []string{"JSON", "Wire"}
+	t.detail = func(i, _ int) []string {
+		if i == 0 {
+			return jsonLines
+		}
+		return wireLines
+	}
+	return t
+}
+
+const noResults = "No benchmark results loaded.\nStart with -results benchmarks/results, or with the JSON files written by BENCH_JSON=1."
+
+// messageItems lists "all messages" and then each message with results.
+func messageItems(rs []results.Result) (items, messages []string) {
+	if len(rs) == 0 {
+		return nil, nil
+	}
+	messages = append([]string{""}, results.Messages(rs)...)
+	items = append([]string{"all messages"}, messages[1:]...)
+	return items, messages
+}
+
+func resultsTab(cfg Config) *tab {
+	items, messages := messageItems(cfg.Results)
+	return &tab{
+		name:  "Results",
+		items: items,
+		empty: noResults,
+		detail: func(i, _ int) []string {
+			summaries := results.Summarize(cfg.Results, messages[i])
+			labelWidth := len("Implementation")
+			for _, s := range summaries {
+				labelWidth = max(labelWidth, len(s.Label()))
+			}
+			lines := []string{
+				fmt.Sprintf("%-*s %12s %12s %12s %10s", labelWidth, "Implementation", "Encode", "Decode", "Total", "Wire"),
+			}
+			for _, s := range summaries {
+				lines = append(lines, fmt.Sprintf("%-*s %9.2f μs %9.2f μs %9.2f μs %8.0f B",
+					labelWidth, s.Label(), s.EncodeNs/1000, s.DecodeNs/1000, s.TotalNs/1000, s.WireSize))
+			}
+			if messages[i] == "" {
+				lines = append(lines, "", fmt.Sprintf("Averages over %d messages.", len(messages)-1))
+			}
+			return lines
+		},
+	}
+}
+
+func graphTab(cfg Config) *tab {
+	items, messages := messageItems(cfg.Results)
+	return &tab{
+		name:  "Graph",
+		items: items,
+		empty: noResults,
+		detail: func(i, width int) []string {
+			summaries := results.Summarize(cfg.Results, messages[i])
+			labelWidth := 0
+			for _, s := range summaries {
+				labelWidth = max(labelWidth, len(s.Label()))
+			}
+			// label, bar, " 12345.67 μs  (12.34x)"
+			barWidth := max(10, width-labelWidth-24)
+
+			var lines []string
+			for _, m := range results.Metrics {
+				lines = append(lines, m.Name+" time, lower is better", "")
+				lines = append(lines, results.Chart(summaries, m, barWidth)...)
+				lines = append(lines, "")
+			}
+			return lines
+		},
+	}
+}
@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/shaban/ffire/pkg/parser"
+	"github.com/shaban/ffire/pkg/results"
+)
+
+const testSchema = `package audio
+
+type DeviceList []Device
+
+type Device struct {
+	Name     string
+	Channels int32
+	Gain     *float32
+	Ports    []Port
+}
+
+type Port struct {
+	ID int16
+}
+`
+
+func testApp(t *testing.T, cfg Config) *App {
+	t.Helper()
+	s, err := parser.ParseBytes([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Canonicalize()
+	cfg.Schema = s
+	return New(cfg)
+}
+
+// screen renders a 100x30 view and checks its dimensions.
+func screen(t *testing.T, a *App) string {
+	t.Helper()
+	lines := a.View(100, 30)
+	if len(lines) != 30 {
+		t.Fatalf("View returned %d lines, want 30", len(lines))
+	}
+	for _, l := range lines {
+		if n := utf8.RuneCountInString(l); n > 100 {
+			t.Fatalf("line is %d runes wide, want at most 100: %q", n, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTypesView(t *testing.T) {
+	a := testApp(t, Config{})
+
+	out := screen(t, a)
+	for _, want := range []string{"[1 Types]", "▸ DeviceList (message)", "message DeviceList = []Device"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("types view lacks %q:\n%s", want, out)
+		}
+	}
+
+	a.Update(KeyDown) // Device
+	out = screen(t, a)
+	for _, want := range []string{
+		"used by   DeviceList",
+		"size      variable",
+		"presence  1 optional, 1 B",
+		"Channels  int32     4 B",
+		"Gain      *float32  optional",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Device details lack %q:\n%s", want, out)
+		}
+	}
+	// Wire order puts fixed fields first
+	if strings.Index(out, "Channels") > strings.Index(out, "Name ") {
+		t.Errorf("fields are not in wire order:\n%s", out)
+	}
+
+	a.Update(KeyDown) // Port
+	if out = screen(t, a); !strings.Contains(out, "fixed, 2 B") || !strings.Contains(out, "used by   Device") {
+		t.Errorf("Port details:\n%s", out)
+	}
+}
+
+func TestFixtureView(t *testing.T) {
+	a := testApp(t, Config{})
+	a.Update(Key1 + 1)
+	if out := screen(t, a); !strings.Contains(out, "No fixture loaded") {
+		t.Errorf("fixture view without a fixture:\n%s", out)
+	}
+
+	a = testApp(t, Config{
+		Fixture:     []byte(`[{"Name":"mic","Channels":2,"Ports":[{"ID":7}]}]`),
+		FixtureName: "devices.json",
+		Message:     "DeviceList",
+	})
+	a.Update(KeyTab)
+	if out := screen(t, a); !strings.Contains(out, `"Name": "mic"`) {
+		t.Errorf("JSON preview lacks the indented fixture:\n%s", out)
+	}
+	a.Update(KeyDown)
+	if out := screen(t, a); !strings.Contains(out, "encode to") || strings.Contains(out, "Cannot encode") {
+		t.Errorf("wire preview:\n%s", out)
+	}
+}
+
+func TestResultsViews(t *testing.T) {
+	rs := []results.Result{
+		{Language: "go", Format: "ffire", Message: "struct", EncodeNs: 100, DecodeNs: 200, TotalNs: 300, WireSize: 10},
+		{Language: "go", Format: "proto", Message: "struct", EncodeNs: 400, DecodeNs: 800, TotalNs: 1200, WireSize: 12},
+	}
+	a := testApp(t, Config{Results: rs})
+
+	a.Update(KeyBackTab) // Wraps around to Graph
+	out := screen(t, a)
+	if !strings.Contains(out, "[4 Graph]") || !strings.Contains(out, "Decode time") || !strings.Contains(out, "(4.00x)") {
+		t.Errorf("graph view:\n%s", out)
+	}
+
+	a.Update(KeyLeft)
+	a.Update(KeyDown) // struct
+	out = screen(t, a)
+	if !strings.Contains(out, "▸ struct") || !strings.Contains(out, "ffire go") || !strings.Contains(out, "1.20 μs") {
+		t.Errorf("results view:\n%s", out)
+	}
+
+	if !a.Update(KeyQuit) {
+		t.Error("KeyQuit did not quit")
+	}
+}
+
+func TestViewScrolls(t *testing.T) {
+	a := testApp(t, Config{
+		Fixture: []byte(`[` + strings.Repeat(`{"Name":"x","Channels":1,"Ports":[]},`, 20) + `{"Name":"last","Channels":1,"Ports":[]}]`),
+		Message: "DeviceList",
+	})
+	a.Update(KeyTab)
+	if out := screen(t, a); strings.Contains(out, `"last"`) || !strings.Contains(out, "lines 1-27 of") {
+		t.Errorf("first page:\n%s", out)
+	}
+	for i := 0; i < 20; i++ {
+		a.Update(KeyPageDown)
+	}
+	if out := screen(t, a); !strings.Contains(out, `"last"`) {
+		t.Errorf("last page lacks the end of the fixture:\n%s", out)
+	}
+}
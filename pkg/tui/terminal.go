@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Key is a key press the UI reacts to.
+type Key int
+
+const (
+	KeyNone Key = iota // Anything else
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyPageUp
+	KeyPageDown
+	KeyTab
+	KeyBackTab
+	KeyQuit // q, Esc or Ctrl-C
+	Key1    // 1 to 9 select a tab; Key1+n is n+1
+)
+
+// escapes are the terminal input sequences of the special keys, in both the
+// CSI (ESC [) and SS3 (ESC O) forms terminals send for arrows.
+var escapes = map[string]Key{
+	"\x1b[A": KeyUp, "\x1bOA": KeyUp,
+	"\x1b[B": KeyDown, "\x1bOB": KeyDown,
+	"\x1b[C": KeyRight, "\x1bOC": KeyRight,
+	"\x1b[D": KeyLeft, "\x1bOD": KeyLeft,
+	"\x1b[5~": KeyPageUp,
+	"\x1b[6~": KeyPageDown,
+	"\x1b[Z":  KeyBackTab,
+}
+
+// ParseKeys decodes the bytes of one read from a raw-mode terminal.
+func ParseKeys(b []byte) []Key {
+	var keys []Key
+	for len(b) > 0 {
+		if b[0] == 0x1b {
+			if len(b) == 1 {
+				return append(keys, KeyQuit) // A lone Esc
+			}
+			n := escapeLen(b)
+			keys = append(keys, escapes[string(b[:n])])
+			b = b[n:]
+			continue
+		}
+		switch c := b[0]; {
+		case c == 'q' || c == 3: // Ctrl-C
+			keys = append(keys, KeyQuit)
+		case c == '\t':
+			keys = append(keys, KeyTab)
+		case c == 'k':
+			keys = append(keys, KeyUp)
+		case c == 'j':
+			keys = append(keys, KeyDown)
+		case c == 'h':
+			keys = append(keys, KeyLeft)
+		case c == 'l':
+			keys = append(keys, KeyRight)
+		case c == ' ':
+			keys = append(keys, KeyPageDown)
+		case c >= '1' && c <= '9':
+			keys = append(keys, Key1+Key(c-'1'))
+		default:
+			keys = append(keys, KeyNone)
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+// escapeLen returns the length of the escape sequence at the start of b:
+// ESC, an introducer, parameters and a final byte.
+func escapeLen(b []byte) int {
+	if b[1] != '[' && b[1] != 'O' {
+		return 1
+	}
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// Run shows app on the terminal connected to in and out until the user
+// quits. It switches the terminal to raw mode and the alternate screen with
+// stty and ANSI escapes, and restores both before returning.
+func Run(app *App, in *os.File, out io.Writer) error {
+	if info, err := in.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return errors.New("ffire ui needs an interactive terminal")
+	}
+	saved, err := stty(in, "-g")
+	if err != nil {
+		return fmt.Errorf("ffire ui needs an interactive terminal: stty: %w", err)
+	}
+	if _, err := stty(in, "raw", "-echo"); err != nil {
+		return err
+	}
+	defer stty(in, strings.TrimSpace(saved))
+
+	w := bufio.NewWriter(out)
+	fmt.Fprint(w, "\x1b[?1049h\x1b[?25l") // Alternate screen, hide cursor
+	defer func() {
+		fmt.Fprint(w, "\x1b[?25h\x1b[?1049l")
+		w.Flush()
+	}()
+
+	buf := make([]byte, 64)
+	for {
+		width, height := size(in)
+		fmt.Fprint(w, "\x1b[H\x1b[2J")
+		fmt.Fprint(w, strings.Join(app.View(width, height), "\r\n"))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		n, err := in.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, k := range ParseKeys(buf[:n]) {
+			if app.Update(k) {
+				return nil
+			}
+		}
+	}
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// size returns the terminal's columns and rows, or 80x24 if stty cannot
+// tell. It is asked before every redraw, so resizing the window just works.
+func size(tty *os.File) (width, height int) {
+	out, err := stty(tty, "size")
+	if err == nil {
+		if f := strings.Fields(out); len(f) == 2 {
+			rows, err1 := strconv.Atoi(f[0])
+			cols, err2 := strconv.Atoi(f[1])
+			if err1 == nil && err2 == nil && rows > 3 && cols > 20 {
+				return cols, rows
+			}
+		}
+	}
+	return 80, 24
+}
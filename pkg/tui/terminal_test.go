@@ -0,0 +1,28 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []Key
+	}{
+		{"\x1b[A\x1b[B", []Key{KeyUp, KeyDown}},
+		{"\x1bOC\x1b[D", []Key{KeyRight, KeyLeft}},
+		{"\x1b[5~\x1b[6~", []Key{KeyPageUp, KeyPageDown}},
+		{"\t\x1b[Z", []Key{KeyTab, KeyBackTab}},
+		{"jk3", []Key{KeyDown, KeyUp, Key1 + 2}},
+		{"\x1b[1;5A", []Key{KeyNone}}, // Ctrl-Up is not bound
+		{"\x1b", []Key{KeyQuit}},
+		{"\x03", []Key{KeyQuit}},
+		{"x", []Key{KeyNone}},
+	}
+	for _, tt := range tests {
+		if got := ParseKeys([]byte(tt.in)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKeys(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package usage keeps a local history of ffire generate runs and summarizes
+// it, so platform teams can see how ffire is used without anything leaving
+// the machine. Recording is opt-in: nothing is written unless
+// FFIRE_USAGE_STATS=1 is set.
+//
+// The history is a JSON Lines file, one Event per run, in the user cache
+// directory (e.g. ~/.cache/ffire/usage.jsonl).
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EnvVar is the environment variable that turns recording on when set to 1.
+const EnvVar = "FFIRE_USAGE_STATS"
+
+// Enabled reports whether the user opted in to recording.
+func Enabled() bool {
+	return os.Getenv(EnvVar) == "1"
+}
+
+// DefaultPath returns the history file in the user cache directory.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ffire", "usage.jsonl"), nil
+}
+
+// Event is one ffire generate run.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Schema      string    `json:"schema"` // Schema package name
+	Language    string    `json:"language"`
+	Types       int       `json:"types"`
+	Messages    int       `json:"messages"`
+	SchemaBytes int64     `json:"schema_bytes,omitempty"` // Size of the .ffi file; 0 for remote schemas
+	OutputBytes int64     `json:"output_bytes,omitempty"` // Size of the output directory after the run
+	DurationMs  int64     `json:"duration_ms"`
+	Failed      bool      `json:"failed,omitempty"`
+}
+
+// Record appends e to the history file at path, creating it if needed.
+func Record(path string, e Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads the history file at path. A missing file is an empty history.
+// Lines that do not parse, such as one cut short by a crash, are skipped
+// and counted in skipped.
+func Load(path string) (events []Event, skipped int, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			skipped++
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, skipped, sc.Err()
+}
+
+// Report summarizes a history.
+type Report struct {
+	Runs      int            `json:"runs"`
+	Failed    int            `json:"failed"`
+	First     time.Time      `json:"first"`
+	Last      time.Time      `json:"last"`
+	Languages []LanguageStat `json:"languages"` // Most runs first
+	Schemas   []SchemaStat   `json:"schemas"`   // Most runs first
+}
+
+// LanguageStat is the runs for one target language.
+type LanguageStat struct {
+	Language string `json:"language"`
+	Runs     int    `json:"runs"`
+	Failed   int    `json:"failed"`
+	MedianMs int64  `json:"median_ms"`
+	P95Ms    int64  `json:"p95_ms"`
+}
+
+// SchemaStat is the runs for one schema. Sizes are those of its latest
+// successful run.
+type SchemaStat struct {
+	Schema      string    `json:"schema"`
+	Runs        int       `json:"runs"`
+	Languages   []string  `json:"languages"`
+	Types       int       `json:"types"`
+	Messages    int       `json:"messages"`
+	SchemaBytes int64     `json:"schema_bytes"`
+	OutputBytes int64     `json:"output_bytes"`
+	Last        time.Time `json:"last"` // Latest successful run
+}
+
+// Summarize aggregates events into a Report.
+func Summarize(events []Event) Report {
+	r := Report{Runs: len(events)}
+	durations := make(map[string][]int64)
+	langs := make(map[string]*LanguageStat)
+	schemas := make(map[string]*SchemaStat)
+	for _, e := range events {
+		if r.First.IsZero() || e.Time.Before(r.First) {
+			r.First = e.Time
+		}
+		if e.Time.After(r.Last) {
+			r.Last = e.Time
+		}
+
+		l := langs[e.Language]
+		if l == nil {
+			l = &LanguageStat{Language: e.Language}
+			langs[e.Language] = l
+		}
+		l.Runs++
+		if e.Failed {
+			r.Failed++
+			l.Failed++
+		} else {
+			// Failed runs stop early and would skew the timings
+			durations[e.Language] = append(durations[e.Language], e.DurationMs)
+		}
+
+		s := schemas[e.Schema]
+		if s == nil {
+			s = &SchemaStat{Schema: e.Schema}
+			schemas[e.Schema] = s
+		}
+		s.Runs++
+		if !slices.Contains(s.Languages, e.Language) {
+			s.Languages = append(s.Languages, e.Language)
+		}
+		if !e.Failed && !e.Time.Before(s.Last) {
+			s.Last = e.Time
+			s.Types, s.Messages = e.Types, e.Messages
+			s.SchemaBytes, s.OutputBytes = e.SchemaBytes, e.OutputBytes
+		}
+	}
+
+	for lang, l := range langs {
+		d := durations[lang]
+		slices.Sort(d)
+		l.MedianMs = percentile(d, 50)
+		l.P95Ms = percentile(d, 95)
+		r.Languages = append(r.Languages, *l)
+	}
+	sort.Slice(r.Languages, func(i, j int) bool {
+		a, b := r.Languages[i], r.Languages[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return a.Language < b.Language
+	})
+
+	for _, s := range schemas {
+		sort.Strings(s.Languages)
+		r.Schemas = append(r.Schemas, *s)
+	}
+	sort.Slice(r.Schemas, func(i, j int) bool {
+		a, b := r.Schemas[i], r.Schemas[j]
+		if a.Runs != b.Runs {
+			return a.Runs > b.Runs
+		}
+		return a.Schema < b.Schema
+	})
+	return r
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted values.
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Write prints r as plain-text tables.
+func (r Report) Write(w io.Writer) {
+	if r.Runs == 0 {
+		fmt.Fprintln(w, "No generation history recorded.")
+		return
+	}
+	fmt.Fprintf(w, "%d runs (%d failed) from %s to %s\n\n", r.Runs, r.Failed,
+		r.First.Format(time.DateOnly), r.Last.Format(time.DateOnly))
+
+	fmt.Fprintf(w, "%-10s %6s %7s %9s %9s\n", "LANGUAGE", "RUNS", "FAILED", "MEDIAN", "P95")
+	for _, l := range r.Languages {
+		fmt.Fprintf(w, "%-10s %6d %7d %9s %9s\n", l.Language, l.Runs, l.Failed,
+			formatMs(l.MedianMs), formatMs(l.P95Ms))
+	}
+
+	fmt.Fprintf(w, "\n%-16s %6s %6s %8s %10s %10s  %s\n", "SCHEMA", "RUNS", "TYPES", "MESSAGES", "SCHEMA", "OUTPUT", "LANGUAGES")
+	for _, s := range r.Schemas {
+		fmt.Fprintf(w, "%-16s %6d %6d %8d %10s %10s  %s\n", s.Schema, s.Runs, s.Types, s.Messages,
+			formatBytes(s.SchemaBytes), formatBytes(s.OutputBytes), strings.Join(s.Languages, ", "))
+	}
+}
+
+func formatMs(ms int64) string {
+	if ms == 0 {
+		return "<1ms"
+	}
+	return (time.Duration(ms) * time.Millisecond).String()
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n == 0:
+		return "-"
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+}
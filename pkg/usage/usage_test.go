@@ -0,0 +1,116 @@
+package usage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func TestRecordLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ffire", "usage.jsonl")
+
+	events, skipped, err := Load(path)
+	if err != nil || events != nil || skipped != 0 {
+		t.Fatalf("Load of missing file = %v, %d, %v; want empty history", events, skipped, err)
+	}
+
+	want := []Event{
+		{Time: t0, Schema: "audio", Language: "go", Types: 4, Messages: 1, SchemaBytes: 300, OutputBytes: 9000, DurationMs: 12},
+		{Time: t0.Add(time.Hour), Schema: "audio", Language: "cpp", Types: 4, Messages: 1, DurationMs: 3, Failed: true},
+	}
+	for _, e := range want {
+		if err := Record(path, e); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	// A line cut short by a crash
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"time":"2026-09-01T`)
+	f.Close()
+
+	events, skipped, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if skipped != 1 {
+		t.Errorf("skipped = %d, want 1", skipped)
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i := range want {
+		if !events[i].Time.Equal(want[i].Time) {
+			t.Errorf("event %d: time %v, want %v", i, events[i].Time, want[i].Time)
+		}
+		events[i].Time = want[i].Time
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var events []Event
+	for i, ms := range []int64{10, 20, 30, 40, 1000} {
+		events = append(events, Event{Time: t0.Add(time.Duration(i) * time.Hour), Schema: "audio", Language: "go", Types: 3 + i, DurationMs: ms})
+	}
+	events = append(events,
+		Event{Time: t0.Add(-time.Hour), Schema: "metrics", Language: "python", DurationMs: 500},
+		Event{Time: t0.Add(10 * time.Hour), Schema: "audio", Language: "python", Types: 99, DurationMs: 1, Failed: true},
+	)
+
+	r := Summarize(events)
+	if r.Runs != 7 || r.Failed != 1 {
+		t.Errorf("runs/failed = %d/%d, want 7/1", r.Runs, r.Failed)
+	}
+	if !r.First.Equal(t0.Add(-time.Hour)) || !r.Last.Equal(t0.Add(10*time.Hour)) {
+		t.Errorf("period = %v - %v", r.First, r.Last)
+	}
+
+	if len(r.Languages) != 2 || r.Languages[0].Language != "go" {
+		t.Fatalf("languages = %+v, want go first", r.Languages)
+	}
+	if got := r.Languages[0]; got.MedianMs != 30 || got.P95Ms != 1000 {
+		t.Errorf("go median/p95 = %d/%d, want 30/1000", got.MedianMs, got.P95Ms)
+	}
+	// The failed run does not count towards timings
+	if got := r.Languages[1]; got.Runs != 2 || got.Failed != 1 || got.MedianMs != 500 {
+		t.Errorf("python = %+v", got)
+	}
+
+	if len(r.Schemas) != 2 || r.Schemas[0].Schema != "audio" {
+		t.Fatalf("schemas = %+v, want audio first", r.Schemas)
+	}
+	audio := r.Schemas[0]
+	if audio.Runs != 6 || strings.Join(audio.Languages, ",") != "go,python" {
+		t.Errorf("audio = %+v", audio)
+	}
+	// Sizes come from the latest successful run
+	if audio.Types != 7 {
+		t.Errorf("audio types = %d, want 7", audio.Types)
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	var buf bytes.Buffer
+	Summarize(nil).Write(&buf)
+	if !strings.Contains(buf.String(), "No generation history") {
+		t.Errorf("empty report = %q", buf.String())
+	}
+
+	buf.Reset()
+	Summarize([]Event{
+		{Time: t0, Schema: "audio", Language: "go", Types: 4, Messages: 1, SchemaBytes: 300, OutputBytes: 9 << 10, DurationMs: 1500},
+	}).Write(&buf)
+	out := buf.String()
+	for _, want := range []string{"1 runs (0 failed) from 2026-09-01 to 2026-09-01", "1.5s", "300 B", "9.0 KiB", "audio"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -2,8 +2,11 @@
 package validator
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
 
 	"github.com/shaban/ffire/pkg/errors"
 	"github.com/shaban/ffire/pkg/schema"
@@ -33,6 +36,18 @@ func ValidateSchema(s *schema.Schema) error {
 		if msg.TargetType == nil {
 			return errors.Newf(errors.ErrNilTargetType, "message %s: target type cannot be nil", msg.Name)
 		}
+		if _, ok := msg.TargetType.(*schema.MapType); ok {
+			return errors.Newf(errors.ErrMapRoot, "message %s: root type cannot be a map", msg.Name)
+		}
+		if _, ok := msg.TargetType.(*schema.EnumType); ok {
+			return errors.Newf(errors.ErrEnumRoot, "message %s: root type cannot be an enum", msg.Name)
+		}
+		if _, ok := msg.TargetType.(*schema.UnionType); ok {
+			return errors.Newf(errors.ErrUnionRoot, "message %s: root type cannot be a union", msg.Name)
+		}
+		if schema.IsFixedArray(msg.TargetType) {
+			return errors.Newf(errors.ErrInvalidFixedArray, "message %s: root type cannot be a fixed-size array", msg.Name)
+		}
 		if err := validateType(s, msg.TargetType, 0); err != nil {
 			return fmt.Errorf("message %s: %w", msg.Name, err)
 		}
@@ -88,10 +103,36 @@ func validateType(s *schema.Schema, typ schema.Type, depth int) error {
 		if t.ElementType == nil {
 			return errors.New(errors.ErrNilArrayElement, "array element type cannot be nil")
 		}
+		if t.Length > 0 {
+			return validateFixedArray(t)
+		}
+		if schema.IsFixedArray(t.ElementType) {
+			return errors.Newf(errors.ErrInvalidFixedArray, "array element %s cannot be a fixed-size array; wrap it in a struct", t.ElementType.TypeName())
+		}
 		if err := validateType(s, t.ElementType, depth+1); err != nil {
 			return fmt.Errorf("array element: %w", err)
 		}
 
+	case *schema.MapType:
+		if !schema.IsMapKey(t.KeyType) {
+			return errors.Newf(errors.ErrInvalidMapKey, "invalid map key type %s: keys must be string, bool or an integer type", typ.TypeName())
+		}
+		if t.ValueType == nil {
+			return errors.New(errors.ErrNilArrayElement, "map value type cannot be nil")
+		}
+		if schema.IsFixedArray(t.ValueType) {
+			return errors.Newf(errors.ErrInvalidFixedArray, "map value %s cannot be a fixed-size array; wrap it in a struct", t.ValueType.TypeName())
+		}
+		if err := validateType(s, t.ValueType, depth+1); err != nil {
+			return fmt.Errorf("map value: %w", err)
+		}
+
+	case *schema.EnumType:
+		return validateEnum(t)
+
+	case *schema.UnionType:
+		return validateUnion(s, t, depth)
+
 	default:
 		return errors.Newf(errors.ErrUnknownType, "unknown type: %T", typ)
 	}
@@ -99,6 +140,84 @@ func validateType(s *schema.Schema, typ schema.Type, depth int) error {
 	return nil
 }
 
+// validateFixedArray checks that a fixed-size array is a non-optional run
+// of 1 to MaxFixedArrayLength numbers or bools, which every generator can
+// hold inline without a length prefix.
+func validateFixedArray(t *schema.ArrayType) error {
+	if t.Optional {
+		return errors.Newf(errors.ErrInvalidFixedArray, "fixed-size array *%s cannot be optional", t.TypeName())
+	}
+	if t.Length > schema.MaxFixedArrayLength {
+		return errors.Newf(errors.ErrInvalidFixedArray, "fixed-size array %s exceeds maximum of %d elements", t.TypeName(), schema.MaxFixedArrayLength)
+	}
+	if schema.GetPrimitiveSize(t.ElementType) == 0 {
+		return errors.Newf(errors.ErrInvalidFixedArray, "fixed-size array %s: elements must be non-optional numbers or bools", t.TypeName())
+	}
+	return nil
+}
+
+// validateEnum checks that an enum has an integer base type whose range
+// holds each constant, and that no two constants share a value.
+func validateEnum(t *schema.EnumType) error {
+	min, max, ok := intRange(t.Base)
+	if !ok {
+		return errors.Newf(errors.ErrInvalidEnumBase, "enum %s: base type %s is not an integer type", t.Name, t.Base)
+	}
+	names := make(map[int64]string, len(t.Values))
+	for _, v := range t.Values {
+		if v.Value < min || v.Value > max {
+			return errors.Newf(errors.ErrEnumValueOutOfRange, "enum %s: %s = %d out of range for %s", t.Name, v.Name, v.Value, t.Base)
+		}
+		if other, ok := names[v.Value]; ok {
+			return errors.Newf(errors.ErrDuplicateEnumValue, "enum %s: %s and %s both have value %d", t.Name, other, v.Name, v.Value)
+		}
+		names[v.Value] = v.Name
+	}
+	return nil
+}
+
+// validateUnion checks that a union has between 1 and 255 variants, each a
+// distinct non-optional struct, enum or primitive.
+func validateUnion(s *schema.Schema, t *schema.UnionType, depth int) error {
+	if len(t.Variants) == 0 || len(t.Variants) > schema.MaxUnionVariants {
+		return errors.Newf(errors.ErrUnionVariantCount, "union %s has %d variants; unions need 1 to %d", t.Name, len(t.Variants), schema.MaxUnionVariants)
+	}
+	seen := make(map[string]bool, len(t.Variants))
+	for _, v := range t.Variants {
+		switch v.(type) {
+		case *schema.PrimitiveType, *schema.StructType, *schema.EnumType:
+		default:
+			return errors.Newf(errors.ErrInvalidUnionVariant, "union %s: variant %s must be a struct, enum or primitive", t.Name, v.TypeName())
+		}
+		if v.IsOptional() {
+			return errors.Newf(errors.ErrInvalidUnionVariant, "union %s: variant *%s cannot be optional", t.Name, v.TypeName())
+		}
+		if seen[v.TypeName()] {
+			return errors.Newf(errors.ErrDuplicateUnionVariant, "union %s lists %s twice", t.Name, v.TypeName())
+		}
+		seen[v.TypeName()] = true
+		if err := validateType(s, v, depth+1); err != nil {
+			return fmt.Errorf("union %s: variant %s: %w", t.Name, v.TypeName(), err)
+		}
+	}
+	return nil
+}
+
+// intRange returns the bounds of an integer primitive.
+func intRange(name string) (min, max int64, ok bool) {
+	switch name {
+	case "int8":
+		return math.MinInt8, math.MaxInt8, true
+	case "int16":
+		return math.MinInt16, math.MaxInt16, true
+	case "int32":
+		return math.MinInt32, math.MaxInt32, true
+	case "int64":
+		return math.MinInt64, math.MaxInt64, true
+	}
+	return 0, 0, false
+}
+
 // checkCircularReferences detects circular type references.
 func checkCircularReferences(s *schema.Schema) error {
 	for _, typ := range s.Types {
@@ -139,6 +258,18 @@ func detectCycle(s *schema.Schema, typ schema.Type, visited map[string]bool) err
 		if err := detectCycle(s, t.ElementType, visited); err != nil {
 			return err
 		}
+
+	case *schema.MapType:
+		if err := detectCycle(s, t.ValueType, visited); err != nil {
+			return err
+		}
+
+	case *schema.UnionType:
+		for _, v := range t.Variants {
+			if err := detectCycle(s, v, visited); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -192,6 +323,15 @@ func validateJSONValue(s *schema.Schema, typ schema.Type, value interface{}, pat
 	case *schema.ArrayType:
 		return validateArray(s, t, value, path)
 
+	case *schema.MapType:
+		return validateMap(s, t, value, path)
+
+	case *schema.EnumType:
+		return validateEnumValue(t, value, path)
+
+	case *schema.UnionType:
+		return validateUnionValue(s, t, value, path)
+
 	default:
 		return fmt.Errorf("%s: unknown type %T", path, typ)
 	}
@@ -245,9 +385,22 @@ func validatePrimitive(typ *schema.PrimitiveType, value interface{}, path string
 		if !ok {
 			return errors.Newf(errors.ErrStringExpected, "%s: expected string, got %T", path, value)
 		}
-		// Validate string length (uint16 wire format limit)
-		if len(str) > 65535 {
-			return errors.Newf(errors.ErrStringTooLong, "%s: string length %d exceeds maximum of 65,535 bytes", path, len(str))
+		// Validate string length (@max, else the uint16 wire format limit, uint32 if @large)
+		if uint64(len(str)) > schema.MaxLengthOf(typ) {
+			return errors.Newf(errors.ErrStringTooLong, "%s: string length %d exceeds maximum of %s bytes", path, len(str), maxLengthText(typ))
+		}
+
+	case "bytes":
+		str, ok := value.(string)
+		if !ok {
+			return errors.Newf(errors.ErrStringExpected, "%s: expected base64 string, got %T", path, value)
+		}
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return errors.Newf(errors.ErrInvalidBase64, "%s: invalid base64: %v", path, err)
+		}
+		if uint64(len(data)) > schema.MaxLengthOf(typ) {
+			return errors.Newf(errors.ErrBytesTooLong, "%s: bytes length %d exceeds maximum of %s bytes", path, len(data), maxLengthText(typ))
 		}
 
 	default:
@@ -257,6 +410,17 @@ func validatePrimitive(typ *schema.PrimitiveType, value interface{}, path string
 	return nil
 }
 
+// maxLengthText spells the length limit of typ for error messages.
+func maxLengthText(typ schema.Type) string {
+	if m := schema.MaxOf(typ); m > 0 {
+		return strconv.FormatUint(uint64(m), 10)
+	}
+	if schema.IsLarge(typ) {
+		return "4,294,967,295"
+	}
+	return "65,535"
+}
+
 // validateStruct validates a struct value.
 func validateStruct(s *schema.Schema, typ *schema.StructType, value interface{}, path string) error {
 	if value == nil && typ.Optional {
@@ -303,9 +467,13 @@ func validateArray(s *schema.Schema, typ *schema.ArrayType, value interface{}, p
 		return errors.Newf(errors.ErrArrayExpected, "%s: expected array, got %T", path, value)
 	}
 
-	// Validate array length (uint16 wire format limit)
-	if len(arr) > 65535 {
-		return errors.Newf(errors.ErrArrayTooLong, "%s: array length %d exceeds maximum of 65,535 elements", path, len(arr))
+	if typ.Length > 0 && len(arr) != typ.Length {
+		return errors.Newf(errors.ErrFixedArrayLength, "%s: array has %d elements, want exactly %d", path, len(arr), typ.Length)
+	}
+
+	// Validate array length (@max, else the uint16 wire format limit, uint32 if @large)
+	if uint64(len(arr)) > schema.MaxLengthOf(typ) {
+		return errors.Newf(errors.ErrArrayTooLong, "%s: array length %d exceeds maximum of %s elements", path, len(arr), maxLengthText(typ))
 	}
 
 	// Validate each element
@@ -318,3 +486,90 @@ func validateArray(s *schema.Schema, typ *schema.ArrayType, value interface{}, p
 
 	return nil
 }
+
+// validateMap validates a map value: a JSON object whose keys spell values
+// of the key type ("42" for an integer key, "true" for a bool key).
+func validateMap(s *schema.Schema, typ *schema.MapType, value interface{}, path string) error {
+	if value == nil && typ.Optional {
+		return nil
+	}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.Newf(errors.ErrObjectExpected, "%s: expected object, got %T", path, value)
+	}
+
+	// Validate map size (@max, else the uint16 wire format limit, uint32 if @large)
+	if uint64(len(obj)) > schema.MaxLengthOf(typ) {
+		return errors.Newf(errors.ErrMapTooLong, "%s: map size %d exceeds maximum of %s entries", path, len(obj), maxLengthText(typ))
+	}
+
+	key := typ.KeyType.(*schema.PrimitiveType)
+	for k, v := range obj {
+		entryPath := fmt.Sprintf("%s[%q]", path, k)
+		switch key.Name {
+		case "string":
+			if err := validatePrimitive(key, k, entryPath); err != nil {
+				return err
+			}
+		case "bool":
+			if k != "true" && k != "false" {
+				return errors.Newf(errors.ErrBoolExpected, "%s: map key %q is not a bool", entryPath, k)
+			}
+		default:
+			n, err := strconv.ParseInt(k, 10, 64)
+			if err != nil {
+				return errors.Newf(errors.ErrIntegerExpected, "%s: map key %q is not an integer", entryPath, k)
+			}
+			if err := validatePrimitive(key, float64(n), entryPath); err != nil {
+				return err
+			}
+		}
+		if err := validateJSONValue(s, typ.ValueType, v, entryPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateEnumValue validates an enum value: a constant name, or a number
+// that is the value of one.
+func validateEnumValue(typ *schema.EnumType, value interface{}, path string) error {
+	switch v := value.(type) {
+	case string:
+		if _, ok := typ.ValueOf(v); !ok {
+			return errors.Newf(errors.ErrUnknownEnumValue, "%s: %q is not a %s", path, v, typ.Name)
+		}
+	case float64:
+		if v != float64(int64(v)) {
+			return errors.Newf(errors.ErrIntegerExpected, "%s: expected integer, got %v", path, v)
+		}
+		if _, ok := typ.Lookup(int64(v)); !ok {
+			return errors.Newf(errors.ErrUnknownEnumValue, "%s: %v is not a %s value", path, v, typ.Name)
+		}
+	default:
+		return errors.Newf(errors.ErrStringExpected, "%s: expected %s name, got %T", path, typ.Name, value)
+	}
+	return nil
+}
+
+// validateUnionValue validates a union value: an object with a single key
+// naming the variant, holding the variant's value.
+func validateUnionValue(s *schema.Schema, typ *schema.UnionType, value interface{}, path string) error {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return errors.Newf(errors.ErrObjectExpected, "%s: expected object naming a %s variant, got %T", path, typ.Name, value)
+	}
+	if len(obj) != 1 {
+		return errors.Newf(errors.ErrTypeMismatch, "%s: %s value must have exactly one key naming the variant, got %d", path, typ.Name, len(obj))
+	}
+	for name, v := range obj {
+		variant, _, ok := typ.Variant(name)
+		if !ok {
+			return errors.Newf(errors.ErrUnknownUnionVariant, "%s: %q is not a %s variant", path, name, typ.Name)
+		}
+		return validateJSONValue(s, variant, v, path+"."+name)
+	}
+	return nil
+}
@@ -138,6 +138,129 @@ func TestValidateSchema_ErrorCodes(t *testing.T) {
 			},
 			wantCode: errors.ErrCircularReference,
 		},
+		{
+			name: "float map key",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{Name: "Test", TargetType: &schema.StructType{
+						Name: "Test",
+						Fields: []schema.Field{
+							{Name: "Names", Type: &schema.MapType{
+								KeyType:   &schema.PrimitiveType{Name: "float64"},
+								ValueType: &schema.PrimitiveType{Name: "string"},
+							}},
+						},
+					}},
+				},
+			},
+			wantCode: errors.ErrInvalidMapKey,
+		},
+		{
+			name: "map root",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{Name: "Test", TargetType: &schema.MapType{
+						KeyType:   &schema.PrimitiveType{Name: "string"},
+						ValueType: &schema.PrimitiveType{Name: "string"},
+					}},
+				},
+			},
+			wantCode: errors.ErrMapRoot,
+		},
+		{
+			name: "enum on a float",
+			schema: enumSchema(&schema.EnumType{Name: "Mode", Base: "float32",
+				Values: []schema.EnumValue{{Name: "On", Value: 1}}}),
+			wantCode: errors.ErrInvalidEnumBase,
+		},
+		{
+			name: "duplicate enum value",
+			schema: enumSchema(&schema.EnumType{Name: "Mode", Base: "int8",
+				Values: []schema.EnumValue{{Name: "On", Value: 1}, {Name: "Enabled", Value: 1}}}),
+			wantCode: errors.ErrDuplicateEnumValue,
+		},
+		{
+			name: "enum value out of range",
+			schema: enumSchema(&schema.EnumType{Name: "Mode", Base: "int8",
+				Values: []schema.EnumValue{{Name: "Big", Value: 128}}}),
+			wantCode: errors.ErrEnumValueOutOfRange,
+		},
+		{
+			name: "enum root",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{Name: "Test", TargetType: &schema.EnumType{Name: "Mode", Base: "int8",
+						Values: []schema.EnumValue{{Name: "On", Value: 1}}}},
+				},
+			},
+			wantCode: errors.ErrEnumRoot,
+		},
+		{
+			name: "array union variant",
+			schema: unionSchema(&schema.UnionType{Name: "Shape", Variants: []schema.Type{
+				&schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int32"}}}}),
+			wantCode: errors.ErrInvalidUnionVariant,
+		},
+		{
+			name: "optional union variant",
+			schema: unionSchema(&schema.UnionType{Name: "Shape", Variants: []schema.Type{
+				&schema.PrimitiveType{Name: "int32", Optional: true}}}),
+			wantCode: errors.ErrInvalidUnionVariant,
+		},
+		{
+			name: "duplicate union variant",
+			schema: unionSchema(&schema.UnionType{Name: "Shape", Variants: []schema.Type{
+				&schema.PrimitiveType{Name: "string"}, &schema.PrimitiveType{Name: "string"}}}),
+			wantCode: errors.ErrDuplicateUnionVariant,
+		},
+		{
+			name:     "empty union",
+			schema:   unionSchema(&schema.UnionType{Name: "Shape"}),
+			wantCode: errors.ErrUnionVariantCount,
+		},
+		{
+			name: "union root",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{Name: "Test", TargetType: &schema.UnionType{Name: "Shape",
+						Variants: []schema.Type{&schema.PrimitiveType{Name: "string"}}}},
+				},
+			},
+			wantCode: errors.ErrUnionRoot,
+		},
+		{
+			name: "fixed array of strings",
+			schema: fieldSchema(&schema.ArrayType{Length: 4,
+				ElementType: &schema.PrimitiveType{Name: "string"}}),
+			wantCode: errors.ErrInvalidFixedArray,
+		},
+		{
+			name: "optional fixed array",
+			schema: fieldSchema(&schema.ArrayType{Length: 4, Optional: true,
+				ElementType: &schema.PrimitiveType{Name: "float32"}}),
+			wantCode: errors.ErrInvalidFixedArray,
+		},
+		{
+			name: "fixed array element",
+			schema: fieldSchema(&schema.ArrayType{ElementType: &schema.ArrayType{Length: 3,
+				ElementType: &schema.PrimitiveType{Name: "float32"}}}),
+			wantCode: errors.ErrInvalidFixedArray,
+		},
+		{
+			name: "fixed array root",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{Name: "Test", TargetType: &schema.ArrayType{Length: 3,
+						ElementType: &schema.PrimitiveType{Name: "float32"}}},
+				},
+			},
+			wantCode: errors.ErrInvalidFixedArray,
+		},
 	}
 
 	for _, tt := range tests {
@@ -155,6 +278,47 @@ func TestValidateSchema_ErrorCodes(t *testing.T) {
 	}
 }
 
+// enumSchema returns a schema whose message has a single field of type e.
+func enumSchema(e *schema.EnumType) *schema.Schema {
+	return &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Test", TargetType: &schema.StructType{
+				Name:   "Test",
+				Fields: []schema.Field{{Name: "Mode", Type: e}},
+			}},
+		},
+		Types: []schema.Type{e},
+	}
+}
+
+// unionSchema returns a schema whose message has a single field of type u.
+func unionSchema(u *schema.UnionType) *schema.Schema {
+	return &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Test", TargetType: &schema.StructType{
+				Name:   "Test",
+				Fields: []schema.Field{{Name: "Shape", Type: u}},
+			}},
+		},
+		Types: []schema.Type{u},
+	}
+}
+
+// fieldSchema returns a schema whose message has a single field of type t.
+func fieldSchema(t schema.Type) *schema.Schema {
+	return &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{Name: "Test", TargetType: &schema.StructType{
+				Name:   "Test",
+				Fields: []schema.Field{{Name: "Value", Type: t}},
+			}},
+		},
+	}
+}
+
 func TestValidateJSON_ErrorCodes(t *testing.T) {
 	schema := &schema.Schema{
 		Package: "test",
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/shaban/ffire/pkg/errors"
 	"github.com/shaban/ffire/pkg/schema"
 )
 
@@ -529,6 +530,35 @@ func TestValidateUint16Bounds(t *testing.T) {
 			shouldErr: true,
 			errMsg:    "string length 65536 exceeds maximum of 65,535 bytes",
 		},
+		{
+			name: "string exceeds @max",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{
+						Name:       "Message",
+						TargetType: &schema.PrimitiveType{Name: "string", Max: 4},
+					},
+				},
+			},
+			jsonData:  `"hello"`,
+			shouldErr: true,
+			errMsg:    "string length 5 exceeds maximum of 4 bytes",
+		},
+		{
+			name: "array within @max",
+			schema: &schema.Schema{
+				Package: "test",
+				Messages: []schema.MessageType{
+					{
+						Name:       "Message",
+						TargetType: &schema.ArrayType{ElementType: &schema.PrimitiveType{Name: "int32"}, Max: 2},
+					},
+				},
+			},
+			jsonData:  `[1, 2]`,
+			shouldErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -564,3 +594,127 @@ func containsHelper(s, substr string) bool {
 	}
 	return false
 }
+
+func TestValidateJSONMap(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{
+						{Name: "Names", Type: &schema.MapType{
+							KeyType:   &schema.PrimitiveType{Name: "int16"},
+							ValueType: &schema.PrimitiveType{Name: "string", Optional: true},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	if err := ValidateJSON(s, "Message", []byte(`{"Names": {"1": "one", "-2": null}}`)); err != nil {
+		t.Errorf("ValidateJSON failed: %v", err)
+	}
+
+	for _, bad := range []string{`{"x": "one"}`, `{"40000": "big"}`, `{"1": 1}`, `["one"]`} {
+		bad = `{"Names": ` + bad + `}`
+		if err := ValidateJSON(s, "Message", []byte(bad)); err == nil {
+			t.Errorf("ValidateJSON(%s) should fail", bad)
+		}
+	}
+}
+
+func TestValidateJSONEnum(t *testing.T) {
+	mode := &schema.EnumType{Name: "Mode", Base: "int8", Values: []schema.EnumValue{
+		{Name: "Mono", Value: 1},
+		{Name: "Stereo", Value: 2},
+	}}
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name:   "Message",
+					Fields: []schema.Field{{Name: "Mode", Type: mode}},
+				},
+			},
+		},
+	}
+
+	for _, good := range []string{`"Stereo"`, `1`} {
+		if err := ValidateJSON(s, "Message", []byte(`{"Mode": `+good+`}`)); err != nil {
+			t.Errorf("ValidateJSON(%s) failed: %v", good, err)
+		}
+	}
+
+	for _, bad := range []string{`"Quad"`, `3`, `1.5`, `true`} {
+		bad = `{"Mode": ` + bad + `}`
+		if err := ValidateJSON(s, "Message", []byte(bad)); err == nil {
+			t.Errorf("ValidateJSON(%s) should fail", bad)
+		}
+	}
+}
+
+func TestValidateJSONUnion(t *testing.T) {
+	circle := &schema.StructType{Name: "Circle", Fields: []schema.Field{
+		{Name: "R", Type: &schema.PrimitiveType{Name: "float32"}},
+	}}
+	shape := &schema.UnionType{Name: "Shape", Variants: []schema.Type{circle, &schema.PrimitiveType{Name: "string"}}}
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name:   "Message",
+					Fields: []schema.Field{{Name: "Shape", Type: shape}},
+				},
+			},
+		},
+	}
+
+	for _, good := range []string{`{"Circle": {"R": 1.5}}`, `{"string": "dot"}`} {
+		if err := ValidateJSON(s, "Message", []byte(`{"Shape": `+good+`}`)); err != nil {
+			t.Errorf("ValidateJSON(%s) failed: %v", good, err)
+		}
+	}
+
+	for _, bad := range []string{`{"Square": {}}`, `{"Circle": {"R": 1}, "string": "x"}`, `{}`, `{"string": 1}`, `"Circle"`} {
+		bad = `{"Shape": ` + bad + `}`
+		if err := ValidateJSON(s, "Message", []byte(bad)); err == nil {
+			t.Errorf("ValidateJSON(%s) should fail", bad)
+		}
+	}
+}
+
+func TestValidateJSONFixedArray(t *testing.T) {
+	s := &schema.Schema{
+		Package: "test",
+		Messages: []schema.MessageType{
+			{
+				Name: "Message",
+				TargetType: &schema.StructType{
+					Name: "Message",
+					Fields: []schema.Field{{Name: "Gains", Type: &schema.ArrayType{
+						Length: 3, ElementType: &schema.PrimitiveType{Name: "float32"}}}},
+				},
+			},
+		},
+	}
+	if err := ValidateSchema(s); err != nil {
+		t.Fatalf("ValidateSchema failed: %v", err)
+	}
+
+	if err := ValidateJSON(s, "Message", []byte(`{"Gains": [1, 0.5, -2]}`)); err != nil {
+		t.Errorf("ValidateJSON failed: %v", err)
+	}
+	for _, bad := range []string{`[]`, `[1, 2]`, `[1, 2, 3, 4]`} {
+		err := ValidateJSON(s, "Message", []byte(`{"Gains": `+bad+`}`))
+		if !errors.IsCode(err, errors.ErrFixedArrayLength) {
+			t.Errorf("ValidateJSON(%s) = %v, want %s", bad, err, errors.ErrFixedArrayLength)
+		}
+	}
+}
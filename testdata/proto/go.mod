@@ -1,3 +0,0 @@
-module github.com/shaban/ffire/testdata/proto
-
-go 1.21